@@ -91,6 +91,10 @@ nofuse: GOTAGS += nofuse
 nofuse: build
 .PHONY: nofuse
 
+edge: GOTAGS += nofuse nodht nowebui
+edge: build
+.PHONY: edge
+
 install: cmd/ipfs-install
 .PHONY: install
 
@@ -122,6 +126,7 @@ help:
 	@echo '  all          - print this help message'
 	@echo '  build        - Build binary at ./cmd/ipfs/ipfs'
 	@echo '  nofuse       - Build binary with no fuse support'
+	@echo '  edge         - Build binary with no fuse, DHT and webui support'
 	@echo '  install      - Build binary and install into $$GOBIN'
 	@echo '  mod_tidy     - Remove unused dependencis from go.mod files'
 #	@echo '  dist_install - TODO: c.f. ./cmd/ipfs/dist/README.md'
@@ -0,0 +1,387 @@
+// Package bloombs provides a Blockstore wrapper that answers the lookups of
+// missing blocks from a Bloom filter of the keys of the blockstore.
+//
+// Building the filter takes a scan of all the keys, which lasts minutes on
+// large repos. The filter is saved to the datastore when the blockstore is
+// closed, and loaded back when it is opened again. A saved filter is removed
+// as soon as it is loaded, and whenever a block is written after it was saved,
+// so that a node that doesn't stop cleanly builds it again from the keys
+// instead of loading a stale filter.
+package bloombs
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bstore "github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("bloombs")
+
+// DatastoreKey is the key the filter is saved under.
+var DatastoreKey = datastore.NewKey("/local/bloomfilter")
+
+// States of the filter.
+const (
+	// StateBuilding means the keys are being added to the filter, which
+	// doesn't answer lookups yet.
+	StateBuilding = "building"
+	// StateReady means the filter answers lookups.
+	StateReady = "ready"
+	// StateFailed means the filter couldn't be built, lookups go to the
+	// wrapped blockstore.
+	StateFailed = "failed"
+)
+
+// Status reports how the filter was built.
+type Status struct {
+	State string
+	// Loaded is true when the filter was loaded from the datastore instead
+	// of being built from the keys.
+	Loaded bool
+	// Keys is the number of keys added while building the filter.
+	Keys uint64
+	// Duration is the time taken to load or build the filter, so far.
+	Duration time.Duration
+	Error    string `json:",omitempty"`
+}
+
+// Blockstore answers Has, Get and GetSize for missing blocks from a Bloom
+// filter, without reaching the wrapped Blockstore.
+type Blockstore struct {
+	bstore.Blockstore
+
+	ds     datastore.Datastore
+	filter *filter
+	active atomic.Bool
+	// saved is true while the filter saved in the datastore is up to date.
+	// Writes hold savedLk for reading, saving the filter holds it for
+	// writing, so that no key is missing from the filter saved.
+	saved   atomic.Bool
+	savedLk sync.RWMutex
+
+	keys    atomic.Uint64
+	started time.Time
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	lk       sync.Mutex
+	state    string
+	loaded   bool
+	duration time.Duration
+	err      error
+}
+
+var _ bstore.Blockstore = (*Blockstore)(nil)
+
+// New wraps bs with a filter of size bytes and the given number of hash
+// functions. The filter saved in ds is loaded when its parameters match,
+// otherwise it is built from the keys of bs in the background.
+func New(ds datastore.Datastore, bs bstore.Blockstore, size, hashes int) (*Blockstore, error) {
+	if size <= 0 || hashes <= 0 {
+		return nil, errors.New("the size and number of hashes of the bloom filter must be positive")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Blockstore{
+		Blockstore: bs,
+		ds:         ds,
+		filter:     newFilter(size, hashes),
+		started:    time.Now(),
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		state:      StateBuilding,
+	}
+
+	if err := b.load(ctx); err != nil {
+		log.Warnf("loading the bloom filter, building it again: %s", err)
+	}
+	if b.active.Load() {
+		close(b.done)
+		return b, nil
+	}
+	go b.build(ctx)
+	return b, nil
+}
+
+// load loads the saved filter, and removes it from the datastore.
+func (b *Blockstore) load(ctx context.Context) error {
+	data, err := b.ds.Get(ctx, DatastoreKey)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// the filter isn't valid anymore once blocks are written
+	if err := b.ds.Delete(ctx, DatastoreKey); err != nil {
+		return err
+	}
+	if err := b.ds.Sync(ctx, DatastoreKey); err != nil {
+		return err
+	}
+	if err := b.filter.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	b.lk.Lock()
+	b.state = StateReady
+	b.loaded = true
+	b.duration = time.Since(b.started)
+	b.lk.Unlock()
+	b.active.Store(true)
+	log.Debugf("bloom filter loaded in %s", time.Since(b.started))
+	return nil
+}
+
+func (b *Blockstore) build(ctx context.Context) {
+	defer close(b.done)
+
+	err := func() error {
+		keys, err := b.Blockstore.AllKeysChan(ctx)
+		if err != nil {
+			return err
+		}
+		for {
+			select {
+			case c, ok := <-keys:
+				if !ok {
+					return ctx.Err()
+				}
+				b.filter.add(c.Hash())
+				b.keys.Add(1)
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}()
+
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	b.duration = time.Since(b.started)
+	if err != nil {
+		b.state = StateFailed
+		b.err = err
+		if ctx.Err() == nil {
+			log.Errorf("building the bloom filter: %s", err)
+		}
+		return
+	}
+	b.state = StateReady
+	b.active.Store(true)
+	log.Infof("bloom filter built from %d keys in %s", b.keys.Load(), b.duration)
+}
+
+// Wait waits for the filter to be loaded or built.
+func (b *Blockstore) Wait(ctx context.Context) error {
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	return b.err
+}
+
+// Status returns the status of the filter.
+func (b *Blockstore) Status() Status {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	s := Status{
+		State:    b.state,
+		Loaded:   b.loaded,
+		Keys:     b.keys.Load(),
+		Duration: b.duration,
+	}
+	if s.State == StateBuilding {
+		s.Duration = time.Since(b.started)
+	}
+	if b.err != nil {
+		s.Error = b.err.Error()
+	}
+	return s
+}
+
+// Close stops building the filter, or saves it to the datastore once built.
+func (b *Blockstore) Close() error {
+	b.cancel()
+	<-b.done
+	if !b.active.Load() {
+		return nil
+	}
+
+	b.savedLk.Lock()
+	defer b.savedLk.Unlock()
+	ctx := context.Background()
+	data, err := b.filter.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := b.ds.Put(ctx, DatastoreKey, data); err != nil {
+		return err
+	}
+	if err := b.ds.Sync(ctx, DatastoreKey); err != nil {
+		return err
+	}
+	b.saved.Store(true)
+	return nil
+}
+
+// written removes the saved filter, which doesn't have the keys written.
+func (b *Blockstore) written() error {
+	if b.saved.CompareAndSwap(true, false) {
+		return b.ds.Delete(context.Background(), DatastoreKey)
+	}
+	return nil
+}
+
+// missing returns true when the filter tells c isn't in the blockstore.
+func (b *Blockstore) missing(c cid.Cid) bool {
+	return c.Defined() && b.active.Load() && !b.filter.has(c.Hash())
+}
+
+// Has implements Blockstore.
+func (b *Blockstore) Has(ctx context.Context, c cid.Cid) (bool, error) {
+	if b.missing(c) {
+		return false, nil
+	}
+	return b.Blockstore.Has(ctx, c)
+}
+
+// Get implements Blockstore.
+func (b *Blockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if b.missing(c) {
+		return nil, ipld.ErrNotFound{Cid: c}
+	}
+	return b.Blockstore.Get(ctx, c)
+}
+
+// GetSize implements Blockstore.
+func (b *Blockstore) GetSize(ctx context.Context, c cid.Cid) (int, error) {
+	if b.missing(c) {
+		return -1, ipld.ErrNotFound{Cid: c}
+	}
+	return b.Blockstore.GetSize(ctx, c)
+}
+
+// DeleteBlock implements Blockstore.
+func (b *Blockstore) DeleteBlock(ctx context.Context, c cid.Cid) error {
+	if b.missing(c) {
+		return nil
+	}
+	return b.Blockstore.DeleteBlock(ctx, c)
+}
+
+// Put implements Blockstore.
+func (b *Blockstore) Put(ctx context.Context, blk blocks.Block) error {
+	b.savedLk.RLock()
+	defer b.savedLk.RUnlock()
+	if err := b.written(); err != nil {
+		return err
+	}
+	// keys are added once written: the filter tells the blocks being
+	// written are missing until then
+	if err := b.Blockstore.Put(ctx, blk); err != nil {
+		return err
+	}
+	b.filter.add(blk.Cid().Hash())
+	return nil
+}
+
+// PutMany implements Blockstore.
+func (b *Blockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	b.savedLk.RLock()
+	defer b.savedLk.RUnlock()
+	if err := b.written(); err != nil {
+		return err
+	}
+	if err := b.Blockstore.PutMany(ctx, blks); err != nil {
+		return err
+	}
+	for _, blk := range blks {
+		b.filter.add(blk.Cid().Hash())
+	}
+	return nil
+}
+
+// filter is a Bloom filter safe for concurrent use.
+type filter struct {
+	words  []uint64
+	hashes uint64
+}
+
+func newFilter(size, hashes int) *filter {
+	return &filter{words: make([]uint64, (size+7)/8), hashes: uint64(hashes)}
+}
+
+// locations calls fn with the bits of key, until it returns false.
+func (f *filter) locations(key []byte, fn func(word int, mask uint64) bool) {
+	h := fnv.New64a()
+	_, _ = h.Write(key)
+	sum := h.Sum64()
+	// double hashing, see Kirsch and Mitzenmacher
+	h1, h2 := sum&0xffffffff, sum>>32|1
+	bits := uint64(len(f.words)) * 64
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % bits
+		if !fn(int(bit/64), 1<<(bit%64)) {
+			return
+		}
+	}
+}
+
+func (f *filter) add(key []byte) {
+	f.locations(key, func(word int, mask uint64) bool {
+		for {
+			old := atomic.LoadUint64(&f.words[word])
+			if old&mask != 0 || atomic.CompareAndSwapUint64(&f.words[word], old, old|mask) {
+				return true
+			}
+		}
+	})
+}
+
+func (f *filter) has(key []byte) bool {
+	has := true
+	f.locations(key, func(word int, mask uint64) bool {
+		has = atomic.LoadUint64(&f.words[word])&mask != 0
+		return has
+	})
+	return has
+}
+
+// MarshalBinary encodes the filter as its number of words and hashes,
+// followed by its words, little endian.
+func (f *filter) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 16+8*len(f.words))
+	binary.LittleEndian.PutUint64(buf, uint64(len(f.words)))
+	binary.LittleEndian.PutUint64(buf[8:], f.hashes)
+	for i := range f.words {
+		binary.LittleEndian.PutUint64(buf[16+8*i:], atomic.LoadUint64(&f.words[i]))
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a filter with the parameters of f.
+func (f *filter) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 16 ||
+		binary.LittleEndian.Uint64(buf) != uint64(len(f.words)) ||
+		binary.LittleEndian.Uint64(buf[8:]) != f.hashes ||
+		len(buf) != 16+8*len(f.words) {
+		return errors.New("the saved bloom filter doesn't match the configured size and number of hashes")
+	}
+	for i := range f.words {
+		f.words[i] = binary.LittleEndian.Uint64(buf[16+8*i:])
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package bloombs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	bstore "github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	base := bstore.NewBlockstore(ds)
+
+	var blks []blocks.Block
+	for i := 0; i < 100; i++ {
+		blks = append(blks, blocks.NewBlock([]byte(fmt.Sprint("block ", i))))
+	}
+	if err := base.PutMany(ctx, blks[:50]); err != nil {
+		t.Fatal(err)
+	}
+
+	open := func() *Blockstore {
+		t.Helper()
+		bs, err := New(ds, base, 4096, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bs.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return bs
+	}
+	expectBlocks := func(bs *Blockstore, n int) {
+		t.Helper()
+		for i, blk := range blks {
+			has, err := bs.Has(ctx, blk.Cid())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if has != (i < n) {
+				t.Fatalf("block %d: expected has=%t", i, i < n)
+			}
+		}
+	}
+
+	// built from the keys
+	bs := open()
+	if s := bs.Status(); s.State != StateReady || s.Loaded || s.Keys != 50 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+	expectBlocks(bs, 50)
+	if err := bs.PutMany(ctx, blks[50:60]); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// loaded, and removed from the datastore
+	bs = open()
+	if s := bs.Status(); s.State != StateReady || !s.Loaded {
+		t.Fatalf("unexpected status %+v", s)
+	}
+	if has, _ := ds.Has(ctx, DatastoreKey); has {
+		t.Fatal("expected the saved filter to be removed once loaded")
+	}
+	expectBlocks(bs, 60)
+
+	// blocks written after the filter was saved invalidate it
+	if err := bs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.Put(ctx, blks[60]); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := ds.Has(ctx, DatastoreKey); has {
+		t.Fatal("expected the saved filter to be removed once stale")
+	}
+	bs = open()
+	if s := bs.Status(); s.Loaded {
+		t.Fatalf("unexpected status %+v", s)
+	}
+	expectBlocks(bs, 61)
+
+	// a filter of another size is built again
+	if err := bs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	other, err := New(ds, base, 8192, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s := other.Status(); s.Loaded || s.Keys != 61 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+}
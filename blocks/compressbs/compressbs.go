@@ -0,0 +1,333 @@
+// Package compressbs provides a Blockstore wrapper that transparently
+// compresses blocks at rest.
+//
+// Compressed blocks are stored in the wrapped Blockstore like the other
+// blocks, and marked out of band by an entry of the datastore under
+// MarkerPrefix, keyed like the block, which holds their uncompressed size.
+// Blocks without a marker are returned unchanged, whatever their data, so
+// compression can be enabled on an existing repo without migrating it, and
+// the wrapper is only needed while the repo holds compressed blocks.
+//
+// Unlike a self-describing envelope, the marker is not part of the stored
+// data: a compressed block read from the wrapped Blockstore, or copied out of
+// the datastore, without its marker is the zstd stream, not the block. Blocks
+// must be read through the wrapper to leave the repo.
+package compressbs
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	bstore "github.com/ipfs/boxo/blockstore"
+	dshelp "github.com/ipfs/boxo/datastore/dshelp"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/klauspost/compress/zstd"
+	mc "github.com/multiformats/go-multicodec"
+)
+
+// MarkerPrefix is the datastore namespace of the markers of the compressed
+// blocks.
+var MarkerPrefix = ds.NewKey("/blocks-zstd")
+
+// DefaultCodecKey is the key of Policy.MinSize that applies to every codec
+// not listed explicitly.
+const DefaultCodecKey = "default"
+
+// ErrCorruptBlock is returned when a compressed block can't be decoded.
+var ErrCorruptBlock = errors.New("compressbs: corrupt compressed block")
+
+// Policy decides which blocks get compressed.
+type Policy struct {
+	// Enabled turns on compression of written blocks. A Blockstore with a
+	// disabled policy still reads the compressed blocks, see HasCompressed.
+	Enabled bool
+
+	// MinSize maps a codec name (e.g. "raw", "dag-pb") to the minimum block
+	// size eligible for compression. A negative size disables compression for
+	// the codec. Codecs not present use the DefaultCodecKey entry.
+	MinSize map[string]int64
+
+	// Level is the zstd encoder level.
+	Level zstd.EncoderLevel
+}
+
+// DefaultPolicy compresses structured blocks of at least 512 bytes and raw
+// leaves of at least 4KiB, which are usually file data.
+func DefaultPolicy() Policy {
+	return Policy{
+		Enabled: true,
+		MinSize: map[string]int64{
+			DefaultCodecKey: 512,
+			"raw":           4096,
+		},
+		Level: zstd.SpeedDefault,
+	}
+}
+
+func (p Policy) minSize(c cid.Cid) int64 {
+	if sz, ok := p.MinSize[mc.Code(c.Type()).String()]; ok {
+		return sz
+	}
+	if sz, ok := p.MinSize[DefaultCodecKey]; ok {
+		return sz
+	}
+	return 0
+}
+
+// Stats reports how much space compression saved since the blockstore was
+// opened.
+type Stats struct {
+	Enabled          bool
+	BlocksWritten    uint64 // blocks written through the blockstore
+	BlocksCompressed uint64 // blocks stored compressed
+	BytesIn          uint64 // uncompressed size of written blocks
+	BytesStored      uint64 // size of written blocks as stored
+}
+
+// Saved returns the number of bytes saved by compression.
+func (s Stats) Saved() int64 {
+	return int64(s.BytesIn) - int64(s.BytesStored)
+}
+
+// Blockstore compresses blocks according to a Policy before handing them to
+// the wrapped Blockstore.
+type Blockstore struct {
+	bstore.Blockstore
+
+	markers    ds.Datastore
+	policy     Policy
+	enc        *zstd.Encoder
+	dec        *zstd.Decoder
+	hashOnRead atomic.Bool
+
+	blocksWritten    atomic.Uint64
+	blocksCompressed atomic.Uint64
+	bytesIn          atomic.Uint64
+	bytesStored      atomic.Uint64
+}
+
+var _ bstore.Blockstore = (*Blockstore)(nil)
+
+// New wraps bs, storing the markers of the compressed blocks in d. The wrapped
+// blockstore must not rehash blocks on read, this is done by the wrapper
+// after decompression instead.
+func New(bs bstore.Blockstore, d ds.Datastore, policy Policy) (*Blockstore, error) {
+	cbs := &Blockstore{
+		Blockstore: bs,
+		markers:    d,
+		policy:     policy,
+	}
+
+	var err error
+	if policy.Enabled {
+		cbs.enc, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(policy.Level), zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+	}
+	cbs.dec, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	if err != nil {
+		return nil, err
+	}
+	return cbs, nil
+}
+
+// HasCompressed tells whether d holds markers of compressed blocks, in which
+// case the blockstore must be wrapped to read them, even with compression
+// disabled.
+func HasCompressed(ctx context.Context, d ds.Datastore) (bool, error) {
+	res, err := d.Query(ctx, dsq.Query{Prefix: MarkerPrefix.String(), KeysOnly: true, Limit: 1})
+	if err != nil {
+		return false, err
+	}
+	defer res.Close()
+	for r := range res.Next() {
+		if r.Error != nil {
+			return false, r.Error
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// Stats returns the compression statistics.
+func (bs *Blockstore) Stats() Stats {
+	return Stats{
+		Enabled:          bs.policy.Enabled,
+		BlocksWritten:    bs.blocksWritten.Load(),
+		BlocksCompressed: bs.blocksCompressed.Load(),
+		BytesIn:          bs.bytesIn.Load(),
+		BytesStored:      bs.bytesStored.Load(),
+	}
+}
+
+// HashOnRead implements Blockstore.
+func (bs *Blockstore) HashOnRead(enabled bool) {
+	bs.hashOnRead.Store(enabled)
+}
+
+func markerKey(c cid.Cid) ds.Key {
+	return MarkerPrefix.Child(dshelp.MultihashToDsKey(c.Hash()))
+}
+
+// marker returns the uncompressed size of the block c, and whether it is
+// stored compressed.
+func (bs *Blockstore) marker(ctx context.Context, c cid.Cid) (int, bool, error) {
+	v, err := bs.markers.Get(ctx, markerKey(c))
+	if err == ds.ErrNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	size, n := binary.Uvarint(v)
+	if n <= 0 {
+		return 0, false, fmt.Errorf("%s: %w", c, ErrCorruptBlock)
+	}
+	return int(size), true, nil
+}
+
+// Put implements Blockstore.
+func (bs *Blockstore) Put(ctx context.Context, b blocks.Block) error {
+	return bs.PutMany(ctx, []blocks.Block{b})
+}
+
+// PutMany implements Blockstore.
+func (bs *Blockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	out := make([]blocks.Block, 0, len(blks))
+	for _, b := range blks {
+		// the marker of a block already stored must not change
+		has, err := bs.Blockstore.Has(ctx, b.Cid())
+		if err != nil {
+			return err
+		}
+		if has {
+			continue
+		}
+
+		eb, err := bs.encode(b)
+		if err != nil {
+			return err
+		}
+		// the marker is written before the block, and deleted after it, so
+		// that a compressed block is never read without it
+		k := markerKey(b.Cid())
+		if eb != b {
+			err = bs.markers.Put(ctx, k, binary.AppendUvarint(nil, uint64(len(b.RawData()))))
+		} else {
+			// only a deletion interrupted between the block and its
+			// marker leaves a marker behind
+			var stale bool
+			stale, err = bs.markers.Has(ctx, k)
+			if err == nil && stale {
+				err = bs.markers.Delete(ctx, k)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		out = append(out, eb)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return bs.Blockstore.PutMany(ctx, out)
+}
+
+// DeleteBlock implements Blockstore.
+func (bs *Blockstore) DeleteBlock(ctx context.Context, c cid.Cid) error {
+	if err := bs.Blockstore.DeleteBlock(ctx, c); err != nil {
+		return err
+	}
+	return bs.markers.Delete(ctx, markerKey(c))
+}
+
+// Get implements Blockstore.
+func (bs *Blockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	size, compressed, err := bs.marker(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	b, err := bs.Blockstore.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	data := b.RawData()
+	if compressed {
+		data, err = bs.decode(data, size)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c, err)
+		}
+	}
+
+	if bs.hashOnRead.Load() {
+		rbcid, err := c.Prefix().Sum(data)
+		if err != nil {
+			return nil, err
+		}
+		if !rbcid.Equals(c) {
+			return nil, bstore.ErrHashMismatch
+		}
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
+
+// GetSize implements Blockstore. It returns the uncompressed size of the
+// block, kept in its marker.
+func (bs *Blockstore) GetSize(ctx context.Context, c cid.Cid) (int, error) {
+	size, compressed, err := bs.marker(ctx, c)
+	if err != nil {
+		return -1, err
+	}
+	if !compressed {
+		return bs.Blockstore.GetSize(ctx, c)
+	}
+	// the marker may outlive the block if deleting it was interrupted
+	has, err := bs.Blockstore.Has(ctx, c)
+	if err != nil {
+		return -1, err
+	}
+	if !has {
+		return -1, ipld.ErrNotFound{Cid: c}
+	}
+	return size, nil
+}
+
+// encode returns the block to store for b: b itself, or its compressed data
+// when the policy applies and compression pays off.
+func (bs *Blockstore) encode(b blocks.Block) (blocks.Block, error) {
+	data := b.RawData()
+	bs.blocksWritten.Add(1)
+	bs.bytesIn.Add(uint64(len(data)))
+
+	if min := bs.policy.minSize(b.Cid()); bs.policy.Enabled && min >= 0 && int64(len(data)) >= min {
+		compressed := bs.enc.EncodeAll(data, make([]byte, 0, len(data)/2))
+		// only keep compressed data when it saves at least 1/8th
+		if len(compressed) < len(data)-len(data)/8 {
+			bs.blocksCompressed.Add(1)
+			bs.bytesStored.Add(uint64(len(compressed)))
+			return blocks.NewBlockWithCid(compressed, b.Cid())
+		}
+	}
+	bs.bytesStored.Add(uint64(len(data)))
+	return b, nil
+}
+
+func (bs *Blockstore) decode(data []byte, size int) ([]byte, error) {
+	out, err := bs.dec.DecodeAll(data, make([]byte, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorruptBlock, err)
+	}
+	if len(out) != size {
+		return nil, ErrCorruptBlock
+	}
+	return out, nil
+}
@@ -0,0 +1,155 @@
+package compressbs
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	bstore "github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func newBlock(t *testing.T, codec uint64, data []byte) blocks.Block {
+	t.Helper()
+	c, err := cid.Prefix{Version: 1, Codec: codec, MhType: mh.SHA2_256, MhLength: -1}.Sum(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	inner := bstore.NewBlockstore(d)
+	bs, err := New(inner, d, DefaultPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs.HashOnRead(true)
+
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog "), 200)
+	cases := map[string]blocks.Block{
+		"compressible": newBlock(t, cid.DagProtobuf, text),
+		"small raw":    newBlock(t, cid.Raw, text[:1000]),
+		// a raw block holding a zstd frame is not mistaken for a compressed one
+		"zstd frame": newBlock(t, cid.Raw, bs.enc.EncodeAll(text, nil)),
+	}
+	for name, b := range cases {
+		if err := bs.Put(ctx, b); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		got, err := bs.Get(ctx, b.Cid())
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if !bytes.Equal(got.RawData(), b.RawData()) {
+			t.Fatalf("%s: data mismatch", name)
+		}
+		size, err := bs.GetSize(ctx, b.Cid())
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if size != len(b.RawData()) {
+			t.Fatalf("%s: expected size %d, got %d", name, len(b.RawData()), size)
+		}
+	}
+
+	stored, err := inner.Get(ctx, cases["compressible"].Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.RawData()) >= len(text) {
+		t.Fatal("block was not compressed")
+	}
+	stored, err = inner.Get(ctx, cases["small raw"].Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored.RawData(), cases["small raw"].RawData()) {
+		t.Fatal("raw block below the policy minimum was modified")
+	}
+
+	s := bs.Stats()
+	if s.BlocksWritten != 3 || s.BlocksCompressed != 1 || s.Saved() <= 0 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+
+	// the marker goes with the block
+	if ok, err := HasCompressed(ctx, d); err != nil || !ok {
+		t.Fatalf("expected compressed blocks, got %t, %v", ok, err)
+	}
+	if err := bs.DeleteBlock(ctx, cases["compressible"].Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := HasCompressed(ctx, d); err != nil || ok {
+		t.Fatalf("expected no compressed blocks, got %t, %v", ok, err)
+	}
+	if _, err := bs.GetSize(ctx, cases["compressible"].Cid()); !ipld.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestReadWhenDisabled(t *testing.T) {
+	ctx := context.Background()
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	inner := bstore.NewBlockstore(d)
+	on, err := New(inner, d, DefaultPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newBlock(t, cid.DagCBOR, bytes.Repeat([]byte{0x42}, 4096))
+	if err := on.Put(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+
+	off, err := New(inner, d, Policy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := off.Get(ctx, b.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.RawData(), b.RawData()) {
+		t.Fatal("data mismatch")
+	}
+	if s := off.Stats(); s.Enabled {
+		t.Fatal("expected compression to be disabled")
+	}
+}
+
+func TestStaleMarker(t *testing.T) {
+	ctx := context.Background()
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	inner := bstore.NewBlockstore(d)
+	bs, err := New(inner, d, DefaultPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a deletion interrupted before the marker was removed
+	b := newBlock(t, cid.Raw, []byte("stored as-is"))
+	if err := d.Put(ctx, markerKey(b.Cid()), []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.Put(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	got, err := bs.Get(ctx, b.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.RawData(), b.RawData()) {
+		t.Fatal("data mismatch")
+	}
+}
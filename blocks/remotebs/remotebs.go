@@ -0,0 +1,298 @@
+// Package remotebs provides a Blockstore wrapper that reads the blocks missing
+// from the wrapped blockstore from remote trustless gateways, so that
+// stateless frontend nodes can serve the gateway and bitswap from a shared
+// storage tier.
+//
+// The remote blockstores are kubo nodes, or any server, answering the raw
+// block requests of the trustless gateway protocol:
+//
+//	GET /ipfs/<cid>?format=raw
+//	Accept: application/vnd.ipld.raw
+//
+// The blocks read are verified against their CID, and stored in the wrapped
+// blockstore when caching is enabled. The writes go to the wrapped blockstore.
+package remotebs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	bstore "github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("remotebs")
+
+// MaxBlockSize is the size of the largest block read from the remotes, the
+// one of bitswap.
+const MaxBlockSize = 2 << 20
+
+// errNotFound is returned by the remotes not having a block.
+var errNotFound = errors.New("block not found")
+
+// Fetcher fetches raw blocks from trustless gateways.
+type Fetcher struct {
+	urls   []string
+	client *http.Client
+	// next is the index of the gateway tried first, the last one that
+	// answered.
+	next atomic.Int64
+}
+
+// NewFetcher returns a fetcher of the gateways of urls, tried in turn.
+func NewFetcher(urls []string, client *http.Client) (*Fetcher, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	f := &Fetcher{client: client}
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL %q: %w", u, err)
+		}
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			return nil, fmt.Errorf("invalid URL %q: the scheme must be http or https", u)
+		}
+		f.urls = append(f.urls, strings.TrimSuffix(u, "/"))
+	}
+	if len(f.urls) == 0 {
+		return nil, errors.New("no gateway URL")
+	}
+	return f, nil
+}
+
+// Fetch tries the gateways in turn, starting with the last one that answered.
+// The error wraps ipld.ErrNotFound when no gateway has the block.
+func (f *Fetcher) Fetch(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	var blk blocks.Block
+	err := f.try(ctx, c, func(u string) error {
+		var err error
+		blk, err = f.fetchFrom(ctx, u, c)
+		return err
+	})
+	return blk, err
+}
+
+// Has tells whether a gateway has the block, with HEAD requests.
+func (f *Fetcher) Has(ctx context.Context, c cid.Cid) (bool, error) {
+	err := f.try(ctx, c, func(u string) error {
+		return f.headFrom(ctx, u, c)
+	})
+	if ipld.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (f *Fetcher) try(ctx context.Context, c cid.Cid, do func(u string) error) error {
+	var errs []error
+	notFound := 0
+	start := int(f.next.Load())
+	for i := range f.urls {
+		idx := (start + i) % len(f.urls)
+		err := do(f.urls[idx])
+		if err == nil {
+			f.next.Store(int64(idx))
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, errNotFound) {
+			notFound++
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", f.urls[idx], err))
+	}
+	if notFound == len(f.urls) {
+		return ipld.ErrNotFound{Cid: c}
+	}
+	return fmt.Errorf("fetching %s from the gateways: %w", c, errors.Join(errs...))
+}
+
+func (f *Fetcher) request(ctx context.Context, method, u string, c cid.Cid) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u+"/ipfs/"+c.String()+"?format=raw", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.ipld.raw")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusNotFound, http.StatusGone:
+		resp.Body.Close()
+		return nil, errNotFound
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+}
+
+func (f *Fetcher) headFrom(ctx context.Context, u string, c cid.Cid) error {
+	resp, err := f.request(ctx, http.MethodHead, u, c)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (f *Fetcher) fetchFrom(ctx context.Context, u string, c cid.Cid) (blocks.Block, error) {
+	resp, err := f.request(ctx, http.MethodGet, u, c)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxBlockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxBlockSize {
+		return nil, fmt.Errorf("block larger than %d bytes", MaxBlockSize)
+	}
+	// the gateways are not trusted: the block must match its CID
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		return nil, fmt.Errorf("block doesn't match its CID, got %s", sum)
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
+
+// Options configures a Blockstore.
+type Options struct {
+	// Cache stores the blocks read from the remotes in the wrapped
+	// blockstore.
+	Cache bool
+	// Timeout bounds each read from the remotes, 0 for no bound.
+	Timeout time.Duration
+}
+
+// Stats counts the reads of the blockstore since it was created.
+type Stats struct {
+	Remotes []string
+	// Local is the number of blocks read from the wrapped blockstore,
+	// Remote the number read from the remotes, NotFound the number found
+	// nowhere, Errors the number of failed remote reads.
+	Local    uint64
+	Remote   uint64
+	NotFound uint64
+	Errors   uint64
+	// BytesRemote is the size of the blocks read from the remotes.
+	BytesRemote uint64
+}
+
+// Blockstore reads the blocks missing from the wrapped Blockstore from remote
+// trustless gateways.
+type Blockstore struct {
+	bstore.Blockstore
+
+	fetcher *Fetcher
+	opts    Options
+
+	local, remote, notFound, failed, bytesRemote atomic.Uint64
+}
+
+// New returns a blockstore reading the blocks missing from bs from the
+// gateways of urls.
+func New(bs bstore.Blockstore, urls []string, opts Options) (*Blockstore, error) {
+	f, err := NewFetcher(urls, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Blockstore{Blockstore: bs, fetcher: f, opts: opts}, nil
+}
+
+// Stats returns the number of blocks read locally and from the remotes.
+func (bs *Blockstore) Stats() Stats {
+	return Stats{
+		Remotes:     append([]string(nil), bs.fetcher.urls...),
+		Local:       bs.local.Load(),
+		Remote:      bs.remote.Load(),
+		NotFound:    bs.notFound.Load(),
+		Errors:      bs.failed.Load(),
+		BytesRemote: bs.bytesRemote.Load(),
+	}
+}
+
+func (bs *Blockstore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if bs.opts.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, bs.opts.Timeout)
+}
+
+func (bs *Blockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	blk, err := bs.Blockstore.Get(ctx, c)
+	if !ipld.IsNotFound(err) {
+		if err == nil {
+			bs.local.Add(1)
+		}
+		return blk, err
+	}
+	return bs.fetch(ctx, c)
+}
+
+func (bs *Blockstore) fetch(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	fctx, cancel := bs.withTimeout(ctx)
+	blk, err := bs.fetcher.Fetch(fctx, c)
+	cancel()
+	if err != nil {
+		if ipld.IsNotFound(err) {
+			bs.notFound.Add(1)
+			return nil, ipld.ErrNotFound{Cid: c}
+		}
+		bs.failed.Add(1)
+		return nil, err
+	}
+	bs.remote.Add(1)
+	bs.bytesRemote.Add(uint64(len(blk.RawData())))
+
+	if bs.opts.Cache {
+		if err := bs.Blockstore.Put(ctx, blk); err != nil {
+			log.Warnf("caching %s: %s", c, err)
+		}
+	}
+	return blk, nil
+}
+
+func (bs *Blockstore) Has(ctx context.Context, c cid.Cid) (bool, error) {
+	has, err := bs.Blockstore.Has(ctx, c)
+	if err != nil || has {
+		return has, err
+	}
+	fctx, cancel := bs.withTimeout(ctx)
+	defer cancel()
+	has, err = bs.fetcher.Has(fctx, c)
+	if err != nil {
+		bs.failed.Add(1)
+	}
+	return has, err
+}
+
+func (bs *Blockstore) GetSize(ctx context.Context, c cid.Cid) (int, error) {
+	size, err := bs.Blockstore.GetSize(ctx, c)
+	if !ipld.IsNotFound(err) {
+		return size, err
+	}
+	blk, err := bs.fetch(ctx, c)
+	if err != nil {
+		return -1, err
+	}
+	return len(blk.RawData()), nil
+}
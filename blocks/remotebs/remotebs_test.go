@@ -0,0 +1,99 @@
+package remotebs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	bstore "github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// newTestRemote serves the raw blocks of data by CID.
+func newTestRemote(t *testing.T, data map[string][]byte) *httptest.Server {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "raw" {
+			http.Error(w, "raw blocks only", http.StatusBadRequest)
+			return
+		}
+		b, ok := data[strings.TrimPrefix(r.URL.Path, "/ipfs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.ipld.raw")
+		_, _ = w.Write(b)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestBlockstore(t *testing.T) {
+	ctx := context.Background()
+	local := blocks.NewBlock([]byte("local"))
+	remote := blocks.NewBlock([]byte("remote"))
+	forged := blocks.NewBlock([]byte("forged"))
+	missing := blocks.NewBlock([]byte("missing"))
+
+	srv := newTestRemote(t, map[string][]byte{
+		remote.Cid().String(): remote.RawData(),
+		forged.Cid().String(): []byte("something else"),
+	})
+
+	inner := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	if err := inner.Put(ctx, local); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := New(inner, []string{srv.URL + "/"}, Options{Cache: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bs.Get(ctx, local.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	blk, err := bs.Get(ctx, remote.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(blk.RawData()) != "remote" {
+		t.Fatalf("unexpected block %q", blk.RawData())
+	}
+	// the remote block is cached
+	if has, _ := inner.Has(ctx, remote.Cid()); !has {
+		t.Fatal("expected the remote block to be cached")
+	}
+
+	if _, err := bs.Get(ctx, forged.Cid()); err == nil || ipld.IsNotFound(err) {
+		t.Fatalf("expected a block not matching its CID to be rejected, got %v", err)
+	}
+	if _, err := bs.Get(ctx, missing.Cid()); !ipld.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if has, err := bs.Has(ctx, missing.Cid()); err != nil || has {
+		t.Fatalf("expected a missing block, got %t, %v", has, err)
+	}
+	if has, err := bs.Has(ctx, forged.Cid()); err != nil || !has {
+		t.Fatalf("expected the remote to have the block, got %t, %v", has, err)
+	}
+
+	s := bs.Stats()
+	if s.Local != 1 || s.Remote != 1 || s.NotFound != 1 || s.Errors != 1 || s.BytesRemote != uint64(len("remote")) {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestInvalidEndpoints(t *testing.T) {
+	inner := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	for _, urls := range [][]string{nil, {"ftp://example.com"}} {
+		if _, err := New(inner, urls, Options{}); err == nil {
+			t.Fatalf("expected an error for %v", urls)
+		}
+	}
+}
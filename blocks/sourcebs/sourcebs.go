@@ -0,0 +1,300 @@
+// Package sourcebs provides a Blockstore wrapper around the filestore which
+// checks the availability of the mounts the sources of the filestore are read
+// from, such as network shares, so that the reads of the blocks of an
+// unavailable mount fail at once instead of hanging until the mount comes
+// back.
+package sourcebs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/filestore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("sourcebs")
+
+// ErrNoMounts is returned when checking the mounts of a node without
+// Filestore.Mounts.
+var ErrNoMounts = errors.New("no filestore source mounts configured, see Filestore.Mounts")
+
+// UnavailableError is returned when reading a source under an unavailable
+// mount.
+type UnavailableError struct {
+	Mount string
+	Err   error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("filestore source mount %s is unavailable: %s", e.Mount, e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// Mount is the availability of a mount.
+type Mount struct {
+	Path      string
+	Available bool
+	// Error is why the mount is unavailable.
+	Error string
+	// LastCheck is when the mount was last checked, and Since when its
+	// availability last changed.
+	LastCheck time.Time
+	Since     time.Time
+}
+
+type mount struct {
+	path string
+
+	// checking is set while a check runs, the checks of a hung mount don't
+	// pile up
+	checking  bool
+	err       error
+	lastCheck time.Time
+	since     time.Time
+}
+
+// Checker checks the mounts periodically.
+type Checker struct {
+	interval time.Duration
+	timeout  time.Duration
+	// probe checks that path can be read from
+	probe func(path string) error
+
+	lk     sync.Mutex
+	mounts []*mount // longest path first
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a checker of the mounts paths, checked every interval once
+// Start is called. A check taking longer than timeout reports the mount
+// unavailable. The mounts are reported available until checked.
+func New(paths []string, interval, timeout time.Duration) *Checker {
+	c := &Checker{
+		interval: interval,
+		timeout:  timeout,
+		probe:    probe,
+	}
+	now := time.Now()
+	for _, p := range paths {
+		c.mounts = append(c.mounts, &mount{path: filepath.Clean(p), since: now})
+	}
+	sort.Slice(c.mounts, func(i, j int) bool {
+		return len(c.mounts[i].path) > len(c.mounts[j].path)
+	})
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	return c
+}
+
+// probe reads an entry of the directory path, which stats the directory
+// itself and goes to the server of network shares.
+func probe(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// Start checks the mounts, then every interval until Close.
+func (c *Checker) Start() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			c.Check()
+			select {
+			case <-ticker.C:
+			case <-c.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the checks. The checks of hung mounts are left behind.
+func (c *Checker) Close() error {
+	c.cancel()
+	c.wg.Wait()
+	return nil
+}
+
+// Check checks all the mounts, and returns once they are checked or the
+// checks timed out.
+func (c *Checker) Check() {
+	var wg sync.WaitGroup
+	for _, m := range c.mounts {
+		wg.Add(1)
+		go func(m *mount) {
+			defer wg.Done()
+			c.check(m)
+		}(m)
+	}
+	wg.Wait()
+}
+
+func (c *Checker) check(m *mount) {
+	c.lk.Lock()
+	if m.checking {
+		// the previous check is still hung
+		c.record(m, fmt.Errorf("check still running after %s", time.Since(m.lastCheck).Round(time.Second)), false)
+		c.lk.Unlock()
+		return
+	}
+	m.checking = true
+	c.lk.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		err := c.probe(m.path)
+		c.lk.Lock()
+		defer c.lk.Unlock()
+		m.checking = false
+		// a check that completes after timing out still reports the mount
+		// back
+		c.record(m, err, true)
+		done <- err
+	}()
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		c.lk.Lock()
+		if m.checking {
+			c.record(m, fmt.Errorf("check timed out after %s", c.timeout), false)
+		}
+		c.lk.Unlock()
+	}
+}
+
+// record records the outcome of a check of m, with c.lk held.
+func (c *Checker) record(m *mount, err error, completed bool) {
+	now := time.Now()
+	if (err == nil) != (m.err == nil) {
+		m.since = now
+		if err != nil {
+			log.Errorf("filestore source mount %s is unavailable: %s", m.path, err)
+		} else {
+			log.Infof("filestore source mount %s is available again", m.path)
+		}
+	}
+	m.err = err
+	if completed || m.lastCheck.IsZero() {
+		m.lastCheck = now
+	}
+}
+
+// Available returns an *UnavailableError when path, the absolute path of a
+// source, is under an unavailable mount. The paths outside of the mounts are
+// always available.
+func (c *Checker) Available(path string) error {
+	path = filepath.Clean(path)
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	for _, m := range c.mounts {
+		if !under(path, m.path) {
+			continue
+		}
+		if m.err != nil {
+			return &UnavailableError{Mount: m.path, Err: m.err}
+		}
+		return nil
+	}
+	return nil
+}
+
+// under returns whether path is dir or is in dir.
+func under(path, dir string) bool {
+	if path == dir || dir == string(filepath.Separator) {
+		return true
+	}
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// Mounts returns the availability of the mounts, sorted by path.
+func (c *Checker) Mounts() []Mount {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	out := make([]Mount, 0, len(c.mounts))
+	for _, m := range c.mounts {
+		st := Mount{
+			Path:      m.path,
+			Available: m.err == nil,
+			LastCheck: m.lastCheck,
+			Since:     m.since,
+		}
+		if m.err != nil {
+			st.Error = m.err.Error()
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// Blockstore fails the reads of the blocks of the filestore whose source is
+// under an unavailable mount at once, instead of leaving them hanging on the
+// mount. The blocks stored in the blockstore are read as usual.
+type Blockstore struct {
+	*filestore.Filestore
+	base    blockstore.Blockstore
+	root    string
+	checker *Checker
+}
+
+// NewBlockstore wraps fs, which stores its blocks in base and reads its
+// sources relative to root.
+func NewBlockstore(fs *filestore.Filestore, base blockstore.Blockstore, root string, c *Checker) *Blockstore {
+	return &Blockstore{Filestore: fs, base: base, root: root, checker: c}
+}
+
+func (bs *Blockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if err := bs.available(ctx, c); err != nil {
+		return nil, err
+	}
+	return bs.Filestore.Get(ctx, c)
+}
+
+// available returns an error when the block c is read from a source under an
+// unavailable mount. The filestore reads the blockstore first.
+func (bs *Blockstore) available(ctx context.Context, c cid.Cid) error {
+	r := filestore.List(ctx, bs.Filestore, c)
+	if r.Status != filestore.StatusOk || strings.Contains(r.FilePath, "://") {
+		// not in the filestore, or in the urlstore
+		return nil
+	}
+	if err := bs.checker.Available(filepath.Join(bs.root, r.FilePath)); err != nil {
+		if has, herr := bs.base.Has(ctx, c); herr == nil && has {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
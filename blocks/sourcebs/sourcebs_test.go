@@ -0,0 +1,121 @@
+package sourcebs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bstore "github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/filestore"
+	"github.com/ipfs/boxo/filestore/posinfo"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+// newTestChecker returns a checker of paths, whose checks of the mounts in
+// hung block until their channel is closed.
+func newTestChecker(paths []string, hung map[string]chan struct{}) *Checker {
+	c := New(paths, time.Hour, 50*time.Millisecond)
+	c.probe = func(path string) error {
+		if ch, ok := hung[path]; ok {
+			<-ch
+		}
+		return nil
+	}
+	return c
+}
+
+func TestChecker(t *testing.T) {
+	release := make(chan struct{})
+	c := newTestChecker([]string{"/nas", "/nas/archive/"}, map[string]chan struct{}{"/nas/archive": release})
+
+	for _, p := range []string{"/nas/archive/file", "/nas/file"} {
+		if err := c.Available(p); err != nil {
+			t.Fatalf("expected %s to be available before the first check, got %s", p, err)
+		}
+	}
+
+	start := time.Now()
+	c.Check()
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("expected the check of a hung mount to time out, took %s", d)
+	}
+	var unavailable *UnavailableError
+	if err := c.Available("/nas/archive/2024/file"); !errors.As(err, &unavailable) || unavailable.Mount != "/nas/archive" {
+		t.Fatalf("expected the hung mount to be unavailable, got %v", err)
+	}
+	for _, p := range []string{"/nas/file", "/nas/archived/file", "/home/file"} {
+		if err := c.Available(p); err != nil {
+			t.Fatalf("expected %s to be available, got %s", p, err)
+		}
+	}
+
+	// the checks don't pile up on the hung mount
+	c.Check()
+	mounts := c.Mounts()
+	if len(mounts) != 2 || mounts[0].Path != "/nas" || !mounts[0].Available || mounts[1].Available || mounts[1].Error == "" {
+		t.Fatalf("unexpected mounts %+v", mounts)
+	}
+
+	// the mount is reported back once the hung check completes
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Available("/nas/archive/file") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the mount to be available again")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBlockstore(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	mnt := filepath.Join(root, "nas")
+	if err := os.Mkdir(mnt, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("the data of a source")
+	if err := os.WriteFile(filepath.Join(mnt, "file"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mds := dssync.MutexWrap(ds.NewMapDatastore())
+	base := bstore.NewBlockstore(mds)
+	fm := filestore.NewFileManager(mds, root)
+	fm.AllowFiles = true
+	fs := filestore.NewFilestore(base, fm)
+	nd := dag.NewRawNode(data)
+	if err := fs.Put(ctx, &posinfo.FilestoreNode{
+		Node:    nd,
+		PosInfo: &posinfo.PosInfo{FullPath: filepath.Join(mnt, "file"), Offset: 0},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	stored := dag.NewRawNode([]byte("a block of the blockstore"))
+	if err := fs.Put(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	c := newTestChecker([]string{mnt}, map[string]chan struct{}{mnt: release})
+	bs := NewBlockstore(fs, base, root, c)
+
+	if _, err := bs.Get(ctx, nd.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Check()
+	var unavailable *UnavailableError
+	if _, err := bs.Get(ctx, nd.Cid()); !errors.As(err, &unavailable) {
+		t.Fatalf("expected the source to be unavailable, got %v", err)
+	}
+	if _, err := bs.Get(ctx, stored.Cid()); err != nil {
+		t.Fatalf("expected the blocks of the blockstore to be read, got %s", err)
+	}
+}
@@ -0,0 +1,468 @@
+// Package writebackbs provides a Blockstore wrapper acknowledging the writes
+// once they are journaled to a local directory, and flushing them to the
+// wrapped blockstore in the background, to speed up the writes to slow or
+// networked datastores.
+//
+// Each block written is saved to its own file of the journal, synced to disk
+// before the write returns, and removed once the block is flushed. The blocks
+// left in the journal by a crash are flushed again when the blockstore is
+// opened.
+package writebackbs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	bstore "github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("writebackbs")
+
+// tmpSuffix is the suffix of the journal files being written.
+const tmpSuffix = ".tmp"
+
+// retryDelay is the time before a failed flush is retried.
+const retryDelay = time.Second
+
+// Options configures a Blockstore.
+type Options struct {
+	// Workers is the number of blocks flushed at the same time.
+	Workers int
+	// MaxPendingBytes bounds the size of the blocks waiting to be flushed,
+	// the writes wait when it is reached.
+	MaxPendingBytes int64
+}
+
+// Status reports the blocks waiting to be flushed, and the flushes since the
+// blockstore was opened.
+type Status struct {
+	Pending      int
+	PendingBytes int64
+	// Replayed is the number of blocks found in the journal when the
+	// blockstore was opened.
+	Replayed int
+	Flushed  uint64
+	// Failed is the number of flushes that failed, and were retried.
+	Failed    uint64
+	LastError string `json:",omitempty"`
+}
+
+type entry struct {
+	c    cid.Cid
+	size int
+}
+
+// Blockstore journals the blocks written, and flushes them to the wrapped
+// Blockstore in the background.
+type Blockstore struct {
+	bstore.Blockstore
+
+	dir  string
+	opts Options
+
+	lk sync.Mutex
+	// pending are the blocks not flushed yet, by multihash
+	pending      map[string]*entry
+	pendingBytes int64
+	queue        []*entry
+	// changed is closed and replaced when blocks are flushed
+	changed chan struct{}
+	status  Status
+
+	wake   chan struct{}
+	closed chan struct{}
+	wg     sync.WaitGroup
+}
+
+var _ bstore.Blockstore = (*Blockstore)(nil)
+
+// New wraps bs with a journal in dir, and starts flushing the blocks left in
+// the journal.
+func New(bs bstore.Blockstore, dir string, opts Options) (*Blockstore, error) {
+	if opts.Workers <= 0 || opts.MaxPendingBytes <= 0 {
+		return nil, errors.New("the number of workers and the maximum of pending bytes must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	b := &Blockstore{
+		Blockstore: bs,
+		dir:        dir,
+		opts:       opts,
+		pending:    make(map[string]*entry),
+		changed:    make(chan struct{}),
+		wake:       make(chan struct{}, opts.Workers),
+		closed:     make(chan struct{}),
+	}
+	if err := b.replay(); err != nil {
+		return nil, fmt.Errorf("replaying the write-back journal: %w", err)
+	}
+	for i := 0; i < opts.Workers; i++ {
+		b.wg.Add(1)
+		go b.worker()
+	}
+	return b, nil
+}
+
+// replay queues the blocks of the journal.
+func (b *Blockstore) replay() error {
+	files, err := os.ReadDir(b.dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name := f.Name()
+		if strings.HasSuffix(name, tmpSuffix) {
+			// never acknowledged
+			_ = os.Remove(filepath.Join(b.dir, name))
+			continue
+		}
+		c, err := cid.Decode(name)
+		if err != nil {
+			log.Warnf("ignoring %s in the write-back journal: %s", name, err)
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(b.dir, name))
+		if err != nil {
+			return err
+		}
+		if sum, err := c.Prefix().Sum(data); err != nil || !sum.Equals(c) {
+			log.Errorf("removing corrupted block %s from the write-back journal", c)
+			_ = os.Remove(filepath.Join(b.dir, name))
+			continue
+		}
+		b.add(&entry{c: c, size: len(data)})
+	}
+	b.status.Replayed = len(b.pending)
+	if b.status.Replayed > 0 {
+		log.Infof("flushing %d blocks left in the write-back journal", b.status.Replayed)
+	}
+	return nil
+}
+
+func (b *Blockstore) path(c cid.Cid) string {
+	return filepath.Join(b.dir, c.String())
+}
+
+// add queues e, with b.lk held.
+func (b *Blockstore) add(e *entry) {
+	b.pending[string(e.c.Hash())] = e
+	b.pendingBytes += int64(e.size)
+	b.queue = append(b.queue, e)
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// remove removes e from the pending blocks, with b.lk held. It returns false
+// when e was already replaced or deleted.
+func (b *Blockstore) remove(e *entry) bool {
+	key := string(e.c.Hash())
+	if b.pending[key] != e {
+		return false
+	}
+	delete(b.pending, key)
+	b.pendingBytes -= int64(e.size)
+	close(b.changed)
+	b.changed = make(chan struct{})
+	return true
+}
+
+func (b *Blockstore) lookup(c cid.Cid) *entry {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	return b.pending[string(c.Hash())]
+}
+
+func (b *Blockstore) Put(ctx context.Context, blk blocks.Block) error {
+	c := blk.Cid()
+	for {
+		b.lk.Lock()
+		if _, ok := b.pending[string(c.Hash())]; ok {
+			b.lk.Unlock()
+			return nil
+		}
+		if b.pendingBytes < b.opts.MaxPendingBytes {
+			b.lk.Unlock()
+			break
+		}
+		changed := b.changed
+		b.lk.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.closed:
+			return errors.New("write-back blockstore closed")
+		}
+	}
+
+	if err := b.journal(c, blk.RawData()); err != nil {
+		return err
+	}
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	if _, ok := b.pending[string(c.Hash())]; !ok {
+		b.add(&entry{c: c, size: len(blk.RawData())})
+	}
+	return nil
+}
+
+func (b *Blockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	for _, blk := range blks {
+		if err := b.Put(ctx, blk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// journal saves data to the file of c, synced to disk.
+func (b *Blockstore) journal(c cid.Cid, data []byte) error {
+	path := b.path(c)
+	f, err := os.CreateTemp(b.dir, c.String()+"-*"+tmpSuffix)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return syncDir(b.dir)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (b *Blockstore) worker() {
+	defer b.wg.Done()
+	for {
+		b.lk.Lock()
+		var e *entry
+		if len(b.queue) > 0 {
+			e = b.queue[0]
+			b.queue = b.queue[1:]
+		}
+		b.lk.Unlock()
+
+		if e == nil {
+			select {
+			case <-b.wake:
+				continue
+			case <-b.closed:
+				return
+			}
+		}
+		if !b.flush(e) {
+			select {
+			case <-time.After(retryDelay):
+			case <-b.closed:
+				return
+			}
+		}
+	}
+}
+
+// flush writes the block of e to the wrapped blockstore, and removes it from
+// the journal. It returns false when the flush failed and is retried.
+func (b *Blockstore) flush(e *entry) bool {
+	if b.lookup(e.c) != e {
+		// deleted meanwhile
+		return true
+	}
+	err := func() error {
+		data, err := os.ReadFile(b.path(e.c))
+		if err != nil {
+			return err
+		}
+		blk, err := blocks.NewBlockWithCid(data, e.c)
+		if err != nil {
+			return err
+		}
+		return b.Blockstore.Put(context.Background(), blk)
+	}()
+
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	if err != nil && b.pending[string(e.c.Hash())] != e {
+		// deleted while it was flushed
+		return true
+	}
+	if err != nil {
+		b.status.Failed++
+		b.status.LastError = err.Error()
+		log.Warnf("flushing %s, retrying: %s", e.c, err)
+		b.queue = append(b.queue, e)
+		return false
+	}
+	b.status.Flushed++
+	if b.remove(e) {
+		_ = os.Remove(b.path(e.c))
+	} else if b.pending[string(e.c.Hash())] == nil {
+		// deleted while it was flushed
+		_ = b.Blockstore.DeleteBlock(context.Background(), e.c)
+	}
+	return true
+}
+
+func (b *Blockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if e := b.lookup(c); e != nil {
+		data, err := os.ReadFile(b.path(e.c))
+		if err == nil {
+			return blocks.NewBlockWithCid(data, c)
+		}
+		// flushed meanwhile
+	}
+	return b.Blockstore.Get(ctx, c)
+}
+
+func (b *Blockstore) Has(ctx context.Context, c cid.Cid) (bool, error) {
+	if b.lookup(c) != nil {
+		return true, nil
+	}
+	return b.Blockstore.Has(ctx, c)
+}
+
+func (b *Blockstore) GetSize(ctx context.Context, c cid.Cid) (int, error) {
+	if e := b.lookup(c); e != nil {
+		return e.size, nil
+	}
+	return b.Blockstore.GetSize(ctx, c)
+}
+
+func (b *Blockstore) DeleteBlock(ctx context.Context, c cid.Cid) error {
+	b.lk.Lock()
+	e := b.pending[string(c.Hash())]
+	if e != nil {
+		b.remove(e)
+		_ = os.Remove(b.path(e.c))
+	}
+	b.lk.Unlock()
+
+	err := b.Blockstore.DeleteBlock(ctx, c)
+	if e != nil && ipld.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// AllKeysChan returns the keys of the pending blocks, then the ones of the
+// wrapped blockstore.
+func (b *Blockstore) AllKeysChan(ctx context.Context) (<-chan cid.Cid, error) {
+	b.lk.Lock()
+	pending := make([]cid.Cid, 0, len(b.pending))
+	seen := make(map[string]struct{}, len(b.pending))
+	for key, e := range b.pending {
+		pending = append(pending, e.c)
+		seen[key] = struct{}{}
+	}
+	b.lk.Unlock()
+
+	keys, err := b.Blockstore.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan cid.Cid)
+	go func() {
+		defer close(out)
+		for _, c := range pending {
+			select {
+			case out <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+		for c := range keys {
+			if _, ok := seen[string(c.Hash())]; ok {
+				continue
+			}
+			select {
+			case out <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Status returns the blocks waiting to be flushed.
+func (b *Blockstore) Status() Status {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	s := b.status
+	s.Pending = len(b.pending)
+	s.PendingBytes = b.pendingBytes
+	return s
+}
+
+// Flush waits for the blocks written so far to be flushed, or for ctx to be
+// done.
+func (b *Blockstore) Flush(ctx context.Context) error {
+	b.lk.Lock()
+	waiting := make([]*entry, 0, len(b.pending))
+	for _, e := range b.pending {
+		waiting = append(waiting, e)
+	}
+	b.lk.Unlock()
+
+	for _, e := range waiting {
+		for {
+			b.lk.Lock()
+			done := b.pending[string(e.c.Hash())] != e
+			changed := b.changed
+			b.lk.Unlock()
+			if done {
+				break
+			}
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-b.closed:
+				return errors.New("write-back blockstore closed")
+			}
+		}
+	}
+	return nil
+}
+
+// Close stops the flushes, the blocks not flushed stay in the journal.
+func (b *Blockstore) Close() error {
+	select {
+	case <-b.closed:
+		return nil
+	default:
+	}
+	close(b.closed)
+	b.wg.Wait()
+	return nil
+}
@@ -0,0 +1,117 @@
+package writebackbs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	bstore "github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// gatedBlockstore fails the writes until it is opened.
+type gatedBlockstore struct {
+	bstore.Blockstore
+
+	lk   sync.Mutex
+	open bool
+}
+
+func (g *gatedBlockstore) setOpen(open bool) {
+	g.lk.Lock()
+	defer g.lk.Unlock()
+	g.open = open
+}
+
+func (g *gatedBlockstore) Put(ctx context.Context, b blocks.Block) error {
+	g.lk.Lock()
+	defer g.lk.Unlock()
+	if !g.open {
+		return errors.New("closed")
+	}
+	return g.Blockstore.Put(ctx, b)
+}
+
+func newGated() *gatedBlockstore {
+	return &gatedBlockstore{Blockstore: bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))}
+}
+
+func TestWriteBack(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	dir := t.TempDir()
+	inner := newGated()
+	opts := Options{Workers: 2, MaxPendingBytes: 1 << 20}
+
+	bs, err := New(inner, dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk := blocks.NewBlock([]byte("journaled"))
+	if err := bs.Put(ctx, blk); err != nil {
+		t.Fatal(err)
+	}
+	// the block is read from the journal until it is flushed
+	got, err := bs.Get(ctx, blk.Cid())
+	if err != nil || string(got.RawData()) != "journaled" {
+		t.Fatalf("unexpected block %v, %v", got, err)
+	}
+	if has, _ := inner.Has(ctx, blk.Cid()); has {
+		t.Fatal("the block was flushed to a closed blockstore")
+	}
+	if s := bs.Status(); s.Pending != 1 || s.PendingBytes != int64(len("journaled")) {
+		t.Fatalf("unexpected status %+v", s)
+	}
+
+	// the journal is replayed after a crash
+	if err := bs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	inner.setOpen(true)
+	bs, err = New(inner, dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bs.Close()
+	if err := bs.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := inner.Has(ctx, blk.Cid()); !has {
+		t.Fatal("expected the block to be flushed")
+	}
+	if s := bs.Status(); s.Pending != 0 || s.Replayed != 1 || s.Flushed != 1 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+
+	if err := bs.DeleteBlock(ctx, blk.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.Get(ctx, blk.Cid()); !ipld.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestMaxPendingBytes(t *testing.T) {
+	ctx := context.Background()
+	inner := newGated()
+	bs, err := New(inner, t.TempDir(), Options{Workers: 1, MaxPendingBytes: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bs.Close()
+
+	if err := bs.Put(ctx, blocks.NewBlock([]byte("first"))); err != nil {
+		t.Fatal(err)
+	}
+	// the next write waits for the first block to be flushed
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := bs.Put(tctx, blocks.NewBlock([]byte("second"))); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the write to wait, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ipfs/boxo/path"
+	iface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+)
+
+type AliasAPI HttpApi
+
+type aliasOutput struct {
+	Name string
+	Path string
+}
+
+func (api *AliasAPI) Set(ctx context.Context, name string, p path.Path, opts ...caopts.AliasSetOption) error {
+	options, err := caopts.AliasSetOptions(opts...)
+	if err != nil {
+		return err
+	}
+
+	return api.core().Request("alias/set", name, p.String()).
+		Option("force", options.Force).
+		Exec(ctx, nil)
+}
+
+func (api *AliasAPI) Get(ctx context.Context, name string) (path.Path, error) {
+	var out aliasOutput
+	if err := api.core().Request("alias/get", name).Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+	return path.NewPath(out.Path)
+}
+
+func (api *AliasAPI) List(ctx context.Context) ([]iface.Alias, error) {
+	var out struct {
+		Aliases []aliasOutput
+	}
+	if err := api.core().Request("alias/ls").Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+
+	res := make([]iface.Alias, len(out.Aliases))
+	for i, a := range out.Aliases {
+		p, err := path.NewPath(a.Path)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = iface.Alias{Name: a.Name, Path: p}
+	}
+	return res, nil
+}
+
+func (api *AliasAPI) Remove(ctx context.Context, name string) error {
+	return api.core().Request("alias/rm", name).Exec(ctx, nil)
+}
+
+func (api *AliasAPI) Expand(ctx context.Context, p string) (path.Path, error) {
+	segments := path.StringToSegments(p)
+	if !strings.HasPrefix(p, "/") || len(segments) < 2 || segments[0] != "alias" {
+		return path.NewPath(p)
+	}
+	target, err := api.Get(ctx, segments[1])
+	if err != nil {
+		return nil, err
+	}
+	return path.Join(target, segments[2:]...)
+}
+
+func (api *AliasAPI) core() *HttpApi {
+	return (*HttpApi)(api)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -109,12 +110,24 @@ func NewApi(a ma.Multiaddr) (*HttpApi, error) {
 }
 
 // NewApiWithClient constructs HttpApi with specified endpoint and custom http client.
+//
+// Unix socket endpoints, such as /unix/run/ipfs/api.sock, require c to use an
+// *http.Transport, or the default one: a copy of c dialing the socket is used.
 func NewApiWithClient(a ma.Multiaddr, c *http.Client) (*HttpApi, error) {
-	_, url, err := manet.DialArgs(a)
+	network, url, err := manet.DialArgs(a)
 	if err != nil {
 		return nil, err
 	}
 
+	if network == "unix" {
+		c, err := unixSocketClient(c, url)
+		if err != nil {
+			return nil, err
+		}
+		// the host is ignored by the transport
+		return NewURLApiWithClient("http://unix", c)
+	}
+
 	if a, err := ma.NewMultiaddr(url); err == nil {
 		_, host, err := manet.DialArgs(a)
 		if err == nil {
@@ -139,6 +152,31 @@ func NewApiWithClient(a ma.Multiaddr, c *http.Client) (*HttpApi, error) {
 	return NewURLApiWithClient(proto+url, c)
 }
 
+// unixSocketClient returns a copy of c sending all its requests over the unix
+// socket at path.
+func unixSocketClient(c *http.Client, path string) (*http.Client, error) {
+	var t *http.Transport
+	switch rt := c.Transport.(type) {
+	case nil:
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		t = rt.Clone()
+	default:
+		return nil, fmt.Errorf("unix socket %s: the client must use an *http.Transport, got %T", path, rt)
+	}
+	// proxies can't reach the socket
+	t.Proxy = nil
+	var d net.Dialer
+	t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return d.DialContext(ctx, "unix", path)
+	}
+	t.DialTLSContext = nil
+
+	uc := *c
+	uc.Transport = t
+	return &uc, nil
+}
+
 func NewURLApiWithClient(url string, c *http.Client) (*HttpApi, error) {
 	decoder := legacy.NewDecoder()
 	// Add support for these codecs to match what is done in the merkledag library
@@ -177,6 +215,9 @@ func (api *HttpApi) WithOptions(opts ...caopts.ApiOption) (iface.CoreAPI, error)
 			if options.Offline {
 				req.Option("offline", options.Offline)
 			}
+			if options.Context != "" {
+				req.Option("context", options.Context)
+			}
 		},
 		ipldDecoder: api.ipldDecoder,
 	}
@@ -241,10 +282,50 @@ func (api *HttpApi) PubSub() iface.PubSubAPI {
 	return (*PubsubAPI)(api)
 }
 
+func (api *HttpApi) P2P() iface.P2PAPI {
+	return (*P2PAPI)(api)
+}
+
 func (api *HttpApi) Routing() iface.RoutingAPI {
 	return (*RoutingAPI)(api)
 }
 
+func (api *HttpApi) Search() iface.SearchAPI {
+	return (*SearchAPI)(api)
+}
+
+func (api *HttpApi) Car() iface.CarAPI {
+	return (*CarAPI)(api)
+}
+
+func (api *HttpApi) Alias() iface.AliasAPI {
+	return (*AliasAPI)(api)
+}
+
+func (api *HttpApi) Cache() iface.CacheAPI {
+	return (*CacheAPI)(api)
+}
+
+func (api *HttpApi) Repo() iface.RepoAPI {
+	return (*RepoAPI)(api)
+}
+
+func (api *HttpApi) KV() iface.KVAPI {
+	return (*KVAPI)(api)
+}
+
+func (api *HttpApi) Export() iface.ExportAPI {
+	return (*ExportAPI)(api)
+}
+
+func (api *HttpApi) Replicate() iface.ReplicateAPI {
+	return (*ReplicateAPI)(api)
+}
+
+func (api *HttpApi) Standby() iface.StandbyAPI {
+	return (*StandbyAPI)(api)
+}
+
 func (api *HttpApi) loadRemoteVersion() (*semver.Version, error) {
 	api.versionMu.Lock()
 	defer api.versionMu.Unlock()
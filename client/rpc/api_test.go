@@ -2,8 +2,10 @@ package rpc
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -45,6 +47,12 @@ func (np NodeProvider) MakeAPISwarm(t *testing.T, ctx context.Context, fullIdent
 
 				c := n.ReadConfig()
 				c.Experimental.FilestoreEnabled = true
+				c.Experimental.UrlstoreEnabled = true
+				c.Experimental.ContentIndex = true
+				c.Experimental.FullTextSearch = true
+				c.Experimental.Libp2pStreamMounting = true
+				c.Experimental.KVStore = true
+				c.Experimental.FastCDCChunker = true
 				n.WriteConfig(c)
 
 				n.StartDaemon("--enable-pubsub-experiment", "--offline="+strconv.FormatBool(!online))
@@ -168,3 +176,44 @@ func Test_NewURLApiWithClient_HTTP_Variant(t *testing.T) {
 		}
 	}
 }
+
+func Test_NewApiWithClient_Unix_Socket(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not available")
+	}
+
+	sock := filepath.Join(t.TempDir(), "api.sock")
+	lis, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v0/version" {
+			w.WriteHeader(404)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"Version":"0.27.0"}`))
+	}))
+	ts.Listener = lis
+	ts.Start()
+	defer ts.Close()
+
+	api, err := NewApiWithClient(ma.StringCast("/unix"+sock), &http.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api.url != "http://unix" {
+		t.Errorf("Expected = http://unix; got %s", api.url)
+	}
+
+	var out struct{ Version string }
+	if err := api.Request("version").Exec(context.Background(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Version != "0.27.0" {
+		t.Errorf("Expected = 0.27.0; got %s", out.Version)
+	}
+}
@@ -1,8 +1,10 @@
 package rpc
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 
@@ -10,44 +12,82 @@ import (
 	unixfs "github.com/ipfs/boxo/ipld/unixfs"
 	"github.com/ipfs/boxo/path"
 	"github.com/ipfs/go-cid"
+	iface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/ipfs/kubo/core/coreiface/options"
 )
 
 const forwardSeekLimit = 1 << 14 // 16k
 
-func (api *UnixfsAPI) Get(ctx context.Context, p path.Path) (files.Node, error) {
+func (api *UnixfsAPI) Get(ctx context.Context, p path.Path, opts ...options.UnixfsGetOption) (files.Node, error) {
+	settings, err := options.UnixfsGetOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
 	if p.Mutable() { // use resolved path in case we are dealing with IPNS / MFS
-		var err error
 		p, _, err = api.core().ResolvePath(ctx, p)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	if settings.Archive {
+		return api.getArchive(ctx, p, settings.Compression)
+	}
+
 	var stat struct {
 		Hash string
 		Type string
 		Size int64 // unixfs size
 	}
-	err := api.core().Request("files/stat", p.String()).Exec(ctx, &stat)
+	err = api.core().Request("files/stat", p.String()).Exec(ctx, &stat)
 	if err != nil {
 		return nil, err
 	}
 
+	ranged := settings.Offset != 0 || settings.Length >= 0
 	switch stat.Type {
 	case "file":
-		return api.getFile(ctx, p, stat.Size)
+		if !ranged {
+			return api.getFile(ctx, p, stat.Size, settings.Progressive)
+		}
+		// only the range is requested to the daemon
+		f := &apiFile{
+			ctx:         ctx,
+			core:        api.core(),
+			path:        p,
+			progressive: settings.Progressive,
+			start:       settings.Offset,
+			ranged:      true,
+		}
+		if f.start > stat.Size {
+			f.start = stat.Size
+		}
+		f.size = stat.Size - f.start
+		if settings.Length >= 0 && settings.Length < f.size {
+			f.size = settings.Length
+		}
+		return f, f.reset()
 	case "directory":
+		if ranged {
+			return nil, iface.ErrIsDir
+		}
 		return api.getDir(ctx, p, stat.Size)
 	default:
 		return nil, fmt.Errorf("unsupported file type '%s'", stat.Type)
 	}
 }
 
+// apiFile is a file read with cat. When ranged, it is the range of size
+// bytes of the file from start.
 type apiFile struct {
-	ctx  context.Context
-	core *HttpApi
-	size int64
-	path path.Path
+	ctx         context.Context
+	core        *HttpApi
+	size        int64
+	path        path.Path
+	progressive bool
+	start       int64
+	ranged      bool
 
 	r  *Response
 	at int64
@@ -59,8 +99,18 @@ func (f *apiFile) reset() error {
 		f.r = nil
 	}
 	req := f.core.Request("cat", f.path.String())
-	if f.at != 0 {
-		req.Option("offset", f.at)
+	if f.start+f.at != 0 {
+		req.Option("offset", f.start+f.at)
+	}
+	if f.ranged {
+		rest := f.size - f.at
+		if rest < 0 {
+			rest = 0
+		}
+		req.Option("length", rest)
+	}
+	if f.progressive {
+		req.Option("progressive", true)
 	}
 	resp, err := req.Send(f.ctx)
 	if err != nil {
@@ -83,8 +133,15 @@ func (f *apiFile) Read(p []byte) (int, error) {
 
 func (f *apiFile) ReadAt(p []byte, off int64) (int, error) {
 	// Always make a new request. This method should be parallel-safe.
+	length := int64(len(p))
+	if f.ranged && off+length > f.size {
+		length = f.size - off
+	}
+	if length <= 0 {
+		return 0, io.EOF
+	}
 	resp, err := f.core.Request("cat", f.path.String()).
-		Option("offset", off).Option("length", len(p)).Send(f.ctx)
+		Option("offset", f.start+off).Option("length", length).Send(f.ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -132,17 +189,59 @@ func (f *apiFile) Size() (int64, error) {
 	return f.size, nil
 }
 
-func (api *UnixfsAPI) getFile(ctx context.Context, p path.Path, size int64) (files.Node, error) {
+func (api *UnixfsAPI) getFile(ctx context.Context, p path.Path, size int64, progressive bool) (files.Node, error) {
 	f := &apiFile{
-		ctx:  ctx,
-		core: api.core(),
-		size: size,
-		path: p,
+		ctx:         ctx,
+		core:        api.core(),
+		size:        size,
+		path:        p,
+		progressive: progressive,
 	}
 
 	return f, f.reset()
 }
 
+// apiArchive is a tree streamed as a tar archive by the get command.
+type apiArchive struct {
+	r *Response
+}
+
+func (api *UnixfsAPI) getArchive(ctx context.Context, p path.Path, compression int) (files.Node, error) {
+	req := api.core().Request("get", p.String()).
+		Option("archive", true).
+		Option("progress", false)
+	if compression != gzip.NoCompression {
+		req.Option("compress", true)
+		if compression != gzip.DefaultCompression {
+			req.Option("compression-level", compression)
+		}
+	}
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	return &apiArchive{r: resp}, nil
+}
+
+func (a *apiArchive) Read(p []byte) (int, error) {
+	return a.r.Output.Read(p)
+}
+
+func (a *apiArchive) Close() error {
+	return a.r.Cancel()
+}
+
+func (a *apiArchive) Seek(offset int64, whence int) (int64, error) {
+	return 0, errors.New("cannot seek an archive")
+}
+
+func (a *apiArchive) Size() (int64, error) {
+	return 0, errors.New("the size of an archive is unknown")
+}
+
 type apiIter struct {
 	ctx  context.Context
 	core *UnixfsAPI
@@ -201,7 +300,7 @@ func (it *apiIter) Next() bool {
 			return false
 		}
 	case unixfs.TFile:
-		it.curFile, err = it.core.getFile(it.ctx, path.FromCid(c), int64(it.cur.Size))
+		it.curFile, err = it.core.getFile(it.ctx, path.FromCid(c), int64(it.cur.Size), false)
 		if err != nil {
 			it.err = err
 			return false
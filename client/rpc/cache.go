@@ -0,0 +1,57 @@
+package rpc
+
+import (
+	"context"
+	"io"
+
+	"github.com/ipfs/boxo/path"
+	iface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+)
+
+type CacheAPI HttpApi
+
+func (api *CacheAPI) WarmFromLog(ctx context.Context, r io.Reader, opts ...caopts.CacheWarmOption) (iface.CacheWarmResult, error) {
+	options, err := caopts.CacheWarmOptions(opts...)
+	if err != nil {
+		return iface.CacheWarmResult{}, err
+	}
+
+	req := api.core().Request("cache/warm").
+		Option("concurrency", options.Concurrency).
+		Option("limit", options.Limit)
+	if options.PinTTL > 0 {
+		req = req.Option("pin-ttl", options.PinTTL.String())
+	}
+
+	var out struct {
+		Paths     int
+		Warmed    int
+		Protected int
+		Failures  []struct {
+			Path  string
+			Error string
+		}
+	}
+	if err := req.FileBody(r).Exec(ctx, &out); err != nil {
+		return iface.CacheWarmResult{}, err
+	}
+
+	result := iface.CacheWarmResult{
+		Paths:     out.Paths,
+		Warmed:    out.Warmed,
+		Protected: out.Protected,
+	}
+	for _, f := range out.Failures {
+		p, err := path.NewPath(f.Path)
+		if err != nil {
+			return iface.CacheWarmResult{}, err
+		}
+		result.Failures = append(result.Failures, iface.CacheWarmFailure{Path: p, Error: f.Error})
+	}
+	return result, nil
+}
+
+func (api *CacheAPI) core() *HttpApi {
+	return (*HttpApi)(api)
+}
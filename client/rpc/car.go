@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	iface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+)
+
+type CarAPI HttpApi
+
+func (api *CarAPI) Inspect(ctx context.Context, r io.Reader) (iface.CarInspection, error) {
+	var out struct {
+		Version      uint64
+		Roots        []cid.Cid
+		MissingRoots []cid.Cid
+		Blocks       uint64
+		Bytes        uint64
+		Codecs       []iface.CarCodec
+		HasIndex     bool
+		IndexCodec   string
+		IndexValid   bool
+		Errors       []iface.CarError
+	}
+	if err := api.core().Request("dag/inspect").FileBody(r).Exec(ctx, &out); err != nil {
+		return iface.CarInspection{}, err
+	}
+	return iface.CarInspection{
+		Version:      out.Version,
+		Roots:        out.Roots,
+		MissingRoots: out.MissingRoots,
+		Blocks:       out.Blocks,
+		Bytes:        out.Bytes,
+		Codecs:       out.Codecs,
+		HasIndex:     out.HasIndex,
+		IndexCodec:   out.IndexCodec,
+		IndexValid:   out.IndexValid,
+		Errors:       out.Errors,
+	}, nil
+}
+
+func (api *CarAPI) Transcode(ctx context.Context, r io.Reader, w io.Writer, opts ...caopts.CarTranscodeOption) error {
+	options, err := caopts.CarTranscodeOptions(opts...)
+	if err != nil {
+		return err
+	}
+
+	req := api.core().Request("dag/transcode").
+		Option("version", options.Version).
+		Option("dedupe", options.Dedupe)
+	if options.Selector != nil {
+		var sel bytes.Buffer
+		if err := dagjson.Encode(options.Selector, &sel); err != nil {
+			return err
+		}
+		req = req.Option("selector", sel.String())
+	}
+	resp, err := req.FileBody(r).Send(ctx)
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	defer resp.Close()
+
+	_, err = io.Copy(w, resp.Output)
+	return err
+}
+
+func (api *CarAPI) core() *HttpApi {
+	return (*HttpApi)(api)
+}
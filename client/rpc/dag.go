@@ -10,7 +10,9 @@ import (
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
 	format "github.com/ipfs/go-ipld-format"
+	iface "github.com/ipfs/kubo/core/coreiface"
 	"github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/libp2p/go-libp2p/core/peer"
 	multicodec "github.com/multiformats/go-multicodec"
 )
 
@@ -129,6 +131,53 @@ func (api *HttpDagServ) RemoveMany(ctx context.Context, cids []cid.Cid) error {
 	return nil
 }
 
+func (api *HttpDagServ) FetchFrom(ctx context.Context, root cid.Cid, from peer.AddrInfo, opts ...options.DagFetchFromOption) (iface.DagFetchStats, error) {
+	var stats iface.DagFetchStats
+
+	settings, err := options.DagFetchFromOptions(opts...)
+	if err != nil {
+		return stats, err
+	}
+
+	// the command takes a single address
+	addrs, err := peer.AddrInfoToP2pAddrs(&from)
+	if err != nil {
+		return stats, err
+	}
+	if len(addrs) == 0 {
+		return stats, fmt.Errorf("no addresses given for peer %s", from.ID)
+	}
+
+	req := api.core().Request("dag/fetch", root.String(), addrs[0].String()).
+		Option("pin", settings.Pin)
+	if settings.Timeout > 0 {
+		// the global timeout option, which bounds the whole request
+		req = req.Option("timeout", settings.Timeout.String())
+	}
+
+	var out struct {
+		Blocks uint64
+		Bytes  uint64
+	}
+	if err := req.Exec(ctx, &out); err != nil {
+		return stats, err
+	}
+	stats.Blocks, stats.Bytes = out.Blocks, out.Bytes
+	return stats, nil
+}
+
+func (api *HttpDagServ) Prune(ctx context.Context, root cid.Cid, rel string) (iface.DagPruneResult, error) {
+	var out struct {
+		Root   cid.Cid
+		Blocks uint64
+		Bytes  uint64
+	}
+	if err := api.core().Request("dag/prune", root.String(), rel).Exec(ctx, &out); err != nil {
+		return iface.DagPruneResult{}, err
+	}
+	return iface.DagPruneResult{Root: out.Root, Blocks: out.Blocks, Bytes: out.Bytes}, nil
+}
+
 func (api *httpNodeAdder) core() *HttpApi {
 	return (*HttpApi)(api)
 }
@@ -0,0 +1,71 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	iface "github.com/ipfs/kubo/core/coreiface"
+	carv2 "github.com/ipld/go-car/v2"
+)
+
+type ExportAPI HttpApi
+
+func (api *ExportAPI) Dataset(ctx context.Context, label string, w io.Writer) (iface.DatasetManifest, error) {
+	resp, err := api.core().Request("pin/export", label).Send(ctx)
+	if err != nil {
+		return iface.DatasetManifest{}, err
+	}
+	if resp.Error != nil {
+		return iface.DatasetManifest{}, resp.Error
+	}
+	defer resp.Close()
+
+	// the manifest is the first block of the CAR, read as it is copied
+	r := io.TeeReader(resp.Output, w)
+	br, err := carv2.NewBlockReader(r)
+	if err != nil {
+		return iface.DatasetManifest{}, err
+	}
+	blk, err := br.Next()
+	if err != nil {
+		return iface.DatasetManifest{}, err
+	}
+	var out struct {
+		Label   string `json:"label"`
+		Created string `json:"created"`
+		Pins    []struct {
+			Name         string  `json:"name"`
+			Root         cid.Cid `json:"root"`
+			Recursive    bool    `json:"recursive"`
+			ProvideClass string  `json:"provideClass"`
+		} `json:"pins"`
+	}
+	if err := json.Unmarshal(blk.RawData(), &out); err != nil {
+		return iface.DatasetManifest{}, err
+	}
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return iface.DatasetManifest{}, err
+	}
+
+	created, err := time.Parse(time.RFC3339, out.Created)
+	if err != nil {
+		return iface.DatasetManifest{}, err
+	}
+	manifest := iface.DatasetManifest{Label: out.Label, Created: created}
+	for _, p := range out.Pins {
+		manifest.Pins = append(manifest.Pins, iface.DatasetPin{
+			Name:         p.Name,
+			Root:         p.Root,
+			Recursive:    p.Recursive,
+			ProvideClass: p.ProvideClass,
+		})
+	}
+	return manifest, nil
+}
+
+func (api *ExportAPI) core() *HttpApi {
+	return (*HttpApi)(api)
+}
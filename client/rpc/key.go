@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"strings"
 
 	"github.com/ipfs/boxo/ipns"
 	"github.com/ipfs/boxo/path"
@@ -16,9 +17,10 @@ import (
 type KeyAPI HttpApi
 
 type key struct {
-	name string
-	pid  peer.ID
-	path path.Path
+	name           string
+	pid            peer.ID
+	path           path.Path
+	derivationPath string
 }
 
 func newKey(name, pidStr string) (*key, error) {
@@ -47,9 +49,14 @@ func (k *key) ID() peer.ID {
 	return k.pid
 }
 
+func (k *key) DerivationPath() string {
+	return k.derivationPath
+}
+
 type keyOutput struct {
-	Name string
-	Id   string
+	Name           string
+	Id             string
+	DerivationPath string
 }
 
 func (api *KeyAPI) Generate(ctx context.Context, name string, opts ...caopts.KeyGenerateOption) (iface.Key, error) {
@@ -70,6 +77,32 @@ func (api *KeyAPI) Generate(ctx context.Context, name string, opts ...caopts.Key
 	return newKey(out.Name, out.Id)
 }
 
+func (api *KeyAPI) Derive(ctx context.Context, name string, phrase string, opts ...caopts.KeyDeriveOption) (iface.Key, error) {
+	options, err := caopts.KeyDeriveOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	req := api.core().Request("key/derive", name)
+	if options.Path != "" {
+		req = req.Option("path", options.Path)
+	}
+	if options.Passphrase != "" {
+		req = req.Option("passphrase", options.Passphrase)
+	}
+	var out keyOutput
+	if err := req.FileBody(strings.NewReader(phrase)).Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+
+	key, err := newKey(out.Name, out.Id)
+	if err != nil {
+		return nil, err
+	}
+	key.derivationPath = out.DerivationPath
+	return key, nil
+}
+
 func (api *KeyAPI) Rename(ctx context.Context, oldName string, newName string, opts ...caopts.KeyRenameOption) (iface.Key, bool, error) {
 	options, err := caopts.KeyRenameOptions(opts...)
 	if err != nil {
@@ -111,6 +144,7 @@ func (api *KeyAPI) List(ctx context.Context) ([]iface.Key, error) {
 		if err != nil {
 			return nil, err
 		}
+		key.derivationPath = k.DerivationPath
 		res[i] = key
 	}
 
@@ -0,0 +1,134 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	iface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type KVAPI HttpApi
+
+type kvEntryOutput struct {
+	Store   string
+	Key     string
+	Value   []byte
+	Deleted bool
+	Writer  string
+	Clock   uint64
+}
+
+func (out *kvEntryOutput) entry() (iface.KVEntry, error) {
+	e := iface.KVEntry{
+		Store:   out.Store,
+		Key:     out.Key,
+		Value:   out.Value,
+		Deleted: out.Deleted,
+		Clock:   out.Clock,
+	}
+	if out.Writer != "" {
+		var err error
+		if e.Writer, err = peer.Decode(out.Writer); err != nil {
+			return iface.KVEntry{}, err
+		}
+	}
+	return e, nil
+}
+
+func (api *KVAPI) Put(ctx context.Context, store string, key string, value []byte, opts ...caopts.KVPutOption) (iface.KVEntry, error) {
+	options, err := caopts.KVPutOptions(opts...)
+	if err != nil {
+		return iface.KVEntry{}, err
+	}
+
+	var out kvEntryOutput
+	err = api.core().Request("kv/put", store, key).
+		Option("key", options.Key).
+		FileBody(bytes.NewReader(value)).
+		Exec(ctx, &out)
+	if err != nil {
+		return iface.KVEntry{}, err
+	}
+	return out.entry()
+}
+
+func (api *KVAPI) Get(ctx context.Context, store string, key string) ([]byte, error) {
+	var out kvEntryOutput
+	if err := api.core().Request("kv/get", store, key).Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out.Value, nil
+}
+
+func (api *KVAPI) Delete(ctx context.Context, store string, key string, opts ...caopts.KVPutOption) error {
+	options, err := caopts.KVPutOptions(opts...)
+	if err != nil {
+		return err
+	}
+
+	return api.core().Request("kv/rm", store, key).
+		Option("key", options.Key).
+		Exec(ctx, nil)
+}
+
+func (api *KVAPI) List(ctx context.Context, store string) ([]iface.KVEntry, error) {
+	var out struct {
+		Entries []kvEntryOutput
+	}
+	if err := api.core().Request("kv/ls", store).Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+
+	res := make([]iface.KVEntry, len(out.Entries))
+	for i := range out.Entries {
+		e, err := out.Entries[i].entry()
+		if err != nil {
+			return nil, err
+		}
+		res[i] = e
+	}
+	return res, nil
+}
+
+func (api *KVAPI) Subscribe(ctx context.Context, store string) (<-chan iface.KVEntry, error) {
+	resp, err := api.core().Request("kv/sub", store).Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	res := make(chan iface.KVEntry)
+	go func() {
+		defer close(res)
+		defer resp.Close()
+
+		dec := json.NewDecoder(resp.Output)
+		for {
+			var out kvEntryOutput
+			err := dec.Decode(&out)
+			if err != nil {
+				// io.EOF when the subscription ends
+				return
+			}
+			e, err := out.entry()
+			if err != nil {
+				return
+			}
+			select {
+			case res <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return res, nil
+}
+
+func (api *KVAPI) core() *HttpApi {
+	return (*HttpApi)(api)
+}
@@ -59,7 +59,8 @@ func (api *NameAPI) Search(ctx context.Context, name string, opts ...caopts.Name
 		Option("recursive", ropts.Depth != 1).
 		Option("dht-record-count", ropts.DhtRecordCount).
 		Option("dht-timeout", ropts.DhtTimeout).
-		Option("stream", true)
+		Option("stream", true).
+		Option("provenance", options.Provenance)
 	resp, err := req.Send(ctx)
 	if err != nil {
 		return nil, err
@@ -77,7 +78,10 @@ func (api *NameAPI) Search(ctx context.Context, name string, opts ...caopts.Name
 		dec := json.NewDecoder(resp.Output)
 
 		for {
-			var out struct{ Path string }
+			var out struct {
+				Path       string
+				Provenance []iface.NameProvenance
+			}
 			err := dec.Decode(&out)
 			if err == io.EOF {
 				return
@@ -89,6 +93,7 @@ func (api *NameAPI) Search(ctx context.Context, name string, opts ...caopts.Name
 					return
 				}
 				ires.Path = p
+				ires.Provenance = out.Provenance
 			}
 
 			select {
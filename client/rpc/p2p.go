@@ -0,0 +1,174 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	iface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+type P2PAPI HttpApi
+
+func (api *P2PAPI) Send(ctx context.Context, p peer.ID, proto string, data []byte, opts ...caopts.P2PSendOption) ([]byte, error) {
+	options, err := caopts.P2PSendOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := api.core().Request("p2p/send", p.String(), proto).
+		Option("max-reply-size", options.MaxReplySize).
+		FileBody(bytes.NewReader(data)).
+		Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	defer resp.Close()
+
+	return io.ReadAll(resp.Output)
+}
+
+// Handle is not supported over the RPC API, the handler has to run in the
+// process of the node.
+func (api *P2PAPI) Handle(ctx context.Context, proto string, handler iface.P2PMessageHandler, opts ...caopts.P2PHandleOption) error {
+	return fmt.Errorf("handling p2p messages over the RPC API: %w", iface.ErrNotSupported)
+}
+
+func (api *P2PAPI) Forward(ctx context.Context, proto string, listenAddr ma.Multiaddr, target peer.ID, opts ...caopts.P2PListenOption) (iface.P2PListener, error) {
+	options, err := caopts.P2PListenOptions(opts...)
+	if err != nil {
+		return iface.P2PListener{}, err
+	}
+
+	targetAddr, err := ma.NewMultiaddr("/p2p/" + target.String())
+	if err != nil {
+		return iface.P2PListener{}, err
+	}
+	err = api.core().Request("p2p/forward", proto, listenAddr.String(), targetAddr.String()).
+		Option("allow-custom-protocol", options.AllowCustomProtocol).
+		Exec(ctx, nil)
+	if err != nil {
+		return iface.P2PListener{}, err
+	}
+	return api.find(ctx, proto, listenAddr, targetAddr)
+}
+
+func (api *P2PAPI) Listen(ctx context.Context, proto string, targetAddr ma.Multiaddr, opts ...caopts.P2PListenOption) (iface.P2PListener, error) {
+	options, err := caopts.P2PListenOptions(opts...)
+	if err != nil {
+		return iface.P2PListener{}, err
+	}
+
+	req := api.core().Request("p2p/listen", proto, targetAddr.String()).
+		Option("allow-custom-protocol", options.AllowCustomProtocol).
+		Option("report-peer-id", options.ReportPeerID)
+	if len(options.AllowedPeers) > 0 {
+		// the options of a request are a map, the command accepts a list
+		allowed := make([]string, len(options.AllowedPeers))
+		for i, p := range options.AllowedPeers {
+			allowed[i] = p.String()
+		}
+		req.Option("allow-peer", strings.Join(allowed, ","))
+	}
+	if err := req.Exec(ctx, nil); err != nil {
+		return iface.P2PListener{}, err
+	}
+	return api.find(ctx, proto, nil, targetAddr)
+}
+
+// find returns the listener of proto with the given addresses, once created.
+func (api *P2PAPI) find(ctx context.Context, proto string, listenAddr, targetAddr ma.Multiaddr) (iface.P2PListener, error) {
+	listeners, err := api.Listeners(ctx)
+	if err != nil {
+		return iface.P2PListener{}, err
+	}
+	for _, l := range listeners {
+		if l.Protocol != proto || !targetAddr.Equal(l.TargetAddress) {
+			continue
+		}
+		if listenAddr != nil && !listenAddr.Equal(l.ListenAddress) {
+			continue
+		}
+		return l, nil
+	}
+	return iface.P2PListener{}, fmt.Errorf("listener of %s to %s not found", proto, targetAddr)
+}
+
+func (api *P2PAPI) Listeners(ctx context.Context) ([]iface.P2PListener, error) {
+	var out struct {
+		Listeners []struct {
+			Protocol      string
+			ListenAddress string
+			TargetAddress string
+			AllowedPeers  []string
+			BytesIn       uint64
+			BytesOut      uint64
+		}
+	}
+	if err := api.core().Request("p2p/ls").Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+
+	res := make([]iface.P2PListener, len(out.Listeners))
+	for i, l := range out.Listeners {
+		listen, err := ma.NewMultiaddr(l.ListenAddress)
+		if err != nil {
+			return nil, err
+		}
+		target, err := ma.NewMultiaddr(l.TargetAddress)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = iface.P2PListener{
+			Protocol:      l.Protocol,
+			ListenAddress: listen,
+			TargetAddress: target,
+			BytesIn:       l.BytesIn,
+			BytesOut:      l.BytesOut,
+		}
+		for _, a := range l.AllowedPeers {
+			pid, err := peer.Decode(a)
+			if err != nil {
+				return nil, err
+			}
+			res[i].AllowedPeers = append(res[i].AllowedPeers, pid)
+		}
+	}
+	return res, nil
+}
+
+func (api *P2PAPI) Close(ctx context.Context, opts ...caopts.P2PCloseOption) (int, error) {
+	options, err := caopts.P2PCloseOptions(opts...)
+	if err != nil {
+		return 0, err
+	}
+
+	req := api.core().Request("p2p/close").Option("all", options.All)
+	if options.Protocol != "" {
+		req.Option("protocol", options.Protocol)
+	}
+	if options.ListenAddress != nil {
+		req.Option("listen-address", options.ListenAddress.String())
+	}
+	if options.TargetAddress != nil {
+		req.Option("target-address", options.TargetAddress.String())
+	}
+
+	var closed int
+	if err := req.Exec(ctx, &closed); err != nil {
+		return 0, err
+	}
+	return closed, nil
+}
+
+func (api *P2PAPI) core() *HttpApi {
+	return (*HttpApi)(api)
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ipfs/boxo/path"
 	"github.com/ipfs/go-cid"
 	iface "github.com/ipfs/kubo/core/coreiface"
 	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/pkg/errors"
 )
 
@@ -52,8 +55,15 @@ func (api *PinAPI) Add(ctx context.Context, p path.Path, opts ...caopts.PinAddOp
 		return err
 	}
 
-	return api.core().Request("pin/add", p.String()).
-		Option("recursive", options.Recursive).Exec(ctx, nil)
+	req := api.core().Request("pin/add", p.String()).
+		Option("recursive", options.Recursive)
+	if options.Name != "" {
+		req = req.Option("name", options.Name)
+	}
+	if options.ProvideClass != "" {
+		req = req.Option("provide-class", options.ProvideClass)
+	}
+	return req.Exec(ctx, nil)
 }
 
 type pinLsObject struct {
@@ -150,9 +160,47 @@ func (api *PinAPI) Rm(ctx context.Context, p path.Path, opts ...caopts.PinRmOpti
 		return err
 	}
 
-	return api.core().Request("pin/rm", p.String()).
-		Option("recursive", options.Recursive).
-		Exec(ctx, nil)
+	req := api.core().Request("pin/rm", p.String()).
+		Option("recursive", options.Recursive)
+	if options.Grace > 0 {
+		req = req.Option("grace", options.Grace.String())
+	}
+	return req.Exec(ctx, nil)
+}
+
+func (api *PinAPI) Removed(ctx context.Context) ([]iface.PinRemoval, error) {
+	var out struct {
+		Removals []struct {
+			Cid       string
+			Recursive bool
+			Name      string
+			Removed   time.Time
+			Expires   time.Time
+		}
+	}
+	if err := api.core().Request("pin/removed").Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+
+	removals := make([]iface.PinRemoval, len(out.Removals))
+	for i, r := range out.Removals {
+		c, err := cid.Decode(r.Cid)
+		if err != nil {
+			return nil, err
+		}
+		removals[i] = iface.PinRemoval{
+			Path:      path.FromCid(c),
+			Recursive: r.Recursive,
+			Name:      r.Name,
+			Removed:   r.Removed,
+			Expires:   r.Expires,
+		}
+	}
+	return removals, nil
+}
+
+func (api *PinAPI) Restore(ctx context.Context, p path.Path) error {
+	return api.core().Request("pin/restore", p.String()).Exec(ctx, nil)
 }
 
 func (api *PinAPI) Update(ctx context.Context, from path.Path, to path.Path, opts ...caopts.PinUpdateOption) error {
@@ -165,6 +213,218 @@ func (api *PinAPI) Update(ctx context.Context, from path.Path, to path.Path, opt
 		Option("unpin", options.Unpin).Exec(ctx, nil)
 }
 
+type pinQueueObject struct {
+	ID          string
+	Cid         string
+	Recursive   bool
+	Name        string
+	Priority    int
+	Status      string
+	Attempts    int
+	LastError   string
+	Created     time.Time
+	NextAttempt time.Time
+}
+
+func (o *pinQueueObject) toPinRequest() (iface.PinRequest, error) {
+	c, err := cid.Decode(o.Cid)
+	if err != nil {
+		return iface.PinRequest{}, err
+	}
+	return iface.PinRequest{
+		ID:          o.ID,
+		Path:        path.FromCid(c),
+		Recursive:   o.Recursive,
+		Name:        o.Name,
+		Priority:    o.Priority,
+		Status:      o.Status,
+		Attempts:    o.Attempts,
+		LastError:   o.LastError,
+		Created:     o.Created,
+		NextAttempt: o.NextAttempt,
+	}, nil
+}
+
+func (api *PinAPI) Enqueue(ctx context.Context, p path.Path, opts ...caopts.PinAddOption) (iface.PinRequest, error) {
+	options, err := caopts.PinAddOptions(opts...)
+	if err != nil {
+		return iface.PinRequest{}, err
+	}
+
+	var out struct {
+		Queued []*pinQueueObject
+	}
+	err = api.core().Request("pin/add", p.String()).
+		Option("recursive", options.Recursive).
+		Option("name", options.Name).
+		Option("background", true).
+		Option("priority", options.Priority).
+		Exec(ctx, &out)
+	if err != nil {
+		return iface.PinRequest{}, err
+	}
+	if len(out.Queued) != 1 {
+		return iface.PinRequest{}, errors.New("http api returned no queued pin")
+	}
+	return out.Queued[0].toPinRequest()
+}
+
+func (api *PinAPI) Queue(ctx context.Context) ([]iface.PinRequest, error) {
+	res, err := api.core().Request("pin/queue/ls").Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	defer res.Close()
+
+	var reqs []iface.PinRequest
+	dec := json.NewDecoder(res.Output)
+	for {
+		var out pinQueueObject
+		if err := dec.Decode(&out); err != nil {
+			if err == io.EOF {
+				return reqs, nil
+			}
+			return nil, err
+		}
+		r, err := out.toPinRequest()
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, r)
+	}
+}
+
+func (api *PinAPI) SetQueuePriority(ctx context.Context, id string, priority int) (iface.PinRequest, error) {
+	var out pinQueueObject
+	err := api.core().Request("pin/queue/priority", id, strconv.Itoa(priority)).Exec(ctx, &out)
+	if err != nil {
+		return iface.PinRequest{}, err
+	}
+	return out.toPinRequest()
+}
+
+func (api *PinAPI) CancelQueued(ctx context.Context, id string) error {
+	return api.core().Request("pin/queue/cancel", id).Exec(ctx, nil)
+}
+
+func (api *PinAPI) Report(ctx context.Context, p path.Path) (iface.PinReport, error) {
+	var out struct {
+		Cid       string
+		Recursive bool
+		Started   time.Time
+		Finished  time.Time
+		Blocks    uint64
+		Bytes     uint64
+		Sources   []struct {
+			Peer      string
+			Transport string
+			Blocks    uint64
+			Bytes     uint64
+		}
+	}
+	if err := api.core().Request("pin/report", p.String()).Exec(ctx, &out); err != nil {
+		return iface.PinReport{}, err
+	}
+
+	c, err := cid.Decode(out.Cid)
+	if err != nil {
+		return iface.PinReport{}, err
+	}
+	report := iface.PinReport{
+		Path:      path.FromCid(c),
+		Recursive: out.Recursive,
+		Started:   out.Started,
+		Finished:  out.Finished,
+		Blocks:    out.Blocks,
+		Bytes:     out.Bytes,
+		Sources:   make([]iface.PinReportSource, len(out.Sources)),
+	}
+	for i, s := range out.Sources {
+		report.Sources[i] = iface.PinReportSource{
+			Transport: s.Transport,
+			Blocks:    s.Blocks,
+			Bytes:     s.Bytes,
+		}
+		if s.Peer != "" {
+			if report.Sources[i].Peer, err = peer.Decode(s.Peer); err != nil {
+				return iface.PinReport{}, err
+			}
+		}
+	}
+	return report, nil
+}
+
+func (api *PinAPI) SetProvideClass(ctx context.Context, p path.Path, class string) error {
+	return api.core().Request("pin/provide-class/set", class, p.String()).Exec(ctx, nil)
+}
+
+func (api *PinAPI) ProvideClasses(ctx context.Context) ([]iface.PinProvideClass, error) {
+	var out struct {
+		Pins []struct {
+			Cid       string
+			Recursive bool
+			Class     string
+			Provided  time.Time
+		}
+	}
+	if err := api.core().Request("pin/provide-class/ls").Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+
+	classes := make([]iface.PinProvideClass, len(out.Pins))
+	for i, p := range out.Pins {
+		c, err := cid.Decode(p.Cid)
+		if err != nil {
+			return nil, err
+		}
+		classes[i] = iface.PinProvideClass{
+			Path:      path.FromCid(c),
+			Recursive: p.Recursive,
+			Class:     p.Class,
+			Provided:  p.Provided,
+		}
+	}
+	return classes, nil
+}
+
+func (api *PinAPI) Reconcile(ctx context.Context, p peer.ID) (iface.PinsetDiff, error) {
+	type entry struct {
+		Cid       string
+		Recursive bool
+	}
+	var out struct {
+		Missing []entry
+		Extra   []entry
+	}
+	if err := api.core().Request("pin/reconcile", p.String()).Exec(ctx, &out); err != nil {
+		return iface.PinsetDiff{}, err
+	}
+
+	toEntries := func(in []entry) ([]iface.PinsetEntry, error) {
+		entries := make([]iface.PinsetEntry, len(in))
+		for i, e := range in {
+			c, err := cid.Decode(e.Cid)
+			if err != nil {
+				return nil, err
+			}
+			entries[i] = iface.PinsetEntry{Path: path.FromCid(c), Recursive: e.Recursive}
+		}
+		return entries, nil
+	}
+	var diff iface.PinsetDiff
+	var err error
+	if diff.Missing, err = toEntries(out.Missing); err != nil {
+		return iface.PinsetDiff{}, err
+	}
+	if diff.Extra, err = toEntries(out.Extra); err != nil {
+		return iface.PinsetDiff{}, err
+	}
+	return diff, nil
+}
+
 type pinVerifyRes struct {
 	ok       bool
 	badNodes []iface.BadPinNode
@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	iface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type ReplicateAPI HttpApi
+
+func (api *ReplicateAPI) Push(ctx context.Context, target peer.ID, name string, opts ...caopts.ReplicatePushOption) ([]iface.ReplicatedPin, error) {
+	options, err := caopts.ReplicatePushOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{target.String()}
+	if name != "" {
+		args = append(args, name)
+	}
+	req := api.core().Request("pin/push", args...).Option("retries", options.Retries)
+	if options.BytesPerSecond > 0 {
+		req = req.Option("bandwidth", strconv.FormatUint(options.BytesPerSecond, 10))
+	}
+
+	var out struct {
+		Pins []struct {
+			Cid       string
+			Recursive bool
+			Name      string
+			Blocks    uint64
+			Bytes     uint64
+			Skipped   bool
+			Attempts  int
+		}
+	}
+	if err := req.Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+
+	pins := make([]iface.ReplicatedPin, len(out.Pins))
+	for i, p := range out.Pins {
+		c, err := cid.Decode(p.Cid)
+		if err != nil {
+			return nil, err
+		}
+		pins[i] = iface.ReplicatedPin{
+			Path:      path.FromCid(c),
+			Recursive: p.Recursive,
+			Name:      p.Name,
+			Blocks:    p.Blocks,
+			Bytes:     p.Bytes,
+			Skipped:   p.Skipped,
+			Attempts:  p.Attempts,
+		}
+	}
+	return pins, nil
+}
+
+func (api *ReplicateAPI) core() *HttpApi {
+	return (*HttpApi)(api)
+}
@@ -0,0 +1,21 @@
+package rpc
+
+import (
+	"context"
+
+	iface "github.com/ipfs/kubo/core/coreiface"
+)
+
+type RepoAPI HttpApi
+
+func (api *RepoAPI) LastGCReport(ctx context.Context) (iface.GCReport, error) {
+	var out iface.GCReport
+	if err := api.core().Request("repo/gc-report").Exec(ctx, &out); err != nil {
+		return iface.GCReport{}, err
+	}
+	return out, nil
+}
+
+func (api *RepoAPI) core() *HttpApi {
+	return (*HttpApi)(api)
+}
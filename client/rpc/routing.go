@@ -5,8 +5,12 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"io"
 
 	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	iface "github.com/ipfs/kubo/core/coreiface"
 	"github.com/ipfs/kubo/core/coreiface/options"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/routing"
@@ -159,3 +163,56 @@ func (api *RoutingAPI) Provide(ctx context.Context, p path.Path, opts ...options
 func (api *RoutingAPI) core() *HttpApi {
 	return (*HttpApi)(api)
 }
+
+func (api *RoutingAPI) CheckAvailability(ctx context.Context, cids []cid.Cid, opts ...options.RoutingCheckAvailabilityOption) ([]iface.Availability, error) {
+	options, err := options.RoutingCheckAvailabilityOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, len(cids))
+	for i, c := range cids {
+		args[i] = c.String()
+	}
+	resp, err := api.core().Request("routing/check", args...).
+		Option("num-providers", options.NumProviders).
+		Option("fetch", options.Fetch).
+		Option("concurrency", options.Concurrency).
+		Option("step-timeout", options.Timeout.String()).
+		Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	defer resp.Close()
+
+	out := make([]iface.Availability, 0, len(cids))
+	dec := json.NewDecoder(resp.Output)
+	for {
+		var a iface.Availability
+		if err := dec.Decode(&a); err != nil {
+			if errors.Is(err, io.EOF) {
+				return out, nil
+			}
+			return nil, err
+		}
+		out = append(out, a)
+	}
+}
+
+func (api *RoutingAPI) EstimateRetrieval(ctx context.Context, p path.Path, opts ...options.RoutingEstimateRetrievalOption) (iface.RetrievalEstimate, error) {
+	options, err := options.RoutingEstimateRetrievalOptions(opts...)
+	if err != nil {
+		return iface.RetrievalEstimate{}, err
+	}
+
+	var out iface.RetrievalEstimate
+	err = api.core().Request("routing/estimate", p.String()).
+		Option("num-providers", options.NumProviders).
+		Option("sample-blocks", options.SampleBlocks).
+		Option("step-timeout", options.Timeout.String()).
+		Exec(ctx, &out)
+	return out, err
+}
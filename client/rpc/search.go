@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	iface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+)
+
+type SearchAPI HttpApi
+
+type searchHit struct {
+	Source  string
+	Path    string
+	Cid     string
+	Root    string
+	Title   string
+	Format  string
+	Score   float64
+	Snippet string
+}
+
+func (api *SearchAPI) Query(ctx context.Context, q string, opts ...caopts.SearchQueryOption) ([]iface.SearchHit, error) {
+	options, err := caopts.SearchQueryOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := api.core().Request("search/text", q).
+		Option("limit", options.Limit).
+		Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	defer resp.Close()
+
+	var hits []iface.SearchHit
+	dec := json.NewDecoder(resp.Output)
+	for {
+		var out searchHit
+		if err := dec.Decode(&out); err != nil {
+			if err == io.EOF {
+				return hits, nil
+			}
+			return nil, err
+		}
+
+		h := iface.SearchHit{
+			Source:  out.Source,
+			Path:    out.Path,
+			Title:   out.Title,
+			Format:  out.Format,
+			Score:   out.Score,
+			Snippet: out.Snippet,
+		}
+		if h.Cid, err = cid.Decode(out.Cid); err != nil {
+			return nil, err
+		}
+		if out.Root != "" {
+			if h.Root, err = cid.Decode(out.Root); err != nil {
+				return nil, err
+			}
+		}
+		hits = append(hits, h)
+	}
+}
+
+func (api *SearchAPI) core() *HttpApi {
+	return (*HttpApi)(api)
+}
@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	iface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type StandbyAPI HttpApi
+
+func (api *StandbyAPI) Status(ctx context.Context) (iface.StandbyStatus, error) {
+	var out struct {
+		Primary   string
+		Standbys  []string
+		Promoted  bool
+		LastSync  time.Time
+		LastError string
+		Pins      int
+		Files     string
+		Keys      int
+	}
+	if err := api.core().Request("standby/status").Exec(ctx, &out); err != nil {
+		return iface.StandbyStatus{}, err
+	}
+
+	st := iface.StandbyStatus{
+		Promoted:  out.Promoted,
+		LastSync:  out.LastSync,
+		LastError: out.LastError,
+		Pins:      out.Pins,
+		Keys:      out.Keys,
+	}
+	var err error
+	if out.Primary != "" {
+		if st.Primary, err = peer.Decode(out.Primary); err != nil {
+			return iface.StandbyStatus{}, err
+		}
+	}
+	for _, s := range out.Standbys {
+		p, err := peer.Decode(s)
+		if err != nil {
+			return iface.StandbyStatus{}, err
+		}
+		st.Standbys = append(st.Standbys, p)
+	}
+	if out.Files != "" {
+		if st.Files, err = cid.Decode(out.Files); err != nil {
+			return iface.StandbyStatus{}, err
+		}
+	}
+	return st, nil
+}
+
+func (api *StandbyAPI) Sync(ctx context.Context) error {
+	return api.core().Request("standby/sync").Exec(ctx, nil)
+}
+
+func (api *StandbyAPI) Promote(ctx context.Context, opts ...caopts.StandbyPromoteOption) error {
+	options, err := caopts.StandbyPromoteOptions(opts...)
+	if err != nil {
+		return err
+	}
+	return api.core().Request("standby/promote").Option("force", options.Force).Exec(ctx, nil)
+}
+
+func (api *StandbyAPI) core() *HttpApi {
+	return (*HttpApi)(api)
+}
@@ -182,6 +182,68 @@ func (api *SwarmAPI) ListenAddrs(ctx context.Context) ([]multiaddr.Multiaddr, er
 	return res, nil
 }
 
+func (api *SwarmAPI) KnownPeers(ctx context.Context) ([]iface.KnownPeer, error) {
+	var out struct {
+		Peers []struct {
+			ID        peer.ID
+			Addrs     []string
+			Protocols []protocol.ID
+			Connected bool
+		}
+	}
+	err := api.core().Request("swarm/peerstore/ls").
+		Option("protocols", true).
+		Exec(ctx, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]iface.KnownPeer, len(out.Peers))
+	for i, p := range out.Peers {
+		res[i] = iface.KnownPeer{
+			ID:        p.ID,
+			Addrs:     make([]multiaddr.Multiaddr, len(p.Addrs)),
+			Protocols: p.Protocols,
+			Connected: p.Connected,
+		}
+		for j, addr := range p.Addrs {
+			a, err := multiaddr.NewMultiaddr(addr)
+			if err != nil {
+				return nil, err
+			}
+			res[i].Addrs[j] = a
+		}
+	}
+	return res, nil
+}
+
+func (api *SwarmAPI) ForgetPeers(ctx context.Context, peers ...peer.ID) ([]peer.ID, error) {
+	args := make([]string, len(peers))
+	for i, p := range peers {
+		args[i] = p.String()
+	}
+
+	var out struct {
+		Strings []string
+	}
+	err := api.core().Request("swarm/peerstore/rm", args...).
+		Option("all", len(peers) == 0).
+		Exec(ctx, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]peer.ID, len(out.Strings))
+	for i, s := range out.Strings {
+		p, err := peer.Decode(s)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = p
+	}
+	return res, nil
+}
+
 func (api *SwarmAPI) core() *HttpApi {
 	return (*HttpApi)(api)
 }
@@ -6,6 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
+	"os"
+	gopath "path"
+	"strconv"
+	"time"
 
 	"github.com/ipfs/boxo/files"
 	unixfs "github.com/ipfs/boxo/ipld/unixfs"
@@ -14,18 +19,36 @@ import (
 	"github.com/ipfs/go-cid"
 	iface "github.com/ipfs/kubo/core/coreiface"
 	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/core/localfiles"
 	mh "github.com/multiformats/go-multihash"
 )
 
 type addEvent struct {
-	Name  string
-	Hash  string `json:",omitempty"`
-	Bytes int64  `json:",omitempty"`
-	Size  string `json:",omitempty"`
+	Name   string
+	Hash   string              `json:",omitempty"`
+	Bytes  int64               `json:",omitempty"`
+	Size   string              `json:",omitempty"`
+	DryRun *caopts.DryRunStats `json:",omitempty"`
 }
 
 type UnixfsAPI HttpApi
 
+// posixMode returns the permission, setuid, setgid and sticky bits of mode as
+// an octal POSIX mode.
+func posixMode(mode os.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= 0o4000
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= 0o2000
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= 0o1000
+	}
+	return m
+}
+
 func (api *UnixfsAPI) Add(ctx context.Context, f files.Node, opts ...caopts.UnixfsAddOption) (path.ImmutablePath, error) {
 	options, _, err := caopts.UnixfsAddOptions(opts...)
 	if err != nil {
@@ -45,14 +68,46 @@ func (api *UnixfsAPI) Add(ctx context.Context, f files.Node, opts ...caopts.Unix
 		Option("inline", options.Inline).
 		Option("inline-limit", options.InlineLimit).
 		Option("nocopy", options.NoCopy).
+		Option("incremental", options.Incremental).
+		Option("car", options.Car).
+		Option("wrap-with-directory", options.Wrap).
+		Option("preserve-mode", options.PreserveMode).
+		Option("preserve-mtime", options.PreserveMtime).
+		Option("preserve-order", options.PreserveOrder).
 		Option("only-hash", options.OnlyHash).
 		Option("pin", options.Pin).
 		Option("silent", options.Silent).
-		Option("progress", options.Progress)
+		Option("progress", options.Progress || options.ProgressEvents != nil)
 
 	if options.RawLeavesSet {
 		req.Option("raw-leaves", options.RawLeaves)
 	}
+	if options.DryRun != nil {
+		*options.DryRun = caopts.DryRunStats{}
+		req.Option("dry-run", true)
+	}
+	if options.MemoryBudget > 0 {
+		req.Option("memory-budget", strconv.FormatUint(options.MemoryBudget, 10))
+	}
+	if options.MaxFileLinks != 0 {
+		req.Option("max-file-links", options.MaxFileLinks)
+	}
+	if options.MaxDirectoryLinks != 0 {
+		req.Option("max-directory-links", options.MaxDirectoryLinks)
+	}
+	if options.HAMTFanout != 0 {
+		req.Option("hamt-fanout", options.HAMTFanout)
+	}
+	if options.AddConcurrency != 0 {
+		req.Option("add-concurrency", options.AddConcurrency)
+	}
+	if options.Mode != 0 {
+		req.Option("mode", strconv.FormatUint(uint64(posixMode(options.Mode)), 8))
+	}
+	if !options.Mtime.IsZero() {
+		req.Option("mtime", options.Mtime.Unix())
+		req.Option("mtime-nsecs", options.Mtime.Nanosecond())
+	}
 
 	switch options.Layout {
 	case caopts.BalancedLayout:
@@ -61,7 +116,18 @@ func (api *UnixfsAPI) Add(ctx context.Context, f files.Node, opts ...caopts.Unix
 		req.Option("trickle", true)
 	}
 
-	d := files.NewMapDirectory(map[string]files.Node{"": f}) // unwrapped on the other side
+	// the ignore file and the files are the caller's: the entries are
+	// skipped, and the symlinks followed, before being sent instead of by
+	// the daemon
+	policy, err := localfiles.FromSettings(options)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+	if f, err = localfiles.Apply(f, policy); err != nil {
+		return path.ImmutablePath{}, err
+	}
+
+	d := files.NewMapDirectory(map[string]files.Node{options.Name: f}) // unwrapped on the other side, unless wrapping
 
 	version, err := api.core().loadRemoteVersion()
 	if err != nil {
@@ -71,6 +137,7 @@ func (api *UnixfsAPI) Add(ctx context.Context, f files.Node, opts ...caopts.Unix
 	req.Body(files.NewMultiFileReader(d, false, useEncodedAbsPaths))
 
 	var out addEvent
+	var started string
 	resp, err := req.Send(ctx)
 	if err != nil {
 		return path.ImmutablePath{}, err
@@ -90,9 +157,22 @@ loop:
 		default:
 			return path.ImmutablePath{}, err
 		}
+		if evt.DryRun != nil {
+			// sent after the events of the entry, the last one being its root
+			if options.DryRun != nil {
+				*options.DryRun = *evt.DryRun
+			}
+			continue
+		}
 		out = evt
 
-		if options.Events != nil {
+		if options.ProgressEvents != nil {
+			if err := sendProgressEvents(ctx, options.ProgressEvents, evt, &started); err != nil {
+				return path.ImmutablePath{}, err
+			}
+		}
+		// the bytes only events are requested for the typed events too
+		if options.Events != nil && (out.Hash != "" || options.Progress) {
 			ifevt := &iface.AddEvent{
 				Name:  out.Name,
 				Size:  out.Size,
@@ -121,14 +201,55 @@ loop:
 		return path.ImmutablePath{}, err
 	}
 
+	if options.ProgressEvents != nil {
+		size, _ := strconv.ParseUint(out.Size, 10, 64)
+		select {
+		case options.ProgressEvents <- caopts.AddProgressEvent{Kind: caopts.RootEmitted, Path: path.FromCid(c), Size: size, Time: time.Now()}:
+		case <-ctx.Done():
+			return path.ImmutablePath{}, ctx.Err()
+		}
+	}
+
 	return path.FromCid(c), nil
 }
 
+// sendProgressEvents sends the typed events of the add event evt of the
+// daemon to sink. started is the name of the last file started, the daemon
+// only reporting the bytes written.
+func sendProgressEvents(ctx context.Context, sink chan<- caopts.AddProgressEvent, evt addEvent, started *string) error {
+	var events []caopts.AddProgressEvent
+	now := time.Now()
+	if evt.Hash == "" {
+		if evt.Name != *started {
+			*started = evt.Name
+			events = append(events, caopts.AddProgressEvent{Kind: caopts.FileStarted, Name: evt.Name, Time: now})
+		}
+		events = append(events, caopts.AddProgressEvent{Kind: caopts.ChunkWritten, Name: evt.Name, Bytes: evt.Bytes, Time: now})
+	} else if evt.Name != "" {
+		c, err := cid.Parse(evt.Hash)
+		if err != nil {
+			return err
+		}
+		size, _ := strconv.ParseUint(evt.Size, 10, 64)
+		events = append(events, caopts.AddProgressEvent{Kind: caopts.FileCompleted, Name: evt.Name, Path: path.FromCid(c), Size: size, Time: now})
+	}
+	for _, ev := range events {
+		select {
+		case sink <- ev:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
 type lsLink struct {
 	Name, Hash string
 	Size       uint64
 	Type       unixfs_pb.Data_DataType
 	Target     string
+	MimeType   string
+	Cursor     string
 }
 
 type lsObject struct {
@@ -140,6 +261,20 @@ type lsOutput struct {
 	Objects []lsObject
 }
 
+// AddURL adds the content at an http or https URL with the urlstore. The
+// content is fetched by the client and streamed to the node with its URL,
+// which the node stores instead of the leaves.
+func (api *UnixfsAPI) AddURL(ctx context.Context, rawURL string, opts ...caopts.UnixfsAddOption) (path.ImmutablePath, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return path.ImmutablePath{}, fmt.Errorf("%q is not an http or https URL", rawURL)
+	}
+	return api.Add(ctx, files.NewWebFile(u), append(opts, caopts.Unixfs.Nocopy(true))...)
+}
+
 func (api *UnixfsAPI) Ls(ctx context.Context, p path.Path, opts ...caopts.UnixfsLsOption) (<-chan iface.DirEntry, error) {
 	options, err := caopts.UnixfsLsOptions(opts...)
 	if err != nil {
@@ -149,7 +284,12 @@ func (api *UnixfsAPI) Ls(ctx context.Context, p path.Path, opts ...caopts.Unixfs
 	resp, err := api.core().Request("ls", p.String()).
 		Option("resolve-type", options.ResolveChildren).
 		Option("size", options.ResolveChildren).
+		Option("mime-type", options.ResolveMimeType).
 		Option("stream", true).
+		Option("offset", options.Offset).
+		Option("limit", options.Limit).
+		Option("cursor", options.Cursor).
+		Option("preserved-order", options.PreservedOrder).
 		Send(ctx)
 	if err != nil {
 		return nil, err
@@ -217,11 +357,13 @@ func (api *UnixfsAPI) Ls(ctx context.Context, p path.Path, opts ...caopts.Unixfs
 
 			select {
 			case out <- iface.DirEntry{
-				Name:   l0.Name,
-				Cid:    c,
-				Size:   l0.Size,
-				Type:   ftype,
-				Target: l0.Target,
+				Name:     l0.Name,
+				Cid:      c,
+				Size:     l0.Size,
+				Type:     ftype,
+				Target:   l0.Target,
+				MimeType: l0.MimeType,
+				Cursor:   l0.Cursor,
 			}:
 			case <-ctx.Done():
 			}
@@ -231,6 +373,145 @@ func (api *UnixfsAPI) Ls(ctx context.Context, p path.Path, opts ...caopts.Unixfs
 	return out, nil
 }
 
+type searchOutput struct {
+	Source   string
+	Path     string
+	Cid      string
+	Root     string
+	Type     string
+	Size     uint64
+	MimeType string
+	Added    time.Time
+	ModTime  *time.Time
+}
+
+func (api *UnixfsAPI) Search(ctx context.Context, query string, opts ...caopts.UnixfsSearchOption) ([]iface.SearchResult, error) {
+	options, err := caopts.UnixfsSearchOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := api.core().Request("search", query).
+		Option("limit", options.Limit).
+		Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	defer resp.Close()
+
+	var results []iface.SearchResult
+	dec := json.NewDecoder(resp.Output)
+	for {
+		var out searchOutput
+		if err := dec.Decode(&out); err != nil {
+			if err == io.EOF {
+				return results, nil
+			}
+			return nil, err
+		}
+
+		r := iface.SearchResult{
+			Source:   out.Source,
+			Path:     out.Path,
+			Name:     gopath.Base(out.Path),
+			Type:     iface.TFile,
+			Size:     out.Size,
+			MimeType: out.MimeType,
+			Added:    out.Added,
+		}
+		if out.Type == iface.TDirectory.String() {
+			r.Type = iface.TDirectory
+		}
+		if r.Cid, err = cid.Decode(out.Cid); err != nil {
+			return nil, err
+		}
+		if out.Root != "" {
+			if r.Root, err = cid.Decode(out.Root); err != nil {
+				return nil, err
+			}
+		}
+		if out.ModTime != nil {
+			r.ModTime = *out.ModTime
+		}
+		results = append(results, r)
+	}
+}
+
+func (api *UnixfsAPI) LsShards(ctx context.Context, p path.Path) ([]iface.ShardInfo, error) {
+	var out struct {
+		Shards []iface.ShardInfo
+	}
+	if err := api.core().Request("diag/shards", p.String()).Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out.Shards, nil
+}
+
+func (api *UnixfsAPI) Mv(ctx context.Context, src, dst string, opts ...caopts.UnixfsMvOption) error {
+	options, err := caopts.UnixfsMvOptions(opts...)
+	if err != nil {
+		return err
+	}
+	return api.core().Request("files/mv", src, dst).
+		Option("parents", options.Parents).
+		Option("overwrite", options.Overwrite).
+		Exec(ctx, nil)
+}
+
+func (api *UnixfsAPI) Chmod(ctx context.Context, p string, mode os.FileMode, opts ...caopts.UnixfsMetaOption) error {
+	options, err := caopts.UnixfsMetaOptions(opts...)
+	if err != nil {
+		return err
+	}
+	return api.core().Request("files/chmod", strconv.FormatUint(uint64(posixMode(mode)), 8), p).
+		Option("recursive", options.Recursive).
+		Exec(ctx, nil)
+}
+
+func (api *UnixfsAPI) Touch(ctx context.Context, p string, opts ...caopts.UnixfsMetaOption) error {
+	options, err := caopts.UnixfsMetaOptions(opts...)
+	if err != nil {
+		return err
+	}
+	req := api.core().Request("files/touch", p).
+		Option("recursive", options.Recursive)
+	if !options.ModTime.IsZero() {
+		req.Option("mtime", options.ModTime.Unix())
+		req.Option("mtime-nsecs", options.ModTime.Nanosecond())
+	}
+	return req.Exec(ctx, nil)
+}
+
+func (api *UnixfsAPI) Symlink(ctx context.Context, target, linkPath string, opts ...caopts.UnixfsSymlinkOption) error {
+	options, err := caopts.UnixfsSymlinkOptions(opts...)
+	if err != nil {
+		return err
+	}
+	return api.core().Request("files/symlink", target, linkPath).
+		Option("parents", options.Parents).
+		Exec(ctx, nil)
+}
+
+func (api *UnixfsAPI) Flush(ctx context.Context, p string) (path.ImmutablePath, error) {
+	if p == "" {
+		p = "/"
+	}
+	var out struct {
+		Cid string
+	}
+	if err := api.core().Request("files/flush", p).Exec(ctx, &out); err != nil {
+		return path.ImmutablePath{}, err
+	}
+	c, err := cid.Parse(out.Cid)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+	return path.FromCid(c), nil
+}
+
 func (api *UnixfsAPI) core() *HttpApi {
 	return (*HttpApi)(api)
 }
@@ -1,6 +1,7 @@
 package kubo
 
 import (
+	"context"
 	"errors"
 	_ "expvar"
 	"fmt"
@@ -28,12 +29,15 @@ import (
 	corehttp "github.com/ipfs/kubo/core/corehttp"
 	options "github.com/ipfs/kubo/core/coreiface/options"
 	corerepo "github.com/ipfs/kubo/core/corerepo"
+	"github.com/ipfs/kubo/core/httphints"
 	libp2p "github.com/ipfs/kubo/core/node/libp2p"
+	"github.com/ipfs/kubo/core/subsystem"
 	nodeMount "github.com/ipfs/kubo/fuse/node"
 	fsrepo "github.com/ipfs/kubo/repo/fsrepo"
 	"github.com/ipfs/kubo/repo/fsrepo/migrations"
 	"github.com/ipfs/kubo/repo/fsrepo/migrations/ipfsfetcher"
 	goprocess "github.com/jbenet/goprocess"
+	gostream "github.com/libp2p/go-libp2p-gostream"
 	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
 	pnet "github.com/libp2p/go-libp2p/core/pnet"
 	"github.com/libp2p/go-libp2p/core/protocol"
@@ -134,7 +138,8 @@ Shutdown
 
 To shut down the daemon, send a SIGINT signal to it (e.g. by pressing 'Ctrl-C')
 or send a SIGTERM signal to it (e.g. with 'kill'). It may take a while for the
-daemon to shutdown gracefully, but it can be killed forcibly by sending a
+daemon to shutdown gracefully, as in-flight requests get up to
+Internal.ShutdownTimeout to finish, but it can be killed forcibly by sending a
 second signal.
 
 IPFS_PATH environment variable
@@ -427,11 +432,16 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		if cfg.Routing.AcceleratedDHTClient {
 			return fmt.Errorf("Routing.AcceleratedDHTClient option is set even tho Routing.Type is custom, using custom .AcceleratedDHTClient needs to be set on DHT routers individually")
 		}
+		addrs := cfg.Addresses
+		addrs.AppendAnnounce, err = httphints.AppendAnnounce(addrs.AppendAnnounce, cfg.Experimental.HTTPProviderURLs)
+		if err != nil {
+			return err
+		}
 		ncfg.Routing = libp2p.ConstructDelegatedRouting(
 			cfg.Routing.Routers,
 			cfg.Routing.Methods,
 			cfg.Identity.PeerID,
-			cfg.Addresses,
+			addrs,
 			cfg.Identity.PrivKey,
 		)
 	default:
@@ -443,7 +453,12 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		version.SetUserAgentSuffix(agentVersionSuffixString)
 	}
 
-	node, err := core.NewNode(req.Context, ncfg)
+	// The node is not tied to the request context: on interrupt it is shut
+	// down gracefully below, instead of being torn down right away.
+	nodeCtx, cancelNode := context.WithCancel(context.Background())
+	defer cancelNode()
+
+	node, err := core.NewNode(nodeCtx, ncfg)
 	if err != nil {
 		return err
 	}
@@ -477,10 +492,23 @@ take effect.
 `)
 	}
 
+	// On interrupt, stop accepting requests and let the in-flight ones
+	// finish, for up to Internal.ShutdownTimeout, before closing the node. A
+	// second interrupt exits right away.
+	go func() {
+		<-req.Context.Done()
+		err := node.Shutdown(context.Background(), core.ShutdownOptions{Drain: true})
+		if err != nil {
+			log.Errorf("error while shutting down: %s", err)
+		}
+	}()
+
 	defer func() {
 		// We wait for the node to close first, as the node has children
 		// that it will wait for before closing, such as the API server.
-		node.Close()
+		// This also waits for a shutdown already in progress, started on
+		// interrupt or by 'ipfs shutdown'.
+		node.Shutdown(context.Background(), core.ShutdownOptions{})
 
 		select {
 		case <-req.Context.Done():
@@ -489,6 +517,14 @@ take effect.
 		}
 	}()
 
+	// The repo contexts are closed before the node, whose identity they
+	// may share.
+	contexts, err := openRepoContexts(nodeCtx, cctx.ConfigRoot, node, cfg.Repos)
+	if err != nil {
+		return err
+	}
+	defer closeRepoContexts(contexts)
+
 	cctx.ConstructNode = func() (*core.IpfsNode, error) {
 		return node, nil
 	}
@@ -556,6 +592,12 @@ take effect.
 		return err
 	}
 
+	// add remote administration over libp2p
+	adminErrc, err := serveRemoteAdmin(cctx)
+	if err != nil {
+		return err
+	}
+
 	// Add ipfs version info to prometheus metrics
 	ipfsInfoMetric := promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "ipfs_info",
@@ -622,7 +664,7 @@ take effect.
 	// collect long-running errors and block for shutdown
 	// TODO(cryptix): our fuse currently doesn't follow this pattern for graceful shutdown
 	var errs error
-	for err := range merge(apiErrc, gwErrc, gcErrc, p2pGwErrc) {
+	for err := range merge(apiErrc, gwErrc, gcErrc, p2pGwErrc, adminErrc) {
 		if err != nil {
 			errs = multierror.Append(errs, err)
 		}
@@ -643,6 +685,8 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 		return nil, fmt.Errorf("serveHTTPApi: socket activation failed: %s", err)
 	}
 
+	activated := len(listeners)
+
 	apiAddrs := make([]string, 0, 2)
 	apiAddr, _ := req.Options[commands.ApiOption].(string)
 	if apiAddr == "" {
@@ -665,7 +709,7 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 			continue
 		}
 
-		apiLis, err := manet.Listen(apiMaddr)
+		apiLis, err := listen(apiMaddr)
 		if err != nil {
 			return nil, fmt.Errorf("serveHTTPApi: manet.Listen(%s) failed: %s", apiMaddr, err)
 		}
@@ -674,17 +718,28 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 		listeners = append(listeners, apiLis)
 	}
 
-	if len(cfg.API.Authorizations) > 0 && len(listeners) > 0 {
-		fmt.Printf("RPC API access is limited by the rules defined in API.Authorizations\n")
-	}
-
-	for _, listener := range listeners {
+	for i, listener := range listeners {
 		// we might have listened to /tcp/0 - let's see what we are listing on
 		fmt.Printf("RPC API server listening on %s\n", listener.Multiaddr())
+		authorization := apiListenerAuthorization(cfg, listener.Multiaddr())
+		switch authorization {
+		case oldcmds.ListenerAuthGlobal:
+			fmt.Printf("RPC API access on %s is limited by the rules defined in API.Authorizations\n", listener.Multiaddr())
+		case oldcmds.ListenerAuthListener:
+			fmt.Printf("RPC API access on %s is limited by the rules defined in API.Listeners\n", listener.Multiaddr())
+		}
+		addListener(cctx, oldcmds.ListenerInfo{
+			Server:          "api",
+			Address:         listener.Multiaddr().String(),
+			SocketActivated: i < activated,
+			Authorization:   authorization,
+		})
 		// Browsers require TCP.
 		switch listener.Addr().Network() {
 		case "tcp", "tcp4", "tcp6":
-			fmt.Printf("WebUI: http://%s/webui\n", listener.Addr())
+			if subsystem.Enabled(subsystem.WebUI) {
+				fmt.Printf("WebUI: http://%s/webui\n", listener.Addr())
+			}
 		}
 	}
 
@@ -707,6 +762,7 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 		corehttp.WebUIOption,
 		gatewayOpt,
 		corehttp.VersionOption(),
+		corehttp.HealthOption(),
 		defaultMux("/debug/vars"),
 		defaultMux("/debug/pprof/"),
 		defaultMux("/debug/stack"),
@@ -750,6 +806,40 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 	return errc, nil
 }
 
+// apiListenerAuthorization returns how the RPC API is authorized on the
+// listener listening on addr.
+func apiListenerAuthorization(cfg *config.Config, addr ma.Multiaddr) string {
+	if len(cfg.API.ListenerAuthorizations(addr)) == 0 {
+		return oldcmds.ListenerAuthNone
+	}
+	if l := cfg.API.Listener(addr); l != nil && len(l.Authorizations) > 0 {
+		return oldcmds.ListenerAuthListener
+	}
+	return oldcmds.ListenerAuthGlobal
+}
+
+// addListener records a listener for 'ipfs diag listeners'.
+func addListener(cctx *oldcmds.Context, info oldcmds.ListenerInfo) {
+	if cctx.Listeners != nil {
+		cctx.Listeners.Add(info)
+	}
+}
+
+// listen listens on maddr. A unix socket left behind by a daemon that did not
+// shut down cleanly is removed first, unless something still listens on it.
+func listen(maddr ma.Multiaddr) (manet.Listener, error) {
+	if path, err := maddr.ValueForProtocol(ma.P_UNIX); err == nil {
+		if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if conn, err := net.Dial("unix", path); err == nil {
+				conn.Close()
+			} else {
+				_ = os.Remove(path)
+			}
+		}
+	}
+	return manet.Listen(maddr)
+}
+
 func rewriteMaddrToUseLocalhostIfItsAny(maddr ma.Multiaddr) ma.Multiaddr {
 	first, rest := ma.SplitFirst(maddr)
 
@@ -806,6 +896,8 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 		return nil, fmt.Errorf("serveHTTPGateway: socket activation failed: %s", err)
 	}
 
+	activated := len(listeners)
+
 	listenerAddrs := make(map[string]bool, len(listeners))
 	for _, listener := range listeners {
 		listenerAddrs[string(listener.Multiaddr().Bytes())] = true
@@ -822,7 +914,7 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 			continue
 		}
 
-		gwLis, err := manet.Listen(gatewayMaddr)
+		gwLis, err := listen(gatewayMaddr)
 		if err != nil {
 			return nil, fmt.Errorf("serveHTTPGateway: manet.Listen(%s) failed: %s", gatewayMaddr, err)
 		}
@@ -831,8 +923,13 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 	}
 
 	// we might have listened to /tcp/0 - let's see what we are listing on
-	for _, listener := range listeners {
+	for i, listener := range listeners {
 		fmt.Printf("Gateway server listening on %s\n", listener.Multiaddr())
+		addListener(cctx, oldcmds.ListenerInfo{
+			Server:          "gateway",
+			Address:         listener.Multiaddr().String(),
+			SocketActivated: i < activated,
+		})
 	}
 
 	if cfg.Gateway.ExposeRoutingAPI.WithDefault(config.DefaultExposeRoutingAPI) {
@@ -849,6 +946,7 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 		corehttp.HostnameOption(),
 		corehttp.GatewayOption("/ipfs", "/ipns"),
 		corehttp.VersionOption(),
+		corehttp.HealthOption(),
 		corehttp.CheckVersionOption(),
 		// TODO[api-on-gw]: remove for 0.28.0: https://github.com/ipfs/kubo/issues/10312
 		corehttp.CommandsROOption(cmdctx),
@@ -862,6 +960,14 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 		opts = append(opts, corehttp.RoutingOption())
 	}
 
+	if cfg.Experimental.FullTextSearch {
+		opts = append(opts, corehttp.SearchOption())
+	}
+
+	if cfg.Experimental.Previews {
+		opts = append(opts, corehttp.PreviewOption())
+	}
+
 	if len(cfg.Gateway.RootRedirect) > 0 {
 		opts = append(opts, corehttp.RedirectOption("", cfg.Gateway.RootRedirect))
 	}
@@ -954,6 +1060,41 @@ func serveTrustlessGatewayOverLibp2p(cctx *oldcmds.Context) (<-chan error, error
 	return errc, nil
 }
 
+// serveRemoteAdmin serves the commands operators may call remotely over
+// libp2p, see RemoteAdmin in the config.
+func serveRemoteAdmin(cctx *oldcmds.Context) (<-chan error, error) {
+	node, err := cctx.ConstructNode()
+	if err != nil {
+		return nil, fmt.Errorf("serveRemoteAdmin: ConstructNode() failed: %s", err)
+	}
+	cfg, err := node.Repo.Config()
+	if err != nil {
+		return nil, fmt.Errorf("could not read config: %w", err)
+	}
+
+	if !cfg.RemoteAdmin.Enabled.WithDefault(config.DefaultRemoteAdminEnabled) || !node.IsOnline {
+		errCh := make(chan error)
+		close(errCh)
+		return errCh, nil
+	}
+	if len(cfg.RemoteAdmin.Operators) == 0 {
+		log.Error("RemoteAdmin.Enabled is set but RemoteAdmin.Operators is empty, nobody can administer this node")
+	}
+
+	lis, err := gostream.Listen(node.PeerHost, commands.RemoteAdminProtocol)
+	if err != nil {
+		return nil, fmt.Errorf("serveRemoteAdmin: listen failed: %w", err)
+	}
+	fmt.Printf("Remote admin listening on %s\n", commands.RemoteAdminProtocol)
+
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		errc <- corehttp.Serve(node, lis, corehttp.RemoteAdminOption(*cctx))
+	}()
+	return errc, nil
+}
+
 // collects options and opens the fuse mountpoint.
 func mountFuse(req *cmds.Request, cctx *oldcmds.Context) error {
 	cfg, err := cctx.GetConfig()
@@ -0,0 +1,131 @@
+package kubo
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	provider "github.com/ipfs/boxo/provider"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/node/libp2p"
+	"github.com/ipfs/kubo/repo/fsrepo"
+	"github.com/mitchellh/go-homedir"
+)
+
+// openRepoContexts opens the repos of Repos, and registers them as the
+// contexts of n. The contexts sharing the identity of n run offline, fetch
+// and publish with n, and n serves and reprovides their blocks.
+func openRepoContexts(ctx context.Context, root string, n *core.IpfsNode, repos map[string]config.RepoContext) (core.Contexts, error) {
+	contexts := make(core.Contexts, len(repos))
+	for name, rc := range repos {
+		c, err := openRepoContext(ctx, root, n, name, rc)
+		if err != nil {
+			closeRepoContexts(contexts)
+			return nil, fmt.Errorf("repo context %q: %w", name, err)
+		}
+		contexts[name] = c
+	}
+	for _, c := range contexts {
+		c.Node.Contexts = contexts
+	}
+	n.Contexts = contexts
+	return contexts, nil
+}
+
+func openRepoContext(ctx context.Context, root string, n *core.IpfsNode, name string, rc config.RepoContext) (*core.RepoContext, error) {
+	if name == "" {
+		return nil, fmt.Errorf("empty name")
+	}
+	identity := rc.Identity
+	if identity == "" {
+		identity = config.RepoIdentityShared
+	}
+	if identity != config.RepoIdentityShared && identity != config.RepoIdentityOwn {
+		return nil, fmt.Errorf("invalid identity %q, must be %q or %q", identity, config.RepoIdentityShared, config.RepoIdentityOwn)
+	}
+
+	path, err := homedir.Expand(rc.Path)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	if path == root {
+		return nil, fmt.Errorf("%s is the repo of the daemon", path)
+	}
+
+	r, err := fsrepo.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	ncfg := &core.BuildCfg{
+		Repo:      r,
+		Permanent: true,
+		Online:    identity == config.RepoIdentityOwn && n.IsOnline,
+	}
+	if ncfg.Online {
+		ncfg.Routing = libp2p.ConstructDefaultRouting(cfg, libp2p.DHTClientOption)
+	}
+	cn, err := core.NewNode(ctx, ncfg)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	cn.IsDaemon = true
+
+	c := &core.RepoContext{Name: name, Path: path, Node: cn}
+	if identity == config.RepoIdentityShared {
+		c.Host = n
+		if n.SharedContexts != nil {
+			keys, err := contextKeys(n, cn)
+			if err != nil {
+				cn.Close()
+				return nil, err
+			}
+			n.SharedContexts.Add(name, cn.Blockstore, keys)
+		}
+	}
+	return c, nil
+}
+
+// contextKeys returns the keys of cn reprovided by n, following the
+// Reprovider.Strategy of n.
+func contextKeys(n, cn *core.IpfsNode) (provider.KeyChanFunc, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	switch strategy := cfg.Reprovider.Strategy.WithDefault(config.DefaultReproviderStrategy); strategy {
+	case "all", "":
+		return provider.NewBlockstoreProvider(cn.Blockstore), nil
+	case "roots":
+		return provider.NewPinnedProvider(true, cn.Pinning, cn.OfflineIPLDFetcherFactory), nil
+	case "pinned":
+		return provider.NewPinnedProvider(false, cn.Pinning, cn.OfflineIPLDFetcherFactory), nil
+	default:
+		return nil, fmt.Errorf("unknown reprovider strategy %q", strategy)
+	}
+}
+
+// closeRepoContexts closes the nodes of the contexts.
+func closeRepoContexts(contexts core.Contexts) {
+	for name, c := range contexts {
+		if c.Host != nil && c.Host.SharedContexts != nil {
+			c.Host.SharedContexts.Remove(name)
+		}
+		if err := c.Node.Close(); err != nil {
+			log.Errorf("error closing repo context %q: %s", name, err)
+		}
+	}
+}
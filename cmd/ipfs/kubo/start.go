@@ -124,6 +124,7 @@ func BuildEnv(pl PluginPreloader) func(ctx context.Context, req *cmds.Request) (
 		return &oldcmds.Context{
 			ConfigRoot: repoPath,
 			ReqLog:     &oldcmds.ReqLog{},
+			Listeners:  &oldcmds.Listeners{},
 			Plugins:    plugins,
 			ConstructNode: func() (n *core.IpfsNode, err error) {
 				if req == nil {
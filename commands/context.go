@@ -24,6 +24,9 @@ type Context struct {
 	ConfigRoot string
 	ReqLog     *ReqLog
 
+	// Listeners are the HTTP listeners of the daemon.
+	Listeners *Listeners
+
 	Plugins *loader.PluginLoader
 
 	Gateway       bool
@@ -0,0 +1,47 @@
+package commands
+
+import "sync"
+
+// Kinds of authorization of the RPC API on a listener.
+const (
+	// ListenerAuthGlobal is the listeners using API.Authorizations.
+	ListenerAuthGlobal = "global"
+	// ListenerAuthListener is the listeners with their own authorizations.
+	ListenerAuthListener = "listener"
+	// ListenerAuthNone is the listeners exposing the RPC API to everyone.
+	ListenerAuthNone = "none"
+)
+
+// ListenerInfo describes an HTTP listener of the daemon.
+type ListenerInfo struct {
+	// Server is the server listening, "api" or "gateway".
+	Server string
+	// Address is the multiaddr listened on.
+	Address string
+	// SocketActivated is whether the listener was inherited from the
+	// service manager, e.g. systemd.
+	SocketActivated bool
+	// Authorization is how the RPC API is authorized on the listener, empty
+	// for the gateway.
+	Authorization string `json:",omitempty"`
+}
+
+// Listeners is the list of the HTTP listeners of the daemon.
+type Listeners struct {
+	lock      sync.Mutex
+	listeners []ListenerInfo
+}
+
+// Add adds a listener to the list.
+func (l *Listeners) Add(info ListenerInfo) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.listeners = append(l.listeners, info)
+}
+
+// Report returns a copy of the list.
+func (l *Listeners) Report() []ListenerInfo {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]ListenerInfo{}, l.listeners...)
+}
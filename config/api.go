@@ -3,6 +3,8 @@ package config
 import (
 	"encoding/base64"
 	"strings"
+
+	ma "github.com/multiformats/go-multiaddr"
 )
 
 const (
@@ -29,6 +31,62 @@ type API struct {
 	// If the map is empty, then the RPC API is exposed to everyone. Check the
 	// documentation for more details.
 	Authorizations map[string]*RPCAuthScope `json:",omitempty"`
+
+	// Listeners are the settings of the RPC API listeners, by the multiaddr
+	// they listen on, as in Addresses.API.
+	Listeners map[string]*APIListener `json:",omitempty"`
+
+	// RequestBudgets limit the memory and the goroutines a single RPC request
+	// may use, by the path of its command, e.g. "refs" or "dag/export". The
+	// budget of a command also applies to its subcommands that have none,
+	// and the budget of "" to every command.
+	RequestBudgets map[string]*APIRequestBudget `json:",omitempty"`
+}
+
+// APIRequestBudget contains the per request limits of a command over the RPC
+// API. Unset limits are disabled.
+type APIRequestBudget struct {
+	// MaxMemory is the memory a request may hold for its own bookkeeping,
+	// such as the set of CIDs already visited, e.g. "256MiB".
+	MaxMemory *OptionalString `json:",omitempty"`
+
+	// MaxGoroutines is the number of goroutines a request may run at once.
+	MaxGoroutines *OptionalInteger `json:",omitempty"`
+}
+
+// APIListener contains the settings of a single RPC API listener.
+type APIListener struct {
+	// Authorizations replace API.Authorizations on the listener.
+	Authorizations map[string]*RPCAuthScope `json:",omitempty"`
+
+	// NoAuthorization exposes the RPC API to everyone on the listener, e.g.
+	// on a unix socket only its owner can access.
+	NoAuthorization Flag `json:",omitempty"`
+}
+
+// ListenerAuthorizations returns the authorizations of the RPC API listener
+// listening on addr. An empty map exposes the RPC API to everyone.
+func (a API) ListenerAuthorizations(addr ma.Multiaddr) map[string]*RPCAuthScope {
+	if l := a.Listener(addr); l != nil {
+		if l.NoAuthorization.WithDefault(false) {
+			return nil
+		}
+		if len(l.Authorizations) > 0 {
+			return l.Authorizations
+		}
+	}
+	return a.Authorizations
+}
+
+// Listener returns the settings of the RPC API listener listening on addr, if
+// any.
+func (a API) Listener(addr ma.Multiaddr) *APIListener {
+	for s, l := range a.Listeners {
+		if maddr, err := ma.NewMultiaddr(s); err == nil && maddr.Equal(addr) {
+			return l
+		}
+	}
+	return nil
 }
 
 // ConvertAuthSecret converts the given secret in the format "type:value" into an
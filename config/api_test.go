@@ -3,6 +3,7 @@ package config
 import (
 	"testing"
 
+	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,3 +21,24 @@ func TestConvertAuthSecret(t *testing.T) {
 		assert.Equal(t, testCase.output, ConvertAuthSecret(testCase.input))
 	}
 }
+
+func TestListenerAuthorizations(t *testing.T) {
+	global := map[string]*RPCAuthScope{"admin": {AuthSecret: "a", AllowedPaths: []string{"/api/v0"}}}
+	own := map[string]*RPCAuthScope{"ci": {AuthSecret: "b", AllowedPaths: []string{"/api/v0/add"}}}
+	api := API{
+		Authorizations: global,
+		Listeners: map[string]*APIListener{
+			"/unix/run/ipfs.sock":    {NoAuthorization: True},
+			"/ip4/10.0.0.1/tcp/5001": {Authorizations: own},
+		},
+	}
+
+	tcp := ma.StringCast("/ip4/127.0.0.1/tcp/5001")
+	unix := ma.StringCast("/unix/run/ipfs.sock")
+	lan := ma.StringCast("/ip4/10.0.0.1/tcp/5001")
+
+	assert.Equal(t, global, api.ListenerAuthorizations(tcp))
+	assert.Empty(t, api.ListenerAuthorizations(unix))
+	assert.Equal(t, own, api.ListenerAuthorizations(lan))
+	assert.Nil(t, api.Listener(tcp))
+}
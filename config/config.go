@@ -36,6 +36,14 @@ type Config struct {
 	Experimental Experiments
 	Plugins      Plugins
 	Pinning      Pinning
+	Filestore    Filestore
+	RemoteAdmin  RemoteAdmin
+	Standby      Standby
+	Webhooks     Webhooks
+	MFS          MFS
+	DAGLimits    DAGLimits
+
+	Repos map[string]RepoContext `json:",omitempty"` // the repo contexts opened by the daemon, by name
 
 	Internal Internal // experimental/unstable options
 }
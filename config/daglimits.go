@@ -0,0 +1,15 @@
+package config
+
+// DAGLimits bounds the shape of the DAGs imported with 'ipfs dag import',
+// pinned, and fetched by the gateway, against the DAGs deep or wide enough to
+// exhaust the memory of the node. 0 is no limit.
+type DAGLimits struct {
+	// MaxDepth is the depth of the deepest node of a DAG, the root being at
+	// depth 0. It is not enforced by the gateway.
+	MaxDepth *OptionalInteger `json:",omitempty"`
+	// MaxLinks is the number of links of a node.
+	MaxLinks *OptionalInteger `json:",omitempty"`
+	// MaxNodes is the number of distinct nodes of a DAG. On the gateway, it
+	// is the number of blocks a request fetches from the network.
+	MaxNodes *OptionalInteger `json:",omitempty"`
+}
@@ -2,17 +2,47 @@ package config
 
 import (
 	"encoding/json"
+	"time"
 )
 
 // DefaultDataStoreDirectory is the directory to store all the local IPFS data.
 const DefaultDataStoreDirectory = "datastore"
 
+// DefaultGCConcurrency is the default number of shards of the blockstore swept
+// at the same time by a garbage collection.
+const DefaultGCConcurrency = 4
+
+const (
+	DefaultDatastoreForecastEnabled  = true
+	DefaultDatastoreForecastInterval = 10 * time.Minute
+	DefaultDatastoreForecastWindow   = 24 * time.Hour
+	DefaultDatastoreForecastWarning  = 7 * 24 * time.Hour
+	DefaultDatastoreForecastCritical = 24 * time.Hour
+
+	DefaultDatastoreRemoteCache   = true
+	DefaultDatastoreRemoteTimeout = 30 * time.Second
+
+	DefaultDatastoreWriteBackJournal    = "writeback"
+	DefaultDatastoreWriteBackWorkers    = 8
+	DefaultDatastoreWriteBackMaxPending = "256MiB"
+
+	DefaultDatastoreProvenanceEnabled    = false
+	DefaultDatastoreProvenanceSampling   = 1
+	DefaultDatastoreProvenanceMaxRecords = 100000
+)
+
 // Datastore tracks the configuration of the datastore.
 type Datastore struct {
 	StorageMax         string // in B, kB, kiB, MB, ...
 	StorageGCWatermark int64  // in percentage to multiply on StorageMax
 	GCPeriod           string // in ns, us, ms, s, m, h
 
+	// GCConcurrency is the number of shards of the blockstore listed and
+	// swept at the same time by a garbage collection, when the blocks are
+	// stored in a sharded datastore like flatfs. Otherwise it is the number
+	// of blocks deleted at the same time.
+	GCConcurrency *OptionalInteger `json:",omitempty"`
+
 	// deprecated fields, use Spec
 	Type   string           `json:",omitempty"`
 	Path   string           `json:",omitempty"`
@@ -23,6 +53,96 @@ type Datastore struct {
 
 	HashOnRead      bool
 	BloomFilterSize int
+
+	Compression DatastoreCompression
+
+	Forecast DatastoreForecast
+
+	Remote DatastoreRemote
+
+	WriteBack DatastoreWriteBack
+
+	Provenance DatastoreProvenance
+}
+
+// DatastoreForecast configures the forecast of when the repo reaches
+// StorageMax, from its growth rate.
+type DatastoreForecast struct {
+	Enabled Flag `json:",omitempty"`
+
+	// Interval is the time between two samples of the size of the repo.
+	Interval *OptionalDuration `json:",omitempty"`
+
+	// Window is the period the growth rate is computed over.
+	Window *OptionalDuration `json:",omitempty"`
+
+	// WarningPeriod and CriticalPeriod are how long before the repo is
+	// forecast to reach StorageMax the alerts are raised.
+	WarningPeriod  *OptionalDuration `json:",omitempty"`
+	CriticalPeriod *OptionalDuration `json:",omitempty"`
+
+	// Webhook is the URL the alerts are posted to, as JSON.
+	Webhook *OptionalString `json:",omitempty"`
+}
+
+// DatastoreCompression configures transparent compression of blocks at rest.
+type DatastoreCompression struct {
+	// Enabled turns on compression of newly written blocks. Blocks already
+	// stored remain readable regardless of this setting.
+	Enabled Flag `json:",omitempty"`
+
+	// Level is the zstd encoder level: fastest, default, better or best.
+	Level *OptionalString `json:",omitempty"`
+
+	// MinBlockSize maps a codec name to the smallest block that gets
+	// compressed, "default" applies to codecs not listed. A negative value
+	// disables compression for the codec.
+	MinBlockSize map[string]int64 `json:",omitempty"`
+}
+
+// DatastoreRemote configures the remote blockstores the blocks missing from
+// the repo are read from, to run stateless frontends of a shared storage tier.
+type DatastoreRemote struct {
+	// Endpoints are the URLs of the trustless gateways serving the blocks,
+	// tried in turn. Remote reads are disabled when empty.
+	Endpoints []string `json:",omitempty"`
+
+	// Cache stores the blocks read from the endpoints in the repo.
+	Cache Flag `json:",omitempty"`
+
+	// Timeout bounds each read from the endpoints.
+	Timeout *OptionalDuration `json:",omitempty"`
+}
+
+// DatastoreWriteBack configures the write-back journal, which acknowledges
+// the blocks written once journaled locally, and flushes them to the datastore
+// in the background.
+type DatastoreWriteBack struct {
+	Enabled Flag `json:",omitempty"`
+
+	// Journal is the directory of the journal, relative to the repo when not
+	// absolute. It should be on a fast local disk.
+	Journal *OptionalString `json:",omitempty"`
+
+	// Workers is the number of blocks flushed at the same time.
+	Workers *OptionalInteger `json:",omitempty"`
+
+	// MaxPending bounds the size of the blocks waiting to be flushed, in B,
+	// kB, kiB, MB, ... The writes wait when it is reached.
+	MaxPending *OptionalString `json:",omitempty"`
+}
+
+// DatastoreProvenance configures the recording of the peers the blocks
+// received by Bitswap come from.
+type DatastoreProvenance struct {
+	Enabled Flag `json:",omitempty"`
+
+	// Sampling records one in Sampling of the blocks received.
+	Sampling *OptionalInteger `json:",omitempty"`
+
+	// MaxRecords bounds the number of records kept, the oldest records are
+	// replaced first.
+	MaxRecords *OptionalInteger `json:",omitempty"`
 }
 
 // DataStorePath returns the default data store path given a configuration root
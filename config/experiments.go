@@ -10,6 +10,23 @@ type Experiments struct {
 	OptimisticProvide             bool
 	OptimisticProvideJobsPoolSize int
 	GatewayOverLibp2p             bool `json:",omitempty"`
+	ContentIndex                  bool `json:",omitempty"`
+	FullTextSearch                bool `json:",omitempty"`
+	Previews                      bool `json:",omitempty"`
+	PinsetReconciliation          bool `json:",omitempty"`
+	PinReplication                bool `json:",omitempty"`
+	ReadOnlyMirror                bool `json:",omitempty"`
+	// HTTPProviderURLs are the URLs of the trustless gateways serving the
+	// content of the node, announced with its provider records.
+	HTTPProviderURLs []string `json:",omitempty"`
+	// HTTPProviderRetrieval fetches the blocks Bitswap doesn't find from the
+	// trustless gateways announced by their providers.
+	HTTPProviderRetrieval bool `json:",omitempty"`
+	// KVStore replicates the key-value stores of `ipfs kv` over pubsub.
+	KVStore bool `json:",omitempty"`
+	// FastCDCChunker allows the fastcdc chunker, whose DAGs other
+	// implementations don't reproduce.
+	FastCDCChunker bool `json:",omitempty"`
 
 	GraphsyncEnabled     graphsyncEnabled                 `json:",omitempty"`
 	AcceleratedDHTClient experimentalAcceleratedDHTClient `json:",omitempty"`
@@ -0,0 +1,24 @@
+package config
+
+import "time"
+
+const (
+	DefaultFilestoreCheckInterval = 10 * time.Second
+	DefaultFilestoreCheckTimeout  = 5 * time.Second
+)
+
+// Filestore configures the sources of the filestore, the files added with
+// --nocopy.
+type Filestore struct {
+	// Mounts are the directories the sources are read from which can become
+	// unavailable, such as the mount points of network shares. The reads of
+	// the sources under an unavailable mount fail at once instead of hanging.
+	Mounts []string `json:",omitempty"`
+
+	// CheckInterval is how often the mounts are checked.
+	CheckInterval *OptionalDuration `json:",omitempty"`
+
+	// CheckTimeout is how long a check of a mount can take before the mount
+	// is reported unavailable.
+	CheckTimeout *OptionalDuration `json:",omitempty"`
+}
@@ -1,10 +1,15 @@
 package config
 
+import "time"
+
 const (
 	DefaultInlineDNSLink         = false
 	DefaultDeserializedResponses = true
 	DefaultDisableHTMLErrors     = false
 	DefaultExposeRoutingAPI      = false
+
+	DefaultGatewayFallbackTimeout = 5 * time.Second
+	DefaultGatewayMediaPrefetch   = true
 )
 
 type GatewaySpec struct {
@@ -73,4 +78,65 @@ type Gateway struct {
 	// ExposeRoutingAPI configures the gateway port to expose
 	// routing system as HTTP API at /routing/v1 (https://specs.ipfs.tech/routing/http-routing-v1/).
 	ExposeRoutingAPI Flag
+
+	// FetchBudget limits how much a single request may fetch from the
+	// network, and for how long it may run.
+	FetchBudget GatewayFetchBudget
+
+	// SurrogateKeys tags the responses with the Surrogate-Key and Cache-Tag
+	// headers of CDNs: the root CID, the CID of the path and the name of
+	// mutable paths.
+	SurrogateKeys Flag `json:",omitempty"`
+
+	// Fallback fetches the blocks the node doesn't find in time from
+	// upstream trustless gateways.
+	Fallback GatewayFallback
+
+	// MediaPrefetch prefetches the header regions of the files requested
+	// with an Accept header asking for video or audio.
+	MediaPrefetch Flag `json:",omitempty"`
+
+	// Templates replaces the HTML directory listings and error pages of the
+	// gateway with custom templates.
+	Templates GatewayTemplates
+}
+
+// GatewayTemplates configures the templates of the HTML pages of the gateway,
+// loaded at startup. The paths are relative to the repo when not absolute.
+type GatewayTemplates struct {
+	// DirectoryListing is the html/template file of the directory listings.
+	DirectoryListing *OptionalString `json:",omitempty"`
+
+	// Error is the html/template file of the error pages.
+	Error *OptionalString `json:",omitempty"`
+
+	// Assets is a directory of static files, such as stylesheets and logos,
+	// served at /ipfs-gateway-assets/.
+	Assets *OptionalString `json:",omitempty"`
+}
+
+// GatewayFetchBudget contains the per request limits of the gateway. Unset
+// limits are disabled.
+type GatewayFetchBudget struct {
+	// MaxBlocks is the number of blocks a request may fetch from the network.
+	MaxBlocks *OptionalInteger `json:",omitempty"`
+
+	// MaxBytes is the amount of data a request may fetch from the network,
+	// e.g. "100MiB".
+	MaxBytes *OptionalString `json:",omitempty"`
+
+	// MaxDuration is the time a request may take.
+	MaxDuration *OptionalDuration `json:",omitempty"`
+}
+
+// GatewayFallback configures the upstream trustless gateways the gateway
+// falls back to.
+type GatewayFallback struct {
+	// Upstreams are the URLs of the trustless gateways, tried in turn.
+	// The fallback is disabled when empty.
+	Upstreams []string `json:",omitempty"`
+
+	// Timeout is how long the node looks for a block before it is fetched
+	// from the upstreams.
+	Timeout *OptionalDuration `json:",omitempty"`
 }
@@ -6,6 +6,8 @@ type Internal struct {
 	UnixFSShardingSizeThreshold *OptionalString   `json:",omitempty"`
 	Libp2pForceReachability     *OptionalString   `json:",omitempty"`
 	BackupBootstrapInterval     *OptionalDuration `json:",omitempty"`
+	Hashing                     *InternalHashing  `json:",omitempty"`
+	ShutdownTimeout             *OptionalDuration `json:",omitempty"`
 }
 
 type InternalBitswap struct {
@@ -14,4 +16,25 @@ type InternalBitswap struct {
 	EngineTaskWorkerCount       OptionalInteger
 	MaxOutstandingBytesPerPeer  OptionalInteger
 	ProviderSearchDelay         OptionalDuration
+	// FairScheduling serves the peers with pathological request patterns
+	// after the well-behaved ones.
+	FairScheduling *BitswapFairScheduling `json:",omitempty"`
+}
+
+// BitswapFairScheduling configures the fair scheduling of the tasks of the
+// Bitswap server.
+type BitswapFairScheduling struct {
+	Enabled Flag `json:",omitempty"`
+	// MaxWantlist is the number of wantlist entries over which a peer is
+	// deprioritized.
+	MaxWantlist *OptionalInteger `json:",omitempty"`
+	// MinAckRate is the percentage of the blocks sent to a peer that it must
+	// cancel rather than want again, under which it is deprioritized.
+	MinAckRate *OptionalInteger `json:",omitempty"`
+}
+
+type InternalHashing struct {
+	SHA256Implementation OptionalString
+	Concurrency          OptionalInteger
+	AddReadAhead         OptionalInteger
 }
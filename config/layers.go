@@ -0,0 +1,240 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// OverridesDir is the directory next to the config file holding JSON
+	// files that override parts of the config. They are applied in the
+	// lexical order of their names.
+	OverridesDir = "config.d"
+
+	// EnvOverridePrefix is the prefix of the environment variables that
+	// override a single config key, e.g. IPFS_CONFIG_Swarm_ConnMgr_HighWater.
+	// Key segments are separated by "_" and matched case-insensitively. The
+	// value is parsed as JSON, or used as a string when it is not valid JSON.
+	EnvOverridePrefix = "IPFS_CONFIG_"
+
+	// SourceFile is the source of the values read from the config file.
+	SourceFile = "config"
+)
+
+// Layer is one source of config values.
+type Layer struct {
+	// Source names the layer, e.g. "config", "config.d/10-fleet.json" or
+	// "env:IPFS_CONFIG_Swarm_ConnMgr_HighWater".
+	Source string
+	Values map[string]interface{}
+}
+
+// Layers are sources of config values, from lowest to highest precedence.
+type Layers []Layer
+
+// Merge deep merges the layers. Objects are merged key by key, any other
+// value of a later layer replaces the value of an earlier one. It also returns
+// the source of each value, by key.
+func (ls Layers) Merge() (map[string]interface{}, map[string]string) {
+	merged := make(map[string]interface{})
+	sources := make(map[string]string)
+	for _, l := range ls {
+		mergeLayer(merged, l.Values, "", l.Source, sources)
+	}
+	return merged, sources
+}
+
+func mergeLayer(dst, src map[string]interface{}, prefix, source string, sources map[string]string) {
+	for k, v := range src {
+		path := joinPath(prefix, k)
+		if vm, ok := v.(map[string]interface{}); ok {
+			dm, ok := dst[k].(map[string]interface{})
+			if !ok {
+				clearSources(sources, path)
+				dm = make(map[string]interface{})
+				dst[k] = dm
+			}
+			if len(vm) == 0 && !ok {
+				sources[path] = source
+			}
+			mergeLayer(dm, vm, path, source, sources)
+			continue
+		}
+		clearSources(sources, path)
+		dst[k] = v
+		sources[path] = source
+	}
+}
+
+func clearSources(sources map[string]string, path string) {
+	for k := range sources {
+		if k == path || strings.HasPrefix(k, path+".") {
+			delete(sources, k)
+		}
+	}
+}
+
+// Source returns the effective value of a key, e.g. "Swarm.ConnMgr", and the
+// sources it was merged from.
+func (ls Layers) Source(key string) (interface{}, []string, error) {
+	merged, sources := ls.Merge()
+
+	var cursor interface{} = merged
+	for _, part := range strings.Split(key, ".") {
+		m, ok := cursor.(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("%s not found", key)
+		}
+		if cursor, ok = m[part]; !ok {
+			return nil, nil, fmt.Errorf("%s not found", key)
+		}
+	}
+
+	found := make(map[string]bool)
+	for path, source := range sources {
+		if path == key || strings.HasPrefix(path, key+".") {
+			found[source] = true
+		}
+	}
+	var srcs []string
+	for _, l := range ls {
+		if found[l.Source] {
+			srcs = append(srcs, l.Source)
+			delete(found, l.Source)
+		}
+	}
+	return cursor, srcs, nil
+}
+
+// RemoveOverrides removes from m, a config being written back to the config
+// file, the values that come from the other layers and were not changed.
+// This keeps overrides from being persisted in the config file.
+func (ls Layers) RemoveOverrides(m map[string]interface{}) {
+	merged, sources := ls.Merge()
+	paths := make([]string, 0, len(sources))
+	for path, source := range sources {
+		if source != SourceFile {
+			paths = append(paths, path)
+		}
+	}
+	// remove the deepest keys first
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	for _, path := range paths {
+		parts := strings.Split(path, ".")
+		effective, ok := lookupPath(merged, parts)
+		if !ok {
+			continue
+		}
+		parent, ok := lookupPath(m, parts[:len(parts)-1])
+		if !ok {
+			continue
+		}
+		pm, ok := parent.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if v, ok := pm[parts[len(parts)-1]]; ok && jsonEqual(v, effective) {
+			delete(pm, parts[len(parts)-1])
+		}
+	}
+}
+
+func lookupPath(m map[string]interface{}, parts []string) (interface{}, bool) {
+	var cursor interface{} = m
+	for _, part := range parts {
+		cm, ok := cursor.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cursor, ok = cm[part]; !ok {
+			return nil, false
+		}
+	}
+	return cursor, true
+}
+
+func jsonEqual(a, b interface{}) bool {
+	ab, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(ab) == string(bb)
+}
+
+// EnvLayers returns a layer for each IPFS_CONFIG_* variable in environ, in
+// the "KEY=value" format of os.Environ, sorted by variable name.
+func EnvLayers(environ []string) (Layers, error) {
+	var ls Layers
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, EnvOverridePrefix) {
+			continue
+		}
+		key, err := envKey(strings.TrimPrefix(name, EnvOverridePrefix))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		var v interface{}
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			v = value
+		}
+		values := map[string]interface{}{}
+		cursor := values
+		for _, part := range key[:len(key)-1] {
+			next := map[string]interface{}{}
+			cursor[part] = next
+			cursor = next
+		}
+		cursor[key[len(key)-1]] = v
+
+		ls = append(ls, Layer{Source: "env:" + name, Values: values})
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i].Source < ls[j].Source })
+	return ls, nil
+}
+
+// envKey resolves the "_" separated key of an environment variable to the
+// names used in the config schema.
+func envKey(suffix string) ([]string, error) {
+	parts := strings.Split(suffix, "_")
+	key := make([]string, 0, len(parts))
+	t := reflect.TypeOf(Config{})
+	for i, part := range parts {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if part == "" {
+			return nil, fmt.Errorf("empty key segment")
+		}
+		switch {
+		case reflect.PointerTo(t).Implements(unmarshalerType):
+			return nil, fmt.Errorf("%s cannot be overridden partially", strings.Join(key, "."))
+		case t.Kind() == reflect.Struct:
+			fields := jsonFields(t)
+			name, f, ok := lookupField(fields, part)
+			if !ok {
+				// unknown key, keep it as is for Validate to report
+				return append(key, strings.Join(parts[i:], "_")), nil
+			}
+			part = name
+			t = f.Type
+		case t.Kind() == reflect.Map:
+			t = t.Elem()
+		case t.Kind() == reflect.Interface:
+			// free-form value, keep the rest of the key as is
+			return append(key, parts[i:]...), nil
+		default:
+			return nil, fmt.Errorf("%s cannot be overridden partially", strings.Join(key, "."))
+		}
+		key = append(key, part)
+	}
+	return key, nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLayersMerge(t *testing.T) {
+	env, err := EnvLayers([]string{
+		"IPFS_CONFIG_SWARM_CONNMGR_HIGHWATER=300",
+		"IPFS_CONFIG_Gateway_RootRedirect=/ipfs/x",
+		"PATH=/bin",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := append(Layers{
+		{Source: SourceFile, Values: map[string]interface{}{
+			"Swarm": map[string]interface{}{
+				"ConnMgr": map[string]interface{}{"LowWater": 10.0, "HighWater": 100.0},
+			},
+			"Gateway": map[string]interface{}{"NoFetch": true},
+		}},
+		{Source: "config.d/10-fleet.json", Values: map[string]interface{}{
+			"Swarm": map[string]interface{}{
+				"ConnMgr": map[string]interface{}{"HighWater": 200.0},
+			},
+		}},
+	}, env...)
+
+	for _, tc := range []struct {
+		key     string
+		value   interface{}
+		sources []string
+	}{
+		{"Swarm.ConnMgr.LowWater", 10.0, []string{SourceFile}},
+		{"Swarm.ConnMgr.HighWater", 300.0, []string{"env:IPFS_CONFIG_SWARM_CONNMGR_HIGHWATER"}},
+		{"Swarm.ConnMgr", nil, []string{SourceFile, "env:IPFS_CONFIG_SWARM_CONNMGR_HIGHWATER"}},
+		{"Gateway.RootRedirect", "/ipfs/x", []string{"env:IPFS_CONFIG_Gateway_RootRedirect"}},
+		{"Gateway.NoFetch", true, []string{SourceFile}},
+	} {
+		v, sources, err := layers.Source(tc.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.value != nil && v != tc.value {
+			t.Errorf("%s: expected %v, got %v", tc.key, tc.value, v)
+		}
+		if !reflect.DeepEqual(sources, tc.sources) {
+			t.Errorf("%s: expected sources %v, got %v", tc.key, tc.sources, sources)
+		}
+	}
+
+	// writing the effective config back must not persist the overrides
+	m, _ := layers.Merge()
+	m["Gateway"].(map[string]interface{})["NoFetch"] = false
+	layers.RemoveOverrides(m)
+	if _, ok := m["Swarm"].(map[string]interface{})["ConnMgr"].(map[string]interface{})["HighWater"]; ok {
+		t.Error("override persisted")
+	}
+	if _, ok := m["Gateway"].(map[string]interface{})["RootRedirect"]; ok {
+		t.Error("override persisted")
+	}
+	if m["Gateway"].(map[string]interface{})["NoFetch"] != false {
+		t.Error("change to the config file lost")
+	}
+}
+
+func TestEnvLayersUnknownKey(t *testing.T) {
+	ls, err := EnvLayers([]string{"IPFS_CONFIG_Swarm_ConnMgr_HighWaterr=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Validate(ls[0].Values)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || errs[0].Suggestion != "HighWater" {
+		t.Fatalf("expected unknown key error with suggestion, got %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+package config
+
+// MFS configures how the changes made with 'ipfs files' are flushed to the
+// DAG.
+type MFS struct {
+	// AutoFlushInterval batches the flushes: the directories changed by the
+	// commands run with --flush are rewritten once per interval, instead of
+	// once per command. 0 flushes after every command.
+	AutoFlushInterval *OptionalDuration `json:",omitempty"`
+
+	// ManualFlush only flushes the changes on 'ipfs files flush', and when
+	// the node shuts down.
+	ManualFlush Flag `json:",omitempty"`
+}
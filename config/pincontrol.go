@@ -0,0 +1,25 @@
+package config
+
+import "time"
+
+const (
+	DefaultPinControlTopic  = "ipfs-pin-control"
+	DefaultPinControlMaxAge = 10 * time.Minute
+)
+
+// PinControl configures the pins and unpins ordered over pubsub, see
+// `ipfs pin control`.
+type PinControl struct {
+	// Enabled applies the orders received on Topic. It requires pubsub.
+	Enabled Flag `json:",omitempty"`
+
+	// Topic is the pubsub topic the orders are published on.
+	Topic *OptionalString `json:",omitempty"`
+
+	// AllowedKeys are the keys, as peer IDs, allowed to sign orders.
+	AllowedKeys []string
+
+	// MaxAge is how old an order may be when it is received. Older orders
+	// are ignored, so that they can't be replayed.
+	MaxAge *OptionalDuration `json:",omitempty"`
+}
@@ -0,0 +1,27 @@
+package config
+
+import "time"
+
+const (
+	DefaultPinQueueConcurrency    = 4
+	DefaultPinQueueMaxAttempts    = 10
+	DefaultPinQueueRetryBackoff   = time.Minute
+	DefaultPinQueueMaxBackoff     = 6 * time.Hour
+	DefaultPinQueueAttemptTimeout = time.Hour
+)
+
+// PinQueue configures the queue of background pins, see `ipfs pin add
+// --background`.
+type PinQueue struct {
+	// Concurrency is the number of pins processed at the same time.
+	Concurrency *OptionalInteger `json:",omitempty"`
+	// MaxAttempts is the number of attempts before a pin fails, 0 for no
+	// limit.
+	MaxAttempts *OptionalInteger `json:",omitempty"`
+	// RetryBackoff is the delay before the first retry, doubled with each
+	// failed attempt up to MaxBackoff.
+	RetryBackoff *OptionalDuration `json:",omitempty"`
+	MaxBackoff   *OptionalDuration `json:",omitempty"`
+	// AttemptTimeout bounds a single attempt.
+	AttemptTimeout *OptionalDuration `json:",omitempty"`
+}
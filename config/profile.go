@@ -188,6 +188,18 @@ fetching may be degraded.
 			return nil
 		},
 	},
+	"edge": {
+		Description: `Configures the node for the small footprint edge binaries,
+built with the nofuse, nodht and nowebui tags. The node does not serve the
+DHT or relay other peers.
+`,
+		Transform: func(c *Config) error {
+			c.Routing.Type = NewOptionalString("autoclient")
+			c.AutoNAT.ServiceMode = AutoNATServiceDisabled
+			c.Swarm.RelayService.Enabled = False
+			return nil
+		},
+	},
 	"randomports": {
 		Description: `Use a random port number for swarm.`,
 
@@ -0,0 +1,13 @@
+package config
+
+const DefaultRemoteAdminEnabled = false
+
+// RemoteAdmin configures the administration of the node by operators over
+// libp2p.
+type RemoteAdmin struct {
+	// Enabled serves the remote administration protocol.
+	Enabled Flag `json:",omitempty"`
+
+	// Operators are the peer IDs allowed to administer the node.
+	Operators []string
+}
@@ -7,6 +7,8 @@ var (
 
 type Pinning struct {
 	RemoteServices map[string]RemotePinningService
+	Queue          PinQueue
+	Control        PinControl
 }
 
 type RemotePinningService struct {
@@ -0,0 +1,20 @@
+package config
+
+// Repo contexts identities, see RepoContext.Identity.
+const (
+	RepoIdentityShared = "shared"
+	RepoIdentityOwn    = "own"
+)
+
+// RepoContext is a repo the daemon opens next to its own one, with its own
+// blockstore, pins and keys, selected by name with the --context option.
+type RepoContext struct {
+	// Path is the path of the repo, relative to the repo of the daemon when
+	// not absolute. The repo must be initialized with 'ipfs init'.
+	Path string
+
+	// Identity is "shared" to fetch and publish with the libp2p identity of
+	// the daemon, or "own" to run a libp2p host with the identity and the
+	// swarm addresses of the repo. Defaults to "shared".
+	Identity string `json:",omitempty"`
+}
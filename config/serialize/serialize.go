@@ -11,8 +11,11 @@ import (
 	"github.com/ipfs/kubo/config"
 
 	"github.com/facebookgo/atomicfile"
+	logging "github.com/ipfs/go-log"
 )
 
+var log = logging.Logger("config")
+
 // ErrNotInitialized is returned when we fail to read the config because the
 // repo doesn't exist.
 var ErrNotInitialized = errors.New("ipfs not initialized, please run 'ipfs init'")
@@ -60,13 +63,29 @@ func encode(w io.Writer, value interface{}) error {
 	return err
 }
 
-// Load reads given file and returns the read config, or error.
+// Load reads given file and returns the read config, or error. The config is
+// validated against the schema, see config.Validate: unknown keys are logged,
+// values of the wrong type are errors.
 func Load(filename string) (*config.Config, error) {
-	var cfg config.Config
-	err := ReadConfigFile(filename, &cfg)
+	buf, err := os.ReadFile(filename)
 	if err != nil {
+		if os.IsNotExist(err) {
+			err = ErrNotInitialized
+		}
 		return nil, err
 	}
 
-	return &cfg, err
+	unknown, err := config.SplitUnknownKeys(config.ValidateJSON(buf))
+	for _, e := range unknown {
+		log.Warnf("%s: ignoring config key %s", filename, e)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	var cfg config.Config
+	if err := json.Unmarshal(buf, &cfg); err != nil {
+		return nil, fmt.Errorf("failure to decode config: %w", err)
+	}
+	return &cfg, nil
 }
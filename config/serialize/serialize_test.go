@@ -2,6 +2,7 @@ package fsrepo
 
 import (
 	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 
@@ -9,7 +10,7 @@ import (
 )
 
 func TestConfig(t *testing.T) {
-	const filename = ".ipfsconfig"
+	filename := filepath.Join(t.TempDir(), ".ipfsconfig")
 	cfgWritten := new(config.Config)
 	cfgWritten.Identity.PeerID = "faketest"
 
@@ -0,0 +1,20 @@
+package config
+
+import "time"
+
+const DefaultStandbyInterval = time.Minute
+
+// Standby configures warm standby pairs: a standby node mirrors the pinset,
+// the MFS root and the keys of its primary node, and can be promoted to
+// replace it, see `ipfs standby`.
+type Standby struct {
+	// Primary is the peer ID of the node mirrored, on the standby node.
+	Primary string `json:",omitempty"`
+
+	// Standbys are the peer IDs allowed to mirror the node, on the primary
+	// node. Mirroring hands the keys of the keystore over to them.
+	Standbys []string `json:",omitempty"`
+
+	// Interval is how often the standby node mirrors the primary node.
+	Interval *OptionalDuration `json:",omitempty"`
+}
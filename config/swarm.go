@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 type SwarmConfig struct {
 	// AddrFilters specifies a set libp2p addresses that we should never
 	// dial or receive connections from.
@@ -32,6 +34,114 @@ type SwarmConfig struct {
 
 	// ResourceMgr configures the libp2p Network Resource Manager
 	ResourceMgr ResourceMgr
+
+	// DialPolicy selects the address family of the addresses of a peer
+	// dialed first: default, prefer-ipv6, happy-eyeballs or ipv6-only.
+	DialPolicy *OptionalString `json:",omitempty"`
+
+	// DialFamilyDelay is how long IPv4 addresses are delayed after the IPv6
+	// ones, with the prefer-ipv6 and happy-eyeballs policies.
+	DialFamilyDelay *OptionalDuration `json:",omitempty"`
+
+	// Proxy routes the outbound TCP and WebSocket connections through a
+	// SOCKS5 or HTTP proxy.
+	Proxy SwarmProxy
+
+	// Bandwidth caps the bandwidth of the streams of the node.
+	Bandwidth SwarmBandwidth
+
+	// Peerstore configures the store of the addresses and protocols of the
+	// peers.
+	Peerstore SwarmPeerstore
+
+	// PeerPolicies deprioritize or refuse the peers by peer ID or agent
+	// version, the first policy matching a peer applies.
+	PeerPolicies []PeerPolicy `json:",omitempty"`
+}
+
+const (
+	PeerPolicyDeprioritize = "deprioritize"
+	PeerPolicyRefuse       = "refuse"
+)
+
+// PeerPolicy matches the peers of Peers, and the peers whose agent version
+// matches the regular expression AgentVersion.
+type PeerPolicy struct {
+	// Name identifies the policy in 'ipfs swarm policy'.
+	Name string
+
+	AgentVersion string   `json:",omitempty"`
+	Peers        []string `json:",omitempty"`
+
+	// Action is "deprioritize", to trim the connections of the peers
+	// first, or "refuse", to close them and refuse the next ones.
+	Action string
+}
+
+const (
+	DialPolicyDefault       = "default"
+	DialPolicyPreferIPv6    = "prefer-ipv6"
+	DialPolicyHappyEyeballs = "happy-eyeballs"
+	DialPolicyIPv6Only      = "ipv6-only"
+
+	DefaultDialFamilyDelay = 250 * time.Millisecond
+
+	DefaultPeerstoreAddressTTL = 24 * time.Hour
+	DefaultPeerstoreGCInterval = time.Hour
+)
+
+// SwarmPeerstore configures the store of the addresses and protocols of the
+// peers.
+type SwarmPeerstore struct {
+	// Persist stores the addresses, protocols and metadata of the peers in
+	// the datastore of the repo, so that the node reconnects to them faster
+	// after a restart. Defaults to off, the peerstore is then in memory.
+	Persist Flag `json:",omitempty"`
+
+	// AddressTTL is how long the addresses of the peers connected when the
+	// node stops are kept, when persisted.
+	AddressTTL *OptionalDuration `json:",omitempty"`
+
+	// GCInterval is the interval between two purges of the expired
+	// addresses from the datastore.
+	GCInterval *OptionalDuration `json:",omitempty"`
+}
+
+// SwarmProxy configures the proxy the outbound connections are dialed through.
+type SwarmProxy struct {
+	// URL is the proxy, socks5://[user:password@]host:port or
+	// http://host:port. Connections are dialed directly when unset.
+	URL *OptionalString `json:",omitempty"`
+
+	// TCP and Websocket select the transports dialed through the proxy,
+	// both default to on when URL is set.
+	TCP       Flag `json:",omitempty"`
+	Websocket Flag `json:",omitempty"`
+
+	// Onion dials /onion3 addresses through the proxy, which must then be
+	// the SOCKS5 port of Tor. Defaults to off.
+	Onion Flag `json:",omitempty"`
+}
+
+// SwarmBandwidth configures the bandwidth caps of the node. The rates are in
+// bytes per second, as a number or with a unit ("1MB"), unlimited when unset.
+type SwarmBandwidth struct {
+	// RateIn and RateOut cap all the streams together.
+	RateIn  *OptionalString `json:",omitempty"`
+	RateOut *OptionalString `json:",omitempty"`
+
+	// PeerRateIn and PeerRateOut cap the streams of each peer.
+	PeerRateIn  *OptionalString `json:",omitempty"`
+	PeerRateOut *OptionalString `json:",omitempty"`
+
+	// Protocols caps the streams of each protocol, by protocol ID.
+	Protocols map[string]BandwidthRate `json:",omitempty"`
+}
+
+// BandwidthRate caps the streams of a protocol.
+type BandwidthRate struct {
+	RateIn  *OptionalString `json:",omitempty"`
+	RateOut *OptionalString `json:",omitempty"`
 }
 
 type RelayClient struct {
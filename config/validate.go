@@ -0,0 +1,326 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrUnknownKey is wrapped by the ValidationError of a key that does not
+	// exist in the config schema.
+	ErrUnknownKey = errors.New("unknown key")
+	// ErrInvalidValue is wrapped by the ValidationError of a value that does
+	// not match the type of its key.
+	ErrInvalidValue = errors.New("invalid value")
+)
+
+// ValidationError locates a config key that does not match the schema.
+type ValidationError struct {
+	// Path is the location of the key, e.g. "Swarm.ConnMgr.HighWater" or
+	// "Peering.Peers[0].ID".
+	Path string
+	// Err is ErrUnknownKey or ErrInvalidValue.
+	Err error
+	// Detail explains why a value is invalid.
+	Detail string
+	// Suggestion is the closest known key, for unknown keys.
+	Suggestion string
+}
+
+func (e *ValidationError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Path, e.Err)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", e.Suggestion)
+	}
+	return msg
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors are all the problems found in a config.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+// Is makes errors.Is match any of the validation errors.
+func (e ValidationErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitUnknownKeys separates the unknown keys reported by Validate from the
+// other errors. Unknown keys are usually options that were removed or
+// misspelled: they are ignored when the config is decoded, so callers
+// typically warn about them and only fail on the returned error, which is nil
+// when every problem was an unknown key.
+func SplitUnknownKeys(err error) (ValidationErrors, error) {
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil, err
+	}
+	var unknown, rest ValidationErrors
+	for _, e := range errs {
+		if errors.Is(e, ErrUnknownKey) {
+			unknown = append(unknown, e)
+		} else {
+			rest = append(rest, e)
+		}
+	}
+	if len(rest) == 0 {
+		return unknown, nil
+	}
+	return unknown, rest
+}
+
+// Validate checks a config decoded into a generic map against the Config
+// schema. Unlike decoding into Config, which silently ignores unknown keys,
+// it reports every unknown key and every value of the wrong type. The
+// returned error is a ValidationErrors, or nil.
+func Validate(v map[string]interface{}) error {
+	var errs ValidationErrors
+	validateValue(&errs, "", reflect.TypeOf(Config{}), v)
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	return errs
+}
+
+// ValidateJSON is like Validate for a serialized config.
+func ValidateJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v map[string]interface{}
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("failure to decode config: %w", err)
+	}
+	return Validate(v)
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+func validateValue(errs *ValidationErrors, path string, t reflect.Type, v interface{}) {
+	if v == nil {
+		// null leaves the default in place
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	// Types with their own decoding are checked by decoding the value.
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		checkDecode(errs, path, t, v)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			invalid(errs, path, fmt.Sprintf("expected an object, got %s", jsonKind(v)))
+			return
+		}
+		fields := jsonFields(t)
+		for key, val := range m {
+			_, f, ok := lookupField(fields, key)
+			if !ok {
+				*errs = append(*errs, &ValidationError{
+					Path:       joinPath(path, key),
+					Err:        ErrUnknownKey,
+					Suggestion: suggest(key, fields),
+				})
+				continue
+			}
+			validateValue(errs, joinPath(path, key), f.Type, val)
+		}
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			invalid(errs, path, fmt.Sprintf("expected an object, got %s", jsonKind(v)))
+			return
+		}
+		for key, val := range m {
+			validateValue(errs, joinPath(path, key), t.Elem(), val)
+		}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as a base64 string
+			checkDecode(errs, path, t, v)
+			return
+		}
+		a, ok := v.([]interface{})
+		if !ok {
+			invalid(errs, path, fmt.Sprintf("expected an array, got %s", jsonKind(v)))
+			return
+		}
+		for i, val := range a {
+			validateValue(errs, fmt.Sprintf("%s[%d]", path, i), t.Elem(), val)
+		}
+	case reflect.Interface:
+		// anything goes
+	default:
+		checkDecode(errs, path, t, v)
+	}
+}
+
+func checkDecode(errs *ValidationErrors, path string, t reflect.Type, v interface{}) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		invalid(errs, path, err.Error())
+		return
+	}
+	if err := json.Unmarshal(buf, reflect.New(t).Interface()); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			invalid(errs, path, fmt.Sprintf("expected %s, got %s", typeName(t), typeErr.Value))
+			return
+		}
+		invalid(errs, path, err.Error())
+	}
+}
+
+func invalid(errs *ValidationErrors, path, detail string) {
+	*errs = append(*errs, &ValidationError{Path: path, Err: ErrInvalidValue, Detail: detail})
+}
+
+// jsonFields returns the fields of a struct by their JSON name.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+// lookupField matches keys the way encoding/json does: exactly, or else
+// case-insensitively. It returns the name of the matching field.
+func lookupField(fields map[string]reflect.StructField, key string) (string, reflect.StructField, bool) {
+	if f, ok := fields[key]; ok {
+		return key, f, true
+	}
+	for name, f := range fields {
+		if strings.EqualFold(name, key) {
+			return name, f, true
+		}
+	}
+	return "", reflect.StructField{}, false
+}
+
+// suggest returns the known key closest to an unknown one, if it is close
+// enough to be a typo.
+func suggest(key string, fields map[string]reflect.StructField) string {
+	limit := 2
+	if len(key) > 8 {
+		limit = 3
+	}
+	best, bestDist := "", limit+1
+	for name := range fields {
+		d := editDistance(strings.ToLower(key), strings.ToLower(name))
+		if d < bestDist || (d == bestDist && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case float64, json.Number:
+		return "a number"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	default:
+		return t.String()
+	}
+}
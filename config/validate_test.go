@@ -0,0 +1,82 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cfg, err := ToMap(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("empty config should be valid: %s", err)
+	}
+
+	for _, tc := range []struct {
+		json       string
+		path       string
+		kind       error
+		suggestion string
+	}{
+		{`{"Swarm": {"ConnMgr": {"HighWaterr": 10}}}`, "Swarm.ConnMgr.HighWaterr", ErrUnknownKey, "HighWater"},
+		{`{"Swarm": {"ConnMgr": {"HighWater": "ten"}}}`, "Swarm.ConnMgr.HighWater", ErrInvalidValue, ""},
+		{`{"Gateway": {"NoFetch": "yes"}}`, "Gateway.NoFetch", ErrInvalidValue, ""},
+		{`{"Pinning": {"RemoteServices": {"svc": {"Policies": {"MFS": {"Enabeld": true}}}}}}`, "Pinning.RemoteServices.svc.Policies.MFS.Enabeld", ErrUnknownKey, "Enable"},
+		{`{"Bootstrap": ["/dnsaddr/bootstrap.libp2p.io", 1]}`, "Bootstrap[1]", ErrInvalidValue, ""},
+		{`{"Gateway": {"PublicGateways": {"example.com": {"Paths": 1}}}}`, "Gateway.PublicGateways.example.com.Paths", ErrInvalidValue, ""},
+		{`{"Experimental": {"AcceleratedDHTClient": true}}`, "Experimental.AcceleratedDHTClient", ErrInvalidValue, ""},
+		{`{"Completely": {}}`, "Completely", ErrUnknownKey, ""},
+	} {
+		err := ValidateJSON([]byte(tc.json))
+		var errs ValidationErrors
+		if !errors.As(err, &errs) || len(errs) != 1 {
+			t.Errorf("%s: expected one validation error, got %v", tc.json, err)
+			continue
+		}
+		if !errors.Is(err, tc.kind) {
+			t.Errorf("%s: expected %v, got %v", tc.json, tc.kind, err)
+		}
+		if errs[0].Path != tc.path {
+			t.Errorf("%s: expected path %q, got %q", tc.json, tc.path, errs[0].Path)
+		}
+		if errs[0].Suggestion != tc.suggestion {
+			t.Errorf("%s: expected suggestion %q, got %q", tc.json, tc.suggestion, errs[0].Suggestion)
+		}
+	}
+}
+
+func TestValidateDefaultConfig(t *testing.T) {
+	cfg, err := InitWithIdentity(Identity{PeerID: "QmTest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSplitUnknownKeys(t *testing.T) {
+	unknown, err := SplitUnknownKeys(ValidateJSON([]byte(`{"Stale": 1, "Swarm": {"ConnMgr": {"HighWater": "ten"}}}`)))
+	if len(unknown) != 1 || unknown[0].Path != "Stale" {
+		t.Errorf("expected the unknown key Stale, got %v", unknown)
+	}
+	if !errors.Is(err, ErrInvalidValue) || errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected only the invalid value, got %v", err)
+	}
+
+	unknown, err = SplitUnknownKeys(ValidateJSON([]byte(`{"Stale": 1}`)))
+	if len(unknown) != 1 || err != nil {
+		t.Errorf("expected only the unknown key Stale, got %v and %v", unknown, err)
+	}
+
+	if unknown, err := SplitUnknownKeys(nil); unknown != nil || err != nil {
+		t.Errorf("expected nothing, got %v and %v", unknown, err)
+	}
+}
@@ -0,0 +1,37 @@
+package config
+
+import "time"
+
+const (
+	DefaultWebhooksTimeout       = 10 * time.Second
+	DefaultWebhooksPeersInterval = time.Minute
+)
+
+// Webhooks configures the HTTP requests posted on node events.
+type Webhooks struct {
+	Endpoints []WebhookEndpoint `json:",omitempty"`
+
+	// Timeout bounds a single request to an endpoint.
+	Timeout *OptionalDuration `json:",omitempty"`
+
+	// PeersLowWater and PeersHighWater fire the peers.low and peers.high
+	// events when the number of connected peers falls below, or rises above
+	// them. 0 disables the event.
+	PeersLowWater  *OptionalInteger `json:",omitempty"`
+	PeersHighWater *OptionalInteger `json:",omitempty"`
+	// PeersInterval is the time between two counts of the connected peers.
+	PeersInterval *OptionalDuration `json:",omitempty"`
+}
+
+// WebhookEndpoint is a URL the events are posted to.
+type WebhookEndpoint struct {
+	URL string
+
+	// Events are the patterns of the names of the events posted, like
+	// "pin.*". All the events are posted when empty.
+	Events []string `json:",omitempty"`
+
+	// Secret signs the requests with HMAC-SHA256, in the
+	// X-Ipfs-Signature header.
+	Secret string `json:",omitempty"`
+}
@@ -0,0 +1,150 @@
+// Package alias stores the local aliases of the repo: human names for the
+// /ipfs and /ipns paths, usable as /alias/<name> paths.
+package alias
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// DatastoreKey is the key the aliases are stored under.
+var DatastoreKey = datastore.NewKey("/local/aliases")
+
+// Namespace is the first segment of the alias paths.
+const Namespace = "alias"
+
+var (
+	// ErrNotFound is returned for the names without an alias.
+	ErrNotFound = errors.New("alias not found")
+	// ErrExists is returned when setting an alias already set, without
+	// overwriting it.
+	ErrExists = errors.New("alias already exists")
+)
+
+// Alias is the name of a path.
+type Alias struct {
+	Name string
+	Path path.Path
+}
+
+// Store is the persistent set of aliases.
+type Store struct {
+	ds datastore.Datastore
+}
+
+// New returns the aliases persisted in ds.
+func New(ds datastore.Datastore) *Store {
+	return &Store{ds: ds}
+}
+
+// ValidName returns an error when name can't be used as an alias: names are
+// non-empty path segments.
+func ValidName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		return fmt.Errorf("invalid alias name %q", name)
+	}
+	return nil
+}
+
+// Set records name as an alias of p, replacing the previous alias of name
+// when overwrite is true.
+func (s *Store) Set(ctx context.Context, name string, p path.Path, overwrite bool) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	k := DatastoreKey.ChildString(name)
+	if !overwrite {
+		has, err := s.ds.Has(ctx, k)
+		if err != nil {
+			return err
+		}
+		if has {
+			return fmt.Errorf("%s: %w", name, ErrExists)
+		}
+	}
+	return s.ds.Put(ctx, k, []byte(p.String()))
+}
+
+// Get returns the path of the alias name.
+func (s *Store) Get(ctx context.Context, name string) (path.Path, error) {
+	if err := ValidName(name); err != nil {
+		return nil, err
+	}
+	buf, err := s.ds.Get(ctx, DatastoreKey.ChildString(name))
+	if errors.Is(err, datastore.ErrNotFound) {
+		return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return path.NewPath(string(buf))
+}
+
+// List returns the aliases sorted by name.
+func (s *Store) List(ctx context.Context) ([]Alias, error) {
+	res, err := s.ds.Query(ctx, query.Query{Prefix: DatastoreKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+	aliases := make([]Alias, 0, len(entries))
+	for _, e := range entries {
+		p, err := path.NewPath(string(e.Value))
+		if err != nil {
+			return nil, fmt.Errorf("alias %s: %w", e.Key, err)
+		}
+		aliases = append(aliases, Alias{Name: datastore.RawKey(e.Key).BaseNamespace(), Path: p})
+	}
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Name < aliases[j].Name })
+	return aliases, nil
+}
+
+// Remove deletes the alias name.
+func (s *Store) Remove(ctx context.Context, name string) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	k := DatastoreKey.ChildString(name)
+	has, err := s.ds.Has(ctx, k)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	return s.ds.Delete(ctx, k)
+}
+
+// Expand returns the path p, with its /alias/<name> prefix replaced by the
+// path of the alias. The other paths are parsed with path.NewPath.
+func (s *Store) Expand(ctx context.Context, p string) (path.Path, error) {
+	name, rest, ok := Split(p)
+	if !ok {
+		return path.NewPath(p)
+	}
+	target, err := s.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return path.Join(target, rest...)
+}
+
+// Split returns the alias name and the remaining segments of an
+// /alias/<name>/... path. It returns false for the other paths.
+func Split(p string) (string, []string, bool) {
+	segments := path.StringToSegments(p)
+	if !strings.HasPrefix(p, "/") || len(segments) < 2 || segments[0] != Namespace {
+		return "", nil, false
+	}
+	return segments[1], segments[2:], true
+}
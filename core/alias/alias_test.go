@@ -0,0 +1,116 @@
+package alias
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-datastore"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	s := New(datastore.NewMapDatastore())
+
+	photos, err := path.NewPath("/ipfs/bafkqaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	site, err := path.NewPath("/ipns/example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, "photos-2023", photos, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, "site", photos, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, "site", site, false); !errors.Is(err, ErrExists) {
+		t.Fatalf("expected ErrExists, got %v", err)
+	}
+	if err := s.Set(ctx, "site", site, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, "a/b", photos, true); err == nil {
+		t.Fatal("expected an error for an invalid name")
+	}
+
+	p, err := s.Get(ctx, "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.String() != site.String() {
+		t.Fatalf("unexpected path %s", p)
+	}
+
+	aliases, err := s.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aliases) != 2 || aliases[0].Name != "photos-2023" || aliases[1].Name != "site" {
+		t.Fatalf("unexpected aliases %v", aliases)
+	}
+
+	if err := s.Remove(ctx, "site"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "site"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := s.Remove(ctx, "site"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	for p, want := range map[string][]string{
+		"/alias/photos":             {"photos"},
+		"/alias/photos/2023/a.jpg":  {"photos", "2023", "a.jpg"},
+		"/ipfs/bafkqaaa/alias/name": nil,
+		"alias/photos":              nil,
+		"/alias":                    nil,
+	} {
+		name, rest, ok := Split(p)
+		var got []string
+		if ok {
+			got = append([]string{name}, rest...)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Split(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	ctx := context.Background()
+	s := New(datastore.NewMapDatastore())
+
+	photos, err := path.NewPath("/ipfs/bafkqaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, "photos", photos, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for p, want := range map[string]string{
+		"/alias/photos":          "/ipfs/bafkqaaa",
+		"/alias/photos/2023/a":   "/ipfs/bafkqaaa/2023/a",
+		"/ipns/example.com/a":    "/ipns/example.com/a",
+		"/ipfs/bafkqaaa/alias/a": "/ipfs/bafkqaaa/alias/a",
+	} {
+		got, err := s.Expand(ctx, p)
+		if err != nil {
+			t.Fatalf("Expand(%q): %s", p, err)
+		}
+		if got.String() != want {
+			t.Errorf("Expand(%q) = %s, want %s", p, got, want)
+		}
+	}
+	if _, err := s.Expand(ctx, "/alias/missing/a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
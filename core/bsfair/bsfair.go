@@ -0,0 +1,289 @@
+// Package bsfair schedules the tasks of the Bitswap server fairly between the
+// peers: the peers with pathological request patterns, such as huge
+// wantlists or blocks they keep asking for after receiving them, are served
+// after the well-behaved ones.
+//
+// The Scheduler watches the Bitswap messages as a tracer.Tracer, and orders
+// the tasks of the engine as a server.TaskComparator.
+package bsfair
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	pb "github.com/ipfs/boxo/bitswap/message/pb"
+	"github.com/ipfs/boxo/bitswap/server"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reasons a peer is deprioritized, as reported by the metrics.
+const (
+	ReasonWantlist = "wantlist"
+	ReasonAckRate  = "ack-rate"
+)
+
+const (
+	// DefaultMaxWantlist is the default number of wantlist entries over which
+	// a peer is deprioritized.
+	DefaultMaxWantlist = 1024
+	// DefaultMinAckRate is the default rate under which a peer is
+	// deprioritized.
+	DefaultMinAckRate = 0.5
+
+	// minAckSamples is the number of blocks sent to a peer before its ack
+	// rate is judged.
+	minAckSamples = 16
+	// maxAckSamples bounds the ack counts, which are halved past it, so that
+	// the rate follows the recent behavior of the peer.
+	maxAckSamples = 1024
+	// recentSentBlocks is the number of blocks sent to a peer that are
+	// remembered to match its cancels.
+	recentSentBlocks = 256
+	// servedHalfLife is the half-life of the bytes served to a peer, which
+	// orders the peers of the same class.
+	servedHalfLife = 10 * time.Second
+	// peerIdleTimeout is how long a peer that sent no message is remembered.
+	peerIdleTimeout = 10 * time.Minute
+	// maxPeers is the number of remembered peers over which the idle ones
+	// are forgotten.
+	maxPeers = 4096
+)
+
+var (
+	deprioritizedMetric = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ipfs_bitswap_fair_deprioritized_peers",
+		Help: "Peers currently deprioritized by the Bitswap fair scheduler",
+	})
+	deprioritizationsMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ipfs_bitswap_fair_deprioritizations_total",
+		Help: "Peers deprioritized by the Bitswap fair scheduler, by reason",
+	}, []string{"reason"})
+	preemptionsMetric = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ipfs_bitswap_fair_preemptions_total",
+		Help: "Scheduling decisions that served a well-behaved peer before a deprioritized one",
+	})
+)
+
+// Collectors returns the metrics of the fair scheduler.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{deprioritizedMetric, deprioritizationsMetric, preemptionsMetric}
+}
+
+// Config are the thresholds of a Scheduler.
+type Config struct {
+	// MaxWantlist is the number of wantlist entries over which a peer is
+	// deprioritized. It is restored once its wantlist is back under half of
+	// it.
+	MaxWantlist int
+	// MinAckRate is the share of the blocks sent to a peer that it must
+	// cancel, as Bitswap clients do once they received a block, rather than
+	// want again.
+	MinAckRate float64
+}
+
+// Scheduler tracks the behavior of the peers of the Bitswap server, and
+// orders its tasks.
+type Scheduler struct {
+	cfg Config
+	now func() time.Time
+
+	lk    sync.RWMutex
+	peers map[peer.ID]*peerState
+}
+
+type peerState struct {
+	// wantlist estimates the size of the wantlist of the peer.
+	wantlist int
+	// sent are the blocks recently sent to the peer, in a ring.
+	sent     map[cid.Cid]struct{}
+	sentRing [recentSentBlocks]cid.Cid
+	sentNext int
+	// acks are the sent blocks the peer cancelled, nacks the ones it wanted
+	// again.
+	acks, nacks float64
+	// served are the bytes sent to the peer, decayed with servedHalfLife
+	// since servedAt.
+	served   float64
+	servedAt time.Time
+
+	deprioritized bool
+	lastSeen      time.Time
+}
+
+// New returns a scheduler with the given thresholds.
+func New(cfg Config) *Scheduler {
+	if cfg.MaxWantlist <= 0 {
+		cfg.MaxWantlist = DefaultMaxWantlist
+	}
+	if cfg.MinAckRate <= 0 {
+		cfg.MinAckRate = DefaultMinAckRate
+	}
+	return &Scheduler{
+		cfg:   cfg,
+		now:   time.Now,
+		peers: make(map[peer.ID]*peerState),
+	}
+}
+
+// Compare implements server.TaskComparator: it returns whether ta should be
+// served before tb. The tasks of the well-behaved peers come first, then the
+// peers that were served the least lately. The want-have tasks of a peer,
+// which are cheap to answer, come before its want-block tasks.
+func (s *Scheduler) Compare(ta, tb *server.TaskInfo) bool {
+	if ta.Peer == tb.Peer {
+		return !ta.IsWantBlock && tb.IsWantBlock
+	}
+
+	s.lk.RLock()
+	pa, pb := s.peers[ta.Peer], s.peers[tb.Peer]
+	var da, db bool
+	var servedA, servedB float64
+	if pa != nil {
+		da, servedA = pa.deprioritized, pa.served
+	}
+	if pb != nil {
+		db, servedB = pb.deprioritized, pb.served
+	}
+	s.lk.RUnlock()
+
+	if da != db {
+		if db {
+			preemptionsMetric.Inc()
+		}
+		return db
+	}
+	return servedA < servedB
+}
+
+// Deprioritized returns whether p is currently deprioritized.
+func (s *Scheduler) Deprioritized(p peer.ID) bool {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+	ps := s.peers[p]
+	return ps != nil && ps.deprioritized
+}
+
+// MessageReceived implements tracer.Tracer.
+func (s *Scheduler) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	entries := msg.Wantlist()
+	if len(entries) == 0 && !msg.Full() {
+		return
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	ps := s.peer(p)
+	if msg.Full() {
+		ps.wantlist = 0
+	}
+	for _, e := range entries {
+		_, sent := ps.sent[e.Cid]
+		switch {
+		case e.Cancel:
+			if sent {
+				ps.acks++
+				delete(ps.sent, e.Cid)
+			}
+			if ps.wantlist > 0 {
+				ps.wantlist--
+			}
+		case e.WantType == pb.Message_Wantlist_Block && sent:
+			// the peer asks again for a block it was sent
+			ps.nacks++
+			delete(ps.sent, e.Cid)
+			ps.wantlist++
+		default:
+			ps.wantlist++
+		}
+	}
+	if ps.acks+ps.nacks > maxAckSamples {
+		ps.acks /= 2
+		ps.nacks /= 2
+	}
+	s.classify(ps)
+}
+
+// MessageSent implements tracer.Tracer.
+func (s *Scheduler) MessageSent(p peer.ID, msg bsmsg.BitSwapMessage) {
+	blks := msg.Blocks()
+	if len(blks) == 0 {
+		return
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	ps := s.peer(p)
+	now := s.now()
+	ps.served *= math.Exp2(-float64(now.Sub(ps.servedAt)) / float64(servedHalfLife))
+	ps.servedAt = now
+	for _, b := range blks {
+		c := b.Cid()
+		ps.served += float64(len(b.RawData()))
+		if old := ps.sentRing[ps.sentNext]; old.Defined() {
+			delete(ps.sent, old)
+		}
+		ps.sentRing[ps.sentNext] = c
+		ps.sentNext = (ps.sentNext + 1) % recentSentBlocks
+		ps.sent[c] = struct{}{}
+		if ps.wantlist > 0 {
+			ps.wantlist--
+		}
+	}
+}
+
+// peer returns the state of p, created when missing. s.lk must be held.
+func (s *Scheduler) peer(p peer.ID) *peerState {
+	now := s.now()
+	ps, ok := s.peers[p]
+	if !ok {
+		if len(s.peers) >= maxPeers {
+			s.forgetIdle(now)
+		}
+		ps = &peerState{sent: make(map[cid.Cid]struct{}), servedAt: now}
+		s.peers[p] = ps
+	}
+	ps.lastSeen = now
+	return ps
+}
+
+// forgetIdle forgets the peers that sent no message for peerIdleTimeout.
+func (s *Scheduler) forgetIdle(now time.Time) {
+	for p, ps := range s.peers {
+		if now.Sub(ps.lastSeen) > peerIdleTimeout {
+			if ps.deprioritized {
+				deprioritizedMetric.Dec()
+			}
+			delete(s.peers, p)
+		}
+	}
+}
+
+// classify updates whether ps is deprioritized. s.lk must be held.
+func (s *Scheduler) classify(ps *peerState) {
+	lowAckRate := ps.acks+ps.nacks >= minAckSamples && ps.acks/(ps.acks+ps.nacks) < s.cfg.MinAckRate
+
+	if !ps.deprioritized {
+		reason := ""
+		switch {
+		case ps.wantlist > s.cfg.MaxWantlist:
+			reason = ReasonWantlist
+		case lowAckRate:
+			reason = ReasonAckRate
+		default:
+			return
+		}
+		ps.deprioritized = true
+		deprioritizedMetric.Inc()
+		deprioritizationsMetric.WithLabelValues(reason).Inc()
+		return
+	}
+
+	if ps.wantlist <= s.cfg.MaxWantlist/2 && !lowAckRate {
+		ps.deprioritized = false
+		deprioritizedMetric.Dec()
+	}
+}
@@ -0,0 +1,77 @@
+package bsfair
+
+import (
+	"fmt"
+	"testing"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	pb "github.com/ipfs/boxo/bitswap/message/pb"
+	"github.com/ipfs/boxo/bitswap/server"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWantlistDeprioritizes(t *testing.T) {
+	s := New(Config{MaxWantlist: 10})
+	greedy, polite := peer.ID("greedy"), peer.ID("polite")
+
+	msg := bsmsg.New(true)
+	for i := 0; i < 11; i++ {
+		blk := blocks.NewBlock([]byte(fmt.Sprint(i)))
+		msg.AddEntry(blk.Cid(), 1, pb.Message_Wantlist_Block, false)
+	}
+	s.MessageReceived(greedy, msg)
+	require.True(t, s.Deprioritized(greedy))
+	require.False(t, s.Deprioritized(polite))
+
+	a := &server.TaskInfo{Peer: greedy, IsWantBlock: true}
+	b := &server.TaskInfo{Peer: polite, IsWantBlock: true}
+	require.True(t, s.Compare(b, a))
+	require.False(t, s.Compare(a, b))
+
+	// a full wantlist replaces the previous one
+	small := bsmsg.New(true)
+	small.AddEntry(blocks.NewBlock([]byte("x")).Cid(), 1, pb.Message_Wantlist_Block, false)
+	s.MessageReceived(greedy, small)
+	require.False(t, s.Deprioritized(greedy))
+}
+
+func TestAckRateDeprioritizes(t *testing.T) {
+	s := New(Config{})
+	good, bad := peer.ID("good"), peer.ID("bad")
+
+	for i := 0; i < minAckSamples; i++ {
+		blk := blocks.NewBlock([]byte(fmt.Sprint(i)))
+		for _, p := range []peer.ID{good, bad} {
+			sent := bsmsg.New(false)
+			sent.AddBlock(blk)
+			s.MessageSent(p, sent)
+		}
+
+		cancel := bsmsg.New(false)
+		cancel.Cancel(blk.Cid())
+		s.MessageReceived(good, cancel)
+
+		again := bsmsg.New(false)
+		again.AddEntry(blk.Cid(), 1, pb.Message_Wantlist_Block, false)
+		s.MessageReceived(bad, again)
+	}
+	require.False(t, s.Deprioritized(good))
+	require.True(t, s.Deprioritized(bad))
+}
+
+func TestCompareSameClass(t *testing.T) {
+	s := New(Config{})
+	served, starved := peer.ID("served"), peer.ID("starved")
+
+	sent := bsmsg.New(false)
+	sent.AddBlock(blocks.NewBlock(make([]byte, 1024)))
+	s.MessageSent(served, sent)
+
+	require.True(t, s.Compare(&server.TaskInfo{Peer: starved}, &server.TaskInfo{Peer: served}))
+	require.False(t, s.Compare(&server.TaskInfo{Peer: served}, &server.TaskInfo{Peer: starved}))
+
+	// the tasks of a peer: want-have first
+	require.True(t, s.Compare(&server.TaskInfo{Peer: served}, &server.TaskInfo{Peer: served, IsWantBlock: true}))
+}
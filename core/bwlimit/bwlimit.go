@@ -0,0 +1,305 @@
+// Package bwlimit caps the bandwidth of the streams of the node with token
+// buckets: for all the streams, for the streams of each peer and for the
+// streams of each protocol. The limits can be changed at runtime.
+package bwlimit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// Rate is a pair of limits in bytes per second, 0 is unlimited.
+type Rate struct {
+	In  uint64
+	Out uint64
+}
+
+func (r Rate) limited() bool {
+	return r.In > 0 || r.Out > 0
+}
+
+// Limits are the bandwidth caps of the node.
+type Limits struct {
+	// Total caps all the streams together.
+	Total Rate
+	// Peer caps the streams of each peer.
+	Peer Rate
+	// Protocols caps the streams of each protocol.
+	Protocols map[protocol.ID]Rate `json:",omitempty"`
+}
+
+func (l Limits) limited() bool {
+	if l.Total.limited() || l.Peer.limited() {
+		return true
+	}
+	for _, r := range l.Protocols {
+		if r.limited() {
+			return true
+		}
+	}
+	return false
+}
+
+// bucket is a token bucket holding up to one second of its rate. Reservations
+// may take more tokens than the bucket holds: the next ones wait until the
+// debt is paid back.
+type bucket struct {
+	lk     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate uint64) *bucket {
+	return &bucket{rate: float64(rate), tokens: float64(rate), last: time.Now()}
+}
+
+// reserve takes n tokens, and returns how long to wait before using them.
+func (b *bucket) reserve(n int) time.Duration {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	if b.rate <= 0 {
+		return 0
+	}
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	b.last = now
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *bucket) setRate(rate uint64) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	b.rate = float64(rate)
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+}
+
+// buckets are the pair of buckets of a Rate.
+type buckets struct {
+	in, out *bucket
+}
+
+func newBuckets(r Rate) *buckets {
+	return &buckets{in: newBucket(r.In), out: newBucket(r.Out)}
+}
+
+func (b *buckets) set(r Rate) {
+	b.in.setRate(r.In)
+	b.out.setRate(r.Out)
+}
+
+// Limiter enforces the Limits on the streams it wraps.
+type Limiter struct {
+	limited atomic.Bool
+	notify  sync.Once
+
+	lk        sync.Mutex
+	limits    Limits
+	total     *buckets
+	peers     map[peer.ID]*buckets
+	protocols map[protocol.ID]*buckets
+}
+
+// New returns a Limiter enforcing limits.
+func New(limits Limits) *Limiter {
+	l := &Limiter{
+		total:     newBuckets(Rate{}),
+		peers:     make(map[peer.ID]*buckets),
+		protocols: make(map[protocol.ID]*buckets),
+	}
+	l.SetLimits(limits)
+	return l
+}
+
+// Limits returns the limits being enforced.
+func (l *Limiter) Limits() Limits {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	res := l.limits
+	res.Protocols = make(map[protocol.ID]Rate, len(l.limits.Protocols))
+	for p, r := range l.limits.Protocols {
+		res.Protocols[p] = r
+	}
+	return res
+}
+
+// SetLimits changes the limits, including the ones of the open streams.
+func (l *Limiter) SetLimits(limits Limits) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	l.limits = limits
+	l.total.set(limits.Total)
+	for _, b := range l.peers {
+		b.set(limits.Peer)
+	}
+	for p, b := range l.protocols {
+		b.set(limits.Protocols[p])
+	}
+	l.limited.Store(limits.limited())
+}
+
+func (l *Limiter) peerBuckets(p peer.ID) *buckets {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	b, ok := l.peers[p]
+	if !ok {
+		b = newBuckets(l.limits.Peer)
+		l.peers[p] = b
+	}
+	return b
+}
+
+func (l *Limiter) protocolBuckets(p protocol.ID) *buckets {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	b, ok := l.protocols[p]
+	if !ok {
+		b = newBuckets(l.limits.Protocols[p])
+		l.protocols[p] = b
+	}
+	return b
+}
+
+// Stream returns s, with its bandwidth capped.
+func (l *Limiter) Stream(s network.Stream) network.Stream {
+	return &stream{
+		Stream:  s,
+		limiter: l,
+		peer:    l.peerBuckets(s.Conn().RemotePeer()),
+	}
+}
+
+// Wrap returns h, with the bandwidth of the streams it opens and handles
+// capped. The buckets of the peers are dropped once they disconnect.
+func (l *Limiter) Wrap(h host.Host) host.Host {
+	l.notify.Do(func() {
+		h.Network().Notify(&network.NotifyBundle{
+			DisconnectedF: func(n network.Network, c network.Conn) {
+				p := c.RemotePeer()
+				if n.Connectedness(p) == network.Connected {
+					return
+				}
+				l.lk.Lock()
+				delete(l.peers, p)
+				l.lk.Unlock()
+			},
+		})
+	})
+	return &limitedHost{Host: h, limiter: l}
+}
+
+type limitedHost struct {
+	host.Host
+	limiter *Limiter
+}
+
+func (h *limitedHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (network.Stream, error) {
+	s, err := h.Host.NewStream(ctx, p, pids...)
+	if err != nil {
+		return nil, err
+	}
+	return h.limiter.Stream(s), nil
+}
+
+func (h *limitedHost) SetStreamHandler(pid protocol.ID, handler network.StreamHandler) {
+	h.Host.SetStreamHandler(pid, h.handler(handler))
+}
+
+func (h *limitedHost) SetStreamHandlerMatch(pid protocol.ID, m func(protocol.ID) bool, handler network.StreamHandler) {
+	h.Host.SetStreamHandlerMatch(pid, m, h.handler(handler))
+}
+
+func (h *limitedHost) handler(handler network.StreamHandler) network.StreamHandler {
+	return func(s network.Stream) {
+		handler(h.limiter.Stream(s))
+	}
+}
+
+type stream struct {
+	network.Stream
+	limiter *Limiter
+	peer    *buckets
+	proto   atomic.Pointer[buckets]
+}
+
+// protocolBuckets returns the buckets of the protocol of the stream, which
+// is only known once it is negotiated.
+func (s *stream) protocolBuckets() *buckets {
+	if b := s.proto.Load(); b != nil {
+		return b
+	}
+	p := s.Stream.Protocol()
+	if p == "" {
+		return nil
+	}
+	b := s.limiter.protocolBuckets(p)
+	s.proto.Store(b)
+	return b
+}
+
+func (s *stream) wait(n int, in bool) {
+	if n <= 0 || !s.limiter.limited.Load() {
+		return
+	}
+	var delay time.Duration
+	for _, b := range []*buckets{s.limiter.total, s.peer, s.protocolBuckets()} {
+		if b == nil {
+			continue
+		}
+		bk := b.out
+		if in {
+			bk = b.in
+		}
+		if d := bk.reserve(n); d > delay {
+			delay = d
+		}
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// Read waits after reading, which slows down the remote peer through the
+// flow control of the stream.
+func (s *stream) Read(b []byte) (int, error) {
+	n, err := s.Stream.Read(b)
+	s.wait(n, true)
+	return n, err
+}
+
+// Write sends b in chunks, so that large writes don't wait for a long time
+// before sending anything.
+func (s *stream) Write(b []byte) (int, error) {
+	const chunk = 16 << 10
+	var written int
+	for len(b) > 0 {
+		c := b
+		if len(c) > chunk {
+			c = c[:chunk]
+		}
+		s.wait(len(c), false)
+		n, err := s.Stream.Write(c)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,47 @@
+package bwlimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket(t *testing.T) {
+	b := newBucket(1000)
+	// one second of burst
+	require.Zero(t, b.reserve(1000))
+	// then the debt is paid back at the rate
+	d := b.reserve(500)
+	require.InDelta(t, 500*time.Millisecond, d, float64(10*time.Millisecond))
+
+	b.setRate(0)
+	require.Zero(t, b.reserve(1<<20))
+}
+
+func TestSetLimits(t *testing.T) {
+	l := New(Limits{})
+	require.False(t, l.limited.Load())
+
+	pid := protocol.ID("/test/1.0.0")
+	proto := l.protocolBuckets(pid)
+	peer := l.peerBuckets("peer")
+
+	l.SetLimits(Limits{
+		Peer:      Rate{In: 100},
+		Protocols: map[protocol.ID]Rate{pid: {Out: 200}},
+	})
+	require.True(t, l.limited.Load())
+	require.Equal(t, float64(100), peer.in.rate)
+	require.Equal(t, float64(200), proto.out.rate)
+	require.Zero(t, l.total.in.rate)
+
+	limits := l.Limits()
+	limits.Protocols[pid] = Rate{}
+	require.Equal(t, Rate{Out: 200}, l.Limits().Protocols[pid])
+
+	l.SetLimits(Limits{})
+	require.False(t, l.limited.Load())
+	require.Zero(t, peer.in.rate)
+}
@@ -0,0 +1,100 @@
+// Package cachewarm reads the paths to prefetch from an access log, such as
+// the log of a gateway, so that the content requested yesterday is in the
+// blockstore before it is requested again today.
+package cachewarm
+
+import (
+	"bufio"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+)
+
+// maxLineSize is the size of the longest line of a log.
+const maxLineSize = 1 << 20
+
+// Entry is a path of a log, with the number of times it was requested.
+type Entry struct {
+	Path path.Path
+	Hits int
+}
+
+// ParseLog returns the paths requested in the log read from r, the most
+// requested first, and in the order of the log for the same number of
+// requests.
+//
+// Each line of the log is either a bare CID or path, or a line of an access
+// log: the first field that is an /ipfs or /ipns path, or an ipfs:// or
+// ipns:// URL, is the path it requested. The query and fragment of the paths
+// are ignored. The lines without a path are skipped.
+func ParseLog(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	index := make(map[string]int)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		p, ok := parseLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		key := p.String()
+		if i, ok := index[key]; ok {
+			entries[i].Hits++
+			continue
+		}
+		index[key] = len(entries)
+		entries = append(entries, Entry{Path: p, Hits: 1})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Hits > entries[j].Hits
+	})
+	return entries, nil
+}
+
+func parseLine(line string) (path.Path, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 1 {
+		// a list of CIDs rather than a log
+		if c, err := cid.Decode(fields[0]); err == nil {
+			return path.FromCid(c), true
+		}
+	}
+	for _, f := range fields {
+		if p, ok := parseField(strings.Trim(f, `"'`)); ok {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+func parseField(f string) (path.Path, bool) {
+	for _, scheme := range []string{"ipfs", "ipns"} {
+		if rest, ok := strings.CutPrefix(f, scheme+"://"); ok {
+			f = "/" + scheme + "/" + rest
+			break
+		}
+	}
+	if !strings.HasPrefix(f, "/ipfs/") && !strings.HasPrefix(f, "/ipns/") {
+		return nil, false
+	}
+	if i := strings.IndexAny(f, "?#"); i >= 0 {
+		f = f[:i]
+	}
+	if unescaped, err := url.PathUnescape(f); err == nil {
+		f = unescaped
+	}
+	p, err := path.NewPath(strings.TrimSuffix(f, "/"))
+	if err != nil {
+		return nil, false
+	}
+	return p, true
+}
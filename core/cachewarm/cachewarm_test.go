@@ -0,0 +1,38 @@
+package cachewarm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLog(t *testing.T) {
+	log := `bafkqaaa
+203.0.113.7 - - [15/Oct/2026:10:00:00 +0000] "GET /ipfs/bafkqaaa/a%20b.jpg?download=true HTTP/1.1" 200 512 "-" "curl"
+203.0.113.8 - - [15/Oct/2026:10:00:01 +0000] "GET /ipns/example.com/ HTTP/1.1" 200 128 "-" "curl"
+203.0.113.9 - - [15/Oct/2026:10:00:02 +0000] "GET /ipfs/bafkqaaa/a%20b.jpg HTTP/1.1" 200 512 "-" "curl"
+203.0.113.9 - - [15/Oct/2026:10:00:03 +0000] "GET /favicon.ico HTTP/1.1" 404 0 "-" "curl"
+ipfs://bafkqaaa
+not a cid
+`
+	entries, err := ParseLog(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		path string
+		hits int
+	}{
+		{"/ipfs/bafkqaaa", 2},
+		{"/ipfs/bafkqaaa/a b.jpg", 2},
+		{"/ipns/example.com", 1},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %v", len(expected), entries)
+	}
+	for i, e := range entries {
+		if e.Path.String() != expected[i].path || e.Hits != expected[i].hits {
+			t.Errorf("entry %d: expected %s (%d hits), got %s (%d hits)", i, expected[i].path, expected[i].hits, e.Path, e.Hits)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package cachewarm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// DatastoreKey is the prefix under which the expirations of the protected
+// DAGs are persisted, by CID.
+var DatastoreKey = datastore.NewKey("/local/cachewarm")
+
+// Store keeps the warmed DAGs protected from garbage collection until their
+// protection expires.
+type Store struct {
+	lk sync.Mutex
+	ds datastore.Datastore
+}
+
+// NewStore returns the protected DAGs persisted in ds.
+func NewStore(ds datastore.Datastore) *Store {
+	return &Store{ds: ds}
+}
+
+// Extend protects the DAG of c until expires, or until its current
+// expiration when it is later.
+func (s *Store) Extend(ctx context.Context, c cid.Cid, expires time.Time) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	k := DatastoreKey.ChildString(c.String())
+	buf, err := s.ds.Get(ctx, k)
+	switch {
+	case err == nil:
+		var prev time.Time
+		if err := prev.UnmarshalText(buf); err != nil {
+			return err
+		}
+		if !expires.After(prev) {
+			return nil
+		}
+	case !errors.Is(err, datastore.ErrNotFound):
+		return err
+	}
+
+	buf, err = expires.MarshalText()
+	if err != nil {
+		return err
+	}
+	return s.ds.Put(ctx, k, buf)
+}
+
+// List returns the roots of the DAGs whose protection didn't expire at now.
+// The expired protections are deleted.
+func (s *Store) List(ctx context.Context, now time.Time) ([]cid.Cid, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	results, err := s.ds.Query(ctx, query.Query{Prefix: DatastoreKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []cid.Cid
+	for _, e := range entries {
+		k := datastore.RawKey(e.Key)
+		var expires time.Time
+		if err := expires.UnmarshalText(e.Value); err != nil {
+			return nil, err
+		}
+		if !now.Before(expires) {
+			if err := s.ds.Delete(ctx, k); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		c, err := cid.Decode(k.BaseNamespace())
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, c)
+	}
+	return roots, nil
+}
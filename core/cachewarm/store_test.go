@@ -0,0 +1,56 @@
+package cachewarm
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	now := time.Now()
+	a := cid.MustParse("bafkqaaa")
+	b := cid.MustParse("bafybeiaysi4s6lnjev27ln5icwm6tueaw2vdykrtjkwiphwekaywqhcjze")
+
+	// concurrent extensions keep the latest expiration
+	var wg sync.WaitGroup
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := s.Extend(ctx, a, now.Add(time.Duration(i)*time.Hour)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := s.Extend(ctx, b, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	roots, err := s.List(ctx, now.Add(9*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 || !roots[0].Equals(a) {
+		t.Fatalf("unexpected roots %v", roots)
+	}
+
+	// the expired protections were deleted
+	if err := s.Extend(ctx, a, now); err != nil {
+		t.Fatal(err)
+	}
+	roots, err = s.List(ctx, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 || !roots[0].Equals(a) {
+		t.Fatalf("unexpected roots %v", roots)
+	}
+}
@@ -0,0 +1,109 @@
+// Package chunker extends the chunkers of boxo with FastCDC, a content defined
+// chunker much faster than rabin, and with the chunkers registered by
+// plugins.
+package chunker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	chunk "github.com/ipfs/boxo/chunker"
+)
+
+// Splitter is the interface of the chunkers.
+type Splitter = chunk.Splitter
+
+// Constructor returns the Splitter of r for spec, the whole chunker spec, e.g.
+// "name" or "name-[params]". Invalid specs must be refused before reading r.
+type Constructor func(r io.Reader, spec string) (Splitter, error)
+
+// builtin are the names of the chunkers of boxo and of this package.
+var builtin = map[string]struct{}{
+	"size":    {},
+	"rabin":   {},
+	"buzhash": {},
+	"fastcdc": {},
+}
+
+var (
+	registryLk sync.RWMutex
+	registry   = make(map[string]Constructor)
+)
+
+// Register registers the chunker name, used with the specs "name" and
+// "name-[params]". The names of the builtin chunkers can't be registered, and
+// names can't contain "-".
+func Register(name string, c Constructor) error {
+	if name == "" || strings.Contains(name, "-") {
+		return fmt.Errorf("invalid chunker name %q", name)
+	}
+	if _, ok := builtin[name]; ok {
+		return fmt.Errorf("the chunker %q is builtin", name)
+	}
+
+	registryLk.Lock()
+	defer registryLk.Unlock()
+	if _, ok := registry[name]; ok {
+		return fmt.Errorf("the chunker %q is already registered", name)
+	}
+	registry[name] = c
+	return nil
+}
+
+// FromString returns the Splitter of spec, which is one of the specs of the
+// chunkers of boxo, see chunk.FromString, of a registered chunker, or:
+//
+//	fastcdc - FastCDC with the default chunk sizes, 64KiB-256KiB-1MiB
+//	fastcdc-[min]-[avg]-[max] - FastCDC with the given chunk sizes, in bytes
+func FromString(r io.Reader, spec string) (Splitter, error) {
+	name, _, _ := strings.Cut(spec, "-")
+	registryLk.RLock()
+	c, ok := registry[name]
+	registryLk.RUnlock()
+	if ok {
+		return c(r, spec)
+	}
+
+	if name != "fastcdc" {
+		return chunk.FromString(r, spec)
+	}
+	min, avg, max, err := parseFastCDC(spec)
+	if err != nil {
+		return nil, err
+	}
+	return NewFastCDC(r, min, avg, max)
+}
+
+// Experimental reports whether spec selects a chunker whose DAGs other
+// implementations don't reproduce, FastCDC, which is only used when enabled
+// by Experimental.FastCDCChunker.
+func Experimental(spec string) bool {
+	name, _, _ := strings.Cut(spec, "-")
+	return name == "fastcdc"
+}
+
+// ErrExperimental is returned when a chunker of Experimental is used without
+// being enabled.
+var ErrExperimental = errors.New("the fastcdc chunker produces CIDs other implementations don't reproduce, enable it with Experimental.FastCDCChunker, see: https://github.com/ipfs/kubo/blob/master/docs/experimental-features.md#fastcdc-chunker")
+
+func parseFastCDC(spec string) (min, avg, max int, err error) {
+	parts := strings.Split(spec, "-")
+	switch len(parts) {
+	case 1:
+		return DefaultFastCDCMin, DefaultFastCDCAvg, DefaultFastCDCMax, nil
+	case 4:
+		sizes := make([]int, 3)
+		for i, p := range parts[1:] {
+			if sizes[i], err = strconv.Atoi(p); err != nil {
+				return 0, 0, 0, fmt.Errorf("invalid fastcdc chunk size %q: %w", p, err)
+			}
+		}
+		return sizes[0], sizes[1], sizes[2], nil
+	default:
+		return 0, 0, 0, fmt.Errorf("invalid fastcdc chunker spec %q, must be fastcdc or fastcdc-[min]-[avg]-[max]", spec)
+	}
+}
@@ -0,0 +1,51 @@
+package chunker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	chunk "github.com/ipfs/boxo/chunker"
+)
+
+func TestRegister(t *testing.T) {
+	// bytes splits into chunks of n bytes, given as bytes-[n]
+	err := Register("bytes", func(r io.Reader, spec string) (Splitter, error) {
+		n := 1
+		if _, param, ok := strings.Cut(spec, "-"); ok {
+			var err error
+			if n, err = strconv.Atoi(param); err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid bytes chunker spec %q", spec)
+			}
+		}
+		return chunk.NewSizeSplitter(r, int64(n)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, spec := range []string{"bytes", "bytes-3"} {
+		if _, err := FromString(bytes.NewReader(nil), spec); err != nil {
+			t.Errorf("%s: %s", spec, err)
+		}
+	}
+	if _, err := FromString(bytes.NewReader(nil), "bytes-x"); err == nil {
+		t.Error("expected an error for an invalid spec of a registered chunker")
+	}
+	spl, err := FromString(bytes.NewReader([]byte("abcdefg")), "bytes-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := chunks(t, spl); len(got) != 3 {
+		t.Errorf("expected 3 chunks, got %d", len(got))
+	}
+
+	for _, name := range []string{"bytes", "fastcdc", "size", "my-bytes", ""} {
+		if err := Register(name, nil); err == nil {
+			t.Errorf("%q: expected an error registering the chunker", name)
+		}
+	}
+}
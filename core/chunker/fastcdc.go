@@ -0,0 +1,165 @@
+package chunker
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	chunk "github.com/ipfs/boxo/chunker"
+)
+
+// The default chunk sizes of FastCDC.
+const (
+	DefaultFastCDCMin = 64 << 10
+	DefaultFastCDCAvg = 256 << 10
+	DefaultFastCDCMax = 1 << 20
+)
+
+// fastCDCMinSize is the smallest minimum chunk size, so that the gear hash has
+// seen a full window of bytes at the first possible cut point.
+const fastCDCMinSize = 64
+
+var (
+	ErrFastCDCMin   = errors.New("fastcdc min must be at least 64")
+	ErrFastCDCOrder = errors.New("fastcdc sizes must be ordered min < avg < max")
+)
+
+// gear is the table of the gear hash. It is part of the format of the DAGs:
+// changing it changes the CIDs of the files added with FastCDC. It is specific
+// to kubo, as are the masks, so other FastCDC implementations don't reproduce
+// these CIDs, see Experimental.
+var gear = func() (t [256]uint64) {
+	// splitmix64, from a fixed seed
+	x := uint64(0x6b756b6f66617374)
+	for i := range t {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		t[i] = z ^ (z >> 31)
+	}
+	return t
+}()
+
+// FastCDC is the content defined chunker of "FastCDC: a Fast and Efficient
+// Content-Defined Chunking Approach for Data Deduplication" (Xia et al.,
+// 2016), with normalized chunking: the cut points are harder to match before
+// the average size and easier after it, which narrows the distribution of
+// the chunk sizes around the average.
+type FastCDC struct {
+	r             io.Reader
+	min, avg, max int
+	maskS, maskL  uint64
+
+	buf      []byte
+	start, n int
+	err      error
+}
+
+var _ Splitter = (*FastCDC)(nil)
+
+// NewFastCDC returns a FastCDC splitting r into chunks of min to max bytes,
+// avg on average.
+func NewFastCDC(r io.Reader, min, avg, max int) (*FastCDC, error) {
+	if err := checkFastCDC(min, avg, max); err != nil {
+		return nil, err
+	}
+
+	// the masks of the top bits of the hash, which depend on the last 64
+	// bytes, match with a probability of 1/avg, made 4 times harder before
+	// avg and 4 times easier after it
+	bits := int(math.Round(math.Log2(float64(avg))))
+	return &FastCDC{
+		r:     r,
+		min:   min,
+		avg:   avg,
+		max:   max,
+		maskS: ^uint64(0) << (64 - (bits + 2)),
+		maskL: ^uint64(0) << (64 - (bits - 2)),
+		buf:   make([]byte, 2*max),
+	}, nil
+}
+
+// checkFastCDC checks the chunk sizes of a FastCDC.
+func checkFastCDC(min, avg, max int) error {
+	switch {
+	case min < fastCDCMinSize:
+		return ErrFastCDCMin
+	case min >= avg || avg >= max:
+		return ErrFastCDCOrder
+	case max > chunk.ChunkSizeLimit:
+		return chunk.ErrSizeMax
+	}
+	return nil
+}
+
+// Reader implements chunk.Splitter.
+func (f *FastCDC) Reader() io.Reader {
+	return f.r
+}
+
+// NextBytes implements chunk.Splitter.
+func (f *FastCDC) NextBytes() ([]byte, error) {
+	// the buffer holds twice the maximum chunk size, so that the data left
+	// is moved to its start at most once per max bytes
+	if f.err == nil && f.n-f.start < f.max {
+		f.n = copy(f.buf, f.buf[f.start:f.n])
+		f.start = 0
+		n, err := io.ReadFull(f.r, f.buf[f.n:])
+		f.n += n
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			f.err = io.EOF
+		default:
+			f.err = err
+			return nil, err
+		}
+	}
+	if f.start == f.n {
+		// release the buffer once the input is consumed
+		f.buf = nil
+		return nil, f.err
+	}
+
+	data := f.buf[f.start:f.n]
+	if len(data) > f.max {
+		data = data[:f.max]
+	}
+	i := f.cut(data)
+	res := make([]byte, i)
+	copy(res, data)
+	f.start += i
+	return res, nil
+}
+
+// cut returns the length of the chunk at the start of data.
+func (f *FastCDC) cut(data []byte) int {
+	n := len(data)
+	if n <= f.min {
+		return n
+	}
+	normal := f.avg
+	if n < normal {
+		normal = n
+	}
+
+	var fp uint64
+	maskS, maskL := f.maskS, f.maskL
+	i := f.min
+	for _, b := range data[i:normal] {
+		fp = (fp << 1) + gear[b]
+		i++
+		if fp&maskS == 0 {
+			return i
+		}
+	}
+	for _, b := range data[i:n] {
+		fp = (fp << 1) + gear[b]
+		i++
+		if fp&maskL == 0 {
+			return i
+		}
+	}
+	return n
+}
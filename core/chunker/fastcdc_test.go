@@ -0,0 +1,130 @@
+package chunker
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func randomData(n int, seed int64) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func chunks(t testing.TB, spl Splitter) [][]byte {
+	var out [][]byte
+	for {
+		b, err := spl.NextBytes()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, b)
+	}
+}
+
+func TestFromString(t *testing.T) {
+	for _, spec := range []string{"fastcdc", "fastcdc-1024-4096-16384", "size-1024", "buzhash", ""} {
+		if _, err := FromString(bytes.NewReader(nil), spec); err != nil {
+			t.Errorf("%s: %s", spec, err)
+		}
+	}
+	for _, spec := range []string{"fastcdc-1024", "fastcdc-32-4096-16384", "fastcdc-4096-1024-16384", "fastcdc-1024-4096-2097152", "fastcdc-a-b-c", "fastcdcx", "buzz"} {
+		if _, err := FromString(bytes.NewReader(nil), spec); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	specs := []string{
+		"", "default", "buzhash", "size-1024", "size-0", "size-x", "size-2097152",
+		"rabin", "rabin-4096", "rabin-1048576", "rabin-16-4096-16384", "rabin-min:16-avg:4096-max:16384",
+		"rabin-avg:16-avg:4096-max:16384", "rabin-8-4096-16384", "rabin-4096-1024-16384", "rabin-1-2",
+		"fastcdc", "fastcdc-1024-4096-16384", "fastcdc-1024", "fastcdc-32-4096-16384", "fastcdc-a-b-c",
+		"fastcdcx", "buzz",
+	}
+	for _, spec := range specs {
+		_, fromErr := FromString(bytes.NewReader(nil), spec)
+		if err := Validate(spec); (err == nil) != (fromErr == nil) {
+			t.Errorf("%s: Validate returned %v, FromString %v", spec, err, fromErr)
+		}
+	}
+}
+
+func TestExperimental(t *testing.T) {
+	for spec, want := range map[string]bool{"fastcdc": true, "fastcdc-1024-4096-16384": true, "size-1024": false, "buzhash": false, "": false} {
+		if got := Experimental(spec); got != want {
+			t.Errorf("%s: expected %t, got %t", spec, want, got)
+		}
+	}
+}
+
+func TestFastCDC(t *testing.T) {
+	const min, avg, max = 1024, 4096, 16384
+	data := randomData(4<<20, 1)
+
+	spl, err := NewFastCDC(bytes.NewReader(data), min, avg, max)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := chunks(t, spl)
+	if !bytes.Equal(bytes.Join(out, nil), data) {
+		t.Fatal("the chunks don't add up to the data")
+	}
+	for i, c := range out {
+		if len(c) > max || (len(c) < min && i != len(out)-1) {
+			t.Fatalf("chunk %d of %d bytes is out of bounds", i, len(c))
+		}
+	}
+	if mean := len(data) / len(out); mean < avg/2 || mean > avg*2 {
+		t.Fatalf("expected chunks of %d bytes on average, got %d", avg, mean)
+	}
+
+	again, _ := NewFastCDC(bytes.NewReader(data), min, avg, max)
+	if len(chunks(t, again)) != len(out) {
+		t.Fatal("expected the chunks to be deterministic")
+	}
+}
+
+func TestFastCDCShift(t *testing.T) {
+	data := randomData(4<<20, 2)
+	shifted := append(randomData(100, 3), data...)
+
+	spl, _ := NewFastCDC(bytes.NewReader(data), 1024, 4096, 16384)
+	seen := map[string]bool{}
+	out := chunks(t, spl)
+	for _, c := range out {
+		seen[string(c)] = true
+	}
+	spl, _ = NewFastCDC(bytes.NewReader(shifted), 1024, 4096, 16384)
+	var shared int
+	for _, c := range chunks(t, spl) {
+		if seen[string(c)] {
+			shared++
+		}
+	}
+	if shared < len(out)*9/10 {
+		t.Fatalf("expected the chunks to survive a shift of the data, %d of %d did", shared, len(out))
+	}
+}
+
+func BenchmarkChunkers(b *testing.B) {
+	data := randomData(64<<20, 4)
+	for _, spec := range []string{"fastcdc", "buzhash", "rabin", "size-262144"} {
+		b.Run(spec, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				spl, err := FromString(bytes.NewReader(data), spec)
+				if err != nil {
+					b.Fatal(err)
+				}
+				chunks(b, spl)
+			}
+		})
+	}
+}
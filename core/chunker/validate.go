@@ -0,0 +1,31 @@
+package chunker
+
+import (
+	"bytes"
+	"strings"
+
+	chunk "github.com/ipfs/boxo/chunker"
+)
+
+// Validate checks the syntax and the sizes of spec. The specs of boxo are
+// checked by chunk.FromString, on an empty reader. The parameters of the
+// registered chunkers are only checked by their constructor.
+func Validate(spec string) error {
+	name, _, _ := strings.Cut(spec, "-")
+	registryLk.RLock()
+	_, ok := registry[name]
+	registryLk.RUnlock()
+	if ok {
+		return nil
+	}
+
+	if name != "fastcdc" {
+		_, err := chunk.FromString(bytes.NewReader(nil), spec)
+		return err
+	}
+	min, avg, max, err := parseFastCDC(spec)
+	if err != nil {
+		return err
+	}
+	return checkFastCDC(min, avg, max)
+}
@@ -6,11 +6,14 @@ import (
 	"io"
 	"os"
 	gopath "path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ipfs/kubo/core/commands/cmdenv"
 
 	"github.com/cheggaaa/pb"
+	humanize "github.com/dustin/go-humanize"
 	"github.com/ipfs/boxo/files"
 	mfs "github.com/ipfs/boxo/mfs"
 	"github.com/ipfs/boxo/path"
@@ -29,30 +32,78 @@ type AddEvent struct {
 	Hash  string `json:",omitempty"`
 	Bytes int64  `json:",omitempty"`
 	Size  string `json:",omitempty"`
+	// DryRun describes the DAG of the entry Name, with --dry-run
+	DryRun *options.DryRunStats `json:",omitempty"`
 }
 
 const (
-	quietOptionName       = "quiet"
-	quieterOptionName     = "quieter"
-	silentOptionName      = "silent"
-	progressOptionName    = "progress"
-	trickleOptionName     = "trickle"
-	wrapOptionName        = "wrap-with-directory"
-	onlyHashOptionName    = "only-hash"
-	chunkerOptionName     = "chunker"
-	pinOptionName         = "pin"
-	rawLeavesOptionName   = "raw-leaves"
-	noCopyOptionName      = "nocopy"
-	fstoreCacheOptionName = "fscache"
-	cidVersionOptionName  = "cid-version"
-	hashOptionName        = "hash"
-	inlineOptionName      = "inline"
-	inlineLimitOptionName = "inline-limit"
-	toFilesOptionName     = "to-files"
+	quietOptionName         = "quiet"
+	quieterOptionName       = "quieter"
+	silentOptionName        = "silent"
+	progressOptionName      = "progress"
+	trickleOptionName       = "trickle"
+	wrapOptionName          = "wrap-with-directory"
+	onlyHashOptionName      = "only-hash"
+	chunkerOptionName       = "chunker"
+	pinOptionName           = "pin"
+	rawLeavesOptionName     = "raw-leaves"
+	noCopyOptionName        = "nocopy"
+	fstoreCacheOptionName   = "fscache"
+	cidVersionOptionName    = "cid-version"
+	hashOptionName          = "hash"
+	inlineOptionName        = "inline"
+	inlineLimitOptionName   = "inline-limit"
+	toFilesOptionName       = "to-files"
+	incrementalOptionName   = "incremental"
+	carOptionName           = "car"
+	memoryBudgetOptionName  = "memory-budget"
+	preserveModeOptionName  = "preserve-mode"
+	preserveMtimeOptionName = "preserve-mtime"
+	modeOptionName          = "mode"
+	mtimeOptionName         = "mtime"
+	mtimeNsecsOptionName    = "mtime-nsecs"
+	maxFileLinksOptionName  = "max-file-links"
+	maxDirLinksOptionName   = "max-directory-links"
+	hamtFanoutOptionName    = "hamt-fanout"
+	concurrencyOptionName   = "add-concurrency"
+	preserveOrderOptionName = "preserve-order"
+	dryRunOptionName        = "dry-run"
 )
 
 const adderOutChanSize = 8
 
+// parsePosixMode parses an octal POSIX mode, which holds the permission,
+// setuid, setgid and sticky bits.
+func parsePosixMode(s string) (os.FileMode, error) {
+	v, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	if v > 0o7777 {
+		return 0, fmt.Errorf("%s is not a file mode", s)
+	}
+	mode := os.FileMode(v & 0o777)
+	if v&0o4000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if v&0o2000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if v&0o1000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode, nil
+}
+
+// printDryRun writes the description of the DAG of the dry run of name.
+func printDryRun(w io.Writer, name string, s *options.DryRunStats) {
+	if name == "" {
+		name = "the input"
+	}
+	fmt.Fprintf(w, "dry run of %s: %d blocks, %s (%s of overhead), %d blocks stored locally\n",
+		cmdenv.EscNonPrint(name), s.Blocks, humanize.Bytes(s.Bytes), humanize.Bytes(s.Overhead), s.LocalBlocks)
+}
+
 var AddCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Add a file or directory to IPFS.",
@@ -97,14 +148,35 @@ to find it in the future:
 See 'ipfs files --help' to learn more about using MFS
 for keeping track of added files and directories.
 
+Passing '--car' adds the UnixFS DAG of a .car file as is, in a single
+operation instead of 'ipfs dag import', 'ipfs files cp' and 'ipfs pin add':
+the CAR must have a single root, and the DAG under it must be complete and
+made of UnixFS nodes. Its blocks are verified, stored, and the root is pinned
+and, with '--to-files', added to MFS (without the .car extension when the
+destination is a directory):
+
+  > ipfs add --car site.car --to-files /sites/
+  > ipfs files ls /sites/
+  site
+
+Passing '--preserve-mode' and '--preserve-mtime' stores the POSIX mode and
+the modification time of the added files and directories in their UnixFS 1.5
+metadata, changing their CIDs. '--mode' and '--mtime' store the given values
+instead, e.g. '--mode=0644 --mtime=1700000000'. Directories only keep their
+own mode and time when the daemon isn't running, and large directories,
+which are sharded, don't keep them.
+
 The chunker option, '-s', specifies the chunking strategy that dictates
 how to break files into blocks. Blocks with same content can
 be deduplicated. Different chunking strategies will produce different
 hashes for the same file. The default is a fixed block size of
 256 * 1024 bytes, 'size-262144'. Alternatively, you can use the
-Buzhash or Rabin fingerprint chunker for content defined chunking by
-specifying buzhash or rabin-[min]-[avg]-[max] (where min/avg/max refer
-to the desired chunk sizes in bytes), e.g. 'rabin-262144-524288-1048576'.
+Buzhash, Rabin fingerprint or FastCDC chunker for content defined chunking
+by specifying buzhash, rabin-[min]-[avg]-[max] or fastcdc-[min]-[avg]-[max]
+(where min/avg/max refer to the desired chunk sizes in bytes), e.g.
+'rabin-262144-524288-1048576'. FastCDC is the fastest of them, 'fastcdc'
+alone uses chunks of 64KiB to 1MiB, 256KiB on average. Its CIDs are specific
+to kubo, so it must be enabled with Experimental.FastCDCChunker.
 
 The following examples use very small byte sizes to demonstrate the
 properties of the different chunkers on a small file. You'll likely
@@ -125,6 +197,11 @@ You can now check what blocks have been created by:
   QmerURi9k4XzKCaaPbsK6BL5pMEjF7PGphjDvkkjDtsVf3 868
   QmQB28iwSriSUSMqG2nXDTLtdPHgWb4rebBrU7Q1j4vxPv 338
 
+The shape of the DAG is set by '--max-file-links', the number of links of the
+nodes of the files, and for the directories, by '--max-directory-links', over
+which the directories are sharded into HAMTs, and '--hamt-fanout', the number
+of links of the nodes of the shards.
+
 Finally, a note on hash (CID) determinism and 'ipfs add' command.
 
 Almost all the flags provided by this command will change the final CID, and
@@ -132,9 +209,34 @@ new flags may be added in the future. It is not guaranteed for the implicit
 defaults of 'ipfs add' to remain the same in future Kubo releases, or for other
 IPFS software to use the same import parameters as Kubo.
 
+Passing '--dry-run' only hashes the data, like '--only-hash', and describes
+the DAG the add would write: the number and size of its blocks, the overhead
+of the UnixFS nodes over the data of the files, and the blocks already stored
+locally.
+
+  > ipfs add -r --dry-run ./photos
+  added QmS... photos
+  dry run of photos: 8201 blocks, 2.1 GB (6.2 MB of overhead), 0 blocks stored locally
+
 If you need to back up or transport content-addressed data using a non-IPFS
 medium, CID can be preserved with CAR files.
 See 'dag export' and 'dag import' for more information.
+
+The '--incremental' flag makes re-adding large, mostly unchanged directories
+cheap: the node keeps a local index of the absolute path, size and
+modification time of every file it imported, and files that haven't changed
+since their last import (with the same chunker, layout and CID options) are
+reused without being read and hashed again, as long as all their blocks are
+still stored. Only files and directories that changed are printed. Only the
+files read by the node itself are indexed: when the daemon is running, the
+files sent to it by 'ipfs add' are always imported again.
+
+  > ipfs add -r --incremental ./photos
+  > touch ./photos/2023/new.jpg
+  > ipfs add -r --incremental ./photos
+  added QmQ... photos/2023/new.jpg
+  added QmR... photos/2023
+  added QmS... photos
 `,
 	},
 
@@ -155,7 +257,7 @@ See 'dag export' and 'dag import' for more information.
 		cmds.BoolOption(trickleOptionName, "t", "Use trickle-dag format for dag generation."),
 		cmds.BoolOption(onlyHashOptionName, "n", "Only chunk and hash - do not write to disk."),
 		cmds.BoolOption(wrapOptionName, "w", "Wrap files with a directory object."),
-		cmds.StringOption(chunkerOptionName, "s", "Chunking algorithm, size-[bytes], rabin-[min]-[avg]-[max] or buzhash").WithDefault("size-262144"),
+		cmds.StringOption(chunkerOptionName, "s", "Chunking algorithm, size-[bytes], rabin-[min]-[avg]-[max], buzhash or fastcdc-[min]-[avg]-[max]").WithDefault("size-262144"),
 		cmds.BoolOption(rawLeavesOptionName, "Use raw blocks for leaf nodes."),
 		cmds.BoolOption(noCopyOptionName, "Add the file using filestore. Implies raw-leaves. (experimental)"),
 		cmds.BoolOption(fstoreCacheOptionName, "Check the filestore for pre-existing blocks. (experimental)"),
@@ -165,6 +267,20 @@ See 'dag export' and 'dag import' for more information.
 		cmds.IntOption(inlineLimitOptionName, "Maximum block size to inline. (experimental)").WithDefault(32),
 		cmds.BoolOption(pinOptionName, "Pin locally to protect added files from garbage collection.").WithDefault(true),
 		cmds.StringOption(toFilesOptionName, "Add reference to Files API (MFS) at the provided path."),
+		cmds.BoolOption(incrementalOptionName, "Skip files unchanged since they were last added, based on a local index of path, size and mtime. (experimental)"),
+		cmds.BoolOption(carOptionName, "Add the UnixFS DAG of .car files as is, instead of chunking them."),
+		cmds.StringOption(memoryBudgetOptionName, "Cap the blocks buffered in memory, e.g. \"64MiB\". The blocks over it are spilled to a temporary file in the repo."),
+		cmds.BoolOption(preserveModeOptionName, "Store the POSIX mode of the files and directories in UnixFS metadata."),
+		cmds.BoolOption(preserveMtimeOptionName, "Store the modification time of the files and directories in UnixFS metadata."),
+		cmds.StringOption(modeOptionName, "Store this octal POSIX mode in the UnixFS metadata of all the files and directories, e.g. 0644."),
+		cmds.Int64Option(mtimeOptionName, "Store this modification time, in seconds since the Unix epoch, in the UnixFS metadata of all the files and directories."),
+		cmds.UintOption(mtimeNsecsOptionName, "The nanoseconds of the modification time of --mtime."),
+		cmds.IntOption(maxFileLinksOptionName, "Maximum number of links of the nodes of the files. Default: 174."),
+		cmds.IntOption(maxDirLinksOptionName, "Shard the directories of more links than this. Default: shard over Internal.UnixFSShardingSizeThreshold."),
+		cmds.IntOption(hamtFanoutOptionName, "Number of links of the nodes of the sharded directories, a power of two. Default: 256."),
+		cmds.IntOption(concurrencyOptionName, "Number of files of a directory chunked and hashed at once. Only applies offline: the files sent to a running daemon are streamed, and added, one after the other. Default: 1."),
+		cmds.BoolOption(preserveOrderOptionName, "Store the order of the entries of the directories, listed by 'ipfs ls --preserved-order'. Not supported by the sharded directories."),
+		cmds.BoolOption(dryRunOptionName, "Only chunk and hash, and describe the blocks the add would write."),
 	},
 	PreRun: func(req *cmds.Request, env cmds.Environment) error {
 		quiet, _ := req.Options[quietOptionName].(bool)
@@ -206,10 +322,31 @@ See 'dag export' and 'dag import' for more information.
 		inline, _ := req.Options[inlineOptionName].(bool)
 		inlineLimit, _ := req.Options[inlineLimitOptionName].(int)
 		toFilesStr, toFilesSet := req.Options[toFilesOptionName].(string)
+		incremental, _ := req.Options[incrementalOptionName].(bool)
+		car, _ := req.Options[carOptionName].(bool)
+		memoryBudgetStr, memoryBudgetSet := req.Options[memoryBudgetOptionName].(string)
+		preserveMode, _ := req.Options[preserveModeOptionName].(bool)
+		preserveMtime, _ := req.Options[preserveMtimeOptionName].(bool)
+		modeStr, modeSet := req.Options[modeOptionName].(string)
+		mtime, mtimeSet := req.Options[mtimeOptionName].(int64)
+		mtimeNsecs, mtimeNsecsSet := req.Options[mtimeNsecsOptionName].(uint)
+		maxFileLinks, _ := req.Options[maxFileLinksOptionName].(int)
+		maxDirLinks, _ := req.Options[maxDirLinksOptionName].(int)
+		hamtFanout, _ := req.Options[hamtFanoutOptionName].(int)
+		concurrency, _ := req.Options[concurrencyOptionName].(int)
+		preserveOrder, _ := req.Options[preserveOrderOptionName].(bool)
+		dryRun, _ := req.Options[dryRunOptionName].(bool)
 
 		if onlyHash && toFilesSet {
 			return fmt.Errorf("%s and %s options are not compatible", onlyHashOptionName, toFilesOptionName)
 		}
+		if dryRun && toFilesSet {
+			return fmt.Errorf("%s and %s options are not compatible", dryRunOptionName, toFilesOptionName)
+		}
+		if repoContext, _ := req.Options[ContextOption].(string); repoContext != "" && toFilesSet {
+			// the MFS root is the one of the daemon's repo
+			return fmt.Errorf("%s and %s options are not compatible", ContextOption, toFilesOptionName)
+		}
 
 		hashFunCode, ok := mh.Names[strings.ToLower(hashFunStr)]
 		if !ok {
@@ -235,11 +372,20 @@ See 'dag export' and 'dag import' for more information.
 			options.Unixfs.InlineLimit(inlineLimit),
 
 			options.Unixfs.Chunker(chunker),
+			options.Unixfs.MaxFileLinks(maxFileLinks),
+			options.Unixfs.MaxDirectoryLinks(maxDirLinks),
+			options.Unixfs.HAMTFanout(hamtFanout),
+			options.Unixfs.AddConcurrency(concurrency),
+			options.Unixfs.PreserveOrder(preserveOrder),
 
 			options.Unixfs.Pin(dopin),
 			options.Unixfs.HashOnly(onlyHash),
 			options.Unixfs.FsCache(fscache),
 			options.Unixfs.Nocopy(nocopy),
+			options.Unixfs.Incremental(incremental),
+			options.Unixfs.Car(car),
+			options.Unixfs.PreserveMode(preserveMode),
+			options.Unixfs.PreserveMtime(preserveMtime),
 
 			options.Unixfs.Progress(progress),
 			options.Unixfs.Silent(silent),
@@ -257,7 +403,39 @@ See 'dag export' and 'dag import' for more information.
 			opts = append(opts, options.Unixfs.Layout(options.TrickleLayout))
 		}
 
-		opts = append(opts, nil) // events option placeholder
+		if memoryBudgetSet {
+			budget, err := humanize.ParseBytes(memoryBudgetStr)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", memoryBudgetOptionName, err)
+			}
+			opts = append(opts, options.Unixfs.MemoryBudget(budget))
+		}
+
+		if modeSet {
+			mode, err := parsePosixMode(modeStr)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", modeOptionName, err)
+			}
+			opts = append(opts, options.Unixfs.Mode(mode))
+		}
+
+		if mtimeNsecsSet && !mtimeSet {
+			return fmt.Errorf("%s requires %s", mtimeNsecsOptionName, mtimeOptionName)
+		}
+		if mtimeSet {
+			if mtimeNsecs >= uint(time.Second) {
+				return fmt.Errorf("%s must be lower than 1e9", mtimeNsecsOptionName)
+			}
+			opts = append(opts, options.Unixfs.Mtime(time.Unix(mtime, int64(mtimeNsecs))))
+		}
+
+		var dryRunStats *options.DryRunStats
+		if dryRun {
+			dryRunStats = new(options.DryRunStats)
+			opts = append(opts, options.Unixfs.DryRun(dryRunStats))
+		}
+
+		opts = append(opts, nil, nil) // name and events option placeholders
 
 		ipfsNode, err := cmdenv.GetNode(env)
 		if err != nil {
@@ -271,6 +449,7 @@ See 'dag export' and 'dag import' for more information.
 			errCh := make(chan error, 1)
 			events := make(chan interface{}, adderOutChanSize)
 			opts[len(opts)-1] = options.Unixfs.Events(events)
+			opts[len(opts)-2] = options.Unixfs.Name(addit.Name())
 
 			go func() {
 				var err error
@@ -306,7 +485,11 @@ See 'dag export' and 'dag import' for more information.
 							return
 						}
 						// if MFS destination is a dir, append filename to the dir path
-						toFilesDst += gopath.Base(addit.Name())
+						name := gopath.Base(addit.Name())
+						if car {
+							name = strings.TrimSuffix(name, ".car")
+						}
+						toFilesDst += name
 					}
 
 					// error if we try to overwrite a preexisting file destination
@@ -367,6 +550,12 @@ See 'dag export' and 'dag import' for more information.
 			if err := <-errCh; err != nil {
 				return err
 			}
+			if dryRunStats != nil {
+				stats := *dryRunStats
+				if err := res.Emit(&AddEvent{Name: addit.Name(), DryRun: &stats}); err != nil {
+					return err
+				}
+			}
 			added++
 		}
 
@@ -433,7 +622,15 @@ See 'dag export' and 'dag import' for more information.
 							break LOOP
 						}
 						output := out.(*AddEvent)
-						if len(output.Hash) > 0 {
+						if output.DryRun != nil {
+							if quieter {
+								continue
+							}
+							if progress {
+								fmt.Fprintf(os.Stderr, "\033[2K\r")
+							}
+							printDryRun(os.Stdout, output.Name, output.DryRun)
+						} else if len(output.Hash) > 0 {
 							lastHash = output.Hash
 							if quieter {
 								continue
@@ -0,0 +1,310 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/ipfs/boxo/path"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/alias"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/commands/cmdutils"
+	options "github.com/ipfs/kubo/core/coreiface/options"
+)
+
+// AliasOutput is an alias of a path.
+type AliasOutput struct {
+	Name string
+	Path string
+}
+
+// AliasList are aliases of paths.
+type AliasList struct {
+	Aliases []AliasOutput
+}
+
+const (
+	aliasForceOptionName = "force"
+)
+
+var AliasCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Name CIDs and IPNS names with local aliases.",
+		ShortDescription: `
+Aliases are human names of /ipfs and /ipns paths, stored in the repo. They are
+accepted by the commands taking a path as /alias/<name>, followed by the path
+within the aliased DAG:
+
+  ipfs alias set photos-2023 QmXoypizjW3WknFiJnKLwHCnL72vedxjQkDDP1mXWo6uco
+  ipfs cat /alias/photos-2023/img.jpg
+
+The aliases of an /ipns path are resolved each time they are used. The aliases
+are local to the repo: they are not published, and the gateway does not serve
+/alias paths. Move them to another repo with 'ipfs alias export' and
+'ipfs alias import'.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"set":    aliasSetCmd,
+		"get":    aliasGetCmd,
+		"ls":     aliasLsCmd,
+		"rm":     aliasRmCmd,
+		"export": aliasExportCmd,
+		"import": aliasImportCmd,
+	},
+}
+
+var aliasSetCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Set an alias of a path.",
+		ShortDescription: `
+'ipfs alias set' names a CID, an /ipfs or /ipns path, or the target of another
+alias. It fails when the name is already an alias, unless --force is passed.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "The name of the alias."),
+		cmds.StringArg("ipfs-path", true, false, "The path named by the alias."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(aliasForceOptionName, "f", "Replace an existing alias of the same name."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		force, _ := req.Options[aliasForceOptionName].(bool)
+
+		p, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[1])
+		if err != nil {
+			return err
+		}
+		if err := api.Alias().Set(req.Context, req.Arguments[0], p, options.Alias.Force(force)); err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &AliasOutput{Name: req.Arguments[0], Path: p.String()})
+	},
+	Type: AliasOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: aliasOutputEncoder,
+	},
+}
+
+var aliasGetCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Show the path of an alias.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "The name of the alias."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		p, err := api.Alias().Get(req.Context, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &AliasOutput{Name: req.Arguments[0], Path: p.String()})
+	},
+	Type: AliasOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *AliasOutput) error {
+			_, err := fmt.Fprintln(w, out.Path)
+			return err
+		}),
+	},
+}
+
+var aliasLsCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "List the aliases.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		aliases, err := api.Alias().List(req.Context)
+		if err != nil {
+			return err
+		}
+		out := &AliasList{Aliases: make([]AliasOutput, len(aliases))}
+		for i, a := range aliases {
+			out.Aliases[i] = AliasOutput{Name: a.Name, Path: a.Path.String()}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: AliasList{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: aliasListEncoder,
+	},
+}
+
+var aliasRmCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Remove aliases.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, true, "The names of the aliases to remove.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		out := &AliasList{Aliases: make([]AliasOutput, 0, len(req.Arguments))}
+		for _, name := range req.Arguments {
+			p, err := api.Alias().Get(req.Context, name)
+			if err != nil {
+				return err
+			}
+			if err := api.Alias().Remove(req.Context, name); err != nil {
+				return err
+			}
+			out.Aliases = append(out.Aliases, AliasOutput{Name: name, Path: p.String()})
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: AliasList{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: aliasListEncoder,
+	},
+}
+
+var aliasExportCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Export the aliases as JSON.",
+		ShortDescription: `
+'ipfs alias export' writes the aliases as a JSON object mapping their names to
+their paths, which 'ipfs alias import' reads:
+
+  ipfs alias export > aliases.json
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		aliases, err := api.Alias().List(req.Context)
+		if err != nil {
+			return err
+		}
+		out := &AliasList{Aliases: make([]AliasOutput, len(aliases))}
+		for i, a := range aliases {
+			out.Aliases[i] = AliasOutput{Name: a.Name, Path: a.Path.String()}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: AliasList{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *AliasList) error {
+			aliases := make(map[string]string, len(out.Aliases))
+			for _, a := range out.Aliases {
+				aliases[a.Name] = a.Path
+			}
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(aliases)
+		}),
+	},
+}
+
+var aliasImportCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Import aliases from JSON.",
+		ShortDescription: `
+'ipfs alias import' sets the aliases of a JSON object mapping names to paths,
+as written by 'ipfs alias export'. Nothing is imported when one of the names
+is already an alias, unless --force is passed.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("file", true, false, "The JSON file of the aliases.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(aliasForceOptionName, "f", "Replace the existing aliases of the same names."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		force, _ := req.Options[aliasForceOptionName].(bool)
+
+		file, err := cmdenv.GetFileArg(req.Files.Entries())
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		var in map[string]string
+		if err := json.NewDecoder(file).Decode(&in); err != nil {
+			return fmt.Errorf("invalid aliases: %w", err)
+		}
+
+		// check every alias before setting any
+		names := make([]string, 0, len(in))
+		paths := make(map[string]path.Path, len(in))
+		for name, s := range in {
+			if err := alias.ValidName(name); err != nil {
+				return err
+			}
+			p, err := path.NewPath(s)
+			if err != nil {
+				return fmt.Errorf("alias %s: %w", name, err)
+			}
+			if !force {
+				if _, err := api.Alias().Get(req.Context, name); err == nil {
+					return fmt.Errorf("alias %s already exists, use --force to replace it", name)
+				}
+			}
+			names = append(names, name)
+			paths[name] = p
+		}
+		sort.Strings(names)
+
+		out := &AliasList{Aliases: make([]AliasOutput, 0, len(names))}
+		for _, name := range names {
+			if err := api.Alias().Set(req.Context, name, paths[name], options.Alias.Force(force)); err != nil {
+				return err
+			}
+			out.Aliases = append(out.Aliases, AliasOutput{Name: name, Path: paths[name].String()})
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: AliasList{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: aliasListEncoder,
+	},
+}
+
+var aliasOutputEncoder = cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *AliasOutput) error {
+	_, err := fmt.Fprintf(w, "%s\t%s\n", out.Name, out.Path)
+	return err
+})
+
+var aliasListEncoder = cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *AliasList) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+	for _, a := range out.Aliases {
+		fmt.Fprintf(tw, "%s\t%s\n", a.Name, a.Path)
+	}
+	return nil
+})
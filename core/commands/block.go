@@ -37,10 +37,11 @@ passed with a valid CID.
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"stat": blockStatCmd,
-		"get":  blockGetCmd,
-		"put":  blockPutCmd,
-		"rm":   blockRmCmd,
+		"stat":       blockStatCmd,
+		"get":        blockGetCmd,
+		"put":        blockPutCmd,
+		"rm":         blockRmCmd,
+		"provenance": blockProvenanceCmd,
 	},
 }
 
@@ -66,7 +67,7 @@ on raw IPFS blocks. It outputs the following to stdout:
 			return err
 		}
 
-		p, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		p, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -108,7 +109,7 @@ It takes a <cid>, and outputs the block to stdout.
 			return err
 		}
 
-		p, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		p, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -265,7 +266,7 @@ It takes a list of CIDs to remove from the local datastore..
 
 		// TODO: use batching coreapi when done
 		for _, b := range req.Arguments {
-			p, err := cmdutils.PathOrCidPath(b)
+			p, err := cmdutils.PathOrAlias(req.Context, api, b)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,206 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/provenance"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// BlockProvenanceOutput is the recorded provenance of a block.
+type BlockProvenanceOutput struct {
+	Cid       string
+	Peer      string    `json:",omitempty"`
+	Transport string    `json:",omitempty"`
+	Received  time.Time `json:",omitempty"`
+	Error     string    `json:",omitempty"`
+}
+
+// BlockProvenancePeersOutput counts the recorded blocks sent by each peer.
+type BlockProvenancePeersOutput struct {
+	Peers []provenance.PeerRecords
+}
+
+// BlockProvenancePurgeOutput is the number of records purged.
+type BlockProvenancePurgeOutput struct {
+	Purged int
+}
+
+const (
+	provenanceOlderThanOptionName = "older-than"
+	provenancePeerOptionName      = "peer"
+)
+
+var blockProvenanceCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect the recorded origin of the blocks received.",
+		ShortDescription: `
+'ipfs block provenance' shows which peer sent the blocks received by Bitswap,
+over which transport, and when, to investigate the peers sending bad blocks
+and to credit the peers providing the content.
+
+The provenance is only recorded with Datastore.Provenance.Enabled, for one in
+Datastore.Provenance.Sampling of the blocks received, and the oldest records
+are replaced once Datastore.Provenance.MaxRecords is reached. A block keeps
+the record of the first time it was received.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"get":   blockProvenanceGetCmd,
+		"peers": blockProvenancePeersCmd,
+		"purge": blockProvenancePurgeCmd,
+	},
+}
+
+func getBlockProvenance(env cmds.Environment) (*provenance.Recorder, error) {
+	nd, err := cmdenv.GetNode(env)
+	if err != nil {
+		return nil, err
+	}
+	if nd.BlockProvenance == nil {
+		return nil, errors.New("the provenance of the blocks is not recorded, see Datastore.Provenance.Enabled, or the node is offline")
+	}
+	return nd.BlockProvenance, nil
+}
+
+var blockProvenanceGetCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Show which peer sent blocks.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("cid", true, true, "The CIDs of the blocks.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		rec, err := getBlockProvenance(env)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		for _, arg := range req.Arguments {
+			out := &BlockProvenanceOutput{Cid: arg}
+			c, err := cid.Decode(arg)
+			if err == nil {
+				out.Cid = enc.Encode(c)
+				var r provenance.Record
+				if r, err = rec.Get(req.Context, c); err == nil {
+					out.Peer = r.Peer.String()
+					out.Transport = r.Transport
+					out.Received = r.Received
+				}
+			}
+			if err != nil {
+				out.Error = err.Error()
+			}
+			if err := res.Emit(out); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: BlockProvenanceOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *BlockProvenanceOutput) error {
+			if out.Error != "" {
+				_, err := fmt.Fprintf(w, "%s: %s\n", out.Cid, out.Error)
+				return err
+			}
+			_, err := fmt.Fprintf(w, "%s %s %s %s\n", out.Cid, out.Peer, out.Transport, out.Received.Format(time.RFC3339))
+			return err
+		}),
+	},
+}
+
+var blockProvenancePeersCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Count the recorded blocks sent by each peer.",
+		ShortDescription: `
+'ipfs block provenance peers' lists the peers the recorded blocks were
+received from, with the number of blocks and when the last one was received,
+the peers with the most blocks first.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		rec, err := getBlockProvenance(env)
+		if err != nil {
+			return err
+		}
+		peers, err := rec.Peers(req.Context)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &BlockProvenancePeersOutput{Peers: peers})
+	},
+	Type: BlockProvenancePeersOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *BlockProvenancePeersOutput) error {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			for _, p := range out.Peers {
+				fmt.Fprintf(tw, "%s\t%d blocks\tlast %s\n", p.Peer, p.Blocks, p.Last.Format(time.RFC3339))
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+var blockProvenancePurgeCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Delete provenance records.",
+		ShortDescription: `
+'ipfs block provenance purge' deletes all the provenance records, or only the
+records older than --older-than, and only the records of --peer when given.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(provenanceOlderThanOptionName, "Only delete the records of the blocks received longer ago than this duration, e.g. '24h'."),
+		cmds.StringOption(provenancePeerOptionName, "Only delete the records of the blocks sent by this peer."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		rec, err := getBlockProvenance(env)
+		if err != nil {
+			return err
+		}
+
+		var before time.Time
+		if s, ok := req.Options[provenanceOlderThanOptionName].(string); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", provenanceOlderThanOptionName, err)
+			}
+			before = time.Now().Add(-d)
+		}
+		var p peer.ID
+		if s, ok := req.Options[provenancePeerOptionName].(string); ok {
+			if p, err = peer.Decode(s); err != nil {
+				return fmt.Errorf("invalid %s: %w", provenancePeerOptionName, err)
+			}
+		}
+
+		n, err := rec.Purge(req.Context, before, p)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &BlockProvenancePurgeOutput{Purged: n})
+	},
+	Type: BlockProvenancePurgeOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *BlockProvenancePurgeOutput) error {
+			_, err := fmt.Fprintf(w, "purged %d records\n", out.Purged)
+			return err
+		}),
+	},
+}
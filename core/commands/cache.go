@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	options "github.com/ipfs/kubo/core/coreiface/options"
+)
+
+// CacheWarmFailure is a path that couldn't be warmed.
+type CacheWarmFailure struct {
+	Path  string
+	Error string
+}
+
+// CacheWarmOutput is the outcome of 'ipfs cache warm'.
+type CacheWarmOutput struct {
+	Paths     int
+	Warmed    int
+	Protected int
+	Failures  []CacheWarmFailure `json:",omitempty"`
+}
+
+const (
+	cacheWarmPinTTLOptionName      = "pin-ttl"
+	cacheWarmConcurrencyOptionName = "concurrency"
+	cacheWarmLimitOptionName       = "limit"
+)
+
+var CacheCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the content cached in the blockstore.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"warm": cacheWarmCmd,
+	},
+}
+
+var cacheWarmCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Prefetch the content requested in a log.",
+		ShortDescription: `
+'ipfs cache warm' fetches the DAGs of the paths and CIDs of a log, the most
+requested first, so that content with predictable daily traffic is local
+before it is requested again. The log is either a list of CIDs and paths, one
+per line, or an access log, such as the log of a gateway: the first /ipfs or
+/ipns path of each line is the path it requested.
+
+  > ipfs cache warm --pin-ttl 24h --limit 10000 /var/log/nginx/access.log.1
+
+With --pin-ttl, the warmed DAGs are protected from garbage collection for the
+given duration. Warming a DAG again extends its protection.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("log", true, false, "The log of the paths to warm.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(cacheWarmPinTTLOptionName, "Protect the warmed DAGs from garbage collection for this duration, e.g. '24h'."),
+		cmds.IntOption(cacheWarmConcurrencyOptionName, "Number of paths fetched at the same time.").WithDefault(8),
+		cmds.IntOption(cacheWarmLimitOptionName, "Warm only this many of the most requested paths. 0 warms all of them."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		concurrency, _ := req.Options[cacheWarmConcurrencyOptionName].(int)
+		limit, _ := req.Options[cacheWarmLimitOptionName].(int)
+		var ttl time.Duration
+		if s, ok := req.Options[cacheWarmPinTTLOptionName].(string); ok {
+			if ttl, err = time.ParseDuration(s); err != nil {
+				return fmt.Errorf("invalid --%s: %w", cacheWarmPinTTLOptionName, err)
+			}
+		}
+
+		file, err := cmdenv.GetFileArg(req.Files.Entries())
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		result, err := api.Cache().WarmFromLog(req.Context, file,
+			options.Cache.PinTTL(ttl),
+			options.Cache.Concurrency(concurrency),
+			options.Cache.Limit(limit))
+		if err != nil {
+			return err
+		}
+
+		out := &CacheWarmOutput{
+			Paths:     result.Paths,
+			Warmed:    result.Warmed,
+			Protected: result.Protected,
+		}
+		for _, f := range result.Failures {
+			out.Failures = append(out.Failures, CacheWarmFailure{Path: f.Path.String(), Error: f.Error})
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: CacheWarmOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *CacheWarmOutput) error {
+			for _, f := range out.Failures {
+				fmt.Fprintf(w, "failed %s: %s\n", f.Path, f.Error)
+			}
+			fmt.Fprintf(w, "warmed %d of %d paths", out.Warmed, out.Paths)
+			if out.Protected > 0 {
+				fmt.Fprintf(w, ", %d protected", out.Protected)
+			}
+			fmt.Fprintln(w)
+			return nil
+		}),
+	},
+}
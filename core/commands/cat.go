@@ -13,18 +13,27 @@ import (
 	"github.com/ipfs/boxo/files"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	iface "github.com/ipfs/kubo/core/coreiface"
+	options "github.com/ipfs/kubo/core/coreiface/options"
 )
 
 const (
-	progressBarMinSize = 1024 * 1024 * 8 // show progress bar for outputs > 8MiB
-	offsetOptionName   = "offset"
-	lengthOptionName   = "length"
+	progressBarMinSize    = 1024 * 1024 * 8 // show progress bar for outputs > 8MiB
+	offsetOptionName      = "offset"
+	lengthOptionName      = "length"
+	progressiveOptionName = "progressive"
 )
 
 var CatCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline:          "Show IPFS object data.",
 		ShortDescription: "Displays the data contained by an IPFS or IPNS object(s) at the given path.",
+		LongDescription: `
+Displays the data contained by an IPFS or IPNS object(s) at the given path.
+
+With --progressive, the blocks of the files are fetched ahead of the output,
+in parallel, and their data is output as soon as it and the data before it
+are fetched and verified. This suits streaming large files, such as videos.
+`,
 	},
 
 	Arguments: []cmds.Argument{
@@ -34,6 +43,7 @@ var CatCmd = &cmds.Command{
 		cmds.Int64Option(offsetOptionName, "o", "Byte offset to begin reading from."),
 		cmds.Int64Option(lengthOptionName, "l", "Maximum number of bytes to read."),
 		cmds.BoolOption(progressOptionName, "p", "Stream progress data.").WithDefault(true),
+		cmds.BoolOption(progressiveOptionName, "Fetch the blocks ahead of the output, in parallel, and output the data as it arrives."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -60,7 +70,9 @@ var CatCmd = &cmds.Command{
 			return err
 		}
 
-		readers, length, err := cat(req.Context, api, req.Arguments, int64(offset), int64(max))
+		progressive, _ := req.Options[progressiveOptionName].(bool)
+
+		readers, length, err := cat(req.Context, api, req.Arguments, int64(offset), int64(max), options.Unixfs.Progressive(progressive))
 		if err != nil {
 			return err
 		}
@@ -121,19 +133,27 @@ var CatCmd = &cmds.Command{
 	},
 }
 
-func cat(ctx context.Context, api iface.CoreAPI, paths []string, offset int64, max int64) ([]io.Reader, uint64, error) {
+func cat(ctx context.Context, api iface.CoreAPI, paths []string, offset int64, max int64, opts ...options.UnixfsGetOption) ([]io.Reader, uint64, error) {
 	readers := make([]io.Reader, 0, len(paths))
 	length := uint64(0)
 	if max == 0 {
 		return nil, 0, nil
 	}
+	if len(paths) == 1 {
+		// the blocks after the range of a single file are not fetched
+		opts = append(opts, options.Unixfs.GetOffset(offset))
+		if max > 0 {
+			opts = append(opts, options.Unixfs.GetLength(max))
+		}
+		offset = 0
+	}
 	for _, pString := range paths {
-		p, err := cmdutils.PathOrCidPath(pString)
+		p, err := cmdutils.PathOrAlias(ctx, api, pString)
 		if err != nil {
 			return nil, 0, err
 		}
 
-		f, err := api.Unixfs().Get(ctx, p)
+		f, err := api.Unixfs().Get(ctx, p, opts...)
 		if err != nil {
 			return nil, 0, err
 		}
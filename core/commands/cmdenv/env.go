@@ -40,12 +40,13 @@ func GetApi(env cmds.Environment, req *cmds.Request) (coreiface.CoreAPI, error)
 			log.Errorf("Command '%s', --local is deprecated, use --offline instead", strings.Join(req.Path, " "))
 		}
 	}
+	repoContext, _ := req.Options["context"].(string)
 	api, err := ctx.GetAPI()
 	if err != nil {
 		return nil, err
 	}
-	if offline {
-		return api.WithOptions(options.Api.Offline(offline))
+	if offline || repoContext != "" {
+		return api.WithOptions(options.Api.Offline(offline), options.Api.Context(repoContext))
 	}
 
 	return api, nil
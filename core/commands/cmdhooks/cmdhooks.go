@@ -0,0 +1,121 @@
+// Package cmdhooks runs middlewares around the RPC command invocations, so
+// that plugins can audit the commands run on the node, or deny some of them,
+// without changing the commands themselves.
+package cmdhooks
+
+import (
+	"context"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Caller identifies who invoked a command.
+type Caller struct {
+	// User is the API.Authorizations user the request was authorized as, if
+	// any.
+	User string
+	// Peer is the RemoteAdmin operator that sent the request, if any.
+	Peer peer.ID
+	// RemoteAddr is the address the request came from.
+	RemoteAddr string
+}
+
+// Invocation describes an RPC command invocation.
+type Invocation struct {
+	// Command is the path of the command, e.g. "repo/gc".
+	Command   string
+	Arguments []string
+	Options   map[string]interface{}
+	Caller    Caller
+}
+
+// Middleware runs around every RPC command invocation.
+type Middleware interface {
+	// Before is called before the command runs. An error denies the
+	// invocation and is returned to the caller instead.
+	Before(ctx context.Context, inv *Invocation) error
+	// After is called once the command returned, with its error.
+	After(ctx context.Context, inv *Invocation, err error)
+}
+
+var middlewares []Middleware
+
+// Register registers a middleware for the RPC command invocations. The
+// middlewares run in the order they were registered, and After in the reverse
+// order.
+//
+// Register must be called before the daemon starts serving the RPC API, such
+// as when plugins are injected.
+func Register(m Middleware) {
+	middlewares = append(middlewares, m)
+}
+
+type callerKey struct{}
+
+// ContextWithCaller returns a context carrying c, which the HTTP handlers
+// fill in as they authenticate the request.
+func ContextWithCaller(ctx context.Context, c *Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, c)
+}
+
+// CallerFromContext returns the caller carried by ctx, or nil.
+func CallerFromContext(ctx context.Context) *Caller {
+	c, _ := ctx.Value(callerKey{}).(*Caller)
+	return c
+}
+
+// Wrap returns a copy of the command tree of root whose commands run the
+// registered middlewares around their Run function. root itself is returned
+// when no middleware is registered.
+func Wrap(root *cmds.Command) *cmds.Command {
+	if len(middlewares) == 0 {
+		return root
+	}
+	return wrap(root, middlewares)
+}
+
+func wrap(c *cmds.Command, mws []Middleware) *cmds.Command {
+	wrapped := *c
+	if c.Run != nil {
+		wrapped.Run = wrapRun(c.Run, mws)
+	}
+	if c.Subcommands != nil {
+		wrapped.Subcommands = make(map[string]*cmds.Command, len(c.Subcommands))
+		for name, sub := range c.Subcommands {
+			wrapped.Subcommands[name] = wrap(sub, mws)
+		}
+	}
+	return &wrapped
+}
+
+func wrapRun(run cmds.Function, mws []Middleware) cmds.Function {
+	return func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		inv := &Invocation{
+			Command:   strings.Join(req.Path, "/"),
+			Arguments: req.Arguments,
+			Options:   req.Options,
+		}
+		if c := CallerFromContext(req.Context); c != nil {
+			inv.Caller = *c
+		}
+
+		ctx := req.Context
+		for i, m := range mws {
+			if err := m.Before(ctx, inv); err != nil {
+				// the middlewares that ran see the denial
+				for j := i - 1; j >= 0; j-- {
+					mws[j].After(ctx, inv, err)
+				}
+				return cmds.Errorf(cmds.ErrForbidden, "%s", err)
+			}
+		}
+
+		err := run(req, res, env)
+		for i := len(mws) - 1; i >= 0; i-- {
+			mws[i].After(ctx, inv, err)
+		}
+		return err
+	}
+}
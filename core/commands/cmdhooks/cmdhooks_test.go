@@ -0,0 +1,77 @@
+package cmdhooks
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	name  string
+	deny  string
+	calls *[]string
+}
+
+func (r recorder) Before(_ context.Context, inv *Invocation) error {
+	*r.calls = append(*r.calls, r.name+" before "+inv.Command+" by "+inv.Caller.User)
+	if inv.Command == r.deny {
+		return errors.New("denied")
+	}
+	return nil
+}
+
+func (r recorder) After(_ context.Context, inv *Invocation, err error) {
+	*r.calls = append(*r.calls, r.name+" after "+inv.Command)
+}
+
+func TestWrap(t *testing.T) {
+	var ran []string
+	gc := &cmds.Command{Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		ran = append(ran, "gc")
+		return nil
+	}}
+	stat := &cmds.Command{Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		ran = append(ran, "stat")
+		return nil
+	}}
+	root := &cmds.Command{Subcommands: map[string]*cmds.Command{
+		"repo": {Subcommands: map[string]*cmds.Command{"gc": gc, "stat": stat}},
+	}}
+
+	var calls []string
+	wrapped := wrap(root, []Middleware{
+		recorder{name: "audit", calls: &calls},
+		recorder{name: "policy", deny: "repo/gc", calls: &calls},
+	})
+	// the original tree is left as is
+	require.NotSame(t, gc, wrapped.Subcommands["repo"].Subcommands["gc"])
+	require.Same(t, gc, root.Subcommands["repo"].Subcommands["gc"])
+
+	ctx := ContextWithCaller(context.Background(), &Caller{User: "alice"})
+	call := func(path ...string) error {
+		cmd, err := wrapped.Get(path)
+		require.NoError(t, err)
+		return cmd.Run(&cmds.Request{Context: ctx, Path: path}, nil, nil)
+	}
+
+	require.NoError(t, call("repo", "stat"))
+	require.Equal(t, []string{
+		"audit before repo/stat by alice",
+		"policy before repo/stat by alice",
+		"policy after repo/stat",
+		"audit after repo/stat",
+	}, calls)
+
+	calls = nil
+	err := call("repo", "gc")
+	require.ErrorContains(t, err, "denied")
+	require.Equal(t, []string{
+		"audit before repo/gc by alice",
+		"policy before repo/gc by alice",
+		"audit after repo/gc",
+	}, calls)
+	require.Equal(t, []string{"stat"}, ran)
+}
@@ -1,12 +1,14 @@
 package cmdutils
 
 import (
+	"context"
 	"fmt"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 
 	"github.com/ipfs/boxo/path"
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/core/alias"
 	coreiface "github.com/ipfs/kubo/core/coreiface"
 )
 
@@ -65,3 +67,12 @@ func PathOrCidPath(str string) (path.Path, error) {
 	// Send back original err.
 	return nil, err
 }
+
+// PathOrAlias is PathOrCidPath, also accepting the /alias/<name>/... paths,
+// which it expands with the aliases of api.
+func PathOrAlias(ctx context.Context, api coreiface.CoreAPI, str string) (path.Path, error) {
+	if _, _, ok := alias.Split(str); !ok {
+		return PathOrCidPath(str)
+	}
+	return api.Alias().Expand(ctx, str)
+}
@@ -75,6 +75,15 @@ func TestROCommands(t *testing.T) {
 func TestCommands(t *testing.T) {
 	list := []string{
 		"/add",
+		"/admin",
+		"/admin/call",
+		"/alias",
+		"/alias/export",
+		"/alias/get",
+		"/alias/import",
+		"/alias/ls",
+		"/alias/rm",
+		"/alias/set",
 		"/bitswap",
 		"/bitswap/ledger",
 		"/bitswap/reprovide",
@@ -82,6 +91,10 @@ func TestCommands(t *testing.T) {
 		"/bitswap/wantlist",
 		"/block",
 		"/block/get",
+		"/block/provenance",
+		"/block/provenance/get",
+		"/block/provenance/peers",
+		"/block/provenance/purge",
 		"/block/put",
 		"/block/rm",
 		"/block/stat",
@@ -91,6 +104,8 @@ func TestCommands(t *testing.T) {
 		"/bootstrap/list",
 		"/bootstrap/rm",
 		"/bootstrap/rm/all",
+		"/cache",
+		"/cache/warm",
 		"/cat",
 		"/cid",
 		"/cid/base32",
@@ -111,8 +126,12 @@ func TestCommands(t *testing.T) {
 		"/config/show",
 		"/dag",
 		"/dag/export",
+		"/dag/fetch",
+		"/dag/inspect",
+		"/dag/transcode",
 		"/dag/get",
 		"/dag/import",
+		"/dag/prune",
 		"/dag/put",
 		"/dag/resolve",
 		"/dag/stat",
@@ -124,14 +143,20 @@ func TestCommands(t *testing.T) {
 		"/routing/findpeer",
 		"/routing/findprovs",
 		"/routing/provide",
+		"/routing/check",
+		"/routing/estimate",
 		"/diag",
 		"/diag/cmds",
 		"/diag/cmds/clear",
 		"/diag/cmds/set-time",
 		"/diag/profile",
+		"/diag/hashing",
+		"/diag/listeners",
+		"/diag/shards",
 		"/diag/sys",
 		"/files",
 		"/files/chcid",
+		"/files/chmod",
 		"/files/cp",
 		"/files/flush",
 		"/files/ls",
@@ -140,15 +165,24 @@ func TestCommands(t *testing.T) {
 		"/files/read",
 		"/files/rm",
 		"/files/stat",
+		"/files/symlink",
+		"/files/sync",
+		"/files/touch",
+		"/files/ttl",
+		"/files/ttl/clear",
+		"/files/ttl/ls",
+		"/files/ttl/set",
 		"/files/write",
 		"/filestore",
 		"/filestore/dups",
 		"/filestore/ls",
+		"/filestore/sources",
 		"/filestore/verify",
 		"/get",
 		"/id",
 		"/key",
 		"/key/export",
+		"/key/derive",
 		"/key/gen",
 		"/key/import",
 		"/key/list",
@@ -157,6 +191,12 @@ func TestCommands(t *testing.T) {
 		"/key/rotate",
 		"/key/sign",
 		"/key/verify",
+		"/kv",
+		"/kv/get",
+		"/kv/ls",
+		"/kv/put",
+		"/kv/rm",
+		"/kv/sub",
 		"/log",
 		"/log/level",
 		"/log/ls",
@@ -175,6 +215,7 @@ func TestCommands(t *testing.T) {
 		"/name/pubsub/cancel",
 		"/name/pubsub/state",
 		"/name/pubsub/subs",
+		"/name/purge",
 		"/name/resolve",
 		"/object",
 		"/object/data",
@@ -194,12 +235,25 @@ func TestCommands(t *testing.T) {
 		"/p2p/forward",
 		"/p2p/listen",
 		"/p2p/ls",
+		"/p2p/send",
 		"/p2p/stream",
 		"/p2p/stream/close",
 		"/p2p/stream/ls",
 		"/pin",
 		"/pin/add",
+		"/pin/control",
+		"/pin/control/add",
+		"/pin/control/rm",
+		"/pin/export",
 		"/pin/ls",
+		"/pin/provide-class",
+		"/pin/provide-class/ls",
+		"/pin/provide-class/set",
+		"/pin/push",
+		"/pin/queue",
+		"/pin/queue/cancel",
+		"/pin/queue/ls",
+		"/pin/queue/priority",
 		"/pin/remote",
 		"/pin/remote/add",
 		"/pin/remote/ls",
@@ -208,38 +262,66 @@ func TestCommands(t *testing.T) {
 		"/pin/remote/service/add",
 		"/pin/remote/service/ls",
 		"/pin/remote/service/rm",
+		"/pin/report",
+		"/pin/removed",
+		"/pin/restore",
+		"/pin/reconcile",
 		"/pin/rm",
 		"/pin/update",
 		"/pin/verify",
 		"/ping",
+		"/preview",
 		"/pubsub",
 		"/pubsub/ls",
 		"/pubsub/peers",
 		"/pubsub/pub",
 		"/pubsub/sub",
+		"/readonly",
+		"/readonly/disable",
+		"/readonly/enable",
+		"/readonly/status",
 		"/refs",
 		"/refs/local",
 		"/repo",
 		"/repo/gc",
+		"/repo/gc-report",
 		"/repo/migrate",
+		"/repo/namespaces",
 		"/repo/stat",
 		"/repo/verify",
 		"/repo/version",
 		"/repo/ls",
+		"/repo/forecast",
+		"/repo/contexts",
+		"/repo/writeback",
+		"/repo/writeback/flush",
 		"/resolve",
+		"/search",
+		"/search/text",
 		"/shutdown",
+		"/spec",
+		"/standby",
+		"/standby/promote",
+		"/standby/status",
+		"/standby/sync",
 		"/stats",
 		"/stats/bitswap",
 		"/stats/bw",
 		"/stats/dht",
+		"/stats/dht/health",
 		"/stats/provide",
+		"/stats/compression",
+		"/stats/remote",
 		"/stats/repo",
 		"/swarm",
 		"/swarm/addrs",
 		"/swarm/addrs/listen",
 		"/swarm/addrs/local",
+		"/swarm/bandwidth",
 		"/swarm/connect",
 		"/swarm/disconnect",
+		"/swarm/events",
+		"/swarm/events/stats",
 		"/swarm/filters",
 		"/swarm/filters/add",
 		"/swarm/filters/rm",
@@ -248,6 +330,13 @@ func TestCommands(t *testing.T) {
 		"/swarm/peering/add",
 		"/swarm/peering/ls",
 		"/swarm/peering/rm",
+		"/swarm/peerstore",
+		"/swarm/peerstore/ls",
+		"/swarm/peerstore/rm",
+		"/swarm/peerstore/stat",
+		"/swarm/policy",
+		"/swarm/policy/add",
+		"/swarm/policy/rm",
 		"/swarm/resources",
 		"/update",
 		"/version",
@@ -280,3 +369,14 @@ func TestCommands(t *testing.T) {
 		}
 	}
 }
+
+func TestContextCommands(t *testing.T) {
+	cmdSet := make(map[string]struct{})
+	collectPaths("", Root, cmdSet)
+
+	for path := range contextCommands {
+		if _, ok := cmdSet[path]; !ok {
+			t.Errorf("%q honors --context but isn't a command", path)
+		}
+	}
+}
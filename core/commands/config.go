@@ -25,14 +25,16 @@ type ConfigUpdateOutput struct {
 }
 
 type ConfigField struct {
-	Key   string
-	Value interface{}
+	Key     string
+	Value   interface{}
+	Sources []string `json:",omitempty"`
 }
 
 const (
 	configBoolOptionName   = "bool"
 	configJSONOptionName   = "json"
 	configDryRunOptionName = "dry-run"
+	configSourceOptionName = "show-source"
 )
 
 var ConfigCmd = &cmds.Command{
@@ -55,6 +57,14 @@ Get the value of the 'Datastore.Path' key:
 Set the value of the 'Datastore.Path' key:
 
   $ ipfs config Datastore.Path ~/.ipfs/datastore
+
+Values set in the config file can be overridden by the JSON files in the
+'config.d' directory next to it, applied in the lexical order of their names,
+and then by IPFS_CONFIG_* environment variables, e.g.
+IPFS_CONFIG_Swarm_ConnMgr_HighWater=200. Reading a key returns its effective
+value. Use --show-source to also show where the value comes from:
+
+  $ ipfs config --show-source Swarm.ConnMgr.HighWater
 `,
 	},
 	Subcommands: map[string]*cmds.Command{
@@ -70,6 +80,7 @@ Set the value of the 'Datastore.Path' key:
 	Options: []cmds.Option{
 		cmds.BoolOption(configBoolOptionName, "Set a boolean value."),
 		cmds.BoolOption(configJSONOptionName, "Parse stringified JSON."),
+		cmds.BoolOption(configSourceOptionName, "Show the config layers the value comes from."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		args := req.Arguments
@@ -115,6 +126,8 @@ Set the value of the 'Datastore.Path' key:
 			} else {
 				output, err = setConfig(r, key, value)
 			}
+		} else if showSource, _ := req.Options[configSourceOptionName].(bool); showSource {
+			output, err = getConfigSources(r, key)
 		} else {
 			output, err = getConfig(r, key)
 		}
@@ -136,6 +149,9 @@ Set the value of the 'Datastore.Path' key:
 				return err
 			}
 			buf = append(buf, byte('\n'))
+			if len(out.Sources) > 0 {
+				buf = append([]byte(strings.Join(out.Sources, ", ")+"\t"), buf...)
+			}
 
 			_, err = w.Write(buf)
 			return err
@@ -493,6 +509,18 @@ func getConfig(r repo.Repo, key string) (*ConfigField, error) {
 	}, nil
 }
 
+func getConfigSources(r repo.Repo, key string) (*ConfigField, error) {
+	value, sources, err := r.ConfigKeySources(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config value: %q", err)
+	}
+	return &ConfigField{
+		Key:     key,
+		Value:   value,
+		Sources: sources,
+	}, nil
+}
+
 func setConfig(r repo.Repo, key string, value interface{}) (*ConfigField, error) {
 	err := r.SetConfigKey(key, value)
 	if err != nil {
@@ -513,8 +541,20 @@ func editConfig(filename string) error {
 }
 
 func replaceConfig(r repo.Repo, file io.Reader) error {
+	buf, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	unknown, err := config.SplitUnknownKeys(config.ValidateJSON(buf))
+	for _, e := range unknown {
+		log.Warnf("config replace: ignoring config key %s", e)
+	}
+	if err != nil {
+		return err
+	}
+
 	var newCfg config.Config
-	if err := json.NewDecoder(file).Decode(&newCfg); err != nil {
+	if err := json.Unmarshal(buf, &newCfg); err != nil {
 		return errors.New("failed to decode file as config")
 	}
 
@@ -20,6 +20,10 @@ const (
 	progressOptionName = "progress"
 	silentOptionName   = "silent"
 	statsOptionName    = "stats"
+	pinOptionName      = "pin"
+	versionOptionName  = "version"
+	dedupeOptionName   = "dedupe"
+	selectorOptionName = "selector"
 )
 
 // DagCmd provides a subset of commands for interacting with ipld dag objects
@@ -34,12 +38,16 @@ the existing 'ipfs object' command moving forward.
 `,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"put":     DagPutCmd,
-		"get":     DagGetCmd,
-		"resolve": DagResolveCmd,
-		"import":  DagImportCmd,
-		"export":  DagExportCmd,
-		"stat":    DagStatCmd,
+		"put":       DagPutCmd,
+		"get":       DagGetCmd,
+		"resolve":   DagResolveCmd,
+		"import":    DagImportCmd,
+		"export":    DagExportCmd,
+		"stat":      DagStatCmd,
+		"fetch":     DagFetchCmd,
+		"inspect":   DagInspectCmd,
+		"transcode": DagTranscodeCmd,
+		"prune":     DagPruneCmd,
 	},
 }
 
@@ -272,6 +280,158 @@ CAR file follows the CARv1 format: https://ipld.io/specs/transport/car/carv1/
 	},
 }
 
+// DagFetchOutput is the output type of 'dag fetch' command
+type DagFetchOutput struct {
+	Root   cid.Cid
+	Blocks uint64
+	Bytes  uint64
+}
+
+// DagFetchCmd is a command for fetching a dag from a single peer
+var DagFetchCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Fetch a complete DAG from a single peer.",
+		ShortDescription: `
+'ipfs dag fetch' dials only the given peer and fetches the full DAG under
+root from it. Content routing is never used and no other peer is asked for
+blocks; every block is verified against its CID before it is stored.
+
+The peer address must include the peer ID:
+
+  > ipfs dag fetch bafy... /ip4/10.0.0.5/tcp/4001/p2p/12D3KooW...
+
+The global --timeout option bounds the time the whole fetch may take.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "CID of the DAG root to fetch."),
+		cmds.StringArg("peer", true, false, "Multiaddr of the peer to fetch from, ending with /p2p/<peer-id>."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(pinOptionName, "Recursively pin the DAG once fetched.").WithDefault(false),
+	},
+	Run:  dagFetch,
+	Type: DagFetchOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *DagFetchOutput) error {
+			fmt.Fprintf(w, "fetched %s: %d blocks, %d bytes\n", out.Root, out.Blocks, out.Bytes)
+			return nil
+		}),
+	},
+}
+
+// CarInspectOutput is the output type of 'dag inspect' command
+type CarInspectOutput struct {
+	Version      uint64
+	Roots        []cid.Cid
+	MissingRoots []cid.Cid `json:",omitempty"`
+	Blocks       uint64
+	Bytes        uint64
+	Codecs       []CarCodec
+	HasIndex     bool
+	IndexCodec   string `json:",omitempty"`
+	IndexValid   bool
+	Errors       []CarError `json:",omitempty"`
+}
+
+// CarCodec is the number of blocks of a codec in 'dag inspect' output
+type CarCodec struct {
+	Codec  string
+	Blocks uint64
+}
+
+// CarError is a malformed section in 'dag inspect' output
+type CarError struct {
+	Offset uint64
+	Error  string
+}
+
+// DagInspectCmd is a command for inspecting a car without importing it
+var DagInspectCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect a .car file without importing it.",
+		ShortDescription: `
+'ipfs dag inspect' reads a .car file and reports its version, roots, number
+of blocks and codecs, whether it has a valid index (CARv2), and the malformed
+sections found, such as truncated sections or blocks that don't match their
+hash. Nothing is written to the repo.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("path", true, false, "The path of a .car file.").EnableStdin(),
+	},
+	Run:  dagInspect,
+	Type: CarInspectOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *CarInspectOutput) error {
+			fmt.Fprintf(w, "Version: %d\n", out.Version)
+			fmt.Fprintln(w, "Roots:")
+			for _, c := range out.Roots {
+				fmt.Fprintf(w, "  %s\n", c)
+			}
+			for _, c := range out.MissingRoots {
+				fmt.Fprintf(w, "Root not in the CAR: %s\n", c)
+			}
+			fmt.Fprintf(w, "Blocks: %d (%d bytes)\n", out.Blocks, out.Bytes)
+			fmt.Fprintln(w, "Codecs:")
+			for _, c := range out.Codecs {
+				fmt.Fprintf(w, "  %s: %d\n", c.Codec, c.Blocks)
+			}
+			if out.HasIndex {
+				valid := "valid"
+				if !out.IndexValid {
+					valid = "invalid"
+				}
+				fmt.Fprintf(w, "Index: %s (%s)\n", out.IndexCodec, valid)
+			} else {
+				fmt.Fprintln(w, "Index: none")
+			}
+			for _, e := range out.Errors {
+				fmt.Fprintf(w, "Error at offset %d: %s\n", e.Offset, e.Error)
+			}
+			return nil
+		}),
+	},
+}
+
+// DagPruneOutput is the output type of 'dag prune' command
+type DagPruneOutput struct {
+	Root   cid.Cid
+	Blocks uint64
+	Bytes  uint64
+}
+
+// DagPruneCmd is a command for removing a subtree from a dag
+var DagPruneCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove a subtree from a DAG.",
+		ShortDescription: `
+'ipfs dag prune' returns the root of a new DAG without the subtree at the
+given path, relative to root. UnixFS directories, including HAMT sharded
+ones, and dag-pb nodes can be pruned. If root is recursively pinned, the pin
+is moved to the new root, and the number and size of the blocks that are no
+longer pinned, and will be removed by the next garbage collection, are
+reported.
+
+  > ipfs dag prune bafy... photos/2019
+
+The whole DAG must be available locally.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "CID of the DAG root."),
+		cmds.StringArg("path", true, false, "Path of the subtree to remove, relative to root."),
+	},
+	Run:  dagPrune,
+	Type: DagPruneOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *DagPruneOutput) error {
+			fmt.Fprintf(w, "%s\n%d blocks, %d bytes no longer pinned\n", out.Root, out.Blocks, out.Bytes)
+			return nil
+		}),
+	},
+}
+
 // DagStat is a dag stat command response
 type DagStat struct {
 	Cid       cid.Cid `json:",omitempty"`
@@ -412,3 +572,40 @@ Note: This command skips duplicate blocks in reporting both size and the number
 		),
 	},
 }
+
+// DagTranscodeCmd is a command for converting a car between versions
+var DagTranscodeCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Convert a .car file between CARv1 and CARv2, streamed on stdout.",
+		ShortDescription: `
+'ipfs dag transcode' reads a .car file and writes it out as a CARv1, or as a
+CARv2 with a new index, without importing its blocks:
+
+  > ipfs dag transcode --version=2 data.car > data.v2.car
+
+With --dedupe, duplicated blocks are written once. With --selector, only the
+blocks matched by the given IPLD selector (in DAG-JSON) from the roots of the
+CAR are written, in traversal order, e.g. to keep a single level of links:
+
+  > ipfs dag transcode data.car \
+      --selector='{"R":{"l":{"depth":1},":>":{"a":{">":{"@":{}}}}}}'
+
+Blocks are streamed. CARv2 files and filtered CARs are spooled to a temporary
+file first, since their header, or the traversal, needs the whole CAR.
+Malformed CARs are refused, see 'ipfs dag inspect'.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("path", true, false, "The path of a .car file.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.Uint64Option(versionOptionName, "Version of the CAR written, 1 or 2.").WithDefault(uint64(2)),
+		cmds.BoolOption(dedupeOptionName, "Write duplicated blocks once.").WithDefault(false),
+		cmds.StringOption(selectorOptionName, "IPLD selector, in DAG-JSON, of the blocks written."),
+		cmds.BoolOption(progressOptionName, "p", "Display progress on CLI. Defaults to true when STDERR is a TTY."),
+	},
+	Run: dagTranscode,
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: finishCLIExport,
+	},
+}
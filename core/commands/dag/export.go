@@ -22,13 +22,13 @@ import (
 )
 
 func dagExport(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
-	// Accept CID or a content path
-	p, err := cmdutils.PathOrCidPath(req.Arguments[0])
+	api, err := cmdenv.GetApi(env, req)
 	if err != nil {
 		return err
 	}
 
-	api, err := cmdenv.GetApi(env, req)
+	// Accept CID or a content path
+	p, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,41 @@
+package dagcmd
+
+import (
+	"fmt"
+
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func dagFetch(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+	api, err := cmdenv.GetApi(env, req)
+	if err != nil {
+		return err
+	}
+
+	root, err := cid.Decode(req.Arguments[0])
+	if err != nil {
+		return fmt.Errorf("invalid root CID: %w", err)
+	}
+	from, err := peer.AddrInfoFromString(req.Arguments[1])
+	if err != nil {
+		return fmt.Errorf("invalid peer address, expected a multiaddr ending with /p2p/<peer-id>: %w", err)
+	}
+
+	pin, _ := req.Options[pinOptionName].(bool)
+	opts := []options.DagFetchFromOption{options.Dag.Pin(pin)}
+
+	stats, err := api.Dag().FetchFrom(req.Context, root, *from, opts...)
+	if err != nil {
+		return err
+	}
+
+	return cmds.EmitOnce(res, &DagFetchOutput{
+		Root:   root,
+		Blocks: stats.Blocks,
+		Bytes:  stats.Bytes,
+	})
+}
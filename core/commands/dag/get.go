@@ -29,7 +29,7 @@ func dagGet(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) e
 		return err
 	}
 
-	p, err := cmdutils.PathOrCidPath(req.Arguments[0])
+	p, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 	if err != nil {
 		return err
 	}
@@ -16,6 +16,7 @@ import (
 
 	"github.com/ipfs/kubo/core/commands/cmdenv"
 	"github.com/ipfs/kubo/core/commands/cmdutils"
+	"github.com/ipfs/kubo/core/dagshape"
 )
 
 func dagImport(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
@@ -41,6 +42,12 @@ func dagImport(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment
 
 	doPinRoots, _ := req.Options[pinRootsOptionName].(bool)
 
+	cfg, err := node.Repo.Config()
+	if err != nil {
+		return err
+	}
+	limits := dagshape.ParseLimits(cfg.DAGLimits)
+
 	// grab a pinlock ( which doubles as a GC lock ) so that regardless of the
 	// size of the streamed-in cars nothing will disappear on us before we had
 	// a chance to roots that may show up at the very end
@@ -116,6 +123,9 @@ func dagImport(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment
 				if err != nil {
 					return importError(previous, block, err)
 				}
+				if err := limits.CheckNode(nd); err != nil {
+					return importError(previous, block, err)
+				}
 
 				if err := batch.Add(req.Context, nd); err != nil {
 					return importError(previous, block, err)
@@ -135,6 +145,22 @@ func dagImport(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment
 		return err
 	}
 
+	// The depth and the number of nodes of the DAGs are only known once all
+	// their blocks are imported. The blocks of the rejected DAGs are left
+	// unpinned, for the GC. The roots and the blocks missing from the CARs
+	// are skipped.
+	if limits.MaxDepth > 0 || limits.MaxNodes > 0 {
+		err = roots.ForEach(func(c cid.Cid) error {
+			if err := dagshape.Walk(req.Context, api.Dag(), c, limits, true); err != nil {
+				return fmt.Errorf("import failed for root %q: %w", c, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	// It is not guaranteed that a root in a header is actually present in the same ( or any )
 	// .car file. This is the case in version 1, and ideally in further versions too.
 	// Accumulate any root CID seen in a header, and supplement its actual node if/when encountered
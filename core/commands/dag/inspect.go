@@ -0,0 +1,55 @@
+package dagcmd
+
+import (
+	"errors"
+
+	"github.com/ipfs/boxo/files"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+func dagInspect(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+	api, err := cmdenv.GetApi(env, req)
+	if err != nil {
+		return err
+	}
+
+	it := req.Files.Entries()
+	if !it.Next() {
+		if it.Err() != nil {
+			return it.Err()
+		}
+		return errors.New("expected a .car file")
+	}
+	file := files.FileFromEntry(it)
+	if file == nil {
+		return errors.New("expected a file handle")
+	}
+	defer file.Close()
+
+	car, err := api.Car().Inspect(req.Context, file)
+	if err != nil {
+		return err
+	}
+
+	out := &CarInspectOutput{
+		Version:      car.Version,
+		Roots:        car.Roots,
+		MissingRoots: car.MissingRoots,
+		Blocks:       car.Blocks,
+		Bytes:        car.Bytes,
+		Codecs:       make([]CarCodec, len(car.Codecs)),
+		HasIndex:     car.HasIndex,
+		IndexCodec:   car.IndexCodec,
+		IndexValid:   car.IndexValid,
+		Errors:       make([]CarError, len(car.Errors)),
+	}
+	for i, c := range car.Codecs {
+		out.Codecs[i] = CarCodec{Codec: c.Codec, Blocks: c.Blocks}
+	}
+	for i, e := range car.Errors {
+		out.Errors[i] = CarError{Offset: e.Offset, Error: e.Error}
+	}
+	return cmds.EmitOnce(res, out)
+}
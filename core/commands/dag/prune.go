@@ -0,0 +1,32 @@
+package dagcmd
+
+import (
+	"fmt"
+
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+func dagPrune(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+	api, err := cmdenv.GetApi(env, req)
+	if err != nil {
+		return err
+	}
+
+	root, err := cid.Decode(req.Arguments[0])
+	if err != nil {
+		return fmt.Errorf("invalid root CID: %w", err)
+	}
+
+	out, err := api.Dag().Prune(req.Context, root, req.Arguments[1])
+	if err != nil {
+		return err
+	}
+
+	return cmds.EmitOnce(res, &DagPruneOutput{
+		Root:   out.Root,
+		Blocks: out.Blocks,
+		Bytes:  out.Bytes,
+	})
+}
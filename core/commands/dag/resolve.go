@@ -14,7 +14,7 @@ func dagResolve(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environmen
 		return err
 	}
 
-	p, err := cmdutils.PathOrCidPath(req.Arguments[0])
+	p, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 	if err != nil {
 		return err
 	}
@@ -29,7 +29,7 @@ func dagStat(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment)
 	cidSet := cid.NewSet()
 	dagStatSummary := &DagStatSummary{DagStatsArray: []*DagStat{}}
 	for _, a := range req.Arguments {
-		p, err := cmdutils.PathOrCidPath(a)
+		p, err := cmdutils.PathOrAlias(req.Context, api, a)
 		if err != nil {
 			return err
 		}
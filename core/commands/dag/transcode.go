@@ -0,0 +1,59 @@
+package dagcmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/boxo/files"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
+
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/coreiface/options"
+)
+
+func dagTranscode(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+	api, err := cmdenv.GetApi(env, req)
+	if err != nil {
+		return err
+	}
+
+	version, _ := req.Options[versionOptionName].(uint64)
+	dedupe, _ := req.Options[dedupeOptionName].(bool)
+	opts := []options.CarTranscodeOption{options.Car.Version(version), options.Car.Dedupe(dedupe)}
+	if s, ok := req.Options[selectorOptionName].(string); ok && s != "" {
+		sel, err := selectorparse.ParseJSONSelector(s)
+		if err != nil {
+			return fmt.Errorf("invalid selector: %w", err)
+		}
+		opts = append(opts, options.Car.Selector(sel))
+	}
+
+	it := req.Files.Entries()
+	if !it.Next() {
+		if it.Err() != nil {
+			return it.Err()
+		}
+		return errors.New("expected a .car file")
+	}
+	file := files.FileFromEntry(it)
+	if file == nil {
+		return errors.New("expected a file handle")
+	}
+	defer file.Close()
+
+	pipeR, pipeW := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		err := api.Car().Transcode(req.Context, file, pipeW, opts...)
+		_ = pipeW.CloseWithError(err)
+		errCh <- err
+	}()
+
+	if err := res.Emit(pipeR); err != nil {
+		pipeR.Close() // ignore the error if any
+		return err
+	}
+	return <-errCh
+}
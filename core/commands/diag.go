@@ -10,8 +10,11 @@ var DiagCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"sys":     sysDiagCmd,
-		"cmds":    ActiveReqsCmd,
-		"profile": sysProfileCmd,
+		"sys":       sysDiagCmd,
+		"cmds":      ActiveReqsCmd,
+		"profile":   sysProfileCmd,
+		"hashing":   diagHashingCmd,
+		"listeners": diagListenersCmd,
+		"shards":    diagShardsCmd,
 	},
 }
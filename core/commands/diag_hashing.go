@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/hashing"
+)
+
+const (
+	hashBenchDurationOptionName  = "duration"
+	hashBenchBlockSizeOptionName = "block-size"
+)
+
+type HashBenchOutput struct {
+	CPUFeatures []string
+	Current     string
+	Results     []hashing.BenchmarkResult
+	Best        hashing.BenchmarkResult
+}
+
+var diagHashingCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Benchmark the available SHA-256 implementations.",
+		ShortDescription: `
+Measures the SHA-256 throughput of every available implementation with an
+increasing number of goroutines, and prints the configuration that performed
+best on this host.
+
+The selected implementation can be set with the
+Internal.Hashing.SHA256Implementation config option, and the concurrency of
+'ipfs repo verify' with Internal.Hashing.Concurrency.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(hashBenchDurationOptionName, "Time spent benchmarking each configuration.").WithDefault("1s"),
+		cmds.IntOption(hashBenchBlockSizeOptionName, "Size of the hashed blocks in bytes.").WithDefault(256 * 1024),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		durStr, _ := req.Options[hashBenchDurationOptionName].(string)
+		dur, err := time.ParseDuration(durStr)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", hashBenchDurationOptionName, err)
+		}
+		blockSize, _ := req.Options[hashBenchBlockSizeOptionName].(int)
+		if blockSize <= 0 {
+			return fmt.Errorf("%s must be positive", hashBenchBlockSizeOptionName)
+		}
+
+		// auto is one of the other implementations, don't measure it twice
+		var impls []string
+		for _, name := range hashing.SHA256Implementations() {
+			if name != hashing.SHA256Auto {
+				impls = append(impls, name)
+			}
+		}
+		var concurrencies []int
+		for n := 1; n < runtime.NumCPU(); n *= 2 {
+			concurrencies = append(concurrencies, n)
+		}
+		concurrencies = append(concurrencies, runtime.NumCPU())
+
+		results, err := hashing.Benchmark(req.Context, impls, concurrencies, blockSize, dur)
+		if err != nil {
+			return err
+		}
+
+		out := &HashBenchOutput{
+			CPUFeatures: hashing.CPUFeatures(),
+			Current:     hashing.SHA256(),
+			Results:     results,
+		}
+		for _, r := range results {
+			if r.BytesPerSecond > out.Best.BytesPerSecond {
+				out.Best = r
+			}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *HashBenchOutput) error {
+			fmt.Fprintf(w, "CPU features: %v\n", out.CPUFeatures)
+			fmt.Fprintf(w, "Current implementation: %s\n\n", out.Current)
+
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			fmt.Fprintln(tw, "Implementation\tConcurrency\tThroughput\t")
+			for _, r := range out.Results {
+				fmt.Fprintf(tw, "%s\t%d\t%s/s\t\n", r.Implementation, r.Concurrency, humanize.IBytes(uint64(r.BytesPerSecond)))
+			}
+			tw.Flush()
+
+			fmt.Fprintf(w, "\nBest: %s with %d goroutines, apply with:\n", out.Best.Implementation, out.Best.Concurrency)
+			fmt.Fprintf(w, "  ipfs config Internal.Hashing.SHA256Implementation %s\n", out.Best.Implementation)
+			fmt.Fprintf(w, "  ipfs config --json Internal.Hashing.Concurrency %d\n", out.Best.Concurrency)
+			return nil
+		}),
+	},
+	Type: HashBenchOutput{},
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	oldcmds "github.com/ipfs/kubo/commands"
+)
+
+// ListenersOutput is the list of the HTTP listeners of the daemon.
+type ListenersOutput struct {
+	Listeners []oldcmds.ListenerInfo
+}
+
+var diagListenersCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the HTTP listeners of the daemon.",
+		ShortDescription: `
+Lists the addresses the RPC API and the gateway listen on, whether they were
+inherited from the service manager (systemd socket activation), and how the
+RPC API is authorized on each of them:
+
+  global    API.Authorizations applies
+  listener  the authorizations of the listener in API.Listeners apply
+  none      the RPC API is exposed to everyone on the listener
+`,
+	},
+	NoLocal: true,
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		ctx := env.(*oldcmds.Context)
+		out := &ListenersOutput{Listeners: []oldcmds.ListenerInfo{}}
+		if ctx.Listeners != nil {
+			out.Listeners = ctx.Listeners.Report()
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ListenersOutput) error {
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "Server\tAddress\tSocketActivated\tAuthorization")
+			for _, l := range out.Listeners {
+				auth := l.Authorization
+				if auth == "" {
+					auth = "-"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%t\t%s\n", l.Server, l.Address, l.SocketActivated, auth)
+			}
+			return tw.Flush()
+		}),
+	},
+	Type: ListenersOutput{},
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/commands/cmdutils"
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+)
+
+// ShardsOutput is the list of the HAMT shards of a sharded directory.
+type ShardsOutput struct {
+	Shards []coreiface.ShardInfo
+}
+
+var diagShardsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the HAMT shards of a sharded directory.",
+		ShortDescription: `
+Lists the shards of a HAMT sharded UnixFS directory, depth first starting
+with the root shard, to understand pathological layouts and check when
+directories get sharded (see Internal.UnixFSShardingSizeThreshold):
+
+  Depth     0 for the root shard
+  Fanout    the number of buckets of the shard
+  Buckets   the number of occupied buckets
+  Entries   the directory entries stored in the shard
+  Children  the shards under it
+  Size      the size of the block of the shard
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "The path to the sharded directory.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		p, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		shards, err := api.Unixfs().LsShards(req.Context, p)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &ShardsOutput{Shards: shards})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ShardsOutput) error {
+			enc, err := cmdenv.GetCidEncoder(req)
+			if err != nil {
+				return err
+			}
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "Cid\tDepth\tFanout\tBuckets\tEntries\tChildren\tSize")
+			for _, s := range out.Shards {
+				fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\n", enc.Encode(s.Cid), s.Depth, s.Fanout, s.Buckets, s.Entries, s.Children, s.Size)
+			}
+			return tw.Flush()
+		}),
+	},
+	Type: ShardsOutput{},
+}
@@ -12,14 +12,16 @@ import (
 
 	humanize "github.com/dustin/go-humanize"
 	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/alias"
+	chunker "github.com/ipfs/kubo/core/chunker"
 	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/coreunix"
 
 	bservice "github.com/ipfs/boxo/blockservice"
 	offline "github.com/ipfs/boxo/exchange/offline"
 	dag "github.com/ipfs/boxo/ipld/merkledag"
 	ft "github.com/ipfs/boxo/ipld/unixfs"
 	mfs "github.com/ipfs/boxo/mfs"
-	"github.com/ipfs/boxo/path"
 	cid "github.com/ipfs/go-cid"
 	cidenc "github.com/ipfs/go-cidutil/cidenc"
 	cmds "github.com/ipfs/go-ipfs-cmds"
@@ -64,22 +66,32 @@ of consistency guarantees. If the daemon is unexpectedly killed before running
 'ipfs files flush' on the files in question, then data may be lost. This also
 applies to run 'ipfs repo gc' concurrently with '--flush=false'
 operations.
+
+When many operations target the same directories, the flushes can instead be
+batched with MFS.AutoFlushInterval in the config: the changed directories are
+then flushed once per interval. With MFS.ManualFlush, they are only flushed by
+'ipfs files flush' and when the daemon shuts down.
 `,
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(filesFlushOptionName, "f", "Flush target and ancestors after write.").WithDefault(true),
 	},
 	Subcommands: map[string]*cmds.Command{
-		"read":  filesReadCmd,
-		"write": filesWriteCmd,
-		"mv":    filesMvCmd,
-		"cp":    filesCpCmd,
-		"ls":    filesLsCmd,
-		"mkdir": filesMkdirCmd,
-		"stat":  filesStatCmd,
-		"rm":    filesRmCmd,
-		"flush": filesFlushCmd,
-		"chcid": filesChcidCmd,
+		"read":    filesReadCmd,
+		"write":   filesWriteCmd,
+		"mv":      filesMvCmd,
+		"cp":      filesCpCmd,
+		"ls":      filesLsCmd,
+		"mkdir":   filesMkdirCmd,
+		"stat":    filesStatCmd,
+		"rm":      filesRmCmd,
+		"flush":   filesFlushCmd,
+		"chcid":   filesChcidCmd,
+		"sync":    filesSyncCmd,
+		"ttl":     filesTTLCmd,
+		"chmod":   filesChmodCmd,
+		"touch":   filesTouchCmd,
+		"symlink": filesSymlinkCmd,
 	},
 }
 
@@ -101,6 +113,7 @@ type statOutput struct {
 	CumulativeSize uint64
 	Blocks         int
 	Type           string
+	MimeType       string `json:",omitempty"`
 	WithLocality   bool   `json:",omitempty"`
 	Local          bool   `json:",omitempty"`
 	SizeLocal      uint64 `json:",omitempty"`
@@ -115,6 +128,7 @@ Type: <type>`
 	filesFormatOptionName    = "format"
 	filesSizeOptionName      = "size"
 	filesWithLocalOptionName = "with-local"
+	filesMimeTypeOptionName  = "mime-type"
 )
 
 var filesStatCmd = &cmds.Command{
@@ -127,10 +141,11 @@ var filesStatCmd = &cmds.Command{
 	},
 	Options: []cmds.Option{
 		cmds.StringOption(filesFormatOptionName, "Print statistics in given format. Allowed tokens: "+
-			"<hash> <size> <cumulsize> <type> <childs>. Conflicts with other format options.").WithDefault(defaultStatFormat),
+			"<hash> <size> <cumulsize> <type> <childs> <mime>. <mime> requires --mime-type. Conflicts with other format options.").WithDefault(defaultStatFormat),
 		cmds.BoolOption(filesHashOptionName, "Print only hash. Implies '--format=<hash>'. Conflicts with other format options."),
 		cmds.BoolOption(filesSizeOptionName, "Print only size. Implies '--format=<cumulsize>'. Conflicts with other format options."),
 		cmds.BoolOption(filesWithLocalOptionName, "Compute the amount of the dag that is local, and if possible the total size"),
+		cmds.BoolOption(filesMimeTypeOptionName, "Sniff the MIME type of files, which may fetch their first block."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		_, err := statGetFormatOptions(req)
@@ -154,6 +169,7 @@ var filesStatCmd = &cmds.Command{
 		}
 
 		withLocal, _ := req.Options[filesWithLocalOptionName].(bool)
+		mimeType, _ := req.Options[filesMimeTypeOptionName].(bool)
 
 		enc, err := cmdenv.GetCidEncoder(req)
 		if err != nil {
@@ -181,6 +197,13 @@ var filesStatCmd = &cmds.Command{
 			return err
 		}
 
+		if mimeType && o.Type == "file" {
+			o.MimeType, err = node.MimeTypes.MimeType(req.Context, dagserv, nd.Cid())
+			if err != nil && !ipld.IsNotFound(err) {
+				return err
+			}
+		}
+
 		if !withLocal {
 			return cmds.EmitOnce(res, o)
 		}
@@ -204,6 +227,7 @@ var filesStatCmd = &cmds.Command{
 			s = strings.Replace(s, "<cumulsize>", fmt.Sprintf("%d", out.CumulativeSize), -1)
 			s = strings.Replace(s, "<childs>", fmt.Sprintf("%d", out.Blocks), -1)
 			s = strings.Replace(s, "<type>", out.Type, -1)
+			s = strings.Replace(s, "<mime>", out.MimeType, -1)
 
 			fmt.Fprintln(w, s)
 
@@ -324,7 +348,8 @@ var filesCpCmd = &cmds.Command{
 		Tagline: "Add references to IPFS files and directories in MFS (or copy within MFS).",
 		ShortDescription: `
 "ipfs files cp" can be used to add references to any IPFS file or directory
-(usually in the form /ipfs/<CID>, but also any resolvable path) into MFS.
+(usually in the form /ipfs/<CID>, or /alias/<name> for the aliases of the
+repo) into MFS.
 This performs a lazy copy: the full DAG will not be fetched, only the root
 node being copied.
 
@@ -410,7 +435,7 @@ being GC'ed.
 		}
 
 		if flush {
-			_, err := mfs.FlushPath(req.Context, nd.FilesRoot, dst)
+			err := nd.MFSFlusher.Flush(req.Context, dst)
 			if err != nil {
 				return fmt.Errorf("cp: cannot flush the created file %s: %s", dst, err)
 			}
@@ -422,8 +447,8 @@ being GC'ed.
 
 func getNodeFromPath(ctx context.Context, node *core.IpfsNode, api iface.CoreAPI, p string) (ipld.Node, error) {
 	switch {
-	case strings.HasPrefix(p, "/ipfs/"):
-		pth, err := path.NewPath(p)
+	case strings.HasPrefix(p, "/ipfs/"), strings.HasPrefix(p, "/"+alias.Namespace+"/"):
+		pth, err := api.Alias().Expand(ctx, p)
 		if err != nil {
 			return nil, err
 		}
@@ -693,6 +718,10 @@ Example:
 		cmds.StringArg("source", true, false, "Source file to move."),
 		cmds.StringArg("dest", true, false, "Destination path for file to be moved to."),
 	},
+	Options: []cmds.Option{
+		cmds.BoolOption(filesParentsOptionName, "p", "Make parent directories of the destination as needed."),
+		cmds.BoolOption(filesOverwriteOptionName, "Replace an entry already at the destination.").WithDefault(true),
+	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		nd, err := cmdenv.GetNode(env)
 		if err != nil {
@@ -700,6 +729,8 @@ Example:
 		}
 
 		flush, _ := req.Options[filesFlushOptionName].(bool)
+		parents, _ := req.Options[filesParentsOptionName].(bool)
+		overwrite, _ := req.Options[filesOverwriteOptionName].(bool)
 
 		src, err := checkPath(req.Arguments[0])
 		if err != nil {
@@ -710,9 +741,13 @@ Example:
 			return err
 		}
 
-		err = mfs.Mv(nd.FilesRoot, src, dst)
+		moved, err := coreunix.Mv(nd.FilesRoot, src, dst, parents, overwrite)
+		if err == nil {
+			// the TTLs follow their paths
+			err = nd.MFSExpiry.Move(req.Context, src, moved)
+		}
 		if err == nil && flush {
-			_, err = mfs.FlushPath(req.Context, nd.FilesRoot, "/")
+			err = nd.MFSFlusher.FlushDir(req.Context, "/")
 		}
 		return err
 	},
@@ -724,6 +759,7 @@ const (
 	filesTruncateOptionName  = "truncate"
 	filesRawLeavesOptionName = "raw-leaves"
 	filesFlushOptionName     = "flush"
+	filesOverwriteOptionName = "overwrite"
 )
 
 var filesWriteCmd = &cmds.Command{
@@ -838,7 +874,10 @@ See '--to-files' in 'ipfs add --help' for more information.
 			fi.RawLeaves = rawLeaves
 		}
 
-		wfd, err := fi.Open(mfs.Flags{Write: true, Sync: flush})
+		// when batching, the file is flushed with its directory at the end of
+		// the flush epoch
+		sync := flush && !nd.MFSFlusher.Batching()
+		wfd, err := fi.Open(mfs.Flags{Write: true, Sync: sync})
 		if err != nil {
 			return err
 		}
@@ -852,6 +891,9 @@ See '--to-files' in 'ipfs add --help' for more information.
 					flog.Error("files: error closing file mfs file descriptor", err)
 				}
 			}
+			if retErr == nil && flush && !sync {
+				retErr = nd.MFSFlusher.Flush(req.Context, path)
+			}
 		}()
 
 		if trunc {
@@ -933,10 +975,12 @@ Examples:
 
 		err = mfs.Mkdir(root, dirtomake, mfs.MkdirOpts{
 			Mkparents:  dashp,
-			Flush:      flush,
+			Flush:      flush && !n.MFSFlusher.Batching(),
 			CidBuilder: prefix,
 		})
-
+		if err == nil && flush && n.MFSFlusher.Batching() {
+			err = n.MFSFlusher.Flush(req.Context, dirtomake)
+		}
 		return err
 	},
 }
@@ -950,7 +994,9 @@ var filesFlushCmd = &cmds.Command{
 		Tagline: "Flush a given path's data to disk.",
 		ShortDescription: `
 Flush a given path to the disk. This is only useful when other commands
-are run with the '--flush=false'.
+are run with the '--flush=false', or when the flushes are batched with
+MFS.AutoFlushInterval or MFS.ManualFlush in the config. The batched changes
+are all flushed first.
 `,
 	},
 	Arguments: []cmds.Argument{
@@ -972,6 +1018,10 @@ are run with the '--flush=false'.
 			path = req.Arguments[0]
 		}
 
+		// end the flush epoch of the batched changes
+		if err := nd.MFSFlusher.FlushPending(req.Context); err != nil {
+			return err
+		}
 		n, err := mfs.FlushPath(req.Context, nd.FilesRoot, path)
 		if err != nil {
 			return err
@@ -1016,12 +1066,171 @@ Change the CID version or hash function of the root node of a given path.
 
 		err = updatePath(nd.FilesRoot, path, prefix)
 		if err == nil && flush {
-			_, err = mfs.FlushPath(req.Context, nd.FilesRoot, path)
+			err = nd.MFSFlusher.Flush(req.Context, path)
 		}
 		return err
 	},
 }
 
+const (
+	filesSyncPullOptionName    = "pull"
+	filesSyncTwoWayOptionName  = "two-way"
+	filesSyncDryRunOptionName  = "dry-run"
+	filesSyncDeleteOptionName  = "delete"
+	filesSyncExcludeOptionName = "exclude"
+	filesSyncChunkerOptionName = "chunker"
+)
+
+var filesSyncCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Synchronize a local directory with an MFS directory.",
+		ShortDescription: `
+Make an MFS directory match a local directory (or, with '--pull', the other
+way around) by applying the minimal set of add, write and remove operations.
+`,
+		LongDescription: `
+Make an MFS directory match a local directory (or, with '--pull', the other
+way around) by applying the minimal set of add, write and remove operations.
+
+The modification times of the files are stored in their UnixFS metadata
+when they are copied to MFS, and set on the local files copied from it.
+Files are compared by size, then by modification time and, when the sizes
+match but the times don't, by CID. The local file is hashed using the chunker
+and CID prefix of the existing MFS entry, so unchanged files are never
+rewritten. Entries only present at the destination are kept unless
+'--delete' is passed.
+
+With '--two-way', the entries missing on either side are copied to the
+other, and the files that differ are copied from the side where they were
+modified last, the local one when the times are the same. Removed entries
+can't be told apart from new ones, so '--delete' can't be used with it.
+
+The local directory is read and written by the node process, so it must be
+accessible from the machine running the daemon.
+
+Use '--exclude' (can be repeated) to skip entries whose name or relative path
+matches a shell pattern, and '--dry-run' to print what would be done without
+changing anything.
+
+Examples:
+
+    $ ipfs files sync ./website /website
+    add index.html
+    update css/main.css
+    $ ipfs files sync --pull --delete --exclude '*.tmp' ./backup /website
+    $ ipfs files sync --two-way ./notes /notes
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("local", true, false, "Local directory to synchronize."),
+		cmds.StringArg("path", true, false, "MFS directory to synchronize."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(filesSyncPullOptionName, "Update the local directory from MFS instead of MFS from the local directory."),
+		cmds.BoolOption(filesSyncTwoWayOptionName, "Update both directories from each other, the last modified files winning."),
+		cmds.BoolOption(filesSyncDryRunOptionName, "n", "Only print the operations that would be performed."),
+		cmds.BoolOption(filesSyncDeleteOptionName, "Remove destination entries that do not exist in the source."),
+		cmds.StringsOption(filesSyncExcludeOptionName, "Skip entries matching this shell pattern."),
+		cmds.StringOption(filesSyncChunkerOptionName, "s", "Chunking algorithm used for new files, size-[bytes], rabin-[min]-[avg]-[max], buzhash or fastcdc-[min]-[avg]-[max]").WithDefault("size-262144"),
+		cmds.BoolOption(filesRawLeavesOptionName, "Use raw blocks for newly created leaf nodes. (experimental)"),
+		cidVersionOption,
+		hashOption,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		mfsDir, err := checkPath(req.Arguments[1])
+		if err != nil {
+			return err
+		}
+
+		prefix, err := getPrefixNew(req)
+		if err != nil {
+			return err
+		}
+
+		pull, _ := req.Options[filesSyncPullOptionName].(bool)
+		twoWay, _ := req.Options[filesSyncTwoWayOptionName].(bool)
+		dryRun, _ := req.Options[filesSyncDryRunOptionName].(bool)
+		deleteExtra, _ := req.Options[filesSyncDeleteOptionName].(bool)
+		exclude, _ := req.Options[filesSyncExcludeOptionName].([]string)
+		chunkerSpec, _ := req.Options[filesSyncChunkerOptionName].(string)
+		rawLeaves, rawLeavesDef := req.Options[filesRawLeavesOptionName].(bool)
+		flush, _ := req.Options[filesFlushOptionName].(bool)
+
+		if err := chunker.Validate(chunkerSpec); err != nil {
+			return err
+		}
+		if chunker.Experimental(chunkerSpec) {
+			cfg, err := nd.Repo.Config()
+			if err != nil {
+				return err
+			}
+			if !cfg.Experimental.FastCDCChunker {
+				return chunker.ErrExperimental
+			}
+		}
+
+		if !rawLeavesDef {
+			cidVer, _ := req.Options[filesCidVersionOptionName].(int)
+			_, hashSet := req.Options[filesHashOptionName].(string)
+			rawLeaves = cidVer > 0 || hashSet
+		}
+
+		syncer := &coreunix.Syncer{
+			Root:       nd.FilesRoot,
+			DAG:        nd.DAG,
+			Direction:  coreunix.SyncToMFS,
+			DryRun:     dryRun,
+			Delete:     deleteExtra,
+			Exclude:    exclude,
+			Chunker:    chunkerSpec,
+			RawLeaves:  rawLeaves,
+			CidBuilder: prefix,
+		}
+		switch {
+		case pull && twoWay:
+			return fmt.Errorf("%s and %s options are not compatible", filesSyncPullOptionName, filesSyncTwoWayOptionName)
+		case twoWay && deleteExtra:
+			return fmt.Errorf("%s and %s options are not compatible", filesSyncTwoWayOptionName, filesSyncDeleteOptionName)
+		case pull:
+			syncer.Direction = coreunix.SyncFromMFS
+		case twoWay:
+			syncer.Direction = coreunix.SyncBoth
+		}
+
+		actions := make(chan *coreunix.SyncAction, 16)
+		syncer.Out = actions
+
+		errCh := make(chan error, 1)
+		go func() {
+			defer close(actions)
+			_, err := syncer.Sync(req.Context, req.Arguments[0], mfsDir)
+			if err == nil && flush && !dryRun && !pull {
+				err = nd.MFSFlusher.FlushDir(req.Context, mfsDir)
+			}
+			errCh <- err
+		}()
+
+		for a := range actions {
+			if err := res.Emit(a); err != nil {
+				return err
+			}
+		}
+		return <-errCh
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *coreunix.SyncAction) error {
+			_, err := fmt.Fprintf(w, "%s %s\n", out.Op, out.Path)
+			return err
+		}),
+	},
+	Type: coreunix.SyncAction{},
+}
+
 func updatePath(rt *mfs.Root, pth string, builder cid.Builder) error {
 	if builder == nil {
 		return nil
@@ -1083,6 +1292,10 @@ Remove files or directories.
 
 			if err := removePath(nd.FilesRoot, path, force, dashr); err != nil {
 				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+			if _, err := nd.MFSExpiry.Clear(req.Context, path); err != nil {
+				errs = append(errs, fmt.Errorf("%s: clearing TTLs: %w", path, err))
 			}
 		}
 		if len(errs) > 0 {
@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/coreunix"
+)
+
+var filesChmodCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Change the mode of an MFS entry.",
+		ShortDescription: `
+Store an octal POSIX mode in the UnixFS 1.5 metadata of a file or a directory
+in MFS. Only the permission, setuid, setgid and sticky bits are stored, and a
+mode of 0 removes it. The CID of the entry changes, and a file made of a
+single raw block gets wrapped into a UnixFS file node to hold the mode.
+
+    $ ipfs files chmod 0644 /photos/cat.jpg
+    $ ipfs files chmod -r 0755 /scripts
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("mode", true, false, "Octal mode to set, e.g. 0644."),
+		cmds.StringArg("path", true, false, "Path of the MFS entry to change."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(recursiveOptionName, "r", "Change every entry under the directory too."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		flush, _ := req.Options[filesFlushOptionName].(bool)
+		recursive, _ := req.Options[recursiveOptionName].(bool)
+
+		mode, err := parsePosixMode(req.Arguments[0])
+		if err != nil {
+			return fmt.Errorf("invalid mode: %w", err)
+		}
+		path, err := checkPath(req.Arguments[1])
+		if err != nil {
+			return err
+		}
+
+		err = coreunix.Chmod(req.Context, nd.FilesRoot, nd.DAG, path, mode, recursive)
+		if err == nil && flush {
+			err = nd.MFSFlusher.Flush(req.Context, path)
+		}
+		return err
+	},
+}
+
+var filesTouchCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Change the modification time of an MFS entry.",
+		ShortDescription: `
+Store a modification time in the UnixFS 1.5 metadata of a file or a directory
+in MFS: the current time, or the one given with --mtime and --mtime-nsecs. The
+CID of the entry changes, and a file made of a single raw block gets wrapped
+into a UnixFS file node to hold the time. Unlike touch, the entry must exist.
+
+    $ ipfs files touch /photos/cat.jpg
+    $ ipfs files touch -r --mtime=1700000000 /photos
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "Path of the MFS entry to change."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(recursiveOptionName, "r", "Change every entry under the directory too."),
+		cmds.Int64Option(mtimeOptionName, "Modification time to set, in seconds since the Unix epoch. Default: now."),
+		cmds.UintOption(mtimeNsecsOptionName, "The nanoseconds of the modification time of --mtime."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		flush, _ := req.Options[filesFlushOptionName].(bool)
+		recursive, _ := req.Options[recursiveOptionName].(bool)
+		secs, mtimeSet := req.Options[mtimeOptionName].(int64)
+		nsecs, nsecsSet := req.Options[mtimeNsecsOptionName].(uint)
+
+		mtime := time.Now()
+		if nsecsSet && !mtimeSet {
+			return fmt.Errorf("%s requires %s", mtimeNsecsOptionName, mtimeOptionName)
+		}
+		if mtimeSet {
+			if nsecs >= uint(time.Second) {
+				return fmt.Errorf("%s must be lower than 1e9", mtimeNsecsOptionName)
+			}
+			mtime = time.Unix(secs, int64(nsecs))
+		}
+
+		path, err := checkPath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		err = coreunix.Touch(req.Context, nd.FilesRoot, nd.DAG, path, mtime, recursive)
+		if err == nil && flush {
+			err = nd.MFSFlusher.Flush(req.Context, path)
+		}
+		return err
+	},
+}
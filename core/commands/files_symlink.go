@@ -0,0 +1,49 @@
+package commands
+
+import (
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/coreunix"
+)
+
+var filesSymlinkCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Make a symbolic link.",
+		ShortDescription: `
+Write a UnixFS symlink to the target at the given MFS path, like 'ln -s'. The
+target is stored as is: it may be relative to the directory of the link, and
+doesn't have to exist. The command fails when an entry is already at the path.
+
+    $ ipfs files symlink ../photos/cat.jpg /shared/cat.jpg
+    $ ipfs files symlink -p /photos /links/to/photos
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("target", true, false, "Target of the symlink."),
+		cmds.StringArg("path", true, false, "Path of the symlink to write."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(filesParentsOptionName, "p", "Make parent directories as needed."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		flush, _ := req.Options[filesFlushOptionName].(bool)
+		parents, _ := req.Options[filesParentsOptionName].(bool)
+
+		path, err := checkPath(req.Arguments[1])
+		if err != nil {
+			return err
+		}
+
+		err = coreunix.Symlink(nd.FilesRoot, req.Arguments[0], path, parents)
+		if err == nil && flush {
+			err = nd.MFSFlusher.Flush(req.Context, path)
+		}
+		return err
+	},
+}
@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/mfsexpiry"
+)
+
+const filesTTLUnpinOptionName = "unpin"
+
+type filesTTLOutput struct {
+	Entries []mfsexpiry.Entry
+}
+
+type filesTTLClearOutput struct {
+	Cleared int
+}
+
+var filesTTLCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Remove MFS files and directories once their TTL expires.",
+		ShortDescription: `
+Attaches TTLs to MFS paths, for scratch and workspace directories that should
+clean themselves up. Once its TTL expires, whatever is at the path is removed
+from MFS by the daemon, and its CID is unpinned when the TTL was set with
+--unpin.
+
+TTLs follow their path through 'ipfs files mv', and are cleared by
+'ipfs files rm'.
+
+    $ ipfs files mkdir /scratch
+    $ ipfs files ttl set /scratch 24h
+    $ ipfs files ttl ls
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"set":   filesTTLSetCmd,
+		"ls":    filesTTLLsCmd,
+		"clear": filesTTLClearCmd,
+	},
+}
+
+var filesTTLSetCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Set the TTL of an MFS path.",
+		ShortDescription: `
+Sets the TTL of an MFS path, replacing the previous one if any. The TTL is a
+duration such as 30m, 24h or 168h.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "MFS path to expire."),
+		cmds.StringArg("ttl", true, false, "Time after which the path is removed."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(filesTTLUnpinOptionName, "Unpin the CID of the path when it is removed."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		path, err := checkPath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		ttl, err := time.ParseDuration(req.Arguments[1])
+		if err != nil {
+			return cmds.Errorf(cmds.ErrClient, "invalid TTL: %s", err)
+		}
+		if ttl <= 0 {
+			return cmds.Errorf(cmds.ErrClient, "the TTL must be positive, use 'ipfs files ttl clear' to clear it")
+		}
+		unpin, _ := req.Options[filesTTLUnpinOptionName].(bool)
+
+		e, err := nd.MFSExpiry.Set(req.Context, path, ttl, unpin)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &filesTTLOutput{Entries: []mfsexpiry.Entry{e}})
+	},
+	Type: filesTTLOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(encodeFilesTTL),
+	},
+}
+
+var filesTTLLsCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "List the TTLs of MFS paths, the ones expiring first first.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		entries, err := nd.MFSExpiry.List(req.Context)
+		if err != nil {
+			return err
+		}
+		if entries == nil {
+			entries = []mfsexpiry.Entry{}
+		}
+		return cmds.EmitOnce(res, &filesTTLOutput{Entries: entries})
+	},
+	Type: filesTTLOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(encodeFilesTTL),
+	},
+}
+
+var filesTTLClearCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Clear the TTLs of an MFS path and of the paths under it.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "MFS path to keep."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		path, err := checkPath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		n, err := nd.MFSExpiry.Clear(req.Context, path)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &filesTTLClearOutput{Cleared: n})
+	},
+	Type: filesTTLClearOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *filesTTLClearOutput) error {
+			_, err := fmt.Fprintf(w, "cleared %d TTLs\n", out.Cleared)
+			return err
+		}),
+	},
+}
+
+func encodeFilesTTL(req *cmds.Request, w io.Writer, out *filesTTLOutput) error {
+	tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+	for _, e := range out.Entries {
+		unpin := ""
+		if e.Unpin {
+			unpin = "unpin"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Path, e.Expires.Format(time.RFC3339), unpin)
+	}
+	return tw.Flush()
+}
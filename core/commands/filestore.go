@@ -20,9 +20,10 @@ var FileStoreCmd = &cmds.Command{
 		Tagline: "Interact with filestore objects.",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"ls":     lsFileStore,
-		"verify": verifyFileStore,
-		"dups":   dupsFileStore,
+		"ls":      lsFileStore,
+		"verify":  verifyFileStore,
+		"dups":    dupsFileStore,
+		"sources": filestoreSourcesCmd,
 	},
 }
 
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/blocks/sourcebs"
+)
+
+// FilestoreSourcesOutput is the availability of the mounts the sources of
+// the filestore are read from.
+type FilestoreSourcesOutput struct {
+	Available bool
+	Mounts    []sourcebs.Mount
+}
+
+const (
+	filestoreSourcesCheckOptionName = "check"
+)
+
+var filestoreSourcesCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Show the availability of the mounts of the filestore sources.",
+		ShortDescription: `
+'ipfs filestore sources' shows whether the mounts of Filestore.Mounts, the
+directories the sources of the filestore are read from, such as the mount
+points of network shares, are available. The mounts are checked every
+Filestore.CheckInterval, and reported unavailable when a check fails or takes
+longer than Filestore.CheckTimeout.
+
+The reads of the blocks whose source is under an unavailable mount fail at
+once, instead of hanging until the mount comes back. The mounts are also
+reported by the /readyz probe of the API.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(filestoreSourcesCheckOptionName, "Check the mounts before reporting them."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, _, err := getFilestore(env)
+		if err != nil {
+			return err
+		}
+		if n.FilestoreSources == nil {
+			return sourcebs.ErrNoMounts
+		}
+
+		if check, _ := req.Options[filestoreSourcesCheckOptionName].(bool); check {
+			n.FilestoreSources.Check()
+		}
+		out := &FilestoreSourcesOutput{Available: true, Mounts: n.FilestoreSources.Mounts()}
+		for _, m := range out.Mounts {
+			out.Available = out.Available && m.Available
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: FilestoreSourcesOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *FilestoreSourcesOutput) error {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			for _, m := range out.Mounts {
+				state := "available"
+				if !m.Available {
+					state = "unavailable: " + m.Error
+				}
+				fmt.Fprintf(tw, "%s\t%s\tsince %s\n", m.Path, state, m.Since.Format(time.RFC3339))
+			}
+			return tw.Flush()
+		}),
+	},
+}
@@ -72,7 +72,7 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 			return err
 		}
 
-		p, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		p, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 		if err != nil {
 			return err
 		}
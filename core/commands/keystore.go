@@ -46,6 +46,7 @@ publish'.
 	},
 	Subcommands: map[string]*cmds.Command{
 		"gen":    keyGenCmd,
+		"derive": keyDeriveCmd,
 		"export": keyExportCmd,
 		"import": keyImportCmd,
 		"list":   keyListCmd,
@@ -58,8 +59,9 @@ publish'.
 }
 
 type KeyOutput struct {
-	Name string
-	Id   string //nolint
+	Name           string
+	Id             string //nolint
+	DerivationPath string `json:",omitempty"`
 }
 
 type KeyOutputList struct {
@@ -139,6 +141,92 @@ var keyGenCmd = &cmds.Command{
 	Type: KeyOutput{},
 }
 
+const (
+	keyDerivePathOptionName       = "path"
+	keyDerivePassphraseOptionName = "passphrase"
+)
+
+var keyDeriveCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Derive a keypair from a seed phrase.",
+		ShortDescription: `
+Derives an ed25519 keypair from a BIP39 seed phrase, read from stdin, along a
+hardened derivation path (SLIP-0010), and stores it under the provided name.
+The seed phrase is not stored: back it up, and derive the keys again with it
+to regenerate them.
+
+By default, the derivation path is derived from the name of the key, so the
+seed phrase and the names of the keys are enough to regenerate them. Use
+--path to choose the path, such as one per site. 'ipfs key list -l' shows the
+derivation paths of the derived keys.
+
+  > echo "$SEED_PHRASE" | ipfs key derive example.com
+  > echo "$SEED_PHRASE" | ipfs key derive --path="m/0'/1'" blog
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(keyDerivePathOptionName, "Derivation path of the key, such as m/0'/1'. Default: derived from the name."),
+		cmds.StringOption(keyDerivePassphraseOptionName, "Passphrase protecting the seed phrase."),
+		ke.OptionIPNSBase,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "name of key to derive"),
+		cmds.FileArg("seed-phrase", true, false, "BIP39 seed phrase").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		keyEnc, err := ke.KeyEncoderFromString(req.Options[ke.OptionIPNSBase.Name()].(string))
+		if err != nil {
+			return err
+		}
+
+		name := req.Arguments[0]
+		if name == "self" {
+			return fmt.Errorf("cannot create key with name 'self'")
+		}
+
+		file, err := cmdenv.GetFileArg(req.Files.Entries())
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		phrase, err := io.ReadAll(file)
+		if err != nil {
+			return err
+		}
+
+		var opts []options.KeyDeriveOption
+		if p, ok := req.Options[keyDerivePathOptionName].(string); ok {
+			opts = append(opts, options.Key.Path(p))
+		}
+		if p, ok := req.Options[keyDerivePassphraseOptionName].(string); ok {
+			opts = append(opts, options.Key.Passphrase(p))
+		}
+
+		key, err := api.Key().Derive(req.Context, name, string(phrase), opts...)
+		if err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, &KeyOutput{
+			Name:           name,
+			Id:             keyEnc.FormatID(key.ID()),
+			DerivationPath: key.DerivationPath(),
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, ko *KeyOutput) error {
+			_, err := w.Write([]byte(ko.Id + "\n"))
+			return err
+		}),
+	},
+	Type: KeyOutput{},
+}
+
 const (
 	// Key format options used both for importing and exporting.
 	keyFormatOptionName            = "format"
@@ -474,8 +562,9 @@ var keyListCmd = &cmds.Command{
 
 		for _, key := range keys {
 			list = append(list, KeyOutput{
-				Name: key.Name(),
-				Id:   keyEnc.FormatID(key.ID()),
+				Name:           key.Name(),
+				Id:             keyEnc.FormatID(key.ID()),
+				DerivationPath: key.DerivationPath(),
 			})
 		}
 
@@ -681,7 +770,7 @@ func keyOutputListEncoders() cmds.EncoderFunc {
 		tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
 		for _, s := range list.Keys {
 			if withID {
-				fmt.Fprintf(tw, "%s\t%s\t\n", s.Id, cmdenv.EscNonPrint(s.Name))
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Id, cmdenv.EscNonPrint(s.Name), s.DerivationPath)
 			} else {
 				fmt.Fprintf(tw, "%s\n", cmdenv.EscNonPrint(s.Name))
 			}
@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	iface "github.com/ipfs/kubo/core/coreiface"
+	options "github.com/ipfs/kubo/core/coreiface/options"
+)
+
+// KVEntryOutput is a write of a key of a key-value store.
+type KVEntryOutput struct {
+	Store   string
+	Key     string
+	Value   []byte `json:",omitempty"`
+	Deleted bool   `json:",omitempty"`
+	Writer  string `json:",omitempty"`
+	Clock   uint64 `json:",omitempty"`
+}
+
+// KVList are the entries of a key-value store.
+type KVList struct {
+	Entries []KVEntryOutput
+}
+
+const (
+	kvKeyOptionName = "key"
+)
+
+var KVCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Key-value stores replicated over pubsub.",
+		ShortDescription: `
+'ipfs kv' reads and writes key-value stores shared by the nodes using them.
+The writes of a store are published on the pubsub topic /ipfs/kv/<store>, and
+applied by the nodes using the store, which converge to the last write of each
+key, whatever the order they receive the writes in. The nodes joining a store
+request its entries from the nodes already there.
+
+The writes are signed with the key of the node, or the key given with --key,
+and the writes not signed by their writer are ignored. Anyone can write to a
+store: use the writer of the entries to decide which ones to trust.
+
+This feature is experimental, it requires Experimental.KVStore and pubsub to
+be enabled.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"put": kvPutCmd,
+		"get": kvGetCmd,
+		"rm":  kvRmCmd,
+		"ls":  kvLsCmd,
+		"sub": kvSubCmd,
+	},
+}
+
+var kvPutCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Write the value of a key.",
+		ShortDescription: `
+'ipfs kv put' writes the value of a key of a store, read from stdin or a
+file, and publishes the write to the other nodes of the store.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("store", true, false, "The name of the store."),
+		cmds.StringArg("key", true, false, "The key to write."),
+		cmds.FileArg("value", true, false, "The value to write.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(kvKeyOptionName, "k", "Name of the key the write is signed with.").WithDefault("self"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		key, _ := req.Options[kvKeyOptionName].(string)
+
+		file, err := cmdenv.GetFileArg(req.Files.Entries())
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		value, err := io.ReadAll(file)
+		if err != nil {
+			return err
+		}
+
+		e, err := api.KV().Put(req.Context, req.Arguments[0], req.Arguments[1], value, options.KV.Key(key))
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, kvEntryOutput(e))
+	},
+	Type: KVEntryOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *KVEntryOutput) error {
+			_, err := fmt.Fprintf(w, "wrote %s/%s at clock %d\n", out.Store, out.Key, out.Clock)
+			return err
+		}),
+	},
+}
+
+var kvGetCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Show the value of a key.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("store", true, false, "The name of the store."),
+		cmds.StringArg("key", true, false, "The key to read."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		value, err := api.KV().Get(req.Context, req.Arguments[0], req.Arguments[1])
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &KVEntryOutput{Store: req.Arguments[0], Key: req.Arguments[1], Value: value})
+	},
+	Type: KVEntryOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *KVEntryOutput) error {
+			_, err := w.Write(out.Value)
+			return err
+		}),
+	},
+}
+
+var kvRmCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Delete a key.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("store", true, false, "The name of the store."),
+		cmds.StringArg("key", true, false, "The key to delete."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(kvKeyOptionName, "k", "Name of the key the delete is signed with.").WithDefault("self"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		key, _ := req.Options[kvKeyOptionName].(string)
+
+		return api.KV().Delete(req.Context, req.Arguments[0], req.Arguments[1], options.KV.Key(key))
+	},
+}
+
+var kvLsCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "List the entries of a store.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("store", true, false, "The name of the store."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		entries, err := api.KV().List(req.Context, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		out := &KVList{Entries: make([]KVEntryOutput, len(entries))}
+		for i, e := range entries {
+			out.Entries[i] = *kvEntryOutput(e)
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: KVList{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *KVList) error {
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			for _, e := range out.Entries {
+				fmt.Fprintf(tw, "%s\t%d bytes\t%s\n", e.Key, len(e.Value), e.Writer)
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+var kvSubCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Stream the writes of a store.",
+		ShortDescription: `
+'ipfs kv sub' streams the writes applied to a store, local or received from
+the other nodes, until it is interrupted. The writes superseded by a write
+already applied are not streamed.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("store", true, false, "The name of the store."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		entries, err := api.KV().Subscribe(req.Context, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		if f, ok := res.(http.Flusher); ok {
+			f.Flush()
+		}
+
+		for e := range entries {
+			if err := res.Emit(kvEntryOutput(e)); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: KVEntryOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *KVEntryOutput) error {
+			if out.Deleted {
+				_, err := fmt.Fprintf(w, "rm %s by %s\n", out.Key, out.Writer)
+				return err
+			}
+			_, err := fmt.Fprintf(w, "put %s (%d bytes) by %s\n", out.Key, len(out.Value), out.Writer)
+			return err
+		}),
+	},
+}
+
+func kvEntryOutput(e iface.KVEntry) *KVEntryOutput {
+	return &KVEntryOutput{
+		Store:   e.Store,
+		Key:     e.Key,
+		Value:   e.Value,
+		Deleted: e.Deleted,
+		Writer:  e.Writer.String(),
+		Clock:   e.Clock,
+	}
+}
@@ -23,6 +23,8 @@ type LsLink struct {
 	Size       uint64
 	Type       unixfs_pb.Data_DataType
 	Target     string
+	MimeType   string `json:",omitempty"`
+	Cursor     string `json:",omitempty"`
 }
 
 // LsObject is an element of LsOutput
@@ -42,7 +44,12 @@ const (
 	lsHeadersOptionNameTime = "headers"
 	lsResolveTypeOptionName = "resolve-type"
 	lsSizeOptionName        = "size"
+	lsMimeTypeOptionName    = "mime-type"
 	lsStreamOptionName      = "stream"
+	lsOffsetOptionName      = "offset"
+	lsLimitOptionName       = "limit"
+	lsCursorOptionName      = "cursor"
+	lsOrderOptionName       = "preserved-order"
 )
 
 var LsCmd = &cmds.Command{
@@ -55,6 +62,18 @@ the following format:
   <link base58 hash> <link size in bytes> <link name>
 
 The JSON output contains type information.
+
+Large directories can be listed in pages with --offset, --limit and --cursor.
+The entries of a page are listed in a stable order, by name for basic
+directories and by hash of the name for HAMT sharded ones, each with a cursor.
+Passing the cursor of the last entry of a page to --cursor lists the next one,
+without reading the shards of the directory before it:
+
+  > ipfs ls --limit 1000 <dir>
+  > ipfs ls --limit 1000 --cursor <cursor of the last entry> <dir>
+
+The entries of the directories added with 'ipfs add --preserve-order' are
+listed in the order they were added in with --preserved-order, paged or not.
 `,
 	},
 
@@ -65,7 +84,12 @@ The JSON output contains type information.
 		cmds.BoolOption(lsHeadersOptionNameTime, "v", "Print table headers (Hash, Size, Name)."),
 		cmds.BoolOption(lsResolveTypeOptionName, "Resolve linked objects to find out their types.").WithDefault(true),
 		cmds.BoolOption(lsSizeOptionName, "Resolve linked objects to find out their file size.").WithDefault(true),
+		cmds.BoolOption(lsMimeTypeOptionName, "Resolve linked files to find out their MIME type, sniffed from their first block."),
 		cmds.BoolOption(lsStreamOptionName, "s", "Enable experimental streaming of directory entries as they are traversed."),
+		cmds.IntOption(lsOffsetOptionName, "Skip the first entries of the directories, in a stable order."),
+		cmds.IntOption(lsLimitOptionName, "List at most this many entries of each directory, in a stable order."),
+		cmds.StringOption(lsCursorOptionName, "List the entries after the one this cursor was returned with."),
+		cmds.BoolOption(lsOrderOptionName, "List the entries of the directories added with 'ipfs add --preserve-order' in the order they were added in."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -75,7 +99,13 @@ The JSON output contains type information.
 
 		resolveType, _ := req.Options[lsResolveTypeOptionName].(bool)
 		resolveSize, _ := req.Options[lsSizeOptionName].(bool)
+		resolveMimeType, _ := req.Options[lsMimeTypeOptionName].(bool)
 		stream, _ := req.Options[lsStreamOptionName].(bool)
+		offset, _ := req.Options[lsOffsetOptionName].(int)
+		limit, _ := req.Options[lsLimitOptionName].(int)
+		cursor, _ := req.Options[lsCursorOptionName].(string)
+		preservedOrder, _ := req.Options[lsOrderOptionName].(bool)
+		paged := lsPaged(req)
 
 		err = req.ParseBodyArgs()
 		if err != nil {
@@ -113,10 +143,12 @@ The JSON output contains type information.
 						outputLinks = append(outputLinks, link)
 						return nil
 					}, func(i int) {
-						// after each dir
-						sort.Slice(outputLinks, func(i, j int) bool {
-							return outputLinks[i].Name < outputLinks[j].Name
-						})
+						// after each dir, the pages keep their order
+						if !paged {
+							sort.Slice(outputLinks, func(i, j int) bool {
+								return outputLinks[i].Name < outputLinks[j].Name
+							})
+						}
 
 						output[i] = LsObject{
 							Hash:  paths[i],
@@ -131,13 +163,18 @@ The JSON output contains type information.
 		}
 
 		for i, fpath := range paths {
-			pth, err := cmdutils.PathOrCidPath(fpath)
+			pth, err := cmdutils.PathOrAlias(req.Context, api, fpath)
 			if err != nil {
 				return err
 			}
 
 			results, err := api.Unixfs().Ls(req.Context, pth,
-				options.Unixfs.ResolveChildren(resolveSize || resolveType))
+				options.Unixfs.ResolveChildren(resolveSize || resolveType),
+				options.Unixfs.ResolveMimeType(resolveMimeType),
+				options.Unixfs.Offset(offset),
+				options.Unixfs.LsLimit(limit),
+				options.Unixfs.Cursor(cursor),
+				options.Unixfs.PreservedOrder(preservedOrder))
 			if err != nil {
 				return err
 			}
@@ -160,9 +197,11 @@ The JSON output contains type information.
 					Name: link.Name,
 					Hash: enc.Encode(link.Cid),
 
-					Size:   link.Size,
-					Type:   ftype,
-					Target: link.Target,
+					Size:     link.Size,
+					Type:     ftype,
+					Target:   link.Target,
+					MimeType: link.MimeType,
+					Cursor:   link.Cursor,
 				}
 				if err := processLink(paths[i], lsLink); err != nil {
 					return err
@@ -203,10 +242,20 @@ The JSON output contains type information.
 	Type: LsOutput{},
 }
 
+// lsPaged returns whether req lists pages of the directories.
+func lsPaged(req *cmds.Request) bool {
+	offset, _ := req.Options[lsOffsetOptionName].(int)
+	limit, _ := req.Options[lsLimitOptionName].(int)
+	cursor, _ := req.Options[lsCursorOptionName].(string)
+	return offset > 0 || limit > 0 || cursor != ""
+}
+
 func tabularOutput(req *cmds.Request, w io.Writer, out *LsOutput, lastObjectHash string, ignoreBreaks bool) string {
 	headers, _ := req.Options[lsHeadersOptionNameTime].(bool)
 	stream, _ := req.Options[lsStreamOptionName].(bool)
 	size, _ := req.Options[lsSizeOptionName].(bool)
+	mimeType, _ := req.Options[lsMimeTypeOptionName].(bool)
+	paged := lsPaged(req)
 	// in streaming mode we can't automatically align the tabs
 	// so we take a best guess
 	var minTabWidth int
@@ -230,33 +279,51 @@ func tabularOutput(req *cmds.Request, w io.Writer, out *LsOutput, lastObjectHash
 				fmt.Fprintf(tw, "%s:\n", object.Hash)
 			}
 			if headers {
-				s := "Hash\tName"
+				s := "Hash\t"
 				if size {
-					s = "Hash\tSize\tName"
+					s += "Size\t"
+				}
+				if mimeType {
+					s += "MimeType\t"
+				}
+				if paged {
+					s += "Cursor\t"
 				}
-				fmt.Fprintln(tw, s)
+				fmt.Fprintln(tw, s+"Name")
 			}
 			lastObjectHash = object.Hash
 		}
 
 		for _, link := range object.Links {
-			var s string
+			var isDir bool
 			switch link.Type {
 			case unixfs.TDirectory, unixfs.THAMTShard, unixfs.TMetadata:
-				if size {
-					s = "%[1]s\t-\t%[3]s/\n"
+				isDir = true
+			}
+
+			s := link.Hash + "\t"
+			if size {
+				if isDir {
+					s += "-\t"
 				} else {
-					s = "%[1]s\t%[3]s/\n"
+					s += fmt.Sprintf("%v\t", link.Size)
 				}
-			default:
-				if size {
-					s = "%s\t%v\t%s\n"
+			}
+			if mimeType {
+				if link.MimeType == "" {
+					s += "-\t"
 				} else {
-					s = "%[1]s\t%[3]s\n"
+					s += link.MimeType + "\t"
 				}
 			}
-
-			fmt.Fprintf(tw, s, link.Hash, link.Size, cmdenv.EscNonPrint(link.Name))
+			if paged {
+				s += link.Cursor + "\t"
+			}
+			s += cmdenv.EscNonPrint(link.Name)
+			if isDir {
+				s += "/"
+			}
+			fmt.Fprintln(tw, s)
 		}
 	}
 	tw.Flush()
@@ -12,13 +12,15 @@ import (
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	logging "github.com/ipfs/go-log"
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	coreiface "github.com/ipfs/kubo/core/coreiface"
 	options "github.com/ipfs/kubo/core/coreiface/options"
 )
 
 var log = logging.Logger("core/commands/ipns")
 
 type ResolvedPath struct {
-	Path string
+	Path       string
+	Provenance []coreiface.NameProvenance `json:",omitempty"`
 }
 
 const (
@@ -27,6 +29,7 @@ const (
 	dhtRecordCountOptionName = "dht-record-count"
 	dhtTimeoutOptionName     = "dht-timeout"
 	streamOptionName         = "stream"
+	provenanceOptionName     = "provenance"
 )
 
 var IpnsCmd = &cmds.Command{
@@ -64,6 +67,11 @@ Resolve the value of a dnslink:
   > ipfs name resolve ipfs.io
   /ipfs/QmaBvfZooxWkrv7D3r8LS9moNjzD2o525XMZze69hhoxf5
 
+With --provenance, each step of the resolution is listed with where its
+answer came from: the cache, the DNSLink TXT records and the DNS resolver
+that returned them, or the IPNS records returned by each router, with the
+DHT peers that answered.
+
 `,
 	},
 
@@ -76,6 +84,7 @@ Resolve the value of a dnslink:
 		cmds.UintOption(dhtRecordCountOptionName, "dhtrc", "Number of records to request for DHT resolution.").WithDefault(uint(namesys.DefaultResolverDhtRecordCount)),
 		cmds.StringOption(dhtTimeoutOptionName, "dhtt", "Max time to collect values during DHT resolution e.g. \"30s\". Pass 0 for no timeout.").WithDefault(namesys.DefaultResolverDhtTimeout.String()),
 		cmds.BoolOption(streamOptionName, "s", "Stream entries as they are found."),
+		cmds.BoolOption(provenanceOptionName, "Report where each step of the resolution came from."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -100,9 +109,11 @@ Resolve the value of a dnslink:
 		rc, rcok := req.Options[dhtRecordCountOptionName].(uint)
 		dhtt, dhttok := req.Options[dhtTimeoutOptionName].(string)
 		stream, _ := req.Options[streamOptionName].(bool)
+		provenance, _ := req.Options[provenanceOptionName].(bool)
 
 		opts := []options.NameResolveOption{
 			options.Name.Cache(!nocache),
+			options.Name.WithProvenance(provenance),
 		}
 
 		if !recursive {
@@ -126,7 +137,7 @@ Resolve the value of a dnslink:
 			name = "/ipns/" + name
 		}
 
-		if !stream {
+		if !stream && !provenance {
 			output, err := api.Name().Resolve(req.Context, name, opts...)
 			if err != nil && (recursive || err != namesys.ErrResolveRecursion) {
 				return err
@@ -137,7 +148,7 @@ Resolve the value of a dnslink:
 				return err
 			}
 
-			return cmds.EmitOnce(res, &ResolvedPath{pth.String()})
+			return cmds.EmitOnce(res, &ResolvedPath{Path: pth.String()})
 		}
 
 		output, err := api.Name().Search(req.Context, name, opts...)
@@ -145,23 +156,63 @@ Resolve the value of a dnslink:
 			return err
 		}
 
+		var last *ResolvedPath
 		for v := range output {
 			if v.Err != nil && (recursive || v.Err != namesys.ErrResolveRecursion) {
 				return v.Err
 			}
-			if err := res.Emit(&ResolvedPath{v.Path.String()}); err != nil {
+			rp := &ResolvedPath{Path: v.Path.String(), Provenance: v.Provenance}
+			if !stream {
+				last = rp
+				continue
+			}
+			if err := res.Emit(rp); err != nil {
 				return err
 			}
 
 		}
 
+		if !stream {
+			if last == nil {
+				return coreiface.ErrResolveFailed
+			}
+			return cmds.EmitOnce(res, last)
+		}
 		return nil
 	},
 	Encoders: cmds.EncoderMap{
 		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, rp *ResolvedPath) error {
-			_, err := fmt.Fprintln(w, rp.Path)
-			return err
+			if _, err := fmt.Fprintln(w, rp.Path); err != nil {
+				return err
+			}
+			for _, step := range rp.Provenance {
+				if err := writeProvenance(w, step); err != nil {
+					return err
+				}
+			}
+			return nil
 		}),
 	},
 	Type: ResolvedPath{},
 }
+
+// writeProvenance writes a step of the resolution of a name, indented under
+// the resolved path.
+func writeProvenance(w io.Writer, step coreiface.NameProvenance) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "  %s -> %s (%s)\n", step.Name, step.Value, step.Source)
+	if step.Resolver != "" {
+		fmt.Fprintf(&b, "    resolver: %s\n", step.Resolver)
+	}
+	for _, txt := range step.TXT {
+		fmt.Fprintf(&b, "    txt: %s\n", txt)
+	}
+	for _, r := range step.Records {
+		fmt.Fprintf(&b, "    record: %s seq=%d %s\n", r.Router, r.Sequence, r.Value)
+	}
+	for _, p := range step.Peers {
+		fmt.Fprintf(&b, "    peer: %s\n", p)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -114,7 +114,7 @@ Alternatively, publish an <ipfs-path> using a valid PeerID (as listed by
 			opts = append(opts, options.Name.TTL(d))
 		}
 
-		p, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		p, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,96 @@
+package name
+
+import (
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/namecache"
+)
+
+// PurgeOutput is a name purged from the cache, or the number of names purged
+// with --all.
+type PurgeOutput struct {
+	Name string `json:",omitempty"`
+	// SurrogateKey is the key of the gateway responses for the name, to purge
+	// from the cache of a CDN.
+	SurrogateKey string `json:",omitempty"`
+	// Cached is whether the name was cached.
+	Cached bool `json:",omitempty"`
+	// Purged is the number of names purged with --all.
+	Purged int `json:",omitempty"`
+}
+
+const purgeAllOptionName = "all"
+
+var PurgeCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Purge IPNS names and DNSLink domains from the resolve cache.",
+		ShortDescription: `
+'ipfs name purge' removes names from the cache of the resolved IPNS names and
+DNSLink domains, so that they are resolved again by the next request, rather
+than when the TTL of their record expires.
+
+With a CDN in front of the gateway and Gateway.SurrogateKeys set, purge the
+surrogate key of the name from the CDN after purging it from the node, so that
+the CDN fetches the new version of the name:
+
+  ipfs name purge example.com
+  # then purge the surrogate key "ipns:example.com" from the CDN
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", false, true, "IPNS names or DNSLink domains to purge, with or without /ipns/."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(purgeAllOptionName, "Purge all the names."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.NameCache == nil {
+			return fmt.Errorf("the resolve cache is disabled")
+		}
+
+		if all, _ := req.Options[purgeAllOptionName].(bool); all {
+			if len(req.Arguments) > 0 {
+				return cmds.Errorf(cmds.ErrClient, "names can't be given with --%s", purgeAllOptionName)
+			}
+			return res.Emit(&PurgeOutput{Purged: n.NameCache.PurgeAll()})
+		}
+		if len(req.Arguments) == 0 {
+			return cmds.Errorf(cmds.ErrClient, "names to purge, or --%s, are required", purgeAllOptionName)
+		}
+
+		for _, name := range req.Arguments {
+			out := &PurgeOutput{
+				Name:         namecache.Key(name),
+				SurrogateKey: namecache.SurrogateKey(name),
+				Cached:       n.NameCache.Purge(name),
+			}
+			if err := res.Emit(out); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PurgeOutput) error {
+			if out.Name == "" {
+				_, err := fmt.Fprintf(w, "purged %d names\n", out.Purged)
+				return err
+			}
+			status := "purged"
+			if !out.Cached {
+				status = "not cached"
+			}
+			_, err := fmt.Fprintf(w, "%s: %s (surrogate key %s)\n", out.Name, status, out.SurrogateKey)
+			return err
+		}),
+	},
+	Type: PurgeOutput{},
+}
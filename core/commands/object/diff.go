@@ -61,12 +61,12 @@ Example:
 			return err
 		}
 
-		pa, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		pa, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 		if err != nil {
 			return err
 		}
 
-		pb, err := cmdutils.PathOrCidPath(req.Arguments[1])
+		pb, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[1])
 		if err != nil {
 			return err
 		}
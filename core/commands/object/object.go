@@ -95,7 +95,7 @@ is the raw data of the object.
 			return err
 		}
 
-		path, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		path, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -138,7 +138,7 @@ multihash. Provided for legacy reasons. Use 'ipfs dag get' instead.
 			return err
 		}
 
-		path, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		path, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -218,7 +218,7 @@ DEPRECATED and provided for legacy reasons. Use 'ipfs dag get' instead.
 			return err
 		}
 
-		path, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		path, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -342,7 +342,7 @@ DEPRECATED: Provided for legacy reasons. Modern replacements:
 			return err
 		}
 
-		p, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		p, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 		if err != nil {
 			return err
 		}
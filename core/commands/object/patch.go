@@ -75,7 +75,7 @@ DEPRECATED and provided for legacy reasons. Use 'ipfs add' or 'ipfs files' inste
 			return err
 		}
 
-		root, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		root, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -129,7 +129,7 @@ DEPRECATED and provided for legacy reasons. Use 'files cp' and 'dag put' instead
 			return err
 		}
 
-		root, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		root, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -179,7 +179,7 @@ DEPRECATED and provided for legacy reasons. Use 'files rm' instead.
 			return err
 		}
 
-		root, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		root, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -246,14 +246,14 @@ Use MFS and 'files' commands instead:
 			return err
 		}
 
-		root, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		root, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 		if err != nil {
 			return err
 		}
 
 		name := req.Arguments[1]
 
-		child, err := cmdutils.PathOrCidPath(req.Arguments[2])
+		child, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[2])
 		if err != nil {
 			return err
 		}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 
 	core "github.com/ipfs/kubo/core"
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	options "github.com/ipfs/kubo/core/coreiface/options"
 	p2p "github.com/ipfs/kubo/p2p"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
@@ -30,6 +32,9 @@ type P2PListenerInfoOutput struct {
 	Protocol      string
 	ListenAddress string
 	TargetAddress string
+	AllowedPeers  []string `json:",omitempty"`
+	BytesIn       uint64
+	BytesOut      uint64
 }
 
 // P2PStreamInfoOutput is output type of streams command
@@ -38,6 +43,8 @@ type P2PStreamInfoOutput struct {
 	Protocol      string
 	OriginAddress string
 	TargetAddress string
+	BytesIn       uint64
+	BytesOut      uint64
 }
 
 // P2PLsOutput is output type of ls command
@@ -53,6 +60,7 @@ type P2PStreamsOutput struct {
 const (
 	allowCustomProtocolOptionName = "allow-custom-protocol"
 	reportPeerIDOptionName        = "report-peer-id"
+	allowPeerOptionName           = "allow-peer"
 )
 
 var resolveTimeout = 10 * time.Second
@@ -75,6 +83,7 @@ are refined`,
 		"listen":  p2pListenCmd,
 		"close":   p2pCloseCmd,
 		"ls":      p2pLsCmd,
+		"send":    p2pSendCmd,
 	},
 }
 
@@ -193,6 +202,9 @@ Example:
   ipfs p2p listen ` + P2PProtoPrefix + `myproto /ip4/127.0.0.1/tcp/1234
     - Forward connections to 'myproto' libp2p service to 127.0.0.1:1234
 
+With --allow-peer, only the given peers may open streams to the service, the
+streams of other peers are refused.
+
 `,
 	},
 	Arguments: []cmds.Argument{
@@ -202,6 +214,7 @@ Example:
 	Options: []cmds.Option{
 		cmds.BoolOption(allowCustomProtocolOptionName, "Don't require /x/ prefix"),
 		cmds.BoolOption(reportPeerIDOptionName, "r", "Send remote base58 peerid to target when a new connection is established"),
+		cmds.StringsOption(allowPeerOptionName, "Only accept the streams of this peer. Can be repeated, or comma separated."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		n, err := p2pGetNode(env)
@@ -231,7 +244,19 @@ Example:
 			return errors.New("protocol name must be within '" + P2PProtoPrefix + "' namespace")
 		}
 
-		_, err = n.P2P.ForwardRemote(n.Context(), proto, target, reportPeerID)
+		var allowed []peer.ID
+		allowOpt, _ := req.Options[allowPeerOptionName].([]string)
+		for _, opt := range allowOpt {
+			for _, a := range strings.Split(opt, ",") {
+				pid, err := peer.Decode(strings.TrimSpace(a))
+				if err != nil {
+					return fmt.Errorf("invalid --%s %q: %w", allowPeerOptionName, a, err)
+				}
+				allowed = append(allowed, pid)
+			}
+		}
+
+		_, err = n.P2P.ForwardRemote(n.Context(), proto, target, reportPeerID, allowed)
 		return err
 	},
 }
@@ -300,21 +325,13 @@ var p2pLsCmd = &cmds.Command{
 
 		n.P2P.ListenersLocal.Lock()
 		for _, listener := range n.P2P.ListenersLocal.Listeners {
-			output.Listeners = append(output.Listeners, P2PListenerInfoOutput{
-				Protocol:      string(listener.Protocol()),
-				ListenAddress: listener.ListenAddress().String(),
-				TargetAddress: listener.TargetAddress().String(),
-			})
+			output.Listeners = append(output.Listeners, p2pListenerInfo(listener))
 		}
 		n.P2P.ListenersLocal.Unlock()
 
 		n.P2P.ListenersP2P.Lock()
 		for _, listener := range n.P2P.ListenersP2P.Listeners {
-			output.Listeners = append(output.Listeners, P2PListenerInfoOutput{
-				Protocol:      string(listener.Protocol()),
-				ListenAddress: listener.ListenAddress().String(),
-				TargetAddress: listener.TargetAddress().String(),
-			})
+			output.Listeners = append(output.Listeners, p2pListenerInfo(listener))
 		}
 		n.P2P.ListenersP2P.Unlock()
 
@@ -339,6 +356,20 @@ var p2pLsCmd = &cmds.Command{
 	},
 }
 
+func p2pListenerInfo(listener p2p.Listener) P2PListenerInfoOutput {
+	info := P2PListenerInfoOutput{
+		Protocol:      string(listener.Protocol()),
+		ListenAddress: listener.ListenAddress().String(),
+		TargetAddress: listener.TargetAddress().String(),
+		BytesIn:       listener.Counters().In.Load(),
+		BytesOut:      listener.Counters().Out.Load(),
+	}
+	for _, p := range listener.AllowedPeers() {
+		info.AllowedPeers = append(info.AllowedPeers, p.String())
+	}
+	return info
+}
+
 const (
 	p2pAllOptionName           = "all"
 	p2pProtocolOptionName      = "protocol"
@@ -467,6 +498,9 @@ var p2pStreamLsCmd = &cmds.Command{
 
 				OriginAddress: s.OriginAddr.String(),
 				TargetAddress: s.TargetAddr.String(),
+
+				BytesIn:  s.Counters.In.Load(),
+				BytesOut: s.Counters.Out.Load(),
 			})
 		}
 		n.P2P.Streams.Unlock()
@@ -544,6 +578,60 @@ var p2pStreamCloseCmd = &cmds.Command{
 	},
 }
 
+const p2pMaxReplySizeOptionName = "max-reply-size"
+
+var p2pSendCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Send a message to a peer and print its reply.",
+		ShortDescription: `
+Sends <data> to the handler of <protocol> on <peer-id>, registered with
+P2P().Handle in the Go API, and prints its reply. The message is sent on a
+libp2p stream, which authenticates the sender. The protocol must be prefixed
+with '` + P2PProtoPrefix + `'.
+
+Example:
+  echo -n ping | ipfs p2p send QmPeer ` + P2PProtoPrefix + `myapp/ping
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("peer-id", true, false, "The peer to send the message to."),
+		cmds.StringArg("protocol", true, false, "Protocol name."),
+		cmds.FileArg("data", true, false, "The message.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption(p2pMaxReplySizeOptionName, "Maximum size of the reply, in bytes.").WithDefault(options.DefaultP2PMessageSize),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		pid, err := peer.Decode(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		file, err := cmdenv.GetFileArg(req.Files.Entries())
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return err
+		}
+
+		maxReply, _ := req.Options[p2pMaxReplySizeOptionName].(int)
+		reply, err := api.P2P().Send(req.Context, pid, req.Arguments[1], data, options.P2P.MaxReplySize(maxReply))
+		if err != nil {
+			return err
+		}
+		return res.Emit(bytes.NewReader(reply))
+	},
+}
+
 func p2pGetNode(env cmds.Environment) (*core.IpfsNode, error) {
 	nd, err := cmdenv.GetNode(env)
 	if err != nil {
@@ -0,0 +1,140 @@
+package pin
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/ipfs/kubo/config"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/pincontrol"
+)
+
+const (
+	pinControlKeyOptionName   = "key"
+	pinControlTopicOptionName = "topic"
+)
+
+type PinControlOutput struct {
+	Op    string
+	Cid   string
+	Key   string
+	Topic string
+}
+
+var controlPinCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Order pins and unpins to the nodes listening on a pubsub topic.",
+		ShortDescription: `
+Publishes signed pin and unpin orders on the pubsub topic of
+Pinning.Control. The nodes with Pinning.Control.Enabled apply the orders
+signed by one of their Pinning.Control.AllowedKeys: pins are queued as
+background pins (see 'ipfs pin queue'), unpins are applied right away.
+
+Orders are signed with the key given by --key, 'self' by default, whose peer
+ID ('ipfs key list -l') is the one to add to Pinning.Control.AllowedKeys of
+the nodes. They are only valid for Pinning.Control.MaxAge.
+
+Publishing requires pubsub to be enabled.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add": controlOrderCmd(pincontrol.OpPin),
+		"rm":  controlOrderCmd(pincontrol.OpUnpin),
+	},
+}
+
+func controlOrderCmd(op string) *cmds.Command {
+	tagline := "Order the nodes to pin a CID."
+	if op == pincontrol.OpUnpin {
+		tagline = "Order the nodes to unpin a CID."
+	}
+	opts := []cmds.Option{
+		cmds.StringOption(pinControlKeyOptionName, "k", "Name of the key signing the order.").WithDefault("self"),
+		cmds.StringOption(pinControlTopicOptionName, "Topic to publish the order on. Default: Pinning.Control.Topic."),
+		cmds.BoolOption(pinRecursiveOptionName, "r", "Recursively pin, or unpin, the DAG of the CID.").WithDefault(true),
+	}
+	if op == pincontrol.OpPin {
+		opts = append(opts, cmds.StringOption(pinNameOptionName, "n", "An optional name for the pin."))
+	}
+
+	return &cmds.Command{
+		Status: cmds.Experimental,
+		Helptext: cmds.HelpText{
+			Tagline: tagline,
+		},
+		Arguments: []cmds.Argument{
+			cmds.StringArg("cid", true, false, "CID to order, with or without /ipfs/."),
+		},
+		Options: opts,
+		Type:    PinControlOutput{},
+		Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+			n, err := cmdenv.GetNode(env)
+			if err != nil {
+				return err
+			}
+			api, err := cmdenv.GetApi(env, req)
+			if err != nil {
+				return err
+			}
+			if n.PubSub == nil {
+				return fmt.Errorf("pubsub is disabled, set Pubsub.Enabled or run the daemon with --enable-pubsub-experiment")
+			}
+			cfg, err := n.Repo.Config()
+			if err != nil {
+				return err
+			}
+
+			c, err := cid.Decode(strings.TrimPrefix(req.Arguments[0], "/ipfs/"))
+			if err != nil {
+				return cmds.Errorf(cmds.ErrClient, "invalid CID: %s", err)
+			}
+
+			keyName, _ := req.Options[pinControlKeyOptionName].(string)
+			var sk ci.PrivKey
+			if keyName == "self" {
+				sk = n.PrivateKey
+			} else if sk, err = n.Repo.Keystore().Get(keyName); err != nil {
+				return fmt.Errorf("key %q: %w", keyName, err)
+			}
+			pid, err := peer.IDFromPrivateKey(sk)
+			if err != nil {
+				return err
+			}
+
+			recursive, _ := req.Options[pinRecursiveOptionName].(bool)
+			name, _ := req.Options[pinNameOptionName].(string)
+			data, err := pincontrol.Sign(sk, pincontrol.Order{Op: op, Cid: c, Recursive: recursive, Name: name})
+			if err != nil {
+				return err
+			}
+
+			topic, _ := req.Options[pinControlTopicOptionName].(string)
+			if topic == "" {
+				topic = cfg.Pinning.Control.Topic.WithDefault(config.DefaultPinControlTopic)
+			}
+			if err := api.PubSub().Publish(req.Context, topic, data); err != nil {
+				return err
+			}
+
+			return cmds.EmitOnce(res, &PinControlOutput{
+				Op:    op,
+				Cid:   c.String(),
+				Key:   pid.String(),
+				Topic: topic,
+			})
+		},
+		Encoders: cmds.EncoderMap{
+			cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PinControlOutput) error {
+				_, err := fmt.Fprintf(w, "ordered %s %s on %s, signed by %s\n", out.Op, out.Cid, out.Topic, out.Key)
+				return err
+			}),
+		},
+	}
+}
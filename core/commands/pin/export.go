@@ -0,0 +1,61 @@
+package pin
+
+import (
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+var exportPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Export the pins with a name as a .car file, streamed on stdout.",
+		ShortDescription: `
+'ipfs pin export' writes the pins with the given name, and all their blocks,
+as a CARv2 with an index, for offline transfer to another node:
+
+  > ipfs pin add --name=photos QmA QmB
+  > ipfs pin export photos > photos.car
+
+The root of the CAR is a dag-json manifest of the pins, linking to them, with
+their name, mode and provide class:
+
+  {
+    "label": "photos",
+    "created": "2024-01-02T03:04:05Z",
+    "pins": [
+      {"name": "photos", "root": {"/": "QmA"}, "recursive": true,
+       "provideClass": ""}
+    ]
+  }
+
+'ipfs dag import photos.car' imports the blocks and pins the manifest, and
+with it the whole dataset. The export is a consistent snapshot: the pins are
+listed once, and garbage collection waits for the export to complete.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "The name of the pins exported."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		pipeR, pipeW := io.Pipe()
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := api.Export().Dataset(req.Context, req.Arguments[0], pipeW)
+			_ = pipeW.CloseWithError(err)
+			errCh <- err
+		}()
+
+		if err := res.Emit(pipeR); err != nil {
+			pipeR.Close() // ignore the error if any
+			return err
+		}
+		return <-errCh
+	},
+}
@@ -0,0 +1,133 @@
+package pin
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/commands/cmdutils"
+)
+
+const pinGraceOptionName = "grace"
+
+type PinRemoval struct {
+	Cid       string
+	Recursive bool
+	Name      string `json:",omitempty"`
+	Removed   time.Time
+	Expires   time.Time
+}
+
+type PinRemovedOutput struct {
+	Removals []PinRemoval
+}
+
+var removedPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List pins pending removal.",
+		ShortDescription: `
+Lists the pins removed with 'ipfs pin rm --grace' whose grace period didn't
+expire: CID, mode, time left and name. Until the grace period expires, their
+blocks are not garbage collected, and they can be restored with
+'ipfs pin restore'.
+`,
+	},
+	Type: PinRemovedOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		removals, err := api.Pin().Removed(req.Context)
+		if err != nil {
+			return err
+		}
+		out := &PinRemovedOutput{Removals: make([]PinRemoval, len(removals))}
+		for i, r := range removals {
+			out.Removals[i] = PinRemoval{
+				Cid:       enc.Encode(r.Path.RootCid()),
+				Recursive: r.Recursive,
+				Name:      r.Name,
+				Removed:   r.Removed,
+				Expires:   r.Expires,
+			}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PinRemovedOutput) error {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			for _, r := range out.Removals {
+				mode := "direct"
+				if r.Recursive {
+					mode = "recursive"
+				}
+				left := time.Until(r.Expires).Round(time.Second)
+				fmt.Fprintf(tw, "%s\t%s\t%s left\t%s\n", r.Cid, mode, left, cmdenv.EscNonPrint(r.Name))
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+var restorePinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Restore pins removed with a grace period.",
+		ShortDescription: `
+Pins again objects whose pins were removed with 'ipfs pin rm --grace', with
+their previous mode and name, as long as the grace period didn't expire.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, true, "Path to object(s) to be pinned again.").EnableStdin(),
+	},
+	Type: PinOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		if err := req.ParseBodyArgs(); err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		pins := make([]string, 0, len(req.Arguments))
+		for _, b := range req.Arguments {
+			p, err := cmdutils.PathOrAlias(req.Context, api, b)
+			if err != nil {
+				return err
+			}
+			rp, _, err := api.ResolvePath(req.Context, p)
+			if err != nil {
+				return err
+			}
+			if err := api.Pin().Restore(req.Context, rp); err != nil {
+				return fmt.Errorf("%s: %w", enc.Encode(rp.RootCid()), err)
+			}
+			pins = append(pins, enc.Encode(rp.RootCid()))
+		}
+		return cmds.EmitOnce(res, &PinOutput{pins})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PinOutput) error {
+			for _, k := range out.Pins {
+				fmt.Fprintf(w, "restored %s\n", k)
+			}
+			return nil
+		}),
+	},
+}
@@ -30,12 +30,21 @@ var PinCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"add":    addPinCmd,
-		"rm":     rmPinCmd,
-		"ls":     listPinCmd,
-		"verify": verifyPinCmd,
-		"update": updatePinCmd,
-		"remote": remotePinCmd,
+		"add":           addPinCmd,
+		"rm":            rmPinCmd,
+		"ls":            listPinCmd,
+		"verify":        verifyPinCmd,
+		"update":        updatePinCmd,
+		"remote":        remotePinCmd,
+		"queue":         queuePinCmd,
+		"report":        reportPinCmd,
+		"removed":       removedPinCmd,
+		"restore":       restorePinCmd,
+		"reconcile":     reconcilePinCmd,
+		"control":       controlPinCmd,
+		"provide-class": provideClassPinCmd,
+		"export":        exportPinCmd,
+		"push":          pushPinCmd,
 	},
 }
 
@@ -44,8 +53,9 @@ type PinOutput struct {
 }
 
 type AddPinOutput struct {
-	Pins     []string `json:",omitempty"`
-	Progress int      `json:",omitempty"`
+	Pins     []string          `json:",omitempty"`
+	Progress int               `json:",omitempty"`
+	Queued   []*PinQueueOutput `json:",omitempty"`
 }
 
 const (
@@ -76,6 +86,14 @@ name will update the name of the pin.
 
 If daemon is running, any missing blocks will be retrieved from the network.
 It may take some time. Pass '--progress' to track the progress.
+
+Pass '--background' to queue the pins instead of waiting for them. Queued pins
+are persisted and processed by the daemon by '--priority', and retried when
+the content cannot be fetched. See 'ipfs pin queue --help'.
+
+Pass '--provide-class' to reprovide the DAGs of the pins hourly ("hot"), daily
+("warm"), weekly ("cold") or never ("never") instead of every
+Reprovider.Interval. See 'ipfs pin provide-class --help'.
 `,
 	},
 
@@ -86,6 +104,9 @@ It may take some time. Pass '--progress' to track the progress.
 		cmds.BoolOption(pinRecursiveOptionName, "r", "Recursively pin the object linked to by the specified object(s).").WithDefault(true),
 		cmds.StringOption(pinNameOptionName, "n", "An optional name for created pin(s)."),
 		cmds.BoolOption(pinProgressOptionName, "Show progress"),
+		cmds.BoolOption(pinBackgroundOptionName, "Queue the pins and return without waiting for them.").WithDefault(false),
+		cmds.IntOption(pinPriorityOptionName, "Priority of the queued pins, higher first. Requires --background.").WithDefault(0),
+		cmds.StringOption(pinProvideClassOptionName, "The provide class of the pins: hot, warm, cold or never. Can't be combined with --background."),
 	},
 	Type: AddPinOutput{},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
@@ -98,6 +119,9 @@ It may take some time. Pass '--progress' to track the progress.
 		recursive, _ := req.Options[pinRecursiveOptionName].(bool)
 		name, _ := req.Options[pinNameOptionName].(string)
 		showProgress, _ := req.Options[pinProgressOptionName].(bool)
+		background, _ := req.Options[pinBackgroundOptionName].(bool)
+		priority, _ := req.Options[pinPriorityOptionName].(int)
+		provideClass, _ := req.Options[pinProvideClassOptionName].(string)
 
 		if err := req.ParseBodyArgs(); err != nil {
 			return err
@@ -108,8 +132,20 @@ It may take some time. Pass '--progress' to track the progress.
 			return err
 		}
 
+		if background {
+			if provideClass != "" {
+				return fmt.Errorf("--%s can't be combined with --%s", pinProvideClassOptionName, pinBackgroundOptionName)
+			}
+			queued, err := pinEnqueueMany(req.Context, api, enc, req.Arguments, recursive, name, priority)
+			if err != nil {
+				return err
+			}
+
+			return cmds.EmitOnce(res, &AddPinOutput{Queued: queued})
+		}
+
 		if !showProgress {
-			added, err := pinAddMany(req.Context, api, enc, req.Arguments, recursive, name)
+			added, err := pinAddMany(req.Context, api, enc, req.Arguments, recursive, name, provideClass)
 			if err != nil {
 				return err
 			}
@@ -127,7 +163,7 @@ It may take some time. Pass '--progress' to track the progress.
 
 		ch := make(chan pinResult, 1)
 		go func() {
-			added, err := pinAddMany(ctx, api, enc, req.Arguments, recursive, name)
+			added, err := pinAddMany(ctx, api, enc, req.Arguments, recursive, name, provideClass)
 			ch <- pinResult{pins: added, err: err}
 		}()
 
@@ -170,6 +206,9 @@ It may take some time. Pass '--progress' to track the progress.
 			for _, k := range out.Pins {
 				fmt.Fprintf(w, "pinned %s %s\n", k, pintype)
 			}
+			for _, q := range out.Queued {
+				fmt.Fprintf(w, "queued %s %s as %s\n", q.Cid, pintype, q.ID)
+			}
 
 			return nil
 		}),
@@ -189,7 +228,7 @@ It may take some time. Pass '--progress' to track the progress.
 				if !ok {
 					return e.TypeErr(out, v)
 				}
-				if out.Pins == nil {
+				if out.Pins == nil && out.Queued == nil {
 					// this can only happen if the progress option is set
 					fmt.Fprintf(os.Stderr, "Fetched/Processed %d nodes\r", out.Progress)
 				} else {
@@ -203,10 +242,10 @@ It may take some time. Pass '--progress' to track the progress.
 	},
 }
 
-func pinAddMany(ctx context.Context, api coreiface.CoreAPI, enc cidenc.Encoder, paths []string, recursive bool, name string) ([]string, error) {
+func pinAddMany(ctx context.Context, api coreiface.CoreAPI, enc cidenc.Encoder, paths []string, recursive bool, name, provideClass string) ([]string, error) {
 	added := make([]string, len(paths))
 	for i, b := range paths {
-		p, err := cmdutils.PathOrCidPath(b)
+		p, err := cmdutils.PathOrAlias(ctx, api, b)
 		if err != nil {
 			return nil, err
 		}
@@ -216,7 +255,7 @@ func pinAddMany(ctx context.Context, api coreiface.CoreAPI, enc cidenc.Encoder,
 			return nil, err
 		}
 
-		if err := api.Pin().Add(ctx, rp, options.Pin.Recursive(recursive), options.Pin.Name(name)); err != nil {
+		if err := api.Pin().Add(ctx, rp, options.Pin.Recursive(recursive), options.Pin.Name(name), options.Pin.ProvideClass(provideClass)); err != nil {
 			return nil, err
 		}
 		added[i] = enc.Encode(rp.RootCid())
@@ -225,6 +264,24 @@ func pinAddMany(ctx context.Context, api coreiface.CoreAPI, enc cidenc.Encoder,
 	return added, nil
 }
 
+func pinEnqueueMany(ctx context.Context, api coreiface.CoreAPI, enc cidenc.Encoder, paths []string, recursive bool, name string, priority int) ([]*PinQueueOutput, error) {
+	queued := make([]*PinQueueOutput, len(paths))
+	for i, b := range paths {
+		p, err := cmdutils.PathOrAlias(ctx, api, b)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := api.Pin().Enqueue(ctx, p, options.Pin.Recursive(recursive), options.Pin.Name(name), options.Pin.Priority(priority))
+		if err != nil {
+			return nil, err
+		}
+		queued[i] = toPinQueueOutput(r, enc)
+	}
+
+	return queued, nil
+}
+
 var rmPinCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Remove object from pin-list.",
@@ -239,6 +296,12 @@ collected if needed. (By default, recursively. Use -r=false for direct pins.)
 A pin may not be removed because the specified object is not pinned or pinned
 indirectly. To determine if the object is pinned indirectly, use the command:
 ipfs pin ls -t indirect <cid>
+
+With --grace, the blocks of the removed pin are not garbage collected until
+the grace period expires, and the pin can be restored with 'ipfs pin restore'
+in the meantime. Pins pending removal are listed by 'ipfs pin removed'.
+
+  ipfs pin rm --grace=24h <cid>
 `,
 	},
 
@@ -247,6 +310,7 @@ ipfs pin ls -t indirect <cid>
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(pinRecursiveOptionName, "r", "Recursively unpin the object linked to by the specified object(s).").WithDefault(true),
+		cmds.StringOption(pinGraceOptionName, "Keep the blocks of the removed pins from garbage collection for this duration, during which the pins can be restored (e.g. 24h)."),
 	},
 	Type: PinOutput{},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
@@ -258,6 +322,13 @@ ipfs pin ls -t indirect <cid>
 		// set recursive flag
 		recursive, _ := req.Options[pinRecursiveOptionName].(bool)
 
+		var grace time.Duration
+		if g, ok := req.Options[pinGraceOptionName].(string); ok {
+			if grace, err = time.ParseDuration(g); err != nil {
+				return fmt.Errorf("invalid grace period: %w", err)
+			}
+		}
+
 		if err := req.ParseBodyArgs(); err != nil {
 			return err
 		}
@@ -269,7 +340,7 @@ ipfs pin ls -t indirect <cid>
 
 		pins := make([]string, 0, len(req.Arguments))
 		for _, b := range req.Arguments {
-			p, err := cmdutils.PathOrCidPath(b)
+			p, err := cmdutils.PathOrAlias(req.Context, api, b)
 			if err != nil {
 				return err
 			}
@@ -281,7 +352,7 @@ ipfs pin ls -t indirect <cid>
 
 			id := enc.Encode(rp.RootCid())
 			pins = append(pins, id)
-			if err := api.Pin().Rm(req.Context, rp, options.Pin.RmRecursive(recursive)); err != nil {
+			if err := api.Pin().Rm(req.Context, rp, options.Pin.RmRecursive(recursive), options.Pin.Grace(grace)); err != nil {
 				return err
 			}
 		}
@@ -498,7 +569,7 @@ func pinLsKeys(req *cmds.Request, typeStr string, api coreiface.CoreAPI, emit fu
 	}
 
 	for _, p := range req.Arguments {
-		p, err := cmdutils.PathOrCidPath(p)
+		p, err := cmdutils.PathOrAlias(req.Context, api, p)
 		if err != nil {
 			return err
 		}
@@ -619,12 +690,12 @@ pin.
 
 		unpin, _ := req.Options[pinUnpinOptionName].(bool)
 
-		fromPath, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		fromPath, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 		if err != nil {
 			return err
 		}
 
-		toPath, err := cmdutils.PathOrCidPath(req.Arguments[1])
+		toPath, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[1])
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,166 @@
+package pin
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/commands/cmdutils"
+)
+
+const pinProvideClassOptionName = "provide-class"
+
+type PinProvideClass struct {
+	Cid       string
+	Recursive bool
+	Class     string
+	Provided  time.Time `json:",omitempty"`
+}
+
+type PinProvideClassesOutput struct {
+	Pins []PinProvideClass
+}
+
+var provideClassPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Set the cadence the DAGs of pins are reprovided at.",
+		ShortDescription: `
+The DAGs of the pins with a provide class are reprovided to the routing system
+at the cadence of their class, instead of every Reprovider.Interval:
+
+  hot    reprovided hourly
+  warm   reprovided daily
+  cold   reprovided weekly
+  never  not reprovided
+
+so that the load of the DHT concentrates on the content that actually needs
+to be discoverable. The DAGs are reprovided according to Reprovider.Strategy:
+only their roots with the "roots" strategy. A block shared by a pin with a
+class and a pin without one follows the class.
+
+The class of a pin is set with 'ipfs pin provide-class set', or
+'ipfs pin add --provide-class', and cleared when the pin is removed.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"set": setProvideClassPinCmd,
+		"ls":  lsProvideClassPinCmd,
+	},
+}
+
+var setProvideClassPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Set the class of pins.",
+		ShortDescription: `
+Sets the provide class of pinned objects: "hot", "warm", "cold" or "never".
+An empty class, '""', clears it: their DAGs are reprovided every
+Reprovider.Interval again.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("class", true, false, "The provide class: hot, warm, cold or never."),
+		cmds.StringArg("ipfs-path", true, true, "Path to the pinned object(s).").EnableStdin(),
+	},
+	Type: PinOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		if err := req.ParseBodyArgs(); err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		class := req.Arguments[0]
+		pins := make([]string, 0, len(req.Arguments)-1)
+		for _, b := range req.Arguments[1:] {
+			p, err := cmdutils.PathOrAlias(req.Context, api, b)
+			if err != nil {
+				return err
+			}
+			rp, _, err := api.ResolvePath(req.Context, p)
+			if err != nil {
+				return err
+			}
+			if err := api.Pin().SetProvideClass(req.Context, rp, class); err != nil {
+				return fmt.Errorf("%s: %w", enc.Encode(rp.RootCid()), err)
+			}
+			pins = append(pins, enc.Encode(rp.RootCid()))
+		}
+		return cmds.EmitOnce(res, &PinOutput{pins})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PinOutput) error {
+			class := req.Arguments[0]
+			for _, k := range out.Pins {
+				if class == "" {
+					fmt.Fprintf(w, "cleared the provide class of %s\n", k)
+				} else {
+					fmt.Fprintf(w, "set the provide class of %s to %s\n", k, class)
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+var lsProvideClassPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the classes of pins.",
+		ShortDescription: `
+Lists the pins with a provide class: CID, mode, class and when their DAG was
+last reprovided for it.
+`,
+	},
+	Type: PinProvideClassesOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		classes, err := api.Pin().ProvideClasses(req.Context)
+		if err != nil {
+			return err
+		}
+		out := &PinProvideClassesOutput{Pins: make([]PinProvideClass, len(classes))}
+		for i, c := range classes {
+			out.Pins[i] = PinProvideClass{
+				Cid:       enc.Encode(c.Path.RootCid()),
+				Recursive: c.Recursive,
+				Class:     c.Class,
+				Provided:  c.Provided,
+			}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PinProvideClassesOutput) error {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			for _, p := range out.Pins {
+				mode := "direct"
+				if p.Recursive {
+					mode = "recursive"
+				}
+				provided := "not reprovided yet"
+				if !p.Provided.IsZero() {
+					provided = "reprovided " + p.Provided.Format(time.RFC3339)
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.Cid, mode, p.Class, provided)
+			}
+			return tw.Flush()
+		}),
+	},
+}
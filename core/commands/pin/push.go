@@ -0,0 +1,124 @@
+package pin
+
+import (
+	"fmt"
+	"io"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/coreiface/options"
+)
+
+const (
+	pinPushBandwidthOptionName = "bandwidth"
+	pinPushRetriesOptionName   = "retries"
+)
+
+type PinPushed struct {
+	Cid       string
+	Recursive bool
+	Name      string `json:",omitempty"`
+	Blocks    uint64
+	Bytes     uint64
+	Skipped   bool `json:",omitempty"`
+	Attempts  int
+}
+
+type PinPushOutput struct {
+	Pins []PinPushed
+}
+
+var pushPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Push pins and their DAGs to a peer.",
+		ShortDescription: `
+Sends the pins with the given name, or all the pins without a name argument,
+and their DAGs to a peer, which pins them:
+
+  > ipfs pin push 12D3KooW... photos --bandwidth=2MB
+
+The peer asks for the blocks it doesn't have only, so pushing pins the peer
+has partially, or again, is cheap. A push interrupted by a network error is
+resumed up to --retries times, and each pin is checked to be pinned by the
+peer once sent. --bandwidth caps the bandwidth used to send the blocks, in
+bytes per second.
+
+Both nodes need Experimental.PinReplication, and a node only accepts the pins
+pushed by the peers of its Peering.Peers.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("peer", true, false, "ID of the peer to push the pins to."),
+		cmds.StringArg("name", false, false, "Name of the pins pushed, all the pins when omitted."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(pinPushBandwidthOptionName, "Cap the bandwidth used to send the blocks, in bytes per second, e.g. 2MB."),
+		cmds.IntOption(pinPushRetriesOptionName, "Number of times an interrupted push of a pin is resumed.").WithDefault(3),
+	},
+	Type: PinPushOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+		p, err := peer.Decode(req.Arguments[0])
+		if err != nil {
+			return fmt.Errorf("invalid peer ID: %w", err)
+		}
+		var name string
+		if len(req.Arguments) > 1 {
+			name = req.Arguments[1]
+		}
+
+		retries, _ := req.Options[pinPushRetriesOptionName].(int)
+		opts := []options.ReplicatePushOption{options.Replicate.Retries(retries)}
+		if s, ok := req.Options[pinPushBandwidthOptionName].(string); ok {
+			v, err := humanize.ParseBytes(s)
+			if err != nil {
+				return fmt.Errorf("invalid --%s %q: %w", pinPushBandwidthOptionName, s, err)
+			}
+			opts = append(opts, options.Replicate.Bandwidth(v))
+		}
+
+		pushed, err := api.Replicate().Push(req.Context, p, name, opts...)
+		if err != nil {
+			return err
+		}
+		out := &PinPushOutput{Pins: make([]PinPushed, len(pushed))}
+		for i, r := range pushed {
+			out.Pins[i] = PinPushed{
+				Cid:       enc.Encode(r.Path.RootCid()),
+				Recursive: r.Recursive,
+				Name:      r.Name,
+				Blocks:    r.Blocks,
+				Bytes:     r.Bytes,
+				Skipped:   r.Skipped,
+				Attempts:  r.Attempts,
+			}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PinPushOutput) error {
+			for _, p := range out.Pins {
+				if p.Skipped {
+					fmt.Fprintf(w, "%s already pinned\n", p.Cid)
+					continue
+				}
+				fmt.Fprintf(w, "pushed %s: %d blocks, %s", p.Cid, p.Blocks, humanize.Bytes(p.Bytes))
+				if p.Attempts > 1 {
+					fmt.Fprintf(w, " in %d attempts", p.Attempts)
+				}
+				fmt.Fprintln(w)
+			}
+			return nil
+		}),
+	},
+}
@@ -0,0 +1,193 @@
+package pin
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	cidenc "github.com/ipfs/go-cidutil/cidenc"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+const pinPriorityOptionName = "priority"
+
+var queuePinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the queue of background pins.",
+		ShortDescription: `
+Pins added with 'ipfs pin add --background' are queued and processed by the
+daemon, by priority and a few at a time (Pinning.Queue.Concurrency). Pins of
+content that cannot be fetched are retried with an increasing delay, until
+they fail after Pinning.Queue.MaxAttempts attempts. The queue is persisted in
+the repo and resumed when the daemon restarts.
+
+Queued pins are 'queued', 'pinning' or 'failed'. Pinned ones leave the queue.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"ls":       lsQueuePinCmd,
+		"priority": priorityQueuePinCmd,
+		"cancel":   cancelQueuePinCmd,
+	},
+}
+
+type PinQueueOutput struct {
+	ID          string
+	Cid         string
+	Recursive   bool
+	Name        string `json:",omitempty"`
+	Priority    int
+	Status      string
+	Attempts    int
+	LastError   string `json:",omitempty"`
+	Created     time.Time
+	NextAttempt time.Time
+}
+
+func toPinQueueOutput(req coreiface.PinRequest, enc cidenc.Encoder) *PinQueueOutput {
+	return &PinQueueOutput{
+		ID:          req.ID,
+		Cid:         enc.Encode(req.Path.RootCid()),
+		Recursive:   req.Recursive,
+		Name:        req.Name,
+		Priority:    req.Priority,
+		Status:      req.Status,
+		Attempts:    req.Attempts,
+		LastError:   req.LastError,
+		Created:     req.Created,
+		NextAttempt: req.NextAttempt,
+	}
+}
+
+func encodePinQueueOutput(req *cmds.Request, w io.Writer, out *PinQueueOutput) error {
+	fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s", out.ID, out.Status, out.Priority, out.Cid, cmdenv.EscNonPrint(out.Name))
+	if out.LastError != "" {
+		fmt.Fprintf(w, "\t(%d attempts: %s)", out.Attempts, cmdenv.EscNonPrint(out.LastError))
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+var lsQueuePinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List queued pins.",
+		ShortDescription: `
+Lists the queued pins in the order they will be processed: ID, status,
+priority, CID and name.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.DelimitedStringsOption(",", pinStatusOptionName, "Return pins with the specified statuses (queued,pinning,failed).").WithDefault([]string{"queued", "pinning", "failed"}),
+	},
+	Type: PinQueueOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		statuses := make(map[string]bool)
+		rawStatuses, _ := req.Options[pinStatusOptionName].([]string)
+		for _, s := range rawStatuses {
+			switch s {
+			case "queued", "pinning", "failed":
+				statuses[s] = true
+			default:
+				return fmt.Errorf("status %q is not valid", s)
+			}
+		}
+
+		reqs, err := api.Pin().Queue(req.Context)
+		if err != nil {
+			return err
+		}
+		for _, r := range reqs {
+			if !statuses[r.Status] {
+				continue
+			}
+			out := toPinQueueOutput(r, enc)
+			if err := res.Emit(out); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(encodePinQueueOutput),
+	},
+}
+
+var priorityQueuePinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Change the priority of a queued pin.",
+		ShortDescription: `
+Moves a queued pin in the queue. Pins with a higher priority are processed
+first, pins with the same priority in the order they were added.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("id", true, false, "ID of the queued pin."),
+		cmds.StringArg("priority", true, false, "The new priority."),
+	},
+	Type: PinQueueOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		priority, err := strconv.Atoi(req.Arguments[1])
+		if err != nil {
+			return fmt.Errorf("invalid priority %q: %w", req.Arguments[1], err)
+		}
+
+		r, err := api.Pin().SetQueuePriority(req.Context, req.Arguments[0], priority)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, toPinQueueOutput(r, enc))
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(encodePinQueueOutput),
+	},
+}
+
+var cancelQueuePinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove pins from the queue.",
+		ShortDescription: `
+Removes pins from the queue, interrupting them if they are being pinned. The
+blocks fetched so far are not pinned, and will be removed by the next garbage
+collection.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("id", true, true, "ID of the queued pin(s)."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range req.Arguments {
+			if err := api.Pin().CancelQueued(req.Context, id); err != nil {
+				return fmt.Errorf("%s: %w", id, err)
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,112 @@
+package pin
+
+import (
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/ipfs/kubo/core/coreiface/options"
+)
+
+const pinEnqueueOptionName = "enqueue"
+
+type PinsetEntry struct {
+	Cid       string
+	Recursive bool
+}
+
+type PinReconcileOutput struct {
+	Missing []PinsetEntry
+	Extra   []PinsetEntry
+	// Queued are the IDs of the missing pins queued with --enqueue
+	Queued []string `json:",omitempty"`
+}
+
+var reconcilePinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Compare the local pinset with the pinset of a peer.",
+		ShortDescription: `
+Lists the pins of the given peer that are missing locally, and the local pins
+the peer doesn't have, without transferring the full pin lists: the nodes
+exchange summaries of their pinsets (MinHash sketches and invertible Bloom
+lookup tables) sized for the number of pins that differ.
+
+With --enqueue, the missing pins are queued as background pins (see
+'ipfs pin queue'), to replicate the pinset of the peer.
+
+Both nodes need Experimental.PinsetReconciliation, and a node only shares its
+pinset with the peers of its Peering.Peers.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("peer", true, false, "ID of the peer to compare pinsets with."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(pinEnqueueOptionName, "Queue the pins of the peer that are missing locally.").WithDefault(false),
+	},
+	Type: PinReconcileOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+		p, err := peer.Decode(req.Arguments[0])
+		if err != nil {
+			return fmt.Errorf("invalid peer ID: %w", err)
+		}
+
+		diff, err := api.Pin().Reconcile(req.Context, p)
+		if err != nil {
+			return err
+		}
+
+		toEntries := func(in []coreiface.PinsetEntry) []PinsetEntry {
+			entries := make([]PinsetEntry, len(in))
+			for i, e := range in {
+				entries[i] = PinsetEntry{Cid: enc.Encode(e.Path.RootCid()), Recursive: e.Recursive}
+			}
+			return entries
+		}
+		out := &PinReconcileOutput{Missing: toEntries(diff.Missing), Extra: toEntries(diff.Extra)}
+
+		if enqueue, _ := req.Options[pinEnqueueOptionName].(bool); enqueue {
+			for _, e := range diff.Missing {
+				r, err := api.Pin().Enqueue(req.Context, e.Path, options.Pin.Recursive(e.Recursive))
+				if err != nil {
+					return err
+				}
+				out.Queued = append(out.Queued, r.ID)
+			}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PinReconcileOutput) error {
+			mode := func(e PinsetEntry) string {
+				if e.Recursive {
+					return "recursive"
+				}
+				return "direct"
+			}
+			for _, e := range out.Missing {
+				fmt.Fprintf(w, "missing %s %s\n", e.Cid, mode(e))
+			}
+			for _, e := range out.Extra {
+				fmt.Fprintf(w, "extra %s %s\n", e.Cid, mode(e))
+			}
+			if len(out.Queued) > 0 {
+				fmt.Fprintf(w, "queued %d pins\n", len(out.Queued))
+			}
+			return nil
+		}),
+	},
+}
@@ -157,7 +157,7 @@ NOTE: a comma-separated notation is supported in CLI for convenience:
 		if err != nil {
 			return err
 		}
-		p, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		p, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
 		if err != nil {
 			return err
 		}
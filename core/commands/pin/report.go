@@ -0,0 +1,104 @@
+package pin
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/commands/cmdutils"
+)
+
+type PinReportSource struct {
+	Peer      string `json:",omitempty"`
+	Transport string
+	Blocks    uint64
+	Bytes     uint64
+}
+
+type PinReportOutput struct {
+	Cid       string
+	Recursive bool
+	Started   time.Time
+	Finished  time.Time
+	Blocks    uint64
+	Bytes     uint64
+	Sources   []PinReportSource
+}
+
+var reportPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show where the blocks of a pin came from.",
+		ShortDescription: `
+Shows how many blocks and bytes of the last pin of the given object came from
+which peers, and over which transports. Blocks that were already stored
+locally are reported as 'local'. Reports are recorded by 'ipfs pin add',
+including background pins.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "Path to the pinned object."),
+	},
+	Type: PinReportOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		p, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		report, err := api.Pin().Report(req.Context, p)
+		if err != nil {
+			return err
+		}
+
+		out := &PinReportOutput{
+			Cid:       enc.Encode(report.Path.RootCid()),
+			Recursive: report.Recursive,
+			Started:   report.Started,
+			Finished:  report.Finished,
+			Blocks:    report.Blocks,
+			Bytes:     report.Bytes,
+			Sources:   make([]PinReportSource, len(report.Sources)),
+		}
+		for i, s := range report.Sources {
+			out.Sources[i] = PinReportSource{
+				Transport: s.Transport,
+				Blocks:    s.Blocks,
+				Bytes:     s.Bytes,
+			}
+			if s.Peer != "" {
+				out.Sources[i].Peer = s.Peer.String()
+			}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PinReportOutput) error {
+			fmt.Fprintf(w, "%s: %d blocks, %s in %s\n", out.Cid, out.Blocks, humanize.Bytes(out.Bytes), out.Finished.Sub(out.Started).Round(time.Millisecond))
+
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			for _, s := range out.Sources {
+				source := s.Peer
+				if source == "" {
+					source = "-"
+				}
+				fmt.Fprintf(tw, "\t%s\t%s\t%d blocks\t%s\n", source, s.Transport, s.Blocks, humanize.Bytes(s.Bytes))
+			}
+			return tw.Flush()
+		}),
+	},
+}
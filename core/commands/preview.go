@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/commands/cmdutils"
+)
+
+type PreviewOutput struct {
+	Cid      string
+	MimeType string
+	// Thumbnail is a JPEG image, base64 encoded in JSON.
+	Thumbnail []byte `json:",omitempty"`
+	Width     int    `json:",omitempty"`
+	Height    int    `json:",omitempty"`
+	Snippet   string `json:",omitempty"`
+}
+
+var PreviewCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Show the preview of a file.",
+		ShortDescription: `
+Returns the preview of a file stored locally: a JPEG thumbnail of an image, or
+a snippet of a text file. Previews are made on the first request and cached,
+when Experimental.Previews is enabled.
+`,
+		LongDescription: `
+Returns the preview of a file stored locally: a JPEG thumbnail of an image, or
+a snippet of a text file. Previews are made on the first request and cached,
+when Experimental.Previews is enabled.
+
+Thumbnails are at most 256 pixels wide and high, and are base64 encoded in the
+JSON output. JPEG, PNG and GIF images are supported. Snippets are the first
+280 characters of text files.
+
+The gateway serves the same previews under /preview/<cid>, and shows them in
+its directory listings.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "The path to the file to preview."),
+	},
+	Type: PreviewOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if nd.Previews == nil {
+			return errors.New("previews are disabled, see Experimental.Previews")
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		p, err := cmdutils.PathOrAlias(req.Context, api, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		rp, _, err := api.ResolvePath(req.Context, p)
+		if err != nil {
+			return err
+		}
+		preview, err := nd.Previews.Preview(req.Context, rp.RootCid())
+		if err != nil {
+			if ipld.IsNotFound(err) {
+				return fmt.Errorf("%s is not stored locally", p)
+			}
+			return err
+		}
+
+		return cmds.EmitOnce(res, &PreviewOutput{
+			Cid:       enc.Encode(rp.RootCid()),
+			MimeType:  preview.MimeType,
+			Thumbnail: preview.Thumbnail,
+			Width:     preview.Width,
+			Height:    preview.Height,
+			Snippet:   preview.Snippet,
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PreviewOutput) error {
+			switch {
+			case len(out.Thumbnail) > 0:
+				fmt.Fprintf(w, "%s %dx%d, thumbnail of %d bytes\n", out.MimeType, out.Width, out.Height, len(out.Thumbnail))
+			case out.Snippet != "":
+				fmt.Fprintln(w, cmdenv.EscNonPrint(out.Snippet))
+			default:
+				fmt.Fprintf(w, "no preview of %s\n", out.Cid)
+			}
+			return nil
+		}),
+	},
+}
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+// ReadOnlyOutput is the read-only mode of the node.
+type ReadOnlyOutput struct {
+	Enabled bool
+}
+
+var ReadOnlyCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the read-only mode of the daemon.",
+		ShortDescription: `
+In read-only mode, the RPC commands that change the content, the pins, MFS,
+the keys, the IPNS names or the config of the node are rejected with a
+"read-only mode" error, while the other commands, the gateway and bitswap keep
+working. This is used to expose replicas safely.
+
+The daemon starts in read-only mode when Experimental.ReadOnlyMirror is set.
+'ipfs readonly enable' and 'ipfs readonly disable' toggle it until the daemon
+restarts. Use API.Authorizations to restrict who can call them.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"status":  readOnlyStatusCmd,
+		"enable":  readOnlySetCmd(true),
+		"disable": readOnlySetCmd(false),
+	},
+}
+
+var readOnlyEncoders = cmds.EncoderMap{
+	cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ReadOnlyOutput) error {
+		if out.Enabled {
+			_, err := fmt.Fprintln(w, "read-only")
+			return err
+		}
+		_, err := fmt.Fprintln(w, "read-write")
+		return err
+	}),
+}
+
+var readOnlyStatusCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show whether the daemon is read-only.",
+	},
+	NoLocal: true,
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &ReadOnlyOutput{Enabled: nd.ReadOnly.Enabled()})
+	},
+	Encoders: readOnlyEncoders,
+	Type:     ReadOnlyOutput{},
+}
+
+func readOnlySetCmd(enabled bool) *cmds.Command {
+	tagline := "Make the daemon read-only."
+	if !enabled {
+		tagline = "Make the daemon writable again."
+	}
+	return &cmds.Command{
+		Helptext: cmds.HelpText{
+			Tagline: tagline,
+		},
+		NoLocal: true,
+		Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+			nd, err := cmdenv.GetNode(env)
+			if err != nil {
+				return err
+			}
+			if !nd.IsDaemon {
+				return cmds.Errorf(cmds.ErrClient, "daemon not running")
+			}
+			nd.ReadOnly.Set(enabled)
+			if enabled {
+				log.Info("the node is now read-only")
+			} else {
+				log.Info("the node is now writable")
+			}
+			return cmds.EmitOnce(res, &ReadOnlyOutput{Enabled: enabled})
+		},
+		Encoders: readOnlyEncoders,
+		Type:     ReadOnlyOutput{},
+	}
+}
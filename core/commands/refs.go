@@ -16,6 +16,7 @@ import (
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	ipld "github.com/ipfs/go-ipld-format"
 	iface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/ipfs/kubo/core/reqbudget"
 )
 
 var refsEncoderMap = cmds.EncoderMap{
@@ -122,6 +123,9 @@ NOTE: Like most other commands, Kubo will try to fetch the blocks of the passed
 
 		for _, o := range objs {
 			if _, err := rw.WriteRefs(o, enc); err != nil {
+				if errors.Is(err, reqbudget.ErrExceeded) {
+					return err
+				}
 				if err := res.Emit(&RefWrapper{Err: err.Error()}); err != nil {
 					return err
 				}
@@ -171,7 +175,7 @@ Displays the hashes of all local objects. NOTE: This treats all local objects as
 func objectsForPaths(ctx context.Context, n iface.CoreAPI, paths []string) ([]cid.Cid, error) {
 	roots := make([]cid.Cid, len(paths))
 	for i, sp := range paths {
-		p, err := cmdutils.PathOrCidPath(sp)
+		p, err := cmdutils.PathOrAlias(ctx, n, sp)
 		if err != nil {
 			return nil, err
 		}
@@ -201,6 +205,18 @@ type RefWriter struct {
 	seen map[string]int
 }
 
+// seenEntryOverhead approximates the memory used by an entry of
+// RefWriter.seen besides its key.
+const seenEntryOverhead = 48
+
+// budget returns the request budget of rw.Ctx, if any.
+func (rw *RefWriter) budget() *reqbudget.Budget {
+	if rw.Ctx == nil {
+		return nil
+	}
+	return reqbudget.FromContext(rw.Ctx)
+}
+
 // WriteRefs writes refs of the given object to the underlying writer.
 func (rw *RefWriter) WriteRefs(c cid.Cid, enc cidenc.Encoder) (int, error) {
 	n, err := rw.DAG.Get(rw.Ctx, c)
@@ -213,10 +229,25 @@ func (rw *RefWriter) WriteRefs(c cid.Cid, enc cidenc.Encoder) (int, error) {
 func (rw *RefWriter) writeRefsRecursive(n ipld.Node, depth int, enc cidenc.Encoder) (int, error) {
 	nc := n.Cid()
 
+	// Each level holds its node, and fetches the children in a goroutine.
+	budget := rw.budget()
+	size := int64(len(n.RawData()))
+	if err := budget.Alloc(size); err != nil {
+		return 0, err
+	}
+	defer budget.Free(size)
+	if err := budget.Acquire(); err != nil {
+		return 0, err
+	}
+	defer budget.Release()
+
 	var count int
 	for i, ng := range ipld.GetDAG(rw.Ctx, rw.DAG, n) {
 		lc := n.Links()[i].Cid
-		goDeeper, shouldWrite := rw.visit(lc, depth+1) // The children are at depth+1
+		goDeeper, shouldWrite, err := rw.visit(lc, depth+1) // The children are at depth+1
+		if err != nil {
+			return count, err
+		}
 
 		// Avoid "Get()" on the node and continue with next Link.
 		// We can do this if:
@@ -267,12 +298,15 @@ func (rw *RefWriter) writeRefsRecursive(n ipld.Node, depth int, enc cidenc.Encod
 // - the first boolean is true if we should keep traversing the DAG
 // - the second boolean is true if we should print the CID
 //
+// It fails when the set of visited CIDs exceeds the memory budget of the
+// request.
+//
 // visit will do branch pruning depending on rw.MaxDepth, previously visited
 // cids and whether rw.Unique is set. i.e. rw.Unique = false and
 // rw.MaxDepth = -1 disables any pruning. But setting rw.Unique to true will
 // prune already visited branches at the cost of keeping as set of visited
 // CIDs in memory.
-func (rw *RefWriter) visit(c cid.Cid, depth int) (bool, bool) {
+func (rw *RefWriter) visit(c cid.Cid, depth int) (bool, bool, error) {
 	atMaxDepth := rw.MaxDepth >= 0 && depth == rw.MaxDepth
 	overMaxDepth := rw.MaxDepth >= 0 && depth > rw.MaxDepth
 
@@ -281,14 +315,14 @@ func (rw *RefWriter) visit(c cid.Cid, depth int) (bool, bool) {
 	// children are already over max depth. Otherwise nothing should
 	// hit this.
 	if overMaxDepth {
-		return false, false
+		return false, false, nil
 	}
 
 	// We can shortcut right away if we don't need unique output:
 	//   - we keep traversing when not atMaxDepth
 	//   - always print
 	if !rw.Unique {
-		return !atMaxDepth, true
+		return !atMaxDepth, true, nil
 	}
 
 	// Unique == true from this point.
@@ -308,7 +342,7 @@ func (rw *RefWriter) visit(c cid.Cid, depth int) (bool, bool) {
 	//     explored deep enough before)
 	// Because we saw the CID, we don't print it again.
 	if ok && (rw.MaxDepth < 0 || oldDepth <= depth) {
-		return false, false
+		return false, false, nil
 	}
 
 	// Final case, we must keep exploring the DAG from this CID
@@ -316,8 +350,14 @@ func (rw *RefWriter) visit(c cid.Cid, depth int) (bool, bool) {
 	// We note down its depth because it was either not seen
 	// or is lower than last time.
 	// We print if it was not seen.
+	if !ok {
+		// the set is kept until the end of the request
+		if err := rw.budget().Alloc(int64(len(key)) + seenEntryOverhead); err != nil {
+			return false, false, err
+		}
+	}
 	rw.seen[key] = depth
-	return !atMaxDepth, !ok
+	return !atMaxDepth, !ok, nil
 }
 
 // Write one edge
@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/commands/pin"
+	p2phttp "github.com/libp2p/go-libp2p-http"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// RemoteAdminProtocol is the libp2p protocol serving the RootRemoteAdmin
+// commands over HTTP to the operators listed in RemoteAdmin.Operators.
+const RemoteAdminProtocol protocol.ID = "/kubo/admin/1.0.0"
+
+// RootRemoteAdmin is the subset of Root that operators may call remotely.
+var RootRemoteAdmin = &cmds.Command{}
+
+// configGetCmd is `ipfs config <key>`, without the ability to set the value.
+var configGetCmd = &cmds.Command{}
+
+var rootRemoteAdminSubcommands = map[string]*cmds.Command{
+	"stats": StatsCmd,
+	"pin": {
+		Subcommands: map[string]*cmds.Command{
+			"add": pin.PinCmd.Subcommands["add"],
+			"rm":  pin.PinCmd.Subcommands["rm"],
+			"ls":  pin.PinCmd.Subcommands["ls"],
+		},
+	},
+	"repo": {
+		Subcommands: map[string]*cmds.Command{
+			"gc":   repoGcCmd,
+			"stat": repoStatCmd,
+		},
+	},
+	"config":  configGetCmd,
+	"id":      IDCmd,
+	"version": VersionROCmd,
+}
+
+const adminOptionOptionName = "option"
+
+var AdminCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Administer remote nodes over libp2p.",
+		ShortDescription: `
+Nodes that set RemoteAdmin.Enabled accept a safe subset of commands from the
+peer IDs listed in RemoteAdmin.Operators, over libp2p. This works with nodes
+behind NATs and does not need their RPC API to be exposed.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"call": adminCallCmd,
+	},
+}
+
+var adminCallCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Call a command on a remote node.",
+		ShortDescription: `
+Calls a command on a remote node, with the identity of this node, which must
+be listed in RemoteAdmin.Operators of the remote node. The remote node returns
+the output of the command as JSON.
+
+The commands available remotely are 'stats', 'pin add', 'pin rm', 'pin ls',
+'repo gc', 'repo stat', 'config <key>', 'id' and 'version'.
+
+Example:
+
+  $ ipfs admin call 12D3KooW... pin add bafy...
+  $ ipfs admin call --option=stream-channels=true 12D3KooW... repo gc
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("peer", true, false, "ID of the remote node."),
+		cmds.StringArg("command", true, true, "The command and its arguments."),
+	},
+	Options: []cmds.Option{
+		cmds.StringsOption(adminOptionOptionName, "o", "Option of the remote command, as name=value."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+
+		pid, err := peer.Decode(req.Arguments[0])
+		if err != nil {
+			return fmt.Errorf("invalid peer ID: %w", err)
+		}
+
+		// the leading arguments that name a command form the path, the
+		// rest are its arguments
+		path, args := splitRemoteCommand(req.Arguments[1:])
+		if len(path) == 0 {
+			return errors.New("not a remote administration command")
+		}
+
+		query := url.Values{}
+		for _, arg := range args {
+			query.Add("arg", arg)
+		}
+		opts, _ := req.Options[adminOptionOptionName].([]string)
+		for _, opt := range opts {
+			name, value, ok := strings.Cut(opt, "=")
+			if !ok {
+				return fmt.Errorf("invalid option %q, expected name=value", opt)
+			}
+			query.Add(name, value)
+		}
+		query.Set("encoding", cmds.JSON)
+
+		u := fmt.Sprintf("libp2p://%s/api/v0/%s?%s", pid, strings.Join(path, "/"), query.Encode())
+		hreq, err := http.NewRequestWithContext(req.Context, http.MethodPost, u, nil)
+		if err != nil {
+			return err
+		}
+		client := &http.Client{Transport: p2phttp.NewTransport(nd.PeerHost, p2phttp.ProtocolOption(RemoteAdminProtocol))}
+		resp, err := client.Do(hreq)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			return fmt.Errorf("remote node answered %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+		}
+		return res.Emit(resp.Body)
+	},
+}
+
+func splitRemoteCommand(words []string) ([]string, []string) {
+	cmd := RootRemoteAdmin
+	for i, w := range words {
+		sub, ok := cmd.Subcommands[w]
+		if !ok {
+			return words[:i], words[i:]
+		}
+		cmd = sub
+	}
+	return words, nil
+}
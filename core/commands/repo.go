@@ -10,10 +10,15 @@ import (
 	"strings"
 	"sync"
 	"text/tabwriter"
+	"time"
 
+	"github.com/ipfs/kubo/blocks/bloombs"
+	"github.com/ipfs/kubo/blocks/compressbs"
 	oldcmds "github.com/ipfs/kubo/commands"
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
 	corerepo "github.com/ipfs/kubo/core/corerepo"
+	"github.com/ipfs/kubo/core/hashing"
+	"github.com/ipfs/kubo/gc"
 	fsrepo "github.com/ipfs/kubo/repo/fsrepo"
 	"github.com/ipfs/kubo/repo/fsrepo/migrations"
 	"github.com/ipfs/kubo/repo/fsrepo/migrations/ipfsfetcher"
@@ -37,25 +42,33 @@ var RepoCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"stat":    repoStatCmd,
-		"gc":      repoGcCmd,
-		"version": repoVersionCmd,
-		"verify":  repoVerifyCmd,
-		"migrate": repoMigrateCmd,
-		"ls":      RefsLocalCmd,
+		"stat":       repoStatCmd,
+		"gc":         repoGcCmd,
+		"version":    repoVersionCmd,
+		"verify":     repoVerifyCmd,
+		"migrate":    repoMigrateCmd,
+		"ls":         RefsLocalCmd,
+		"forecast":   repoForecastCmd,
+		"contexts":   repoContextsCmd,
+		"writeback":  repoWriteBackCmd,
+		"namespaces": repoNamespacesCmd,
+		"gc-report":  repoGcReportCmd,
 	},
 }
 
 // GcResult is the result returned by "repo gc" command.
 type GcResult struct {
 	Key   cid.Cid
-	Error string `json:",omitempty"`
+	Error string            `json:",omitempty"`
+	Shard *gc.ShardProgress `json:",omitempty"`
 }
 
 const (
 	repoStreamErrorsOptionName   = "stream-errors"
 	repoQuietOptionName          = "quiet"
 	repoSilentOptionName         = "silent"
+	repoGcConcurrencyOptionName  = "concurrency"
+	repoGcProgressOptionName     = "progress"
 	repoAllowDowngradeOptionName = "allow-downgrade"
 )
 
@@ -66,12 +79,22 @@ var repoGcCmd = &cmds.Command{
 'ipfs repo gc' is a plumbing command that will sweep the local
 set of stored objects and remove ones that are not pinned in
 order to reclaim hard disk space.
+
+When the blocks are stored in flatfs, the shard directories are listed
+and swept in parallel, --concurrency of them at a time, which defaults
+to Datastore.GCConcurrency. With --progress, a line is written after
+each shard swept.
+
+A report of the garbage collection is stored in the repo when it ends, and
+shown by 'ipfs repo gc-report'.
 `,
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(repoStreamErrorsOptionName, "Stream errors."),
 		cmds.BoolOption(repoQuietOptionName, "q", "Write minimal output."),
 		cmds.BoolOption(repoSilentOptionName, "Write no output."),
+		cmds.IntOption(repoGcConcurrencyOptionName, "Number of shards swept at the same time. Default: Datastore.GCConcurrency."),
+		cmds.BoolOption(repoGcProgressOptionName, "Write the progress of the sweep of each shard."),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		n, err := cmdenv.GetNode(env)
@@ -82,12 +105,28 @@ order to reclaim hard disk space.
 		silent, _ := req.Options[repoSilentOptionName].(bool)
 		streamErrors, _ := req.Options[repoStreamErrorsOptionName].(bool)
 
-		gcOutChan := corerepo.GarbageCollectAsync(n, req.Context)
+		opts, err := corerepo.GCOptions(n)
+		if err != nil {
+			return err
+		}
+		if concurrency, ok := req.Options[repoGcConcurrencyOptionName].(int); ok {
+			if concurrency < 1 {
+				return fmt.Errorf("%s must be positive", repoGcConcurrencyOptionName)
+			}
+			opts.Concurrency = concurrency
+		}
+		opts.Progress, _ = req.Options[repoGcProgressOptionName].(bool)
+
+		gcOutChan := corerepo.GarbageCollectAsyncWithOptions(n, req.Context, opts)
 
 		if streamErrors {
 			errs := false
 			for res := range gcOutChan {
-				if res.Error != nil {
+				if res.Shard != nil {
+					if err := re.Emit(&GcResult{Shard: res.Shard}); err != nil {
+						return err
+					}
+				} else if res.Error != nil {
 					if err := re.Emit(&GcResult{Error: res.Error.Error()}); err != nil {
 						return err
 					}
@@ -102,7 +141,7 @@ order to reclaim hard disk space.
 				return errors.New("encountered errors during gc run")
 			}
 		} else {
-			err := corerepo.CollectResult(req.Context, gcOutChan, func(k cid.Cid) {
+			err := corerepo.CollectResultWithProgress(req.Context, gcOutChan, func(k cid.Cid) {
 				if silent {
 					return
 				}
@@ -110,6 +149,11 @@ order to reclaim hard disk space.
 				// most likely means that the client is gone but
 				// we still need to let the GC finish.
 				_ = re.Emit(&GcResult{Key: k})
+			}, func(shard *gc.ShardProgress) {
+				if silent {
+					return
+				}
+				_ = re.Emit(&GcResult{Shard: shard})
 			})
 			if err != nil {
 				return err
@@ -133,6 +177,14 @@ order to reclaim hard disk space.
 				return err
 			}
 
+			if s := gcr.Shard; s != nil {
+				if quiet {
+					return nil
+				}
+				_, err := fmt.Fprintf(w, "swept shard %s (%d/%d): removed %d of %d blocks\n", s.Name, s.Done, s.Total, s.Removed, s.Keys)
+				return err
+			}
+
 			prefix := "removed "
 			if quiet {
 				prefix = ""
@@ -161,6 +213,10 @@ StorageMax      string Maximum datastore size (from configuration)
 NumObjects      int Number of objects in the local repo.
 RepoPath        string The path to the repo being currently used.
 Version         string The repo version.
+BloomFilter     The state of the bloom filter of the blockstore, when
+                Datastore.BloomFilterSize is set: building, with the number
+                of keys added so far, ready, or failed. The filter is saved
+                when the node stops, and loaded back when it starts.
 `,
 	},
 	Options: []cmds.Option{
@@ -219,6 +275,20 @@ Version         string The repo version.
 			if !sizeOnly {
 				fmt.Fprintf(wtr, "RepoPath:\t%s\n", stat.RepoPath)
 				fmt.Fprintf(wtr, "Version:\t%s\n", stat.Version)
+				if b := stat.BloomFilter; b != nil {
+					how := "built"
+					if b.Loaded {
+						how = "loaded"
+					}
+					switch b.State {
+					case bloombs.StateReady:
+						fmt.Fprintf(wtr, "BloomFilter:\t%s, %s in %s\n", b.State, how, b.Duration.Round(time.Millisecond))
+					case bloombs.StateFailed:
+						fmt.Fprintf(wtr, "BloomFilter:\t%s: %s\n", b.State, b.Error)
+					default:
+						fmt.Fprintf(wtr, "BloomFilter:\t%s, %d keys in %s\n", b.State, b.Keys, b.Duration.Round(time.Second))
+					}
+				}
 			}
 
 			return nil
@@ -262,7 +332,7 @@ func verifyResultChan(ctx context.Context, keys <-chan cid.Cid, bs bstore.Blocks
 
 		var wg sync.WaitGroup
 
-		for i := 0; i < runtime.NumCPU()*2; i++ {
+		for i := 0; i < hashing.Concurrency(runtime.NumCPU()*2); i++ {
 			wg.Add(1)
 			go verifyWorkerRun(ctx, &wg, keys, results, bs)
 		}
@@ -283,7 +353,11 @@ var repoVerifyCmd = &cmds.Command{
 			return err
 		}
 
-		bs := bstore.NewBlockstore(nd.Repo.Datastore())
+		// compressed blocks have to be decompressed before they can be hashed
+		bs, err := compressbs.New(bstore.NewBlockstore(nd.Repo.Datastore()), nd.Repo.Datastore(), compressbs.Policy{})
+		if err != nil {
+			return err
+		}
 		bs.HashOnRead(true)
 
 		keys, err := bs.AllKeysChan(req.Context)
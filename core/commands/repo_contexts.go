@@ -0,0 +1,189 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+// RepoContextOutput is a repo context of the daemon.
+type RepoContextOutput struct {
+	Name     string
+	Path     string
+	Identity string
+	PeerID   string
+}
+
+// RepoContextsOutput are the repo contexts of the daemon.
+type RepoContextsOutput struct {
+	Contexts []RepoContextOutput
+}
+
+var repoContextsCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "List the repo contexts of the daemon.",
+		ShortDescription: `
+'ipfs repo contexts' lists the repos the daemon opened next to its own one,
+from the Repos config key. Each context has its own blockstore, pins and keys,
+and is selected with the global --context option:
+
+  ipfs --context=customer1 add file.txt
+
+Contexts with the "shared" identity fetch and publish with the libp2p identity
+of the daemon, contexts with the "own" identity run their own libp2p host.
+Only the commands using the core API, such as add, cat, pin, block, dag, key
+and name, honor --context.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		out := &RepoContextsOutput{Contexts: []RepoContextOutput{}}
+		for _, name := range n.Contexts.Names() {
+			c := n.Contexts[name]
+			// the peer ID the context fetches and publishes with
+			id := c.Node.Identity
+			if c.Host != nil {
+				id = c.Host.Identity
+			}
+			out.Contexts = append(out.Contexts, RepoContextOutput{
+				Name:     c.Name,
+				Path:     c.Path,
+				Identity: c.Identity(),
+				PeerID:   id.String(),
+			})
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: RepoContextsOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *RepoContextsOutput) error {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			defer tw.Flush()
+			fmt.Fprintln(tw, "Name\tIdentity\tPeer ID\tPath")
+			for _, c := range out.Contexts {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Name, c.Identity, c.PeerID, c.Path)
+			}
+			return nil
+		}),
+	},
+}
+
+// contextCommands are the commands honoring the global --context option. They
+// only use the core API, which is bound to the repo context, while the other
+// commands use the node of the daemon directly, and reject the option rather
+// than run against the repo of the daemon.
+var contextCommands = map[string]bool{
+	"/add":                      true,
+	"/alias/export":             true,
+	"/alias/get":                true,
+	"/alias/import":             true,
+	"/alias/ls":                 true,
+	"/alias/rm":                 true,
+	"/alias/set":                true,
+	"/block/get":                true,
+	"/block/put":                true,
+	"/block/rm":                 true,
+	"/block/stat":               true,
+	"/cache/warm":               true,
+	"/cat":                      true,
+	"/dag/export":               true,
+	"/dag/fetch":                true,
+	"/dag/get":                  true,
+	"/dag/inspect":              true,
+	"/dag/prune":                true,
+	"/dag/put":                  true,
+	"/dag/resolve":              true,
+	"/dag/stat":                 true,
+	"/dag/transcode":            true,
+	"/get":                      true,
+	"/key/derive":               true,
+	"/key/gen":                  true,
+	"/key/list":                 true,
+	"/key/rename":               true,
+	"/key/rm":                   true,
+	"/key/sign":                 true,
+	"/key/verify":               true,
+	"/kv/get":                   true,
+	"/kv/ls":                    true,
+	"/kv/put":                   true,
+	"/kv/rm":                    true,
+	"/kv/sub":                   true,
+	"/ls":                       true,
+	"/name/publish":             true,
+	"/name/resolve":             true,
+	"/object/data":              true,
+	"/object/diff":              true,
+	"/object/get":               true,
+	"/object/links":             true,
+	"/object/new":               true,
+	"/object/patch/add-link":    true,
+	"/object/patch/append-data": true,
+	"/object/patch/rm-link":     true,
+	"/object/patch/set-data":    true,
+	"/object/put":               true,
+	"/object/stat":              true,
+	"/pin/add":                  true,
+	"/pin/export":               true,
+	"/pin/ls":                   true,
+	"/pin/provide-class/ls":     true,
+	"/pin/provide-class/set":    true,
+	"/pin/push":                 true,
+	"/pin/queue/cancel":         true,
+	"/pin/queue/ls":             true,
+	"/pin/queue/priority":       true,
+	"/pin/reconcile":            true,
+	"/pin/removed":              true,
+	"/pin/report":               true,
+	"/pin/restore":              true,
+	"/pin/rm":                   true,
+	"/pin/update":               true,
+	"/refs":                     true,
+	"/repo/gc-report":           true,
+	"/resolve":                  true,
+	"/search":                   true,
+	"/search/text":              true,
+}
+
+// rejectRepoContext makes the commands under subcommands not in
+// contextCommands fail when the --context option is set.
+func rejectRepoContext(subcommands map[string]*cmds.Command) {
+	allowed := make(map[*cmds.Command]bool)
+	var all []*cmds.Command
+	var walk func(path string, c *cmds.Command)
+	walk = func(path string, c *cmds.Command) {
+		if contextCommands[path] {
+			allowed[c] = true
+		}
+		all = append(all, c)
+		for name, sub := range c.Subcommands {
+			walk(path+"/"+name, sub)
+		}
+	}
+	for name, c := range subcommands {
+		walk("/"+name, c)
+	}
+
+	wrapped := make(map[*cmds.Command]bool)
+	for _, c := range all {
+		if c.Run == nil || allowed[c] || wrapped[c] {
+			continue
+		}
+		wrapped[c] = true
+		run := c.Run
+		c.Run = func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+			if name, _ := req.Options[ContextOption].(string); name != "" {
+				return fmt.Errorf("'ipfs %s' doesn't support the --%s option", strings.Join(req.Path, " "), ContextOption)
+			}
+			return run(req, res, env)
+		}
+	}
+}
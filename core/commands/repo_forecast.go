@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/repoforecast"
+)
+
+// RepoForecastOutput is the forecast of the growth of the repo. Previous is
+// the level before an alert, with --watch.
+type RepoForecastOutput struct {
+	repoforecast.Forecast
+	Previous repoforecast.Level `json:",omitempty"`
+}
+
+const (
+	repoForecastSampleOptionName = "sample"
+	repoForecastWatchOptionName  = "watch"
+)
+
+var repoForecastCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Forecast when the repo reaches Datastore.StorageMax.",
+		ShortDescription: `
+'ipfs repo forecast' shows the growth rate of the repo, computed from the
+samples of its size taken by the daemon over Datastore.Forecast.Window, and
+when the repo is forecast to reach Datastore.StorageMax at that rate.
+
+The level of the forecast turns to warning, then critical, when the repo is
+forecast to be full within Datastore.Forecast.WarningPeriod, then
+Datastore.Forecast.CriticalPeriod. Alerts are logged and posted to
+Datastore.Forecast.Webhook when the level changes, and are streamed with
+--watch.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(repoForecastSampleOptionName, "Sample the size of the repo before forecasting."),
+		cmds.BoolOption(repoForecastWatchOptionName, "w", "Stream the alerts raised when the level of the forecast changes."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if nd.RepoForecast == nil {
+			return errors.New("the repo forecast is disabled, see Datastore.Forecast.Enabled")
+		}
+
+		watch, _ := req.Options[repoForecastWatchOptionName].(bool)
+		var alerts <-chan repoforecast.Alert
+		if watch {
+			if !nd.IsOnline {
+				return errors.New("--watch needs the daemon to be running")
+			}
+			alerts = nd.RepoForecast.Subscribe(req.Context)
+		}
+
+		f := nd.RepoForecast.Forecast()
+		if sample, _ := req.Options[repoForecastSampleOptionName].(bool); sample {
+			f, err = nd.RepoForecast.Sample(req.Context)
+			if err != nil {
+				return err
+			}
+		}
+		if err := res.Emit(&RepoForecastOutput{Forecast: f}); err != nil {
+			return err
+		}
+
+		if !watch {
+			return nil
+		}
+		for a := range alerts {
+			if err := res.Emit(&RepoForecastOutput{Forecast: a.Forecast, Previous: a.Previous}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: RepoForecastOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *RepoForecastOutput) error {
+			if out.Previous != "" {
+				fmt.Fprintf(w, "Level changed from %s to %s at %s\n", out.Previous, out.Level, out.Updated.Format(time.RFC3339))
+			}
+			if out.Samples == 0 {
+				fmt.Fprintln(w, "The size of the repo wasn't sampled yet.")
+				return nil
+			}
+
+			wtr := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			defer wtr.Flush()
+
+			fmt.Fprintf(wtr, "Size:\t%s of %s\n", humanize.Bytes(out.Size), humanize.Bytes(out.StorageMax))
+			fmt.Fprintf(wtr, "Growth:\t%s/day (%d samples)\n", humanRate(out.Rate*24*60*60), out.Samples)
+			if out.Full != nil {
+				fmt.Fprintf(wtr, "Full:\t%s (in %s)\n", out.Full.Format(time.RFC3339), time.Until(*out.Full).Round(time.Minute))
+			} else {
+				fmt.Fprintf(wtr, "Full:\tnever at this rate\n")
+			}
+			fmt.Fprintf(wtr, "Level:\t%s\n", out.Level)
+			return nil
+		}),
+	},
+}
+
+// humanRate formats a number of bytes that can be negative.
+func humanRate(bytes float64) string {
+	if bytes < 0 {
+		return "-" + humanize.Bytes(uint64(-bytes))
+	}
+	return humanize.Bytes(uint64(bytes))
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+// GCReportOutput is the report of the last garbage collection, as written by
+// 'ipfs repo gc-report'.
+type GCReportOutput struct {
+	Started    time.Time
+	Duration   time.Duration
+	Removed    int
+	FreedBytes uint64
+	Retained   map[string]int
+	ErrorCount int
+	Errors     []string `json:",omitempty"`
+}
+
+var repoGcReportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the report of the last garbage collection.",
+		ShortDescription: `
+'ipfs repo gc-report' shows the report stored by the last garbage collection
+of the repo, whether run by 'ipfs repo gc' or automatically: when it started,
+how long it ran, the number of blocks it removed and their size, the number of
+blocks it retained by the reason they were kept for, and its errors.
+
+The blocks retained are counted by pin type: "recursive" for the DAGs of the
+recursive pins, "direct" for the direct pins, "best-effort" for the MFS root,
+"internal" for the internal pinning data and "other" for the blocks kept by
+the node, such as the removed pins in their grace period.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		report, err := api.Repo().LastGCReport(req.Context)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &GCReportOutput{
+			Started:    report.Started,
+			Duration:   report.Duration,
+			Removed:    report.Removed,
+			FreedBytes: report.FreedBytes,
+			Retained:   report.Retained,
+			ErrorCount: report.ErrorCount,
+			Errors:     report.Errors,
+		})
+	},
+	Type: GCReportOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *GCReportOutput) error {
+			fmt.Fprintf(w, "Started:\t%s\n", out.Started.Format(time.RFC3339))
+			fmt.Fprintf(w, "Duration:\t%s\n", out.Duration.Round(time.Millisecond))
+			fmt.Fprintf(w, "Removed:\t%d blocks\n", out.Removed)
+			fmt.Fprintf(w, "Freed:\t%s\n", humanize.Bytes(out.FreedBytes))
+
+			kinds := make([]string, 0, len(out.Retained))
+			for kind := range out.Retained {
+				kinds = append(kinds, kind)
+			}
+			sort.Strings(kinds)
+			fmt.Fprintln(w, "Retained:")
+			for _, kind := range kinds {
+				fmt.Fprintf(w, "  %s:\t%d blocks\n", kind, out.Retained[kind])
+			}
+
+			fmt.Fprintf(w, "Errors:\t%d\n", out.ErrorCount)
+			for _, e := range out.Errors {
+				fmt.Fprintf(w, "  %s\n", e)
+			}
+			return nil
+		}),
+	},
+}
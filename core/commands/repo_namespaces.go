@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/corerepo"
+)
+
+// RepoNamespacesOutput is the usage of the datastore by key namespace, and
+// of the blockstore by reachability with --blocks.
+type RepoNamespacesOutput struct {
+	Namespaces []corerepo.NamespaceStat
+	Blocks     *corerepo.BlockUsage `json:",omitempty"`
+}
+
+const (
+	repoNamespacesBlocksOptionName = "blocks"
+)
+
+var repoNamespacesCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the size of the datastore by key namespace.",
+		ShortDescription: `
+'ipfs repo namespaces' scans the keys of the datastore and shows the number
+and the size of the entries under each namespace, such as the blocks, the
+pins, the provider records, the peerstore or the DHT records, largest first.
+
+With --blocks, the blocks are also split between the live ones, reachable from
+the pins and MFS, and the unreachable ones, which 'ipfs repo gc' removes. This
+walks the pinned DAGs, like the garbage collector.
+
+Both scans read the whole repo, which takes a while on large repos.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(repoNamespacesBlocksOptionName, "Split the blocks between the live and the unreachable ones."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		out := &RepoNamespacesOutput{}
+		out.Namespaces, err = corerepo.DatastoreNamespaces(req.Context, n.Repo.Datastore())
+		if err != nil {
+			return err
+		}
+		if blocks, _ := req.Options[repoNamespacesBlocksOptionName].(bool); blocks {
+			usage, err := corerepo.BlocksUsage(req.Context, n)
+			if err != nil {
+				return err
+			}
+			out.Blocks = &usage
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: RepoNamespacesOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *RepoNamespacesOutput) error {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "Namespace\tEntries\tSize\tDescription")
+			for _, s := range out.Namespaces {
+				fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", s.Namespace, s.Count, humanize.Bytes(s.Size), s.Description)
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+
+			if b := out.Blocks; b != nil {
+				fmt.Fprintf(w, "\nLive blocks:        %d (%s)\n", b.Live, humanize.Bytes(b.LiveSize))
+				fmt.Fprintf(w, "Unreachable blocks: %d (%s), removed by 'ipfs repo gc'\n", b.Unreachable, humanize.Bytes(b.UnreachableSize))
+				if b.MissingLinks > 0 {
+					fmt.Fprintf(w, "Pinned blocks whose links couldn't be read: %d\n", b.MissingLinks)
+				}
+			}
+			return nil
+		}),
+	},
+}
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/blocks/writebackbs"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+var errWriteBackDisabled = errors.New("the write-back journal is disabled, see Datastore.WriteBack.Enabled")
+
+var writeBackTextEncoder = cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *writebackbs.Status) error {
+	tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+	defer tw.Flush()
+	fmt.Fprintf(tw, "Pending:\t%s (%s)\n", humanNumber(s.Pending), humanize.Bytes(uint64(s.PendingBytes)))
+	fmt.Fprintf(tw, "Flushed:\t%s\n", humanNumber(s.Flushed))
+	fmt.Fprintf(tw, "Replayed:\t%s\n", humanNumber(s.Replayed))
+	fmt.Fprintf(tw, "Failed:\t%s\n", humanNumber(s.Failed))
+	if s.LastError != "" {
+		fmt.Fprintf(tw, "LastError:\t%s\n", s.LastError)
+	}
+	return nil
+})
+
+var repoWriteBackCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Show the blocks waiting in the write-back journal.",
+		ShortDescription: `
+'ipfs repo writeback' shows the blocks journaled but not flushed to the
+datastore yet, and the number of blocks flushed since the node started. The
+journal is enabled with the Datastore.WriteBack.Enabled config option.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"flush": repoWriteBackFlushCmd,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if nd.WriteBack == nil {
+			return errWriteBackDisabled
+		}
+		s := nd.WriteBack.Status()
+		return cmds.EmitOnce(res, &s)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: writeBackTextEncoder,
+	},
+	Type: writebackbs.Status{},
+}
+
+var repoWriteBackFlushCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Wait for the write-back journal to be flushed.",
+		ShortDescription: `
+'ipfs repo writeback flush' waits for the blocks journaled so far to be
+flushed to the datastore, for example before a backup of the datastore.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if nd.WriteBack == nil {
+			return errWriteBackDisabled
+		}
+		if err := nd.WriteBack.Flush(req.Context); err != nil {
+			return err
+		}
+		s := nd.WriteBack.Status()
+		return cmds.EmitOnce(res, &s)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: writeBackTextEncoder,
+	},
+	Type: writebackbs.Status{},
+}
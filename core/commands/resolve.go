@@ -127,7 +127,7 @@ Resolve the value of an IPFS DAG path:
 			}
 		}
 
-		p, err := cmdutils.PathOrCidPath(name)
+		p, err := cmdutils.PathOrAlias(req.Context, api, name)
 		if err != nil {
 			return err
 		}
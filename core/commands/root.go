@@ -27,6 +27,7 @@ const (
 	DebugOption      = "debug"
 	LocalOption      = "local" // DEPRECATED: use OfflineOption
 	OfflineOption    = "offline"
+	ContextOption    = "context"
 	ApiOption        = "api"      //nolint
 	ApiAuthOption    = "api-auth" //nolint
 )
@@ -43,6 +44,8 @@ BASIC COMMANDS
   get <ref>     Download IPFS objects
   ls <ref>      List links from an object
   refs <ref>    List hashes of links from an object
+  search <q>    Search the local content (experimental)
+  preview <ref> Show the preview of a file (experimental)
 
 DATA STRUCTURE COMMANDS
   dag           Interact with IPLD DAG nodes
@@ -59,12 +62,16 @@ ADVANCED COMMANDS
   resolve       Resolve any type of content path
   name          Publish and resolve IPNS names
   key           Create and list IPNS name keypairs
+  alias         Name CIDs and IPNS names locally (experimental)
   pin           Pin objects to local storage
   repo          Manipulate the IPFS repository
+  cache         Manage the content cached in the blockstore (experimental)
   stats         Various operational stats
   p2p           Libp2p stream mounting (experimental)
   filestore     Manage the filestore (experimental)
   mount         Mount an IPFS read-only mount point (experimental)
+  readonly      Manage the read-only mode of the daemon (experimental)
+  standby       Warm standby pairs of nodes (experimental)
 
 NETWORK COMMANDS
   id            Show info about IPFS peers
@@ -75,6 +82,8 @@ NETWORK COMMANDS
   ping          Measure the latency of a connection
   bitswap       Inspect bitswap state
   pubsub        Send and receive messages via pubsub
+  admin         Administer remote nodes over libp2p
+  kv            Key-value stores replicated over pubsub (experimental)
 
 TOOL COMMANDS
   config        Manage configuration
@@ -82,6 +91,7 @@ TOOL COMMANDS
   diag          Generate diagnostic reports
   update        Download and apply go-ipfs updates
   commands      List all available commands
+  spec          Describe the RPC API as OpenAPI
   log           Manage and show logs of running daemon
 
 Use 'ipfs <command> --help' to learn more about each command.
@@ -109,6 +119,7 @@ The CLI will exit with one of the following values:
 		cmds.BoolOption(cmds.OptShortHelp, "Show a short version of the command help text."),
 		cmds.BoolOption(LocalOption, "L", "Run the command locally, instead of using the daemon. DEPRECATED: use --offline."),
 		cmds.BoolOption(OfflineOption, "Run the command offline."),
+		cmds.StringOption(ContextOption, "Run the command against the repo context of this name, see the Repos config key. Experimental."),
 		cmds.StringOption(ApiOption, "Use a specific API instance (defaults to /ip4/127.0.0.1/tcp/5001)"),
 		cmds.StringOption(ApiAuthOption, "Optional RPC API authorization secret (defined as AuthSecret in API.Authorizations config)"),
 
@@ -124,10 +135,14 @@ The CLI will exit with one of the following values:
 
 var CommandsDaemonCmd = CommandsCmd(Root)
 
+var SpecDaemonCmd = SpecCmd(Root)
+
 var rootSubcommands = map[string]*cmds.Command{
 	"add":       AddCmd,
+	"alias":     AliasCmd,
 	"bitswap":   BitswapCmd,
 	"block":     BlockCmd,
+	"cache":     CacheCmd,
 	"cat":       CatCmd,
 	"commands":  CommandsDaemonCmd,
 	"files":     FilesCmd,
@@ -144,6 +159,7 @@ var rootSubcommands = map[string]*cmds.Command{
 	"diag":      DiagCmd,
 	"id":        IDCmd,
 	"key":       KeyCmd,
+	"kv":        KVCmd,
 	"log":       LogCmd,
 	"ls":        LsCmd,
 	"mount":     MountCmd,
@@ -151,13 +167,19 @@ var rootSubcommands = map[string]*cmds.Command{
 	"object":    ocmd.ObjectCmd,
 	"pin":       pin.PinCmd,
 	"ping":      PingCmd,
+	"preview":   PreviewCmd,
 	"p2p":       P2PCmd,
+	"readonly":  ReadOnlyCmd,
 	"refs":      RefsCmd,
 	"resolve":   ResolveCmd,
+	"search":    SearchCmd,
+	"spec":      SpecDaemonCmd,
+	"standby":   StandbyCmd,
 	"swarm":     SwarmCmd,
 	"update":    ExternalBinary("Please see https://github.com/ipfs/ipfs-update/blob/master/README.md#install for installation instructions."),
 	"version":   VersionCmd,
 	"shutdown":  daemonShutdownCmd,
+	"admin":     AdminCmd,
 	"cid":       CidCmd,
 	"multibase": MbaseCmd,
 }
@@ -210,6 +232,7 @@ var rootROSubcommands = map[string]*cmds.Command{
 
 func init() {
 	Root.ProcessHelp()
+	rejectRepoContext(rootSubcommands)
 	*RootRO = *Root
 
 	// this was in the big map definition above before,
@@ -228,6 +251,13 @@ func init() {
 
 	Root.Subcommands = rootSubcommands
 	RootRO.Subcommands = rootROSubcommands
+
+	*RootRemoteAdmin = *Root
+	*configGetCmd = *ConfigCmd
+	configGetCmd.Arguments = configGetCmd.Arguments[:1]
+	configGetCmd.Options = nil
+	configGetCmd.Subcommands = nil
+	RootRemoteAdmin.Subcommands = rootRemoteAdminSubcommands
 }
 
 type MessageOutput struct {
@@ -42,6 +42,8 @@ var RoutingCmd = &cmds.Command{
 		"get":       getValueRoutingCmd,
 		"put":       putValueRoutingCmd,
 		"provide":   provideRefRoutingCmd,
+		"check":     checkRoutingCmd,
+		"estimate":  estimateRoutingCmd,
 	},
 }
 
@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	iface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/ipfs/kubo/core/coreiface/options"
+)
+
+const (
+	checkFetchOptionName       = "fetch"
+	checkConcurrencyOptionName = "concurrency"
+	checkTimeoutOptionName     = "step-timeout"
+)
+
+var checkRoutingCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Check whether CIDs can be retrieved from the network.",
+		ShortDescription: `
+'ipfs routing check' looks for the providers of each CID, in parallel, and
+with --fetch fetches its block from the network, to tell whether it can be
+retrieved by other peers. The local node does not count as a provider.
+
+The verdict of a CID is one of:
+
+  retrievable   its block was fetched from the network (--fetch)
+  provided      providers were found, its block was not fetched
+  unreachable   providers were found, but its block could not be fetched
+  unavailable   no providers were found
+
+The --step-timeout bounds the search for the providers of a CID, and the
+fetch of its block.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("cid", true, true, "The CIDs to check.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption(numProvidersOptionName, "n", "The number of providers to look for each CID.").WithDefault(20),
+		cmds.BoolOption(checkFetchOptionName, "Fetch the block of each CID from the network."),
+		cmds.IntOption(checkConcurrencyOptionName, "The number of CIDs checked in parallel.").WithDefault(8),
+		cmds.StringOption(checkTimeoutOptionName, "How long the search for the providers of a CID, and the fetch of its block, may each take.").WithDefault("30s"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		cids := make([]cid.Cid, len(req.Arguments))
+		for i, arg := range req.Arguments {
+			cids[i], err = cid.Decode(arg)
+			if err != nil {
+				return err
+			}
+		}
+
+		timeout, err := time.ParseDuration(req.Options[checkTimeoutOptionName].(string))
+		if err != nil {
+			return fmt.Errorf("invalid --%s: %w", checkTimeoutOptionName, err)
+		}
+		numProviders, _ := req.Options[numProvidersOptionName].(int)
+		fetch, _ := req.Options[checkFetchOptionName].(bool)
+		concurrency, _ := req.Options[checkConcurrencyOptionName].(int)
+
+		results, err := api.Routing().CheckAvailability(req.Context, cids,
+			options.Routing.CountProviders(numProviders),
+			options.Routing.Fetch(fetch),
+			options.Routing.Concurrency(concurrency),
+			options.Routing.Timeout(timeout),
+		)
+		if err != nil {
+			return err
+		}
+		for i := range results {
+			if err := res.Emit(&results[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *iface.Availability) error {
+			fmt.Fprintf(w, "%s\t%s\t%d providers", out.Cid, out.Verdict, out.Providers)
+			if out.Error != "" {
+				fmt.Fprintf(w, "\t%s", out.Error)
+			}
+			fmt.Fprintln(w)
+			return nil
+		}),
+	},
+	Type: iface.Availability{},
+}
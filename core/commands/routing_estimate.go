@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/ipfs/boxo/path"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	iface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/ipfs/kubo/core/coreiface/options"
+)
+
+const (
+	estimateSampleBlocksOptionName = "sample-blocks"
+	estimateTimeoutOptionName      = "step-timeout"
+)
+
+var estimateRoutingCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Estimate how long fetching a DAG from the network takes.",
+		ShortDescription: `
+'ipfs routing estimate' estimates how long fetching the DAG of a path from the
+network takes, without fetching it, to choose between fetching it through the
+local node and through a gateway.
+
+The providers of the DAG are pinged, and its root and a sample of its blocks
+are fetched from the network, without storing them, to measure the bandwidth
+of the providers. The estimate is a round trip per level of the DAG, and the
+transfer of its size at the bandwidth of the sample. The local node does not
+count as a provider.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "The path of the DAG to estimate."),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption(numProvidersOptionName, "n", "The number of providers to probe.").WithDefault(5),
+		cmds.IntOption(estimateSampleBlocksOptionName, "The number of children of the root fetched to sample the bandwidth.").WithDefault(8),
+		cmds.StringOption(estimateTimeoutOptionName, "How long the search for the providers, their pings, and the sample may each take.").WithDefault("30s"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		p, err := path.NewPath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		timeout, err := time.ParseDuration(req.Options[estimateTimeoutOptionName].(string))
+		if err != nil {
+			return fmt.Errorf("invalid --%s: %w", estimateTimeoutOptionName, err)
+		}
+		numProviders, _ := req.Options[numProvidersOptionName].(int)
+		sampleBlocks, _ := req.Options[estimateSampleBlocksOptionName].(int)
+
+		est, err := api.Routing().EstimateRetrieval(req.Context, p,
+			options.Routing.ProbeProviders(numProviders),
+			options.Routing.SampleBlocks(sampleBlocks),
+			options.Routing.ProbeTimeout(timeout),
+		)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &est)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *iface.RetrievalEstimate) error {
+			if len(out.Providers) == 0 {
+				fmt.Fprintf(w, "%s: no providers found\n", out.Cid)
+				return nil
+			}
+			fmt.Fprintf(w, "%s: %s, depth %d, estimated %s\n", out.Cid, humanize.Bytes(out.Size), out.Depth, out.Duration.Round(time.Millisecond))
+			fmt.Fprintf(w, "sampled %s in %s, %s/s\n", humanize.Bytes(out.SampleBytes), out.SampleDuration.Round(time.Millisecond), humanize.Bytes(uint64(out.Bandwidth)))
+
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			defer tw.Flush()
+			fmt.Fprintln(tw, "Provider\tLatency\tReceived\tBandwidth")
+			for _, p := range out.Providers {
+				latency := "-"
+				if p.Latency > 0 {
+					latency = p.Latency.Round(time.Millisecond).String()
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s/s", p.ID, latency, humanize.Bytes(p.Received), humanize.Bytes(uint64(p.Bandwidth)))
+				if p.Error != "" {
+					fmt.Fprintf(tw, "\t%s", p.Error)
+				}
+				fmt.Fprintln(tw)
+			}
+			return nil
+		}),
+	},
+	Type: iface.RetrievalEstimate{},
+}
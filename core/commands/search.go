@@ -0,0 +1,241 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	options "github.com/ipfs/kubo/core/coreiface/options"
+)
+
+const searchLimitOptionName = "limit"
+
+// The default limits of 'ipfs search' and 'ipfs search text', which share
+// the limit option.
+const (
+	searchDefaultLimit     = 100
+	searchTextDefaultLimit = 20
+)
+
+type SearchResult struct {
+	Source   string
+	Path     string
+	Cid      string
+	Root     string `json:",omitempty"`
+	Type     string
+	Size     uint64
+	MimeType string `json:",omitempty"`
+	Added    time.Time
+	ModTime  *time.Time `json:",omitempty"`
+}
+
+var SearchCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Search the local content.",
+		ShortDescription: `
+Searches the index of the files and directories added to this node, and of
+the ones in MFS ('ipfs files'). The index is kept up to date when
+Experimental.ContentIndex is enabled. Results are listed most recently added
+first.
+`,
+		LongDescription: `
+Searches the index of the files and directories added to this node, and of
+the ones in MFS ('ipfs files'). The index is kept up to date when
+Experimental.ContentIndex is enabled. Results are listed most recently added
+first.
+
+A query is made of terms that must all match. Double quotes group words
+containing spaces into one term:
+
+  beach, name:*.jpg    the name contains a word, or matches a glob
+  path:photos/2024     the path contains a string, or matches a glob
+  type:image           the MIME type, or its type only, e.g. type:text/html
+  is:file, is:dir      files or directories only
+  size>10MB            the size, compared with >, >=, <, <= or :
+  added>30d            added in the last 30 days (h, d, w, mo and y)
+  added:2024-09        added in September 2024 (also a day or a year)
+  modified<2024-01-01  the modification time on the local filesystem
+  cid:bafy...          the CID of the entry, or the CID it was added under
+  source:add           added content (add), or MFS content (mfs)
+
+Names, paths and types are case insensitive. MIME types are sniffed from the
+content of the files. Modification times are only known for the files added
+from the local filesystem.
+
+Example:
+
+  > ipfs search 'type:image added:2024-09'
+  > ipfs search '"tax return" is:file added>1y'
+
+A query starting with the word "text" must be quoted, as 'ipfs search text'
+searches the content of the documents instead.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("query", true, true, "The search terms."),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption(searchLimitOptionName, "n", "Maximum number of results, 0 for all of them. Default: 100, or 20 for 'ipfs search text'."),
+	},
+	Subcommands: map[string]*cmds.Command{
+		"text": searchTextCmd,
+	},
+	Type: SearchResult{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		limit, ok := req.Options[searchLimitOptionName].(int)
+		if !ok {
+			limit = searchDefaultLimit
+		}
+		if limit < 0 {
+			return fmt.Errorf("limit can't be negative")
+		}
+		results, err := api.Unixfs().Search(req.Context, strings.Join(req.Arguments, " "), options.Unixfs.Limit(limit))
+		if err != nil {
+			return err
+		}
+
+		for _, r := range results {
+			out := &SearchResult{
+				Source:   r.Source,
+				Path:     r.Path,
+				Cid:      enc.Encode(r.Cid),
+				Type:     r.Type.String(),
+				Size:     r.Size,
+				MimeType: r.MimeType,
+				Added:    r.Added,
+			}
+			if r.Root.Defined() {
+				out.Root = enc.Encode(r.Root)
+			}
+			if !r.ModTime.IsZero() {
+				modTime := r.ModTime
+				out.ModTime = &modTime
+			}
+			if err := res.Emit(out); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *SearchResult) error {
+			size := humanize.Bytes(out.Size)
+			if out.Type == coreiface.TDirectory.String() {
+				size = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", out.Added.Format(time.DateOnly), size, out.Cid, cmdenv.EscNonPrint(out.Path), out.MimeType)
+			return nil
+		}),
+	},
+}
+
+type SearchHit struct {
+	Source  string
+	Path    string
+	Cid     string
+	Root    string `json:",omitempty"`
+	Title   string `json:",omitempty"`
+	Format  string
+	Score   float64
+	Snippet string
+}
+
+var searchTextCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Search the text of the documents in MFS and in the pinned DAGs.",
+		ShortDescription: `
+Searches the full-text index of the text, markdown and HTML documents in MFS
+('ipfs files') and in the recursively pinned DAGs. The index is kept up to
+date when Experimental.FullTextSearch is enabled. Results are listed best
+match first.
+`,
+		LongDescription: `
+Searches the full-text index of the text, markdown and HTML documents in MFS
+('ipfs files') and in the recursively pinned DAGs. The index is kept up to
+date when Experimental.FullTextSearch is enabled. Results are listed best
+match first.
+
+Documents match when they contain all the words of the query. Words are case
+insensitive, and:
+
+  "apple pie"    matches a phrase
+  -worm          excludes the documents containing a word, or a phrase
+  appl*          matches any word starting with a prefix
+
+Only the blocks stored locally are indexed. Documents larger than 4MiB are
+not indexed.
+
+Example:
+
+  > ipfs search text 'apple "pie crust" -cinnamon'
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("query", true, true, "The search terms."),
+	},
+	Type: SearchHit{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		limit, ok := req.Options[searchLimitOptionName].(int)
+		if !ok {
+			limit = searchTextDefaultLimit
+		}
+		if limit < 0 {
+			return fmt.Errorf("limit can't be negative")
+		}
+		hits, err := api.Search().Query(req.Context, strings.Join(req.Arguments, " "), options.Search.Limit(limit))
+		if err != nil {
+			return err
+		}
+
+		for _, h := range hits {
+			out := &SearchHit{
+				Source:  h.Source,
+				Path:    h.Path,
+				Cid:     enc.Encode(h.Cid),
+				Title:   h.Title,
+				Format:  h.Format,
+				Score:   h.Score,
+				Snippet: h.Snippet,
+			}
+			if h.Root.Defined() {
+				out.Root = enc.Encode(h.Root)
+			}
+			if err := res.Emit(out); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *SearchHit) error {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", out.Cid, cmdenv.EscNonPrint(out.Path), cmdenv.EscNonPrint(out.Title))
+			fmt.Fprintf(w, "\t%s\n", cmdenv.EscNonPrint(out.Snippet))
+			return nil
+		}),
+	},
+}
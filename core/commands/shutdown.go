@@ -1,13 +1,31 @@
 package commands
 
 import (
+	"context"
+	"time"
+
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core"
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
 )
 
+const (
+	shutdownDrainOptionName        = "drain"
+	shutdownDrainTimeoutOptionName = "drain-timeout"
+)
+
 var daemonShutdownCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Shut down the IPFS daemon.",
+		ShortDescription: `
+Stops accepting new API and gateway requests, lets the in-flight ones
+finish for up to --drain-timeout, flushes MFS and pins, and stops the daemon.
+Use --drain=false to cancel in-flight requests right away.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(shutdownDrainOptionName, "Wait for in-flight requests to finish.").WithDefault(true),
+		cmds.StringOption(shutdownDrainTimeoutOptionName, "How long to wait for in-flight requests. Defaults to Internal.ShutdownTimeout, or "+core.DefaultShutdownTimeout.String()+"."),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		nd, err := cmdenv.GetNode(env)
@@ -19,10 +37,23 @@ var daemonShutdownCmd = &cmds.Command{
 			return cmds.Errorf(cmds.ErrClient, "daemon not running")
 		}
 
-		if err := nd.Close(); err != nil {
-			log.Error("error while shutting down ipfs daemon:", err)
+		drain, _ := req.Options[shutdownDrainOptionName].(bool)
+		var timeout time.Duration
+		if s, ok := req.Options[shutdownDrainTimeoutOptionName].(string); ok {
+			timeout, err = time.ParseDuration(s)
+			if err != nil {
+				return cmds.Errorf(cmds.ErrClient, "invalid timeout: %s", err)
+			}
 		}
 
+		// Shut down in the background: draining waits for this request too.
+		go func() {
+			err := nd.Shutdown(context.Background(), core.ShutdownOptions{Drain: drain, Timeout: timeout})
+			if err != nil {
+				log.Error("error while shutting down ipfs daemon:", err)
+			}
+		}()
+
 		return nil
 	},
 }
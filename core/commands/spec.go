@@ -0,0 +1,384 @@
+package commands
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	gopath "path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	version "github.com/ipfs/kubo"
+)
+
+// OpenAPI is the OpenAPI 3.0 description of the RPC API.
+type OpenAPI struct {
+	OpenAPI    string                 `json:"openapi"`
+	Info       OpenAPIInfo            `json:"info"`
+	Servers    []OpenAPIServer        `json:"servers"`
+	Paths      map[string]OpenAPIPath `json:"paths"`
+	Components OpenAPIComponents      `json:"components"`
+
+	// the named structs described in the components, and their names
+	names map[reflect.Type]string
+	taken map[string]bool
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// OpenAPIPath is a command, called with POST.
+type OpenAPIPath struct {
+	Post OpenAPIOperation `json:"post"`
+}
+
+type OpenAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Parameters  []*OpenAPIParam            `json:"parameters,omitempty"`
+	RequestBody *OpenAPIBody               `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParam is an argument or an option of a command, in the query
+// string. Ref refers to the global options, in the components.
+type OpenAPIParam struct {
+	Ref         string  `json:"$ref,omitempty"`
+	Name        string  `json:"name,omitempty"`
+	In          string  `json:"in,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type OpenAPIBody struct {
+	Required bool                    `json:"required,omitempty"`
+	Content  map[string]OpenAPIMedia `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                  `json:"description"`
+	Content     map[string]OpenAPIMedia `json:"content,omitempty"`
+}
+
+type OpenAPIMedia struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type OpenAPIComponents struct {
+	Schemas    map[string]*Schema       `json:"schemas,omitempty"`
+	Parameters map[string]*OpenAPIParam `json:"parameters,omitempty"`
+}
+
+// Schema is the JSON schema of a value. Ref refers to a schema of the
+// components.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// SpecCmd takes in a root command, and returns a command that describes the
+// commands of that root as OpenAPI.
+func SpecCmd(root *cmds.Command) *cmds.Command {
+	return &cmds.Command{
+		Helptext: cmds.HelpText{
+			Tagline: "Describe the RPC API as OpenAPI.",
+			ShortDescription: `
+Prints the OpenAPI 3.0 description of the commands of the RPC API, with their
+arguments, options and response types, to generate typed clients in other
+languages.
+`,
+			LongDescription: `
+Prints the OpenAPI 3.0 description of the commands of the RPC API, with their
+arguments, options and response types, to generate typed clients in other
+languages. It is generated from the commands of this binary, so it works
+without a repository:
+
+  > ipfs spec > kubo-rpc.json
+
+The daemon serves it at /api/v0/spec as well. The commands streaming their
+response send newline delimited JSON values of the described type.
+`,
+		},
+		Extra: CreateCmdExtras(SetDoesNotUseRepo(true)),
+		Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+			return cmds.EmitOnce(res, NewOpenAPI(root))
+		},
+		Encoders: cmds.EncoderMap{
+			cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *OpenAPI) error {
+				marshaled, err := json.MarshalIndent(out, "", "  ")
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintln(w, string(marshaled))
+				return err
+			}),
+		},
+		Type: OpenAPI{},
+	}
+}
+
+// NewOpenAPI describes the commands of root which can be called over HTTP.
+func NewOpenAPI(root *cmds.Command) *OpenAPI {
+	spec := &OpenAPI{
+		OpenAPI: "3.0.3",
+		Info: OpenAPIInfo{
+			Title:       "Kubo RPC API",
+			Description: "The commands are called with POST. The commands streaming their response send newline delimited JSON values.",
+			Version:     version.CurrentVersionNumber,
+		},
+		Servers: []OpenAPIServer{{URL: "/api/v0"}},
+		Paths:   map[string]OpenAPIPath{},
+		Components: OpenAPIComponents{
+			Schemas:    map[string]*Schema{},
+			Parameters: map[string]*OpenAPIParam{},
+		},
+		names: map[reflect.Type]string{},
+		taken: map[string]bool{},
+	}
+
+	// the options of the root are global, they are in the components
+	var global []*OpenAPIParam
+	for _, opt := range root.Options {
+		spec.Components.Parameters[opt.Name()] = optionParam(opt)
+		global = append(global, &OpenAPIParam{Ref: "#/components/parameters/" + opt.Name()})
+	}
+
+	var walk func(path []string, cmd *cmds.Command)
+	walk = func(path []string, cmd *cmds.Command) {
+		if cmd.Run != nil && !cmd.NoRemote && len(path) > 0 {
+			spec.Paths["/"+strings.Join(path, "/")] = OpenAPIPath{Post: spec.operation(path, cmd, global)}
+		}
+		for name, sub := range cmd.Subcommands {
+			walk(append(path[:len(path):len(path)], name), sub)
+		}
+	}
+	walk(nil, root)
+	return spec
+}
+
+// operation describes the command cmd at path.
+func (spec *OpenAPI) operation(path []string, cmd *cmds.Command, global []*OpenAPIParam) OpenAPIOperation {
+	op := OpenAPIOperation{
+		OperationID: strings.Join(path, "_"),
+		Summary:     cmd.Helptext.Tagline,
+		Description: strings.TrimSpace(cmd.Helptext.ShortDescription),
+		Responses: map[string]OpenAPIResponse{
+			"200": {Description: "The output of the command."},
+			"500": {
+				Description: "The error of the command.",
+				Content: map[string]OpenAPIMedia{"application/json": {Schema: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"Message": {Type: "string"},
+						"Code":    {Type: "integer"},
+						"Type":    {Type: "string"},
+					},
+				}}},
+			},
+		},
+	}
+
+	// the arguments are all sent as arg, in order, except the files
+	var args []string
+	var required, variadic bool
+	for _, arg := range cmd.Arguments {
+		if arg.Type == cmds.ArgFile {
+			op.RequestBody = &OpenAPIBody{
+				Required: arg.Required,
+				Content: map[string]OpenAPIMedia{"multipart/form-data": {Schema: &Schema{
+					Type:       "object",
+					Properties: map[string]*Schema{"file": {Type: "string", Format: "binary", Description: arg.Description}},
+				}}},
+			}
+			continue
+		}
+		args = append(args, fmt.Sprintf("%s: %s", arg.Name, arg.Description))
+		required = required || arg.Required
+		variadic = variadic || arg.Variadic
+	}
+	if len(args) > 0 {
+		schema := &Schema{Type: "string"}
+		if len(args) > 1 || variadic {
+			schema = &Schema{Type: "array", Items: schema}
+		}
+		op.Parameters = append(op.Parameters, &OpenAPIParam{
+			Name:        "arg",
+			In:          "query",
+			Description: strings.Join(args, "\n"),
+			Required:    required,
+			Schema:      schema,
+		})
+	}
+	for _, opt := range cmd.Options {
+		op.Parameters = append(op.Parameters, optionParam(opt))
+	}
+	op.Parameters = append(op.Parameters, global...)
+
+	if cmd.Type != nil {
+		resp := op.Responses["200"]
+		resp.Content = map[string]OpenAPIMedia{"application/json": {Schema: spec.schema(reflect.TypeOf(cmd.Type))}}
+		op.Responses["200"] = resp
+	} else {
+		resp := op.Responses["200"]
+		resp.Content = map[string]OpenAPIMedia{"application/octet-stream": {Schema: &Schema{Type: "string", Format: "binary"}}}
+		op.Responses["200"] = resp
+	}
+	return op
+}
+
+// optionParam describes the option opt, with its long name.
+func optionParam(opt cmds.Option) *OpenAPIParam {
+	var schema *Schema
+	switch opt.Type() {
+	case cmds.Bool:
+		schema = &Schema{Type: "boolean"}
+	case cmds.Int, cmds.Int64:
+		schema = &Schema{Type: "integer", Format: "int64"}
+	case cmds.Uint, cmds.Uint64:
+		schema = &Schema{Type: "integer", Format: "int64"}
+	case cmds.Float:
+		schema = &Schema{Type: "number"}
+	case cmds.Strings:
+		schema = &Schema{Type: "array", Items: &Schema{Type: "string"}}
+	default:
+		schema = &Schema{Type: "string"}
+	}
+	schema.Default = opt.Default()
+	return &OpenAPIParam{
+		Name:        opt.Name(),
+		In:          "query",
+		Description: opt.Description(),
+		Schema:      schema,
+	}
+}
+
+var (
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	cidType       = reflect.TypeOf(cid.Cid{})
+	timeType      = reflect.TypeOf(time.Time{})
+)
+
+// schema describes the JSON encoding of the values of t. The named structs
+// are described once, in the components.
+func (spec *OpenAPI) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == cidType:
+		return &Schema{Type: "object", Properties: map[string]*Schema{"/": {Type: "string"}}}
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Implements(jsonMarshaler) || reflect.PointerTo(t).Implements(jsonMarshaler):
+		// encoded in its own way
+		return &Schema{}
+	case t.Implements(textMarshaler) || reflect.PointerTo(t).Implements(textMarshaler):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: spec.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: spec.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return spec.structSchema(t)
+		}
+		name, ok := spec.names[t]
+		if !ok {
+			// named before being described, for the recursive types
+			name = spec.schemaName(t)
+			spec.names[t] = name
+			spec.Components.Schemas[name] = spec.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+// structSchema describes the fields of the struct t, as encoding/json does.
+func (spec *OpenAPI) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			// the fields of the embedded structs are promoted
+			for k, v := range spec.structSchema(ft).Properties {
+				if _, ok := s.Properties[k]; !ok {
+					s.Properties[k] = v
+				}
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = spec.schema(f.Type)
+	}
+	return s
+}
+
+// schemaName names the schema of t in the components, with its package.
+func (spec *OpenAPI) schemaName(t reflect.Type) string {
+	name := gopath.Base(t.PkgPath()) + "." + t.Name()
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	// the same name in different packages
+	unique := name
+	for i := 2; spec.taken[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	spec.taken[unique] = true
+	return unique
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPI(t *testing.T) {
+	spec := NewOpenAPI(Root)
+
+	add, ok := spec.Paths["/add"]
+	if !ok {
+		t.Fatal("expected /add to be described")
+	}
+	if add.Post.RequestBody == nil {
+		t.Error("expected /add to take files")
+	}
+	if add.Post.Responses["200"].Content["application/json"].Schema == nil {
+		t.Error("expected the output of /add to be described")
+	}
+	if _, ok := spec.Paths["/pin/remote/service/ls"]; !ok {
+		t.Error("expected the subcommands to be described")
+	}
+	if _, ok := spec.Paths["/commands/completion/bash"]; ok {
+		t.Error("expected the local only commands not to be described")
+	}
+	if _, ok := spec.Components.Parameters[OfflineOption]; !ok {
+		t.Error("expected the global options to be described")
+	}
+
+	// the references point to described schemas
+	b, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var refs []string
+	var collect func(v interface{})
+	collect = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				refs = append(refs, ref)
+			}
+			for _, e := range v {
+				collect(e)
+			}
+		case []interface{}:
+			for _, e := range v {
+				collect(e)
+			}
+		}
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	collect(decoded)
+	for _, ref := range refs {
+		var found bool
+		if name, ok := strings.CutPrefix(ref, "#/components/schemas/"); ok {
+			_, found = spec.Components.Schemas[name]
+		} else if name, ok := strings.CutPrefix(ref, "#/components/parameters/"); ok {
+			_, found = spec.Components.Parameters[name]
+		}
+		if !found {
+			t.Errorf("dangling reference %s", ref)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	options "github.com/ipfs/kubo/core/coreiface/options"
+)
+
+// StandbyStatusOutput is the status of a node of a warm standby pair.
+type StandbyStatusOutput struct {
+	Primary   string   `json:",omitempty"`
+	Standbys  []string `json:",omitempty"`
+	Promoted  bool
+	LastSync  time.Time `json:",omitempty"`
+	LastError string    `json:",omitempty"`
+	Pins      int
+	Files     string `json:",omitempty"`
+	Keys      int
+}
+
+const (
+	standbyForceOptionName = "force"
+)
+
+var StandbyCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Warm standby pairs of nodes.",
+		ShortDescription: `
+'ipfs standby' manages warm standby pairs. A standby node, with the peer ID of
+its primary in Standby.Primary, mirrors the pinset, the MFS root and the keys
+of the primary every Standby.Interval, fetching the blocks it misses. The
+primary only hands its state over to the nodes of its Standby.Standbys, over
+the encrypted libp2p connection of the nodes.
+
+When the primary goes away, 'ipfs standby promote' turns the standby into a
+node of its own: it stops mirroring the primary, and the mirrored MFS root
+becomes its own.
+
+This feature is experimental.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"status":  standbyStatusCmd,
+		"sync":    standbySyncCmd,
+		"promote": standbyPromoteCmd,
+	},
+}
+
+var standbyStatusCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Show the status of the standby pair.",
+		ShortDescription: `
+'ipfs standby status' shows the primary mirrored by the node, or the standbys
+allowed to mirror it, and what was last mirrored.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		st, err := api.Standby().Status(req.Context)
+		if err != nil {
+			return err
+		}
+
+		out := &StandbyStatusOutput{
+			Promoted:  st.Promoted,
+			LastSync:  st.LastSync,
+			LastError: st.LastError,
+			Pins:      st.Pins,
+			Keys:      st.Keys,
+		}
+		if st.Primary != "" {
+			out.Primary = st.Primary.String()
+		}
+		for _, p := range st.Standbys {
+			out.Standbys = append(out.Standbys, p.String())
+		}
+		if st.Files.Defined() {
+			out.Files = st.Files.String()
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: StandbyStatusOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *StandbyStatusOutput) error {
+			for _, s := range out.Standbys {
+				fmt.Fprintf(w, "standby: %s\n", s)
+			}
+			if out.Primary == "" {
+				return nil
+			}
+			fmt.Fprintf(w, "primary: %s\n", out.Primary)
+			if out.Promoted {
+				fmt.Fprintln(w, "promoted: true")
+			}
+			if out.LastSync.IsZero() {
+				fmt.Fprintln(w, "last sync: never")
+			} else {
+				fmt.Fprintf(w, "last sync: %s\n", out.LastSync.Format(time.RFC3339))
+			}
+			if out.LastError != "" {
+				fmt.Fprintf(w, "last error: %s\n", out.LastError)
+			}
+			_, err := fmt.Fprintf(w, "pins: %d\nfiles: %s\nkeys: %d\n", out.Pins, out.Files, out.Keys)
+			return err
+		}),
+	},
+}
+
+var standbySyncCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Mirror the primary now.",
+		ShortDescription: `
+'ipfs standby sync' mirrors the primary without waiting for
+Standby.Interval, and returns once the blocks of its pins and of its MFS root
+are stored locally.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		return api.Standby().Sync(req.Context)
+	},
+}
+
+var standbyPromoteCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Promote the standby to replace its primary.",
+		ShortDescription: `
+'ipfs standby promote' mirrors the primary one last time, then stops
+mirroring it, and makes the mirrored MFS root the MFS root of the node. The
+mirrored pins and keys are kept.
+
+When the primary can't be reached, the node is only promoted with --force,
+with the state it mirrored last. The promotion is kept across restarts:
+remove Standby.Primary from the config once the node replaces the primary.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(standbyForceOptionName, "f", "Promote the node even when the primary can't be mirrored one last time."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		force, _ := req.Options[standbyForceOptionName].(bool)
+		return api.Standby().Promote(req.Context, options.Standby.Force(force))
+	},
+}
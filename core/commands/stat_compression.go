@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+type CompressionStatOutput struct {
+	Enabled          bool
+	BlocksWritten    uint64
+	BlocksCompressed uint64
+	BytesIn          uint64
+	BytesStored      uint64
+	BytesSaved       int64
+}
+
+var statCompressionCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Returns statistics about block compression.",
+		ShortDescription: `
+Returns how many blocks were written since the node started, how many of them
+were stored compressed and how much disk space this saved.
+
+Compression is enabled with the Datastore.Compression.Enabled config option.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if nd.BlockCompression == nil {
+			return cmds.EmitOnce(res, &CompressionStatOutput{})
+		}
+
+		s := nd.BlockCompression.Stats()
+		return cmds.EmitOnce(res, &CompressionStatOutput{
+			Enabled:          s.Enabled,
+			BlocksWritten:    s.BlocksWritten,
+			BlocksCompressed: s.BlocksCompressed,
+			BytesIn:          s.BytesIn,
+			BytesStored:      s.BytesStored,
+			BytesSaved:       s.Saved(),
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *CompressionStatOutput) error {
+			if !s.Enabled {
+				fmt.Fprintln(w, "Block compression is disabled.")
+				return nil
+			}
+
+			wtr := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			defer wtr.Flush()
+
+			fmt.Fprintf(wtr, "BlocksWritten:\t%s\n", humanNumber(s.BlocksWritten))
+			fmt.Fprintf(wtr, "BlocksCompressed:\t%s\n", humanNumber(s.BlocksCompressed))
+			fmt.Fprintf(wtr, "BytesIn:\t%s\n", humanize.Bytes(s.BytesIn))
+			fmt.Fprintf(wtr, "BytesStored:\t%s\n", humanize.Bytes(s.BytesStored))
+			if s.BytesSaved >= 0 {
+				fmt.Fprintf(wtr, "BytesSaved:\t%s\n", humanize.Bytes(uint64(s.BytesSaved)))
+			} else {
+				fmt.Fprintf(wtr, "BytesSaved:\t-%s\n", humanize.Bytes(uint64(-s.BytesSaved)))
+			}
+			return nil
+		}),
+	},
+	Type: CompressionStatOutput{},
+}
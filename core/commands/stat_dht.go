@@ -9,11 +9,6 @@ import (
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
-	dht "github.com/libp2p/go-libp2p-kad-dht"
-	"github.com/libp2p/go-libp2p-kad-dht/fullrt"
-	kbucket "github.com/libp2p/go-libp2p-kbucket"
-	"github.com/libp2p/go-libp2p/core/network"
-	pstore "github.com/libp2p/go-libp2p/core/peerstore"
 )
 
 type dhtPeerInfo struct {
@@ -48,6 +43,9 @@ This interface is not stable and may change from release to release.
 			"wan and lan refer to client routing tables. When using the experimental DHT client only WAN is supported. Defaults to wan and lan."),
 	},
 	Options: []cmds.Option{},
+	Subcommands: map[string]*cmds.Command{
+		"health": statDhtHealthCmd,
+	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		nd, err := cmdenv.GetNode(env)
 		if err != nil {
@@ -62,124 +60,11 @@ This interface is not stable and may change from release to release.
 			return ErrNotDHT
 		}
 
-		id := kbucket.ConvertPeerID(nd.Identity)
-
 		dhts := req.Arguments
 		if len(dhts) == 0 {
 			dhts = []string{"wan", "lan"}
 		}
-
-	dhttypeloop:
-		for _, name := range dhts {
-			var dht *dht.IpfsDHT
-
-			var separateClient bool
-			if nd.DHTClient != nd.DHT {
-				separateClient = true
-			}
-
-			switch name {
-			case "wan":
-				if separateClient {
-					client, ok := nd.DHTClient.(*fullrt.FullRT)
-					if !ok {
-						return cmds.Errorf(cmds.ErrClient, "could not generate stats for the WAN DHT client type")
-					}
-					peerMap := client.Stat()
-					buckets := make([]dhtBucket, 1)
-					b := &dhtBucket{}
-					for _, p := range peerMap {
-						info := dhtPeerInfo{ID: p.String()}
-
-						if ver, err := nd.Peerstore.Get(p, "AgentVersion"); err == nil {
-							info.AgentVersion, _ = ver.(string)
-						} else if err == pstore.ErrNotFound {
-							// ignore
-						} else {
-							// this is a bug, usually.
-							log.Errorw(
-								"failed to get agent version from peerstore",
-								"error", err,
-							)
-						}
-
-						info.Connected = nd.PeerHost.Network().Connectedness(p) == network.Connected
-						b.Peers = append(b.Peers, info)
-					}
-					buckets[0] = *b
-
-					if err := res.Emit(dhtStat{
-						Name:    name,
-						Buckets: buckets,
-					}); err != nil {
-						return err
-					}
-					continue dhttypeloop
-				}
-				fallthrough
-			case "wanserver":
-				dht = nd.DHT.WAN
-			case "lan":
-				if separateClient {
-					return cmds.Errorf(cmds.ErrClient, "no LAN client found")
-				}
-				fallthrough
-			case "lanserver":
-				dht = nd.DHT.LAN
-			default:
-				return cmds.Errorf(cmds.ErrClient, "unknown dht type: %s", name)
-			}
-
-			rt := dht.RoutingTable()
-			lastRefresh := rt.GetTrackedCplsForRefresh()
-			infos := rt.GetPeerInfos()
-			buckets := make([]dhtBucket, 0, len(lastRefresh))
-			for _, pi := range infos {
-				cpl := kbucket.CommonPrefixLen(id, kbucket.ConvertPeerID(pi.Id))
-				if len(buckets) <= cpl {
-					buckets = append(buckets, make([]dhtBucket, 1+cpl-len(buckets))...)
-				}
-
-				info := dhtPeerInfo{ID: pi.Id.String()}
-
-				if ver, err := nd.Peerstore.Get(pi.Id, "AgentVersion"); err == nil {
-					info.AgentVersion, _ = ver.(string)
-				} else if err == pstore.ErrNotFound {
-					// ignore
-				} else {
-					// this is a bug, usually.
-					log.Errorw(
-						"failed to get agent version from peerstore",
-						"error", err,
-					)
-				}
-				if !pi.LastUsefulAt.IsZero() {
-					info.LastUsefulAt = pi.LastUsefulAt.Format(time.RFC3339)
-				}
-
-				if !pi.LastSuccessfulOutboundQueryAt.IsZero() {
-					info.LastQueriedAt = pi.LastSuccessfulOutboundQueryAt.Format(time.RFC3339)
-				}
-
-				info.Connected = nd.PeerHost.Network().Connectedness(pi.Id) == network.Connected
-
-				buckets[cpl].Peers = append(buckets[cpl].Peers, info)
-			}
-			for i := 0; i < len(buckets) && i < len(lastRefresh); i++ {
-				refreshTime := lastRefresh[i]
-				if !refreshTime.IsZero() {
-					buckets[i].LastRefresh = refreshTime.Format(time.RFC3339)
-				}
-			}
-			if err := res.Emit(dhtStat{
-				Name:    name,
-				Buckets: buckets,
-			}); err != nil {
-				return err
-			}
-		}
-
-		return nil
+		return emitDhtStats(nd, dhts, res)
 	},
 	Encoders: cmds.EncoderMap{
 		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out dhtStat) error {
@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/dhthealth"
+)
+
+// DHTHealthOutput is the health of the routing tables of the DHT and of the
+// routing queries.
+type DHTHealthOutput struct {
+	Since   time.Time
+	Tables  []dhthealth.Table
+	Queries map[string]DHTQueriesOutput
+}
+
+// DHTQueriesOutput are the outcomes of the routing queries of a kind, with
+// their success rates.
+type DHTQueriesOutput struct {
+	dhthealth.Queries
+	SuccessRate     float64
+	PeerSuccessRate float64
+}
+
+var statDhtHealthCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Report the health of the DHT routing tables and queries.",
+		ShortDescription: `
+'ipfs stats dht health' reports, for each routing table of the DHT (wan and
+lan, the server tables when Routing.AcceleratedDHTClient is enabled):
+
+  - the occupancy of its buckets, out of 20 peers,
+  - the distribution of the time the peers have been in the table,
+  - the peers that entered and left the table during the last hour, sampled
+    every 30 seconds, and the fraction of the table replaced per hour.
+
+It also reports the outcomes of the routing queries since the daemon started:
+the queries that returned results, that completed without results, and that
+failed, and how many times the DHT peers queried answered or failed to.
+
+Empty buckets, a high churn or peers failing to answer point to a problem with
+the routing tables, while queries answered by the peers without results point
+to content that is not provided.
+
+This interface is not stable and may change from release to release.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+		if nd.DHT == nil || nd.DHTHealth == nil {
+			return ErrNotDHT
+		}
+
+		r := nd.DHTHealth.Report()
+		out := &DHTHealthOutput{
+			Since:   r.Since,
+			Tables:  r.Tables,
+			Queries: make(map[string]DHTQueriesOutput, len(r.Queries)),
+		}
+		for kind, q := range r.Queries {
+			out.Queries[kind] = DHTQueriesOutput{
+				Queries:         q,
+				SuccessRate:     q.SuccessRate(),
+				PeerSuccessRate: q.PeerSuccessRate(),
+			}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *DHTHealthOutput) error {
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			defer tw.Flush()
+
+			for _, t := range out.Tables {
+				fmt.Fprintf(tw, "DHT %s: %d peers, median age %s, churn %.2f/h (+%d -%d in the last %s)\n",
+					t.Name, t.Peers, t.MedianAge.Round(time.Second), t.ChurnRate, t.Added, t.Removed, dhthealth.ChurnWindow)
+				fmt.Fprint(tw, "  Bucket\tPeers\tOccupancy")
+				for _, b := range dhthealth.AgeBounds {
+					fmt.Fprintf(tw, "\t<%s", b)
+				}
+				fmt.Fprintf(tw, "\t>=%s\tAdded\tRemoved\n", dhthealth.AgeBounds[len(dhthealth.AgeBounds)-1])
+				for i, b := range t.Buckets {
+					fmt.Fprintf(tw, "  %d\t%d\t%.0f%%", i, b.Peers, b.Occupancy*100)
+					for _, n := range b.Ages {
+						fmt.Fprintf(tw, "\t%d", n)
+					}
+					fmt.Fprintf(tw, "\t%d\t%d\n", b.Added, b.Removed)
+				}
+				fmt.Fprintln(tw)
+			}
+
+			kinds := make([]string, 0, len(out.Queries))
+			for kind := range out.Queries {
+				kinds = append(kinds, kind)
+			}
+			sort.Strings(kinds)
+			fmt.Fprintf(tw, "Queries since %s:\n", out.Since.Format(time.RFC3339))
+			fmt.Fprintln(tw, "  Kind\tSucceeded\tEmpty\tFailed\tSuccess\tPeer success")
+			for _, kind := range kinds {
+				q := out.Queries[kind]
+				fmt.Fprintf(tw, "  %s\t%d\t%d\t%d\t%.0f%%\t%.0f%%\n", kind, q.Succeeded, q.Empty, q.Failed, q.SuccessRate*100, q.PeerSuccessRate*100)
+			}
+			return nil
+		}),
+	},
+	Type: DHTHealthOutput{},
+}
@@ -0,0 +1,15 @@
+//go:build nodht
+// +build nodht
+
+package commands
+
+import (
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/subsystem"
+)
+
+// emitDhtStats fails, as the DHT is compiled out.
+func emitDhtStats(_ *core.IpfsNode, _ []string, _ cmds.ResponseEmitter) error {
+	return subsystem.Check(subsystem.DHT)
+}
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/blocks/remotebs"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+type RemoteStatOutput struct {
+	Enabled bool
+	remotebs.Stats
+}
+
+var statRemoteCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Returns statistics about the remote blockstore.",
+		ShortDescription: `
+Returns how many blocks were read from the repo and from the remote
+blockstores since the node started, how many were found nowhere, and how many
+remote reads failed.
+
+The remote blockstores are set with the Datastore.Remote.Endpoints config
+option.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if nd.RemoteBlocks == nil {
+			return cmds.EmitOnce(res, &RemoteStatOutput{})
+		}
+		return cmds.EmitOnce(res, &RemoteStatOutput{Enabled: true, Stats: nd.RemoteBlocks.Stats()})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *RemoteStatOutput) error {
+			if !s.Enabled {
+				fmt.Fprintln(w, "The remote blockstore is disabled.")
+				return nil
+			}
+
+			wtr := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			defer wtr.Flush()
+
+			fmt.Fprintf(wtr, "Remotes:\t%s\n", strings.Join(s.Remotes, ", "))
+			fmt.Fprintf(wtr, "BlocksLocal:\t%s\n", humanNumber(s.Local))
+			fmt.Fprintf(wtr, "BlocksRemote:\t%s\n", humanNumber(s.Remote))
+			fmt.Fprintf(wtr, "BytesRemote:\t%s\n", humanize.Bytes(s.BytesRemote))
+			fmt.Fprintf(wtr, "NotFound:\t%s\n", humanNumber(s.NotFound))
+			fmt.Fprintf(wtr, "Errors:\t%s\n", humanNumber(s.Errors))
+			return nil
+		}),
+	},
+	Type: RemoteStatOutput{},
+}
@@ -55,11 +55,15 @@ ipfs peers in the internet.
 	},
 	Subcommands: map[string]*cmds.Command{
 		"addrs":      swarmAddrsCmd,
+		"bandwidth":  swarmBandwidthCmd,
 		"connect":    swarmConnectCmd,
 		"disconnect": swarmDisconnectCmd,
+		"events":     swarmEventsCmd,
 		"filters":    swarmFiltersCmd,
 		"peers":      swarmPeersCmd,
 		"peering":    swarmPeeringCmd,
+		"peerstore":  swarmPeerstoreCmd,
+		"policy":     swarmPolicyCmd,
 		"resources":  swarmResourcesCmd, // libp2p Network Resource Manager
 
 	},
@@ -73,6 +77,7 @@ const (
 	swarmResetLimitsOptionName       = "reset"
 	swarmUsedResourcesPercentageName = "min-used-limit-perc"
 	swarmIdentifyOptionName          = "identify"
+	swarmProxiedOptionName           = "proxied"
 )
 
 type peeringResult struct {
@@ -233,6 +238,8 @@ var swarmPeersCmd = &cmds.Command{
 		Tagline: "List peers with open connections.",
 		ShortDescription: `
 'ipfs swarm peers' lists the set of peers this node is connected to.
+
+Connections dialed through Swarm.Proxy are marked as proxied.
 `,
 	},
 	Options: []cmds.Option{
@@ -241,17 +248,23 @@ var swarmPeersCmd = &cmds.Command{
 		cmds.BoolOption(swarmLatencyOptionName, "Also list information about latency to each peer"),
 		cmds.BoolOption(swarmDirectionOptionName, "Also list information about the direction of connection"),
 		cmds.BoolOption(swarmIdentifyOptionName, "Also list information about peers identify"),
+		cmds.BoolOption(swarmProxiedOptionName, "Only list the connections dialed through Swarm.Proxy"),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
 		if err != nil {
 			return err
 		}
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
 		verbose, _ := req.Options[swarmVerboseOptionName].(bool)
 		latency, _ := req.Options[swarmLatencyOptionName].(bool)
 		streams, _ := req.Options[swarmStreamsOptionName].(bool)
 		direction, _ := req.Options[swarmDirectionOptionName].(bool)
 		identify, _ := req.Options[swarmIdentifyOptionName].(bool)
+		proxied, _ := req.Options[swarmProxiedOptionName].(bool)
 
 		conns, err := api.Swarm().Peers(req.Context)
 		if err != nil {
@@ -261,8 +274,12 @@ var swarmPeersCmd = &cmds.Command{
 		var out connInfos
 		for _, c := range conns {
 			ci := connInfo{
-				Addr: c.Address().String(),
-				Peer: c.ID().String(),
+				Addr:    c.Address().String(),
+				Peer:    c.ID().String(),
+				Proxied: n.Proxy.Proxied(c.ID(), c.Address()),
+			}
+			if proxied && !ci.Proxied {
+				continue
 			}
 
 			if verbose || direction {
@@ -294,10 +311,6 @@ var swarmPeersCmd = &cmds.Command{
 			}
 
 			if verbose || identify {
-				n, err := cmdenv.GetNode(env)
-				if err != nil {
-					return err
-				}
 				identifyResult, _ := ci.identifyPeer(n.Peerstore, c.ID())
 				ci.Identify = identifyResult
 			}
@@ -320,6 +333,10 @@ var swarmPeersCmd = &cmds.Command{
 				if info.Direction != inet.DirUnknown {
 					fmt.Fprintf(w, " %s", directionString(info.Direction))
 				}
+
+				if info.Proxied {
+					fmt.Fprint(w, " proxied")
+				}
 				fmt.Fprintln(w)
 
 				for _, s := range info.Streams {
@@ -433,6 +450,7 @@ type connInfo struct {
 	Direction inet.Direction `json:",omitempty"`
 	Streams   []streamInfo   `json:",omitempty"`
 	Identify  IdOutput       `json:",omitempty"`
+	Proxied   bool           `json:",omitempty"`
 }
 
 func (ci *connInfo) Less(i, j int) bool {
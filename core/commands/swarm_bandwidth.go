@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/bwlimit"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+const (
+	swarmBandwidthRateInOptionName      = "rate-in"
+	swarmBandwidthRateOutOptionName     = "rate-out"
+	swarmBandwidthPeerRateInOptionName  = "peer-rate-in"
+	swarmBandwidthPeerRateOutOptionName = "peer-rate-out"
+	swarmBandwidthProtocolOptionName    = "protocol"
+)
+
+var swarmBandwidthCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Show or change the bandwidth caps of the node.",
+		ShortDescription: `
+'ipfs swarm bandwidth' shows the bandwidth caps of the streams of the node,
+in bytes per second: for all the streams, for the streams of each peer, and
+for the streams of each protocol.
+
+The options change the caps until the daemon restarts, set Swarm.Bandwidth
+to keep them. Rates are a number of bytes per second or a size with a unit,
+0 is unlimited. With --protocol, --rate-in and --rate-out change the caps of
+the streams of the protocol.
+
+  # cap the node to 1MB/s in both directions
+  ipfs swarm bandwidth --rate-in=1MB --rate-out=1MB
+  # cap bitswap uploads to 500kB/s
+  ipfs swarm bandwidth --protocol=/ipfs/bitswap/1.2.0 --rate-out=500kB
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(swarmBandwidthRateInOptionName, "Cap of the inbound bandwidth."),
+		cmds.StringOption(swarmBandwidthRateOutOptionName, "Cap of the outbound bandwidth."),
+		cmds.StringOption(swarmBandwidthPeerRateInOptionName, "Cap of the inbound bandwidth of each peer."),
+		cmds.StringOption(swarmBandwidthPeerRateOutOptionName, "Cap of the outbound bandwidth of each peer."),
+		cmds.StringOption(swarmBandwidthProtocolOptionName, "Change the caps of the streams of this protocol."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.BandwidthLimiter == nil {
+			return ErrNotOnline
+		}
+
+		limits := n.BandwidthLimiter.Limits()
+		changed, err := setBandwidthLimits(req.Options, &limits)
+		if err != nil {
+			return cmds.Errorf(cmds.ErrClient, err.Error())
+		}
+		if changed {
+			n.BandwidthLimiter.SetLimits(limits)
+		}
+		return cmds.EmitOnce(res, &limits)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, limits *bwlimit.Limits) error {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "Scope\tIn\tOut")
+			fmt.Fprintf(tw, "total\t%s\t%s\n", formatRate(limits.Total.In), formatRate(limits.Total.Out))
+			fmt.Fprintf(tw, "per peer\t%s\t%s\n", formatRate(limits.Peer.In), formatRate(limits.Peer.Out))
+			pids := make([]string, 0, len(limits.Protocols))
+			for pid := range limits.Protocols {
+				pids = append(pids, string(pid))
+			}
+			sort.Strings(pids)
+			for _, pid := range pids {
+				r := limits.Protocols[protocol.ID(pid)]
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", pid, formatRate(r.In), formatRate(r.Out))
+			}
+			return tw.Flush()
+		}),
+	},
+	Type: bwlimit.Limits{},
+}
+
+// setBandwidthLimits applies the options of 'ipfs swarm bandwidth' to limits,
+// and returns whether they changed.
+func setBandwidthLimits(opts cmds.OptMap, limits *bwlimit.Limits) (bool, error) {
+	var changed bool
+	rate := func(name string, r *uint64) error {
+		s, ok := opts[name].(string)
+		if !ok {
+			return nil
+		}
+		v, err := humanize.ParseBytes(s)
+		if err != nil {
+			return fmt.Errorf("invalid --%s %q: %w", name, s, err)
+		}
+		*r = v
+		changed = true
+		return nil
+	}
+
+	pid, ok := opts[swarmBandwidthProtocolOptionName].(string)
+	if !ok {
+		for name, r := range map[string]*uint64{
+			swarmBandwidthRateInOptionName:      &limits.Total.In,
+			swarmBandwidthRateOutOptionName:     &limits.Total.Out,
+			swarmBandwidthPeerRateInOptionName:  &limits.Peer.In,
+			swarmBandwidthPeerRateOutOptionName: &limits.Peer.Out,
+		} {
+			if err := rate(name, r); err != nil {
+				return false, err
+			}
+		}
+		return changed, nil
+	}
+
+	for _, name := range []string{swarmBandwidthPeerRateInOptionName, swarmBandwidthPeerRateOutOptionName} {
+		if _, ok := opts[name]; ok {
+			return false, fmt.Errorf("--%s can't be used with --%s", name, swarmBandwidthProtocolOptionName)
+		}
+	}
+	r := limits.Protocols[protocol.ID(pid)]
+	if err := rate(swarmBandwidthRateInOptionName, &r.In); err != nil {
+		return false, err
+	}
+	if err := rate(swarmBandwidthRateOutOptionName, &r.Out); err != nil {
+		return false, err
+	}
+	if limits.Protocols == nil {
+		limits.Protocols = make(map[protocol.ID]bwlimit.Rate)
+	}
+	if r.In == 0 && r.Out == 0 {
+		delete(limits.Protocols, protocol.ID(pid))
+	} else {
+		limits.Protocols[protocol.ID(pid)] = r
+	}
+	return changed, nil
+}
+
+func formatRate(r uint64) string {
+	if r == 0 {
+		return "unlimited"
+	}
+	return humanize.Bytes(r) + "/s"
+}
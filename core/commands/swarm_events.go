@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/connevents"
+)
+
+var swarmEventsCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Stream the events of the connections.",
+		ShortDescription: `
+'ipfs swarm events' streams the connections of the node as they are opened,
+closed or refused, until interrupted, with the reason the connections were
+closed or refused:
+
+  local           closed with 'ipfs swarm disconnect'
+  trim            closed by the connection manager, above
+                  Swarm.ConnMgr.HighWater
+  policy          closed by Swarm.PeerPolicies
+  resource-limit  refused by the resource manager
+  remote          closed by the remote peer, or failed
+
+Connections closed by the remote peer while the connection manager trims may
+be reported as trimmed. Events are dropped when the client does not keep up,
+'ipfs swarm events stats' counts them.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"stats": swarmEventsStatsCmd,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.ConnEvents == nil {
+			return ErrNotOnline
+		}
+
+		for e := range n.ConnEvents.Subscribe(req.Context) {
+			e := e
+			if err := res.Emit(&e); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, e *connevents.Event) error {
+			fmt.Fprintf(w, "%s %s %s", e.Time.Format(time.RFC3339), e.Type, e.Direction)
+			if e.Peer != "" {
+				fmt.Fprintf(w, " %s", e.Peer)
+			}
+			if e.Addr != "" {
+				fmt.Fprintf(w, " %s", e.Addr)
+			}
+			if e.Reason != "" {
+				fmt.Fprintf(w, " reason=%s", e.Reason)
+			}
+			if e.Duration > 0 {
+				fmt.Fprintf(w, " open=%s", e.Duration.Round(time.Second))
+			}
+			if e.Error != "" {
+				fmt.Fprintf(w, " error=%q", e.Error)
+			}
+			fmt.Fprintln(w)
+			return nil
+		}),
+	},
+	Type: connevents.Event{},
+}
+
+var swarmEventsStatsCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Count the events of the connections by reason.",
+		ShortDescription: `
+'ipfs swarm events stats' counts the connections opened, closed and refused
+since the daemon started, by reason for the closed and refused connections.
+See 'ipfs swarm events' for the reasons.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.ConnEvents == nil {
+			return ErrNotOnline
+		}
+		stats := n.ConnEvents.Stats()
+		return cmds.EmitOnce(res, &stats)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *connevents.Stats) error {
+			fmt.Fprintf(w, "Since %s\n", s.Since.Format(time.RFC3339))
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			defer tw.Flush()
+			fmt.Fprintln(tw, "Event\tReason\tCount")
+			fmt.Fprintf(tw, "%s\t\t%d\n", connevents.Opened, s.Opened)
+			for _, events := range []struct {
+				typ    connevents.Type
+				counts map[connevents.Reason]uint64
+			}{{connevents.Closed, s.Closed}, {connevents.Refused, s.Refused}} {
+				reasons := make([]string, 0, len(events.counts))
+				for r := range events.counts {
+					reasons = append(reasons, string(r))
+				}
+				sort.Strings(reasons)
+				for _, r := range reasons {
+					fmt.Fprintf(tw, "%s\t%s\t%d\n", events.typ, r, events.counts[connevents.Reason(r)])
+				}
+			}
+			if s.Dropped > 0 {
+				fmt.Fprintf(tw, "dropped\t\t%d\n", s.Dropped)
+			}
+			return nil
+		}),
+	},
+	Type: connevents.Stats{},
+}
@@ -0,0 +1,241 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	swarmPeerstoreProtocolsOptionName = "protocols"
+	swarmPeerstoreAllOptionName       = "all"
+)
+
+var swarmPeerstoreCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect and purge the peerstore.",
+		ShortDescription: `
+'ipfs swarm peerstore' inspects the peerstore, where the node keeps the
+addresses and protocols of the peers it learns about, and removes peers from
+it. Set Swarm.Peerstore.Persist to keep the peerstore across restarts.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":   swarmPeerstoreLsCmd,
+		"stat": swarmPeerstoreStatCmd,
+		"rm":   swarmPeerstoreRmCmd,
+	},
+}
+
+type peerstorePeer struct {
+	ID        string
+	Addrs     []string
+	Protocols []string `json:",omitempty"`
+	Connected bool
+}
+
+type peerstorePeers struct {
+	Peers []peerstorePeer
+}
+
+var swarmPeerstoreLsCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "List the peers of the peerstore.",
+		ShortDescription: `
+'ipfs swarm peerstore ls' lists the peers of the peerstore, with their
+addresses that have not expired, and whether the node is connected to them.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(swarmPeerstoreProtocolsOptionName, "Also list the protocols of the peers."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		withProtocols, _ := req.Options[swarmPeerstoreProtocolsOptionName].(bool)
+
+		peers, err := api.Swarm().KnownPeers(req.Context)
+		if err != nil {
+			return err
+		}
+
+		out := peerstorePeers{Peers: make([]peerstorePeer, len(peers))}
+		for i, p := range peers {
+			pp := peerstorePeer{
+				ID:        p.ID.String(),
+				Addrs:     make([]string, len(p.Addrs)),
+				Connected: p.Connected,
+			}
+			for j, a := range p.Addrs {
+				pp.Addrs[j] = a.String()
+			}
+			if withProtocols {
+				for _, proto := range p.Protocols {
+					pp.Protocols = append(pp.Protocols, string(proto))
+				}
+			}
+			out.Peers[i] = pp
+		}
+		return cmds.EmitOnce(res, &out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *peerstorePeers) error {
+			for _, p := range out.Peers {
+				state := ""
+				if p.Connected {
+					state = ", connected"
+				}
+				fmt.Fprintf(w, "%s (%d%s)\n", p.ID, len(p.Addrs), state)
+				for _, a := range p.Addrs {
+					fmt.Fprintf(w, "\t%s\n", a)
+				}
+				for _, proto := range p.Protocols {
+					fmt.Fprintf(w, "\tprotocol %s\n", proto)
+				}
+			}
+			return nil
+		}),
+	},
+	Type: peerstorePeers{},
+}
+
+type peerstoreStat struct {
+	Persistent bool
+	Peers      int
+	Connected  int
+	// PeersWithoutAddrs are the peers whose addresses all expired.
+	PeersWithoutAddrs int
+	Addrs             int
+	// MaxAddrs is the largest number of addresses of a peer, MaxAddrsPeer.
+	MaxAddrs     int
+	MaxAddrsPeer string `json:",omitempty"`
+}
+
+var swarmPeerstoreStatCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Show the number of peers and addresses of the peerstore.",
+		ShortDescription: `
+'ipfs swarm peerstore stat' shows how many peers and addresses the peerstore
+holds, and the peer with the most addresses, to spot the peers that announce
+many addresses.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return err
+		}
+
+		peers, err := api.Swarm().KnownPeers(req.Context)
+		if err != nil {
+			return err
+		}
+
+		out := peerstoreStat{
+			Persistent: cfg.Swarm.Peerstore.Persist.WithDefault(false),
+			Peers:      len(peers),
+		}
+		for _, p := range peers {
+			if p.Connected {
+				out.Connected++
+			}
+			if len(p.Addrs) == 0 {
+				out.PeersWithoutAddrs++
+			}
+			out.Addrs += len(p.Addrs)
+			if len(p.Addrs) > out.MaxAddrs {
+				out.MaxAddrs = len(p.Addrs)
+				out.MaxAddrsPeer = p.ID.String()
+			}
+		}
+		return cmds.EmitOnce(res, &out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *peerstoreStat) error {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			defer tw.Flush()
+			fmt.Fprintf(tw, "Persistent:\t%t\n", out.Persistent)
+			fmt.Fprintf(tw, "Peers:\t%d\n", out.Peers)
+			fmt.Fprintf(tw, "Connected:\t%d\n", out.Connected)
+			fmt.Fprintf(tw, "Without addresses:\t%d\n", out.PeersWithoutAddrs)
+			fmt.Fprintf(tw, "Addresses:\t%d\n", out.Addrs)
+			if out.MaxAddrsPeer != "" {
+				fmt.Fprintf(tw, "Most addresses:\t%d (%s)\n", out.MaxAddrs, out.MaxAddrsPeer)
+			}
+			return nil
+		}),
+	},
+	Type: peerstoreStat{},
+}
+
+var swarmPeerstoreRmCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Remove peers from the peerstore.",
+		ShortDescription: `
+'ipfs swarm peerstore rm' removes the addresses, protocols and metadata of the
+given peers from the peerstore, or of all the peers with --all. The peers the
+node is connected to are not removed.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ID", false, true, "ID of the peers to remove."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(swarmPeerstoreAllOptionName, "Remove all the peers the node is not connected to."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		all, _ := req.Options[swarmPeerstoreAllOptionName].(bool)
+		if all == (len(req.Arguments) > 0) {
+			return cmds.Errorf(cmds.ErrClient, "pass either peer IDs or --%s", swarmPeerstoreAllOptionName)
+		}
+
+		peers := make([]peer.ID, len(req.Arguments))
+		for i, arg := range req.Arguments {
+			peers[i], err = peer.Decode(arg)
+			if err != nil {
+				return cmds.Errorf(cmds.ErrClient, "invalid peer ID %q: %s", arg, err)
+			}
+		}
+
+		removed, err := api.Swarm().ForgetPeers(req.Context, peers...)
+		if err != nil {
+			return err
+		}
+		out := stringList{Strings: make([]string, len(removed))}
+		for i, p := range removed {
+			out.Strings[i] = p.String()
+		}
+		return cmds.EmitOnce(res, &out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *stringList) error {
+			for _, p := range out.Strings {
+				fmt.Fprintf(w, "removed %s\n", p)
+			}
+			return nil
+		}),
+	},
+	Type: stringList{},
+}
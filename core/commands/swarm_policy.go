@@ -0,0 +1,210 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/commands"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node/libp2p"
+	"github.com/ipfs/kubo/core/peerpolicy"
+	"github.com/ipfs/kubo/repo/fsrepo"
+)
+
+const (
+	swarmPolicyAgentOptionName  = "agent"
+	swarmPolicyPeerOptionName   = "peer"
+	swarmPolicyActionOptionName = "action"
+)
+
+type peerPolicies struct {
+	Policies []peerpolicy.RuleStat
+}
+
+var peerPoliciesTextEncoder = cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *peerPolicies) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+	fmt.Fprintln(tw, "Name\tAction\tDeprioritized\tRefused\tMatch")
+	for _, p := range out.Policies {
+		var match []string
+		if p.AgentVersion != "" {
+			match = append(match, fmt.Sprintf("agent %q", p.AgentVersion))
+		}
+		if len(p.Peers) > 0 {
+			match = append(match, fmt.Sprintf("%d peers", len(p.Peers)))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", p.Name, p.Action, p.Deprioritized, p.Refused, strings.Join(match, ", "))
+	}
+	return nil
+})
+
+var swarmPolicyCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "List the peer policies and the actions they took.",
+		ShortDescription: `
+'ipfs swarm policy' lists the peer policies, which deprioritize or refuse the
+peers by peer ID or agent version, with the number of peers they deprioritized
+and of connections they refused. The first policy matching a peer applies.
+
+Deprioritized peers are tagged in the connection manager, so that their
+connections are trimmed first. Refused peers are disconnected once identified,
+and their next connections are refused. Peers are matched on their agent
+version once identified, and on their peer ID when they connect.
+
+Policies default to the ones of the "Swarm.PeerPolicies" config key, the
+subcommands change them and the config.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add": swarmPolicyAddCmd,
+		"rm":  swarmPolicyRmCmd,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.PeerPolicy == nil {
+			return ErrNotOnline
+		}
+		return cmds.EmitOnce(res, &peerPolicies{Policies: n.PeerPolicy.Stats()})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: peerPoliciesTextEncoder,
+	},
+	Type: peerPolicies{},
+}
+
+var swarmPolicyAddCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Add or replace a peer policy.",
+		ShortDescription: `
+'ipfs swarm policy add' adds a peer policy, or replaces the policy of the same
+name, and saves it in the config. The policy matches the peers given with
+--peer, and the peers whose agent version matches the regular expression
+--agent.
+
+  # refuse a crawler
+  ipfs swarm policy add crawler --agent='^crawler/' --action=refuse
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the policy."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(swarmPolicyAgentOptionName, "Regular expression matched against the agent version of the peers."),
+		cmds.StringsOption(swarmPolicyPeerOptionName, "ID of a peer matched by the policy."),
+		cmds.StringOption(swarmPolicyActionOptionName, "Action taken on the peers: deprioritize or refuse.").WithDefault(config.PeerPolicyDeprioritize),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		policy := config.PeerPolicy{Name: req.Arguments[0]}
+		policy.AgentVersion, _ = req.Options[swarmPolicyAgentOptionName].(string)
+		policy.Peers, _ = req.Options[swarmPolicyPeerOptionName].([]string)
+		policy.Action, _ = req.Options[swarmPolicyActionOptionName].(string)
+
+		return updatePeerPolicies(env, res, func(policies []config.PeerPolicy) []config.PeerPolicy {
+			for i, p := range policies {
+				if p.Name == policy.Name {
+					policies[i] = policy
+					return policies
+				}
+			}
+			return append(policies, policy)
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: peerPoliciesTextEncoder,
+	},
+	Type: peerPolicies{},
+}
+
+var swarmPolicyRmCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Remove peer policies.",
+		ShortDescription: `
+'ipfs swarm policy rm' removes the peer policies of the given names, and saves
+the change in the config.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, true, "Name of the policy to remove."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		var missing []string
+		err := updatePeerPolicies(env, res, func(policies []config.PeerPolicy) []config.PeerPolicy {
+			for _, name := range req.Arguments {
+				found := false
+				for i, p := range policies {
+					if p.Name == name {
+						policies = append(policies[:i], policies[i+1:]...)
+						found = true
+						break
+					}
+				}
+				if !found {
+					missing = append(missing, name)
+				}
+			}
+			if len(missing) > 0 {
+				return nil
+			}
+			return policies
+		})
+		if len(missing) > 0 {
+			return fmt.Errorf("no peer policy named %s", strings.Join(missing, ", "))
+		}
+		return err
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: peerPoliciesTextEncoder,
+	},
+	Type: peerPolicies{},
+}
+
+// updatePeerPolicies applies update to Swarm.PeerPolicies, enforces the new
+// policies and saves them in the config. Nothing changes when update returns
+// nil.
+func updatePeerPolicies(env cmds.Environment, res cmds.ResponseEmitter, update func([]config.PeerPolicy) []config.PeerPolicy) error {
+	n, err := cmdenv.GetNode(env)
+	if err != nil {
+		return err
+	}
+	if n.PeerPolicy == nil {
+		return ErrNotOnline
+	}
+
+	r, err := fsrepo.Open(env.(*commands.Context).ConfigRoot)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+
+	policies := update(append([]config.PeerPolicy(nil), cfg.Swarm.PeerPolicies...))
+	if policies == nil {
+		return nil
+	}
+	rules, err := libp2p.PeerPolicyRules(policies)
+	if err != nil {
+		return cmds.Errorf(cmds.ErrClient, err.Error())
+	}
+	if err := n.PeerPolicy.SetRules(rules); err != nil {
+		return cmds.Errorf(cmds.ErrClient, err.Error())
+	}
+
+	cfg.Swarm.PeerPolicies = policies
+	if err := r.SetConfig(cfg); err != nil {
+		return err
+	}
+	return cmds.EmitOnce(res, &peerPolicies{Policies: n.PeerPolicy.Stats()})
+}
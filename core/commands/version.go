@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"runtime/debug"
+	"strings"
 
 	version "github.com/ipfs/kubo"
 
@@ -49,6 +50,9 @@ var VersionCmd = &cmds.Command{
 				out := fmt.Sprintf("Kubo version: %s\n"+
 					"Repo version: %s\nSystem version: %s\nGolang version: %s\n",
 					ver, version.Repo, version.System, version.Golang)
+				if len(version.Disabled) > 0 {
+					out += fmt.Sprintf("Disabled subsystems: %s\n", strings.Join(version.Disabled, ", "))
+				}
 				fmt.Fprint(w, out)
 				return nil
 			}
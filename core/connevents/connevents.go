@@ -0,0 +1,294 @@
+// Package connevents records the connections of the node as they are opened,
+// closed or refused, with the reason they were closed or refused, and streams
+// them to subscribers, to debug the churn of the connections.
+package connevents
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// Type is the type of an event.
+type Type string
+
+const (
+	Opened  Type = "opened"
+	Closed  Type = "closed"
+	Refused Type = "refused"
+)
+
+// Reason is why a connection was closed or refused.
+type Reason string
+
+const (
+	// Local connections were closed by the node, with 'ipfs swarm
+	// disconnect'.
+	Local Reason = "local"
+	// Trim connections were closed by the connection manager, trimming the
+	// connections above its high water mark. Connections closed by the
+	// remote peer during a trim may be counted as trimmed.
+	Trim Reason = "trim"
+	// Policy connections were closed by the peer policies.
+	Policy Reason = "policy"
+	// ResourceLimit connections were refused by the resource manager.
+	ResourceLimit Reason = "resource-limit"
+	// Remote connections were closed by the remote peer, or failed: libp2p
+	// does not tell them apart.
+	Remote Reason = "remote"
+)
+
+// SubscriberBuffer is the number of events buffered for a subscriber, the
+// next events are dropped until the subscriber catches up.
+const SubscriberBuffer = 256
+
+const (
+	// markTTL is how long the connections of a peer closed by the node are
+	// counted with the reason given to Closing.
+	markTTL = 5 * time.Second
+	// trimSettle is how long after closing a connection the trims of the
+	// connection manager are looked for: the connection manager records its
+	// trims once the connections are closed.
+	trimSettle = time.Second
+)
+
+// Event is the opening, closing or refusal of a connection.
+type Event struct {
+	Time      time.Time
+	Type      Type
+	Peer      peer.ID `json:",omitempty"`
+	Addr      string  `json:",omitempty"`
+	Direction string  `json:",omitempty"`
+	Reason    Reason  `json:",omitempty"`
+	// Duration is how long a closed connection was open.
+	Duration time.Duration `json:",omitempty"`
+	Error    string        `json:",omitempty"`
+}
+
+// Stats are the number of events since Since, by reason for the closed and
+// refused connections.
+type Stats struct {
+	Since   time.Time
+	Opened  uint64
+	Closed  map[Reason]uint64
+	Refused map[Reason]uint64
+	// Dropped is the number of events dropped for subscribers not keeping
+	// up.
+	Dropped uint64
+}
+
+type mark struct {
+	reason Reason
+	until  time.Time
+}
+
+// Tracker records the events of the connections of a host.
+type Tracker struct {
+	clock clock.Clock
+
+	lk       sync.Mutex
+	host     host.Host
+	notifiee network.Notifiee
+	lastTrim func() time.Time
+	marks    map[peer.ID]mark
+	stopping bool
+	subs     map[chan Event]struct{}
+	stats    Stats
+}
+
+// New returns a tracker, recording the events once Start is called.
+func New(clk clock.Clock) *Tracker {
+	now := clk.Now()
+	return &Tracker{
+		clock: clk,
+		marks: make(map[peer.ID]mark),
+		subs:  make(map[chan Event]struct{}),
+		stats: Stats{
+			Since:   now,
+			Closed:  make(map[Reason]uint64),
+			Refused: make(map[Reason]uint64),
+		},
+	}
+}
+
+// Start records the events of the connections of h.
+func (t *Tracker) Start(h host.Host) {
+	n := &network.NotifyBundle{
+		ConnectedF:    func(_ network.Network, c network.Conn) { t.connected(c) },
+		DisconnectedF: func(_ network.Network, c network.Conn) { t.disconnected(c) },
+	}
+	t.lk.Lock()
+	t.host, t.notifiee = h, n
+	if cm, ok := h.ConnManager().(interface{ GetInfo() connmgr.CMInfo }); ok {
+		t.lastTrim = func() time.Time { return cm.GetInfo().LastTrim }
+	}
+	t.lk.Unlock()
+	h.Network().Notify(n)
+}
+
+// Close stops recording the events, and closes the subscriptions.
+func (t *Tracker) Close() error {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	t.stopping = true
+	if t.host != nil {
+		t.host.Network().StopNotify(t.notifiee)
+		t.host = nil
+	}
+	for ch := range t.subs {
+		delete(t.subs, ch)
+		close(ch)
+	}
+	return nil
+}
+
+// Closing records that the connections of p about to be closed are closed by
+// the node for reason.
+func (t *Tracker) Closing(p peer.ID, reason Reason) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	now := t.clock.Now()
+	for id, m := range t.marks {
+		if now.After(m.until) {
+			delete(t.marks, id)
+		}
+	}
+	t.marks[p] = mark{reason: reason, until: now.Add(markTTL)}
+}
+
+// Refused records a connection refused for err, p is empty when the
+// connection was refused before the peer was known.
+func (t *Tracker) Refused(dir network.Direction, p peer.ID, addr ma.Multiaddr, err error) {
+	e := Event{
+		Time:      t.clock.Now(),
+		Type:      Refused,
+		Peer:      p,
+		Direction: dir.String(),
+		Reason:    Remote,
+	}
+	if addr != nil {
+		e.Addr = addr.String()
+	}
+	if err != nil {
+		e.Error = err.Error()
+		if errors.Is(err, network.ErrResourceLimitExceeded) {
+			e.Reason = ResourceLimit
+		}
+	}
+	t.emit(e)
+}
+
+func (t *Tracker) connected(c network.Conn) {
+	t.emit(Event{
+		Time:      t.clock.Now(),
+		Type:      Opened,
+		Peer:      c.RemotePeer(),
+		Addr:      c.RemoteMultiaddr().String(),
+		Direction: c.Stat().Direction.String(),
+	})
+}
+
+func (t *Tracker) disconnected(c network.Conn) {
+	now := t.clock.Now()
+	e := Event{
+		Time:      now,
+		Type:      Closed,
+		Peer:      c.RemotePeer(),
+		Addr:      c.RemoteMultiaddr().String(),
+		Direction: c.Stat().Direction.String(),
+	}
+	if opened := c.Stat().Opened; !opened.IsZero() {
+		e.Duration = now.Sub(opened)
+	}
+
+	t.lk.Lock()
+	if m, ok := t.marks[e.Peer]; ok && !now.After(m.until) {
+		e.Reason = m.reason
+	}
+	lastTrim := t.lastTrim
+	t.lk.Unlock()
+
+	if e.Reason != "" || lastTrim == nil {
+		if e.Reason == "" {
+			e.Reason = Remote
+		}
+		t.emit(e)
+		return
+	}
+	t.clock.AfterFunc(trimSettle, func() {
+		e.Reason = Remote
+		if last := lastTrim(); last.After(now.Add(-trimSettle)) {
+			e.Reason = Trim
+		}
+		t.emit(e)
+	})
+}
+
+func (t *Tracker) emit(e Event) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	switch e.Type {
+	case Opened:
+		t.stats.Opened++
+	case Closed:
+		t.stats.Closed[e.Reason]++
+	case Refused:
+		t.stats.Refused[e.Reason]++
+	}
+	for ch := range t.subs {
+		select {
+		case ch <- e:
+		default:
+			t.stats.Dropped++
+		}
+	}
+}
+
+// Subscribe returns the events from now on, until ctx is done or the tracker
+// is closed.
+func (t *Tracker) Subscribe(ctx context.Context) <-chan Event {
+	ch := make(chan Event, SubscriberBuffer)
+	t.lk.Lock()
+	if t.stopping {
+		close(ch)
+		t.lk.Unlock()
+		return ch
+	}
+	t.subs[ch] = struct{}{}
+	t.lk.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		t.lk.Lock()
+		defer t.lk.Unlock()
+		if _, ok := t.subs[ch]; ok {
+			delete(t.subs, ch)
+			close(ch)
+		}
+	}()
+	return ch
+}
+
+// Stats returns the number of events since the tracker started.
+func (t *Tracker) Stats() Stats {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	s := t.stats
+	s.Closed = make(map[Reason]uint64, len(t.stats.Closed))
+	for r, n := range t.stats.Closed {
+		s.Closed[r] = n
+	}
+	s.Refused = make(map[Reason]uint64, len(t.stats.Refused))
+	for r, n := range t.stats.Refused {
+		s.Refused[r] = n
+	}
+	return s
+}
@@ -0,0 +1,74 @@
+package connevents
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func next(t *testing.T, events <-chan Event) Event {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+		return Event{}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mn, err := mocknet.FullMeshLinked(3)
+	require.NoError(t, err)
+	defer mn.Close()
+	h1, h2, h3 := mn.Hosts()[0], mn.Hosts()[1], mn.Hosts()[2]
+
+	tr := New(clock.New())
+	tr.Start(h1)
+	defer tr.Close()
+	events := tr.Subscribe(ctx)
+
+	require.NoError(t, h2.Connect(ctx, peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	e := next(t, events)
+	require.Equal(t, Opened, e.Type)
+	require.Equal(t, h2.ID(), e.Peer)
+	require.Equal(t, network.DirInbound.String(), e.Direction)
+
+	// closed by the node
+	tr.Closing(h2.ID(), Local)
+	require.NoError(t, h1.Network().ClosePeer(h2.ID()))
+	e = next(t, events)
+	require.Equal(t, Closed, e.Type)
+	require.Equal(t, Local, e.Reason)
+
+	// closed by the remote peer
+	require.NoError(t, h3.Connect(ctx, peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	require.Equal(t, Opened, next(t, events).Type)
+	require.NoError(t, h3.Network().ClosePeer(h1.ID()))
+	e = next(t, events)
+	require.Equal(t, Closed, e.Type)
+	require.Equal(t, h3.ID(), e.Peer)
+	require.Equal(t, Remote, e.Reason)
+
+	tr.Refused(network.DirInbound, "", nil, fmt.Errorf("opening connection: %w", network.ErrResourceLimitExceeded))
+	e = next(t, events)
+	require.Equal(t, Refused, e.Type)
+	require.Equal(t, ResourceLimit, e.Reason)
+
+	stats := tr.Stats()
+	require.Equal(t, uint64(2), stats.Opened)
+	require.Equal(t, map[Reason]uint64{Local: 1, Remote: 1}, stats.Closed)
+	require.Equal(t, map[Reason]uint64{ResourceLimit: 1}, stats.Refused)
+
+	require.NoError(t, tr.Close())
+	_, ok := <-events
+	require.False(t, ok)
+}
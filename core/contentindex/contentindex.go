@@ -0,0 +1,344 @@
+// Package contentindex maintains a searchable index of the local UnixFS
+// content: the files and directories added to the node, and the ones in MFS.
+//
+// The index is stored in the repo datastore. Searches scan the whole index,
+// which is fast enough for the amount of content a node stores locally.
+package contentindex
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	gopath "path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	format "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+
+	"github.com/ipfs/kubo/core/indexer"
+)
+
+var log = logging.Logger("contentindex")
+
+var (
+	// DatastoreKey is the prefix under which the index is persisted.
+	DatastoreKey = datastore.NewKey("/local/contentindex")
+
+	entriesKey = DatastoreKey.ChildString("entries")
+	mfsRootKey = DatastoreKey.ChildString("state").ChildString("mfsroot")
+)
+
+const (
+	// SourceAdd is the source of the content imported with 'ipfs add'.
+	SourceAdd = "add"
+	// SourceMFS is the source of the content found in MFS.
+	SourceMFS = "mfs"
+)
+
+// sniffLen is the number of bytes http.DetectContentType looks at.
+const sniffLen = 512
+
+// Entry is an indexed file or directory.
+type Entry struct {
+	Source string
+	// Path is the MFS path of the entry, or the path of the entry under the
+	// name it was added as, e.g. "photos/2024/beach.jpg".
+	Path string
+	Name string
+	Cid  cid.Cid
+	// Root is the CID the entry was added under. It is undefined for MFS
+	// entries.
+	Root cid.Cid
+	Dir  bool
+	// Size is the size of a file, or the size of the DAG of a directory.
+	Size     uint64
+	MimeType string `json:",omitempty"`
+	// Added is when the entry was added, or when it last changed in MFS.
+	Added time.Time
+	// ModTime is the modification time of a file added from the local
+	// filesystem. It is zero when it isn't known.
+	ModTime time.Time
+	// Incomplete is set on the directories whose content was not entirely
+	// stored locally when they were indexed.
+	Incomplete bool `json:",omitempty"`
+}
+
+// Index is the content index of a node.
+type Index struct {
+	ds  datastore.Batching
+	dag format.DAGService
+
+	// mfsLk serializes the updates of the MFS entries.
+	mfsLk sync.Mutex
+
+	loop *indexer.Loop
+}
+
+// New returns the index persisted in ds. The indexed content is read through
+// dag, which should not fetch missing blocks from the network: content that
+// isn't stored locally is not indexed.
+func New(ds datastore.Batching, dag format.DAGService) *Index {
+	idx := &Index{
+		ds:  ds,
+		dag: dag,
+	}
+	idx.loop = indexer.Start(idx, log, 0)
+	return idx
+}
+
+// Close stops indexing MFS in the background.
+func (idx *Index) Close() error {
+	return idx.loop.Close()
+}
+
+// UpdateMFS schedules the indexing of the MFS tree under root, in the
+// background. When MFS changes quickly, only the latest root is indexed.
+func (idx *Index) UpdateMFS(root cid.Cid) {
+	idx.loop.UpdateMFS(root)
+}
+
+// IndexAdd indexes the content added as root, under name. The modification
+// times of the files added from the local filesystem are given by their path
+// relative to root, "" being root itself. Content already indexed under the
+// same name keeps the time it was first added.
+func (idx *Index) IndexAdd(ctx context.Context, root cid.Cid, name string, modTimes map[string]time.Time) error {
+	if name == "" {
+		name = root.String()
+	}
+	template := Entry{Source: SourceAdd, Root: root, Added: time.Now()}
+	has, err := idx.ds.Has(ctx, entryKey(&Entry{Source: SourceAdd, Root: root, Path: name}))
+	if err != nil || has {
+		return err
+	}
+
+	b, err := idx.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	put := func(e *Entry) error {
+		rel := strings.TrimPrefix(strings.TrimPrefix(e.Path, name), "/")
+		e.ModTime = modTimes[rel]
+		return putEntry(ctx, b, e)
+	}
+	if _, err := idx.walk(template, nil, put).Run(ctx, name, root); err != nil {
+		return err
+	}
+	return b.Commit(ctx)
+}
+
+// IndexMFS updates the MFS entries to the tree under root. Only the
+// directories that changed since the last update are walked.
+func (idx *Index) IndexMFS(ctx context.Context, root cid.Cid) error {
+	idx.mfsLk.Lock()
+	defer idx.mfsLk.Unlock()
+
+	if unchanged, err := indexer.Unchanged(ctx, idx.ds, mfsRootKey, root); err != nil || unchanged {
+		return err
+	}
+
+	existing, err := idx.entries(ctx, SourceMFS)
+	if err != nil {
+		return err
+	}
+	indexed := make(map[string]indexer.Indexed, len(existing))
+	for p, e := range existing {
+		indexed[p] = indexer.Indexed{Cid: e.Cid, Incomplete: e.Incomplete}
+	}
+	tree := indexer.NewTree(indexed)
+
+	b, err := idx.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	put := func(e *Entry) error {
+		if e.Path == "/" {
+			return nil
+		}
+		tree.Keep(e.Path)
+		if old, ok := existing[e.Path]; ok && old.Cid.Equals(e.Cid) {
+			e.Added = old.Added
+		}
+		return putEntry(ctx, b, e)
+	}
+	complete, err := idx.walk(Entry{Source: SourceMFS, Added: time.Now()}, tree.Skip, put).Run(ctx, "/", root)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range tree.Stale() {
+		if err := b.Delete(ctx, entryKey(&Entry{Source: SourceMFS, Path: p})); err != nil {
+			return err
+		}
+	}
+	if err := indexer.RecordRoot(ctx, b, mfsRootKey, root, complete); err != nil {
+		return err
+	}
+	return b.Commit(ctx)
+}
+
+// Search returns the entries matching query, most recently added first. A
+// limit of 0 returns all of them. See ParseQuery for the syntax of queries.
+func (idx *Index) Search(ctx context.Context, query string, limit int) ([]Entry, error) {
+	q, err := ParseQuery(query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Entry
+	err = idx.scan(ctx, func(e *Entry) {
+		if q.Match(e) {
+			found = append(found, *e)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].Added.Equal(found[j].Added) {
+			return found[i].Added.After(found[j].Added)
+		}
+		return found[i].Path < found[j].Path
+	})
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+	return found, nil
+}
+
+// entries returns the entries of a source, by path.
+func (idx *Index) entries(ctx context.Context, source string) (map[string]Entry, error) {
+	entries := make(map[string]Entry)
+	err := idx.scanPrefix(ctx, entriesKey.ChildString(source), func(e *Entry) {
+		entries[e.Path] = *e
+	})
+	return entries, err
+}
+
+func (idx *Index) scan(ctx context.Context, f func(*Entry)) error {
+	return idx.scanPrefix(ctx, entriesKey, f)
+}
+
+func (idx *Index) scanPrefix(ctx context.Context, prefix datastore.Key, f func(*Entry)) error {
+	results, err := idx.ds.Query(ctx, query.Query{Prefix: prefix.String()})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		var e Entry
+		if err := json.Unmarshal(r.Value, &e); err != nil {
+			log.Warnf("corrupt entry %s: %s", r.Key, err)
+			continue
+		}
+		f(&e)
+	}
+	return nil
+}
+
+// entryKey is the key of an entry: its source, the CID it was added under,
+// and the encoded segments of its path, so that the entries under a
+// directory share its prefix.
+func entryKey(e *Entry) datastore.Key {
+	k := entriesKey.ChildString(e.Source)
+	if e.Root.Defined() {
+		k = k.ChildString(e.Root.String())
+	}
+	for _, seg := range strings.Split(strings.Trim(e.Path, "/"), "/") {
+		if seg != "" {
+			k = k.ChildString(base64.RawURLEncoding.EncodeToString([]byte(seg)))
+		}
+	}
+	return k
+}
+
+func putEntry(ctx context.Context, b datastore.Batch, e *Entry) error {
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return b.Put(ctx, entryKey(e), buf)
+}
+
+// walk returns the walk that indexes a UnixFS DAG, calling put with the
+// entries filled from template.
+func (idx *Index) walk(template Entry, skip func(p string, c cid.Cid) bool, put func(e *Entry) error) *indexer.Walk {
+	entry := func(p string, nd format.Node) *Entry {
+		e := template
+		e.Path, e.Name, e.Cid = p, gopath.Base(p), nd.Cid()
+		return &e
+	}
+	return &indexer.Walk{
+		DAG:  idx.dag,
+		Skip: skip,
+		File: func(ctx context.Context, p string, nd format.Node) (bool, error) {
+			e := entry(p, nd)
+			switch nd := nd.(type) {
+			case *merkledag.RawNode:
+				data := nd.RawData()
+				e.Size = uint64(len(data))
+				if len(data) > sniffLen {
+					data = data[:sniffLen]
+				}
+				e.MimeType = MimeType(e.Name, data)
+			case *merkledag.ProtoNode:
+				fsn, err := ft.FSNodeFromBytes(nd.Data())
+				if err != nil {
+					return false, err
+				}
+				e.Size = fsn.FileSize()
+				e.MimeType = MimeType(e.Name, idx.head(ctx, nd))
+			}
+			return true, put(e)
+		},
+		Dir: func(ctx context.Context, p string, nd format.Node, complete bool) error {
+			e := entry(p, nd)
+			e.Dir, e.Incomplete = true, !complete
+			e.Size, _ = nd.Size()
+			return put(e)
+		},
+	}
+}
+
+// head returns the first bytes of a file, or what is stored locally of them.
+func (idx *Index) head(ctx context.Context, nd format.Node) []byte {
+	r, err := uio.NewDagReader(ctx, nd, idx.dag)
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	buf := make([]byte, sniffLen)
+	n, _ := io.ReadFull(r, buf)
+	return buf[:n]
+}
+
+// MimeType sniffs the MIME type of a file from its first bytes, falling back
+// on its extension when the content isn't recognized.
+func MimeType(name string, head []byte) string {
+	var sniffed string
+	if len(head) > 0 {
+		sniffed, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+	}
+	if sniffed == "" || sniffed == "application/octet-stream" || sniffed == "text/plain" {
+		if byExt, _, err := mime.ParseMediaType(mime.TypeByExtension(gopath.Ext(name))); err == nil {
+			return byExt
+		}
+	}
+	return sniffed
+}
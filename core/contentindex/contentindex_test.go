@@ -0,0 +1,204 @@
+package contentindex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	format "github.com/ipfs/go-ipld-format"
+)
+
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR")
+
+func dir(t *testing.T, dag format.DAGService, children map[string]format.Node) *merkledag.ProtoNode {
+	t.Helper()
+	nd := ft.EmptyDirNode()
+	for name, child := range children {
+		if err := nd.AddNodeLink(name, child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dag.Add(context.Background(), nd); err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
+
+func file(t *testing.T, dag format.DAGService, data []byte) format.Node {
+	t.Helper()
+	nd := merkledag.NewRawNode(data)
+	if err := dag.Add(context.Background(), nd); err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
+
+func search(t *testing.T, idx *Index, query string) []string {
+	t.Helper()
+	entries, err := idx.Search(context.Background(), query, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, e.Path)
+	}
+	return paths
+}
+
+func expectPaths(t *testing.T, idx *Index, query string, expected ...string) {
+	t.Helper()
+	paths := search(t, idx, query)
+	if len(paths) != len(expected) {
+		t.Fatalf("%s: expected %v, got %v", query, expected, paths)
+	}
+	found := make(map[string]bool)
+	for _, p := range paths {
+		found[p] = true
+	}
+	for _, p := range expected {
+		if !found[p] {
+			t.Fatalf("%s: expected %v, got %v", query, expected, paths)
+		}
+	}
+}
+
+func TestIndexAdd(t *testing.T) {
+	ctx := context.Background()
+	dag := dagtest.Mock()
+	idx := New(dssync.MutexWrap(datastore.NewMapDatastore()), dag)
+	defer idx.Close()
+
+	beach := file(t, dag, pngHeader)
+	root := dir(t, dag, map[string]format.Node{
+		"Beach.png": beach,
+		"notes.txt": file(t, dag, []byte("remember the sunscreen")),
+	})
+	modified := time.Date(2023, 7, 14, 12, 0, 0, 0, time.Local)
+	err := idx.IndexAdd(ctx, root.Cid(), "holidays", map[string]time.Time{"notes.txt": modified})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectPaths(t, idx, "source:add", "holidays", "holidays/Beach.png", "holidays/notes.txt")
+	expectPaths(t, idx, "beach", "holidays/Beach.png")
+	expectPaths(t, idx, "type:image", "holidays/Beach.png")
+	expectPaths(t, idx, "type:text/plain", "holidays/notes.txt")
+	expectPaths(t, idx, "is:dir", "holidays")
+	expectPaths(t, idx, "name:*.txt added>1d", "holidays/notes.txt")
+	expectPaths(t, idx, "modified:2023-07", "holidays/notes.txt")
+	expectPaths(t, idx, "modified<2023-07-14")
+	expectPaths(t, idx, "cid:"+beach.Cid().String(), "holidays/Beach.png")
+	expectPaths(t, idx, "cid:"+root.Cid().String(), "holidays", "holidays/Beach.png", "holidays/notes.txt")
+
+	entries, err := idx.Search(ctx, "is:file", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+}
+
+func TestIndexMFS(t *testing.T) {
+	ctx := context.Background()
+	dag := dagtest.Mock()
+	idx := New(dssync.MutexWrap(datastore.NewMapDatastore()), dag)
+	defer idx.Close()
+
+	a := file(t, dag, []byte("a"))
+	b := file(t, dag, []byte("b"))
+	sub := dir(t, dag, map[string]format.Node{"b": b})
+	if err := idx.IndexMFS(ctx, dir(t, dag, map[string]format.Node{"a": a, "sub": sub}).Cid()); err != nil {
+		t.Fatal(err)
+	}
+	expectPaths(t, idx, "source:mfs", "/a", "/sub", "/sub/b")
+	before, err := idx.Search(ctx, "path:/sub/b", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// /a is removed, /c is missing locally
+	missing := merkledag.NewRawNode([]byte("c"))
+	root := dir(t, dag, map[string]format.Node{"sub": sub, "c": missing})
+	if err := dag.Remove(ctx, missing.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.IndexMFS(ctx, root.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	expectPaths(t, idx, "source:mfs", "/sub", "/sub/b")
+
+	after, err := idx.Search(ctx, "path:/sub/b", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after[0].Added.Equal(before[0].Added) {
+		t.Fatal("unchanged entry was indexed again")
+	}
+
+	// the incomplete root is walked again once the content is available
+	if err := dag.Add(ctx, missing); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.IndexMFS(ctx, root.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	expectPaths(t, idx, "source:mfs", "/c", "/sub", "/sub/b")
+}
+
+func TestParseQuery(t *testing.T) {
+	now := time.Date(2024, 10, 15, 12, 0, 0, 0, time.UTC)
+	e := &Entry{
+		Source:   SourceAdd,
+		Path:     "photos/2024/Beach Day.jpg",
+		Name:     "Beach Day.jpg",
+		Size:     3 << 20,
+		MimeType: "image/jpeg",
+		Added:    time.Date(2024, 9, 20, 8, 0, 0, 0, time.UTC),
+	}
+
+	for query, match := range map[string]bool{
+		"beach":                       true,
+		`"beach day"`:                 true,
+		`name:"beach day.jpg"`:        true,
+		"name:*.png":                  false,
+		"path:photos/2024":            true,
+		"type:image":                  true,
+		"type:image/*":                true,
+		"type:video":                  false,
+		"is:file":                     true,
+		"size>1MB":                    true,
+		"size>=3MiB":                  true,
+		"size<3MiB":                   false,
+		"added>30d":                   true,
+		"added>7d":                    false,
+		"added:2024-09":               true,
+		"added:2024-09-20":            true,
+		"added>2024-09-20":            false,
+		"added>=2024-09-20":           true,
+		"added<2024":                  false,
+		"modified<2030":               false,
+		"source:mfs":                  false,
+		"beach type:image added:2024": true,
+	} {
+		q, err := ParseQuery(query, now)
+		if err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+		if q.Match(e) != match {
+			t.Errorf("%s: expected match to be %t", query, match)
+		}
+	}
+
+	for _, query := range []string{`"beach`, "size>big", "added>yesterday", "is:symlink", "color:blue", "name>a", "source:web"} {
+		if _, err := ParseQuery(query, now); err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+}
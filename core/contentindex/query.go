@@ -0,0 +1,258 @@
+package contentindex
+
+import (
+	"bytes"
+	"fmt"
+	gopath "path"
+	"strconv"
+	"strings"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/ipfs/go-cid"
+)
+
+// Query is a parsed search query. An entry matches when all of its terms
+// match.
+type Query struct {
+	terms []func(*Entry) bool
+}
+
+// Match reports whether e matches the query.
+func (q *Query) Match(e *Entry) bool {
+	for _, t := range q.terms {
+		if !t(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseQuery parses a query made of terms separated by spaces. Double quotes
+// group words containing spaces into one term. Terms are:
+//
+//	beach, name:*.jpg    the name contains a word, or matches a glob
+//	path:photos/2024     the path contains a string, or matches a glob
+//	type:image           the MIME type, or its type only
+//	is:file, is:dir      files or directories only
+//	size>10MB            the size, compared with >, >=, <, <= or :
+//	added>30d            added in the last 30 days (h, d, w, mo and y)
+//	added:2024-09        added in September 2024 (also a day or a year)
+//	modified<2024-01-01  the modification time on the local filesystem
+//	cid:bafy...          the CID of the entry, or the CID it was added under
+//	source:add           added content (add), or MFS content (mfs)
+//
+// Names, paths and types are case insensitive. Relative times are relative
+// to now.
+func ParseQuery(s string, now time.Time) (*Query, error) {
+	words, err := splitQuery(s)
+	if err != nil {
+		return nil, err
+	}
+
+	q := new(Query)
+	for _, w := range words {
+		t, err := parseTerm(w, now)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", w, err)
+		}
+		q.terms = append(q.terms, t)
+	}
+	return q, nil
+}
+
+func splitQuery(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	quoted, inWord := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted, inWord = !quoted, true
+		case r == ' ' && !quoted:
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func parseTerm(w string, now time.Time) (func(*Entry) bool, error) {
+	i := strings.IndexAny(w, ":<>=")
+	if i <= 0 {
+		return matchString(w, func(e *Entry) string { return e.Name }), nil
+	}
+	field, op, value := strings.ToLower(w[:i]), w[i:i+1], w[i+1:]
+	if (op == ">" || op == "<") && strings.HasPrefix(value, "=") {
+		op, value = op+"=", value[1:]
+	}
+	if op == "=" {
+		op = ":"
+	}
+
+	switch field {
+	case "size":
+		size, err := humanize.ParseBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return func(e *Entry) bool { return compareSize(e.Size, op, size) }, nil
+	case "added", "modified":
+		from, to, relative, err := parseTime(value, now)
+		if err != nil {
+			return nil, err
+		}
+		if relative && op == ":" {
+			op = ">="
+		}
+		modified := field == "modified"
+		return func(e *Entry) bool {
+			t := e.Added
+			if modified {
+				t = e.ModTime
+				if t.IsZero() {
+					return false
+				}
+			}
+			return compareTime(t, op, from, to)
+		}, nil
+	}
+
+	if op != ":" {
+		return nil, fmt.Errorf("%s can't be compared with %s", field, op)
+	}
+	switch field {
+	case "name":
+		return matchString(value, func(e *Entry) string { return e.Name }), nil
+	case "path":
+		return matchString(value, func(e *Entry) string { return e.Path }), nil
+	case "type":
+		value = strings.ToLower(value)
+		if !strings.Contains(value, "/") {
+			return func(e *Entry) bool { return strings.HasPrefix(e.MimeType, value+"/") }, nil
+		}
+		return matchString(value, func(e *Entry) string { return e.MimeType }), nil
+	case "is":
+		switch value {
+		case "file":
+			return func(e *Entry) bool { return !e.Dir }, nil
+		case "dir":
+			return func(e *Entry) bool { return e.Dir }, nil
+		}
+		return nil, fmt.Errorf("expected is:file or is:dir")
+	case "cid":
+		c, err := cid.Decode(value)
+		if err != nil {
+			return nil, err
+		}
+		return func(e *Entry) bool {
+			return sameHash(e.Cid, c) || sameHash(e.Root, c)
+		}, nil
+	case "source":
+		if value != SourceAdd && value != SourceMFS {
+			return nil, fmt.Errorf("expected source:%s or source:%s", SourceAdd, SourceMFS)
+		}
+		return func(e *Entry) bool { return e.Source == value }, nil
+	}
+	return nil, fmt.Errorf("unknown field %q", field)
+}
+
+// matchString matches a glob pattern, or else a substring, case insensitively.
+func matchString(pattern string, field func(*Entry) string) func(*Entry) bool {
+	pattern = strings.ToLower(pattern)
+	if strings.ContainsAny(pattern, "*?[") {
+		return func(e *Entry) bool {
+			ok, _ := gopath.Match(pattern, strings.ToLower(field(e)))
+			return ok
+		}
+	}
+	return func(e *Entry) bool {
+		return strings.Contains(strings.ToLower(field(e)), pattern)
+	}
+}
+
+func sameHash(a, b cid.Cid) bool {
+	return a.Defined() && bytes.Equal(a.Hash(), b.Hash())
+}
+
+func compareSize(size uint64, op string, value uint64) bool {
+	switch op {
+	case ">":
+		return size > value
+	case ">=":
+		return size >= value
+	case "<":
+		return size < value
+	case "<=":
+		return size <= value
+	default:
+		return size == value
+	}
+}
+
+// compareTime compares t with the period [from, to).
+func compareTime(t time.Time, op string, from, to time.Time) bool {
+	switch op {
+	case ">":
+		return !t.Before(to)
+	case ">=":
+		return !t.Before(from)
+	case "<":
+		return t.Before(from)
+	case "<=":
+		return t.Before(to)
+	default:
+		return !t.Before(from) && t.Before(to)
+	}
+}
+
+var relativeUnits = map[string]time.Duration{
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"mo": 30 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// parseTime parses a date as the period [from, to) it covers, or a time
+// relative to now as an instant.
+func parseTime(value string, now time.Time) (from, to time.Time, relative bool, err error) {
+	if n := strings.IndexFunc(value, func(r rune) bool { return r < '0' || r > '9' }); n > 0 {
+		if unit, ok := relativeUnits[value[n:]]; ok {
+			count, err := strconv.Atoi(value[:n])
+			if err != nil {
+				return from, to, false, err
+			}
+			t := now.Add(-time.Duration(count) * unit)
+			return t, t, true, nil
+		}
+	}
+
+	for _, layout := range []struct {
+		layout string
+		next   func(time.Time) time.Time
+	}{
+		{time.RFC3339, func(t time.Time) time.Time { return t.Add(time.Second) }},
+		{"2006-01-02T15:04", func(t time.Time) time.Time { return t.Add(time.Minute) }},
+		{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+		{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+		{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+	} {
+		if t, err := time.ParseInLocation(layout.layout, value, now.Location()); err == nil {
+			return t, layout.next(t), false, nil
+		}
+	}
+	return from, to, false, fmt.Errorf("expected a date (2006-01-02) or a relative time (30d)")
+}
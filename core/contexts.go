@@ -0,0 +1,53 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ipfs/kubo/config"
+)
+
+// RepoContext is a repo opened by the daemon next to its own one, with its
+// own blockstore, pins and keys, selected by name with the "context" API
+// option.
+type RepoContext struct {
+	Name string
+	Path string
+	Node *IpfsNode
+
+	// Host is the node whose libp2p identity, exchange and routing the
+	// context uses when it shares the identity of the daemon, nil when the
+	// context has its own identity.
+	Host *IpfsNode
+}
+
+// Identity returns config.RepoIdentityShared or config.RepoIdentityOwn.
+func (c *RepoContext) Identity() string {
+	if c.Host != nil {
+		return config.RepoIdentityShared
+	}
+	return config.RepoIdentityOwn
+}
+
+// Contexts are the repo contexts of a daemon, by name. They are shared by the
+// node of the daemon and the nodes of the contexts.
+type Contexts map[string]*RepoContext
+
+// Get returns the context named name.
+func (cs Contexts) Get(name string) (*RepoContext, error) {
+	c, ok := cs[name]
+	if !ok {
+		return nil, fmt.Errorf("no repo context named %q", name)
+	}
+	return c, nil
+}
+
+// Names returns the names of the contexts, sorted.
+func (cs Contexts) Names() []string {
+	names := make([]string, 0, len(cs))
+	for name := range cs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -15,6 +15,7 @@ import (
 	"io"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/ipfs/boxo/filestore"
 	pin "github.com/ipfs/boxo/pinning/pinner"
 	"github.com/ipfs/go-datastore"
@@ -29,7 +30,6 @@ import (
 	ipld "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log"
 	goprocess "github.com/jbenet/goprocess"
-	ddht "github.com/libp2p/go-libp2p-kad-dht/dual"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	psrouter "github.com/libp2p/go-libp2p-pubsub-router"
 	record "github.com/libp2p/go-libp2p-record"
@@ -50,9 +50,39 @@ import (
 	"github.com/ipfs/boxo/namesys"
 	ipnsrp "github.com/ipfs/boxo/namesys/republisher"
 	"github.com/ipfs/boxo/peering"
+	"github.com/ipfs/kubo/blocks/bloombs"
+	"github.com/ipfs/kubo/blocks/compressbs"
+	"github.com/ipfs/kubo/blocks/remotebs"
+	"github.com/ipfs/kubo/blocks/sourcebs"
+	"github.com/ipfs/kubo/blocks/writebackbs"
 	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/bwlimit"
+	"github.com/ipfs/kubo/core/cachewarm"
+	"github.com/ipfs/kubo/core/connevents"
+	"github.com/ipfs/kubo/core/contentindex"
+	"github.com/ipfs/kubo/core/dhthealth"
+	"github.com/ipfs/kubo/core/fulltext"
+	"github.com/ipfs/kubo/core/kv"
+	"github.com/ipfs/kubo/core/mfsexpiry"
+	"github.com/ipfs/kubo/core/mfsflush"
+	"github.com/ipfs/kubo/core/mfsrefs"
+	"github.com/ipfs/kubo/core/mimetypes"
+	"github.com/ipfs/kubo/core/namecache"
 	"github.com/ipfs/kubo/core/node"
 	"github.com/ipfs/kubo/core/node/libp2p"
+	"github.com/ipfs/kubo/core/peerpolicy"
+	"github.com/ipfs/kubo/core/pincontrol"
+	"github.com/ipfs/kubo/core/pinqueue"
+	"github.com/ipfs/kubo/core/pinreport"
+	"github.com/ipfs/kubo/core/pinsync"
+	"github.com/ipfs/kubo/core/preview"
+	"github.com/ipfs/kubo/core/provenance"
+	"github.com/ipfs/kubo/core/provideclass"
+	"github.com/ipfs/kubo/core/readonly"
+	"github.com/ipfs/kubo/core/replicate"
+	"github.com/ipfs/kubo/core/repoforecast"
+	"github.com/ipfs/kubo/core/standby"
+	"github.com/ipfs/kubo/core/webhooks"
 	"github.com/ipfs/kubo/fuse/mount"
 	"github.com/ipfs/kubo/p2p"
 	"github.com/ipfs/kubo/repo"
@@ -68,17 +98,37 @@ type IpfsNode struct {
 
 	Repo repo.Repo
 
+	Clock clock.Clock // the clock of the expiries, see BuildCfg.Clock
+
 	// Local node
-	Pinning         pin.Pinner             // the pinning manager
-	Mounts          Mounts                 `optional:"true"` // current mount state, if any.
-	PrivateKey      ic.PrivKey             `optional:"true"` // the local node's private Key
-	PNetFingerprint libp2p.PNetFingerprint `optional:"true"` // fingerprint of private network
+	Pinning         pin.Pinner              // the pinning manager
+	PinQueue        *pinqueue.Queue         // the queue of background pins
+	ProvideClasses  *provideclass.Scheduler // the provide classes of the pins
+	ContentIndex    *contentindex.Index     `optional:"true"` // the index of the local content, if enabled
+	FullText        *fulltext.Indexer       `optional:"true"` // the full-text index of MFS and pins, if enabled
+	MimeTypes       *mimetypes.Cache        // the cache of the MIME types of files
+	CacheWarm       *cachewarm.Store        // the DAGs protected by 'ipfs cache warm'
+	MFSRefs         *mfsrefs.Index          // the index of the blocks referenced by MFS
+	MFSExpiry       *mfsexpiry.Reaper       // the TTLs of MFS paths
+	MFSFlusher      *mfsflush.Flusher       // batches the flushes of MFS
+	ReadOnly        *readonly.Mode          // the read-only mode of the node
+	Previews        *preview.Generator      `optional:"true"` // the previews of files, if enabled
+	RepoForecast    *repoforecast.Monitor   `optional:"true"` // the forecast of the growth of the repo, if enabled
+	Webhooks        *webhooks.Notifier      `optional:"true"` // the webhooks of the events of the node, if any
+	Mounts          Mounts                  `optional:"true"` // current mount state, if any.
+	PrivateKey      ic.PrivKey              `optional:"true"` // the local node's private Key
+	PNetFingerprint libp2p.PNetFingerprint  `optional:"true"` // fingerprint of private network
 
 	// Services
 	Peerstore                   pstore.Peerstore          `optional:"true"` // storage for other Peer instances
 	Blockstore                  bstore.GCBlockstore       // the block store (lower level)
 	Filestore                   *filestore.Filestore      `optional:"true"` // the filestore blockstore
+	FilestoreSources            *sourcebs.Checker         `optional:"true"` // the availability of the mounts of the filestore sources, nil without Filestore.Mounts
 	BaseBlocks                  node.BaseBlocks           // the raw blockstore, no filestore wrapping
+	BlockCompression            *compressbs.Blockstore    `optional:"true"` // the compressing blockstore layer
+	WriteBack                   *writebackbs.Blockstore   `optional:"true"` // the write-back journal of the blockstore, nil when disabled
+	BloomFilter                 *bloombs.Blockstore       `optional:"true"` // the bloom filter of the blockstore, nil when disabled
+	RemoteBlocks                *remotebs.Blockstore      `optional:"true"` // the remote blockstore, nil when Datastore.Remote is disabled
 	GCLocker                    bstore.GCLocker           // the locker used to protect the blockstore during gc
 	Blocks                      bserv.BlockService        // the block service, get/add blocks.
 	DAG                         ipld.DAGService           // the merkle dag service, get/add objects.
@@ -95,6 +145,8 @@ type IpfsNode struct {
 	PeerHost                  p2phost.Host               `optional:"true"` // the network host (server+client)
 	Peering                   *peering.PeeringService    `optional:"true"`
 	Filters                   *ma.Filters                `optional:"true"`
+	PeerPolicy                *peerpolicy.Policy         `optional:"true"`
+	ConnEvents                *connevents.Tracker        `optional:"true"` // the events of the connections
 	Bootstrapper              io.Closer                  `optional:"true"` // the periodic bootstrapper
 	Routing                   irouting.ProvideManyRouter `optional:"true"` // the routing system. recommend ipfs-dht
 	DNSResolver               *madns.Resolver            // the DNS resolver
@@ -103,15 +155,26 @@ type IpfsNode struct {
 	OfflineIPLDPathResolver   pathresolver.Resolver      `name:"offlineIpldPathResolver"`   // The IPLD path resolver that uses only locally available blocks
 	OfflineUnixFSPathResolver pathresolver.Resolver      `name:"offlineUnixFSPathResolver"` // The UnixFS path resolver that uses only locally available blocks
 	Exchange                  exchange.Interface         // the block exchange + strategy (bitswap)
+	BlockSources              *pinreport.Recorder        `optional:"true"` // the origin of the blocks received by bitswap
+	BlockProvenance           *provenance.Recorder       `optional:"true"` // the recorded origin of the blocks received by bitswap, if enabled
+	PinSync                   *pinsync.Service           `optional:"true"` // pinset reconciliation with peers, if enabled
+	Replicate                 *replicate.Service         `optional:"true"` // the pins pushed to and by peers, if enabled
+	Standby                   *standby.Service           `optional:"true"` // the mirroring of a primary node, if configured
+	PinControl                *pincontrol.Controller     `optional:"true"` // the pins ordered over pubsub, if enabled
+	KV                        *kv.Service                `optional:"true"` // the key-value stores replicated over pubsub, if enabled
 	Namesys                   namesys.NameSystem         // the name system, resolves paths to hashes
+	NameCache                 *namecache.Cache           `optional:"true"` // the cache of the resolved names, nil when disabled
 	Provider                  provider.System            // the value provider system
 	IpnsRepub                 *ipnsrp.Republisher        `optional:"true"`
 	ResourceManager           network.ResourceManager    `optional:"true"`
+	Proxy                     *libp2p.Proxy              `optional:"true"` // the proxy outbound connections are dialed through, nil when not configured
+	BandwidthLimiter          *bwlimit.Limiter           `optional:"true"` // the bandwidth caps of the streams
+	DHTHealth                 *dhthealth.Monitor         `optional:"true"` // the health of the routing tables and queries
 
 	PubSub   *pubsub.PubSub             `optional:"true"`
 	PSRouter *psrouter.PubsubValueStore `optional:"true"`
 
-	DHT       *ddht.DHT       `optional:"true"`
+	DHT       *libp2p.DHT     `optional:"true"`
 	DHTClient routing.Routing `name:"dhtc" optional:"true"`
 
 	P2P *p2p.P2P `optional:"true"`
@@ -120,10 +183,14 @@ type IpfsNode struct {
 	ctx     context.Context
 
 	stop func() error
+	ops  operations
 
 	// Flags
 	IsOnline bool `optional:"true"` // Online is set when networking is enabled.
 	IsDaemon bool `optional:"true"` // Daemon is set when running on a long-running daemon.
+
+	Contexts       Contexts             `optional:"true"` // the repo contexts opened by the daemon, see Config.Repos
+	SharedContexts *node.SharedContexts `optional:"true"` // the contexts served and reprovided by the node, nil offline
 }
 
 // Mounts defines what the node's mount state is. This should
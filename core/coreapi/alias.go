@@ -0,0 +1,70 @@
+package coreapi
+
+import (
+	"context"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/kubo/core/alias"
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type AliasAPI CoreAPI
+
+func (api *AliasAPI) Set(ctx context.Context, name string, p path.Path, opts ...caopts.AliasSetOption) error {
+	ctx, span := tracing.Span(ctx, "CoreAPI.AliasAPI", "Set", trace.WithAttributes(attribute.String("name", name), attribute.String("path", p.String())))
+	defer span.End()
+
+	settings, err := caopts.AliasSetOptions(opts...)
+	if err != nil {
+		return err
+	}
+	span.SetAttributes(attribute.Bool("force", settings.Force))
+
+	return api.aliases().Set(ctx, name, p, settings.Force)
+}
+
+func (api *AliasAPI) Get(ctx context.Context, name string) (path.Path, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.AliasAPI", "Get", trace.WithAttributes(attribute.String("name", name)))
+	defer span.End()
+
+	return api.aliases().Get(ctx, name)
+}
+
+func (api *AliasAPI) List(ctx context.Context) ([]coreiface.Alias, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.AliasAPI", "List")
+	defer span.End()
+
+	aliases, err := api.aliases().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]coreiface.Alias, len(aliases))
+	for i, a := range aliases {
+		out[i] = coreiface.Alias{Name: a.Name, Path: a.Path}
+	}
+	return out, nil
+}
+
+func (api *AliasAPI) Remove(ctx context.Context, name string) error {
+	ctx, span := tracing.Span(ctx, "CoreAPI.AliasAPI", "Remove", trace.WithAttributes(attribute.String("name", name)))
+	defer span.End()
+
+	return api.aliases().Remove(ctx, name)
+}
+
+func (api *AliasAPI) Expand(ctx context.Context, p string) (path.Path, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.AliasAPI", "Expand", trace.WithAttributes(attribute.String("path", p)))
+	defer span.End()
+
+	return api.aliases().Expand(ctx, p)
+}
+
+// aliases returns the aliases of the repo of the API, which is the repo of
+// the selected context, if any.
+func (api *AliasAPI) aliases() *alias.Store {
+	return alias.New(api.repo.Datastore())
+}
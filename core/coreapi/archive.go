@@ -0,0 +1,74 @@
+package coreapi
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+
+	"github.com/ipfs/boxo/files"
+)
+
+// archiveBufSize is the size of the buffer between the tar writer and the
+// reads of an archive.
+const archiveBufSize = 1 << 20
+
+// archiveFile is a tree streamed as a tar archive, gzip compressed unless
+// the compression is gzip.NoCompression. The archive is written as it is
+// read, by a goroutine reading the tree, so it is never buffered whole.
+type archiveFile struct {
+	r  *io.PipeReader
+	nd files.Node
+}
+
+func newArchiveFile(nd files.Node, name string, compression int) (files.File, error) {
+	pr, pw := io.Pipe()
+	bufw := bufio.NewWriterSize(pw, archiveBufSize)
+	var w io.Writer = bufw
+	var gzw *gzip.Writer
+	if compression != gzip.NoCompression {
+		var err error
+		if gzw, err = gzip.NewWriterLevel(bufw, compression); err != nil {
+			return nil, err
+		}
+		w = gzw
+	}
+	tw, err := files.NewTarWriter(w)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		err := tw.WriteFile(nd, name)
+		if err == nil {
+			err = tw.Close()
+		}
+		if err == nil && gzw != nil {
+			err = gzw.Close()
+		}
+		if err == nil {
+			err = bufw.Flush()
+		}
+		// a nil error closes the pipe with io.EOF
+		pw.CloseWithError(err)
+	}()
+	return &archiveFile{r: pr, nd: nd}, nil
+}
+
+func (f *archiveFile) Read(p []byte) (int, error) {
+	return f.r.Read(p)
+}
+
+// Close stops the writes of the archive, which fail on the closed pipe.
+func (f *archiveFile) Close() error {
+	f.r.Close()
+	return f.nd.Close()
+}
+
+func (f *archiveFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, errors.New("cannot seek an archive")
+}
+
+func (f *archiveFile) Size() (int64, error) {
+	return 0, errors.New("the size of an archive is unknown")
+}
@@ -0,0 +1,111 @@
+package coreapi
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	merkledag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/kubo/core/cachewarm"
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type CacheAPI CoreAPI
+
+func (api *CacheAPI) WarmFromLog(ctx context.Context, r io.Reader, opts ...caopts.CacheWarmOption) (coreiface.CacheWarmResult, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.CacheAPI", "WarmFromLog")
+	defer span.End()
+
+	settings, err := caopts.CacheWarmOptions(opts...)
+	if err != nil {
+		return coreiface.CacheWarmResult{}, err
+	}
+	span.SetAttributes(attribute.String("pinttl", settings.PinTTL.String()), attribute.Int("concurrency", settings.Concurrency), attribute.Int("limit", settings.Limit))
+
+	// a graceful shutdown waits for the warming to complete
+	ctx, done, err := api.startOperation(ctx)
+	if err != nil {
+		return coreiface.CacheWarmResult{}, err
+	}
+	defer done()
+
+	entries, err := cachewarm.ParseLog(r)
+	if err != nil {
+		return coreiface.CacheWarmResult{}, err
+	}
+	result := coreiface.CacheWarmResult{Paths: len(entries)}
+	if settings.Limit > 0 && len(entries) > settings.Limit {
+		entries = entries[:settings.Limit]
+	}
+	span.SetAttributes(attribute.Int("paths", result.Paths))
+
+	var lk sync.Mutex
+	var wg sync.WaitGroup
+	paths := make(chan path.Path)
+	for i := 0; i < settings.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range paths {
+				protected, err := api.warm(ctx, p, settings.PinTTL)
+				lk.Lock()
+				if err != nil {
+					log.Debugf("warming %s: %s", p, err)
+					result.Failures = append(result.Failures, coreiface.CacheWarmFailure{Path: p, Error: err.Error()})
+				} else {
+					result.Warmed++
+					if protected {
+						result.Protected++
+					}
+				}
+				lk.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, e := range entries {
+		select {
+		case paths <- e.Path:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(paths)
+	wg.Wait()
+
+	return result, ctx.Err()
+}
+
+// warm fetches the DAG of p, and protects it from garbage collection for ttl,
+// if set. It returns whether the DAG was protected.
+func (api *CacheAPI) warm(ctx context.Context, p path.Path, ttl time.Duration) (bool, error) {
+	// the DAG is protected before the garbage collection can run again
+	if ttl > 0 {
+		defer api.blockstore.PinLock(ctx).Unlock(ctx)
+	}
+
+	ses := api.core().getSession(ctx)
+	rp, _, err := ses.ResolvePath(ctx, p)
+	if err != nil {
+		return false, err
+	}
+	if err := merkledag.FetchGraph(ctx, rp.RootCid(), ses.dag); err != nil {
+		return false, err
+	}
+	if ttl <= 0 {
+		return false, nil
+	}
+
+	err = api.cacheWarm.Extend(ctx, rp.RootCid(), api.clock.Now().Add(ttl))
+	return err == nil, err
+}
+
+func (api *CacheAPI) core() *CoreAPI {
+	return (*CoreAPI)(api)
+}
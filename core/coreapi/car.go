@@ -0,0 +1,641 @@
+package coreapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/ipfs/go-cid"
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/tracing"
+	gocar "github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/ipld/go-car/v2/index"
+	dagpb "github.com/ipld/go-codec-dagpb"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	_ "github.com/ipld/go-ipld-prime/codec/dagjson"
+	_ "github.com/ipld/go-ipld-prime/codec/raw"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/linking"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/traversal"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	"github.com/multiformats/go-multicodec"
+	mh "github.com/multiformats/go-multihash"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// maxCarSectionSize bounds the size of the sections of inspected CARs,
+	// blocks are at most a few MiB.
+	maxCarSectionSize = 8 << 20
+	// maxCarErrors is the maximum number of malformed sections reported.
+	maxCarErrors = 100
+	// carV2HeaderSize is the size of the header of CARv2 files, after their
+	// pragma.
+	carV2HeaderSize = 40
+)
+
+type CarAPI CoreAPI
+
+// Inspect reads a CAR and describes it, without importing its blocks.
+func (api *CarAPI) Inspect(ctx context.Context, r io.Reader) (coreiface.CarInspection, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.CarAPI", "Inspect")
+	defer span.End()
+
+	in := &carInspector{
+		r:       &countingReader{r: bufio.NewReader(r)},
+		codecs:  make(map[string]uint64),
+		offsets: make(map[cid.Cid][]uint64),
+	}
+	err := in.inspect(ctx)
+	if err != nil && !errors.Is(err, errMalformedCar) {
+		return coreiface.CarInspection{}, err
+	}
+
+	res := in.res
+	for codec, n := range in.codecs {
+		res.Codecs = append(res.Codecs, coreiface.CarCodec{Codec: codec, Blocks: n})
+	}
+	sort.Slice(res.Codecs, func(i, j int) bool {
+		if res.Codecs[i].Blocks != res.Codecs[j].Blocks {
+			return res.Codecs[i].Blocks > res.Codecs[j].Blocks
+		}
+		return res.Codecs[i].Codec < res.Codecs[j].Codec
+	})
+	for _, root := range res.Roots {
+		if _, ok := in.offsets[root]; !ok {
+			res.MissingRoots = append(res.MissingRoots, root)
+		}
+	}
+	return res, nil
+}
+
+// errMalformedCar stops the inspection of a CAR that cannot be read further.
+var errMalformedCar = errors.New("malformed CAR")
+
+type countingReader struct {
+	r *bufio.Reader
+	n uint64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += uint64(n)
+	return n, err
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	b, err := cr.r.ReadByte()
+	if err == nil {
+		cr.n++
+	}
+	return b, err
+}
+
+type carInspector struct {
+	r   *countingReader
+	res coreiface.CarInspection
+
+	codecs map[string]uint64
+	// offsets are the offsets of the sections of the blocks, relative to
+	// the start of the CARv1 data, when not nil
+	offsets map[cid.Cid][]uint64
+
+	// strict makes all the malformed sections fatal
+	strict bool
+	// onRoots and onBlock, when set, are called with the roots and the
+	// valid blocks read
+	onRoots func(roots []cid.Cid) error
+	onBlock func(c cid.Cid, data []byte) error
+}
+
+// malformed records a malformed section. It returns errMalformedCar when
+// fatal, to stop the inspection.
+func (in *carInspector) malformed(offset uint64, fatal bool, format string, args ...interface{}) error {
+	if len(in.res.Errors) < maxCarErrors {
+		in.res.Errors = append(in.res.Errors, coreiface.CarError{Offset: offset, Error: fmt.Sprintf(format, args...)})
+	}
+	if fatal || in.strict {
+		return errMalformedCar
+	}
+	return nil
+}
+
+// readError turns the truncation of the CAR into a malformed section, and
+// returns the other errors of the reader as is.
+func (in *carInspector) readError(offset uint64, what string, err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return in.malformed(offset, true, "truncated %s", what)
+	}
+	return err
+}
+
+// section reads a section: its size, as a varint, and its content. It
+// returns io.EOF at the end of the CAR, or once end bytes were read when end
+// isn't 0.
+func (in *carInspector) section(end uint64) ([]byte, error) {
+	offset := in.r.n
+	if end > 0 && offset >= end {
+		return nil, io.EOF
+	}
+	size, err := binary.ReadUvarint(in.r)
+	if err != nil {
+		if err == io.EOF && in.r.n == offset && end == 0 {
+			return nil, io.EOF
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, in.malformed(offset, true, "truncated section size")
+		}
+		return nil, in.malformed(offset, true, "invalid section size: %s", err)
+	}
+	switch {
+	case size == 0:
+		return nil, in.malformed(offset, true, "zero-length section")
+	case size > maxCarSectionSize:
+		return nil, in.malformed(offset, true, "section of %d bytes, larger than the maximum of %d", size, maxCarSectionSize)
+	case end > 0 && in.r.n+size > end:
+		return nil, in.malformed(offset, true, "section of %d bytes past the end of the data", size)
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(in.r, buf); err != nil {
+		return nil, in.readError(offset, "section", err)
+	}
+	return buf, nil
+}
+
+// header reads the header of a CARv1, or the pragma of a CARv2.
+func (in *carInspector) header(end uint64) (version uint64, roots []cid.Cid, err error) {
+	offset := in.r.n
+	buf, err := in.section(end)
+	if err == io.EOF {
+		return 0, nil, in.malformed(offset, true, "empty CAR")
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := dagcbor.Decode(nb, bytes.NewReader(buf)); err != nil {
+		return 0, nil, in.malformed(offset, true, "invalid header: %s", err)
+	}
+	n := nb.Build()
+	vn, err := n.LookupByString("version")
+	if err != nil {
+		return 0, nil, in.malformed(offset, true, "invalid header: no version")
+	}
+	v, err := vn.AsInt()
+	if err != nil || v < 1 {
+		return 0, nil, in.malformed(offset, true, "invalid header: invalid version")
+	}
+	if v != 1 {
+		return uint64(v), nil, nil
+	}
+
+	rn, err := n.LookupByString("roots")
+	if err != nil {
+		return 1, nil, in.malformed(offset, true, "invalid header: no roots")
+	}
+	it := rn.ListIterator()
+	if it == nil {
+		return 1, nil, in.malformed(offset, true, "invalid header: the roots are not a list")
+	}
+	for !it.Done() {
+		_, item, err := it.Next()
+		if err != nil {
+			return 1, nil, in.malformed(offset, true, "invalid header: %s", err)
+		}
+		l, err := item.AsLink()
+		if err != nil {
+			return 1, nil, in.malformed(offset, true, "invalid header: a root is not a CID")
+		}
+		cl, ok := l.(cidlink.Link)
+		if !ok {
+			return 1, nil, in.malformed(offset, true, "invalid header: a root is not a CID")
+		}
+		roots = append(roots, cl.Cid)
+	}
+	return 1, roots, nil
+}
+
+// skipTo discards the bytes up to offset.
+func (in *carInspector) skipTo(offset uint64, what string) error {
+	if offset < in.r.n {
+		return in.malformed(in.r.n, true, "%s at offset %d overlaps the previous sections", what, offset)
+	}
+	if _, err := io.CopyN(io.Discard, in.r, int64(offset-in.r.n)); err != nil {
+		return in.readError(in.r.n, what, err)
+	}
+	return nil
+}
+
+func (in *carInspector) inspect(ctx context.Context) error {
+	version, roots, err := in.header(0)
+	if err != nil {
+		return err
+	}
+	in.res.Version = version
+
+	switch version {
+	case 1:
+		if err := in.roots(roots); err != nil {
+			return err
+		}
+		return in.blocks(ctx, 0, 0)
+	case 2:
+	default:
+		return in.malformed(0, true, "unsupported version %d", version)
+	}
+
+	offset := in.r.n
+	var header [carV2HeaderSize]byte
+	if _, err := io.ReadFull(in.r, header[:]); err != nil {
+		return in.readError(offset, "CARv2 header", err)
+	}
+	// the characteristics take the first 16 bytes
+	dataOffset := binary.LittleEndian.Uint64(header[16:])
+	dataSize := binary.LittleEndian.Uint64(header[24:])
+	indexOffset := binary.LittleEndian.Uint64(header[32:])
+	if err := in.skipTo(dataOffset, "data"); err != nil {
+		return err
+	}
+
+	dataEnd := dataOffset + dataSize
+	version, roots, err = in.header(dataEnd)
+	if err != nil {
+		return err
+	}
+	if version != 1 {
+		return in.malformed(dataOffset, true, "the data of the CARv2 is a CARv%d instead of a CARv1", version)
+	}
+	if err := in.roots(roots); err != nil {
+		return err
+	}
+	if err := in.blocks(ctx, dataOffset, dataEnd); err != nil {
+		return err
+	}
+	if in.r.n != dataEnd {
+		return in.malformed(in.r.n, true, "the data ends at offset %d instead of %d", in.r.n, dataEnd)
+	}
+
+	// transcoding writes a new index
+	if indexOffset == 0 || in.onBlock != nil {
+		return nil
+	}
+	in.res.HasIndex = true
+	if err := in.skipTo(indexOffset, "index"); err != nil {
+		return err
+	}
+	idx, err := index.ReadFrom(in.r)
+	if err != nil {
+		return in.malformed(indexOffset, false, "invalid index: %s", err)
+	}
+	in.res.IndexCodec = idx.Codec().String()
+	in.res.IndexValid = in.checkIndex(indexOffset, idx)
+	return nil
+}
+
+func (in *carInspector) roots(roots []cid.Cid) error {
+	in.res.Roots = roots
+	if in.onRoots != nil {
+		return in.onRoots(roots)
+	}
+	return nil
+}
+
+// blocks reads the sections of the blocks, up to end when it isn't 0.
+// Offsets are recorded relative to dataOffset.
+func (in *carInspector) blocks(ctx context.Context, dataOffset, end uint64) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		offset := in.r.n
+		buf, err := in.section(end)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		n, c, err := cid.CidFromBytes(buf)
+		if err != nil {
+			if err := in.malformed(offset, false, "invalid CID: %s", err); err != nil {
+				return err
+			}
+			continue
+		}
+		data := buf[n:]
+
+		in.res.Blocks++
+		in.res.Bytes += uint64(len(data))
+		in.codecs[multicodec.Code(c.Type()).String()]++
+		if in.offsets != nil {
+			in.offsets[c] = append(in.offsets[c], offset-dataOffset)
+		}
+
+		sum, err := c.Prefix().Sum(data)
+		if err != nil {
+			if err := in.malformed(offset, false, "cannot verify the hash of %s: %s", c, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if !sum.Equals(c) {
+			if err := in.malformed(offset, false, "the data of %s doesn't match its hash", c); err != nil {
+				return err
+			}
+			continue
+		}
+		if in.onBlock != nil {
+			if err := in.onBlock(c, data); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// checkIndex checks that all the blocks can be found at their offset with the
+// index, except for identity CIDs, which indexes usually skip.
+func (in *carInspector) checkIndex(indexOffset uint64, idx index.Index) bool {
+	valid := true
+	for c, offsets := range in.offsets {
+		if c.Prefix().MhType == mh.IDENTITY {
+			continue
+		}
+		// indexes may keep a single offset for duplicated blocks
+		var ok bool
+		_ = idx.GetAll(c, func(o uint64) bool {
+			for _, offset := range offsets {
+				ok = ok || o == offset
+			}
+			return !ok
+		})
+		if !ok {
+			valid = false
+			_ = in.malformed(indexOffset, false, "the index doesn't locate %s", c)
+		}
+	}
+	return valid
+}
+
+// Transcode reads a CAR and writes it as a CARv1, or as a CARv2 with a new
+// index, filtering its blocks.
+func (api *CarAPI) Transcode(ctx context.Context, r io.Reader, w io.Writer, opts ...caopts.CarTranscodeOption) error {
+	ctx, span := tracing.Span(ctx, "CoreAPI.CarAPI", "Transcode")
+	defer span.End()
+
+	settings, err := caopts.CarTranscodeOptions(opts...)
+	if err != nil {
+		return err
+	}
+	if settings.Version != 1 && settings.Version != 2 {
+		return fmt.Errorf("unsupported CAR version %d", settings.Version)
+	}
+	span.SetAttributes(
+		attribute.Int64("version", int64(settings.Version)),
+		attribute.Bool("dedupe", settings.Dedupe),
+		attribute.Bool("selector", settings.Selector != nil),
+	)
+
+	var sel selector.Selector
+	if settings.Selector != nil {
+		if sel, err = selector.CompileSelector(settings.Selector); err != nil {
+			return fmt.Errorf("invalid selector: %w", err)
+		}
+	}
+
+	// the header of CARv2 files holds the size of their data, which is
+	// spooled to a temporary file until it is known
+	out := &carOutput{dedupe: settings.Dedupe, index: settings.Version == 2}
+	var data *os.File
+	if settings.Version == 2 {
+		if data, err = createCarSpool(); err != nil {
+			return err
+		}
+		defer removeCarSpool(data)
+		out.w = bufio.NewWriter(data)
+	} else {
+		out.w = bufio.NewWriter(w)
+	}
+
+	in := &carInspector{
+		r:      &countingReader{r: bufio.NewReader(r)},
+		codecs: make(map[string]uint64),
+		strict: true,
+	}
+	if sel == nil {
+		in.onRoots, in.onBlock = out.header, out.block
+		err = in.inspect(ctx)
+	} else {
+		err = transcodeSelected(ctx, in, sel, out)
+	}
+	if errors.Is(err, errMalformedCar) && len(in.res.Errors) > 0 {
+		e := in.res.Errors[len(in.res.Errors)-1]
+		return fmt.Errorf("malformed CAR at offset %d: %s", e.Offset, e.Error)
+	}
+	if err != nil {
+		return err
+	}
+	if err := out.w.Flush(); err != nil {
+		return err
+	}
+
+	if settings.Version == 1 {
+		return nil
+	}
+	return out.writeV2(w, data)
+}
+
+func createCarSpool() (*os.File, error) {
+	return os.CreateTemp("", "ipfs-car-transcode-*")
+}
+
+func removeCarSpool(f *os.File) {
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
+
+// carOutput writes the CARv1 data of transcoded CARs.
+type carOutput struct {
+	w *bufio.Writer
+	// n is the number of bytes written
+	n uint64
+
+	dedupe bool
+	seen   *cid.Set
+
+	// index is true to record the offsets of the blocks, for CARv2 files
+	index   bool
+	records []index.Record
+}
+
+func (out *carOutput) Write(p []byte) (int, error) {
+	n, err := out.w.Write(p)
+	out.n += uint64(n)
+	return n, err
+}
+
+func (out *carOutput) header(roots []cid.Cid) error {
+	return gocar.WriteHeader(&gocar.CarHeader{Roots: roots, Version: 1}, out)
+}
+
+func (out *carOutput) block(c cid.Cid, data []byte) error {
+	if out.dedupe {
+		if out.seen == nil {
+			out.seen = cid.NewSet()
+		}
+		if !out.seen.Visit(c) {
+			return nil
+		}
+	}
+	// like go-car, identity CIDs are left out of the index
+	if out.index && c.Prefix().MhType != mh.IDENTITY {
+		out.records = append(out.records, index.Record{Cid: c, Offset: out.n})
+	}
+	return carutil.LdWrite(out, c.Bytes(), data)
+}
+
+// writeV2 writes a CARv2 with the CARv1 data spooled to data, followed by its
+// index.
+func (out *carOutput) writeV2(w io.Writer, data *os.File) error {
+	idx, err := index.New(multicodec.CarMultihashIndexSorted)
+	if err != nil {
+		return err
+	}
+	if err := idx.Load(out.records); err != nil {
+		return err
+	}
+	if _, err := data.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(carv2.Pragma); err != nil {
+		return err
+	}
+	if _, err := carv2.NewHeader(out.n).WriteTo(bw); err != nil {
+		return err
+	}
+	if _, err := io.Copy(bw, data); err != nil {
+		return err
+	}
+	if _, err := index.WriteTo(idx, bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// transcodeSelected spools the blocks of the CAR read by in to a temporary
+// file, then writes to out the blocks matched by sel from the roots, once
+// each, in traversal order.
+func transcodeSelected(ctx context.Context, in *carInspector, sel selector.Selector, out *carOutput) error {
+	f, err := createCarSpool()
+	if err != nil {
+		return err
+	}
+	defer removeCarSpool(f)
+
+	type location struct {
+		offset, size uint64
+	}
+	spool := &carOutput{w: bufio.NewWriter(f)}
+	locations := make(map[cid.Cid]location)
+	var roots []cid.Cid
+	in.onRoots = func(r []cid.Cid) error {
+		roots = r
+		return nil
+	}
+	in.onBlock = func(c cid.Cid, data []byte) error {
+		if _, ok := locations[c]; ok {
+			return nil
+		}
+		locations[c] = location{offset: spool.n, size: uint64(len(data))}
+		_, err := spool.Write(data)
+		return err
+	}
+	if err := in.inspect(ctx); err != nil {
+		return err
+	}
+	if err := spool.w.Flush(); err != nil {
+		return err
+	}
+
+	var selected []cid.Cid
+	visited := cid.NewSet()
+	lsys := cidlink.DefaultLinkSystem()
+	// the hashes were verified while spooling
+	lsys.TrustedStorage = true
+	lsys.StorageReadOpener = func(_ linking.LinkContext, l datamodel.Link) (io.Reader, error) {
+		cl, ok := l.(cidlink.Link)
+		if !ok {
+			return nil, fmt.Errorf("unsupported link %s", l)
+		}
+		if cl.Cid.Prefix().MhType == mh.IDENTITY {
+			dmh, err := mh.Decode(cl.Cid.Hash())
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(dmh.Digest), nil
+		}
+		loc, ok := locations[cl.Cid]
+		if !ok {
+			return nil, fmt.Errorf("block %s is not in the CAR", cl.Cid)
+		}
+		if visited.Visit(cl.Cid) {
+			selected = append(selected, cl.Cid)
+		}
+		return io.NewSectionReader(f, int64(loc.offset), int64(loc.size)), nil
+	}
+
+	chooser := dagpb.AddSupportToChooser(basicnode.Chooser)
+	for _, root := range roots {
+		lctx := linking.LinkContext{Ctx: ctx}
+		lnk := cidlink.Link{Cid: root}
+		np, err := chooser(lnk, lctx)
+		if err != nil {
+			return err
+		}
+		nd, err := lsys.Load(lctx, lnk, np)
+		if err != nil {
+			return fmt.Errorf("loading root %s: %w", root, err)
+		}
+		prog := traversal.Progress{Cfg: &traversal.Config{
+			Ctx:                            ctx,
+			LinkSystem:                     lsys,
+			LinkTargetNodePrototypeChooser: chooser,
+			LinkVisitOnlyOnce:              true,
+		}}
+		if err := prog.WalkAdv(nd, sel, func(traversal.Progress, datamodel.Node, traversal.VisitReason) error {
+			return nil
+		}); err != nil {
+			return fmt.Errorf("traversing %s: %w", root, err)
+		}
+	}
+
+	if err := out.header(roots); err != nil {
+		return err
+	}
+	for _, c := range selected {
+		loc := locations[c]
+		data := make([]byte, loc.size)
+		if _, err := f.ReadAt(data, int64(loc.offset)); err != nil {
+			return err
+		}
+		if err := out.block(c, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
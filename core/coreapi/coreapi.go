@@ -15,17 +15,20 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/benbjohnson/clock"
 	bserv "github.com/ipfs/boxo/blockservice"
 	blockstore "github.com/ipfs/boxo/blockstore"
 	exchange "github.com/ipfs/boxo/exchange"
 	offlinexch "github.com/ipfs/boxo/exchange/offline"
 	"github.com/ipfs/boxo/fetcher"
 	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/mfs"
 	pathresolver "github.com/ipfs/boxo/path/resolver"
 	pin "github.com/ipfs/boxo/pinning/pinner"
 	provider "github.com/ipfs/boxo/provider"
 	offlineroute "github.com/ipfs/boxo/routing/offline"
 	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
 	"github.com/ipfs/kubo/config"
 	coreiface "github.com/ipfs/kubo/core/coreiface"
 	"github.com/ipfs/kubo/core/coreiface/options"
@@ -40,12 +43,31 @@ import (
 
 	"github.com/ipfs/boxo/namesys"
 	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/cachewarm"
+	"github.com/ipfs/kubo/core/connevents"
+	"github.com/ipfs/kubo/core/contentindex"
+	"github.com/ipfs/kubo/core/fulltext"
+	"github.com/ipfs/kubo/core/kv"
+	"github.com/ipfs/kubo/core/mfsexpiry"
+	"github.com/ipfs/kubo/core/mfsflush"
+	"github.com/ipfs/kubo/core/mimetypes"
 	"github.com/ipfs/kubo/core/node"
+	"github.com/ipfs/kubo/core/pinqueue"
+	"github.com/ipfs/kubo/core/pinreport"
+	"github.com/ipfs/kubo/core/pinsync"
+	"github.com/ipfs/kubo/core/provideclass"
+	"github.com/ipfs/kubo/core/replicate"
+	"github.com/ipfs/kubo/core/standby"
+	"github.com/ipfs/kubo/core/webhooks"
+	"github.com/ipfs/kubo/p2p"
 	"github.com/ipfs/kubo/repo"
 )
 
+var log = logging.Logger("coreapi")
+
 type CoreAPI struct {
-	nctx context.Context
+	nctx  context.Context
+	clock clock.Clock
 
 	identity   peer.ID
 	privateKey ci.PrivKey
@@ -54,6 +76,34 @@ type CoreAPI struct {
 	blockstore blockstore.GCBlockstore
 	baseBlocks blockstore.Blockstore
 	pinning    pin.Pinner
+	pinQueue   *pinqueue.Queue
+	// provideClasses are the provide classes of the pins
+	provideClasses *provideclass.Scheduler
+	// contentIndex is nil when Experimental.ContentIndex is disabled
+	contentIndex *contentindex.Index
+	// fullText is nil when Experimental.FullTextSearch is disabled
+	fullText  *fulltext.Indexer
+	mimeTypes *mimetypes.Cache
+	cacheWarm *cachewarm.Store
+
+	filesRoot  *mfs.Root
+	mfsExpiry  *mfsexpiry.Reaper
+	mfsFlusher *mfsflush.Flusher
+
+	// blockSources is nil on offline nodes
+	blockSources *pinreport.Recorder
+	// pinSync is nil on offline nodes, and when
+	// Experimental.PinsetReconciliation is disabled
+	pinSync *pinsync.Service
+	// replicate is nil on offline nodes, and when Experimental.PinReplication
+	// is disabled
+	replicate *replicate.Service
+	// standby is nil on offline nodes, and when Standby is not configured
+	standby *standby.Service
+	// webhooks is nil when Webhooks.Endpoints is empty
+	webhooks *webhooks.Notifier
+	// kv is nil when Experimental.KVStore is disabled
+	kv *kv.Service
 
 	blocks               bserv.BlockService
 	dag                  ipld.DAGService
@@ -74,8 +124,14 @@ type CoreAPI struct {
 
 	pubSub *pubsub.PubSub
 
+	// p2p is nil on offline nodes
+	p2p *p2p.P2P
+	// connEvents is nil on offline nodes
+	connEvents *connevents.Tracker
+
 	checkPublishAllowed func() error
 	checkOnline         func(allowOffline bool) error
+	startOperation      func(ctx context.Context) (context.Context, func(), error)
 
 	// ONLY for re-applying options in WithOptions, DO NOT USE ANYWHERE ELSE
 	nd         *core.IpfsNode
@@ -146,11 +202,61 @@ func (api *CoreAPI) PubSub() coreiface.PubSubAPI {
 	return (*PubSubAPI)(api)
 }
 
+// P2P returns the P2PAPI interface implementation backed by the kubo node
+func (api *CoreAPI) P2P() coreiface.P2PAPI {
+	return (*P2PAPI)(api)
+}
+
 // Routing returns the RoutingAPI interface implementation backed by the kubo node
 func (api *CoreAPI) Routing() coreiface.RoutingAPI {
 	return (*RoutingAPI)(api)
 }
 
+// Search returns the SearchAPI interface implementation backed by the kubo node
+func (api *CoreAPI) Search() coreiface.SearchAPI {
+	return (*SearchAPI)(api)
+}
+
+// Car returns the CarAPI interface implementation backed by the kubo node
+func (api *CoreAPI) Car() coreiface.CarAPI {
+	return (*CarAPI)(api)
+}
+
+// Alias returns the AliasAPI interface implementation backed by the kubo node
+func (api *CoreAPI) Alias() coreiface.AliasAPI {
+	return (*AliasAPI)(api)
+}
+
+// Cache returns the CacheAPI interface implementation backed by the kubo node
+func (api *CoreAPI) Cache() coreiface.CacheAPI {
+	return (*CacheAPI)(api)
+}
+
+// Repo returns the RepoAPI interface implementation backed by the kubo node
+func (api *CoreAPI) Repo() coreiface.RepoAPI {
+	return (*RepoAPI)(api)
+}
+
+// KV returns the KVAPI interface implementation backed by the kubo node
+func (api *CoreAPI) KV() coreiface.KVAPI {
+	return (*KVAPI)(api)
+}
+
+// Replicate returns the ReplicateAPI interface implementation backed by the kubo node
+func (api *CoreAPI) Replicate() coreiface.ReplicateAPI {
+	return (*ReplicateAPI)(api)
+}
+
+// Standby returns the StandbyAPI interface implementation backed by the kubo node
+func (api *CoreAPI) Standby() coreiface.StandbyAPI {
+	return (*StandbyAPI)(api)
+}
+
+// Export returns the ExportAPI interface implementation backed by the kubo node
+func (api *CoreAPI) Export() coreiface.ExportAPI {
+	return (*ExportAPI)(api)
+}
+
 // WithOptions returns api with global options applied
 func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, error) {
 	settings := api.parentOpts // make sure to copy
@@ -164,9 +270,18 @@ func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, e
 	}
 
 	n := api.nd
+	var host *core.IpfsNode
+	if settings.Context != "" {
+		rc, err := n.Contexts.Get(settings.Context)
+		if err != nil {
+			return nil, err
+		}
+		n, host = rc.Node, rc.Host
+	}
 
 	subAPI := &CoreAPI{
-		nctx: n.Context(),
+		nctx:  n.Context(),
+		clock: n.Clock,
 
 		identity:   n.Identity,
 		privateKey: n.PrivateKey,
@@ -175,6 +290,23 @@ func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, e
 		blockstore: n.Blockstore,
 		baseBlocks: n.BaseBlocks,
 		pinning:    n.Pinning,
+		pinQueue:   n.PinQueue,
+
+		contentIndex:   n.ContentIndex,
+		fullText:       n.FullText,
+		mimeTypes:      n.MimeTypes,
+		cacheWarm:      n.CacheWarm,
+		filesRoot:      n.FilesRoot,
+		mfsExpiry:      n.MFSExpiry,
+		provideClasses: n.ProvideClasses,
+		mfsFlusher:     n.MFSFlusher,
+
+		blockSources: n.BlockSources,
+		pinSync:      n.PinSync,
+		replicate:    n.Replicate,
+		standby:      n.Standby,
+		webhooks:     n.Webhooks,
+		kv:           n.KV,
 
 		blocks:               n.Blocks,
 		dag:                  n.DAG,
@@ -193,19 +325,57 @@ func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, e
 
 		provider: n.Provider,
 
+		connEvents: n.ConnEvents,
+
 		pubSub: n.PubSub,
+		p2p:    n.P2P,
 
-		nd:         n,
+		nd:         api.nd,
 		parentOpts: settings,
 	}
 
+	online := n.IsOnline
+	if host != nil {
+		// the context shares the libp2p identity of the daemon: blocks and
+		// records are fetched, provided and published with the node of the
+		// daemon, which also serves the blocks of the context.
+		online = host.IsOnline
+
+		subAPI.peerstore = host.Peerstore
+		subAPI.peerHost = host.PeerHost
+		subAPI.exchange = host.Exchange
+		subAPI.routing = host.Routing
+		subAPI.blockSources = host.BlockSources
+		subAPI.connEvents = host.ConnEvents
+		subAPI.p2p = host.P2P
+		subAPI.pubSub = host.PubSub
+		subAPI.provider = host.Provider
+
+		subAPI.blocks = bserv.New(subAPI.blockstore, subAPI.exchange)
+		subAPI.dag = dag.NewDAGService(subAPI.blocks)
+		fetchers := node.FetcherConfig(subAPI.blocks)
+		subAPI.ipldFetcherFactory = fetchers.IPLDFetcher
+		subAPI.unixFSFetcherFactory = fetchers.UnixfsFetcher
+		subAPI.ipldPathResolver = pathresolver.NewBasicResolver(fetchers.IPLDFetcher)
+		subAPI.unixFSPathResolver = pathresolver.NewBasicResolver(fetchers.UnixfsFetcher)
+
+		if !settings.Offline {
+			subAPI.namesys, err = newNameSystem(n, subAPI.routing, subAPI.dnsResolver)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	subAPI.checkOnline = func(allowOffline bool) error {
-		if !n.IsOnline && !allowOffline {
+		if !online && !allowOffline {
 			return coreiface.ErrOffline
 		}
 		return nil
 	}
 
+	subAPI.startOperation = n.StartOperation
+
 	subAPI.checkPublishAllowed = func() error {
 		if n.Mounts.Ipns != nil && n.Mounts.Ipns.IsActive() {
 			return errors.New("cannot manually publish while IPNS is mounted")
@@ -214,31 +384,11 @@ func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, e
 	}
 
 	if settings.Offline {
-		cfg, err := n.Repo.Config()
-		if err != nil {
-			return nil, err
-		}
-
-		cs := cfg.Ipns.ResolveCacheSize
-		if cs == 0 {
-			cs = node.DefaultIpnsCacheSize
-		}
-		if cs < 0 {
-			return nil, fmt.Errorf("cannot specify negative resolve cache size")
-		}
-
-		nsOptions := []namesys.Option{
-			namesys.WithDatastore(subAPI.repo.Datastore()),
-			namesys.WithDNSResolver(subAPI.dnsResolver),
-			namesys.WithCache(cs),
-			namesys.WithMaxCacheTTL(cfg.Ipns.MaxCacheTTL.WithDefault(config.DefaultIpnsMaxCacheTTL)),
-		}
-
 		subAPI.routing = offlineroute.NewOfflineRouter(subAPI.repo.Datastore(), subAPI.recordValidator)
 
-		subAPI.namesys, err = namesys.NewNameSystem(subAPI.routing, nsOptions...)
+		subAPI.namesys, err = newNameSystem(n, subAPI.routing, subAPI.dnsResolver)
 		if err != nil {
-			return nil, fmt.Errorf("error constructing namesys: %w", err)
+			return nil, err
 		}
 
 		subAPI.provider = provider.NewNoopProvider()
@@ -246,6 +396,8 @@ func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, e
 		subAPI.peerstore = nil
 		subAPI.peerHost = nil
 		subAPI.recordValidator = nil
+		subAPI.p2p = nil
+		subAPI.connEvents = nil
 	}
 
 	if settings.Offline || !settings.FetchBlocks {
@@ -257,6 +409,34 @@ func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, e
 	return subAPI, nil
 }
 
+// newNameSystem returns a name system resolving and publishing the records of
+// the repo of n with r.
+func newNameSystem(n *core.IpfsNode, r routing.Routing, dns *madns.Resolver) (namesys.NameSystem, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	cs := cfg.Ipns.ResolveCacheSize
+	if cs == 0 {
+		cs = node.DefaultIpnsCacheSize
+	}
+	if cs < 0 {
+		return nil, fmt.Errorf("cannot specify negative resolve cache size")
+	}
+
+	ns, err := namesys.NewNameSystem(r,
+		namesys.WithDatastore(n.Repo.Datastore()),
+		namesys.WithDNSResolver(dns),
+		namesys.WithCache(cs),
+		namesys.WithMaxCacheTTL(cfg.Ipns.MaxCacheTTL.WithDefault(config.DefaultIpnsMaxCacheTTL)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing namesys: %w", err)
+	}
+	return ns, nil
+}
+
 // getSession returns new api backed by the same node with a read-only session DAG
 func (api *CoreAPI) getSession(ctx context.Context) *CoreAPI {
 	sesAPI := *api
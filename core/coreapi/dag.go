@@ -1,15 +1,33 @@
 package coreapi
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 
+	bsclient "github.com/ipfs/boxo/bitswap/client"
+	bsnet "github.com/ipfs/boxo/bitswap/network"
+	"github.com/ipfs/boxo/blockservice"
+	offlinexch "github.com/ipfs/boxo/exchange/offline"
 	dag "github.com/ipfs/boxo/ipld/merkledag"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
 	pin "github.com/ipfs/boxo/pinning/pinner"
 	cid "github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
 	"github.com/ipfs/kubo/tracing"
 )
 
@@ -68,6 +86,285 @@ func (api *dagAPI) Session(ctx context.Context) ipld.NodeGetter {
 	return dag.NewSession(ctx, api.DAGService)
 }
 
+func (api *dagAPI) FetchFrom(ctx context.Context, root cid.Cid, from peer.AddrInfo, opts ...caopts.DagFetchFromOption) (coreiface.DagFetchStats, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.DagAPI", "FetchFrom", trace.WithAttributes(attribute.String("root", root.String()), attribute.String("peer", from.ID.String())))
+	defer span.End()
+
+	var stats coreiface.DagFetchStats
+
+	settings, err := caopts.DagFetchFromOptions(opts...)
+	if err != nil {
+		return stats, err
+	}
+	span.SetAttributes(attribute.Bool("pin", settings.Pin))
+
+	if err := api.core.checkOnline(false); err != nil {
+		return stats, err
+	}
+	if len(from.Addrs) == 0 {
+		return stats, fmt.Errorf("no addresses given for peer %s", from.ID)
+	}
+
+	if settings.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, settings.Timeout)
+		defer cancel()
+	}
+
+	// The node's own bitswap asks every connected peer and falls back to
+	// content routing, so the fetch runs on a short lived host with its own
+	// bitswap client that only knows about the given peer.
+	hostOpts := []libp2p.Option{
+		libp2p.NoListenAddrs,
+		libp2p.DisableRelay(),
+	}
+	swarmkey, err := api.core.repo.SwarmKey()
+	if err != nil {
+		return stats, err
+	}
+	if swarmkey != nil {
+		psk, err := pnet.DecodeV1PSK(bytes.NewReader(swarmkey))
+		if err != nil {
+			return stats, fmt.Errorf("failed to configure private network: %s", err)
+		}
+		// only TCP supports private networks
+		hostOpts = append(hostOpts, libp2p.PrivateNetwork(psk), libp2p.Transport(tcp.NewTCPTransport))
+	}
+	h, err := libp2p.New(hostOpts...)
+	if err != nil {
+		return stats, err
+	}
+	defer h.Close()
+
+	// bitswap learns about the peers from the connection notifications, so
+	// it is started before connecting
+	net := bsnet.NewFromIpfsHost(h, singlePeerRouting(from.ID))
+	bs := bsclient.New(ctx, net, api.core.blockstore)
+	net.Start(bs)
+	defer net.Stop()
+	defer bs.Close()
+
+	if err := h.Connect(ctx, from); err != nil {
+		return stats, fmt.Errorf("failed to connect to %s: %w", from.ID, err)
+	}
+
+	// keep the GC from collecting fetched blocks before they are pinned
+	defer api.core.blockstore.PinLock(ctx).Unlock(ctx)
+
+	// bitswap only accepts blocks matching the CID they were requested by
+	dserv := dag.NewDAGService(blockservice.New(api.core.blockstore, bs))
+
+	var (
+		blocks, size atomic.Uint64
+		visitLk      sync.Mutex
+		visited      = cid.NewSet()
+	)
+	getLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+		nd, err := dserv.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		blocks.Add(1)
+		size.Add(uint64(len(nd.RawData())))
+		return nd.Links(), nil
+	}
+	visit := func(c cid.Cid) bool {
+		visitLk.Lock()
+		defer visitLk.Unlock()
+		return visited.Visit(c)
+	}
+	err = dag.Walk(ctx, getLinks, root, visit, dag.Concurrency(32))
+	stats.Blocks, stats.Bytes = blocks.Load(), size.Load()
+	if err != nil {
+		return stats, err
+	}
+
+	if settings.Pin {
+		if err := api.core.pinning.PinWithMode(ctx, root, pin.Recursive, ""); err != nil {
+			return stats, err
+		}
+		if err := api.core.pinning.Flush(ctx); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// singlePeerRouting is a bitswap content router that knows about a single
+// provider and never announces anything.
+type singlePeerRouting peer.ID
+
+func (r singlePeerRouting) FindProvidersAsync(ctx context.Context, _ cid.Cid, _ int) <-chan peer.AddrInfo {
+	out := make(chan peer.AddrInfo, 1)
+	out <- peer.AddrInfo{ID: peer.ID(r)}
+	close(out)
+	return out
+}
+
+func (singlePeerRouting) Provide(context.Context, cid.Cid, bool) error {
+	return nil
+}
+
+func (api *dagAPI) Prune(ctx context.Context, root cid.Cid, rel string) (coreiface.DagPruneResult, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.DagAPI", "Prune", trace.WithAttributes(attribute.String("root", root.String()), attribute.String("path", rel)))
+	defer span.End()
+
+	var res coreiface.DagPruneResult
+
+	segments := strings.FieldsFunc(rel, func(r rune) bool { return r == '/' })
+	if len(segments) == 0 {
+		return res, errors.New("nothing to prune: the path is empty")
+	}
+
+	// keep the GC away while the pin moves to the new root, and only work
+	// with the local blocks
+	defer api.core.blockstore.PinLock(ctx).Unlock(ctx)
+	dserv := dag.NewDAGService(blockservice.New(api.core.blockstore, offlinexch.Exchange(api.core.blockstore)))
+
+	before, err := dagBlocks(ctx, dserv, root)
+	if err != nil {
+		return res, fmt.Errorf("the DAG of %s is not complete locally: %w", root, err)
+	}
+
+	nd, err := dserv.Get(ctx, root)
+	if err != nil {
+		return res, err
+	}
+	pruned, err := pruneNode(ctx, dserv, nd, segments)
+	if err != nil {
+		return res, err
+	}
+	res.Root = pruned.Cid()
+
+	_, pinned, err := api.core.pinning.IsPinnedWithType(ctx, root, pin.Recursive)
+	if err != nil {
+		return res, err
+	}
+	if pinned {
+		if err := api.core.pinning.Update(ctx, root, res.Root, true); err != nil {
+			return res, err
+		}
+		if err := api.core.pinning.Flush(ctx); err != nil {
+			return res, err
+		}
+	}
+
+	after, err := dagBlocks(ctx, dserv, res.Root)
+	if err != nil {
+		return res, err
+	}
+	var gone []cid.Cid
+	for c := range before {
+		if _, ok := after[c]; !ok {
+			gone = append(gone, c)
+		}
+	}
+	// the blocks may still be pinned through other DAGs
+	pins, err := api.core.pinning.CheckIfPinned(ctx, gone...)
+	if err != nil {
+		return res, err
+	}
+	for _, p := range pins {
+		if !p.Pinned() {
+			res.Blocks++
+			res.Bytes += before[p.Key]
+		}
+	}
+	return res, nil
+}
+
+// pruneNode returns a copy of nd without the subtree at the path segments,
+// with the modified nodes stored in dserv.
+func pruneNode(ctx context.Context, dserv ipld.DAGService, nd ipld.Node, segments []string) (ipld.Node, error) {
+	name := segments[0]
+
+	if dir, err := uio.NewDirectoryFromNode(dserv, nd); err == nil {
+		if len(segments) == 1 {
+			if err := dir.RemoveChild(ctx, name); err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil, fmt.Errorf("no link named %q under %s", name, nd.Cid())
+				}
+				return nil, err
+			}
+		} else {
+			child, err := dir.Find(ctx, name)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil, fmt.Errorf("no link named %q under %s", name, nd.Cid())
+				}
+				return nil, err
+			}
+			child, err = pruneNode(ctx, dserv, child, segments[1:])
+			if err != nil {
+				return nil, err
+			}
+			if err := dir.AddChild(ctx, name, child); err != nil {
+				return nil, err
+			}
+		}
+		out, err := dir.GetNode()
+		if err != nil {
+			return nil, err
+		}
+		return out, dserv.Add(ctx, out)
+	}
+
+	// dag-pb nodes that are not UnixFS directories, such as the ones built
+	// with 'ipfs object patch'
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return nil, fmt.Errorf("cannot prune %s: only dag-pb nodes have named links", nd.Cid())
+	}
+	pn = pn.Copy().(*dag.ProtoNode)
+	if len(segments) == 1 {
+		if err := pn.RemoveNodeLink(name); err != nil {
+			return nil, fmt.Errorf("no link named %q under %s", name, nd.Cid())
+		}
+	} else {
+		child, err := pn.GetLinkedNode(ctx, dserv, name)
+		if err != nil {
+			return nil, err
+		}
+		child, err = pruneNode(ctx, dserv, child, segments[1:])
+		if err != nil {
+			return nil, err
+		}
+		if err := pn.RemoveNodeLink(name); err != nil {
+			return nil, err
+		}
+		if err := pn.AddNodeLink(name, child); err != nil {
+			return nil, err
+		}
+	}
+	return pn, dserv.Add(ctx, pn)
+}
+
+// dagBlocks returns the sizes of the blocks of the DAG under root.
+func dagBlocks(ctx context.Context, dserv ipld.DAGService, root cid.Cid) (map[cid.Cid]uint64, error) {
+	var lk sync.Mutex
+	blocks := make(map[cid.Cid]uint64)
+	getLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+		nd, err := dserv.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		lk.Lock()
+		blocks[c] = uint64(len(nd.RawData()))
+		lk.Unlock()
+		return nd.Links(), nil
+	}
+	visited := cid.NewSet()
+	visit := func(c cid.Cid) bool {
+		lk.Lock()
+		defer lk.Unlock()
+		return visited.Visit(c)
+	}
+	if err := dag.Walk(ctx, getLinks, root, visit, dag.Concurrency(32)); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
 var (
 	_ ipld.DAGService  = (*dagAPI)(nil)
 	_ dag.SessionMaker = (*dagAPI)(nil)
@@ -0,0 +1,164 @@
+package coreapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	bserv "github.com/ipfs/boxo/blockservice"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/go-cid"
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/ipfs/kubo/tracing"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	mh "github.com/multiformats/go-multihash"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ExportAPI CoreAPI
+
+// Dataset writes the pins named label and their blocks to w as a CARv2,
+// rooted at a manifest describing them.
+func (api *ExportAPI) Dataset(ctx context.Context, label string, w io.Writer) (coreiface.DatasetManifest, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.ExportAPI", "Dataset", trace.WithAttributes(attribute.String("label", label)))
+	defer span.End()
+
+	// garbage collection waits for the export, so that the blocks of the
+	// pins listed stay in the blockstore
+	defer api.blockstore.PinLock(ctx).Unlock(ctx)
+
+	manifest := coreiface.DatasetManifest{Label: label, Created: time.Now().UTC().Truncate(time.Second)}
+	for _, recursive := range []bool{true, false} {
+		keys := api.pinning.DirectKeys(ctx, true)
+		if recursive {
+			keys = api.pinning.RecursiveKeys(ctx, true)
+		}
+		for p := range keys {
+			if p.Err != nil {
+				return coreiface.DatasetManifest{}, p.Err
+			}
+			if p.Pin.Name == label {
+				manifest.Pins = append(manifest.Pins, coreiface.DatasetPin{Name: p.Pin.Name, Root: p.Pin.Key, Recursive: recursive})
+			}
+		}
+	}
+	if len(manifest.Pins) == 0 {
+		return coreiface.DatasetManifest{}, fmt.Errorf("no pin is named %q", label)
+	}
+	span.SetAttributes(attribute.Int("pins", len(manifest.Pins)))
+
+	classes, err := api.provideClasses.List(ctx)
+	if err != nil {
+		return coreiface.DatasetManifest{}, err
+	}
+	for _, e := range classes {
+		for i := range manifest.Pins {
+			if manifest.Pins[i].Root.Equals(e.Cid) {
+				manifest.Pins[i].ProvideClass = e.Class
+			}
+		}
+	}
+
+	root, data, err := encodeDatasetManifest(manifest)
+	if err != nil {
+		return coreiface.DatasetManifest{}, err
+	}
+
+	f, err := createCarSpool()
+	if err != nil {
+		return coreiface.DatasetManifest{}, err
+	}
+	defer removeCarSpool(f)
+	out := &carOutput{w: bufio.NewWriter(f), dedupe: true, index: true}
+	if err := out.header([]cid.Cid{root}); err != nil {
+		return coreiface.DatasetManifest{}, err
+	}
+	if err := out.block(root, data); err != nil {
+		return coreiface.DatasetManifest{}, err
+	}
+
+	// the pinned blocks are local, they are never fetched
+	dag := merkledag.NewDAGService(bserv.New(api.blockstore, offline.Exchange(api.blockstore)))
+	visited := cid.NewSet()
+	for _, p := range manifest.Pins {
+		if !p.Recursive {
+			if visited.Visit(p.Root) {
+				if err := api.exportBlock(ctx, out, p.Root); err != nil {
+					return coreiface.DatasetManifest{}, err
+				}
+			}
+			continue
+		}
+		var werr error
+		err := merkledag.Walk(ctx, merkledag.GetLinksWithDAG(dag), p.Root, func(c cid.Cid) bool {
+			if werr != nil || !visited.Visit(c) {
+				return false
+			}
+			werr = api.exportBlock(ctx, out, c)
+			return werr == nil
+		})
+		if werr != nil {
+			err = werr
+		}
+		if err != nil {
+			return coreiface.DatasetManifest{}, fmt.Errorf("exporting %s: %w", p.Root, err)
+		}
+	}
+	if err := out.w.Flush(); err != nil {
+		return coreiface.DatasetManifest{}, err
+	}
+	span.SetAttributes(attribute.Int("blocks", len(out.records)))
+
+	if err := out.writeV2(w, f); err != nil {
+		return coreiface.DatasetManifest{}, err
+	}
+	return manifest, nil
+}
+
+func (api *ExportAPI) exportBlock(ctx context.Context, out *carOutput, c cid.Cid) error {
+	blk, err := api.blockstore.Get(ctx, c)
+	if err != nil {
+		return err
+	}
+	return out.block(c, blk.RawData())
+}
+
+// encodeDatasetManifest encodes the manifest as a dag-json block, returning
+// its CID and data.
+func encodeDatasetManifest(m coreiface.DatasetManifest) (cid.Cid, []byte, error) {
+	nd, err := qp.BuildMap(basicnode.Prototype.Any, 3, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "label", qp.String(m.Label))
+		qp.MapEntry(ma, "created", qp.String(m.Created.Format(time.RFC3339)))
+		qp.MapEntry(ma, "pins", qp.List(int64(len(m.Pins)), func(la datamodel.ListAssembler) {
+			for _, p := range m.Pins {
+				qp.ListEntry(la, qp.Map(4, func(ma datamodel.MapAssembler) {
+					qp.MapEntry(ma, "name", qp.String(p.Name))
+					qp.MapEntry(ma, "root", qp.Link(cidlink.Link{Cid: p.Root}))
+					qp.MapEntry(ma, "recursive", qp.Bool(p.Recursive))
+					qp.MapEntry(ma, "provideClass", qp.String(p.ProvideClass))
+				}))
+			}
+		}))
+	})
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	var buf bytes.Buffer
+	if err := dagjson.Encode(nd, &buf); err != nil {
+		return cid.Undef, nil, err
+	}
+	c, err := cid.Prefix{Version: 1, Codec: cid.DagJSON, MhType: mh.SHA2_256, MhLength: -1}.Sum(buf.Bytes())
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	return c, buf.Bytes(), nil
+}
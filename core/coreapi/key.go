@@ -11,6 +11,7 @@ import (
 	"github.com/ipfs/boxo/path"
 	coreiface "github.com/ipfs/kubo/core/coreiface"
 	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/core/keyderiv"
 	"github.com/ipfs/kubo/tracing"
 	crypto "github.com/libp2p/go-libp2p/core/crypto"
 	peer "github.com/libp2p/go-libp2p/core/peer"
@@ -21,9 +22,10 @@ import (
 type KeyAPI CoreAPI
 
 type key struct {
-	name   string
-	peerID peer.ID
-	path   path.Path
+	name           string
+	peerID         peer.ID
+	path           path.Path
+	derivationPath string
 }
 
 func newKey(name string, pid peer.ID) (*key, error) {
@@ -53,6 +55,11 @@ func (k *key) ID() peer.ID {
 	return k.peerID
 }
 
+// DerivationPath returns the path the key was derived along, if any.
+func (k *key) DerivationPath() string {
+	return k.derivationPath
+}
+
 // Generate generates new key, stores it in the keystore under the specified
 // name and returns a base58 encoded multihash of its public key.
 func (api *KeyAPI) Generate(ctx context.Context, name string, opts ...caopts.KeyGenerateOption) (coreiface.Key, error) {
@@ -114,9 +121,63 @@ func (api *KeyAPI) Generate(ctx context.Context, name string, opts ...caopts.Key
 	return newKey(name, pid)
 }
 
+// Derive derives an ed25519 key from a seed phrase, stores it in the keystore
+// under the specified name and records its derivation path.
+func (api *KeyAPI) Derive(ctx context.Context, name string, phrase string, opts ...caopts.KeyDeriveOption) (coreiface.Key, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.KeyAPI", "Derive", trace.WithAttributes(attribute.String("name", name)))
+	defer span.End()
+
+	options, err := caopts.KeyDeriveOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "self" {
+		return nil, fmt.Errorf("cannot create key with name 'self'")
+	}
+	if options.Path == "" {
+		options.Path = keyderiv.NamePath(name)
+	}
+	span.SetAttributes(attribute.String("path", options.Path))
+
+	seed, err := keyderiv.SeedFromPhrase(phrase, options.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	sk, err := keyderiv.Derive(seed, options.Path)
+	if err != nil {
+		return nil, err
+	}
+	pid, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := api.repo.Keystore()
+	if existing, err := ks.Get(name); err == nil {
+		// deriving a key again is fine, replacing another key is not
+		if !existing.Equals(sk) {
+			return nil, fmt.Errorf("key with name '%s' already exists", name)
+		}
+	} else if err := ks.Put(name, sk); err != nil {
+		return nil, err
+	}
+
+	if err := keyderiv.SetPath(ctx, api.repo.Datastore(), name, options.Path); err != nil {
+		return nil, err
+	}
+
+	k, err := newKey(name, pid)
+	if err != nil {
+		return nil, err
+	}
+	k.derivationPath = options.Path
+	return k, nil
+}
+
 // List returns a list keys stored in keystore.
 func (api *KeyAPI) List(ctx context.Context) ([]coreiface.Key, error) {
-	_, span := tracing.Span(ctx, "CoreAPI.KeyAPI", "List")
+	ctx, span := tracing.Span(ctx, "CoreAPI.KeyAPI", "List")
 	defer span.End()
 
 	keys, err := api.repo.Keystore().List()
@@ -145,10 +206,14 @@ func (api *KeyAPI) List(ctx context.Context) ([]coreiface.Key, error) {
 			return nil, err
 		}
 
-		out[n+1], err = newKey(k, pid)
+		dk, err := newKey(k, pid)
 		if err != nil {
 			return nil, err
 		}
+		if dk.derivationPath, err = keyderiv.GetPath(ctx, api.repo.Datastore(), k); err != nil {
+			return nil, err
+		}
+		out[n+1] = dk
 	}
 	return out, nil
 }
@@ -156,7 +221,7 @@ func (api *KeyAPI) List(ctx context.Context) ([]coreiface.Key, error) {
 // Rename renames `oldName` to `newName`. Returns the key and whether another
 // key was overwritten, or an error.
 func (api *KeyAPI) Rename(ctx context.Context, oldName string, newName string, opts ...caopts.KeyRenameOption) (coreiface.Key, bool, error) {
-	_, span := tracing.Span(ctx, "CoreAPI.KeyAPI", "Rename", trace.WithAttributes(attribute.String("oldname", oldName), attribute.String("newname", newName)))
+	ctx, span := tracing.Span(ctx, "CoreAPI.KeyAPI", "Rename", trace.WithAttributes(attribute.String("oldname", oldName), attribute.String("newname", newName)))
 	defer span.End()
 
 	options, err := caopts.KeyRenameOptions(opts...)
@@ -187,11 +252,21 @@ func (api *KeyAPI) Rename(ctx context.Context, oldName string, newName string, o
 		return nil, false, err
 	}
 
+	ds := api.repo.Datastore()
+	derivationPath, err := keyderiv.GetPath(ctx, ds, oldName)
+	if err != nil {
+		return nil, false, err
+	}
+
 	// This is important, because future code will delete key `oldName`
 	// even if it is the same as newName.
 	if newName == oldName {
 		k, err := newKey(oldName, pid)
-		return k, false, err
+		if err != nil {
+			return nil, false, err
+		}
+		k.derivationPath = derivationPath
+		return k, false, nil
 	}
 
 	overwrite := false
@@ -220,13 +295,30 @@ func (api *KeyAPI) Rename(ctx context.Context, oldName string, newName string, o
 		return nil, false, err
 	}
 
+	// the derivation path follows the key
+	if err := keyderiv.DeletePath(ctx, ds, newName); err != nil {
+		return nil, false, err
+	}
+	if derivationPath != "" {
+		if err := keyderiv.SetPath(ctx, ds, newName, derivationPath); err != nil {
+			return nil, false, err
+		}
+		if err := keyderiv.DeletePath(ctx, ds, oldName); err != nil {
+			return nil, false, err
+		}
+	}
+
 	k, err := newKey(newName, pid)
-	return k, overwrite, err
+	if err != nil {
+		return nil, false, err
+	}
+	k.derivationPath = derivationPath
+	return k, overwrite, nil
 }
 
 // Remove removes keys from keystore. Returns ipns path of the removed key.
 func (api *KeyAPI) Remove(ctx context.Context, name string) (coreiface.Key, error) {
-	_, span := tracing.Span(ctx, "CoreAPI.KeyAPI", "Remove", trace.WithAttributes(attribute.String("name", name)))
+	ctx, span := tracing.Span(ctx, "CoreAPI.KeyAPI", "Remove", trace.WithAttributes(attribute.String("name", name)))
 	defer span.End()
 
 	ks := api.repo.Keystore()
@@ -251,6 +343,9 @@ func (api *KeyAPI) Remove(ctx context.Context, name string) (coreiface.Key, erro
 	if err != nil {
 		return nil, err
 	}
+	if err := keyderiv.DeletePath(ctx, api.repo.Datastore(), name); err != nil {
+		return nil, err
+	}
 
 	return newKey("", pid)
 }
@@ -0,0 +1,130 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/core/kv"
+	"github.com/ipfs/kubo/tracing"
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type KVAPI CoreAPI
+
+func (api *KVAPI) Put(ctx context.Context, store string, key string, value []byte, opts ...caopts.KVPutOption) (coreiface.KVEntry, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.KVAPI", "Put", trace.WithAttributes(attribute.String("store", store), attribute.String("key", key)))
+	defer span.End()
+
+	sk, err := api.writeKey(opts)
+	if err != nil {
+		return coreiface.KVEntry{}, err
+	}
+	e, err := api.kv.Put(ctx, store, key, value, sk)
+	if err != nil {
+		return coreiface.KVEntry{}, err
+	}
+	return kvEntry(e), nil
+}
+
+func (api *KVAPI) Get(ctx context.Context, store string, key string) ([]byte, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.KVAPI", "Get", trace.WithAttributes(attribute.String("store", store), attribute.String("key", key)))
+	defer span.End()
+
+	if err := api.checkKV(); err != nil {
+		return nil, err
+	}
+	e, err := api.kv.Get(ctx, store, key)
+	if err != nil {
+		return nil, err
+	}
+	return e.Value, nil
+}
+
+func (api *KVAPI) Delete(ctx context.Context, store string, key string, opts ...caopts.KVPutOption) error {
+	ctx, span := tracing.Span(ctx, "CoreAPI.KVAPI", "Delete", trace.WithAttributes(attribute.String("store", store), attribute.String("key", key)))
+	defer span.End()
+
+	sk, err := api.writeKey(opts)
+	if err != nil {
+		return err
+	}
+	_, err = api.kv.Delete(ctx, store, key, sk)
+	return err
+}
+
+func (api *KVAPI) List(ctx context.Context, store string) ([]coreiface.KVEntry, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.KVAPI", "List", trace.WithAttributes(attribute.String("store", store)))
+	defer span.End()
+
+	if err := api.checkKV(); err != nil {
+		return nil, err
+	}
+	entries, err := api.kv.List(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]coreiface.KVEntry, len(entries))
+	for i, e := range entries {
+		out[i] = kvEntry(e)
+	}
+	return out, nil
+}
+
+func (api *KVAPI) Subscribe(ctx context.Context, store string) (<-chan coreiface.KVEntry, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.KVAPI", "Subscribe", trace.WithAttributes(attribute.String("store", store)))
+	defer span.End()
+
+	if err := api.checkKV(); err != nil {
+		return nil, err
+	}
+	in, err := api.kv.Subscribe(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan coreiface.KVEntry)
+	go func() {
+		defer close(out)
+		for e := range in {
+			select {
+			case out <- kvEntry(e):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (api *KVAPI) checkKV() error {
+	if api.kv == nil {
+		return errors.New("key-value stores are disabled, see Experimental.KVStore")
+	}
+	return nil
+}
+
+// writeKey returns the key the writes of opts are signed with.
+func (api *KVAPI) writeKey(opts []caopts.KVPutOption) (ci.PrivKey, error) {
+	settings, err := caopts.KVPutOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.checkKV(); err != nil {
+		return nil, err
+	}
+	return keylookup(api.privateKey, api.repo.Keystore(), settings.Key)
+}
+
+func kvEntry(e kv.Entry) coreiface.KVEntry {
+	return coreiface.KVEntry{
+		Store:   e.Store,
+		Key:     e.Key,
+		Value:   e.Value,
+		Deleted: e.Deleted,
+		Writer:  e.Writer,
+		Clock:   e.Clock,
+	}
+}
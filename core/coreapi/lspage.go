@@ -0,0 +1,327 @@
+package coreapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+	"strconv"
+
+	merkledag "github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	ipld "github.com/ipfs/go-ipld-format"
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	options "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/core/coreunix"
+	"github.com/spaolacci/murmur3"
+)
+
+// errPageFull stops the walk of a directory once its page is listed.
+var errPageFull = errors.New("page full")
+
+// lsCursor returns the cursor of the directory entry name. It is the name
+// itself, so that it stays valid when the directory changes, or is sharded,
+// between two pages.
+func lsCursor(name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(name))
+}
+
+func parseLsCursor(cursor string) (string, error) {
+	name, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", fmt.Errorf("invalid cursor %q: %w", cursor, err)
+	}
+	return string(name), nil
+}
+
+// lsPage lists the page of nd selected by the offset, the limit and the
+// cursor of settings, in a stable order.
+func (api *UnixfsAPI) lsPage(ctx context.Context, nd ipld.Node, settings *options.UnixfsLsSettings) (<-chan coreiface.DirEntry, error) {
+	var after *string
+	if settings.Cursor != "" {
+		name, err := parseLsCursor(settings.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &name
+	}
+
+	var walk func(f func(*ipld.Link) error) error
+	if order, ok := coreunix.EntryOrder(nd); ok && settings.PreservedOrder {
+		links, err := api.orderedLinks(ctx, nd, order)
+		if err != nil {
+			return nil, err
+		}
+		walk = func(f func(*ipld.Link) error) error {
+			return walkOrderedLinks(links, after, f)
+		}
+	} else if pn, ok := nd.(*merkledag.ProtoNode); ok {
+		fsn, err := ft.FSNodeFromBytes(pn.Data())
+		if err != nil {
+			return nil, err
+		}
+		switch fsn.Type() {
+		case ft.TDirectory:
+			walk = func(f func(*ipld.Link) error) error {
+				return walkDirLinks(pn, after, f)
+			}
+		case ft.THAMTShard:
+			var cur *shardCursor
+			if after != nil {
+				cur = &shardCursor{name: *after, hash: hamtHash(*after)}
+			}
+			walk = func(f func(*ipld.Link) error) error {
+				return walkShardLinks(ctx, api.dag, pn, fsn, 0, cur, f)
+			}
+		}
+	}
+	if walk == nil {
+		// the links of a file have no name to resume after
+		if after != nil {
+			return nil, uio.ErrNotADir
+		}
+		links := nd.Links()
+		if settings.Offset >= len(links) {
+			links = nil
+		} else {
+			links = links[settings.Offset:]
+		}
+		if settings.Limit > 0 && settings.Limit < len(links) {
+			links = links[:settings.Limit]
+		}
+		return api.lsFromLinks(ctx, links, settings)
+	}
+
+	out := make(chan coreiface.DirEntry, uio.DefaultShardWidth)
+	go func() {
+		defer close(out)
+
+		skip, left := settings.Offset, settings.Limit
+		err := walk(func(l *ipld.Link) error {
+			if skip > 0 {
+				skip--
+				return nil
+			}
+			entry := api.processLink(ctx, ft.LinkResult{Link: l}, settings)
+			entry.Cursor = lsCursor(l.Name)
+			select {
+			case out <- entry:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if left > 0 {
+				if left--; left == 0 {
+					return errPageFull
+				}
+			}
+			return nil
+		})
+		if err != nil && err != errPageFull {
+			select {
+			case out <- coreiface.DirEntry{Err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out, nil
+}
+
+// walkDirLinks calls f with the links of the basic directory pn after the
+// name after, if set, by name.
+func walkDirLinks(pn *merkledag.ProtoNode, after *string, f func(*ipld.Link) error) error {
+	links := append([]*ipld.Link(nil), pn.Links()...)
+	sort.SliceStable(links, func(i, j int) bool {
+		return links[i].Name < links[j].Name
+	})
+	start := 0
+	if after != nil {
+		start = sort.Search(len(links), func(i int) bool {
+			return links[i].Name > *after
+		})
+	}
+	for _, l := range links[start:] {
+		if err := f(&ipld.Link{Name: l.Name, Size: l.Size, Cid: l.Cid}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shardCursor is the entry a HAMT sharded directory is listed after.
+type shardCursor struct {
+	name string
+	hash []byte
+}
+
+// before returns whether the entry name with the given hash comes before the
+// cursor, or is the cursor itself.
+func (c *shardCursor) before(name string, hash []byte) bool {
+	if cmp := bytes.Compare(hash, c.hash); cmp != 0 {
+		return cmp < 0
+	}
+	return name <= c.name
+}
+
+// hamtHash is the hash a HAMT sharded directory places its entries with.
+func hamtHash(name string) []byte {
+	h := murmur3.New64()
+	h.Write([]byte(name))
+	return h.Sum(nil)
+}
+
+// hashIndex returns the bucket index of hash in a shard of the given depth,
+// each shard consuming width bits of it.
+func hashIndex(hash []byte, depth, width int) (int, error) {
+	first := depth * width
+	if first+width > len(hash)*8 {
+		return 0, errors.New("sharded directory too deep")
+	}
+	idx := 0
+	for i := first; i < first+width; i++ {
+		idx = idx<<1 | int(hash[i/8]>>(7-i%8)&1)
+	}
+	return idx, nil
+}
+
+// walkShardLinks calls f with the entries of the HAMT shard pn in the order
+// of their hash, which is the order of the buckets of the shards. With a
+// cursor, the buckets before the one of the cursor are skipped without
+// reading their shards.
+func walkShardLinks(ctx context.Context, ng ipld.NodeGetter, pn *merkledag.ProtoNode, fsn *ft.FSNode, depth int, after *shardCursor, f func(*ipld.Link) error) error {
+	fanout := int(fsn.Fanout())
+	if fanout < 2 || fanout&(fanout-1) != 0 {
+		return fmt.Errorf("%s: invalid HAMT fanout %d", pn.Cid(), fanout)
+	}
+	width := bits.TrailingZeros(uint(fanout))
+
+	cursorIdx := -1
+	if after != nil {
+		var err error
+		if cursorIdx, err = hashIndex(after.hash, depth, width); err != nil {
+			return err
+		}
+	}
+
+	// as in lsShards, the links are named with the index of their bucket,
+	// followed by the name of the entry for the links to the entries
+	padLen := len(fmt.Sprintf("%X", fanout-1))
+	type bucket struct {
+		idx  int
+		link *ipld.Link
+	}
+	buckets := make([]bucket, 0, len(pn.Links()))
+	for _, l := range pn.Links() {
+		if len(l.Name) < padLen {
+			return fmt.Errorf("%s: invalid HAMT link name %q", pn.Cid(), l.Name)
+		}
+		idx, err := strconv.ParseUint(l.Name[:padLen], 16, 32)
+		if err != nil || int(idx) >= fanout {
+			return fmt.Errorf("%s: invalid HAMT link name %q", pn.Cid(), l.Name)
+		}
+		buckets = append(buckets, bucket{int(idx), l})
+	}
+	sort.SliceStable(buckets, func(i, j int) bool {
+		return buckets[i].idx < buckets[j].idx
+	})
+
+	for _, b := range buckets {
+		if b.idx < cursorIdx {
+			continue
+		}
+		var cur *shardCursor
+		if b.idx == cursorIdx {
+			cur = after
+		}
+
+		if len(b.link.Name) > padLen {
+			name := b.link.Name[padLen:]
+			if cur != nil && cur.before(name, hamtHash(name)) {
+				continue
+			}
+			if err := f(&ipld.Link{Name: name, Size: b.link.Size, Cid: b.link.Cid}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		child, err := b.link.GetNode(ctx, ng)
+		if err != nil {
+			return err
+		}
+		childPn, ok := child.(*merkledag.ProtoNode)
+		if !ok {
+			return fmt.Errorf("%s is not a HAMT shard", child.Cid())
+		}
+		childFsn, err := ft.FSNodeFromBytes(childPn.Data())
+		if err != nil {
+			return err
+		}
+		if childFsn.Type() != ft.THAMTShard {
+			return fmt.Errorf("%s is not a HAMT shard", child.Cid())
+		}
+		if err := walkShardLinks(ctx, ng, childPn, childFsn, depth+1, cur, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderedLinks returns the links of the directory nd, basic or HAMT sharded,
+// in order, the names of its entries in the order they were added in. The
+// links missing from order come last, by name.
+func (api *UnixfsAPI) orderedLinks(ctx context.Context, nd ipld.Node, order []string) ([]*ipld.Link, error) {
+	dir, err := uio.NewDirectoryFromNode(api.dag, nd)
+	if err != nil {
+		return nil, err
+	}
+	links, err := dir.Links(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := make(map[string]int, len(order))
+	for i, name := range order {
+		pos[name] = i
+	}
+	rank := func(l *ipld.Link) int {
+		if i, ok := pos[l.Name]; ok {
+			return i
+		}
+		return len(order)
+	}
+	sort.SliceStable(links, func(i, j int) bool {
+		ri, rj := rank(links[i]), rank(links[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return links[i].Name < links[j].Name
+	})
+	return links, nil
+}
+
+// walkOrderedLinks calls f with links, after the link named after, if set.
+func walkOrderedLinks(links []*ipld.Link, after *string, f func(*ipld.Link) error) error {
+	start := 0
+	if after != nil {
+		start = -1
+		for i, l := range links {
+			if l.Name == *after {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			return fmt.Errorf("no entry %q to resume the listing after", *after)
+		}
+	}
+	for _, l := range links[start:] {
+		if err := f(&ipld.Link{Name: l.Name, Size: l.Size, Cid: l.Cid}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
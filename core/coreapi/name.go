@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/ipfs/boxo/ipns"
 	keystore "github.com/ipfs/boxo/keystore"
@@ -16,6 +15,8 @@ import (
 	"github.com/ipfs/boxo/path"
 	coreiface "github.com/ipfs/kubo/core/coreiface"
 	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/core/nameprov"
+	"github.com/ipfs/kubo/core/webhooks"
 	ci "github.com/libp2p/go-libp2p/core/crypto"
 	peer "github.com/libp2p/go-libp2p/core/peer"
 )
@@ -54,7 +55,7 @@ func (api *NameAPI) Publish(ctx context.Context, p path.Path, opts ...caopts.Nam
 		return ipns.Name{}, err
 	}
 
-	eol := time.Now().Add(options.ValidTime)
+	eol := api.clock.Now().Add(options.ValidTime)
 
 	publishOptions := []namesys.PublishOption{
 		namesys.PublishWithEOL(eol),
@@ -75,7 +76,9 @@ func (api *NameAPI) Publish(ctx context.Context, p path.Path, opts ...caopts.Nam
 		return ipns.Name{}, err
 	}
 
-	return ipns.NameFromPeer(pid), nil
+	name := ipns.NameFromPeer(pid)
+	api.webhooks.Notify(webhooks.EventIPNSPublished, webhooks.IPNSData{Name: name.String(), Value: p.String()})
+	return name, nil
 }
 
 func (api *NameAPI) Search(ctx context.Context, name string, opts ...caopts.NameResolveOption) (<-chan coreiface.IpnsResult, error) {
@@ -87,7 +90,7 @@ func (api *NameAPI) Search(ctx context.Context, name string, opts ...caopts.Name
 		return nil, err
 	}
 
-	span.SetAttributes(attribute.Bool("cache", options.Cache))
+	span.SetAttributes(attribute.Bool("cache", options.Cache), attribute.Bool("provenance", options.Provenance))
 
 	err = api.checkOnline(true)
 	if err != nil {
@@ -96,9 +99,13 @@ func (api *NameAPI) Search(ctx context.Context, name string, opts ...caopts.Name
 
 	var resolver namesys.Resolver = api.namesys
 	if !options.Cache {
+		cfg, err := api.repo.Config()
+		if err != nil {
+			return nil, err
+		}
 		resolver, err = namesys.NewNameSystem(api.routing,
 			namesys.WithDatastore(api.repo.Datastore()),
-			namesys.WithDNSResolver(api.dnsResolver))
+			namesys.WithDNSResolver(nameprov.DNSResolver(api.dnsResolver, cfg.DNS.Resolvers)))
 		if err != nil {
 			return nil, err
 		}
@@ -113,12 +120,22 @@ func (api *NameAPI) Search(ctx context.Context, name string, opts ...caopts.Name
 		return nil, err
 	}
 
+	var rec *nameprov.Recorder
+	if options.Provenance {
+		rec = nameprov.NewRecorder()
+		ctx = nameprov.WithRecorder(ctx, rec)
+	}
+
 	out := make(chan coreiface.IpnsResult)
 	go func() {
 		defer close(out)
 		for res := range resolver.ResolveAsync(ctx, p, options.ResolveOpts...) {
+			ires := coreiface.IpnsResult{Path: res.Path, Err: res.Err}
+			if rec != nil && res.Err == nil {
+				ires.Provenance = rec.Provenance(p.String(), res.Path.String())
+			}
 			select {
-			case out <- coreiface.IpnsResult{Path: res.Path, Err: res.Err}:
+			case out <- ires:
 			case <-ctx.Done():
 				return
 			}
@@ -0,0 +1,192 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/p2p"
+	"github.com/ipfs/kubo/tracing"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	ma "github.com/multiformats/go-multiaddr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// p2pProtoPrefix is the required prefix of the messaging protocols, the same
+// as the one of 'ipfs p2p' tunnels.
+const p2pProtoPrefix = "/x/"
+
+type P2PAPI CoreAPI
+
+func (api *P2PAPI) Send(ctx context.Context, p peer.ID, proto string, data []byte, opts ...caopts.P2PSendOption) ([]byte, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.P2PAPI", "Send", trace.WithAttributes(attribute.String("peer", p.String()), attribute.String("protocol", proto)))
+	defer span.End()
+
+	settings, err := caopts.P2PSendOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.checkP2P(proto); err != nil {
+		return nil, err
+	}
+
+	return api.p2p.SendMessage(ctx, p, protocol.ID(proto), data, settings.MaxReplySize)
+}
+
+func (api *P2PAPI) Handle(ctx context.Context, proto string, handler coreiface.P2PMessageHandler, opts ...caopts.P2PHandleOption) error {
+	_, span := tracing.Span(ctx, "CoreAPI.P2PAPI", "Handle", trace.WithAttributes(attribute.String("protocol", proto)))
+	defer span.End()
+
+	settings, err := caopts.P2PHandleOptions(opts...)
+	if err != nil {
+		return err
+	}
+	if err := api.checkP2P(proto); err != nil {
+		return err
+	}
+
+	return api.p2p.HandleMessages(ctx, protocol.ID(proto), p2p.MessageHandler(handler), settings.MaxMessageSize)
+}
+
+func (api *P2PAPI) Forward(ctx context.Context, proto string, listenAddr ma.Multiaddr, target peer.ID, opts ...caopts.P2PListenOption) (coreiface.P2PListener, error) {
+	_, span := tracing.Span(ctx, "CoreAPI.P2PAPI", "Forward", trace.WithAttributes(attribute.String("protocol", proto), attribute.String("target", target.String())))
+	defer span.End()
+
+	settings, err := caopts.P2PListenOptions(opts...)
+	if err != nil {
+		return coreiface.P2PListener{}, err
+	}
+	if err := api.checkStreamMounting(proto, settings.AllowCustomProtocol); err != nil {
+		return coreiface.P2PListener{}, err
+	}
+
+	// the listener outlives the request creating it
+	l, err := api.p2p.ForwardLocal(api.nctx, target, protocol.ID(proto), listenAddr)
+	if err != nil {
+		return coreiface.P2PListener{}, err
+	}
+	return p2pListener(l), nil
+}
+
+func (api *P2PAPI) Listen(ctx context.Context, proto string, targetAddr ma.Multiaddr, opts ...caopts.P2PListenOption) (coreiface.P2PListener, error) {
+	_, span := tracing.Span(ctx, "CoreAPI.P2PAPI", "Listen", trace.WithAttributes(attribute.String("protocol", proto), attribute.String("target", targetAddr.String())))
+	defer span.End()
+
+	settings, err := caopts.P2PListenOptions(opts...)
+	if err != nil {
+		return coreiface.P2PListener{}, err
+	}
+	if err := api.checkStreamMounting(proto, settings.AllowCustomProtocol); err != nil {
+		return coreiface.P2PListener{}, err
+	}
+
+	l, err := api.p2p.ForwardRemote(api.nctx, protocol.ID(proto), targetAddr, settings.ReportPeerID, settings.AllowedPeers)
+	if err != nil {
+		return coreiface.P2PListener{}, err
+	}
+	return p2pListener(l), nil
+}
+
+func (api *P2PAPI) Listeners(ctx context.Context) ([]coreiface.P2PListener, error) {
+	_, span := tracing.Span(ctx, "CoreAPI.P2PAPI", "Listeners")
+	defer span.End()
+
+	if err := api.checkStreamMounting("", true); err != nil {
+		return nil, err
+	}
+
+	var out []coreiface.P2PListener
+	for _, reg := range []*p2p.Listeners{api.p2p.ListenersLocal, api.p2p.ListenersP2P} {
+		reg.RLock()
+		for _, l := range reg.Listeners {
+			out = append(out, p2pListener(l))
+		}
+		reg.RUnlock()
+	}
+	return out, nil
+}
+
+func (api *P2PAPI) Close(ctx context.Context, opts ...caopts.P2PCloseOption) (int, error) {
+	_, span := tracing.Span(ctx, "CoreAPI.P2PAPI", "Close")
+	defer span.End()
+
+	settings, err := caopts.P2PCloseOptions(opts...)
+	if err != nil {
+		return 0, err
+	}
+	if err := api.checkStreamMounting("", true); err != nil {
+		return 0, err
+	}
+
+	p, l, t := settings.Protocol != "", settings.ListenAddress != nil, settings.TargetAddress != nil
+	if !(settings.All || p || l || t) {
+		return 0, errors.New("no matching options given")
+	}
+	if settings.All && (p || l || t) {
+		return 0, errors.New("can't combine All with other matching options")
+	}
+
+	match := func(listener p2p.Listener) bool {
+		if settings.All {
+			return true
+		}
+		if p && protocol.ID(settings.Protocol) != listener.Protocol() {
+			return false
+		}
+		if l && !settings.ListenAddress.Equal(listener.ListenAddress()) {
+			return false
+		}
+		if t && !settings.TargetAddress.Equal(listener.TargetAddress()) {
+			return false
+		}
+		return true
+	}
+
+	done := api.p2p.ListenersLocal.Close(match)
+	done += api.p2p.ListenersP2P.Close(match)
+	return done, nil
+}
+
+func p2pListener(l p2p.Listener) coreiface.P2PListener {
+	return coreiface.P2PListener{
+		Protocol:      string(l.Protocol()),
+		ListenAddress: l.ListenAddress(),
+		TargetAddress: l.TargetAddress(),
+		AllowedPeers:  l.AllowedPeers(),
+		BytesIn:       l.Counters().In.Load(),
+		BytesOut:      l.Counters().Out.Load(),
+	}
+}
+
+func (api *P2PAPI) checkP2P(proto string) error {
+	if err := api.checkOnline(false); err != nil {
+		return err
+	}
+	if api.p2p == nil {
+		return coreiface.ErrOffline
+	}
+	if !strings.HasPrefix(proto, p2pProtoPrefix) {
+		return errors.New("protocol name must be within '" + p2pProtoPrefix + "' namespace")
+	}
+	return nil
+}
+
+// checkStreamMounting checks the tunnels are enabled, and that proto is in the
+// '/x/' namespace unless allowCustom.
+func (api *P2PAPI) checkStreamMounting(proto string, allowCustom bool) error {
+	cfg, err := api.repo.Config()
+	if err != nil {
+		return err
+	}
+	if !cfg.Experimental.Libp2pStreamMounting {
+		return errors.New("libp2p stream mounting not enabled")
+	}
+	if allowCustom {
+		return api.checkP2P(p2pProtoPrefix)
+	}
+	return api.checkP2P(proto)
+}
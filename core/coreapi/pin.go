@@ -2,7 +2,9 @@ package coreapi
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	bserv "github.com/ipfs/boxo/blockservice"
 	offline "github.com/ipfs/boxo/exchange/offline"
@@ -12,6 +14,14 @@ import (
 	"github.com/ipfs/go-cid"
 	coreiface "github.com/ipfs/kubo/core/coreiface"
 	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/core/dagshape"
+	"github.com/ipfs/kubo/core/pingrace"
+	"github.com/ipfs/kubo/core/pinqueue"
+	"github.com/ipfs/kubo/core/pinreport"
+	"github.com/ipfs/kubo/core/pinsync"
+	"github.com/ipfs/kubo/core/provideclass"
+	"github.com/ipfs/kubo/core/webhooks"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
@@ -24,7 +34,14 @@ func (api *PinAPI) Add(ctx context.Context, p path.Path, opts ...caopts.PinAddOp
 	ctx, span := tracing.Span(ctx, "CoreAPI.PinAPI", "Add", trace.WithAttributes(attribute.String("path", p.String())))
 	defer span.End()
 
-	dagNode, err := api.core().ResolveNode(ctx, p)
+	// a graceful shutdown waits for the pin to complete
+	ctx, done, err := api.startOperation(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	rp, _, err := api.core().ResolvePath(ctx, p)
 	if err != nil {
 		return fmt.Errorf("pin: %s", err)
 	}
@@ -34,11 +51,37 @@ func (api *PinAPI) Add(ctx context.Context, p path.Path, opts ...caopts.PinAddOp
 		return err
 	}
 
-	span.SetAttributes(attribute.Bool("recursive", settings.Recursive))
+	span.SetAttributes(attribute.Bool("recursive", settings.Recursive), attribute.String("provideclass", settings.ProvideClass))
+
+	if settings.ProvideClass != "" {
+		if err := provideclass.Validate(settings.ProvideClass); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := api.repo.Config()
+	if err != nil {
+		return err
+	}
+
+	// Fetch the blocks first to account for where they come from, and to
+	// reject the DAGs exceeding DAGLimits. The pinner then finds them
+	// locally.
+	report, err := pinreport.Fetch(ctx, api.blockSources, api.blockstore, api.dag, rp.RootCid(), settings.Recursive, dagshape.ParseLimits(cfg.DAGLimits))
+	if err != nil {
+		return fmt.Errorf("pin: %s", err)
+	}
+
+	dagNode, err := api.dag.Get(ctx, rp.RootCid())
+	if err != nil {
+		return fmt.Errorf("pin: %s", err)
+	}
 
 	defer api.blockstore.PinLock(ctx).Unlock(ctx)
 
-	err = api.pinning.Pin(ctx, dagNode, settings.Recursive, settings.Name)
+	// the progress was reported while fetching, the pinner walks the DAG again
+	pinCtx := new(merkledag.ProgressTracker).DeriveContext(ctx)
+	err = api.pinning.Pin(pinCtx, dagNode, settings.Recursive, settings.Name)
 	if err != nil {
 		return fmt.Errorf("pin: %s", err)
 	}
@@ -47,7 +90,23 @@ func (api *PinAPI) Add(ctx context.Context, p path.Path, opts ...caopts.PinAddOp
 		return err
 	}
 
-	return api.pinning.Flush(ctx)
+	if err := api.pinning.Flush(ctx); err != nil {
+		return err
+	}
+	if settings.ProvideClass != "" {
+		if _, err := api.provideClasses.Set(ctx, dagNode.Cid(), settings.ProvideClass, settings.Recursive); err != nil {
+			return err
+		}
+	}
+	(*CoreAPI)(api).pinsChanged()
+	api.webhooks.Notify(webhooks.EventPinCompleted, webhooks.PinData{
+		Cid:       dagNode.Cid().String(),
+		Recursive: settings.Recursive,
+		Name:      settings.Name,
+	})
+
+	report.Finished = time.Now()
+	return pinreport.Save(ctx, api.repo.Datastore(), report)
 }
 
 func (api *PinAPI) Ls(ctx context.Context, opts ...caopts.PinLsOption) (<-chan coreiface.Pin, error) {
@@ -109,17 +168,110 @@ func (api *PinAPI) Rm(ctx context.Context, p path.Path, opts ...caopts.PinRmOpti
 		return err
 	}
 
-	span.SetAttributes(attribute.Bool("recursive", settings.Recursive))
+	span.SetAttributes(attribute.Bool("recursive", settings.Recursive), attribute.String("grace", settings.Grace.String()))
 
 	// Note: after unpin the pin sets are flushed to the blockstore, so we need
 	// to take a lock to prevent a concurrent garbage collection
 	defer api.blockstore.PinLock(ctx).Unlock(ctx)
 
+	var removal *pingrace.Removal
+	if settings.Grace > 0 {
+		if removal, err = api.removal(ctx, rp.RootCid(), settings.Grace); err != nil {
+			return err
+		}
+	}
+
 	if err = api.pinning.Unpin(ctx, rp.RootCid(), settings.Recursive); err != nil {
 		return err
 	}
 
-	return api.pinning.Flush(ctx)
+	if err := api.pinning.Flush(ctx); err != nil {
+		return err
+	}
+	if err := api.provideClasses.Clear(ctx, rp.RootCid()); err != nil {
+		return err
+	}
+	(*CoreAPI)(api).pinsChanged()
+
+	// the blocks are protected before the garbage collection can run again
+	if removal != nil {
+		return pingrace.Save(ctx, api.repo.Datastore(), removal)
+	}
+	return nil
+}
+
+// removal returns the removal of the pin of c, with its mode and name, for a
+// grace period starting now.
+func (api *PinAPI) removal(ctx context.Context, c cid.Cid, grace time.Duration) (*pingrace.Removal, error) {
+	_, recursive, err := api.pinning.IsPinnedWithType(ctx, c, pin.Recursive)
+	if err != nil {
+		return nil, err
+	}
+	keys := api.pinning.DirectKeys
+	if recursive {
+		keys = api.pinning.RecursiveKeys
+	}
+
+	now := api.clock.Now()
+	removal := &pingrace.Removal{Cid: c, Recursive: recursive, Removed: now, Expires: now.Add(grace)}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for p := range keys(ctx, true) {
+		if p.Err != nil {
+			return nil, p.Err
+		}
+		if p.Pin.Key.Equals(c) {
+			removal.Name = p.Pin.Name
+			break
+		}
+	}
+	return removal, nil
+}
+
+func (api *PinAPI) Removed(ctx context.Context) ([]coreiface.PinRemoval, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.PinAPI", "Removed")
+	defer span.End()
+
+	removals, err := pingrace.List(ctx, api.repo.Datastore(), api.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	out := make([]coreiface.PinRemoval, len(removals))
+	for i, r := range removals {
+		out[i] = coreiface.PinRemoval{
+			Path:      path.FromCid(r.Cid),
+			Recursive: r.Recursive,
+			Name:      r.Name,
+			Removed:   r.Removed,
+			Expires:   r.Expires,
+		}
+	}
+	return out, nil
+}
+
+func (api *PinAPI) Restore(ctx context.Context, p path.Path) error {
+	ctx, span := tracing.Span(ctx, "CoreAPI.PinAPI", "Restore", trace.WithAttributes(attribute.String("path", p.String())))
+	defer span.End()
+
+	rp, _, err := api.core().ResolvePath(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	ds := api.repo.Datastore()
+	removal, err := pingrace.Take(ctx, ds, rp.RootCid(), api.clock.Now())
+	if err != nil {
+		return err
+	}
+	err = api.Add(ctx, path.FromCid(removal.Cid), caopts.Pin.Recursive(removal.Recursive), caopts.Pin.Name(removal.Name))
+	if err != nil {
+		// the pin can be restored again until the grace period expires
+		if serr := pingrace.Save(ctx, ds, removal); serr != nil {
+			log.Errorf("saving the removal of the pin of %s: %s", removal.Cid, serr)
+		}
+		return err
+	}
+	return nil
 }
 
 func (api *PinAPI) Update(ctx context.Context, from path.Path, to path.Path, opts ...caopts.PinUpdateOption) error {
@@ -153,7 +305,211 @@ func (api *PinAPI) Update(ctx context.Context, from path.Path, to path.Path, opt
 		return err
 	}
 
-	return api.pinning.Flush(ctx)
+	if err := api.pinning.Flush(ctx); err != nil {
+		return err
+	}
+
+	// the new pin keeps the provide class of the old one
+	if err := api.provideClasses.Copy(ctx, fp.RootCid(), tp.RootCid(), true); err != nil {
+		return err
+	}
+	if settings.Unpin {
+		if err := api.provideClasses.Clear(ctx, fp.RootCid()); err != nil {
+			return err
+		}
+	}
+	(*CoreAPI)(api).pinsChanged()
+	return nil
+}
+
+func (api *PinAPI) Enqueue(ctx context.Context, p path.Path, opts ...caopts.PinAddOption) (coreiface.PinRequest, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.PinAPI", "Enqueue", trace.WithAttributes(attribute.String("path", p.String())))
+	defer span.End()
+
+	settings, err := caopts.PinAddOptions(opts...)
+	if err != nil {
+		return coreiface.PinRequest{}, err
+	}
+
+	span.SetAttributes(attribute.Bool("recursive", settings.Recursive), attribute.Int("priority", settings.Priority))
+
+	if settings.ProvideClass != "" {
+		return coreiface.PinRequest{}, errors.New("the provide class of a queued pin can't be set before it is pinned")
+	}
+
+	// Only the root of the path is resolved here, the content is fetched by
+	// the queue.
+	rp, _, err := api.core().ResolvePath(ctx, p)
+	if err != nil {
+		return coreiface.PinRequest{}, fmt.Errorf("pin: %s", err)
+	}
+
+	req, err := api.pinQueue.Add(ctx, rp.RootCid(), settings.Recursive, settings.Name, settings.Priority)
+	if err != nil {
+		return coreiface.PinRequest{}, err
+	}
+	return toPinRequest(req), nil
+}
+
+func (api *PinAPI) Queue(ctx context.Context) ([]coreiface.PinRequest, error) {
+	_, span := tracing.Span(ctx, "CoreAPI.PinAPI", "Queue")
+	defer span.End()
+
+	queued := api.pinQueue.List()
+	reqs := make([]coreiface.PinRequest, len(queued))
+	for i, req := range queued {
+		reqs[i] = toPinRequest(req)
+	}
+	return reqs, nil
+}
+
+func (api *PinAPI) SetQueuePriority(ctx context.Context, id string, priority int) (coreiface.PinRequest, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.PinAPI", "SetQueuePriority", trace.WithAttributes(attribute.String("id", id), attribute.Int("priority", priority)))
+	defer span.End()
+
+	req, err := api.pinQueue.SetPriority(ctx, id, priority)
+	if err != nil {
+		return coreiface.PinRequest{}, err
+	}
+	return toPinRequest(req), nil
+}
+
+func (api *PinAPI) CancelQueued(ctx context.Context, id string) error {
+	ctx, span := tracing.Span(ctx, "CoreAPI.PinAPI", "CancelQueued", trace.WithAttributes(attribute.String("id", id)))
+	defer span.End()
+
+	return api.pinQueue.Cancel(ctx, id)
+}
+
+func (api *PinAPI) Report(ctx context.Context, p path.Path) (coreiface.PinReport, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.PinAPI", "Report", trace.WithAttributes(attribute.String("path", p.String())))
+	defer span.End()
+
+	rp, _, err := api.core().ResolvePath(ctx, p)
+	if err != nil {
+		return coreiface.PinReport{}, err
+	}
+
+	report, err := pinreport.Load(ctx, api.repo.Datastore(), rp.RootCid())
+	if err != nil {
+		return coreiface.PinReport{}, err
+	}
+
+	out := coreiface.PinReport{
+		Path:      path.FromCid(report.Cid),
+		Recursive: report.Recursive,
+		Started:   report.Started,
+		Finished:  report.Finished,
+		Blocks:    report.Blocks,
+		Bytes:     report.Bytes,
+		Sources:   make([]coreiface.PinReportSource, len(report.Sources)),
+	}
+	for i, s := range report.Sources {
+		out.Sources[i] = coreiface.PinReportSource{
+			Peer:      s.Peer,
+			Transport: s.Transport,
+			Blocks:    s.Blocks,
+			Bytes:     s.Bytes,
+		}
+	}
+	return out, nil
+}
+
+func (api *PinAPI) SetProvideClass(ctx context.Context, p path.Path, class string) error {
+	ctx, span := tracing.Span(ctx, "CoreAPI.PinAPI", "SetProvideClass", trace.WithAttributes(attribute.String("path", p.String()), attribute.String("class", class)))
+	defer span.End()
+
+	rp, _, err := api.core().ResolvePath(ctx, p)
+	if err != nil {
+		return err
+	}
+	c := rp.RootCid()
+	if class == "" {
+		return api.provideClasses.Clear(ctx, c)
+	}
+	if err := provideclass.Validate(class); err != nil {
+		return err
+	}
+
+	_, recursive, err := api.pinning.IsPinnedWithType(ctx, c, pin.Recursive)
+	if err != nil {
+		return err
+	}
+	if !recursive {
+		_, direct, err := api.pinning.IsPinnedWithType(ctx, c, pin.Direct)
+		if err != nil {
+			return err
+		}
+		if !direct {
+			return pin.ErrNotPinned
+		}
+	}
+
+	_, err = api.provideClasses.Set(ctx, c, class, recursive)
+	return err
+}
+
+func (api *PinAPI) ProvideClasses(ctx context.Context) ([]coreiface.PinProvideClass, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.PinAPI", "ProvideClasses")
+	defer span.End()
+
+	entries, err := api.provideClasses.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]coreiface.PinProvideClass, len(entries))
+	for i, e := range entries {
+		out[i] = coreiface.PinProvideClass{
+			Path:      path.FromCid(e.Cid),
+			Recursive: e.Recursive,
+			Class:     e.Class,
+			Provided:  e.Provided,
+		}
+	}
+	return out, nil
+}
+
+func (api *PinAPI) Reconcile(ctx context.Context, p peer.ID) (coreiface.PinsetDiff, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.PinAPI", "Reconcile", trace.WithAttributes(attribute.String("peer", p.String())))
+	defer span.End()
+
+	if api.pinSync == nil {
+		return coreiface.PinsetDiff{}, errors.New("pinset reconciliation is not enabled (Experimental.PinsetReconciliation), or the node is offline")
+	}
+
+	diff, err := api.pinSync.Reconcile(ctx, p)
+	if err != nil {
+		return coreiface.PinsetDiff{}, err
+	}
+	span.SetAttributes(attribute.Int("missing", len(diff.Missing)), attribute.Int("extra", len(diff.Extra)), attribute.Int("cells", diff.Cells))
+
+	return coreiface.PinsetDiff{
+		Missing: toPinsetEntries(diff.Missing),
+		Extra:   toPinsetEntries(diff.Extra),
+	}, nil
+}
+
+func toPinsetEntries(pins []pinsync.Pin) []coreiface.PinsetEntry {
+	entries := make([]coreiface.PinsetEntry, len(pins))
+	for i, p := range pins {
+		entries[i] = coreiface.PinsetEntry{Path: path.FromCid(p.Cid), Recursive: p.Recursive}
+	}
+	return entries
+}
+
+func toPinRequest(req pinqueue.Request) coreiface.PinRequest {
+	return coreiface.PinRequest{
+		ID:          req.ID,
+		Path:        path.FromCid(req.Cid),
+		Recursive:   req.Recursive,
+		Name:        req.Name,
+		Priority:    req.Priority,
+		Status:      string(req.Status),
+		Attempts:    req.Attempts,
+		LastError:   req.LastError,
+		Created:     req.Created,
+		NextAttempt: req.NextAttempt,
+	}
 }
 
 type pinStatus struct {
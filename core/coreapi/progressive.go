@@ -0,0 +1,299 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/boxo/files"
+	merkledag "github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// progressiveWindow is the number of children of a file node fetched ahead
+// of the reads.
+const progressiveWindow = 16
+
+// progressiveFile is a UnixFS file fetching its leaves ahead of the reads, in
+// parallel, and yielding the data of each leaf as soon as it and the leaves
+// before it are fetched and verified.
+type progressiveFile struct {
+	ctx     context.Context
+	ng      ipld.NodeGetter
+	root    *merkledag.ProtoNode
+	fsn     *ft.FSNode
+	onRange func(offset, length uint64)
+	// end is where the reads stop, the leaves after it are not fetched. It
+	// is 0 for the whole file.
+	end uint64
+
+	offset int64
+	cur    []byte
+	walk   *progressiveWalk
+}
+
+// newProgressiveFile returns the progressive file of nd, read until end when
+// set, or nil when nd is not a file made of several blocks.
+func newProgressiveFile(ctx context.Context, ng ipld.NodeGetter, nd ipld.Node, onRange func(offset, length uint64), end uint64) files.File {
+	pn, ok := nd.(*merkledag.ProtoNode)
+	if !ok || len(pn.Links()) == 0 {
+		return nil
+	}
+	fsn, err := ft.FSNodeFromBytes(pn.Data())
+	if err != nil || (fsn.Type() != ft.TFile && fsn.Type() != ft.TRaw) {
+		return nil
+	}
+	return &progressiveFile{
+		ctx:     ctx,
+		ng:      ng,
+		root:    pn,
+		fsn:     fsn,
+		onRange: onRange,
+		end:     end,
+	}
+}
+
+func (f *progressiveFile) Size() (int64, error) {
+	return int64(f.fsn.FileSize()), nil
+}
+
+func (f *progressiveFile) Read(p []byte) (int, error) {
+	if f.walk == nil {
+		f.walk = startProgressiveWalk(f.ctx, f.ng, f.root, f.fsn, uint64(f.offset), f.end, f.onRange)
+	}
+	for len(f.cur) == 0 {
+		data, ok := <-f.walk.out
+		if !ok {
+			if f.walk.err != nil {
+				return 0, f.walk.err
+			}
+			if err := f.ctx.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		f.cur = data
+	}
+	n := copy(p, f.cur)
+	f.cur = f.cur[n:]
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *progressiveFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(f.fsn.FileSize())
+	default:
+		return f.offset, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return f.offset, errors.New("invalid offset")
+	}
+
+	if offset >= f.offset && offset-f.offset <= int64(len(f.cur)) {
+		// within the data already read
+		f.cur = f.cur[offset-f.offset:]
+	} else {
+		f.stop()
+		f.cur = nil
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *progressiveFile) Close() error {
+	f.stop()
+	return nil
+}
+
+func (f *progressiveFile) stop() {
+	if f.walk != nil {
+		f.walk.cancel()
+		f.walk = nil
+	}
+}
+
+// progressiveWalk sends the data of a file in order, from the offset start
+// until end, when set.
+type progressiveWalk struct {
+	ng      ipld.NodeGetter
+	start   uint64
+	end     uint64
+	onRange func(offset, length uint64)
+
+	out    chan []byte
+	err    error // set before out is closed
+	cancel context.CancelFunc
+}
+
+func startProgressiveWalk(ctx context.Context, ng ipld.NodeGetter, root *merkledag.ProtoNode, fsn *ft.FSNode, start, end uint64, onRange func(offset, length uint64)) *progressiveWalk {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &progressiveWalk{
+		ng:      ng,
+		start:   start,
+		end:     end,
+		onRange: onRange,
+		out:     make(chan []byte, progressiveWindow),
+		cancel:  cancel,
+	}
+	go func() {
+		defer close(w.out)
+		if err := w.walk(ctx, root, fsn, 0); err != nil && ctx.Err() == nil {
+			w.err = err
+		}
+	}()
+	return w
+}
+
+// fetched is a child of a file node, with the data of a leaf, or the UnixFS
+// node of an internal node.
+type fetched struct {
+	nd   ipld.Node
+	fsn  *ft.FSNode
+	data []byte
+	err  error
+}
+
+// walk sends the data of the file node nd, starting at the offset base of the
+// file.
+func (w *progressiveWalk) walk(ctx context.Context, nd ipld.Node, fsn *ft.FSNode, base uint64) error {
+	links := nd.Links()
+	if fsn.NumChildren() != len(links) {
+		return fmt.Errorf("file node %s has %d links but %d block sizes", nd.Cid(), len(links), fsn.NumChildren())
+	}
+	if data := fsn.Data(); len(data) > 0 {
+		if w.onRange != nil {
+			w.onRange(base, uint64(len(data)))
+		}
+		if err := w.emit(ctx, base, data); err != nil {
+			return err
+		}
+	}
+
+	// the children ending before the start, or starting after the end, are
+	// not fetched
+	type child struct {
+		c         cid.Cid
+		off, size uint64
+	}
+	var children []child
+	off := base + uint64(len(fsn.Data()))
+	for i, l := range links {
+		size := fsn.BlockSize(i)
+		if off+size > w.start && (w.end == 0 || off < w.end) {
+			children = append(children, child{c: l.Cid, off: off, size: size})
+		}
+		off += size
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sem := make(chan struct{}, progressiveWindow)
+	slots := make([]chan fetched, len(children))
+	for i := range slots {
+		slots[i] = make(chan fetched, 1)
+	}
+	go func() {
+		for i, c := range children {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(i int, c child) {
+				slots[i] <- w.fetch(ctx, c.c, c.off, c.size)
+			}(i, c)
+		}
+	}()
+
+	for i, c := range children {
+		var r fetched
+		select {
+		case r = <-slots[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		<-sem
+		if r.err != nil {
+			return r.err
+		}
+		if r.fsn != nil {
+			if err := w.walk(ctx, r.nd, r.fsn, c.off); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.emit(ctx, c.off, r.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetch gets the child c of a file node, verifying it has the size recorded
+// by its parent.
+func (w *progressiveWalk) fetch(ctx context.Context, c cid.Cid, off, size uint64) fetched {
+	nd, err := w.ng.Get(ctx, c)
+	if err != nil {
+		return fetched{err: err}
+	}
+
+	var data []byte
+	switch nd := nd.(type) {
+	case *merkledag.RawNode:
+		data = nd.RawData()
+	case *merkledag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return fetched{err: err}
+		}
+		if t := fsn.Type(); t != ft.TFile && t != ft.TRaw {
+			return fetched{err: fmt.Errorf("unexpected node %s of type %s in a file", c, t)}
+		}
+		if len(nd.Links()) > 0 {
+			if fsn.FileSize() != size {
+				return fetched{err: fmt.Errorf("file node %s has %d bytes, expected %d", c, fsn.FileSize(), size)}
+			}
+			return fetched{nd: nd, fsn: fsn}
+		}
+		data = fsn.Data()
+	default:
+		return fetched{err: fmt.Errorf("unexpected node %s in a file", c)}
+	}
+
+	if uint64(len(data)) != size {
+		return fetched{err: fmt.Errorf("leaf %s has %d bytes, expected %d", c, len(data), size)}
+	}
+	if w.onRange != nil {
+		w.onRange(off, size)
+	}
+	return fetched{data: data}
+}
+
+// emit sends the data at the offset off of the file, trimmed to the start and
+// the end.
+func (w *progressiveWalk) emit(ctx context.Context, off uint64, data []byte) error {
+	end := off + uint64(len(data))
+	if end <= w.start || (w.end != 0 && off >= w.end) {
+		return nil
+	}
+	if w.end != 0 && end > w.end {
+		data = data[:w.end-off]
+	}
+	if off < w.start {
+		data = data[w.start-off:]
+	}
+	select {
+	case w.out <- data:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,84 @@
+package coreapi
+
+import (
+	"errors"
+	"io"
+
+	"github.com/ipfs/boxo/files"
+)
+
+// rangeFile is a byte range of a file. The file seeks to the start of the
+// range, which UnixFS files do fetching only the blocks on the way, and is
+// not read past its end.
+type rangeFile struct {
+	files.File
+	start  int64
+	size   int64
+	offset int64
+}
+
+// newRangeFile returns the range of f from start, of length bytes, or until
+// the end of f when length is -1.
+func newRangeFile(f files.File, start, length int64) (files.File, error) {
+	total, err := f.Size()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if start > total {
+		start = total
+	}
+	size := total - start
+	if length >= 0 && length < size {
+		size = length
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &rangeFile{File: f, start: start, size: size}, nil
+}
+
+func (f *rangeFile) Size() (int64, error) {
+	return f.size, nil
+}
+
+func (f *rangeFile) Read(p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+	if rest := f.size - f.offset; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	n, err := f.File.Read(p)
+	f.offset += int64(n)
+	if err == io.EOF && f.offset < f.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (f *rangeFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return f.offset, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return f.offset, errors.New("invalid offset")
+	}
+	// past the end, the reads return io.EOF
+	at := offset
+	if at > f.size {
+		at = f.size
+	}
+	if _, err := f.File.Seek(f.start+at, io.SeekStart); err != nil {
+		return f.offset, err
+	}
+	f.offset = offset
+	return offset, nil
+}
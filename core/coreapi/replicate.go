@@ -0,0 +1,66 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ipfs/boxo/path"
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/core/replicate"
+	"github.com/ipfs/kubo/tracing"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ReplicateAPI CoreAPI
+
+func (api *ReplicateAPI) Push(ctx context.Context, target peer.ID, name string, opts ...caopts.ReplicatePushOption) ([]coreiface.ReplicatedPin, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.ReplicateAPI", "Push", trace.WithAttributes(attribute.String("peer", target.String()), attribute.String("name", name)))
+	defer span.End()
+
+	settings, err := caopts.ReplicatePushOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int64("bandwidth", int64(settings.BytesPerSecond)), attribute.Int("retries", settings.Retries))
+
+	if api.replicate == nil {
+		return nil, errors.New("pin replication is not enabled (Experimental.PinReplication), or the node is offline")
+	}
+
+	var pins []replicate.Pin
+	for _, recursive := range []bool{true, false} {
+		keys := api.pinning.DirectKeys(ctx, true)
+		if recursive {
+			keys = api.pinning.RecursiveKeys(ctx, true)
+		}
+		for p := range keys {
+			if p.Err != nil {
+				return nil, p.Err
+			}
+			if name == "" || p.Pin.Name == name {
+				pins = append(pins, replicate.Pin{Cid: p.Pin.Key, Recursive: recursive, Name: p.Pin.Name})
+			}
+		}
+	}
+	span.SetAttributes(attribute.Int("pins", len(pins)))
+
+	var out []coreiface.ReplicatedPin
+	err = api.replicate.Push(ctx, target, pins, replicate.Options{
+		BytesPerSecond: settings.BytesPerSecond,
+		Retries:        settings.Retries,
+	}, func(r replicate.Result) {
+		out = append(out, coreiface.ReplicatedPin{
+			Path:      path.FromCid(r.Pin.Cid),
+			Recursive: r.Pin.Recursive,
+			Name:      r.Pin.Name,
+			Blocks:    r.Blocks,
+			Bytes:     r.Bytes,
+			Skipped:   r.Skipped,
+			Attempts:  r.Attempts,
+		})
+	})
+	return out, err
+}
@@ -0,0 +1,30 @@
+package coreapi
+
+import (
+	"context"
+
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/ipfs/kubo/core/corerepo"
+	"github.com/ipfs/kubo/tracing"
+)
+
+type RepoAPI CoreAPI
+
+func (api *RepoAPI) LastGCReport(ctx context.Context) (coreiface.GCReport, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.RepoAPI", "LastGCReport")
+	defer span.End()
+
+	report, err := corerepo.LastGCReport(ctx, api.repo.Datastore())
+	if err != nil {
+		return coreiface.GCReport{}, err
+	}
+	return coreiface.GCReport{
+		Started:    report.Started,
+		Duration:   report.Duration,
+		Removed:    report.Removed,
+		FreedBytes: report.FreedBytes,
+		Retained:   report.Retained,
+		ErrorCount: report.ErrorCount,
+		Errors:     report.Errors,
+	}, nil
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	blockservice "github.com/ipfs/boxo/blockservice"
 	blockstore "github.com/ipfs/boxo/blockstore"
@@ -159,6 +160,105 @@ func (api *RoutingAPI) Provide(ctx context.Context, path path.Path, opts ...caop
 	return nil
 }
 
+func (api *RoutingAPI) CheckAvailability(ctx context.Context, cids []cid.Cid, opts ...caopts.RoutingCheckAvailabilityOption) ([]coreiface.Availability, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.RoutingAPI", "CheckAvailability", trace.WithAttributes(attribute.Int("cids", len(cids))))
+	defer span.End()
+
+	settings, err := caopts.RoutingCheckAvailabilityOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Bool("fetch", settings.Fetch), attribute.Int("numproviders", settings.NumProviders))
+	if settings.NumProviders < 1 {
+		return nil, fmt.Errorf("number of providers must be greater than 0")
+	}
+	if settings.Concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be greater than 0")
+	}
+
+	err = api.checkOnline(false)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]coreiface.Availability, len(cids))
+	sem := make(chan struct{}, settings.Concurrency)
+	var wg sync.WaitGroup
+	for i, c := range cids {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+		wg.Add(1)
+		go func(i int, c cid.Cid) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			out[i] = api.checkAvailability(ctx, c, settings)
+		}(i, c)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// checkAvailabilityDials is the number of the providers found that are dialed
+// before fetching a block, so that the exchange asks them right away.
+const checkAvailabilityDials = 3
+
+func (api *RoutingAPI) checkAvailability(ctx context.Context, c cid.Cid, settings *caopts.RoutingCheckAvailabilitySettings) coreiface.Availability {
+	a := coreiface.Availability{Cid: c, Verdict: coreiface.AvailabilityUnavailable}
+
+	findCtx, cancel := context.WithTimeout(ctx, settings.Timeout)
+	var providers []peer.AddrInfo
+	for p := range api.routing.FindProvidersAsync(findCtx, c, settings.NumProviders) {
+		if p.ID == api.identity {
+			continue
+		}
+		providers = append(providers, p)
+	}
+	cancel()
+	a.Providers = len(providers)
+	if a.Providers == 0 {
+		return a
+	}
+	if !settings.Fetch {
+		a.Verdict = coreiface.AvailabilityProvided
+		return a
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, settings.Timeout)
+	defer cancel()
+	// the exchange also finds the providers itself, when the dials fail
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		if i == checkAvailabilityDials {
+			break
+		}
+		if len(p.Addrs) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(p peer.AddrInfo) {
+			defer wg.Done()
+			_ = api.peerHost.Connect(fetchCtx, p)
+		}(p)
+	}
+	wg.Wait()
+	// the exchange fetches the block from the network, even when it is in the
+	// local blockstore
+	if _, err := api.exchange.GetBlock(fetchCtx, c); err != nil {
+		a.Verdict = coreiface.AvailabilityUnreachable
+		a.Error = err.Error()
+		return a
+	}
+	a.Verdict = coreiface.AvailabilityRetrievable
+	return a
+}
+
 func provideKeys(ctx context.Context, r routing.Routing, cids []cid.Cid) error {
 	for _, c := range cids {
 		err := r.Provide(ctx, c, true)
@@ -0,0 +1,255 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/path"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/tracing"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	ping "github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// estimatePings is the number of pings of each provider.
+	estimatePings = 3
+	// estimateMaxDepth is the number of levels of a DAG walked to measure
+	// its depth.
+	estimateMaxDepth = 32
+)
+
+func (api *RoutingAPI) EstimateRetrieval(ctx context.Context, p path.Path, opts ...caopts.RoutingEstimateRetrievalOption) (coreiface.RetrievalEstimate, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.RoutingAPI", "EstimateRetrieval", trace.WithAttributes(attribute.String("path", p.String())))
+	defer span.End()
+
+	settings, err := caopts.RoutingEstimateRetrievalOptions(opts...)
+	if err != nil {
+		return coreiface.RetrievalEstimate{}, err
+	}
+	if settings.NumProviders < 1 {
+		return coreiface.RetrievalEstimate{}, fmt.Errorf("number of providers must be greater than 0")
+	}
+	if settings.SampleBlocks < 0 {
+		return coreiface.RetrievalEstimate{}, fmt.Errorf("number of sample blocks must not be negative")
+	}
+
+	err = api.checkOnline(false)
+	if err != nil {
+		return coreiface.RetrievalEstimate{}, err
+	}
+
+	rp, _, err := api.core().ResolvePath(ctx, p)
+	if err != nil {
+		return coreiface.RetrievalEstimate{}, err
+	}
+	c := rp.RootCid()
+	est := coreiface.RetrievalEstimate{Cid: c}
+
+	findCtx, cancel := context.WithTimeout(ctx, settings.Timeout)
+	var providers []peer.AddrInfo
+	for ai := range api.routing.FindProvidersAsync(findCtx, c, settings.NumProviders) {
+		if ai.ID == api.identity {
+			continue
+		}
+		providers = append(providers, ai)
+		est.Providers = append(est.Providers, coreiface.ProviderProbe{ID: ai.ID})
+	}
+	cancel()
+	if len(providers) == 0 {
+		return est, nil
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, settings.Timeout)
+	var wg sync.WaitGroup
+	for i := range providers {
+		wg.Add(1)
+		go func(probe *coreiface.ProviderProbe, ai peer.AddrInfo) {
+			defer wg.Done()
+			latency, err := api.pingProvider(pingCtx, ai)
+			if err != nil {
+				probe.Error = err.Error()
+				return
+			}
+			probe.Latency = latency
+		}(&est.Providers[i], providers[i])
+	}
+	wg.Wait()
+	cancel()
+
+	// the bytes the providers sent during the sample, from the ledgers of
+	// bitswap
+	bs, _ := api.exchange.(*bitswap.Bitswap)
+	received := make([]uint64, len(est.Providers))
+	if bs != nil {
+		for i, probe := range est.Providers {
+			received[i] = bs.LedgerForPeer(probe.ID).Recv
+		}
+	}
+
+	sampleCtx, cancel := context.WithTimeout(ctx, settings.Timeout)
+	defer cancel()
+	rootDuration, err := api.sampleDAG(sampleCtx, c, settings.SampleBlocks, &est)
+	if err != nil {
+		return coreiface.RetrievalEstimate{}, fmt.Errorf("fetching the root of %s: %w", c, err)
+	}
+
+	if bs != nil && est.SampleDuration > 0 {
+		for i := range est.Providers {
+			probe := &est.Providers[i]
+			probe.Received = bs.LedgerForPeer(probe.ID).Recv - received[i]
+			probe.Bandwidth = float64(probe.Received) / est.SampleDuration.Seconds()
+		}
+	}
+
+	if est.Bandwidth > 0 {
+		// a round trip per level of the DAG, and the transfer of its blocks
+		latency := medianLatency(est.Providers)
+		if latency == 0 {
+			latency = rootDuration
+		}
+		transfer := time.Duration(float64(est.Size) / est.Bandwidth * float64(time.Second))
+		est.Duration = time.Duration(est.Depth)*latency + transfer
+	}
+	return est, nil
+}
+
+// pingProvider connects to the provider ai, and returns the median round trip
+// time of its pings.
+func (api *RoutingAPI) pingProvider(ctx context.Context, ai peer.AddrInfo) (time.Duration, error) {
+	if len(ai.Addrs) > 0 {
+		if err := api.peerHost.Connect(ctx, ai); err != nil {
+			return 0, err
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var rtts []time.Duration
+	for res := range ping.Ping(ctx, api.peerHost, ai.ID) {
+		if res.Error != nil {
+			return 0, res.Error
+		}
+		rtts = append(rtts, res.RTT)
+		if len(rtts) == estimatePings {
+			break
+		}
+	}
+	if len(rtts) == 0 {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		return 0, errors.New("no ping answered")
+	}
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	return rtts[len(rtts)/2], nil
+}
+
+// sampleDAG fetches the root c of a DAG and its first n children from the
+// network, without storing them, and walks the first links of the DAG to
+// measure its depth. It returns how long fetching the root took.
+func (api *RoutingAPI) sampleDAG(ctx context.Context, c cid.Cid, n int, est *coreiface.RetrievalEstimate) (time.Duration, error) {
+	start := time.Now()
+	root, err := api.exchange.GetBlock(ctx, c)
+	if err != nil {
+		return 0, err
+	}
+	rootDuration := time.Since(start)
+	est.SampleBytes = uint64(len(root.RawData()))
+	if rootDuration > 0 {
+		est.Bandwidth = float64(est.SampleBytes) / rootDuration.Seconds()
+	}
+	est.Depth = 1
+
+	links, size, err := blockLinks(root)
+	if err != nil {
+		return 0, err
+	}
+	est.Size = size
+	if len(links) > n {
+		links = links[:n]
+	}
+
+	var first blocks.Block
+	if len(links) > 0 {
+		batchStart := time.Now()
+		blks, err := api.exchange.GetBlocks(ctx, links)
+		if err != nil {
+			return 0, err
+		}
+		var batchBytes uint64
+		for b := range blks {
+			batchBytes += uint64(len(b.RawData()))
+			if b.Cid().Equals(links[0]) {
+				first = b
+			}
+		}
+		est.SampleBytes += batchBytes
+		if d := time.Since(batchStart); batchBytes > 0 && d > 0 {
+			est.Bandwidth = float64(batchBytes) / d.Seconds()
+		}
+	}
+
+	for first != nil && est.Depth < estimateMaxDepth {
+		est.Depth++
+		links, _, err := blockLinks(first)
+		if err != nil || len(links) == 0 {
+			break
+		}
+		first, err = api.exchange.GetBlock(ctx, links[0])
+		if err != nil {
+			break
+		}
+		est.SampleBytes += uint64(len(first.RawData()))
+	}
+	est.SampleDuration = time.Since(start)
+	return rootDuration, nil
+}
+
+// blockLinks returns the links of the block b, and the size of its DAG, or of
+// b for the codecs that don't record it.
+func blockLinks(b blocks.Block) ([]cid.Cid, uint64, error) {
+	if b.Cid().Prefix().Codec != cid.DagProtobuf {
+		return nil, uint64(len(b.RawData())), nil
+	}
+	nd, err := dag.DecodeProtobufBlock(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	size, err := nd.Size()
+	if err != nil {
+		return nil, 0, err
+	}
+	links := make([]cid.Cid, len(nd.Links()))
+	for i, l := range nd.Links() {
+		links[i] = l.Cid
+	}
+	return links, size, nil
+}
+
+// medianLatency returns the median latency of the providers that were
+// pinged, zero when none was.
+func medianLatency(probes []coreiface.ProviderProbe) time.Duration {
+	var latencies []time.Duration
+	for _, p := range probes {
+		if p.Latency > 0 {
+			latencies = append(latencies, p.Latency)
+		}
+	}
+	if len(latencies) == 0 {
+		return 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies[len(latencies)/2]
+}
@@ -0,0 +1,54 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type SearchAPI CoreAPI
+
+func (api *SearchAPI) Query(ctx context.Context, q string, opts ...caopts.SearchQueryOption) ([]coreiface.SearchHit, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.SearchAPI", "Query", trace.WithAttributes(attribute.String("query", q)))
+	defer span.End()
+
+	settings, err := caopts.SearchQueryOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if api.fullText == nil {
+		return nil, errors.New("full-text search is disabled, see Experimental.FullTextSearch")
+	}
+
+	found, err := api.fullText.Query(ctx, q, settings.Limit)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]coreiface.SearchHit, len(found))
+	for i, h := range found {
+		hits[i] = coreiface.SearchHit{
+			Source:  h.Source,
+			Path:    h.Path,
+			Cid:     h.Doc.Cid,
+			Root:    h.Doc.Root,
+			Title:   h.Doc.Title,
+			Format:  h.Doc.Format,
+			Score:   h.Score,
+			Snippet: h.Snippet,
+		}
+	}
+	return hits, nil
+}
+
+// pinsChanged schedules the full-text indexing of the recursive pins, when
+// enabled.
+func (api *CoreAPI) pinsChanged() {
+	if api.fullText != nil {
+		api.fullText.UpdatePins()
+	}
+}
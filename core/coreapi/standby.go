@@ -0,0 +1,60 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type StandbyAPI CoreAPI
+
+var errStandbyDisabled = errors.New("standby is not configured (Standby), or the node is offline")
+
+func (api *StandbyAPI) Status(ctx context.Context) (coreiface.StandbyStatus, error) {
+	_, span := tracing.Span(ctx, "CoreAPI.StandbyAPI", "Status")
+	defer span.End()
+
+	if api.standby == nil {
+		return coreiface.StandbyStatus{}, errStandbyDisabled
+	}
+	st := api.standby.Status()
+	return coreiface.StandbyStatus{
+		Primary:   st.Primary,
+		Standbys:  st.Standbys,
+		Promoted:  st.Promoted,
+		LastSync:  st.LastSync,
+		LastError: st.LastError,
+		Pins:      st.Pins,
+		Files:     st.Files,
+		Keys:      st.Keys,
+	}, nil
+}
+
+func (api *StandbyAPI) Sync(ctx context.Context) error {
+	ctx, span := tracing.Span(ctx, "CoreAPI.StandbyAPI", "Sync")
+	defer span.End()
+
+	if api.standby == nil {
+		return errStandbyDisabled
+	}
+	return api.standby.Sync(ctx)
+}
+
+func (api *StandbyAPI) Promote(ctx context.Context, opts ...caopts.StandbyPromoteOption) error {
+	settings, err := caopts.StandbyPromoteOptions(opts...)
+	if err != nil {
+		return err
+	}
+	ctx, span := tracing.Span(ctx, "CoreAPI.StandbyAPI", "Promote", trace.WithAttributes(attribute.Bool("force", settings.Force)))
+	defer span.End()
+
+	if api.standby == nil {
+		return errStandbyDisabled
+	}
+	return api.standby.Promote(ctx, settings.Force)
+}
@@ -5,6 +5,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/ipfs/kubo/core/connevents"
 	coreiface "github.com/ipfs/kubo/core/coreiface"
 	"github.com/ipfs/kubo/tracing"
 	inet "github.com/libp2p/go-libp2p/core/network"
@@ -74,6 +75,9 @@ func (api *SwarmAPI) Disconnect(ctx context.Context, addr ma.Multiaddr) error {
 		if net.Connectedness(id) != inet.Connected {
 			return coreiface.ErrNotConnected
 		}
+		if api.connEvents != nil {
+			api.connEvents.Closing(id, connevents.Local)
+		}
 		if err := net.ClosePeer(id); err != nil {
 			return err
 		}
@@ -84,6 +88,9 @@ func (api *SwarmAPI) Disconnect(ctx context.Context, addr ma.Multiaddr) error {
 			continue
 		}
 
+		if api.connEvents != nil {
+			api.connEvents.Closing(id, connevents.Local)
+		}
 		return conn.Close()
 	}
 	return coreiface.ErrConnNotFound
@@ -167,6 +174,65 @@ func (api *SwarmAPI) Peers(ctx context.Context) ([]coreiface.ConnectionInfo, err
 	return out, nil
 }
 
+func (api *SwarmAPI) KnownPeers(ctx context.Context) ([]coreiface.KnownPeer, error) {
+	_, span := tracing.Span(ctx, "CoreAPI.SwarmAPI", "KnownPeers")
+	defer span.End()
+
+	if api.peerHost == nil {
+		return nil, coreiface.ErrOffline
+	}
+
+	net := api.peerHost.Network()
+	ps := net.Peerstore()
+	peers := ps.Peers()
+	sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+
+	out := make([]coreiface.KnownPeer, 0, len(peers))
+	for _, p := range peers {
+		if p == api.identity {
+			continue
+		}
+		protos, err := ps.GetProtocols(p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, coreiface.KnownPeer{
+			ID:        p,
+			Addrs:     ps.Addrs(p),
+			Protocols: protos,
+			Connected: net.Connectedness(p) == inet.Connected,
+		})
+	}
+	return out, nil
+}
+
+func (api *SwarmAPI) ForgetPeers(ctx context.Context, peers ...peer.ID) ([]peer.ID, error) {
+	_, span := tracing.Span(ctx, "CoreAPI.SwarmAPI", "ForgetPeers", trace.WithAttributes(attribute.Int("peers", len(peers))))
+	defer span.End()
+
+	if api.peerHost == nil {
+		return nil, coreiface.ErrOffline
+	}
+
+	net := api.peerHost.Network()
+	ps := net.Peerstore()
+	if len(peers) == 0 {
+		peers = ps.Peers()
+	}
+
+	var removed []peer.ID
+	for _, p := range peers {
+		if p == api.identity || net.Connectedness(p) == inet.Connected {
+			continue
+		}
+		// RemovePeer leaves the addresses of the peer
+		ps.ClearAddrs(p)
+		ps.RemovePeer(p)
+		removed = append(removed, p)
+	}
+	return removed, nil
+}
+
 func (ci *connInfo) ID() peer.ID {
 	return ci.peer
 }
@@ -69,13 +69,21 @@ func (NodeProvider) MakeAPISwarm(t *testing.T, ctx context.Context, fullIdentity
 		c.Addresses.Swarm = []string{fmt.Sprintf("/ip4/18.0.%d.1/tcp/4001", i)}
 		c.Identity = ident
 		c.Experimental.FilestoreEnabled = true
+		c.Experimental.UrlstoreEnabled = true
+		c.Experimental.ContentIndex = true
+		c.Experimental.FullTextSearch = true
+		c.Experimental.Libp2pStreamMounting = true
+		c.Experimental.KVStore = true
+		c.Experimental.FastCDCChunker = true
 
 		ds := syncds.MutexWrap(datastore.NewMapDatastore())
+		fm := filestore.NewFileManager(ds, filepath.Dir(os.TempDir()))
+		fm.AllowUrls = true
 		r := &repo.Mock{
 			C: c,
 			D: ds,
 			K: keystore.NewMemKeystore(),
-			F: filestore.NewFileManager(ds, filepath.Dir(os.TempDir())),
+			F: fm,
 		}
 
 		node, err := core.NewNode(ctx, &core.BuildCfg{
@@ -0,0 +1,182 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/boxo/files"
+	keystore "github.com/ipfs/boxo/keystore"
+	"github.com/ipfs/boxo/mfs"
+	"github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/coreapi"
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	opt "github.com/ipfs/kubo/core/coreiface/options"
+	mock "github.com/ipfs/kubo/core/mock"
+	"github.com/ipfs/kubo/core/node/libp2p"
+	"github.com/ipfs/kubo/repo"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+// clockNode builds a node whose clock is clk.
+func clockNode(t *testing.T, ctx context.Context, clk clock.Clock, online bool) (*core.IpfsNode, coreiface.CoreAPI) {
+	t.Helper()
+	sk, pk, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kbytes, err := crypto.MarshalPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := config.Config{}
+	c.Addresses.Swarm = []string{"/ip4/18.0.0.1/tcp/4001"}
+	c.Identity = config.Identity{PeerID: id.String(), PrivKey: base64.StdEncoding.EncodeToString(kbytes)}
+	r := &repo.Mock{
+		C: c,
+		D: syncds.MutexWrap(datastore.NewMapDatastore()),
+		K: keystore.NewMemKeystore(),
+	}
+
+	cfg := &core.BuildCfg{Repo: r, Clock: clk, Online: online}
+	if online {
+		cfg.Host = mock.MockHostOption(mocknet.New())
+		cfg.Routing = libp2p.NilRouterOption
+	}
+	n, err := core.NewNode(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { n.Close() })
+	api, err := coreapi.NewCoreAPI(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n, api
+}
+
+func TestClockIPNS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a clock long before the wall clock: the records it publishes expired
+	// long ago for the wall clock
+	clk := clock.NewMock()
+	clk.Set(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	_, api := clockNode(t, ctx, clk, false)
+
+	p, err := api.Unixfs().Add(ctx, files.NewBytesFile([]byte("published")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := api.Name().Publish(ctx, p, opt.Name.AllowOffline(true), opt.Name.ValidTime(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := api.Name().Resolve(ctx, name.String(), opt.Name.Cache(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.String() != p.String() {
+		t.Fatalf("expected %s, got %s", p, resolved)
+	}
+
+	clk.Add(2 * time.Hour)
+	if _, err := api.Name().Resolve(ctx, name.String(), opt.Name.Cache(false)); err == nil {
+		t.Fatal("expected the record to expire with the clock")
+	}
+}
+
+func TestClockPinGrace(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clk := clock.NewMock()
+	clk.Set(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	_, api := clockNode(t, ctx, clk, false)
+
+	p, err := api.Unixfs().Add(ctx, files.NewBytesFile([]byte("removed with a grace period")), opt.Unixfs.Pin(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Pin().Rm(ctx, p, opt.Pin.Grace(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	removals, err := api.Pin().Removed(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removals) != 1 || !removals[0].Expires.Equal(clk.Now().Add(time.Hour)) {
+		t.Fatalf("expected one removal expiring in an hour, got %v", removals)
+	}
+
+	clk.Add(2 * time.Hour)
+	removals, err = api.Pin().Removed(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removals) != 0 {
+		t.Fatalf("expected the grace period to expire with the clock, got %v", removals)
+	}
+	if err := api.Pin().Restore(ctx, p); err == nil {
+		t.Fatal("restored a pin whose grace period expired")
+	}
+}
+
+func TestClockMFSTTL(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clk := clock.NewMock()
+	clk.Set(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	n, api := clockNode(t, ctx, clk, true)
+
+	p, err := api.Unixfs().Add(ctx, files.NewBytesFile([]byte("expiring")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := api.Dag().Get(ctx, p.RootCid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.PutNode(n.FilesRoot, "/expiring", nd); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.MFSExpiry.Set(ctx, "/expiring", time.Hour, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// the path is kept until the clock reaches its TTL, however long the
+	// test waits
+	time.Sleep(50 * time.Millisecond)
+	if _, err := mfs.Lookup(n.FilesRoot, "/expiring"); err != nil {
+		t.Fatalf("path removed before its TTL: %s", err)
+	}
+
+	// the reaper waits on the clock: move it until the path is removed
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		clk.Add(time.Hour)
+		if _, err := mfs.Lookup(n.FilesRoot, "/expiring"); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("path not removed after its TTL")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -1,19 +1,30 @@
 package coreapi
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"math/bits"
+	"net/url"
+	"os"
+	gopath "path"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/ipfs/kubo/core"
 	"github.com/ipfs/kubo/tracing"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
+	chunker "github.com/ipfs/kubo/core/chunker"
 	"github.com/ipfs/kubo/core/coreunix"
+	"github.com/ipfs/kubo/core/localfiles"
 
 	blockservice "github.com/ipfs/boxo/blockservice"
 	bstore "github.com/ipfs/boxo/blockstore"
+	offlinexch "github.com/ipfs/boxo/exchange/offline"
 	"github.com/ipfs/boxo/files"
 	filestore "github.com/ipfs/boxo/filestore"
 	merkledag "github.com/ipfs/boxo/ipld/merkledag"
@@ -23,6 +34,7 @@ import (
 	uio "github.com/ipfs/boxo/ipld/unixfs/io"
 	mfs "github.com/ipfs/boxo/mfs"
 	"github.com/ipfs/boxo/path"
+	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
 	cidutil "github.com/ipfs/go-cidutil"
 	ipld "github.com/ipfs/go-ipld-format"
@@ -66,6 +78,22 @@ func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options
 	if err != nil {
 		return path.ImmutablePath{}, err
 	}
+	// bad chunker specs fail before anything is added
+	if err := chunker.Validate(settings.Chunker); err != nil {
+		return path.ImmutablePath{}, err
+	}
+
+	// a graceful shutdown waits for the add to complete
+	ctx, done, err := api.startOperation(ctx)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+	defer done()
+
+	if settings.Car {
+		span.SetAttributes(attribute.Bool("car", true), attribute.Bool("pin", settings.Pin))
+		return api.addCar(ctx, files, settings)
+	}
 
 	span.SetAttributes(
 		attribute.String("chunker", settings.Chunker),
@@ -75,12 +103,19 @@ func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options
 		attribute.Bool("rawleaves", settings.RawLeaves),
 		attribute.Bool("rawleavesset", settings.RawLeavesSet),
 		attribute.Int("layout", int(settings.Layout)),
+		attribute.Int("maxfilelinks", settings.MaxFileLinks),
+		attribute.Int("maxdirectorylinks", settings.MaxDirectoryLinks),
+		attribute.Int("hamtfanout", settings.HAMTFanout),
 		attribute.Bool("pin", settings.Pin),
 		attribute.Bool("onlyhash", settings.OnlyHash),
 		attribute.Bool("fscache", settings.FsCache),
 		attribute.Bool("nocopy", settings.NoCopy),
+		attribute.Bool("incremental", settings.Incremental),
 		attribute.Bool("silent", settings.Silent),
 		attribute.Bool("progress", settings.Progress),
+		attribute.Bool("preservemode", settings.PreserveMode),
+		attribute.Bool("preservemtime", settings.PreserveMtime),
+		attribute.Bool("wrap", settings.Wrap),
 	)
 
 	cfg, err := api.repo.Config()
@@ -96,6 +131,10 @@ func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options
 	//	return
 	//}
 
+	if chunker.Experimental(settings.Chunker) && !cfg.Experimental.FastCDCChunker {
+		return path.ImmutablePath{}, chunker.ErrExperimental
+	}
+
 	if settings.NoCopy && !(cfg.Experimental.FilestoreEnabled || cfg.Experimental.UrlstoreEnabled) {
 		return path.ImmutablePath{}, fmt.Errorf("either the filestore or the urlstore must be enabled to use nocopy, see: https://github.com/ipfs/kubo/blob/master/docs/experimental-features.md#ipfs-filestore")
 	}
@@ -118,7 +157,15 @@ func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options
 	}
 
 	bserv := blockservice.New(addblockstore, exch) // hash security 001
-	dserv := merkledag.NewDAGService(bserv)
+	var dserv ipld.DAGService = merkledag.NewDAGService(bserv)
+
+	// a dry run accounts for the blocks the add would write
+	var dryRun *dryRunCounter
+	if settings.DryRun != nil {
+		*settings.DryRun = options.DryRunStats{}
+		dryRun = &dryRunCounter{local: api.blockstore, stats: settings.DryRun, nodes: make(map[cid.Cid]dryRunNode)}
+		dserv = &dryRunDagService{DAGService: dserv, c: dryRun}
+	}
 
 	// add a sync call to the DagService
 	// this ensures that data written to the DagService is persisted to the underlying datastore
@@ -152,11 +199,35 @@ func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options
 		fileAdder.Out = settings.Events
 		fileAdder.Progress = settings.Progress
 	}
+	fileAdder.ProgressEvents = settings.ProgressEvents
+	fileAdder.Clock = api.clock
 	fileAdder.Pin = settings.Pin && !settings.OnlyHash
 	fileAdder.Silent = settings.Silent
 	fileAdder.RawLeaves = settings.RawLeaves
 	fileAdder.NoCopy = settings.NoCopy
 	fileAdder.CidBuilder = prefix
+	fileAdder.MemoryBudget = settings.MemoryBudget
+	fileAdder.PreserveMode = settings.PreserveMode
+	fileAdder.PreserveMtime = settings.PreserveMtime
+	fileAdder.FileMode = settings.Mode
+	fileAdder.FileMtime = settings.Mtime
+	fileAdder.MaxFileLinks = settings.MaxFileLinks
+	fileAdder.MaxDirectoryLinks = settings.MaxDirectoryLinks
+	fileAdder.HAMTFanout = settings.HAMTFanout
+	fileAdder.Concurrency = settings.AddConcurrency
+	fileAdder.PreserveOrder = settings.PreserveOrder
+	if r, ok := api.repo.(interface{ Path() string }); ok {
+		// keep the spilled blocks out of the memory backed /tmp of small
+		// devices
+		fileAdder.TempDir = r.Path()
+	}
+	if settings.Incremental {
+		fileAdder.Index = coreunix.NewAddIndex(api.repo.Datastore(), api.blockstore)
+	}
+	indexed := api.contentIndex != nil && !settings.OnlyHash
+	if indexed {
+		fileAdder.ModTimes = make(map[string]time.Time)
+	}
 
 	switch settings.Layout {
 	case options.BalancedLayout:
@@ -182,7 +253,11 @@ func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options
 		if err != nil {
 			return path.ImmutablePath{}, err
 		}
-		mr, err := mfs.NewRoot(ctx, md, emptyDirNode, nil)
+		var mdserv ipld.DAGService = md
+		if dryRun != nil {
+			mdserv = &dryRunDagService{DAGService: md, c: dryRun}
+		}
+		mr, err := mfs.NewRoot(ctx, mdserv, emptyDirNode, nil)
 		if err != nil {
 			return path.ImmutablePath{}, err
 		}
@@ -190,24 +265,221 @@ func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options
 		fileAdder.SetMfsRoot(mr)
 	}
 
+	policy, err := localfiles.FromSettings(settings)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+	if files, err = localfiles.Apply(files, policy); err != nil {
+		return path.ImmutablePath{}, err
+	}
+	if settings.Wrap {
+		files = wrapInDirectory(settings.Name, files)
+	}
+
 	nd, err := fileAdder.AddAllAndPin(ctx, files)
+	if settings.Stats != nil {
+		*settings.Stats = fileAdder.Stats()
+	}
 	if err != nil {
 		return path.ImmutablePath{}, err
 	}
+	if dryRun != nil {
+		if err := dryRun.count(ctx, nd.Cid()); err != nil {
+			return path.ImmutablePath{}, err
+		}
+	}
 
 	if !settings.OnlyHash {
 		if err := api.provider.Provide(nd.Cid()); err != nil {
 			return path.ImmutablePath{}, err
 		}
 	}
+	if fileAdder.Pin {
+		api.core().pinsChanged()
+	}
+
+	if settings.ProgressEvents != nil {
+		size, err := nd.Size()
+		if err != nil {
+			return path.ImmutablePath{}, err
+		}
+		if err := api.emitRoot(ctx, settings, path.FromCid(nd.Cid()), size); err != nil {
+			return path.ImmutablePath{}, err
+		}
+	}
+
+	if indexed {
+		// the content is added, failing to index it doesn't fail the add
+		if err := api.contentIndex.IndexAdd(ctx, nd.Cid(), settings.Name, fileAdder.ModTimes); err != nil {
+			log.Errorf("indexing %s: %s", nd.Cid(), err)
+		}
+	}
 
 	return path.FromCid(nd.Cid()), nil
 }
 
-func (api *UnixfsAPI) Get(ctx context.Context, p path.Path) (files.Node, error) {
+// wrapInDirectory returns a directory holding nd as its single entry, name.
+func wrapInDirectory(name string, nd files.Node) files.Directory {
+	return files.NewSliceDirectory([]files.DirEntry{files.FileEntry(name, nd)})
+}
+
+// AddURL adds the content at an http or https URL with the urlstore.
+func (api *UnixfsAPI) AddURL(ctx context.Context, rawURL string, opts ...options.UnixfsAddOption) (path.ImmutablePath, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.UnixfsAPI", "AddURL", trace.WithAttributes(attribute.String("url", rawURL)))
+	defer span.End()
+
+	u, err := parseAddURL(rawURL)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+	cfg, err := api.repo.Config()
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+	if !cfg.Experimental.UrlstoreEnabled {
+		return path.ImmutablePath{}, errors.New("the urlstore is not enabled, see: https://github.com/ipfs/kubo/blob/master/docs/experimental-features.md#ipfs-urlstore")
+	}
+
+	return api.Add(ctx, files.NewWebFile(u), append(opts, options.Unixfs.Nocopy(true))...)
+}
+
+// parseAddURL parses the URL of AddURL, which must be http or https.
+func parseAddURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an http or https URL", rawURL)
+	}
+	return u, nil
+}
+
+// addCar stores the UnixFS DAG of a CAR, once verified: the CAR must have a
+// single root, hold valid blocks, and the DAG under the root must be complete
+// and made of UnixFS nodes.
+func (api *UnixfsAPI) addCar(ctx context.Context, node files.Node, settings *options.UnixfsAddSettings) (path.ImmutablePath, error) {
+	f, ok := node.(files.File)
+	if !ok {
+		return path.ImmutablePath{}, errors.New("a CAR must be a file, not a directory")
+	}
+
+	// the GC must not remove the blocks before the root is pinned
+	defer api.blockstore.PinLock(ctx).Unlock(ctx)
+
+	in := &carInspector{
+		r:      &countingReader{r: bufio.NewReader(f)},
+		codecs: make(map[string]uint64),
+		strict: true,
+	}
+	in.onBlock = func(c cid.Cid, data []byte) error {
+		blk, err := blocks.NewBlockWithCid(data, c)
+		if err != nil {
+			return err
+		}
+		return api.blockstore.Put(ctx, blk)
+	}
+	err := in.inspect(ctx)
+	if errors.Is(err, errMalformedCar) && len(in.res.Errors) > 0 {
+		e := in.res.Errors[len(in.res.Errors)-1]
+		return path.ImmutablePath{}, fmt.Errorf("malformed CAR at offset %d: %s", e.Offset, e.Error)
+	}
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+	if len(in.res.Roots) != 1 {
+		return path.ImmutablePath{}, fmt.Errorf("a CAR with a single root is expected, it has %d", len(in.res.Roots))
+	}
+	root := in.res.Roots[0]
+
+	// the DAG is verified from the local blocks only
+	dserv := merkledag.NewDAGService(blockservice.New(api.blockstore, offlinexch.Exchange(api.blockstore)))
+	var size uint64
+	getLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+		nd, err := dserv.Get(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("the DAG of the CAR is incomplete: %w", err)
+		}
+		size += uint64(len(nd.RawData()))
+		switch nd := nd.(type) {
+		case *merkledag.RawNode:
+			return nil, nil
+		case *merkledag.ProtoNode:
+			if _, err := ft.FSNodeFromBytes(nd.Data()); err != nil {
+				return nil, fmt.Errorf("%s is not a UnixFS node: %w", c, err)
+			}
+			return nd.Links(), nil
+		default:
+			return nil, fmt.Errorf("%s is not a UnixFS node", c)
+		}
+	}
+	if err := merkledag.Walk(ctx, getLinks, root, cid.NewSet().Visit); err != nil {
+		return path.ImmutablePath{}, err
+	}
+
+	if settings.Pin {
+		nd, err := dserv.Get(ctx, root)
+		if err != nil {
+			return path.ImmutablePath{}, err
+		}
+		if err := api.pinning.Pin(ctx, nd, true, settings.Name); err != nil {
+			return path.ImmutablePath{}, err
+		}
+		if err := api.pinning.Flush(ctx); err != nil {
+			return path.ImmutablePath{}, err
+		}
+		api.core().pinsChanged()
+	}
+	if err := api.provider.Provide(root); err != nil {
+		return path.ImmutablePath{}, err
+	}
+
+	if api.contentIndex != nil {
+		// the content is added, failing to index it doesn't fail the add
+		if err := api.contentIndex.IndexAdd(ctx, root, settings.Name, nil); err != nil {
+			log.Errorf("indexing %s: %s", root, err)
+		}
+	}
+
+	p := path.FromCid(root)
+	if settings.Events != nil && !settings.Silent {
+		select {
+		case settings.Events <- &coreiface.AddEvent{Path: p, Size: strconv.FormatUint(size, 10)}:
+		case <-ctx.Done():
+			return path.ImmutablePath{}, ctx.Err()
+		}
+	}
+	if err := api.emitRoot(ctx, settings, p, size); err != nil {
+		return path.ImmutablePath{}, err
+	}
+	return p, nil
+}
+
+// emitRoot sends the RootEmitted event of an add, of the root p, when the
+// typed events are requested.
+func (api *UnixfsAPI) emitRoot(ctx context.Context, settings *options.UnixfsAddSettings, p path.ImmutablePath, size uint64) error {
+	if settings.ProgressEvents == nil {
+		return nil
+	}
+	select {
+	case settings.ProgressEvents <- options.AddProgressEvent{Kind: options.RootEmitted, Path: p, Size: size, Time: api.clock.Now()}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (api *UnixfsAPI) Get(ctx context.Context, p path.Path, opts ...options.UnixfsGetOption) (files.Node, error) {
 	ctx, span := tracing.Span(ctx, "CoreAPI.UnixfsAPI", "Get", trace.WithAttributes(attribute.String("path", p.String())))
 	defer span.End()
 
+	settings, err := options.UnixfsGetOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Bool("progressive", settings.Progressive), attribute.Bool("archive", settings.Archive))
+
 	ses := api.core().getSession(ctx)
 
 	nd, err := ses.ResolveNode(ctx, p)
@@ -215,7 +487,39 @@ func (api *UnixfsAPI) Get(ctx context.Context, p path.Path) (files.Node, error)
 		return nil, err
 	}
 
-	return unixfile.NewUnixfsFile(ctx, ses.dag, nd)
+	ranged := settings.Offset != 0 || settings.Length >= 0
+	if ranged {
+		span.SetAttributes(attribute.Int64("offset", settings.Offset), attribute.Int64("length", settings.Length))
+	}
+
+	var f files.Node
+	if settings.Progressive {
+		var end uint64
+		if settings.Length >= 0 {
+			end = uint64(settings.Offset + settings.Length)
+		}
+		if pf := newProgressiveFile(ctx, ses.dag, nd, settings.OnRange, end); pf != nil {
+			f = pf
+		}
+	}
+	if f == nil {
+		if f, err = unixfile.NewUnixfsFile(ctx, ses.dag, nd); err != nil {
+			return nil, err
+		}
+	}
+	if settings.Archive {
+		return newArchiveFile(f, gopath.Base(p.String()), settings.Compression)
+	}
+	if !ranged {
+		return f, nil
+	}
+
+	file, ok := f.(files.File)
+	if !ok {
+		f.Close()
+		return nil, coreiface.ErrIsDir
+	}
+	return newRangeFile(file, settings.Offset, settings.Length)
 }
 
 // Ls returns the contents of an IPFS or IPNS object(s) at path p, with the format:
@@ -229,7 +533,11 @@ func (api *UnixfsAPI) Ls(ctx context.Context, p path.Path, opts ...options.Unixf
 		return nil, err
 	}
 
-	span.SetAttributes(attribute.Bool("resolvechildren", settings.ResolveChildren))
+	if settings.ResolveMimeType {
+		settings.ResolveChildren = true
+	}
+
+	span.SetAttributes(attribute.Bool("resolvechildren", settings.ResolveChildren), attribute.Bool("resolvemimetype", settings.ResolveMimeType))
 
 	ses := api.core().getSession(ctx)
 	uses := (*UnixfsAPI)(ses)
@@ -239,6 +547,21 @@ func (api *UnixfsAPI) Ls(ctx context.Context, p path.Path, opts ...options.Unixf
 		return nil, err
 	}
 
+	if settings.Offset > 0 || settings.Limit > 0 || settings.Cursor != "" {
+		span.SetAttributes(attribute.Int("offset", settings.Offset), attribute.Int("limit", settings.Limit), attribute.String("cursor", settings.Cursor))
+		return uses.lsPage(ctx, dagnode, settings)
+	}
+
+	if settings.PreservedOrder {
+		if order, ok := coreunix.EntryOrder(dagnode); ok {
+			links, err := uses.orderedLinks(ctx, dagnode, order)
+			if err != nil {
+				return nil, err
+			}
+			return uses.lsFromLinks(ctx, links, settings)
+		}
+	}
+
 	dir, err := uio.NewDirectoryFromNode(ses.dag, dagnode)
 	if err == uio.ErrNotADir {
 		return uses.lsFromLinks(ctx, dagnode.Links(), settings)
@@ -250,6 +573,165 @@ func (api *UnixfsAPI) Ls(ctx context.Context, p path.Path, opts ...options.Unixf
 	return uses.lsFromLinksAsync(ctx, dir, settings)
 }
 
+func (api *UnixfsAPI) LsShards(ctx context.Context, p path.Path) ([]coreiface.ShardInfo, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.UnixfsAPI", "LsShards", trace.WithAttributes(attribute.String("path", p.String())))
+	defer span.End()
+
+	ses := api.core().getSession(ctx)
+	nd, err := ses.ResolveNode(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	var shards []coreiface.ShardInfo
+	if err := lsShards(ctx, ses.dag, nd, 0, &shards); err != nil {
+		return nil, err
+	}
+	return shards, nil
+}
+
+func (api *UnixfsAPI) Mv(ctx context.Context, src, dst string, opts ...options.UnixfsMvOption) error {
+	ctx, span := tracing.Span(ctx, "CoreAPI.UnixfsAPI", "Mv", trace.WithAttributes(attribute.String("src", src), attribute.String("dst", dst)))
+	defer span.End()
+
+	settings, err := options.UnixfsMvOptions(opts...)
+	if err != nil {
+		return err
+	}
+	span.SetAttributes(attribute.Bool("parents", settings.Parents), attribute.Bool("overwrite", settings.Overwrite))
+
+	moved, err := coreunix.Mv(api.filesRoot, src, dst, settings.Parents, settings.Overwrite)
+	if err != nil {
+		return err
+	}
+	// the TTLs follow their paths
+	if err := api.mfsExpiry.Move(ctx, src, moved); err != nil {
+		return err
+	}
+	return api.mfsFlusher.FlushDir(ctx, "/")
+}
+
+func (api *UnixfsAPI) Chmod(ctx context.Context, p string, mode os.FileMode, opts ...options.UnixfsMetaOption) error {
+	ctx, span := tracing.Span(ctx, "CoreAPI.UnixfsAPI", "Chmod", trace.WithAttributes(attribute.String("path", p), attribute.String("mode", mode.String())))
+	defer span.End()
+
+	settings, err := options.UnixfsMetaOptions(opts...)
+	if err != nil {
+		return err
+	}
+	span.SetAttributes(attribute.Bool("recursive", settings.Recursive))
+
+	if err := coreunix.Chmod(ctx, api.filesRoot, api.dag, p, mode, settings.Recursive); err != nil {
+		return err
+	}
+	return api.mfsFlusher.Flush(ctx, p)
+}
+
+func (api *UnixfsAPI) Symlink(ctx context.Context, target, linkPath string, opts ...options.UnixfsSymlinkOption) error {
+	ctx, span := tracing.Span(ctx, "CoreAPI.UnixfsAPI", "Symlink", trace.WithAttributes(attribute.String("target", target), attribute.String("linkpath", linkPath)))
+	defer span.End()
+
+	settings, err := options.UnixfsSymlinkOptions(opts...)
+	if err != nil {
+		return err
+	}
+	span.SetAttributes(attribute.Bool("parents", settings.Parents))
+
+	if err := coreunix.Symlink(api.filesRoot, target, linkPath, settings.Parents); err != nil {
+		return err
+	}
+	return api.mfsFlusher.Flush(ctx, linkPath)
+}
+
+func (api *UnixfsAPI) Touch(ctx context.Context, p string, opts ...options.UnixfsMetaOption) error {
+	ctx, span := tracing.Span(ctx, "CoreAPI.UnixfsAPI", "Touch", trace.WithAttributes(attribute.String("path", p)))
+	defer span.End()
+
+	settings, err := options.UnixfsMetaOptions(opts...)
+	if err != nil {
+		return err
+	}
+	mtime := settings.ModTime
+	if mtime.IsZero() {
+		mtime = api.clock.Now()
+	}
+	span.SetAttributes(attribute.Bool("recursive", settings.Recursive), attribute.String("mtime", mtime.String()))
+
+	if err := coreunix.Touch(ctx, api.filesRoot, api.dag, p, mtime, settings.Recursive); err != nil {
+		return err
+	}
+	return api.mfsFlusher.Flush(ctx, p)
+}
+
+func (api *UnixfsAPI) Flush(ctx context.Context, p string) (path.ImmutablePath, error) {
+	if p == "" {
+		p = "/"
+	}
+	ctx, span := tracing.Span(ctx, "CoreAPI.UnixfsAPI", "Flush", trace.WithAttributes(attribute.String("path", p)))
+	defer span.End()
+
+	// end the flush epoch of the batched changes
+	if err := api.mfsFlusher.FlushPending(ctx); err != nil {
+		return path.ImmutablePath{}, err
+	}
+	nd, err := mfs.FlushPath(ctx, api.filesRoot, p)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+	return path.FromCid(nd.Cid()), nil
+}
+
+// lsShards appends the shard nd at depth, and the shards under it, to out.
+func lsShards(ctx context.Context, ng ipld.NodeGetter, nd ipld.Node, depth int, out *[]coreiface.ShardInfo) error {
+	pn, ok := nd.(*merkledag.ProtoNode)
+	if !ok {
+		return fmt.Errorf("%s is not a HAMT sharded directory", nd.Cid())
+	}
+	fsn, err := ft.FSNodeFromBytes(pn.Data())
+	if err != nil {
+		return err
+	}
+	if fsn.Type() != ft.THAMTShard {
+		return fmt.Errorf("%s is not a HAMT sharded directory", nd.Cid())
+	}
+
+	info := coreiface.ShardInfo{
+		Cid:    nd.Cid(),
+		Depth:  depth,
+		Fanout: int(fsn.Fanout()),
+		Size:   len(pn.RawData()),
+	}
+	for _, b := range fsn.Data() {
+		info.Buckets += bits.OnesCount8(b)
+	}
+
+	// the links to the shards under this one are named with the index of
+	// their bucket alone, the links to the entries with the index followed
+	// by the name of the entry
+	padLen := len(fmt.Sprintf("%X", info.Fanout-1))
+	var children []*ipld.Link
+	for _, l := range pn.Links() {
+		if len(l.Name) == padLen {
+			children = append(children, l)
+		} else {
+			info.Entries++
+		}
+	}
+	info.Children = len(children)
+	*out = append(*out, info)
+
+	for _, l := range children {
+		child, err := l.GetNode(ctx, ng)
+		if err != nil {
+			return err
+		}
+		if err := lsShards(ctx, ng, child, depth+1, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (api *UnixfsAPI) processLink(ctx context.Context, linkres ft.LinkResult, settings *options.UnixfsLsSettings) coreiface.DirEntry {
 	ctx, span := tracing.Span(ctx, "CoreAPI.UnixfsAPI", "ProcessLink")
 	defer span.End()
@@ -305,6 +787,14 @@ func (api *UnixfsAPI) processLink(ctx context.Context, linkres ft.LinkResult, se
 		}
 	}
 
+	if settings.ResolveMimeType && lnk.Type == coreiface.TFile && lnk.Err == nil {
+		mimeType, err := api.mimeTypes.MimeType(ctx, api.dag, lnk.Cid)
+		if err != nil {
+			lnk.Err = err
+		}
+		lnk.MimeType = mimeType
+	}
+
 	return lnk
 }
 
@@ -340,6 +830,94 @@ func (api *UnixfsAPI) core() *CoreAPI {
 	return (*CoreAPI)(api)
 }
 
+// dryRunCounter accounts for the distinct blocks of the DAG of a dry run.
+// The nodes are recorded as they are written, and only the ones reachable
+// from the root of the add are counted: MFS writes intermediate directories
+// that aren't part of the resulting DAG.
+type dryRunCounter struct {
+	local bstore.Blockstore
+	stats *options.DryRunStats
+
+	lk    sync.Mutex
+	nodes map[cid.Cid]dryRunNode
+}
+
+type dryRunNode struct {
+	size  uint64
+	data  uint64
+	links []cid.Cid
+}
+
+func (c *dryRunCounter) record(nd ipld.Node) {
+	n := dryRunNode{size: uint64(len(nd.RawData()))}
+	switch nd := nd.(type) {
+	case *merkledag.RawNode:
+		n.data = n.size
+	case *merkledag.ProtoNode:
+		if fsn, err := ft.FSNodeFromBytes(nd.Data()); err == nil {
+			n.data = uint64(len(fsn.Data()))
+		}
+	}
+	for _, l := range nd.Links() {
+		n.links = append(n.links, l.Cid)
+	}
+
+	c.lk.Lock()
+	c.nodes[nd.Cid()] = n
+	c.lk.Unlock()
+}
+
+// count fills the stats with the blocks of the DAG under root.
+func (c *dryRunCounter) count(ctx context.Context, root cid.Cid) error {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	seen := cid.NewSet()
+	queue := []cid.Cid{root}
+	for len(queue) > 0 {
+		k := queue[0]
+		queue = queue[1:]
+		n, ok := c.nodes[k]
+		if !ok || !seen.Visit(k) {
+			continue
+		}
+		queue = append(queue, n.links...)
+
+		c.stats.Blocks++
+		c.stats.Bytes += n.size
+		c.stats.DataBytes += n.data
+		c.stats.Overhead += n.size - n.data
+
+		has, err := c.local.Has(ctx, k)
+		if err != nil {
+			return err
+		}
+		if has {
+			c.stats.LocalBlocks++
+			c.stats.LocalBytes += n.size
+		}
+	}
+	return nil
+}
+
+// dryRunDagService records the nodes added to the DAGService it wraps.
+type dryRunDagService struct {
+	ipld.DAGService
+	c *dryRunCounter
+}
+
+func (s *dryRunDagService) Add(ctx context.Context, nd ipld.Node) error {
+	s.c.record(nd)
+	return s.DAGService.Add(ctx, nd)
+}
+
+func (s *dryRunDagService) AddMany(ctx context.Context, nds []ipld.Node) error {
+	for _, nd := range nds {
+		s.c.record(nd)
+	}
+	return s.DAGService.AddMany(ctx, nds)
+}
+
 // syncDagService is used by the Adder to ensure blocks get persisted to the underlying datastore
 type syncDagService struct {
 	ipld.DAGService
@@ -349,3 +927,40 @@ type syncDagService struct {
 func (s *syncDagService) Sync() error {
 	return s.syncFn()
 }
+
+func (api *UnixfsAPI) Search(ctx context.Context, query string, opts ...options.UnixfsSearchOption) ([]coreiface.SearchResult, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.UnixfsAPI", "Search", trace.WithAttributes(attribute.String("query", query)))
+	defer span.End()
+
+	settings, err := options.UnixfsSearchOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if api.contentIndex == nil {
+		return nil, errors.New("the content index is disabled, see Experimental.ContentIndex")
+	}
+
+	entries, err := api.contentIndex.Search(ctx, query, settings.Limit)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]coreiface.SearchResult, len(entries))
+	for i, e := range entries {
+		results[i] = coreiface.SearchResult{
+			Source:   e.Source,
+			Path:     e.Path,
+			Name:     e.Name,
+			Cid:      e.Cid,
+			Root:     e.Root,
+			Type:     coreiface.TFile,
+			Size:     e.Size,
+			MimeType: e.MimeType,
+			Added:    e.Added,
+			ModTime:  e.ModTime,
+		}
+		if e.Dir {
+			results[i].Type = coreiface.TDirectory
+		}
+	}
+	return results, nil
+}
@@ -17,6 +17,10 @@ import (
 	config "github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/core"
 	corecommands "github.com/ipfs/kubo/core/commands"
+	"github.com/ipfs/kubo/core/commands/cmdhooks"
+	"github.com/ipfs/kubo/core/readonly"
+	"github.com/ipfs/kubo/core/reqbudget"
+	manet "github.com/multiformats/go-multiaddr/net"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
@@ -138,15 +142,20 @@ func commandsOption(cctx oldcmds.Context, command *cmds.Command, allowGet bool)
 			return nil, err
 		}
 
+		budgets, err := reqbudget.ParseBudgets(rcfg.API.RequestBudgets)
+		if err != nil {
+			return nil, err
+		}
+
 		addHeadersFromConfig(cfg, rcfg)
 		addCORSFromEnv(cfg)
 		addCORSDefaults(cfg)
 		patchCORSVars(cfg, l.Addr())
 
-		cmdHandler := cmdsHttp.NewHandler(&cctx, command, cfg)
+		cmdHandler := cmdsHttp.NewHandler(&cctx, cmdhooks.Wrap(reqbudget.Guard(readonly.Guard(command, n.ReadOnly), budgets)), cfg)
 
-		if len(rcfg.API.Authorizations) > 0 {
-			authorizations := convertAuthorizationsMap(rcfg.API.Authorizations)
+		if authScopes := listenerAuthorizations(rcfg, l); len(authScopes) > 0 {
+			authorizations := convertAuthorizationsMap(authScopes)
 			cmdHandler = withAuthSecrets(authorizations, cmdHandler)
 		}
 
@@ -157,12 +166,24 @@ func commandsOption(cctx oldcmds.Context, command *cmds.Command, allowGet bool)
 			}).Wrap(cmdHandler)
 		}
 
+		cmdHandler = withCommandCaller(cmdHandler)
 		cmdHandler = otelhttp.NewHandler(cmdHandler, "corehttp.cmdsHandler")
 		mux.Handle(APIPath+"/", cmdHandler)
 		return mux, nil
 	}
 }
 
+// listenerAuthorizations returns the authorizations of the RPC API on l, see
+// API.Listeners in the config.
+func listenerAuthorizations(cfg *config.Config, l net.Listener) map[string]*config.RPCAuthScope {
+	maddr, err := manet.FromNetAddr(l.Addr())
+	if err != nil {
+		// libp2p listeners have no multiaddr
+		return cfg.API.Authorizations
+	}
+	return cfg.API.ListenerAuthorizations(maddr)
+}
+
 type rpcAuthScopeWithUser struct {
 	config.RPCAuthScope
 	User string
@@ -190,6 +211,9 @@ func withAuthSecrets(authorizations map[string]rpcAuthScopeWithUser, next http.H
 		auth, ok := authorizations[authorizationHeader]
 
 		if ok {
+			if c := cmdhooks.CallerFromContext(r.Context()); c != nil {
+				c.User = auth.User
+			}
 			// version check is implicitly allowed
 			if r.URL.Path == "/api/v0/version" {
 				next.ServeHTTP(w, r)
@@ -208,6 +232,15 @@ func withAuthSecrets(authorizations map[string]rpcAuthScopeWithUser, next http.H
 	})
 }
 
+// withCommandCaller attaches the caller of the request to its context, for
+// the command middlewares of cmdhooks. The authorization handlers fill it in.
+func withCommandCaller(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := cmdhooks.ContextWithCaller(r.Context(), &cmdhooks.Caller{RemoteAddr: r.RemoteAddr})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // CommandsOption constructs a ServerOption for hooking the commands into the
 // HTTP server. It will NOT allow GET requests.
 func CommandsOption(cctx oldcmds.Context) ServeOption {
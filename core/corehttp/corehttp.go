@@ -91,9 +91,10 @@ func Serve(node *core.IpfsNode, lis net.Listener, options ...ServeOption) error
 		return err
 	}
 
-	addr, err := manet.FromNetAddr(lis.Addr())
-	if err != nil {
-		return err
+	// libp2p listeners do not have a multiaddr, they are logged as is
+	addr := lis.Addr().String()
+	if maddr, err := manet.FromNetAddr(lis.Addr()); err == nil {
+		addr = maddr.String()
 	}
 
 	select {
@@ -102,6 +103,14 @@ func Serve(node *core.IpfsNode, lis net.Listener, options ...ServeOption) error
 	default:
 	}
 
+	// Register the server with the node so that a graceful shutdown waits
+	// for in-flight requests before tearing the node down.
+	opCtx, opDone, err := node.StartOperation(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	defer opDone()
+
 	server := &http.Server{
 		Handler: handler,
 	}
@@ -114,28 +123,40 @@ func Serve(node *core.IpfsNode, lis net.Listener, options ...ServeOption) error
 	// wait for server to exit.
 	select {
 	case <-serverProc.Closed():
+	// if the node starts draining, stop accepting new requests and let the
+	// running ones finish until the node gives up waiting
+	case <-node.Draining():
+		err := shutdownServer(opCtx, server, serverProc, addr)
+		if err != nil {
+			// drop the connections that are still busy
+			server.Close()
+		}
+		serverError = err
 	// if node being closed before server exits, close server
 	case <-node.Process.Closing():
-		log.Infof("server at %s terminating...", addr)
-
-		warnProc := periodicproc.Tick(5*time.Second, func(_ goprocess.Process) {
-			log.Infof("waiting for server at %s to terminate...", addr)
-		})
-
 		// This timeout shouldn't be necessary if all of our commands
 		// are obeying their contexts but we should have *some* timeout.
 		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
-		err := server.Shutdown(ctx)
-
-		// Should have already closed but we still need to wait for it
-		// to set the error.
-		<-serverProc.Closed()
-		serverError = err
-
-		warnProc.Close()
+		serverError = shutdownServer(ctx, server, serverProc, addr)
 	}
 
 	log.Infof("server at %s terminated", addr)
 	return serverError
 }
+
+func shutdownServer(ctx context.Context, server *http.Server, serverProc goprocess.Process, addr string) error {
+	log.Infof("server at %s terminating...", addr)
+
+	warnProc := periodicproc.Tick(5*time.Second, func(_ goprocess.Process) {
+		log.Infof("waiting for server at %s to terminate...", addr)
+	})
+	defer warnProc.Close()
+
+	err := server.Shutdown(ctx)
+
+	// Should have already closed but we still need to wait for it
+	// to set the error.
+	<-serverProc.Closed()
+	return err
+}
@@ -13,14 +13,20 @@ import (
 	"github.com/ipfs/boxo/exchange/offline"
 	"github.com/ipfs/boxo/files"
 	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/boxo/ipld/merkledag"
 	"github.com/ipfs/boxo/namesys"
 	"github.com/ipfs/boxo/path"
 	offlineroute "github.com/ipfs/boxo/routing/offline"
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	format "github.com/ipfs/go-ipld-format"
 	version "github.com/ipfs/kubo"
 	"github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/alias"
 	iface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/ipfs/kubo/core/mimetypes"
+	"github.com/ipfs/kubo/core/namecache"
 	"github.com/ipfs/kubo/core/node"
 	"github.com/libp2p/go-libp2p/core/routing"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
@@ -38,13 +44,58 @@ func GatewayOption(paths ...string) ServeOption {
 			return nil, err
 		}
 
+		limits, err := getGatewayFetchLimits(n)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+
+		fallback, err := parseFallbackConfig(cfg.Gateway.Fallback)
+		if err != nil {
+			return nil, err
+		}
+
+		templates, err := getGatewayTemplates(n)
+		if err != nil {
+			return nil, err
+		}
+		if templates.plainErrors() {
+			config.DisableHTMLErrors = true
+		}
+
+		// the nodes of the responses were just read by the gateway
+		localDAG := merkledag.NewDAGService(blockservice.New(n.Blocks.Blockstore(), offline.Exchange(n.Blocks.Blockstore())))
+
 		handler := gateway.NewHandler(config, backend)
+		handler = withIPNSRecordCaching(handler)
+		if cfg.Gateway.SurrogateKeys.WithDefault(false) {
+			handler = withSurrogateKeys(handler)
+		}
+		handler = withListingTemplate(templates, localDAG, handler)
+		if n.Previews != nil {
+			handler = withPreviewListing(handler)
+		}
+		handler = withConditionalRequests(localDAG, handler)
+		handler = withErrorTemplate(templates, handler)
+		handler = withFetchBudget(limits, handler)
+		handler = withFallbackState(fallback, handler)
+		if mediaPrefetchEnabled(cfg) {
+			handler = withMediaPrefetch(handler)
+		}
 		handler = gateway.NewHeaders(headers).ApplyCors().Wrap(handler)
 		handler = otelhttp.NewHandler(handler, "Gateway")
 
 		for _, p := range paths {
 			mux.Handle(p+"/", handler)
+			if p == "/ipfs" {
+				mux.Handle("/"+alias.Namespace+"/", withAliases(alias.New(n.Repo.Datastore()), handler))
+			}
 		}
+		templates.serveAssets(mux)
 
 		return mux, nil
 	}
@@ -62,10 +113,39 @@ func HostnameOption() ServeOption {
 			return nil, err
 		}
 
+		limits, err := getGatewayFetchLimits(n)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+
+		fallback, err := parseFallbackConfig(cfg.Gateway.Fallback)
+		if err != nil {
+			return nil, err
+		}
+
+		templates, err := getGatewayTemplates(n)
+		if err != nil {
+			return nil, err
+		}
+		if templates.plainErrors() {
+			config.DisableHTMLErrors = true
+		}
+
 		childMux := http.NewServeMux()
 
 		var handler http.Handler
 		handler = gateway.NewHostnameHandler(config, backend, childMux)
+		handler = withErrorTemplate(templates, handler)
+		handler = withFetchBudget(limits, handler)
+		handler = withFallbackState(fallback, handler)
+		if mediaPrefetchEnabled(cfg) {
+			handler = withMediaPrefetch(handler)
+		}
 		handler = gateway.NewHeaders(headers).ApplyCors().Wrap(handler)
 		handler = otelhttp.NewHandler(handler, "HostnameGateway")
 
@@ -135,11 +215,11 @@ func newGatewayBackend(n *core.IpfsNode) (gateway.IPFSBackend, error) {
 			return nil, fmt.Errorf("cannot specify negative resolve cache size")
 		}
 
+		maxCacheTTL := cfg.Ipns.MaxCacheTTL.WithDefault(config.DefaultIpnsMaxCacheTTL)
 		nsOptions := []namesys.Option{
 			namesys.WithDatastore(n.Repo.Datastore()),
 			namesys.WithDNSResolver(n.DNSResolver),
-			namesys.WithCache(cs),
-			namesys.WithMaxCacheTTL(cfg.Ipns.MaxCacheTTL.WithDefault(config.DefaultIpnsMaxCacheTTL)),
+			namesys.WithMaxCacheTTL(maxCacheTTL),
 		}
 
 		vsRouting = offlineroute.NewOfflineRouter(n.Repo.Datastore(), n.RecordValidator)
@@ -147,13 +227,47 @@ func newGatewayBackend(n *core.IpfsNode) (gateway.IPFSBackend, error) {
 		if err != nil {
 			return nil, fmt.Errorf("error constructing namesys: %w", err)
 		}
+		cache, err := namecache.New(nsys, cs, maxCacheTTL, n.Clock)
+		if err != nil {
+			return nil, fmt.Errorf("error constructing namesys: %w", err)
+		}
+		// 'ipfs name purge' purges the names cached for the gateway too
+		if n.NameCache != nil {
+			n.NameCache.Attach(cache)
+		}
+		nsys = cache
 
 		// Gateway.NoFetch=true requires offline path resolver
 		// to avoid fetching missing blocks during path traversal
 		pathResolver = n.OfflineUnixFSPathResolver
 	}
 
-	backend, err := gateway.NewBlocksBackend(bserv,
+	limits, err := parseFetchLimits(cfg.Gateway.FetchBudget)
+	if err != nil {
+		return nil, err
+	}
+	limits = limits.withDAGLimits(cfg.DAGLimits)
+	fallback, err := parseFallbackConfig(cfg.Gateway.Fallback)
+	if err != nil {
+		return nil, err
+	}
+	if (limits.enabled() || fallback.enabled()) && !cfg.Gateway.NoFetch {
+		ex := n.Exchange
+		if fallback.enabled() {
+			// blocks the node doesn't find in time are fetched from the
+			// upstream gateways
+			ex = newFallbackExchange(ex, fallback)
+		}
+		if limits.enabled() {
+			// blocks fetched from the network count against the budget of
+			// the request they are fetched for
+			ex = &budgetExchange{ex}
+		}
+		bserv = blockservice.New(bserv.Blockstore(), ex)
+	}
+
+	var backend gateway.IPFSBackend
+	backend, err = gateway.NewBlocksBackend(bserv,
 		gateway.WithValueStore(vsRouting),
 		gateway.WithNameSystem(nsys),
 		gateway.WithResolver(pathResolver),
@@ -161,9 +275,58 @@ func newGatewayBackend(n *core.IpfsNode) (gateway.IPFSBackend, error) {
 	if err != nil {
 		return nil, err
 	}
+	if n.MimeTypes != nil {
+		backend = &mimeTypeBackend{
+			IPFSBackend: backend,
+			dag:         merkledag.NewDAGService(bserv),
+			mimeTypes:   n.MimeTypes,
+		}
+	}
+	if mediaPrefetchEnabled(cfg) {
+		backend = newMediaBackend(backend, merkledag.NewDAGService(bserv))
+	}
 	return &offlineGatewayErrWrapper{gwimpl: backend}, nil
 }
 
+// mimeTypeBackend sets the content type of UnixFS files from the cache of
+// MIME types of the node. The handler doesn't sniff the first bytes of the
+// files on every request, and knows the type of the files requested by ranges
+// that don't start at zero.
+type mimeTypeBackend struct {
+	gateway.IPFSBackend
+	dag       format.NodeGetter
+	mimeTypes *mimetypes.Cache
+}
+
+func (b *mimeTypeBackend) Get(ctx context.Context, path path.ImmutablePath, ranges ...gateway.ByteRange) (gateway.ContentPathMetadata, *gateway.GetResponse, error) {
+	md, resp, err := b.IPFSBackend.Get(ctx, path, ranges...)
+	if err == nil {
+		b.setContentType(ctx, &md)
+	}
+	return md, resp, err
+}
+
+func (b *mimeTypeBackend) Head(ctx context.Context, path path.ImmutablePath) (gateway.ContentPathMetadata, *gateway.HeadResponse, error) {
+	md, resp, err := b.IPFSBackend.Head(ctx, path)
+	if err == nil {
+		b.setContentType(ctx, &md)
+	}
+	return md, resp, err
+}
+
+func (b *mimeTypeBackend) setContentType(ctx context.Context, md *gateway.ContentPathMetadata) {
+	if md.ContentType != "" || len(md.LastSegmentRemainder) > 0 {
+		return
+	}
+	// on errors, the handler sniffs the content itself
+	mimeType, err := b.mimeTypes.MimeType(ctx, b.dag, md.LastSegment.RootCid())
+	if err != nil {
+		log.Debugf("mime type of %s: %s", md.LastSegment, err)
+		return
+	}
+	md.ContentType = mimeType
+}
+
 type offlineGatewayErrWrapper struct {
 	gwimpl gateway.IPFSBackend
 }
@@ -217,6 +380,10 @@ func (o *offlineGatewayErrWrapper) IsCached(ctx context.Context, path path.Path)
 
 func (o *offlineGatewayErrWrapper) GetIPNSRecord(ctx context.Context, c cid.Cid) ([]byte, error) {
 	rec, err := o.gwimpl.GetIPNSRecord(ctx, c)
+	if errors.Is(err, routing.ErrNotFound) || errors.Is(err, datastore.ErrNotFound) {
+		// light clients tell missing records from failures
+		err = gateway.NewErrorStatusCode(err, http.StatusNotFound)
+	}
 	err = offlineErrWrap(err)
 	return rec, err
 }
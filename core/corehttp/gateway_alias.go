@@ -0,0 +1,38 @@
+package corehttp
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ipfs/kubo/core/alias"
+)
+
+// withAliases serves the /alias/<name>/... paths as the paths of the aliases
+// of the repo.
+func withAliases(aliases *alias.Store, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := aliases.Expand(r.Context(), r.URL.Path)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, alias.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		expanded := p.String()
+		if strings.HasSuffix(r.URL.Path, "/") && !strings.HasSuffix(expanded, "/") {
+			expanded += "/"
+		}
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = expanded
+		r2.URL.RawPath = ""
+		next.ServeHTTP(w, r2)
+	})
+}
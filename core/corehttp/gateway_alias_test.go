@@ -0,0 +1,45 @@
+package corehttp
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/ipfs/boxo/files"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayAliases(t *testing.T) {
+	ts, api, ctx := newTestServerAndNode(t, mockNamesys{})
+
+	root, err := api.Unixfs().Add(ctx, files.NewMapDirectory(map[string]files.Node{
+		"a.txt": files.NewBytesFile([]byte("hello alias")),
+	}))
+	require.NoError(t, err)
+	require.NoError(t, api.Alias().Set(ctx, "photos", root))
+
+	get := func(p string, header http.Header) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+p, nil)
+		require.NoError(t, err)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		res, err := doWithoutRedirect(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, string(body)
+	}
+
+	res, body := get("/alias/photos/a.txt", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "hello alias", body)
+
+	res, body = get("/alias/photos/a.txt", http.Header{"Range": {"bytes=6-"}})
+	require.Equal(t, http.StatusPartialContent, res.StatusCode)
+	require.Equal(t, "alias", body)
+
+	res, _ = get("/alias/missing/a.txt", nil)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
@@ -0,0 +1,362 @@
+package corehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/ipfs/boxo/exchange"
+	"github.com/ipfs/boxo/gateway"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/dagshape"
+)
+
+// Trailers set on gateway responses that ran out of fetch budget after the
+// response status was already sent.
+const (
+	fetchBudgetExceededTrailer = "Ipfs-Fetch-Budget-Exceeded"
+	fetchMissingTrailer        = "Ipfs-Fetch-Missing"
+	fetchBlocksTrailer         = "Ipfs-Fetch-Blocks"
+	fetchBytesTrailer          = "Ipfs-Fetch-Bytes"
+)
+
+// Names of the exhausted limit, as reported in fetchBudgetExceededTrailer.
+const (
+	fetchLimitBlocks   = "max-blocks"
+	fetchLimitBytes    = "max-bytes"
+	fetchLimitDuration = "max-duration"
+	fetchLimitLinks    = "max-links"
+)
+
+// errFetchBudgetExceeded is returned by the exchange once a request used up
+// its budget. It maps to 504 Gateway Timeout when nothing was sent yet.
+var errFetchBudgetExceeded = fmt.Errorf("%w: gateway fetch budget exceeded", gateway.ErrGatewayTimeout)
+
+// fetchLimits are the per request limits configured in Gateway.FetchBudget,
+// and the links of the blocks fetched configured in DAGLimits.
+type fetchLimits struct {
+	maxBlocks   int64
+	maxBytes    int64
+	maxDuration time.Duration
+	shape       dagshape.Limits
+}
+
+func (l fetchLimits) enabled() bool {
+	return l.maxBlocks > 0 || l.maxBytes > 0 || l.maxDuration > 0 || l.shape.MaxLinks > 0
+}
+
+func getGatewayFetchLimits(n *core.IpfsNode) (fetchLimits, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return fetchLimits{}, err
+	}
+	limits, err := parseFetchLimits(cfg.Gateway.FetchBudget)
+	if err != nil {
+		return limits, err
+	}
+	return limits.withDAGLimits(cfg.DAGLimits), nil
+}
+
+// withDAGLimits adds the limits of DAGLimits which apply to a request: the
+// links of each block fetched, and the number of blocks as MaxNodes. The
+// depth of the DAGs isn't known from the blocks.
+func (l fetchLimits) withDAGLimits(cfg config.DAGLimits) fetchLimits {
+	l.shape = dagshape.ParseLimits(cfg)
+	if n := l.shape.MaxNodes; n > 0 && (l.maxBlocks <= 0 || n < l.maxBlocks) {
+		l.maxBlocks = n
+	}
+	return l
+}
+
+func parseFetchLimits(cfg config.GatewayFetchBudget) (fetchLimits, error) {
+	limits := fetchLimits{
+		maxBlocks:   cfg.MaxBlocks.WithDefault(0),
+		maxDuration: cfg.MaxDuration.WithDefault(0),
+	}
+	if s := cfg.MaxBytes.WithDefault(""); s != "" {
+		b, err := humanize.ParseBytes(s)
+		if err != nil {
+			return limits, fmt.Errorf("invalid Gateway.FetchBudget.MaxBytes: %w", err)
+		}
+		limits.maxBytes = int64(b)
+	}
+	return limits, nil
+}
+
+// fetchBudget tracks what a single gateway request fetched from the network.
+type fetchBudget struct {
+	limits fetchLimits
+
+	lk       sync.Mutex
+	blocks   int64
+	bytes    int64
+	exceeded string
+	missing  cid.Cid
+}
+
+type fetchBudgetKey struct{}
+
+func fetchBudgetFromContext(ctx context.Context) *fetchBudget {
+	b, _ := ctx.Value(fetchBudgetKey{}).(*fetchBudget)
+	return b
+}
+
+// reserve checks that another block may be fetched.
+func (b *fetchBudget) reserve(c cid.Cid) error {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	if b.exceeded == "" {
+		switch {
+		case b.limits.maxBlocks > 0 && b.blocks >= b.limits.maxBlocks:
+			b.exceeded = fetchLimitBlocks
+		case b.limits.maxBytes > 0 && b.bytes >= b.limits.maxBytes:
+			b.exceeded = fetchLimitBytes
+		default:
+			return nil
+		}
+	}
+	if !b.missing.Defined() {
+		b.missing = c
+	}
+	return errFetchBudgetExceeded
+}
+
+func (b *fetchBudget) charge(blk blocks.Block) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	b.blocks++
+	b.bytes += int64(len(blk.RawData()))
+}
+
+// checkShape checks the links of a block fetched against DAGLimits. The
+// blocks exceeding them are not returned, the gateway answers with 502 Bad
+// Gateway.
+func (b *fetchBudget) checkShape(ctx context.Context, blk blocks.Block) error {
+	err := b.limits.shape.CheckBlock(ctx, blk)
+	if err == nil {
+		return nil
+	}
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	if b.exceeded == "" {
+		b.exceeded = fetchLimitLinks
+	}
+	if !b.missing.Defined() {
+		b.missing = blk.Cid()
+	}
+	return fmt.Errorf("%w: %w", gateway.ErrBadGateway, err)
+}
+
+// failed records a block that could not be fetched because the request ran
+// out of time.
+func (b *fetchBudget) failed(ctx context.Context, c cid.Cid, err error) {
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return
+	}
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	if b.exceeded == "" {
+		b.exceeded = fetchLimitDuration
+	}
+	if !b.missing.Defined() {
+		b.missing = c
+	}
+}
+
+// budgetExchange enforces the fetch budget attached to the request context
+// on every block fetched from the network.
+type budgetExchange struct {
+	exchange.Interface
+}
+
+func (e *budgetExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return getBlockWithBudget(ctx, e.Interface, c)
+}
+
+func (e *budgetExchange) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	return getBlocksWithBudget(ctx, e.Interface, cids)
+}
+
+func (e *budgetExchange) NewSession(ctx context.Context) exchange.Fetcher {
+	if sx, ok := e.Interface.(exchange.SessionExchange); ok {
+		return &budgetFetcher{sx.NewSession(ctx)}
+	}
+	return &budgetFetcher{e.Interface}
+}
+
+type budgetFetcher struct {
+	exchange.Fetcher
+}
+
+func (f *budgetFetcher) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return getBlockWithBudget(ctx, f.Fetcher, c)
+}
+
+func (f *budgetFetcher) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	return getBlocksWithBudget(ctx, f.Fetcher, cids)
+}
+
+func getBlockWithBudget(ctx context.Context, f exchange.Fetcher, c cid.Cid) (blocks.Block, error) {
+	budget := fetchBudgetFromContext(ctx)
+	if budget == nil {
+		return f.GetBlock(ctx, c)
+	}
+	if err := budget.reserve(c); err != nil {
+		return nil, err
+	}
+	blk, err := f.GetBlock(ctx, c)
+	if err != nil {
+		budget.failed(ctx, c, err)
+		return nil, err
+	}
+	budget.charge(blk)
+	if err := budget.checkShape(ctx, blk); err != nil {
+		return nil, err
+	}
+	return blk, nil
+}
+
+func getBlocksWithBudget(ctx context.Context, f exchange.Fetcher, cids []cid.Cid) (<-chan blocks.Block, error) {
+	budget := fetchBudgetFromContext(ctx)
+	if budget == nil {
+		return f.GetBlocks(ctx, cids)
+	}
+	if len(cids) > 0 {
+		if err := budget.reserve(cids[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	in, err := f.GetBlocks(ctx, cids)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// the blocks not received yet, and the next one in the order of cids
+	pending := cid.NewSet()
+	for _, c := range cids {
+		pending.Add(c)
+	}
+	next := 0
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		defer cancel()
+		for blk := range in {
+			budget.charge(blk)
+			if err := budget.checkShape(ctx, blk); err != nil {
+				// stop fetching the remaining blocks
+				return
+			}
+			pending.Remove(blk.Cid())
+			select {
+			case out <- blk:
+			case <-ctx.Done():
+				return
+			}
+			for next < len(cids) && !pending.Has(cids[next]) {
+				next++
+			}
+			if next == len(cids) {
+				continue
+			}
+			if err := budget.reserve(cids[next]); err != nil {
+				// stop fetching the remaining blocks
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// budgetResponseWriter remembers whether the response status was sent, and
+// declares the budget trailers on the streamed responses which can carry
+// them.
+type budgetResponseWriter struct {
+	http.ResponseWriter
+	head        bool
+	wroteHeader bool
+	trailers    bool
+}
+
+func (w *budgetResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	// trailers are only sent with chunked encoding: the responses with a
+	// known Content-Length, such as the ranges of files, are cut short
+	// instead when the budget runs out, which clients detect
+	if !w.head && (code == http.StatusOK || code == http.StatusPartialContent) && h.Get("Content-Length") == "" {
+		w.trailers = true
+		h.Add("Trailer", strings.Join([]string{fetchBudgetExceededTrailer, fetchMissingTrailer, fetchBlocksTrailer, fetchBytesTrailer}, ", "))
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *budgetResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *budgetResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// withFetchBudget attaches a fresh fetch budget to every request. When the
+// budget runs out before the response started, the gateway answers with 504
+// Gateway Timeout. When it runs out while a 200 or 206 body is streamed, the
+// status can no longer change: the body ends early and, when the response has
+// no Content-Length, the trailers declared on the response describe which
+// limit was hit and the first block that could not be fetched.
+func withFetchBudget(limits fetchLimits, next http.Handler) http.Handler {
+	if !limits.enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		budget := &fetchBudget{limits: limits}
+		ctx := context.WithValue(r.Context(), fetchBudgetKey{}, budget)
+		if limits.maxDuration > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, limits.maxDuration)
+			defer cancel()
+		}
+
+		bw := &budgetResponseWriter{ResponseWriter: w, head: r.Method == http.MethodHead}
+		next.ServeHTTP(bw, r.WithContext(ctx))
+
+		budget.lk.Lock()
+		defer budget.lk.Unlock()
+		if budget.exceeded == "" || !bw.trailers {
+			return
+		}
+		h := w.Header()
+		h.Set(fetchBudgetExceededTrailer, budget.exceeded)
+		if budget.missing.Defined() {
+			h.Set(fetchMissingTrailer, budget.missing.String())
+		}
+		h.Set(fetchBlocksTrailer, strconv.FormatInt(budget.blocks, 10))
+		h.Set(fetchBytesTrailer, strconv.FormatInt(budget.bytes, 10))
+	})
+}
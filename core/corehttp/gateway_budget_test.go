@@ -0,0 +1,155 @@
+package corehttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/dagshape"
+)
+
+type mapFetcher map[cid.Cid]blocks.Block
+
+func (m mapFetcher) GetBlock(_ context.Context, c cid.Cid) (blocks.Block, error) {
+	return m[c], nil
+}
+
+func (m mapFetcher) GetBlocks(_ context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	out := make(chan blocks.Block, len(cids))
+	for _, c := range cids {
+		out <- m[c]
+	}
+	close(out)
+	return out, nil
+}
+
+func TestFetchBudget(t *testing.T) {
+	f := mapFetcher{}
+	var cids []cid.Cid
+	for _, s := range []string{"a", "b", "c"} {
+		b := blocks.NewBlock([]byte(s))
+		f[b.Cid()] = b
+		cids = append(cids, b.Cid())
+	}
+
+	handler := withFetchBudget(fetchLimits{maxBlocks: 2}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if _, err := getBlockWithBudget(ctx, f, cids[0]); err != nil {
+			t.Error(err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "partial")
+
+		ch, err := getBlocksWithBudget(ctx, f, cids[1:])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var n int
+		for range ch {
+			n++
+		}
+		if n != 1 {
+			t.Errorf("expected 1 block within budget, got %d", n)
+		}
+		if _, err := getBlockWithBudget(ctx, f, cids[2]); !errors.Is(err, errFetchBudgetExceeded) {
+			t.Errorf("expected budget error, got %v", err)
+		}
+	}))
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if _, err := io.ReadAll(res.Body); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", res.StatusCode)
+	}
+	if v := res.Trailer.Get(fetchBudgetExceededTrailer); v != fetchLimitBlocks {
+		t.Fatalf("expected %s trailer, got %q", fetchLimitBlocks, v)
+	}
+	if v := res.Trailer.Get(fetchMissingTrailer); v != cids[2].String() {
+		t.Fatalf("expected missing %s, got %q", cids[2], v)
+	}
+	if v := res.Trailer.Get(fetchBlocksTrailer); v != "2" {
+		t.Fatalf("expected 2 blocks, got %q", v)
+	}
+}
+
+func TestFetchBudgetDisabled(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	rec := httptest.NewRecorder()
+	withFetchBudget(fetchLimits{}, next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Header().Get("Trailer") != "" {
+		t.Fatal("trailers declared with budgets disabled")
+	}
+}
+
+func TestFetchBudgetDAGLimits(t *testing.T) {
+	limits := fetchLimits{maxBlocks: 10}.withDAGLimits(config.DAGLimits{
+		MaxLinks: config.NewOptionalInteger(2),
+		MaxNodes: config.NewOptionalInteger(5),
+	})
+	if !limits.enabled() || limits.maxBlocks != 5 {
+		t.Fatalf("expected MaxNodes to lower the blocks of the budget, got %d", limits.maxBlocks)
+	}
+
+	wide := merkledag.NodeWithData([]byte("wide"))
+	for _, s := range []string{"a", "b", "c"} {
+		if err := wide.AddNodeLink(s, merkledag.NewRawNode([]byte(s))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	leaf := merkledag.NewRawNode([]byte("leaf"))
+	f := mapFetcher{wide.Cid(): wide, leaf.Cid(): leaf}
+
+	budget := &fetchBudget{limits: limits}
+	ctx := context.WithValue(context.Background(), fetchBudgetKey{}, budget)
+	if _, err := getBlockWithBudget(ctx, f, leaf.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	_, err := getBlockWithBudget(ctx, f, wide.Cid())
+	if !errors.Is(err, dagshape.ErrExceeded) || !errors.Is(err, gateway.ErrBadGateway) {
+		t.Fatalf("expected the links to exceed DAGLimits, got %v", err)
+	}
+	if budget.exceeded != fetchLimitLinks || !budget.missing.Equals(wide.Cid()) {
+		t.Fatalf("unexpected budget state %q %s", budget.exceeded, budget.missing)
+	}
+}
+
+func TestFetchBudgetContentLength(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Length", "5")
+		w.Header().Set("Content-Range", "bytes 0-4/10")
+		w.WriteHeader(http.StatusPartialContent)
+		io.WriteString(w, "range")
+	})
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=0-4")
+	withFetchBudget(fetchLimits{maxBlocks: 2}, next).ServeHTTP(rec, req)
+	if rec.Code != http.StatusPartialContent || rec.Header().Get("Content-Length") != "5" {
+		t.Fatalf("unexpected response %d with Content-Length %q", rec.Code, rec.Header().Get("Content-Length"))
+	}
+	if rec.Header().Get("Trailer") != "" {
+		t.Fatal("trailers declared with a Content-Length")
+	}
+}
@@ -0,0 +1,72 @@
+package corehttp
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/kubo/core/namecache"
+)
+
+// withSurrogateKeys tags the responses of the gateway for the caches of CDNs,
+// with the Surrogate-Key header of Fastly and Varnish, and the Cache-Tag header
+// of Cloudflare. The keys are the root CID of the content path, the CID it
+// resolves to, and the name of mutable paths, so that all the cached responses
+// of a DAG or name can be purged at once.
+func withSurrogateKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&surrogateKeyWriter{ResponseWriter: w}, r)
+	})
+}
+
+// surrogateKeyWriter sets the surrogate keys from the X-Ipfs-Roots and
+// X-Ipfs-Path headers of the gateway, before the headers are sent.
+type surrogateKeyWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *surrogateKeyWriter) WriteHeader(code int) {
+	w.setKeys()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *surrogateKeyWriter) Write(p []byte) (int, error) {
+	w.setKeys()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *surrogateKeyWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.setKeys()
+		f.Flush()
+	}
+}
+
+func (w *surrogateKeyWriter) setKeys() {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	keys := surrogateKeys(w.Header())
+	if len(keys) == 0 {
+		return
+	}
+	w.Header().Set("Surrogate-Key", strings.Join(keys, " "))
+	w.Header().Set("Cache-Tag", strings.Join(keys, ","))
+}
+
+func surrogateKeys(h http.Header) []string {
+	var keys []string
+	if roots := h.Get("X-Ipfs-Roots"); roots != "" {
+		cids := strings.Split(roots, ",")
+		keys = append(keys, cids[0])
+		if last := cids[len(cids)-1]; last != cids[0] {
+			keys = append(keys, last)
+		}
+	}
+	if p := h.Get("X-Ipfs-Path"); strings.HasPrefix(p, ipns.NamespacePrefix) {
+		keys = append(keys, namecache.SurrogateKey(p))
+	}
+	return keys
+}
@@ -0,0 +1,30 @@
+package corehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSurrogateKeys(t *testing.T) {
+	const (
+		root = "bafybeiaysi4s6lnjev27ln5icwm6tueaw2vdykrtjkwiphwekaywqhcjze"
+		leaf = "bafkreibn6euazfvoghepcm4efzqx5l3hieof2frhp254hio5y7n3hv5rma"
+	)
+	h := withSurrogateKeys(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Ipfs-Path", r.URL.Path)
+		w.Header().Set("X-Ipfs-Roots", root+",bafybeihn2f7lhumh4grizksi2fl233cyszqadkn424ptjajfenykpsaiw4,"+leaf)
+		w.Write([]byte("hello"))
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ipns/Example.com/a/b.txt", nil))
+	require.Equal(t, root+" "+leaf+" ipns:example.com", rec.Header().Get("Surrogate-Key"))
+	require.Equal(t, root+","+leaf+",ipns:example.com", rec.Header().Get("Cache-Tag"))
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ipfs/"+root, nil))
+	require.Equal(t, root+" "+leaf, rec.Header().Get("Surrogate-Key"))
+}
@@ -0,0 +1,206 @@
+package corehttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	format "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/core/coreunix"
+)
+
+// modTimeTimeout bounds the read of the root node of a response for its
+// modification time. The node was just read by the gateway, so it is local.
+const modTimeTimeout = 5 * time.Second
+
+// withConditionalRequests makes the validators of the gateway responses
+// usable by browsers and caches, and answers the conditional requests
+// matching them with 304 Not Modified, once the content path is resolved, so
+// that IPNS paths are revalidated too:
+//   - the HTML directory listings get a strong ETag, the hash of the listing
+//     served, which changes with the template and the previews;
+//   - the ETags of the raw blocks and of the CARs in DFS order, which are the
+//     same bytes for the same request, are strong;
+//   - the responses for UnixFS nodes storing a modification time get a
+//     Last-Modified header, for If-Modified-Since.
+func withConditionalRequests(dag format.NodeGetter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// the gateway stops reading the content once the response is known
+		// to be not modified
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		cw := &conditionalWriter{ResponseWriter: w, req: r, dag: dag, cancel: cancel}
+		next.ServeHTTP(cw, r.WithContext(ctx))
+		cw.finish()
+	})
+}
+
+// conditionalWriter rewrites the validators of a response before its headers
+// are sent, buffering the directory listings to hash them.
+type conditionalWriter struct {
+	http.ResponseWriter
+	req    *http.Request
+	dag    format.NodeGetter
+	cancel context.CancelFunc
+
+	wroteHeader bool
+	// notModified discards the body of the response replaced by a 304
+	notModified bool
+	listing     *bytes.Buffer
+	listingCid  string
+}
+
+func (w *conditionalWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if code != http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	h := w.Header()
+	etag := h.Get("Etag")
+	mt, params, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	switch {
+	case mt == "text/html" && strings.HasPrefix(strings.TrimPrefix(etag, "W/"), `"DirIndex-`):
+		if w.req.Method != http.MethodGet {
+			// a listing can't be tagged without its body
+			h.Del("Etag")
+			break
+		}
+		_, w.listingCid, _ = strings.Cut(strings.Trim(strings.TrimPrefix(etag, "W/"), `"`), "_CID-")
+		w.listing = new(bytes.Buffer)
+		h.Del("Content-Length")
+		return
+	case mt == "application/vnd.ipld.raw", mt == "application/vnd.ipld.car" && params["order"] == "dfs":
+		h.Set("Etag", strings.TrimPrefix(etag, "W/"))
+	}
+	w.setLastModified()
+	w.writeHeader()
+}
+
+// writeHeader sends the headers of the response, or of a 304 when the request
+// is conditional and matches them.
+func (w *conditionalWriter) writeHeader() {
+	if !notModified(w.req, w.Header()) {
+		w.ResponseWriter.WriteHeader(http.StatusOK)
+		return
+	}
+	w.notModified = true
+	w.cancel()
+	h := w.Header()
+	// as http.ServeContent, the headers of the body are not sent
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	if h.Get("Etag") != "" {
+		h.Del("Last-Modified")
+	}
+	w.ResponseWriter.WriteHeader(http.StatusNotModified)
+}
+
+func (w *conditionalWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.listing != nil {
+		return w.listing.Write(p)
+	}
+	if w.notModified {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *conditionalWriter) Flush() {
+	if w.listing != nil || w.notModified {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// finish tags and sends the buffered directory listing.
+func (w *conditionalWriter) finish() {
+	if w.listing == nil {
+		return
+	}
+	sum := sha256.Sum256(w.listing.Bytes())
+	etag := "DirIndex-" + hex.EncodeToString(sum[:16])
+	if w.listingCid != "" {
+		etag += "_CID-" + w.listingCid
+	}
+	w.Header().Set("Etag", `"`+etag+`"`)
+	w.setLastModified()
+	w.writeHeader()
+	if !w.notModified {
+		w.ResponseWriter.Write(w.listing.Bytes())
+	}
+}
+
+// setLastModified sets the Last-Modified header of the response from the
+// modification time of its UnixFS node, the last of the X-Ipfs-Roots.
+func (w *conditionalWriter) setLastModified() {
+	h := w.Header()
+	if h.Get("Last-Modified") != "" {
+		return
+	}
+	roots := strings.Split(h.Get("X-Ipfs-Roots"), ",")
+	c, err := cid.Decode(roots[len(roots)-1])
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(w.req.Context(), modTimeTimeout)
+	defer cancel()
+	nd, err := w.dag.Get(ctx, c)
+	if err != nil {
+		return
+	}
+	if mtime, ok := coreunix.ModTime(nd); ok {
+		h.Set("Last-Modified", mtime.UTC().Format(http.TimeFormat))
+	}
+}
+
+// notModified reports whether the conditional request r matches the response
+// headers h: If-None-Match with the weak comparison of the ETags, or, without
+// it, If-Modified-Since.
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := h.Get("Etag")
+		if etag == "" {
+			return false
+		}
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lm.After(ims)
+}
@@ -0,0 +1,86 @@
+package corehttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	dag := dagtest.Mock()
+	mtime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// a UnixFS file storing its modification time, in the field 8 of Data
+	ts := protowire.AppendTag(nil, 1, protowire.VarintType)
+	ts = protowire.AppendVarint(ts, uint64(mtime.Unix()))
+	data := protowire.AppendTag(ft.FilePBData([]byte("file"), 4), 8, protowire.BytesType)
+	data = protowire.AppendBytes(data, ts)
+	file := merkledag.NodeWithData(data)
+	require.NoError(t, dag.Add(context.Background(), file))
+
+	listing := "<html><body>listing</body></html>"
+	h := withConditionalRequests(dag, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ipns/dir/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Etag", `W/"DirIndex-abc_CID-bafy"`)
+			w.Write([]byte(listing))
+		case "/ipfs/block":
+			w.Header().Set("Content-Type", "application/vnd.ipld.raw")
+			w.Header().Set("Etag", `W/"bafy.raw"`)
+			w.Write([]byte("block"))
+		case "/ipfs/car":
+			w.Header().Set("Content-Type", "application/vnd.ipld.car; version=1; order=unk; dups=n")
+			w.Header().Set("Etag", `W/"bafy.car"`)
+			w.Write([]byte("car"))
+		case "/ipns/file":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Etag", `"`+file.Cid().String()+`"`)
+			w.Header().Set("X-Ipfs-Roots", file.Cid().String())
+			w.Write([]byte("file"))
+		}
+	}))
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// the listings get a strong ETag of their body
+	res := get("/ipns/dir/")
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Equal(t, listing, res.Body.String())
+	etag := res.Header().Get("Etag")
+	require.Regexp(t, `^"DirIndex-[0-9a-f]{32}_CID-bafy"$`, etag)
+	res = get("/ipns/dir/", "If-None-Match", etag)
+	require.Equal(t, http.StatusNotModified, res.Code)
+	require.Empty(t, res.Body.String())
+	require.Empty(t, res.Header().Get("Content-Type"))
+	require.Equal(t, etag, res.Header().Get("Etag"))
+	require.Equal(t, http.StatusOK, get("/ipns/dir/", "If-None-Match", `"DirIndex-other"`).Code)
+
+	// the raw blocks are strong, the CARs in any order aren't
+	require.Equal(t, `"bafy.raw"`, get("/ipfs/block").Header().Get("Etag"))
+	require.Equal(t, http.StatusNotModified, get("/ipfs/block", "If-None-Match", `"other", "bafy.raw"`).Code)
+	require.Equal(t, `W/"bafy.car"`, get("/ipfs/car").Header().Get("Etag"))
+
+	// the modification time of UnixFS nodes is Last-Modified
+	res = get("/ipns/file")
+	require.Equal(t, mtime.Format(http.TimeFormat), res.Header().Get("Last-Modified"))
+	require.Equal(t, http.StatusNotModified, get("/ipns/file", "If-Modified-Since", mtime.Format(http.TimeFormat)).Code)
+	require.Equal(t, http.StatusOK, get("/ipns/file", "If-Modified-Since", mtime.Add(-time.Hour).Format(http.TimeFormat)).Code)
+	// If-None-Match takes precedence
+	require.Equal(t, http.StatusOK, get("/ipns/file", "If-None-Match", `"other"`, "If-Modified-Since", mtime.Format(http.TimeFormat)).Code)
+}
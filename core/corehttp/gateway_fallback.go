@@ -0,0 +1,261 @@
+package corehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/boxo/exchange"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+)
+
+// maxFallbackBlockSize is the size of the largest block fetched from the
+// upstream gateways, the one of bitswap.
+const maxFallbackBlockSize = 2 << 20
+
+// fallbackConfig is the configuration of Gateway.Fallback.
+type fallbackConfig struct {
+	upstreams []string
+	timeout   time.Duration
+}
+
+func (c fallbackConfig) enabled() bool {
+	return len(c.upstreams) > 0
+}
+
+func parseFallbackConfig(cfg config.GatewayFallback) (fallbackConfig, error) {
+	res := fallbackConfig{timeout: cfg.Timeout.WithDefault(config.DefaultGatewayFallbackTimeout)}
+	for _, u := range cfg.Upstreams {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return res, fmt.Errorf("invalid Gateway.Fallback.Upstreams URL %q: %w", u, err)
+		}
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			return res, fmt.Errorf("invalid Gateway.Fallback.Upstreams URL %q: the scheme must be http or https", u)
+		}
+		res.upstreams = append(res.upstreams, strings.TrimSuffix(u, "/"))
+	}
+	return res, nil
+}
+
+// fallbackState remembers that a request fell back to the upstream gateways,
+// so that the next blocks of the request are fetched from them right away
+// rather than after the timeout of the node again.
+type fallbackState struct {
+	used atomic.Bool
+}
+
+type fallbackStateKey struct{}
+
+func fallbackStateFromContext(ctx context.Context) *fallbackState {
+	s, _ := ctx.Value(fallbackStateKey{}).(*fallbackState)
+	return s
+}
+
+// withFallbackState attaches a fresh fallbackState to every request.
+func withFallbackState(cfg fallbackConfig, next http.Handler) http.Handler {
+	if !cfg.enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), fallbackStateKey{}, &fallbackState{})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// fallbackExchange fetches the blocks the node doesn't find within the
+// timeout from upstream trustless gateways, as raw blocks verified against
+// their CID. The blockservice then stores them like the blocks of the node.
+type fallbackExchange struct {
+	exchange.Interface
+	upstream *upstreamFetcher
+	timeout  time.Duration
+}
+
+func newFallbackExchange(ex exchange.Interface, cfg fallbackConfig) *fallbackExchange {
+	return &fallbackExchange{
+		Interface: ex,
+		upstream:  &upstreamFetcher{urls: cfg.upstreams, client: http.DefaultClient},
+		timeout:   cfg.timeout,
+	}
+}
+
+func (e *fallbackExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return e.getBlock(ctx, e.Interface, c)
+}
+
+func (e *fallbackExchange) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	return e.getBlocks(ctx, e.Interface, cids)
+}
+
+func (e *fallbackExchange) NewSession(ctx context.Context) exchange.Fetcher {
+	if sx, ok := e.Interface.(exchange.SessionExchange); ok {
+		return &fallbackFetcher{sx.NewSession(ctx), e}
+	}
+	return &fallbackFetcher{e.Interface, e}
+}
+
+type fallbackFetcher struct {
+	exchange.Fetcher
+	ex *fallbackExchange
+}
+
+func (f *fallbackFetcher) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return f.ex.getBlock(ctx, f.Fetcher, c)
+}
+
+func (f *fallbackFetcher) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	return f.ex.getBlocks(ctx, f.Fetcher, cids)
+}
+
+func (e *fallbackExchange) getBlock(ctx context.Context, f exchange.Fetcher, c cid.Cid) (blocks.Block, error) {
+	state := fallbackStateFromContext(ctx)
+	if state == nil || !state.used.Load() {
+		fctx, cancel := context.WithTimeout(ctx, e.timeout)
+		blk, err := f.GetBlock(fctx, c)
+		cancel()
+		if err == nil || ctx.Err() != nil {
+			return blk, err
+		}
+		log.Debugf("falling back to the upstream gateways for %s: %s", c, err)
+	}
+	blk, err := e.upstream.fetch(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if state != nil {
+		state.used.Store(true)
+	}
+	return blk, nil
+}
+
+func (e *fallbackExchange) getBlocks(ctx context.Context, f exchange.Fetcher, cids []cid.Cid) (<-chan blocks.Block, error) {
+	pending := cid.NewSet()
+	for _, c := range cids {
+		pending.Add(c)
+	}
+
+	out := make(chan blocks.Block)
+	send := func(blk blocks.Block) bool {
+		select {
+		case out <- blk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	state := fallbackStateFromContext(ctx)
+	var in <-chan blocks.Block
+	cancel := func() {}
+	if state == nil || !state.used.Load() {
+		var fctx context.Context
+		fctx, cancel = context.WithTimeout(ctx, e.timeout)
+		var err error
+		in, err = f.GetBlocks(fctx, cids)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	go func() {
+		defer close(out)
+		defer cancel()
+		if in != nil {
+			for blk := range in {
+				pending.Remove(blk.Cid())
+				if !send(blk) {
+					return
+				}
+			}
+		}
+		// the blocks the node didn't find in time
+		for _, c := range pending.Keys() {
+			if ctx.Err() != nil {
+				return
+			}
+			blk, err := e.upstream.fetch(ctx, c)
+			if err != nil {
+				log.Debugf("fetching %s from the upstream gateways: %s", c, err)
+				continue
+			}
+			if state != nil {
+				state.used.Store(true)
+			}
+			if !send(blk) {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// upstreamFetcher fetches raw blocks from trustless gateways.
+type upstreamFetcher struct {
+	urls   []string
+	client *http.Client
+	// next is the index of the upstream tried first, the last one that
+	// answered.
+	next atomic.Int64
+}
+
+// fetch tries the upstreams in turn, starting with the last one that
+// answered.
+func (u *upstreamFetcher) fetch(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	var errs []error
+	start := int(u.next.Load())
+	for i := range u.urls {
+		idx := (start + i) % len(u.urls)
+		blk, err := u.fetchFrom(ctx, u.urls[idx], c)
+		if err == nil {
+			u.next.Store(int64(idx))
+			return blk, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", u.urls[idx], err))
+	}
+	return nil, fmt.Errorf("fetching %s from the upstream gateways: %w", c, errors.Join(errs...))
+}
+
+func (u *upstreamFetcher) fetchFrom(ctx context.Context, upstream string, c cid.Cid) (blocks.Block, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstream+"/ipfs/"+c.String()+"?format=raw", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.ipld.raw")
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFallbackBlockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFallbackBlockSize {
+		return nil, fmt.Errorf("block larger than %d bytes", maxFallbackBlockSize)
+	}
+	// the upstreams are not trusted: the block must match its CID
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		return nil, fmt.Errorf("block doesn't match its CID, got %s", sum)
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
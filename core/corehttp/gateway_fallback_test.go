@@ -0,0 +1,155 @@
+package corehttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+)
+
+// partialFetcher only has some of the blocks.
+type partialFetcher map[cid.Cid]blocks.Block
+
+func (p partialFetcher) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if b, ok := p[c]; ok {
+		return b, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (p partialFetcher) GetBlocks(_ context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	out := make(chan blocks.Block, len(cids))
+	for _, c := range cids {
+		if b, ok := p[c]; ok {
+			out <- b
+		}
+	}
+	close(out)
+	return out, nil
+}
+
+func newTestUpstream(t *testing.T, served map[string][]byte) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "raw" {
+			http.Error(w, "raw blocks only", http.StatusBadRequest)
+			return
+		}
+		data, ok := served[strings.TrimPrefix(r.URL.Path, "/ipfs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.ipld.raw")
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestParseFallbackConfig(t *testing.T) {
+	cfg, err := parseFallbackConfig(config.GatewayFallback{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.enabled() || cfg.timeout != config.DefaultGatewayFallbackTimeout {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+
+	cfg, err = parseFallbackConfig(config.GatewayFallback{Upstreams: []string{"https://trustless-gateway.link/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.enabled() || cfg.upstreams[0] != "https://trustless-gateway.link" {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+
+	if _, err := parseFallbackConfig(config.GatewayFallback{Upstreams: []string{"ftp://example.com"}}); err == nil {
+		t.Fatal("expected an error for a non-HTTP upstream")
+	}
+}
+
+func TestFallbackExchange(t *testing.T) {
+	local := blocks.NewBlock([]byte("local"))
+	remote := blocks.NewBlock([]byte("remote"))
+	forged := blocks.NewBlock([]byte("forged"))
+
+	bad := newTestUpstream(t, map[string][]byte{
+		remote.Cid().String(): []byte("not the block"),
+	})
+	good := newTestUpstream(t, map[string][]byte{
+		remote.Cid().String(): remote.RawData(),
+		forged.Cid().String(): []byte("something else"),
+	})
+
+	ex := newFallbackExchange(nil, fallbackConfig{
+		upstreams: []string{bad.URL, good.URL},
+		timeout:   50 * time.Millisecond,
+	})
+	f := partialFetcher{local.Cid(): local}
+	ctx := context.Background()
+
+	blk, err := ex.getBlock(ctx, f, local.Cid())
+	if err != nil || !blk.Cid().Equals(local.Cid()) {
+		t.Fatalf("expected the local block, got %v, %v", blk, err)
+	}
+
+	// the block of the bad upstream is rejected, the one of the good upstream
+	// is verified
+	blk, err = ex.getBlock(ctx, f, remote.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(blk.RawData()) != "remote" {
+		t.Fatalf("unexpected block %q", blk.RawData())
+	}
+	if _, err := ex.getBlock(ctx, f, forged.Cid()); err == nil {
+		t.Fatal("expected a block not matching its CID to be rejected")
+	}
+
+	ch, err := ex.getBlocks(ctx, f, []cid.Cid{local.Cid(), remote.Cid(), forged.Cid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[cid.Cid]bool{}
+	for b := range ch {
+		got[b.Cid()] = true
+	}
+	if len(got) != 2 || !got[local.Cid()] || !got[remote.Cid()] {
+		t.Fatalf("unexpected blocks %v", got)
+	}
+}
+
+func TestFallbackState(t *testing.T) {
+	remote := blocks.NewBlock([]byte("remote"))
+	upstream := newTestUpstream(t, map[string][]byte{remote.Cid().String(): remote.RawData()})
+	ex := newFallbackExchange(nil, fallbackConfig{
+		upstreams: []string{upstream.URL},
+		timeout:   time.Hour,
+	})
+
+	state := &fallbackState{}
+	state.used.Store(true)
+	ctx := context.WithValue(context.Background(), fallbackStateKey{}, state)
+
+	// once a request fell back, the node isn't asked again
+	done := make(chan error, 1)
+	go func() {
+		_, err := ex.getBlock(ctx, partialFetcher{}, remote.Cid())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the request waited for the node")
+	}
+}
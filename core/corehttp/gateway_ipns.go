@@ -0,0 +1,70 @@
+package corehttp
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+)
+
+// ipnsRecordContentType is the content type of the signed IPNS records served
+// with ?format=ipns-record, for clients to verify names themselves.
+const ipnsRecordContentType = "application/vnd.ipfs.ipns-record"
+
+// withIPNSRecordCaching caps the max-age of the IPNS records served, derived
+// from their TTL, to the time they remain valid: caches must not keep serving
+// a record after its end of life.
+func withIPNSRecordCaching(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "ipns-record" && !strings.Contains(r.Header.Get("Accept"), ipnsRecordContentType) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&ipnsRecordWriter{ResponseWriter: w}, r)
+	})
+}
+
+// ipnsRecordWriter sets the max-age of the IPNS record written before its
+// headers are sent. The gateway writes records in a single call.
+type ipnsRecordWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *ipnsRecordWriter) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *ipnsRecordWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if w.Header().Get("Content-Type") == ipnsRecordContentType {
+			setIPNSRecordMaxAge(w.Header(), p, time.Now())
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// setIPNSRecordMaxAge sets the max-age of a record to its TTL, or to the time
+// until its end of life when shorter.
+func setIPNSRecordMaxAge(h http.Header, data []byte, now time.Time) {
+	rec, err := ipns.UnmarshalRecord(data)
+	if err != nil {
+		return
+	}
+	eol, err := rec.Validity()
+	if err != nil {
+		return
+	}
+	maxAge := eol.Sub(now)
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	if ttl, err := rec.TTL(); err == nil && ttl < maxAge {
+		maxAge = ttl
+	}
+	h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+}
@@ -0,0 +1,77 @@
+package corehttp
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/kubo/core/coreiface/options"
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPNSRecord(t *testing.T) {
+	ts, api, ctx := newTestServerAndNode(t, mockNamesys{})
+
+	publish := func(eol time.Time, ttl time.Duration) (ipns.Name, []byte) {
+		sk, _, err := ci.GenerateEd25519Key(nil)
+		require.NoError(t, err)
+		pid, err := peer.IDFromPrivateKey(sk)
+		require.NoError(t, err)
+		name := ipns.NameFromPeer(pid)
+
+		value, err := path.NewPath("/ipfs/bafkqaaa")
+		require.NoError(t, err)
+		rec, err := ipns.NewRecord(sk, value, 1, eol, ttl)
+		require.NoError(t, err)
+		data, err := ipns.MarshalRecord(rec)
+		require.NoError(t, err)
+		require.NoError(t, api.Routing().Put(ctx, "/ipns/"+name.String(), data, options.Routing.AllowOffline(true)))
+		return name, data
+	}
+	get := func(name ipns.Name) *http.Response {
+		res, err := http.Get(ts.URL + "/ipns/" + name.String() + "?format=ipns-record")
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+	maxAge := func(res *http.Response) int {
+		cc := res.Header.Get("Cache-Control")
+		require.True(t, strings.HasPrefix(cc, "public, max-age="), cc)
+		age, err := strconv.Atoi(strings.TrimPrefix(cc, "public, max-age="))
+		require.NoError(t, err)
+		return age
+	}
+
+	// the max-age is the TTL of the record
+	name, data := publish(time.Now().Add(24*time.Hour), time.Hour)
+	res := get(name)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, ipnsRecordContentType, res.Header.Get("Content-Type"))
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, data, body)
+	require.Equal(t, 3600, maxAge(res))
+
+	// unless the record expires before
+	name, _ = publish(time.Now().Add(10*time.Minute), time.Hour)
+	res = get(name)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	age := maxAge(res)
+	require.LessOrEqual(t, age, 600)
+	require.Greater(t, age, 500)
+
+	// missing records are not found
+	sk, _, err := ci.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	pid, err := peer.IDFromPrivateKey(sk)
+	require.NoError(t, err)
+	res = get(ipns.NameFromPeer(pid))
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
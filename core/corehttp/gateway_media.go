@@ -0,0 +1,266 @@
+package corehttp
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	format "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/config"
+)
+
+// Regions of the media files prefetched by the gateway, see
+// Gateway.MediaPrefetch.
+const (
+	// mediaHeadSize covers the headers at the start of the files, e.g. the
+	// ftyp and moov boxes of MP4 files optimized for streaming, or the
+	// EBML header and tracks of WebM files.
+	mediaHeadSize = 1 << 20
+	// mediaTailSize covers the indexes at the end of the files, e.g. the
+	// moov box of MP4 files not optimized for streaming, or WebM cues.
+	mediaTailSize = 1 << 20
+	// mediaMaxMoovSize is the part of a moov box prefetched.
+	mediaMaxMoovSize = 16 << 20
+	// mediaMaxBoxes is the number of top level MP4 boxes walked looking for
+	// the moov box.
+	mediaMaxBoxes = 16
+
+	mediaPrefetchTimeout = time.Minute
+	mediaPrefetchRecent  = 1024
+)
+
+type mediaRequestKey struct{}
+
+func mediaPrefetchEnabled(cfg *config.Config) bool {
+	return cfg.Gateway.MediaPrefetch.WithDefault(config.DefaultGatewayMediaPrefetch)
+}
+
+// withMediaPrefetch marks the contexts of the GET requests asking for video
+// or audio, for mediaBackend.
+func withMediaPrefetch(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && acceptsMedia(r.Header.Get("Accept")) {
+			r = r.WithContext(context.WithValue(r.Context(), mediaRequestKey{}, true))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acceptsMedia reports whether the Accept header asks for video or audio.
+func acceptsMedia(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mt, _, _ := strings.Cut(part, ";")
+		mt = strings.ToLower(strings.TrimSpace(mt))
+		if strings.HasPrefix(mt, "video/") || strings.HasPrefix(mt, "audio/") {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaBackend prefetches the regions media players read before the first
+// frame of the files requested by media requests, so that the range requests
+// that follow don't wait for the network.
+type mediaBackend struct {
+	gateway.IPFSBackend
+	dag format.NodeGetter
+
+	lk     sync.Mutex
+	recent map[cid.Cid]time.Time
+}
+
+func newMediaBackend(backend gateway.IPFSBackend, dag format.NodeGetter) *mediaBackend {
+	return &mediaBackend{
+		IPFSBackend: backend,
+		dag:         dag,
+		recent:      make(map[cid.Cid]time.Time),
+	}
+}
+
+func (b *mediaBackend) Get(ctx context.Context, path path.ImmutablePath, ranges ...gateway.ByteRange) (gateway.ContentPathMetadata, *gateway.GetResponse, error) {
+	md, resp, err := b.IPFSBackend.Get(ctx, path, ranges...)
+	if err == nil && ctx.Value(mediaRequestKey{}) != nil && len(md.LastSegmentRemainder) == 0 {
+		b.prefetch(md.LastSegment.RootCid())
+	}
+	return md, resp, err
+}
+
+// prefetch prefetches the media regions of the file c in the background,
+// unless it was prefetched recently.
+func (b *mediaBackend) prefetch(c cid.Cid) {
+	now := time.Now()
+	b.lk.Lock()
+	if t, ok := b.recent[c]; ok && now.Sub(t) < mediaPrefetchTimeout {
+		b.lk.Unlock()
+		return
+	}
+	if len(b.recent) >= mediaPrefetchRecent {
+		for k, t := range b.recent {
+			if now.Sub(t) >= mediaPrefetchTimeout {
+				delete(b.recent, k)
+			}
+		}
+		if len(b.recent) >= mediaPrefetchRecent {
+			b.recent = make(map[cid.Cid]time.Time)
+		}
+	}
+	b.recent[c] = now
+	b.lk.Unlock()
+
+	go func() {
+		// the requests of the player that follow use the prefetched blocks,
+		// the prefetch outlives the request that started it
+		ctx, cancel := context.WithTimeout(context.Background(), mediaPrefetchTimeout)
+		defer cancel()
+		if err := prefetchMedia(ctx, b.dag, c); err != nil {
+			log.Debugf("prefetching the media regions of %s: %s", c, err)
+		}
+	}()
+}
+
+// prefetchMedia fetches the blocks of the media regions of the UnixFS file c:
+// its head, the moov box of MP4 files, and its tail.
+func prefetchMedia(ctx context.Context, ng format.NodeGetter, c cid.Cid) error {
+	nd, err := ng.Get(ctx, c)
+	if err != nil {
+		return err
+	}
+	pn, ok := nd.(*merkledag.ProtoNode)
+	if !ok {
+		// a single raw block, fetched already
+		return nil
+	}
+	fsn, err := ft.FSNodeFromBytes(pn.Data())
+	if err != nil {
+		return err
+	}
+	if fsn.Type() != ft.TFile && fsn.Type() != ft.TRaw {
+		return nil
+	}
+	size := fsn.FileSize()
+	discard := func([]byte) {}
+
+	if err := readRange(ctx, ng, nd, 0, 0, mediaHeadSize, discard); err != nil {
+		return err
+	}
+	if off, n, ok := findMoov(ctx, ng, nd, size); ok {
+		if n > mediaMaxMoovSize {
+			n = mediaMaxMoovSize
+		}
+		if err := readRange(ctx, ng, nd, 0, off, n, discard); err != nil {
+			return err
+		}
+	}
+	if size > mediaTailSize {
+		return readRange(ctx, ng, nd, 0, size-mediaTailSize, mediaTailSize, discard)
+	}
+	return nil
+}
+
+// findMoov walks the top level boxes of the MP4 file nd of the given size,
+// and returns the offset and size of its moov box.
+func findMoov(ctx context.Context, ng format.NodeGetter, nd format.Node, size uint64) (uint64, uint64, bool) {
+	var off uint64
+	for i := 0; i < mediaMaxBoxes && off+8 <= size; i++ {
+		hdr := make([]byte, 0, 16)
+		err := readRange(ctx, ng, nd, 0, off, 16, func(data []byte) {
+			hdr = append(hdr, data...)
+		})
+		if err != nil || len(hdr) < 8 {
+			return 0, 0, false
+		}
+		typ := string(hdr[4:8])
+		if i == 0 && typ != "ftyp" {
+			// not an MP4 file
+			return 0, 0, false
+		}
+
+		n := uint64(binary.BigEndian.Uint32(hdr[:4]))
+		switch n {
+		case 0:
+			// the box extends to the end of the file
+			n = size - off
+		case 1:
+			if len(hdr) < 16 {
+				return 0, 0, false
+			}
+			n = binary.BigEndian.Uint64(hdr[8:16])
+		}
+		if n < 8 {
+			return 0, 0, false
+		}
+		if typ == "moov" {
+			return off, n, true
+		}
+		off += n
+	}
+	return 0, 0, false
+}
+
+// readRange calls fn with the data of the UnixFS file node nd, at the offset
+// base of the file, within [off, off+n), in order. Only the nodes overlapping
+// the range are fetched, the children of a node in parallel, using the block
+// sizes of the nodes: in trickle DAGs, the first bytes are in the direct
+// children of the root, and are read in a single round trip.
+func readRange(ctx context.Context, ng format.NodeGetter, nd format.Node, base, off, n uint64, fn func([]byte)) error {
+	var data []byte
+	var sizes []uint64
+	switch nd := nd.(type) {
+	case *merkledag.RawNode:
+		data = nd.RawData()
+	case *merkledag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return err
+		}
+		data = fsn.Data()
+		sizes = fsn.BlockSizes()
+	default:
+		return fmt.Errorf("unexpected node %s in a file", nd.Cid())
+	}
+	links := nd.Links()
+	if len(sizes) != len(links) {
+		return fmt.Errorf("file node %s has %d links but %d block sizes", nd.Cid(), len(links), len(sizes))
+	}
+
+	end := off + n
+	if lo, hi := base, base+uint64(len(data)); lo < end && hi > off {
+		if lo < off {
+			lo = off
+		}
+		if hi > end {
+			hi = end
+		}
+		fn(data[lo-base : hi-base])
+	}
+
+	var cids []cid.Cid
+	var offs []uint64
+	childOff := base + uint64(len(data))
+	for i, l := range links {
+		if childOff < end && childOff+sizes[i] > off {
+			cids = append(cids, l.Cid)
+			offs = append(offs, childOff)
+		}
+		childOff += sizes[i]
+	}
+	for i, p := range format.GetNodes(ctx, ng, cids) {
+		child, err := p.Get(ctx)
+		if err != nil {
+			return err
+		}
+		if err := readRange(ctx, ng, child, offs[i], off, n, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package corehttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	chunker "github.com/ipfs/boxo/chunker"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/trickle"
+)
+
+func TestAcceptsMedia(t *testing.T) {
+	for accept, want := range map[string]bool{
+		"video/webm,video/ogg,video/*;q=0.9,application/ogg;q=0.7,audio/*;q=0.6,*/*;q=0.5": true,
+		"Audio/MPEG": true,
+		"text/html,application/xhtml+xml,*/*;q=0.8": false,
+		"": false,
+	} {
+		if got := acceptsMedia(accept); got != want {
+			t.Errorf("acceptsMedia(%q) = %t, want %t", accept, got, want)
+		}
+	}
+}
+
+func mp4Box(typ string, size int) []byte {
+	box := make([]byte, size)
+	binary.BigEndian.PutUint32(box, uint32(size))
+	copy(box[4:], typ)
+	rand.New(rand.NewSource(int64(size))).Read(box[8:])
+	return box
+}
+
+func TestMediaRanges(t *testing.T) {
+	ctx := context.Background()
+	ds := dagtest.Mock()
+
+	// an MP4 file not optimized for streaming, with its moov box at the end
+	var data []byte
+	data = append(data, mp4Box("ftyp", 24)...)
+	data = append(data, mp4Box("mdat", 200000)...)
+	data = append(data, mp4Box("moov", 3000)...)
+
+	params := helpers.DagBuilderParams{Dagserv: ds, Maxlinks: 8}
+	db, err := params.New(chunker.NewSizeSplitter(bytes.NewReader(data), 512))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := trickle.Layout(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range [][2]uint64{{0, 100}, {511, 2}, {1000, 70000}, {uint64(len(data)) - 10, 100}} {
+		var got []byte
+		if err := readRange(ctx, ds, nd, 0, r[0], r[1], func(b []byte) { got = append(got, b...) }); err != nil {
+			t.Fatal(err)
+		}
+		end := r[0] + r[1]
+		if end > uint64(len(data)) {
+			end = uint64(len(data))
+		}
+		if !bytes.Equal(data[r[0]:end], got) {
+			t.Fatalf("range %d+%d: unexpected data", r[0], r[1])
+		}
+	}
+
+	off, n, ok := findMoov(ctx, ds, nd, uint64(len(data)))
+	if !ok {
+		t.Fatal("moov box not found")
+	}
+	if off != 200024 || n != 3000 {
+		t.Fatalf("moov box at %d+%d, want 200024+3000", off, n)
+	}
+	if err := prefetchMedia(ctx, ds, nd.Cid()); err != nil {
+		t.Fatal(err)
+	}
+}
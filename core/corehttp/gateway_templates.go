@@ -0,0 +1,334 @@
+package corehttp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	humanize "github.com/dustin/go-humanize"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/go-cid"
+	format "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core"
+)
+
+// gatewayAssetsPath is where the static files of Gateway.Templates.Assets are
+// served.
+const gatewayAssetsPath = "/ipfs-gateway-assets/"
+
+// gatewayTemplates are the templates of Gateway.Templates. The nil templates
+// are the ones of the gateway.
+type gatewayTemplates struct {
+	listing *template.Template
+	error   *template.Template
+	assets  string
+}
+
+// ListingData is the data of the directory listing templates.
+type ListingData struct {
+	// Path is the content path of the directory, such as /ipfs/<cid>/dir,
+	// and CID the CID of the directory.
+	Path string
+	CID  string
+	// HasParent is whether the directory is in a parent directory of the
+	// content path, which the template can link to with "..".
+	HasParent bool
+	Entries   []ListingEntry
+	// AssetsPath is where the static files of Gateway.Templates.Assets are
+	// served.
+	AssetsPath string
+}
+
+// ListingEntry is an entry of a directory listing.
+type ListingEntry struct {
+	Name string
+	// Href is the URL of the entry, relative to the listing.
+	Href string
+	CID  string
+	// Size is the cumulative size of the entry, human readable, and Bytes
+	// the same in bytes.
+	Size  string
+	Bytes uint64
+}
+
+// ErrorData is the data of the error page templates.
+type ErrorData struct {
+	StatusCode int
+	StatusText string
+	// Error is the error message of the gateway.
+	Error      string
+	Path       string
+	AssetsPath string
+}
+
+// getGatewayTemplates loads and validates the templates of
+// Gateway.Templates. It returns nil when none is configured.
+func getGatewayTemplates(n *core.IpfsNode) (*gatewayTemplates, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	var repoPath string
+	if r, ok := n.Repo.(interface{ Path() string }); ok {
+		repoPath = r.Path()
+	}
+	return loadGatewayTemplates(cfg.Gateway, repoPath)
+}
+
+func loadGatewayTemplates(cfg config.Gateway, repoPath string) (*gatewayTemplates, error) {
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(repoPath, p)
+	}
+	listing := resolve(cfg.Templates.DirectoryListing.WithDefault(""))
+	errPage := resolve(cfg.Templates.Error.WithDefault(""))
+	assets := resolve(cfg.Templates.Assets.WithDefault(""))
+	if listing == "" && errPage == "" && assets == "" {
+		return nil, nil
+	}
+
+	t := &gatewayTemplates{assets: assets}
+	var err error
+	if listing != "" {
+		sample := ListingData{
+			Path:       "/ipfs/bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi/dir",
+			CID:        "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi",
+			HasParent:  true,
+			Entries:    []ListingEntry{{Name: "file", Href: "file", CID: "bafkqaaa", Size: "0 B"}},
+			AssetsPath: gatewayAssetsPath,
+		}
+		if t.listing, err = loadTemplate("DirectoryListing", listing, sample); err != nil {
+			return nil, err
+		}
+	}
+	// the message of the errors is only known when they are plain text
+	if errPage != "" && !cfg.DisableHTMLErrors.WithDefault(config.DefaultDisableHTMLErrors) {
+		sample := ErrorData{
+			StatusCode: http.StatusNotFound,
+			StatusText: http.StatusText(http.StatusNotFound),
+			Error:      "not found",
+			Path:       "/ipfs/bafkqaaa/missing",
+			AssetsPath: gatewayAssetsPath,
+		}
+		if t.error, err = loadTemplate("Error", errPage, sample); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// loadTemplate parses the template file at path, and executes it with the
+// sample data so that the templates referring to unknown fields fail at
+// startup rather than on each request.
+func loadTemplate(name, path string, sample any) (*template.Template, error) {
+	t, err := template.ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading Gateway.Templates.%s: %w", name, err)
+	}
+	if err := t.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("validating Gateway.Templates.%s: %w", name, err)
+	}
+	return t, nil
+}
+
+// plainErrors is whether the gateway must send its errors as plain text, for
+// the error template.
+func (t *gatewayTemplates) plainErrors() bool {
+	return t != nil && t.error != nil
+}
+
+// serveAssets serves the static files of Gateway.Templates.Assets on mux.
+func (t *gatewayTemplates) serveAssets(mux *http.ServeMux) {
+	if t == nil || t.assets == "" {
+		return
+	}
+	mux.Handle(gatewayAssetsPath, http.StripPrefix(gatewayAssetsPath, http.FileServer(http.Dir(t.assets))))
+}
+
+// withListingTemplate replaces the HTML directory listings of the gateway by
+// the ones of the listing template, listing the directories from dag.
+func withListingTemplate(t *gatewayTemplates, dag format.DAGService, next http.Handler) http.Handler {
+	if t == nil || t.listing == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tw := &templateWriter{ResponseWriter: w, match: isListing}
+		next.ServeHTTP(tw, r)
+		if tw.buf == nil {
+			return
+		}
+		data, err := listingData(r, w.Header(), dag)
+		if err != nil {
+			log.Errorf("listing %s for the directory listing template: %s", r.URL.Path, err)
+			tw.flush()
+			return
+		}
+		tw.render(t.listing, data)
+	})
+}
+
+// withErrorTemplate renders the plain text errors of the gateway with the
+// error template for the browsers, which accept HTML.
+func withErrorTemplate(t *gatewayTemplates, next http.Handler) http.Handler {
+	if t == nil || t.error == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tw := &templateWriter{ResponseWriter: w, match: isPlainError}
+		next.ServeHTTP(tw, r)
+		if tw.buf == nil {
+			return
+		}
+		tw.render(t.error, ErrorData{
+			StatusCode: tw.code,
+			StatusText: http.StatusText(tw.code),
+			Error:      strings.TrimSpace(tw.buf.String()),
+			Path:       r.URL.Path,
+			AssetsPath: gatewayAssetsPath,
+		})
+	})
+}
+
+// isListing reports whether a response is a directory listing of the gateway,
+// which tags them with a DirIndex- ETag.
+func isListing(code int, h http.Header) bool {
+	mt, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return code == http.StatusOK && mt == "text/html" && strings.HasPrefix(strings.TrimPrefix(h.Get("Etag"), "W/"), `"DirIndex-`)
+}
+
+// isPlainError reports whether a response is a plain text error.
+func isPlainError(code int, h http.Header) bool {
+	mt, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return code >= http.StatusBadRequest && mt == "text/plain"
+}
+
+// templateWriter buffers the body of the responses matching match, to render
+// them with a template, passing the other responses through.
+type templateWriter struct {
+	http.ResponseWriter
+	match       func(code int, h http.Header) bool
+	wroteHeader bool
+	code        int
+	buf         *bytes.Buffer
+}
+
+func (w *templateWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if w.match(code, w.Header()) {
+		w.code = code
+		w.buf = new(bytes.Buffer)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *templateWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buf != nil {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *templateWriter) Flush() {
+	if w.buf != nil {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// flush sends the buffered response as is.
+func (w *templateWriter) flush() {
+	w.ResponseWriter.WriteHeader(w.code)
+	w.ResponseWriter.Write(w.buf.Bytes())
+}
+
+// render sends the buffered response rendered with t instead.
+func (w *templateWriter) render(t *template.Template, data any) {
+	var out bytes.Buffer
+	if err := t.Execute(&out, data); err != nil {
+		log.Errorf("rendering the gateway template %s: %s", t.Name(), err)
+		w.flush()
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.code)
+	w.ResponseWriter.Write(out.Bytes())
+}
+
+// listingData lists the directory of a listing response, the last of its
+// X-Ipfs-Roots, which the gateway just read.
+func listingData(r *http.Request, h http.Header, dag format.DAGService) (ListingData, error) {
+	roots := strings.Split(h.Get("X-Ipfs-Roots"), ",")
+	c, err := cid.Decode(roots[len(roots)-1])
+	if err != nil {
+		return ListingData{}, err
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), modTimeTimeout)
+	defer cancel()
+	nd, err := dag.Get(ctx, c)
+	if err != nil {
+		return ListingData{}, err
+	}
+	dir, err := uio.NewDirectoryFromNode(dag, nd)
+	if err != nil {
+		return ListingData{}, err
+	}
+	links, err := dir.Links(ctx)
+	if err != nil {
+		return ListingData{}, err
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Name < links[j].Name })
+
+	p := h.Get("X-Ipfs-Path")
+	if p == "" {
+		p = r.URL.Path
+	}
+	data := ListingData{
+		Path:       strings.TrimSuffix(p, "/"),
+		CID:        c.String(),
+		HasParent:  strings.Count(strings.Trim(p, "/"), "/") > 1,
+		AssetsPath: gatewayAssetsPath,
+	}
+	for _, l := range links {
+		data.Entries = append(data.Entries, ListingEntry{
+			Name:  l.Name,
+			Href:  "./" + url.PathEscape(l.Name),
+			CID:   l.Cid.String(),
+			Size:  humanize.Bytes(l.Size),
+			Bytes: l.Size,
+		})
+	}
+	return data, nil
+}
@@ -0,0 +1,84 @@
+package corehttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	format "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayTemplates(t *testing.T) {
+	repo := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644))
+	}
+	write("listing.html", `<h1>{{.Path}}</h1>{{range .Entries}}<a href="{{.Href}}">{{.Name}}</a> {{.Size}};{{end}}`)
+	write("error.html", `<h1>{{.StatusCode}} {{.StatusText}}</h1><p>{{.Error}}</p>`)
+	write("bad.html", `{{.Missing}}`)
+
+	cfg := config.Gateway{Templates: config.GatewayTemplates{
+		DirectoryListing: config.NewOptionalString("listing.html"),
+		Error:            config.NewOptionalString(filepath.Join(repo, "error.html")),
+	}}
+	templates, err := loadGatewayTemplates(cfg, repo)
+	require.NoError(t, err)
+	require.True(t, templates.plainErrors())
+
+	// the templates are validated at startup
+	_, err = loadGatewayTemplates(config.Gateway{Templates: config.GatewayTemplates{DirectoryListing: config.NewOptionalString("bad.html")}}, repo)
+	require.ErrorContains(t, err, "Gateway.Templates.DirectoryListing")
+	_, err = loadGatewayTemplates(config.Gateway{Templates: config.GatewayTemplates{Error: config.NewOptionalString("missing.html")}}, repo)
+	require.ErrorContains(t, err, "Gateway.Templates.Error")
+	none, err := loadGatewayTemplates(config.Gateway{}, repo)
+	require.NoError(t, err)
+	require.Nil(t, none)
+
+	dag := dagtest.Mock()
+	file := merkledag.NewRawNode([]byte("file"))
+	dir := ft.EmptyDirNode()
+	require.NoError(t, dir.AddNodeLink("a file", file))
+	require.NoError(t, dag.AddMany(context.Background(), []format.Node{file, dir}))
+
+	h := withErrorTemplate(templates, withListingTemplate(templates, dag, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ipfs/dir/" {
+			http.Error(w, "no link named <missing>", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Etag", `"DirIndex-abc_CID-`+dir.Cid().String()+`"`)
+		w.Header().Set("X-Ipfs-Roots", dir.Cid().String())
+		w.Header().Set("X-Ipfs-Path", "/ipfs/"+dir.Cid().String()+"/")
+		w.Write([]byte("<html>the listing of the gateway</html>"))
+	})))
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	res := get("/ipfs/dir/", "text/html")
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Equal(t, `<h1>/ipfs/`+dir.Cid().String()+`</h1><a href="./a%20file">a file</a> 4 B;`, res.Body.String())
+	require.Equal(t, `"DirIndex-abc_CID-`+dir.Cid().String()+`"`, res.Header().Get("Etag"))
+
+	res = get("/ipfs/missing", "text/html,*/*")
+	require.Equal(t, http.StatusNotFound, res.Code)
+	require.Equal(t, "text/html; charset=utf-8", res.Header().Get("Content-Type"))
+	require.Equal(t, "<h1>404 Not Found</h1><p>no link named &lt;missing&gt;</p>", res.Body.String())
+
+	// the other clients get the plain text errors
+	res = get("/ipfs/missing", "*/*")
+	require.Equal(t, http.StatusNotFound, res.Code)
+	require.Equal(t, "no link named <missing>\n", res.Body.String())
+}
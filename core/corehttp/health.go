@@ -0,0 +1,34 @@
+package corehttp
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+
+	core "github.com/ipfs/kubo/core"
+)
+
+// HealthOption adds the /livez and /readyz probes. Both answer 200 when the
+// check passes and 503 otherwise, /readyz with the readiness of each
+// component of the node in the body.
+func HealthOption() ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		mux.HandleFunc("/livez", func(w http.ResponseWriter, r *http.Request) {
+			if !n.Live() {
+				http.Error(w, "not live", http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("ok\n"))
+		})
+		mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+			readiness := n.Readiness()
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			if !readiness.Ready {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			json.NewEncoder(w).Encode(readiness)
+		})
+		return mux, nil
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	core "github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/repoforecast"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/zpages"
 
@@ -158,12 +159,43 @@ var peersTotalMetric = prometheus.NewDesc(
 	nil,
 )
 
+var (
+	repoSizeMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "size_bytes"),
+		"Size of the repo at the last sample of the forecast",
+		nil,
+		nil,
+	)
+	repoGrowthMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "growth_bytes_per_second"),
+		"Growth rate of the repo",
+		nil,
+		nil,
+	)
+	repoFullMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "full_seconds"),
+		"Time until the repo is forecast to reach Datastore.StorageMax",
+		nil,
+		nil,
+	)
+	repoForecastLevelMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "forecast_level"),
+		"Level of the forecast of the repo size, 1 for the current level",
+		[]string{"level"},
+		nil,
+	)
+)
+
 type IpfsNodeCollector struct {
 	Node *core.IpfsNode
 }
 
 func (IpfsNodeCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- peersTotalMetric
+	ch <- repoSizeMetric
+	ch <- repoGrowthMetric
+	ch <- repoFullMetric
+	ch <- repoForecastLevelMetric
 }
 
 func (c IpfsNodeCollector) Collect(ch chan<- prometheus.Metric) {
@@ -175,6 +207,33 @@ func (c IpfsNodeCollector) Collect(ch chan<- prometheus.Metric) {
 			tr,
 		)
 	}
+	c.collectRepoForecast(ch)
+}
+
+func (c IpfsNodeCollector) collectRepoForecast(ch chan<- prometheus.Metric) {
+	if c.Node.RepoForecast == nil {
+		return
+	}
+	f := c.Node.RepoForecast.Forecast()
+	if f.Samples == 0 {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(repoSizeMetric, prometheus.GaugeValue, float64(f.Size))
+	ch <- prometheus.MustNewConstMetric(repoGrowthMetric, prometheus.GaugeValue, f.Rate)
+	if f.Full != nil {
+		full := time.Until(*f.Full).Seconds()
+		if full < 0 {
+			full = 0
+		}
+		ch <- prometheus.MustNewConstMetric(repoFullMetric, prometheus.GaugeValue, full)
+	}
+	for _, level := range []repoforecast.Level{repoforecast.LevelOK, repoforecast.LevelWarning, repoforecast.LevelCritical} {
+		val := 0.0
+		if f.Level == level {
+			val = 1
+		}
+		ch <- prometheus.MustNewConstMetric(repoForecastLevelMetric, prometheus.GaugeValue, val, string(level))
+	}
 }
 
 func (c IpfsNodeCollector) PeersTotalValues() map[string]float64 {
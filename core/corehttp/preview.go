@@ -0,0 +1,175 @@
+package corehttp
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	format "github.com/ipfs/go-ipld-format"
+	core "github.com/ipfs/kubo/core"
+)
+
+// PreviewPath is the path the previews of files are served under, followed by
+// the CID of a file.
+const PreviewPath = "/preview/"
+
+// previewListingScript loads the previews of the first 200 entries of a
+// directory listing: thumbnails replace the icons of images, and snippets are
+// shown as the titles of the links to text files.
+const previewListingScript = `<script>
+(function () {
+  var links = document.querySelectorAll('.grid.dir a.ipfs-hash')
+  for (var i = 0; i < links.length && i < 200; i++) {
+    (function (a) {
+      var m = /(?:\/ipfs\/|#)([0-9A-Za-z]+)/.exec(a.getAttribute('href') || '')
+      var cell = a.parentElement
+      var name = cell.previousElementSibling
+      var icon = name && name.previousElementSibling
+      if (!m || !icon) return
+      fetch('/preview/' + m[1]).then(function (res) {
+        if (!res.ok) return
+        if ((res.headers.get('Content-Type') || '').indexOf('image/') === 0) {
+          return res.blob().then(function (blob) {
+            var img = document.createElement('img')
+            img.src = URL.createObjectURL(blob)
+            img.alt = ''
+            img.style.maxWidth = img.style.maxHeight = '2em'
+            icon.replaceChildren(img)
+          })
+        }
+        return res.text().then(function (text) {
+          var link = name.querySelector('a')
+          if (link) link.title = text
+        })
+      }).catch(function () {})
+    })(links[i])
+  }
+})()
+</script>
+`
+
+// PreviewOption adds the PreviewPath endpoint, serving the thumbnails of
+// images as JPEG, and the snippets of text files as plain text, see
+// Experimental.Previews. Previews are only made of the files stored locally,
+// it answers 404 Not Found for other files.
+func PreviewOption() ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		mux.HandleFunc(PreviewPath, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				w.Header().Set("Allow", "GET, HEAD")
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if n.Previews == nil {
+				http.Error(w, "previews are disabled, see Experimental.Previews", http.StatusNotFound)
+				return
+			}
+			c, err := cid.Decode(strings.TrimPrefix(r.URL.Path, PreviewPath))
+			if err != nil {
+				http.Error(w, "invalid CID: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			p, err := n.Previews.Preview(r.Context(), c)
+			if err != nil {
+				if format.IsNotFound(err) {
+					http.Error(w, "not stored locally", http.StatusNotFound)
+					return
+				}
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if p.Empty() {
+				http.Error(w, "no preview", http.StatusNotFound)
+				return
+			}
+
+			// the preview of a CID never changes
+			w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			if len(p.Thumbnail) > 0 {
+				w.Header().Set("Content-Type", "image/jpeg")
+				w.Write(p.Thumbnail)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(p.Snippet))
+		})
+		return mux, nil
+	}
+}
+
+// withPreviewListing adds previewListingScript to the HTML directory listings
+// of the gateway.
+func withPreviewListing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		lw := &listingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(lw, r)
+		lw.finish()
+	})
+}
+
+// listingResponseWriter buffers the body of directory listings, passing the
+// other responses through.
+type listingResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	code        int
+	listing     *bytes.Buffer
+}
+
+func (w *listingResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	// the gateway tags its directory listings with a DirIndex- ETag
+	if code == http.StatusOK && strings.HasPrefix(h.Get("Content-Type"), "text/html") && strings.HasPrefix(h.Get("Etag"), `"DirIndex-`) {
+		w.code = code
+		w.listing = new(bytes.Buffer)
+		h.Del("Content-Length")
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *listingResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.listing != nil {
+		return w.listing.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *listingResponseWriter) Flush() {
+	if w.listing != nil {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+func (w *listingResponseWriter) finish() {
+	if w.listing == nil {
+		return
+	}
+	body := w.listing.Bytes()
+	if i := bytes.LastIndex(body, []byte("</body>")); i >= 0 {
+		body = append(body[:i:i], append([]byte(previewListingScript), body[i:]...)...)
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+	w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,36 @@
+package corehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPreviewListing(t *testing.T) {
+	h := withPreviewListing(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/ipfs/dir/" {
+			w.Header().Set("Etag", `"DirIndex-abc_CID-bafy"`)
+		}
+		w.Write([]byte("<html><body>listing</body></html>"))
+	}))
+
+	for _, tc := range []struct {
+		path   string
+		script bool
+	}{
+		{"/ipfs/dir/", true},
+		{"/ipfs/dir/index.html", false},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK || !strings.HasPrefix(body, "<html><body>listing") || !strings.HasSuffix(body, "</body></html>") {
+			t.Fatalf("%s: unexpected response %d %q", tc.path, rec.Code, body)
+		}
+		if strings.Contains(body, previewListingScript) != tc.script {
+			t.Errorf("%s: expected the script: %t, got %q", tc.path, tc.script, body)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package corehttp
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	cmdsHttp "github.com/ipfs/go-ipfs-cmds/http"
+	oldcmds "github.com/ipfs/kubo/commands"
+	core "github.com/ipfs/kubo/core"
+	corecommands "github.com/ipfs/kubo/core/commands"
+	"github.com/ipfs/kubo/core/commands/cmdhooks"
+	"github.com/ipfs/kubo/core/readonly"
+	"github.com/ipfs/kubo/core/reqbudget"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// RemoteAdminOption serves the corecommands.RootRemoteAdmin commands to the
+// peers listed in RemoteAdmin.Operators. It must be used with a libp2p
+// listener, whose connections are addressed by the ID of the remote peer.
+func RemoteAdminOption(cctx oldcmds.Context) ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		rcfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+		operators := make(map[peer.ID]struct{}, len(rcfg.RemoteAdmin.Operators))
+		for _, s := range rcfg.RemoteAdmin.Operators {
+			p, err := peer.Decode(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RemoteAdmin.Operators entry %q: %w", s, err)
+			}
+			operators[p] = struct{}{}
+		}
+		budgets, err := reqbudget.ParseBudgets(rcfg.API.RequestBudgets)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg := cmdsHttp.NewServerConfig()
+		cfg.SetAllowedMethods(http.MethodPost)
+		cfg.APIPath = APIPath
+
+		handler := cmdsHttp.NewHandler(&cctx, cmdhooks.Wrap(reqbudget.Guard(readonly.Guard(corecommands.RootRemoteAdmin, n.ReadOnly), budgets)), cfg)
+		mux.Handle(APIPath+"/", withCommandCaller(withOperators(operators, handler)))
+		return mux, nil
+	}
+}
+
+func withOperators(operators map[peer.ID]struct{}, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := peer.Decode(r.RemoteAddr)
+		if err == nil {
+			if _, ok := operators[p]; ok {
+				log.Infof("remote admin: %s called %s", p, r.URL.Path)
+				if c := cmdhooks.CallerFromContext(r.Context()); c != nil {
+					c.Peer = p
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		http.Error(w, "403 - Forbidden", http.StatusForbidden)
+	})
+}
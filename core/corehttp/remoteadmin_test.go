@@ -0,0 +1,40 @@
+package corehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestWithOperators(t *testing.T) {
+	operator, err := peer.Decode("12D3KooWRBy97UB99e3J6hiPesre1MZeuNQvfan4gBziswrRJsNK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, err := peer.Decode("12D3KooWJ8TBCK9QsGWwTQpXHfk4akCsD1EbQRWomfWFqtVBkFJH")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := withOperators(map[peer.ID]struct{}{operator: {}}, ok)
+
+	for _, tc := range []struct {
+		remote string
+		code   int
+	}{
+		{operator.String(), http.StatusOK},
+		{stranger.String(), http.StatusForbidden},
+		{"127.0.0.1:4001", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodPost, APIPath+"/stats/bw", nil)
+		req.RemoteAddr = tc.remote
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.code {
+			t.Errorf("%s: expected %d, got %d", tc.remote, tc.code, rec.Code)
+		}
+	}
+}
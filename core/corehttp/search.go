@@ -0,0 +1,92 @@
+package corehttp
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+
+	core "github.com/ipfs/kubo/core"
+)
+
+// searchHit is a hit of the full-text index, as returned by /search.
+type searchHit struct {
+	Source  string
+	Path    string
+	Cid     string
+	Root    string `json:",omitempty"`
+	Title   string `json:",omitempty"`
+	Format  string
+	Score   float64
+	Snippet string
+}
+
+// SearchOption adds the /search endpoint, querying the full-text index of the
+// node with the q parameter, see Experimental.FullTextSearch. The hits are
+// returned as JSON, best match first, at most limit of them (20 by default).
+//
+// The index reveals the content of the node, so /search only answers the
+// requests from the loopback interface, or from a unix socket.
+func SearchOption() ServeOption {
+	return func(n *core.IpfsNode, l net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		unix := l.Addr().Network() == "unix"
+		mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+			if !unix && !isLoopback(r.RemoteAddr) {
+				http.Error(w, "search is only available locally", http.StatusForbidden)
+				return
+			}
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				w.Header().Set("Allow", "GET, HEAD")
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if n.FullText == nil {
+				http.Error(w, "full-text search is disabled, see Experimental.FullTextSearch", http.StatusNotFound)
+				return
+			}
+
+			limit := 20
+			if s := r.URL.Query().Get("limit"); s != "" {
+				var err error
+				if limit, err = strconv.Atoi(s); err != nil || limit < 0 {
+					http.Error(w, "invalid limit", http.StatusBadRequest)
+					return
+				}
+			}
+			found, err := n.FullText.Query(r.Context(), r.URL.Query().Get("q"), limit)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			hits := make([]searchHit, len(found))
+			for i, h := range found {
+				hits[i] = searchHit{
+					Source:  h.Source,
+					Path:    h.Path,
+					Cid:     h.Doc.Cid.String(),
+					Title:   h.Doc.Title,
+					Format:  h.Doc.Format,
+					Score:   h.Score,
+					Snippet: h.Snippet,
+				}
+				if h.Doc.Root.Defined() {
+					hits[i].Root = h.Doc.Root.String()
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			json.NewEncoder(w).Encode(hits)
+		})
+		return mux, nil
+	}
+}
+
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,42 @@
+package corehttp
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	core "github.com/ipfs/kubo/core"
+)
+
+func TestSearchIsLocal(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	mux, err := SearchOption()(&core.IpfsNode{}, l, http.NewServeMux())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		remote string
+		code   int
+	}{
+		// full-text search is disabled on this node
+		{"127.0.0.1:4001", http.StatusNotFound},
+		{"[::1]:4001", http.StatusNotFound},
+		{"192.168.1.2:4001", http.StatusForbidden},
+		{"[2001:db8::1]:4001", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/search?q=apple", nil)
+		req.RemoteAddr = tc.remote
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tc.code {
+			t.Errorf("%s: expected %d, got %d", tc.remote, tc.code, rec.Code)
+		}
+	}
+}
@@ -1,3 +1,6 @@
+//go:build !nowebui
+// +build !nowebui
+
 package corehttp
 
 // TODO: move to IPNS
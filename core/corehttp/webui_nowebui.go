@@ -0,0 +1,23 @@
+//go:build nowebui
+// +build nowebui
+
+package corehttp
+
+import (
+	"net"
+	"net/http"
+
+	core "github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/subsystem"
+)
+
+// WebUIPaths is empty, the webui is compiled out.
+var WebUIPaths []string
+
+// WebUIOption answers /webui with 501, the webui is compiled out.
+var WebUIOption ServeOption = func(_ *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+	mux.HandleFunc("/webui/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, subsystem.Check(subsystem.WebUI).Error(), http.StatusNotImplemented)
+	})
+	return mux, nil
+}
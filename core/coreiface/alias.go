@@ -0,0 +1,35 @@
+package iface
+
+import (
+	"context"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/kubo/core/coreiface/options"
+)
+
+// Alias is a local name of a path, usable as /alias/<name>.
+type Alias struct {
+	Name string
+	Path path.Path
+}
+
+// AliasAPI specifies the interface to the aliases of the repo: human names
+// for the /ipfs and /ipns paths, so /alias/photos/a.jpg can be used wherever
+// a path is accepted by the commands.
+type AliasAPI interface {
+	// Set records name as an alias of p
+	Set(ctx context.Context, name string, p path.Path, opts ...options.AliasSetOption) error
+
+	// Get returns the path of the alias name
+	Get(ctx context.Context, name string) (path.Path, error)
+
+	// List returns the aliases, sorted by name
+	List(ctx context.Context) ([]Alias, error)
+
+	// Remove deletes the alias name
+	Remove(ctx context.Context, name string) error
+
+	// Expand returns the path p, with its /alias/<name> prefix replaced by
+	// the path of the alias. The other paths are returned as parsed.
+	Expand(ctx context.Context, p string) (path.Path, error)
+}
@@ -0,0 +1,40 @@
+package iface
+
+import (
+	"context"
+	"io"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/kubo/core/coreiface/options"
+)
+
+// CacheWarmFailure is a path of the log that couldn't be warmed
+type CacheWarmFailure struct {
+	Path  path.Path
+	Error string
+}
+
+// CacheWarmResult is the outcome of warming the cache from a log
+type CacheWarmResult struct {
+	// Paths is the number of distinct paths of the log
+	Paths int
+
+	// Warmed is the number of paths whose content is now in the blockstore
+	Warmed int
+
+	// Protected is the number of warmed paths protected from garbage
+	// collection for the pin TTL
+	Protected int
+
+	Failures []CacheWarmFailure
+}
+
+// CacheAPI specifies the interface to the content cached in the blockstore
+type CacheAPI interface {
+	// WarmFromLog prefetches the content of the paths and CIDs of a log, such
+	// as the access log of a gateway, the most requested first, so that the
+	// content requested daily is local before it is requested again. With a
+	// pin TTL, the warmed content is protected from garbage collection like
+	// the pins removed with a grace period, and listed by Pin().Removed.
+	WarmFromLog(ctx context.Context, r io.Reader, opts ...options.CacheWarmOption) (CacheWarmResult, error)
+}
@@ -0,0 +1,67 @@
+package iface
+
+import (
+	"context"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/core/coreiface/options"
+)
+
+// CarInspection describes a CAR file, without importing it
+type CarInspection struct {
+	// Version is the version of the CAR, 1 or 2, or 0 when the header
+	// cannot be read
+	Version uint64
+
+	// Roots are the roots of the CAR, MissingRoots those whose blocks are not
+	// in the CAR
+	Roots        []cid.Cid
+	MissingRoots []cid.Cid
+
+	// Blocks is the number of blocks, Bytes the size of their data
+	Blocks uint64
+	Bytes  uint64
+
+	// Codecs are the number of blocks by codec, most frequent first
+	Codecs []CarCodec
+
+	// HasIndex is true for CARv2 files with an index, which is valid when
+	// all the blocks can be found with it. IndexCodec is the format of the
+	// index, e.g. "car-multihash-index-sorted"
+	HasIndex   bool
+	IndexCodec string
+	IndexValid bool
+
+	// Errors diagnose the malformed parts of the CAR. The CAR is valid when
+	// there are none.
+	Errors []CarError
+}
+
+// CarCodec is the number of blocks of a codec in a CAR
+type CarCodec struct {
+	Codec  string
+	Blocks uint64
+}
+
+// CarError is a malformed part of a CAR
+type CarError struct {
+	// Offset is the offset of the malformed section in the CAR
+	Offset uint64
+	Error  string
+}
+
+// CarAPI specifies the interface to CAR files
+type CarAPI interface {
+	// Inspect reads a CAR and describes it, without importing its blocks:
+	// its version, roots, blocks and codecs, its index, and the malformed
+	// sections found. Errors are only returned when r cannot be read.
+	Inspect(ctx context.Context, r io.Reader) (CarInspection, error)
+
+	// Transcode reads a CAR from r and writes it to w as a CARv1, or as a
+	// CARv2 with a new index, optionally without its duplicated blocks or
+	// with only the blocks matched by a selector. Blocks are streamed: only
+	// their CIDs and offsets are kept in memory, the data of CARv2 files and
+	// of filtered CARs is spooled to a temporary file.
+	Transcode(ctx context.Context, r io.Reader, w io.Writer, opts ...options.CarTranscodeOption) error
+}
@@ -44,9 +44,39 @@ type CoreAPI interface {
 	// PubSub returns an implementation of PubSub API
 	PubSub() PubSubAPI
 
+	// P2P returns an implementation of P2P API
+	P2P() P2PAPI
+
 	// Routing returns an implementation of Routing API
 	Routing() RoutingAPI
 
+	// Search returns an implementation of Search API
+	Search() SearchAPI
+
+	// Car returns an implementation of Car API
+	Car() CarAPI
+
+	// Alias returns an implementation of Alias API
+	Alias() AliasAPI
+
+	// Cache returns an implementation of Cache API
+	Cache() CacheAPI
+
+	// Repo returns an implementation of Repo API
+	Repo() RepoAPI
+
+	// KV returns an implementation of KV API
+	KV() KVAPI
+
+	// Export returns an implementation of Export API
+	Export() ExportAPI
+
+	// Replicate returns an implementation of Replicate API
+	Replicate() ReplicateAPI
+
+	// Standby returns an implementation of Standby API
+	Standby() StandbyAPI
+
 	// ResolvePath resolves the path using UnixFS resolver, and returns the resolved
 	// immutable path, and the remainder of the path segments that cannot be resolved
 	// within UnixFS.
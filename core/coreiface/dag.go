@@ -1,7 +1,12 @@
 package iface
 
 import (
+	"context"
+
+	"github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 // APIDagService extends ipld.DAGService
@@ -10,4 +15,34 @@ type APIDagService interface {
 
 	// Pinning returns special NodeAdder which recursively pins added nodes
 	Pinning() ipld.NodeAdder
+
+	// FetchFrom fetches the complete DAG under root from a single peer and
+	// stores it locally. Only the given peer is dialed and content routing is
+	// never used; every block is verified against its CID.
+	FetchFrom(ctx context.Context, root cid.Cid, from peer.AddrInfo, opts ...options.DagFetchFromOption) (DagFetchStats, error)
+
+	// Prune returns a new DAG without the subtree at rel, a path relative to
+	// root such as "photos/2019". UnixFS directories, including HAMT sharded
+	// ones, and plain dag-pb nodes can be pruned. A recursive pin of root is
+	// moved to the new root.
+	Prune(ctx context.Context, root cid.Cid, rel string) (DagPruneResult, error)
+}
+
+// DagFetchStats describes what was transferred by FetchFrom.
+type DagFetchStats struct {
+	// Blocks is the number of blocks in the DAG
+	Blocks uint64
+	// Bytes is the total size of the blocks in the DAG
+	Bytes uint64
+}
+
+// DagPruneResult describes the DAG returned by Prune.
+type DagPruneResult struct {
+	// Root is the root of the pruned DAG
+	Root cid.Cid
+	// Blocks is the number of blocks that are no longer pinned, and can be
+	// garbage collected
+	Blocks uint64
+	// Bytes is the total size of these blocks
+	Bytes uint64
 }
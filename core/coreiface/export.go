@@ -0,0 +1,39 @@
+package iface
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// DatasetManifest describes the pins of a dataset exported with
+// ExportAPI.Dataset. It is the dag-json root block of the CAR of the dataset,
+// with the keys "label", "created" and "pins", each pin with the keys "name",
+// "root", a link, "recursive" and "provideClass".
+type DatasetManifest struct {
+	// Label is the name of the exported pins
+	Label   string
+	Created time.Time
+	Pins    []DatasetPin
+}
+
+// DatasetPin is a pin of an exported dataset
+type DatasetPin struct {
+	Name      string
+	Root      cid.Cid
+	Recursive bool
+
+	// ProvideClass is the provide class of the pin, empty if it has none
+	ProvideClass string
+}
+
+// ExportAPI specifies the interface to the export of pinned content
+type ExportAPI interface {
+	// Dataset writes to w a CARv2 with the pins named label and their
+	// blocks, rooted at a manifest describing them. The pins are listed
+	// once, and garbage collection waits for the export to complete, so the
+	// CAR is a consistent snapshot. It fails when no pin is named label.
+	Dataset(ctx context.Context, label string, w io.Writer) (DatasetManifest, error)
+}
@@ -20,6 +20,10 @@ type Key interface {
 
 	// ID returns key PeerID
 	ID() peer.ID
+
+	// DerivationPath returns the path the key was derived along from a seed
+	// phrase, or "" for keys that were not derived, see KeyAPI.Derive
+	DerivationPath() string
 }
 
 // KeyAPI specifies the interface to Keystore
@@ -28,6 +32,12 @@ type KeyAPI interface {
 	// name and returns a base58 encoded multihash of it's public key
 	Generate(ctx context.Context, name string, opts ...options.KeyGenerateOption) (Key, error)
 
+	// Derive derives an ed25519 key from a seed phrase, along the derivation
+	// path given with options.Key.Path or one derived from the name, and stores
+	// it in the keystore under the specified name. Deriving a key again with
+	// the same seed phrase and path returns the same key.
+	Derive(ctx context.Context, name string, phrase string, opts ...options.KeyDeriveOption) (Key, error)
+
 	// Rename renames oldName key to newName. Returns the key and whether another
 	// key was overwritten, or an error
 	Rename(ctx context.Context, oldName string, newName string, opts ...options.KeyRenameOption) (Key, bool, error)
@@ -0,0 +1,44 @@
+package iface
+
+import (
+	"context"
+
+	"github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// KVEntry is a write of a key of a key-value store.
+type KVEntry struct {
+	Store string
+	Key   string
+	Value []byte
+	// Deleted is set for the deletes of the key.
+	Deleted bool
+	// Writer is the peer ID of the key the write is signed with.
+	Writer peer.ID
+	// Clock is the Lamport clock of the write. Of two writes of a key, the
+	// one of the highest clock wins.
+	Clock uint64
+}
+
+// KVAPI specifies the interface to the key-value stores replicated over
+// pubsub by the nodes using them, see Experimental.KVStore. The stores
+// converge to the last write of each key, whatever the order the writes are
+// received in.
+type KVAPI interface {
+	// Put writes value to key in store
+	Put(ctx context.Context, store string, key string, value []byte, opts ...options.KVPutOption) (KVEntry, error)
+
+	// Get returns the value of key in store
+	Get(ctx context.Context, store string, key string) ([]byte, error)
+
+	// Delete deletes key from store
+	Delete(ctx context.Context, store string, key string, opts ...options.KVPutOption) error
+
+	// List returns the entries of store, sorted by key
+	List(ctx context.Context, store string) ([]KVEntry, error)
+
+	// Subscribe returns the writes of store, local or received from the other
+	// peers, until ctx is done
+	Subscribe(ctx context.Context, store string) (<-chan KVEntry, error)
+}
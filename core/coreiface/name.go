@@ -7,6 +7,7 @@ import (
 	"github.com/ipfs/boxo/ipns"
 	"github.com/ipfs/boxo/path"
 	"github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 var ErrResolveFailed = errors.New("could not resolve name")
@@ -14,6 +15,44 @@ var ErrResolveFailed = errors.New("could not resolve name")
 type IpnsResult struct {
 	path.Path
 	Err error
+
+	// Provenance are the steps of the resolution, when requested with
+	// options.Name.WithProvenance.
+	Provenance []NameProvenance
+}
+
+// NameProvenance describes how a step of the resolution of a name was
+// answered.
+type NameProvenance struct {
+	// Name is the name resolved at this step, such as /ipns/example.com or
+	// /ipns/k51...
+	Name string
+	// Value is the path the name resolved to.
+	Value string
+	// Source is where the answer came from: "cache", "dnslink", or the name
+	// of the router that returned the IPNS record: "routing" for the routers
+	// of Routing.Type, "pubsub" or "datastore".
+	Source string
+
+	// Sequence is the sequence number of the IPNS record used.
+	Sequence uint64 `json:",omitempty"`
+	// Records are the IPNS records returned by the routers.
+	Records []IpnsRecordProvenance `json:",omitempty"`
+	// Peers are the DHT peers that answered the queries for the IPNS record.
+	Peers []peer.ID `json:",omitempty"`
+
+	// TXT are the DNSLink TXT records of the domain.
+	TXT []string `json:",omitempty"`
+	// Resolver is the DNS resolver that returned them: the URL of a
+	// DNS-over-HTTPS resolver, or "system".
+	Resolver string `json:",omitempty"`
+}
+
+// IpnsRecordProvenance is an IPNS record returned by a router.
+type IpnsRecordProvenance struct {
+	Router   string
+	Sequence uint64
+	Value    string
 }
 
 // NameAPI specifies the interface to IPNS.
@@ -28,7 +67,8 @@ type NameAPI interface {
 	// Publish announces new IPNS name
 	Publish(ctx context.Context, path path.Path, opts ...options.NamePublishOption) (ipns.Name, error)
 
-	// Resolve attempts to resolve the newest version of the specified name
+	// Resolve attempts to resolve the newest version of the specified name.
+	// Use Search with options.Name.WithProvenance to learn how it was resolved.
 	Resolve(ctx context.Context, name string, opts ...options.NameResolveOption) (path.Path, error)
 
 	// Search is a version of Resolve which outputs paths as they are discovered,
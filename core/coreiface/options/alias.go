@@ -0,0 +1,34 @@
+package options
+
+type AliasSetSettings struct {
+	Force bool
+}
+
+type AliasSetOption func(*AliasSetSettings) error
+
+func AliasSetOptions(opts ...AliasSetOption) (*AliasSetSettings, error) {
+	options := &AliasSetSettings{
+		Force: false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type aliasOpts struct{}
+
+var Alias aliasOpts
+
+// Force is an option for [Alias.Set] which specifies whether an existing
+// alias of the same name is replaced. Default is false.
+func (aliasOpts) Force(force bool) AliasSetOption {
+	return func(settings *AliasSetSettings) error {
+		settings.Force = force
+		return nil
+	}
+}
@@ -0,0 +1,71 @@
+package options
+
+import (
+	"errors"
+	"time"
+)
+
+type CacheWarmSettings struct {
+	PinTTL      time.Duration
+	Concurrency int
+	Limit       int
+}
+
+type CacheWarmOption func(*CacheWarmSettings) error
+
+func CacheWarmOptions(opts ...CacheWarmOption) (*CacheWarmSettings, error) {
+	options := &CacheWarmSettings{
+		PinTTL:      0,
+		Concurrency: 8,
+		Limit:       0,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type cacheOpts struct{}
+
+var Cache cacheOpts
+
+// PinTTL is an option for [Cache.WarmFromLog] which protects the warmed
+// content from garbage collection for ttl, as if it was pinned until then.
+// Default is 0, which leaves it to the garbage collection.
+func (cacheOpts) PinTTL(ttl time.Duration) CacheWarmOption {
+	return func(settings *CacheWarmSettings) error {
+		if ttl < 0 {
+			return errors.New("negative pin TTL")
+		}
+		settings.PinTTL = ttl
+		return nil
+	}
+}
+
+// Concurrency is an option for [Cache.WarmFromLog] which specifies how many
+// paths are fetched at the same time. Default is 8.
+func (cacheOpts) Concurrency(n int) CacheWarmOption {
+	return func(settings *CacheWarmSettings) error {
+		if n < 1 {
+			return errors.New("concurrency must be at least 1")
+		}
+		settings.Concurrency = n
+		return nil
+	}
+}
+
+// Limit is an option for [Cache.WarmFromLog] which warms only the n most
+// requested paths of the log. Default is 0, which warms all of them.
+func (cacheOpts) Limit(n int) CacheWarmOption {
+	return func(settings *CacheWarmSettings) error {
+		if n < 0 {
+			return errors.New("negative limit")
+		}
+		settings.Limit = n
+		return nil
+	}
+}
@@ -0,0 +1,59 @@
+package options
+
+import (
+	"github.com/ipld/go-ipld-prime/datamodel"
+)
+
+type CarTranscodeSettings struct {
+	Version  uint64
+	Dedupe   bool
+	Selector datamodel.Node
+}
+
+type CarTranscodeOption func(*CarTranscodeSettings) error
+
+func CarTranscodeOptions(opts ...CarTranscodeOption) (*CarTranscodeSettings, error) {
+	options := &CarTranscodeSettings{
+		Version: 2,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type carOpts struct{}
+
+var Car carOpts
+
+// Version is an option for [Car.Transcode] which specifies the version of the
+// CAR written, 1 or 2. CARv2 files are written with an index. Default is 2.
+func (carOpts) Version(version uint64) CarTranscodeOption {
+	return func(settings *CarTranscodeSettings) error {
+		settings.Version = version
+		return nil
+	}
+}
+
+// Dedupe is an option for [Car.Transcode] which specifies whether the
+// duplicated blocks of the CAR are written only once. Default is false.
+func (carOpts) Dedupe(dedupe bool) CarTranscodeOption {
+	return func(settings *CarTranscodeSettings) error {
+		settings.Dedupe = dedupe
+		return nil
+	}
+}
+
+// Selector is an option for [Car.Transcode] which specifies an IPLD selector,
+// applied from the roots of the CAR, to write only the blocks it matches.
+// Default is nil, to write all the blocks.
+func (carOpts) Selector(selector datamodel.Node) CarTranscodeOption {
+	return func(settings *CarTranscodeSettings) error {
+		settings.Selector = selector
+		return nil
+	}
+}
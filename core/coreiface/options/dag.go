@@ -0,0 +1,53 @@
+package options
+
+import (
+	"fmt"
+	"time"
+)
+
+type DagFetchFromSettings struct {
+	Pin     bool
+	Timeout time.Duration
+}
+
+type DagFetchFromOption func(*DagFetchFromSettings) error
+
+func DagFetchFromOptions(opts ...DagFetchFromOption) (*DagFetchFromSettings, error) {
+	options := &DagFetchFromSettings{
+		Pin:     false,
+		Timeout: 0,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type dagOpts struct{}
+
+var Dag dagOpts
+
+// Pin is an option for Dag.FetchFrom which specifies whether to recursively
+// pin the fetched DAG.
+func (dagOpts) Pin(pin bool) DagFetchFromOption {
+	return func(settings *DagFetchFromSettings) error {
+		settings.Pin = pin
+		return nil
+	}
+}
+
+// Timeout is an option for Dag.FetchFrom which limits how long the whole
+// fetch may take. Zero means no limit.
+func (dagOpts) Timeout(timeout time.Duration) DagFetchFromOption {
+	return func(settings *DagFetchFromSettings) error {
+		if timeout < 0 {
+			return fmt.Errorf("timeout must not be negative")
+		}
+		settings.Timeout = timeout
+		return nil
+	}
+}
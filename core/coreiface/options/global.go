@@ -3,6 +3,7 @@ package options
 type ApiSettings struct {
 	Offline     bool
 	FetchBlocks bool
+	Context     string
 }
 
 type ApiOption func(*ApiSettings) error
@@ -45,3 +46,12 @@ func (apiOpts) FetchBlocks(fetch bool) ApiOption {
 		return nil
 	}
 }
+
+// Context selects the repo context of the daemon named name, see the Repos
+// config key. The empty name selects the repo of the daemon.
+func (apiOpts) Context(name string) ApiOption {
+	return func(settings *ApiSettings) error {
+		settings.Context = name
+		return nil
+	}
+}
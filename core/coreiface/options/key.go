@@ -12,12 +12,18 @@ type KeyGenerateSettings struct {
 	Size      int
 }
 
+type KeyDeriveSettings struct {
+	Path       string
+	Passphrase string
+}
+
 type KeyRenameSettings struct {
 	Force bool
 }
 
 type (
 	KeyGenerateOption func(*KeyGenerateSettings) error
+	KeyDeriveOption   func(*KeyDeriveSettings) error
 	KeyRenameOption   func(*KeyRenameSettings) error
 )
 
@@ -36,6 +42,18 @@ func KeyGenerateOptions(opts ...KeyGenerateOption) (*KeyGenerateSettings, error)
 	return options, nil
 }
 
+func KeyDeriveOptions(opts ...KeyDeriveOption) (*KeyDeriveSettings, error) {
+	options := &KeyDeriveSettings{}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
 func KeyRenameOptions(opts ...KeyRenameOption) (*KeyRenameSettings, error) {
 	options := &KeyRenameSettings{
 		Force: false,
@@ -79,6 +97,24 @@ func (keyOpts) Size(size int) KeyGenerateOption {
 	}
 }
 
+// Path is an option for Key.Derive which specifies the derivation path of the
+// key, such as "m/0'/1'". Default is a path derived from the name of the key
+func (keyOpts) Path(path string) KeyDeriveOption {
+	return func(settings *KeyDeriveSettings) error {
+		settings.Path = path
+		return nil
+	}
+}
+
+// Passphrase is an option for Key.Derive which specifies the passphrase
+// protecting the seed phrase. Default is ""
+func (keyOpts) Passphrase(passphrase string) KeyDeriveOption {
+	return func(settings *KeyDeriveSettings) error {
+		settings.Passphrase = passphrase
+		return nil
+	}
+}
+
 // Force is an option for Key.Rename which specifies whether to allow to
 // replace existing keys.
 func (keyOpts) Force(force bool) KeyRenameOption {
@@ -0,0 +1,34 @@
+package options
+
+type KVPutSettings struct {
+	Key string
+}
+
+type KVPutOption func(*KVPutSettings) error
+
+func KVPutOptions(opts ...KVPutOption) (*KVPutSettings, error) {
+	options := &KVPutSettings{
+		Key: "self",
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type kvOpts struct{}
+
+var KV kvOpts
+
+// Key is an option for [KV.Put] and [KV.Delete] which specifies the name of
+// the key the write is signed with. Default is "self", the key of the node.
+func (kvOpts) Key(key string) KVPutOption {
+	return func(settings *KVPutSettings) error {
+		settings.Key = key
+		return nil
+	}
+}
@@ -19,7 +19,8 @@ type NamePublishSettings struct {
 }
 
 type NameResolveSettings struct {
-	Cache bool
+	Cache      bool
+	Provenance bool
 
 	ResolveOpts []namesys.ResolveOption
 }
@@ -123,6 +124,15 @@ func (nameOpts) Cache(cache bool) NameResolveOption {
 	}
 }
 
+// WithProvenance is an option for Name.Search which specifies whether the
+// results carry how the name was resolved. Default value is false
+func (nameOpts) WithProvenance(provenance bool) NameResolveOption {
+	return func(settings *NameResolveSettings) error {
+		settings.Provenance = provenance
+		return nil
+	}
+}
+
 func (nameOpts) ResolveOption(opt namesys.ResolveOption) NameResolveOption {
 	return func(settings *NameResolveSettings) error {
 		settings.ResolveOpts = append(settings.ResolveOpts, opt)
@@ -0,0 +1,175 @@
+package options
+
+import (
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// DefaultP2PMessageSize is the default size limit of the messages and of
+// their replies.
+const DefaultP2PMessageSize = 1 << 20
+
+type P2PSendSettings struct {
+	MaxReplySize int
+}
+
+type P2PHandleSettings struct {
+	MaxMessageSize int
+}
+
+type P2PListenSettings struct {
+	AllowCustomProtocol bool
+	ReportPeerID        bool
+	AllowedPeers        []peer.ID
+}
+
+type P2PCloseSettings struct {
+	All           bool
+	Protocol      string
+	ListenAddress ma.Multiaddr
+	TargetAddress ma.Multiaddr
+}
+
+type (
+	P2PSendOption   func(*P2PSendSettings) error
+	P2PHandleOption func(*P2PHandleSettings) error
+	P2PListenOption func(*P2PListenSettings) error
+	P2PCloseOption  func(*P2PCloseSettings) error
+)
+
+func P2PSendOptions(opts ...P2PSendOption) (*P2PSendSettings, error) {
+	options := &P2PSendSettings{
+		MaxReplySize: DefaultP2PMessageSize,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+func P2PHandleOptions(opts ...P2PHandleOption) (*P2PHandleSettings, error) {
+	options := &P2PHandleSettings{
+		MaxMessageSize: DefaultP2PMessageSize,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+func P2PListenOptions(opts ...P2PListenOption) (*P2PListenSettings, error) {
+	options := &P2PListenSettings{
+		AllowCustomProtocol: false,
+		ReportPeerID:        false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+func P2PCloseOptions(opts ...P2PCloseOption) (*P2PCloseSettings, error) {
+	options := &P2PCloseSettings{
+		All: false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type p2pOpts struct{}
+
+var P2P p2pOpts
+
+// MaxReplySize limits the size of the reply accepted by Send.
+func (p2pOpts) MaxReplySize(size int) P2PSendOption {
+	return func(settings *P2PSendSettings) error {
+		settings.MaxReplySize = size
+		return nil
+	}
+}
+
+// MaxMessageSize limits the size of the messages accepted by Handle.
+func (p2pOpts) MaxMessageSize(size int) P2PHandleOption {
+	return func(settings *P2PHandleSettings) error {
+		settings.MaxMessageSize = size
+		return nil
+	}
+}
+
+// AllowCustomProtocol allows the protocols of Forward and Listen outside of
+// the '/x/' namespace.
+func (p2pOpts) AllowCustomProtocol(allow bool) P2PListenOption {
+	return func(settings *P2PListenSettings) error {
+		settings.AllowCustomProtocol = allow
+		return nil
+	}
+}
+
+// ReportPeerID makes Listen send the base58 peer ID of the remote peer,
+// followed by a newline, to the target when a stream is opened.
+func (p2pOpts) ReportPeerID(report bool) P2PListenOption {
+	return func(settings *P2PListenSettings) error {
+		settings.ReportPeerID = report
+		return nil
+	}
+}
+
+// AllowPeers only accepts the streams of the given peers in Listen. All the
+// peers are accepted by default.
+func (p2pOpts) AllowPeers(peers ...peer.ID) P2PListenOption {
+	return func(settings *P2PListenSettings) error {
+		settings.AllowedPeers = append(settings.AllowedPeers, peers...)
+		return nil
+	}
+}
+
+// All closes all the forwards and listeners. It can't be combined with the
+// other options of Close.
+func (p2pOpts) All(all bool) P2PCloseOption {
+	return func(settings *P2PCloseSettings) error {
+		settings.All = all
+		return nil
+	}
+}
+
+// Protocol closes the forwards and listeners of the protocol.
+func (p2pOpts) Protocol(proto string) P2PCloseOption {
+	return func(settings *P2PCloseSettings) error {
+		settings.Protocol = proto
+		return nil
+	}
+}
+
+// ListenAddress closes the forwards and listeners with this listen address.
+func (p2pOpts) ListenAddress(addr ma.Multiaddr) P2PCloseOption {
+	return func(settings *P2PCloseSettings) error {
+		settings.ListenAddress = addr
+		return nil
+	}
+}
+
+// TargetAddress closes the forwards and listeners with this target address.
+func (p2pOpts) TargetAddress(addr ma.Multiaddr) P2PCloseOption {
+	return func(settings *P2PCloseSettings) error {
+		settings.TargetAddress = addr
+		return nil
+	}
+}
@@ -1,11 +1,16 @@
 package options
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // PinAddSettings represent the settings for PinAPI.Add
 type PinAddSettings struct {
-	Recursive bool
-	Name      string
+	Recursive    bool
+	Name         string
+	Priority     int
+	ProvideClass string
 }
 
 // PinLsSettings represent the settings for PinAPI.Ls
@@ -22,6 +27,7 @@ type PinIsPinnedSettings struct {
 // PinRmSettings represents the settings for PinAPI.Rm
 type PinRmSettings struct {
 	Recursive bool
+	Grace     time.Duration
 }
 
 // PinUpdateSettings represent the settings for PinAPI.Update
@@ -282,6 +288,25 @@ func (pinOpts) Name(name string) PinAddOption {
 	}
 }
 
+// Priority is an option for Pin.Enqueue which orders the queued pin among the
+// others, higher first. Default: 0
+func (pinOpts) Priority(priority int) PinAddOption {
+	return func(settings *PinAddSettings) error {
+		settings.Priority = priority
+		return nil
+	}
+}
+
+// ProvideClass is an option for Pin.Add which sets the provide class of the
+// pin, the cadence its DAG is reprovided at: "hot", "warm", "cold" or
+// "never". Default: none, the DAG is reprovided every Reprovider.Interval.
+func (pinOpts) ProvideClass(class string) PinAddOption {
+	return func(settings *PinAddSettings) error {
+		settings.ProvideClass = class
+		return nil
+	}
+}
+
 // RmRecursive is an option for Pin.Rm which specifies whether to recursively
 // unpin the object linked to by the specified object(s). This does not remove
 // indirect pins referenced by other recursive pins.
@@ -292,6 +317,19 @@ func (pinOpts) RmRecursive(recursive bool) PinRmOption {
 	}
 }
 
+// Grace is an option for Pin.Rm which keeps the blocks of the removed pin
+// from being garbage collected for the given duration, during which the pin
+// can be restored with Pin.Restore. Default: 0, the pin is removed right away.
+func (pinOpts) Grace(grace time.Duration) PinRmOption {
+	return func(settings *PinRmSettings) error {
+		if grace < 0 {
+			return fmt.Errorf("invalid grace period %s", grace)
+		}
+		settings.Grace = grace
+		return nil
+	}
+}
+
 // Unpin is an option for Pin.Update which specifies whether to remove the old pin.
 // Default is true.
 func (pinOpts) Unpin(unpin bool) PinUpdateOption {
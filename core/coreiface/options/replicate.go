@@ -0,0 +1,52 @@
+package options
+
+import (
+	"fmt"
+)
+
+type ReplicatePushSettings struct {
+	BytesPerSecond uint64
+	Retries        int
+}
+
+type ReplicatePushOption func(*ReplicatePushSettings) error
+
+func ReplicatePushOptions(opts ...ReplicatePushOption) (*ReplicatePushSettings, error) {
+	options := &ReplicatePushSettings{
+		Retries: 3,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type replicateOpts struct{}
+
+var Replicate replicateOpts
+
+// Bandwidth is an option for [Replicate.Push] which caps the bandwidth used
+// to send the blocks, in bytes per second. Default is 0, for no cap.
+func (replicateOpts) Bandwidth(bytesPerSecond uint64) ReplicatePushOption {
+	return func(settings *ReplicatePushSettings) error {
+		settings.BytesPerSecond = bytesPerSecond
+		return nil
+	}
+}
+
+// Retries is an option for [Replicate.Push] which specifies the number of
+// times the push of a pin interrupted by a network error is resumed before
+// giving up. Default is 3.
+func (replicateOpts) Retries(retries int) ReplicatePushOption {
+	return func(settings *ReplicatePushSettings) error {
+		if retries < 0 {
+			return fmt.Errorf("invalid number of retries %d", retries)
+		}
+		settings.Retries = retries
+		return nil
+	}
+}
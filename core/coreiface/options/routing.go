@@ -1,5 +1,7 @@
 package options
 
+import "time"
+
 type RoutingPutSettings struct {
 	AllowOffline bool
 }
@@ -33,9 +35,24 @@ type RoutingFindProvidersSettings struct {
 	NumProviders int
 }
 
+type RoutingCheckAvailabilitySettings struct {
+	NumProviders int
+	Fetch        bool
+	Concurrency  int
+	Timeout      time.Duration
+}
+
+type RoutingEstimateRetrievalSettings struct {
+	NumProviders int
+	SampleBlocks int
+	Timeout      time.Duration
+}
+
 type (
-	RoutingProvideOption       func(*DhtProvideSettings) error
-	RoutingFindProvidersOption func(*DhtFindProvidersSettings) error
+	RoutingProvideOption           func(*DhtProvideSettings) error
+	RoutingFindProvidersOption     func(*DhtFindProvidersSettings) error
+	RoutingCheckAvailabilityOption func(*RoutingCheckAvailabilitySettings) error
+	RoutingEstimateRetrievalOption func(*RoutingEstimateRetrievalSettings) error
 )
 
 func RoutingProvideOptions(opts ...RoutingProvideOption) (*RoutingProvideSettings, error) {
@@ -66,6 +83,39 @@ func RoutingFindProvidersOptions(opts ...RoutingFindProvidersOption) (*RoutingFi
 	return options, nil
 }
 
+func RoutingCheckAvailabilityOptions(opts ...RoutingCheckAvailabilityOption) (*RoutingCheckAvailabilitySettings, error) {
+	options := &RoutingCheckAvailabilitySettings{
+		NumProviders: 20,
+		Fetch:        false,
+		Concurrency:  8,
+		Timeout:      30 * time.Second,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+func RoutingEstimateRetrievalOptions(opts ...RoutingEstimateRetrievalOption) (*RoutingEstimateRetrievalSettings, error) {
+	options := &RoutingEstimateRetrievalSettings{
+		NumProviders: 5,
+		SampleBlocks: 8,
+		Timeout:      30 * time.Second,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
 type routingOpts struct{}
 
 var Routing routingOpts
@@ -96,3 +146,70 @@ func (routingOpts) AllowOffline(allow bool) RoutingPutOption {
 		return nil
 	}
 }
+
+// CountProviders is an option for [Routing.CheckAvailability] which specifies
+// the number of providers to look for each CID. Default is 20.
+func (routingOpts) CountProviders(numProviders int) RoutingCheckAvailabilityOption {
+	return func(settings *RoutingCheckAvailabilitySettings) error {
+		settings.NumProviders = numProviders
+		return nil
+	}
+}
+
+// Fetch is an option for [Routing.CheckAvailability] which specifies whether
+// to fetch the block of each CID from the network once providers are found.
+// Default value is false
+func (routingOpts) Fetch(fetch bool) RoutingCheckAvailabilityOption {
+	return func(settings *RoutingCheckAvailabilitySettings) error {
+		settings.Fetch = fetch
+		return nil
+	}
+}
+
+// Concurrency is an option for [Routing.CheckAvailability] which specifies
+// the number of CIDs checked in parallel. Default is 8.
+func (routingOpts) Concurrency(n int) RoutingCheckAvailabilityOption {
+	return func(settings *RoutingCheckAvailabilitySettings) error {
+		settings.Concurrency = n
+		return nil
+	}
+}
+
+// Timeout is an option for [Routing.CheckAvailability] which specifies how
+// long the search for the providers of a CID, and the fetch of its block, may
+// each take. Default is 30 seconds.
+func (routingOpts) Timeout(timeout time.Duration) RoutingCheckAvailabilityOption {
+	return func(settings *RoutingCheckAvailabilitySettings) error {
+		settings.Timeout = timeout
+		return nil
+	}
+}
+
+// ProbeProviders is an option for [Routing.EstimateRetrieval] which specifies
+// the number of providers to look for and probe. Default is 5.
+func (routingOpts) ProbeProviders(n int) RoutingEstimateRetrievalOption {
+	return func(settings *RoutingEstimateRetrievalSettings) error {
+		settings.NumProviders = n
+		return nil
+	}
+}
+
+// SampleBlocks is an option for [Routing.EstimateRetrieval] which specifies
+// the number of children of the root fetched to sample the bandwidth of the
+// providers. Default is 8.
+func (routingOpts) SampleBlocks(n int) RoutingEstimateRetrievalOption {
+	return func(settings *RoutingEstimateRetrievalSettings) error {
+		settings.SampleBlocks = n
+		return nil
+	}
+}
+
+// ProbeTimeout is an option for [Routing.EstimateRetrieval] which specifies
+// how long the search for the providers, their pings, and the fetch of the
+// sample may each take. Default is 30 seconds.
+func (routingOpts) ProbeTimeout(timeout time.Duration) RoutingEstimateRetrievalOption {
+	return func(settings *RoutingEstimateRetrievalSettings) error {
+		settings.Timeout = timeout
+		return nil
+	}
+}
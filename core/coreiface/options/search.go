@@ -0,0 +1,34 @@
+package options
+
+type SearchQuerySettings struct {
+	Limit int
+}
+
+type SearchQueryOption func(*SearchQuerySettings) error
+
+func SearchQueryOptions(opts ...SearchQueryOption) (*SearchQuerySettings, error) {
+	options := &SearchQuerySettings{
+		Limit: 20,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type searchOpts struct{}
+
+var Search searchOpts
+
+// Limit is an option for [Search.Query] which specifies the maximum number of
+// documents returned. Default is 20, and 0 returns all the matches.
+func (searchOpts) Limit(limit int) SearchQueryOption {
+	return func(settings *SearchQuerySettings) error {
+		settings.Limit = limit
+		return nil
+	}
+}
@@ -0,0 +1,33 @@
+package options
+
+type StandbyPromoteSettings struct {
+	Force bool
+}
+
+type StandbyPromoteOption func(*StandbyPromoteSettings) error
+
+func StandbyPromoteOptions(opts ...StandbyPromoteOption) (*StandbyPromoteSettings, error) {
+	options := &StandbyPromoteSettings{}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type standbyOpts struct{}
+
+var Standby standbyOpts
+
+// Force is an option for [Standby.Promote] which promotes the node even when
+// the primary can't be mirrored one last time, with the state mirrored last.
+// Default is false.
+func (standbyOpts) Force(force bool) StandbyPromoteOption {
+	return func(settings *StandbyPromoteSettings) error {
+		settings.Force = force
+		return nil
+	}
+}
@@ -1,10 +1,15 @@
 package options
 
 import (
+	"compress/gzip"
 	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/path"
 	cid "github.com/ipfs/go-cid"
 	mh "github.com/multiformats/go-multihash"
 )
@@ -16,6 +21,21 @@ const (
 	TrickleLayout
 )
 
+// SymlinkPolicy is how the adder handles the symlinks, see
+// Unixfs.DereferenceSymlinks.
+type SymlinkPolicy int
+
+const (
+	// DereferenceNever stores the symlinks as symlink nodes.
+	DereferenceNever SymlinkPolicy = iota
+	// DereferenceAlways adds the files and directories the symlinks point
+	// to instead of the symlinks, in the added directories too.
+	DereferenceAlways
+	// DereferenceTopLevel only dereferences the added node, when it is a
+	// symlink, like the paths given to 'ipfs add --dereference-args'.
+	DereferenceTopLevel
+)
+
 type UnixfsAddSettings struct {
 	CidVersion int
 	MhType     uint64
@@ -28,24 +48,177 @@ type UnixfsAddSettings struct {
 	Chunker string
 	Layout  Layout
 
-	Pin      bool
-	OnlyHash bool
-	FsCache  bool
-	NoCopy   bool
+	MaxFileLinks      int
+	MaxDirectoryLinks int
+	HAMTFanout        int
+
+	AddConcurrency int
+	PreserveOrder  bool
+
+	IgnoreRules []string
+	IgnoreFile  string
+
+	IncludeHidden    bool
+	IncludeHiddenSet bool
+	Dereference      SymlinkPolicy
+
+	Pin         bool
+	OnlyHash    bool
+	FsCache     bool
+	NoCopy      bool
+	Incremental bool
+	Name        string
+	Wrap        bool
+	Car         bool
+
+	MemoryBudget uint64
+	Stats        *AddStats
+	DryRun       *DryRunStats
+
+	PreserveMode  bool
+	PreserveMtime bool
+	Mode          os.FileMode
+	Mtime         time.Time
 
-	Events   chan<- interface{}
-	Silent   bool
-	Progress bool
+	Events         chan<- interface{}
+	ProgressEvents chan<- AddProgressEvent
+	Silent         bool
+	Progress       bool
+}
+
+// AddProgressKind is the kind of an AddProgressEvent.
+type AddProgressKind int
+
+const (
+	// FileStarted is sent when the adder starts reading a file.
+	FileStarted AddProgressKind = iota
+	// ChunkWritten is sent as the data of a file is chunked and written,
+	// with the bytes of the file read so far.
+	ChunkWritten
+	// FileCompleted is sent once a file, a symlink or a directory is added,
+	// with its path and the size of its DAG.
+	FileCompleted
+	// RootEmitted is sent last, once the add is done, with the path and the
+	// size of the DAG of its root.
+	RootEmitted
+)
+
+func (k AddProgressKind) String() string {
+	switch k {
+	case FileStarted:
+		return "FileStarted"
+	case ChunkWritten:
+		return "ChunkWritten"
+	case FileCompleted:
+		return "FileCompleted"
+	case RootEmitted:
+		return "RootEmitted"
+	default:
+		return fmt.Sprintf("AddProgressKind(%d)", int(k))
+	}
+}
+
+// AddProgressEvent is an event of an add, see Unixfs.ProgressEvents.
+type AddProgressEvent struct {
+	Kind AddProgressKind
+	// Name is the path of the entry in the added directory. It is empty for
+	// RootEmitted.
+	Name string
+	// Path is the path of the node added, for FileCompleted and RootEmitted.
+	Path path.ImmutablePath
+	// Bytes is the size of the data of the file read so far, for
+	// ChunkWritten.
+	Bytes int64
+	// Size is the cumulative size of the DAG of the node added, for
+	// FileCompleted and RootEmitted.
+	Size uint64
+	// Time is when the event happened.
+	Time time.Time
+}
+
+// AddStats describes the resources used by an add, see Unixfs.Stats.
+type AddStats struct {
+	// PeakMemory is the peak size of the blocks buffered in memory before
+	// being written. It is only measured with a memory budget.
+	PeakMemory uint64
+	// SpilledBlocks and SpilledBytes count the blocks spilled to disk to stay
+	// within the memory budget.
+	SpilledBlocks uint64
+	SpilledBytes  uint64
+}
+
+// DryRunStats describes the DAG an add would write, see Unixfs.DryRun.
+type DryRunStats struct {
+	// Blocks and Bytes are the distinct blocks of the DAG and their size
+	Blocks uint64
+	Bytes  uint64
+	// DataBytes is the size of the data of the files in the blocks, and
+	// Overhead the size of the rest of the blocks: the protobuf framing of
+	// the UnixFS nodes, their links and the directories
+	DataBytes uint64
+	Overhead  uint64
+	// LocalBlocks and LocalBytes are the blocks of the DAG already stored
+	// locally, which the add wouldn't write again
+	LocalBlocks uint64
+	LocalBytes  uint64
 }
 
 type UnixfsLsSettings struct {
 	ResolveChildren   bool
 	UseCumulativeSize bool
+	ResolveMimeType   bool
+	PreservedOrder    bool
+
+	Offset int
+	Limit  int
+	Cursor string
+}
+
+type UnixfsSearchSettings struct {
+	Limit int
+}
+
+type UnixfsGetSettings struct {
+	Progressive bool
+	OnRange     func(offset, length uint64)
+
+	// Offset and Length are the byte range of the file read, Length is -1
+	// for the rest of the file.
+	Offset int64
+	Length int64
+
+	// Archive returns the tree as a tar stream, gzip compressed at the level
+	// Compression unless it is gzip.NoCompression.
+	Archive     bool
+	Compression int
+}
+
+type UnixfsMvSettings struct {
+	Parents   bool
+	Overwrite bool
+}
+
+// UnixfsMetaSettings are the settings of Chmod and Touch.
+type UnixfsMetaSettings struct {
+	Recursive bool
+	// ModTime is the modification time set by Touch, the current time when
+	// zero.
+	ModTime time.Time
+}
+
+// UnixfsSymlinkSettings are the settings of Symlink.
+type UnixfsSymlinkSettings struct {
+	Parents bool
 }
 
 type (
-	UnixfsAddOption func(*UnixfsAddSettings) error
-	UnixfsLsOption  func(*UnixfsLsSettings) error
+	UnixfsAddOption     func(*UnixfsAddSettings) error
+	UnixfsLsOption      func(*UnixfsLsSettings) error
+	UnixfsSearchOption  func(*UnixfsSearchSettings) error
+	UnixfsGetOption     func(*UnixfsGetSettings) error
+	UnixfsMvOption      func(*UnixfsMvSettings) error
+	UnixfsMetaOption    func(*UnixfsMetaSettings) error
+	UnixfsSymlinkOption func(*UnixfsSymlinkSettings) error
 )
 
 func UnixfsAddOptions(opts ...UnixfsAddOption) (*UnixfsAddSettings, cid.Prefix, error) {
@@ -61,14 +234,40 @@ func UnixfsAddOptions(opts ...UnixfsAddOption) (*UnixfsAddSettings, cid.Prefix,
 		Chunker: "size-262144",
 		Layout:  BalancedLayout,
 
-		Pin:      false,
-		OnlyHash: false,
-		FsCache:  false,
-		NoCopy:   false,
+		MaxFileLinks:      0,
+		MaxDirectoryLinks: 0,
+		HAMTFanout:        0,
+
+		AddConcurrency: 0,
+		PreserveOrder:  false,
+
+		IgnoreRules: nil,
+		IgnoreFile:  "",
+
+		IncludeHidden:    false,
+		IncludeHiddenSet: false,
+		Dereference:      DereferenceNever,
 
-		Events:   nil,
-		Silent:   false,
-		Progress: false,
+		Pin:         false,
+		OnlyHash:    false,
+		FsCache:     false,
+		NoCopy:      false,
+		Incremental: false,
+		Name:        "",
+		Car:         false,
+
+		MemoryBudget: 0,
+		Stats:        nil,
+
+		PreserveMode:  false,
+		PreserveMtime: false,
+		Mode:          0,
+		Mtime:         time.Time{},
+
+		Events:         nil,
+		ProgressEvents: nil,
+		Silent:         false,
+		Progress:       false,
 	}
 
 	for _, opt := range opts {
@@ -89,6 +288,46 @@ func UnixfsAddOptions(opts ...UnixfsAddOption) (*UnixfsAddSettings, cid.Prefix,
 		options.RawLeaves = true
 	}
 
+	if options.MaxFileLinks != 0 && options.MaxFileLinks < 2 {
+		return nil, cid.Prefix{}, fmt.Errorf("max file links must be at least 2, got %d", options.MaxFileLinks)
+	}
+	if options.MaxDirectoryLinks < 0 {
+		return nil, cid.Prefix{}, fmt.Errorf("max directory links can't be negative, got %d", options.MaxDirectoryLinks)
+	}
+	if f := options.HAMTFanout; f != 0 && (f < 8 || f > 1024 || f&(f-1) != 0) {
+		return nil, cid.Prefix{}, fmt.Errorf("HAMT fanout must be a power of two between 8 and 1024, got %d", f)
+	}
+
+	switch options.Dereference {
+	case DereferenceNever, DereferenceAlways, DereferenceTopLevel:
+	default:
+		return nil, cid.Prefix{}, fmt.Errorf("unknown symlink policy: %d", options.Dereference)
+	}
+
+	if options.AddConcurrency < 0 {
+		return nil, cid.Prefix{}, fmt.Errorf("add concurrency can't be negative, got %d", options.AddConcurrency)
+	}
+
+	// a dry run only hashes
+	if options.DryRun != nil {
+		options.OnlyHash = true
+	}
+
+	if options.Incremental && options.OnlyHash {
+		return nil, cid.Prefix{}, errors.New("incremental add requires blocks to be stored, it can't be used with only-hash")
+	}
+
+	if options.Car && (options.OnlyHash || options.NoCopy || options.Incremental) {
+		return nil, cid.Prefix{}, errors.New("adding a CAR can't be combined with only-hash, nocopy or incremental")
+	}
+
+	if options.Wrap && (options.Name == "" || strings.Contains(options.Name, "/")) {
+		return nil, cid.Prefix{}, fmt.Errorf("wrapping in a directory requires the name of the entry, without slashes, got %q", options.Name)
+	}
+	if options.Wrap && options.Car {
+		return nil, cid.Prefix{}, errors.New("adding a CAR can't be combined with wrapping in a directory")
+	}
+
 	// (hash != "sha2-256") -> CIDv1
 	if options.MhType != mh.SHA2_256 {
 		switch options.CidVersion {
@@ -137,6 +376,90 @@ func UnixfsLsOptions(opts ...UnixfsLsOption) (*UnixfsLsSettings, error) {
 	return options, nil
 }
 
+func UnixfsGetOptions(opts ...UnixfsGetOption) (*UnixfsGetSettings, error) {
+	options := &UnixfsGetSettings{
+		Progressive: false,
+		Offset:      0,
+		Length:      -1,
+		Archive:     false,
+		Compression: gzip.NoCompression,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Archive && (options.Offset != 0 || options.Length >= 0) {
+		return nil, errors.New("a range of an archive cannot be read")
+	}
+
+	return options, nil
+}
+
+func UnixfsSearchOptions(opts ...UnixfsSearchOption) (*UnixfsSearchSettings, error) {
+	options := &UnixfsSearchSettings{
+		Limit: 0,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
+func UnixfsMvOptions(opts ...UnixfsMvOption) (*UnixfsMvSettings, error) {
+	options := &UnixfsMvSettings{
+		Parents:   false,
+		Overwrite: false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
+func UnixfsMetaOptions(opts ...UnixfsMetaOption) (*UnixfsMetaSettings, error) {
+	options := &UnixfsMetaSettings{
+		Recursive: false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
+func UnixfsSymlinkOptions(opts ...UnixfsSymlinkOption) (*UnixfsSymlinkSettings, error) {
+	options := &UnixfsSymlinkSettings{
+		Parents: false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
 type unixfsOpts struct{}
 
 var Unixfs unixfsOpts
@@ -198,6 +521,11 @@ func (unixfsOpts) InlineLimit(limit int) UnixfsAddOption {
 // Default: size-262144, formats:
 // size-[bytes] - Simple chunker splitting data into blocks of n bytes
 // rabin-[min]-[avg]-[max] - Rabin chunker
+// buzhash - Buzhash chunker, content defined like rabin but much faster
+// fastcdc-[min]-[avg]-[max] - FastCDC chunker, the fastest content defined
+// chunker, 'fastcdc' alone uses the sizes 65536-262144-1048576. Its CIDs are
+// specific to kubo: it is only accepted by the nodes enabling
+// Experimental.FastCDCChunker
 func (unixfsOpts) Chunker(chunker string) UnixfsAddOption {
 	return func(settings *UnixfsAddSettings) error {
 		settings.Chunker = chunker
@@ -216,6 +544,40 @@ func (unixfsOpts) Layout(layout Layout) UnixfsAddOption {
 	}
 }
 
+// MaxFileLinks sets the maximum number of links of the nodes of the file
+// DAGs, at least 2. Lower values make deeper DAGs of smaller nodes.
+// Default: 174, the number of links fitting in nodes of about 8KiB
+func (unixfsOpts) MaxFileLinks(n int) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.MaxFileLinks = n
+		return nil
+	}
+}
+
+// MaxDirectoryLinks sets the maximum number of links of the directories: the
+// directories of more entries are sharded into HAMTs. By default, the
+// directories are sharded when their links exceed
+// Internal.UnixFSShardingSizeThreshold, 256KiB unless configured.
+//
+// The mode and the modification time of the sharded directories are not
+// stored.
+func (unixfsOpts) MaxDirectoryLinks(n int) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.MaxDirectoryLinks = n
+		return nil
+	}
+}
+
+// HAMTFanout sets the number of links of the nodes of the sharded
+// directories, a power of two between 8 and 1024.
+// Default: 256
+func (unixfsOpts) HAMTFanout(n int) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.HAMTFanout = n
+		return nil
+	}
+}
+
 // Pin tells the adder to pin the file root recursively after adding
 func (unixfsOpts) Pin(pin bool) UnixfsAddOption {
 	return func(settings *UnixfsAddSettings) error {
@@ -244,6 +606,21 @@ func (unixfsOpts) Events(sink chan<- interface{}) UnixfsAddOption {
 	}
 }
 
+// ProgressEvents specifies a channel which receives the typed events of the
+// add: FileStarted, ChunkWritten, FileCompleted, and RootEmitted last. They
+// are sent in addition to the ones of Events, whatever Progress. The events of
+// the files of a directory added concurrently, see AddConcurrency, are
+// interleaved. The channel is not closed by the add. Over the RPC, the
+// FileCompleted events are not sent with Silent.
+//
+// Note that if this channel blocks it may slowdown the adder
+func (unixfsOpts) ProgressEvents(sink chan<- AddProgressEvent) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.ProgressEvents = sink
+		return nil
+	}
+}
+
 // Silent reduces event output
 func (unixfsOpts) Silent(silent bool) UnixfsAddOption {
 	return func(settings *UnixfsAddSettings) error {
@@ -280,6 +657,203 @@ func (unixfsOpts) Nocopy(enable bool) UnixfsAddOption {
 	}
 }
 
+// Incremental tells the adder to consult (and update) a local index of
+// previously added files, keyed by absolute path, size and modification time.
+// Files that haven't changed since they were last added with the same
+// parameters are not read again, and only directories containing changes are
+// reported in events.
+//
+// Only applies to files that come from the local filesystem.
+func (unixfsOpts) Incremental(enable bool) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.Incremental = enable
+		return nil
+	}
+}
+
+// Name sets the name the added file or directory is recorded under in the
+// content index. It defaults to the resulting CID.
+func (unixfsOpts) Name(name string) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.Name = name
+		return nil
+	}
+}
+
+// Wrap wraps the added file or directory in a directory, as the entry named
+// Name, which is required. The events of the add report the wrapped node under
+// Name, and the wrapping directory under the empty name. The returned path is
+// the one of the wrapping directory. Default is false.
+func (unixfsOpts) Wrap(enable bool) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.Wrap = enable
+		return nil
+	}
+}
+
+// Car tells the adder that the file added is a CAR holding a UnixFS DAG under
+// its single root. The DAG is verified and its blocks are stored as is,
+// instead of the file being chunked, so the options shaping the DAG, such as
+// the chunker or the CID version, don't apply.
+func (unixfsOpts) Car(enable bool) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.Car = enable
+		return nil
+	}
+}
+
+// AddConcurrency sets the number of files of a directory chunked and hashed
+// at once. The links of the directories, and the events of the add, are still
+// in the order of the entries, so the resulting DAG doesn't depend on it.
+// Only the files read from the local filesystem by the adding process are
+// added concurrently, not the files sent over the HTTP API. 0 and 1, the
+// default, add the files one after the other.
+func (unixfsOpts) AddConcurrency(n int) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.AddConcurrency = n
+		return nil
+	}
+}
+
+// PreserveOrder stores the order of the entries of the added directories,
+// the order of the files.Directory iterators, in the metadata of the
+// directories. The links of the directories, and so the CIDs, are still sorted
+// by name, as dag-pb requires: Ls lists the entries in the stored order with
+// the PreservedOrder option.
+func (unixfsOpts) PreserveOrder(enable bool) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.PreserveOrder = enable
+		return nil
+	}
+}
+
+// IgnoreRules skips the files and directories of the added directories
+// matching the .gitignore-style rules, such as "node_modules/" or "*.o". The
+// rules are matched against the paths relative to the added directory. The
+// rules add to the rules of the previous calls and of IgnoreFile.
+func (unixfsOpts) IgnoreRules(rules []string) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.IgnoreRules = append(settings.IgnoreRules, rules...)
+		return nil
+	}
+}
+
+// IgnoreFile reads more ignore rules, see IgnoreRules, from the .gitignore
+// file at path, on the filesystem of the caller.
+func (unixfsOpts) IgnoreFile(path string) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.IgnoreFile = path
+		return nil
+	}
+}
+
+// IncludeHidden sets whether the hidden files and directories of the added
+// directories, whose name starts with a dot, are added. When enabled, the
+// directories of localfiles.NewSerialFile list their hidden entries, whatever
+// they were built with: only the ignore rules apply. The other directories,
+// such as the ones of files.NewSerialFile, can't add the hidden entries they
+// leave out. When disabled, the hidden entries of any directory are skipped.
+// By default, the entries are added as listed by the directories.
+func (unixfsOpts) IncludeHidden(include bool) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.IncludeHidden = include
+		settings.IncludeHiddenSet = true
+		return nil
+	}
+}
+
+// DereferenceSymlinks sets how the symlinks read from the local filesystem
+// are added: as symlink nodes with DereferenceNever, the default, or as the
+// files and directories they point to, for all of them with
+// DereferenceAlways, or only for the added node with DereferenceTopLevel.
+// Adding a symlink to one of its parent directories fails instead of looping,
+// and so does adding a dangling symlink when it is dereferenced. The
+// directories reached through the symlinks list their hidden entries only
+// with IncludeHidden.
+//
+// Only the symlinks in the directories of localfiles.NewSerialFile, which
+// tell their path, are dereferenced: the ones of the other directories, such
+// as the ones of files.NewSerialFile or the ones sent over the HTTP API, are
+// always stored as symlink nodes. The relative target of a top-level symlink, whose location
+// is unknown, is resolved against the working directory.
+func (unixfsOpts) DereferenceSymlinks(policy SymlinkPolicy) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.Dereference = policy
+		return nil
+	}
+}
+
+// MemoryBudget caps the size of the blocks the adder buffers in memory
+// before they are written. The blocks over the budget are spilled to a
+// temporary file, so that importing huge files on memory-constrained devices
+// doesn't run out of memory. 0, the default, doesn't cap the memory.
+func (unixfsOpts) MemoryBudget(bytes uint64) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.MemoryBudget = bytes
+		return nil
+	}
+}
+
+// Stats fills stats with the resources used by the add once done. Only the
+// local implementation fills them.
+func (unixfsOpts) Stats(stats *AddStats) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.Stats = stats
+		return nil
+	}
+}
+
+// DryRun makes the add only hash the data, like HashOnly, and fills stats
+// with the blocks of the DAG it would write: their number and size, the
+// overhead of the UnixFS nodes over the data of the files, and the blocks
+// already stored locally.
+func (unixfsOpts) DryRun(stats *DryRunStats) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.DryRun = stats
+		return nil
+	}
+}
+
+// PreserveMode stores the POSIX mode of the files and directories added from
+// the local filesystem in their UnixFS 1.5 metadata. Directories only keep
+// their mode when they are read by the adding process, not over the HTTP API,
+// and sharded directories don't keep it.
+func (unixfsOpts) PreserveMode(enable bool) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.PreserveMode = enable
+		return nil
+	}
+}
+
+// PreserveMtime stores the modification time of the files and directories
+// added from the local filesystem in their UnixFS 1.5 metadata, with the same
+// limits as PreserveMode.
+func (unixfsOpts) PreserveMtime(enable bool) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.PreserveMtime = enable
+		return nil
+	}
+}
+
+// Mode stores mode in the UnixFS 1.5 metadata of all the added files and
+// directories, instead of their own mode. Only the permission, setuid, setgid
+// and sticky bits are stored.
+func (unixfsOpts) Mode(mode os.FileMode) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.Mode = mode
+		return nil
+	}
+}
+
+// Mtime stores mtime in the UnixFS 1.5 metadata of all the added files and
+// directories, instead of their own modification time.
+func (unixfsOpts) Mtime(mtime time.Time) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.Mtime = mtime
+		return nil
+	}
+}
+
 func (unixfsOpts) ResolveChildren(resolve bool) UnixfsLsOption {
 	return func(settings *UnixfsLsSettings) error {
 		settings.ResolveChildren = resolve
@@ -293,3 +867,183 @@ func (unixfsOpts) UseCumulativeSize(use bool) UnixfsLsOption {
 		return nil
 	}
 }
+
+// ResolveMimeType sets the MIME type of the files listed, sniffed from their
+// first block. It implies ResolveChildren. MIME types are cached by CID, so
+// the first block of a file is only read once.
+func (unixfsOpts) ResolveMimeType(resolve bool) UnixfsLsOption {
+	return func(settings *UnixfsLsSettings) error {
+		settings.ResolveMimeType = resolve
+		return nil
+	}
+}
+
+// PreservedOrder lists the entries of the directories added with the
+// PreserveOrder option in the order they were added in, instead of by name,
+// including with an offset, a limit or a cursor. The directories which don't
+// store an order are listed as usual.
+func (unixfsOpts) PreservedOrder(enable bool) UnixfsLsOption {
+	return func(settings *UnixfsLsSettings) error {
+		settings.PreservedOrder = enable
+		return nil
+	}
+}
+
+// Offset skips the first n entries of the directory listed. With an offset, a
+// limit or a cursor, the entries are listed in a stable order: by name for
+// basic directories, and by hash of the name for HAMT sharded ones.
+func (unixfsOpts) Offset(n int) UnixfsLsOption {
+	return func(settings *UnixfsLsSettings) error {
+		if n < 0 {
+			return errors.New("negative offset")
+		}
+		settings.Offset = n
+		return nil
+	}
+}
+
+// LsLimit lists at most n entries of the directory. 0 lists all of them. It is
+// not named Limit, which limits the search results.
+func (unixfsOpts) LsLimit(n int) UnixfsLsOption {
+	return func(settings *UnixfsLsSettings) error {
+		if n < 0 {
+			return errors.New("negative limit")
+		}
+		settings.Limit = n
+		return nil
+	}
+}
+
+// Cursor resumes the listing of a directory after the entry the cursor was
+// returned with, in DirEntry.Cursor. Unlike an offset, a cursor doesn't read
+// the shards of a HAMT sharded directory that come before it.
+func (unixfsOpts) Cursor(cursor string) UnixfsLsOption {
+	return func(settings *UnixfsLsSettings) error {
+		settings.Cursor = cursor
+		return nil
+	}
+}
+
+// Limit limits the number of search results. 0 returns all of them.
+func (unixfsOpts) Limit(limit int) UnixfsSearchOption {
+	return func(settings *UnixfsSearchSettings) error {
+		settings.Limit = limit
+		return nil
+	}
+}
+
+// Progressive makes the files returned by Get fetch their leaf blocks ahead
+// of the reads, in parallel, and yield the data of each leaf as soon as it
+// and the leaves before it are fetched and verified, instead of walking the
+// DAG one block at a time. It suits streaming, e.g. of videos.
+func (unixfsOpts) Progressive(progressive bool) UnixfsGetOption {
+	return func(settings *UnixfsGetSettings) error {
+		settings.Progressive = progressive
+		return nil
+	}
+}
+
+// OnRange is called with the byte range of each leaf of a progressive file
+// once it is fetched and verified, possibly before the previous ranges. It
+// may be called concurrently, and implies Progressive. Only the local
+// implementation calls it.
+func (unixfsOpts) OnRange(f func(offset, length uint64)) UnixfsGetOption {
+	return func(settings *UnixfsGetSettings) error {
+		settings.OnRange = f
+		settings.Progressive = true
+		return nil
+	}
+}
+
+// GetOffset makes Get return the data of the file from the offset, only
+// fetching the blocks of the file from there. Get fails with ErrIsDir for the
+// directories with an offset or a length.
+func (unixfsOpts) GetOffset(offset int64) UnixfsGetOption {
+	return func(settings *UnixfsGetSettings) error {
+		if offset < 0 {
+			return fmt.Errorf("invalid offset %d", offset)
+		}
+		settings.Offset = offset
+		return nil
+	}
+}
+
+// GetLength makes Get return at most length bytes of the file, from the
+// offset set by GetOffset, only fetching the blocks of the file covering
+// them.
+func (unixfsOpts) GetLength(length int64) UnixfsGetOption {
+	return func(settings *UnixfsGetSettings) error {
+		if length < 0 {
+			return fmt.Errorf("invalid length %d", length)
+		}
+		settings.Length = length
+		return nil
+	}
+}
+
+// Archive makes Get return a file streaming the tree as a tar archive,
+// produced as it is read, instead of the tree. The archive has no size.
+func (unixfsOpts) Archive(archive bool) UnixfsGetOption {
+	return func(settings *UnixfsGetSettings) error {
+		settings.Archive = archive
+		return nil
+	}
+}
+
+// ArchiveCompression gzip compresses the archive returned by Get at the level,
+// from 1 to 9, or gzip.DefaultCompression. It implies Archive.
+func (unixfsOpts) ArchiveCompression(level int) UnixfsGetOption {
+	return func(settings *UnixfsGetSettings) error {
+		if level != gzip.DefaultCompression && (level < gzip.BestSpeed || level > gzip.BestCompression) {
+			return fmt.Errorf("invalid compression level %d", level)
+		}
+		settings.Compression = level
+		settings.Archive = true
+		return nil
+	}
+}
+
+// Parents creates the missing parent directories of the destination of Mv.
+// Default: false
+func (unixfsOpts) Parents(parents bool) UnixfsMvOption {
+	return func(settings *UnixfsMvSettings) error {
+		settings.Parents = parents
+		return nil
+	}
+}
+
+// Overwrite replaces the entry already at the destination of Mv, which fails
+// otherwise. Default: false
+func (unixfsOpts) Overwrite(overwrite bool) UnixfsMvOption {
+	return func(settings *UnixfsMvSettings) error {
+		settings.Overwrite = overwrite
+		return nil
+	}
+}
+
+// Recursive makes Chmod and Touch change every entry under the directory at
+// their path too. Default: false
+func (unixfsOpts) Recursive(recursive bool) UnixfsMetaOption {
+	return func(settings *UnixfsMetaSettings) error {
+		settings.Recursive = recursive
+		return nil
+	}
+}
+
+// ModTime is the modification time set by Touch. Default: the current time
+func (unixfsOpts) ModTime(mtime time.Time) UnixfsMetaOption {
+	return func(settings *UnixfsMetaSettings) error {
+		settings.ModTime = mtime
+		return nil
+	}
+}
+
+// SymlinkParents creates the missing parent directories of the symlink
+// written by Symlink, like 'ipfs files mkdir -p'. It is not named Parents,
+// which is the option of Mv. Default: false
+func (unixfsOpts) SymlinkParents(parents bool) UnixfsSymlinkOption {
+	return func(settings *UnixfsSymlinkSettings) error {
+		settings.Parents = parents
+		return nil
+	}
+}
@@ -0,0 +1,67 @@
+package iface
+
+import (
+	"context"
+
+	"github.com/ipfs/kubo/core/coreiface/options"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// P2PMessageHandler answers the messages received from other peers. The
+// returned data is sent back to the sender as the reply, or the error
+// message when it fails.
+type P2PMessageHandler func(ctx context.Context, from peer.ID, data []byte) ([]byte, error)
+
+// P2PListener is a tunnel of TCP services over libp2p, see
+// Experimental.Libp2pStreamMounting. It is either a forward, of the
+// connections made to a local address to the protocol of a peer, or a
+// listener, of the streams opened by peers on a protocol to a local address.
+type P2PListener struct {
+	Protocol string
+	// ListenAddress is the local address of a forward, or /p2p/<self> for a
+	// listener.
+	ListenAddress ma.Multiaddr
+	// TargetAddress is /p2p/<peer> for a forward, or the local address of a
+	// listener.
+	TargetAddress ma.Multiaddr
+	// AllowedPeers are the peers allowed to open streams to a listener, all
+	// of them when empty.
+	AllowedPeers []peer.ID
+	// BytesIn and BytesOut count the bytes received from and sent to the
+	// peers by the streams of the listener.
+	BytesIn  uint64
+	BytesOut uint64
+}
+
+// P2PAPI specifies the interface to the direct messaging between peers, and
+// to the tunnels of TCP services over libp2p. Each message is sent on its
+// own libp2p stream, the sender is authenticated by the secure channel of the
+// connection, and the size of the messages and of their replies is limited.
+// The protocols must be prefixed with '/x/', unless custom protocols are
+// allowed for tunnels.
+type P2PAPI interface {
+	// Send sends data to the peer p on the protocol proto, and returns the
+	// reply of its handler.
+	Send(ctx context.Context, p peer.ID, proto string, data []byte, opts ...options.P2PSendOption) ([]byte, error)
+
+	// Handle answers the messages received on the protocol proto with
+	// handler, until ctx is done.
+	Handle(ctx context.Context, proto string, handler P2PMessageHandler, opts ...options.P2PHandleOption) error
+
+	// Forward forwards the connections made to listenAddr to the protocol
+	// proto of the peer target.
+	Forward(ctx context.Context, proto string, listenAddr ma.Multiaddr, target peer.ID, opts ...options.P2PListenOption) (P2PListener, error)
+
+	// Listen forwards the streams opened by peers on the protocol proto to
+	// targetAddr.
+	Listen(ctx context.Context, proto string, targetAddr ma.Multiaddr, opts ...options.P2PListenOption) (P2PListener, error)
+
+	// Listeners lists the forwards and listeners.
+	Listeners(context.Context) ([]P2PListener, error)
+
+	// Close closes the forwards and listeners matching the options, and
+	// returns how many were closed. The streams they opened are kept.
+	Close(context.Context, ...options.P2PCloseOption) (int, error)
+}
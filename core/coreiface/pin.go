@@ -2,8 +2,10 @@ package iface
 
 import (
 	"context"
+	"time"
 
 	"github.com/ipfs/boxo/path"
+	"github.com/libp2p/go-libp2p/core/peer"
 
 	"github.com/ipfs/kubo/core/coreiface/options"
 )
@@ -44,6 +46,100 @@ type BadPinNode interface {
 	Err() error
 }
 
+// PinRequest is a pin queued with PinAPI.Enqueue
+type PinRequest struct {
+	// ID identifies the request in the queue
+	ID string
+
+	Path      path.ImmutablePath
+	Recursive bool
+	Name      string
+	Priority  int
+
+	// Status is "queued", "pinning" or "failed". Pinned requests leave the
+	// queue.
+	Status string
+
+	// Attempts is the number of failed attempts, LastError the error of the
+	// last one
+	Attempts  int
+	LastError string
+
+	Created     time.Time
+	NextAttempt time.Time
+}
+
+// PinReport accounts for the blocks fetched by the last pin of a CID
+type PinReport struct {
+	Path      path.ImmutablePath
+	Recursive bool
+	Started   time.Time
+	Finished  time.Time
+
+	// Blocks and Bytes are the totals of the pinned DAG
+	Blocks uint64
+	Bytes  uint64
+
+	// Sources are the shares of the pin by origin, largest first
+	Sources []PinReportSource
+}
+
+// PinReportSource is the share of a pin fetched from a peer over a transport
+type PinReportSource struct {
+	// Peer is empty for the blocks that were already stored locally
+	// (Transport "local") and the blocks of unknown origin (Transport
+	// "unknown")
+	Peer peer.ID
+
+	// Transport is the transport of the connection to the peer, e.g. "tcp",
+	// "quic-v1" or "p2p-circuit"
+	Transport string
+
+	Blocks uint64
+	Bytes  uint64
+}
+
+// PinRemoval is a pin removed with a grace period, whose blocks are protected
+// from garbage collection until the grace period expires
+type PinRemoval struct {
+	Path      path.ImmutablePath
+	Recursive bool
+	Name      string
+	Removed   time.Time
+	Expires   time.Time
+}
+
+// PinProvideClass is the provide class of a pin, the cadence its DAG is
+// reprovided at
+type PinProvideClass struct {
+	Path      path.ImmutablePath
+	Recursive bool
+
+	// Class is "hot" (reprovided hourly), "warm" (daily), "cold" (weekly) or
+	// "never"
+	Class string
+
+	// Provided is when the DAG was last reprovided for its class, zero if
+	// it wasn't yet
+	Provided time.Time
+}
+
+// PinsetEntry is a pin of a pinset
+type PinsetEntry struct {
+	Path      path.ImmutablePath
+	Recursive bool
+}
+
+// PinsetDiff is the difference between the local pinset and the pinset of a
+// peer
+type PinsetDiff struct {
+	// Missing are the pins of the peer that aren't pinned locally
+	Missing []PinsetEntry
+
+	// Extra are the local pins the peer doesn't have
+	Extra []PinsetEntry
+}
+
 // PinAPI specifies the interface to pining
 type PinAPI interface {
 	// Add creates new pin, be default recursive - pinning the whole referenced
@@ -60,10 +156,46 @@ type PinAPI interface {
 	// Rm removes pin for object specified by the path
 	Rm(context.Context, path.Path, ...options.PinRmOption) error
 
+	// Removed lists the pins removed with a grace period that didn't expire
+	Removed(context.Context) ([]PinRemoval, error)
+
+	// Restore pins again an object whose pin was removed with a grace period
+	// that didn't expire
+	Restore(context.Context, path.Path) error
+
 	// Update changes one pin to another, skipping checks for matching paths in
 	// the old tree
 	Update(ctx context.Context, from path.Path, to path.Path, opts ...options.PinUpdateOption) error
 
 	// Verify verifies the integrity of pinned objects
 	Verify(context.Context) (<-chan PinStatus, error)
+
+	// Enqueue queues a pin to be processed in the background. Queued pins are
+	// persisted, and retried when the content cannot be fetched
+	Enqueue(context.Context, path.Path, ...options.PinAddOption) (PinRequest, error)
+
+	// Queue lists the queued pins in the order they will be processed
+	Queue(context.Context) ([]PinRequest, error)
+
+	// SetQueuePriority moves a queued pin in the queue
+	SetQueuePriority(ctx context.Context, id string, priority int) (PinRequest, error)
+
+	// CancelQueued removes a pin from the queue
+	CancelQueued(ctx context.Context, id string) error
+
+	// Report returns how many blocks and bytes of the last pin of a path came
+	// from which peers, over which transports
+	Report(context.Context, path.Path) (PinReport, error)
+
+	// SetProvideClass sets the provide class of a pin: its DAG is reprovided
+	// at the cadence of the class instead of every Reprovider.Interval. An
+	// empty class clears it
+	SetProvideClass(ctx context.Context, p path.Path, class string) error
+
+	// ProvideClasses lists the pins with a provide class
+	ProvideClasses(context.Context) ([]PinProvideClass, error)
+
+	// Reconcile compares the local pinset with the pinset of a peer, without
+	// transferring the full pin lists
+	Reconcile(context.Context, peer.ID) (PinsetDiff, error)
 }
@@ -0,0 +1,40 @@
+package iface
+
+import (
+	"context"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/ipfs/kubo/core/coreiface/options"
+)
+
+// ReplicatedPin is a pin pushed to a peer with ReplicateAPI.Push
+type ReplicatedPin struct {
+	Path      path.ImmutablePath
+	Recursive bool
+	Name      string
+
+	// Blocks and Bytes are the blocks sent, the blocks the peer already had
+	// aren't counted
+	Blocks uint64
+	Bytes  uint64
+
+	// Skipped is true when the peer had the pin already
+	Skipped bool
+
+	// Attempts is the number of times the pin was sent, more than one when
+	// the push was interrupted and resumed
+	Attempts int
+}
+
+// ReplicateAPI specifies the interface to the replication of pins to peers
+type ReplicateAPI interface {
+	// Push sends the pins named name, or all the pins when name is empty,
+	// with their DAGs, to the target peer, which pins them. Only the blocks
+	// the peer doesn't have are sent, and interrupted pushes are resumed.
+	// Each pin is checked to be pinned by the peer once sent. Both nodes need
+	// Experimental.PinReplication, and the peer only accepts the pins of
+	// the nodes of its Peering.Peers.
+	Push(ctx context.Context, target peer.ID, name string, opts ...options.ReplicatePushOption) ([]ReplicatedPin, error)
+}
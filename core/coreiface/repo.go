@@ -0,0 +1,32 @@
+package iface
+
+import (
+	"context"
+	"time"
+)
+
+// GCReport is the report of a garbage collection of the repo
+type GCReport struct {
+	Started  time.Time
+	Duration time.Duration
+
+	// Removed is the number of blocks removed, and FreedBytes their size
+	Removed    int
+	FreedBytes uint64
+
+	// Retained is the number of blocks kept, by the reason they were kept
+	// for: "recursive", "best-effort" (MFS), "direct", "internal" or "other"
+	Retained map[string]int
+
+	// ErrorCount is the number of errors of the garbage collection, and
+	// Errors the first of them
+	ErrorCount int
+	Errors     []string
+}
+
+// RepoAPI specifies the interface to the repo of the node
+type RepoAPI interface {
+	// LastGCReport returns the report of the last garbage collection of the
+	// repo, stored when it ended
+	LastGCReport(ctx context.Context) (GCReport, error)
+}
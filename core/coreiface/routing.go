@@ -2,12 +2,77 @@ package iface
 
 import (
 	"context"
+	"time"
 
 	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
 	"github.com/ipfs/kubo/core/coreiface/options"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
 
+// AvailabilityVerdict tells whether a CID can be retrieved from the network.
+type AvailabilityVerdict string
+
+const (
+	// AvailabilityRetrievable means that the block of the CID was fetched
+	// from the network.
+	AvailabilityRetrievable AvailabilityVerdict = "retrievable"
+	// AvailabilityProvided means that providers were found for the CID, its
+	// block was not fetched.
+	AvailabilityProvided AvailabilityVerdict = "provided"
+	// AvailabilityUnreachable means that providers were found for the CID,
+	// but its block could not be fetched.
+	AvailabilityUnreachable AvailabilityVerdict = "unreachable"
+	// AvailabilityUnavailable means that no providers were found for the CID.
+	AvailabilityUnavailable AvailabilityVerdict = "unavailable"
+)
+
+// Availability is the availability of a CID on the network.
+type Availability struct {
+	Cid     cid.Cid
+	Verdict AvailabilityVerdict
+	// Providers is the number of providers found, other than the local node.
+	Providers int
+	// Error tells why the block could not be fetched, with
+	// AvailabilityUnreachable.
+	Error string `json:",omitempty"`
+}
+
+// ProviderProbe is the probe of a provider by Routing().EstimateRetrieval.
+type ProviderProbe struct {
+	ID peer.ID
+	// Latency is the median round trip time of the pings of the provider,
+	// zero when it could not be pinged.
+	Latency time.Duration
+	// Received is the number of bytes of the sample the provider sent, and
+	// Bandwidth the rate it sent them at, in bytes per second.
+	Received  uint64
+	Bandwidth float64
+	// Error tells why the provider could not be pinged.
+	Error string `json:",omitempty"`
+}
+
+// RetrievalEstimate is the estimated cost of fetching a DAG from the
+// network, from a sample of its blocks.
+type RetrievalEstimate struct {
+	Cid cid.Cid
+	// Size is the size of the DAG, as recorded by its root, or the size of
+	// the root for the DAGs that don't record it.
+	Size uint64
+	// Depth is the number of levels of the DAG, walking its first links.
+	Depth     int
+	Providers []ProviderProbe
+	// SampleBytes and SampleDuration are the size of the blocks fetched to
+	// sample the bandwidth, and how long it took. Bandwidth is their combined
+	// rate, in bytes per second.
+	SampleBytes    uint64
+	SampleDuration time.Duration
+	Bandwidth      float64
+	// Duration is the predicted time to fetch the DAG, zero when no provider
+	// sent its blocks.
+	Duration time.Duration
+}
+
 // RoutingAPI specifies the interface to the routing layer.
 type RoutingAPI interface {
 	// Get retrieves the best value for a given key
@@ -26,4 +91,16 @@ type RoutingAPI interface {
 
 	// Provide announces to the network that you are providing given values
 	Provide(context.Context, path.Path, ...options.RoutingProvideOption) error
+
+	// CheckAvailability looks for the providers of each of the CIDs, in
+	// parallel, and optionally fetches their blocks from the network, to tell
+	// whether they can be retrieved by other peers. The local node does not
+	// count as a provider.
+	CheckAvailability(context.Context, []cid.Cid, ...options.RoutingCheckAvailabilityOption) ([]Availability, error)
+
+	// EstimateRetrieval estimates how long fetching the DAG of the path from
+	// the network takes, without fetching it: its providers are pinged, and a
+	// sample of its blocks is fetched, without storing them, to measure their
+	// bandwidth.
+	EstimateRetrieval(context.Context, path.Path, ...options.RoutingEstimateRetrievalOption) (RetrievalEstimate, error)
 }
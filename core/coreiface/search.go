@@ -0,0 +1,35 @@
+package iface
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/core/coreiface/options"
+)
+
+// SearchHit is a document of the full-text index matching a query.
+type SearchHit struct {
+	// Source is "mfs" for MFS documents, or "pin" for the documents of
+	// pinned DAGs.
+	Source string
+	// Path is the MFS path of the document, or its /ipfs path under Root.
+	Path string
+	Cid  cid.Cid
+	// Root is the pinned CID the document was found under. It is undefined
+	// for MFS documents.
+	Root   cid.Cid
+	Title  string
+	Format string
+	Score  float64
+	// Snippet is the text around the first match of the query.
+	Snippet string
+}
+
+// SearchAPI specifies the interface to the full-text index of MFS and of the
+// pinned DAGs, see Experimental.FullTextSearch.
+type SearchAPI interface {
+	// Query returns the documents containing all the words of q, best match
+	// first. Quoted words are phrases, words starting with '-' are excluded,
+	// and words ending with '*' match any word they start.
+	Query(ctx context.Context, q string, opts ...options.SearchQueryOption) ([]SearchHit, error)
+}
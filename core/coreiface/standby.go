@@ -0,0 +1,48 @@
+package iface
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/ipfs/kubo/core/coreiface/options"
+)
+
+// StandbyStatus is the status of a node of a warm standby pair
+type StandbyStatus struct {
+	// Primary is the node mirrored, empty on primary nodes
+	Primary peer.ID
+	// Standbys are the nodes allowed to mirror this node
+	Standbys []peer.ID
+	// Promoted is true once the standby was promoted
+	Promoted bool
+
+	// LastSync is when the primary was last mirrored, and LastError the
+	// error of the attempts since, if any
+	LastSync  time.Time
+	LastError string
+
+	// Pins, Files and Keys describe the state last mirrored: the number of
+	// pins, the MFS root and the number of keys
+	Pins  int
+	Files cid.Cid
+	Keys  int
+}
+
+// StandbyAPI specifies the interface to warm standby pairs: a standby node
+// mirrors the pinset, the MFS root and the keys of its primary, and can be
+// promoted to replace it.
+type StandbyAPI interface {
+	// Status returns the status of the node
+	Status(context.Context) (StandbyStatus, error)
+
+	// Sync mirrors the primary now, instead of waiting for Standby.Interval
+	Sync(context.Context) error
+
+	// Promote stops mirroring the primary, and makes the MFS root mirrored
+	// the root of the node. The primary is mirrored one last time first,
+	// unless it can't be reached and the promotion is forced.
+	Promote(context.Context, ...options.StandbyPromoteOption) error
+}
@@ -35,6 +35,20 @@ type ConnectionInfo interface {
 	Streams() ([]protocol.ID, error)
 }
 
+// KnownPeer is a peer of the peerstore
+type KnownPeer struct {
+	ID peer.ID
+
+	// Addrs are the addresses of the peer that have not expired
+	Addrs []ma.Multiaddr
+
+	// Protocols are the protocols the peer supports, as last identified
+	Protocols []protocol.ID
+
+	// Connected tells whether the node is connected to the peer
+	Connected bool
+}
+
 // SwarmAPI specifies the interface to libp2p swarm
 type SwarmAPI interface {
 	// Connect to a given peer
@@ -54,4 +68,13 @@ type SwarmAPI interface {
 
 	// ListenAddrs returns the list of all listening addresses
 	ListenAddrs(context.Context) ([]ma.Multiaddr, error)
+
+	// KnownPeers returns the peers of the peerstore, with their addresses
+	// and protocols
+	KnownPeers(context.Context) ([]KnownPeer, error)
+
+	// ForgetPeers removes the given peers from the peerstore, or all the
+	// peers when none is given, and returns the peers removed. The peers
+	// the node is connected to are not removed.
+	ForgetPeers(context.Context, ...peer.ID) ([]peer.ID, error)
 }
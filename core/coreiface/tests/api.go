@@ -74,14 +74,20 @@ func TestApi(p Provider) func(t *testing.T) {
 
 	return func(t *testing.T) {
 		t.Run("Block", tp.TestBlock)
+		t.Run("Cache", tp.TestCache)
+		t.Run("Car", tp.TestCar)
 		t.Run("Dag", tp.TestDag)
+		t.Run("Export", tp.TestExport)
 		t.Run("Key", tp.TestKey)
+		t.Run("KV", tp.TestKV)
 		t.Run("Name", tp.TestName)
 		t.Run("Object", tp.TestObject)
+		t.Run("P2P", tp.TestP2P)
 		t.Run("Path", tp.TestPath)
 		t.Run("Pin", tp.TestPin)
 		t.Run("PubSub", tp.TestPubSub)
 		t.Run("Routing", tp.TestRouting)
+		t.Run("Search", tp.TestFullTextSearch)
 		t.Run("Unixfs", tp.TestUnixfs)
 
 		apis <- -1
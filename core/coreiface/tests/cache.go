@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	iface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/stretchr/testify/require"
+)
+
+func (tp *TestSuite) TestCache(t *testing.T) {
+	tp.hasApi(t, func(api iface.CoreAPI) error {
+		if api.Cache() == nil {
+			return errAPINotImplemented
+		}
+		return nil
+	})
+
+	t.Run("TestCacheWarmFromLog", tp.TestCacheWarmFromLog)
+}
+
+func (tp *TestSuite) TestCacheWarmFromLog(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	require.NoError(t, err)
+
+	p, err := api.Unixfs().Add(ctx, strFile(helloStr)())
+	require.NoError(t, err)
+
+	log := fmt.Sprintf(`%s
+203.0.113.7 - - [15/Oct/2026:10:00:00 +0000] "GET %s HTTP/1.1" 200 13 "-" "curl"
+203.0.113.7 - - [15/Oct/2026:10:00:01 +0000] "GET %s/missing HTTP/1.1" 404 0 "-" "curl"
+`, p.RootCid(), p, p)
+
+	res, err := api.Cache().WarmFromLog(ctx, strings.NewReader(log), options.Cache.PinTTL(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 2, res.Paths)
+	require.Equal(t, 1, res.Warmed)
+	require.Equal(t, 1, res.Protected)
+	require.Len(t, res.Failures, 1)
+	require.Equal(t, p.String()+"/missing", res.Failures[0].Path.String())
+
+	// the warmed DAGs aren't removed pins
+	removed, err := api.Pin().Removed(ctx)
+	require.NoError(t, err)
+	require.Empty(t, removed)
+}
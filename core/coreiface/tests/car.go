@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	iface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/ipfs/kubo/core/coreiface/options"
+	gocar "github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+	gocarv2 "github.com/ipld/go-car/v2"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
+	"github.com/stretchr/testify/require"
+)
+
+func (tp *TestSuite) TestCar(t *testing.T) {
+	tp.hasApi(t, func(api iface.CoreAPI) error {
+		if api.Car() == nil {
+			return errAPINotImplemented
+		}
+		return nil
+	})
+
+	t.Run("TestCarInspect", tp.TestCarInspect)
+	t.Run("TestCarTranscode", tp.TestCarTranscode)
+}
+
+// makeCar returns a CARv1 of the given blocks, the data of the blocks being
+// written as is.
+func makeCar(t *testing.T, roots []cid.Cid, blks map[cid.Cid][]byte, order []cid.Cid) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, gocar.WriteHeader(&gocar.CarHeader{Roots: roots, Version: 1}, &buf))
+	for _, c := range order {
+		require.NoError(t, carutil.LdWrite(&buf, c.Bytes(), blks[c]))
+	}
+	return buf.Bytes()
+}
+
+func (tp *TestSuite) TestCarInspect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	require.NoError(t, err)
+
+	raw := blocks.NewBlock([]byte("raw block"))
+	rawV1 := cid.NewCidV1(cid.Raw, raw.Cid().Hash())
+	other := cid.NewCidV1(cid.DagCBOR, raw.Cid().Hash())
+	blks := map[cid.Cid][]byte{rawV1: raw.RawData(), other: raw.RawData()}
+	missing := cid.NewCidV1(cid.Raw, blocks.NewBlock([]byte("missing")).Cid().Hash())
+	v1 := makeCar(t, []cid.Cid{rawV1, missing}, blks, []cid.Cid{rawV1, other})
+
+	res, err := api.Car().Inspect(ctx, bytes.NewReader(v1))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res.Version)
+	require.Equal(t, []cid.Cid{rawV1, missing}, res.Roots)
+	require.Equal(t, []cid.Cid{missing}, res.MissingRoots)
+	require.Equal(t, uint64(2), res.Blocks)
+	require.Equal(t, uint64(2*len(raw.RawData())), res.Bytes)
+	require.Equal(t, []iface.CarCodec{{Codec: "dag-cbor", Blocks: 1}, {Codec: "raw", Blocks: 1}}, res.Codecs)
+	require.False(t, res.HasIndex)
+	require.Empty(t, res.Errors)
+
+	// a CARv2 with an index
+	var v2 bytes.Buffer
+	require.NoError(t, gocarv2.WrapV1(bytes.NewReader(v1), &v2))
+	res, err = api.Car().Inspect(ctx, bytes.NewReader(v2.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res.Version)
+	require.Equal(t, []cid.Cid{rawV1, missing}, res.Roots)
+	require.Equal(t, uint64(2), res.Blocks)
+	require.True(t, res.HasIndex)
+	require.True(t, res.IndexValid)
+	require.NotEmpty(t, res.IndexCodec)
+	require.Empty(t, res.Errors)
+
+	// a block that doesn't match its hash, and a truncated section
+	blks[rawV1] = []byte("tampered")
+	tampered := makeCar(t, []cid.Cid{rawV1}, blks, []cid.Cid{rawV1, other})
+	res, err = api.Car().Inspect(ctx, bytes.NewReader(tampered[:len(tampered)-3]))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res.Blocks)
+	require.Len(t, res.Errors, 2)
+	require.Contains(t, res.Errors[0].Error, "doesn't match its hash")
+	require.Contains(t, res.Errors[1].Error, "truncated")
+
+	// not a CAR
+	res, err = api.Car().Inspect(ctx, bytes.NewReader([]byte("hello world")))
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res.Version)
+	require.NotEmpty(t, res.Errors)
+}
+
+func (tp *TestSuite) TestCarTranscode(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	require.NoError(t, err)
+
+	child := merkledag.NewRawNode([]byte("child"))
+	unrelated := merkledag.NewRawNode([]byte("unrelated"))
+	root := merkledag.NodeWithData([]byte("root"))
+	require.NoError(t, root.AddNodeLink("child", child))
+	blks := map[cid.Cid][]byte{
+		root.Cid():      root.RawData(),
+		child.Cid():     child.RawData(),
+		unrelated.Cid(): unrelated.RawData(),
+	}
+	v1 := makeCar(t, []cid.Cid{root.Cid()}, blks, []cid.Cid{root.Cid(), child.Cid(), unrelated.Cid(), child.Cid()})
+
+	transcode := func(car []byte, opts ...options.CarTranscodeOption) iface.CarInspection {
+		t.Helper()
+		var out bytes.Buffer
+		require.NoError(t, api.Car().Transcode(ctx, bytes.NewReader(car), &out, opts...))
+		res, err := api.Car().Inspect(ctx, bytes.NewReader(out.Bytes()))
+		require.NoError(t, err)
+		require.Empty(t, res.Errors)
+		require.Equal(t, []cid.Cid{root.Cid()}, res.Roots)
+		return res
+	}
+
+	// CARv1 to CARv2, with an index, and back
+	var v2 bytes.Buffer
+	require.NoError(t, api.Car().Transcode(ctx, bytes.NewReader(v1), &v2))
+	res, err := api.Car().Inspect(ctx, bytes.NewReader(v2.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res.Version)
+	require.Equal(t, uint64(4), res.Blocks)
+	require.True(t, res.HasIndex)
+	require.True(t, res.IndexValid)
+
+	res = transcode(v2.Bytes(), options.Car.Version(1))
+	require.Equal(t, uint64(1), res.Version)
+	require.Equal(t, uint64(4), res.Blocks)
+
+	// without the duplicated block
+	res = transcode(v1, options.Car.Version(1), options.Car.Dedupe(true))
+	require.Equal(t, uint64(3), res.Blocks)
+	res = transcode(v1, options.Car.Dedupe(true))
+	require.Equal(t, uint64(3), res.Blocks)
+	require.True(t, res.IndexValid)
+
+	// only the blocks reachable from the root
+	res = transcode(v1, options.Car.Version(1), options.Car.Selector(selectorparse.CommonSelector_ExploreAllRecursively))
+	require.Equal(t, uint64(2), res.Blocks)
+	require.Empty(t, res.MissingRoots)
+
+	// only the root
+	sel, err := selectorparse.ParseJSONSelector(`{".":{}}`)
+	require.NoError(t, err)
+	res = transcode(v1, options.Car.Selector(sel))
+	require.Equal(t, uint64(1), res.Blocks)
+	require.True(t, res.IndexValid)
+
+	// malformed CARs are refused
+	blks[child.Cid()] = []byte("tampered")
+	tampered := makeCar(t, []cid.Cid{root.Cid()}, blks, []cid.Cid{root.Cid(), child.Cid()})
+	err = api.Car().Transcode(ctx, bytes.NewReader(tampered), io.Discard)
+	require.ErrorContains(t, err, "doesn't match its hash")
+
+	require.Error(t, api.Car().Transcode(ctx, bytes.NewReader(v1), io.Discard, options.Car.Version(3)))
+}
@@ -6,8 +6,11 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/ipfs/boxo/files"
 	"github.com/ipfs/boxo/path"
 	coreiface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/stretchr/testify/require"
 
 	ipldcbor "github.com/ipfs/go-ipld-cbor"
 	ipld "github.com/ipfs/go-ipld-format"
@@ -27,6 +30,7 @@ func (tp *TestSuite) TestDag(t *testing.T) {
 	t.Run("TestPath", tp.TestDagPath)
 	t.Run("TestTree", tp.TestTree)
 	t.Run("TestBatch", tp.TestBatch)
+	t.Run("TestPrune", tp.TestPrune)
 }
 
 var treeExpected = map[string]struct{}{
@@ -197,3 +201,61 @@ func (tp *TestSuite) TestBatch(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func (tp *TestSuite) TestPrune(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	require.NoError(t, err)
+
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"keep.txt": files.NewBytesFile([]byte("keep me")),
+		"photos": files.NewMapDirectory(map[string]files.Node{
+			"2019": files.NewMapDirectory(map[string]files.Node{
+				"beach.jpg": files.NewBytesFile([]byte("a photo of the beach")),
+			}),
+			"2020": files.NewMapDirectory(map[string]files.Node{
+				"snow.jpg": files.NewBytesFile([]byte("a photo of the snow")),
+			}),
+		}),
+	})
+	p, err := api.Unixfs().Add(ctx, dir, options.Unixfs.Pin(true))
+	require.NoError(t, err)
+
+	res, err := api.Dag().Prune(ctx, p.RootCid(), "photos/2019")
+	require.NoError(t, err)
+	require.False(t, res.Root.Equals(p.RootCid()))
+	// the 2019 directory, the file in it, and the old root and photos
+	// directory
+	require.EqualValues(t, 4, res.Blocks)
+	require.NotZero(t, res.Bytes)
+
+	ls := func(p path.Path) []string {
+		entries, err := api.Unixfs().Ls(ctx, p)
+		require.NoError(t, err)
+		var names []string
+		for e := range entries {
+			require.NoError(t, e.Err)
+			names = append(names, e.Name)
+		}
+		return names
+	}
+	newRoot := path.FromCid(res.Root)
+	require.ElementsMatch(t, []string{"keep.txt", "photos"}, ls(newRoot))
+	photos, err := path.Join(newRoot, "photos")
+	require.NoError(t, err)
+	require.Equal(t, []string{"2020"}, ls(photos))
+
+	// the pin moved to the new root
+	_, pinned, err := api.Pin().IsPinned(ctx, newRoot)
+	require.NoError(t, err)
+	require.True(t, pinned)
+	_, pinned, err = api.Pin().IsPinned(ctx, p)
+	require.NoError(t, err)
+	require.False(t, pinned)
+
+	_, err = api.Dag().Prune(ctx, res.Root, "photos/2019")
+	require.Error(t, err)
+	_, err = api.Dag().Prune(ctx, res.Root, "/")
+	require.Error(t, err)
+}
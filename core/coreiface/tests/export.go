@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	iface "github.com/ipfs/kubo/core/coreiface"
+	opt "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/stretchr/testify/require"
+)
+
+func (tp *TestSuite) TestExport(t *testing.T) {
+	tp.hasApi(t, func(api iface.CoreAPI) error {
+		if api.Export() == nil {
+			return errAPINotImplemented
+		}
+		return nil
+	})
+
+	t.Run("TestExportDataset", tp.TestExportDataset)
+}
+
+func (tp *TestSuite) TestExportDataset(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	require.NoError(t, err)
+
+	a, err := api.Unixfs().Add(ctx, strFile("a")(), opt.Unixfs.Pin(false))
+	require.NoError(t, err)
+	b, err := api.Unixfs().Add(ctx, strFile("b")(), opt.Unixfs.Pin(false))
+	require.NoError(t, err)
+	other, err := api.Unixfs().Add(ctx, strFile("other")(), opt.Unixfs.Pin(false))
+	require.NoError(t, err)
+	require.NoError(t, api.Pin().Add(ctx, a, opt.Pin.Name("dataset")))
+	require.NoError(t, api.Pin().Add(ctx, b, opt.Pin.Name("dataset"), opt.Pin.Recursive(false)))
+	require.NoError(t, api.Pin().Add(ctx, other, opt.Pin.Name("other")))
+
+	var buf bytes.Buffer
+	manifest, err := api.Export().Dataset(ctx, "dataset", &buf)
+	require.NoError(t, err)
+	require.Equal(t, "dataset", manifest.Label)
+	require.False(t, manifest.Created.IsZero())
+	require.ElementsMatch(t, []iface.DatasetPin{
+		{Name: "dataset", Root: a.RootCid(), Recursive: true},
+		{Name: "dataset", Root: b.RootCid(), Recursive: false},
+	}, manifest.Pins)
+
+	res, err := api.Car().Inspect(ctx, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res.Version)
+	require.Len(t, res.Roots, 1)
+	require.Empty(t, res.MissingRoots)
+	require.True(t, res.HasIndex)
+	require.True(t, res.IndexValid)
+	require.Empty(t, res.Errors)
+	// the manifest and the two files
+	require.Equal(t, uint64(3), res.Blocks)
+	require.Contains(t, res.Codecs, iface.CarCodec{Codec: "dag-json", Blocks: 1})
+	require.Equal(t, uint64(cid.DagJSON), res.Roots[0].Type())
+
+	_, err = api.Export().Dataset(ctx, "missing", &bytes.Buffer{})
+	require.Error(t, err)
+}
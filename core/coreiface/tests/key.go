@@ -30,6 +30,7 @@ func (tp *TestSuite) TestKey(t *testing.T) {
 	t.Run("TestGenerateSize", tp.TestGenerateSize)
 	t.Run("TestGenerateType", tp.TestGenerateType)
 	t.Run("TestGenerateExisting", tp.TestGenerateExisting)
+	t.Run("TestDerive", tp.TestDerive)
 	t.Run("TestList", tp.TestList)
 	t.Run("TestRename", tp.TestRename)
 	t.Run("TestRenameToSelf", tp.TestRenameToSelf)
@@ -316,6 +317,57 @@ func (tp *TestSuite) TestRemove(t *testing.T) {
 	assert.Equal(t, "self", l[0].Name())
 }
 
+func (tp *TestSuite) TestDerive(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	api, err := tp.makeAPI(t, ctx)
+	require.NoError(t, err)
+
+	const phrase = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	k, err := api.Key().Derive(ctx, "site", phrase, opt.Key.Path("m/0'/1'"))
+	require.NoError(t, err)
+	assert.Equal(t, "site", k.Name())
+	assert.Equal(t, "m/0'/1'", k.DerivationPath())
+
+	// deriving again gives the same key
+	again, err := api.Key().Derive(ctx, "site", phrase, opt.Key.Path("m/0'/1'"))
+	require.NoError(t, err)
+	assert.Equal(t, k.ID(), again.ID())
+
+	// another path, or another passphrase, gives another key
+	_, err = api.Key().Derive(ctx, "site", phrase, opt.Key.Path("m/0'/2'"))
+	require.ErrorContains(t, err, "already exists")
+	other, err := api.Key().Derive(ctx, "other", phrase, opt.Key.Path("m/0'/1'"), opt.Key.Passphrase("secret"))
+	require.NoError(t, err)
+	assert.NotEqual(t, k.ID(), other.ID())
+
+	byName, err := api.Key().Derive(ctx, "example.com", phrase)
+	require.NoError(t, err)
+	assert.NotEmpty(t, byName.DerivationPath())
+
+	_, _, err = api.Key().Rename(ctx, "site", "renamed")
+	require.NoError(t, err)
+
+	l, err := api.Key().List(ctx)
+	require.NoError(t, err)
+	require.Len(t, l, 4)
+	paths := map[string]string{}
+	for _, k := range l {
+		paths[k.Name()] = k.DerivationPath()
+	}
+	assert.Equal(t, map[string]string{
+		"self":        "",
+		"renamed":     "m/0'/1'",
+		"other":       "m/0'/1'",
+		"example.com": byName.DerivationPath(),
+	}, paths)
+
+	_, err = api.Key().Derive(ctx, "bad", phrase, opt.Key.Path("m/0"))
+	require.Error(t, err)
+}
+
 func (tp *TestSuite) TestSign(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
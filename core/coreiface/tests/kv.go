@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	iface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/stretchr/testify/require"
+)
+
+func (tp *TestSuite) TestKV(t *testing.T) {
+	tp.hasApi(t, func(api iface.CoreAPI) error {
+		if api.KV() == nil {
+			return errAPINotImplemented
+		}
+		return nil
+	})
+
+	t.Run("TestKVPutGet", tp.TestKVPutGet)
+	t.Run("TestKVReplication", tp.TestKVReplication)
+}
+
+func (tp *TestSuite) TestKVPutGet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	apis, err := tp.MakeAPISwarm(t, ctx, 1)
+	require.NoError(t, err)
+	api := apis[0]
+
+	self, err := api.Key().Self(ctx)
+	require.NoError(t, err)
+
+	e, err := api.KV().Put(ctx, "app", "b", []byte("2"))
+	require.NoError(t, err)
+	require.Equal(t, self.ID(), e.Writer)
+	_, err = api.KV().Put(ctx, "app", "a", []byte("1"))
+	require.NoError(t, err)
+
+	v, err := api.KV().Get(ctx, "app", "a")
+	require.NoError(t, err)
+	require.Equal(t, "1", string(v))
+
+	entries, err := api.KV().List(ctx, "app")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "a", entries[0].Key)
+	require.Equal(t, "b", entries[1].Key)
+	require.Greater(t, entries[0].Clock, entries[1].Clock)
+
+	require.NoError(t, api.KV().Delete(ctx, "app", "a"))
+	_, err = api.KV().Get(ctx, "app", "a")
+	require.ErrorContains(t, err, "key not found")
+
+	_, err = api.KV().Put(ctx, "a/b", "a", []byte("1"))
+	require.ErrorContains(t, err, "invalid store name")
+}
+
+func (tp *TestSuite) TestKVReplication(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	apis, err := tp.MakeAPISwarm(t, ctx, 2)
+	require.NoError(t, err)
+
+	// written before the second node joins the store
+	_, err = apis[0].KV().Put(ctx, "shared", "early", []byte("1"))
+	require.NoError(t, err)
+
+	writes, err := apis[1].KV().Subscribe(ctx, "shared")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		v, err := apis[1].KV().Get(ctx, "shared", "early")
+		return err == nil && string(v) == "1"
+	}, 30*time.Second, 100*time.Millisecond)
+
+	_, err = apis[0].KV().Put(ctx, "shared", "late", []byte("2"))
+	require.NoError(t, err)
+	for {
+		select {
+		case e := <-writes:
+			if e.Key != "late" {
+				continue
+			}
+			require.Equal(t, "2", string(e.Value))
+			return
+		case <-time.After(30 * time.Second):
+			t.Fatal("timed out waiting for the write")
+		}
+	}
+}
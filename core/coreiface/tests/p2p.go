@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	iface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+func (tp *TestSuite) TestP2P(t *testing.T) {
+	tp.hasApi(t, func(api iface.CoreAPI) error {
+		if api.P2P() == nil {
+			return errAPINotImplemented
+		}
+		return nil
+	})
+
+	t.Run("TestP2PMessages", tp.TestP2PMessages)
+	t.Run("TestP2PListeners", tp.TestP2PListeners)
+}
+
+func (tp *TestSuite) TestP2PMessages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	apis, err := tp.MakeAPISwarm(t, ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const proto = "/x/test/echo"
+	err = apis[0].P2P().Handle(ctx, proto, func(_ context.Context, _ peer.ID, data []byte) ([]byte, error) {
+		if string(data) == "fail" {
+			return nil, errors.New("failed on purpose")
+		}
+		return append([]byte("echo "), data...), nil
+	}, options.P2P.MaxMessageSize(16))
+	if errors.Is(err, iface.ErrNotSupported) {
+		t.Skip("handling messages is not supported by this implementation")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	self, err := apis[0].Key().Self(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := apis[1].P2P().Send(ctx, self.ID(), proto, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reply) != "echo hello" {
+		t.Errorf("unexpected reply: %q", reply)
+	}
+
+	_, err = apis[1].P2P().Send(ctx, self.ID(), proto, []byte("fail"))
+	if err == nil || !strings.Contains(err.Error(), "failed on purpose") {
+		t.Errorf("expected the error of the handler, got %v", err)
+	}
+
+	_, err = apis[1].P2P().Send(ctx, self.ID(), proto, []byte("a message over the limit"))
+	if err == nil || !strings.Contains(err.Error(), "over the limit") {
+		t.Errorf("expected the message to be refused, got %v", err)
+	}
+
+	_, err = apis[1].P2P().Send(ctx, self.ID(), "/test/echo", []byte("hello"))
+	if err == nil {
+		t.Error("expected protocols outside of /x/ to be refused")
+	}
+}
+
+// freeLocalAddr returns a local TCP address nothing listens on.
+func freeLocalAddr(t *testing.T) ma.Multiaddr {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	addr, err := ma.NewMultiaddr(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", l.Addr().(*net.TCPAddr).Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+func (tp *TestSuite) TestP2PListeners(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	apis, err := tp.MakeAPISwarm(t, ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	self0, err := apis[0].Key().Self(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	self1, err := apis[1].Key().Self(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an echo service behind the listener of the first node
+	echo, err := manet.Listen(freeLocalAddr(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			c, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				_, _ = io.Copy(c, c)
+			}()
+		}
+	}()
+
+	const proto = "/x/test/tunnel"
+	listener, err := apis[0].P2P().Listen(ctx, proto, echo.Multiaddr(), options.P2P.AllowPeers(self1.ID()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listener.AllowedPeers) != 1 || listener.AllowedPeers[0] != self1.ID() {
+		t.Errorf("unexpected allowed peers: %v", listener.AllowedPeers)
+	}
+
+	forward, err := apis[1].P2P().Forward(ctx, proto, freeLocalAddr(t), self0.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := manet.Dial(forward.ListenAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if string(buf) != "hello" {
+		t.Fatalf("unexpected echo: %q", buf)
+	}
+
+	listeners, err := apis[0].P2P().Listeners(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 1 || listeners[0].BytesIn < 5 || listeners[0].BytesOut < 5 {
+		t.Errorf("unexpected listeners: %+v", listeners)
+	}
+
+	if _, err := apis[0].P2P().Close(ctx); err == nil {
+		t.Error("expected an error without matching options")
+	}
+	closed, err := apis[0].P2P().Close(ctx, options.P2P.Protocol(proto))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closed != 1 {
+		t.Errorf("closed %d listeners, expected 1", closed)
+	}
+	closed, err = apis[1].P2P().Close(ctx, options.P2P.All(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closed != 1 {
+		t.Errorf("closed %d forwards, expected 1", closed)
+	}
+}
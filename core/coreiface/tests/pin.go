@@ -5,6 +5,7 @@ import (
 	"math"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ipfs/boxo/path"
 	"github.com/ipfs/go-cid"
@@ -28,6 +29,10 @@ func (tp *TestSuite) TestPin(t *testing.T) {
 	t.Run("TestPinLsIndirect", tp.TestPinLsIndirect)
 	t.Run("TestPinLsPrecedence", tp.TestPinLsPrecedence)
 	t.Run("TestPinIsPinned", tp.TestPinIsPinned)
+	t.Run("TestPinQueue", tp.TestPinQueue)
+	t.Run("TestPinReport", tp.TestPinReport)
+	t.Run("TestPinRmGrace", tp.TestPinRmGrace)
+	t.Run("TestPinProvideClass", tp.TestPinProvideClass)
 }
 
 func (tp *TestSuite) TestPinAdd(t *testing.T) {
@@ -49,6 +54,207 @@ func (tp *TestSuite) TestPinAdd(t *testing.T) {
 	}
 }
 
+func (tp *TestSuite) TestPinQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	apis, err := tp.makeAPISwarm(t, ctx, true, true, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := apis[0]
+
+	p, err := api.Unixfs().Add(ctx, strFile("foo")(), opt.Unixfs.Pin(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := api.Pin().Enqueue(ctx, p, opt.Pin.Name("queued"), opt.Pin.Priority(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ID == "" || req.Priority != 3 || req.Name != "queued" || !req.Path.RootCid().Equals(p.RootCid()) {
+		t.Fatalf("unexpected pin request %+v", req)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		reqs, err := api.Pin().Queue(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reqs) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pin still queued: %+v", reqs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, pinned, err := api.Pin().IsPinned(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pinned {
+		t.Fatal("queued pin was not pinned")
+	}
+
+	if err := api.Pin().CancelQueued(ctx, req.ID); err == nil {
+		t.Fatal("expected an error cancelling a pin that left the queue")
+	}
+}
+
+func (tp *TestSuite) TestPinReport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := api.Unixfs().Add(ctx, strFile("foo")(), opt.Unixfs.Pin(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := api.Pin().Report(ctx, p); err == nil {
+		t.Fatal("expected an error for an object that was never pinned")
+	}
+
+	if err := api.Pin().Add(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := api.Pin().Report(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Path.RootCid().Equals(p.RootCid()) || !report.Recursive || report.Blocks == 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if len(report.Sources) != 1 || report.Sources[0].Transport != "local" || report.Sources[0].Bytes != report.Bytes {
+		t.Fatalf("expected all blocks to be local, got %+v", report.Sources)
+	}
+}
+
+func (tp *TestSuite) TestPinRmGrace(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := api.Unixfs().Add(ctx, strFile("foo")(), opt.Unixfs.Pin(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Pin().Add(ctx, p, opt.Pin.Name("important")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := api.Pin().Restore(ctx, p); err == nil {
+		t.Fatal("expected an error restoring a pin that wasn't removed")
+	}
+
+	if err := api.Pin().Rm(ctx, p, opt.Pin.Grace(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, pinned, err := api.Pin().IsPinned(ctx, p); err != nil || pinned {
+		t.Fatalf("expected the pin to be removed (err: %v)", err)
+	}
+
+	removed, err := api.Pin().Removed(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || !removed[0].Path.RootCid().Equals(p.RootCid()) || !removed[0].Recursive || removed[0].Name != "important" {
+		t.Fatalf("unexpected pins pending removal %+v", removed)
+	}
+	if !removed[0].Expires.After(time.Now().Add(59 * time.Minute)) {
+		t.Fatalf("unexpected expiration %s", removed[0].Expires)
+	}
+
+	if err := api.Pin().Restore(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	how, pinned, err := api.Pin().IsPinned(ctx, p)
+	if err != nil || !pinned || how != "recursive" {
+		t.Fatalf("expected the pin to be restored, got %q (err: %v)", how, err)
+	}
+	if removed, err := api.Pin().Removed(ctx); err != nil || len(removed) != 0 {
+		t.Fatalf("expected no pin pending removal, got %+v (err: %v)", removed, err)
+	}
+}
+
+func (tp *TestSuite) TestPinProvideClass(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hot, err := api.Unixfs().Add(ctx, strFile("hot")(), opt.Unixfs.Pin(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cold, err := api.Unixfs().Add(ctx, strFile("cold")(), opt.Unixfs.Pin(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := api.Pin().Add(ctx, hot, opt.Pin.ProvideClass("lukewarm")); err == nil {
+		t.Fatal("expected an error for an invalid provide class")
+	}
+	if err := api.Pin().SetProvideClass(ctx, cold, "cold"); err == nil {
+		t.Fatal("expected an error setting the provide class of an object that isn't pinned")
+	}
+
+	if err := api.Pin().Add(ctx, hot, opt.Pin.ProvideClass("hot")); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Pin().Add(ctx, cold, opt.Pin.Recursive(false)); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Pin().SetProvideClass(ctx, cold, "cold"); err != nil {
+		t.Fatal(err)
+	}
+
+	classes, err := api.Pin().ProvideClasses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(classes) != 2 {
+		t.Fatalf("expected 2 pins with a provide class, got %+v", classes)
+	}
+	for _, c := range classes {
+		switch {
+		case c.Path.RootCid().Equals(hot.RootCid()):
+			if c.Class != "hot" || !c.Recursive {
+				t.Errorf("unexpected provide class of the hot pin %+v", c)
+			}
+		case c.Path.RootCid().Equals(cold.RootCid()):
+			if c.Class != "cold" || c.Recursive {
+				t.Errorf("unexpected provide class of the cold pin %+v", c)
+			}
+		default:
+			t.Errorf("unexpected pin %+v", c)
+		}
+	}
+
+	// removing a pin or clearing its class drops it
+	if err := api.Pin().Rm(ctx, hot); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Pin().SetProvideClass(ctx, cold, ""); err != nil {
+		t.Fatal(err)
+	}
+	if classes, err := api.Pin().ProvideClasses(ctx); err != nil || len(classes) != 0 {
+		t.Fatalf("expected no pin with a provide class, got %+v (err: %v)", classes, err)
+	}
+}
+
 func (tp *TestSuite) TestPinSimple(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -6,10 +6,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ipfs/boxo/files"
 	"github.com/ipfs/boxo/ipns"
 	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
 	iface "github.com/ipfs/kubo/core/coreiface"
 	"github.com/ipfs/kubo/core/coreiface/options"
+	mh "github.com/multiformats/go-multihash"
 	"github.com/stretchr/testify/require"
 )
 
@@ -27,6 +30,8 @@ func (tp *TestSuite) TestRouting(t *testing.T) {
 	t.Run("TestRoutingFindPeer", tp.TestRoutingFindPeer)
 	t.Run("TestRoutingFindProviders", tp.TestRoutingFindProviders)
 	t.Run("TestRoutingProvide", tp.TestRoutingProvide)
+	t.Run("TestRoutingCheckAvailability", tp.TestRoutingCheckAvailability)
+	t.Run("TestRoutingEstimateRetrieval", tp.TestRoutingEstimateRetrieval)
 }
 
 func (tp *TestSuite) testRoutingPublishKey(t *testing.T, ctx context.Context, api iface.CoreAPI, opts ...options.NamePublishOption) (path.Path, ipns.Name) {
@@ -244,3 +249,73 @@ func (tp *TestSuite) TestRoutingProvide(t *testing.T) {
 		t.Errorf("got wrong provider: %s != %s", provider.ID.String(), self0.ID().String())
 	}
 }
+
+func (tp *TestSuite) TestRoutingCheckAvailability(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	apis, err := tp.MakeAPISwarm(t, ctx, 5)
+	require.NoError(t, err)
+
+	p, err := apis[0].Unixfs().Add(ctx, files.NewReaderFile(&io.LimitedReader{R: rnd, N: 4092}))
+	require.NoError(t, err)
+	hash, err := mh.Sum([]byte("never provided"), mh.SHA2_256, -1)
+	require.NoError(t, err)
+	missing := cid.NewCidV1(cid.Raw, hash)
+
+	time.Sleep(3 * time.Second)
+
+	res, err := apis[2].Routing().CheckAvailability(ctx, []cid.Cid{p.RootCid(), missing},
+		options.Routing.Fetch(true),
+		options.Routing.Timeout(5*time.Second),
+	)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	require.Equal(t, p.RootCid(), res[0].Cid)
+	require.Equal(t, iface.AvailabilityRetrievable, res[0].Verdict, res[0].Error)
+	require.GreaterOrEqual(t, res[0].Providers, 1)
+	require.Equal(t, missing, res[1].Cid)
+	require.Equal(t, iface.AvailabilityUnavailable, res[1].Verdict)
+	require.Zero(t, res[1].Providers)
+
+	// the local node is not a provider
+	local, err := apis[1].Unixfs().Add(ctx, files.NewReaderFile(&io.LimitedReader{R: rnd, N: 4092}))
+	require.NoError(t, err)
+	time.Sleep(3 * time.Second)
+	res, err = apis[1].Routing().CheckAvailability(ctx, []cid.Cid{local.RootCid()}, options.Routing.Timeout(time.Second))
+	require.NoError(t, err)
+	require.Equal(t, iface.AvailabilityUnavailable, res[0].Verdict)
+}
+
+func (tp *TestSuite) TestRoutingEstimateRetrieval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	apis, err := tp.MakeAPISwarm(t, ctx, 5)
+	require.NoError(t, err)
+
+	const size = 1 << 20
+	p, err := apis[0].Unixfs().Add(ctx, files.NewReaderFile(&io.LimitedReader{R: rnd, N: size}))
+	require.NoError(t, err)
+	self, err := apis[0].Key().Self(ctx)
+	require.NoError(t, err)
+
+	time.Sleep(3 * time.Second)
+
+	est, err := apis[2].Routing().EstimateRetrieval(ctx, p,
+		options.Routing.SampleBlocks(2),
+		options.Routing.ProbeTimeout(5*time.Second),
+	)
+	require.NoError(t, err)
+	require.Equal(t, p.RootCid(), est.Cid)
+	require.GreaterOrEqual(t, est.Size, uint64(size))
+	require.GreaterOrEqual(t, est.Depth, 1)
+	require.Positive(t, est.SampleBytes)
+	require.Positive(t, est.Duration)
+
+	var found bool
+	for _, probe := range est.Providers {
+		if probe.ID == self.ID() {
+			found = true
+		}
+	}
+	require.True(t, found, "the node that added the file is a provider")
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/files"
+	iface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/stretchr/testify/require"
+)
+
+func (tp *TestSuite) TestFullTextSearch(t *testing.T) {
+	tp.hasApi(t, func(api iface.CoreAPI) error {
+		if api.Search() == nil {
+			return errAPINotImplemented
+		}
+		return nil
+	})
+
+	t.Run("TestSearchQuery", tp.TestSearchQuery)
+}
+
+func (tp *TestSuite) TestSearchQuery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	require.NoError(t, err)
+
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"recipe.md": files.NewBytesFile([]byte("# Apple pie\n\nPeel the apples, and bake for an hour.")),
+		"todo.txt":  files.NewBytesFile([]byte("buy flour")),
+	})
+	p, err := api.Unixfs().Add(ctx, dir, options.Unixfs.Pin(true))
+	require.NoError(t, err)
+
+	// the pins are indexed in the background
+	var hits []iface.SearchHit
+	require.Eventually(t, func() bool {
+		hits, err = api.Search().Query(ctx, `"bake for" appl*`, options.Search.Limit(10))
+		return err == nil && len(hits) > 0
+	}, 10*time.Second, 100*time.Millisecond)
+
+	require.Len(t, hits, 1)
+	h := hits[0]
+	require.Equal(t, "pin", h.Source)
+	require.Equal(t, p.String()+"/recipe.md", h.Path)
+	require.True(t, h.Root.Equals(p.RootCid()))
+	require.Equal(t, "Apple pie", h.Title)
+	require.Equal(t, "text/markdown", h.Format)
+	require.Contains(t, h.Snippet, "bake for an hour")
+
+	hits, err = api.Search().Query(ctx, "flour -buy")
+	require.NoError(t, err)
+	require.Empty(t, hits)
+}
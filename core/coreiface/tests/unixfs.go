@@ -1,26 +1,34 @@
 package tests
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"math"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/ipfs/boxo/path"
 	coreiface "github.com/ipfs/kubo/core/coreiface"
 	"github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/core/localfiles"
 
 	"github.com/ipfs/boxo/files"
 	mdag "github.com/ipfs/boxo/ipld/merkledag"
 	"github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/ipld/unixfs/hamt"
 	"github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
 	"github.com/ipfs/go-cid"
 	cbor "github.com/ipfs/go-ipld-cbor"
@@ -39,16 +47,34 @@ func (tp *TestSuite) TestUnixfs(t *testing.T) {
 	t.Run("TestAdd", tp.TestAdd)
 	t.Run("TestAddPinned", tp.TestAddPinned)
 	t.Run("TestAddHashOnly", tp.TestAddHashOnly)
+	t.Run("TestAddDryRun", tp.TestAddDryRun)
+	t.Run("TestAddShape", tp.TestAddShape)
+	t.Run("TestAddCar", tp.TestAddCar)
+	t.Run("TestAddURL", tp.TestAddURL)
+	t.Run("TestAddIgnoreRules", tp.TestAddIgnoreRules)
+	t.Run("TestAddIncludeHidden", tp.TestAddIncludeHidden)
+	t.Run("TestAddDereferenceSymlinks", tp.TestAddDereferenceSymlinks)
+	t.Run("TestAddProgressEvents", tp.TestAddProgressEvents)
 	t.Run("TestGetEmptyFile", tp.TestGetEmptyFile)
 	t.Run("TestGetDir", tp.TestGetDir)
 	t.Run("TestGetNonUnixfs", tp.TestGetNonUnixfs)
+	t.Run("TestGetProgressive", tp.TestGetProgressive)
+	t.Run("TestGetRange", tp.TestGetRange)
+	t.Run("TestGetArchive", tp.TestGetArchive)
 	t.Run("TestLs", tp.TestLs)
+	t.Run("TestLsMimeType", tp.TestLsMimeType)
 	t.Run("TestEntriesExpired", tp.TestEntriesExpired)
 	t.Run("TestLsEmptyDir", tp.TestLsEmptyDir)
 	t.Run("TestLsNonUnixfs", tp.TestLsNonUnixfs)
 	t.Run("TestAddCloses", tp.TestAddCloses)
 	t.Run("TestGetSeek", tp.TestGetSeek)
 	t.Run("TestGetReadAt", tp.TestGetReadAt)
+	t.Run("TestSearch", tp.TestSearch)
+	t.Run("TestLsShards", tp.TestLsShards)
+	t.Run("TestLsPages", tp.TestLsPages)
+	t.Run("TestLsPreservedOrder", tp.TestLsPreservedOrder)
+	t.Run("TestFlush", tp.TestFlush)
+	t.Run("TestSymlink", tp.TestSymlink)
 }
 
 // `echo -n 'hello, world!' | ipfs add`
@@ -233,6 +259,45 @@ func (tp *TestSuite) TestAdd(t *testing.T) {
 			path: "/ipfs/QmNNhDGttafX3M1wKWixGre6PrLFGjnoPEDXjBYpTv93HP",
 			opts: []options.UnixfsAddOption{options.Unixfs.Chunker("size-4"), options.Unixfs.Layout(options.TrickleLayout)},
 		},
+		{
+			// smaller than the minimum buzhash chunk: a single block
+			name: "addBuzhash",
+			data: strFile(helloStr),
+			path: hello,
+			opts: []options.UnixfsAddOption{options.Unixfs.Chunker("buzhash")},
+		},
+		{
+			name: "addBadChunker",
+			data: strFile(helloStr),
+			err:  "unrecognized chunker option: buzz",
+			opts: []options.UnixfsAddOption{options.Unixfs.Chunker("buzz")},
+		},
+		{
+			// smaller than the minimum fastcdc chunk: a single block
+			name: "addFastCDC",
+			data: strFile(helloStr),
+			path: hello,
+			opts: []options.UnixfsAddOption{options.Unixfs.Chunker("fastcdc-1024-4096-16384")},
+		},
+		{
+			name: "addBadFastCDC",
+			data: strFile(helloStr),
+			err:  "fastcdc sizes must be ordered min < avg < max",
+			opts: []options.UnixfsAddOption{options.Unixfs.Chunker("fastcdc-4096-1024-16384")},
+		},
+		// DAG shape
+		{
+			name: "addBadMaxFileLinks",
+			data: strFile(helloStr),
+			err:  "max file links must be at least 2, got 1",
+			opts: []options.UnixfsAddOption{options.Unixfs.MaxFileLinks(1)},
+		},
+		{
+			name: "addBadHAMTFanout",
+			data: strFile(helloStr),
+			err:  "HAMT fanout must be a power of two between 8 and 1024, got 12",
+			opts: []options.UnixfsAddOption{options.Unixfs.HAMTFanout(12)},
+		},
 		// Local
 		{
 			name:    "addLocal", // better cases in sharness
@@ -276,6 +341,23 @@ func (tp *TestSuite) TestAdd(t *testing.T) {
 			wrap:   "foo",
 			expect: wrapped("foo"),
 		},
+		{
+			name:   "addWrapOption",
+			data:   strFile(helloStr),
+			path:   "/ipfs/QmVE9rNpj5doj7XHzp5zMUxD7BJgXEqx4pe3xZ3JBReWHE",
+			expect: wrapped("foo"),
+			events: []coreiface.AddEvent{
+				{Name: "foo", Path: p("QmQy2Dw4Wk7rdJKjThjYXzfFJNaRKRHhHP5gHHXroJMYxk"), Size: "21"},
+				{Name: "", Path: p("QmVE9rNpj5doj7XHzp5zMUxD7BJgXEqx4pe3xZ3JBReWHE"), Size: "70"},
+			},
+			opts: []options.UnixfsAddOption{options.Unixfs.Wrap(true), options.Unixfs.Name("foo")},
+		},
+		{
+			name: "addWrapOptionNoName",
+			data: strFile(helloStr),
+			err:  `wrapping in a directory requires the name of the entry, without slashes, got ""`,
+			opts: []options.UnixfsAddOption{options.Unixfs.Wrap(true)},
+		},
 		// hidden
 		{
 			name: "hiddenFilesAdded",
@@ -557,7 +639,7 @@ func (tp *TestSuite) TestAddPinned(t *testing.T) {
 	}
 }
 
-func (tp *TestSuite) TestAddHashOnly(t *testing.T) {
+func (tp *TestSuite) TestAddShape(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	api, err := tp.makeAPI(t, ctx)
@@ -565,25 +647,50 @@ func (tp *TestSuite) TestAddHashOnly(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	p, err := api.Unixfs().Add(ctx, strFile(helloStr)(), options.Unixfs.HashOnly(true))
+	p, err := api.Unixfs().Add(ctx, strFile(strings.Repeat("aoeuidhtns", 200))(),
+		options.Unixfs.Chunker("size-4"), options.Unixfs.MaxFileLinks(8))
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	if p.String() != hello {
-		t.Errorf("unxepected path: %s", p.String())
+	nd, err := api.Dag().Get(ctx, p.RootCid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(nd.Links()); n > 8 {
+		t.Errorf("expected at most 8 links, got %d", n)
 	}
 
-	_, err = api.Block().Get(ctx, p)
-	if err == nil {
-		t.Fatal("expected an error")
+	p, err = api.Unixfs().Add(ctx, twoLevelDir()(),
+		options.Unixfs.MaxDirectoryLinks(2), options.Unixfs.HAMTFanout(16))
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !ipld.IsNotFound(err) {
-		t.Errorf("unxepected error: %s", err.Error())
+	shards, err := api.Unixfs().LsShards(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shards[0].Fanout != 16 {
+		t.Errorf("expected a fanout of 16, got %d", shards[0].Fanout)
+	}
+	// the subdirectory has a single link, it isn't sharded
+	sub, err := path.Join(p, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.Unixfs().LsShards(ctx, sub); err == nil {
+		t.Error("expected abc not to be sharded")
+	}
+	f, err := api.Unixfs().Get(ctx, sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, ok := f.(files.Directory); !ok {
+		t.Fatal("expected abc to be a directory")
 	}
 }
 
-func (tp *TestSuite) TestGetEmptyFile(t *testing.T) {
+func (tp *TestSuite) TestAddURL(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	api, err := tp.makeAPI(t, ctx)
@@ -591,65 +698,100 @@ func (tp *TestSuite) TestGetEmptyFile(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = api.Unixfs().Add(ctx, files.NewBytesFile([]byte{}))
+	data := make([]byte, 600*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "data", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	if _, err := api.Unixfs().AddURL(ctx, "ftp://example.com/data"); err == nil {
+		t.Fatal("expected an error adding an ftp URL")
+	}
+
+	p, err := api.Unixfs().AddURL(ctx, srv.URL+"/data", options.Unixfs.CidVersion(1))
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	emptyFilePath, err := path.NewPath(emptyFile)
+	// the DAG is the one of a regular add with raw leaves
+	expected, err := api.Unixfs().Add(ctx, files.NewBytesFile(data), options.Unixfs.CidVersion(1), options.Unixfs.HashOnly(true))
 	if err != nil {
 		t.Fatal(err)
 	}
+	if !p.RootCid().Equals(expected.RootCid()) {
+		t.Fatalf("expected %s, got %s", expected.RootCid(), p.RootCid())
+	}
 
-	r, err := api.Unixfs().Get(ctx, emptyFilePath)
+	nd, err := api.Unixfs().Get(ctx, p)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	buf := make([]byte, 1) // non-zero so that Read() actually tries to read
-	n, err := io.ReadFull(r.(files.File), buf)
-	if err != nil && err != io.EOF {
-		t.Error(err)
+	got, err := io.ReadAll(files.ToFile(nd))
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !bytes.HasPrefix(buf, []byte{0x00}) {
-		t.Fatalf("expected empty data, got [%s] [read=%d]", buf, n)
+	if !bytes.Equal(got, data) {
+		t.Fatal("the content read through the urlstore differs")
 	}
 }
 
-func (tp *TestSuite) TestGetDir(t *testing.T) {
+func (tp *TestSuite) TestAddIgnoreRules(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	api, err := tp.makeAPI(t, ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
-	edir := unixfs.EmptyDirNode()
-	err = api.Dag().Add(ctx, edir)
-	if err != nil {
+
+	ignoreFile := t.TempDir() + "/.gitignore"
+	if err := os.WriteFile(ignoreFile, []byte("# build artifacts\nbuild\n"), 0o644); err != nil {
 		t.Fatal(err)
 	}
-	p := path.FromCid(edir.Cid())
 
-	emptyDir, err := api.Object().New(ctx, options.Object.Type("unixfs-dir"))
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"main.go": files.NewBytesFile([]byte("main")),
+		"main.o":  files.NewBytesFile([]byte("object")),
+		"node_modules": files.NewMapDirectory(map[string]files.Node{
+			"dep.js": files.NewBytesFile([]byte("dep")),
+		}),
+		"build": files.NewMapDirectory(map[string]files.Node{
+			"out": files.NewBytesFile([]byte("out")),
+		}),
+		"src": files.NewMapDirectory(map[string]files.Node{
+			"lib.go": files.NewBytesFile([]byte("lib")),
+			"lib.o":  files.NewBytesFile([]byte("object")),
+			// a file isn't matched by a rule for directories
+			"node_modules": files.NewBytesFile([]byte("file")),
+		}),
+	})
+	p, err := api.Unixfs().Add(ctx, dir,
+		options.Unixfs.IgnoreRules([]string{"node_modules/", "*.o"}),
+		options.Unixfs.IgnoreFile(ignoreFile))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if p.String() != path.FromCid(emptyDir.Cid()).String() {
-		t.Fatalf("expected path %s, got: %s", emptyDir.Cid(), p.String())
-	}
-
-	r, err := api.Unixfs().Get(ctx, path.FromCid(emptyDir.Cid()))
+	want, err := api.Unixfs().Add(ctx, files.NewMapDirectory(map[string]files.Node{
+		"main.go": files.NewBytesFile([]byte("main")),
+		"src": files.NewMapDirectory(map[string]files.Node{
+			"lib.go":       files.NewBytesFile([]byte("lib")),
+			"node_modules": files.NewBytesFile([]byte("file")),
+		}),
+	}))
 	if err != nil {
 		t.Fatal(err)
 	}
+	if p.String() != want.String() {
+		t.Fatalf("expected %s, got %s", want, p)
+	}
 
-	if _, ok := r.(files.Directory); !ok {
-		t.Fatalf("expected a directory")
+	_, err = api.Unixfs().Add(ctx, files.NewMapDirectory(nil), options.Unixfs.IgnoreFile(ignoreFile+".missing"))
+	if err == nil {
+		t.Fatal("expected a missing ignore file to fail the add")
 	}
 }
 
-func (tp *TestSuite) TestGetNonUnixfs(t *testing.T) {
+func (tp *TestSuite) TestAddIncludeHidden(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	api, err := tp.makeAPI(t, ctx)
@@ -657,19 +799,92 @@ func (tp *TestSuite) TestGetNonUnixfs(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	nd := new(mdag.ProtoNode)
-	err = api.Dag().Add(ctx, nd)
-	if err != nil {
-		t.Fatal(err)
+	root := t.TempDir()
+	for name, data := range map[string]string{
+		"visible":        "visible",
+		".hidden":        "hidden",
+		".config/config": "config",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	visible := func() files.Node {
+		return files.NewMapDirectory(map[string]files.Node{
+			"visible": files.NewBytesFile([]byte("visible")),
+		})
+	}
+	all := func() files.Node {
+		return files.NewMapDirectory(map[string]files.Node{
+			"visible": files.NewBytesFile([]byte("visible")),
+			".hidden": files.NewBytesFile([]byte("hidden")),
+			".config": files.NewMapDirectory(map[string]files.Node{
+				"config": files.NewBytesFile([]byte("config")),
+			}),
+		})
+	}
+	serial := func(includeHidden bool) files.Node {
+		st, err := os.Stat(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nd, err := localfiles.NewSerialFile(root, includeHidden, st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nd
+	}
+	// the directories of files.NewSerialFile don't tell their path: the
+	// hidden entries they leave out can't be included
+	boxo := func() files.Node {
+		st, err := os.Stat(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nd, err := files.NewSerialFile(root, false, st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nd
 	}
 
-	_, err = api.Unixfs().Get(ctx, path.FromCid(nd.Cid()))
-	if !strings.Contains(err.Error(), "proto: required field") {
-		t.Fatalf("expected protobuf error, got: %s", err)
+	for _, tc := range []struct {
+		name string
+		node files.Node
+		opts []options.UnixfsAddOption
+		want files.Node
+	}{
+		{name: "default", node: serial(false), want: visible()},
+		{name: "included", node: serial(false), opts: []options.UnixfsAddOption{options.Unixfs.IncludeHidden(true)}, want: all()},
+		{name: "excluded", node: serial(true), opts: []options.UnixfsAddOption{options.Unixfs.IncludeHidden(false)}, want: visible()},
+		{name: "includedBoxo", node: boxo(), opts: []options.UnixfsAddOption{options.Unixfs.IncludeHidden(true)}, want: visible()},
+		{name: "excludedMap", node: all(), opts: []options.UnixfsAddOption{options.Unixfs.IncludeHidden(false)}, want: visible()},
+		{name: "ignored", node: serial(false), opts: []options.UnixfsAddOption{options.Unixfs.IncludeHidden(true), options.Unixfs.IgnoreRules([]string{".config/"})},
+			want: files.NewMapDirectory(map[string]files.Node{
+				"visible": files.NewBytesFile([]byte("visible")),
+				".hidden": files.NewBytesFile([]byte("hidden")),
+			})},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := api.Unixfs().Add(ctx, tc.node, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := api.Unixfs().Add(ctx, tc.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.String() != want.String() {
+				t.Fatalf("expected %s, got %s", want, p)
+			}
+		})
 	}
 }
 
-func (tp *TestSuite) TestLs(t *testing.T) {
+func (tp *TestSuite) TestAddDereferenceSymlinks(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	api, err := tp.makeAPI(t, ctx)
@@ -677,59 +892,101 @@ func (tp *TestSuite) TestLs(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	r := strings.NewReader("content-of-file")
-	p, err := api.Unixfs().Add(ctx, files.NewMapDirectory(map[string]files.Node{
-		"name-of-file":    files.NewReaderFile(r),
-		"name-of-symlink": files.NewLinkFile("/foo/bar", nil),
-	}))
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	entries, err := api.Unixfs().Ls(ctx, p)
-	if err != nil {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0o755); err != nil {
 		t.Fatal(err)
 	}
-
-	entry := <-entries
-	if entry.Err != nil {
-		t.Fatal(entry.Err)
+	for name, data := range map[string]string{"file": "file", "sub/nested": "nested"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
 	}
-	if entry.Size != 15 {
-		t.Errorf("expected size = 15, got %d", entry.Size)
+	for name, target := range map[string]string{"link": "file", "sublink": "sub", "abslink": filepath.Join(root, "sub")} {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skipf("creating symlinks: %s", err)
+		}
 	}
-	if entry.Name != "name-of-file" {
-		t.Errorf("expected name = name-of-file, got %s", entry.Name)
+	serial := func(path string) files.Node {
+		st, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nd, err := localfiles.NewSerialFile(path, false, st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nd
 	}
-	if entry.Type != coreiface.TFile {
-		t.Errorf("wrong type %s", entry.Type)
+	sub := func() files.Node {
+		return files.NewMapDirectory(map[string]files.Node{
+			"nested": files.NewBytesFile([]byte("nested")),
+		})
 	}
-	if entry.Cid.String() != "QmX3qQVKxDGz3URVC3861Z3CKtQKGBn6ffXRBBWGMFz9Lr" {
-		t.Errorf("expected cid = QmX3qQVKxDGz3URVC3861Z3CKtQKGBn6ffXRBBWGMFz9Lr, got %s", entry.Cid)
+	dir := func(links map[string]files.Node) files.Node {
+		entries := map[string]files.Node{
+			"file": files.NewBytesFile([]byte("file")),
+			"sub":  sub(),
+		}
+		for name, nd := range links {
+			entries[name] = nd
+		}
+		return files.NewMapDirectory(entries)
 	}
-	entry = <-entries
-	if entry.Err != nil {
-		t.Fatal(entry.Err)
+
+	for _, tc := range []struct {
+		name   string
+		node   files.Node
+		policy options.SymlinkPolicy
+		want   files.Node
+	}{
+		{name: "never", node: serial(root), policy: options.DereferenceNever, want: dir(map[string]files.Node{
+			"link":    files.NewLinkFile("file", nil),
+			"sublink": files.NewLinkFile("sub", nil),
+			"abslink": files.NewLinkFile(filepath.Join(root, "sub"), nil),
+		})},
+		{name: "always", node: serial(root), policy: options.DereferenceAlways, want: dir(map[string]files.Node{
+			"link":    files.NewBytesFile([]byte("file")),
+			"sublink": sub(),
+			"abslink": sub(),
+		})},
+		{name: "topLevel", node: serial(filepath.Join(root, "abslink")), policy: options.DereferenceTopLevel, want: sub()},
+		{name: "topLevelDir", node: serial(root), policy: options.DereferenceTopLevel, want: dir(map[string]files.Node{
+			"link":    files.NewLinkFile("file", nil),
+			"sublink": files.NewLinkFile("sub", nil),
+			"abslink": files.NewLinkFile(filepath.Join(root, "sub"), nil),
+		})},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := api.Unixfs().Add(ctx, tc.node, options.Unixfs.DereferenceSymlinks(tc.policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := api.Unixfs().Add(ctx, tc.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.String() != want.String() {
+				t.Fatalf("expected %s, got %s", want, p)
+			}
+		})
 	}
-	if entry.Type != coreiface.TSymlink {
-		t.Errorf("wrong type %s", entry.Type)
+
+	// a symlink to a parent directory isn't followed forever
+	if err := os.Symlink(root, filepath.Join(root, "sub", "loop")); err != nil {
+		t.Fatal(err)
 	}
-	if entry.Name != "name-of-symlink" {
-		t.Errorf("expected name = name-of-symlink, got %s", entry.Name)
+	if _, err := api.Unixfs().Add(ctx, serial(root), options.Unixfs.DereferenceSymlinks(options.DereferenceAlways)); err == nil {
+		t.Fatal("expected the symlink cycle to fail the add")
 	}
-	if entry.Target != "/foo/bar" {
-		t.Errorf("expected symlink target to be /foo/bar, got %s", entry.Target)
+	if err := os.Symlink(filepath.Join(root, "missing"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
 	}
-
-	if l, ok := <-entries; ok {
-		t.Errorf("didn't expect a second link")
-		if l.Err != nil {
-			t.Error(l.Err)
-		}
+	if _, err := api.Unixfs().Add(ctx, serial(filepath.Join(root, "dangling")), options.Unixfs.DereferenceSymlinks(options.DereferenceTopLevel)); err == nil {
+		t.Fatal("expected the dangling symlink to fail the add")
 	}
 }
 
-func (tp *TestSuite) TestEntriesExpired(t *testing.T) {
+func (tp *TestSuite) TestAddProgressEvents(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	api, err := tp.makeAPI(t, ctx)
@@ -737,41 +994,58 @@ func (tp *TestSuite) TestEntriesExpired(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	r := strings.NewReader("content-of-file")
+	events := make(chan options.AddProgressEvent, 100)
 	p, err := api.Unixfs().Add(ctx, files.NewMapDirectory(map[string]files.Node{
-		"name-of-file": files.NewReaderFile(r),
-	}))
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	ctx, cancel = context.WithCancel(ctx)
-
-	nd, err := api.Unixfs().Get(ctx, p)
+		"a": files.NewBytesFile([]byte("aaaa")),
+		"b": files.NewBytesFile([]byte("bbbbbbbb")),
+	}), options.Unixfs.ProgressEvents(events))
 	if err != nil {
 		t.Fatal(err)
 	}
-	cancel()
+	close(events)
 
-	it := files.ToDir(nd).Entries()
-	if it == nil {
-		t.Fatal("it was nil")
+	var all []options.AddProgressEvent
+	for ev := range events {
+		all = append(all, ev)
 	}
-
-	if it.Next() {
-		t.Fatal("Next succeeded")
+	if len(all) == 0 {
+		t.Fatal("expected progress events")
 	}
-
-	if it.Err() != context.Canceled {
-		t.Fatalf("unexpected error %s", it.Err())
+	root := all[len(all)-1]
+	if root.Kind != options.RootEmitted || root.Path.String() != p.String() {
+		t.Fatalf("expected the last event to be RootEmitted for %s, got %s for %s", p, root.Kind, root.Path)
 	}
 
-	if it.Next() {
-		t.Fatal("Next succeeded")
+	// the events of each file are in order, the bytes written growing
+	for _, name := range []string{"a", "b"} {
+		var kinds []options.AddProgressKind
+		var bytes int64
+		for _, ev := range all {
+			if strings.TrimPrefix(ev.Name, "/") != name {
+				continue
+			}
+			if ev.Kind == options.ChunkWritten {
+				if ev.Bytes < bytes {
+					t.Fatalf("%s: the bytes written went from %d to %d", name, bytes, ev.Bytes)
+				}
+				bytes = ev.Bytes
+				if len(kinds) > 0 && kinds[len(kinds)-1] == options.ChunkWritten {
+					continue
+				}
+			}
+			if ev.Kind == options.FileCompleted && ev.Path.RootCid() == cid.Undef {
+				t.Fatalf("%s: FileCompleted without a path", name)
+			}
+			kinds = append(kinds, ev.Kind)
+		}
+		want := []options.AddProgressKind{options.FileStarted, options.ChunkWritten, options.FileCompleted}
+		if fmt.Sprint(kinds) != fmt.Sprint(want) {
+			t.Fatalf("%s: expected the events %v, got %v", name, want, kinds)
+		}
 	}
 }
 
-func (tp *TestSuite) TestLsEmptyDir(t *testing.T) {
+func (tp *TestSuite) TestAddCar(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	api, err := tp.makeAPI(t, ctx)
@@ -779,47 +1053,612 @@ func (tp *TestSuite) TestLsEmptyDir(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = api.Unixfs().Add(ctx, files.NewSliceDirectory([]files.DirEntry{}))
-	if err != nil {
+	file := mdag.NodeWithData(unixfs.FilePBData([]byte(helloStr), uint64(len(helloStr))))
+	dir := unixfs.EmptyDirNode()
+	if err := dir.AddNodeLink("hello.txt", file); err != nil {
 		t.Fatal(err)
 	}
+	blks := map[cid.Cid][]byte{dir.Cid(): dir.RawData(), file.Cid(): file.RawData()}
+	car := makeCar(t, []cid.Cid{dir.Cid()}, blks, []cid.Cid{dir.Cid(), file.Cid()})
 
-	emptyDir, err := api.Object().New(ctx, options.Object.Type("unixfs-dir"))
+	p, err := api.Unixfs().Add(ctx, files.NewBytesFile(car), options.Unixfs.Car(true), options.Unixfs.Pin(true))
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	links, err := api.Unixfs().Ls(ctx, path.FromCid(emptyDir.Cid()))
-	if err != nil {
-		t.Fatal(err)
+	if !p.RootCid().Equals(dir.Cid()) {
+		t.Fatalf("expected %s, got %s", dir.Cid(), p.RootCid())
 	}
-
-	if len(links) != 0 {
-		t.Fatalf("expected 0 links, got %d", len(links))
+	if _, pinned, err := api.Pin().IsPinned(ctx, p); err != nil || !pinned {
+		t.Fatalf("expected the root to be pinned: %v", err)
 	}
-}
-
-// TODO(lgierth) this should test properly, with len(links) > 0
-func (tp *TestSuite) TestLsNonUnixfs(t *testing.T) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	api, err := tp.makeAPI(t, ctx)
+	fp, err := path.Join(p, "hello.txt")
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	nd, err := cbor.WrapObject(map[string]interface{}{"foo": "bar"}, math.MaxUint64, -1)
+	nd, err := api.Unixfs().Get(ctx, fp)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	err = api.Dag().Add(ctx, nd)
+	data, err := io.ReadAll(files.ToFile(nd))
 	if err != nil {
 		t.Fatal(err)
 	}
+	if string(data) != helloStr {
+		t.Fatalf("expected %q, got %q", helloStr, data)
+	}
 
-	links, err := api.Unixfs().Ls(ctx, path.FromCid(nd.Cid()))
-	if err != nil {
+	// the DAG must be complete
+	missing := mdag.NodeWithData(unixfs.FilePBData([]byte("missing"), 7))
+	partialDir := unixfs.EmptyDirNode()
+	if err := partialDir.AddNodeLink("missing.txt", missing); err != nil {
+		t.Fatal(err)
+	}
+	partial := makeCar(t, []cid.Cid{partialDir.Cid()}, map[cid.Cid][]byte{partialDir.Cid(): partialDir.RawData()}, []cid.Cid{partialDir.Cid()})
+	if _, err := api.Unixfs().Add(ctx, files.NewBytesFile(partial), options.Unixfs.Car(true)); err == nil {
+		t.Fatal("expected an incomplete DAG to be refused")
+	}
+
+	// and made of UnixFS nodes
+	notUnixfs := mdag.NodeWithData([]byte("not unixfs"))
+	other := makeCar(t, []cid.Cid{notUnixfs.Cid()}, map[cid.Cid][]byte{notUnixfs.Cid(): notUnixfs.RawData()}, []cid.Cid{notUnixfs.Cid()})
+	if _, err := api.Unixfs().Add(ctx, files.NewBytesFile(other), options.Unixfs.Car(true)); err == nil {
+		t.Fatal("expected a DAG that isn't UnixFS to be refused")
+	}
+}
+
+func (tp *TestSuite) TestAddHashOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := api.Unixfs().Add(ctx, strFile(helloStr)(), options.Unixfs.HashOnly(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.String() != hello {
+		t.Errorf("unxepected path: %s", p.String())
+	}
+
+	_, err = api.Block().Get(ctx, p)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !ipld.IsNotFound(err) {
+		t.Errorf("unxepected error: %s", err.Error())
+	}
+}
+
+func (tp *TestSuite) TestAddDryRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	var stats options.DryRunStats
+	p, err := api.Unixfs().Add(ctx, files.NewBytesFile(data), options.Unixfs.DryRun(&stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// four leaves of 256KiB and their root
+	if stats.Blocks != 5 || stats.DataBytes != uint64(len(data)) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if stats.Overhead == 0 || stats.Bytes != stats.DataBytes+stats.Overhead {
+		t.Fatalf("unexpected overhead in %+v", stats)
+	}
+	if stats.LocalBlocks != 0 || stats.LocalBytes != 0 {
+		t.Fatalf("expected no local blocks, got %+v", stats)
+	}
+	if _, err := api.Block().Stat(ctx, p); err == nil {
+		t.Fatal("expected the dry run not to store the root")
+	}
+
+	added, err := api.Unixfs().Add(ctx, files.NewBytesFile(bytes.Clone(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !added.RootCid().Equals(p.RootCid()) {
+		t.Fatalf("expected %s, got %s", p.RootCid(), added.RootCid())
+	}
+
+	// the blocks are now stored locally
+	if _, err := api.Unixfs().Add(ctx, files.NewBytesFile(data), options.Unixfs.DryRun(&stats)); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Blocks != 5 || stats.LocalBlocks != 5 || stats.LocalBytes != stats.Bytes {
+		t.Fatalf("expected all the blocks to be local, got %+v", stats)
+	}
+}
+
+func (tp *TestSuite) TestGetEmptyFile(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = api.Unixfs().Add(ctx, files.NewBytesFile([]byte{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	emptyFilePath, err := path.NewPath(emptyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := api.Unixfs().Get(ctx, emptyFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1) // non-zero so that Read() actually tries to read
+	n, err := io.ReadFull(r.(files.File), buf)
+	if err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if !bytes.HasPrefix(buf, []byte{0x00}) {
+		t.Fatalf("expected empty data, got [%s] [read=%d]", buf, n)
+	}
+}
+
+func (tp *TestSuite) TestGetDir(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edir := unixfs.EmptyDirNode()
+	err = api.Dag().Add(ctx, edir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := path.FromCid(edir.Cid())
+
+	emptyDir, err := api.Object().New(ctx, options.Object.Type("unixfs-dir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.String() != path.FromCid(emptyDir.Cid()).String() {
+		t.Fatalf("expected path %s, got: %s", emptyDir.Cid(), p.String())
+	}
+
+	r, err := api.Unixfs().Get(ctx, path.FromCid(emptyDir.Cid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := r.(files.Directory); !ok {
+		t.Fatalf("expected a directory")
+	}
+}
+
+func (tp *TestSuite) TestGetProgressive(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// small chunks, so that the file has several levels
+	data := make([]byte, 500*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+	p, err := api.Unixfs().Add(ctx, files.NewBytesFile(data), options.Unixfs.Chunker("size-1024"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd, err := api.Unixfs().Get(ctx, p, options.Unixfs.Progressive(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, ok := nd.(files.File)
+	if !ok {
+		t.Fatal("expected a file")
+	}
+	defer f.Close()
+
+	got, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, got) {
+		t.Fatal("progressive read returned different data")
+	}
+
+	const off = 123457
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got, err = io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data[off:], got) {
+		t.Fatal("progressive read after seek returned different data")
+	}
+}
+
+func (tp *TestSuite) TestGetRange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 100*1024)
+	rand.New(rand.NewSource(2)).Read(data)
+	p, err := api.Unixfs().Add(ctx, files.NewBytesFile(data), options.Unixfs.Chunker("size-1024"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name           string
+		offset, length int64
+		want           []byte
+	}{
+		{name: "offset", offset: 5000, length: -1, want: data[5000:]},
+		{name: "length", offset: 0, length: 3000, want: data[:3000]},
+		{name: "range", offset: 4000, length: 10000, want: data[4000:14000]},
+		{name: "pastEnd", offset: 100*1024 - 10, length: 100, want: data[100*1024-10:]},
+		{name: "empty", offset: 200 * 1024, length: 10, want: []byte{}},
+	} {
+		for _, progressive := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/progressive=%t", tc.name, progressive), func(t *testing.T) {
+				opts := []options.UnixfsGetOption{options.Unixfs.GetOffset(tc.offset), options.Unixfs.Progressive(progressive)}
+				if tc.length >= 0 {
+					opts = append(opts, options.Unixfs.GetLength(tc.length))
+				}
+				nd, err := api.Unixfs().Get(ctx, p, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				f, ok := nd.(files.File)
+				if !ok {
+					t.Fatal("expected a file")
+				}
+				defer f.Close()
+
+				size, err := f.Size()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if size != int64(len(tc.want)) {
+					t.Fatalf("expected the size %d, got %d", len(tc.want), size)
+				}
+				got, err := io.ReadAll(f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(tc.want, got) {
+					t.Fatalf("expected %d bytes of the range, got %d different ones", len(tc.want), len(got))
+				}
+
+				// the seeks are within the range
+				if len(tc.want) > 10 {
+					if _, err := f.Seek(10, io.SeekStart); err != nil {
+						t.Fatal(err)
+					}
+					got, err = io.ReadAll(f)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(tc.want[10:], got) {
+						t.Fatal("read after seek returned different data")
+					}
+				}
+			})
+		}
+	}
+
+	dir, err := api.Unixfs().Add(ctx, files.NewMapDirectory(map[string]files.Node{"file": files.NewBytesFile(data)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.Unixfs().Get(ctx, dir, options.Unixfs.GetLength(10)); err != coreiface.ErrIsDir {
+		t.Fatalf("expected ErrIsDir for a range of a directory, got %v", err)
+	}
+}
+
+func (tp *TestSuite) TestGetArchive(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := api.Unixfs().Add(ctx, files.NewMapDirectory(map[string]files.Node{
+		"file": files.NewBytesFile([]byte("file")),
+		"sub": files.NewMapDirectory(map[string]files.Node{
+			"nested": files.NewBytesFile([]byte("nested")),
+		}),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := p.RootCid().String()
+
+	for _, tc := range []struct {
+		name string
+		opts []options.UnixfsGetOption
+		gz   bool
+	}{
+		{name: "tar", opts: []options.UnixfsGetOption{options.Unixfs.Archive(true)}},
+		{name: "tar.gz", opts: []options.UnixfsGetOption{options.Unixfs.ArchiveCompression(gzip.BestSpeed)}, gz: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			nd, err := api.Unixfs().Get(ctx, p, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, ok := nd.(files.File)
+			if !ok {
+				t.Fatal("expected the archive to be a file")
+			}
+			defer f.Close()
+
+			var r io.Reader = f
+			if tc.gz {
+				if r, err = gzip.NewReader(f); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got := map[string]string{}
+			tr := tar.NewReader(r)
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := io.ReadAll(tr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got[hdr.Name] = string(data)
+			}
+			want := map[string]string{
+				root:                 "",
+				root + "/file":       "file",
+				root + "/sub":        "",
+				root + "/sub/nested": "nested",
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("expected the entries %v, got %v", want, got)
+			}
+		})
+	}
+
+	if _, err := api.Unixfs().Get(ctx, p, options.Unixfs.Archive(true), options.Unixfs.GetLength(10)); err == nil {
+		t.Fatal("expected a range of an archive to fail")
+	}
+}
+
+func (tp *TestSuite) TestGetNonUnixfs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd := new(mdag.ProtoNode)
+	err = api.Dag().Add(ctx, nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = api.Unixfs().Get(ctx, path.FromCid(nd.Cid()))
+	if !strings.Contains(err.Error(), "proto: required field") {
+		t.Fatalf("expected protobuf error, got: %s", err)
+	}
+}
+
+func (tp *TestSuite) TestLs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := strings.NewReader("content-of-file")
+	p, err := api.Unixfs().Add(ctx, files.NewMapDirectory(map[string]files.Node{
+		"name-of-file":    files.NewReaderFile(r),
+		"name-of-symlink": files.NewLinkFile("/foo/bar", nil),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := api.Unixfs().Ls(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := <-entries
+	if entry.Err != nil {
+		t.Fatal(entry.Err)
+	}
+	if entry.Size != 15 {
+		t.Errorf("expected size = 15, got %d", entry.Size)
+	}
+	if entry.Name != "name-of-file" {
+		t.Errorf("expected name = name-of-file, got %s", entry.Name)
+	}
+	if entry.Type != coreiface.TFile {
+		t.Errorf("wrong type %s", entry.Type)
+	}
+	if entry.Cid.String() != "QmX3qQVKxDGz3URVC3861Z3CKtQKGBn6ffXRBBWGMFz9Lr" {
+		t.Errorf("expected cid = QmX3qQVKxDGz3URVC3861Z3CKtQKGBn6ffXRBBWGMFz9Lr, got %s", entry.Cid)
+	}
+	entry = <-entries
+	if entry.Err != nil {
+		t.Fatal(entry.Err)
+	}
+	if entry.Type != coreiface.TSymlink {
+		t.Errorf("wrong type %s", entry.Type)
+	}
+	if entry.Name != "name-of-symlink" {
+		t.Errorf("expected name = name-of-symlink, got %s", entry.Name)
+	}
+	if entry.Target != "/foo/bar" {
+		t.Errorf("expected symlink target to be /foo/bar, got %s", entry.Target)
+	}
+
+	if l, ok := <-entries; ok {
+		t.Errorf("didn't expect a second link")
+		if l.Err != nil {
+			t.Error(l.Err)
+		}
+	}
+}
+
+func (tp *TestSuite) TestLsMimeType(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := api.Unixfs().Add(ctx, files.NewMapDirectory(map[string]files.Node{
+		"image": files.NewBytesFile([]byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR")),
+		"sub":   files.NewMapDirectory(map[string]files.Node{}),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := api.Unixfs().Ls(ctx, p, options.Unixfs.ResolveMimeType(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mimeTypes := map[string]string{}
+	for entry := range entries {
+		if entry.Err != nil {
+			t.Fatal(entry.Err)
+		}
+		mimeTypes[entry.Name] = entry.MimeType
+	}
+	if mimeTypes["image"] != "image/png" {
+		t.Errorf("expected image/png, got %q", mimeTypes["image"])
+	}
+	if mimeTypes["sub"] != "" {
+		t.Errorf("expected no MIME type for a directory, got %q", mimeTypes["sub"])
+	}
+}
+
+func (tp *TestSuite) TestEntriesExpired(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := strings.NewReader("content-of-file")
+	p, err := api.Unixfs().Add(ctx, files.NewMapDirectory(map[string]files.Node{
+		"name-of-file": files.NewReaderFile(r),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel = context.WithCancel(ctx)
+
+	nd, err := api.Unixfs().Get(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	it := files.ToDir(nd).Entries()
+	if it == nil {
+		t.Fatal("it was nil")
+	}
+
+	if it.Next() {
+		t.Fatal("Next succeeded")
+	}
+
+	if it.Err() != context.Canceled {
+		t.Fatalf("unexpected error %s", it.Err())
+	}
+
+	if it.Next() {
+		t.Fatal("Next succeeded")
+	}
+}
+
+func (tp *TestSuite) TestLsEmptyDir(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = api.Unixfs().Add(ctx, files.NewSliceDirectory([]files.DirEntry{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	emptyDir, err := api.Object().New(ctx, options.Object.Type("unixfs-dir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	links, err := api.Unixfs().Ls(ctx, path.FromCid(emptyDir.Cid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(links) != 0 {
+		t.Fatalf("expected 0 links, got %d", len(links))
+	}
+}
+
+// TODO(lgierth) this should test properly, with len(links) > 0
+func (tp *TestSuite) TestLsNonUnixfs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd, err := cbor.WrapObject(map[string]interface{}{"foo": "bar"}, math.MaxUint64, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = api.Dag().Add(ctx, nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	links, err := api.Unixfs().Ls(ctx, path.FromCid(nd.Cid()))
+	if err != nil {
 		t.Fatal(err)
 	}
 
@@ -1082,3 +1921,357 @@ func (tp *TestSuite) TestGetReadAt(t *testing.T) {
 	test(0, int(dataSize), dataSize, false)
 	test(dataSize-50, 100, 50, true)
 }
+
+func (tp *TestSuite) TestSearch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"beach.png": files.NewBytesFile([]byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR")),
+		"notes.txt": files.NewBytesFile([]byte("remember the sunscreen")),
+	})
+	p, err := api.Unixfs().Add(ctx, dir, options.Unixfs.Name("holidays"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := api.Unixfs().Search(ctx, "type:image added>1d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	r := results[0]
+	if r.Path != "holidays/beach.png" || r.Name != "beach.png" || r.MimeType != "image/png" || r.Type != coreiface.TFile || !r.Root.Equals(p.RootCid()) {
+		t.Fatalf("unexpected result %+v", r)
+	}
+
+	results, err = api.Unixfs().Search(ctx, "source:add", options.Unixfs.Limit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+
+	if _, err := api.Unixfs().Search(ctx, "color:blue"); err == nil {
+		t.Fatal("expected an error for an unknown field")
+	}
+}
+
+func (tp *TestSuite) TestLsShards(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := unixfs.EmptyFileNode()
+	if err := api.Dag().Add(ctx, file); err != nil {
+		t.Fatal(err)
+	}
+	shard, err := hamt.NewShard(api.Dag(), 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if err := shard.Set(ctx, fmt.Sprintf("file-%d", i), file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nd, err := shard.Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shards, err := api.Unixfs().LsShards(ctx, path.FromCid(nd.Cid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) < 2 || !shards[0].Cid.Equals(nd.Cid()) || shards[0].Depth != 0 || shards[0].Fanout != 256 {
+		t.Fatalf("unexpected root shard %+v", shards[0])
+	}
+	entries, children := 0, 0
+	for _, s := range shards {
+		if s.Buckets != s.Entries+s.Children {
+			t.Errorf("shard %s: %d buckets for %d entries and %d children", s.Cid, s.Buckets, s.Entries, s.Children)
+		}
+		if s.Depth > 0 && s.Size == 0 {
+			t.Errorf("shard %s: no size", s.Cid)
+		}
+		entries += s.Entries
+		children += s.Children
+	}
+	if entries != 1000 {
+		t.Errorf("expected 1000 entries, got %d", entries)
+	}
+	if children != len(shards)-1 {
+		t.Errorf("expected %d child shards, got %d", len(shards)-1, children)
+	}
+
+	dir, err := api.Unixfs().Add(ctx, files.NewMapDirectory(map[string]files.Node{
+		"file": files.NewBytesFile([]byte("not sharded")),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.Unixfs().LsShards(ctx, dir); err == nil {
+		t.Fatal("expected an error for a directory that is not sharded")
+	}
+}
+
+func (tp *TestSuite) TestFlush(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// MFS starts empty
+	p, err := api.Unixfs().Flush(ctx, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.RootCid().Equals(unixfs.EmptyDirNode().Cid()) {
+		t.Fatalf("unexpected root %s", p)
+	}
+	if _, err := api.Unixfs().Flush(ctx, "/missing"); err == nil {
+		t.Fatal("expected an error flushing a missing path")
+	}
+}
+
+func (tp *TestSuite) TestSymlink(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := api.Unixfs().Symlink(ctx, "../target", "/links/link"); err == nil {
+		t.Fatal("expected an error without the parent directory")
+	}
+	if err := api.Unixfs().Symlink(ctx, "../target", "/links/link", options.Unixfs.SymlinkParents(true)); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Unixfs().Symlink(ctx, "other", "/links/link"); err == nil {
+		t.Fatal("expected an error replacing an entry")
+	}
+
+	dir, err := api.Unixfs().Flush(ctx, "/links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := api.Unixfs().Ls(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var links []coreiface.DirEntry
+	for e := range entries {
+		if e.Err != nil {
+			t.Fatal(e.Err)
+		}
+		links = append(links, e)
+	}
+	if len(links) != 1 || links[0].Name != "link" || links[0].Type != coreiface.TSymlink || links[0].Target != "../target" {
+		t.Fatalf("unexpected entries %+v", links)
+	}
+}
+
+func (tp *TestSuite) TestLsPages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := unixfs.EmptyFileNode()
+	if err := api.Dag().Add(ctx, file); err != nil {
+		t.Fatal(err)
+	}
+	shard, err := hamt.NewShard(api.Dag(), 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if err := shard.Set(ctx, fmt.Sprintf("file-%d", i), file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nd, err := shard.Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := path.FromCid(nd.Cid())
+
+	ls := func(opts ...options.UnixfsLsOption) []coreiface.DirEntry {
+		t.Helper()
+		entries, err := api.Unixfs().Ls(ctx, p, append(opts, options.Unixfs.ResolveChildren(false))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []coreiface.DirEntry
+		for e := range entries {
+			if e.Err != nil {
+				t.Fatal(e.Err)
+			}
+			out = append(out, e)
+		}
+		return out
+	}
+
+	// the pages resumed with the cursor of their last entry list every
+	// entry once
+	var all []coreiface.DirEntry
+	cursor := ""
+	for {
+		page := ls(options.Unixfs.LsLimit(64), options.Unixfs.Cursor(cursor))
+		if len(page) > 64 {
+			t.Fatalf("expected at most 64 entries, got %d", len(page))
+		}
+		if len(page) == 0 {
+			break
+		}
+		all = append(all, page...)
+		cursor = page[len(page)-1].Cursor
+		if cursor == "" {
+			t.Fatal("expected a cursor")
+		}
+	}
+	seen := make(map[string]bool)
+	for _, e := range all {
+		if seen[e.Name] {
+			t.Fatalf("%s listed twice", e.Name)
+		}
+		seen[e.Name] = true
+	}
+	if len(seen) != 1000 {
+		t.Fatalf("expected 1000 entries, got %d", len(seen))
+	}
+
+	// the offset counts in the same order
+	page := ls(options.Unixfs.Offset(150), options.Unixfs.LsLimit(10))
+	if len(page) != 10 {
+		t.Fatalf("expected 10 entries, got %d", len(page))
+	}
+	for i, e := range page {
+		if e.Name != all[150+i].Name {
+			t.Fatalf("entry %d: expected %s, got %s", 150+i, all[150+i].Name, e.Name)
+		}
+	}
+	if page := ls(options.Unixfs.Cursor(all[999].Cursor)); len(page) != 0 {
+		t.Fatalf("expected no entry after the last one, got %d", len(page))
+	}
+
+	// basic directories are listed by name
+	dir, err := api.Unixfs().Add(ctx, files.NewMapDirectory(map[string]files.Node{
+		"a": files.NewBytesFile([]byte("a")),
+		"b": files.NewBytesFile([]byte("b")),
+		"c": files.NewBytesFile([]byte("c")),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = dir
+	first := ls(options.Unixfs.LsLimit(1))
+	if len(first) != 1 || first[0].Name != "a" {
+		t.Fatalf("unexpected first page %+v", first)
+	}
+	rest := ls(options.Unixfs.Cursor(first[0].Cursor))
+	if len(rest) != 2 || rest[0].Name != "b" || rest[1].Name != "c" {
+		t.Fatalf("unexpected second page %+v", rest)
+	}
+}
+
+func (tp *TestSuite) TestLsPreservedOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := func() files.Directory {
+		return files.NewSliceDirectory([]files.DirEntry{
+			files.FileEntry("c", files.NewBytesFile([]byte("c"))),
+			files.FileEntry("a", files.NewBytesFile([]byte("a"))),
+			files.FileEntry("b", files.NewSliceDirectory([]files.DirEntry{
+				files.FileEntry("z", files.NewBytesFile([]byte("z"))),
+				files.FileEntry("y", files.NewBytesFile([]byte("y"))),
+			})),
+		})
+	}
+
+	names := func(p path.Path, opts ...options.UnixfsLsOption) string {
+		t.Helper()
+		entries, err := api.Unixfs().Ls(ctx, p, append(opts, options.Unixfs.ResolveChildren(false))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for e := range entries {
+			if e.Err != nil {
+				t.Fatal(e.Err)
+			}
+			out = append(out, e.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	p, err := api.Unixfs().Add(ctx, dir(), options.Unixfs.PreserveOrder(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := path.Join(p, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(p, options.Unixfs.PreservedOrder(true)); got != "c,a,b" {
+		t.Fatalf("expected the added order, got %s", got)
+	}
+	if got := names(sub, options.Unixfs.PreservedOrder(true)); got != "z,y" {
+		t.Fatalf("expected the added order, got %s", got)
+	}
+	if got := names(p); got != "a,b,c" {
+		t.Fatalf("expected the entries by name, got %s", got)
+	}
+
+	// the pages follow the stored order
+	entries, err := api.Unixfs().Ls(ctx, p, options.Unixfs.PreservedOrder(true), options.Unixfs.LsLimit(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cursor string
+	for e := range entries {
+		if e.Err != nil {
+			t.Fatal(e.Err)
+		}
+		cursor = e.Cursor
+	}
+	if got := names(p, options.Unixfs.PreservedOrder(true), options.Unixfs.Cursor(cursor)); got != "a,b" {
+		t.Fatalf("expected the entries after c, got %s", got)
+	}
+
+	// the order of sharded directories isn't kept by MFS, so isn't stored
+	if _, err := api.Unixfs().Add(ctx, dir(), options.Unixfs.PreserveOrder(true), options.Unixfs.MaxDirectoryLinks(1)); err == nil {
+		t.Fatal("expected an error preserving the order of a sharded directory")
+	}
+
+	// directories added without the option don't store an order
+	p, err = api.Unixfs().Add(ctx, dir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(p, options.Unixfs.PreservedOrder(true)); got != "a,b,c" {
+		t.Fatalf("expected the entries by name, got %s", got)
+	}
+}
@@ -2,6 +2,8 @@ package iface
 
 import (
 	"context"
+	"os"
+	"time"
 
 	"github.com/ipfs/boxo/files"
 	"github.com/ipfs/boxo/path"
@@ -56,9 +58,55 @@ type DirEntry struct {
 	Type   FileType // The type of the file.
 	Target string   // The symlink target (if a symlink).
 
+	// Only filled when asked to resolve the MIME type of files.
+	MimeType string // The MIME type sniffed from the first block of the file.
+
+	// Only filled when listing with an offset, a limit or a cursor: passed to
+	// options.Unixfs.Cursor, it resumes the listing after this entry.
+	Cursor string
+
 	Err error
 }
 
+// SearchResult is a file or directory of the local content index returned
+// by `Search`.
+type SearchResult struct {
+	// Source is "add" for added content, or "mfs" for MFS content.
+	Source string
+	// Path is the MFS path, or the path under the name the content was added
+	// as.
+	Path string
+	Name string
+	Cid  cid.Cid
+	// Root is the CID the content was added as. It is undefined for MFS
+	// content.
+	Root     cid.Cid
+	Type     FileType
+	Size     uint64
+	MimeType string
+	Added    time.Time
+	// ModTime is the modification time of a file added from the local
+	// filesystem, or zero.
+	ModTime time.Time
+}
+
+// ShardInfo describes a shard of a HAMT sharded directory, see LsShards.
+type ShardInfo struct {
+	Cid cid.Cid
+	// Depth is 0 for the root shard of the directory.
+	Depth int
+	// Fanout is the number of buckets of the shard.
+	Fanout int
+	// Buckets is the number of occupied buckets.
+	Buckets int
+	// Entries is the number of directory entries stored in the shard, and
+	// Children the number of shards under it.
+	Entries  int
+	Children int
+	// Size is the size of the block of the shard.
+	Size int
+}
+
 // UnixfsAPI is the basic interface to immutable files in IPFS
 // NOTE: This API is heavily WIP, things are guaranteed to break frequently
 type UnixfsAPI interface {
@@ -67,13 +115,53 @@ type UnixfsAPI interface {
 	// TODO: a long useful comment on how to use this for many different scenarios
 	Add(context.Context, files.Node, ...options.UnixfsAddOption) (path.ImmutablePath, error)
 
+	// AddURL imports the content at an http or https URL with the urlstore,
+	// see Experimental.UrlstoreEnabled: the content is fetched to build the
+	// DAG, but only references to the URL are stored instead of the leaves,
+	// like NoCopy does for local files.
+	AddURL(ctx context.Context, url string, opts ...options.UnixfsAddOption) (path.ImmutablePath, error)
+
 	// Get returns a read-only handle to a file tree referenced by a path
 	//
 	// Note that some implementations of this API may apply the specified context
 	// to operations performed on the returned file
-	Get(context.Context, path.Path) (files.Node, error)
+	Get(context.Context, path.Path, ...options.UnixfsGetOption) (files.Node, error)
 
 	// Ls returns the list of links in a directory. Links aren't guaranteed to be
 	// returned in order
 	Ls(context.Context, path.Path, ...options.UnixfsLsOption) (<-chan DirEntry, error)
+
+	// Search queries the index of the local content, see
+	// Experimental.ContentIndex. Results are sorted by the time they were
+	// added, most recent first.
+	Search(ctx context.Context, query string, opts ...options.UnixfsSearchOption) ([]SearchResult, error)
+
+	// LsShards returns the shards of the HAMT sharded directory at the path,
+	// to debug its layout. The shards are listed depth first, starting with
+	// the root shard.
+	LsShards(context.Context, path.Path) ([]ShardInfo, error)
+
+	// Mv moves the MFS entry at src to dst, in a single operation. Like mv,
+	// dst may be an existing directory to move src into. The TTLs of the
+	// paths follow them.
+	Mv(ctx context.Context, src, dst string, opts ...options.UnixfsMvOption) error
+
+	// Chmod stores mode in the UnixFS 1.5 metadata of the MFS entry at path.
+	// Only the permission, setuid, setgid and sticky bits are stored.
+	Chmod(ctx context.Context, path string, mode os.FileMode, opts ...options.UnixfsMetaOption) error
+
+	// Touch stores a modification time in the UnixFS 1.5 metadata of the MFS
+	// entry at path, the current time unless options.Unixfs.ModTime is set.
+	Touch(ctx context.Context, path string, opts ...options.UnixfsMetaOption) error
+
+	// Symlink writes a UnixFS symlink to target at the MFS path linkPath. The
+	// target is stored as is, and doesn't have to exist. It fails when an
+	// entry is already at linkPath.
+	Symlink(ctx context.Context, target, linkPath string, opts ...options.UnixfsSymlinkOption) error
+
+	// Flush writes the MFS directory or file at mfsPath, "/" when empty, and
+	// its ancestors to the blockstore, like 'ipfs files flush', and returns
+	// the path of its flushed node. The changes batched by
+	// MFS.AutoFlushInterval or MFS.ManualFlush are all flushed first.
+	Flush(ctx context.Context, mfsPath string) (path.ImmutablePath, error)
 }
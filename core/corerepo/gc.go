@@ -6,13 +6,18 @@ import (
 	"errors"
 	"time"
 
+	"github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/pingrace"
+	"github.com/ipfs/kubo/core/webhooks"
 	"github.com/ipfs/kubo/gc"
 	"github.com/ipfs/kubo/repo"
 
 	"github.com/dustin/go-humanize"
+	bstore "github.com/ipfs/boxo/blockstore"
 	"github.com/ipfs/boxo/mfs"
 	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log"
 )
 
@@ -83,12 +88,78 @@ func BestEffortRoots(filesRoot *mfs.Root) ([]cid.Cid, error) {
 	return []cid.Cid{rootDag.Cid()}, nil
 }
 
-func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
+// gcRoots returns the best effort roots and the markers of a garbage
+// collection. The blocks referenced by MFS are marked from the index of MFS
+// references when the node keeps one. Otherwise the MFS root is a best effort
+// root, and the garbage collection reads the whole MFS tree.
+func gcRoots(n *core.IpfsNode) ([]cid.Cid, []gc.Marker, error) {
+	markers := []gc.Marker{markPendingRemovals(n), markWarmed(n)}
 	roots, err := BestEffortRoots(n.FilesRoot)
+	if err != nil || n.MFSRefs == nil {
+		return roots, markers, err
+	}
+	mark := func(ctx context.Context, _ ipld.NodeGetter, marked *cid.Set) error {
+		return n.MFSRefs.Mark(ctx, roots[0], marked)
+	}
+	return nil, append(markers, mark), nil
+}
+
+// markPendingRemovals returns the marker of the blocks of the pins removed
+// with a grace period that didn't expire.
+func markPendingRemovals(n *core.IpfsNode) gc.Marker {
+	return func(ctx context.Context, ng ipld.NodeGetter, marked *cid.Set) error {
+		removals, err := pingrace.List(ctx, n.Repo.Datastore(), n.Clock.Now())
+		if err != nil {
+			return err
+		}
+		var roots []cid.Cid
+		for _, r := range removals {
+			if r.Recursive {
+				roots = append(roots, r.Cid)
+			} else {
+				marked.Add(r.Cid)
+			}
+		}
+		return gc.MarkBestEffort(ctx, ng, roots, marked)
+	}
+}
+
+// markWarmed returns the marker of the DAGs protected by 'ipfs cache warm'
+// whose protection didn't expire.
+func markWarmed(n *core.IpfsNode) gc.Marker {
+	return func(ctx context.Context, ng ipld.NodeGetter, marked *cid.Set) error {
+		roots, err := n.CacheWarm.List(ctx, n.Clock.Now())
+		if err != nil {
+			return err
+		}
+		return gc.MarkBestEffort(ctx, ng, roots, marked)
+	}
+}
+
+// GCOptions returns the options of the sweep of the garbage collections of n,
+// from its config. The keys of the blockstore are listed one shard at a time
+// when they are stored in a sharded datastore like flatfs.
+func GCOptions(n *core.IpfsNode) (gc.Options, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return gc.Options{}, err
+	}
+	opts := gc.Options{
+		Concurrency: int(cfg.Datastore.GCConcurrency.WithDefault(config.DefaultGCConcurrency)),
+	}
+	// the keys of the filestore aren't in the datastore
+	if r, ok := n.Repo.(repo.ShardedRepo); ok && n.Filestore == nil {
+		opts.Shards = r.ShardedDatastore(bstore.BlockPrefix)
+	}
+	return opts, nil
+}
+
+func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
+	opts, err := GCOptions(n)
 	if err != nil {
 		return err
 	}
-	rmed := gc.GC(ctx, n.Blockstore, n.Repo.Datastore(), n.Pinning, roots)
+	rmed := GarbageCollectAsyncWithOptions(n, ctx, opts)
 
 	return CollectResult(ctx, rmed, nil)
 }
@@ -97,6 +168,12 @@ func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
 // given callback for each object removed.  It also collects all errors into a
 // MultiError which is returned after the gc is completed.
 func CollectResult(ctx context.Context, gcOut <-chan gc.Result, cb func(cid.Cid)) error {
+	return CollectResultWithProgress(ctx, gcOut, cb, nil)
+}
+
+// CollectResultWithProgress is CollectResult, also calling progress for each
+// shard swept when the garbage collection reports them.
+func CollectResultWithProgress(ctx context.Context, gcOut <-chan gc.Result, cb func(cid.Cid), progress func(*gc.ShardProgress)) error {
 	var errors []error
 loop:
 	for {
@@ -107,6 +184,8 @@ loop:
 			}
 			if res.Error != nil {
 				errors = append(errors, res.Error)
+			} else if res.Shard != nil && progress != nil {
+				progress(res.Shard)
 			} else if res.KeyRemoved.Defined() && cb != nil {
 				cb(res.KeyRemoved)
 			}
@@ -148,15 +227,64 @@ func (e *MultiError) Error() string {
 }
 
 func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) <-chan gc.Result {
-	roots, err := BestEffortRoots(n.FilesRoot)
+	opts, err := GCOptions(n)
+	if err != nil {
+		return gcError(err)
+	}
+	return GarbageCollectAsyncWithOptions(n, ctx, opts)
+}
+
+// GarbageCollectAsyncWithOptions is GarbageCollectAsync, with the sweep
+// configured by opts. The report of the garbage collection is stored in the
+// repo once it ends, see LastGCReport.
+func GarbageCollectAsyncWithOptions(n *core.IpfsNode, ctx context.Context, opts gc.Options) <-chan gc.Result {
+	roots, markers, err := gcRoots(n)
 	if err != nil {
-		out := make(chan gc.Result)
-		out <- gc.Result{Error: err}
-		close(out)
+		return gcError(err)
+	}
+
+	if opts.Stats == nil {
+		opts.Stats = &gc.Stats{}
+	}
+	out := gc.GCWithOptions(ctx, n.Blockstore, n.Repo.Datastore(), n.Pinning, roots, opts, markers...)
+	out = reportGC(ctx, n.Repo.Datastore(), opts.Stats, out)
+	if n.Webhooks == nil {
 		return out
 	}
+	return notifyGC(ctx, n.Webhooks, out)
+}
+
+// notifyGC forwards the results of a garbage collection, and fires the
+// webhooks.EventGCCompleted event once it ends.
+func notifyGC(ctx context.Context, w *webhooks.Notifier, in <-chan gc.Result) <-chan gc.Result {
+	start := time.Now()
+	out := make(chan gc.Result, cap(in))
+	go func() {
+		defer close(out)
+		var data webhooks.GCData
+		for res := range in {
+			if res.Error != nil {
+				data.Errors++
+			} else if res.KeyRemoved.Defined() {
+				data.Removed++
+			}
+			select {
+			case out <- res:
+			case <-ctx.Done():
+				// the garbage collection stops, and closes in
+			}
+		}
+		data.Duration = time.Since(start)
+		w.Notify(webhooks.EventGCCompleted, data)
+	}()
+	return out
+}
 
-	return gc.GC(ctx, n.Blockstore, n.Repo.Datastore(), n.Pinning, roots)
+func gcError(err error) <-chan gc.Result {
+	out := make(chan gc.Result, 1)
+	out <- gc.Result{Error: err}
+	close(out)
+	return out
 }
 
 func PeriodicGC(ctx context.Context, node *core.IpfsNode) error {
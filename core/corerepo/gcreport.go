@@ -0,0 +1,99 @@
+package corerepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/kubo/gc"
+)
+
+// GCReportKey is the key the report of the last garbage collection is stored
+// under.
+var GCReportKey = datastore.NewKey("/local/gcreport")
+
+// ErrNoGCReport is returned by LastGCReport before the first garbage
+// collection of the repo.
+var ErrNoGCReport = errors.New("no garbage collection report: the repo was never garbage collected")
+
+// maxGCReportErrors is the number of errors kept in a GCReport.
+const maxGCReportErrors = 100
+
+// GCReport is the report of a garbage collection, stored in the repo.
+type GCReport struct {
+	Started  time.Time
+	Duration time.Duration
+	// Removed is the number of blocks removed, and FreedBytes their size.
+	Removed    int
+	FreedBytes uint64
+	// Retained is the number of blocks kept, by the reason they were kept
+	// for: the gc.Marked* constants.
+	Retained map[string]int
+	// ErrorCount is the number of errors of the garbage collection, and
+	// Errors the first of them.
+	ErrorCount int
+	Errors     []string `json:",omitempty"`
+}
+
+// LastGCReport returns the report of the last garbage collection of the repo
+// whose datastore is ds, or ErrNoGCReport.
+func LastGCReport(ctx context.Context, ds datastore.Datastore) (*GCReport, error) {
+	buf, err := ds.Get(ctx, GCReportKey)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil, ErrNoGCReport
+		}
+		return nil, err
+	}
+	var report GCReport
+	if err := json.Unmarshal(buf, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// reportGC forwards the results of a garbage collection, and stores its
+// report in ds once it ends. stats must be the Stats of the options of the
+// garbage collection.
+func reportGC(ctx context.Context, ds datastore.Datastore, stats *gc.Stats, in <-chan gc.Result) <-chan gc.Result {
+	report := GCReport{Started: time.Now()}
+	out := make(chan gc.Result, cap(in))
+	addError := func(err error) {
+		report.ErrorCount++
+		if len(report.Errors) < maxGCReportErrors {
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+	go func() {
+		defer close(out)
+		for res := range in {
+			if res.Error != nil {
+				addError(res.Error)
+			}
+			select {
+			case out <- res:
+			case <-ctx.Done():
+				// the garbage collection stops, and closes in
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			addError(err)
+		}
+
+		report.Duration = time.Since(report.Started)
+		report.Removed = stats.Removed
+		report.FreedBytes = stats.RemovedBytes
+		report.Retained = stats.Marked
+		buf, err := json.Marshal(&report)
+		if err == nil {
+			// the report of a cancelled garbage collection is stored too
+			err = ds.Put(context.Background(), GCReportKey, buf)
+		}
+		if err != nil {
+			log.Errorf("storing the garbage collection report: %s", err)
+		}
+	}()
+	return out
+}
@@ -0,0 +1,44 @@
+package corerepo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/kubo/gc"
+)
+
+func TestGCReport(t *testing.T) {
+	ctx := context.Background()
+	ds := datastore.NewMapDatastore()
+
+	if _, err := LastGCReport(ctx, ds); err != ErrNoGCReport {
+		t.Fatalf("expected ErrNoGCReport, got %v", err)
+	}
+
+	stats := &gc.Stats{Marked: map[string]int{gc.MarkedRecursive: 3}}
+	in := make(chan gc.Result, 2)
+	in <- gc.Result{Error: errors.New("could not remove")}
+	in <- gc.Result{Error: gc.ErrCannotDeleteSomeBlocks}
+	out := reportGC(ctx, ds, stats, in)
+	// the garbage collection fills in its stats before closing its output
+	stats.Removed, stats.RemovedBytes = 2, 1024
+	close(in)
+	for range out {
+	}
+
+	report, err := LastGCReport(ctx, ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Removed != 2 || report.FreedBytes != 1024 || report.Retained[gc.MarkedRecursive] != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if report.ErrorCount != 2 || len(report.Errors) != 2 || report.Errors[0] != "could not remove" {
+		t.Fatalf("unexpected errors %+v", report)
+	}
+	if report.Started.IsZero() || report.Duration < 0 {
+		t.Fatalf("unexpected times %+v", report)
+	}
+}
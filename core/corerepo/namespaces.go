@@ -0,0 +1,194 @@
+package corerepo
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+
+	bserv "github.com/ipfs/boxo/blockservice"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/gc"
+)
+
+// NamespaceStat is the number and the total size of the datastore entries
+// under a key namespace.
+type NamespaceStat struct {
+	Namespace string
+	// Description tells what the namespace holds, when it is known.
+	Description string `json:",omitempty"`
+	Count       uint64
+	Size        uint64
+}
+
+// dhtNamespace groups the records stored by the DHT, whose keys are the
+// base32 of the record keys, at the root of the datastore.
+const dhtNamespace = "/<dht>"
+
+var namespaceDescriptions = map[string]string{
+	"/blocks":          "blocks",
+	"/pins":            "pins",
+	"/local/filesroot": "MFS root",
+	"/local/gcreport":  "last garbage collection report",
+	"/providers":       "provider records",
+	"/peers":           "peerstore",
+	"/ipns":            "IPNS records",
+	"/pk":              "public keys",
+	"/filestore":       "filestore references",
+	dhtNamespace:       "DHT records",
+}
+
+var dhtKeyRegexp = regexp.MustCompile(`^[A-Z2-7]+$`)
+
+// keyNamespace returns the namespace of k: its first segment, its first two
+// segments under /local, where each subsystem has its own namespace, or the
+// DHT records namespace.
+func keyNamespace(k datastore.Key) string {
+	segments := k.List()
+	switch {
+	case len(segments) == 0:
+		return "/"
+	case len(segments) == 1 && dhtKeyRegexp.MatchString(segments[0]):
+		return dhtNamespace
+	case len(segments) >= 2 && segments[0] == "local":
+		return "/local/" + segments[1]
+	default:
+		return "/" + segments[0]
+	}
+}
+
+// DatastoreNamespaces returns the number and the size of the entries of ds
+// by key namespace, largest first. Unlike RepoSize, it reads every key of
+// the datastore, which takes a while on large repos.
+func DatastoreNamespaces(ctx context.Context, ds datastore.Datastore) ([]NamespaceStat, error) {
+	res, err := ds.Query(ctx, query.Query{KeysOnly: true, ReturnsSizes: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	stats := make(map[string]*NamespaceStat)
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		k := datastore.RawKey(r.Key)
+		size := r.Size
+		if size < 0 {
+			// the datastore doesn't return the sizes with the keys
+			if size, err = ds.GetSize(ctx, k); err != nil {
+				if errors.Is(err, datastore.ErrNotFound) {
+					continue
+				}
+				return nil, err
+			}
+		}
+
+		ns := keyNamespace(k)
+		s, ok := stats[ns]
+		if !ok {
+			s = &NamespaceStat{Namespace: ns, Description: namespaceDescriptions[ns]}
+			stats[ns] = s
+		}
+		s.Count++
+		s.Size += uint64(size)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]NamespaceStat, 0, len(stats))
+	for _, s := range stats {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Size != out[j].Size {
+			return out[i].Size > out[j].Size
+		}
+		return strings.Compare(out[i].Namespace, out[j].Namespace) < 0
+	})
+	return out, nil
+}
+
+// BlockUsage splits the blocks of the repo between the live ones, which are
+// reachable from the pins and MFS and are kept by the garbage collector, and
+// the unreachable ones, which it removes.
+type BlockUsage struct {
+	Live            uint64
+	LiveSize        uint64
+	Unreachable     uint64
+	UnreachableSize uint64
+	// MissingLinks counts the pinned blocks whose links couldn't be read,
+	// whose descendants are counted as unreachable.
+	MissingLinks uint64
+}
+
+// BlocksUsage computes the BlockUsage of the repo of n by marking the live
+// blocks like the garbage collector, without its lock: the blocks added
+// meanwhile may be reported as unreachable.
+func BlocksUsage(ctx context.Context, n *core.IpfsNode) (BlockUsage, error) {
+	roots, markers, err := gcRoots(n)
+	if err != nil {
+		return BlockUsage{}, err
+	}
+	ds := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+
+	var usage BlockUsage
+	output := make(chan gc.Result)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for r := range output {
+			if _, ok := r.Error.(*gc.CannotFetchLinksError); ok {
+				usage.MissingLinks++
+			} else if r.Error != nil {
+				log.Warnf("marking the live blocks: %s", r.Error)
+			}
+		}
+	}()
+	live, err := gc.ColoredSet(ctx, n.Pinning, ds, roots, output)
+	close(output)
+	<-done
+	if err != nil {
+		return BlockUsage{}, err
+	}
+	for _, mark := range markers {
+		if err := mark(ctx, ds, live); err != nil {
+			return BlockUsage{}, err
+		}
+	}
+
+	// the blockstore keys are raw CIDs
+	rawLive := cid.NewSet()
+	if err := live.ForEach(func(c cid.Cid) error {
+		rawLive.Add(cid.NewCidV1(cid.Raw, c.Hash()))
+		return nil
+	}); err != nil {
+		return BlockUsage{}, err
+	}
+
+	keys, err := n.Blockstore.AllKeysChan(ctx)
+	if err != nil {
+		return BlockUsage{}, err
+	}
+	for c := range keys {
+		size, err := n.Blockstore.GetSize(ctx, c)
+		if err != nil {
+			continue
+		}
+		if rawLive.Has(cid.NewCidV1(cid.Raw, c.Hash())) {
+			usage.Live++
+			usage.LiveSize += uint64(size)
+		} else {
+			usage.Unreachable++
+			usage.UnreachableSize += uint64(size)
+		}
+	}
+	return usage, ctx.Err()
+}
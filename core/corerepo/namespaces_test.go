@@ -0,0 +1,49 @@
+package corerepo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+)
+
+func TestDatastoreNamespaces(t *testing.T) {
+	ctx := context.Background()
+	ds := datastore.NewMapDatastore()
+	for k, v := range map[string]string{
+		"/blocks/AFKREIA":              "large block",
+		"/blocks/AFKREIB":              "block",
+		"/pins/pin/abc":                "pin",
+		"/local/filesroot":             "root",
+		"/local/pinqueue/1":            "queued",
+		"/CIQGFTQ7FSI2COUXWWLOQ45VUM2": "record",
+	} {
+		if err := ds.Put(ctx, datastore.NewKey(k), []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := DatastoreNamespaces(ctx, ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]NamespaceStat)
+	for _, s := range stats {
+		got[s.Namespace] = s
+	}
+	if len(got) != 5 {
+		t.Fatalf("unexpected namespaces %+v", stats)
+	}
+	if stats[0].Namespace != "/blocks" || stats[0].Count != 2 || stats[0].Size != uint64(len("large block")+len("block")) {
+		t.Fatalf("expected the blocks first, got %+v", stats[0])
+	}
+	if s := got["/local/filesroot"]; s.Count != 1 || s.Description != "MFS root" {
+		t.Fatalf("unexpected MFS root stat %+v", s)
+	}
+	if s := got["/local/pinqueue"]; s.Count != 1 || s.Description != "" {
+		t.Fatalf("unexpected pin queue stat %+v", s)
+	}
+	if s := got[dhtNamespace]; s.Count != 1 || s.Size != uint64(len("record")) {
+		t.Fatalf("unexpected DHT stat %+v", s)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	context "context"
 
+	"github.com/ipfs/kubo/blocks/bloombs"
 	"github.com/ipfs/kubo/core"
 	fsrepo "github.com/ipfs/kubo/repo/fsrepo"
 
@@ -24,6 +25,9 @@ type Stat struct {
 	NumObjects uint64
 	RepoPath   string
 	Version    string
+	// BloomFilter is the status of the bloom filter of the blockstore, nil
+	// when Datastore.BloomFilterSize is 0
+	BloomFilter *bloombs.Status `json:",omitempty"`
 }
 
 // NoLimit represents the value for unlimited storage
@@ -51,7 +55,7 @@ func RepoStat(ctx context.Context, n *core.IpfsNode) (Stat, error) {
 		return Stat{}, err
 	}
 
-	return Stat{
+	stat := Stat{
 		SizeStat: SizeStat{
 			RepoSize:   sizeStat.RepoSize,
 			StorageMax: sizeStat.StorageMax,
@@ -59,7 +63,12 @@ func RepoStat(ctx context.Context, n *core.IpfsNode) (Stat, error) {
 		NumObjects: count,
 		RepoPath:   path,
 		Version:    fmt.Sprintf("fs-repo@%d", fsrepo.RepoVersion),
-	}, nil
+	}
+	if n.BloomFilter != nil {
+		status := n.BloomFilter.Status()
+		stat.BloomFilter = &status
+	}
+	return stat, nil
 }
 
 // RepoSize returns a *Stat object with the RepoSize and StorageMax fields set.
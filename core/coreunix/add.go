@@ -5,25 +5,32 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	gopath "path"
 	"strconv"
+	"time"
 
+	"github.com/benbjohnson/clock"
 	bstore "github.com/ipfs/boxo/blockstore"
-	chunker "github.com/ipfs/boxo/chunker"
 	"github.com/ipfs/boxo/files"
 	posinfo "github.com/ipfs/boxo/filestore/posinfo"
 	dag "github.com/ipfs/boxo/ipld/merkledag"
 	"github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/ipld/unixfs/hamt"
 	"github.com/ipfs/boxo/ipld/unixfs/importer/balanced"
 	ihelper "github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
 	"github.com/ipfs/boxo/ipld/unixfs/importer/trickle"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
 	"github.com/ipfs/boxo/mfs"
 	"github.com/ipfs/boxo/path"
 	pin "github.com/ipfs/boxo/pinning/pinner"
 	"github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log"
+	chunker "github.com/ipfs/kubo/core/chunker"
 	coreiface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/core/hashing"
 
 	"github.com/ipfs/kubo/tracing"
 )
@@ -67,7 +74,7 @@ type Adder struct {
 	pinning    pin.Pinner
 	gcLocker   bstore.GCLocker
 	dagService ipld.DAGService
-	bufferedDS *ipld.BufferedDAG
+	bufferedDS bufferedDAG
 	Out        chan<- interface{}
 	Progress   bool
 	Pin        bool
@@ -81,6 +88,60 @@ type Adder struct {
 	tempRoot   cid.Cid
 	CidBuilder cid.Builder
 	liveNodes  uint64
+
+	// Index, when set, is used to skip re-importing local files that have
+	// not changed since they were last added. Only directories containing
+	// new or modified files are reported.
+	Index   *AddIndex
+	changed map[string]struct{}
+
+	// ModTimes, when set, collects the modification times of the files
+	// added from the local filesystem, by path.
+	ModTimes map[string]time.Time
+
+	// ProgressEvents, when set, receives the typed events of the add, in
+	// addition to Out, see emit.
+	ProgressEvents chan<- options.AddProgressEvent
+	// Clock, when set, times the ProgressEvents instead of the wall clock.
+	Clock clock.Clock
+
+	// MemoryBudget, when set, caps the size of the blocks buffered in memory
+	// before they are written. The blocks over it are spilled to a temporary
+	// file in TempDir, see spillDAG.
+	MemoryBudget uint64
+	TempDir      string
+	stats        options.AddStats
+
+	// PreserveMode and PreserveMtime store the mode and the modification
+	// time of the files and directories added from the local filesystem in
+	// their UnixFS 1.5 metadata. FileMode and FileMtime, when set, are stored
+	// for all of them instead.
+	PreserveMode  bool
+	PreserveMtime bool
+	FileMode      os.FileMode
+	FileMtime     time.Time
+
+	// MaxFileLinks, when set, is the maximum number of links of the nodes
+	// of the files, instead of ihelper.DefaultLinksPerBlock.
+	MaxFileLinks int
+	// MaxDirectoryLinks and HAMTFanout, when set, shape the directories once
+	// added, see shapeDir.
+	MaxDirectoryLinks int
+	HAMTFanout        int
+
+	// Concurrency, when over 1, is the number of files of a directory
+	// chunked and hashed at once, see addEntries.
+	Concurrency int
+
+	// PreserveOrder stores the order of the entries of the directories,
+	// which their links are not in, see EntryOrder.
+	PreserveOrder bool
+	order         map[string][]string
+}
+
+// Stats returns the resources used by AddAllAndPin.
+func (adder *Adder) Stats() options.AddStats {
+	return adder.stats
 }
 
 func (adder *Adder) mfsRoot() (*mfs.Root, error) {
@@ -100,25 +161,32 @@ func (adder *Adder) mfsRoot() (*mfs.Root, error) {
 	return adder.mroot, nil
 }
 
-// SetMfsRoot sets `r` as the root for Adder.
+// SetMfsRoot sets `r` as the root for Adder. It is replaced by a root on the
+// DAG service of the adder when the added directory stores POSIX metadata.
 func (adder *Adder) SetMfsRoot(r *mfs.Root) {
 	adder.mroot = r
 }
 
-// Constructs a node from reader's data, and adds it. Doesn't pin.
-func (adder *Adder) add(reader io.Reader) (ipld.Node, error) {
+// Constructs a node from reader's data, and adds it to ds. Doesn't pin.
+func (adder *Adder) add(reader io.Reader, ds bufferedDAG) (ipld.Node, error) {
 	chnk, err := chunker.FromString(reader, adder.Chunker)
 	if err != nil {
 		return nil, err
 	}
+	if n := hashing.AddReadAhead(); n > 0 {
+		chnk = newReadAheadSplitter(adder.ctx, chnk, n)
+	}
 
 	params := ihelper.DagBuilderParams{
-		Dagserv:    adder.bufferedDS,
+		Dagserv:    ds,
 		RawLeaves:  adder.RawLeaves,
 		Maxlinks:   ihelper.DefaultLinksPerBlock,
 		NoCopy:     adder.NoCopy,
 		CidBuilder: adder.CidBuilder,
 	}
+	if adder.MaxFileLinks > 0 {
+		params.Maxlinks = adder.MaxFileLinks
+	}
 
 	db, err := params.New(chnk)
 	if err != nil {
@@ -134,7 +202,7 @@ func (adder *Adder) add(reader io.Reader) (ipld.Node, error) {
 		return nil, err
 	}
 
-	return nd, adder.bufferedDS.Commit()
+	return nd, ds.Commit()
 }
 
 // RootNode returns the mfs root node
@@ -194,47 +262,171 @@ func (adder *Adder) PinRoot(ctx context.Context, root ipld.Node) error {
 	return adder.pinning.Flush(ctx)
 }
 
-func (adder *Adder) outputDirs(path string, fsn mfs.FSNode) error {
+// outputDirs outputs the directories under fsn, and returns the node of fsn,
+// once shaped by shapeDir. The node of the files is only returned when the
+// directories are shaped.
+func (adder *Adder) outputDirs(path string, fsn mfs.FSNode) (ipld.Node, error) {
+	// storing the order of the entries rebuilds the directories too
+	shaped := adder.MaxDirectoryLinks > 0 || adder.HAMTFanout > 0 || adder.PreserveOrder
+
 	switch fsn := fsn.(type) {
 	case *mfs.File:
-		return nil
+		if !shaped {
+			return nil, nil
+		}
+		return fsn.GetNode()
 	case *mfs.Directory:
 		names, err := fsn.ListNames(adder.ctx)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
+		var links []*ipld.Link
 		for _, name := range names {
 			child, err := fsn.Child(name)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
 			childpath := gopath.Join(path, name)
-			err = adder.outputDirs(childpath, child)
+			cnd, err := adder.outputDirs(childpath, child)
 			if err != nil {
-				return err
+				return nil, err
+			}
+			if shaped {
+				lnk, err := ipld.MakeLink(cnd)
+				if err != nil {
+					return nil, err
+				}
+				lnk.Name = name
+				links = append(links, lnk)
 			}
 
 			fsn.Uncache(name)
 		}
 		nd, err := fsn.GetNode()
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if shaped {
+			if nd, err = adder.shapeDir(nd, links, adder.order[path]); err != nil {
+				return nil, err
+			}
 		}
 
-		return outputDagnode(adder.Out, path, nd)
+		if adder.Index != nil && path != "" {
+			if _, ok := adder.changed[path]; !ok {
+				return nd, nil
+			}
+		}
+
+		if path != "" {
+			// the root is reported by the caller, once done
+			if err := adder.emitCompleted(path, nd); err != nil {
+				return nil, err
+			}
+		}
+		return nd, outputDagnode(adder.Out, path, nd)
 	default:
-		return fmt.Errorf("unrecognized fsn type: %#v", fsn)
+		return nil, fmt.Errorf("unrecognized fsn type: %#v", fsn)
 	}
 }
 
+// shapeDir rebuilds the directory nd with links, the links of its entries
+// once shaped. The directories of more than MaxDirectoryLinks links are
+// sharded, the others are not. Without MaxDirectoryLinks, the directories
+// sharded by MFS stay sharded. The shards have HAMTFanout links. order, when
+// set, is stored in the data of the directory, see EntryOrder: it fails for
+// the sharded directories.
+func (adder *Adder) shapeDir(nd ipld.Node, links []*ipld.Link, order []string) (ipld.Node, error) {
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return nil, dag.ErrNotProtobuf
+	}
+	fsn, err := unixfs.FSNodeFromBytes(pn.Data())
+	if err != nil {
+		return nil, err
+	}
+	sharded := fsn.Type() == unixfs.THAMTShard
+	if adder.MaxDirectoryLinks > 0 {
+		sharded = len(links) > adder.MaxDirectoryLinks
+	}
+
+	if sharded && len(order) > 0 {
+		// MFS rebuilds the data of the shards it changes, losing the order
+		return nil, errShardedEntryOrder
+	}
+
+	var out ipld.Node
+	if sharded {
+		fanout := adder.HAMTFanout
+		if fanout == 0 {
+			fanout = uio.DefaultShardWidth
+		}
+		shard, err := hamt.NewShard(adder.dagService, fanout)
+		if err != nil {
+			return nil, err
+		}
+		shard.SetCidBuilder(adder.CidBuilder)
+		for _, l := range links {
+			if err := shard.SetLink(adder.ctx, l.Name, l); err != nil {
+				return nil, err
+			}
+		}
+		if out, err = shard.Node(); err != nil {
+			return nil, err
+		}
+	} else {
+		// the data of basic directories holds their metadata
+		data := pn.Data()
+		if fsn.Type() == unixfs.THAMTShard {
+			data = unixfs.FolderPBData()
+		}
+		if data, err = appendEntryOrder(data, order); err != nil {
+			return nil, err
+		}
+		dir := dag.NodeWithData(data)
+		if err := dir.SetCidBuilder(adder.CidBuilder); err != nil {
+			return nil, err
+		}
+		for _, l := range links {
+			if err := dir.AddRawLink(l.Name, l); err != nil {
+				return nil, err
+			}
+		}
+		out = dir
+	}
+
+	if out.Cid().Equals(nd.Cid()) {
+		return nd, nil
+	}
+	return out, adder.dagService.Add(adder.ctx, out)
+}
+
 func (adder *Adder) addNode(node ipld.Node, path string) error {
 	// patch it into the root
 	if path == "" {
 		path = node.Cid().String()
 	}
 
+	if err := adder.putNode(node, path); err != nil {
+		return err
+	}
+	if adder.Index != nil {
+		adder.markChanged(path)
+	}
+	if err := adder.emitCompleted(path, node); err != nil {
+		return err
+	}
+
+	if !adder.Silent {
+		return outputDagnode(adder.Out, path, node)
+	}
+	return nil
+}
+
+// putNode patches node into the mfs root at path.
+func (adder *Adder) putNode(node ipld.Node, path string) error {
 	if pi, ok := node.(*posinfo.FilestoreNode); ok {
 		node = pi.Node
 	}
@@ -255,14 +447,7 @@ func (adder *Adder) addNode(node ipld.Node, path string) error {
 		}
 	}
 
-	if err := mfs.PutNode(mr, path, node); err != nil {
-		return err
-	}
-
-	if !adder.Silent {
-		return outputDagnode(adder.Out, path, node)
-	}
-	return nil
+	return mfs.PutNode(mr, path, node)
 }
 
 // AddAllAndPin adds the given request's files and pin them.
@@ -279,6 +464,17 @@ func (adder *Adder) AddAllAndPin(ctx context.Context, file files.Node) (ipld.Nod
 		}
 	}()
 
+	if adder.MemoryBudget > 0 {
+		spill := newSpillDAG(adder.ctx, adder.dagService, adder.MemoryBudget, adder.TempDir)
+		adder.bufferedDS = spill
+		defer func() {
+			adder.stats = spill.Stats()
+			if err := spill.Close(); err != nil {
+				log.Errorf("removing the spill file of the import: %s", err)
+			}
+		}()
+	}
+
 	if err := adder.addFileNode(ctx, "", file, true); err != nil {
 		return nil, err
 	}
@@ -330,10 +526,13 @@ func (adder *Adder) AddAllAndPin(ctx context.Context, file files.Node) (ipld.Nod
 	}
 
 	// output directory events
-	err = adder.outputDirs(name, root)
+	shapedNd, err := adder.outputDirs(name, root)
 	if err != nil {
 		return nil, err
 	}
+	if shapedNd != nil {
+		nd = shapedNd
+	}
 
 	if asyncDagService, ok := adder.dagService.(syncer); ok {
 		err = asyncDagService.Sync()
@@ -359,6 +558,25 @@ func (adder *Adder) addFileNode(ctx context.Context, path string, file files.Nod
 		return err
 	}
 
+	if err := adder.countLiveNode(); err != nil {
+		return err
+	}
+
+	switch f := file.(type) {
+	case files.Directory:
+		return adder.addDir(ctx, path, f, toplevel)
+	case *files.Symlink:
+		return adder.addSymlink(path, f)
+	case files.File:
+		return adder.addFile(path, f, toplevel)
+	default:
+		return errors.New("unknown file type")
+	}
+}
+
+// countLiveNode counts a node added to the root, flushing the root from
+// memory once it holds too many.
+func (adder *Adder) countLiveNode() error {
 	if adder.liveNodes >= liveCacheSize {
 		// TODO: A smarter cache that uses some sort of lru cache with an eviction handler
 		mr, err := adder.mfsRoot()
@@ -372,17 +590,7 @@ func (adder *Adder) addFileNode(ctx context.Context, path string, file files.Nod
 		adder.liveNodes = 0
 	}
 	adder.liveNodes++
-
-	switch f := file.(type) {
-	case files.Directory:
-		return adder.addDir(ctx, path, f, toplevel)
-	case *files.Symlink:
-		return adder.addSymlink(path, f)
-	case files.File:
-		return adder.addFile(path, f)
-	default:
-		return errors.New("unknown file type")
-	}
+	return nil
 }
 
 func (adder *Adder) addSymlink(path string, l *files.Symlink) error {
@@ -404,32 +612,148 @@ func (adder *Adder) addSymlink(path string, l *files.Symlink) error {
 	return adder.addNode(dagnode, path)
 }
 
-func (adder *Adder) addFile(path string, file files.File) error {
+func (adder *Adder) addFile(path string, file files.File, toplevel bool) error {
+	fi := adder.prepareFile(path, file)
+	if fi.unchanged.Defined() {
+		return adder.addUnchanged(path, fi.unchanged, fi.st.Size(), toplevel)
+	}
+
+	dagnode, err := adder.importFile(fi, adder.bufferedDS)
+	if err != nil {
+		return err
+	}
+
+	// patch it into the root
+	return adder.finishFile(fi, dagnode)
+}
+
+// fileImport is a file being added. Adding a file is split in three steps so
+// that the files of a directory can be chunked and hashed concurrently, see
+// addEntries: prepareFile and finishFile run one file after the other,
+// importFile runs concurrently.
+type fileImport struct {
+	path    string
+	file    files.File
+	absPath string
+	st      os.FileInfo
+	indexed bool
+	// unchanged is the CID of the file when it didn't change since it was
+	// last added, see Adder.Index.
+	unchanged cid.Cid
+
+	nd   ipld.Node
+	err  error
+	done chan struct{}
+}
+
+// prepareFile looks file up in the index and records its modification time.
+func (adder *Adder) prepareFile(path string, file files.File) *fileImport {
+	fi := &fileImport{path: path, file: file}
+	local := false
+	if adder.Index != nil || adder.ModTimes != nil {
+		fi.absPath, fi.st, local = indexedStat(file)
+	}
+	if local && adder.ModTimes != nil {
+		adder.ModTimes[path] = fi.st.ModTime()
+	}
+	fi.indexed = local && adder.Index != nil
+	if fi.indexed {
+		if c, ok := adder.Index.Lookup(adder.ctx, adder.indexParams(), fi.absPath, fi.st); ok {
+			fi.unchanged = c
+		}
+	}
+	return fi
+}
+
+// importFile chunks and hashes the file into ds. It is safe for concurrent
+// use as long as ds is.
+func (adder *Adder) importFile(fi *fileImport, ds bufferedDAG) (ipld.Node, error) {
 	// if the progress flag was specified, wrap the file so that we can send
 	// progress updates to the client (over the output channel)
-	var reader io.Reader = file
-	if adder.Progress {
-		rdr := &progressReader{file: reader, path: path, out: adder.Out}
-		if fi, ok := file.(files.FileInfo); ok {
-			reader = &progressReader2{rdr, fi}
+	adder.emit(options.AddProgressEvent{Kind: options.FileStarted, Name: fi.path})
+	var reader io.Reader = fi.file
+	if adder.Progress || adder.ProgressEvents != nil {
+		rdr := &progressReader{file: reader, path: fi.path, events: adder.ProgressEvents, now: adder.now}
+		if adder.Progress {
+			rdr.out = adder.Out
+		}
+		if info, ok := fi.file.(files.FileInfo); ok {
+			reader = &progressReader2{rdr, info}
 		} else {
 			reader = rdr
 		}
 	}
 
-	dagnode, err := adder.add(reader)
+	dagnode, err := adder.add(reader, ds)
+	if err != nil {
+		return nil, err
+	}
+	return adder.withPosixMeta(dagnode, adder.posixMeta(fi.file))
+}
+
+// finishFile records the imported file in the index and patches it into the
+// root.
+func (adder *Adder) finishFile(fi *fileImport, dagnode ipld.Node) error {
+	if fi.indexed {
+		if err := adder.Index.Record(adder.ctx, adder.indexParams(), fi.absPath, fi.st, dagnode.Cid()); err != nil {
+			return err
+		}
+	}
+	return adder.addNode(dagnode, fi.path)
+}
+
+// addUnchanged patches a previously imported file into the root without
+// reading it. Unless it is the only thing being added, it isn't reported.
+func (adder *Adder) addUnchanged(path string, c cid.Cid, size int64, toplevel bool) error {
+	nd, err := adder.dagService.Get(adder.ctx, c)
 	if err != nil {
 		return err
 	}
 
-	// patch it into the root
-	return adder.addNode(dagnode, path)
+	if adder.Progress && adder.Out != nil {
+		adder.Out <- &coreiface.AddEvent{
+			Name:  path,
+			Bytes: size,
+		}
+	}
+
+	if toplevel {
+		return adder.addNode(nd, path)
+	}
+	return adder.putNode(nd, path)
+}
+
+// markChanged records that path and all of its parent directories contain
+// changes, see Adder.Index.
+func (adder *Adder) markChanged(path string) {
+	if adder.changed == nil {
+		adder.changed = make(map[string]struct{})
+	}
+	for ; path != "" && path != "." && path != "/"; path = gopath.Dir(path) {
+		adder.changed[path] = struct{}{}
+	}
 }
 
 func (adder *Adder) addDir(ctx context.Context, path string, dir files.Directory, toplevel bool) error {
 	log.Infof("adding directory: %s", path)
 
-	if !(toplevel && path == "") {
+	m := adder.posixMeta(dir)
+	if toplevel && path == "" {
+		// the root of the added directory stores its metadata
+		if !m.isZero() {
+			rnode, err := adder.posixMetaDirNode(m)
+			if err != nil {
+				return err
+			}
+			if adder.mroot, err = mfs.NewRoot(adder.ctx, adder.dagService, rnode, nil); err != nil {
+				return err
+			}
+		}
+	} else if !m.isZero() {
+		if err := adder.mkdirWithPosixMeta(path, m); err != nil {
+			return err
+		}
+	} else {
 		mr, err := adder.mfsRoot()
 		if err != nil {
 			return err
@@ -445,8 +769,12 @@ func (adder *Adder) addDir(ctx context.Context, path string, dir files.Directory
 	}
 
 	it := dir.Entries()
+	if adder.Concurrency > 1 {
+		return adder.addEntries(ctx, path, it)
+	}
 	for it.Next() {
 		fpath := gopath.Join(path, it.Name())
+		adder.recordEntry(path, it.Name())
 		err := adder.addFileNode(ctx, fpath, it.Node(), false)
 		if err != nil {
 			return err
@@ -497,6 +825,40 @@ func outputDagnode(out chan<- interface{}, name string, dn ipld.Node) error {
 	return nil
 }
 
+// emit sends ev to ProgressEvents, when set.
+func (adder *Adder) emit(ev options.AddProgressEvent) {
+	if adder.ProgressEvents == nil {
+		return
+	}
+	ev.Time = adder.now()
+	adder.ProgressEvents <- ev
+}
+
+func (adder *Adder) now() time.Time {
+	if adder.Clock != nil {
+		return adder.Clock.Now()
+	}
+	return time.Now()
+}
+
+// emitCompleted sends the FileCompleted event of the node added at name.
+func (adder *Adder) emitCompleted(name string, nd ipld.Node) error {
+	if adder.ProgressEvents == nil {
+		return nil
+	}
+	size, err := nd.Size()
+	if err != nil {
+		return err
+	}
+	adder.emit(options.AddProgressEvent{
+		Kind: options.FileCompleted,
+		Name: name,
+		Path: path.FromCid(nd.Cid()),
+		Size: size,
+	})
+	return nil
+}
+
 // from core/commands/object.go
 func getOutput(dagnode ipld.Node) (*coreiface.AddEvent, error) {
 	c := dagnode.Cid()
@@ -513,10 +875,14 @@ func getOutput(dagnode ipld.Node) (*coreiface.AddEvent, error) {
 	return output, nil
 }
 
+// progressReader reports the bytes of a file read to out and events, when
+// set.
 type progressReader struct {
 	file         io.Reader
 	path         string
 	out          chan<- interface{}
+	events       chan<- options.AddProgressEvent
+	now          func() time.Time
 	bytes        int64
 	lastProgress int64
 }
@@ -527,9 +893,19 @@ func (i *progressReader) Read(p []byte) (int, error) {
 	i.bytes += int64(n)
 	if i.bytes-i.lastProgress >= progressReaderIncrement || err == io.EOF {
 		i.lastProgress = i.bytes
-		i.out <- &coreiface.AddEvent{
-			Name:  i.path,
-			Bytes: i.bytes,
+		if i.out != nil {
+			i.out <- &coreiface.AddEvent{
+				Name:  i.path,
+				Bytes: i.bytes,
+			}
+		}
+		if i.events != nil {
+			i.events <- options.AddProgressEvent{
+				Kind:  options.ChunkWritten,
+				Name:  i.path,
+				Bytes: i.bytes,
+				Time:  i.now(),
+			}
 		}
 	}
 
@@ -90,7 +90,9 @@ func TestAddMultipleGCLive(t *testing.T) {
 	default:
 	}
 
-	// finish write and unblock gc
+	// finish write and unblock gc, once requested: the adder only pauses
+	// for a gc requested before it moves on to the next file
+	waitGCRequested(t, node.Blockstore)
 	pipew1.Close()
 
 	// Should have gotten the lock at this point
@@ -121,6 +123,7 @@ func TestAddMultipleGCLive(t *testing.T) {
 	default:
 	}
 
+	waitGCRequested(t, node.Blockstore)
 	pipew2.Close()
 
 	<-gc2started
@@ -139,6 +142,19 @@ func TestAddMultipleGCLive(t *testing.T) {
 	}
 }
 
+// waitGCRequested waits for a garbage collection started in the background to
+// request the lock of bs.
+func waitGCRequested(t *testing.T, bs blockstore.GCLocker) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !bs.GCRequested(context.Background()) {
+		if time.Now().After(deadline) {
+			t.Fatal("gc didn't request the lock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
 func TestAddGCLive(t *testing.T) {
 	r := &repo.Mock{
 		C: config.Config{
@@ -0,0 +1,133 @@
+package coreunix
+
+import (
+	"context"
+	gopath "path"
+
+	"github.com/ipfs/boxo/files"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// addEntries adds the entries of the directory path, chunking and hashing up
+// to Concurrency of its files at once. The files are still patched into the
+// root, and reported, in the order of the entries, so that neither the output
+// nor the directory depend on the order the imports finish in.
+//
+// Only the files read from the local filesystem are imported concurrently:
+// the entries of the directories sent over the HTTP API are read one after
+// the other from a single stream, so they are added in turn, like the
+// directories and the symlinks.
+func (adder *Adder) addEntries(ctx context.Context, path string, it files.DirIterator) error {
+	var pending []*fileImport
+	defer func() {
+		// the imports left are only waited for on errors
+		for _, fi := range pending {
+			<-fi.done
+		}
+	}()
+
+	// finish patches the first pending file into the root once imported
+	finish := func() error {
+		fi := pending[0]
+		pending = pending[1:]
+		<-fi.done
+		if fi.unchanged.Defined() {
+			return adder.addUnchanged(fi.path, fi.unchanged, fi.st.Size(), false)
+		}
+		if fi.err != nil {
+			return fi.err
+		}
+		return adder.finishFile(fi, fi.nd)
+	}
+	finishAll := func() error {
+		for len(pending) > 0 {
+			if err := finish(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for it.Next() {
+		fpath := gopath.Join(path, it.Name())
+		adder.recordEntry(path, it.Name())
+		file, ok := concurrentFile(it.Node())
+		if !ok {
+			if err := finishAll(); err != nil {
+				return err
+			}
+			if err := adder.addFileNode(ctx, fpath, it.Node(), false); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for len(pending) >= adder.Concurrency {
+			if err := finish(); err != nil {
+				file.Close()
+				return err
+			}
+		}
+		// the blocks of the pending files aren't in the root yet, so they
+		// would not be pinned while the GC runs
+		if adder.unlocker != nil && adder.gcLocker.GCRequested(ctx) {
+			if err := finishAll(); err != nil {
+				file.Close()
+				return err
+			}
+		}
+		if err := adder.maybePauseForGC(ctx); err != nil {
+			file.Close()
+			return err
+		}
+		if err := adder.countLiveNode(); err != nil {
+			file.Close()
+			return err
+		}
+
+		fi := adder.prepareFile(fpath, file)
+		fi.done = make(chan struct{})
+		pending = append(pending, fi)
+		if fi.unchanged.Defined() {
+			file.Close()
+			close(fi.done)
+			continue
+		}
+		go func() {
+			defer close(fi.done)
+			defer fi.file.Close()
+
+			// the buffered DAG of the adder isn't safe for concurrent use,
+			// unless it spills to disk
+			ds := adder.bufferedDS
+			if _, ok := ds.(*spillDAG); !ok {
+				ds = ipld.NewBufferedDAG(adder.ctx, adder.dagService)
+			}
+			fi.nd, fi.err = adder.importFile(fi, ds)
+		}()
+	}
+	if err := finishAll(); err != nil {
+		return err
+	}
+
+	return it.Err()
+}
+
+// concurrentFile returns nd when it is a regular file read from the local
+// filesystem by the adding process, which can be read while the other entries
+// of its directory are. The files sent over the HTTP API don't carry a stat.
+func concurrentFile(nd files.Node) (files.File, bool) {
+	f, ok := nd.(files.File)
+	if !ok {
+		return nil, false
+	}
+	fi, ok := f.(files.FileInfo)
+	if !ok || fi.AbsPath() == "" {
+		return nil, false
+	}
+	st := fi.Stat()
+	if st == nil || !st.Mode().IsRegular() {
+		return nil, false
+	}
+	return f, true
+}
@@ -0,0 +1,71 @@
+package coreunix
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/boxo/files"
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+)
+
+func TestAddConcurrency(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"", "a", "a/b"} {
+		if sub != "" {
+			if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 0; i < 50; i++ {
+			// files of different sizes, so that the imports finish out of
+			// order
+			data := strings.Repeat(fmt.Sprint(sub, i), (50-i)*1000)
+			if err := os.WriteFile(filepath.Join(dir, sub, fmt.Sprintf("f%02d", i)), []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := os.Symlink("f00", filepath.Join(dir, "a", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	add := func(concurrency int) (string, []string) {
+		t.Helper()
+		st, err := os.Stat(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := files.NewSerialFile(dir, false, st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adder, _ := newPosixAdder(t)
+		adder.Silent = false
+		adder.Concurrency = concurrency
+		out := make(chan interface{}, 1024)
+		adder.Out = out
+		root, err := adder.AddAllAndPin(context.Background(), f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		close(out)
+		var names []string
+		for ev := range out {
+			names = append(names, ev.(*coreiface.AddEvent).Name)
+		}
+		return root.Cid().String(), names
+	}
+
+	want, wantNames := add(0)
+	got, gotNames := add(8)
+	if got != want {
+		t.Fatalf("expected root %s, got %s", want, got)
+	}
+	if strings.Join(gotNames, ",") != strings.Join(wantNames, ",") {
+		t.Fatalf("expected the events %v, got %v", wantNames, gotNames)
+	}
+}
@@ -0,0 +1,157 @@
+package coreunix
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	bstore "github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/files"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+)
+
+// addIndexPrefix is the datastore namespace used by AddIndex.
+var addIndexPrefix = datastore.NewKey("/local/addindex")
+
+// AddIndex is a persistent record of files previously imported from the
+// local filesystem. It maps the absolute path of a file to the size and
+// modification time it had when it was imported, and to the resulting root
+// CID, so that re-adding an unchanged file can skip chunking and hashing
+// entirely.
+//
+// Entries are partitioned by import parameters: a file added with a different
+// chunker, layout or CID builder is never matched against a stale entry.
+type AddIndex struct {
+	ds datastore.Datastore
+	bs bstore.Blockstore
+}
+
+type addIndexEntry struct {
+	Size    int64
+	ModTime int64 // unix nanoseconds
+	Cid     string
+}
+
+// NewAddIndex returns an AddIndex persisted in ds. Entries are only trusted if
+// all the blocks of the DAG they point at are still present in bs.
+func NewAddIndex(ds datastore.Datastore, bs bstore.Blockstore) *AddIndex {
+	return &AddIndex{ds: ds, bs: bs}
+}
+
+func (idx *AddIndex) key(params, absPath string) datastore.Key {
+	return addIndexPrefix.ChildString(params).ChildString(base64.RawURLEncoding.EncodeToString([]byte(filepath.Clean(absPath))))
+}
+
+// Lookup returns the CID recorded for absPath if the file still has the given
+// size and modification time and its whole DAG is available locally.
+func (idx *AddIndex) Lookup(ctx context.Context, params, absPath string, st os.FileInfo) (cid.Cid, bool) {
+	b, err := idx.ds.Get(ctx, idx.key(params, absPath))
+	if err != nil {
+		if err != datastore.ErrNotFound {
+			log.Warnf("add index: reading entry for %s: %s", absPath, err)
+		}
+		return cid.Undef, false
+	}
+
+	var e addIndexEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		log.Warnf("add index: corrupt entry for %s: %s", absPath, err)
+		return cid.Undef, false
+	}
+	if e.Size != st.Size() || e.ModTime != st.ModTime().UnixNano() {
+		return cid.Undef, false
+	}
+
+	c, err := cid.Decode(e.Cid)
+	if err != nil {
+		return cid.Undef, false
+	}
+	if !idx.complete(ctx, c) {
+		return cid.Undef, false
+	}
+	return c, true
+}
+
+// complete tells whether all the blocks of the UnixFS DAG under root are
+// stored in bs. Only the inner nodes are read, the leaves are checked with
+// Has.
+func (idx *AddIndex) complete(ctx context.Context, root cid.Cid) bool {
+	seen := cid.NewSet()
+	queue := []cid.Cid{root}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if !seen.Visit(c) {
+			continue
+		}
+		if c.Type() != cid.DagProtobuf {
+			has, err := idx.bs.Has(ctx, c)
+			if err != nil || !has {
+				return false
+			}
+			continue
+		}
+		b, err := idx.bs.Get(ctx, c)
+		if err != nil {
+			return false
+		}
+		nd, err := dag.DecodeProtobuf(b.RawData())
+		if err != nil {
+			return false
+		}
+		for _, l := range nd.Links() {
+			queue = append(queue, l.Cid)
+		}
+	}
+	return true
+}
+
+// Record stores the CID a file was imported as.
+func (idx *AddIndex) Record(ctx context.Context, params, absPath string, st os.FileInfo, c cid.Cid) error {
+	b, err := json.Marshal(&addIndexEntry{
+		Size:    st.Size(),
+		ModTime: st.ModTime().UnixNano(),
+		Cid:     c.String(),
+	})
+	if err != nil {
+		return err
+	}
+	return idx.ds.Put(ctx, idx.key(params, absPath), b)
+}
+
+// indexParams returns a short fingerprint of the adder settings that affect
+// the resulting CID of a file.
+func (adder *Adder) indexParams() string {
+	params := fmt.Sprintf("%s|%t|%t|%t|%v|%t|%t|%o|%d", adder.Chunker, adder.RawLeaves, adder.Trickle, adder.NoCopy, adder.CidBuilder,
+		adder.PreserveMode, adder.PreserveMtime, adder.FileMode, adder.FileMtime.UnixNano())
+	if adder.MaxFileLinks != 0 {
+		// only when set, so that the files indexed before are still found
+		params += fmt.Sprintf("|%d", adder.MaxFileLinks)
+	}
+	h := sha256.Sum256([]byte(params))
+	return hex.EncodeToString(h[:8])
+}
+
+// indexedStat returns the absolute path and stat of a file being added, if
+// it is read from the local filesystem by this process. The files sent over
+// the HTTP API carry the path the client read them from, which is neither
+// trusted nor necessarily the path of the same file on this machine, and no
+// stat, so they are never indexed.
+func indexedStat(file files.File) (string, os.FileInfo, bool) {
+	fi, ok := file.(files.FileInfo)
+	if !ok || fi.AbsPath() == "" {
+		return "", nil, false
+	}
+	st := fi.Stat()
+	if st == nil || !st.Mode().IsRegular() {
+		return "", nil, false
+	}
+	return fi.AbsPath(), st, true
+}
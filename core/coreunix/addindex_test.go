@@ -0,0 +1,96 @@
+package coreunix
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/boxo/blockservice"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/files"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+)
+
+func TestIndexedStat(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(p, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	local, err := files.NewReaderPathFile(p, f, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if abs, _, ok := indexedStat(local); !ok || abs != p {
+		t.Fatalf("expected %s to be indexed, got %q, %t", p, abs, ok)
+	}
+
+	// a file sent over the HTTP API only carries the path of the client
+	remote, err := files.NewReaderPathFile(p, f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := indexedStat(remote); ok {
+		t.Fatal("expected a file without a stat not to be indexed")
+	}
+}
+
+func TestAddIndexIncompleteDAG(t *testing.T) {
+	ctx := context.Background()
+	d := syncds.MutexWrap(datastore.NewMapDatastore())
+	bs := blockstore.NewBlockstore(d)
+	dserv := dag.NewDAGService(blockservice.New(bs, nil))
+
+	p := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(p, make([]byte, 1<<20), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adder, err := NewAdder(ctx, nil, blockstore.NewGCLocker(), dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Pin = false
+	adder.Silent = true
+	adder.Index = NewAddIndex(d, bs)
+	f, err := files.NewSerialFile(p, false, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := adder.AddAllAndPin(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := adder.indexParams()
+	if c, ok := adder.Index.Lookup(ctx, params, p, st); !ok || !c.Equals(root.Cid()) {
+		t.Fatalf("expected %s to be found, got %s, %t", root.Cid(), c, ok)
+	}
+
+	// a file is imported again when a block of its DAG is missing
+	if len(root.Links()) == 0 {
+		t.Fatal("expected a file of several blocks")
+	}
+	if err := bs.DeleteBlock(ctx, root.Links()[0].Cid); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := adder.Index.Lookup(ctx, params, p, st); ok {
+		t.Fatal("expected an incomplete DAG not to be found")
+	}
+}
@@ -0,0 +1,294 @@
+package coreunix
+
+import (
+	"fmt"
+	"os"
+	gopath "path"
+	"time"
+
+	"github.com/ipfs/boxo/files"
+	posinfo "github.com/ipfs/boxo/filestore/posinfo"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/mfs"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The UnixFS 1.5 fields of the Data message, which the unixfs package of boxo
+// doesn't know about yet.
+const (
+	pbModeField  protowire.Number = 7
+	pbMtimeField protowire.Number = 8
+
+	pbMtimeSeconds protowire.Number = 1
+	pbMtimeNanos   protowire.Number = 2
+)
+
+// posixMeta is the UnixFS 1.5 metadata of a file or a directory. The zero
+// values aren't stored.
+type posixMeta struct {
+	mode  os.FileMode
+	mtime time.Time
+}
+
+func (m posixMeta) isZero() bool {
+	return m.mode == 0 && m.mtime.IsZero()
+}
+
+// posixMode returns the lower 12 bits of the POSIX mode of m, which is what
+// UnixFS stores.
+func (m posixMeta) posixMode() uint64 {
+	mode := uint64(m.mode.Perm())
+	if m.mode&os.ModeSetuid != 0 {
+		mode |= 0o4000
+	}
+	if m.mode&os.ModeSetgid != 0 {
+		mode |= 0o2000
+	}
+	if m.mode&os.ModeSticky != 0 {
+		mode |= 0o1000
+	}
+	return mode
+}
+
+// appendTo appends the metadata fields to data, the protobuf of a UnixFS
+// node. Protobuf fields can be in any order, so the message doesn't need to
+// be decoded.
+func (m posixMeta) appendTo(data []byte) []byte {
+	if m.mode != 0 {
+		data = protowire.AppendTag(data, pbModeField, protowire.VarintType)
+		data = protowire.AppendVarint(data, m.posixMode())
+	}
+	if !m.mtime.IsZero() {
+		var ts []byte
+		ts = protowire.AppendTag(ts, pbMtimeSeconds, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(m.mtime.Unix()))
+		if nsecs := m.mtime.Nanosecond(); nsecs != 0 {
+			ts = protowire.AppendTag(ts, pbMtimeNanos, protowire.Fixed32Type)
+			ts = protowire.AppendFixed32(ts, uint32(nsecs))
+		}
+		data = protowire.AppendTag(data, pbMtimeField, protowire.BytesType)
+		data = protowire.AppendBytes(data, ts)
+	}
+	return data
+}
+
+// posixMeta returns the metadata stored for the added file or directory f:
+// Adder.FileMode and Adder.FileMtime when set, or else, when preserved, the
+// mode and modification time of f on the local filesystem.
+func (adder *Adder) posixMeta(f files.Node) posixMeta {
+	m := posixMeta{mode: adder.FileMode, mtime: adder.FileMtime}
+	if (m.mode != 0 || !adder.PreserveMode) && (!m.mtime.IsZero() || !adder.PreserveMtime) {
+		return m
+	}
+
+	st := localStat(f)
+	if st == nil {
+		return m
+	}
+	if m.mode == 0 && adder.PreserveMode {
+		m.mode = st.Mode()
+	}
+	if m.mtime.IsZero() && adder.PreserveMtime {
+		m.mtime = st.ModTime()
+	}
+	return m
+}
+
+// localStat returns the stat of f on the local filesystem, or nil when f
+// doesn't come from it.
+func localStat(f files.Node) os.FileInfo {
+	if fi, ok := f.(files.FileInfo); ok && fi.AbsPath() != "" {
+		if st := fi.Stat(); st != nil {
+			return st
+		}
+		// files sent over the HTTP API don't carry a stat, see indexedStat
+		st, err := os.Stat(fi.AbsPath())
+		if err != nil {
+			return nil
+		}
+		return st
+	}
+	// the directories read by this process
+	if s, ok := f.(interface{ Stat() os.FileInfo }); ok {
+		return s.Stat()
+	}
+	return nil
+}
+
+// withPosixMeta returns the file nd with the metadata m stored in its root.
+// A file made of a single raw block can't store it, so it is wrapped into a
+// UnixFS file node.
+func (adder *Adder) withPosixMeta(nd ipld.Node, m posixMeta) (ipld.Node, error) {
+	if m.isZero() {
+		return nd, nil
+	}
+	file, err := posixMetaNode(nd)
+	if err != nil {
+		return nil, err
+	}
+	file.SetData(m.appendTo(file.Data()))
+	if err := file.SetCidBuilder(adder.CidBuilder); err != nil {
+		return nil, err
+	}
+	return file, adder.dagService.Add(adder.ctx, file)
+}
+
+// posixMetaNode returns a copy of the UnixFS node nd which can store
+// metadata. A raw block is wrapped into a UnixFS file node linking to it.
+func posixMetaNode(nd ipld.Node) (*dag.ProtoNode, error) {
+	if pi, ok := nd.(*posinfo.FilestoreNode); ok {
+		nd = pi.Node
+	}
+
+	switch n := nd.(type) {
+	case *dag.ProtoNode:
+		return n.Copy().(*dag.ProtoNode), nil
+	case *dag.RawNode:
+		fsn := unixfs.NewFSNode(unixfs.TFile)
+		fsn.AddBlockSize(uint64(len(n.RawData())))
+		data, err := fsn.GetBytes()
+		if err != nil {
+			return nil, err
+		}
+		file := dag.NodeWithData(data)
+		prefix := n.Cid().Prefix()
+		err = file.SetCidBuilder(cid.Prefix{
+			Version:  1,
+			Codec:    cid.DagProtobuf,
+			MhType:   prefix.MhType,
+			MhLength: -1,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := file.AddNodeLink("", n); err != nil {
+			return nil, err
+		}
+		return file, nil
+	default:
+		return nil, fmt.Errorf("cannot store metadata in a %T", nd)
+	}
+}
+
+// splitPosixMeta returns the metadata stored in data, the protobuf of a
+// UnixFS node, and data without it.
+func splitPosixMeta(data []byte) (posixMeta, []byte, error) {
+	var m posixMeta
+	rest := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		num, typ, tagLen := protowire.ConsumeTag(data)
+		if tagLen < 0 {
+			return posixMeta{}, nil, protowire.ParseError(tagLen)
+		}
+		valueLen := protowire.ConsumeFieldValue(num, typ, data[tagLen:])
+		if valueLen < 0 {
+			return posixMeta{}, nil, protowire.ParseError(valueLen)
+		}
+		field, value := data[:tagLen+valueLen], data[tagLen:tagLen+valueLen]
+		data = data[tagLen+valueLen:]
+
+		switch num {
+		case pbModeField:
+			mode, _ := protowire.ConsumeVarint(value)
+			m.mode = fileModeFromPosix(mode)
+		case pbMtimeField:
+			ts, _ := protowire.ConsumeBytes(value)
+			var secs int64
+			var nsecs uint32
+			for len(ts) > 0 {
+				num, typ, n := protowire.ConsumeTag(ts)
+				if n < 0 {
+					return posixMeta{}, nil, protowire.ParseError(n)
+				}
+				ts = ts[n:]
+				switch num {
+				case pbMtimeSeconds:
+					v, _ := protowire.ConsumeVarint(ts)
+					secs = int64(v)
+				case pbMtimeNanos:
+					nsecs, _ = protowire.ConsumeFixed32(ts)
+				}
+				n = protowire.ConsumeFieldValue(num, typ, ts)
+				if n < 0 {
+					return posixMeta{}, nil, protowire.ParseError(n)
+				}
+				ts = ts[n:]
+			}
+			m.mtime = time.Unix(secs, int64(nsecs))
+		default:
+			rest = append(rest, field...)
+		}
+	}
+	return m, rest, nil
+}
+
+// ModTime returns the modification time stored in the UnixFS 1.5 metadata of
+// nd. ok is false when nd doesn't store one.
+func ModTime(nd ipld.Node) (mtime time.Time, ok bool) {
+	if pi, isPos := nd.(*posinfo.FilestoreNode); isPos {
+		nd = pi.Node
+	}
+	pn, isPb := nd.(*dag.ProtoNode)
+	if !isPb {
+		return time.Time{}, false
+	}
+	m, _, err := splitPosixMeta(pn.Data())
+	if err != nil || m.mtime.IsZero() {
+		return time.Time{}, false
+	}
+	return m.mtime, true
+}
+
+// fileModeFromPosix is the reverse of posixMeta.posixMode.
+func fileModeFromPosix(mode uint64) os.FileMode {
+	m := os.FileMode(mode).Perm()
+	if mode&0o4000 != 0 {
+		m |= os.ModeSetuid
+	}
+	if mode&0o2000 != 0 {
+		m |= os.ModeSetgid
+	}
+	if mode&0o1000 != 0 {
+		m |= os.ModeSticky
+	}
+	return m
+}
+
+// posixMetaDirNode returns an empty directory node storing the metadata m.
+// The MFS directories keep the data of their node when children are added,
+// as long as they aren't sharded.
+func (adder *Adder) posixMetaDirNode(m posixMeta) (*dag.ProtoNode, error) {
+	nd := unixfs.EmptyDirNode()
+	nd.SetData(m.appendTo(nd.Data()))
+	if err := nd.SetCidBuilder(adder.CidBuilder); err != nil {
+		return nil, err
+	}
+	return nd, nil
+}
+
+// mkdirWithPosixMeta creates the directory path of the MFS root of the adder
+// storing the metadata m.
+func (adder *Adder) mkdirWithPosixMeta(path string, m posixMeta) error {
+	mr, err := adder.mfsRoot()
+	if err != nil {
+		return err
+	}
+	nd, err := adder.posixMetaDirNode(m)
+	if err != nil {
+		return err
+	}
+	if dir := gopath.Dir(path); dir != "." {
+		err := mfs.Mkdir(mr, dir, mfs.MkdirOpts{
+			Mkparents:  true,
+			Flush:      false,
+			CidBuilder: adder.CidBuilder,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return mfs.PutNode(mr, path, nd)
+}
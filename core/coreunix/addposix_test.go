@@ -0,0 +1,157 @@
+package coreunix
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/files"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	ipld "github.com/ipfs/go-ipld-format"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// readPosixMeta returns the UnixFS 1.5 mode and mtime of nd.
+func readPosixMeta(t *testing.T, nd ipld.Node) (uint64, time.Time) {
+	t.Helper()
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		t.Fatalf("expected a dag-pb node, got %T", nd)
+	}
+	// the node must still be valid for the unixfs package
+	if _, err := unixfs.FSNodeFromBytes(pn.Data()); err != nil {
+		t.Fatal(err)
+	}
+
+	var mode uint64
+	var mtime time.Time
+	data := pn.Data()
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		data = data[n:]
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		switch num {
+		case pbModeField:
+			mode, _ = protowire.ConsumeVarint(data)
+		case pbMtimeField:
+			ts, _ := protowire.ConsumeBytes(data)
+			var secs int64
+			var nsecs uint32
+			for len(ts) > 0 {
+				num, typ, m := protowire.ConsumeTag(ts)
+				ts = ts[m:]
+				switch num {
+				case pbMtimeSeconds:
+					v, _ := protowire.ConsumeVarint(ts)
+					secs = int64(v)
+				case pbMtimeNanos:
+					nsecs, _ = protowire.ConsumeFixed32(ts)
+				}
+				ts = ts[protowire.ConsumeFieldValue(num, typ, ts):]
+			}
+			mtime = time.Unix(secs, int64(nsecs))
+		}
+		data = data[n:]
+	}
+	return mode, mtime
+}
+
+func newPosixAdder(t *testing.T) (*Adder, ipld.DAGService) {
+	t.Helper()
+	ds := dagtest.Mock()
+	adder, err := NewAdder(context.Background(), nil, nil, ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Pin = false
+	adder.Silent = true
+	return adder, ds
+}
+
+func TestAddPreservePosixMeta(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(sub, "file")
+	if err := os.WriteFile(file, []byte("content"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Unix(1700000000, 5)
+	for _, p := range []string{file, sub} {
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := files.NewSerialFile(dir, false, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder, ds := newPosixAdder(t)
+	adder.PreserveMode = true
+	adder.PreserveMtime = true
+	root, err := adder.AddAllAndPin(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	subNode, _, err := root.ResolveLink([]string{"sub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subNd, err := subNode.GetNode(ctx, ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode, got := readPosixMeta(t, subNd); mode != 0o750 || !got.Equal(mtime) {
+		t.Fatalf("unexpected directory metadata %o, %s", mode, got)
+	}
+	fileLink, _, err := subNd.ResolveLink([]string{"file"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileNd, err := fileLink.GetNode(ctx, ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode, got := readPosixMeta(t, fileNd); mode != 0o640 || !got.Equal(mtime) {
+		t.Fatalf("unexpected file metadata %o, %s", mode, got)
+	}
+}
+
+func TestAddPosixMetaRawLeaf(t *testing.T) {
+	adder, ds := newPosixAdder(t)
+	adder.RawLeaves = true
+	adder.FileMode = 0o755 | os.ModeSetuid
+	adder.FileMtime = time.Unix(42, 0)
+
+	nd, err := adder.AddAllAndPin(context.Background(), files.NewBytesFile([]byte("small")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the raw leaf is wrapped to store the metadata
+	if mode, mtime := readPosixMeta(t, nd); mode != 0o4755 || mtime.Unix() != 42 {
+		t.Fatalf("unexpected metadata %o, %s", mode, mtime)
+	}
+	if len(nd.Links()) != 1 {
+		t.Fatalf("expected the raw leaf to be linked, got %d links", len(nd.Links()))
+	}
+	leaf, err := nd.Links()[0].GetNode(context.Background(), ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(leaf.RawData()) != "small" {
+		t.Fatalf("unexpected leaf %q", leaf.RawData())
+	}
+}
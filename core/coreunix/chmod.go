@@ -0,0 +1,145 @@
+package coreunix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	gopath "path"
+	"strings"
+	"time"
+
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/boxo/mfs"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// Chmod stores mode in the UnixFS metadata of the MFS entry at path, and of
+// every entry under it when recursive is true. A zero mode removes it.
+func Chmod(ctx context.Context, root *mfs.Root, dserv ipld.DAGService, path string, mode os.FileMode, recursive bool) error {
+	return updatePosixMeta(ctx, root, dserv, path, recursive, func(m *posixMeta) {
+		m.mode = mode
+	})
+}
+
+// Touch stores mtime as the modification time in the UnixFS metadata of the
+// MFS entry at path, and of every entry under it when recursive is true. A
+// zero mtime removes it.
+func Touch(ctx context.Context, root *mfs.Root, dserv ipld.DAGService, path string, mtime time.Time, recursive bool) error {
+	return updatePosixMeta(ctx, root, dserv, path, recursive, func(m *posixMeta) {
+		m.mtime = mtime
+	})
+}
+
+// updatePosixMeta replaces the MFS entry at path with a copy whose metadata
+// is changed by update. The files made of a single raw block are wrapped into
+// a UnixFS file node to store it.
+func updatePosixMeta(ctx context.Context, root *mfs.Root, dserv ipld.DAGService, path string, recursive bool, update func(*posixMeta)) error {
+	if !strings.HasPrefix(path, "/") {
+		return errors.New("MFS paths must start with '/'")
+	}
+	path = gopath.Clean(path)
+	if path == "/" {
+		// the root node is owned by mfs.Root, and can't be replaced
+		return errors.New("cannot change the metadata of the root directory")
+	}
+
+	dir, err := lookupDir(root, gopath.Dir(path))
+	if err != nil {
+		return err
+	}
+	name := gopath.Base(path)
+	fsn, err := dir.Child(name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	nd, err := fsn.GetNode()
+	if err != nil {
+		return err
+	}
+
+	nd, err = setPosixMeta(ctx, dserv, nd, recursive, update)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := dir.Unlink(name); err != nil {
+		return err
+	}
+	return dir.AddChild(name, nd)
+}
+
+// setPosixMeta returns a copy of nd whose metadata is changed by update, and
+// whose children are changed the same way when nd is a directory and
+// recursive is true. The new nodes are added to dserv.
+func setPosixMeta(ctx context.Context, dserv ipld.DAGService, nd ipld.Node, recursive bool, update func(*posixMeta)) (*dag.ProtoNode, error) {
+	pn, err := posixMetaNode(nd)
+	if err != nil {
+		return nil, err
+	}
+	fsn, err := unixfs.FSNodeFromBytes(pn.Data())
+	if err != nil {
+		return nil, err
+	}
+	m, _, err := splitPosixMeta(pn.Data())
+	if err != nil {
+		return nil, err
+	}
+
+	if recursive && fsn.IsDir() {
+		if pn, err = setChildrenPosixMeta(ctx, dserv, pn, update); err != nil {
+			return nil, err
+		}
+	}
+
+	// the data of a rebuilt shard doesn't hold the metadata anymore
+	_, data, err := splitPosixMeta(pn.Data())
+	if err != nil {
+		return nil, err
+	}
+	update(&m)
+	pn.SetData(m.appendTo(data))
+	return pn, dserv.Add(ctx, pn)
+}
+
+// setChildrenPosixMeta returns the directory dir with the metadata of all its
+// descendants changed by update.
+func setChildrenPosixMeta(ctx context.Context, dserv ipld.DAGService, dir *dag.ProtoNode, update func(*posixMeta)) (*dag.ProtoNode, error) {
+	d, err := uio.NewDirectoryFromNode(dserv, dir)
+	if err != nil {
+		return nil, err
+	}
+	var links []*ipld.Link
+	err = d.ForEachLink(ctx, func(l *ipld.Link) error {
+		links = append(links, l)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range links {
+		child, err := l.GetNode(ctx, dserv)
+		if err != nil {
+			return nil, err
+		}
+		changed, err := setPosixMeta(ctx, dserv, child, true, update)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", l.Name, err)
+		}
+		if err := d.AddChild(ctx, l.Name, changed); err != nil {
+			return nil, err
+		}
+	}
+
+	nd, err := d.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return nil, dag.ErrNotProtobuf
+	}
+	return pn, nil
+}
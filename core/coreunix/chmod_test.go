@@ -0,0 +1,99 @@
+package coreunix
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/mfs"
+)
+
+func TestChmodTouch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ds := dagtest.Mock()
+	root, err := mfs.NewRoot(ctx, ds, ft.EmptyDirNode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mkdir(root, "/a/b", mfs.MkdirOpts{Mkparents: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.PutNode(root, "/a/f", ft.EmptyFileNode()); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.PutNode(root, "/a/b/raw", dag.NewRawNode([]byte("raw"))); err != nil {
+		t.Fatal(err)
+	}
+	meta := func(p string) posixMeta {
+		t.Helper()
+		fsn, err := mfs.Lookup(root, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nd, err := fsn.GetNode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pn, ok := nd.(*dag.ProtoNode)
+		if !ok {
+			t.Fatalf("%s: expected a dag-pb node, got %T", p, nd)
+		}
+		m, _, err := splitPosixMeta(pn.Data())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	if err := Chmod(ctx, root, ds, "/a/f", 0o640, false); err != nil {
+		t.Fatal(err)
+	}
+	if m := meta("/a/f"); m.mode != 0o640 || !m.mtime.IsZero() {
+		t.Fatalf("unexpected metadata %+v", m)
+	}
+	if m := meta("/a"); m.mode != 0 {
+		t.Fatalf("the parent changed: %+v", m)
+	}
+
+	// the mode is kept, and replaced by later changes
+	mtime := time.Unix(1700000000, 5)
+	if err := Touch(ctx, root, ds, "/a", mtime, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := Chmod(ctx, root, ds, "/a", 0o755|os.ModeSticky, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/a", "/a/f", "/a/b", "/a/b/raw"} {
+		if m := meta(p); m.mode != 0o755|os.ModeSticky || !m.mtime.Equal(mtime) {
+			t.Fatalf("%s: unexpected metadata %+v", p, m)
+		}
+	}
+
+	// the raw file was wrapped
+	fsn, err := mfs.Lookup(root, "/a/b/raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := fsn.(*mfs.File).Open(mfs.Flags{Read: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	buf := make([]byte, 8)
+	if n, _ := f.Read(buf); string(buf[:n]) != "raw" {
+		t.Fatalf("unexpected content %q", buf[:n])
+	}
+
+	if err := Chmod(ctx, root, ds, "/", 0o755, false); err == nil {
+		t.Fatal("expected an error changing the root")
+	}
+	if err := Touch(ctx, root, ds, "/missing", mtime, false); err == nil {
+		t.Fatal("expected an error for a missing entry")
+	}
+}
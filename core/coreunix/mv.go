@@ -0,0 +1,120 @@
+package coreunix
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	gopath "path"
+	"strings"
+
+	"github.com/ipfs/boxo/mfs"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// MvDestination returns the path src is moved to by Mv: dst, or the path of
+// src in dst when dst is a directory or ends with a slash.
+func MvDestination(root *mfs.Root, src, dst string) string {
+	if strings.HasSuffix(dst, "/") {
+		return gopath.Join(dst, gopath.Base(src))
+	}
+	if fsn, err := mfs.Lookup(root, dst); err == nil && mfs.IsDir(fsn) {
+		return gopath.Join(dst, gopath.Base(src))
+	}
+	return gopath.Clean(dst)
+}
+
+// Mv moves the MFS entry at src to dst, like mv: dst may be a directory to
+// move src into. The missing parent directories of the destination are
+// created when parents is true, and an entry already at the destination is
+// replaced when overwrite is true. It returns the path src was moved to.
+func Mv(root *mfs.Root, src, dst string, parents, overwrite bool) (string, error) {
+	if !strings.HasPrefix(src, "/") || !strings.HasPrefix(dst, "/") {
+		return "", errors.New("MFS paths must start with '/'")
+	}
+	src = gopath.Clean(src)
+	if src == "/" {
+		return "", errors.New("cannot move the root directory")
+	}
+	target := MvDestination(root, src, dst)
+	if target == src {
+		return target, nil
+	}
+	if strings.HasPrefix(target, src+"/") {
+		return "", fmt.Errorf("cannot move %s into itself", src)
+	}
+
+	srcDir, err := lookupDir(root, gopath.Dir(src))
+	if err != nil {
+		return "", err
+	}
+	srcNode, err := srcDir.Child(gopath.Base(src))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", src, err)
+	}
+	nd, err := srcNode.GetNode()
+	if err != nil {
+		return "", err
+	}
+
+	dstDirPath := gopath.Dir(target)
+	if parents {
+		if _, err := mfs.Lookup(root, dstDirPath); errors.Is(err, os.ErrNotExist) {
+			if err := mfs.Mkdir(root, dstDirPath, mfs.MkdirOpts{Mkparents: true}); err != nil {
+				return "", err
+			}
+		}
+	}
+	dstDir, err := lookupDir(root, dstDirPath)
+	if err != nil {
+		return "", err
+	}
+
+	// the entry replaced at the destination, restored when the move fails
+	name := gopath.Base(target)
+	var replaced ipld.Node
+	if prev, err := dstDir.Child(name); err == nil {
+		if !overwrite {
+			return "", fmt.Errorf("%s already exists", target)
+		}
+		if replaced, err = prev.GetNode(); err != nil {
+			return "", err
+		}
+		if err := dstDir.Unlink(name); err != nil {
+			return "", err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	restore := func(err error) error {
+		if replaced != nil {
+			if rerr := dstDir.AddChild(name, replaced); rerr != nil {
+				return fmt.Errorf("%w, and restoring %s failed: %v", err, target, rerr)
+			}
+		}
+		return err
+	}
+
+	if err := dstDir.AddChild(name, nd); err != nil {
+		return "", restore(err)
+	}
+	if err := srcDir.Unlink(gopath.Base(src)); err != nil {
+		// don't leave the entry at both paths
+		if uerr := dstDir.Unlink(name); uerr != nil {
+			return "", fmt.Errorf("%w, and removing %s failed: %v", err, target, uerr)
+		}
+		return "", restore(err)
+	}
+	return target, nil
+}
+
+func lookupDir(root *mfs.Root, path string) (*mfs.Directory, error) {
+	fsn, err := mfs.Lookup(root, path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	dir, ok := fsn.(*mfs.Directory)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a directory", path)
+	}
+	return dir, nil
+}
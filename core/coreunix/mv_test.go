@@ -0,0 +1,70 @@
+package coreunix
+
+import (
+	"context"
+	"testing"
+
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/mfs"
+)
+
+func TestMv(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	root, err := mfs.NewRoot(ctx, dagtest.Mock(), ft.EmptyDirNode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"/a", "/b"} {
+		if err := mfs.Mkdir(root, dir, mfs.MkdirOpts{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mfs.PutNode(root, "/a/f", ft.EmptyFileNode()); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.PutNode(root, "/b/g", ft.EmptyFileNode()); err != nil {
+		t.Fatal(err)
+	}
+	exists := func(p string) bool {
+		_, err := mfs.Lookup(root, p)
+		return err == nil
+	}
+
+	// into an existing directory
+	moved, err := Mv(root, "/a/f", "/b", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != "/b/f" || !exists("/b/f") || exists("/a/f") {
+		t.Fatalf("unexpected move to %s", moved)
+	}
+
+	// the destination is kept unless overwritten
+	if _, err := Mv(root, "/b/f", "/b/g", false, false); err == nil {
+		t.Fatal("expected an error for an existing destination")
+	}
+	if _, err := Mv(root, "/b/f", "/b/g", false, true); err != nil {
+		t.Fatal(err)
+	}
+	if !exists("/b/g") || exists("/b/f") {
+		t.Fatal("expected /b/f to replace /b/g")
+	}
+
+	// the parents are only created when asked
+	if _, err := Mv(root, "/b/g", "/c/d/g", false, false); err == nil {
+		t.Fatal("expected an error for a missing parent")
+	}
+	if _, err := Mv(root, "/b/g", "/c/d/g", true, false); err != nil {
+		t.Fatal(err)
+	}
+	if !exists("/c/d/g") {
+		t.Fatal("expected /c/d/g")
+	}
+
+	if _, err := Mv(root, "/c", "/c/d/e", true, false); err == nil {
+		t.Fatal("expected an error moving a directory into itself")
+	}
+}
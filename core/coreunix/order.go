@@ -0,0 +1,99 @@
+package coreunix
+
+import (
+	"errors"
+	"fmt"
+
+	posinfo "github.com/ipfs/boxo/filestore/posinfo"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	ipld "github.com/ipfs/go-ipld-format"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// pbEntryOrderField is the field of the Data message of a directory storing
+// the names of its entries in the order they were added in, once per entry.
+// dag-pb sorts the links of the directories by name, so the order can't be
+// kept by the links themselves. It is well above the fields of the UnixFS
+// spec, and ignored by the implementations which don't know it.
+const pbEntryOrderField protowire.Number = 1001
+
+// maxEntryOrderSize bounds the size of the order stored in the data of a
+// directory, so that its block stays well below the block size limit even
+// when the directories aren't sharded by size.
+const maxEntryOrderSize = 256 << 10
+
+// errShardedEntryOrder is returned when the order of the entries of a
+// sharded directory would be stored: MFS rebuilds the data of the shards it
+// changes, which would drop the order, so it is only kept by the basic
+// directories.
+var errShardedEntryOrder = errors.New("the order of the entries of a sharded directory can't be preserved")
+
+// appendEntryOrder appends the names of the entries of a directory, in
+// order, to data, the protobuf of its UnixFS node. It fails when the order
+// takes more than maxEntryOrderSize bytes.
+func appendEntryOrder(data []byte, names []string) ([]byte, error) {
+	size := 0
+	for _, name := range names {
+		size += protowire.SizeTag(pbEntryOrderField) + protowire.SizeBytes(len(name))
+	}
+	if size > maxEntryOrderSize {
+		return nil, fmt.Errorf("the order of the %d entries of the directory takes %d bytes, more than the maximum of %d", len(names), size, maxEntryOrderSize)
+	}
+	for _, name := range names {
+		data = protowire.AppendTag(data, pbEntryOrderField, protowire.BytesType)
+		data = protowire.AppendString(data, name)
+	}
+	return data, nil
+}
+
+// EntryOrder returns the names of the entries of the directory nd in the
+// order they were added in, when the directory was added with the
+// PreserveOrder option. ok is false when nd doesn't store an order. MFS keeps
+// the order of the basic directories it changes: the entries it adds aren't
+// part of it, and are listed after the others. It is lost when MFS shards the
+// directory.
+func EntryOrder(nd ipld.Node) (names []string, ok bool) {
+	if pi, isPos := nd.(*posinfo.FilestoreNode); isPos {
+		nd = pi.Node
+	}
+	pn, isPb := nd.(*dag.ProtoNode)
+	if !isPb {
+		return nil, false
+	}
+
+	data := pn.Data()
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, false
+		}
+		data = data[n:]
+		if num == pbEntryOrderField && typ == protowire.BytesType {
+			name, m := protowire.ConsumeString(data)
+			if m < 0 {
+				return nil, false
+			}
+			names = append(names, name)
+			data = data[m:]
+			continue
+		}
+		m := protowire.ConsumeFieldValue(num, typ, data)
+		if m < 0 {
+			return nil, false
+		}
+		data = data[m:]
+	}
+	return names, names != nil
+}
+
+// recordEntry records that name is the next entry of the directory dir, when
+// the order of the entries is preserved.
+func (adder *Adder) recordEntry(dir, name string) {
+	if !adder.PreserveOrder {
+		return
+	}
+	if adder.order == nil {
+		adder.order = make(map[string][]string)
+	}
+	adder.order[dir] = append(adder.order[dir], name)
+}
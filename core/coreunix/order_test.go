@@ -0,0 +1,84 @@
+package coreunix
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/mfs"
+)
+
+func TestEntryOrderMFS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dserv := dagtest.Mock()
+
+	data, err := appendEntryOrder(ft.FolderPBData(), []string{"c", "a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := dag.NodeWithData(data)
+	for _, name := range []string{"a", "b", "c"} {
+		f := ft.EmptyFileNode()
+		if err := dserv.Add(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+		if err := dir.AddNodeLink(name, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dserv.Add(ctx, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := mfs.NewRoot(ctx, dserv, ft.EmptyDirNode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.PutNode(root, "/d", dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// the basic directories changed by MFS keep their order
+	if err := mfs.PutNode(root, "/d/e", ft.EmptyFileNode()); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mkdir(root, "/d/f", mfs.MkdirOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	d, err := mfs.Lookup(root, "/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.(*mfs.Directory).Unlink("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Mv(root, "/d/c", "/d/g", false, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := Chmod(ctx, root, dserv, "/d", 0o755, true); err != nil {
+		t.Fatal(err)
+	}
+
+	nd, err := d.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, ok := EntryOrder(nd)
+	if !ok || strings.Join(order, ",") != "c,a,b" {
+		t.Fatalf("expected the stored order to be kept, got %v", order)
+	}
+}
+
+func TestEntryOrderSize(t *testing.T) {
+	names := make([]string, maxEntryOrderSize/100)
+	for i := range names {
+		names[i] = strings.Repeat("n", 100)
+	}
+	if _, err := appendEntryOrder(nil, names); err == nil {
+		t.Fatal("expected an error for an order over the maximum size")
+	}
+}
@@ -0,0 +1,74 @@
+package coreunix
+
+import (
+	"context"
+	"io"
+
+	chunker "github.com/ipfs/kubo/core/chunker"
+)
+
+type readAheadChunk struct {
+	data []byte
+	err  error
+}
+
+// readAheadSplitter runs a Splitter in its own goroutine and keeps up to n
+// chunks ready, so that reading and chunking the input overlaps with building
+// the DAG of the chunks already produced. The chunks are still hashed one at
+// a time, by the DAG builder.
+type readAheadSplitter struct {
+	inner  chunker.Splitter
+	chunks chan readAheadChunk
+	err    error
+}
+
+func newReadAheadSplitter(ctx context.Context, inner chunker.Splitter, n int) *readAheadSplitter {
+	s := &readAheadSplitter{
+		inner:  inner,
+		chunks: make(chan readAheadChunk, n),
+	}
+	go s.run(ctx)
+	return s
+}
+
+func (s *readAheadSplitter) run(ctx context.Context) {
+	defer close(s.chunks)
+	for {
+		data, err := s.inner.NextBytes()
+		if data != nil {
+			// some splitters reuse their buffer between calls
+			data = append([]byte(nil), data...)
+		}
+		select {
+		case s.chunks <- readAheadChunk{data: data, err: err}:
+		case <-ctx.Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Reader returns the reader of the wrapped splitter. It must not be read from
+// directly.
+func (s *readAheadSplitter) Reader() io.Reader {
+	return s.inner.Reader()
+}
+
+// NextBytes returns the next chunk.
+func (s *readAheadSplitter) NextBytes() ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	c, ok := <-s.chunks
+	if !ok {
+		// only happens when the context was cancelled
+		s.err = context.Canceled
+		return nil, s.err
+	}
+	if c.err != nil {
+		s.err = c.err
+	}
+	return c.data, c.err
+}
@@ -0,0 +1,285 @@
+package coreunix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	posinfo "github.com/ipfs/boxo/filestore/posinfo"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/core/coreiface/options"
+)
+
+// bufferedDAG is a DAGService writing the added nodes in the background,
+// until they are committed.
+type bufferedDAG interface {
+	ipld.DAGService
+	Commit() error
+}
+
+type spilledNode struct {
+	c    cid.Cid
+	off  int64
+	size int
+}
+
+// spillDAG buffers the nodes added by the importer while they are written to
+// the DAG service in the background. At most budget bytes of blocks are kept
+// in memory: the nodes over the budget are spilled to a temporary file, and
+// read back once the writes caught up, so that importing huge files doesn't
+// run out of memory when the datastore is slower than the importer.
+//
+// Nodes referencing the filestore are never spilled, as their position in
+// the added file would be lost.
+type spillDAG struct {
+	ipld.DAGService
+	ctx    context.Context
+	budget uint64
+	dir    string
+
+	lk   sync.Mutex
+	cond *sync.Cond
+	// mem are the nodes waiting in memory, memSize the size of their blocks
+	// and of the blocks being written
+	mem     []ipld.Node
+	memSize uint64
+	spilled []spilledNode
+	file    *os.File
+	fileEnd int64
+	writing bool
+	closed  bool
+	err     error
+	stats   options.AddStats
+
+	done chan struct{}
+}
+
+// newSpillDAG returns a spillDAG writing to ds, spilling to a temporary file
+// in dir, or in the default directory for temporary files when dir is "".
+func newSpillDAG(ctx context.Context, ds ipld.DAGService, budget uint64, dir string) *spillDAG {
+	s := &spillDAG{
+		DAGService: ds,
+		ctx:        ctx,
+		budget:     budget,
+		dir:        dir,
+		done:       make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.lk)
+	go s.run()
+	return s
+}
+
+func (s *spillDAG) Add(ctx context.Context, nd ipld.Node) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+
+	size := uint64(len(nd.RawData()))
+	_, filestore := nd.(*posinfo.FilestoreNode)
+	if s.memSize > 0 && s.memSize+size > s.budget && !filestore {
+		if err := s.spill(nd); err != nil {
+			return err
+		}
+	} else {
+		s.mem = append(s.mem, nd)
+		s.memSize += size
+		if s.memSize > s.stats.PeakMemory {
+			s.stats.PeakMemory = s.memSize
+		}
+	}
+	s.cond.Broadcast()
+	return nil
+}
+
+func (s *spillDAG) AddMany(ctx context.Context, nds []ipld.Node) error {
+	for _, nd := range nds {
+		if err := s.Add(ctx, nd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// spill appends the block of nd to the temporary file, with s.lk held.
+func (s *spillDAG) spill(nd ipld.Node) error {
+	if s.file == nil {
+		f, err := os.CreateTemp(s.dir, "ipfs-add-spill-")
+		if err != nil {
+			return fmt.Errorf("creating the spill file of the import: %w", err)
+		}
+		s.file = f
+	}
+	data := nd.RawData()
+	if _, err := s.file.WriteAt(data, s.fileEnd); err != nil {
+		return fmt.Errorf("spilling a block of the import: %w", err)
+	}
+	s.spilled = append(s.spilled, spilledNode{c: nd.Cid(), off: s.fileEnd, size: len(data)})
+	s.fileEnd += int64(len(data))
+	s.stats.SpilledBlocks++
+	s.stats.SpilledBytes += uint64(len(data))
+	return nil
+}
+
+// run writes the buffered nodes, the ones in memory first.
+func (s *spillDAG) run() {
+	defer close(s.done)
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	for {
+		for !s.closed && len(s.mem) == 0 && len(s.spilled) == 0 {
+			s.cond.Wait()
+		}
+		if s.closed {
+			return
+		}
+
+		var batch []ipld.Node
+		var recs []spilledNode
+		var size uint64
+		if len(s.mem) > 0 {
+			batch, s.mem = s.mem, nil
+			for _, nd := range batch {
+				size += uint64(len(nd.RawData()))
+			}
+		} else {
+			// read back as much as fits in the budget, and at least one
+			// block
+			n := 0
+			for n < len(s.spilled) && (n == 0 || s.memSize+size+uint64(s.spilled[n].size) <= s.budget) {
+				size += uint64(s.spilled[n].size)
+				n++
+			}
+			recs, s.spilled = s.spilled[:n], s.spilled[n:]
+			s.memSize += size
+			if s.memSize > s.stats.PeakMemory {
+				s.stats.PeakMemory = s.memSize
+			}
+		}
+		s.writing = true
+		s.lk.Unlock()
+
+		var err error
+		if recs != nil {
+			batch, err = s.readBack(recs)
+		}
+		if err == nil {
+			err = s.DAGService.AddMany(s.ctx, batch)
+		}
+
+		s.lk.Lock()
+		s.writing = false
+		s.memSize -= size
+		if err != nil && s.err == nil {
+			s.err = err
+			s.mem, s.spilled = nil, nil
+			s.memSize = 0
+		}
+		if len(s.spilled) == 0 && s.fileEnd > 0 {
+			// start the file over once it was read back
+			if err := s.file.Truncate(0); err != nil && s.err == nil {
+				s.err = err
+			}
+			s.fileEnd = 0
+		}
+		s.cond.Broadcast()
+	}
+}
+
+// readBack reads the spilled nodes of recs.
+func (s *spillDAG) readBack(recs []spilledNode) ([]ipld.Node, error) {
+	nds := make([]ipld.Node, 0, len(recs))
+	for _, r := range recs {
+		data := make([]byte, r.size)
+		if _, err := s.file.ReadAt(data, r.off); err != nil {
+			return nil, fmt.Errorf("reading back a spilled block of the import: %w", err)
+		}
+		blk, err := blocks.NewBlockWithCid(data, r.c)
+		if err != nil {
+			return nil, err
+		}
+		var nd ipld.Node
+		switch r.c.Prefix().Codec {
+		case cid.DagProtobuf:
+			nd, err = dag.DecodeProtobufBlock(blk)
+		case cid.Raw:
+			nd, err = dag.DecodeRawBlock(blk)
+		default:
+			err = fmt.Errorf("unexpected codec of spilled block %s", r.c)
+		}
+		if err != nil {
+			return nil, err
+		}
+		nds = append(nds, nd)
+	}
+	return nds, nil
+}
+
+// Commit waits until all the buffered nodes are written.
+func (s *spillDAG) Commit() error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	for s.err == nil && (len(s.mem) > 0 || len(s.spilled) > 0 || s.writing) {
+		s.cond.Wait()
+	}
+	return s.err
+}
+
+func (s *spillDAG) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	if err := s.Commit(); err != nil {
+		return nil, err
+	}
+	return s.DAGService.Get(ctx, c)
+}
+
+func (s *spillDAG) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	if err := s.Commit(); err != nil {
+		out := make(chan *ipld.NodeOption, 1)
+		out <- &ipld.NodeOption{Err: err}
+		close(out)
+		return out
+	}
+	return s.DAGService.GetMany(ctx, cids)
+}
+
+func (s *spillDAG) Remove(ctx context.Context, c cid.Cid) error {
+	if err := s.Commit(); err != nil {
+		return err
+	}
+	return s.DAGService.Remove(ctx, c)
+}
+
+func (s *spillDAG) RemoveMany(ctx context.Context, cids []cid.Cid) error {
+	if err := s.Commit(); err != nil {
+		return err
+	}
+	return s.DAGService.RemoveMany(ctx, cids)
+}
+
+// Stats returns the peak memory used and the spilled blocks.
+func (s *spillDAG) Stats() options.AddStats {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.stats
+}
+
+// Close stops the writes and removes the temporary file. The nodes not
+// committed yet are dropped.
+func (s *spillDAG) Close() error {
+	s.lk.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.lk.Unlock()
+	<-s.done
+
+	if s.file == nil {
+		return nil
+	}
+	return errors.Join(s.file.Close(), os.Remove(s.file.Name()))
+}
@@ -0,0 +1,38 @@
+package coreunix
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpillDAG(t *testing.T) {
+	ctx := context.Background()
+	ds := dagtest.Mock()
+	const budget = 4096
+
+	s := newSpillDAG(ctx, ds, budget, t.TempDir())
+	var nds []ipld.Node
+	for i := 0; i < 200; i++ {
+		nd := dag.NewRawNode([]byte(fmt.Sprintf("%01000d", i)))
+		require.NoError(t, s.Add(ctx, nd))
+		nds = append(nds, nd)
+	}
+	require.NoError(t, s.Commit())
+
+	for _, nd := range nds {
+		got, err := ds.Get(ctx, nd.Cid())
+		require.NoError(t, err)
+		require.Equal(t, nd.RawData(), got.RawData())
+	}
+
+	stats := s.Stats()
+	require.LessOrEqual(t, stats.PeakMemory, uint64(budget+1000))
+	require.Equal(t, stats.SpilledBlocks*1000, stats.SpilledBytes)
+	require.NoError(t, s.Close())
+}
@@ -0,0 +1,56 @@
+package coreunix
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	gopath "path"
+	"strings"
+
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/mfs"
+)
+
+// Symlink writes a UnixFS symlink to target at the MFS path linkPath, like
+// ln -s: the target is stored as is, and doesn't have to exist. The missing
+// parent directories of linkPath are created when parents is true. It fails
+// when an entry is already at linkPath.
+func Symlink(root *mfs.Root, target, linkPath string, parents bool) error {
+	if target == "" {
+		return errors.New("empty symlink target")
+	}
+	if !strings.HasPrefix(linkPath, "/") {
+		return errors.New("MFS paths must start with '/'")
+	}
+	linkPath = gopath.Clean(linkPath)
+	if linkPath == "/" {
+		return errors.New("cannot replace the root directory")
+	}
+
+	dirPath := gopath.Dir(linkPath)
+	if parents {
+		if _, err := mfs.Lookup(root, dirPath); errors.Is(err, os.ErrNotExist) {
+			if err := mfs.Mkdir(root, dirPath, mfs.MkdirOpts{Mkparents: true}); err != nil {
+				return err
+			}
+		}
+	}
+	dir, err := lookupDir(root, dirPath)
+	if err != nil {
+		return err
+	}
+
+	data, err := ft.SymlinkData(target)
+	if err != nil {
+		return err
+	}
+	nd := dag.NodeWithData(data)
+	if err := nd.SetCidBuilder(dir.GetCidBuilder()); err != nil {
+		return err
+	}
+	if err := dir.AddChild(gopath.Base(linkPath), nd); err != nil {
+		return fmt.Errorf("%s: %w", linkPath, err)
+	}
+	return nil
+}
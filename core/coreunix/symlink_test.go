@@ -0,0 +1,50 @@
+package coreunix
+
+import (
+	"context"
+	"testing"
+
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/mfs"
+)
+
+func TestSymlink(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	root, err := mfs.NewRoot(ctx, dagtest.Mock(), ft.EmptyDirNode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Symlink(root, "../target", "/a/b/link", false); err == nil {
+		t.Fatal("expected an error without the parent directories")
+	}
+	if err := Symlink(root, "../target", "/a/b/link", true); err != nil {
+		t.Fatal(err)
+	}
+	fsn, err := mfs.Lookup(root, "/a/b/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := fsn.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsNode, err := ft.FSNodeFromBytes(nd.(*dag.ProtoNode).Data())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsNode.Type() != ft.TSymlink || string(fsNode.Data()) != "../target" {
+		t.Fatalf("unexpected node %s %q", fsNode.Type(), fsNode.Data())
+	}
+
+	if err := Symlink(root, "other", "/a/b/link", false); err == nil {
+		t.Fatal("expected an error replacing an entry")
+	}
+	if err := Symlink(root, "", "/a/empty", false); err == nil {
+		t.Fatal("expected an error for an empty target")
+	}
+}
@@ -0,0 +1,754 @@
+package coreunix
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	gopath "path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/balanced"
+	ihelper "github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
+	"github.com/ipfs/boxo/mfs"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	chunker "github.com/ipfs/kubo/core/chunker"
+)
+
+// SyncDirection selects which side of a sync is the source of truth.
+type SyncDirection int
+
+const (
+	// SyncToMFS makes the MFS directory match the local directory.
+	SyncToMFS SyncDirection = iota
+	// SyncFromMFS makes the local directory match the MFS directory.
+	SyncFromMFS
+	// SyncBoth copies the entries missing on either side to the other, and
+	// the files that differ from the side where they were modified last.
+	SyncBoth
+)
+
+// SyncOp is the kind of change applied to the destination of a sync.
+type SyncOp string
+
+const (
+	SyncOpAdd    SyncOp = "add"
+	SyncOpUpdate SyncOp = "update"
+	SyncOpRemove SyncOp = "remove"
+	SyncOpMkdir  SyncOp = "mkdir"
+)
+
+// SyncAction describes a single change made (or, in dry-run mode, that would
+// be made) to the destination of a sync.
+type SyncAction struct {
+	Op   SyncOp
+	Path string // path relative to the synced directories
+	Size int64  `json:",omitempty"`
+}
+
+// Syncer diffs a local directory against an MFS directory and applies the
+// minimal set of add, write and remove operations needed to make the
+// destination match the source.
+//
+// The modification time of the files is stored in their UnixFS metadata when
+// they are copied to MFS, and set on the local files copied from it. Files
+// are compared by size first, then by modification time, and by CID when the
+// sizes match but the times don't. Local files are hashed with Chunker and
+// the CID prefix of the existing MFS entry, without storing their blocks, so
+// entries imported with different DAG parameters are reported as updates.
+type Syncer struct {
+	Root      *mfs.Root
+	DAG       ipld.DAGService
+	Direction SyncDirection
+
+	// DryRun reports the actions without applying them.
+	DryRun bool
+	// Delete removes destination entries that do not exist in the source. It
+	// can't be used with SyncBoth, which has no record of removed entries.
+	Delete bool
+	// Exclude holds shell patterns (see path.Match) matched against both the
+	// entry name and its path relative to the synced directories.
+	Exclude []string
+
+	Chunker    string
+	RawLeaves  bool
+	CidBuilder cid.Builder
+
+	// Out receives every action as it is applied. Optional.
+	Out chan<- *SyncAction
+}
+
+// Sync synchronizes localDir and the MFS directory at mfsDir according to
+// s.Direction and returns the list of actions taken.
+func (s *Syncer) Sync(ctx context.Context, localDir, mfsDir string) ([]*SyncAction, error) {
+	for _, pattern := range s.Exclude {
+		if _, err := gopath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+	}
+
+	if s.Direction == SyncBoth && s.Delete {
+		return nil, fmt.Errorf("two-way syncs can't delete entries")
+	}
+
+	st, err := os.Stat(localDir)
+	switch {
+	case err == nil && !st.IsDir():
+		return nil, fmt.Errorf("%s is not a directory", localDir)
+	case os.IsNotExist(err) && s.Direction != SyncToMFS:
+		// will be created
+	case err != nil:
+		return nil, err
+	}
+
+	w := &syncWalker{Syncer: s, ctx: ctx, localDir: localDir, mfsDir: mfsDir}
+	switch s.Direction {
+	case SyncToMFS:
+		err = w.toMFS("")
+	case SyncFromMFS:
+		err = w.fromMFS("")
+	case SyncBoth:
+		err = w.both("")
+	default:
+		err = fmt.Errorf("unknown sync direction: %d", s.Direction)
+	}
+	return w.actions, err
+}
+
+type syncWalker struct {
+	*Syncer
+	ctx      context.Context
+	localDir string
+	mfsDir   string
+	actions  []*SyncAction
+}
+
+func (w *syncWalker) excluded(rel string) bool {
+	for _, pattern := range w.Exclude {
+		if ok, _ := gopath.Match(pattern, gopath.Base(rel)); ok {
+			return true
+		}
+		if ok, _ := gopath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *syncWalker) emit(op SyncOp, rel string, size int64) error {
+	a := &SyncAction{Op: op, Path: rel, Size: size}
+	w.actions = append(w.actions, a)
+	if w.Out == nil {
+		return nil
+	}
+	select {
+	case w.Out <- a:
+		return nil
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	}
+}
+
+func (w *syncWalker) localPath(rel string) string {
+	return filepath.Join(w.localDir, filepath.FromSlash(rel))
+}
+
+func (w *syncWalker) mfsPath(rel string) string {
+	return gopath.Join(w.mfsDir, rel)
+}
+
+// mfsDirectory returns the MFS directory for rel, or nil if it doesn't exist.
+func (w *syncWalker) mfsDirectory(rel string) (*mfs.Directory, error) {
+	fsn, err := mfs.Lookup(w.Root, w.mfsPath(rel))
+	if err == os.ErrNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	dir, ok := fsn.(*mfs.Directory)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a directory", w.mfsPath(rel))
+	}
+	return dir, nil
+}
+
+func (w *syncWalker) toMFS(rel string) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(w.localPath(rel))
+	if err != nil {
+		return err
+	}
+
+	dir, err := w.mfsDirectory(rel)
+	if err != nil {
+		return err
+	}
+	if dir == nil && !w.DryRun {
+		err := mfs.Mkdir(w.Root, w.mfsPath(rel), mfs.MkdirOpts{
+			Mkparents:  true,
+			CidBuilder: w.CidBuilder,
+		})
+		if err != nil {
+			return err
+		}
+		if dir, err = w.mfsDirectory(rel); err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[string]struct{}, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		crel := gopath.Join(rel, name)
+		if w.excluded(crel) {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		var existing mfs.FSNode
+		if dir != nil {
+			existing, err = dir.Child(name)
+			if err != nil && err != os.ErrNotExist {
+				return err
+			}
+		}
+
+		switch {
+		case e.IsDir():
+			if existing != nil && existing.Type() != mfs.TDir {
+				if err := w.unlinkMFS(dir, name, crel); err != nil {
+					return err
+				}
+				existing = nil
+			}
+			if existing == nil {
+				if err := w.emit(SyncOpMkdir, crel, 0); err != nil {
+					return err
+				}
+			}
+			if err := w.toMFS(crel); err != nil {
+				return err
+			}
+		case e.Type().IsRegular():
+			if err := w.fileToMFS(dir, name, crel, existing); err != nil {
+				return err
+			}
+		default:
+			log.Infof("files sync: skipping %s: not a regular file or directory", w.localPath(crel))
+		}
+	}
+
+	if !w.Delete || dir == nil {
+		return nil
+	}
+
+	names, err := dir.ListNames(w.ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		crel := gopath.Join(rel, name)
+		if _, ok := seen[name]; ok || w.excluded(crel) {
+			continue
+		}
+		if err := w.unlinkMFS(dir, name, crel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *syncWalker) unlinkMFS(dir *mfs.Directory, name, rel string) error {
+	if err := w.emit(SyncOpRemove, rel, 0); err != nil {
+		return err
+	}
+	if w.DryRun {
+		return nil
+	}
+	return dir.Unlink(name)
+}
+
+func (w *syncWalker) fileToMFS(dir *mfs.Directory, name, rel string, existing mfs.FSNode) error {
+	st, err := os.Stat(w.localPath(rel))
+	if err != nil {
+		return err
+	}
+
+	op := SyncOpAdd
+	if existing != nil {
+		op = SyncOpUpdate
+		if fi, ok := existing.(*mfs.File); ok {
+			changed, err := w.fileChanged(fi, w.localPath(rel), st)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				return nil
+			}
+		}
+	}
+	return w.pushFile(dir, name, rel, st, existing != nil, op)
+}
+
+// pushFile imports the local file rel into dir, replacing the existing entry.
+func (w *syncWalker) pushFile(dir *mfs.Directory, name, rel string, st os.FileInfo, existing bool, op SyncOp) error {
+	if err := w.emit(op, rel, st.Size()); err != nil {
+		return err
+	}
+	if w.DryRun {
+		return nil
+	}
+
+	builder := w.CidBuilder
+	if builder == nil {
+		builder = dir.GetCidBuilder()
+	}
+	nd, err := importFile(w.ctx, w.DAG, w.localPath(rel), w.Chunker, w.RawLeaves, builder)
+	if err != nil {
+		return err
+	}
+	if nd, err = withMtime(nd, st.ModTime(), builder); err != nil {
+		return err
+	}
+	if err := w.DAG.Add(w.ctx, nd); err != nil {
+		return err
+	}
+
+	if existing {
+		if err := dir.Unlink(name); err != nil {
+			return err
+		}
+	}
+	return dir.AddChild(name, nd)
+}
+
+func (w *syncWalker) fromMFS(rel string) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+
+	dir, err := w.mfsDirectory(rel)
+	if err != nil {
+		return err
+	}
+	if dir == nil {
+		return fmt.Errorf("%s: %w", w.mfsPath(rel), os.ErrNotExist)
+	}
+
+	lpath := w.localPath(rel)
+	if !w.DryRun {
+		if err := os.MkdirAll(lpath, 0o755); err != nil {
+			return err
+		}
+	}
+
+	local := make(map[string]os.DirEntry)
+	entries, err := os.ReadDir(lpath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, e := range entries {
+		local[e.Name()] = e
+	}
+
+	names, err := dir.ListNames(w.ctx)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		crel := gopath.Join(rel, name)
+		if w.excluded(crel) {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		child, err := dir.Child(name)
+		if err != nil {
+			return err
+		}
+		existing, exists := local[name]
+
+		switch child := child.(type) {
+		case *mfs.Directory:
+			if exists && !existing.IsDir() {
+				if err := w.removeLocal(crel); err != nil {
+					return err
+				}
+				exists = false
+			}
+			if !exists {
+				if err := w.emit(SyncOpMkdir, crel, 0); err != nil {
+					return err
+				}
+			}
+			if err := w.fromMFS(crel); err != nil {
+				return err
+			}
+		case *mfs.File:
+			if err := w.fileFromMFS(child, crel, existing); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !w.Delete {
+		return nil
+	}
+
+	// deterministic output order
+	extra := make([]string, 0, len(local))
+	for name := range local {
+		if _, ok := seen[name]; !ok {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		crel := gopath.Join(rel, name)
+		if w.excluded(crel) {
+			continue
+		}
+		if err := w.removeLocal(crel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *syncWalker) removeLocal(rel string) error {
+	if err := w.emit(SyncOpRemove, rel, 0); err != nil {
+		return err
+	}
+	if w.DryRun {
+		return nil
+	}
+	return os.RemoveAll(w.localPath(rel))
+}
+
+func (w *syncWalker) fileFromMFS(fi *mfs.File, rel string, existing os.DirEntry) error {
+	op := SyncOpAdd
+	if existing != nil {
+		op = SyncOpUpdate
+		if existing.IsDir() {
+			if err := w.removeLocal(rel); err != nil {
+				return err
+			}
+			op = SyncOpAdd
+		} else if existing.Type().IsRegular() {
+			st, err := os.Stat(w.localPath(rel))
+			if err != nil {
+				return err
+			}
+			changed, err := w.fileChanged(fi, w.localPath(rel), st)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				return nil
+			}
+		}
+	}
+	return w.pullFile(fi, rel, op)
+}
+
+// pullFile writes the MFS file fi to the local file rel.
+func (w *syncWalker) pullFile(fi *mfs.File, rel string, op SyncOp) error {
+	size, err := fi.Size()
+	if err != nil {
+		return err
+	}
+	if err := w.emit(op, rel, size); err != nil {
+		return err
+	}
+	if w.DryRun {
+		return nil
+	}
+
+	rfd, err := fi.Open(mfs.Flags{Read: true})
+	if err != nil {
+		return err
+	}
+	defer rfd.Close()
+
+	// The file is written next to lpath and renamed over it, which replaces a
+	// symlink rather than writing through it, and never leaves a partially
+	// pulled file behind.
+	lpath := w.localPath(rel)
+	mode := os.FileMode(0o644)
+	if st, err := os.Lstat(lpath); err == nil && st.Mode().IsRegular() {
+		mode = st.Mode().Perm()
+	}
+	out, err := os.CreateTemp(filepath.Dir(lpath), "."+filepath.Base(lpath)+".pull-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	if _, err := io.Copy(out, rfd); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(out.Name(), mode); err != nil {
+		return err
+	}
+	if err := os.Rename(out.Name(), lpath); err != nil {
+		return err
+	}
+
+	nd, err := fi.GetNode()
+	if err != nil {
+		return err
+	}
+	if mtime, ok := ModTime(nd); ok {
+		return os.Chtimes(lpath, mtime, mtime)
+	}
+	return nil
+}
+
+// both syncs the local and MFS directories rel in both directions.
+func (w *syncWalker) both(rel string) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+
+	dir, err := w.mfsDirectory(rel)
+	if err != nil {
+		return err
+	}
+	if dir == nil {
+		// only local
+		return w.toMFS(rel)
+	}
+	lpath := w.localPath(rel)
+	if _, err := os.Stat(lpath); os.IsNotExist(err) {
+		// only in MFS
+		return w.fromMFS(rel)
+	}
+
+	entries, err := os.ReadDir(lpath)
+	if err != nil {
+		return err
+	}
+	local := make(map[string]os.DirEntry, len(entries))
+	for _, e := range entries {
+		local[e.Name()] = e
+	}
+	names, err := dir.ListNames(w.ctx)
+	if err != nil {
+		return err
+	}
+	remote := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		remote[name] = struct{}{}
+	}
+	for name := range local {
+		if _, ok := remote[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		crel := gopath.Join(rel, name)
+		if w.excluded(crel) {
+			continue
+		}
+		e, isLocal := local[name]
+		if isLocal && !e.IsDir() && !e.Type().IsRegular() {
+			log.Infof("files sync: skipping %s: not a regular file or directory", w.localPath(crel))
+			continue
+		}
+		var child mfs.FSNode
+		if _, ok := remote[name]; ok {
+			if child, err = dir.Child(name); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case child == nil && e.IsDir():
+			if err := w.emit(SyncOpMkdir, crel, 0); err != nil {
+				return err
+			}
+			err = w.toMFS(crel)
+		case child == nil:
+			err = w.fileToMFS(dir, name, crel, nil)
+		case !isLocal:
+			if child.Type() == mfs.TDir {
+				if err := w.emit(SyncOpMkdir, crel, 0); err != nil {
+					return err
+				}
+				err = w.fromMFS(crel)
+			} else {
+				err = w.fileFromMFS(child.(*mfs.File), crel, nil)
+			}
+		case e.IsDir() && child.Type() == mfs.TDir:
+			err = w.both(crel)
+		case !e.IsDir() && child.Type() == mfs.TFile:
+			err = w.fileBoth(dir, name, crel, child.(*mfs.File))
+		default:
+			log.Warnf("files sync: skipping %s: a file on one side and a directory on the other", crel)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileBoth copies the file rel from the side where it was modified last to
+// the other, when they differ. The local file wins when the MFS file has no
+// modification time, or the same one.
+func (w *syncWalker) fileBoth(dir *mfs.Directory, name, rel string, fi *mfs.File) error {
+	st, err := os.Stat(w.localPath(rel))
+	if err != nil {
+		return err
+	}
+	changed, err := w.fileChanged(fi, w.localPath(rel), st)
+	if err != nil || !changed {
+		return err
+	}
+	nd, err := fi.GetNode()
+	if err != nil {
+		return err
+	}
+	if mtime, ok := ModTime(nd); ok && mtime.After(st.ModTime()) {
+		return w.pullFile(fi, rel, SyncOpUpdate)
+	}
+	return w.pushFile(dir, name, rel, st, true, SyncOpUpdate)
+}
+
+// fileChanged reports whether the local file, of stat st, differs from the
+// MFS file.
+func (w *syncWalker) fileChanged(fi *mfs.File, local string, st os.FileInfo) (bool, error) {
+	size, err := fi.Size()
+	if err != nil {
+		return false, err
+	}
+	if size != st.Size() {
+		return true, nil
+	}
+
+	nd, err := fi.GetNode()
+	if err != nil {
+		return false, err
+	}
+	var meta posixMeta
+	if pn, ok := nd.(*dag.ProtoNode); ok {
+		if meta, _, err = splitPosixMeta(pn.Data()); err != nil {
+			return false, err
+		}
+	}
+	if !meta.mtime.IsZero() && meta.mtime.Equal(st.ModTime()) {
+		return false, nil
+	}
+
+	// Hash the local file the same way the MFS entry was built, without
+	// storing any blocks.
+	prefix := nd.Cid().Prefix()
+	rawLeaves := prefix.Codec == cid.Raw
+	if links := nd.Links(); len(links) > 0 {
+		rawLeaves = links[0].Cid.Type() == cid.Raw
+	}
+	if prefix.Codec == cid.Raw {
+		// single raw leaf, parents would be dag-pb with the same prefix
+		prefix.Codec = cid.DagProtobuf
+	}
+	prefix.MhLength = -1
+
+	hashed, err := importFile(w.ctx, discardDAG{}, local, w.Chunker, rawLeaves, prefix)
+	if err != nil {
+		return false, err
+	}
+	if !meta.isZero() {
+		// the metadata of the MFS entry doesn't tell the content apart
+		pn, err := posixMetaNode(hashed)
+		if err != nil {
+			return false, err
+		}
+		pn.SetData(meta.appendTo(pn.Data()))
+		if err := pn.SetCidBuilder(prefix); err != nil {
+			return false, err
+		}
+		hashed = pn
+	}
+	return !hashed.Cid().Equals(nd.Cid()), nil
+}
+
+// withMtime returns the file nd storing mtime in its UnixFS metadata. The
+// returned node isn't added to any DAG service.
+func withMtime(nd ipld.Node, mtime time.Time, builder cid.Builder) (ipld.Node, error) {
+	pn, err := posixMetaNode(nd)
+	if err != nil {
+		return nil, err
+	}
+	pn.SetData(posixMeta{mtime: mtime}.appendTo(pn.Data()))
+	if err := pn.SetCidBuilder(builder); err != nil {
+		return nil, err
+	}
+	return pn, nil
+}
+
+// discardDAG is a DAG service dropping the nodes added to it, to hash files
+// without storing or buffering their blocks.
+type discardDAG struct{}
+
+func (discardDAG) Get(_ context.Context, c cid.Cid) (ipld.Node, error) {
+	return nil, ipld.ErrNotFound{Cid: c}
+}
+
+func (discardDAG) GetMany(_ context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	out := make(chan *ipld.NodeOption, len(cids))
+	for _, c := range cids {
+		out <- &ipld.NodeOption{Err: ipld.ErrNotFound{Cid: c}}
+	}
+	close(out)
+	return out
+}
+
+func (discardDAG) Add(context.Context, ipld.Node) error        { return nil }
+func (discardDAG) AddMany(context.Context, []ipld.Node) error  { return nil }
+func (discardDAG) Remove(context.Context, cid.Cid) error       { return nil }
+func (discardDAG) RemoveMany(context.Context, []cid.Cid) error { return nil }
+
+// importFile chunks the file at p into a balanced UnixFS DAG stored in ds.
+func importFile(ctx context.Context, ds ipld.DAGService, p, chunkerSpec string, rawLeaves bool, builder cid.Builder) (ipld.Node, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	chnk, err := chunker.FromString(f, chunkerSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	bufferedDS := ipld.NewBufferedDAG(ctx, ds)
+	params := ihelper.DagBuilderParams{
+		Dagserv:    bufferedDS,
+		RawLeaves:  rawLeaves,
+		Maxlinks:   ihelper.DefaultLinksPerBlock,
+		CidBuilder: builder,
+	}
+	db, err := params.New(chnk)
+	if err != nil {
+		return nil, err
+	}
+	nd, err := balanced.Layout(db)
+	if err != nil {
+		return nil, err
+	}
+	return nd, bufferedDS.Commit()
+}
@@ -0,0 +1,290 @@
+package coreunix
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/mfs"
+)
+
+func writeTestFile(t *testing.T, p, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func opsOf(actions []*SyncAction) map[string]SyncOp {
+	out := make(map[string]SyncOp, len(actions))
+	for _, a := range actions {
+		out[a.Path] = a.Op
+	}
+	return out
+}
+
+func TestSyncToMFS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ds := dagtest.Mock()
+	root, err := mfs.NewRoot(ctx, ds, ft.EmptyDirNode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local := t.TempDir()
+	writeTestFile(t, filepath.Join(local, "a.txt"), "hello")
+	writeTestFile(t, filepath.Join(local, "sub", "b.txt"), "world")
+	writeTestFile(t, filepath.Join(local, "skip.tmp"), "ignored")
+
+	s := &Syncer{Root: root, DAG: ds, Exclude: []string{"*.tmp"}}
+	actions, err := s.Sync(ctx, local, "/dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := opsOf(actions)
+	if ops["a.txt"] != SyncOpAdd || ops["sub"] != SyncOpMkdir || ops["sub/b.txt"] != SyncOpAdd {
+		t.Fatalf("unexpected actions: %v", ops)
+	}
+	if _, ok := ops["skip.tmp"]; ok {
+		t.Fatal("excluded file was synced")
+	}
+
+	// nothing changed, nothing to do
+	actions, err = s.Sync(ctx, local, "/dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 0 {
+		t.Fatalf("expected no actions, got %v", opsOf(actions))
+	}
+
+	// same size, different content
+	writeTestFile(t, filepath.Join(local, "a.txt"), "HELLO")
+	if err := os.Remove(filepath.Join(local, "sub", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Delete = true
+	s.DryRun = true
+	actions, err = s.Sync(ctx, local, "/dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops = opsOf(actions)
+	if ops["a.txt"] != SyncOpUpdate || ops["sub/b.txt"] != SyncOpRemove {
+		t.Fatalf("unexpected actions: %v", ops)
+	}
+	if _, err := mfs.Lookup(root, "/dst/sub/b.txt"); err != nil {
+		t.Fatal("dry run modified MFS")
+	}
+
+	s.DryRun = false
+	if _, err := s.Sync(ctx, local, "/dst"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.Lookup(root, "/dst/sub/b.txt"); err != os.ErrNotExist {
+		t.Fatalf("expected removed file, got %v", err)
+	}
+
+	// pull back into an empty directory
+	out := t.TempDir()
+	s = &Syncer{Root: root, DAG: ds, Direction: SyncFromMFS}
+	if _, err := s.Sync(ctx, out, "/dst"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "HELLO" {
+		t.Fatalf("unexpected content %q", data)
+	}
+}
+
+func TestSyncBoth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ds := dagtest.Mock()
+	root, err := mfs.NewRoot(ctx, ds, ft.EmptyDirNode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local := t.TempDir()
+	old := time.Unix(1700000000, 0)
+	writeTestFile(t, filepath.Join(local, "a.txt"), "hello")
+	writeTestFile(t, filepath.Join(local, "b.txt"), "world")
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.Chtimes(filepath.Join(local, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &Syncer{Root: root, DAG: ds}
+	if _, err := s.Sync(ctx, local, "/dst"); err != nil {
+		t.Fatal(err)
+	}
+	// the modification times are kept in MFS
+	fsn, err := mfs.Lookup(root, "/dst/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := fsn.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mtime, ok := ModTime(nd); !ok || !mtime.Equal(old) {
+		t.Fatalf("expected mtime %s, got %s, %t", old, mtime, ok)
+	}
+
+	// a.txt is newer in MFS, b.txt locally, c.txt only exists in MFS and
+	// d.txt locally
+	newer := old.Add(time.Hour)
+	other := filepath.Join(t.TempDir(), "a.txt")
+	writeTestFile(t, other, "HELLO")
+	if err := os.Chtimes(other, newer, newer); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := lookupDir(root, "/dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := os.Stat(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &syncWalker{Syncer: s, ctx: ctx, localDir: filepath.Dir(other), mfsDir: "/dst"}
+	if err := w.pushFile(dir, "a.txt", "a.txt", st, true, SyncOpUpdate); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(local, "b.txt"), "WORLD")
+	if err := os.Chtimes(filepath.Join(local, "b.txt"), newer, newer); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.PutNode(root, "/dst/c.txt", dag.NodeWithData(ft.FilePBData([]byte("only in mfs"), 11))); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(local, "d.txt"), "only local")
+
+	s.Direction = SyncBoth
+	actions, err := s.Sync(ctx, local, "/dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := opsOf(actions)
+	if len(ops) != 4 || ops["a.txt"] != SyncOpUpdate || ops["b.txt"] != SyncOpUpdate || ops["c.txt"] != SyncOpAdd || ops["d.txt"] != SyncOpAdd {
+		t.Fatalf("unexpected actions: %v", ops)
+	}
+
+	data, err := os.ReadFile(filepath.Join(local, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err = os.Stat(filepath.Join(local, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "HELLO" || !st.ModTime().Equal(newer) {
+		t.Fatalf("expected a.txt to be pulled, got %q, %s", data, st.ModTime())
+	}
+	if _, err := os.Stat(filepath.Join(local, "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/dst/b.txt", "/dst/d.txt"} {
+		fsn, err := mfs.Lookup(root, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nd, err := fsn.GetNode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := ModTime(nd); !ok {
+			t.Fatalf("expected %s to be pushed with its mtime", p)
+		}
+	}
+
+	// both sides match now
+	actions, err = s.Sync(ctx, local, "/dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 0 {
+		t.Fatalf("expected no actions, got %v", opsOf(actions))
+	}
+
+	s.Delete = true
+	if _, err := s.Sync(ctx, local, "/dst"); err == nil {
+		t.Fatal("expected two-way syncs not to delete")
+	}
+}
+
+func TestSyncFromMFSSymlink(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ds := dagtest.Mock()
+	root, err := mfs.NewRoot(ctx, ds, ft.EmptyDirNode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mkdir(root, "/dst", mfs.MkdirOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.PutNode(root, "/dst/a.txt", dag.NodeWithData(ft.FilePBData([]byte("from mfs"), 8))); err != nil {
+		t.Fatal(err)
+	}
+
+	// a.txt is a symlink to a file outside of the synced directory
+	outside := filepath.Join(t.TempDir(), "outside.txt")
+	writeTestFile(t, outside, "outside")
+	local := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(local, "a.txt")); err != nil {
+		t.Skipf("symlinks not supported: %s", err)
+	}
+
+	s := &Syncer{Root: root, DAG: ds, Direction: SyncFromMFS}
+	if _, err := s.Sync(ctx, local, "/dst"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "outside" {
+		t.Fatalf("the pull wrote through the symlink: %q", data)
+	}
+	st, err := os.Lstat(filepath.Join(local, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Mode().IsRegular() {
+		t.Fatalf("expected the symlink to be replaced by a file, got %s", st.Mode())
+	}
+	data, err = os.ReadFile(filepath.Join(local, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "from mfs" {
+		t.Fatalf("unexpected content %q", data)
+	}
+	entries, err := os.ReadDir(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only a.txt, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,167 @@
+// Package dagshape bounds the shape of the DAGs imported, pinned and fetched
+// by the gateway, so that a DAG deep or wide enough to exhaust the memory of
+// the walks is rejected instead.
+package dagshape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	format "github.com/ipfs/go-ipld-format"
+	ipldlegacy "github.com/ipfs/go-ipld-legacy"
+	"github.com/ipfs/kubo/config"
+	dagpb "github.com/ipld/go-codec-dagpb"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+)
+
+// ErrExceeded is matched by the errors returned for the DAGs exceeding the
+// limits.
+var ErrExceeded = errors.New("DAG shape limit exceeded")
+
+// Limits of an ExceededError.
+const (
+	Depth = "depth"
+	Links = "links"
+	Nodes = "nodes"
+)
+
+// ExceededError is returned for a DAG exceeding one of the limits, at the
+// node Cid.
+type ExceededError struct {
+	// Limit is Depth, Links or Nodes.
+	Limit string
+	Max   int64
+	Cid   cid.Cid
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s: more than %d %s at %s", ErrExceeded, e.Max, e.Limit, e.Cid)
+}
+
+func (e *ExceededError) Is(target error) bool {
+	return target == ErrExceeded
+}
+
+// Limits are the limits configured in DAGLimits. Zero limits are disabled.
+type Limits struct {
+	MaxDepth int64
+	MaxLinks int64
+	MaxNodes int64
+}
+
+// Enabled reports whether any limit is set.
+func (l Limits) Enabled() bool {
+	return l.MaxDepth > 0 || l.MaxLinks > 0 || l.MaxNodes > 0
+}
+
+// ParseLimits returns the limits configured in cfg.
+func ParseLimits(cfg config.DAGLimits) Limits {
+	return Limits{
+		MaxDepth: cfg.MaxDepth.WithDefault(0),
+		MaxLinks: cfg.MaxLinks.WithDefault(0),
+		MaxNodes: cfg.MaxNodes.WithDefault(0),
+	}
+}
+
+// CheckNode checks the links of nd against MaxLinks.
+func (l Limits) CheckNode(nd format.Node) error {
+	if l.MaxLinks > 0 && int64(len(nd.Links())) > l.MaxLinks {
+		return &ExceededError{Limit: Links, Max: l.MaxLinks, Cid: nd.Cid()}
+	}
+	return nil
+}
+
+// decoder decodes the blocks as merkledag does.
+var decoder = func() *ipldlegacy.Decoder {
+	d := ipldlegacy.NewDecoder()
+	d.RegisterCodec(cid.DagProtobuf, dagpb.Type.PBNode, merkledag.ProtoNodeConverter)
+	d.RegisterCodec(cid.Raw, basicnode.Prototype.Bytes, merkledag.RawNodeConverter)
+	return d
+}()
+
+// CheckBlock decodes blk, when MaxLinks is set, to check its links. The
+// blocks of unknown codecs have no links to check.
+func (l Limits) CheckBlock(ctx context.Context, blk blocks.Block) error {
+	if l.MaxLinks <= 0 {
+		return nil
+	}
+	nd, err := decoder.DecodeNode(ctx, blk)
+	if err != nil {
+		return nil
+	}
+	return l.CheckNode(nd)
+}
+
+// Walk gets the DAG under root from ng, one level at a time, checking it
+// against the limits. The depth of a node is the one of the shortest path
+// from the root to it. With skipMissing, the nodes ng doesn't have are
+// skipped, for the partial DAGs.
+func Walk(ctx context.Context, ng format.NodeGetter, root cid.Cid, l Limits, skipMissing bool) error {
+	seen := cid.NewSet()
+	seen.Add(root)
+	level := []cid.Cid{root}
+	var nodes int64
+	for depth := int64(0); len(level) > 0; depth++ {
+		if l.MaxDepth > 0 && depth > l.MaxDepth {
+			return &ExceededError{Limit: Depth, Max: l.MaxDepth, Cid: level[0]}
+		}
+		nodes += int64(len(level))
+		if l.MaxNodes > 0 && nodes > l.MaxNodes {
+			return &ExceededError{Limit: Nodes, Max: l.MaxNodes, Cid: level[0]}
+		}
+
+		var next []cid.Cid
+		err := getLevel(ctx, ng, level, skipMissing, func(nd format.Node) error {
+			if err := l.CheckNode(nd); err != nil {
+				return err
+			}
+			for _, link := range nd.Links() {
+				if seen.Visit(link.Cid) {
+					next = append(next, link.Cid)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		level = next
+	}
+	return nil
+}
+
+// getLevel gets the nodes of a level, in parallel unless the missing ones
+// are skipped, which GetMany doesn't tell apart.
+func getLevel(ctx context.Context, ng format.NodeGetter, level []cid.Cid, skipMissing bool, f func(format.Node) error) error {
+	if skipMissing {
+		for _, c := range level {
+			nd, err := ng.Get(ctx, c)
+			if format.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := f(nd); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for opt := range ng.GetMany(ctx, level) {
+		if opt.Err != nil {
+			return opt.Err
+		}
+		if err := f(opt.Node); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,56 @@
+package dagshape
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	format "github.com/ipfs/go-ipld-format"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalk(t *testing.T) {
+	ctx := context.Background()
+	dag := dagtest.Mock()
+
+	// a chain of 4 nodes, the last one linking to 3 leaves
+	wide := merkledag.NodeWithData([]byte("wide"))
+	var nodes []format.Node
+	for _, s := range []string{"a", "b", "c"} {
+		leaf := merkledag.NewRawNode([]byte(s))
+		require.NoError(t, wide.AddNodeLink(s, leaf))
+		nodes = append(nodes, leaf)
+	}
+	nodes = append(nodes, wide)
+	root := wide
+	for i := 0; i < 3; i++ {
+		parent := merkledag.NodeWithData([]byte{byte(i)})
+		require.NoError(t, parent.AddNodeLink("next", root))
+		nodes = append(nodes, parent)
+		root = parent
+	}
+	require.NoError(t, dag.AddMany(ctx, nodes))
+
+	require.NoError(t, Walk(ctx, dag, root.Cid(), Limits{MaxDepth: 4, MaxLinks: 3, MaxNodes: 7}, false))
+
+	for _, tc := range []struct {
+		limits Limits
+		limit  string
+	}{
+		{Limits{MaxDepth: 3}, Depth},
+		{Limits{MaxLinks: 2}, Links},
+		{Limits{MaxNodes: 6}, Nodes},
+	} {
+		err := Walk(ctx, dag, root.Cid(), tc.limits, false)
+		require.ErrorIs(t, err, ErrExceeded)
+		var exceeded *ExceededError
+		require.ErrorAs(t, err, &exceeded)
+		require.Equal(t, tc.limit, exceeded.Limit)
+	}
+
+	// the missing nodes are skipped, with skipMissing
+	require.NoError(t, dag.Remove(ctx, wide.Cid()))
+	require.NoError(t, Walk(ctx, dag, root.Cid(), Limits{MaxLinks: 2}, true))
+	require.Error(t, Walk(ctx, dag, root.Cid(), Limits{MaxLinks: 2}, false))
+}
@@ -0,0 +1,292 @@
+// Package dhthealth tracks the health of the routing tables of the DHT and the
+// outcome of the routing queries, so that operators can tell whether poor
+// resolution comes from the routing tables (empty buckets, peers churning or
+// not answering) or from the content (queries answered, without results).
+package dhthealth
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	kbucket "github.com/libp2p/go-libp2p-kbucket"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// BucketSize is the number of peers of a full bucket, the default of the
+	// DHT.
+	BucketSize = 20
+	// DefaultInterval is the time between two samples of the routing tables.
+	DefaultInterval = 30 * time.Second
+	// ChurnWindow is the period the churn is counted over.
+	ChurnWindow = time.Hour
+)
+
+// AgeBounds are the upper bounds of the classes of the distribution of the
+// ages of the peers, see Bucket.Ages.
+var AgeBounds = []time.Duration{10 * time.Minute, time.Hour, 24 * time.Hour}
+
+// Bucket is the health of a bucket of a routing table.
+type Bucket struct {
+	Peers int
+	// Occupancy is Peers over BucketSize.
+	Occupancy float64
+	// Ages is the number of peers in the table for less than each of
+	// AgeBounds, and for longer in its last element.
+	Ages []int
+	// Added and Removed are the number of peers that entered and left the
+	// bucket during the last ChurnWindow.
+	Added   int
+	Removed int
+}
+
+// Table is the health of a routing table.
+type Table struct {
+	Name    string
+	Peers   int
+	Buckets []Bucket
+	// MedianAge is the median time the peers have been in the table.
+	MedianAge time.Duration
+	Added     int
+	Removed   int
+	// ChurnRate is the fraction of the table replaced per hour, over the
+	// last ChurnWindow, or since the monitor started when it is shorter.
+	ChurnRate float64
+}
+
+// Queries are the outcomes of the routing queries of a kind.
+type Queries struct {
+	// Succeeded, Empty and Failed are the number of queries that returned
+	// results, that completed without results, and that failed or timed out
+	// without results.
+	Succeeded uint64
+	Empty     uint64
+	Failed    uint64
+	// PeerResponses and PeerErrors are the number of times a DHT peer
+	// answered a query, and failed to.
+	PeerResponses uint64
+	PeerErrors    uint64
+}
+
+// SuccessRate is the fraction of the queries that returned results.
+func (q Queries) SuccessRate() float64 {
+	return ratio(q.Succeeded, q.Succeeded+q.Empty+q.Failed)
+}
+
+// PeerSuccessRate is the fraction of the DHT peers queried that answered.
+func (q Queries) PeerSuccessRate() float64 {
+	return ratio(q.PeerResponses, q.PeerResponses+q.PeerErrors)
+}
+
+func ratio(n, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// Report is the health of the routing tables and queries.
+type Report struct {
+	Tables []Table
+	// Queries are the outcomes of the queries by kind: FindProviders,
+	// FindPeer, GetValue and SearchValue.
+	Queries map[string]Queries
+	// Since is when the monitor started.
+	Since time.Time
+}
+
+type queryCounters struct {
+	succeeded, empty, failed atomic.Uint64
+	responses, errors        atomic.Uint64
+}
+
+type churnEvent struct {
+	time   time.Time
+	bucket int
+	added  bool
+}
+
+type table struct {
+	name   string
+	self   kbucket.ID
+	rt     *kbucket.RoutingTable
+	known  map[peer.ID]int // the bucket of the peers of the last sample
+	events []churnEvent
+}
+
+// Monitor samples the routing tables of the DHT, and counts the outcomes of
+// the queries of the routers returned by Router.
+type Monitor struct {
+	clock   clock.Clock
+	started time.Time
+
+	lk     sync.Mutex
+	tables []*table
+
+	queries map[string]*queryCounters
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a monitor, the routing tables are sampled once Start is called.
+func New(clk clock.Clock) *Monitor {
+	m := &Monitor{
+		clock:   clk,
+		started: clk.Now(),
+		queries: make(map[string]*queryCounters),
+	}
+	for _, kind := range []string{queryFindProviders, queryFindPeer, queryGetValue, querySearchValue} {
+		m.queries[kind] = new(queryCounters)
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	return m
+}
+
+// Track samples the routing table rt of the local peer self, reported as
+// name. The peers already in the table are not counted as churn.
+func (m *Monitor) Track(name string, self peer.ID, rt *kbucket.RoutingTable) {
+	t := &table{name: name, self: kbucket.ConvertPeerID(self), rt: rt}
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	m.sampleTable(t, m.clock.Now())
+	m.tables = append(m.tables, t)
+}
+
+// Start samples the routing tables every interval until Close.
+func (m *Monitor) Start(interval time.Duration) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := m.clock.Ticker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.Sample()
+			case <-m.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the sampling.
+func (m *Monitor) Close() error {
+	m.cancel()
+	m.wg.Wait()
+	return nil
+}
+
+// Sample records the peers that entered and left the routing tables since
+// the last sample. Peers that enter and leave a table between two samples
+// are not seen.
+func (m *Monitor) Sample() {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	now := m.clock.Now()
+	for _, t := range m.tables {
+		m.sampleTable(t, now)
+	}
+}
+
+func (m *Monitor) sampleTable(t *table, now time.Time) {
+	first := t.known == nil
+	known := make(map[peer.ID]int)
+	for _, pi := range t.rt.GetPeerInfos() {
+		b := kbucket.CommonPrefixLen(t.self, kbucket.ConvertPeerID(pi.Id))
+		known[pi.Id] = b
+		if _, ok := t.known[pi.Id]; !ok && !first {
+			t.events = append(t.events, churnEvent{time: now, bucket: b, added: true})
+		}
+	}
+	for p, b := range t.known {
+		if _, ok := known[p]; !ok {
+			t.events = append(t.events, churnEvent{time: now, bucket: b})
+		}
+	}
+	t.known = known
+
+	i := sort.Search(len(t.events), func(i int) bool {
+		return now.Sub(t.events[i].time) < ChurnWindow
+	})
+	t.events = append(t.events[:0], t.events[i:]...)
+}
+
+// Report samples the routing tables, and returns their health and the
+// outcomes of the queries.
+func (m *Monitor) Report() Report {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	now := m.clock.Now()
+
+	r := Report{Since: m.started, Queries: make(map[string]Queries, len(m.queries))}
+	for _, t := range m.tables {
+		m.sampleTable(t, now)
+		r.Tables = append(r.Tables, m.tableHealth(t, now))
+	}
+	for kind, c := range m.queries {
+		r.Queries[kind] = Queries{
+			Succeeded:     c.succeeded.Load(),
+			Empty:         c.empty.Load(),
+			Failed:        c.failed.Load(),
+			PeerResponses: c.responses.Load(),
+			PeerErrors:    c.errors.Load(),
+		}
+	}
+	return r
+}
+
+func (m *Monitor) tableHealth(t *table, now time.Time) Table {
+	h := Table{Name: t.name}
+	var buckets []Bucket
+	bucket := func(i int) *Bucket {
+		for len(buckets) <= i {
+			buckets = append(buckets, Bucket{Ages: make([]int, len(AgeBounds)+1)})
+		}
+		return &buckets[i]
+	}
+
+	var ages []time.Duration
+	for _, pi := range t.rt.GetPeerInfos() {
+		b := bucket(kbucket.CommonPrefixLen(t.self, kbucket.ConvertPeerID(pi.Id)))
+		b.Peers++
+		age := now.Sub(pi.AddedAt)
+		class := sort.Search(len(AgeBounds), func(i int) bool { return age < AgeBounds[i] })
+		b.Ages[class]++
+		ages = append(ages, age)
+	}
+	for _, e := range t.events {
+		b := bucket(e.bucket)
+		if e.added {
+			b.Added++
+			h.Added++
+		} else {
+			b.Removed++
+			h.Removed++
+		}
+	}
+	for i := range buckets {
+		buckets[i].Occupancy = float64(buckets[i].Peers) / BucketSize
+		h.Peers += buckets[i].Peers
+	}
+	h.Buckets = buckets
+
+	if len(ages) > 0 {
+		sort.Slice(ages, func(i, j int) bool { return ages[i] < ages[j] })
+		h.MedianAge = ages[len(ages)/2]
+	}
+	window := now.Sub(m.started)
+	if window > ChurnWindow {
+		window = ChurnWindow
+	}
+	if h.Peers > 0 && window > 0 {
+		h.ChurnRate = float64(h.Removed) / float64(h.Peers) / window.Hours()
+	}
+	return h
+}
@@ -0,0 +1,91 @@
+package dhthealth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/go-cid"
+	kbucket "github.com/libp2p/go-libp2p-kbucket"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/test"
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableHealth(t *testing.T) {
+	self := test.RandPeerIDFatal(t)
+	rt, err := kbucket.NewRoutingTable(BucketSize, kbucket.ConvertPeerID(self), time.Hour, pstore.NewMetrics(), time.Hour, nil)
+	require.NoError(t, err)
+
+	addPeers := func(n int) []peer.ID {
+		var ps []peer.ID
+		for i := 0; i < n; i++ {
+			p := test.RandPeerIDFatal(t)
+			_, err := rt.TryAddPeer(p, true, false)
+			require.NoError(t, err)
+			ps = append(ps, p)
+		}
+		return ps
+	}
+
+	clk := clock.NewMock()
+	clk.Set(time.Now())
+	m := New(clk)
+	initial := addPeers(5)
+	m.Track("wan", self, rt)
+
+	// the peers in the table before it is tracked are not churn
+	addPeers(3)
+	rt.RemovePeer(initial[0])
+	clk.Add(DefaultInterval)
+	m.Sample()
+
+	r := m.Report()
+	require.Len(t, r.Tables, 1)
+	table := r.Tables[0]
+	require.Equal(t, "wan", table.Name)
+	require.Equal(t, rt.Size(), table.Peers)
+	require.Equal(t, 3, table.Added)
+	require.Equal(t, 1, table.Removed)
+	require.Greater(t, table.ChurnRate, 0.0)
+
+	var peers, added, young int
+	for _, b := range table.Buckets {
+		require.Len(t, b.Ages, len(AgeBounds)+1)
+		peers += b.Peers
+		added += b.Added
+		young += b.Ages[0]
+	}
+	require.Equal(t, table.Peers, peers)
+	require.Equal(t, 3, added)
+	require.Equal(t, table.Peers, young)
+
+	// the churn is forgotten after the window
+	clk.Add(ChurnWindow)
+	m.Sample()
+	table = m.Report().Tables[0]
+	require.Zero(t, table.Added)
+	require.Zero(t, table.Removed)
+}
+
+func TestRouterQueries(t *testing.T) {
+	ctx := context.Background()
+	m := New(clock.NewMock())
+	r := m.Router(routinghelpers.Null{})
+
+	_, err := r.FindPeer(ctx, test.RandPeerIDFatal(t))
+	require.Error(t, err)
+	for range r.FindProvidersAsync(ctx, cid.MustParse("bafkqaaa"), 1) {
+	}
+	_, err = r.GetValue(ctx, "/ipns/key")
+	require.Error(t, err)
+
+	q := m.Report().Queries
+	require.Equal(t, uint64(1), q[queryFindPeer].Empty)
+	require.Equal(t, uint64(1), q[queryFindProviders].Empty)
+	require.Equal(t, uint64(1), q[queryGetValue].Empty)
+	require.Zero(t, q[queryGetValue].SuccessRate())
+}
@@ -0,0 +1,148 @@
+package dhthealth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+
+	irouting "github.com/ipfs/kubo/routing"
+)
+
+// The kinds of the queries counted.
+const (
+	queryFindProviders = "FindProviders"
+	queryFindPeer      = "FindPeer"
+	queryGetValue      = "GetValue"
+	querySearchValue   = "SearchValue"
+)
+
+// Router returns r, counting the outcomes of its queries, and the DHT peers
+// that answered them.
+func (m *Monitor) Router(r routing.Routing) routing.Routing {
+	return irouting.KeepProvideMany(&router{Routing: r, m: m}, r)
+}
+
+type router struct {
+	routing.Routing
+	m *Monitor
+}
+
+// watch counts the DHT peers answering the queries of ctx, forwarding the
+// query events to the caller listening to them, if any. The returned
+// function must be called once the query is done.
+func (r *router) watch(ctx context.Context, c *queryCounters) (context.Context, func()) {
+	caller := ctx
+	forward := routing.SubscribesToQueryEvents(ctx)
+	ctx, cancel := context.WithCancel(ctx)
+	ctx, events := routing.RegisterForQueryEvents(ctx)
+	go func() {
+		for e := range events {
+			switch e.Type {
+			case routing.PeerResponse:
+				c.responses.Add(1)
+			case routing.QueryError:
+				c.errors.Add(1)
+			}
+			if forward {
+				routing.PublishQueryEvent(caller, e)
+			}
+		}
+	}()
+	return ctx, cancel
+}
+
+// count counts the outcome of a query returning n results.
+func count(ctx context.Context, c *queryCounters, n int, err error) {
+	switch {
+	case n > 0:
+		c.succeeded.Add(1)
+	case errors.Is(err, routing.ErrNotFound) || (err == nil && ctx.Err() == nil):
+		c.empty.Add(1)
+	default:
+		c.failed.Add(1)
+	}
+}
+
+func (r *router) FindPeer(ctx context.Context, p peer.ID) (peer.AddrInfo, error) {
+	c := r.m.queries[queryFindPeer]
+	ctx, done := r.watch(ctx, c)
+	defer done()
+
+	ai, err := r.Routing.FindPeer(ctx, p)
+	n := 0
+	if err == nil {
+		n = 1
+	}
+	count(ctx, c, n, err)
+	return ai, err
+}
+
+func (r *router) GetValue(ctx context.Context, key string, opts ...routing.Option) ([]byte, error) {
+	c := r.m.queries[queryGetValue]
+	ctx, done := r.watch(ctx, c)
+	defer done()
+
+	val, err := r.Routing.GetValue(ctx, key, opts...)
+	n := 0
+	if err == nil {
+		n = 1
+	}
+	count(ctx, c, n, err)
+	return val, err
+}
+
+func (r *router) SearchValue(ctx context.Context, key string, opts ...routing.Option) (<-chan []byte, error) {
+	c := r.m.queries[querySearchValue]
+	wctx, done := r.watch(ctx, c)
+
+	vals, err := r.Routing.SearchValue(wctx, key, opts...)
+	if err != nil {
+		done()
+		count(ctx, c, 0, err)
+		return nil, err
+	}
+	out := make(chan []byte)
+	go func() {
+		defer done()
+		defer close(out)
+		n := 0
+		for val := range vals {
+			n++
+			select {
+			case out <- val:
+			case <-ctx.Done():
+				count(ctx, c, n, nil)
+				return
+			}
+		}
+		count(ctx, c, n, nil)
+	}()
+	return out, nil
+}
+
+func (r *router) FindProvidersAsync(ctx context.Context, k cid.Cid, limit int) <-chan peer.AddrInfo {
+	c := r.m.queries[queryFindProviders]
+	wctx, done := r.watch(ctx, c)
+
+	provs := r.Routing.FindProvidersAsync(wctx, k, limit)
+	out := make(chan peer.AddrInfo)
+	go func() {
+		defer done()
+		defer close(out)
+		n := 0
+		for p := range provs {
+			n++
+			select {
+			case out <- p:
+			case <-ctx.Done():
+				count(ctx, c, n, nil)
+				return
+			}
+		}
+		count(ctx, c, n, nil)
+	}()
+	return out
+}
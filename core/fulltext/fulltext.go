@@ -0,0 +1,445 @@
+// Package fulltext indexes the text content of MFS and of the pinned DAGs:
+// plain text, markdown and HTML files. Queries are answered from an inverted
+// index stored in the repo datastore, and ranked with BM25.
+package fulltext
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	gopath "path"
+	"strconv"
+	"sync"
+	"time"
+
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	format "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+
+	"github.com/ipfs/kubo/core/contentindex"
+	"github.com/ipfs/kubo/core/indexer"
+)
+
+var log = logging.Logger("fulltext")
+
+var (
+	// DatastoreKey is the prefix under which the index is persisted.
+	DatastoreKey = datastore.NewKey("/local/fulltext")
+
+	docsKey    = DatastoreKey.ChildString("docs")
+	termsKey   = DatastoreKey.ChildString("terms")
+	pinsKey    = DatastoreKey.ChildString("pins")
+	statsKey   = DatastoreKey.ChildString("stats")
+	mfsRootKey = DatastoreKey.ChildString("mfsroot")
+)
+
+const (
+	// SourceMFS is the source of the documents found in MFS.
+	SourceMFS = "mfs"
+	// SourcePin is the source of the documents found in pinned DAGs.
+	SourcePin = "pin"
+)
+
+// MaxDocumentSize is the number of bytes of a file that are indexed.
+const MaxDocumentSize = 4 << 20
+
+// PinsInterval is how often the recursive pins are compared with the index,
+// in case they changed without the indexer being notified.
+const PinsInterval = 10 * time.Minute
+
+// sniffLen is the number of bytes the MIME type of a file is sniffed from.
+const sniffLen = 512
+
+// Doc is an indexed document, or an MFS directory.
+type Doc struct {
+	Source string
+	// Root is the pinned CID a document was found under. It is undefined
+	// for MFS documents.
+	Root cid.Cid
+	// Path is the MFS path of a document, or its /ipfs path under Root.
+	Path string
+	Cid  cid.Cid
+	// Dir is set on MFS directories, recorded to only walk the ones that
+	// changed.
+	Dir bool `json:",omitempty"`
+	// Incomplete is set on the directories whose content was not entirely
+	// stored locally when they were indexed.
+	Incomplete bool   `json:",omitempty"`
+	Format     string `json:",omitempty"`
+	Title      string `json:",omitempty"`
+	// Length is the number of terms of the document.
+	Length int `json:",omitempty"`
+	// Terms are the frequencies of the terms of the document.
+	Terms map[string]int `json:",omitempty"`
+}
+
+// suffix is the key of a document under docsKey, and of its postings under
+// the key of each of its terms.
+func (d *Doc) suffix() datastore.Key {
+	h := sha256.Sum256([]byte(d.Path))
+	k := datastore.NewKey(d.Source)
+	if d.Root.Defined() {
+		k = k.ChildString(d.Root.String())
+	}
+	return k.ChildString(hex.EncodeToString(h[:16]))
+}
+
+type stats struct {
+	Docs   int
+	Length int64
+}
+
+// Indexer maintains the full-text index of a node.
+type Indexer struct {
+	ds     datastore.Batching
+	dag    format.DAGService
+	pinner pin.Pinner
+
+	// indexLk serializes the updates of the index.
+	indexLk sync.Mutex
+
+	lk    sync.Mutex
+	stats stats
+
+	loop *indexer.Loop
+}
+
+// New returns the index persisted in ds, and starts indexing the recursive
+// pins of pinner in the background. The documents are read through dag,
+// which should not fetch missing blocks from the network.
+func New(ctx context.Context, ds datastore.Batching, dag format.DAGService, pinner pin.Pinner) (*Indexer, error) {
+	ix := &Indexer{
+		ds:     ds,
+		dag:    dag,
+		pinner: pinner,
+	}
+	buf, err := ds.Get(ctx, statsKey)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(buf, &ix.stats); err != nil {
+			return nil, err
+		}
+	case !errors.Is(err, datastore.ErrNotFound):
+		return nil, err
+	}
+
+	ix.loop = indexer.Start(ix, log, PinsInterval)
+	return ix, nil
+}
+
+// Close stops indexing in the background.
+func (ix *Indexer) Close() error {
+	return ix.loop.Close()
+}
+
+// UpdateMFS schedules the indexing of the MFS tree under root, in the
+// background. When MFS changes quickly, only the latest root is indexed.
+func (ix *Indexer) UpdateMFS(root cid.Cid) {
+	ix.loop.UpdateMFS(root)
+}
+
+// UpdatePins schedules the indexing of the recursive pins that aren't yet,
+// and the removal of the documents of the DAGs that were unpinned.
+func (ix *Indexer) UpdatePins() {
+	ix.loop.UpdatePins()
+}
+
+// batch is a datastore batch that also accounts for the changes of the
+// statistics of the index.
+type batch struct {
+	datastore.Batch
+	ix    *Indexer
+	delta stats
+}
+
+func (ix *Indexer) batch(ctx context.Context) (*batch, error) {
+	b, err := ix.ds.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &batch{Batch: b, ix: ix}, nil
+}
+
+func (b *batch) Commit(ctx context.Context) error {
+	b.ix.lk.Lock()
+	defer b.ix.lk.Unlock()
+
+	s := stats{Docs: b.ix.stats.Docs + b.delta.Docs, Length: b.ix.stats.Length + b.delta.Length}
+	buf, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := b.Batch.Put(ctx, statsKey, buf); err != nil {
+		return err
+	}
+	if err := b.Batch.Commit(ctx); err != nil {
+		return err
+	}
+	b.ix.stats = s
+	return nil
+}
+
+func (b *batch) putDoc(ctx context.Context, d *Doc) error {
+	buf, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	suffix := d.suffix()
+	for term, tf := range d.Terms {
+		if err := b.Put(ctx, termsKey.ChildString(term).Child(suffix), []byte(strconv.Itoa(tf))); err != nil {
+			return err
+		}
+	}
+	if !d.Dir {
+		b.delta.Docs++
+		b.delta.Length += int64(d.Length)
+	}
+	return b.Put(ctx, docsKey.Child(suffix), buf)
+}
+
+func (b *batch) deleteDoc(ctx context.Context, d *Doc) error {
+	suffix := d.suffix()
+	for term := range d.Terms {
+		if err := b.Delete(ctx, termsKey.ChildString(term).Child(suffix)); err != nil {
+			return err
+		}
+	}
+	if !d.Dir {
+		b.delta.Docs--
+		b.delta.Length -= int64(d.Length)
+	}
+	return b.Delete(ctx, docsKey.Child(suffix))
+}
+
+// IndexPins indexes the recursive pins that aren't yet, and removes the
+// documents of the DAGs that were unpinned.
+func (ix *Indexer) IndexPins(ctx context.Context) error {
+	ix.indexLk.Lock()
+	defer ix.indexLk.Unlock()
+
+	pinned := make(map[cid.Cid]struct{})
+	for p := range ix.pinner.RecursiveKeys(ctx, false) {
+		if p.Err != nil {
+			return p.Err
+		}
+		pinned[p.Pin.Key] = struct{}{}
+	}
+
+	indexed := make(map[cid.Cid]struct{})
+	results, err := ix.ds.Query(ctx, query.Query{Prefix: pinsKey.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	for r := range results.Next() {
+		if r.Error != nil {
+			results.Close()
+			return r.Error
+		}
+		c, err := cid.Decode(datastore.RawKey(r.Key).BaseNamespace())
+		if err != nil {
+			continue
+		}
+		indexed[c] = struct{}{}
+	}
+	results.Close()
+
+	for c := range indexed {
+		if _, ok := pinned[c]; !ok {
+			if err := ix.removePin(ctx, c); err != nil {
+				return err
+			}
+		}
+	}
+	for c := range pinned {
+		if _, ok := indexed[c]; !ok {
+			if err := ix.indexPin(ctx, c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (ix *Indexer) indexPin(ctx context.Context, root cid.Cid) error {
+	b, err := ix.batch(ctx)
+	if err != nil {
+		return err
+	}
+	put := func(d *Doc) error {
+		if d.Dir {
+			return nil
+		}
+		return b.putDoc(ctx, d)
+	}
+	if _, err := ix.walk(Doc{Source: SourcePin, Root: root}, nil, put).Run(ctx, "/ipfs/"+root.String(), root); err != nil {
+		return err
+	}
+	if err := b.Put(ctx, pinsKey.ChildString(root.String()), nil); err != nil {
+		return err
+	}
+	return b.Commit(ctx)
+}
+
+func (ix *Indexer) removePin(ctx context.Context, root cid.Cid) error {
+	b, err := ix.batch(ctx)
+	if err != nil {
+		return err
+	}
+	docs, err := ix.docs(ctx, docsKey.ChildString(SourcePin).ChildString(root.String()))
+	if err != nil {
+		return err
+	}
+	for _, d := range docs {
+		if err := b.deleteDoc(ctx, d); err != nil {
+			return err
+		}
+	}
+	if err := b.Delete(ctx, pinsKey.ChildString(root.String())); err != nil {
+		return err
+	}
+	return b.Commit(ctx)
+}
+
+// IndexMFS updates the MFS documents to the tree under root. Only the
+// directories that changed since the last update are walked.
+func (ix *Indexer) IndexMFS(ctx context.Context, root cid.Cid) error {
+	ix.indexLk.Lock()
+	defer ix.indexLk.Unlock()
+
+	if unchanged, err := indexer.Unchanged(ctx, ix.ds, mfsRootKey, root); err != nil || unchanged {
+		return err
+	}
+
+	docs, err := ix.docs(ctx, docsKey.ChildString(SourceMFS))
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]*Doc, len(docs))
+	indexed := make(map[string]indexer.Indexed, len(docs))
+	for _, d := range docs {
+		existing[d.Path] = d
+		indexed[d.Path] = indexer.Indexed{Cid: d.Cid, Incomplete: d.Incomplete}
+	}
+	tree := indexer.NewTree(indexed)
+
+	b, err := ix.batch(ctx)
+	if err != nil {
+		return err
+	}
+	put := func(d *Doc) error {
+		if d.Path == "/" {
+			return nil
+		}
+		if old, ok := existing[d.Path]; ok {
+			tree.Keep(d.Path)
+			if err := b.deleteDoc(ctx, old); err != nil {
+				return err
+			}
+		}
+		return b.putDoc(ctx, d)
+	}
+	complete, err := ix.walk(Doc{Source: SourceMFS}, tree.Skip, put).Run(ctx, "/", root)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range tree.Stale() {
+		if err := b.deleteDoc(ctx, existing[p]); err != nil {
+			return err
+		}
+	}
+	if err := indexer.RecordRoot(ctx, b, mfsRootKey, root, complete); err != nil {
+		return err
+	}
+	return b.Commit(ctx)
+}
+
+func (ix *Indexer) docs(ctx context.Context, prefix datastore.Key) ([]*Doc, error) {
+	results, err := ix.ds.Query(ctx, query.Query{Prefix: prefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var docs []*Doc
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		d := new(Doc)
+		if err := json.Unmarshal(r.Value, d); err != nil {
+			log.Warnf("corrupt document %s: %s", r.Key, err)
+			continue
+		}
+		docs = append(docs, d)
+	}
+	return docs, nil
+}
+
+// walk returns the walk that indexes the documents of a UnixFS DAG, calling
+// put with the text documents and the directories, filled from template.
+func (ix *Indexer) walk(template Doc, skip func(p string, c cid.Cid) bool, put func(d *Doc) error) *indexer.Walk {
+	return &indexer.Walk{
+		DAG:  ix.dag,
+		Skip: skip,
+		File: func(ctx context.Context, p string, nd format.Node) (bool, error) {
+			d := template
+			d.Path, d.Cid = p, nd.Cid()
+			return ix.file(ctx, &d, nd, put)
+		},
+		Dir: func(ctx context.Context, p string, nd format.Node, complete bool) error {
+			d := template
+			d.Path, d.Cid = p, nd.Cid()
+			d.Dir, d.Incomplete = true, !complete
+			return put(&d)
+		},
+	}
+}
+
+// file indexes a text file, and ignores the other ones.
+func (ix *Indexer) file(ctx context.Context, d *Doc, nd format.Node, put func(d *Doc) error) (bool, error) {
+	data, err := ix.read(ctx, nd, sniffLen)
+	if err != nil {
+		if format.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	d.Format = docFormat(gopath.Base(d.Path), contentindex.MimeType(gopath.Base(d.Path), data))
+	if d.Format == "" {
+		return true, nil
+	}
+
+	data, err = ix.read(ctx, nd, MaxDocumentSize)
+	if err != nil {
+		if format.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	var text string
+	d.Title, text = extract(d.Format, data)
+	d.Terms = make(map[string]int)
+	for _, t := range tokenize(text) {
+		d.Terms[t.term]++
+		d.Length++
+	}
+	return true, put(d)
+}
+
+// read returns up to n first bytes of a file.
+func (ix *Indexer) read(ctx context.Context, nd format.Node, n int64) ([]byte, error) {
+	r, err := uio.NewDagReader(ctx, nd, ix.dag)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(io.LimitReader(r, n))
+}
@@ -0,0 +1,155 @@
+package fulltext
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/pinning/pinner/dspinner"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	format "github.com/ipfs/go-ipld-format"
+)
+
+func dir(t *testing.T, dag format.DAGService, children map[string]format.Node) *merkledag.ProtoNode {
+	t.Helper()
+	nd := ft.EmptyDirNode()
+	for name, child := range children {
+		if err := nd.AddNodeLink(name, child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dag.Add(context.Background(), nd); err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
+
+func file(t *testing.T, dag format.DAGService, data string) format.Node {
+	t.Helper()
+	nd := merkledag.NewRawNode([]byte(data))
+	if err := dag.Add(context.Background(), nd); err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
+
+func runQuery(t *testing.T, ix *Indexer, q string) []Hit {
+	t.Helper()
+	hits, err := ix.Query(context.Background(), q, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hits
+}
+
+func expectPaths(t *testing.T, ix *Indexer, q string, expected ...string) []Hit {
+	t.Helper()
+	hits := runQuery(t, ix, q)
+	var paths []string
+	for _, h := range hits {
+		paths = append(paths, h.Path)
+	}
+	if strings.Join(paths, " ") != strings.Join(expected, " ") {
+		t.Fatalf("%s: expected %v, got %v", q, expected, paths)
+	}
+	return hits
+}
+
+func TestIndexMFSAndPins(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	dag := dagtest.Mock()
+	pinner, err := dspinner.New(ctx, ds, dag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix, err := New(ctx, ds, dag, pinner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+
+	notes := file(t, dag, "# Groceries\n\nBuy apples, bananas and more apples.")
+	page := file(t, dag, "<html><head><title>Orchard</title><script>var apples</script></head><body><p>Apple trees grow in the orchard.</p></body></html>")
+	root := dir(t, dag, map[string]format.Node{
+		"notes.md":   notes,
+		"index.html": page,
+		"image.png":  file(t, dag, "\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR apples"),
+	})
+	if err := ix.IndexMFS(ctx, root.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	hits := expectPaths(t, ix, "apples", "/notes.md")
+	if hits[0].Doc.Title != "Groceries" || hits[0].Snippet != "# Groceries Buy apples, bananas and more apples." {
+		t.Fatalf("unexpected hit %+v", hits[0])
+	}
+	hits = expectPaths(t, ix, "orchard", "/index.html")
+	if hits[0].Doc.Title != "Orchard" {
+		t.Fatalf("unexpected title %q", hits[0].Doc.Title)
+	}
+	expectPaths(t, ix, "appl*", "/notes.md", "/index.html")
+	expectPaths(t, ix, "appl* -bananas", "/index.html")
+	expectPaths(t, ix, `"more apples"`, "/notes.md")
+	expectPaths(t, ix, `"apples more"`)
+	expectPaths(t, ix, `appl* -"apple trees"`, "/notes.md")
+
+	// the notes are removed from MFS, and pinned
+	if err := ix.IndexMFS(ctx, dir(t, dag, map[string]format.Node{"index.html": page}).Cid()); err != nil {
+		t.Fatal(err)
+	}
+	expectPaths(t, ix, "apples")
+	if err := pinner.Pin(ctx, notes, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.IndexPins(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expectPaths(t, ix, "apples", "/ipfs/"+notes.Cid().String())
+
+	if err := pinner.Unpin(ctx, notes.Cid(), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.IndexPins(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expectPaths(t, ix, "apples")
+
+	ix.lk.Lock()
+	defer ix.lk.Unlock()
+	if ix.stats.Docs != 1 {
+		t.Fatalf("expected 1 document, got %d", ix.stats.Docs)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	clauses, err := parseQuery(`Apple "big orchard" -worm pie* -"rotten fruit"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []clause{
+		{terms: []string{"apple"}},
+		{terms: []string{"big", "orchard"}},
+		{terms: []string{"worm"}, exclude: true},
+		{terms: []string{"pie"}, prefix: true},
+		{terms: []string{"rotten", "fruit"}, exclude: true},
+	}
+	if len(clauses) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, clauses)
+	}
+	for i, c := range clauses {
+		if strings.Join(c.terms, " ") != strings.Join(expected[i].terms, " ") || c.prefix != expected[i].prefix || c.exclude != expected[i].exclude {
+			t.Fatalf("expected %+v, got %+v", expected, clauses)
+		}
+	}
+
+	for _, q := range []string{`"apple`, "-apple", "", `"a"`, "apple-pie*"} {
+		if _, err := parseQuery(q); err == nil {
+			t.Errorf("%q: expected an error", q)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package fulltext
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	format "github.com/ipfs/go-ipld-format"
+)
+
+// BM25 parameters.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// snippetContext is the number of bytes of text shown around the first match
+// in snippets.
+const snippetContext = 80
+
+// Hit is a document matching a query.
+type Hit struct {
+	Source  string
+	Path    string
+	Doc     *Doc
+	Score   float64
+	Snippet string
+}
+
+// clause is a word, a prefix or a phrase of a query.
+type clause struct {
+	terms   []string
+	prefix  bool
+	exclude bool
+}
+
+func (c *clause) phrase() bool {
+	return len(c.terms) > 1
+}
+
+// parseQuery parses the words of a query. Quoted words are phrases, words
+// starting with '-' are excluded, and words ending with '*' are prefixes.
+func parseQuery(q string) ([]clause, error) {
+	var clauses []clause
+	for q = strings.TrimSpace(q); q != ""; q = strings.TrimSpace(q) {
+		var c clause
+		if q[0] == '-' {
+			c.exclude, q = true, q[1:]
+		}
+		var word string
+		if strings.HasPrefix(q, `"`) {
+			end := strings.IndexByte(q[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			word, q = q[1:end+1], q[end+2:]
+		} else {
+			end := strings.IndexAny(q, " \t\n")
+			if end < 0 {
+				end = len(q)
+			}
+			word, q = q[:end], q[end:]
+			c.prefix = strings.HasSuffix(word, "*")
+		}
+		for _, t := range tokenize(word) {
+			c.terms = append(c.terms, t.term)
+		}
+		if len(c.terms) == 0 {
+			continue
+		}
+		if c.prefix && c.phrase() {
+			return nil, fmt.Errorf("%q: prefixes must be a single word", word)
+		}
+		clauses = append(clauses, c)
+	}
+
+	for _, c := range clauses {
+		if !c.exclude {
+			return clauses, nil
+		}
+	}
+	return nil, errors.New("the query needs at least one word that isn't excluded")
+}
+
+// postings maps the suffix of a document key to the frequency of a term in
+// the document.
+type postings map[string]int
+
+func (ix *Indexer) postings(ctx context.Context, term string, prefix bool) (postings, error) {
+	q := query.Query{Prefix: termsKey.ChildString(term).String()}
+	if prefix {
+		q = query.Query{
+			Prefix:  termsKey.String(),
+			Filters: []query.Filter{query.FilterKeyPrefix{Prefix: termsKey.ChildString(term).String()}},
+		}
+	}
+	results, err := ix.ds.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	p := make(postings)
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		// the key is termsKey/<term>/<suffix>
+		rest := strings.TrimPrefix(r.Key, termsKey.String()+"/")
+		t, suffix, ok := strings.Cut(rest, "/")
+		if !ok || (t != term && !(prefix && strings.HasPrefix(t, term))) {
+			continue
+		}
+		tf, err := strconv.Atoi(string(r.Value))
+		if err != nil {
+			continue
+		}
+		p[suffix] += tf
+	}
+	return p, nil
+}
+
+// Query returns the documents matching q, best first. A limit of 0 returns
+// all of them.
+//
+// Documents match when they contain all the words of q. Quoted words are
+// phrases, words starting with '-' are excluded, and words ending with '*'
+// match any word they start. Snippets show the text around the first match.
+func (ix *Indexer) Query(ctx context.Context, q string, limit int) ([]Hit, error) {
+	clauses, err := parseQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	ix.lk.Lock()
+	st := ix.stats
+	ix.lk.Unlock()
+	avgLength := 1.0
+	if st.Docs > 0 {
+		avgLength = math.Max(float64(st.Length)/float64(st.Docs), 1)
+	}
+
+	// each term, or prefix, is scored on its own
+	type unit struct {
+		postings postings
+		idf      float64
+	}
+	var units []unit
+	var candidates map[string]struct{}
+	excluded := make(map[string]struct{})
+	for _, c := range clauses {
+		if c.exclude && c.phrase() {
+			continue // checked against the text of the documents
+		}
+		for _, term := range c.terms {
+			p, err := ix.postings(ctx, term, c.prefix)
+			if err != nil {
+				return nil, err
+			}
+			if c.exclude {
+				for suffix := range p {
+					excluded[suffix] = struct{}{}
+				}
+				continue
+			}
+
+			df := float64(len(p))
+			idf := math.Log(1 + (float64(st.Docs)-df+0.5)/(df+0.5))
+			units = append(units, unit{postings: p, idf: idf})
+
+			next := make(map[string]struct{})
+			for suffix := range p {
+				if _, ok := candidates[suffix]; ok || candidates == nil {
+					next[suffix] = struct{}{}
+				}
+			}
+			candidates = next
+		}
+	}
+
+	var hits []Hit
+	for suffix := range candidates {
+		if _, ok := excluded[suffix]; ok {
+			continue
+		}
+		d, err := ix.doc(ctx, suffix)
+		if err != nil {
+			if errors.Is(err, datastore.ErrNotFound) {
+				continue // removed since
+			}
+			return nil, err
+		}
+
+		var score float64
+		norm := bm25K1 * (1 - bm25B + bm25B*float64(d.Length)/avgLength)
+		for _, u := range units {
+			tf := float64(u.postings[suffix])
+			score += u.idf * tf * (bm25K1 + 1) / (tf + norm)
+		}
+		hits = append(hits, Hit{Source: d.Source, Path: d.Path, Doc: d, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Path < hits[j].Path
+	})
+
+	// check the phrases, and make the snippets of the hits returned
+	var found []Hit
+	for _, h := range hits {
+		if limit > 0 && len(found) == limit {
+			break
+		}
+		text, err := ix.text(ctx, h.Doc)
+		if err != nil {
+			if format.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		snippet, ok := match(text, clauses)
+		if !ok {
+			continue
+		}
+		h.Snippet = snippet
+		found = append(found, h)
+	}
+	return found, nil
+}
+
+func (ix *Indexer) doc(ctx context.Context, suffix string) (*Doc, error) {
+	buf, err := ix.ds.Get(ctx, docsKey.Child(datastore.NewKey(suffix)))
+	if err != nil {
+		return nil, err
+	}
+	d := new(Doc)
+	return d, json.Unmarshal(buf, d)
+}
+
+// text returns the text of a document.
+func (ix *Indexer) text(ctx context.Context, d *Doc) (string, error) {
+	nd, err := ix.dag.Get(ctx, d.Cid)
+	if err != nil {
+		return "", err
+	}
+	data, err := ix.read(ctx, nd, MaxDocumentSize)
+	if err != nil {
+		return "", err
+	}
+	_, text := extract(d.Format, data)
+	return text, nil
+}
+
+// match checks the phrases of a query against the text of a document, and
+// returns a snippet of the text around the first match.
+func match(text string, clauses []clause) (string, bool) {
+	tokens := tokenize(text)
+	first := -1
+	for _, c := range clauses {
+		i := find(tokens, c)
+		if c.exclude {
+			if c.phrase() && i >= 0 {
+				return "", false
+			}
+			continue
+		}
+		if i < 0 {
+			return "", false
+		}
+		if first < 0 || i < first {
+			first = i
+		}
+	}
+	return snippet(text, tokens[first]), true
+}
+
+// find returns the index of the first token matching c, or -1.
+func find(tokens []token, c clause) int {
+	for i := 0; i+len(c.terms) <= len(tokens); i++ {
+		ok := true
+		for j, term := range c.terms {
+			t := tokens[i+j].term
+			if t != term && !(c.prefix && strings.HasPrefix(t, term)) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func snippet(text string, t token) string {
+	start, end := t.start-snippetContext, t.end+snippetContext
+	prefix, suffix := "…", "…"
+	if start <= 0 {
+		start, prefix = 0, ""
+	} else if i := strings.IndexAny(text[start:t.start], " \t\n"); i >= 0 {
+		start += i // don't cut a word
+	} else {
+		for !utf8.RuneStart(text[start]) {
+			start++
+		}
+	}
+	if end >= len(text) {
+		end, suffix = len(text), ""
+	} else if i := strings.LastIndexAny(text[t.end:end], " \t\n"); i >= 0 {
+		end = t.end + i
+	} else {
+		for !utf8.RuneStart(text[end]) {
+			end--
+		}
+	}
+	return prefix + strings.Join(strings.Fields(text[start:end]), " ") + suffix
+}
@@ -0,0 +1,134 @@
+package fulltext
+
+import (
+	"bytes"
+	gopath "path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	minTermLen = 2
+	maxTermLen = 40
+)
+
+// Document formats.
+const (
+	FormatText     = "text/plain"
+	FormatMarkdown = "text/markdown"
+	FormatHTML     = "text/html"
+)
+
+// docFormat returns the format a file is indexed as, or "" for the files that
+// aren't indexed.
+func docFormat(name, mimeType string) string {
+	switch strings.ToLower(gopath.Ext(name)) {
+	case ".md", ".markdown":
+		return FormatMarkdown
+	case ".html", ".htm", ".xhtml":
+		return FormatHTML
+	}
+	switch mimeType {
+	case FormatHTML:
+		return FormatHTML
+	case FormatMarkdown:
+		return FormatMarkdown
+	}
+	if strings.HasPrefix(mimeType, "text/") {
+		return FormatText
+	}
+	return ""
+}
+
+// extract returns the title and the text of a document.
+func extract(format string, data []byte) (title, text string) {
+	switch format {
+	case FormatHTML:
+		return extractHTML(data)
+	case FormatMarkdown:
+		text = string(data)
+		for _, line := range strings.Split(text, "\n") {
+			if strings.HasPrefix(line, "# ") {
+				title = strings.TrimSpace(line[2:])
+				break
+			}
+		}
+		return title, text
+	default:
+		return "", string(data)
+	}
+}
+
+func extractHTML(data []byte) (title, text string) {
+	var sb strings.Builder
+	var skip, inTitle bool
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			// io.EOF, or a malformed document: keep what was parsed
+			return strings.TrimSpace(title), sb.String()
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style", "noscript":
+				skip = true
+			case "title":
+				inTitle = true
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style", "noscript":
+				skip = false
+			case "title":
+				inTitle = false
+			}
+		case html.TextToken:
+			if skip {
+				continue
+			}
+			t := string(z.Text())
+			if inTitle {
+				title += t
+			}
+			sb.WriteString(t)
+			sb.WriteByte(' ')
+		}
+	}
+}
+
+// token is a term and its position in a text, in bytes.
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits a text into lower case terms made of letters and digits.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		if n := utf8.RuneCountInString(text[start:end]); n >= minTermLen && n <= maxTermLen {
+			tokens = append(tokens, token{term: strings.ToLower(text[start:end]), start: start, end: end})
+		}
+		start = -1
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+	return tokens
+}
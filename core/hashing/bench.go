@@ -0,0 +1,88 @@
+package hashing
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BenchmarkResult is the throughput measured for one configuration.
+type BenchmarkResult struct {
+	Implementation string
+	Concurrency    int
+	BytesPerSecond float64
+}
+
+// Benchmark measures SHA-256 throughput for every combination of the given
+// implementations and goroutine counts, hashing blocks of blockSize bytes for
+// duration each. Results are returned in the order they were measured.
+func Benchmark(ctx context.Context, impls []string, concurrencies []int, blockSize int, duration time.Duration) ([]BenchmarkResult, error) {
+	// validate everything before spending time benchmarking
+	for _, name := range impls {
+		if _, err := NewSHA256(name); err != nil {
+			return nil, err
+		}
+	}
+
+	block := make([]byte, blockSize)
+	if _, err := rand.Read(block); err != nil {
+		return nil, err
+	}
+
+	var results []BenchmarkResult
+	for _, name := range impls {
+		for _, n := range concurrencies {
+			if n < 1 {
+				continue
+			}
+			bps, err := benchmarkOne(ctx, name, n, block, duration)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, BenchmarkResult{
+				Implementation: name,
+				Concurrency:    n,
+				BytesPerSecond: bps,
+			})
+		}
+	}
+	return results, nil
+}
+
+func benchmarkOne(parent context.Context, name string, n int, block []byte, duration time.Duration) (float64, error) {
+	ctx, cancel := context.WithTimeout(parent, duration)
+	defer cancel()
+
+	var (
+		hashed atomic.Int64
+		wg     sync.WaitGroup
+	)
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		h, err := NewSHA256(name)
+		if err != nil {
+			return 0, err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out := make([]byte, 0, 32)
+			for ctx.Err() == nil {
+				// hash whole blocks like the importer does
+				h.Reset()
+				h.Write(block)
+				out = h.Sum(out[:0])
+				hashed.Add(int64(len(block)))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// a cancelled parent context means the results are meaningless
+	if err := parent.Err(); err != nil {
+		return 0, err
+	}
+	return float64(hashed.Load()) / time.Since(start).Seconds(), nil
+}
@@ -0,0 +1,141 @@
+// Package hashing controls the SHA-256 implementation used for multihashes,
+// the number of goroutines used by hashing heavy operations, and how far
+// ahead of the hashing the adder reads its input.
+//
+// The settings are process wide: the SHA-256 implementation is registered in
+// the go-multihash registry, so it should only be changed while the node is
+// being constructed.
+package hashing
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/cpuid/v2"
+	sha256simd "github.com/minio/sha256-simd"
+	mh "github.com/multiformats/go-multihash"
+)
+
+const (
+	// SHA256Auto keeps the go-multihash default implementation.
+	SHA256Auto = "auto"
+	// SHA256Stdlib uses crypto/sha256, which uses SHA-NI and ARMv8 SHA2
+	// instructions when available.
+	SHA256Stdlib = "stdlib"
+	// SHA256SIMD uses github.com/minio/sha256-simd, which additionally has
+	// AVX2 and AVX512 code paths.
+	SHA256SIMD = "simd"
+)
+
+var sha256Impls = map[string]func() hash.Hash{
+	SHA256Stdlib: sha256.New,
+	SHA256SIMD:   sha256simd.New,
+}
+
+var (
+	implLk  sync.Mutex
+	current = SHA256Auto
+
+	concurrency atomic.Int64
+	readAhead   atomic.Int64
+)
+
+// SHA256Implementations returns the names accepted by SetSHA256.
+func SHA256Implementations() []string {
+	names := []string{SHA256Auto}
+	for name := range sha256Impls {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// NewSHA256 returns a hasher of the named implementation. SHA256Auto returns
+// the hasher currently registered for multihashes.
+func NewSHA256(name string) (hash.Hash, error) {
+	if name == SHA256Auto {
+		return mh.GetHasher(mh.SHA2_256)
+	}
+	f, ok := sha256Impls[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown SHA-256 implementation %q, must be one of %v", name, SHA256Implementations())
+	}
+	return f(), nil
+}
+
+// SetSHA256 registers the named implementation for SHA2-256 multihashes.
+func SetSHA256(name string) error {
+	implLk.Lock()
+	defer implLk.Unlock()
+
+	if name == current {
+		return nil
+	}
+
+	f, ok := sha256Impls[name]
+	if name == SHA256Auto {
+		// go-multihash uses crypto/sha256 by default
+		f, ok = sha256.New, true
+	}
+	if !ok {
+		return fmt.Errorf("unknown SHA-256 implementation %q, must be one of %v", name, SHA256Implementations())
+	}
+
+	mh.Register(mh.SHA2_256, f)
+	current = name
+	return nil
+}
+
+// SHA256 returns the name of the implementation set with SetSHA256.
+func SHA256() string {
+	implLk.Lock()
+	defer implLk.Unlock()
+	return current
+}
+
+// SetConcurrency sets the number of goroutines used for hashing. Zero or a
+// negative value restores the per operation defaults.
+func SetConcurrency(n int) {
+	concurrency.Store(int64(n))
+}
+
+// Concurrency returns the number of goroutines to use for hashing, or def
+// when no value was set with SetConcurrency.
+func Concurrency(def int) int {
+	if n := concurrency.Load(); n > 0 {
+		return int(n)
+	}
+	return def
+}
+
+// SetAddReadAhead sets the number of chunks the adder reads and chunks ahead
+// of building and hashing the DAG of a file. Zero or a negative value
+// disables the read-ahead.
+func SetAddReadAhead(n int) {
+	readAhead.Store(int64(n))
+}
+
+// AddReadAhead returns the number of chunks set with SetAddReadAhead, zero
+// when the adder doesn't read ahead.
+func AddReadAhead() int {
+	if n := readAhead.Load(); n > 0 {
+		return int(n)
+	}
+	return 0
+}
+
+// CPUFeatures returns the CPU features relevant to SHA-256 performance that
+// are available on this host.
+func CPUFeatures() []string {
+	var out []string
+	for _, f := range []cpuid.FeatureID{cpuid.SHA, cpuid.SSE4, cpuid.AVX2, cpuid.AVX512F, cpuid.SHA2} {
+		if cpuid.CPU.Supports(f) {
+			out = append(out, f.String())
+		}
+	}
+	return out
+}
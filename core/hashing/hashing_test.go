@@ -0,0 +1,83 @@
+package hashing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	mh "github.com/multiformats/go-multihash"
+)
+
+func TestSetSHA256(t *testing.T) {
+	defer SetSHA256(SHA256Auto)
+
+	data := []byte("hello world")
+	expected, err := mh.Sum(data, mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range SHA256Implementations() {
+		if err := SetSHA256(name); err != nil {
+			t.Fatal(err)
+		}
+		if SHA256() != name {
+			t.Fatalf("expected %s, got %s", name, SHA256())
+		}
+		got, err := mh.Sum(data, mh.SHA2_256, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, expected) {
+			t.Fatalf("%s: digest mismatch", name)
+		}
+	}
+
+	if err := SetSHA256("nope"); err == nil {
+		t.Fatal("expected an error for an unknown implementation")
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	defer SetConcurrency(0)
+
+	if n := Concurrency(3); n != 3 {
+		t.Fatalf("expected default, got %d", n)
+	}
+	SetConcurrency(5)
+	if n := Concurrency(3); n != 5 {
+		t.Fatalf("expected 5, got %d", n)
+	}
+}
+
+func TestAddReadAhead(t *testing.T) {
+	defer SetAddReadAhead(0)
+
+	if n := AddReadAhead(); n != 0 {
+		t.Fatalf("expected no read-ahead, got %d", n)
+	}
+	SetAddReadAhead(4)
+	if n := AddReadAhead(); n != 4 {
+		t.Fatalf("expected 4, got %d", n)
+	}
+}
+
+func TestBenchmark(t *testing.T) {
+	res, err := Benchmark(context.Background(), []string{SHA256Stdlib, SHA256SIMD}, []int{1, 2}, 1024, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(res))
+	}
+	for _, r := range res {
+		if r.BytesPerSecond <= 0 {
+			t.Fatalf("no throughput measured for %+v", r)
+		}
+	}
+
+	if _, err := Benchmark(context.Background(), []string{"nope"}, []int{1}, 1024, time.Millisecond); err == nil {
+		t.Fatal("expected an error for an unknown implementation")
+	}
+}
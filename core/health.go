@@ -0,0 +1,128 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Components reported by IpfsNode.Readiness.
+const (
+	ComponentRepo       = "repo"
+	ComponentKeys       = "keys"
+	ComponentSwarm      = "swarm"
+	ComponentBootstrap  = "bootstrap"
+	ComponentReprovider = "reprovider"
+	// ComponentFilestoreSources is only reported with Filestore.Mounts.
+	ComponentFilestoreSources = "filestore-sources"
+	// ComponentNode is reported as not ready once the node shuts down.
+	ComponentNode = "node"
+)
+
+// ComponentStatus is the readiness of a single component of the node.
+type ComponentStatus struct {
+	Name    string
+	Ready   bool
+	Message string `json:",omitempty"`
+}
+
+// Readiness reports whether the node is ready to serve requests. The node is
+// ready when all of its components are.
+type Readiness struct {
+	Ready      bool
+	Components []ComponentStatus
+}
+
+// Live reports whether the node is running. A node that is shutting down is
+// still live until it is closed, but no longer ready.
+func (n *IpfsNode) Live() bool {
+	if n.Process == nil {
+		return false
+	}
+	select {
+	case <-n.Process.Closing():
+		return false
+	default:
+		return true
+	}
+}
+
+// Readiness checks the components the node needs to serve requests: the repo
+// is open, the keys are loaded and, when online, the swarm is listening, the
+// node is connected to the network and the reprovider is running. With
+// Filestore.Mounts, the mounts of the filestore sources must be available.
+func (n *IpfsNode) Readiness() Readiness {
+	r := Readiness{Ready: true}
+	add := func(name string, err error, msg string) {
+		st := ComponentStatus{Name: name, Ready: err == nil, Message: msg}
+		if err != nil {
+			st.Message = err.Error()
+			r.Ready = false
+		}
+		r.Components = append(r.Components, st)
+	}
+
+	cfg, err := n.Repo.Config()
+	add(ComponentRepo, err, "")
+
+	switch {
+	case n.PrivateKey == nil:
+		add(ComponentKeys, fmt.Errorf("identity key not loaded"), "")
+	case n.Repo.Keystore() == nil:
+		add(ComponentKeys, fmt.Errorf("keystore not loaded"), "")
+	default:
+		add(ComponentKeys, nil, "")
+	}
+
+	if !n.IsOnline {
+		add(ComponentSwarm, nil, "offline")
+		add(ComponentBootstrap, nil, "offline")
+		add(ComponentReprovider, nil, "offline")
+	} else {
+		if n.PeerHost == nil || len(n.PeerHost.Network().ListenAddresses()) == 0 {
+			add(ComponentSwarm, fmt.Errorf("not listening"), "")
+		} else {
+			add(ComponentSwarm, nil, "")
+		}
+
+		switch {
+		case n.PeerHost == nil:
+			add(ComponentBootstrap, fmt.Errorf("no network host"), "")
+		case len(n.PeerHost.Network().Peers()) > 0:
+			add(ComponentBootstrap, nil, fmt.Sprintf("%d peers", len(n.PeerHost.Network().Peers())))
+		case cfg != nil && len(cfg.Bootstrap) == 0 && len(cfg.Peering.Peers) == 0:
+			add(ComponentBootstrap, nil, "no bootstrap peers configured")
+		default:
+			add(ComponentBootstrap, fmt.Errorf("not connected to any peer"), "")
+		}
+
+		if n.Provider == nil {
+			add(ComponentReprovider, fmt.Errorf("not running"), "")
+		} else {
+			_, err := n.Provider.Stat()
+			add(ComponentReprovider, err, "")
+		}
+	}
+
+	if n.FilestoreSources != nil {
+		var down []string
+		mounts := n.FilestoreSources.Mounts()
+		for _, m := range mounts {
+			if !m.Available {
+				down = append(down, fmt.Sprintf("%s: %s", m.Path, m.Error))
+			}
+		}
+		if len(down) > 0 {
+			add(ComponentFilestoreSources, fmt.Errorf("unavailable mounts: %s", strings.Join(down, ", ")), "")
+		} else {
+			add(ComponentFilestoreSources, nil, fmt.Sprintf("%d mounts available", len(mounts)))
+		}
+	}
+
+	select {
+	case <-n.Draining():
+		r.Ready = false
+		r.Components = append(r.Components, ComponentStatus{Name: ComponentNode, Message: ErrShuttingDown.Error()})
+	default:
+	}
+	return r
+}
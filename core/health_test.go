@@ -0,0 +1,42 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/boxo/keystore"
+	datastore "github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/repo"
+)
+
+func TestReadiness(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{Identity: testIdentity},
+		D: syncds.MutexWrap(datastore.NewMapDatastore()),
+		K: keystore.NewMemKeystore(),
+	}
+	n, err := NewNode(context.Background(), &BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !n.Live() {
+		t.Fatal("expected node to be live")
+	}
+	readiness := n.Readiness()
+	if !readiness.Ready {
+		t.Fatalf("expected offline node to be ready: %+v", readiness)
+	}
+
+	if err := n.Shutdown(context.Background(), ShutdownOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if n.Live() {
+		t.Fatal("expected closed node not to be live")
+	}
+	if n.Readiness().Ready {
+		t.Fatal("expected closed node not to be ready")
+	}
+}
@@ -0,0 +1,334 @@
+// Package httphints bridges the providers that serve their content over HTTP.
+//
+// A node announces the URLs of the trustless gateways serving its content as
+// /http and /https multiaddrs of its addresses, which are published with its
+// provider records. An Exchange fetches the blocks it is asked for from the
+// trustless gateways announced by their providers, racing Bitswap, so that
+// the content of HTTP-only providers can be retrieved.
+package httphints
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	blockstore "github.com/ipfs/boxo/blockstore"
+	exchange "github.com/ipfs/boxo/exchange"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	ma "github.com/multiformats/go-multiaddr"
+
+	"github.com/ipfs/kubo/blocks/remotebs"
+)
+
+var log = logging.Logger("core/httphints")
+
+const (
+	// DefaultDelay is the time Bitswap is given to find a block before it
+	// is looked for on the trustless gateways of its providers.
+	DefaultDelay = time.Second
+	// DefaultMaxProviders is the number of providers looked up per block.
+	DefaultMaxProviders = 10
+	// DefaultConcurrency is the number of blocks looked for at the same time.
+	DefaultConcurrency = 16
+	// DefaultTimeout bounds the lookup and retrieval of a block.
+	DefaultTimeout = 30 * time.Second
+)
+
+// Multiaddr returns the multiaddr announcing the trustless gateway at u, such
+// as /dns/gw.example.com/tcp/443/https for https://gw.example.com. The
+// gateway must be served at the root of u.
+func Multiaddr(u string) (ma.Multiaddr, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", u, err)
+	}
+	port := parsed.Port()
+	switch parsed.Scheme {
+	case "http":
+		if port == "" {
+			port = "80"
+		}
+	case "https":
+		if port == "" {
+			port = "443"
+		}
+	default:
+		return nil, fmt.Errorf("invalid URL %q: the scheme must be http or https", u)
+	}
+	if (parsed.Path != "" && parsed.Path != "/") || parsed.RawQuery != "" || parsed.User != nil {
+		return nil, fmt.Errorf("invalid URL %q: the gateway must be served at the root of the host", u)
+	}
+
+	host := parsed.Hostname()
+	if host == "" {
+		return nil, fmt.Errorf("invalid URL %q: no host", u)
+	}
+	proto := "dns"
+	if ip := net.ParseIP(host); ip != nil {
+		proto = "ip6"
+		if ip.To4() != nil {
+			proto = "ip4"
+		}
+	}
+	return ma.NewMultiaddr(fmt.Sprintf("/%s/%s/tcp/%s/%s", proto, host, port, parsed.Scheme))
+}
+
+// URL returns the URL of the trustless gateway announced by m, and false if m
+// doesn't announce one.
+func URL(m ma.Multiaddr) (string, bool) {
+	var host, port, scheme string
+	var tls, ok bool
+	ma.ForEach(m, func(c ma.Component) bool {
+		switch c.Protocol().Code {
+		case ma.P_IP4, ma.P_DNS, ma.P_DNS4, ma.P_DNS6:
+			host = c.Value()
+		case ma.P_IP6:
+			host = "[" + c.Value() + "]"
+		case ma.P_TCP:
+			port = c.Value()
+		case ma.P_TLS, ma.P_SNI:
+			tls = true
+		case ma.P_HTTP:
+			scheme, ok = "http", true
+			if tls {
+				scheme = "https"
+			}
+			return false
+		case ma.P_HTTPS:
+			scheme, ok = "https", true
+			return false
+		default:
+			return false
+		}
+		return true
+	})
+	if !ok || host == "" || port == "" {
+		return "", false
+	}
+	if (scheme == "http" && port != "80") || (scheme == "https" && port != "443") {
+		host += ":" + port
+	}
+	return scheme + "://" + host, true
+}
+
+// URLs returns the URLs of the trustless gateways announced by addrs.
+func URLs(addrs []ma.Multiaddr) []string {
+	var urls []string
+	for _, a := range addrs {
+		if u, ok := URL(a); ok {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// Options configures an Exchange.
+type Options struct {
+	// Self is the peer ID of the node, whose provider records are skipped.
+	Self peer.ID
+	// Delay is the time Bitswap is given to find a block first.
+	Delay time.Duration
+	// MaxProviders is the number of providers looked up per block.
+	MaxProviders int
+	// Concurrency is the number of blocks looked for at the same time.
+	Concurrency int
+	// Timeout bounds the lookup and retrieval of a block.
+	Timeout time.Duration
+	// Client fetches the blocks, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Exchange wraps an exchange, Bitswap, and fetches the blocks it doesn't find
+// within Options.Delay from the trustless gateways announced by their
+// providers. The blocks fetched are passed to the wrapped exchange with
+// NotifyNewBlocks, which hands them to its pending requests.
+type Exchange struct {
+	exchange.Interface
+
+	bs     blockstore.Blockstore
+	router routing.ContentRouting
+	opts   Options
+	sem    chan struct{}
+}
+
+var _ exchange.SessionExchange = (*Exchange)(nil)
+
+// NewExchange returns an exchange fetching the blocks missing from bs that
+// inner doesn't find from the providers found with router.
+func NewExchange(inner exchange.Interface, bs blockstore.Blockstore, router routing.ContentRouting, opts Options) *Exchange {
+	if opts.Delay <= 0 {
+		opts.Delay = DefaultDelay
+	}
+	if opts.MaxProviders <= 0 {
+		opts.MaxProviders = DefaultMaxProviders
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	return &Exchange{
+		Interface: inner,
+		bs:        bs,
+		router:    router,
+		opts:      opts,
+		sem:       make(chan struct{}, opts.Concurrency),
+	}
+}
+
+// GetBlock implements exchange.Fetcher.
+func (e *Exchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return getBlock(ctx, e, c)
+}
+
+// GetBlocks implements exchange.Fetcher.
+func (e *Exchange) GetBlocks(ctx context.Context, keys []cid.Cid) (<-chan blocks.Block, error) {
+	return e.getBlocks(ctx, e.Interface, keys)
+}
+
+// NewSession implements exchange.SessionExchange.
+func (e *Exchange) NewSession(ctx context.Context) exchange.Fetcher {
+	se, ok := e.Interface.(exchange.SessionExchange)
+	if !ok {
+		return e
+	}
+	return &session{e: e, f: se.NewSession(ctx)}
+}
+
+type session struct {
+	e *Exchange
+	f exchange.Fetcher
+}
+
+func (s *session) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return getBlock(ctx, s, c)
+}
+
+func (s *session) GetBlocks(ctx context.Context, keys []cid.Cid) (<-chan blocks.Block, error) {
+	return s.e.getBlocks(ctx, s.f, keys)
+}
+
+// getBlock gets a block with the GetBlocks of f, so that the retrieval from
+// the gateways stops with it.
+func getBlock(ctx context.Context, f exchange.Fetcher, c cid.Cid) (blocks.Block, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ch, err := f.GetBlocks(ctx, []cid.Cid{c})
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case blk, ok := <-ch:
+		if !ok {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return nil, ipld.ErrNotFound{Cid: c}
+		}
+		return blk, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (e *Exchange) getBlocks(ctx context.Context, f exchange.Fetcher, keys []cid.Cid) (<-chan blocks.Block, error) {
+	ch, err := f.GetBlocks(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	go e.race(ctx, append([]cid.Cid(nil), keys...))
+	return ch, nil
+}
+
+// race looks for the keys still missing after Options.Delay on the trustless
+// gateways of their providers, until ctx is done.
+func (e *Exchange) race(ctx context.Context, keys []cid.Cid) {
+	t := time.NewTimer(e.opts.Delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-t.C:
+	}
+
+	for _, c := range keys {
+		if has, err := e.bs.Has(ctx, c); err != nil || has {
+			continue
+		}
+		select {
+		case e.sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		go func(c cid.Cid) {
+			defer func() { <-e.sem }()
+			blk, err := e.fetch(ctx, c)
+			if err != nil {
+				log.Debugf("fetching %s from the gateways of its providers: %s", c, err)
+				return
+			}
+			if err := e.Interface.NotifyNewBlocks(ctx, blk); err != nil {
+				log.Errorf("passing %s to the exchange: %s", c, err)
+			}
+		}(c)
+	}
+}
+
+// fetch fetches c from the trustless gateways of its providers, in the order
+// the providers are found.
+func (e *Exchange) fetch(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.opts.Timeout)
+	defer cancel()
+
+	for p := range e.router.FindProvidersAsync(ctx, c, e.opts.MaxProviders) {
+		if p.ID == e.opts.Self {
+			continue
+		}
+		urls := URLs(p.Addrs)
+		if len(urls) == 0 {
+			continue
+		}
+		f, err := remotebs.NewFetcher(urls, e.opts.Client)
+		if err != nil {
+			continue
+		}
+		blk, err := f.Fetch(ctx, c)
+		if err == nil {
+			return blk, nil
+		}
+		log.Debugf("fetching %s from %s: %s", c, p.ID, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, ipld.ErrNotFound{Cid: c}
+}
+
+// AppendAnnounce returns addrs, the Addresses.AppendAnnounce of the config,
+// followed by the multiaddrs announcing the trustless gateways of urls.
+func AppendAnnounce(addrs, urls []string) ([]string, error) {
+	if len(urls) == 0 {
+		return addrs, nil
+	}
+	out := append([]string(nil), addrs...)
+	for _, u := range urls {
+		m, err := Multiaddr(u)
+		if err != nil {
+			return nil, fmt.Errorf("Experimental.HTTPProviderURLs: %w", err)
+		}
+		out = append(out, m.String())
+	}
+	return out, nil
+}
@@ -0,0 +1,156 @@
+package httphints
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	bstore "github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestMultiaddr(t *testing.T) {
+	for _, tc := range []struct {
+		url, maddr, back string
+	}{
+		{"https://gw.example.com", "/dns/gw.example.com/tcp/443/https", "https://gw.example.com"},
+		{"https://gw.example.com/", "/dns/gw.example.com/tcp/443/https", "https://gw.example.com"},
+		{"http://gw.example.com:8080", "/dns/gw.example.com/tcp/8080/http", "http://gw.example.com:8080"},
+		{"http://127.0.0.1", "/ip4/127.0.0.1/tcp/80/http", "http://127.0.0.1"},
+		{"https://[::1]:8443", "/ip6/::1/tcp/8443/https", "https://[::1]:8443"},
+	} {
+		m, err := Multiaddr(tc.url)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.url, err)
+		}
+		if m.String() != tc.maddr {
+			t.Errorf("%s: expected %s, got %s", tc.url, tc.maddr, m)
+		}
+		if u, ok := URL(m); !ok || u != tc.back {
+			t.Errorf("%s: expected %s back, got %q", m, tc.back, u)
+		}
+	}
+
+	for _, u := range []string{"ftp://gw.example.com", "https://gw.example.com/ipfs", "https://gw.example.com?a=b", "https://"} {
+		if _, err := Multiaddr(u); err == nil {
+			t.Errorf("%s: expected an error", u)
+		}
+	}
+}
+
+func TestURL(t *testing.T) {
+	for _, tc := range []struct {
+		maddr, url string
+	}{
+		{"/dns4/gw.example.com/tcp/443/tls/http", "https://gw.example.com"},
+		{"/dns/gw.example.com/tcp/443/tls/sni/gw.example.com/http", "https://gw.example.com"},
+		{"/ip4/1.2.3.4/tcp/8080/http/p2p/12D3KooWGzxzKZYveHXtpG6AsrUJBcWxHBFS2HsEoGTxrMLvKXtf", "http://1.2.3.4:8080"},
+		{"/ip4/1.2.3.4/tcp/4001", ""},
+		{"/ip4/1.2.3.4/udp/4001/quic-v1", ""},
+	} {
+		u, ok := URL(ma.StringCast(tc.maddr))
+		if ok != (tc.url != "") || u != tc.url {
+			t.Errorf("%s: expected %q, got %q", tc.maddr, tc.url, u)
+		}
+	}
+}
+
+// testExchange hands the blocks passed to NotifyNewBlocks to the pending
+// GetBlocks, like Bitswap, and never finds blocks by itself.
+type testExchange struct {
+	lk    sync.Mutex
+	wants map[cid.Cid][]chan blocks.Block
+}
+
+func (e *testExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return getBlock(ctx, e, c)
+}
+
+func (e *testExchange) GetBlocks(ctx context.Context, keys []cid.Cid) (<-chan blocks.Block, error) {
+	e.lk.Lock()
+	defer e.lk.Unlock()
+	ch := make(chan blocks.Block, len(keys))
+	for _, c := range keys {
+		e.wants[c] = append(e.wants[c], ch)
+	}
+	return ch, nil
+}
+
+func (e *testExchange) NotifyNewBlocks(ctx context.Context, blks ...blocks.Block) error {
+	e.lk.Lock()
+	defer e.lk.Unlock()
+	for _, b := range blks {
+		for _, ch := range e.wants[b.Cid()] {
+			ch <- b
+		}
+		delete(e.wants, b.Cid())
+	}
+	return nil
+}
+
+func (e *testExchange) Close() error { return nil }
+
+type testRouter struct {
+	providers []peer.AddrInfo
+}
+
+func (r testRouter) Provide(context.Context, cid.Cid, bool) error { return nil }
+
+func (r testRouter) FindProvidersAsync(ctx context.Context, c cid.Cid, n int) <-chan peer.AddrInfo {
+	ch := make(chan peer.AddrInfo, len(r.providers))
+	for _, p := range r.providers {
+		ch <- p
+	}
+	close(ch)
+	return ch
+}
+
+func TestExchange(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	served := blocks.NewBlock([]byte("served over http"))
+	missing := blocks.NewBlock([]byte("missing"))
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimPrefix(r.URL.Path, "/ipfs/") != served.Cid().String() {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(served.RawData())
+	}))
+	defer s.Close()
+
+	maddr, err := Multiaddr(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := testRouter{providers: []peer.AddrInfo{
+		{ID: "bitswap-only", Addrs: []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1/tcp/4001")}},
+		{ID: "http", Addrs: []ma.Multiaddr{maddr}},
+	}}
+	bs := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	e := NewExchange(&testExchange{wants: map[cid.Cid][]chan blocks.Block{}}, bs, router, Options{Delay: 10 * time.Millisecond})
+
+	blk, err := e.GetBlock(ctx, served.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !blk.Cid().Equals(served.Cid()) {
+		t.Fatalf("got the wrong block %s", blk.Cid())
+	}
+
+	sctx, scancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer scancel()
+	if _, err := e.NewSession(sctx).GetBlock(sctx, missing.Cid()); err == nil {
+		t.Fatal("expected the block served by no provider not to be found")
+	}
+}
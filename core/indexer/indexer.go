@@ -0,0 +1,217 @@
+// Package indexer holds what the indexes of the local content have in common:
+// the loop that updates an index in the background when MFS or the pins
+// change, the walk of the UnixFS DAGs stored locally, and the state that lets
+// an update of the MFS tree only walk the directories that changed.
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log"
+)
+
+// Index is an index of MFS, updated by a Loop.
+type Index interface {
+	// IndexMFS updates the index to the MFS tree under root.
+	IndexMFS(ctx context.Context, root cid.Cid) error
+}
+
+// PinIndex is an Index that also indexes the recursive pins.
+type PinIndex interface {
+	Index
+	// IndexPins updates the index to the current recursive pins.
+	IndexPins(ctx context.Context) error
+}
+
+// Loop updates an index in the background.
+type Loop struct {
+	idx Index
+	log logging.StandardLogger
+
+	lk      sync.Mutex
+	mfsRoot cid.Cid
+
+	mfsUpdated  chan struct{}
+	pinsUpdated chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start starts updating idx in the background, logging the errors to log.
+// When idx is a PinIndex, the pins are indexed right away, then every
+// pinsInterval in case they changed without the loop being notified.
+func Start(idx Index, log logging.StandardLogger, pinsInterval time.Duration) *Loop {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &Loop{
+		idx:         idx,
+		log:         log,
+		mfsUpdated:  make(chan struct{}, 1),
+		pinsUpdated: make(chan struct{}, 1),
+		ctx:         ctx,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+	pins, _ := idx.(PinIndex)
+	if pins != nil {
+		l.UpdatePins()
+	}
+	go l.run(pins, pinsInterval)
+	return l
+}
+
+// Close stops updating the index.
+func (l *Loop) Close() error {
+	l.cancel()
+	<-l.done
+	return nil
+}
+
+// UpdateMFS schedules the indexing of the MFS tree under root. When MFS
+// changes quickly, only the latest root is indexed.
+func (l *Loop) UpdateMFS(root cid.Cid) {
+	l.lk.Lock()
+	l.mfsRoot = root
+	l.lk.Unlock()
+	notify(l.mfsUpdated)
+}
+
+// UpdatePins schedules the indexing of the recursive pins. It does nothing
+// for an index that isn't a PinIndex.
+func (l *Loop) UpdatePins() {
+	notify(l.pinsUpdated)
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func (l *Loop) run(pins PinIndex, pinsInterval time.Duration) {
+	defer close(l.done)
+
+	var tick <-chan time.Time
+	if pins != nil && pinsInterval > 0 {
+		ticker := time.NewTicker(pinsInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		var err error
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-l.mfsUpdated:
+			l.lk.Lock()
+			root := l.mfsRoot
+			l.lk.Unlock()
+			if err = l.idx.IndexMFS(l.ctx, root); err != nil {
+				err = fmt.Errorf("MFS root %s: %w", root, err)
+			}
+		case <-l.pinsUpdated:
+			if pins != nil {
+				err = pins.IndexPins(l.ctx)
+			}
+		case <-tick:
+			err = pins.IndexPins(l.ctx)
+		}
+		if err != nil && l.ctx.Err() == nil {
+			l.log.Errorf("indexing: %s", err)
+		}
+	}
+}
+
+// Indexed is the state of an MFS path at the last update of an index.
+type Indexed struct {
+	Cid cid.Cid
+	// Incomplete is set on the directories whose content was not entirely
+	// stored locally when they were indexed.
+	Incomplete bool
+}
+
+// Tree tracks the MFS paths of an index during an update, to skip the ones
+// that didn't change and to find the ones that were removed.
+type Tree struct {
+	indexed map[string]Indexed
+	paths   []string
+	stale   map[string]struct{}
+}
+
+// NewTree returns the tree of the paths indexed by the last update.
+func NewTree(indexed map[string]Indexed) *Tree {
+	t := &Tree{
+		indexed: indexed,
+		paths:   make([]string, 0, len(indexed)),
+		stale:   make(map[string]struct{}, len(indexed)),
+	}
+	for p := range indexed {
+		t.paths = append(t.paths, p)
+		t.stale[p] = struct{}{}
+	}
+	sort.Strings(t.paths)
+	return t
+}
+
+// Skip reports whether the content at p is indexed already, as c. The path
+// and everything under it are then kept. It is meant for Walk.Skip.
+func (t *Tree) Skip(p string, c cid.Cid) bool {
+	old, ok := t.indexed[p]
+	if !ok || !old.Cid.Equals(c) || old.Incomplete {
+		return false
+	}
+	delete(t.stale, p)
+	for i := sort.SearchStrings(t.paths, p+"/"); i < len(t.paths) && strings.HasPrefix(t.paths[i], p+"/"); i++ {
+		delete(t.stale, t.paths[i])
+	}
+	return true
+}
+
+// Keep records that p is still in MFS.
+func (t *Tree) Keep(p string) {
+	delete(t.stale, p)
+}
+
+// Stale returns the paths that are no longer in MFS.
+func (t *Tree) Stale() []string {
+	stale := make([]string, 0, len(t.stale))
+	for p := range t.stale {
+		stale = append(stale, p)
+	}
+	return stale
+}
+
+// Unchanged reports whether root is the MFS root recorded under key by
+// RecordRoot.
+func Unchanged(ctx context.Context, ds datastore.Read, key datastore.Key, root cid.Cid) (bool, error) {
+	buf, err := ds.Get(ctx, key)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	last, err := cid.Cast(buf)
+	return err == nil && last.Equals(root), nil
+}
+
+// RecordRoot records root under key once the MFS tree under it is completely
+// indexed. Otherwise it clears the record, for the next update to walk the
+// incomplete directories again.
+func RecordRoot(ctx context.Context, b datastore.Write, key datastore.Key, root cid.Cid, complete bool) error {
+	if complete {
+		return b.Put(ctx, key, root.Bytes())
+	}
+	return b.Delete(ctx, key)
+}
@@ -0,0 +1,105 @@
+package indexer
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func testCid(t *testing.T, s string) cid.Cid {
+	h, err := mh.Sum([]byte(s), mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cid.NewCidV1(cid.Raw, h)
+}
+
+func TestTree(t *testing.T) {
+	a, b, c := testCid(t, "a"), testCid(t, "b"), testCid(t, "c")
+	tree := NewTree(map[string]Indexed{
+		"/dir":          {Cid: a},
+		"/dir/file":     {Cid: b},
+		"/partial":      {Cid: c, Incomplete: true},
+		"/removed":      {Cid: c},
+		"/directory":    {Cid: b},
+		"/dir2/nested":  {Cid: c},
+		"/changed":      {Cid: a},
+		"/changed/file": {Cid: b},
+	})
+
+	if !tree.Skip("/dir", a) {
+		t.Fatal("unchanged directory not skipped")
+	}
+	if tree.Skip("/partial", c) {
+		t.Fatal("incomplete directory skipped")
+	}
+	tree.Keep("/partial")
+	if tree.Skip("/changed", b) {
+		t.Fatal("changed directory skipped")
+	}
+	tree.Keep("/changed")
+	tree.Keep("/directory")
+
+	stale := tree.Stale()
+	sort.Strings(stale)
+	want := []string{"/changed/file", "/dir2/nested", "/removed"}
+	if len(stale) != len(want) {
+		t.Fatalf("expected %v stale, got %v", want, stale)
+	}
+	for i := range want {
+		if stale[i] != want[i] {
+			t.Fatalf("expected %v stale, got %v", want, stale)
+		}
+	}
+}
+
+type pinIndex struct {
+	mfs  chan cid.Cid
+	pins chan struct{}
+}
+
+func (idx *pinIndex) IndexMFS(_ context.Context, root cid.Cid) error {
+	idx.mfs <- root
+	return nil
+}
+
+func (idx *pinIndex) IndexPins(context.Context) error {
+	idx.pins <- struct{}{}
+	return nil
+}
+
+func TestLoop(t *testing.T) {
+	idx := &pinIndex{mfs: make(chan cid.Cid, 1), pins: make(chan struct{}, 1)}
+	l := Start(idx, logging.Logger("indexer"), time.Hour)
+	defer l.Close()
+
+	wait := func(ch <-chan struct{}) {
+		t.Helper()
+		select {
+		case <-ch:
+		case <-time.After(10 * time.Second):
+			t.Fatal("not indexed")
+		}
+	}
+	// the pins are indexed when the loop starts
+	wait(idx.pins)
+
+	root := testCid(t, "root")
+	l.UpdateMFS(root)
+	select {
+	case got := <-idx.mfs:
+		if !got.Equals(root) {
+			t.Fatalf("indexed %s, expected %s", got, root)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("MFS not indexed")
+	}
+
+	l.UpdatePins()
+	wait(idx.pins)
+}
@@ -0,0 +1,86 @@
+package indexer
+
+import (
+	"context"
+	gopath "path"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/go-cid"
+	format "github.com/ipfs/go-ipld-format"
+)
+
+// Walk walks the files and directories of a UnixFS DAG. Symlinks and
+// non-UnixFS nodes are ignored.
+type Walk struct {
+	// DAG is what the content is read from. It should not fetch missing
+	// blocks from the network: content that isn't stored locally is not
+	// indexed.
+	DAG format.DAGService
+	// Skip, when set, reports whether the content at a path is already
+	// indexed. It isn't walked then.
+	Skip func(p string, c cid.Cid) bool
+	// File is called with the files. It returns false when the part of the
+	// file it needs isn't stored locally.
+	File func(ctx context.Context, p string, nd format.Node) (bool, error)
+	// Dir is called with the directories, after their content. complete is
+	// false when some of it isn't stored locally.
+	Dir func(ctx context.Context, p string, nd format.Node, complete bool) error
+}
+
+// Run walks the content under c, at path p. It returns false when some of it
+// isn't stored locally.
+func (w *Walk) Run(ctx context.Context, p string, c cid.Cid) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if w.Skip != nil && w.Skip(p, c) {
+		return true, nil
+	}
+	nd, err := w.DAG.Get(ctx, c)
+	if err != nil {
+		if format.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	switch nd := nd.(type) {
+	case *merkledag.RawNode:
+		return w.File(ctx, p, nd)
+	case *merkledag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return true, nil
+		}
+		switch fsn.Type() {
+		case ft.TFile, ft.TRaw:
+			return w.File(ctx, p, nd)
+		case ft.TDirectory, ft.THAMTShard:
+		default:
+			return true, nil
+		}
+	default:
+		return true, nil
+	}
+
+	dir, err := uio.NewDirectoryFromNode(w.DAG, nd)
+	if err != nil {
+		return false, err
+	}
+	complete := true
+	err = dir.ForEachLink(ctx, func(l *format.Link) error {
+		ok, err := w.Run(ctx, gopath.Join(p, l.Name), l.Cid)
+		complete = complete && ok
+		return err
+	})
+	if format.IsNotFound(err) {
+		// a missing shard of a sharded directory
+		complete, err = false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return complete, w.Dir(ctx, p, nd, complete)
+}
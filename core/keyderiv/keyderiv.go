@@ -0,0 +1,123 @@
+// Package keyderiv derives ed25519 IPNS keys from a seed phrase, along
+// BIP32-style hardened paths (SLIP-0010), so that all the keys of a user can
+// be regenerated from one backed up seed phrase.
+//
+// The seed phrase is never stored. The derivation path of each derived key is
+// kept in the datastore, next to the keystore, to be listed with the keys.
+package keyderiv
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// DatastoreKey is the prefix under which the derivation paths of the keys are
+// stored.
+var DatastoreKey = datastore.NewKey("/local/keyderiv")
+
+// hardened is the offset of the hardened indexes. SLIP-0010 only defines
+// hardened derivation for ed25519.
+const hardened = 1 << 31
+
+// SeedFromPhrase returns the seed of a BIP39 seed phrase, protected by an
+// optional passphrase. The words of the phrase are separated by single
+// spaces, and the phrase is not checked against a wordlist, so any phrase
+// works.
+func SeedFromPhrase(phrase, passphrase string) ([]byte, error) {
+	phrase = strings.Join(strings.Fields(phrase), " ")
+	if phrase == "" {
+		return nil, errors.New("empty seed phrase")
+	}
+	return pbkdf2.Key([]byte(phrase), []byte("mnemonic"+passphrase), 2048, 64, sha512.New), nil
+}
+
+// ParsePath parses a derivation path such as "m/0'/1'". All the indexes must
+// be hardened, with a ' or an h.
+func ParsePath(p string) ([]uint32, error) {
+	parts := strings.Split(p, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %q: must start with m", p)
+	}
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		s := strings.TrimRight(part, "'h")
+		if len(part)-len(s) != 1 {
+			return nil, fmt.Errorf("invalid derivation path %q: %q is not a hardened index", p, part)
+		}
+		i, err := strconv.ParseUint(s, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid derivation path %q: %w", p, err)
+		}
+		indexes = append(indexes, uint32(i)+hardened)
+	}
+	return indexes, nil
+}
+
+// NamePath returns the derivation path of the key named name, when none is
+// given, so that the keys can be regenerated from their names.
+func NamePath(name string) string {
+	h := sha256.Sum256([]byte(name))
+	return fmt.Sprintf("m/0'/%d'", binary.BigEndian.Uint32(h[:4])&(hardened-1))
+}
+
+// Derive derives the ed25519 key of the derivation path p from seed.
+func Derive(seed []byte, p string) (crypto.PrivKey, error) {
+	indexes, err := ParsePath(p)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha512.New, []byte("ed25519 seed"))
+	mac.Write(seed)
+	i := mac.Sum(nil)
+	key, chain := i[:32], i[32:]
+	for _, index := range indexes {
+		data := make([]byte, 0, 37)
+		data = append(data, 0)
+		data = append(data, key...)
+		data = binary.BigEndian.AppendUint32(data, index)
+
+		mac := hmac.New(sha512.New, chain)
+		mac.Write(data)
+		i := mac.Sum(nil)
+		key, chain = i[:32], i[32:]
+	}
+
+	return crypto.UnmarshalEd25519PrivateKey(ed25519.NewKeyFromSeed(key))
+}
+
+func pathKey(name string) datastore.Key {
+	return DatastoreKey.ChildString(name)
+}
+
+// SetPath records the derivation path of the key named name.
+func SetPath(ctx context.Context, ds datastore.Datastore, name, p string) error {
+	return ds.Put(ctx, pathKey(name), []byte(p))
+}
+
+// GetPath returns the derivation path of the key named name, or "" for keys
+// that were not derived.
+func GetPath(ctx context.Context, ds datastore.Datastore, name string) (string, error) {
+	p, err := ds.Get(ctx, pathKey(name))
+	if err == datastore.ErrNotFound {
+		return "", nil
+	}
+	return string(p), err
+}
+
+// DeletePath forgets the derivation path of the key named name, if any.
+func DeletePath(ctx context.Context, ds datastore.Datastore, name string) error {
+	return ds.Delete(ctx, pathKey(name))
+}
@@ -0,0 +1,86 @@
+package keyderiv
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	crypto_pb "github.com/libp2p/go-libp2p/core/crypto/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeedFromPhrase(t *testing.T) {
+	// BIP39 test vector
+	seed, err := SeedFromPhrase("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "TREZOR")
+	require.NoError(t, err)
+	require.Equal(t, "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04", hex.EncodeToString(seed))
+
+	// the whitespace doesn't matter
+	again, err := SeedFromPhrase("  abandon abandon abandon abandon abandon abandon\nabandon abandon abandon abandon abandon about\n", "TREZOR")
+	require.NoError(t, err)
+	require.Equal(t, seed, again)
+
+	_, err = SeedFromPhrase(" \n", "")
+	require.Error(t, err)
+}
+
+func TestParsePath(t *testing.T) {
+	indexes, err := ParsePath("m/0'/1h/2'")
+	require.NoError(t, err)
+	require.Equal(t, []uint32{hardened, hardened + 1, hardened + 2}, indexes)
+
+	for _, p := range []string{"", "0'/1'", "m/0", "m/0''", "m//1'", "m/x'", "m/2147483648'"} {
+		_, err := ParsePath(p)
+		require.Error(t, err, p)
+	}
+}
+
+func TestDerive(t *testing.T) {
+	// SLIP-0010 test vector 1 for ed25519
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	for _, c := range []struct {
+		path string
+		key  string
+	}{
+		{"m", "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7"},
+		{"m/0'", "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3"},
+		{"m/0'/1'", "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2"},
+		{"m/0'/1'/2'/2'/1000000000'", "8f94d394a8e8fd6b1bc2f3f49f5c47e385281d5c17e65324b0f62483e37e8793"},
+	} {
+		sk, err := Derive(seed, c.path)
+		require.NoError(t, err)
+		raw, err := sk.Raw()
+		require.NoError(t, err)
+		require.Equal(t, c.key, hex.EncodeToString(ed25519.PrivateKey(raw).Seed()), c.path)
+		require.Equal(t, crypto_pb.KeyType_Ed25519, sk.Type())
+	}
+}
+
+func TestNamePath(t *testing.T) {
+	p := NamePath("example.com")
+	require.Equal(t, p, NamePath("example.com"))
+	require.NotEqual(t, p, NamePath("example.org"))
+	_, err := ParsePath(p)
+	require.NoError(t, err)
+}
+
+func TestPaths(t *testing.T) {
+	ctx := context.Background()
+	ds := datastore.NewMapDatastore()
+
+	p, err := GetPath(ctx, ds, "site")
+	require.NoError(t, err)
+	require.Empty(t, p)
+
+	require.NoError(t, SetPath(ctx, ds, "site", "m/0'/1'"))
+	p, err = GetPath(ctx, ds, "site")
+	require.NoError(t, err)
+	require.Equal(t, "m/0'/1'", p)
+
+	require.NoError(t, DeletePath(ctx, ds, "site"))
+	p, err = GetPath(ctx, ds, "site")
+	require.NoError(t, err)
+	require.Empty(t, p)
+}
@@ -0,0 +1,655 @@
+// Package kv replicates key-value stores over pubsub, so that the apps of a
+// set of nodes share mutable state without running their own replication.
+//
+// A store is a map of last-writer-wins registers, a CRDT: each write is
+// signed by the key of its writer and stamped with a Lamport clock, and the
+// replicas keep the write of the highest clock of each key, ties broken by
+// the writers. Writes can be received in any order and any number of times,
+// the replicas converge to the same state. Deletes are writes of tombstones.
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var log = logging.Logger("kv")
+
+// DatastoreKey is the key the entries of the stores are stored under.
+var DatastoreKey = datastore.NewKey("/kv")
+
+// TopicPrefix is the prefix of the pubsub topics of the stores, followed by
+// their name.
+const TopicPrefix = "/ipfs/kv/"
+
+// signaturePrefix separates the signatures of the entries from the ones of
+// other data signed by the same keys.
+const signaturePrefix = "ipfs-kv:"
+
+const (
+	// syncDelay gathers the peers joining a topic at once into a single
+	// request of their entries.
+	syncDelay = time.Second
+	// syncInterval is how often the entries of a store are sent to the
+	// peers requesting them, at most.
+	syncInterval = 10 * time.Second
+	// subscriptionBuffer is the number of entries buffered for each
+	// subscriber. The entries a subscriber doesn't read in time are dropped.
+	subscriptionBuffer = 32
+)
+
+var (
+	// ErrNotFound is returned for the keys without a value.
+	ErrNotFound = errors.New("key not found")
+	// ErrClosed is returned by the stores of a closed Service.
+	ErrClosed = errors.New("kv service closed")
+)
+
+// Entry is a write of a key of a store.
+type Entry struct {
+	Store   string
+	Key     string
+	Value   []byte `json:",omitempty"`
+	Deleted bool   `json:",omitempty"`
+	// Clock is the Lamport clock of the write.
+	Clock  uint64
+	Writer peer.ID
+	// PublicKey is the public key of the writer, for the keys not embedded
+	// in their peer ID, such as RSA keys.
+	PublicKey []byte `json:",omitempty"`
+	Signature []byte
+}
+
+// signed is the part of an entry covered by its signature.
+type signed struct {
+	Store   string
+	Key     string
+	Value   []byte `json:",omitempty"`
+	Deleted bool   `json:",omitempty"`
+	Clock   uint64
+	Writer  peer.ID
+}
+
+func (e *Entry) signedBytes() ([]byte, error) {
+	b, err := json.Marshal(signed{
+		Store:   e.Store,
+		Key:     e.Key,
+		Value:   e.Value,
+		Deleted: e.Deleted,
+		Clock:   e.Clock,
+		Writer:  e.Writer,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(signaturePrefix), b...), nil
+}
+
+// Sign sets the writer of e to the peer ID of sk, and signs e with sk.
+func (e *Entry) Sign(sk ci.PrivKey) error {
+	pid, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return err
+	}
+	e.Writer = pid
+	e.PublicKey = nil
+	if _, err := pid.ExtractPublicKey(); err != nil {
+		if e.PublicKey, err = ci.MarshalPublicKey(sk.GetPublic()); err != nil {
+			return err
+		}
+	}
+	b, err := e.signedBytes()
+	if err != nil {
+		return err
+	}
+	e.Signature, err = sk.Sign(b)
+	return err
+}
+
+// Verify returns an error when e is invalid, or not signed by its writer.
+func (e *Entry) Verify() error {
+	if err := ValidName(e.Store); err != nil {
+		return err
+	}
+	if e.Key == "" {
+		return errors.New("empty key")
+	}
+	pk, err := e.Writer.ExtractPublicKey()
+	if err != nil {
+		if pk, err = ci.UnmarshalPublicKey(e.PublicKey); err != nil {
+			return fmt.Errorf("public key: %w", err)
+		}
+		if !e.Writer.MatchesPublicKey(pk) {
+			return errors.New("public key doesn't match the writer")
+		}
+	}
+	b, err := e.signedBytes()
+	if err != nil {
+		return err
+	}
+	ok, err := pk.Verify(b, e.Signature)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// Wins returns whether e wins over other, a write of the same key: the write
+// of the highest clock wins, ties are broken by the writers, and then by the
+// values, so that all the replicas pick the same write.
+func (e *Entry) Wins(other *Entry) bool {
+	if e.Clock != other.Clock {
+		return e.Clock > other.Clock
+	}
+	if e.Writer != other.Writer {
+		return e.Writer > other.Writer
+	}
+	if e.Deleted != other.Deleted {
+		return e.Deleted
+	}
+	return bytes.Compare(e.Value, other.Value) > 0
+}
+
+// ValidName returns an error when name can't be used as the name of a store:
+// names are non-empty path segments.
+func ValidName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		return fmt.Errorf("invalid store name %q", name)
+	}
+	return nil
+}
+
+// message is what is published on the topic of a store.
+type message struct {
+	// Entries are writes of the store.
+	Entries []Entry `json:",omitempty"`
+	// Sync requests the entries of the store from the other peers.
+	Sync bool `json:",omitempty"`
+}
+
+// Service replicates the stores of a node. The stores are joined on first
+// use, or at start for the ones with entries, and stay joined until Close.
+type Service struct {
+	ps   *pubsub.PubSub
+	ds   datastore.Datastore
+	sk   ci.PrivKey
+	self peer.ID
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	lk     sync.Mutex
+	stores map[string]*store
+}
+
+// store is a joined store.
+type store struct {
+	s     *Service
+	name  string
+	topic *pubsub.Topic
+	sub   *pubsub.Subscription
+	ev    *pubsub.TopicEventHandler
+
+	lk       sync.Mutex
+	clock    uint64
+	lastSync time.Time
+	subs     map[chan Entry]struct{}
+}
+
+// New returns the Service of the stores persisted in ds, written with sk by
+// default, and joins the topics of the stores with entries.
+func New(ps *pubsub.PubSub, ds datastore.Datastore, sk ci.PrivKey) (*Service, error) {
+	self, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	s := &Service{
+		ps:     ps,
+		ds:     ds,
+		sk:     sk,
+		self:   self,
+		stores: make(map[string]*store),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	res, err := ds.Query(s.ctx, query.Query{Prefix: DatastoreKey.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]struct{})
+	for _, e := range entries {
+		if ns := datastore.RawKey(e.Key).Namespaces(); len(ns) == 3 {
+			names[ns[1]] = struct{}{}
+		}
+	}
+	for name := range names {
+		if _, err := s.store(name); err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// store returns the store name, joining it if needed.
+func (s *Service) store(name string) (*store, error) {
+	if err := ValidName(name); err != nil {
+		return nil, err
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.ctx.Err() != nil {
+		return nil, ErrClosed
+	}
+	if st, ok := s.stores[name]; ok {
+		return st, nil
+	}
+
+	st := &store{s: s, name: name, subs: make(map[chan Entry]struct{})}
+	entries, err := st.entries(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Clock > st.clock {
+			st.clock = e.Clock
+		}
+	}
+
+	topic := TopicPrefix + name
+	err = s.ps.RegisterTopicValidator(topic, func(_ context.Context, _ peer.ID, msg *pubsub.Message) bool {
+		_, err := st.open(msg.Data)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if st.topic, err = s.ps.Join(topic); err != nil {
+		_ = s.ps.UnregisterTopicValidator(topic)
+		return nil, err
+	}
+	if st.sub, err = st.topic.Subscribe(); err != nil {
+		st.close()
+		return nil, err
+	}
+	if st.ev, err = st.topic.EventHandler(); err != nil {
+		st.close()
+		return nil, err
+	}
+
+	s.stores[name] = st
+	s.wg.Add(2)
+	go st.run()
+	go st.syncOnJoin()
+	return st, nil
+}
+
+// Put writes value to key in the store name, signed with sk, or the key of
+// the node when sk is nil.
+func (s *Service) Put(ctx context.Context, name, key string, value []byte, sk ci.PrivKey) (Entry, error) {
+	return s.write(ctx, name, Entry{Key: key, Value: value}, sk)
+}
+
+// Delete deletes key from the store name, signed with sk, or the key of the
+// node when sk is nil.
+func (s *Service) Delete(ctx context.Context, name, key string, sk ci.PrivKey) (Entry, error) {
+	return s.write(ctx, name, Entry{Key: key, Deleted: true}, sk)
+}
+
+func (s *Service) write(ctx context.Context, name string, e Entry, sk ci.PrivKey) (Entry, error) {
+	if e.Key == "" {
+		return Entry{}, errors.New("empty key")
+	}
+	st, err := s.store(name)
+	if err != nil {
+		return Entry{}, err
+	}
+	if sk == nil {
+		sk = s.sk
+	}
+
+	st.lk.Lock()
+	st.clock++
+	e.Store, e.Clock = name, st.clock
+	st.lk.Unlock()
+	if err := e.Sign(sk); err != nil {
+		return Entry{}, err
+	}
+
+	if _, err := st.apply(ctx, e); err != nil {
+		return Entry{}, err
+	}
+	return e, st.publish(ctx, message{Entries: []Entry{e}})
+}
+
+// Get returns the last write of key in the store name. It returns
+// ErrNotFound when key has no value, or was deleted.
+func (s *Service) Get(ctx context.Context, name, key string) (Entry, error) {
+	st, err := s.store(name)
+	if err != nil {
+		return Entry{}, err
+	}
+	e, err := st.get(ctx, key)
+	if err != nil {
+		return Entry{}, err
+	}
+	if e.Deleted {
+		return Entry{}, ErrNotFound
+	}
+	return e, nil
+}
+
+// List returns the entries of the store name, sorted by key, without the
+// deleted keys.
+func (s *Service) List(ctx context.Context, name string) ([]Entry, error) {
+	st, err := s.store(name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := st.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := entries[:0]
+	for _, e := range entries {
+		if !e.Deleted {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+// Subscribe returns the writes applied to the store name, local or received
+// from the other peers, until ctx is done.
+func (s *Service) Subscribe(ctx context.Context, name string) (<-chan Entry, error) {
+	st, err := s.store(name)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan Entry, subscriptionBuffer)
+	st.lk.Lock()
+	st.subs[ch] = struct{}{}
+	st.lk.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.ctx.Done():
+		}
+		st.lk.Lock()
+		delete(st.subs, ch)
+		st.lk.Unlock()
+		close(ch)
+	}()
+	return ch, nil
+}
+
+// Stores returns the names of the joined stores, sorted.
+func (s *Service) Stores() []string {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	names := make([]string, 0, len(s.stores))
+	for name := range s.stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close leaves the topics of the stores.
+func (s *Service) Close() error {
+	s.cancel()
+	s.lk.Lock()
+	stores := s.stores
+	s.stores = nil
+	s.lk.Unlock()
+
+	var err error
+	for _, st := range stores {
+		st.sub.Cancel()
+		st.ev.Cancel()
+	}
+	s.wg.Wait()
+	for _, st := range stores {
+		if cerr := st.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (st *store) close() error {
+	topic := TopicPrefix + st.name
+	var err error
+	if st.topic != nil {
+		err = st.topic.Close()
+	}
+	if uerr := st.s.ps.UnregisterTopicValidator(topic); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+// datastoreKey returns the datastore key of key. The keys are encoded, as
+// they may contain slashes.
+func (st *store) datastoreKey(key string) datastore.Key {
+	return DatastoreKey.ChildString(st.name).ChildString(base64.RawURLEncoding.EncodeToString([]byte(key)))
+}
+
+func (st *store) get(ctx context.Context, key string) (Entry, error) {
+	buf, err := st.s.ds.Get(ctx, st.datastoreKey(key))
+	if err == datastore.ErrNotFound {
+		return Entry{}, ErrNotFound
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	var e Entry
+	err = json.Unmarshal(buf, &e)
+	return e, err
+}
+
+// entries returns all the entries of the store, including the deleted keys.
+func (st *store) entries(ctx context.Context) ([]Entry, error) {
+	res, err := st.s.ds.Query(ctx, query.Query{Prefix: DatastoreKey.ChildString(st.name).String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var out []Entry
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var e Entry
+		if err := json.Unmarshal(r.Value, &e); err != nil {
+			log.Errorf("invalid entry %s: %s", r.Key, err)
+			continue
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// apply stores e unless the store holds a winning write of its key, and
+// returns whether it was stored.
+func (st *store) apply(ctx context.Context, e Entry) (bool, error) {
+	st.lk.Lock()
+	defer st.lk.Unlock()
+
+	cur, err := st.get(ctx, e.Key)
+	switch err {
+	case nil:
+		if !e.Wins(&cur) {
+			return false, nil
+		}
+	case ErrNotFound:
+	default:
+		return false, err
+	}
+
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return false, err
+	}
+	if err := st.s.ds.Put(ctx, st.datastoreKey(e.Key), buf); err != nil {
+		return false, err
+	}
+	if e.Clock > st.clock {
+		st.clock = e.Clock
+	}
+	for ch := range st.subs {
+		select {
+		case ch <- e:
+		default:
+			log.Warnf("dropping the write of %s/%s for a slow subscriber", st.name, e.Key)
+		}
+	}
+	return true, nil
+}
+
+func (st *store) publish(ctx context.Context, m message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return st.topic.Publish(ctx, data)
+}
+
+// open decodes and verifies a message of the topic.
+func (st *store) open(data []byte) (message, error) {
+	var m message
+	if err := json.Unmarshal(data, &m); err != nil {
+		return message{}, err
+	}
+	for i := range m.Entries {
+		e := &m.Entries[i]
+		if e.Store != st.name {
+			return message{}, fmt.Errorf("entry of store %q on the topic of %q", e.Store, st.name)
+		}
+		if err := e.Verify(); err != nil {
+			return message{}, err
+		}
+	}
+	return m, nil
+}
+
+func (st *store) run() {
+	defer st.s.wg.Done()
+	for {
+		msg, err := st.sub.Next(st.s.ctx)
+		if err != nil {
+			return
+		}
+		if msg.ReceivedFrom == st.s.self {
+			continue
+		}
+		m, err := st.open(msg.Data)
+		if err != nil {
+			log.Debugf("ignoring message from %s: %s", msg.ReceivedFrom, err)
+			continue
+		}
+		for _, e := range m.Entries {
+			if _, err := st.apply(st.s.ctx, e); err != nil {
+				log.Errorf("applying the write of %s/%s by %s: %s", st.name, e.Key, e.Writer, err)
+			}
+		}
+		if m.Sync {
+			st.sendEntries()
+		}
+	}
+}
+
+// sendEntries publishes the entries of the store, for the peers requesting
+// them, at most once per syncInterval.
+func (st *store) sendEntries() {
+	st.lk.Lock()
+	if time.Since(st.lastSync) < syncInterval {
+		st.lk.Unlock()
+		return
+	}
+	st.lastSync = time.Now()
+	st.lk.Unlock()
+
+	entries, err := st.entries(st.s.ctx)
+	if err != nil {
+		log.Errorf("listing the entries of %s: %s", st.name, err)
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+	if err := st.publish(st.s.ctx, message{Entries: entries}); err != nil {
+		log.Errorf("publishing the entries of %s: %s", st.name, err)
+	}
+}
+
+// syncOnJoin requests the entries of the store from the peers joining its
+// topic, which includes the peers already there when the node joins it, so
+// that the replicas catch up with the writes they missed.
+func (st *store) syncOnJoin() {
+	defer st.s.wg.Done()
+
+	joined := make(chan struct{}, 1)
+	go func() {
+		for {
+			ev, err := st.ev.NextPeerEvent(st.s.ctx)
+			if err != nil {
+				return
+			}
+			if ev.Type == pubsub.PeerJoin {
+				select {
+				case joined <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-joined:
+		case <-st.s.ctx.Done():
+			return
+		}
+		select {
+		case <-time.After(syncDelay):
+		case <-st.s.ctx.Done():
+			return
+		}
+		// drain the peers joined while waiting
+		select {
+		case <-joined:
+		default:
+		}
+		if err := st.publish(st.s.ctx, message{Sync: true}); err != nil {
+			log.Debugf("requesting the entries of %s: %s", st.name, err)
+		}
+	}
+}
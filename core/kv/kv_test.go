@@ -0,0 +1,67 @@
+package kv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	sk, _, err := ci.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	// the public key of ECDSA keys is not embedded in their peer ID
+	ecdsa, _, err := ci.GenerateECDSAKeyPair(rand.Reader)
+	require.NoError(t, err)
+
+	for _, k := range []ci.PrivKey{sk, ecdsa} {
+		e := Entry{Store: "app", Key: "a/b", Value: []byte("v"), Clock: 3}
+		require.NoError(t, e.Sign(k))
+		require.True(t, e.Writer.MatchesPrivateKey(k))
+		require.NoError(t, e.Verify())
+
+		tampered := e
+		tampered.Value = []byte("w")
+		require.Error(t, tampered.Verify())
+
+		tampered = e
+		tampered.Clock++
+		require.Error(t, tampered.Verify())
+	}
+
+	e := Entry{Store: "a/b", Key: "k"}
+	require.NoError(t, e.Sign(sk))
+	require.Error(t, e.Verify(), "invalid store name")
+}
+
+func TestWins(t *testing.T) {
+	a := Entry{Clock: 2, Writer: "a", Value: []byte("x")}
+	b := Entry{Clock: 1, Writer: "b", Value: []byte("y")}
+	require.True(t, a.Wins(&b))
+	require.False(t, b.Wins(&a))
+
+	// the same clock: the writers break the tie
+	b.Clock = 2
+	require.True(t, b.Wins(&a))
+	require.False(t, a.Wins(&b))
+
+	// a write doesn't win over itself, so that replays are ignored
+	require.False(t, a.Wins(&a))
+
+	// the same clock and writer: the deletes win, then the values
+	del := Entry{Clock: 2, Writer: "a", Deleted: true}
+	require.True(t, del.Wins(&a))
+	require.False(t, a.Wins(&del))
+	c := Entry{Clock: 2, Writer: "a", Value: []byte("z")}
+	require.True(t, c.Wins(&a))
+}
+
+func TestValidName(t *testing.T) {
+	for _, name := range []string{"app", "my-app.v1"} {
+		require.NoError(t, ValidName(name))
+	}
+	for _, name := range []string{"", ".", "..", "a/b", `a\b`} {
+		require.Error(t, ValidName(name), name)
+	}
+}
@@ -0,0 +1,290 @@
+// Package localfiles applies the ignore rules, the hidden entries and the
+// symlink policy of Unixfs.Add to the files and directories being added, in
+// the process that reads them: the adder of the node, or the RPC client
+// before sending them.
+//
+// The directories read from the local filesystem by NewSerialFile tell their
+// path through files.FileInfo, so that their hidden entries can be included
+// and their symlinks followed whatever they were built with. The other
+// directories, such as the ones of files.NewSerialFile or the ones sent over
+// the HTTP API, are only filtered: their entries are added as they list them,
+// less the skipped ones.
+package localfiles
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	gopath "path"
+	"path/filepath"
+	"strings"
+
+	ignore "github.com/crackcomm/go-gitignore"
+	"github.com/ipfs/boxo/files"
+
+	"github.com/ipfs/kubo/core/coreiface/options"
+)
+
+// Policy is how the files and directories being added are read.
+type Policy struct {
+	// Rules, when set, skips the entries matching them, by their path
+	// relative to the added directory. Directories also match the rules
+	// ending with a slash.
+	Rules *ignore.GitIgnore
+	// Hidden, when set, is whether the hidden entries, whose name starts
+	// with a dot, are added. Otherwise the entries are added as the
+	// directories list them.
+	Hidden *bool
+	// Dereference is how the symlinks are added.
+	Dereference options.SymlinkPolicy
+}
+
+// FromSettings returns the policy of the settings of an add. The ignore file
+// is read on the local filesystem.
+func FromSettings(settings *options.UnixfsAddSettings) (*Policy, error) {
+	p := &Policy{Dereference: settings.Dereference}
+	if settings.IncludeHiddenSet {
+		hidden := settings.IncludeHidden
+		p.Hidden = &hidden
+	}
+	if len(settings.IgnoreRules) > 0 || settings.IgnoreFile != "" {
+		filter, err := files.NewFilter(settings.IgnoreFile, settings.IgnoreRules, true)
+		if err != nil {
+			return nil, err
+		}
+		p.Rules = filter.Rules
+	}
+	return p, nil
+}
+
+// NewSerialFile is files.NewSerialFile, whose directories tell their path
+// through files.FileInfo, so that Apply can include their hidden entries and
+// follow their symlinks.
+func NewSerialFile(path string, includeHidden bool, st os.FileInfo) (files.Node, error) {
+	return open(path, st, &Policy{Hidden: &includeHidden}, "", nil)
+}
+
+// Apply returns nd with the policy p. When nd is a symlink dereferenced by
+// p, the file or directory it points to is read instead: the location of the
+// symlink is unknown, so its relative target is resolved against the working
+// directory.
+func Apply(nd files.Node, p *Policy) (files.Node, error) {
+	if l, ok := nd.(*files.Symlink); ok && p.Dereference != options.DereferenceNever {
+		target, err := dereference(l.Target, p, "", nil)
+		if err != nil {
+			return nil, err
+		}
+		l.Close()
+		return target, nil
+	}
+
+	switch d := nd.(type) {
+	case *dir:
+		merged := *p
+		if merged.Hidden == nil {
+			merged.Hidden = d.p.Hidden
+		}
+		if merged.Rules == nil {
+			merged.Rules = d.p.Rules
+		}
+		return &dir{Directory: d.Directory, path: d.path, st: d.st, p: &merged}, nil
+	case files.Directory:
+		if p.Rules == nil && (p.Hidden == nil || *p.Hidden) {
+			return nd, nil
+		}
+		return &filteredDir{Directory: d, p: p}, nil
+	}
+	return nd, nil
+}
+
+// open reads the file or directory at path, at rel in the added directory.
+// parents are the directories it is in, when its symlinks are followed.
+func open(path string, st os.FileInfo, p *Policy, rel string, parents []os.FileInfo) (files.Node, error) {
+	// the hidden entries are skipped while iterating, see iterator
+	filter, err := files.NewFilter("", nil, true)
+	if err != nil {
+		return nil, err
+	}
+	nd, err := files.NewSerialFileWithFilter(path, filter, st)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := nd.(files.Directory); ok {
+		return &dir{Directory: d, path: path, st: st, p: p, rel: rel, parents: parents}, nil
+	}
+	return nd, nil
+}
+
+// dereference reads the file or directory at path, following the symlinks.
+// It fails for the directories in parents, which path would be added in.
+func dereference(path string, p *Policy, rel string, parents []os.FileInfo) (files.Node, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("dereferencing the symlink %s: %w", path, err)
+	}
+	if st.IsDir() {
+		for _, parent := range parents {
+			if os.SameFile(parent, st) {
+				return nil, fmt.Errorf("dereferencing the symlink %s: it points to one of its parent directories", path)
+			}
+		}
+	}
+	return open(path, st, p, rel, parents)
+}
+
+// skipped returns whether the entry at rel, named name, is skipped by p.
+func (p *Policy) skipped(rel, name string, nd files.Node) bool {
+	if p.Hidden != nil && !*p.Hidden && strings.HasPrefix(name, ".") {
+		return true
+	}
+	if p.Rules == nil {
+		return false
+	}
+	if p.Rules.MatchesPath(rel) {
+		return true
+	}
+	_, isDir := nd.(files.Directory)
+	return isDir && p.Rules.MatchesPath(rel+"/")
+}
+
+// dir is a directory of the local filesystem at path, at rel in the added
+// directory, read with the policy p. Its entries are listed with the hidden
+// ones, which p skips or not. parents are the directories it is in, when its
+// symlinks are followed.
+type dir struct {
+	files.Directory
+	path    string
+	st      os.FileInfo
+	p       *Policy
+	rel     string
+	parents []os.FileInfo
+}
+
+var _ files.FileInfo = (*dir)(nil)
+
+func (d *dir) AbsPath() string {
+	return d.path
+}
+
+func (d *dir) Stat() os.FileInfo {
+	return d.st
+}
+
+func (d *dir) Entries() files.DirIterator {
+	it := &iterator{DirIterator: d.Directory.Entries(), p: d.p, rel: d.rel, dir: d}
+	if d.p.Dereference == options.DereferenceAlways {
+		it.parents = append(d.parents[:len(d.parents):len(d.parents)], d.st)
+	}
+	return it
+}
+
+// Size returns the size of the regular files under the directory which are
+// not skipped. The symlinks are not followed.
+func (d *dir) Size() (int64, error) {
+	var du int64
+	err := filepath.WalkDir(d.path, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == d.path {
+			return nil
+		}
+		rel, err := filepath.Rel(d.path, path)
+		if err != nil {
+			return err
+		}
+		rel = gopath.Join(d.rel, filepath.ToSlash(rel))
+		skipped := d.p.Hidden != nil && !*d.p.Hidden && strings.HasPrefix(e.Name(), ".")
+		if d.p.Rules != nil {
+			skipped = skipped || d.p.Rules.MatchesPath(rel) || (e.IsDir() && d.p.Rules.MatchesPath(rel+"/"))
+		}
+		switch {
+		case skipped && e.IsDir():
+			return filepath.SkipDir
+		case skipped || !e.Type().IsRegular():
+			return nil
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		du += info.Size()
+		return nil
+	})
+	return du, err
+}
+
+// filteredDir is a directory which isn't read from the local filesystem, or
+// doesn't tell its path, whose entries skipped by p are skipped.
+type filteredDir struct {
+	files.Directory
+	p   *Policy
+	rel string
+}
+
+func (d *filteredDir) Entries() files.DirIterator {
+	return &iterator{DirIterator: d.Directory.Entries(), p: d.p, rel: d.rel}
+}
+
+// iterator iterates the entries of a directory which are not skipped by p.
+// dir is the directory, when it was read from the local filesystem.
+type iterator struct {
+	files.DirIterator
+	p       *Policy
+	rel     string
+	dir     *dir
+	parents []os.FileInfo
+
+	nd  files.Node
+	err error
+}
+
+func (it *iterator) Next() bool {
+	for it.DirIterator.Next() {
+		name := it.Name()
+		rel := gopath.Join(it.rel, name)
+		nd := it.DirIterator.Node()
+		if l, ok := nd.(*files.Symlink); ok && it.dir != nil && it.p.Dereference == options.DereferenceAlways {
+			l.Close()
+			if nd, it.err = dereference(filepath.Join(it.dir.path, name), it.p, rel, it.parents); it.err != nil {
+				return false
+			}
+		}
+		if it.p.skipped(rel, name, nd) {
+			nd.Close()
+			continue
+		}
+
+		switch d := nd.(type) {
+		case *dir:
+		case files.Directory:
+			if it.dir != nil {
+				nd = &dir{Directory: d, path: filepath.Join(it.dir.path, name), st: localStat(d), p: it.p, rel: rel, parents: it.parents}
+			} else {
+				nd = &filteredDir{Directory: d, p: it.p, rel: rel}
+			}
+		}
+		it.nd = nd
+		return true
+	}
+	return false
+}
+
+func (it *iterator) Node() files.Node {
+	return it.nd
+}
+
+func (it *iterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.DirIterator.Err()
+}
+
+// localStat returns the stat of a directory of files.NewSerialFile.
+func localStat(d files.Directory) os.FileInfo {
+	if s, ok := d.(interface{ Stat() os.FileInfo }); ok {
+		return s.Stat()
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package localfiles
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	ignore "github.com/crackcomm/go-gitignore"
+	"github.com/ipfs/boxo/files"
+
+	"github.com/ipfs/kubo/core/coreiface/options"
+)
+
+// walk returns the paths under d.
+func walk(d files.Directory, prefix string) ([]string, error) {
+	var paths []string
+	it := d.Entries()
+	for it.Next() {
+		p := prefix + it.Name()
+		paths = append(paths, p)
+		if sub, ok := it.Node().(files.Directory); ok {
+			subPaths, err := walk(sub, p+"/")
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, subPaths...)
+		}
+	}
+	sort.Strings(paths)
+	return paths, it.Err()
+}
+
+func testDir(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, name := range []string{"visible", ".hidden", "sub/file", "sub/.hidden", "build/out.o"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func serial(t *testing.T, root string, includeHidden bool) files.Node {
+	t.Helper()
+	st, err := os.Lstat(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := NewSerialFile(root, includeHidden, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
+
+func rules(t *testing.T, lines ...string) *ignore.GitIgnore {
+	t.Helper()
+	r, err := ignore.CompileIgnoreLines(lines...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestApply(t *testing.T) {
+	root := testDir(t)
+	include, exclude := true, false
+
+	for _, tc := range []struct {
+		name          string
+		includeHidden bool
+		policy        Policy
+		want          []string
+		size          int64
+	}{
+		{name: "asBuilt", want: []string{"build", "build/out.o", "sub", "sub/file", "visible"}, size: 26},
+		{name: "included", policy: Policy{Hidden: &include},
+			want: []string{".hidden", "build", "build/out.o", "sub", "sub/.hidden", "sub/file", "visible"}, size: 44},
+		{name: "excluded", includeHidden: true, policy: Policy{Hidden: &exclude},
+			want: []string{"build", "build/out.o", "sub", "sub/file", "visible"}, size: 26},
+		{name: "ignored", policy: Policy{Rules: rules(t, "build/", "file")},
+			want: []string{"sub", "visible"}, size: 7},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			nd, err := Apply(serial(t, root, tc.includeHidden), &tc.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := nd.(files.Directory)
+			if fi, ok := d.(files.FileInfo); !ok || fi.AbsPath() != root {
+				t.Fatalf("expected a directory at %s", root)
+			}
+			got, err := walk(d, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+			size, err := d.Size()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != tc.size {
+				t.Fatalf("expected a size of %d, got %d", tc.size, size)
+			}
+		})
+	}
+}
+
+func TestApplyFilteredDir(t *testing.T) {
+	exclude := false
+	d := files.NewMapDirectory(map[string]files.Node{
+		"visible": files.NewBytesFile(nil),
+		".hidden": files.NewBytesFile(nil),
+		"sub": files.NewMapDirectory(map[string]files.Node{
+			"out.o": files.NewBytesFile(nil),
+			"file":  files.NewBytesFile(nil),
+		}),
+	})
+	nd, err := Apply(d, &Policy{Hidden: &exclude, Rules: rules(t, "*.o")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := walk(nd.(files.Directory), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"sub", "sub/file", "visible"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestApplyDereference(t *testing.T) {
+	root := testDir(t)
+	if err := os.Symlink(filepath.Join(root, "sub"), filepath.Join(root, "link")); err != nil {
+		t.Skipf("creating symlinks: %s", err)
+	}
+
+	nd, err := Apply(serial(t, root, false), &Policy{Dereference: options.DereferenceAlways})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := walk(nd.(files.Directory), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"build", "build/out.o", "link", "link/file", "sub", "sub/file", "visible"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	// the errors reading the entries are returned by the iterator
+	if err := os.Symlink(root, filepath.Join(root, "sub", "loop")); err != nil {
+		t.Fatal(err)
+	}
+	nd, err = Apply(serial(t, root, false), &Policy{Dereference: options.DereferenceAlways})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := walk(nd.(files.Directory), ""); err == nil {
+		t.Fatal("expected the cycle to fail")
+	}
+
+	nd, err = Apply(serial(t, filepath.Join(root, "link"), false), &Policy{Dereference: options.DereferenceTopLevel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := nd.(files.Directory); !ok {
+		t.Fatalf("expected the top-level symlink to be dereferenced, got %T", nd)
+	}
+}
@@ -0,0 +1,339 @@
+// Package mfsexpiry removes MFS files and directories once their TTL
+// expires, so that scratch and workspace directories clean themselves up.
+//
+// The TTLs are attached to MFS paths and stored next to MFS in the
+// datastore, not in the MFS tree: whatever is at the path when its TTL
+// expires is removed. Removing or moving a path with 'ipfs files rm' and
+// 'ipfs files mv' clears or moves its TTL, and the TTLs of the paths under it.
+package mfsexpiry
+
+import (
+	"context"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	gopath "path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/boxo/mfs"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("core/mfsexpiry")
+
+// DatastoreKey is the prefix under which the TTLs are stored.
+var DatastoreKey = datastore.NewKey("/local/mfsexpiry")
+
+// DefaultInterval is the time between two looks for expired paths.
+const DefaultInterval = time.Minute
+
+// Entry is the TTL of an MFS path.
+type Entry struct {
+	Path    string
+	Expires time.Time
+	// Unpin unpins the CID of the path when it is removed.
+	Unpin bool `json:",omitempty"`
+}
+
+// UnpinFunc unpins a CID, it must ignore CIDs that are not pinned.
+type UnpinFunc func(ctx context.Context, c cid.Cid) error
+
+// Reaper removes the MFS paths whose TTL expired.
+type Reaper struct {
+	ds    datastore.Datastore
+	root  *mfs.Root
+	unpin UnpinFunc
+	clock clock.Clock
+
+	// lk serializes the changes of the TTLs
+	lk sync.Mutex
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started atomic.Bool
+	done    chan struct{}
+	wake    chan struct{}
+}
+
+// New returns the reaper of the TTLs stored in ds, for the paths of root,
+// expiring according to clk.
+func New(ds datastore.Datastore, root *mfs.Root, unpin UnpinFunc, clk clock.Clock) *Reaper {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Reaper{
+		ds:     namespace.Wrap(ds, DatastoreKey),
+		root:   root,
+		unpin:  unpin,
+		clock:  clk,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+func entryKey(p string) datastore.Key {
+	return datastore.NewKey(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte(p)))
+}
+
+// under returns whether p is dir or a path under dir.
+func under(p, dir string) bool {
+	return p == dir || dir == "/" || strings.HasPrefix(p, dir+"/")
+}
+
+// Set sets the TTL of the MFS path p, which must exist.
+func (r *Reaper) Set(ctx context.Context, p string, ttl time.Duration, unpin bool) (Entry, error) {
+	p = gopath.Clean(p)
+	if p == "/" {
+		return Entry{}, errors.New("the root of MFS can't expire")
+	}
+	if _, err := mfs.Lookup(r.root, p); err != nil {
+		return Entry{}, err
+	}
+
+	e := Entry{Path: p, Expires: r.clock.Now().Add(ttl), Unpin: unpin}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	if err := r.ds.Put(ctx, entryKey(p), data); err != nil {
+		return Entry{}, err
+	}
+	r.notify()
+	return e, nil
+}
+
+// List returns the TTLs, the ones expiring first first.
+func (r *Reaper) List(ctx context.Context) ([]Entry, error) {
+	res, err := r.ds.Query(ctx, query.Query{})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var entries []Entry
+	for result := range res.Next() {
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		var e Entry
+		if err := json.Unmarshal(result.Value, &e); err != nil {
+			log.Errorf("invalid entry %s: %s", result.Key, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Expires.Before(entries[j].Expires)
+	})
+	return entries, nil
+}
+
+// Clear clears the TTLs of p and of the paths under it. It returns the
+// number of TTLs cleared.
+func (r *Reaper) Clear(ctx context.Context, p string) (int, error) {
+	p = gopath.Clean(p)
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	entries, err := r.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, e := range entries {
+		if under(e.Path, p) {
+			if err := r.ds.Delete(ctx, entryKey(e.Path)); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Move moves the TTLs of from and of the paths under it to to.
+func (r *Reaper) Move(ctx context.Context, from, to string) error {
+	from, to = gopath.Clean(from), gopath.Clean(to)
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	entries, err := r.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !under(e.Path, from) {
+			continue
+		}
+		if err := r.ds.Delete(ctx, entryKey(e.Path)); err != nil {
+			return err
+		}
+		e.Path = to + strings.TrimPrefix(e.Path, from)
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if err := r.ds.Put(ctx, entryKey(e.Path), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reap removes the paths expired at now, and returns their TTLs.
+func (r *Reaper) Reap(ctx context.Context, now time.Time) ([]Entry, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	entries, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var reaped []Entry
+	for _, e := range entries {
+		if e.Expires.After(now) {
+			// sorted by expiry
+			break
+		}
+		if err := r.remove(ctx, e); err != nil {
+			return reaped, fmt.Errorf("removing %s: %w", e.Path, err)
+		}
+		reaped = append(reaped, e)
+	}
+
+	// the TTLs of the paths under the removed ones go with them
+	for _, e := range reaped {
+		if err := r.clearUnder(ctx, e.Path); err != nil {
+			return reaped, err
+		}
+	}
+	return reaped, nil
+}
+
+func (r *Reaper) remove(ctx context.Context, e Entry) error {
+	dir, name := gopath.Split(e.Path)
+	fsn, err := mfs.Lookup(r.root, dir)
+	if err == nil {
+		pdir, ok := fsn.(*mfs.Directory)
+		if !ok {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		err = r.unlink(ctx, pdir, name, e.Unpin)
+	}
+	if err != nil && err != os.ErrNotExist {
+		return err
+	}
+	return r.ds.Delete(ctx, entryKey(e.Path))
+}
+
+func (r *Reaper) unlink(ctx context.Context, pdir *mfs.Directory, name string, unpin bool) error {
+	child, err := pdir.Child(name)
+	if err != nil {
+		return err
+	}
+	nd, err := child.GetNode()
+	if err != nil {
+		return err
+	}
+	if err := pdir.Unlink(name); err != nil {
+		return err
+	}
+	if err := pdir.Flush(); err != nil {
+		return err
+	}
+	if unpin && r.unpin != nil {
+		return r.unpin(ctx, nd.Cid())
+	}
+	return nil
+}
+
+func (r *Reaper) clearUnder(ctx context.Context, p string) error {
+	entries, err := r.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Path != p && under(e.Path, p) {
+			if err := r.ds.Delete(ctx, entryKey(e.Path)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Reaper) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start removes the expired paths in the background, every interval and
+// when a TTL is set, until Close is called.
+func (r *Reaper) Start(interval time.Duration) {
+	r.started.Store(true)
+	go func() {
+		defer close(r.done)
+		t := r.clock.Timer(0)
+		defer t.Stop()
+		for {
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-t.C:
+			case <-r.wake:
+				if !t.Stop() {
+					<-t.C
+				}
+			}
+
+			reaped, err := r.Reap(r.ctx, r.clock.Now())
+			for _, e := range reaped {
+				log.Infof("removed %s, expired at %s", e.Path, e.Expires.Format(time.RFC3339))
+			}
+			if err != nil {
+				log.Error(err)
+			}
+			t.Reset(r.next(interval))
+		}
+	}()
+}
+
+// next returns the time until the next expiry, at most interval.
+func (r *Reaper) next(interval time.Duration) time.Duration {
+	entries, err := r.List(r.ctx)
+	if err != nil || len(entries) == 0 {
+		return interval
+	}
+	if d := r.clock.Until(entries[0].Expires); d < interval {
+		if d < time.Second {
+			d = time.Second
+		}
+		return d
+	}
+	return interval
+}
+
+// Close stops the background removals started by Start.
+func (r *Reaper) Close() error {
+	r.cancel()
+	if r.started.Load() {
+		<-r.done
+	}
+	return nil
+}
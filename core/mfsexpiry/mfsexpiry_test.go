@@ -0,0 +1,175 @@
+package mfsexpiry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/mfs"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+func newTestReaper(t *testing.T, dirs ...string) (*Reaper, *mfs.Root, *[]cid.Cid) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	root, err := mfs.NewRoot(ctx, dagtest.Mock(), ft.EmptyDirNode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range dirs {
+		if err := mfs.Mkdir(root, d, mfs.MkdirOpts{Mkparents: true, Flush: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var unpinned []cid.Cid
+	r := New(dssync.MutexWrap(datastore.NewMapDatastore()), root, func(_ context.Context, c cid.Cid) error {
+		unpinned = append(unpinned, c)
+		return nil
+	}, clock.New())
+	t.Cleanup(func() { r.Close() })
+	return r, root, &unpinned
+}
+
+func paths(entries []Entry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = e.Path
+	}
+	return out
+}
+
+func TestSetAndReap(t *testing.T) {
+	ctx := context.Background()
+	r, root, unpinned := newTestReaper(t, "/scratch/a", "/scratch/b", "/keep")
+
+	if _, err := r.Set(ctx, "/missing", time.Hour, false); err == nil {
+		t.Fatal("expected an error for a missing path")
+	}
+	if _, err := r.Set(ctx, "/", time.Hour, false); err == nil {
+		t.Fatal("expected an error for the root")
+	}
+	if _, err := r.Set(ctx, "/scratch/a/", time.Minute, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Set(ctx, "/scratch/b", time.Hour, false); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := r.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(entries); len(got) != 2 || got[0] != "/scratch/a" || got[1] != "/scratch/b" {
+		t.Fatalf("unexpected entries %v", got)
+	}
+
+	reaped, err := r.Reap(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reaped) != 0 {
+		t.Fatalf("nothing should have expired yet, got %v", paths(reaped))
+	}
+
+	reaped, err = r.Reap(ctx, time.Now().Add(2*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(reaped); len(got) != 1 || got[0] != "/scratch/a" {
+		t.Fatalf("unexpected reaped entries %v", got)
+	}
+	if _, err := mfs.Lookup(root, "/scratch/a"); err == nil {
+		t.Fatal("/scratch/a wasn't removed")
+	}
+	if _, err := mfs.Lookup(root, "/scratch/b"); err != nil {
+		t.Fatal(err)
+	}
+	if len(*unpinned) != 1 {
+		t.Fatalf("expected /scratch/a to be unpinned, got %v", *unpinned)
+	}
+
+	entries, err = r.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(entries); len(got) != 1 || got[0] != "/scratch/b" {
+		t.Fatalf("unexpected entries %v", got)
+	}
+}
+
+func TestReapClearsChildren(t *testing.T) {
+	ctx := context.Background()
+	r, root, unpinned := newTestReaper(t, "/tmp/sub/dir")
+
+	if _, err := r.Set(ctx, "/tmp", time.Minute, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Set(ctx, "/tmp/sub/dir", time.Hour, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reap(ctx, time.Now().Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.Lookup(root, "/tmp"); err == nil {
+		t.Fatal("/tmp wasn't removed")
+	}
+	entries, err := r.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected the TTLs under /tmp to be cleared, got %v", paths(entries))
+	}
+	if len(*unpinned) != 0 {
+		t.Fatalf("nothing should have been unpinned, got %v", *unpinned)
+	}
+}
+
+func TestMoveAndClear(t *testing.T) {
+	ctx := context.Background()
+	r, _, _ := newTestReaper(t, "/a/b", "/a/c", "/ab")
+
+	for _, p := range []string{"/a", "/a/b", "/a/c", "/ab"} {
+		if _, err := r.Set(ctx, p, time.Hour, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := r.Move(ctx, "/a", "/x"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := r.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, p := range paths(entries) {
+		got[p] = true
+	}
+	if len(got) != 4 || !got["/x"] || !got["/x/b"] || !got["/x/c"] || !got["/ab"] {
+		t.Fatalf("unexpected entries after the move %v", got)
+	}
+
+	n, err := r.Clear(ctx, "/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 TTLs cleared, got %d", n)
+	}
+	entries, err = r.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(entries); len(got) != 1 || got[0] != "/ab" {
+		t.Fatalf("unexpected entries after the clear %v", got)
+	}
+}
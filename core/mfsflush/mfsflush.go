@@ -0,0 +1,185 @@
+// Package mfsflush batches the flushes of MFS, so that thousands of writes
+// to the same directory don't rewrite the directory node, and all its
+// ancestors, thousands of times.
+//
+// The 'ipfs files' commands run with --flush ask the Flusher to flush the
+// directory they changed. Unless batching is enabled, it is flushed right
+// away. Otherwise the directory is marked dirty, and the dirty directories
+// are flushed once at the end of the flush epoch: every AutoFlushInterval,
+// or on 'ipfs files flush' in ManualFlush mode. The pending changes are
+// always flushed when the node shuts down.
+package mfsflush
+
+import (
+	"context"
+	"errors"
+	"os"
+	gopath "path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/boxo/mfs"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("core/mfsflush")
+
+// Flusher flushes the changes made to the directories of an MFS root.
+type Flusher struct {
+	root     *mfs.Root
+	interval time.Duration
+	manual   bool
+
+	lk    sync.Mutex
+	dirty map[string]struct{}
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started atomic.Bool
+	done    chan struct{}
+}
+
+// New returns the flusher of root. The flushes are batched when interval is
+// not zero, or in manual mode.
+func New(root *mfs.Root, interval time.Duration, manual bool) *Flusher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Flusher{
+		root:     root,
+		interval: interval,
+		manual:   manual,
+		dirty:    make(map[string]struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+// Batching reports whether the flushes are deferred to the end of the flush
+// epoch.
+func (f *Flusher) Batching() bool {
+	return f.manual || f.interval > 0
+}
+
+// Flush flushes the directory containing the MFS path p, or marks it dirty
+// when batching.
+func (f *Flusher) Flush(ctx context.Context, p string) error {
+	return f.FlushDir(ctx, gopath.Dir(gopath.Clean("/"+p)))
+}
+
+// FlushDir flushes the MFS directory dir, or marks it dirty when batching.
+func (f *Flusher) FlushDir(ctx context.Context, dir string) error {
+	dir = gopath.Clean("/" + dir)
+	if !f.Batching() {
+		_, err := mfs.FlushPath(ctx, f.root, dir)
+		return err
+	}
+	f.lk.Lock()
+	f.dirty[dir] = struct{}{}
+	f.lk.Unlock()
+	return nil
+}
+
+// Pending returns the dirty directories, sorted.
+func (f *Flusher) Pending() []string {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	dirs := make([]string, 0, len(f.dirty))
+	for d := range f.dirty {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// FlushPending flushes the dirty directories, ending the flush epoch. A
+// directory is flushed once, even if many of its entries changed, and not
+// at all when one of its ancestors is flushed too, as flushing a directory
+// flushes the changes under it.
+func (f *Flusher) FlushPending(ctx context.Context) error {
+	f.lk.Lock()
+	dirty := f.dirty
+	f.dirty = make(map[string]struct{})
+	f.lk.Unlock()
+	if len(dirty) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, dir := range coalesce(dirty) {
+		if err := f.flushExisting(ctx, dir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flushExisting flushes dir, or its closest ancestor that still exists when
+// it was removed or moved since it was changed.
+func (f *Flusher) flushExisting(ctx context.Context, dir string) error {
+	for {
+		_, err := mfs.FlushPath(ctx, f.root, dir)
+		if err == nil || dir == "/" || !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		dir = gopath.Dir(dir)
+	}
+}
+
+// coalesce returns the directories of dirty that have no ancestor in dirty.
+func coalesce(dirty map[string]struct{}) []string {
+	var dirs []string
+	for d := range dirty {
+		covered := false
+		for a := d; a != "/"; {
+			a = gopath.Dir(a)
+			if _, ok := dirty[a]; ok {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			dirs = append(dirs, d)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// Start flushes the dirty directories every interval, unless in manual
+// mode.
+func (f *Flusher) Start() {
+	if f.manual || f.interval <= 0 {
+		return
+	}
+	f.started.Store(true)
+	go func() {
+		defer close(f.done)
+		t := time.NewTicker(f.interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-f.ctx.Done():
+				return
+			case <-t.C:
+			}
+			if err := f.FlushPending(f.ctx); err != nil {
+				log.Errorf("flushing MFS: %s", err)
+			}
+		}
+	}()
+}
+
+// Close stops the flushes and flushes the pending changes.
+func (f *Flusher) Close(ctx context.Context) error {
+	f.cancel()
+	if f.started.Load() {
+		<-f.done
+	}
+	if pending := f.Pending(); len(pending) > 0 {
+		log.Infof("flushing %d MFS directories: %s", len(pending), strings.Join(pending, ", "))
+	}
+	return f.FlushPending(ctx)
+}
@@ -0,0 +1,144 @@
+package mfsflush
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/mfs"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/stretchr/testify/require"
+)
+
+// countingDAG counts the nodes written.
+type countingDAG struct {
+	ipld.DAGService
+	adds atomic.Int64
+}
+
+func (d *countingDAG) Add(ctx context.Context, nd ipld.Node) error {
+	d.adds.Add(1)
+	return d.DAGService.Add(ctx, nd)
+}
+
+type published struct {
+	lk   sync.Mutex
+	last cid.Cid
+}
+
+func (p *published) get() cid.Cid {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	return p.last
+}
+
+func newTestRoot(t *testing.T) (*mfs.Root, *countingDAG, *published) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	ds := &countingDAG{DAGService: dagtest.Mock()}
+	pub := &published{}
+	root, err := mfs.NewRoot(ctx, ds, ft.EmptyDirNode(), func(_ context.Context, c cid.Cid) error {
+		pub.lk.Lock()
+		defer pub.lk.Unlock()
+		pub.last = c
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, mfs.Mkdir(root, "/a/b", mfs.MkdirOpts{Mkparents: true}))
+	_, err = mfs.FlushPath(ctx, root, "/")
+	require.NoError(t, err)
+	return root, ds, pub
+}
+
+// writeFiles puts n files in /a, flushing each of them.
+func writeFiles(t *testing.T, f *Flusher, root *mfs.Root, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		p := fmt.Sprintf("/a/file-%d", i)
+		nd := dag.NodeWithData(ft.FilePBData([]byte(p), uint64(len(p))))
+		require.NoError(t, mfs.PutNode(root, p, nd))
+		require.NoError(t, f.Flush(context.Background(), p))
+	}
+}
+
+func rootCid(t *testing.T, root *mfs.Root) cid.Cid {
+	nd, err := root.GetDirectory().GetNode()
+	require.NoError(t, err)
+	return nd.Cid()
+}
+
+func TestImmediate(t *testing.T) {
+	root, ds, pub := newTestRoot(t)
+	f := New(root, 0, false)
+	require.False(t, f.Batching())
+
+	before := ds.adds.Load()
+	writeFiles(t, f, root, 50)
+	require.Empty(t, f.Pending())
+	require.Equal(t, rootCid(t, root), pub.get())
+	// the files, and /a and the root once per file
+	require.GreaterOrEqual(t, ds.adds.Load()-before, int64(150))
+}
+
+func TestBatching(t *testing.T) {
+	root, ds, pub := newTestRoot(t)
+	f := New(root, time.Hour, false)
+	require.True(t, f.Batching())
+	flushed := pub.get()
+
+	before := ds.adds.Load()
+	writeFiles(t, f, root, 50)
+	require.Equal(t, []string{"/a"}, f.Pending())
+	require.Equal(t, flushed, pub.get())
+
+	require.NoError(t, f.FlushPending(context.Background()))
+	require.Empty(t, f.Pending())
+	require.Equal(t, rootCid(t, root), pub.get())
+	// the files, and /a and its children once
+	require.Less(t, ds.adds.Load()-before, int64(60))
+}
+
+func TestClose(t *testing.T) {
+	root, _, pub := newTestRoot(t)
+	f := New(root, 0, true)
+	f.Start()
+
+	writeFiles(t, f, root, 3)
+	require.NotEqual(t, rootCid(t, root), pub.get())
+	require.NoError(t, f.Close(context.Background()))
+	require.Equal(t, rootCid(t, root), pub.get())
+}
+
+func TestRemovedDir(t *testing.T) {
+	root, _, pub := newTestRoot(t)
+	f := New(root, time.Hour, false)
+
+	require.NoError(t, f.FlushDir(context.Background(), "/a/b"))
+	require.NoError(t, root.GetDirectory().Unlink("a"))
+	// /a/b is gone, its closest existing ancestor is flushed
+	require.NoError(t, f.FlushPending(context.Background()))
+	require.Equal(t, rootCid(t, root), pub.get())
+}
+
+func TestCoalesce(t *testing.T) {
+	dirty := map[string]struct{}{
+		"/a/b":   {},
+		"/a":     {},
+		"/a/b/c": {},
+		"/ab":    {},
+		"/d/e":   {},
+	}
+	require.Equal(t, []string{"/a", "/ab", "/d/e"}, coalesce(dirty))
+
+	dirty["/"] = struct{}{}
+	require.Equal(t, []string{"/"}, coalesce(dirty))
+}
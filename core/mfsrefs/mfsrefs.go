@@ -0,0 +1,280 @@
+// Package mfsrefs keeps a persistent index of the blocks referenced by MFS,
+// so that garbage collection doesn't need to read the whole MFS tree.
+//
+// The index has an entry for each node referenced by MFS that isn't a raw
+// leaf: either the CIDs the node links to, read once when the node is first
+// seen, or a marker for the nodes that weren't stored locally at the time.
+// Nodes never change, so updating the index for a new MFS root only reads the
+// nodes it doesn't know yet, and marking the blocks MFS references only reads
+// the index, never the blocks themselves.
+package mfsrefs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	format "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("core/mfsrefs")
+
+// DatastoreKey is the key the index is stored under.
+var DatastoreKey = datastore.NewKey("/local/mfsrefs")
+
+// Values of the entries of the index.
+const (
+	entryLinks   byte = 'l' // followed by the bytes of the CIDs linked to
+	entryMissing byte = 'm' // the node wasn't stored locally
+)
+
+// Index is the index of the blocks referenced by MFS.
+type Index struct {
+	ds  datastore.Batching
+	bs  blockstore.Blockstore
+	dag format.NodeGetter
+
+	// lk serializes the updates of the index
+	lk sync.Mutex
+
+	rootLk     sync.Mutex
+	mfsRoot    cid.Cid
+	mfsUpdated chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New returns the index stored in ds, of the blocks of bs. The index follows
+// the MFS roots given to UpdateMFS in the background.
+func New(ds datastore.Batching, bs blockstore.Blockstore) *Index {
+	ctx, cancel := context.WithCancel(context.Background())
+	ix := &Index{
+		ds:         ds,
+		bs:         bs,
+		dag:        merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs))),
+		mfsUpdated: make(chan struct{}, 1),
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+	go ix.run()
+	return ix
+}
+
+// Close stops updating the index in the background.
+func (ix *Index) Close() error {
+	ix.cancel()
+	<-ix.done
+	return nil
+}
+
+// UpdateMFS schedules the update of the index for a new MFS root. Updates
+// scheduled while another one runs are coalesced.
+func (ix *Index) UpdateMFS(root cid.Cid) {
+	ix.rootLk.Lock()
+	ix.mfsRoot = root
+	ix.rootLk.Unlock()
+
+	select {
+	case ix.mfsUpdated <- struct{}{}:
+	default:
+	}
+}
+
+func (ix *Index) run() {
+	defer close(ix.done)
+	for {
+		select {
+		case <-ix.ctx.Done():
+			return
+		case <-ix.mfsUpdated:
+		}
+
+		ix.rootLk.Lock()
+		root := ix.mfsRoot
+		ix.rootLk.Unlock()
+
+		if err := ix.Update(ix.ctx, root); err != nil && ix.ctx.Err() == nil {
+			log.Errorf("indexing the blocks of MFS root %s: %s", root, err)
+		}
+	}
+}
+
+// Update adds the nodes of the DAG under root the index doesn't know yet.
+// Nodes that were missing when they were indexed are read again if they are
+// stored locally now.
+func (ix *Index) Update(ctx context.Context, root cid.Cid) error {
+	ix.lk.Lock()
+	defer ix.lk.Unlock()
+	return ix.update(ctx, root)
+}
+
+func (ix *Index) update(ctx context.Context, root cid.Cid) error {
+	batch, err := ix.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+
+	visited := cid.NewSet()
+	stack := []cid.Cid{root}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if c.Type() == cid.Raw || !visited.Visit(c) {
+			continue
+		}
+
+		val, err := ix.ds.Get(ctx, entryKey(c))
+		switch {
+		case err == datastore.ErrNotFound:
+		case err != nil:
+			return err
+		case len(val) > 0 && val[0] == entryLinks:
+			continue // the whole DAG under c is indexed already
+		}
+
+		nd, err := ix.dag.Get(ctx, c)
+		if format.IsNotFound(err) {
+			if len(val) == 0 {
+				if err := batch.Put(ctx, entryKey(c), []byte{entryMissing}); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		buf := []byte{entryLinks}
+		for _, l := range nd.Links() {
+			buf = append(buf, l.Cid.Bytes()...)
+			stack = append(stack, l.Cid)
+		}
+		if err := batch.Put(ctx, entryKey(c), buf); err != nil {
+			return err
+		}
+	}
+	return batch.Commit(ctx)
+}
+
+// Mark adds the blocks referenced by the MFS root to marked, after updating
+// the index for root. The entries of the nodes MFS doesn't reference anymore
+// are removed from the index.
+//
+// Only the index is read, except for the nodes that were missing and are
+// stored locally now, which are indexed first.
+func (ix *Index) Mark(ctx context.Context, root cid.Cid, marked *cid.Set) error {
+	ix.lk.Lock()
+	defer ix.lk.Unlock()
+
+	if err := ix.update(ctx, root); err != nil {
+		return err
+	}
+
+	reached := cid.NewSet()
+	stack := []cid.Cid{root}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !reached.Visit(c) {
+			continue
+		}
+		marked.Add(c)
+		if c.Type() == cid.Raw {
+			continue
+		}
+
+		links, err := ix.links(ctx, c)
+		if err != nil {
+			return err
+		}
+		stack = append(stack, links...)
+	}
+
+	return ix.prune(ctx, reached)
+}
+
+// links returns the CIDs c links to, indexing c first if it was missing and
+// is stored locally now.
+func (ix *Index) links(ctx context.Context, c cid.Cid) ([]cid.Cid, error) {
+	val, err := ix.ds.Get(ctx, entryKey(c))
+	if err == datastore.ErrNotFound {
+		return nil, nil // not found when the index was updated
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(val) > 0 && val[0] == entryMissing {
+		has, err := ix.bs.Has(ctx, c)
+		if err != nil || !has {
+			return nil, err
+		}
+		if err := ix.update(ctx, c); err != nil {
+			return nil, err
+		}
+		if val, err = ix.ds.Get(ctx, entryKey(c)); err != nil {
+			return nil, err
+		}
+	}
+	return decodeLinks(val)
+}
+
+// prune removes the entries of the nodes that weren't reached.
+func (ix *Index) prune(ctx context.Context, reached *cid.Set) error {
+	results, err := ix.ds.Query(ctx, query.Query{Prefix: DatastoreKey.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	batch, err := ix.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		k := datastore.RawKey(r.Key)
+		c, err := cid.Decode(k.BaseNamespace())
+		if err == nil && reached.Has(c) {
+			continue
+		}
+		if err := batch.Delete(ctx, k); err != nil {
+			return err
+		}
+	}
+	return batch.Commit(ctx)
+}
+
+func entryKey(c cid.Cid) datastore.Key {
+	return DatastoreKey.ChildString(c.String())
+}
+
+func decodeLinks(val []byte) ([]cid.Cid, error) {
+	if len(val) == 0 || val[0] != entryLinks {
+		return nil, nil
+	}
+	var links []cid.Cid
+	for buf := val[1:]; len(buf) > 0; {
+		n, c, err := cid.CidFromBytes(buf)
+		if err != nil {
+			return nil, fmt.Errorf("corrupted entry in the index of MFS blocks: %w", err)
+		}
+		links = append(links, c)
+		buf = buf[n:]
+	}
+	return links, nil
+}
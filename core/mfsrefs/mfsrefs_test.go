@@ -0,0 +1,93 @@
+package mfsrefs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	format "github.com/ipfs/go-ipld-format"
+)
+
+func dir(t *testing.T, bs blockstore.Blockstore, children map[string]format.Node) *merkledag.ProtoNode {
+	t.Helper()
+	nd := ft.EmptyDirNode()
+	for name, child := range children {
+		if err := nd.AddNodeLink(name, child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bs.Put(context.Background(), nd); err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
+
+func mark(t *testing.T, ix *Index, root cid.Cid) *cid.Set {
+	t.Helper()
+	marked := cid.NewSet()
+	if err := ix.Mark(context.Background(), root, marked); err != nil {
+		t.Fatal(err)
+	}
+	return marked
+}
+
+func expectMarked(t *testing.T, marked *cid.Set, nodes ...format.Node) {
+	t.Helper()
+	if marked.Len() != len(nodes) {
+		t.Errorf("expected %d blocks marked, got %d", len(nodes), marked.Len())
+	}
+	for _, nd := range nodes {
+		if !marked.Has(nd.Cid()) {
+			t.Errorf("%s isn't marked", nd.Cid())
+		}
+	}
+}
+
+func TestMark(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	bs := blockstore.NewBlockstore(ds)
+	ix := New(ds, bs)
+	defer ix.Close()
+
+	leaf := merkledag.NewRawNode([]byte("leaf"))
+	if err := bs.Put(ctx, leaf); err != nil {
+		t.Fatal(err)
+	}
+	// a directory that isn't stored locally, as after 'ipfs files cp'
+	lazy := ft.EmptyDirNode()
+	lazyLeaf := merkledag.NewRawNode([]byte("lazy leaf"))
+	if err := lazy.AddNodeLink("leaf", lazyLeaf); err != nil {
+		t.Fatal(err)
+	}
+	sub := dir(t, bs, map[string]format.Node{"leaf": leaf})
+	root := dir(t, bs, map[string]format.Node{"sub": sub, "lazy": lazy})
+
+	expectMarked(t, mark(t, ix, root.Cid()), root, sub, leaf, lazy)
+
+	// the lazy directory is fetched
+	if err := bs.Put(ctx, lazy); err != nil {
+		t.Fatal(err)
+	}
+	expectMarked(t, mark(t, ix, root.Cid()), root, sub, leaf, lazy, lazyLeaf)
+
+	// the entries of the nodes removed from MFS are pruned
+	newRoot := dir(t, bs, map[string]format.Node{"lazy": lazy})
+	expectMarked(t, mark(t, ix, newRoot.Cid()), newRoot, lazy, lazyLeaf)
+	for _, c := range []cid.Cid{root.Cid(), sub.Cid()} {
+		if has, err := ds.Has(ctx, entryKey(c)); err != nil || has {
+			t.Errorf("the entry of %s wasn't pruned", c)
+		}
+	}
+
+	// the index is used once updated, without reading the blocks
+	if err := bs.DeleteBlock(ctx, lazy.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	expectMarked(t, mark(t, ix, newRoot.Cid()), newRoot, lazy, lazyLeaf)
+}
@@ -0,0 +1,101 @@
+// Package mimetypes sniffs the MIME types of UnixFS files from their first
+// block, and caches them by CID.
+package mimetypes
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	format "github.com/ipfs/go-ipld-format"
+)
+
+// DatastoreKey is the key the MIME types are cached under.
+var DatastoreKey = datastore.NewKey("/local/mimetypes")
+
+// Cache is the persistent cache of the MIME types of files.
+//
+// The content of a CID never changes, so entries never expire. Entries are
+// also recorded for the CIDs that aren't UnixFS files, with an empty MIME
+// type, to not read their block again.
+type Cache struct {
+	ds datastore.Datastore
+}
+
+// New returns the cache persisted in ds.
+func New(ds datastore.Datastore) *Cache {
+	return &Cache{ds: ds}
+}
+
+func key(c cid.Cid) datastore.Key {
+	// CIDv0 and CIDv1 of the same content share their entry
+	return DatastoreKey.ChildString(cid.NewCidV1(c.Type(), c.Hash()).String())
+}
+
+// MimeType returns the MIME type of the file c, or "" when c is not a UnixFS
+// file. On the first lookup of c, the first block of the file is read through
+// ng.
+func (mc *Cache) MimeType(ctx context.Context, ng format.NodeGetter, c cid.Cid) (string, error) {
+	buf, err := mc.ds.Get(ctx, key(c))
+	if err == nil {
+		return string(buf), nil
+	}
+	if !errors.Is(err, datastore.ErrNotFound) {
+		return "", err
+	}
+
+	nd, err := ng.Get(ctx, c)
+	if err != nil {
+		return "", err
+	}
+	head, isFile, err := firstBlock(ctx, ng, nd)
+	if err != nil {
+		return "", err
+	}
+	var mimeType string
+	if isFile {
+		mimeType = Sniff(head)
+	}
+	if err := mc.ds.Put(ctx, key(c), []byte(mimeType)); err != nil {
+		return "", err
+	}
+	return mimeType, nil
+}
+
+// firstBlock returns the data of the first block of a UnixFS file, following
+// the first links of the file down to its first leaf when the root has no
+// data. It returns false when nd is not a UnixFS file.
+func firstBlock(ctx context.Context, ng format.NodeGetter, nd format.Node) ([]byte, bool, error) {
+	for {
+		switch n := nd.(type) {
+		case *merkledag.RawNode:
+			return n.RawData(), true, nil
+		case *merkledag.ProtoNode:
+			fsn, err := ft.FSNodeFromBytes(n.Data())
+			if err != nil {
+				return nil, false, nil
+			}
+			if fsn.Type() != ft.TFile && fsn.Type() != ft.TRaw {
+				return nil, false, nil
+			}
+			if len(fsn.Data()) > 0 || len(n.Links()) == 0 {
+				return fsn.Data(), true, nil
+			}
+			if nd, err = n.Links()[0].GetNode(ctx, ng); err != nil {
+				return nil, false, err
+			}
+		default:
+			return nil, false, nil
+		}
+	}
+}
+
+// Sniff returns the MIME type of a file from its first bytes, as the gateway
+// does when serving files without a known extension.
+func Sniff(head []byte) string {
+	return mimetype.Detect(head).String()
+}
@@ -0,0 +1,70 @@
+package mimetypes
+
+import (
+	"context"
+	"mime"
+	"testing"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	format "github.com/ipfs/go-ipld-format"
+)
+
+func TestMimeType(t *testing.T) {
+	ctx := context.Background()
+	dag := dagtest.Mock()
+	mc := New(dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	png := merkledag.NewRawNode([]byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR"))
+	page := merkledag.NewRawNode([]byte("<html><body>hello</body></html>"))
+
+	// a file whose data starts in its first leaf
+	fsn := ft.NewFSNode(ft.TFile)
+	fsn.AddBlockSize(uint64(len(page.RawData())))
+	data, err := fsn.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunked := merkledag.NodeWithData(data)
+	if err := chunked.AddNodeLink("", page); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := ft.EmptyDirNode()
+	if err := dag.AddMany(ctx, []format.Node{png, page, chunked, dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		nd       format.Node
+		expected string
+	}{
+		{png, "image/png"},
+		{chunked, "text/html"},
+		{dir, ""},
+	} {
+		mimeType, err := mc.MimeType(ctx, dag, tc.nd.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// without parameters such as the charset
+		if mediaType, _, _ := mime.ParseMediaType(mimeType); mediaType != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.nd.Cid(), tc.expected, mimeType)
+		}
+	}
+
+	// the MIME type is cached, the blocks aren't read again
+	if err := dag.Remove(ctx, png.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	mimeType, err := mc.MimeType(ctx, dag, png.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mimeType != "image/png" {
+		t.Fatalf("expected the cached MIME type, got %q", mimeType)
+	}
+}
@@ -0,0 +1,177 @@
+// Package namecache caches the resolution of IPNS names and DNSLink domains in
+// front of the name system, and lets cached names be purged: a CDN in front of
+// the gateway can then be told to fetch the new version of a mutable name as
+// soon as it is published, without waiting for the TTL of its record.
+package namecache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/namesys"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/kubo/core/nameprov"
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type entry struct {
+	res namesys.Result
+	eol time.Time
+}
+
+// Cache is a name system caching the resolution of names for the TTL of their
+// records, capped to a maximum.
+type Cache struct {
+	namesys.NameSystem
+	cache  *lru.Cache[string, entry]
+	maxTTL time.Duration
+	clock  clock.Clock
+
+	lk       sync.Mutex
+	attached []*Cache
+}
+
+// New returns a cache of size names in front of ns, which should not cache
+// names itself. The cached names expire according to clk.
+func New(ns namesys.NameSystem, size int, maxTTL time.Duration, clk clock.Clock) (*Cache, error) {
+	cache, err := lru.New[string, entry](size)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{NameSystem: ns, cache: cache, maxTTL: maxTTL, clock: clk}, nil
+}
+
+// Key returns the name of a mutable path, or a name, in the form the cache
+// and the surrogate keys of the gateway use: IPNS names are normalized to
+// their CIDv1, and domains are lowercased.
+func Key(name string) string {
+	name = strings.TrimPrefix(name, ipns.NamespacePrefix)
+	name, _, _ = strings.Cut(name, "/")
+	if n, err := ipns.NameFromString(name); err == nil {
+		return n.String()
+	}
+	return strings.ToLower(name)
+}
+
+// SurrogateKey returns the surrogate key of the gateway responses for a
+// name, to purge from the cache of a CDN once the name changes. The prefix
+// tells the keys of the names apart from the ones of the CIDs.
+func SurrogateKey(name string) string {
+	return "ipns:" + Key(name)
+}
+
+// Resolve returns the cached resolution of the name of p, if any.
+func (c *Cache) Resolve(ctx context.Context, p path.Path, opts ...namesys.ResolveOption) (namesys.Result, error) {
+	segments := p.Segments()
+	// only the resolutions with the default options are cached
+	if !p.Mutable() || len(opts) > 0 || len(segments) < 2 {
+		return c.NameSystem.Resolve(ctx, p, opts...)
+	}
+
+	key := Key(segments[1])
+	e, ok := c.cache.Get(key)
+	if !ok || c.clock.Now().After(e.eol) {
+		base, err := path.NewPathFromSegments(segments[0], key)
+		if err != nil {
+			return namesys.Result{}, err
+		}
+		res, err := c.NameSystem.Resolve(ctx, base)
+		if err != nil {
+			return res, err
+		}
+		e = c.set(key, res)
+	} else if rec := nameprov.FromContext(ctx); rec != nil {
+		rec.Cached(key, e.res.Path.String())
+	}
+
+	res := e.res
+	var err error
+	res.Path, err = path.Join(res.Path, segments[2:]...)
+	return res, err
+}
+
+func (c *Cache) set(key string, res namesys.Result) entry {
+	e := entry{res: res}
+	ttl := res.TTL
+	if ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+	if ttl > 0 {
+		e.eol = c.clock.Now().Add(ttl)
+		c.cache.Add(key, e)
+	}
+	return e
+}
+
+// Publish publishes the name of sk, and caches its new value.
+func (c *Cache) Publish(ctx context.Context, sk ci.PrivKey, value path.Path, opts ...namesys.PublishOption) error {
+	pid, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return err
+	}
+	key := ipns.NameFromPeer(pid).String()
+	if err := c.NameSystem.Publish(ctx, sk, value, opts...); err != nil {
+		c.Purge(key)
+		return err
+	}
+	// the TTL of the value published, as cached by the name system of boxo
+	publishOpts := namesys.ProcessPublishOptions(opts)
+	ttl := namesys.DefaultResolverCacheTTL
+	if publishOpts.TTL >= 0 {
+		ttl = publishOpts.TTL
+	}
+	if ttEOL := c.clock.Until(publishOpts.EOL); ttEOL < ttl {
+		ttl = ttEOL
+	}
+	c.Purge(key)
+	c.set(key, namesys.Result{Path: value, TTL: ttl, LastMod: c.clock.Now()})
+	return nil
+}
+
+// Purge removes names from the cache, and from the attached caches. It
+// returns whether any of them was cached.
+func (c *Cache) Purge(names ...string) bool {
+	var purged bool
+	for _, name := range names {
+		if c.cache.Remove(Key(name)) {
+			purged = true
+		}
+	}
+	for _, a := range c.attachedCaches() {
+		if a.Purge(names...) {
+			purged = true
+		}
+	}
+	return purged
+}
+
+// PurgeAll empties the cache, and the attached caches. It returns the number
+// of names purged from c.
+func (c *Cache) PurgeAll() int {
+	n := c.cache.Len()
+	c.cache.Purge()
+	for _, a := range c.attachedCaches() {
+		a.PurgeAll()
+	}
+	return n
+}
+
+// Attach makes the purges of c purge other as well, such as the cache of the
+// offline name system of a gateway with Gateway.NoFetch.
+func (c *Cache) Attach(other *Cache) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	c.attached = append(c.attached, other)
+}
+
+func (c *Cache) attachedCaches() []*Cache {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return append([]*Cache(nil), c.attached...)
+}
@@ -0,0 +1,113 @@
+package namecache
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/namesys"
+	"github.com/ipfs/boxo/path"
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+type mockNamesys struct {
+	namesys.NameSystem
+	values   map[string]path.Path
+	resolves int
+}
+
+func (m *mockNamesys) Resolve(ctx context.Context, p path.Path, opts ...namesys.ResolveOption) (namesys.Result, error) {
+	m.resolves++
+	v, ok := m.values[p.String()]
+	if !ok {
+		return namesys.Result{}, namesys.ErrResolveFailed
+	}
+	return namesys.Result{Path: v, TTL: time.Hour}, nil
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	v1, err := path.NewPath("/ipfs/bafkqaaa")
+	require.NoError(t, err)
+	v2, err := path.NewPath("/ipfs/bafkqaaa/dir")
+	require.NoError(t, err)
+	m := &mockNamesys{values: map[string]path.Path{"/ipns/example.com": v1}}
+
+	clk := clock.NewMock()
+	c, err := New(m, 16, time.Minute, clk)
+	require.NoError(t, err)
+
+	p, err := path.NewPath("/ipns/Example.com/file.txt")
+	require.NoError(t, err)
+	res, err := c.Resolve(ctx, p)
+	require.NoError(t, err)
+	require.Equal(t, "/ipfs/bafkqaaa/file.txt", res.Path.String())
+
+	// cached, whatever the case of the domain
+	m.values["/ipns/example.com"] = v2
+	p, err = path.NewPath("/ipns/example.com")
+	require.NoError(t, err)
+	res, err = c.Resolve(ctx, p)
+	require.NoError(t, err)
+	require.Equal(t, v1.String(), res.Path.String())
+	require.Equal(t, 1, m.resolves)
+
+	// purged from the attached caches too
+	other, err := New(m, 16, time.Minute, clk)
+	require.NoError(t, err)
+	c.Attach(other)
+	_, err = other.Resolve(ctx, p)
+	require.NoError(t, err)
+	require.True(t, c.Purge("/ipns/EXAMPLE.com"))
+	require.Zero(t, other.cache.Len())
+	require.False(t, c.Purge("example.com"))
+
+	res, err = c.Resolve(ctx, p)
+	require.NoError(t, err)
+	require.Equal(t, v2.String(), res.Path.String())
+	require.Equal(t, 1, c.PurgeAll())
+}
+
+func TestCacheExpiry(t *testing.T) {
+	ctx := context.Background()
+	v, err := path.NewPath("/ipfs/bafkqaaa")
+	require.NoError(t, err)
+	m := &mockNamesys{values: map[string]path.Path{"/ipns/example.com": v}}
+
+	clk := clock.NewMock()
+	c, err := New(m, 16, time.Minute, clk)
+	require.NoError(t, err)
+
+	p, err := path.NewPath("/ipns/example.com")
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = c.Resolve(ctx, p)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, m.resolves)
+
+	// the TTL of the record is capped to the maximum of the cache
+	clk.Add(time.Minute + time.Second)
+	_, err = c.Resolve(ctx, p)
+	require.NoError(t, err)
+	require.Equal(t, 2, m.resolves)
+}
+
+func TestKey(t *testing.T) {
+	require.Equal(t, "example.com", Key("/ipns/Example.COM/a/b"))
+	require.Equal(t, "ipns:example.com", SurrogateKey("example.com"))
+
+	// peer IDs are normalized to their CIDv1
+	_, pk, err := ci.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	pid, err := peer.IDFromPublicKey(pk)
+	require.NoError(t, err)
+	name := ipns.NameFromPeer(pid).String()
+	require.Equal(t, name, Key(pid.String()))
+	require.Equal(t, name, Key("/ipns/"+name))
+}
@@ -0,0 +1,63 @@
+package nameprov
+
+import (
+	"context"
+	"strings"
+
+	madns "github.com/multiformats/go-multiaddr-dns"
+)
+
+// SystemResolver names the DNS resolver of the operating system.
+const SystemResolver = "system"
+
+// defaultResolvers are the resolvers the gateway of boxo uses for the domains
+// not in DNS.Resolvers.
+var defaultResolvers = map[string]string{
+	"eth.":    "https://resolver.cloudflare-eth.com/dns-query",
+	"crypto.": "https://resolver.cloudflare-eth.com/dns-query",
+}
+
+// ResolverOf returns the DNS resolver used for domain, with the DNS.Resolvers
+// of the config: the URL of a DNS-over-HTTPS resolver, or SystemResolver.
+func ResolverOf(resolvers map[string]string, domain string) string {
+	// the config overrides the defaults
+	all := make(map[string]string, len(defaultResolvers)+len(resolvers))
+	for d, u := range defaultResolvers {
+		all[d] = u
+	}
+	for d, u := range resolvers {
+		all[d] = u
+	}
+
+	// the most specific domain wins
+	domain = strings.TrimSuffix(domain, ".") + "."
+	match, url := "", all["."]
+	for d, u := range all {
+		if d != "." && (domain == d || strings.HasSuffix(domain, "."+d)) && len(d) > len(match) {
+			match, url = d, u
+		}
+	}
+	if url == "" {
+		return SystemResolver
+	}
+	return url
+}
+
+// DNSResolver returns r, recording the DNSLink TXT records it returns and the
+// resolver used, for the contexts carrying a Recorder.
+func DNSResolver(r madns.BasicResolver, resolvers map[string]string) madns.BasicResolver {
+	return &dnsResolver{BasicResolver: r, resolvers: resolvers}
+}
+
+type dnsResolver struct {
+	madns.BasicResolver
+	resolvers map[string]string
+}
+
+func (r *dnsResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	txt, err := r.BasicResolver.LookupTXT(ctx, name)
+	if rec := FromContext(ctx); rec != nil && err == nil {
+		rec.TXT(name, ResolverOf(r.resolvers, name), txt)
+	}
+	return txt, err
+}
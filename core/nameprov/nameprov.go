@@ -0,0 +1,199 @@
+// Package nameprov records how names are resolved, to make trust decisions
+// and debugging possible: the IPNS records returned by each router with the
+// DHT peers that answered, the DNSLink TXT records with the DNS resolver that
+// returned them, and the names answered from the cache.
+//
+// Only the resolutions whose context carries a Recorder are recorded.
+package nameprov
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+)
+
+// Sources of the answers, besides the names of the routers.
+const (
+	SourceCache   = "cache"
+	SourceDNSLink = "dnslink"
+)
+
+type lookup struct {
+	name     string
+	cached   string
+	records  []coreiface.IpnsRecordProvenance
+	peers    map[peer.ID]struct{}
+	txt      []string
+	resolver string
+}
+
+// Recorder records the resolutions of names.
+type Recorder struct {
+	lk      sync.Mutex
+	lookups map[string]*lookup
+	// order is the order in which the names were first looked up
+	order []string
+}
+
+// NewRecorder returns an empty recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{lookups: make(map[string]*lookup)}
+}
+
+type recorderKey struct{}
+
+// WithRecorder returns a context whose resolutions are recorded by r.
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// FromContext returns the recorder of ctx, or nil.
+func FromContext(ctx context.Context) *Recorder {
+	r, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return r
+}
+
+// lookup returns the lookup of name, with r.lk held.
+func (r *Recorder) lookup(name string) *lookup {
+	l, ok := r.lookups[name]
+	if !ok {
+		l = &lookup{name: name, peers: make(map[peer.ID]struct{})}
+		r.lookups[name] = l
+		r.order = append(r.order, name)
+	}
+	return l
+}
+
+// ipnsName returns the name of an IPNS routing key, or "" for other keys.
+func ipnsName(key string) string {
+	name, err := ipns.NameFromRoutingKey([]byte(key))
+	if err != nil {
+		return ""
+	}
+	return name.String()
+}
+
+// Record records a value returned by a router for a routing key.
+func (r *Recorder) Record(router, key string, value []byte) {
+	name := ipnsName(key)
+	if name == "" {
+		return
+	}
+	rec, err := ipns.UnmarshalRecord(value)
+	if err != nil {
+		return
+	}
+	seq, err := rec.Sequence()
+	if err != nil {
+		return
+	}
+	p, err := rec.Value()
+	if err != nil {
+		return
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	l := r.lookup(name)
+	for _, e := range l.records {
+		if e.Router == router && e.Sequence == seq {
+			return
+		}
+	}
+	l.records = append(l.records, coreiface.IpnsRecordProvenance{Router: router, Sequence: seq, Value: p.String()})
+}
+
+// Peer records a DHT peer that answered a query for a routing key.
+func (r *Recorder) Peer(key string, p peer.ID) {
+	name := ipnsName(key)
+	if name == "" {
+		return
+	}
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.lookup(name).peers[p] = struct{}{}
+}
+
+// TXT records the DNSLink TXT records of a domain, and the resolver that
+// returned them.
+func (r *Recorder) TXT(domain, resolver string, txt []string) {
+	domain = strings.TrimPrefix(strings.TrimSuffix(domain, "."), "_dnslink.")
+	var links []string
+	for _, t := range txt {
+		if strings.HasPrefix(t, "dnslink=") {
+			links = append(links, t)
+		}
+	}
+	if len(links) == 0 {
+		return
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	l := r.lookup(domain)
+	l.txt = append(l.txt, links...)
+	l.resolver = resolver
+}
+
+// Cached records a name answered from the cache.
+func (r *Recorder) Cached(name, value string) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.lookup(name).cached = value
+}
+
+// Provenance returns the steps of the resolution of name, in the order they
+// were looked up. value is what name resolved to: a resolution with no step
+// recorded was answered from a cache of the name system.
+func (r *Recorder) Provenance(name, value string) []coreiface.NameProvenance {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	var steps []coreiface.NameProvenance
+	for _, n := range r.order {
+		l := r.lookups[n]
+		step := coreiface.NameProvenance{Name: ipns.NamespacePrefix + n}
+		switch {
+		case l.cached != "":
+			step.Source, step.Value = SourceCache, l.cached
+		case len(l.txt) > 0:
+			step.Source = SourceDNSLink
+			step.Value = strings.TrimPrefix(l.txt[0], "dnslink=")
+			step.TXT = l.txt
+			step.Resolver = l.resolver
+		case len(l.records) > 0:
+			// the best record is the one with the highest sequence number
+			best := l.records[0]
+			for _, e := range l.records[1:] {
+				if e.Sequence > best.Sequence {
+					best = e
+				}
+			}
+			step.Source, step.Value, step.Sequence = best.Router, best.Value, best.Sequence
+			step.Records = l.records
+		default:
+			// only peers answered, without the record
+			continue
+		}
+		for p := range l.peers {
+			step.Peers = append(step.Peers, p)
+		}
+		sort.Slice(step.Peers, func(i, j int) bool { return step.Peers[i] < step.Peers[j] })
+		steps = append(steps, step)
+	}
+
+	if len(steps) == 0 {
+		steps = append(steps, coreiface.NameProvenance{
+			Name:   name,
+			Source: SourceCache,
+			Value:  value,
+		})
+	}
+	return steps
+}
@@ -0,0 +1,97 @@
+package nameprov
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/path"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolverOf(t *testing.T) {
+	resolvers := map[string]string{
+		"example.com.": "https://dns.example.com/dns-query",
+		"eth.":         "https://eth.example.net/dns-query",
+	}
+	require.Equal(t, "https://dns.example.com/dns-query", ResolverOf(resolvers, "_dnslink.docs.example.com."))
+	require.Equal(t, "https://eth.example.net/dns-query", ResolverOf(resolvers, "vitalik.eth"))
+	require.Equal(t, defaultResolvers["crypto."], ResolverOf(resolvers, "brantly.crypto"))
+	require.Equal(t, SystemResolver, ResolverOf(resolvers, "ipfs.tech"))
+
+	resolvers["."] = "https://all.example.net/dns-query"
+	require.Equal(t, "https://all.example.net/dns-query", ResolverOf(resolvers, "ipfs.tech"))
+}
+
+func TestDNSLink(t *testing.T) {
+	rec := NewRecorder()
+	rec.TXT("_dnslink.example.com.", SystemResolver, []string{"v=spf1 -all", "dnslink=/ipfs/bafkqaaa"})
+
+	steps := rec.Provenance("/ipns/example.com", "/ipfs/bafkqaaa")
+	require.Len(t, steps, 1)
+	require.Equal(t, "/ipns/example.com", steps[0].Name)
+	require.Equal(t, "/ipfs/bafkqaaa", steps[0].Value)
+	require.Equal(t, SourceDNSLink, steps[0].Source)
+	require.Equal(t, SystemResolver, steps[0].Resolver)
+	require.Equal(t, []string{"dnslink=/ipfs/bafkqaaa"}, steps[0].TXT)
+}
+
+func TestIpnsRecords(t *testing.T) {
+	sk, _, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	pid, err := peer.IDFromPrivateKey(sk)
+	require.NoError(t, err)
+	name := ipns.NameFromPeer(pid)
+	key := string(name.RoutingKey())
+
+	record := func(seq uint64, value string) []byte {
+		p, err := path.NewPath(value)
+		require.NoError(t, err)
+		rec, err := ipns.NewRecord(sk, p, seq, time.Now().Add(time.Hour), time.Minute)
+		require.NoError(t, err)
+		data, err := ipns.MarshalRecord(rec)
+		require.NoError(t, err)
+		return data
+	}
+
+	rec := NewRecorder()
+	rec.Record("routing", key, record(1, "/ipfs/bafkqaaa"))
+	rec.Record("pubsub", key, record(2, "/ipfs/bafkqaaa/v2"))
+	rec.Record("routing", key, record(1, "/ipfs/bafkqaaa"))
+	rec.Record("routing", "/pk/whatever", []byte("not a record"))
+	rec.Peer(key, "QmPeerB")
+	rec.Peer(key, "QmPeerA")
+
+	steps := rec.Provenance(name.String(), "/ipfs/bafkqaaa/v2")
+	require.Len(t, steps, 1)
+	require.Equal(t, ipns.NamespacePrefix+name.String(), steps[0].Name)
+	require.Equal(t, "pubsub", steps[0].Source)
+	require.Equal(t, "/ipfs/bafkqaaa/v2", steps[0].Value)
+	require.EqualValues(t, 2, steps[0].Sequence)
+	require.Len(t, steps[0].Records, 2)
+	require.Equal(t, []peer.ID{"QmPeerA", "QmPeerB"}, steps[0].Peers)
+}
+
+func TestCache(t *testing.T) {
+	rec := NewRecorder()
+	rec.Cached("example.com", "/ipfs/bafkqaaa")
+	steps := rec.Provenance("/ipns/example.com", "/ipfs/bafkqaaa")
+	require.Len(t, steps, 1)
+	require.Equal(t, SourceCache, steps[0].Source)
+
+	// nothing recorded: answered from the cache of the name system
+	steps = NewRecorder().Provenance("/ipns/example.org", "/ipfs/bafkqaaa")
+	require.Len(t, steps, 1)
+	require.Equal(t, "/ipns/example.org", steps[0].Name)
+	require.Equal(t, SourceCache, steps[0].Source)
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	require.Nil(t, FromContext(ctx))
+	rec := NewRecorder()
+	require.Same(t, rec, FromContext(WithRecorder(ctx, rec)))
+}
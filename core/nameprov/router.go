@@ -0,0 +1,83 @@
+package nameprov
+
+import (
+	"context"
+
+	"github.com/libp2p/go-libp2p/core/routing"
+
+	irouting "github.com/ipfs/kubo/routing"
+)
+
+// Router returns r, recording the values it returns as coming from the router
+// named name, and the DHT peers that answered, for the contexts carrying a
+// Recorder.
+func Router(r routing.Routing, name string) routing.Routing {
+	return irouting.KeepProvideMany(&router{Routing: r, name: name}, r)
+}
+
+type router struct {
+	routing.Routing
+	name string
+}
+
+// watch records the DHT peers answering the queries of ctx. The returned
+// function must be called once the query is done.
+func (r *router) watch(ctx context.Context, rec *Recorder, key string) (context.Context, func()) {
+	// don't steal the events of a caller listening to them
+	if routing.SubscribesToQueryEvents(ctx) {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	ctx, events := routing.RegisterForQueryEvents(ctx)
+	go func() {
+		for e := range events {
+			if e.Type == routing.PeerResponse {
+				rec.Peer(key, e.ID)
+			}
+		}
+	}()
+	return ctx, cancel
+}
+
+func (r *router) GetValue(ctx context.Context, key string, opts ...routing.Option) ([]byte, error) {
+	rec := FromContext(ctx)
+	if rec == nil {
+		return r.Routing.GetValue(ctx, key, opts...)
+	}
+	ctx, done := r.watch(ctx, rec, key)
+	defer done()
+
+	val, err := r.Routing.GetValue(ctx, key, opts...)
+	if err == nil {
+		rec.Record(r.name, key, val)
+	}
+	return val, err
+}
+
+func (r *router) SearchValue(ctx context.Context, key string, opts ...routing.Option) (<-chan []byte, error) {
+	rec := FromContext(ctx)
+	if rec == nil {
+		return r.Routing.SearchValue(ctx, key, opts...)
+	}
+	ctx, done := r.watch(ctx, rec, key)
+
+	vals, err := r.Routing.SearchValue(ctx, key, opts...)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	out := make(chan []byte)
+	go func() {
+		defer done()
+		defer close(out)
+		for val := range vals {
+			rec.Record(r.name, key, val)
+			select {
+			case out <- val:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -2,15 +2,24 @@ package node
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/ipfs/boxo/bitswap"
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
 	"github.com/ipfs/boxo/bitswap/network"
+	"github.com/ipfs/boxo/bitswap/tracer"
 	blockstore "github.com/ipfs/boxo/blockstore"
 	exchange "github.com/ipfs/boxo/exchange"
 	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/bsfair"
+	"github.com/ipfs/kubo/core/pinreport"
+	"github.com/ipfs/kubo/core/provenance"
+	"github.com/ipfs/kubo/repo"
 	irouting "github.com/ipfs/kubo/routing"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/fx"
 
 	"github.com/ipfs/kubo/core/node/helpers"
@@ -53,6 +62,102 @@ func BitswapOptions(cfg *config.Config, provide bool) interface{} {
 	}
 }
 
+type blockSourcesOut struct {
+	fx.Out
+
+	Recorder *pinreport.Recorder
+	Tracer   tracer.Tracer `group:"bitswap-tracers"`
+}
+
+// BlockSources records which peers sent the blocks received by Bitswap, for
+// the accounting of pins.
+func BlockSources(h host.Host) blockSourcesOut {
+	rec := pinreport.NewRecorder(h, pinreport.DefaultRecentBlocks)
+	return blockSourcesOut{
+		Recorder: rec,
+		Tracer:   rec,
+	}
+}
+
+type blockProvenanceOut struct {
+	fx.Out
+
+	Recorder *provenance.Recorder
+	Tracer   tracer.Tracer `group:"bitswap-tracers"`
+}
+
+// BlockProvenance records which peers sent the blocks received by Bitswap in
+// the datastore, see Datastore.Provenance.
+func BlockProvenance(cfg config.DatastoreProvenance) interface{} {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, h host.Host, repo repo.Repo) (blockProvenanceOut, error) {
+		rec, err := provenance.New(mctx, repo.Datastore(), h, provenance.Options{
+			Sampling:   int(cfg.Sampling.WithDefault(config.DefaultDatastoreProvenanceSampling)),
+			MaxRecords: int(cfg.MaxRecords.WithDefault(config.DefaultDatastoreProvenanceMaxRecords)),
+		})
+		if err != nil {
+			return blockProvenanceOut{}, err
+		}
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				rec.Start()
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				return rec.Close()
+			},
+		})
+		return blockProvenanceOut{
+			Recorder: rec,
+			Tracer:   rec,
+		}, nil
+	}
+}
+
+type bitswapFairOut struct {
+	fx.Out
+
+	Tracer      tracer.Tracer    `group:"bitswap-tracers"`
+	BitswapOpts []bitswap.Option `group:"bitswap-options,flatten"`
+}
+
+// BitswapFairScheduling orders the tasks of the Bitswap server with a
+// bsfair.Scheduler, see Internal.Bitswap.FairScheduling.
+func BitswapFairScheduling(cfg *config.BitswapFairScheduling) interface{} {
+	return func() (bitswapFairOut, error) {
+		for _, c := range bsfair.Collectors() {
+			err := prometheus.Register(c)
+			if are := (prometheus.AlreadyRegisteredError{}); err != nil && !errors.As(err, &are) {
+				return bitswapFairOut{}, err
+			}
+		}
+
+		s := bsfair.New(bsfair.Config{
+			MaxWantlist: int(cfg.MaxWantlist.WithDefault(bsfair.DefaultMaxWantlist)),
+			MinAckRate:  float64(cfg.MinAckRate.WithDefault(bsfair.DefaultMinAckRate*100)) / 100,
+		})
+		return bitswapFairOut{
+			Tracer:      s,
+			BitswapOpts: []bitswap.Option{bitswap.WithTaskComparator(s.Compare)},
+		}, nil
+	}
+}
+
+// bitswapTracers passes the Bitswap messages to several tracers, as Bitswap
+// only takes one.
+type bitswapTracers []tracer.Tracer
+
+func (ts bitswapTracers) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	for _, t := range ts {
+		t.MessageReceived(p, msg)
+	}
+}
+
+func (ts bitswapTracers) MessageSent(p peer.ID, msg bsmsg.BitSwapMessage) {
+	for _, t := range ts {
+		t.MessageSent(p, msg)
+	}
+}
+
 type onlineExchangeIn struct {
 	fx.In
 
@@ -61,16 +166,29 @@ type onlineExchangeIn struct {
 	Rt          irouting.ProvideManyRouter
 	Bs          blockstore.GCBlockstore
 	BitswapOpts []bitswap.Option `group:"bitswap-options"`
+	Tracers     []tracer.Tracer  `group:"bitswap-tracers"`
+	Shared      *SharedContexts  `optional:"true"`
 }
 
 // OnlineExchange creates new LibP2P backed block exchange (BitSwap).
 // Additional options to bitswap.New can be provided via the "bitswap-options"
-// group.
+// group, and tracers of its messages via the "bitswap-tracers" group.
 func OnlineExchange() interface{} {
 	return func(in onlineExchangeIn, lc fx.Lifecycle) exchange.Interface {
 		bitswapNetwork := network.NewFromIpfsHost(in.Host, in.Rt)
 
-		exch := bitswap.New(helpers.LifecycleCtx(in.Mctx, lc), bitswapNetwork, in.Bs, in.BitswapOpts...)
+		opts := append([]bitswap.Option(nil), in.BitswapOpts...)
+		if len(in.Tracers) > 0 {
+			opts = append(opts, bitswap.WithTracer(bitswapTracers(in.Tracers)))
+		}
+
+		// serve the blocks of the repo contexts sharing the identity of the node
+		var bs blockstore.Blockstore = in.Bs
+		if in.Shared != nil {
+			bs = in.Shared.Blockstore(bs)
+		}
+
+		exch := bitswap.New(helpers.LifecycleCtx(in.Mctx, lc), bitswapNetwork, bs, opts...)
 		lc.Append(fx.Hook{
 			OnStop: func(ctx context.Context) error {
 				return exch.Close()
@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"errors"
 
+	"github.com/benbjohnson/clock"
 	"go.uber.org/fx"
 
 	"github.com/ipfs/kubo/core/node/helpers"
@@ -40,6 +41,15 @@ type BuildCfg struct {
 	Routing libp2p.RoutingOption
 	Host    libp2p.HostOption
 	Repo    repo.Repo
+
+	// Clock is the clock of the validity of the IPNS records, published and
+	// received, the grace periods of the pins, the TTLs of MFS paths, the name
+	// cache and the times of the add events.
+	// Tests and embedders can set a mock clock to expire them without
+	// waiting. Defaults to the wall clock. The DHT validates the records
+	// against the wall clock, and refuses to start with another one: set
+	// Routing to another routing then.
+	Clock clock.Clock
 }
 
 func (cfg *BuildCfg) getOpt(key string) bool {
@@ -77,6 +87,10 @@ func (cfg *BuildCfg) fillDefaults() error {
 		cfg.Host = libp2p.DefaultHostOption
 	}
 
+	if cfg.Clock == nil {
+		cfg.Clock = clock.New()
+	}
+
 	return nil
 }
 
@@ -109,6 +123,10 @@ func (cfg *BuildCfg) options(ctx context.Context) (fx.Option, *cfg.Config) {
 		return cfg.Routing
 	})
 
+	clockOption := fx.Provide(func() clock.Clock {
+		return cfg.Clock
+	})
+
 	conf, err := cfg.Repo.Config()
 	if err != nil {
 		return fx.Error(err), nil
@@ -118,6 +136,7 @@ func (cfg *BuildCfg) options(ctx context.Context) (fx.Option, *cfg.Config) {
 		repoOption,
 		hostOption,
 		routingOption,
+		clockOption,
 		metricsCtx,
 	), conf
 }
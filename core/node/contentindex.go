@@ -0,0 +1,32 @@
+package node
+
+import (
+	"context"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/contentindex"
+	"github.com/ipfs/kubo/repo"
+)
+
+// ContentIndex creates the index of the local content, when enabled. The
+// index only reads the blocks stored locally.
+func ContentIndex(enabled bool) fx.Option {
+	if !enabled {
+		return fx.Options()
+	}
+	return fx.Provide(func(lc fx.Lifecycle, repo repo.Repo, bs blockstore.Blockstore) *contentindex.Index {
+		dag := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+		idx := contentindex.New(repo.Datastore(), dag)
+		lc.Append(fx.Hook{
+			OnStop: func(ctx context.Context) error {
+				return idx.Close()
+			},
+		})
+		return idx
+	})
+}
@@ -24,12 +24,31 @@ import (
 	dagpb "github.com/ipld/go-codec-dagpb"
 	"go.uber.org/fx"
 
+	"github.com/ipfs/kubo/core/cachewarm"
+	"github.com/ipfs/kubo/core/contentindex"
+	"github.com/ipfs/kubo/core/fulltext"
+	"github.com/ipfs/kubo/core/httphints"
+	"github.com/ipfs/kubo/core/mfsrefs"
+	"github.com/ipfs/kubo/core/mimetypes"
 	"github.com/ipfs/kubo/core/node/helpers"
 	"github.com/ipfs/kubo/repo"
 )
 
+type blockServiceIn struct {
+	fx.In
+
+	Lc         fx.Lifecycle
+	Blockstore blockstore.Blockstore
+	Exchange   exchange.Interface
+	HTTPHints  *httphints.Exchange `optional:"true"`
+}
+
 // BlockService creates new blockservice which provides an interface to fetch content-addressable blocks
-func BlockService(lc fx.Lifecycle, bs blockstore.Blockstore, rem exchange.Interface) blockservice.BlockService {
+func BlockService(in blockServiceIn) blockservice.BlockService {
+	lc, bs, rem := in.Lc, in.Blockstore, in.Exchange
+	if in.HTTPHints != nil {
+		rem = in.HTTPHints
+	}
 	bsvc := blockservice.New(bs, rem)
 
 	lc.Append(fx.Hook{
@@ -145,10 +164,53 @@ func Dag(bs blockservice.BlockService) format.DAGService {
 	return merkledag.NewDAGService(bs)
 }
 
+// MimeTypes creates the cache of the MIME types of files
+func MimeTypes(repo repo.Repo) *mimetypes.Cache {
+	return mimetypes.New(repo.Datastore())
+}
+
+// CacheWarm creates the store of the DAGs protected by 'ipfs cache warm'
+func CacheWarm(repo repo.Repo) *cachewarm.Store {
+	return cachewarm.NewStore(repo.Datastore())
+}
+
+// MFSRefs creates the index of the blocks referenced by MFS, which spares
+// garbage collections reading the whole MFS tree
+func MFSRefs(lc fx.Lifecycle, repo repo.Repo, bs blockstore.Blockstore) *mfsrefs.Index {
+	ix := mfsrefs.New(repo.Datastore(), bs)
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			return ix.Close()
+		},
+	})
+	return ix
+}
+
+type filesIn struct {
+	fx.In
+
+	Mctx         helpers.MetricsCtx
+	Lc           fx.Lifecycle
+	Repo         repo.Repo
+	Dag          format.DAGService
+	MFSRefs      *mfsrefs.Index
+	ContentIndex *contentindex.Index `optional:"true"`
+	FullText     *fulltext.Indexer   `optional:"true"`
+}
+
 // Files loads persisted MFS root
-func Files(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, dag format.DAGService) (*mfs.Root, error) {
+func Files(in filesIn) (*mfs.Root, error) {
+	mctx, lc, repo, dag := in.Mctx, in.Lc, in.Repo, in.Dag
 	dsk := datastore.NewKey("/local/filesroot")
 	pf := func(ctx context.Context, c cid.Cid) error {
+		in.MFSRefs.UpdateMFS(c)
+		if in.ContentIndex != nil {
+			in.ContentIndex.UpdateMFS(c)
+		}
+		if in.FullText != nil {
+			in.FullText.UpdateMFS(c)
+		}
+
 		rootDS := repo.Datastore()
 		if err := rootDS.Sync(ctx, blockstore.BlockPrefix); err != nil {
 			return err
@@ -196,6 +258,13 @@ func Files(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, dag format.
 	}
 
 	root, err := mfs.NewRoot(ctx, dag, nd, pf)
+	in.MFSRefs.UpdateMFS(nd.Cid())
+	if in.ContentIndex != nil {
+		in.ContentIndex.UpdateMFS(nd.Cid())
+	}
+	if in.FullText != nil {
+		in.FullText.UpdateMFS(nd.Cid())
+	}
 
 	lc.Append(fx.Hook{
 		OnStop: func(ctx context.Context) error {
@@ -0,0 +1,26 @@
+package node
+
+import (
+	"context"
+
+	"github.com/benbjohnson/clock"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/dhthealth"
+)
+
+// DHTHealth creates the monitor of the routing tables of the DHT and of the
+// routing queries, see 'ipfs stats dht health'.
+func DHTHealth(lc fx.Lifecycle, clk clock.Clock) *dhthealth.Monitor {
+	m := dhthealth.New(clk)
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			m.Start(dhthealth.DefaultInterval)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			return m.Close()
+		},
+	})
+	return m
+}
@@ -0,0 +1,37 @@
+package node
+
+import (
+	"context"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/fulltext"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/repo"
+)
+
+// FullTextSearch creates the full-text index of MFS and of the pinned DAGs,
+// when enabled. The index only reads the blocks stored locally.
+func FullTextSearch(enabled bool) fx.Option {
+	if !enabled {
+		return fx.Options()
+	}
+	return fx.Provide(func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, bs blockstore.Blockstore, pinner pin.Pinner) (*fulltext.Indexer, error) {
+		dag := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+		ix, err := fulltext.New(helpers.LifecycleCtx(mctx, lc), repo.Datastore(), dag, pinner)
+		if err != nil {
+			return nil, err
+		}
+		lc.Append(fx.Hook{
+			OnStop: func(ctx context.Context) error {
+				return ix.Close()
+			},
+		})
+		return ix, nil
+	})
+}
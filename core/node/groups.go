@@ -13,6 +13,9 @@ import (
 	util "github.com/ipfs/boxo/util"
 	"github.com/ipfs/go-log"
 	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/dagshape"
+	"github.com/ipfs/kubo/core/hashing"
+	"github.com/ipfs/kubo/core/httphints"
 	"github.com/ipfs/kubo/core/node/libp2p"
 	"github.com/ipfs/kubo/p2p"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
@@ -122,6 +125,11 @@ func LibP2P(bcfg *BuildCfg, cfg *config.Config, userResourceOverrides rcmgr.Part
 		}
 	}
 
+	appendAnnounce, err := httphints.AppendAnnounce(cfg.Addresses.AppendAnnounce, cfg.Experimental.HTTPProviderURLs)
+	if err != nil {
+		return fx.Error(err)
+	}
+
 	// Gather all the options
 	opts := fx.Options(
 		BaseLibP2P,
@@ -131,16 +139,28 @@ func LibP2P(bcfg *BuildCfg, cfg *config.Config, userResourceOverrides rcmgr.Part
 
 		// Services (resource management)
 		fx.Provide(libp2p.ResourceManager(cfg.Swarm, userResourceOverrides)),
+		fx.Provide(libp2p.ConnEvents),
+		fx.Provide(libp2p.PeerPolicy(cfg.Swarm.PeerPolicies)),
 		fx.Provide(libp2p.AddrFilters(cfg.Swarm.AddrFilters)),
-		fx.Provide(libp2p.AddrsFactory(cfg.Addresses.Announce, cfg.Addresses.AppendAnnounce, cfg.Addresses.NoAnnounce)),
+		fx.Invoke(libp2p.StartConnEvents),
+		fx.Invoke(libp2p.StartPeerPolicy),
+		fx.Provide(libp2p.AddrsFactory(cfg.Addresses.Announce, appendAnnounce, cfg.Addresses.NoAnnounce)),
 		fx.Provide(libp2p.SmuxTransport(cfg.Swarm.Transports)),
 		fx.Provide(libp2p.RelayTransport(enableRelayTransport)),
 		fx.Provide(libp2p.RelayService(enableRelayService, cfg.Swarm.RelayService)),
+		fx.Provide(libp2p.SwarmProxy(cfg.Swarm.Proxy)),
+		fx.Provide(libp2p.BandwidthLimiter(cfg.Swarm.Bandwidth)),
 		fx.Provide(libp2p.Transports(cfg.Swarm.Transports)),
 		fx.Provide(libp2p.ListenOn(cfg.Addresses.Swarm)),
+		fx.Provide(libp2p.DialPolicy(
+			cfg.Swarm.DialPolicy.WithDefault(config.DialPolicyDefault),
+			cfg.Swarm.DialFamilyDelay.WithDefault(config.DefaultDialFamilyDelay),
+		)),
+		fx.Invoke(libp2p.DialMetrics),
 		fx.Invoke(libp2p.SetupDiscovery(cfg.Discovery.MDNS.Enabled)),
 		fx.Provide(libp2p.ForceReachability(cfg.Internal.Libp2pForceReachability)),
 		fx.Provide(libp2p.HolePunching(cfg.Swarm.EnableHolePunching, enableRelayClient)),
+		maybeInvoke(libp2p.PeerstoreRetention(cfg.Swarm.Peerstore.AddressTTL.WithDefault(config.DefaultPeerstoreAddressTTL)), cfg.Swarm.Peerstore.Persist.WithDefault(false)),
 
 		fx.Provide(libp2p.Security(!bcfg.DisableEncryptedConnections, cfg.Swarm.Transports)),
 
@@ -172,13 +192,16 @@ func Storage(bcfg *BuildCfg, cfg *config.Config) fx.Option {
 
 	finalBstore := fx.Provide(GcBlockstoreCtor)
 	if cfg.Experimental.FilestoreEnabled || cfg.Experimental.UrlstoreEnabled {
-		finalBstore = fx.Provide(FilestoreBlockstoreCtor)
+		finalBstore = fx.Options(
+			fx.Provide(FilestoreSources(cfg.Filestore)),
+			fx.Provide(FilestoreBlockstoreCtor),
+		)
 	}
 
 	return fx.Options(
 		fx.Provide(RepoConfig),
 		fx.Provide(Datastore),
-		fx.Provide(BaseBlockstoreCtor(cacheOpts, bcfg.NilRepo, cfg.Datastore.HashOnRead)),
+		fx.Provide(BaseBlockstoreCtor(cacheOpts, bcfg.NilRepo, cfg.Datastore.HashOnRead, cfg.Datastore.Compression, cfg.Datastore.Remote, cfg.Datastore.WriteBack)),
 		finalBstore,
 	)
 }
@@ -205,7 +228,7 @@ func Identity(cfg *config.Config) fx.Option {
 	if cfg.Identity.PrivKey == "" {
 		return fx.Options( // No PK (usually in tests)
 			fx.Provide(PeerID(id)),
-			fx.Provide(libp2p.Peerstore),
+			fx.Provide(libp2p.Peerstore(cfg.Swarm.Peerstore)),
 		)
 	}
 
@@ -217,7 +240,7 @@ func Identity(cfg *config.Config) fx.Option {
 	return fx.Options( // Full identity
 		fx.Provide(PeerID(id)),
 		fx.Provide(PrivateKey(sk)),
-		fx.Provide(libp2p.Peerstore),
+		fx.Provide(libp2p.Peerstore(cfg.Swarm.Peerstore)),
 
 		fx.Invoke(libp2p.PstoreAddSelfKeys),
 	)
@@ -269,17 +292,33 @@ func Online(bcfg *BuildCfg, cfg *config.Config, userResourceOverrides rcmgr.Part
 	/* don't provide from bitswap when the strategic provider service is active */
 	shouldBitswapProvide := !cfg.Experimental.StrategicProviding
 
+	var fairCfg config.BitswapFairScheduling
+	if cfg.Internal.Bitswap != nil && cfg.Internal.Bitswap.FairScheduling != nil {
+		fairCfg = *cfg.Internal.Bitswap.FairScheduling
+	}
+
 	return fx.Options(
 		fx.Provide(BitswapOptions(cfg, shouldBitswapProvide)),
+		fx.Provide(BlockSources),
+		maybeProvide(BlockProvenance(cfg.Datastore.Provenance), cfg.Datastore.Provenance.Enabled.WithDefault(config.DefaultDatastoreProvenanceEnabled)),
+		maybeProvide(BitswapFairScheduling(&fairCfg), fairCfg.Enabled.WithDefault(false)),
+		fx.Provide(NewSharedContexts),
 		fx.Provide(OnlineExchange()),
+		maybeProvide(HTTPHints, cfg.Experimental.HTTPProviderRetrieval),
 		fx.Provide(DNSResolver),
-		fx.Provide(Namesys(ipnsCacheSize, cfg.Ipns.MaxCacheTTL.WithDefault(config.DefaultIpnsMaxCacheTTL))),
+		fx.Provide(Namesys(ipnsCacheSize, cfg.Ipns.MaxCacheTTL.WithDefault(config.DefaultIpnsMaxCacheTTL), cfg.DNS.Resolvers)),
 		fx.Provide(Peering),
 		PeerWith(cfg.Peering.Peers...),
 
 		fx.Invoke(IpnsRepublisher(repubPeriod, recordLifetime)),
+		PinsetReconciliation(cfg.Experimental.PinsetReconciliation, cfg.Peering.Peers),
+		PinReplication(cfg.Experimental.PinReplication, cfg.Peering.Peers),
+		Standby(cfg.Standby),
+		PinControl(cfg.Pinning.Control, bcfg.getOpt("pubsub")),
+		KV(cfg.Experimental.KVStore, bcfg.getOpt("pubsub")),
 
 		fx.Provide(p2p.New),
+		fx.Provide(DHTHealth),
 
 		LibP2P(bcfg, cfg, userResourceOverrides),
 		OnlineProviders(
@@ -296,7 +335,7 @@ func Offline(cfg *config.Config) fx.Option {
 	return fx.Options(
 		fx.Provide(offline.Exchange),
 		fx.Provide(DNSResolver),
-		fx.Provide(Namesys(0, 0)),
+		fx.Provide(Namesys(0, 0, cfg.DNS.Resolvers)),
 		fx.Provide(libp2p.Routing),
 		fx.Provide(libp2p.ContentRouting),
 		fx.Provide(libp2p.OfflineRouting),
@@ -308,6 +347,9 @@ func Offline(cfg *config.Config) fx.Option {
 var Core = fx.Options(
 	fx.Provide(BlockService),
 	fx.Provide(Dag),
+	fx.Provide(MimeTypes),
+	fx.Provide(CacheWarm),
+	fx.Provide(MFSRefs),
 	fx.Provide(FetcherConfig),
 	fx.Provide(PathResolverConfig),
 	fx.Provide(Pinning),
@@ -345,6 +387,15 @@ func IPFS(ctx context.Context, bcfg *BuildCfg) fx.Option {
 	}
 	uio.HAMTShardingSize = int(shardSizeInt)
 
+	// Hashing settings
+	if cfg.Internal.Hashing != nil {
+		if err := hashing.SetSHA256(cfg.Internal.Hashing.SHA256Implementation.WithDefault(hashing.SHA256Auto)); err != nil {
+			return fx.Error(fmt.Errorf("Internal.Hashing.SHA256Implementation: %w", err))
+		}
+		hashing.SetConcurrency(int(cfg.Internal.Hashing.Concurrency.WithDefault(0)))
+		hashing.SetAddReadAhead(int(cfg.Internal.Hashing.AddReadAhead.WithDefault(0)))
+	}
+
 	// Migrate users of deprecated Experimental.ShardingEnabled flag
 	if cfg.Experimental.ShardingEnabled {
 		logger.Fatal("The `Experimental.ShardingEnabled` field is no longer used, please remove it from the config.\n" +
@@ -363,5 +414,19 @@ func IPFS(ctx context.Context, bcfg *BuildCfg) fx.Option {
 		Networked(bcfg, cfg, userResourceOverrides),
 
 		Core,
+		ReadOnly(cfg.Experimental.ReadOnlyMirror),
+		PinQueue(cfg.Pinning.Queue, dagshape.ParseLimits(cfg.DAGLimits), bcfg.Online),
+		MFSExpiry(bcfg.Online),
+		ProvideClasses(
+			cfg.Reprovider.Strategy.WithDefault(config.DefaultReproviderStrategy),
+			cfg.Reprovider.Interval.WithDefault(config.DefaultReproviderInterval),
+			bcfg.Online,
+		),
+		MFSFlusher(cfg.MFS),
+		ContentIndex(cfg.Experimental.ContentIndex),
+		FullTextSearch(cfg.Experimental.FullTextSearch),
+		Previews(cfg.Experimental.Previews),
+		RepoForecast(cfg.Datastore, bcfg.Online),
+		Webhooks(cfg.Webhooks, bcfg.Online),
 	)
 }
@@ -0,0 +1,18 @@
+package node
+
+import (
+	"github.com/ipfs/boxo/blockstore"
+	exchange "github.com/ipfs/boxo/exchange"
+	"github.com/libp2p/go-libp2p/core/host"
+
+	"github.com/ipfs/kubo/core/httphints"
+	irouting "github.com/ipfs/kubo/routing"
+)
+
+// HTTPHints creates the exchange fetching the blocks Bitswap doesn't find
+// from the trustless gateways announced by their providers, see
+// Experimental.HTTPProviderRetrieval. It wraps the exchange of the
+// blockservice only: nd.Exchange stays Bitswap.
+func HTTPHints(ex exchange.Interface, bs blockstore.Blockstore, rt irouting.ProvideManyRouter, h host.Host) *httphints.Exchange {
+	return httphints.NewExchange(ex, bs, rt, httphints.Options{Self: h.ID()})
+}
@@ -1,46 +1,105 @@
 package node
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/ipfs/boxo/ipns"
 	util "github.com/ipfs/boxo/util"
 	record "github.com/libp2p/go-libp2p-record"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	madns "github.com/multiformats/go-multiaddr-dns"
+	"go.uber.org/fx"
 
 	"github.com/ipfs/boxo/namesys"
 	"github.com/ipfs/boxo/namesys/republisher"
+	"github.com/ipfs/kubo/core/namecache"
+	"github.com/ipfs/kubo/core/nameprov"
 	"github.com/ipfs/kubo/repo"
 	irouting "github.com/ipfs/kubo/routing"
 )
 
 const DefaultIpnsCacheSize = 128
 
-// RecordValidator provides namesys compatible routing record validator
-func RecordValidator(ps peerstore.Peerstore) record.Validator {
+// RecordValidator provides namesys compatible routing record validator. The
+// validity of the IPNS records is checked against clk. The DHT requires the
+// validator to be ipns.Validator, which uses the wall clock: with any other
+// clock, the DHT has to be replaced with another routing.
+func RecordValidator(ps peerstore.Peerstore, clk clock.Clock) record.Validator {
+	var ipnsVal record.Validator = ipns.Validator{KeyBook: ps}
+	if reflect.TypeOf(clk) != wallClock {
+		ipnsVal = ipnsValidator{Validator: ipns.Validator{KeyBook: ps}, clock: clk}
+	}
 	return record.NamespacedValidator{
 		"pk":   record.PublicKeyValidator{},
-		"ipns": ipns.Validator{KeyBook: ps},
+		"ipns": ipnsVal,
 	}
 }
 
-// Namesys creates new name system
-func Namesys(cacheSize int, cacheMaxTTL time.Duration) func(rt irouting.ProvideManyRouter, rslv *madns.Resolver, repo repo.Repo) (namesys.NameSystem, error) {
-	return func(rt irouting.ProvideManyRouter, rslv *madns.Resolver, repo repo.Repo) (namesys.NameSystem, error) {
+// wallClock is the type of the wall clock of the clock package.
+var wallClock = reflect.TypeOf(clock.New())
+
+// ipnsValidator is ipns.Validator, checking the end of validity of the records
+// against its clock rather than the wall clock.
+type ipnsValidator struct {
+	ipns.Validator
+	clock clock.Clock
+}
+
+func (v ipnsValidator) Validate(key string, value []byte) error {
+	// the end of validity is checked last: a record only expired by the wall
+	// clock passed every other check
+	err := v.Validator.Validate(key, value)
+	if err != nil && !errors.Is(err, ipns.ErrExpiredRecord) {
+		return err
+	}
+	rec, err := ipns.UnmarshalRecord(value)
+	if err != nil {
+		return err
+	}
+	eol, err := rec.Validity()
+	if err != nil {
+		return err
+	}
+	if v.clock.Now().After(eol) {
+		return ipns.ErrExpiredRecord
+	}
+	return nil
+}
+
+type NamesysOut struct {
+	fx.Out
+
+	Namesys namesys.NameSystem
+	// NameCache is the cache of the resolved names, nil when disabled.
+	NameCache *namecache.Cache
+}
+
+// Namesys creates new name system. Resolved names are cached by kubo rather
+// than by the name system, so that they can be purged. dnsResolvers are the
+// DNS.Resolvers of the config, reported in the provenance of the resolutions.
+func Namesys(cacheSize int, cacheMaxTTL time.Duration, dnsResolvers map[string]string) func(rt irouting.ProvideManyRouter, rslv *madns.Resolver, repo repo.Repo, clk clock.Clock) (NamesysOut, error) {
+	return func(rt irouting.ProvideManyRouter, rslv *madns.Resolver, repo repo.Repo, clk clock.Clock) (out NamesysOut, err error) {
 		opts := []namesys.Option{
 			namesys.WithDatastore(repo.Datastore()),
-			namesys.WithDNSResolver(rslv),
+			namesys.WithDNSResolver(nameprov.DNSResolver(rslv, dnsResolvers)),
 			namesys.WithMaxCacheTTL(cacheMaxTTL),
 		}
 
-		if cacheSize > 0 {
-			opts = append(opts, namesys.WithCache(cacheSize))
+		out.Namesys, err = namesys.NewNameSystem(rt, opts...)
+		if err != nil || cacheSize <= 0 {
+			return out, err
 		}
-
-		return namesys.NewNameSystem(rt, opts...)
+		out.NameCache, err = namecache.New(out.Namesys, cacheSize, cacheMaxTTL, clk)
+		if err != nil {
+			return out, err
+		}
+		out.Namesys = out.NameCache
+		return out, nil
 	}
 }
 
@@ -0,0 +1,38 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/kv"
+	"github.com/ipfs/kubo/repo"
+)
+
+// KV replicates the key-value stores of the node over pubsub, when
+// Experimental.KVStore is enabled. The writes are signed with the key of the
+// node by default.
+func KV(enabled bool, pubsubEnabled bool) fx.Option {
+	if !enabled {
+		return fx.Options()
+	}
+	if !pubsubEnabled {
+		return fx.Error(fmt.Errorf("Experimental.KVStore requires pubsub, set Pubsub.Enabled or run the daemon with --enable-pubsub-experiment"))
+	}
+
+	return fx.Provide(func(lc fx.Lifecycle, ps *pubsub.PubSub, repo repo.Repo, sk crypto.PrivKey) (*kv.Service, error) {
+		s, err := kv.New(ps, repo.Datastore(), sk)
+		if err != nil {
+			return nil, err
+		}
+		lc.Append(fx.Hook{
+			OnStop: func(context.Context) error {
+				return s.Close()
+			},
+		})
+		return s, nil
+	})
+}
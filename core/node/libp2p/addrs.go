@@ -3,16 +3,17 @@ package libp2p
 import (
 	"fmt"
 
+	"github.com/ipfs/kubo/core/peerpolicy"
 	"github.com/libp2p/go-libp2p"
 	p2pbhost "github.com/libp2p/go-libp2p/p2p/host/basic"
 	ma "github.com/multiformats/go-multiaddr"
 	mamask "github.com/whyrusleeping/multiaddr-filter"
 )
 
-func AddrFilters(filters []string) func() (*ma.Filters, Libp2pOpts, error) {
-	return func() (filter *ma.Filters, opts Libp2pOpts, err error) {
+func AddrFilters(filters []string) func(policy *peerpolicy.Policy) (*ma.Filters, Libp2pOpts, error) {
+	return func(policy *peerpolicy.Policy) (filter *ma.Filters, opts Libp2pOpts, err error) {
 		filter = ma.NewFilters()
-		opts.Opts = append(opts.Opts, libp2p.ConnectionGater((*filtersConnectionGater)(filter)))
+		opts.Opts = append(opts.Opts, libp2p.ConnectionGater(policy.Gater((*filtersConnectionGater)(filter))))
 		for _, s := range filters {
 			f, err := mamask.NewMask(s)
 			if err != nil {
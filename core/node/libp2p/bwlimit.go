@@ -0,0 +1,51 @@
+package libp2p
+
+import (
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/bwlimit"
+)
+
+// BandwidthLimiter returns the limiter of the streams of the node, enforcing
+// Swarm.Bandwidth.
+func BandwidthLimiter(cfg config.SwarmBandwidth) func() (*bwlimit.Limiter, error) {
+	return func() (*bwlimit.Limiter, error) {
+		limits, err := bandwidthLimits(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return bwlimit.New(limits), nil
+	}
+}
+
+func bandwidthLimits(cfg config.SwarmBandwidth) (limits bwlimit.Limits, err error) {
+	parse := func(name string, rate *config.OptionalString) uint64 {
+		if err != nil {
+			return 0
+		}
+		s := rate.WithDefault("")
+		if s == "" {
+			return 0
+		}
+		var r uint64
+		r, err = humanize.ParseBytes(s)
+		if err != nil {
+			err = fmt.Errorf("invalid Swarm.Bandwidth.%s %q: %w", name, s, err)
+		}
+		return r
+	}
+	limits.Total = bwlimit.Rate{In: parse("RateIn", cfg.RateIn), Out: parse("RateOut", cfg.RateOut)}
+	limits.Peer = bwlimit.Rate{In: parse("PeerRateIn", cfg.PeerRateIn), Out: parse("PeerRateOut", cfg.PeerRateOut)}
+	limits.Protocols = make(map[protocol.ID]bwlimit.Rate, len(cfg.Protocols))
+	for p, rate := range cfg.Protocols {
+		limits.Protocols[protocol.ID(p)] = bwlimit.Rate{
+			In:  parse(fmt.Sprintf("Protocols[%s].RateIn", p), rate.RateIn),
+			Out: parse(fmt.Sprintf("Protocols[%s].RateOut", p), rate.RateOut),
+		}
+	}
+	return limits, err
+}
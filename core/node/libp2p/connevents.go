@@ -0,0 +1,28 @@
+package libp2p
+
+import (
+	"context"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/host"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/connevents"
+)
+
+// ConnEvents creates the tracker of the events of the connections, see
+// 'ipfs swarm events'.
+func ConnEvents(clk clock.Clock) *connevents.Tracker {
+	return connevents.New(clk)
+}
+
+// StartConnEvents records the events of the connections of the host. The
+// tracker stops before the host closes, since the host is constructed first.
+func StartConnEvents(lc fx.Lifecycle, t *connevents.Tracker, h host.Host) {
+	t.Start(h)
+	lc.Append(fx.Hook{
+		OnStop: func(context.Context) error {
+			return t.Close()
+		},
+	})
+}
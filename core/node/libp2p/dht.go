@@ -0,0 +1,44 @@
+//go:build !nodht
+// +build !nodht
+
+package libp2p
+
+import (
+	"context"
+
+	"github.com/ipfs/kubo/core/dhthealth"
+	ddht "github.com/libp2p/go-libp2p-kad-dht/dual"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+// DHT is the dual DHT, WAN and LAN, of the nodes routing with the DHT.
+type DHT = ddht.DHT
+
+// dualDHTOf returns the dual DHT of router, or of the first of the routers
+// composing it, if any.
+func dualDHTOf(router routing.Routing) *DHT {
+	if dht, ok := router.(*DHT); ok {
+		return dht
+	}
+	if cr, ok := router.(routinghelpers.ComposableRouter); ok {
+		for _, r := range cr.Routers() {
+			if dht, ok := r.(*DHT); ok {
+				return dht
+			}
+		}
+	}
+	return nil
+}
+
+// trackDHT tracks the health of the routing tables of dht.
+func trackDHT(m *dhthealth.Monitor, self peer.ID, dht *DHT) {
+	m.Track("wan", self, dht.WAN.RoutingTable())
+	m.Track("lan", self, dht.LAN.RoutingTable())
+}
+
+// closestDHTPeers returns the peers of the WAN DHT closest to self.
+func closestDHTPeers(ctx context.Context, dht *DHT, self peer.ID) ([]peer.ID, error) {
+	return dht.WAN.GetClosestPeers(ctx, self.String())
+}
@@ -0,0 +1,35 @@
+//go:build nodht
+// +build nodht
+
+package libp2p
+
+import (
+	"context"
+
+	"github.com/ipfs/kubo/core/dhthealth"
+	"github.com/ipfs/kubo/core/subsystem"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+// DHT stands for the dual DHT, which is compiled out: it is never
+// constructed, so IpfsNode.DHT is always nil. It only has what the commands
+// use of the dual DHT.
+type DHT struct {
+	routing.Routing
+	WAN, LAN routing.Routing
+}
+
+// WANActive is always false.
+func (*DHT) WANActive() bool { return false }
+
+// Close does nothing.
+func (*DHT) Close() error { return nil }
+
+func dualDHTOf(_ routing.Routing) *DHT { return nil }
+
+func trackDHT(_ *dhthealth.Monitor, _ peer.ID, _ *DHT) {}
+
+func closestDHTPeers(_ context.Context, _ *DHT, _ peer.ID) ([]peer.ID, error) {
+	return nil, subsystem.Check(subsystem.DHT)
+}
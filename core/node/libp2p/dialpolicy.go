@@ -0,0 +1,144 @@
+package libp2p
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ipfs/kubo/config"
+)
+
+// Address families of the dial metrics.
+const (
+	familyIP4   = "ip4"
+	familyIP6   = "ip6"
+	familyOther = "other"
+)
+
+var (
+	dialAddrsMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ipfs_p2p_dial_addrs_total",
+			Help: "Addresses ranked for dialing, by address family",
+		},
+		[]string{"family"},
+	)
+	outboundConnsMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ipfs_p2p_outbound_conns_total",
+			Help: "Outbound connections established, by address family",
+		},
+		[]string{"family"},
+	)
+)
+
+// addrFamily returns the address family of the first component of addr.
+// Relayed addresses have the family of the relay.
+func addrFamily(addr ma.Multiaddr) string {
+	if len(addr.Protocols()) == 0 {
+		return familyOther
+	}
+	switch addr.Protocols()[0].Code {
+	case ma.P_IP4, ma.P_DNS4:
+		return familyIP4
+	case ma.P_IP6, ma.P_DNS6:
+		return familyIP6
+	default:
+		return familyOther
+	}
+}
+
+// dialRanker returns the ranker of the addresses of a peer for the given
+// Swarm.DialPolicy. IPv4 addresses are dialed familyDelay after the IPv6 ones
+// with happy-eyeballs, and familyDelay after the last IPv6 one with
+// prefer-ipv6. Within an address family, addresses are ranked by the default
+// ranker of libp2p.
+func dialRanker(policy string, familyDelay time.Duration) (network.DialRanker, error) {
+	split := func(addrs []ma.Multiaddr) (first, ip4 []ma.Multiaddr) {
+		for _, a := range addrs {
+			if addrFamily(a) == familyIP4 {
+				ip4 = append(ip4, a)
+			} else {
+				first = append(first, a)
+			}
+		}
+		return first, ip4
+	}
+
+	var rank network.DialRanker
+	switch policy {
+	case config.DialPolicyDefault:
+		rank = swarm.DefaultDialRanker
+	case config.DialPolicyIPv6Only:
+		rank = func(addrs []ma.Multiaddr) []network.AddrDelay {
+			ip6, _ := split(addrs)
+			return swarm.DefaultDialRanker(ip6)
+		}
+	case config.DialPolicyPreferIPv6, config.DialPolicyHappyEyeballs:
+		sequential := policy == config.DialPolicyPreferIPv6
+		rank = func(addrs []ma.Multiaddr) []network.AddrDelay {
+			ip6, ip4 := split(addrs)
+			res := swarm.DefaultDialRanker(ip6)
+			if len(res) == 0 {
+				return swarm.DefaultDialRanker(ip4)
+			}
+			offset := familyDelay
+			if sequential {
+				var last time.Duration
+				for _, a := range res {
+					if a.Delay > last {
+						last = a.Delay
+					}
+				}
+				offset += last
+			}
+			for _, a := range swarm.DefaultDialRanker(ip4) {
+				a.Delay += offset
+				res = append(res, a)
+			}
+			return res
+		}
+	default:
+		return nil, fmt.Errorf("unknown Swarm.DialPolicy %q, expected %q, %q, %q or %q", policy,
+			config.DialPolicyDefault, config.DialPolicyPreferIPv6, config.DialPolicyHappyEyeballs, config.DialPolicyIPv6Only)
+	}
+
+	return func(addrs []ma.Multiaddr) []network.AddrDelay {
+		res := rank(addrs)
+		for _, a := range res {
+			dialAddrsMetric.WithLabelValues(addrFamily(a.Addr)).Inc()
+		}
+		return res
+	}, nil
+}
+
+// DialPolicy ranks the addresses dialed by the swarm by address family.
+func DialPolicy(policy string, familyDelay time.Duration) func() (opts Libp2pOpts, err error) {
+	return func() (opts Libp2pOpts, err error) {
+		ranker, err := dialRanker(policy, familyDelay)
+		if err != nil {
+			return opts, err
+		}
+		opts.Opts = append(opts.Opts, libp2p.DialRanker(ranker))
+		return opts, nil
+	}
+}
+
+// DialMetrics counts the outbound connections by address family.
+func DialMetrics(h host.Host) {
+	mustRegister(dialAddrsMetric)
+	mustRegister(outboundConnsMetric)
+	h.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(_ network.Network, c network.Conn) {
+			if c.Stat().Direction == network.DirOutbound {
+				outboundConnsMetric.WithLabelValues(addrFamily(c.RemoteMultiaddr())).Inc()
+			}
+		},
+	})
+}
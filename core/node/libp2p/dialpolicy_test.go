@@ -0,0 +1,39 @@
+package libp2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialRanker(t *testing.T) {
+	ip4 := ma.StringCast("/ip4/1.2.3.4/tcp/4001")
+	ip6 := ma.StringCast("/ip6/2001:db8::1/tcp/4001")
+	addrs := []ma.Multiaddr{ip4, ip6}
+
+	delays := func(policy string) map[string]time.Duration {
+		rank, err := dialRanker(policy, time.Second)
+		require.NoError(t, err)
+		res := make(map[string]time.Duration)
+		for _, a := range rank(addrs) {
+			res[addrFamily(a.Addr)] = a.Delay
+		}
+		return res
+	}
+
+	res := delays(config.DialPolicyIPv6Only)
+	require.Len(t, res, 1)
+	require.Contains(t, res, familyIP6)
+
+	res = delays(config.DialPolicyHappyEyeballs)
+	require.Equal(t, res[familyIP6]+time.Second, res[familyIP4])
+
+	res = delays(config.DialPolicyPreferIPv6)
+	require.GreaterOrEqual(t, res[familyIP4], res[familyIP6]+time.Second)
+
+	_, err := dialRanker("ipv4-only", 0)
+	require.Error(t, err)
+}
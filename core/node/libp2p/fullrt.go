@@ -0,0 +1,28 @@
+//go:build !nodht
+// +build !nodht
+
+package libp2p
+
+import (
+	"github.com/ipfs/go-datastore"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p-kad-dht/fullrt"
+	record "github.com/libp2p/go-libp2p-record"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// fullRTRouter is the accelerated DHT client, of Routing.AcceleratedDHTClient.
+type fullRTRouter = *fullrt.FullRT
+
+func newFullRTClient(h host.Host, validator record.Validator, ds datastore.Batching, bspeers []peer.AddrInfo) (fullRTRouter, error) {
+	return fullrt.NewFullRT(h,
+		dht.DefaultPrefix,
+		fullrt.DHTOption(
+			dht.Validator(validator),
+			dht.Datastore(ds),
+			dht.BootstrapPeers(bspeers...),
+			dht.BucketSize(20),
+		),
+	)
+}
@@ -0,0 +1,23 @@
+//go:build nodht
+// +build nodht
+
+package libp2p
+
+import (
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/kubo/core/subsystem"
+	record "github.com/libp2p/go-libp2p-record"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+// fullRTRouter is the accelerated DHT client, compiled out with the DHT.
+type fullRTRouter interface {
+	routing.Routing
+	Close() error
+}
+
+func newFullRTClient(_ host.Host, _ record.Validator, _ datastore.Batching, _ []peer.AddrInfo) (fullRTRouter, error) {
+	return nil, subsystem.Check(subsystem.DHT)
+}
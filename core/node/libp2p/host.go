@@ -11,6 +11,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/routing"
 	routedhost "github.com/libp2p/go-libp2p/p2p/host/routed"
 
+	"github.com/ipfs/kubo/core/bwlimit"
 	"github.com/ipfs/kubo/core/node/helpers"
 	"github.com/ipfs/kubo/repo"
 
@@ -26,6 +27,7 @@ type P2PHostIn struct {
 	RoutingOption RoutingOption
 	ID            peer.ID
 	Peerstore     peerstore.Peerstore
+	Bandwidth     *bwlimit.Limiter `optional:"true"`
 
 	Opts [][]libp2p.Option `group:"libp2p"`
 }
@@ -64,6 +66,9 @@ func Host(mctx helpers.MetricsCtx, lc fx.Lifecycle, params P2PHostIn) (out P2PHo
 	opts = append(opts, libp2p.Routing(func(h host.Host) (routing.PeerRouting, error) {
 		args := routingOptArgs
 		args.Host = h
+		if params.Bandwidth != nil {
+			args.Host = params.Bandwidth.Wrap(h)
+		}
 		r, err := params.RoutingOption(args)
 		out.Routing = r
 		return r, err
@@ -87,6 +92,10 @@ func Host(mctx helpers.MetricsCtx, lc fx.Lifecycle, params P2PHostIn) (out P2PHo
 		out.Host = routedhost.Wrap(out.Host, out.Routing)
 	}
 
+	if params.Bandwidth != nil {
+		out.Host = params.Bandwidth.Wrap(out.Host)
+	}
+
 	lc.Append(fx.Hook{
 		OnStop: func(ctx context.Context) error {
 			return out.Host.Close()
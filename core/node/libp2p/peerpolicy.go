@@ -0,0 +1,61 @@
+package libp2p
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/connevents"
+	"github.com/ipfs/kubo/core/peerpolicy"
+)
+
+// PeerPolicy returns the policy of the node, enforcing Swarm.PeerPolicies.
+func PeerPolicy(cfg []config.PeerPolicy) func(lc fx.Lifecycle) (*peerpolicy.Policy, error) {
+	return func(lc fx.Lifecycle) (*peerpolicy.Policy, error) {
+		rules, err := PeerPolicyRules(cfg)
+		if err != nil {
+			return nil, err
+		}
+		p, err := peerpolicy.New(rules)
+		if err != nil {
+			return nil, err
+		}
+		lc.Append(fx.Hook{
+			OnStop: func(ctx context.Context) error {
+				return p.Close()
+			},
+		})
+		return p, nil
+	}
+}
+
+// StartPeerPolicy applies the policy to the peers the host identifies.
+func StartPeerPolicy(p *peerpolicy.Policy, h host.Host, events *connevents.Tracker) error {
+	return p.Start(h, func(id peer.ID) {
+		events.Closing(id, connevents.Policy)
+	})
+}
+
+// PeerPolicyRules returns the rules of the policies of the config.
+func PeerPolicyRules(cfg []config.PeerPolicy) ([]peerpolicy.Rule, error) {
+	rules := make([]peerpolicy.Rule, len(cfg))
+	for i, c := range cfg {
+		rules[i] = peerpolicy.Rule{
+			Name:         c.Name,
+			AgentVersion: c.AgentVersion,
+			Action:       peerpolicy.Action(c.Action),
+		}
+		for _, s := range c.Peers {
+			id, err := peer.Decode(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid peer ID %q in Swarm.PeerPolicies[%s]: %w", s, c.Name, err)
+			}
+			rules[i].Peers = append(rules[i].Peers, id)
+		}
+	}
+	return rules, nil
+}
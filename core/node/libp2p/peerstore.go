@@ -2,22 +2,101 @@ package libp2p
 
 import (
 	"context"
+	"time"
 
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/repo"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoreds"
 	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
 	"go.uber.org/fx"
 )
 
-func Peerstore(lc fx.Lifecycle) (peerstore.Peerstore, error) {
-	pstore, err := pstoremem.NewPeerstore()
-	if err != nil {
-		return nil, err
+// peerstoreDatastoreKey is the prefix of the persisted peerstore in the
+// datastore of the repo.
+var peerstoreDatastoreKey = datastore.NewKey("/libp2p")
+
+// Peerstore returns the peerstore of the node, in the datastore of the repo
+// when cfg.Persist is set, in memory otherwise.
+func Peerstore(cfg config.SwarmPeerstore) func(lc fx.Lifecycle, repo repo.Repo, clk clock.Clock) (peerstore.Peerstore, error) {
+	return func(lc fx.Lifecycle, repo repo.Repo, clk clock.Clock) (peerstore.Peerstore, error) {
+		var pstore peerstore.Peerstore
+		if cfg.Persist.WithDefault(false) {
+			opts := pstoreds.DefaultOpts()
+			opts.GCPurgeInterval = cfg.GCInterval.WithDefault(config.DefaultPeerstoreGCInterval)
+			opts.Clock = clk
+			ps, err := pstoreds.NewPeerstore(context.Background(), namespace.Wrap(repo.Datastore(), peerstoreDatastoreKey), opts)
+			if err != nil {
+				return nil, err
+			}
+			pstore = &persistentPeerstore{dsPeerstore: ps, privKeys: pstoremem.NewKeyBook()}
+		} else {
+			ps, err := pstoremem.NewPeerstore()
+			if err != nil {
+				return nil, err
+			}
+			pstore = ps
+		}
+		lc.Append(fx.Hook{
+			OnStop: func(ctx context.Context) error {
+				return pstore.Close()
+			},
+		})
+
+		return pstore, nil
 	}
-	lc.Append(fx.Hook{
-		OnStop: func(ctx context.Context) error {
-			return pstore.Close()
-		},
-	})
+}
+
+type dsPeerstore interface {
+	peerstore.Peerstore
+	peerstore.CertifiedAddrBook
+}
+
+// persistentPeerstore is a peerstore in the datastore that keeps the private
+// keys in memory, so that the key of the node is never written to the
+// datastore.
+type persistentPeerstore struct {
+	dsPeerstore
+	privKeys peerstore.KeyBook
+}
 
-	return pstore, nil
+func (ps *persistentPeerstore) PrivKey(p peer.ID) crypto.PrivKey {
+	return ps.privKeys.PrivKey(p)
+}
+
+func (ps *persistentPeerstore) AddPrivKey(p peer.ID, sk crypto.PrivKey) error {
+	return ps.privKeys.AddPrivKey(p, sk)
+}
+
+func (ps *persistentPeerstore) RemovePeer(p peer.ID) {
+	ps.dsPeerstore.RemovePeer(p)
+	ps.privKeys.RemovePeer(p)
+}
+
+// PeerstoreRetention keeps the addresses of the peers connected when the node
+// stops in the peerstore for ttl, instead of the few minutes libp2p keeps the
+// addresses of disconnected peers, so that the node reconnects to them after
+// a restart.
+func PeerstoreRetention(ttl time.Duration) func(lc fx.Lifecycle, h host.Host) {
+	return func(lc fx.Lifecycle, h host.Host) {
+		lc.Append(fx.Hook{
+			// runs before the host is closed, since the host is
+			// constructed first
+			OnStop: func(ctx context.Context) error {
+				ps := h.Peerstore()
+				for _, p := range h.Network().Peers() {
+					if addrs := ps.Addrs(p); len(addrs) > 0 {
+						ps.SetAddrs(p, addrs, ttl)
+					}
+				}
+				return nil
+			},
+		})
+	}
 }
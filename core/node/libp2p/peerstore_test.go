@@ -0,0 +1,82 @@
+package libp2p
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	syncds "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/repo"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+type testLifecycle struct {
+	hooks []fx.Hook
+}
+
+func (lc *testLifecycle) Append(h fx.Hook) {
+	lc.hooks = append(lc.hooks, h)
+}
+
+func (lc *testLifecycle) stop(t *testing.T) {
+	for i := len(lc.hooks) - 1; i >= 0; i-- {
+		if lc.hooks[i].OnStop != nil {
+			require.NoError(t, lc.hooks[i].OnStop(context.Background()))
+		}
+	}
+}
+
+func TestPersistentPeerstore(t *testing.T) {
+	r := &repo.Mock{D: syncds.MutexWrap(datastore.NewMapDatastore())}
+	cfg := config.SwarmPeerstore{Persist: config.True}
+	open := func() (peerstore.Peerstore, *testLifecycle) {
+		lc := new(testLifecycle)
+		ps, err := Peerstore(cfg)(lc, r, clock.New())
+		require.NoError(t, err)
+		return ps, lc
+	}
+
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	self, err := peer.IDFromPrivateKey(sk)
+	require.NoError(t, err)
+	_, pk, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	p, err := peer.IDFromPublicKey(pk)
+	require.NoError(t, err)
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/4001")
+
+	ps, lc := open()
+	require.NoError(t, ps.AddPrivKey(self, sk))
+	ps.AddAddr(p, addr, time.Hour)
+	require.NoError(t, ps.AddProtocols(p, "/ipfs/bitswap/1.2.0"))
+	require.Equal(t, sk, ps.PrivKey(self))
+	lc.stop(t)
+
+	// the private key is never written to the datastore
+	res, err := r.D.Query(context.Background(), query.Query{Prefix: "/libp2p/peers/keys", KeysOnly: true})
+	require.NoError(t, err)
+	entries, err := res.Rest()
+	require.NoError(t, err)
+	for _, e := range entries {
+		require.NotEqual(t, "priv", datastore.NewKey(e.Key).BaseNamespace())
+	}
+
+	ps, lc = open()
+	defer lc.stop(t)
+	require.Equal(t, []ma.Multiaddr{addr}, ps.Addrs(p))
+	protos, err := ps.GetProtocols(p)
+	require.NoError(t, err)
+	require.Len(t, protos, 1)
+	require.Nil(t, ps.PrivKey(self))
+}
@@ -0,0 +1,365 @@
+package libp2p
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	"github.com/libp2p/go-libp2p/p2p/transport/websocket"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"golang.org/x/net/proxy"
+
+	"github.com/ipfs/kubo/config"
+)
+
+// Proxy dials the outbound connections of the TCP and WebSocket transports
+// through a SOCKS5 or HTTP proxy, and keeps track of the connections it
+// dialed.
+type Proxy struct {
+	dialer    proxy.ContextDialer
+	tcp       bool
+	websocket bool
+	onion     bool
+
+	lk    sync.Mutex
+	conns map[string]int
+}
+
+// SwarmProxy returns the proxy of Swarm.Proxy, nil when no URL is set.
+func SwarmProxy(cfg config.SwarmProxy) func() (*Proxy, error) {
+	return func() (*Proxy, error) {
+		rawURL := cfg.URL.WithDefault("")
+		if rawURL == "" {
+			return nil, nil
+		}
+		dialer, err := proxyDialer(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		return &Proxy{
+			dialer:    dialer,
+			tcp:       cfg.TCP.WithDefault(true),
+			websocket: cfg.Websocket.WithDefault(true),
+			onion:     cfg.Onion.WithDefault(false),
+			conns:     make(map[string]int),
+		}, nil
+	}
+}
+
+func proxyDialer(rawURL string) (proxy.ContextDialer, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Swarm.Proxy.URL %q: %w", rawURL, err)
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		d, err := proxy.FromURL(u, proxy.Direct)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Swarm.Proxy.URL %q: %w", rawURL, err)
+		}
+		cd, ok := d.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("invalid Swarm.Proxy.URL %q: the SOCKS5 dialer doesn't support contexts", rawURL)
+		}
+		return cd, nil
+	case "http":
+		d := &connectDialer{proxy: u.Host}
+		if u.User != nil {
+			pass, _ := u.User.Password()
+			d.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(u.User.Username()+":"+pass))
+		}
+		return d, nil
+	default:
+		return nil, fmt.Errorf("invalid Swarm.Proxy.URL %q: the scheme must be socks5 or http", rawURL)
+	}
+}
+
+// Proxied returns whether the connection to p at addr was dialed through the
+// proxy. It returns false on a nil Proxy.
+func (px *Proxy) Proxied(p peer.ID, addr ma.Multiaddr) bool {
+	if px == nil {
+		return false
+	}
+	px.lk.Lock()
+	defer px.lk.Unlock()
+	return px.conns[connKey(p, addr)] > 0
+}
+
+func connKey(p peer.ID, addr ma.Multiaddr) string {
+	return string(p) + addr.String()
+}
+
+// track counts c as proxied until it is closed.
+func (px *Proxy) track(c transport.CapableConn) transport.CapableConn {
+	key := connKey(c.RemotePeer(), c.RemoteMultiaddr())
+	px.lk.Lock()
+	px.conns[key]++
+	px.lk.Unlock()
+	return &proxiedCapableConn{CapableConn: c, untrack: func() {
+		px.lk.Lock()
+		defer px.lk.Unlock()
+		if px.conns[key]--; px.conns[key] <= 0 {
+			delete(px.conns, key)
+		}
+	}}
+}
+
+type proxiedCapableConn struct {
+	transport.CapableConn
+	untrack func()
+	once    sync.Once
+}
+
+func (c *proxiedCapableConn) Close() error {
+	c.once.Do(c.untrack)
+	return c.CapableConn.Close()
+}
+
+// dial opens a connection to addr, a host:port, through the proxy, and
+// upgrades it as a connection of t to p.
+func (px *Proxy) dial(ctx context.Context, t transport.Transport, upgrader transport.Upgrader, rcmgr network.ResourceManager, raddr ma.Multiaddr, p peer.ID, open func(context.Context) (net.Conn, error)) (transport.CapableConn, error) {
+	scope, err := rcmgr.OpenConnection(network.DirOutbound, true, raddr)
+	if err != nil {
+		return nil, err
+	}
+	if err := scope.SetPeer(p); err != nil {
+		scope.Done()
+		return nil, err
+	}
+	c, err := open(ctx)
+	if err != nil {
+		scope.Done()
+		return nil, err
+	}
+	cc, err := upgrader.Upgrade(ctx, t, newProxiedConn(c, raddr), network.DirOutbound, p, scope)
+	if err != nil {
+		return nil, err
+	}
+	return px.track(cc), nil
+}
+
+// proxiedConn is a connection dialed through the proxy, whose remote address
+// is the address of the peer rather than the one of the proxy.
+type proxiedConn struct {
+	net.Conn
+	laddr, raddr ma.Multiaddr
+}
+
+func newProxiedConn(c net.Conn, raddr ma.Multiaddr) manet.Conn {
+	laddr, err := manet.FromNetAddr(c.LocalAddr())
+	if err != nil {
+		laddr = ma.StringCast("/ip4/0.0.0.0/tcp/0")
+	}
+	return &proxiedConn{Conn: c, laddr: laddr, raddr: raddr}
+}
+
+func (c *proxiedConn) LocalMultiaddr() ma.Multiaddr  { return c.laddr }
+func (c *proxiedConn) RemoteMultiaddr() ma.Multiaddr { return c.raddr }
+
+// proxiedTCP is the TCP transport, which dials through the proxy.
+type proxiedTCP struct {
+	*tcp.TcpTransport
+	proxy    *Proxy
+	upgrader transport.Upgrader
+	rcmgr    network.ResourceManager
+}
+
+func newProxiedTCP(px *Proxy) func(transport.Upgrader, network.ResourceManager) (*proxiedTCP, error) {
+	return func(upgrader transport.Upgrader, rcmgr network.ResourceManager) (*proxiedTCP, error) {
+		// TODO(9290): Make WithMetrics configurable
+		t, err := tcp.NewTCPTransport(upgrader, rcmgr, tcp.WithMetrics())
+		if err != nil {
+			return nil, err
+		}
+		return &proxiedTCP{TcpTransport: t, proxy: px, upgrader: upgrader, rcmgr: rcmgr}, nil
+	}
+}
+
+func (t *proxiedTCP) CanDial(addr ma.Multiaddr) bool {
+	if isOnion(addr) {
+		return t.proxy.onion
+	}
+	return t.TcpTransport.CanDial(addr)
+}
+
+func (t *proxiedTCP) Protocols() []int {
+	if t.proxy.onion {
+		return append(t.TcpTransport.Protocols(), ma.P_ONION3)
+	}
+	return t.TcpTransport.Protocols()
+}
+
+func (t *proxiedTCP) Dial(ctx context.Context, raddr ma.Multiaddr, p peer.ID) (transport.CapableConn, error) {
+	if !isOnion(raddr) && !t.proxy.tcp {
+		return t.TcpTransport.Dial(ctx, raddr, p)
+	}
+	target, err := tcpTarget(raddr)
+	if err != nil {
+		return nil, err
+	}
+	return t.proxy.dial(ctx, t, t.upgrader, t.rcmgr, raddr, p, func(ctx context.Context) (net.Conn, error) {
+		return t.proxy.dialer.DialContext(ctx, "tcp", target)
+	})
+}
+
+// proxiedWebsocket is the WebSocket transport, which dials through the proxy.
+type proxiedWebsocket struct {
+	*websocket.WebsocketTransport
+	proxy    *Proxy
+	upgrader transport.Upgrader
+	rcmgr    network.ResourceManager
+}
+
+func newProxiedWebsocket(px *Proxy) func(transport.Upgrader, network.ResourceManager) (*proxiedWebsocket, error) {
+	return func(upgrader transport.Upgrader, rcmgr network.ResourceManager) (*proxiedWebsocket, error) {
+		t, err := websocket.New(upgrader, rcmgr)
+		if err != nil {
+			return nil, err
+		}
+		return &proxiedWebsocket{WebsocketTransport: t, proxy: px, upgrader: upgrader, rcmgr: rcmgr}, nil
+	}
+}
+
+func (t *proxiedWebsocket) Dial(ctx context.Context, raddr ma.Multiaddr, p peer.ID) (transport.CapableConn, error) {
+	wsurl, sni, err := websocketTarget(raddr)
+	if err != nil {
+		return nil, err
+	}
+	return t.proxy.dial(ctx, t, t.upgrader, t.rcmgr, raddr, p, func(ctx context.Context) (net.Conn, error) {
+		dialer := ws.Dialer{
+			HandshakeTimeout: 30 * time.Second,
+			NetDialContext:   t.proxy.dialer.DialContext,
+		}
+		secure := wsurl.Scheme == "wss"
+		if secure {
+			dialer.TLSClientConfig = &tls.Config{ServerName: sni}
+		}
+		wsconn, _, err := dialer.DialContext(ctx, wsurl.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		return websocket.NewConn(wsconn, secure), nil
+	})
+}
+
+func isOnion(addr ma.Multiaddr) bool {
+	_, err := addr.ValueForProtocol(ma.P_ONION3)
+	return err == nil
+}
+
+// tcpTarget returns the host:port of a TCP or onion address, as sent to the
+// proxy.
+func tcpTarget(addr ma.Multiaddr) (string, error) {
+	var host, port string
+	ma.ForEach(addr, func(c ma.Component) bool {
+		switch c.Protocol().Code {
+		case ma.P_IP4, ma.P_IP6, ma.P_DNS, ma.P_DNS4, ma.P_DNS6:
+			host = c.Value()
+		case ma.P_TCP:
+			port = c.Value()
+			return false
+		case ma.P_ONION3:
+			// the value is <onion>:<port>
+			if h, p, err := net.SplitHostPort(c.Value()); err == nil {
+				host, port = h+".onion", p
+			}
+			return false
+		}
+		return true
+	})
+	if host == "" || port == "" {
+		return "", fmt.Errorf("can't dial %s through the proxy", addr)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// websocketTarget returns the URL of a WebSocket address, and the TLS server
+// name of secure ones.
+func websocketTarget(addr ma.Multiaddr) (*url.URL, string, error) {
+	hostport, err := tcpTarget(addr)
+	if err != nil {
+		return nil, "", err
+	}
+	host, _, _ := net.SplitHostPort(hostport)
+	scheme, sni := "ws", host
+	ma.ForEach(addr, func(c ma.Component) bool {
+		switch c.Protocol().Code {
+		case ma.P_TLS, ma.P_WSS:
+			scheme = "wss"
+		case ma.P_SNI:
+			sni = c.Value()
+		}
+		return true
+	})
+	return &url.URL{Scheme: scheme, Host: hostport, Path: "/"}, sni, nil
+}
+
+// connectDialer dials through an HTTP proxy, with the CONNECT method.
+type connectDialer struct {
+	proxy  string
+	auth   string
+	dialer net.Dialer
+}
+
+func (d *connectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	c, err := d.dialer.DialContext(ctx, "tcp", d.proxy)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = c.SetDeadline(deadline)
+		defer c.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if d.auth != "" {
+		req.Header.Set("Proxy-Authorization", d.auth)
+	}
+	if err := req.Write(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(c)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		c.Close()
+		return nil, fmt.Errorf("the proxy refused the connection to %s: %s", addr, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: c, r: br}, nil
+	}
+	return c, nil
+}
+
+// bufferedConn is a connection with data already read from it.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,78 @@
+package libp2p
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyTargets(t *testing.T) {
+	for addr, target := range map[string]string{
+		"/ip4/1.2.3.4/tcp/4001":      "1.2.3.4:4001",
+		"/ip6/2001:db8::1/tcp/4001":  "[2001:db8::1]:4001",
+		"/dns4/example.com/tcp/4001": "example.com:4001",
+		"/onion3/vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd:1234": "vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd.onion:1234",
+	} {
+		res, err := tcpTarget(ma.StringCast(addr))
+		require.NoError(t, err, addr)
+		require.Equal(t, target, res, addr)
+	}
+	_, err := tcpTarget(ma.StringCast("/ip4/1.2.3.4/udp/4001/quic-v1"))
+	require.Error(t, err)
+
+	for addr, wsurl := range map[string]string{
+		"/ip4/1.2.3.4/tcp/80/ws":                      "ws://1.2.3.4:80/",
+		"/ip6/2001:db8::1/tcp/80/ws":                  "ws://[2001:db8::1]:80/",
+		"/dns4/example.com/tcp/443/wss":               "wss://example.com:443/",
+		"/ip4/1.2.3.4/tcp/443/tls/sni/example.com/ws": "wss://1.2.3.4:443/",
+	} {
+		u, _, err := websocketTarget(ma.StringCast(addr))
+		require.NoError(t, err, addr)
+		require.Equal(t, wsurl, u.String(), addr)
+	}
+	_, sni, err := websocketTarget(ma.StringCast("/ip4/1.2.3.4/tcp/443/tls/sni/example.com/ws"))
+	require.NoError(t, err)
+	require.Equal(t, "example.com", sni)
+}
+
+func TestConnectDialer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		req, err := http.ReadRequest(bufio.NewReader(c))
+		if err != nil {
+			return
+		}
+		if req.Method != http.MethodConnect || req.Host != "example.com:4001" || req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+			io.WriteString(c, "HTTP/1.1 403 Forbidden\r\n\r\n")
+			return
+		}
+		// the first bytes of the tunnel come with the response
+		io.WriteString(c, "HTTP/1.1 200 OK\r\n\r\nhello")
+	}()
+
+	d, err := proxyDialer("http://user:pass@" + l.Addr().String())
+	require.NoError(t, err)
+	c, err := d.DialContext(context.Background(), "tcp", "example.com:4001")
+	require.NoError(t, err)
+	defer c.Close()
+	b, err := io.ReadAll(c)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(b))
+
+	_, err = proxyDialer("ftp://" + l.Addr().String())
+	require.Error(t, err)
+}
@@ -18,6 +18,7 @@ import (
 	"go.uber.org/fx"
 
 	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/connevents"
 	"github.com/ipfs/kubo/core/node/helpers"
 	"github.com/ipfs/kubo/repo"
 )
@@ -29,7 +30,7 @@ const NetLimitTraceFilename = "rcmgr.json.gz"
 var ErrNoResourceMgr = fmt.Errorf("missing ResourceMgr: make sure the daemon is running with Swarm.ResourceMgr.Enabled")
 
 func ResourceManager(cfg config.SwarmConfig, userResourceOverrides rcmgr.PartialLimitConfig) interface{} {
-	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo) (network.ResourceManager, Libp2pOpts, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, events *connevents.Tracker) (network.ResourceManager, Libp2pOpts, error) {
 		var manager network.ResourceManager
 		var opts Libp2pOpts
 
@@ -105,6 +106,7 @@ filled in with autocomputed defaults.`)
 				clock:    clock.New(),
 				logger:   &logging.Logger("resourcemanager").SugaredLogger,
 				delegate: manager,
+				events:   events,
 			}
 			lrm.start(helpers.LifecycleCtx(mctx, lc))
 			manager = lrm
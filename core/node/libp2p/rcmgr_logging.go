@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/ipfs/kubo/core/connevents"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
@@ -20,6 +21,8 @@ type loggingResourceManager struct {
 	logger      *zap.SugaredLogger
 	delegate    network.ResourceManager
 	logInterval time.Duration
+	// events records the connections refused, when not nil
+	events *connevents.Tracker
 
 	mut               sync.Mutex
 	limitExceededErrs map[string]int
@@ -115,7 +118,30 @@ func (n *loggingResourceManager) ViewPeer(p peer.ID, f func(network.PeerScope) e
 func (n *loggingResourceManager) OpenConnection(dir network.Direction, usefd bool, remote ma.Multiaddr) (network.ConnManagementScope, error) {
 	connMgmtScope, err := n.delegate.OpenConnection(dir, usefd, remote)
 	n.countErrs(err)
-	return connMgmtScope, err
+	if n.events == nil {
+		return connMgmtScope, err
+	}
+	if err != nil {
+		n.events.Refused(dir, "", remote, err)
+		return connMgmtScope, err
+	}
+	return &eventsConnScope{ConnManagementScope: connMgmtScope, events: n.events, dir: dir, remote: remote}, nil
+}
+
+// eventsConnScope records the connections refused once their peer is known.
+type eventsConnScope struct {
+	network.ConnManagementScope
+	events *connevents.Tracker
+	dir    network.Direction
+	remote ma.Multiaddr
+}
+
+func (s *eventsConnScope) SetPeer(p peer.ID) error {
+	err := s.ConnManagementScope.SetPeer(p)
+	if err != nil {
+		s.events.Refused(s.dir, p, s.remote, err)
+	}
+	return err
 }
 
 func (n *loggingResourceManager) OpenStream(p peer.ID, dir network.Direction) (network.StreamManagementScope, error) {
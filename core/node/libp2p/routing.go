@@ -10,9 +10,6 @@ import (
 	"github.com/cenkalti/backoff/v4"
 	offroute "github.com/ipfs/boxo/routing/offline"
 	ds "github.com/ipfs/go-datastore"
-	dht "github.com/libp2p/go-libp2p-kad-dht"
-	ddht "github.com/libp2p/go-libp2p-kad-dht/dual"
-	"github.com/libp2p/go-libp2p-kad-dht/fullrt"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	namesys "github.com/libp2p/go-libp2p-pubsub-router"
 	record "github.com/libp2p/go-libp2p-record"
@@ -23,6 +20,8 @@ import (
 	"go.uber.org/fx"
 
 	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/dhthealth"
+	"github.com/ipfs/kubo/core/nameprov"
 	"github.com/ipfs/kubo/core/node/helpers"
 	"github.com/ipfs/kubo/repo"
 	irouting "github.com/ipfs/kubo/routing"
@@ -31,7 +30,8 @@ import (
 type Router struct {
 	routing.Routing
 
-	Priority int // less = more important
+	Priority int    // less = more important
+	Name     string // where the values come from, see nameprov
 }
 
 type p2pRouterOut struct {
@@ -49,6 +49,7 @@ type processInitialRoutingIn struct {
 	Host      host.Host
 	Repo      repo.Repo
 	Validator record.Validator
+	Health    *dhthealth.Monitor `optional:"true"`
 }
 
 type processInitialRoutingOut struct {
@@ -57,7 +58,7 @@ type processInitialRoutingOut struct {
 	Router        Router                 `group:"routers"`
 	ContentRouter routing.ContentRouting `group:"content-routers"`
 
-	DHT       *ddht.DHT
+	DHT       *DHT
 	DHTClient routing.Routing `name:"dhtc"`
 }
 
@@ -65,10 +66,8 @@ type AddrInfoChan chan peer.AddrInfo
 
 func BaseRouting(cfg *config.Config) interface{} {
 	return func(lc fx.Lifecycle, in processInitialRoutingIn) (out processInitialRoutingOut, err error) {
-		var dualDHT *ddht.DHT
-		if dht, ok := in.Router.(*ddht.DHT); ok {
-			dualDHT = dht
-
+		dualDHT := dualDHTOf(in.Router)
+		if dualDHT != nil {
 			lc.Append(fx.Hook{
 				OnStop: func(ctx context.Context) error {
 					return dualDHT.Close()
@@ -76,18 +75,12 @@ func BaseRouting(cfg *config.Config) interface{} {
 			})
 		}
 
-		if cr, ok := in.Router.(routinghelpers.ComposableRouter); ok {
-			for _, r := range cr.Routers() {
-				if dht, ok := r.(*ddht.DHT); ok {
-					dualDHT = dht
-					lc.Append(fx.Hook{
-						OnStop: func(ctx context.Context) error {
-							return dualDHT.Close()
-						},
-					})
-					break
-				}
+		router := in.Router
+		if in.Health != nil {
+			if dualDHT != nil {
+				trackDHT(in.Health, in.Host.ID(), dualDHT)
 			}
+			router = in.Health.Router(router)
 		}
 
 		if dualDHT != nil && cfg.Routing.AcceleratedDHTClient {
@@ -100,15 +93,7 @@ func BaseRouting(cfg *config.Config) interface{} {
 				return out, err
 			}
 
-			fullRTClient, err := fullrt.NewFullRT(in.Host,
-				dht.DefaultPrefix,
-				fullrt.DHTOption(
-					dht.Validator(in.Validator),
-					dht.Datastore(in.Repo.Datastore()),
-					dht.BootstrapPeers(bspeers...),
-					dht.BucketSize(20),
-				),
-			)
+			fullRTClient, err := newFullRTClient(in.Host, in.Validator, in.Repo.Datastore(), bspeers)
 			if err != nil {
 				return out, err
 			}
@@ -129,12 +114,16 @@ func BaseRouting(cfg *config.Config) interface{} {
 				{Router: fullRTClient, DoNotWaitForSearchValue: true},
 			}
 			routers = append(routers, httpRouters...)
-			router := routinghelpers.NewComposableParallel(routers)
+			var router routing.Routing = routinghelpers.NewComposableParallel(routers)
+			if in.Health != nil {
+				router = in.Health.Router(router)
+			}
 
 			return processInitialRoutingOut{
 				Router: Router{
 					Priority: 1000,
 					Routing:  router,
+					Name:     "routing",
 				},
 				DHT:           dualDHT,
 				DHTClient:     fullRTClient,
@@ -145,7 +134,8 @@ func BaseRouting(cfg *config.Config) interface{} {
 		return processInitialRoutingOut{
 			Router: Router{
 				Priority: 1000,
-				Routing:  in.Router,
+				Routing:  router,
+				Name:     "routing",
 			},
 			DHT:           dualDHT,
 			DHTClient:     dualDHT,
@@ -199,7 +189,7 @@ func Routing(in p2pOnlineRoutingIn) irouting.ProvideManyRouter {
 		cRouters = append(cRouters, &routinghelpers.ParallelRouter{
 			IgnoreError:             true,
 			DoNotWaitForSearchValue: true,
-			Router:                  v.Routing,
+			Router:                  nameprov.Router(v.Routing, v.Name),
 		})
 	}
 
@@ -212,6 +202,7 @@ func OfflineRouting(dstore ds.Datastore, validator record.Validator) p2pRouterOu
 		Router: Router{
 			Routing:  offroute.NewOfflineRouter(dstore, validator),
 			Priority: 10000,
+			Name:     "datastore",
 		},
 	}
 }
@@ -245,12 +236,13 @@ func PubsubRouter(mctx helpers.MetricsCtx, lc fx.Lifecycle, in p2pPSRoutingIn) (
 				},
 			},
 			Priority: 100,
+			Name:     "pubsub",
 		},
 	}, psRouter, nil
 }
 
 func autoRelayFeeder(cfgPeering config.Peering, peerChan chan<- peer.AddrInfo) fx.Option {
-	return fx.Invoke(func(lc fx.Lifecycle, h host.Host, dht *ddht.DHT) {
+	return fx.Invoke(func(lc fx.Lifecycle, h host.Host, dht *DHT) {
 		ctx, cancel := context.WithCancel(context.Background())
 		done := make(chan struct{})
 
@@ -296,7 +288,7 @@ func autoRelayFeeder(cfgPeering config.Peering, peerChan chan<- peer.AddrInfo) f
 					   not worth fixing as we will refactor this after go-libp2p 0.20 */
 					continue
 				}
-				closestPeers, err := dht.WAN.GetClosestPeers(ctx, h.ID().String())
+				closestPeers, err := closestDHTPeers(ctx, dht, h.ID())
 				if err != nil {
 					// no-op: usually 'failed to find any peer in table' during startup
 					continue
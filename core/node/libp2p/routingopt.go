@@ -2,15 +2,15 @@ package libp2p
 
 import (
 	"context"
+	"errors"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/subsystem"
 	irouting "github.com/ipfs/kubo/routing"
-	dht "github.com/libp2p/go-libp2p-kad-dht"
-	dual "github.com/libp2p/go-libp2p-kad-dht/dual"
 	record "github.com/libp2p/go-libp2p-record"
 	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
 	host "github.com/libp2p/go-libp2p/core/host"
@@ -79,15 +79,19 @@ func ConstructDefaultRouting(cfg *config.Config, routingOpt RoutingOption) Routi
 		var routers []*routinghelpers.ParallelRouter
 
 		dhtRouting, err := routingOpt(args)
-		if err != nil {
+		switch {
+		case errors.Is(err, subsystem.ErrDisabled):
+			// the DHT is compiled out, only the HTTP routers are used
+		case err != nil:
 			return nil, err
+		default:
+			routers = append(routers, &routinghelpers.ParallelRouter{
+				Router:                  dhtRouting,
+				IgnoreError:             false,
+				DoNotWaitForSearchValue: true,
+				ExecuteAfter:            0,
+			})
 		}
-		routers = append(routers, &routinghelpers.ParallelRouter{
-			Router:                  dhtRouting,
-			IgnoreError:             false,
-			DoNotWaitForSearchValue: true,
-			ExecuteAfter:            0,
-		})
 
 		httpRouters, err := constructDefaultHTTPRouters(cfg)
 		if err != nil {
@@ -101,29 +105,6 @@ func ConstructDefaultRouting(cfg *config.Config, routingOpt RoutingOption) Routi
 	}
 }
 
-// constructDHTRouting is used when Routing.Type = "dht"
-func constructDHTRouting(mode dht.ModeOpt) RoutingOption {
-	return func(args RoutingOptionArgs) (routing.Routing, error) {
-		dhtOpts := []dht.Option{
-			dht.Concurrency(10),
-			dht.Mode(mode),
-			dht.Datastore(args.Datastore),
-			dht.Validator(args.Validator),
-		}
-		if args.OptimisticProvide {
-			dhtOpts = append(dhtOpts, dht.EnableOptimisticProvide())
-		}
-		if args.OptimisticProvideJobsPoolSize != 0 {
-			dhtOpts = append(dhtOpts, dht.OptimisticProvideJobsPoolSize(args.OptimisticProvideJobsPoolSize))
-		}
-		return dual.New(
-			args.Ctx, args.Host,
-			dual.DHTOption(dhtOpts...),
-			dual.WanDHTOption(dht.BootstrapPeers(args.BootstrapPeers...)),
-		)
-	}
-}
-
 // ConstructDelegatedRouting is used when Routing.Type = "custom"
 func ConstructDelegatedRouting(routers config.Routers, methods config.Methods, peerID string, addrs config.Addresses, privKey string) RoutingOption {
 	return func(args RoutingOptionArgs) (routing.Routing, error) {
@@ -148,12 +129,7 @@ func constructNilRouting(_ RoutingOptionArgs) (routing.Routing, error) {
 	return routinghelpers.Null{}, nil
 }
 
-var (
-	DHTOption       RoutingOption = constructDHTRouting(dht.ModeAuto)
-	DHTClientOption               = constructDHTRouting(dht.ModeClient)
-	DHTServerOption               = constructDHTRouting(dht.ModeServer)
-	NilRouterOption               = constructNilRouting
-)
+var NilRouterOption RoutingOption = constructNilRouting
 
 // httpAddrsFromConfig creates a list of addresses from the provided configuration to be used by HTTP delegated routers.
 func httpAddrsFromConfig(cfgAddrs config.Addresses) []string {
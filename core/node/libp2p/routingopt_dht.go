@@ -0,0 +1,39 @@
+//go:build !nodht
+// +build !nodht
+
+package libp2p
+
+import (
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	dual "github.com/libp2p/go-libp2p-kad-dht/dual"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+// constructDHTRouting is used when Routing.Type = "dht"
+func constructDHTRouting(mode dht.ModeOpt) RoutingOption {
+	return func(args RoutingOptionArgs) (routing.Routing, error) {
+		dhtOpts := []dht.Option{
+			dht.Concurrency(10),
+			dht.Mode(mode),
+			dht.Datastore(args.Datastore),
+			dht.Validator(args.Validator),
+		}
+		if args.OptimisticProvide {
+			dhtOpts = append(dhtOpts, dht.EnableOptimisticProvide())
+		}
+		if args.OptimisticProvideJobsPoolSize != 0 {
+			dhtOpts = append(dhtOpts, dht.OptimisticProvideJobsPoolSize(args.OptimisticProvideJobsPoolSize))
+		}
+		return dual.New(
+			args.Ctx, args.Host,
+			dual.DHTOption(dhtOpts...),
+			dual.WanDHTOption(dht.BootstrapPeers(args.BootstrapPeers...)),
+		)
+	}
+}
+
+var (
+	DHTOption       RoutingOption = constructDHTRouting(dht.ModeAuto)
+	DHTClientOption               = constructDHTRouting(dht.ModeClient)
+	DHTServerOption               = constructDHTRouting(dht.ModeServer)
+)
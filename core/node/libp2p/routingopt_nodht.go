@@ -0,0 +1,21 @@
+//go:build nodht
+// +build nodht
+
+package libp2p
+
+import (
+	"github.com/ipfs/kubo/core/subsystem"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+// constructDHTRouting fails, as the DHT is compiled out. The default routing
+// then only uses the HTTP routers.
+func constructDHTRouting(_ RoutingOptionArgs) (routing.Routing, error) {
+	return nil, subsystem.Check(subsystem.DHT)
+}
+
+var (
+	DHTOption       RoutingOption = constructDHTRouting
+	DHTClientOption RoutingOption = constructDHTRouting
+	DHTServerOption RoutingOption = constructDHTRouting
+)
@@ -19,17 +19,28 @@ func Transports(tptConfig config.Transports) interface{} {
 	return func(pnet struct {
 		fx.In
 		Fprint PNetFingerprint `optional:"true"`
+		Proxy  *Proxy          `optional:"true"`
 	},
 	) (opts Libp2pOpts, err error) {
 		privateNetworkEnabled := pnet.Fprint != nil
 
+		px := pnet.Proxy
+
 		if tptConfig.Network.TCP.WithDefault(true) {
-			// TODO(9290): Make WithMetrics configurable
-			opts.Opts = append(opts.Opts, libp2p.Transport(tcp.NewTCPTransport, tcp.WithMetrics()))
+			if px != nil && (px.tcp || px.onion) {
+				opts.Opts = append(opts.Opts, libp2p.Transport(newProxiedTCP(px)))
+			} else {
+				// TODO(9290): Make WithMetrics configurable
+				opts.Opts = append(opts.Opts, libp2p.Transport(tcp.NewTCPTransport, tcp.WithMetrics()))
+			}
 		}
 
 		if tptConfig.Network.Websocket.WithDefault(true) {
-			opts.Opts = append(opts.Opts, libp2p.Transport(websocket.New))
+			if px != nil && px.websocket {
+				opts.Opts = append(opts.Opts, libp2p.Transport(newProxiedWebsocket(px)))
+			} else {
+				opts.Opts = append(opts.Opts, libp2p.Transport(websocket.New))
+			}
 		}
 
 		if tptConfig.Network.QUIC.WithDefault(!privateNetworkEnabled) {
@@ -0,0 +1,46 @@
+package node
+
+import (
+	"context"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/mfs"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/ipfs/go-cid"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/mfsexpiry"
+	"github.com/ipfs/kubo/repo"
+)
+
+// MFSExpiry creates the reaper of the MFS paths whose TTL expired, see
+// 'ipfs files ttl'. Expired paths are only removed by online nodes.
+func MFSExpiry(online bool) fx.Option {
+	return fx.Provide(func(lc fx.Lifecycle, repo repo.Repo, root *mfs.Root, bs blockstore.GCBlockstore, pinner pin.Pinner, clk clock.Clock) *mfsexpiry.Reaper {
+		unpin := func(ctx context.Context, c cid.Cid) error {
+			defer bs.PinLock(ctx).Unlock(ctx)
+			if err := pinner.Unpin(ctx, c, true); err != nil {
+				if err == pin.ErrNotPinned {
+					return nil
+				}
+				return err
+			}
+			return pinner.Flush(ctx)
+		}
+
+		r := mfsexpiry.New(repo.Datastore(), root, unpin, clk)
+		if online {
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					r.Start(mfsexpiry.DefaultInterval)
+					return nil
+				},
+				OnStop: func(context.Context) error {
+					return r.Close()
+				},
+			})
+		}
+		return r
+	})
+}
@@ -0,0 +1,29 @@
+package node
+
+import (
+	"context"
+
+	"github.com/ipfs/boxo/mfs"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/mfsflush"
+)
+
+// MFSFlusher creates the flusher of the changes made with 'ipfs files',
+// batching them as set by the MFS section of the config.
+func MFSFlusher(cfg config.MFS) fx.Option {
+	return fx.Provide(func(lc fx.Lifecycle, root *mfs.Root) *mfsflush.Flusher {
+		f := mfsflush.New(root, cfg.AutoFlushInterval.WithDefault(0), cfg.ManualFlush.WithDefault(false))
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				f.Start()
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				return f.Close(ctx)
+			},
+		})
+		return f
+	})
+}
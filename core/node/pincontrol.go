@@ -0,0 +1,95 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/boxo/blockstore"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/ipfs/go-cid"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/pincontrol"
+	"github.com/ipfs/kubo/core/pinqueue"
+	"github.com/ipfs/kubo/core/readonly"
+)
+
+type pinControlIn struct {
+	fx.In
+
+	Lc       fx.Lifecycle
+	PubSub   *pubsub.PubSub
+	Bs       blockstore.GCBlockstore
+	Pinner   pin.Pinner
+	PinQueue *pinqueue.Queue
+	ReadOnly *readonly.Mode
+}
+
+// PinControl applies the pins and unpins ordered over pubsub by
+// Pinning.Control.AllowedKeys, when enabled. Pins go through the queue of
+// background pins.
+func PinControl(cfg config.PinControl, pubsubEnabled bool) fx.Option {
+	if !cfg.Enabled.WithDefault(false) {
+		return fx.Options()
+	}
+	if !pubsubEnabled {
+		return fx.Error(fmt.Errorf("Pinning.Control requires pubsub, set Pubsub.Enabled or run the daemon with --enable-pubsub-experiment"))
+	}
+	keys := make([]peer.ID, len(cfg.AllowedKeys))
+	for i, k := range cfg.AllowedKeys {
+		pid, err := peer.Decode(k)
+		if err != nil {
+			return fx.Error(fmt.Errorf("invalid Pinning.Control.AllowedKeys entry %q: %w", k, err))
+		}
+		keys[i] = pid
+	}
+	if len(keys) == 0 {
+		logger.Error("Pinning.Control.Enabled is set but Pinning.Control.AllowedKeys is empty, no order will be applied")
+	}
+
+	return fx.Provide(func(in pinControlIn) (*pincontrol.Controller, error) {
+		c, err := pincontrol.New(in.PubSub, pincontrol.Options{
+			Topic:       cfg.Topic.WithDefault(config.DefaultPinControlTopic),
+			AllowedKeys: keys,
+			MaxAge:      cfg.MaxAge.WithDefault(config.DefaultPinControlMaxAge),
+			Pin: func(ctx context.Context, c cid.Cid, recursive bool, name string) error {
+				if err := in.ReadOnly.Check(); err != nil {
+					return err
+				}
+				_, err := in.PinQueue.Add(ctx, c, recursive, name, 0)
+				return err
+			},
+			Unpin: func(ctx context.Context, c cid.Cid, recursive bool) error {
+				if err := in.ReadOnly.Check(); err != nil {
+					return err
+				}
+				// the pins still queued are cancelled too
+				for _, r := range in.PinQueue.List() {
+					if r.Cid.Equals(c) {
+						if err := in.PinQueue.Cancel(ctx, r.ID); err != nil && err != pinqueue.ErrNotFound {
+							return err
+						}
+					}
+				}
+
+				defer in.Bs.PinLock(ctx).Unlock(ctx)
+				if err := in.Pinner.Unpin(ctx, c, recursive); err != nil && err != pin.ErrNotPinned {
+					return err
+				}
+				return in.Pinner.Flush(ctx)
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		in.Lc.Append(fx.Hook{
+			OnStop: func(context.Context) error {
+				return c.Close()
+			},
+		})
+		return c, nil
+	})
+}
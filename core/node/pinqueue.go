@@ -0,0 +1,99 @@
+package node
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	provider "github.com/ipfs/boxo/provider"
+	"github.com/ipfs/go-cid"
+	format "github.com/ipfs/go-ipld-format"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/dagshape"
+	"github.com/ipfs/kubo/core/fulltext"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/core/pinqueue"
+	"github.com/ipfs/kubo/core/pinreport"
+	"github.com/ipfs/kubo/core/webhooks"
+	"github.com/ipfs/kubo/repo"
+)
+
+type pinQueueIn struct {
+	fx.In
+
+	Mctx         helpers.MetricsCtx
+	Lc           fx.Lifecycle
+	Repo         repo.Repo
+	Bs           blockstore.GCBlockstore
+	Dag          format.DAGService
+	Pinner       pin.Pinner
+	Provider     provider.System
+	BlockSources *pinreport.Recorder `optional:"true"`
+	FullText     *fulltext.Indexer   `optional:"true"`
+	Webhooks     *webhooks.Notifier  `optional:"true"`
+}
+
+// PinQueue creates the persistent queue of background pins. Queued pins are
+// only processed by online nodes; offline nodes can queue pins for the next
+// time the daemon runs. The pinned DAGs are checked against limits.
+func PinQueue(cfg config.PinQueue, limits dagshape.Limits, online bool) fx.Option {
+	return fx.Provide(func(in pinQueueIn) (*pinqueue.Queue, error) {
+		pinFn := func(ctx context.Context, c cid.Cid, recursive bool, name string) error {
+			report, err := pinreport.Fetch(ctx, in.BlockSources, in.Bs, in.Dag, c, recursive, limits)
+			if err != nil {
+				return err
+			}
+			nd, err := in.Dag.Get(ctx, c)
+			if err != nil {
+				return err
+			}
+
+			defer in.Bs.PinLock(ctx).Unlock(ctx)
+
+			if err := in.Pinner.Pin(ctx, nd, recursive, name); err != nil {
+				return err
+			}
+			if err := in.Provider.Provide(c); err != nil {
+				return err
+			}
+			if err := in.Pinner.Flush(ctx); err != nil {
+				return err
+			}
+			if in.FullText != nil {
+				in.FullText.UpdatePins()
+			}
+
+			in.Webhooks.Notify(webhooks.EventPinCompleted, webhooks.PinData{Cid: c.String(), Recursive: recursive, Name: name})
+
+			report.Finished = time.Now()
+			return pinreport.Save(ctx, in.Repo.Datastore(), report)
+		}
+
+		q, err := pinqueue.New(helpers.LifecycleCtx(in.Mctx, in.Lc), in.Repo.Datastore(), pinFn, pinqueue.Options{
+			Concurrency:    int(cfg.Concurrency.WithDefault(config.DefaultPinQueueConcurrency)),
+			MaxAttempts:    int(cfg.MaxAttempts.WithDefault(config.DefaultPinQueueMaxAttempts)),
+			RetryBackoff:   cfg.RetryBackoff.WithDefault(config.DefaultPinQueueRetryBackoff),
+			MaxBackoff:     cfg.MaxBackoff.WithDefault(config.DefaultPinQueueMaxBackoff),
+			AttemptTimeout: cfg.AttemptTimeout.WithDefault(config.DefaultPinQueueAttemptTimeout),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		in.Lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				if online {
+					q.Start()
+				}
+				return nil
+			},
+			OnStop: func(context.Context) error {
+				return q.Close()
+			},
+		})
+		return q, nil
+	})
+}
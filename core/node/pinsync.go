@@ -0,0 +1,36 @@
+package node
+
+import (
+	"context"
+
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/pinsync"
+)
+
+// PinsetReconciliation serves the reconciliation of pinsets to the peers of
+// Peering.Peers, when enabled, and reconciles the local pinset with theirs.
+func PinsetReconciliation(enabled bool, peers []peer.AddrInfo) fx.Option {
+	if !enabled {
+		return fx.Options()
+	}
+	allowed := make(map[peer.ID]struct{}, len(peers))
+	for _, p := range peers {
+		allowed[p.ID] = struct{}{}
+	}
+	return fx.Provide(func(lc fx.Lifecycle, h host.Host, pinner pin.Pinner) *pinsync.Service {
+		s := pinsync.New(h, pinner, func(p peer.ID) bool {
+			_, ok := allowed[p]
+			return ok
+		})
+		lc.Append(fx.Hook{
+			OnStop: func(ctx context.Context) error {
+				return s.Close()
+			},
+		})
+		return s
+	})
+}
@@ -0,0 +1,25 @@
+package node
+
+import (
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/mimetypes"
+	"github.com/ipfs/kubo/core/preview"
+	"github.com/ipfs/kubo/repo"
+)
+
+// Previews creates the generator of the previews of files, when enabled.
+// Previews are only made of the files stored locally.
+func Previews(enabled bool) fx.Option {
+	if !enabled {
+		return fx.Options()
+	}
+	return fx.Provide(func(repo repo.Repo, bs blockstore.Blockstore, mimeTypes *mimetypes.Cache) *preview.Generator {
+		dag := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+		return preview.New(repo.Datastore(), dag, mimeTypes)
+	})
+}
@@ -0,0 +1,45 @@
+package node
+
+import (
+	"context"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	provider "github.com/ipfs/boxo/provider"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/provideclass"
+	"github.com/ipfs/kubo/repo"
+)
+
+// ProvideClasses creates the scheduler of the reprovides of the pins with a
+// provide class, see 'ipfs pin provide-class'. The pins are only reprovided
+// by online nodes, unless Reprovider.Interval is 0.
+func ProvideClasses(reprovideStrategy string, reprovideInterval time.Duration, online bool) fx.Option {
+	return fx.Provide(func(lc fx.Lifecycle, repo repo.Repo, bs blockstore.Blockstore, sys provider.System, clk clock.Clock) *provideclass.Scheduler {
+		getLinks := offlineGetLinks(bs)
+		s := provideclass.New(repo.Datastore(), getLinks, sys.Provide, reprovideStrategy == "roots", clk)
+		if online && reprovideInterval > 0 {
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					s.Start(provideclass.DefaultInterval)
+					return nil
+				},
+				OnStop: func(context.Context) error {
+					return s.Close()
+				},
+			})
+		}
+		return s
+	})
+}
+
+// offlineGetLinks returns the links of the blocks stored in bs, without
+// fetching the missing ones.
+func offlineGetLinks(bs blockstore.Blockstore) merkledag.GetLinks {
+	return merkledag.GetLinksWithDAG(merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs))))
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/ipfs/boxo/fetcher"
 	pin "github.com/ipfs/boxo/pinning/pinner"
 	provider "github.com/ipfs/boxo/provider"
+	"github.com/ipfs/kubo/core/provideclass"
 	"github.com/ipfs/kubo/repo"
 	irouting "github.com/ipfs/kubo/routing"
 	"go.uber.org/fx"
@@ -16,11 +17,13 @@ import (
 
 func ProviderSys(reprovideInterval time.Duration, acceleratedDHTClient bool) fx.Option {
 	const magicThroughputReportCount = 128
-	return fx.Provide(func(lc fx.Lifecycle, cr irouting.ProvideManyRouter, keyProvider provider.KeyChanFunc, repo repo.Repo, bs blockstore.Blockstore) (provider.System, error) {
+	return fx.Provide(func(lc fx.Lifecycle, cr irouting.ProvideManyRouter, keyProvider provider.KeyChanFunc, repo repo.Repo, bs blockstore.Blockstore, shared *SharedContexts) (provider.System, error) {
 		opts := []provider.Option{
 			provider.Online(cr),
 			provider.ReproviderInterval(reprovideInterval),
-			provider.KeyProvider(keyProvider),
+			// the pins with a provide class are reprovided by provideclass,
+			// and the repo contexts sharing the identity of the node with it
+			provider.KeyProvider(shared.KeyChanFunc(provideclass.Exclude(repo.Datastore(), offlineGetLinks(bs), keyProvider))),
 		}
 		if !acceleratedDHTClient {
 			// The estimation kinda suck if you are running with accelerated DHT client,
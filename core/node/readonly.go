@@ -0,0 +1,15 @@
+package node
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/readonly"
+)
+
+// ReadOnly provides the read-only mode of the node, enabled at start by
+// Experimental.ReadOnlyMirror and toggled with 'ipfs readonly'.
+func ReadOnly(enabled bool) fx.Option {
+	return fx.Provide(func() *readonly.Mode {
+		return readonly.New(enabled)
+	})
+}
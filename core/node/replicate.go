@@ -0,0 +1,37 @@
+package node
+
+import (
+	"context"
+
+	"github.com/ipfs/boxo/blockstore"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/replicate"
+)
+
+// PinReplication accepts the pins pushed by the peers of Peering.Peers, when
+// enabled, and pushes the pins of the node to peers.
+func PinReplication(enabled bool, peers []peer.AddrInfo) fx.Option {
+	if !enabled {
+		return fx.Options()
+	}
+	allowed := make(map[peer.ID]struct{}, len(peers))
+	for _, p := range peers {
+		allowed[p.ID] = struct{}{}
+	}
+	return fx.Provide(func(lc fx.Lifecycle, h host.Host, bs blockstore.GCBlockstore, pinner pin.Pinner) *replicate.Service {
+		s := replicate.New(h, bs, pinner, func(p peer.ID) bool {
+			_, ok := allowed[p]
+			return ok
+		})
+		lc.Append(fx.Hook{
+			OnStop: func(ctx context.Context) error {
+				return s.Close()
+			},
+		})
+		return s
+	})
+}
@@ -0,0 +1,56 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	humanize "github.com/dustin/go-humanize"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/core/repoforecast"
+	"github.com/ipfs/kubo/repo"
+)
+
+// RepoForecast creates the monitor of the growth of the repo, when enabled.
+// The repo is only sampled by online nodes.
+func RepoForecast(cfg config.Datastore, online bool) fx.Option {
+	if !cfg.Forecast.Enabled.WithDefault(config.DefaultDatastoreForecastEnabled) {
+		return fx.Options()
+	}
+	return fx.Provide(func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo) (*repoforecast.Monitor, error) {
+		storageMax := cfg.StorageMax
+		if storageMax == "" {
+			storageMax = config.DefaultDatastoreConfig().StorageMax
+		}
+		limit, err := humanize.ParseBytes(storageMax)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Datastore.StorageMax: %w", err)
+		}
+		f := cfg.Forecast
+		m, err := repoforecast.New(helpers.LifecycleCtx(mctx, lc), repo.Datastore(), repo.GetStorageUsage, repoforecast.Options{
+			StorageMax: limit,
+			Interval:   f.Interval.WithDefault(config.DefaultDatastoreForecastInterval),
+			Window:     f.Window.WithDefault(config.DefaultDatastoreForecastWindow),
+			Warning:    f.WarningPeriod.WithDefault(config.DefaultDatastoreForecastWarning),
+			Critical:   f.CriticalPeriod.WithDefault(config.DefaultDatastoreForecastCritical),
+			Webhook:    f.Webhook.WithDefault(""),
+		})
+		if err != nil {
+			return nil, err
+		}
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				if online {
+					m.Start()
+				}
+				return nil
+			},
+			OnStop: func(context.Context) error {
+				return m.Close()
+			},
+		})
+		return m, nil
+	})
+}
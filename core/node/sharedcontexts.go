@@ -0,0 +1,151 @@
+package node
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+
+	"github.com/ipfs/boxo/blockstore"
+	provider "github.com/ipfs/boxo/provider"
+)
+
+// SharedContexts are the repo contexts sharing the identity of the node: the
+// node serves their blocks over bitswap and reprovides their keys, as their
+// own nodes run offline.
+type SharedContexts struct {
+	lk       sync.RWMutex
+	contexts map[string]sharedContext
+}
+
+type sharedContext struct {
+	bs   blockstore.Blockstore
+	keys provider.KeyChanFunc
+}
+
+// NewSharedContexts creates the empty set of the shared contexts of the node.
+func NewSharedContexts() *SharedContexts {
+	return &SharedContexts{contexts: make(map[string]sharedContext)}
+}
+
+// Add registers the context named name, serving the blocks of bs and
+// reproviding the keys of keys.
+func (s *SharedContexts) Add(name string, bs blockstore.Blockstore, keys provider.KeyChanFunc) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.contexts[name] = sharedContext{bs: bs, keys: keys}
+}
+
+// Remove unregisters the context named name.
+func (s *SharedContexts) Remove(name string) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	delete(s.contexts, name)
+}
+
+// list returns the registered contexts, by name.
+func (s *SharedContexts) list() []sharedContext {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+	names := make([]string, 0, len(s.contexts))
+	for name := range s.contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]sharedContext, len(names))
+	for i, name := range names {
+		out[i] = s.contexts[name]
+	}
+	return out
+}
+
+// Blockstore returns bs, falling back to the blockstores of the contexts for
+// the blocks it doesn't have. Writes only go to bs.
+func (s *SharedContexts) Blockstore(bs blockstore.Blockstore) blockstore.Blockstore {
+	return &sharedBlockstore{Blockstore: bs, shared: s}
+}
+
+// KeyChanFunc returns the keys of keys, followed by the ones of the contexts.
+func (s *SharedContexts) KeyChanFunc(keys provider.KeyChanFunc) provider.KeyChanFunc {
+	return func(ctx context.Context) (<-chan cid.Cid, error) {
+		first, err := keys(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out := make(chan cid.Cid)
+		go func() {
+			defer close(out)
+			forward := func(ch <-chan cid.Cid) bool {
+				for c := range ch {
+					select {
+					case out <- c:
+					case <-ctx.Done():
+						return false
+					}
+				}
+				return true
+			}
+			if !forward(first) {
+				return
+			}
+			for _, sc := range s.list() {
+				ch, err := sc.keys(ctx)
+				if err != nil {
+					logger.Errorf("listing the keys of a shared repo context: %s", err)
+					continue
+				}
+				if !forward(ch) {
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+type sharedBlockstore struct {
+	blockstore.Blockstore
+	shared *SharedContexts
+}
+
+func (bs *sharedBlockstore) Has(ctx context.Context, c cid.Cid) (bool, error) {
+	has, err := bs.Blockstore.Has(ctx, c)
+	if err != nil || has {
+		return has, err
+	}
+	for _, sc := range bs.shared.list() {
+		if has, err := sc.bs.Has(ctx, c); err == nil && has {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (bs *sharedBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	b, err := bs.Blockstore.Get(ctx, c)
+	if !ipld.IsNotFound(err) {
+		return b, err
+	}
+	for _, sc := range bs.shared.list() {
+		if b, err := sc.bs.Get(ctx, c); err == nil {
+			return b, nil
+		}
+	}
+	return nil, err
+}
+
+func (bs *sharedBlockstore) GetSize(ctx context.Context, c cid.Cid) (int, error) {
+	size, err := bs.Blockstore.GetSize(ctx, c)
+	if !ipld.IsNotFound(err) {
+		return size, err
+	}
+	for _, sc := range bs.shared.list() {
+		if size, err := sc.bs.GetSize(ctx, c); err == nil {
+			return size, nil
+		}
+	}
+	return -1, err
+}
@@ -0,0 +1,61 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/mfs"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	format "github.com/ipfs/go-ipld-format"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/core/standby"
+	"github.com/ipfs/kubo/repo"
+)
+
+// Standby serves the state of the node to the nodes of Standby.Standbys, and
+// mirrors the node of Standby.Primary, when set.
+func Standby(cfg config.Standby) fx.Option {
+	if cfg.Primary == "" && len(cfg.Standbys) == 0 {
+		return fx.Options()
+	}
+	opts := standby.Options{
+		Interval: cfg.Interval.WithDefault(config.DefaultStandbyInterval),
+	}
+	if cfg.Primary != "" {
+		p, err := peer.Decode(cfg.Primary)
+		if err != nil {
+			return fx.Error(fmt.Errorf("invalid Standby.Primary %q: %w", cfg.Primary, err))
+		}
+		opts.Primary = p
+	}
+	for _, s := range cfg.Standbys {
+		p, err := peer.Decode(s)
+		if err != nil {
+			return fx.Error(fmt.Errorf("invalid Standby.Standbys entry %q: %w", s, err))
+		}
+		opts.Standbys = append(opts.Standbys, p)
+	}
+
+	return fx.Provide(func(mctx helpers.MetricsCtx, lc fx.Lifecycle, h host.Host, dag format.DAGService, bs blockstore.GCBlockstore, pinner pin.Pinner, repo repo.Repo, files *mfs.Root) (*standby.Service, error) {
+		s, err := standby.New(mctx, h, dag, bs, pinner, repo.Keystore(), files, repo.Datastore(), opts)
+		if err != nil {
+			return nil, err
+		}
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				s.Start()
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				return s.Close()
+			},
+		})
+		return s, nil
+	})
+}
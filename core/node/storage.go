@@ -1,9 +1,20 @@
 package node
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	humanize "github.com/dustin/go-humanize"
 	blockstore "github.com/ipfs/boxo/blockstore"
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/kubo/blocks/bloombs"
+	"github.com/ipfs/kubo/blocks/compressbs"
+	"github.com/ipfs/kubo/blocks/remotebs"
+	"github.com/ipfs/kubo/blocks/sourcebs"
+	"github.com/ipfs/kubo/blocks/writebackbs"
 	config "github.com/ipfs/kubo/config"
+	"github.com/klauspost/compress/zstd"
 	"go.uber.org/fx"
 
 	"github.com/ipfs/boxo/filestore"
@@ -26,20 +37,107 @@ func Datastore(repo repo.Repo) datastore.Datastore {
 // BaseBlocks is the lower level blockstore without GC or Filestore layers
 type BaseBlocks blockstore.Blockstore
 
-// BaseBlockstoreCtor creates cached blockstore backed by the provided datastore
-func BaseBlockstoreCtor(cacheOpts blockstore.CacheOpts, nilRepo bool, hashOnRead bool) func(mctx helpers.MetricsCtx, repo repo.Repo, lc fx.Lifecycle) (bs BaseBlocks, err error) {
-	return func(mctx helpers.MetricsCtx, repo repo.Repo, lc fx.Lifecycle) (bs BaseBlocks, err error) {
-		// hash security
+// CompressionPolicy builds the block compression policy from the config.
+func CompressionPolicy(cfg config.DatastoreCompression) (compressbs.Policy, error) {
+	policy := compressbs.DefaultPolicy()
+	policy.Enabled = cfg.Enabled.WithDefault(false)
+	if cfg.Level != nil {
+		lvl := cfg.Level.WithDefault("default")
+		ok, level := zstd.EncoderLevelFromString(lvl)
+		if !ok {
+			return policy, fmt.Errorf("invalid Datastore.Compression.Level %q", lvl)
+		}
+		policy.Level = level
+	}
+	for codec, size := range cfg.MinBlockSize {
+		policy.MinSize[codec] = size
+	}
+	return policy, nil
+}
+
+// BaseBlockstoreCtor creates cached blockstore backed by the provided datastore.
+// The compressing layer, the write-back journal, the bloom filter and the
+// remote blockstore, nil when disabled, are also returned so their stats can
+// be reported.
+func BaseBlockstoreCtor(cacheOpts blockstore.CacheOpts, nilRepo bool, hashOnRead bool, compression config.DatastoreCompression, remote config.DatastoreRemote, writeBack config.DatastoreWriteBack) func(mctx helpers.MetricsCtx, repo repo.Repo, lc fx.Lifecycle) (bs BaseBlocks, cbs *compressbs.Blockstore, wbs *writebackbs.Blockstore, bloom *bloombs.Blockstore, rbs *remotebs.Blockstore, err error) {
+	return func(mctx helpers.MetricsCtx, repo repo.Repo, lc fx.Lifecycle) (bs BaseBlocks, cbs *compressbs.Blockstore, wbs *writebackbs.Blockstore, bloom *bloombs.Blockstore, rbs *remotebs.Blockstore, err error) {
 		bs = blockstore.NewBlockstore(repo.Datastore())
+
+		// installed when compression is enabled, or while the repo holds
+		// blocks compressed before it was disabled. Compressed blocks can
+		// only be verified once decompressed, so this layer takes over
+		// HashOnRead.
+		policy, err := CompressionPolicy(compression)
+		if err != nil {
+			return nil, nil, nil, nil, nil, err
+		}
+		compressed, err := compressbs.HasCompressed(helpers.LifecycleCtx(mctx, lc), repo.Datastore())
+		if err != nil {
+			return nil, nil, nil, nil, nil, err
+		}
+		if policy.Enabled || compressed {
+			cbs, err = compressbs.New(bs, repo.Datastore(), policy)
+			if err != nil {
+				return nil, nil, nil, nil, nil, err
+			}
+			bs = cbs
+		}
+
+		// hash security
 		bs = &verifbs.VerifBS{Blockstore: bs}
 
+		if !nilRepo && writeBack.Enabled.WithDefault(false) {
+			wbs, err = WriteBackBlockstore(repo, bs, writeBack)
+			if err != nil {
+				return nil, nil, nil, nil, nil, err
+			}
+			lc.Append(fx.Hook{
+				OnStop: func(ctx context.Context) error {
+					// the blocks not flushed in time stay in the journal
+					if err := wbs.Flush(ctx); err != nil {
+						logger.Warnf("%d blocks left in the write-back journal: %s", wbs.Status().Pending, err)
+					}
+					return wbs.Close()
+				},
+			})
+			bs = wbs
+		}
+
 		if !nilRepo {
-			bs, err = blockstore.CachedBlockstore(helpers.LifecycleCtx(mctx, lc), bs, cacheOpts)
+			// the bloom filter of boxo is replaced by one that is saved
+			// across restarts
+			opts := cacheOpts
+			opts.HasBloomFilterSize = 0
+			bs, err = blockstore.CachedBlockstore(helpers.LifecycleCtx(mctx, lc), bs, opts)
 			if err != nil {
-				return nil, err
+				return nil, nil, nil, nil, nil, err
+			}
+			if cacheOpts.HasBloomFilterSize > 0 {
+				bloom, err = bloombs.New(repo.Datastore(), bs, cacheOpts.HasBloomFilterSize, cacheOpts.HasBloomFilterHashes)
+				if err != nil {
+					return nil, nil, nil, nil, nil, err
+				}
+				lc.Append(fx.Hook{
+					OnStop: func(context.Context) error {
+						return bloom.Close()
+					},
+				})
+				bs = bloom
 			}
 		}
 
+		// above the bloom filter, which only knows the local blocks
+		if len(remote.Endpoints) > 0 {
+			rbs, err = remotebs.New(bs, remote.Endpoints, remotebs.Options{
+				Cache:   remote.Cache.WithDefault(config.DefaultDatastoreRemoteCache),
+				Timeout: remote.Timeout.WithDefault(config.DefaultDatastoreRemoteTimeout),
+			})
+			if err != nil {
+				return nil, nil, nil, nil, nil, fmt.Errorf("Datastore.Remote.Endpoints: %w", err)
+			}
+			bs = rbs
+		}
+
 		bs = blockstore.NewIdStore(bs)
 
 		if hashOnRead { // TODO: review: this is how it was done originally, is there a reason we can't just pass this directly?
@@ -50,6 +148,26 @@ func BaseBlockstoreCtor(cacheOpts blockstore.CacheOpts, nilRepo bool, hashOnRead
 	}
 }
 
+// WriteBackBlockstore wraps bs with the write-back journal of cfg.
+func WriteBackBlockstore(r repo.Repo, bs blockstore.Blockstore, cfg config.DatastoreWriteBack) (*writebackbs.Blockstore, error) {
+	dir := cfg.Journal.WithDefault(config.DefaultDatastoreWriteBackJournal)
+	if !filepath.IsAbs(dir) {
+		pr, ok := r.(interface{ Path() string })
+		if !ok {
+			return nil, fmt.Errorf("Datastore.WriteBack.Journal must be an absolute path")
+		}
+		dir = filepath.Join(pr.Path(), dir)
+	}
+	maxPending, err := humanize.ParseBytes(cfg.MaxPending.WithDefault(config.DefaultDatastoreWriteBackMaxPending))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Datastore.WriteBack.MaxPending: %w", err)
+	}
+	return writebackbs.New(bs, dir, writebackbs.Options{
+		Workers:         int(cfg.Workers.WithDefault(config.DefaultDatastoreWriteBackWorkers)),
+		MaxPendingBytes: int64(maxPending),
+	})
+}
+
 // GcBlockstoreCtor wraps the base blockstore with GC and Filestore layers
 func GcBlockstoreCtor(bb BaseBlocks) (gclocker blockstore.GCLocker, gcbs blockstore.GCBlockstore, bs blockstore.Blockstore) {
 	gclocker = blockstore.NewGCLocker()
@@ -59,13 +177,46 @@ func GcBlockstoreCtor(bb BaseBlocks) (gclocker blockstore.GCLocker, gcbs blockst
 	return
 }
 
+// FilestoreSources checks the availability of the mounts of Filestore.Mounts,
+// when set.
+func FilestoreSources(cfg config.Filestore) func(lc fx.Lifecycle) *sourcebs.Checker {
+	return func(lc fx.Lifecycle) *sourcebs.Checker {
+		if len(cfg.Mounts) == 0 {
+			return nil
+		}
+		c := sourcebs.New(cfg.Mounts,
+			cfg.CheckInterval.WithDefault(config.DefaultFilestoreCheckInterval),
+			cfg.CheckTimeout.WithDefault(config.DefaultFilestoreCheckTimeout))
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				c.Start()
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				return c.Close()
+			},
+		})
+		return c
+	}
+}
+
 // GcBlockstoreCtor wraps GcBlockstore and adds Filestore support
-func FilestoreBlockstoreCtor(repo repo.Repo, bb BaseBlocks) (gclocker blockstore.GCLocker, gcbs blockstore.GCBlockstore, bs blockstore.Blockstore, fstore *filestore.Filestore) {
+func FilestoreBlockstoreCtor(repo repo.Repo, bb BaseBlocks, sources *sourcebs.Checker) (gclocker blockstore.GCLocker, gcbs blockstore.GCBlockstore, bs blockstore.Blockstore, fstore *filestore.Filestore) {
 	gclocker = blockstore.NewGCLocker()
 
 	// hash security
 	fstore = filestore.NewFilestore(bb, repo.FileManager())
-	gcbs = blockstore.NewGCBlockstore(fstore, gclocker)
+	var fbs blockstore.Blockstore = fstore
+	if sources != nil {
+		// the file manager reads the sources relative to the parent of the
+		// repo
+		root := string(filepath.Separator)
+		if r, ok := repo.(interface{ Path() string }); ok {
+			root = filepath.Dir(r.Path())
+		}
+		fbs = sourcebs.NewBlockstore(fstore, bb, root, sources)
+	}
+	gcbs = blockstore.NewGCBlockstore(fbs, gclocker)
 	gcbs = &verifbs.VerifBSGC{GCBlockstore: gcbs}
 
 	bs = gcbs
@@ -0,0 +1,66 @@
+package node
+
+import (
+	"context"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/webhooks"
+)
+
+// Webhooks creates the notifier posting the events of the node to
+// Webhooks.Endpoints, when there are any. The number of peers is only watched
+// by online nodes.
+func Webhooks(cfg config.Webhooks, online bool) fx.Option {
+	if len(cfg.Endpoints) == 0 {
+		return fx.Options()
+	}
+	opts := []fx.Option{
+		fx.Provide(func(lc fx.Lifecycle, id peer.ID) (*webhooks.Notifier, error) {
+			endpoints := make([]webhooks.Endpoint, len(cfg.Endpoints))
+			for i, e := range cfg.Endpoints {
+				endpoints[i] = webhooks.Endpoint{URL: e.URL, Events: e.Events}
+				if e.Secret != "" {
+					endpoints[i].Secret = []byte(e.Secret)
+				}
+			}
+			n, err := webhooks.New(webhooks.Options{
+				Node:      id.String(),
+				Endpoints: endpoints,
+				Timeout:   cfg.Timeout.WithDefault(config.DefaultWebhooksTimeout),
+			})
+			if err != nil {
+				return nil, err
+			}
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					n.Start()
+					return nil
+				},
+				OnStop: func(context.Context) error {
+					return n.Close()
+				},
+			})
+			return n, nil
+		}),
+	}
+	if online {
+		opts = append(opts, fx.Invoke(func(lc fx.Lifecycle, n *webhooks.Notifier, h host.Host) {
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					count := func() int {
+						return len(h.Network().Peers())
+					}
+					low := int(cfg.PeersLowWater.WithDefault(0))
+					high := int(cfg.PeersHighWater.WithDefault(0))
+					n.WatchPeers(count, low, high, cfg.PeersInterval.WithDefault(config.DefaultWebhooksPeersInterval))
+					return nil
+				},
+			})
+		}))
+	}
+	return fx.Options(opts...)
+}
@@ -0,0 +1,301 @@
+// Package peerpolicy deprioritizes or refuses the peers matching rules on
+// their peer ID or agent version, such as crawlers flooding the node with
+// connections. The rules can be changed at runtime.
+package peerpolicy
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// Action is what is done to the peers matching a rule.
+type Action string
+
+const (
+	// Deprioritize tags the peers in the connection manager with
+	// DeprioritizeWeight, so that their connections are trimmed first.
+	Deprioritize Action = "deprioritize"
+	// Refuse closes the connections of the peers, and refuses their next
+	// ones.
+	Refuse Action = "refuse"
+)
+
+// DeprioritizeWeight is the weight of the tag of the deprioritized peers.
+const DeprioritizeWeight = -100
+
+const connMgrTag = "peerpolicy"
+
+// maxRefused is the number of peers refused on their agent version that are
+// remembered, to refuse their next connections before identifying them.
+const maxRefused = 10000
+
+// Rule matches the peers of Peers, and the peers whose agent version matches
+// the regular expression AgentVersion.
+type Rule struct {
+	Name         string
+	AgentVersion string    `json:",omitempty"`
+	Peers        []peer.ID `json:",omitempty"`
+	Action       Action
+}
+
+// RuleStat is a rule and the number of times its action was taken.
+type RuleStat struct {
+	Rule
+	// Deprioritized is the number of peers deprioritized, Refused the
+	// number of connections refused or closed.
+	Deprioritized uint64
+	Refused       uint64
+}
+
+type rule struct {
+	Rule
+	agent *regexp.Regexp
+	peers map[peer.ID]struct{}
+
+	deprioritized, refused atomic.Uint64
+}
+
+func (r *rule) matchPeer(p peer.ID) bool {
+	_, ok := r.peers[p]
+	return ok
+}
+
+func (r *rule) match(p peer.ID, agent string) bool {
+	return r.matchPeer(p) || (r.agent != nil && agent != "" && r.agent.MatchString(agent))
+}
+
+// Policy applies rules to the peers, the first rule matching a peer applies.
+type Policy struct {
+	lk    sync.RWMutex
+	rules []*rule
+	// refused are the peers refused on their agent version
+	refused  map[peer.ID]*rule
+	host     host.Host
+	sub      event.Subscription
+	onRefuse func(peer.ID)
+}
+
+// New returns a policy applying rules.
+func New(rules []Rule) (*Policy, error) {
+	p := &Policy{refused: make(map[peer.ID]*rule)}
+	var err error
+	p.rules, err = p.compile(rules)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// compile compiles the rules, keeping the counters of the rules whose name
+// is unchanged.
+func (p *Policy) compile(rules []Rule) ([]*rule, error) {
+	old := make(map[string]*rule, len(p.rules))
+	for _, r := range p.rules {
+		old[r.Name] = r
+	}
+
+	out := make([]*rule, len(rules))
+	names := make(map[string]struct{}, len(rules))
+	for i, rr := range rules {
+		if _, ok := names[rr.Name]; ok {
+			return nil, fmt.Errorf("duplicate peer policy %q", rr.Name)
+		}
+		names[rr.Name] = struct{}{}
+		if rr.Action != Deprioritize && rr.Action != Refuse {
+			return nil, fmt.Errorf("peer policy %q: invalid action %q", rr.Name, rr.Action)
+		}
+		if rr.AgentVersion == "" && len(rr.Peers) == 0 {
+			return nil, fmt.Errorf("peer policy %q matches no peer", rr.Name)
+		}
+
+		r := &rule{Rule: rr, peers: make(map[peer.ID]struct{}, len(rr.Peers))}
+		if rr.AgentVersion != "" {
+			re, err := regexp.Compile(rr.AgentVersion)
+			if err != nil {
+				return nil, fmt.Errorf("peer policy %q: invalid agent version: %w", rr.Name, err)
+			}
+			r.agent = re
+		}
+		for _, id := range rr.Peers {
+			r.peers[id] = struct{}{}
+		}
+		if o, ok := old[rr.Name]; ok {
+			r.deprioritized.Store(o.deprioritized.Load())
+			r.refused.Store(o.refused.Load())
+		}
+		out[i] = r
+	}
+	return out, nil
+}
+
+// SetRules replaces the rules, and applies them to the connected peers.
+func (p *Policy) SetRules(rules []Rule) error {
+	p.lk.Lock()
+	compiled, err := p.compile(rules)
+	if err != nil {
+		p.lk.Unlock()
+		return err
+	}
+	p.rules = compiled
+	p.refused = make(map[peer.ID]*rule)
+	h := p.host
+	p.lk.Unlock()
+
+	if h != nil {
+		for _, id := range h.Network().Peers() {
+			p.apply(id)
+		}
+	}
+	return nil
+}
+
+// Stats returns the rules and the number of times their action was taken.
+func (p *Policy) Stats() []RuleStat {
+	p.lk.RLock()
+	defer p.lk.RUnlock()
+	out := make([]RuleStat, len(p.rules))
+	for i, r := range p.rules {
+		out[i] = RuleStat{
+			Rule:          r.Rule,
+			Deprioritized: r.deprioritized.Load(),
+			Refused:       r.refused.Load(),
+		}
+	}
+	return out
+}
+
+// Start applies the rules to the peers h identifies, until Close. onRefuse,
+// if not nil, is called before closing the connections of a refused peer.
+func (p *Policy) Start(h host.Host, onRefuse func(peer.ID)) error {
+	sub, err := h.EventBus().Subscribe(new(event.EvtPeerIdentificationCompleted))
+	if err != nil {
+		return err
+	}
+	p.lk.Lock()
+	p.host, p.sub, p.onRefuse = h, sub, onRefuse
+	p.lk.Unlock()
+
+	go func() {
+		for e := range sub.Out() {
+			p.apply(e.(event.EvtPeerIdentificationCompleted).Peer)
+		}
+	}()
+	return nil
+}
+
+// Close stops applying the rules to the peers identified.
+func (p *Policy) Close() error {
+	p.lk.Lock()
+	sub := p.sub
+	p.host, p.sub = nil, nil
+	p.lk.Unlock()
+	if sub == nil {
+		return nil
+	}
+	return sub.Close()
+}
+
+// apply applies the first rule matching the identified peer id.
+func (p *Policy) apply(id peer.ID) {
+	p.lk.Lock()
+	h, onRefuse := p.host, p.onRefuse
+	if h == nil {
+		p.lk.Unlock()
+		return
+	}
+	var agent string
+	if v, err := h.Peerstore().Get(id, "AgentVersion"); err == nil {
+		agent, _ = v.(string)
+	}
+	var match *rule
+	for _, r := range p.rules {
+		if r.match(id, agent) {
+			match = r
+			break
+		}
+	}
+	if match != nil && match.Action == Refuse {
+		if len(p.refused) >= maxRefused {
+			p.refused = make(map[peer.ID]*rule)
+		}
+		p.refused[id] = match
+	}
+	p.lk.Unlock()
+
+	cm := h.ConnManager()
+	switch {
+	case match == nil:
+		cm.UntagPeer(id, connMgrTag)
+	case match.Action == Deprioritize:
+		cm.TagPeer(id, connMgrTag, DeprioritizeWeight)
+		match.deprioritized.Add(1)
+	case match.Action == Refuse:
+		match.refused.Add(1)
+		if onRefuse != nil {
+			onRefuse(id)
+		}
+		_ = h.Network().ClosePeer(id)
+	}
+}
+
+// refuse tells whether the connections of the peer id are refused, before
+// identifying it.
+func (p *Policy) refuse(id peer.ID) bool {
+	p.lk.RLock()
+	defer p.lk.RUnlock()
+	match := p.refused[id]
+	if match == nil {
+		for _, r := range p.rules {
+			if r.matchPeer(id) {
+				match = r
+				break
+			}
+		}
+	}
+	if match == nil || match.Action != Refuse {
+		return false
+	}
+	match.refused.Add(1)
+	return true
+}
+
+// Gater returns next, also refusing the connections of the peers the policy
+// refuses.
+func (p *Policy) Gater(next connmgr.ConnectionGater) connmgr.ConnectionGater {
+	return &gater{next: next, p: p}
+}
+
+type gater struct {
+	next connmgr.ConnectionGater
+	p    *Policy
+}
+
+func (g *gater) InterceptAddrDial(id peer.ID, addr ma.Multiaddr) bool {
+	return g.next.InterceptAddrDial(id, addr)
+}
+
+func (g *gater) InterceptPeerDial(id peer.ID) bool {
+	return !g.p.refuse(id) && g.next.InterceptPeerDial(id)
+}
+
+func (g *gater) InterceptAccept(addrs network.ConnMultiaddrs) bool {
+	return g.next.InterceptAccept(addrs)
+}
+
+func (g *gater) InterceptSecured(dir network.Direction, id peer.ID, addrs network.ConnMultiaddrs) bool {
+	return !g.p.refuse(id) && g.next.InterceptSecured(dir, id, addrs)
+}
+
+func (g *gater) InterceptUpgraded(c network.Conn) (bool, control.DisconnectReason) {
+	return g.next.InterceptUpgraded(c)
+}
@@ -0,0 +1,72 @@
+package peerpolicy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+type allowAll struct{}
+
+func (allowAll) InterceptAddrDial(peer.ID, ma.Multiaddr) bool { return true }
+func (allowAll) InterceptPeerDial(peer.ID) bool               { return true }
+func (allowAll) InterceptAccept(network.ConnMultiaddrs) bool  { return true }
+func (allowAll) InterceptSecured(network.Direction, peer.ID, network.ConnMultiaddrs) bool {
+	return true
+}
+func (allowAll) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) { return true, 0 }
+
+func TestInvalidRules(t *testing.T) {
+	for _, rules := range [][]Rule{
+		{{Name: "a", AgentVersion: "x", Action: "drop"}},
+		{{Name: "a", Action: Refuse}},
+		{{Name: "a", AgentVersion: "(", Action: Refuse}},
+		{{Name: "a", AgentVersion: "x", Action: Refuse}, {Name: "a", AgentVersion: "y", Action: Refuse}},
+	} {
+		_, err := New(rules)
+		require.Error(t, err)
+	}
+}
+
+func TestRefuse(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mn, err := mocknet.FullMeshLinked(2)
+	require.NoError(t, err)
+	defer mn.Close()
+	h1, h2 := mn.Hosts()[0], mn.Hosts()[1]
+
+	// the agent of the mock hosts is the main module of the test binary
+	p, err := New([]Rule{{Name: "libp2p", AgentVersion: ".", Action: Refuse}})
+	require.NoError(t, err)
+	require.NoError(t, p.Start(h1, nil))
+	defer p.Close()
+
+	gater := p.Gater(allowAll{})
+	require.True(t, gater.InterceptPeerDial(h2.ID()))
+
+	require.NoError(t, h2.Connect(ctx, peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	require.Eventually(t, func() bool {
+		return h1.Network().Connectedness(h2.ID()) != network.Connected
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the next connections of the peer are refused before identifying it
+	require.False(t, gater.InterceptSecured(network.DirInbound, h2.ID(), nil))
+	stats := p.Stats()
+	require.Len(t, stats, 1)
+	require.Equal(t, uint64(2), stats[0].Refused)
+
+	// the counters of the rules are kept when they are replaced
+	require.NoError(t, p.SetRules([]Rule{
+		{Name: "libp2p", Peers: []peer.ID{h1.ID()}, Action: Deprioritize},
+	}))
+	require.True(t, gater.InterceptSecured(network.DirInbound, h2.ID(), nil))
+	require.Equal(t, uint64(2), p.Stats()[0].Refused)
+}
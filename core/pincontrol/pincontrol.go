@@ -0,0 +1,285 @@
+// Package pincontrol applies the pins and unpins ordered over a pubsub topic
+// by a set of allowed keys, so that a fleet of nodes can be told what to pin
+// without deploying a cluster. Orders are signed by their key, independently
+// of the peer that published them, and are only valid for a while so that
+// they can't be replayed.
+package pincontrol
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var log = logging.Logger("pincontrol")
+
+// Operations of the orders.
+const (
+	OpPin   = "pin"
+	OpUnpin = "unpin"
+)
+
+// signaturePrefix separates the signatures of the orders from the ones of
+// other data signed by the same keys.
+const signaturePrefix = "ipfs-pin-control:"
+
+var (
+	// ErrNotAllowed is returned for an order signed by a key that is not
+	// allowed.
+	ErrNotAllowed = errors.New("key not allowed")
+	// ErrExpired is returned for an order older than the max age, or from
+	// the future.
+	ErrExpired = errors.New("order expired")
+)
+
+// Order is a pin or an unpin.
+type Order struct {
+	Op        string
+	Cid       cid.Cid
+	Recursive bool   `json:",omitempty"`
+	Name      string `json:",omitempty"`
+	// Time is when the order was signed.
+	Time time.Time
+}
+
+// message is an order signed by a key, as published on the topic.
+type message struct {
+	// Order is the JSON of the order, as signed.
+	Order json.RawMessage
+	// Key is the peer ID of the key.
+	Key string
+	// PublicKey is the public key, for the keys not embedded in their peer
+	// ID, such as RSA keys.
+	PublicKey []byte `json:",omitempty"`
+	Signature []byte
+}
+
+// Sign signs an order with sk, and returns the message to publish on the
+// topic.
+func Sign(sk ci.PrivKey, o Order) ([]byte, error) {
+	switch o.Op {
+	case OpPin, OpUnpin:
+	default:
+		return nil, fmt.Errorf("unknown operation %q", o.Op)
+	}
+	if !o.Cid.Defined() {
+		return nil, errors.New("undefined CID")
+	}
+	if o.Time.IsZero() {
+		o.Time = time.Now()
+	}
+
+	order, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := sk.Sign(append([]byte(signaturePrefix), order...))
+	if err != nil {
+		return nil, err
+	}
+	pid, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	m := message{Order: order, Key: pid.String(), Signature: sig}
+	if _, err := pid.ExtractPublicKey(); err != nil {
+		if m.PublicKey, err = ci.MarshalPublicKey(sk.GetPublic()); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(m)
+}
+
+// Open verifies a message and returns its order and the key that signed it.
+// The key must be allowed, and the order must not be older than maxAge.
+func Open(data []byte, allowed map[peer.ID]struct{}, maxAge time.Duration, now time.Time) (Order, peer.ID, error) {
+	var m message
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Order{}, "", err
+	}
+	pid, err := peer.Decode(m.Key)
+	if err != nil {
+		return Order{}, "", err
+	}
+	if _, ok := allowed[pid]; !ok {
+		return Order{}, pid, ErrNotAllowed
+	}
+
+	pk, err := pid.ExtractPublicKey()
+	if err != nil {
+		if pk, err = ci.UnmarshalPublicKey(m.PublicKey); err != nil {
+			return Order{}, pid, fmt.Errorf("public key: %w", err)
+		}
+		if !pid.MatchesPublicKey(pk) {
+			return Order{}, pid, errors.New("public key doesn't match the key")
+		}
+	}
+	ok, err := pk.Verify(append([]byte(signaturePrefix), m.Order...), m.Signature)
+	if err != nil {
+		return Order{}, pid, err
+	}
+	if !ok {
+		return Order{}, pid, errors.New("invalid signature")
+	}
+
+	var o Order
+	if err := json.Unmarshal(m.Order, &o); err != nil {
+		return Order{}, pid, err
+	}
+	switch o.Op {
+	case OpPin, OpUnpin:
+	default:
+		return Order{}, pid, fmt.Errorf("unknown operation %q", o.Op)
+	}
+	// a little leeway for the clocks of the signers
+	if now.Sub(o.Time) > maxAge || o.Time.Sub(now) > time.Minute {
+		return Order{}, pid, ErrExpired
+	}
+	return o, pid, nil
+}
+
+// Options configure a Controller.
+type Options struct {
+	Topic       string
+	AllowedKeys []peer.ID
+	MaxAge      time.Duration
+
+	// Pin and Unpin apply the orders.
+	Pin   func(ctx context.Context, c cid.Cid, recursive bool, name string) error
+	Unpin func(ctx context.Context, c cid.Cid, recursive bool) error
+}
+
+// Controller applies the orders received on a topic.
+type Controller struct {
+	opts    Options
+	allowed map[peer.ID]struct{}
+
+	ps    *pubsub.PubSub
+	topic *pubsub.Topic
+	sub   *pubsub.Subscription
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	lk sync.Mutex
+	// seen are the messages applied, until they expire.
+	seen map[[sha256.Size]byte]time.Time
+}
+
+// New subscribes to the topic of opts, and applies its orders until Close is
+// called. Invalid messages are not relayed to the other peers of the topic.
+func New(ps *pubsub.PubSub, opts Options) (*Controller, error) {
+	c := &Controller{
+		opts:    opts,
+		allowed: make(map[peer.ID]struct{}, len(opts.AllowedKeys)),
+		ps:      ps,
+		done:    make(chan struct{}),
+		seen:    make(map[[sha256.Size]byte]time.Time),
+	}
+	for _, k := range opts.AllowedKeys {
+		c.allowed[k] = struct{}{}
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	err := ps.RegisterTopicValidator(opts.Topic, func(_ context.Context, _ peer.ID, msg *pubsub.Message) bool {
+		_, _, err := Open(msg.Data, c.allowed, opts.MaxAge, time.Now())
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if c.topic, err = ps.Join(opts.Topic); err != nil {
+		_ = ps.UnregisterTopicValidator(opts.Topic)
+		return nil, err
+	}
+	if c.sub, err = c.topic.Subscribe(); err != nil {
+		_ = c.topic.Close()
+		_ = ps.UnregisterTopicValidator(opts.Topic)
+		return nil, err
+	}
+
+	go c.run()
+	return c, nil
+}
+
+func (c *Controller) run() {
+	defer close(c.done)
+	for {
+		msg, err := c.sub.Next(c.ctx)
+		if err != nil {
+			return
+		}
+		o, key, err := Open(msg.Data, c.allowed, c.opts.MaxAge, time.Now())
+		if err != nil {
+			log.Debugf("ignoring message from %s: %s", msg.ReceivedFrom, err)
+			continue
+		}
+		if !c.firstSeen(msg.Data, o.Time) {
+			continue
+		}
+		if err := c.apply(o); err != nil {
+			log.Errorf("%s %s ordered by %s: %s", o.Op, o.Cid, key, err)
+			continue
+		}
+		log.Infof("%s %s ordered by %s", o.Op, o.Cid, key)
+	}
+}
+
+// firstSeen returns whether the message is seen for the first time. The
+// messages are remembered until they expire, after what Open rejects them.
+func (c *Controller) firstSeen(data []byte, signed time.Time) bool {
+	h := sha256.Sum256(data)
+	now := time.Now()
+
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	for k, eol := range c.seen {
+		if now.After(eol) {
+			delete(c.seen, k)
+		}
+	}
+	if _, ok := c.seen[h]; ok {
+		return false
+	}
+	c.seen[h] = signed.Add(c.opts.MaxAge)
+	return true
+}
+
+func (c *Controller) apply(o Order) error {
+	switch o.Op {
+	case OpPin:
+		return c.opts.Pin(c.ctx, o.Cid, o.Recursive, o.Name)
+	case OpUnpin:
+		return c.opts.Unpin(c.ctx, o.Cid, o.Recursive)
+	default:
+		return fmt.Errorf("unknown operation %q", o.Op)
+	}
+}
+
+// Topic returns the topic of the orders.
+func (c *Controller) Topic() string {
+	return c.opts.Topic
+}
+
+// Close stops applying the orders, and leaves the topic.
+func (c *Controller) Close() error {
+	c.cancel()
+	c.sub.Cancel()
+	<-c.done
+	err := c.topic.Close()
+	if uerr := c.ps.UnregisterTopicValidator(c.opts.Topic); err == nil {
+		err = uerr
+	}
+	return err
+}
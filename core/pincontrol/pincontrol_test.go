@@ -0,0 +1,75 @@
+package pincontrol
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignOpen(t *testing.T) {
+	c, err := cid.Decode("bafkqaaa")
+	require.NoError(t, err)
+
+	sk, _, err := ci.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	pid, err := peer.IDFromPrivateKey(sk)
+	require.NoError(t, err)
+	// the public key of ECDSA keys is not embedded in their peer ID
+	ecdsa, _, err := ci.GenerateECDSAKeyPair(rand.Reader)
+	require.NoError(t, err)
+	ecdsaID, err := peer.IDFromPrivateKey(ecdsa)
+	require.NoError(t, err)
+
+	allowed := map[peer.ID]struct{}{pid: {}, ecdsaID: {}}
+	now := time.Now()
+
+	for _, k := range []ci.PrivKey{sk, ecdsa} {
+		data, err := Sign(k, Order{Op: OpPin, Cid: c, Recursive: true, Name: "fleet"})
+		require.NoError(t, err)
+		o, key, err := Open(data, allowed, time.Minute, now)
+		require.NoError(t, err)
+		require.True(t, key.MatchesPrivateKey(k))
+		require.Equal(t, OpPin, o.Op)
+		require.True(t, o.Cid.Equals(c))
+		require.Equal(t, "fleet", o.Name)
+	}
+
+	data, err := Sign(sk, Order{Op: OpUnpin, Cid: c})
+	require.NoError(t, err)
+
+	_, _, err = Open(data, map[peer.ID]struct{}{ecdsaID: {}}, time.Minute, now)
+	require.ErrorIs(t, err, ErrNotAllowed)
+
+	_, _, err = Open(data, allowed, time.Minute, now.Add(2*time.Minute))
+	require.ErrorIs(t, err, ErrExpired)
+
+	// the order can't be changed without the key
+	var m message
+	require.NoError(t, json.Unmarshal(data, &m))
+	m.Order, err = json.Marshal(Order{Op: OpPin, Cid: c, Time: now})
+	require.NoError(t, err)
+	tampered, err := json.Marshal(m)
+	require.NoError(t, err)
+	_, _, err = Open(tampered, allowed, time.Minute, now)
+	require.Error(t, err)
+
+	_, err = Sign(sk, Order{Op: "delete", Cid: c})
+	require.Error(t, err)
+}
+
+func TestFirstSeen(t *testing.T) {
+	c := &Controller{opts: Options{MaxAge: time.Minute}, seen: make(map[[32]byte]time.Time)}
+	require.True(t, c.firstSeen([]byte("a"), time.Now()))
+	require.False(t, c.firstSeen([]byte("a"), time.Now()))
+	require.True(t, c.firstSeen([]byte("b"), time.Now()))
+
+	// expired messages are forgotten
+	require.True(t, c.firstSeen([]byte("c"), time.Now().Add(-2*time.Minute)))
+	require.True(t, c.firstSeen([]byte("c"), time.Now()))
+}
@@ -0,0 +1,102 @@
+// Package pingrace keeps the pins removed with a grace period. Until the
+// grace period expires, the blocks of a removed pin are protected from
+// garbage collection, and the pin can be restored.
+package pingrace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// DatastoreKey is the prefix under which removed pins are persisted, by CID.
+var DatastoreKey = datastore.NewKey("/local/pingrace")
+
+// ErrNotFound is returned for a CID that wasn't removed with a grace period,
+// or whose grace period expired.
+var ErrNotFound = errors.New("no pin pending removal for this object")
+
+// Removal is a pin removed with a grace period.
+type Removal struct {
+	Cid       cid.Cid
+	Recursive bool
+	Name      string `json:",omitempty"`
+	Removed   time.Time
+	Expires   time.Time
+}
+
+// Save persists the removal, replacing a previous removal of the same CID.
+func Save(ctx context.Context, ds datastore.Datastore, r *Removal) error {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return ds.Put(ctx, DatastoreKey.ChildString(r.Cid.String()), buf)
+}
+
+// List returns the removals whose grace period hasn't expired at now, the
+// oldest first. Expired removals are deleted.
+func List(ctx context.Context, ds datastore.Datastore, now time.Time) ([]Removal, error) {
+	results, err := ds.Query(ctx, query.Query{Prefix: DatastoreKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var removals []Removal
+	var expired []datastore.Key
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var removal Removal
+		if err := json.Unmarshal(r.Value, &removal); err != nil {
+			return nil, err
+		}
+		if !now.Before(removal.Expires) {
+			expired = append(expired, datastore.RawKey(r.Key))
+			continue
+		}
+		removals = append(removals, removal)
+	}
+
+	for _, k := range expired {
+		if err := ds.Delete(ctx, k); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(removals, func(i, j int) bool {
+		return removals[i].Removed.Before(removals[j].Removed)
+	})
+	return removals, nil
+}
+
+// Take returns the removal of c, and deletes it.
+func Take(ctx context.Context, ds datastore.Datastore, c cid.Cid, now time.Time) (*Removal, error) {
+	k := DatastoreKey.ChildString(c.String())
+	buf, err := ds.Get(ctx, k)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var removal Removal
+	if err := json.Unmarshal(buf, &removal); err != nil {
+		return nil, err
+	}
+	if err := ds.Delete(ctx, k); err != nil {
+		return nil, err
+	}
+	if !now.Before(removal.Expires) {
+		return nil, ErrNotFound
+	}
+	return &removal, nil
+}
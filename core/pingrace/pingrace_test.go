@@ -0,0 +1,61 @@
+package pingrace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func testCid(t *testing.T, data string) cid.Cid {
+	t.Helper()
+	h, err := mh.Sum([]byte(data), mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cid.NewCidV1(cid.DagProtobuf, h)
+}
+
+func TestRemovals(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	now := time.Now()
+
+	recent := &Removal{Cid: testCid(t, "recent"), Recursive: true, Name: "recent", Removed: now, Expires: now.Add(time.Hour)}
+	old := &Removal{Cid: testCid(t, "old"), Removed: now.Add(-time.Hour), Expires: now.Add(time.Minute)}
+	expired := &Removal{Cid: testCid(t, "expired"), Removed: now.Add(-2 * time.Hour), Expires: now.Add(-time.Hour)}
+	for _, r := range []*Removal{recent, old, expired} {
+		if err := Save(ctx, ds, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removals, err := List(ctx, ds, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removals) != 2 || !removals[0].Cid.Equals(old.Cid) || !removals[1].Cid.Equals(recent.Cid) {
+		t.Fatalf("unexpected removals %+v", removals)
+	}
+	if has, err := ds.Has(ctx, DatastoreKey.ChildString(expired.Cid.String())); err != nil || has {
+		t.Fatal("the expired removal wasn't deleted")
+	}
+
+	r, err := Take(ctx, ds, recent.Cid, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Recursive || r.Name != "recent" {
+		t.Fatalf("unexpected removal %+v", r)
+	}
+	if _, err := Take(ctx, ds, recent.Cid, now); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := Take(ctx, ds, old.Cid, now.Add(time.Hour)); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound for an expired removal, got %v", err)
+	}
+}
@@ -0,0 +1,424 @@
+// Package pinqueue implements a persistent queue of pin requests, processed
+// in the background by priority with a concurrency limit and retries. It
+// mirrors the pinning service model for the local node: requests are queued,
+// pinning, or failed, and disappear from the queue once pinned.
+package pinqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("pinqueue")
+
+// DatastoreKey is the prefix under which the queue is persisted.
+var DatastoreKey = datastore.NewKey("/local/pinqueue")
+
+// ErrNotFound is returned for a request that is not in the queue.
+var ErrNotFound = errors.New("pin request not found")
+
+// Status of a pin request.
+type Status string
+
+const (
+	// Queued requests wait for a worker, or for their next attempt.
+	Queued Status = "queued"
+	// Pinning requests are being processed.
+	Pinning Status = "pinning"
+	// Failed requests ran out of attempts. They stay in the queue until
+	// they are cancelled or added again.
+	Failed Status = "failed"
+)
+
+// Request is a pin request in the queue.
+type Request struct {
+	ID        string
+	Cid       cid.Cid
+	Recursive bool
+	Name      string `json:",omitempty"`
+
+	// Priority orders the queue, higher first. Requests of the same priority
+	// are processed in the order they were added.
+	Priority int
+
+	Status      Status
+	Attempts    int
+	LastError   string `json:",omitempty"`
+	Created     time.Time
+	NextAttempt time.Time
+}
+
+// PinFunc pins a CID. It is called by the workers of the queue, and must
+// return when ctx is cancelled.
+type PinFunc func(ctx context.Context, c cid.Cid, recursive bool, name string) error
+
+// Options configure a Queue.
+type Options struct {
+	// Concurrency is the number of requests processed at the same time.
+	Concurrency int
+	// MaxAttempts is the number of attempts before a request fails, 0 for
+	// no limit.
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry. It doubles with each
+	// failed attempt, up to MaxBackoff.
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+	// AttemptTimeout bounds a single attempt, so that content that cannot be
+	// found is retried later instead of occupying a worker. 0 for no limit.
+	AttemptTimeout time.Duration
+}
+
+type entry struct {
+	Request
+	cancel  context.CancelFunc
+	removed bool
+}
+
+// Queue is a persistent queue of pin requests.
+type Queue struct {
+	ds   datastore.Datastore
+	pin  PinFunc
+	opts Options
+
+	lk      sync.Mutex
+	entries map[string]*entry
+	running int
+	wake    chan struct{}
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started bool
+	wg      sync.WaitGroup
+}
+
+// New loads the queue persisted in ds. Requests are processed once Start is
+// called, until ctx is cancelled or Close is called.
+func New(ctx context.Context, ds datastore.Datastore, pin PinFunc, opts Options) (*Queue, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	q := &Queue{
+		ds:      namespace.Wrap(ds, DatastoreKey),
+		pin:     pin,
+		opts:    opts,
+		entries: make(map[string]*entry),
+		wake:    make(chan struct{}, 1),
+	}
+	q.ctx, q.cancel = context.WithCancel(ctx)
+
+	res, err := q.ds.Query(ctx, query.Query{})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var req Request
+		if err := json.Unmarshal(r.Value, &req); err != nil {
+			log.Errorf("skipping invalid pin request %s: %s", r.Key, err)
+			continue
+		}
+		if req.Status == Pinning {
+			req.Status = Queued
+		}
+		q.entries[req.ID] = &entry{Request: req}
+	}
+	return q, nil
+}
+
+// Start processes the requests in the background until Close is called.
+func (q *Queue) Start() {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	if q.started {
+		return
+	}
+	q.started = true
+	q.wg.Add(1)
+	go q.dispatch()
+}
+
+// Close stops processing requests. Requests being pinned are interrupted and
+// stay queued, to be resumed the next time the queue is started.
+func (q *Queue) Close() error {
+	q.cancel()
+	q.wg.Wait()
+	return nil
+}
+
+// Add queues a pin request. Adding a CID that is already queued returns the
+// existing request, with the higher of both priorities. Adding a CID whose
+// request failed queues it again.
+func (q *Queue) Add(ctx context.Context, c cid.Cid, recursive bool, name string, priority int) (Request, error) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	for _, e := range q.entries {
+		if !e.Cid.Equals(c) || e.Recursive != recursive {
+			continue
+		}
+		if priority > e.Priority {
+			e.Priority = priority
+		}
+		if e.Status == Failed {
+			e.Status = Queued
+			e.Attempts = 0
+			e.NextAttempt = time.Time{}
+		}
+		if err := q.persist(ctx, e); err != nil {
+			return Request{}, err
+		}
+		q.notify()
+		return e.Request, nil
+	}
+
+	e := &entry{Request: Request{
+		ID:        uuid.New().String(),
+		Cid:       c,
+		Recursive: recursive,
+		Name:      name,
+		Priority:  priority,
+		Status:    Queued,
+		Created:   time.Now(),
+	}}
+	if err := q.persist(ctx, e); err != nil {
+		return Request{}, err
+	}
+	q.entries[e.ID] = e
+	q.notify()
+	return e.Request, nil
+}
+
+// List returns the requests in the order they will be processed.
+func (q *Queue) List() []Request {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	reqs := make([]Request, 0, len(q.entries))
+	for _, e := range q.entries {
+		reqs = append(reqs, e.Request)
+	}
+	sort.Slice(reqs, func(i, j int) bool { return before(&reqs[i], &reqs[j]) })
+	return reqs
+}
+
+// Get returns a request by ID.
+func (q *Queue) Get(id string) (Request, error) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	e, ok := q.entries[id]
+	if !ok {
+		return Request{}, ErrNotFound
+	}
+	return e.Request, nil
+}
+
+// SetPriority moves a request in the queue.
+func (q *Queue) SetPriority(ctx context.Context, id string, priority int) (Request, error) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	e, ok := q.entries[id]
+	if !ok {
+		return Request{}, ErrNotFound
+	}
+	e.Priority = priority
+	if err := q.persist(ctx, e); err != nil {
+		return Request{}, err
+	}
+	q.notify()
+	return e.Request, nil
+}
+
+// Cancel removes a request from the queue, interrupting it if it is being
+// pinned. Blocks fetched so far are left to the garbage collector.
+func (q *Queue) Cancel(ctx context.Context, id string) error {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	e, ok := q.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.removed = true
+	delete(q.entries, id)
+	return q.ds.Delete(ctx, datastore.NewKey(id))
+}
+
+func before(a, b *Request) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return a.Created.Before(b.Created)
+}
+
+// persist must be called with the lock held.
+func (q *Queue) persist(ctx context.Context, e *entry) error {
+	req := e.Request
+	if req.Status == Pinning {
+		req.Status = Queued
+	}
+	buf, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	key := datastore.NewKey(req.ID)
+	if err := q.ds.Put(ctx, key, buf); err != nil {
+		return fmt.Errorf("persisting pin request: %w", err)
+	}
+	return q.ds.Sync(ctx, key)
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) dispatch() {
+	defer q.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		wait := q.startReady()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if wait > 0 {
+			timer.Reset(wait)
+		}
+
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// startReady starts as many ready requests as there are free workers. It
+// returns how long to wait for the next retry, or 0 when nothing is waiting
+// for one.
+func (q *Queue) startReady() time.Duration {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for q.running < q.opts.Concurrency {
+		var next *entry
+		for _, e := range q.entries {
+			if e.Status != Queued {
+				continue
+			}
+			if d := e.NextAttempt.Sub(now); d > 0 {
+				if wait == 0 || d < wait {
+					wait = d
+				}
+				continue
+			}
+			if next == nil || before(&e.Request, &next.Request) {
+				next = e
+			}
+		}
+		if next == nil {
+			break
+		}
+		q.start(next)
+	}
+	return wait
+}
+
+// start must be called with the lock held.
+func (q *Queue) start(e *entry) {
+	ctx, cancel := context.WithCancel(q.ctx)
+	if q.opts.AttemptTimeout > 0 {
+		ctx, cancel = context.WithTimeout(q.ctx, q.opts.AttemptTimeout)
+	}
+	e.Status = Pinning
+	e.cancel = cancel
+	q.running++
+
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		err := q.pin(ctx, e.Cid, e.Recursive, e.Name)
+		cancel()
+		q.finish(e, err)
+	}()
+}
+
+func (q *Queue) finish(e *entry, err error) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	defer q.notify()
+
+	q.running--
+	e.cancel = nil
+	if e.removed {
+		return
+	}
+
+	ctx := context.Background()
+	switch {
+	case err == nil:
+		delete(q.entries, e.ID)
+		if err := q.ds.Delete(ctx, datastore.NewKey(e.ID)); err != nil {
+			log.Errorf("removing pinned request %s: %s", e.ID, err)
+		}
+		return
+	case q.ctx.Err() != nil:
+		// the queue is closing, try again on the next start
+		e.Status = Queued
+		return
+	}
+
+	e.Attempts++
+	e.LastError = err.Error()
+	if q.opts.MaxAttempts > 0 && e.Attempts >= q.opts.MaxAttempts {
+		log.Errorf("pinning %s failed after %d attempts: %s", e.Cid, e.Attempts, err)
+		e.Status = Failed
+	} else {
+		log.Debugf("pinning %s failed, retrying: %s", e.Cid, err)
+		e.Status = Queued
+		e.NextAttempt = time.Now().Add(q.backoff(e.Attempts))
+	}
+	if err := q.persist(ctx, e); err != nil {
+		log.Error(err)
+	}
+}
+
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.opts.RetryBackoff
+	for i := 1; i < attempts && d < q.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	if q.opts.MaxBackoff > 0 && d > q.opts.MaxBackoff {
+		d = q.opts.MaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,182 @@
+package pinqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func testCid(t *testing.T, s string) cid.Cid {
+	t.Helper()
+	h, err := mh.Sum([]byte(s), mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cid.NewCidV1(cid.Raw, h)
+}
+
+func waitEmpty(t *testing.T, q *Queue) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(q.List()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("queue not empty: %+v", q.List())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPriorityOrder(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	var lk sync.Mutex
+	var pinned []cid.Cid
+	pin := func(ctx context.Context, c cid.Cid, recursive bool, name string) error {
+		lk.Lock()
+		defer lk.Unlock()
+		pinned = append(pinned, c)
+		return nil
+	}
+	q, err := New(ctx, ds, pin, Options{Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	low, mid, high := testCid(t, "low"), testCid(t, "mid"), testCid(t, "high")
+	for _, r := range []struct {
+		c        cid.Cid
+		priority int
+	}{{low, 0}, {mid, 5}, {high, 10}} {
+		if _, err := q.Add(ctx, r.c, true, "", r.priority); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := q.Add(ctx, low, true, "", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.List()) != 3 || req.Priority != 20 {
+		t.Fatalf("adding a queued CID should raise its priority, got %+v", q.List())
+	}
+	if _, err := q.SetPriority(ctx, req.ID, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	q.Start()
+	defer q.Close()
+	waitEmpty(t, q)
+
+	lk.Lock()
+	defer lk.Unlock()
+	expected := []cid.Cid{high, low, mid}
+	for i, c := range expected {
+		if !pinned[i].Equals(c) {
+			t.Fatalf("expected %v, got %v", expected, pinned)
+		}
+	}
+}
+
+func TestRetryAndPersistence(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	unreachable := errors.New("unreachable")
+	pin := func(ctx context.Context, c cid.Cid, recursive bool, name string) error {
+		return unreachable
+	}
+	opts := Options{MaxAttempts: 3, RetryBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	q, err := New(ctx, ds, pin, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := q.Add(ctx, testCid(t, "a"), true, "a", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r, err := q.Get(req.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Status == Failed {
+			if r.Attempts != 3 || r.LastError != unreachable.Error() {
+				t.Fatalf("unexpected failed request %+v", r)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("request did not fail: %+v", r)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	q.Close()
+
+	// the failed request is loaded again, and can be cancelled
+	q, err = New(ctx, ds, pin, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := q.Get(req.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != Failed || r.Name != "a" {
+		t.Fatalf("unexpected reloaded request %+v", r)
+	}
+	if err := q.Cancel(ctx, req.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Cancel(ctx, req.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	q, err = New(ctx, ds, pin, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.List()) != 0 {
+		t.Fatalf("cancelled request was persisted: %+v", q.List())
+	}
+}
+
+func TestCancelPinning(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	started := make(chan struct{})
+	pin := func(ctx context.Context, c cid.Cid, recursive bool, name string) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	q, err := New(ctx, ds, pin, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := q.Add(ctx, testCid(t, "a"), true, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Start()
+	defer q.Close()
+
+	<-started
+	if r, _ := q.Get(req.ID); r.Status != Pinning {
+		t.Fatalf("expected the request to be pinning, got %+v", r)
+	}
+	if err := q.Cancel(ctx, req.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitEmpty(t, q)
+}
@@ -0,0 +1,224 @@
+// Package pinreport accounts for the origin of the blocks fetched by pin
+// operations: how many blocks and bytes came from which peers, over which
+// transports, and how many were already stored locally.
+package pinreport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	format "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/core/dagshape"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// DatastoreKey is the prefix under which reports are persisted, by CID.
+var DatastoreKey = datastore.NewKey("/local/pinreports")
+
+// ErrNotFound is returned for a CID that was not pinned since reports are
+// recorded.
+var ErrNotFound = errors.New("no report for this pin")
+
+const (
+	// TransportLocal is the transport of the blocks that were already
+	// stored locally.
+	TransportLocal = "local"
+	// TransportUnknown is the transport of the blocks whose origin was not
+	// recorded, e.g. blocks fetched while the node was offline.
+	TransportUnknown = "unknown"
+)
+
+// Source is the share of a pin fetched from a peer over a transport.
+type Source struct {
+	// Peer is empty for local and unknown blocks.
+	Peer      peer.ID `json:",omitempty"`
+	Transport string
+	Blocks    uint64
+	Bytes     uint64
+}
+
+// Report is the accounting of a pin operation.
+type Report struct {
+	Cid       cid.Cid
+	Recursive bool
+	Started   time.Time
+	Finished  time.Time
+	Blocks    uint64
+	Bytes     uint64
+	// Sources are sorted by bytes, largest first.
+	Sources []Source
+}
+
+type sourceKey struct {
+	peer      peer.ID
+	transport string
+}
+
+type accountant struct {
+	rec *Recorder
+	bs  blockstore.Blockstore
+
+	lk      sync.Mutex
+	sources map[sourceKey]*Source
+	blocks  uint64
+	bytes   uint64
+}
+
+func (a *accountant) count(c cid.Cid, size int, local bool) {
+	key := sourceKey{transport: TransportLocal}
+	if !local {
+		key.transport = TransportUnknown
+		if r, ok := a.rec.lookup(c); ok {
+			key = sourceKey{peer: r.peer, transport: r.transport}
+		}
+	}
+
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	s, ok := a.sources[key]
+	if !ok {
+		s = &Source{Peer: key.peer, Transport: key.transport}
+		a.sources[key] = s
+	}
+	s.Blocks++
+	s.Bytes += uint64(size)
+	a.blocks++
+	a.bytes += uint64(size)
+}
+
+func (a *accountant) has(ctx context.Context, c cid.Cid) bool {
+	has, err := a.bs.Has(ctx, c)
+	return err == nil && has
+}
+
+// countingDAG counts the blocks fetched through it. It is a SessionMaker so
+// that merkledag.FetchGraph still fetches the DAG in a session.
+type countingDAG struct {
+	format.DAGService
+	a *accountant
+}
+
+func (d *countingDAG) Get(ctx context.Context, c cid.Cid) (format.Node, error) {
+	return (&countingGetter{ng: d.DAGService, a: d.a}).Get(ctx, c)
+}
+
+func (d *countingDAG) GetMany(ctx context.Context, cids []cid.Cid) <-chan *format.NodeOption {
+	return (&countingGetter{ng: d.DAGService, a: d.a}).GetMany(ctx, cids)
+}
+
+func (d *countingDAG) Session(ctx context.Context) format.NodeGetter {
+	return &countingGetter{ng: merkledag.NewSession(ctx, d.DAGService), a: d.a}
+}
+
+type countingGetter struct {
+	ng format.NodeGetter
+	a  *accountant
+}
+
+func (g *countingGetter) Get(ctx context.Context, c cid.Cid) (format.Node, error) {
+	local := g.a.has(ctx, c)
+	nd, err := g.ng.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	g.a.count(c, len(nd.RawData()), local)
+	return nd, nil
+}
+
+func (g *countingGetter) GetMany(ctx context.Context, cids []cid.Cid) <-chan *format.NodeOption {
+	local := make(map[cid.Cid]bool, len(cids))
+	for _, c := range cids {
+		local[c] = g.a.has(ctx, c)
+	}
+
+	in := g.ng.GetMany(ctx, cids)
+	out := make(chan *format.NodeOption, len(cids))
+	go func() {
+		defer close(out)
+		for opt := range in {
+			if opt.Err == nil {
+				g.a.count(opt.Node.Cid(), len(opt.Node.RawData()), local[opt.Node.Cid()])
+			}
+			select {
+			case out <- opt:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Fetch fetches the block of root, or the whole DAG under it when recursive,
+// and accounts for the origin of each block. rec may be nil on offline nodes.
+// The DAG is checked against limits as it is fetched. The returned report is
+// not finished: the caller sets Finished once the pin is complete.
+func Fetch(ctx context.Context, rec *Recorder, bs blockstore.Blockstore, dag format.DAGService, root cid.Cid, recursive bool, limits dagshape.Limits) (*Report, error) {
+	report := &Report{Cid: root, Recursive: recursive, Started: time.Now()}
+	a := &accountant{rec: rec, bs: bs, sources: make(map[sourceKey]*Source)}
+	d := &countingDAG{DAGService: dag, a: a}
+
+	var err error
+	switch {
+	case recursive && limits.Enabled():
+		err = dagshape.Walk(ctx, d, root, limits, false)
+	case recursive:
+		err = merkledag.FetchGraph(ctx, root, d)
+	default:
+		var nd format.Node
+		if nd, err = d.Get(ctx, root); err == nil {
+			err = limits.CheckNode(nd)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	report.Blocks, report.Bytes = a.blocks, a.bytes
+	for _, s := range a.sources {
+		report.Sources = append(report.Sources, *s)
+	}
+	sort.Slice(report.Sources, func(i, j int) bool {
+		if report.Sources[i].Bytes != report.Sources[j].Bytes {
+			return report.Sources[i].Bytes > report.Sources[j].Bytes
+		}
+		return report.Sources[i].Peer < report.Sources[j].Peer
+	})
+	return report, nil
+}
+
+// Save persists the report, replacing the report of a previous pin of the
+// same CID.
+func Save(ctx context.Context, ds datastore.Datastore, report *Report) error {
+	buf, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return ds.Put(ctx, DatastoreKey.ChildString(report.Cid.String()), buf)
+}
+
+// Load returns the report of the last pin of c.
+func Load(ctx context.Context, ds datastore.Datastore, c cid.Cid) (*Report, error) {
+	buf, err := ds.Get(ctx, DatastoreKey.ChildString(c.String()))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var report Report
+	if err := json.Unmarshal(buf, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
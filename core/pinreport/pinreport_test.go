@@ -0,0 +1,112 @@
+package pinreport
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/kubo/core/dagshape"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestTransportName(t *testing.T) {
+	for addr, expected := range map[string]string{
+		"/ip4/1.2.3.4/tcp/4001":                            "tcp",
+		"/ip6/::1/udp/4001/quic-v1":                        "quic-v1",
+		"/dns4/example.com/tcp/443/tls/sni/example.com/ws": "ws",
+		"/ip4/1.2.3.4/udp/4001/quic-v1/webtransport":       "webtransport",
+		"/ip4/1.2.3.4/tcp/4001/p2p/12D3KooWRBy97UB99e3J6hiPesre1MZeuNQvfan4gBziswrRJsNK/p2p-circuit": "p2p-circuit",
+	} {
+		if name := transportName(ma.StringCast(addr)); name != expected {
+			t.Errorf("%s: expected %q, got %q", addr, expected, name)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	ctx := context.Background()
+	newBlockstore := func() blockstore.Blockstore {
+		return blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	}
+
+	// the root is stored locally, its children are fetched from a peer
+	child1 := merkledag.NodeWithData([]byte("child1"))
+	child2 := merkledag.NodeWithData([]byte("child2"))
+	root := merkledag.NodeWithData([]byte("root"))
+	if err := root.AddNodeLink("1", child1); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.AddNodeLink("2", child2); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := newBlockstore()
+	if err := remote.PutMany(ctx, []blocks.Block{child1, child2}); err != nil {
+		t.Fatal(err)
+	}
+	local := newBlockstore()
+	if err := local.Put(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	dag := merkledag.NewDAGService(blockservice.New(local, offline.Exchange(remote)))
+
+	p, err := peer.Decode("12D3KooWRBy97UB99e3J6hiPesre1MZeuNQvfan4gBziswrRJsNK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &Recorder{recent: map[cid.Cid]receipt{
+		child1.Cid(): {peer: p, transport: "quic-v1"},
+	}}
+
+	// the limits are checked as the DAG is fetched
+	if _, err := Fetch(ctx, rec, local, dag, root.Cid(), true, dagshape.Limits{MaxLinks: 1}); !errors.Is(err, dagshape.ErrExceeded) {
+		t.Fatalf("expected the limits to be exceeded, got %v", err)
+	}
+
+	report, err := Fetch(ctx, rec, local, dag, root.Cid(), true, dagshape.Limits{MaxDepth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size := func(n *merkledag.ProtoNode) uint64 { return uint64(len(n.RawData())) }
+	if report.Blocks != 3 || report.Bytes != size(root)+size(child1)+size(child2) {
+		t.Fatalf("unexpected totals %d blocks, %d bytes", report.Blocks, report.Bytes)
+	}
+	expected := map[Source]bool{
+		{Transport: TransportLocal, Blocks: 1, Bytes: size(root)}:       true,
+		{Peer: p, Transport: "quic-v1", Blocks: 1, Bytes: size(child1)}: true,
+		{Transport: TransportUnknown, Blocks: 1, Bytes: size(child2)}:   true,
+	}
+	if len(report.Sources) != len(expected) {
+		t.Fatalf("unexpected sources %+v", report.Sources)
+	}
+	for _, s := range report.Sources {
+		if !expected[s] {
+			t.Fatalf("unexpected source %+v", s)
+		}
+	}
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	if _, err := Load(ctx, ds, root.Cid()); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := Save(ctx, ds, report); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(ctx, ds, root.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Cid.Equals(root.Cid()) || loaded.Bytes != report.Bytes || len(loaded.Sources) != 3 {
+		t.Fatalf("unexpected loaded report %+v", loaded)
+	}
+}
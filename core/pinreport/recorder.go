@@ -0,0 +1,103 @@
+package pinreport
+
+import (
+	"sync"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// DefaultRecentBlocks is the number of received blocks whose origin a
+// Recorder remembers.
+const DefaultRecentBlocks = 1 << 16
+
+type receipt struct {
+	peer      peer.ID
+	transport string
+}
+
+// Recorder is a bitswap tracer remembering which peer sent the recently
+// received blocks, and over which transport.
+type Recorder struct {
+	host host.Host
+
+	lk     sync.Mutex
+	recent map[cid.Cid]receipt
+	order  []cid.Cid
+	next   int
+}
+
+// NewRecorder creates a Recorder remembering the origin of the last size
+// blocks received.
+func NewRecorder(h host.Host, size int) *Recorder {
+	return &Recorder{
+		host:   h,
+		recent: make(map[cid.Cid]receipt, size),
+		order:  make([]cid.Cid, size),
+	}
+}
+
+// MessageReceived implements tracer.Tracer.
+func (r *Recorder) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	blks := msg.Blocks()
+	if len(blks) == 0 {
+		return
+	}
+	rc := receipt{peer: p, transport: Transport(r.host, p)}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	for _, b := range blks {
+		c := b.Cid()
+		if _, ok := r.recent[c]; !ok {
+			if old := r.order[r.next]; old.Defined() {
+				delete(r.recent, old)
+			}
+			r.order[r.next] = c
+			r.next = (r.next + 1) % len(r.order)
+		}
+		r.recent[c] = rc
+	}
+}
+
+// MessageSent implements tracer.Tracer.
+func (r *Recorder) MessageSent(peer.ID, bsmsg.BitSwapMessage) {}
+
+func (r *Recorder) lookup(c cid.Cid) (receipt, bool) {
+	if r == nil {
+		return receipt{}, false
+	}
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	rc, ok := r.recent[c]
+	return rc, ok
+}
+
+// Transport names the transport of the connection of h to p, e.g. "tcp",
+// "quic-v1", "webtransport" or "p2p-circuit" for relayed connections.
+func Transport(h host.Host, p peer.ID) string {
+	conns := h.Network().ConnsToPeer(p)
+	if len(conns) == 0 {
+		return TransportUnknown
+	}
+	return transportName(conns[0].RemoteMultiaddr())
+}
+
+func transportName(addr ma.Multiaddr) string {
+	name := TransportUnknown
+	for _, proto := range addr.Protocols() {
+		switch proto.Code {
+		case ma.P_CIRCUIT:
+			return proto.Name
+		case ma.P_IP4, ma.P_IP6, ma.P_DNS, ma.P_DNS4, ma.P_DNS6, ma.P_DNSADDR,
+			ma.P_P2P, ma.P_CERTHASH, ma.P_SNI, ma.P_TLS:
+			// not a transport, or a layer of the one that follows
+		default:
+			name = proto.Name
+		}
+	}
+	return name
+}
@@ -0,0 +1,196 @@
+package pinsync
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// ibltHashes is the number of cells each key is added to.
+const ibltHashes = 3
+
+// cellSize is the size of an encoded cell: the count, and the sums of the
+// keys and of their checksums.
+const cellSize = 24
+
+// errUndecodable is returned when the difference between two sets is too
+// large for the size of their IBLTs.
+var errUndecodable = errors.New("the IBLT cannot be decoded")
+
+type cell struct {
+	count   int64
+	keySum  uint64
+	hashSum uint64
+}
+
+// iblt is an invertible Bloom lookup table of 64-bit keys. The difference
+// of the IBLTs of two sets lists the keys that are in only one of them, as
+// long as there are few enough of them for the size of the table.
+type iblt []cell
+
+// newIBLT returns an empty IBLT of at least n cells.
+func newIBLT(n int) iblt {
+	n = (n + ibltHashes - 1) / ibltHashes * ibltHashes
+	if n < ibltHashes {
+		n = ibltHashes
+	}
+	return make(iblt, n)
+}
+
+// mix is the finalizer of SplitMix64, used to derive the hashes of a key.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func checksum(key uint64) uint64 {
+	return mix(key ^ 0x9e3779b97f4a7c15)
+}
+
+// update adds count to the cells of key. Each hash maps the key to its own
+// part of the table, so the cells of a key are distinct.
+func (t iblt) update(key uint64, count int64) {
+	part := uint64(len(t) / ibltHashes)
+	h := checksum(key)
+	for i := uint64(0); i < ibltHashes; i++ {
+		c := &t[i*part+mix(key+i)%part]
+		c.count += count
+		c.keySum ^= key
+		c.hashSum ^= h
+	}
+}
+
+func (t iblt) add(key uint64) {
+	t.update(key, 1)
+}
+
+// subtract removes the keys of o, of the same size, from t.
+func (t iblt) subtract(o iblt) {
+	for i := range t {
+		t[i].count -= o[i].count
+		t[i].keySum ^= o[i].keySum
+		t[i].hashSum ^= o[i].hashSum
+	}
+}
+
+// decode lists the keys with a positive count (only in the set of t when o
+// was subtracted from t) and those with a negative count (only in o). The
+// table is emptied.
+func (t iblt) decode() (positive, negative []uint64, err error) {
+	pure := func(c cell) bool {
+		return (c.count == 1 || c.count == -1) && c.hashSum == checksum(c.keySum)
+	}
+
+	var queue []int
+	for i := range t {
+		if pure(t[i]) {
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		i := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		c := t[i]
+		if !pure(c) {
+			continue // peeled since it was queued
+		}
+		if c.count > 0 {
+			positive = append(positive, c.keySum)
+		} else {
+			negative = append(negative, c.keySum)
+		}
+
+		t.update(c.keySum, -c.count)
+		part := len(t) / ibltHashes
+		for j := 0; j < ibltHashes; j++ {
+			k := j*part + int(mix(c.keySum+uint64(j))%uint64(part))
+			if pure(t[k]) {
+				queue = append(queue, k)
+			}
+		}
+	}
+
+	for _, c := range t {
+		if c != (cell{}) {
+			return nil, nil, errUndecodable
+		}
+	}
+	return positive, negative, nil
+}
+
+func (t iblt) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, len(t)*cellSize)
+	for i, c := range t {
+		b := buf[i*cellSize:]
+		binary.BigEndian.PutUint64(b, uint64(c.count))
+		binary.BigEndian.PutUint64(b[8:], c.keySum)
+		binary.BigEndian.PutUint64(b[16:], c.hashSum)
+	}
+	return buf, nil
+}
+
+func (t *iblt) UnmarshalBinary(buf []byte) error {
+	if len(buf)%cellSize != 0 || len(buf)/cellSize%ibltHashes != 0 {
+		return errors.New("invalid IBLT size")
+	}
+	*t = make(iblt, len(buf)/cellSize)
+	for i := range *t {
+		b := buf[i*cellSize:]
+		(*t)[i] = cell{
+			count:   int64(binary.BigEndian.Uint64(b)),
+			keySum:  binary.BigEndian.Uint64(b[8:]),
+			hashSum: binary.BigEndian.Uint64(b[16:]),
+		}
+	}
+	return nil
+}
+
+// sketchSize is the number of hashes kept by sketches.
+const sketchSize = 256
+
+// sketch returns the bottom-k MinHash sketch of a set of keys: the smallest
+// hashes of its keys, in increasing order.
+func sketch(keys map[uint64]Pin) []uint64 {
+	hashes := make([]uint64, 0, len(keys))
+	for k := range keys {
+		hashes = append(hashes, mix(k))
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	if len(hashes) > sketchSize {
+		hashes = hashes[:sketchSize]
+	}
+	return hashes
+}
+
+// estimateDifference estimates the size of the symmetric difference of two
+// sets of sizes na and nb from their sketches.
+func estimateDifference(na int, a []uint64, nb int, b []uint64) int {
+	if na == 0 || nb == 0 {
+		return na + nb
+	}
+
+	// the smallest hashes of the union, and how many are in both sets
+	var union, both int
+	i, j := 0, 0
+	for union < sketchSize && (i < len(a) || j < len(b)) {
+		switch {
+		case j == len(b) || (i < len(a) && a[i] < b[j]):
+			i++
+		case i == len(a) || b[j] < a[i]:
+			j++
+		default:
+			i++
+			j++
+			both++
+		}
+		union++
+	}
+
+	// with a Jaccard index J, the difference is (|A| + |B|)(1 - J)/(1 + J)
+	jaccard := float64(both) / float64(union)
+	return int(float64(na+nb)*(1-jaccard)/(1+jaccard) + 0.5)
+}
@@ -0,0 +1,318 @@
+// Package pinsync reconciles the pinsets of two nodes: it finds which pins
+// each node is missing without transferring the full pin lists.
+//
+// The nodes first exchange MinHash sketches of their pinsets to estimate how
+// many pins differ, then the remote node sends an invertible Bloom lookup
+// table (IBLT) of its pinset, sized for that difference. Subtracting the
+// local IBLT lists the keys of the pins only one of the nodes has, and the
+// remote node resolves the keys of its pins to CIDs. IBLTs that are too small
+// to be decoded are requested again, twice as large.
+package pinsync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+var log = logging.Logger("core/pinsync")
+
+// ProtocolID is the protocol of pinset reconciliation.
+const ProtocolID protocol.ID = "/kubo/pinsync/1.0.0"
+
+const (
+	// MinCells and MaxCells bound the size of the IBLTs exchanged.
+	MinCells = 48
+	MaxCells = 1 << 18
+
+	// MaxPins is the maximum number of pins resolved per request.
+	MaxPins = 4096
+
+	requestTimeout = time.Minute
+)
+
+// ErrTooManyDifferences is returned when the pinsets differ by more pins than
+// the largest IBLT can list.
+var ErrTooManyDifferences = errors.New("the pinsets differ too much to be reconciled")
+
+// Pin is a pinned CID.
+type Pin struct {
+	Cid       cid.Cid
+	Recursive bool
+}
+
+// Diff is the difference between the local pinset and the pinset of a peer.
+type Diff struct {
+	// Missing are the pins of the peer that aren't pinned locally.
+	Missing []Pin
+	// Extra are the local pins the peer doesn't have.
+	Extra []Pin
+	// Cells is the size of the IBLT that was decoded.
+	Cells int
+}
+
+const (
+	opSummary = "summary"
+	opIBLT    = "iblt"
+	opPins    = "pins"
+)
+
+type request struct {
+	Op    string
+	Cells int      `json:",omitempty"`
+	Keys  []uint64 `json:",omitempty"`
+}
+
+type response struct {
+	Error  string   `json:",omitempty"`
+	Count  int      `json:",omitempty"`
+	Sketch []uint64 `json:",omitempty"`
+	IBLT   []byte   `json:",omitempty"`
+	Pins   []Pin    `json:",omitempty"`
+}
+
+// Service answers the reconciliation requests of the peers allowed, and
+// reconciles the local pinset with the pinsets of peers.
+type Service struct {
+	host   host.Host
+	pinner pin.Pinner
+	allow  func(peer.ID) bool
+}
+
+// New registers the reconciliation protocol on h. Only the peers for which
+// allow returns true can read the pinset of the node.
+func New(h host.Host, pinner pin.Pinner, allow func(peer.ID) bool) *Service {
+	s := &Service{host: h, pinner: pinner, allow: allow}
+	h.SetStreamHandler(ProtocolID, s.handle)
+	return s
+}
+
+// Close unregisters the reconciliation protocol.
+func (s *Service) Close() error {
+	s.host.RemoveStreamHandler(ProtocolID)
+	return nil
+}
+
+// key is the key of a pin in the IBLTs. CIDv0 and CIDv1 of the same content
+// have the same key.
+func key(c cid.Cid) uint64 {
+	if c.Version() == 0 {
+		c = cid.NewCidV1(c.Type(), c.Hash())
+	}
+	h := sha256.Sum256(c.Bytes())
+	return binary.BigEndian.Uint64(h[:8])
+}
+
+// pins returns the recursive and direct pins of the node, by key.
+func (s *Service) pins(ctx context.Context) (map[uint64]Pin, error) {
+	pins := make(map[uint64]Pin)
+	for _, recursive := range []bool{true, false} {
+		keys := s.pinner.DirectKeys
+		if recursive {
+			keys = s.pinner.RecursiveKeys
+		}
+		for p := range keys(ctx, false) {
+			if p.Err != nil {
+				return nil, p.Err
+			}
+			pins[key(p.Pin.Key)] = Pin{Cid: p.Pin.Key, Recursive: recursive}
+		}
+	}
+	return pins, nil
+}
+
+func (s *Service) handle(st network.Stream) {
+	defer st.Close()
+
+	remote := st.Conn().RemotePeer()
+	if !s.allow(remote) {
+		log.Debugf("refusing to reconcile pinsets with %s", remote)
+		_ = st.Reset()
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dec := json.NewDecoder(st)
+	enc := json.NewEncoder(st)
+	var pins map[uint64]Pin
+	for {
+		_ = st.SetDeadline(time.Now().Add(requestTimeout))
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			return // the peer is done
+		}
+
+		// the pinset is read once per stream, so that all the answers are
+		// consistent
+		if pins == nil {
+			var err error
+			if pins, err = s.pins(ctx); err != nil {
+				_ = enc.Encode(&response{Error: err.Error()})
+				return
+			}
+		}
+
+		resp, err := answer(&req, pins)
+		if err != nil {
+			resp = &response{Error: err.Error()}
+		}
+		if err := enc.Encode(resp); err != nil {
+			log.Debugf("answering %s: %s", remote, err)
+			return
+		}
+	}
+}
+
+func answer(req *request, pins map[uint64]Pin) (*response, error) {
+	switch req.Op {
+	case opSummary:
+		return &response{Count: len(pins), Sketch: sketch(pins)}, nil
+	case opIBLT:
+		if req.Cells < MinCells || req.Cells > MaxCells {
+			return nil, fmt.Errorf("invalid IBLT size %d", req.Cells)
+		}
+		t := newIBLT(req.Cells)
+		for k := range pins {
+			t.add(k)
+		}
+		buf, err := t.MarshalBinary()
+		return &response{IBLT: buf}, err
+	case opPins:
+		if len(req.Keys) > MaxPins {
+			return nil, fmt.Errorf("too many pins requested, the maximum is %d", MaxPins)
+		}
+		resp := &response{Pins: make([]Pin, 0, len(req.Keys))}
+		for _, k := range req.Keys {
+			if p, ok := pins[k]; ok {
+				resp.Pins = append(resp.Pins, p)
+			}
+		}
+		return resp, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q", req.Op)
+	}
+}
+
+// client is a reconciliation stream opened to a peer.
+type client struct {
+	st  network.Stream
+	enc *json.Encoder
+	dec *json.Decoder
+}
+
+func (c *client) call(req *request) (*response, error) {
+	_ = c.st.SetDeadline(time.Now().Add(requestTimeout))
+	if err := c.enc.Encode(req); err != nil {
+		return nil, err
+	}
+	var resp response
+	if err := c.dec.Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+// Reconcile compares the local pinset with the pinset of p.
+func (s *Service) Reconcile(ctx context.Context, p peer.ID) (*Diff, error) {
+	local, err := s.pins(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	st, err := s.host.NewStream(ctx, p, ProtocolID)
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+	go func() {
+		<-ctx.Done()
+		_ = st.Reset()
+	}()
+	c := &client{st: st, enc: json.NewEncoder(st), dec: json.NewDecoder(st)}
+
+	summary, err := c.call(&request{Op: opSummary})
+	if err != nil {
+		return nil, fmt.Errorf("reading the pinset summary of %s: %w", p, err)
+	}
+	if summary.Count == 0 && len(local) == 0 {
+		return &Diff{}, nil
+	}
+
+	// IBLTs with about twice as many cells as differences decode reliably
+	estimate := estimateDifference(summary.Count, summary.Sketch, len(local), sketch(local))
+	cells := 2 * estimate
+	if cells < MinCells {
+		cells = MinCells
+	}
+	for {
+		if cells > MaxCells {
+			cells = MaxCells
+		}
+		resp, err := c.call(&request{Op: opIBLT, Cells: cells})
+		if err != nil {
+			return nil, fmt.Errorf("reading the IBLT of %s: %w", p, err)
+		}
+		var remote iblt
+		if err := remote.UnmarshalBinary(resp.IBLT); err != nil {
+			return nil, err
+		}
+		t := newIBLT(cells)
+		if len(remote) != len(t) {
+			return nil, fmt.Errorf("%s sent an IBLT of %d cells instead of %d", p, len(remote), len(t))
+		}
+		for k := range local {
+			t.add(k)
+		}
+		remote.subtract(t)
+
+		missing, extra, err := remote.decode()
+		if errors.Is(err, errUndecodable) && cells == MaxCells {
+			return nil, ErrTooManyDifferences
+		}
+		if errors.Is(err, errUndecodable) {
+			log.Debugf("IBLT of %d cells too small to reconcile with %s, %d differences estimated", cells, p, estimate)
+			cells *= 2
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		diff := &Diff{Cells: len(t)}
+		for _, k := range extra {
+			diff.Extra = append(diff.Extra, local[k])
+		}
+		for len(missing) > 0 {
+			n := len(missing)
+			if n > MaxPins {
+				n = MaxPins
+			}
+			resp, err := c.call(&request{Op: opPins, Keys: missing[:n]})
+			if err != nil {
+				return nil, fmt.Errorf("reading the pins of %s: %w", p, err)
+			}
+			diff.Missing = append(diff.Missing, resp.Pins...)
+			missing = missing[n:]
+		}
+		return diff, nil
+	}
+}
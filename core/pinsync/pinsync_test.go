@@ -0,0 +1,173 @@
+package pinsync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/ipfs/boxo/pinning/pinner/dspinner"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func TestIBLT(t *testing.T) {
+	a, b := newIBLT(120), newIBLT(120)
+	for k := uint64(0); k < 1000; k++ {
+		a.add(k)
+		b.add(k)
+	}
+	for k := uint64(1000); k < 1020; k++ {
+		a.add(k)
+	}
+	for k := uint64(2000); k < 2015; k++ {
+		b.add(k)
+	}
+
+	buf, err := a.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded iblt
+	if err := decoded.UnmarshalBinary(buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded.subtract(b)
+	positive, negative, err := decoded.decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positive) != 20 || len(negative) != 15 {
+		t.Fatalf("expected 20 and 15 keys, got %v and %v", positive, negative)
+	}
+
+	// too many differences for the size of the table
+	small := newIBLT(9)
+	for k := uint64(0); k < 100; k++ {
+		small.add(k)
+	}
+	if _, _, err := small.decode(); err != errUndecodable {
+		t.Fatalf("expected errUndecodable, got %v", err)
+	}
+}
+
+func TestEstimateDifference(t *testing.T) {
+	a, b := make(map[uint64]Pin), make(map[uint64]Pin)
+	for k := uint64(0); k < 10000; k++ {
+		a[k], b[k] = Pin{}, Pin{}
+	}
+	for k := uint64(10000); k < 11000; k++ {
+		a[k] = Pin{}
+	}
+	estimate := estimateDifference(len(a), sketch(a), len(b), sketch(b))
+	if estimate < 500 || estimate > 2000 {
+		t.Fatalf("expected about 1000 differences, estimated %d", estimate)
+	}
+	if estimate := estimateDifference(len(a), sketch(a), len(a), sketch(a)); estimate != 0 {
+		t.Fatalf("expected no difference, estimated %d", estimate)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	mn := mocknet.New()
+	defer mn.Close()
+
+	newNode := func() (*Service, pin.Pinner, peer.ID) {
+		h, err := mn.GenPeer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dag := dagtest.Mock()
+		pinner, err := dspinner.New(ctx, dssync.MutexWrap(datastore.NewMapDatastore()), dag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := New(h, pinner, func(peer.ID) bool { return true })
+		t.Cleanup(func() { s.Close() })
+		return s, pinner, h.ID()
+	}
+	pinData := func(pinner pin.Pinner, data string, recursive bool) cid.Cid {
+		nd := merkledag.NodeWithData([]byte(data))
+		if err := pinner.Pin(ctx, nd, recursive, ""); err != nil {
+			t.Fatal(err)
+		}
+		return nd.Cid()
+	}
+
+	local, localPinner, _ := newNode()
+	_, remotePinner, remoteID := newNode()
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 500; i++ {
+		data := fmt.Sprint("shared ", i)
+		pinData(localPinner, data, true)
+		pinData(remotePinner, data, true)
+	}
+	var extra, missing []string
+	for i := 0; i < 30; i++ {
+		extra = append(extra, pinData(localPinner, fmt.Sprint("local ", i), true).String())
+	}
+	for i := 0; i < 70; i++ {
+		missing = append(missing, pinData(remotePinner, fmt.Sprint("remote ", i), i%2 == 0).String())
+	}
+
+	diff, err := local.Reconcile(ctx, remoteID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectPins := func(what string, pins []Pin, expected []string) {
+		t.Helper()
+		var got []string
+		for _, p := range pins {
+			got = append(got, p.Cid.String())
+		}
+		sort.Strings(got)
+		sort.Strings(expected)
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Fatalf("expected %s %v, got %v", what, expected, got)
+		}
+	}
+	expectPins("missing", diff.Missing, missing)
+	expectPins("extra", diff.Extra, extra)
+	for _, p := range diff.Missing {
+		if !p.Recursive {
+			return
+		}
+	}
+	t.Fatal("expected direct pins among the missing pins")
+}
+
+func TestReconcileRefused(t *testing.T) {
+	ctx := context.Background()
+	mn := mocknet.New()
+	defer mn.Close()
+
+	var services []*Service
+	for i := 0; i < 2; i++ {
+		h, err := mn.GenPeer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pinner, err := dspinner.New(ctx, dssync.MutexWrap(datastore.NewMapDatastore()), dagtest.Mock())
+		if err != nil {
+			t.Fatal(err)
+		}
+		services = append(services, New(h, pinner, func(peer.ID) bool { return false }))
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := services[0].Reconcile(ctx, services[1].host.ID()); err == nil {
+		t.Fatal("expected the peer to refuse reconciling pinsets")
+	}
+}
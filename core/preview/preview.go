@@ -0,0 +1,254 @@
+// Package preview generates the previews of files: thumbnails of images, and
+// snippets of text files. Previews are cached by CID in the repo datastore.
+package preview
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif" // registers the decoders of image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	format "github.com/ipfs/go-ipld-format"
+
+	"github.com/ipfs/kubo/core/mimetypes"
+)
+
+// DatastoreKey is the key the previews are cached under.
+var DatastoreKey = datastore.NewKey("/local/previews")
+
+const (
+	// ThumbnailSize is the maximum width and height of thumbnails.
+	ThumbnailSize = 256
+	// MaxImageSize is the size of the largest image files thumbnails are
+	// made of.
+	MaxImageSize = 16 << 20
+	// MaxImagePixels is the number of pixels of the largest images
+	// thumbnails are made of, to not decode images too large for memory.
+	MaxImagePixels = 40_000_000
+	// SnippetLength is the maximum number of characters of snippets.
+	SnippetLength = 280
+
+	// snippetRead is the number of bytes of text files snippets are made
+	// of.
+	snippetRead = 4 << 10
+	// samples is the maximum number of pixels sampled in each direction to
+	// make a pixel of a thumbnail.
+	samples = 4
+)
+
+// Preview is the preview of a file. Files that aren't images or text have no
+// thumbnail or snippet.
+type Preview struct {
+	MimeType string
+	// Thumbnail is a JPEG image at most ThumbnailSize wide and high.
+	Thumbnail []byte `json:",omitempty"`
+	// Width and Height are the dimensions of the original image.
+	Width  int `json:",omitempty"`
+	Height int `json:",omitempty"`
+	// Snippet is the start of a text file, with its whitespace collapsed.
+	Snippet string `json:",omitempty"`
+}
+
+// Empty returns whether p has neither a thumbnail nor a snippet.
+func (p *Preview) Empty() bool {
+	return len(p.Thumbnail) == 0 && p.Snippet == ""
+}
+
+// Generator makes the previews of the files stored locally, and caches them.
+type Generator struct {
+	ds        datastore.Datastore
+	dag       format.DAGService
+	mimeTypes *mimetypes.Cache
+
+	// sem limits the number of images decoded at the same time
+	sem chan struct{}
+}
+
+// New returns a generator caching previews in ds. Files are read through dag,
+// which should not fetch missing blocks from the network.
+func New(ds datastore.Datastore, dag format.DAGService, mimeTypes *mimetypes.Cache) *Generator {
+	return &Generator{
+		ds:        ds,
+		dag:       dag,
+		mimeTypes: mimeTypes,
+		sem:       make(chan struct{}, runtime.NumCPU()),
+	}
+}
+
+// Preview returns the preview of the file c, making it on the first request.
+// Previews are also cached for the CIDs that aren't files, or can't be
+// previewed, and are then empty.
+func (g *Generator) Preview(ctx context.Context, c cid.Cid) (*Preview, error) {
+	// CIDv0 and CIDv1 of the same content share their preview
+	k := DatastoreKey.ChildString(cid.NewCidV1(c.Type(), c.Hash()).String())
+	buf, err := g.ds.Get(ctx, k)
+	if err == nil {
+		p := new(Preview)
+		return p, json.Unmarshal(buf, p)
+	}
+	if !errors.Is(err, datastore.ErrNotFound) {
+		return nil, err
+	}
+
+	p, err := g.generate(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if buf, err = json.Marshal(p); err != nil {
+		return nil, err
+	}
+	return p, g.ds.Put(ctx, k, buf)
+}
+
+func (g *Generator) generate(ctx context.Context, c cid.Cid) (*Preview, error) {
+	mimeType, err := g.mimeTypes.MimeType(ctx, g.dag, c)
+	if err != nil {
+		return nil, err
+	}
+	p := &Preview{MimeType: mimeType}
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		err = g.thumbnail(ctx, c, p)
+	case strings.HasPrefix(mimeType, "text/"):
+		err = g.snippet(ctx, c, p)
+	}
+	return p, err
+}
+
+// read returns the first n bytes of the file c, and whether the file is
+// larger.
+func (g *Generator) read(ctx context.Context, c cid.Cid, n int64) ([]byte, bool, error) {
+	nd, err := g.dag.Get(ctx, c)
+	if err != nil {
+		return nil, false, err
+	}
+	r, err := uio.NewDagReader(ctx, nd, g.dag)
+	if err != nil {
+		return nil, false, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, n))
+	if err != nil {
+		return nil, false, err
+	}
+	return data, r.Size() > uint64(n), nil
+}
+
+func (g *Generator) thumbnail(ctx context.Context, c cid.Cid, p *Preview) error {
+	data, truncated, err := g.read(ctx, c, MaxImageSize)
+	if err != nil || truncated {
+		return err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width*cfg.Height > MaxImagePixels {
+		return nil // a format that isn't supported, or too large
+	}
+
+	select {
+	case g.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-g.sem }()
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil // a corrupted image
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scale(img, ThumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return err
+	}
+	p.Thumbnail = buf.Bytes()
+	p.Width, p.Height = cfg.Width, cfg.Height
+	return nil
+}
+
+// scale returns img fit in a size x size square. Each pixel is the average of
+// at most samples x samples pixels of the area of img it covers.
+func scale(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w > h {
+			w, h = size, atLeast(h*size/w, 1)
+		} else {
+			w, h = atLeast(w*size/h, 1), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+			dst.Set(x, y, average(img, x0, y0, atLeast(x1, x0+1), atLeast(y1, y0+1)))
+		}
+	}
+	return dst
+}
+
+// average returns the average color of samples of the pixels of img in the
+// [x0, x1) x [y0, y1) area, on a white background.
+func average(img image.Image, x0, y0, x1, y1 int) color.Color {
+	stepX, stepY := atLeast((x1-x0)/samples, 1), atLeast((y1-y0)/samples, 1)
+	var r, g, b, n uint64
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			pr, pg, pb, pa := img.At(x, y).RGBA()
+			// alpha-premultiplied, the background shows through
+			r += uint64(pr + 0xffff - pa)
+			g += uint64(pg + 0xffff - pa)
+			b += uint64(pb + 0xffff - pa)
+			n++
+		}
+	}
+	return color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: 0xffff}
+}
+
+func atLeast(n, min int) int {
+	if n < min {
+		return min
+	}
+	return n
+}
+
+func (g *Generator) snippet(ctx context.Context, c cid.Cid, p *Preview) error {
+	data, truncated, err := g.read(ctx, c, snippetRead)
+	if err != nil {
+		return err
+	}
+	if truncated {
+		// don't keep a character cut in the middle
+		for i := 0; i < utf8.UTFMax && len(data) > 0; i++ {
+			if r, size := utf8.DecodeLastRune(data); r != utf8.RuneError || size != 1 {
+				break
+			}
+			data = data[:len(data)-1]
+		}
+	}
+
+	text := strings.Join(strings.Fields(strings.ToValidUTF8(string(data), "")), " ")
+	if utf8.RuneCountInString(text) > SnippetLength {
+		runes := []rune(text)
+		text = strings.TrimSpace(string(runes[:SnippetLength-1])) + "…"
+		truncated = false
+	}
+	if truncated {
+		text += "…"
+	}
+	p.Snippet = text
+	return nil
+}
@@ -0,0 +1,88 @@
+package preview
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	format "github.com/ipfs/go-ipld-format"
+
+	"github.com/ipfs/kubo/core/mimetypes"
+)
+
+func TestPreview(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	dag := dagtest.Mock()
+	g := New(ds, dag, mimetypes.New(ds))
+
+	img := image.NewRGBA(image.Rect(0, 0, 600, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 600; x++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	picture := merkledag.NewRawNode(buf.Bytes())
+	text := merkledag.NewRawNode([]byte(strings.Repeat("lorem   ipsum\n", 100)))
+	dir := ft.EmptyDirNode()
+	if err := dag.AddMany(ctx, []format.Node{picture, text, dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := g.Preview(ctx, picture.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.MimeType != "image/png" || p.Width != 600 || p.Height != 300 {
+		t.Fatalf("unexpected preview %+v", p)
+	}
+	thumbnail, err := jpeg.Decode(bytes.NewReader(p.Thumbnail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := thumbnail.Bounds(); b.Dx() != ThumbnailSize || b.Dy() != ThumbnailSize/2 {
+		t.Fatalf("unexpected thumbnail size %v", b)
+	}
+	if red, green, blue, _ := thumbnail.At(10, 10).RGBA(); red < 0xf000 || green > 0x1000 || blue > 0x1000 {
+		t.Fatalf("expected a red thumbnail, got %v", thumbnail.At(10, 10))
+	}
+
+	p, err = g.Preview(ctx, text.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(p.Snippet, "lorem ipsum lorem ipsum") || !strings.HasSuffix(p.Snippet, "…") || utf8.RuneCountInString(p.Snippet) > SnippetLength {
+		t.Fatalf("unexpected snippet %q", p.Snippet)
+	}
+
+	p, err = g.Preview(ctx, dir.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Empty() {
+		t.Fatalf("expected no preview of a directory, got %+v", p)
+	}
+
+	// previews are cached
+	if err := dag.Remove(ctx, picture.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if p, err = g.Preview(ctx, picture.Cid()); err != nil || len(p.Thumbnail) == 0 {
+		t.Fatalf("expected the cached preview, got %+v, %v", p, err)
+	}
+}
@@ -0,0 +1,407 @@
+// Package provenance records which peer sent the blocks received by Bitswap,
+// and when, to investigate the peers sending bad blocks and to credit the
+// peers providing the content. The records are kept in a bounded namespace of
+// the datastore: once full, the oldest records are replaced.
+package provenance
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/ipfs/kubo/core/pinreport"
+)
+
+var log = logging.Logger("core/provenance")
+
+var (
+	// DatastoreKey is the namespace of the records.
+	DatastoreKey = datastore.NewKey("/local/provenance")
+
+	nextKey  = DatastoreKey.ChildString("next")
+	slotsKey = DatastoreKey.ChildString("slots")
+	cidsKey  = DatastoreKey.ChildString("cids")
+)
+
+// ErrNotFound is returned for the blocks without a record.
+var ErrNotFound = errors.New("no provenance recorded for this block")
+
+const (
+	// queueSize is the number of records waiting to be written, the records
+	// received when it is full are dropped.
+	queueSize = 1024
+	// maxBatch is the number of records written at once.
+	maxBatch = 128
+)
+
+// Record is the provenance of a block.
+type Record struct {
+	Cid       cid.Cid
+	Peer      peer.ID
+	Transport string
+	Received  time.Time
+}
+
+// PeerRecords counts the records of the blocks sent by a peer.
+type PeerRecords struct {
+	Peer   peer.ID
+	Blocks int
+	// Last is when the last block recorded was received from the peer.
+	Last time.Time
+}
+
+// slot is a record, stored at its sequence number modulo the maximum number
+// of records.
+type slot struct {
+	Seq uint64
+	Record
+}
+
+// Options are the settings of a Recorder.
+type Options struct {
+	// Sampling records one in Sampling of the blocks received, all of them
+	// when 1.
+	Sampling int
+	// MaxRecords bounds the number of records.
+	MaxRecords int
+}
+
+// Recorder is a Bitswap tracer recording the provenance of the blocks
+// received.
+type Recorder struct {
+	ds       datastore.Batching
+	host     host.Host
+	sampling uint64
+	max      uint64
+
+	received atomic.Uint64
+	queue    chan Record
+	dropped  atomic.Uint64
+
+	// lk serializes the writes of the records and the purges
+	lk   sync.Mutex
+	next uint64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New returns a recorder storing its records in ds. The records are written
+// once Start is called.
+func New(ctx context.Context, ds datastore.Batching, h host.Host, opts Options) (*Recorder, error) {
+	if opts.Sampling < 1 {
+		return nil, errors.New("the sampling of the provenance must be at least 1")
+	}
+	if opts.MaxRecords < 1 {
+		return nil, errors.New("the provenance needs room for at least one record")
+	}
+	r := &Recorder{
+		ds:       ds,
+		host:     h,
+		sampling: uint64(opts.Sampling),
+		max:      uint64(opts.MaxRecords),
+		queue:    make(chan Record, queueSize),
+	}
+	buf, err := ds.Get(ctx, nextKey)
+	switch err {
+	case nil:
+		next, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return nil, errors.New("corrupted provenance sequence number")
+		}
+		r.next = next
+	case datastore.ErrNotFound:
+	default:
+		return nil, err
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	return r, nil
+}
+
+// MessageReceived implements tracer.Tracer.
+func (r *Recorder) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	blks := msg.Blocks()
+	if len(blks) == 0 {
+		return
+	}
+	var transport string
+	now := time.Now()
+	for _, b := range blks {
+		if (r.received.Add(1)-1)%r.sampling != 0 {
+			continue
+		}
+		if transport == "" {
+			transport = pinreport.Transport(r.host, p)
+		}
+		select {
+		case r.queue <- Record{Cid: b.Cid(), Peer: p, Transport: transport, Received: now}:
+		default:
+			// Bitswap isn't slowed down by the datastore
+			r.dropped.Add(1)
+		}
+	}
+}
+
+// MessageSent implements tracer.Tracer.
+func (r *Recorder) MessageSent(peer.ID, bsmsg.BitSwapMessage) {}
+
+// Start writes the records until Close.
+func (r *Recorder) Start() {
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		for {
+			var batch []Record
+			select {
+			case rec := <-r.queue:
+				batch = append(batch, rec)
+			case <-r.ctx.Done():
+				return
+			}
+		fill:
+			for len(batch) < maxBatch && uint64(len(batch)) < r.max {
+				select {
+				case rec := <-r.queue:
+					batch = append(batch, rec)
+				default:
+					break fill
+				}
+			}
+			if err := r.write(r.ctx, batch); err != nil && r.ctx.Err() == nil {
+				log.Errorf("recording the provenance of %d blocks: %s", len(batch), err)
+			}
+		}
+	}()
+}
+
+// Close stops writing the records, the records waiting are dropped.
+func (r *Recorder) Close() error {
+	r.cancel()
+	if r.done != nil {
+		<-r.done
+	}
+	return nil
+}
+
+func slotKey(n uint64) datastore.Key {
+	return slotsKey.ChildString(strconv.FormatUint(n, 10))
+}
+
+func cidKey(c cid.Cid) datastore.Key {
+	return cidsKey.ChildString(c.String())
+}
+
+// write records batch, replacing the oldest records once full. The blocks
+// already recorded keep their first record.
+func (r *Recorder) write(ctx context.Context, batch []Record) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	b, err := r.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	next := r.next
+	seen := make(map[cid.Cid]struct{}, len(batch))
+	for _, rec := range batch {
+		if _, ok := seen[rec.Cid]; ok {
+			continue
+		}
+		seen[rec.Cid] = struct{}{}
+		if has, err := r.ds.Has(ctx, cidKey(rec.Cid)); err != nil {
+			return err
+		} else if has {
+			continue
+		}
+
+		key := slotKey(next % r.max)
+		old, err := r.getSlot(ctx, key)
+		switch err {
+		case nil:
+			if err := r.unindex(ctx, b, old); err != nil {
+				return err
+			}
+		case datastore.ErrNotFound:
+		default:
+			return err
+		}
+
+		buf, err := json.Marshal(slot{Seq: next, Record: rec})
+		if err != nil {
+			return err
+		}
+		if err := b.Put(ctx, key, buf); err != nil {
+			return err
+		}
+		if err := b.Put(ctx, cidKey(rec.Cid), []byte(strconv.FormatUint(next, 10))); err != nil {
+			return err
+		}
+		next++
+	}
+	if next == r.next {
+		return nil
+	}
+	if err := b.Put(ctx, nextKey, binary.AppendUvarint(nil, next)); err != nil {
+		return err
+	}
+	if err := b.Commit(ctx); err != nil {
+		return err
+	}
+	r.next = next
+	return nil
+}
+
+func (r *Recorder) getSlot(ctx context.Context, key datastore.Key) (slot, error) {
+	buf, err := r.ds.Get(ctx, key)
+	if err != nil {
+		return slot{}, err
+	}
+	var s slot
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return slot{}, err
+	}
+	return s, nil
+}
+
+// unindex deletes the index of the record s, unless the block was recorded
+// again since.
+func (r *Recorder) unindex(ctx context.Context, w datastore.Write, s slot) error {
+	buf, err := r.ds.Get(ctx, cidKey(s.Cid))
+	switch err {
+	case nil:
+	case datastore.ErrNotFound:
+		return nil
+	default:
+		return err
+	}
+	if string(buf) != strconv.FormatUint(s.Seq, 10) {
+		return nil
+	}
+	return w.Delete(ctx, cidKey(s.Cid))
+}
+
+// Get returns the record of the block c.
+func (r *Recorder) Get(ctx context.Context, c cid.Cid) (Record, error) {
+	buf, err := r.ds.Get(ctx, cidKey(c))
+	if err == datastore.ErrNotFound {
+		return Record{}, ErrNotFound
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	seq, err := strconv.ParseUint(string(buf), 10, 64)
+	if err != nil {
+		return Record{}, err
+	}
+	s, err := r.getSlot(ctx, slotKey(seq%r.max))
+	if err == datastore.ErrNotFound || (err == nil && (s.Seq != seq || !s.Cid.Equals(c))) {
+		return Record{}, ErrNotFound
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	return s.Record, nil
+}
+
+// each calls f with the records.
+func (r *Recorder) each(ctx context.Context, f func(key datastore.Key, s slot) error) error {
+	res, err := r.ds.Query(ctx, query.Query{Prefix: slotsKey.String()})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+	for e := range res.Next() {
+		if e.Error != nil {
+			return e.Error
+		}
+		var s slot
+		if err := json.Unmarshal(e.Value, &s); err != nil {
+			return err
+		}
+		if err := f(datastore.NewKey(e.Key), s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Peers counts the records of each peer, the peers with the most records
+// first.
+func (r *Recorder) Peers(ctx context.Context) ([]PeerRecords, error) {
+	counts := make(map[peer.ID]*PeerRecords)
+	err := r.each(ctx, func(_ datastore.Key, s slot) error {
+		pr, ok := counts[s.Peer]
+		if !ok {
+			pr = &PeerRecords{Peer: s.Peer}
+			counts[s.Peer] = pr
+		}
+		pr.Blocks++
+		if s.Received.After(pr.Last) {
+			pr.Last = s.Received
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PeerRecords, 0, len(counts))
+	for _, pr := range counts {
+		out = append(out, *pr)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Blocks != out[j].Blocks {
+			return out[i].Blocks > out[j].Blocks
+		}
+		return out[i].Peer < out[j].Peer
+	})
+	return out, nil
+}
+
+// Purge deletes the records of the blocks received before before, all of
+// them when zero, and only the records of p when set. It returns the number
+// of records deleted.
+func (r *Recorder) Purge(ctx context.Context, before time.Time, p peer.ID) (int, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	b, err := r.ds.Batch(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	err = r.each(ctx, func(key datastore.Key, s slot) error {
+		if (!before.IsZero() && !s.Received.Before(before)) || (p != "" && s.Peer != p) {
+			return nil
+		}
+		if err := r.unindex(ctx, b, s); err != nil {
+			return err
+		}
+		n++
+		return b.Delete(ctx, key)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, b.Commit(ctx)
+}
+
+// Dropped returns the number of records dropped because the datastore
+// couldn't keep up.
+func (r *Recorder) Dropped() uint64 {
+	return r.dropped.Load()
+}
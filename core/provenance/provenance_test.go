@@ -0,0 +1,132 @@
+package provenance
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/test"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func newTestRecorder(t *testing.T, ds datastore.Batching, opts Options) *Recorder {
+	mn := mocknet.New()
+	t.Cleanup(func() { mn.Close() })
+	h, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := New(context.Background(), ds, h, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// receive passes the blocks to r as sent by p, and writes their records.
+func receive(t *testing.T, r *Recorder, p peer.ID, blks ...blocks.Block) {
+	msg := bsmsg.New(false)
+	for _, b := range blks {
+		msg.AddBlock(b)
+	}
+	r.MessageReceived(p, msg)
+
+	var batch []Record
+	for len(r.queue) > 0 {
+		batch = append(batch, <-r.queue)
+	}
+	if err := r.write(context.Background(), batch); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testBlocks(n int) []blocks.Block {
+	var blks []blocks.Block
+	for i := 0; i < n; i++ {
+		blks = append(blks, blocks.NewBlock([]byte(fmt.Sprintf("block %d", i))))
+	}
+	return blks
+}
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	r := newTestRecorder(t, ds, Options{Sampling: 1, MaxRecords: 3})
+	blks := testBlocks(4)
+	p1, p2 := test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)
+
+	// the blocks of a message are recorded in any order
+	receive(t, r, p1, blks[0])
+	receive(t, r, p1, blks[1])
+	receive(t, r, p2, blks[2])
+	// a block keeps its first record
+	receive(t, r, p2, blks[0])
+
+	rec, err := r.Get(ctx, blks[0].Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Peer != p1 || rec.Transport == "" || time.Since(rec.Received) > time.Minute {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+
+	peers, err := r.Peers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 2 || peers[0].Peer != p1 || peers[0].Blocks != 2 || peers[1].Blocks != 1 {
+		t.Fatalf("unexpected peers %+v", peers)
+	}
+
+	// the oldest record is replaced once full, across restarts
+	r = newTestRecorder(t, ds, Options{Sampling: 1, MaxRecords: 3})
+	receive(t, r, p2, blks[3])
+	if _, err := r.Get(ctx, blks[0].Cid()); err != ErrNotFound {
+		t.Fatalf("expected the oldest record to be replaced, got %v", err)
+	}
+	for _, b := range blks[1:] {
+		if _, err := r.Get(ctx, b.Cid()); err != nil {
+			t.Fatalf("expected %s to be recorded: %s", b.Cid(), err)
+		}
+	}
+
+	n, err := r.Purge(ctx, time.Time{}, p2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected the 2 records of %s to be purged, got %d", p2, n)
+	}
+	if _, err := r.Get(ctx, blks[3].Cid()); err != ErrNotFound {
+		t.Fatalf("expected the record to be purged, got %v", err)
+	}
+	if n, err := r.Purge(ctx, time.Now().Add(-time.Hour), ""); err != nil || n != 0 {
+		t.Fatalf("expected the recent records to be kept, got %d, %v", n, err)
+	}
+	if n, err := r.Purge(ctx, time.Time{}, ""); err != nil || n != 1 {
+		t.Fatalf("expected the last record to be purged, got %d, %v", n, err)
+	}
+}
+
+func TestRecorderSampling(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRecorder(t, dssync.MutexWrap(datastore.NewMapDatastore()), Options{Sampling: 2, MaxRecords: 10})
+	blks := testBlocks(4)
+	receive(t, r, test.RandPeerIDFatal(t), blks...)
+
+	var recorded int
+	for _, b := range blks {
+		if _, err := r.Get(ctx, b.Cid()); err == nil {
+			recorded++
+		}
+	}
+	if recorded != 2 {
+		t.Fatalf("expected one in two blocks to be recorded, got %d", recorded)
+	}
+}
@@ -0,0 +1,375 @@
+// Package provideclass reprovides the DAGs of pins at the cadence of their
+// provide class, so that the load of the DHT concentrates on the content that
+// actually needs to be discoverable.
+//
+// The blocks of the DAGs of the pins with a class are left out of the
+// reprovides of Reprovider.Interval, see Exclude, and reprovided by a
+// Scheduler instead: hourly for "hot" pins, daily for "warm" pins, weekly for
+// "cold" pins, and never for "never" pins. A block shared by a pin with a
+// class and a pin without one follows the class.
+package provideclass
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	provider "github.com/ipfs/boxo/provider"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("core/provideclass")
+
+// The provide classes.
+const (
+	Hot   = "hot"
+	Warm  = "warm"
+	Cold  = "cold"
+	Never = "never"
+)
+
+// Intervals are the reprovide intervals of the classes. The pins of class
+// Never are not reprovided.
+var Intervals = map[string]time.Duration{
+	Hot:  time.Hour,
+	Warm: 24 * time.Hour,
+	Cold: 7 * 24 * time.Hour,
+}
+
+// DatastoreKey is the prefix under which the classes are stored, by CID.
+var DatastoreKey = datastore.NewKey("/local/provideclass")
+
+// DefaultInterval is the time between two looks for the pins due for a
+// reprovide.
+const DefaultInterval = time.Minute
+
+// ErrNotFound is returned for a pin without provide class.
+var ErrNotFound = errors.New("the pin has no provide class")
+
+// Entry is the provide class of a pin.
+type Entry struct {
+	Cid       cid.Cid
+	Class     string
+	Recursive bool
+	// Provided is when the DAG of the pin was last reprovided.
+	Provided time.Time `json:",omitempty"`
+}
+
+// due returns when the entry is due for a reprovide, and false for the
+// entries that are never reprovided.
+func (e Entry) due() (time.Time, bool) {
+	interval, ok := Intervals[e.Class]
+	if !ok {
+		return time.Time{}, false
+	}
+	return e.Provided.Add(interval), true
+}
+
+// Validate returns an error if class isn't a provide class.
+func Validate(class string) error {
+	switch class {
+	case Hot, Warm, Cold, Never:
+		return nil
+	default:
+		return fmt.Errorf("invalid provide class %q, must be one of {%s, %s, %s, %s}", class, Hot, Warm, Cold, Never)
+	}
+}
+
+// ProvideFunc announces a CID to the routing system.
+type ProvideFunc func(cid.Cid) error
+
+// Scheduler reprovides the DAGs of the pins with a class.
+type Scheduler struct {
+	ds        datastore.Datastore
+	getLinks  merkledag.GetLinks
+	provide   ProvideFunc
+	onlyRoots bool
+	clock     clock.Clock
+
+	// lk serializes the changes of the classes
+	lk sync.Mutex
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started atomic.Bool
+	done    chan struct{}
+	wake    chan struct{}
+}
+
+// New returns the scheduler of the classes stored in ds. The DAGs of the pins
+// are walked with getLinks, which must not fetch blocks, and announced with
+// provide, only their roots if onlyRoots is set.
+func New(ds datastore.Datastore, getLinks merkledag.GetLinks, provide ProvideFunc, onlyRoots bool, clk clock.Clock) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		ds:        namespace.Wrap(ds, DatastoreKey),
+		getLinks:  getLinks,
+		provide:   provide,
+		onlyRoots: onlyRoots,
+		clock:     clk,
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+func entryKey(c cid.Cid) datastore.Key {
+	return datastore.NewKey(c.String())
+}
+
+func (s *Scheduler) put(ctx context.Context, e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.ds.Put(ctx, entryKey(e.Cid), data)
+}
+
+// Set sets the class of the pin of c. A pin whose class changes is due for a
+// reprovide at the interval of its new class.
+func (s *Scheduler) Set(ctx context.Context, c cid.Cid, class string, recursive bool) (Entry, error) {
+	if err := Validate(class); err != nil {
+		return Entry{}, err
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	e := Entry{Cid: c, Class: class, Recursive: recursive}
+	if prev, err := s.Get(ctx, c); err == nil && prev.Class == class {
+		e.Provided = prev.Provided
+	} else if err != nil && !errors.Is(err, ErrNotFound) {
+		return Entry{}, err
+	}
+	if err := s.put(ctx, e); err != nil {
+		return Entry{}, err
+	}
+	s.notify()
+	return e, nil
+}
+
+// Get returns the class of the pin of c, or ErrNotFound.
+func (s *Scheduler) Get(ctx context.Context, c cid.Cid) (Entry, error) {
+	data, err := s.ds.Get(ctx, entryKey(c))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return Entry{}, ErrNotFound
+		}
+		return Entry{}, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Entry{}, err
+	}
+	return e, nil
+}
+
+// Clear clears the class of the pin of c, if any. Its DAG goes back to the
+// reprovides of Reprovider.Interval.
+func (s *Scheduler) Clear(ctx context.Context, c cid.Cid) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.ds.Delete(ctx, entryKey(c))
+}
+
+// Copy sets the class of the pin of from, if any, to the pin of to.
+func (s *Scheduler) Copy(ctx context.Context, from, to cid.Cid, recursive bool) error {
+	e, err := s.Get(ctx, from)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	_, err = s.Set(ctx, to, e.Class, recursive)
+	return err
+}
+
+// List returns the classes of the pins, by CID.
+func (s *Scheduler) List(ctx context.Context) ([]Entry, error) {
+	return list(ctx, s.ds)
+}
+
+func list(ctx context.Context, ds datastore.Datastore) ([]Entry, error) {
+	res, err := ds.Query(ctx, query.Query{})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var entries []Entry
+	for result := range res.Next() {
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		var e Entry
+		if err := json.Unmarshal(result.Value, &e); err != nil {
+			log.Errorf("invalid entry %s: %s", result.Key, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Cid.KeyString() < entries[j].Cid.KeyString()
+	})
+	return entries, nil
+}
+
+// walk calls visit with the CIDs of the DAG of e, or only its root if the
+// pin isn't recursive or onlyRoots is set.
+func walk(ctx context.Context, getLinks merkledag.GetLinks, e Entry, onlyRoots bool, visit func(cid.Cid) bool) error {
+	if !e.Recursive || onlyRoots {
+		visit(e.Cid)
+		return nil
+	}
+	return merkledag.Walk(ctx, getLinks, e.Cid, visit, merkledag.Concurrent())
+}
+
+// Reprovide announces the DAGs of the pins due for a reprovide at now. It
+// returns the number of pins reprovided.
+func (s *Scheduler) Reprovide(ctx context.Context, now time.Time) (int, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	entries, err := s.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, e := range entries {
+		due, ok := e.due()
+		if !ok || due.After(now) {
+			continue
+		}
+		var provideErr error
+		seen := cid.NewSet()
+		err := walk(ctx, s.getLinks, e, s.onlyRoots, func(c cid.Cid) bool {
+			if provideErr != nil || !seen.Visit(c) {
+				return false
+			}
+			provideErr = s.provide(c)
+			return provideErr == nil
+		})
+		if err == nil {
+			err = provideErr
+		}
+		if err != nil {
+			return n, fmt.Errorf("reproviding %s: %w", e.Cid, err)
+		}
+		e.Provided = now
+		if err := s.put(ctx, e); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start reprovides the pins due for a reprovide in the background, every
+// interval and when a class is set, until Close is called.
+func (s *Scheduler) Start(interval time.Duration) {
+	s.started.Store(true)
+	go func() {
+		defer close(s.done)
+		t := s.clock.Timer(0)
+		defer t.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-t.C:
+			case <-s.wake:
+				if !t.Stop() {
+					<-t.C
+				}
+			}
+
+			n, err := s.Reprovide(s.ctx, s.clock.Now())
+			if n > 0 {
+				log.Infof("reprovided %d pins", n)
+			}
+			if err != nil {
+				log.Error(err)
+			}
+			t.Reset(interval)
+		}
+	}()
+}
+
+// Close stops the background reprovides started by Start.
+func (s *Scheduler) Close() error {
+	s.cancel()
+	if s.started.Load() {
+		<-s.done
+	}
+	return nil
+}
+
+// Exclude returns the keys of keys, but for the blocks of the DAGs of the pins
+// with a class stored in ds, which are walked with getLinks. The reprovider
+// announces the keys it returns every Reprovider.Interval, and the Scheduler
+// the others.
+func Exclude(ds datastore.Datastore, getLinks merkledag.GetLinks, keys provider.KeyChanFunc) provider.KeyChanFunc {
+	ds = namespace.Wrap(ds, DatastoreKey)
+	return func(ctx context.Context) (<-chan cid.Cid, error) {
+		entries, err := list(ctx, ds)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			return keys(ctx)
+		}
+
+		classed := cid.NewSet()
+		for _, e := range entries {
+			dag := cid.NewSet()
+			if err := walk(ctx, getLinks, e, false, dag.Visit); err != nil {
+				// a DAG that can't be walked is reprovided with the others
+				log.Errorf("walking the DAG of %s: %s", e.Cid, err)
+				continue
+			}
+			_ = dag.ForEach(func(c cid.Cid) error {
+				classed.Add(c)
+				return nil
+			})
+		}
+
+		in, err := keys(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out := make(chan cid.Cid)
+		go func() {
+			defer close(out)
+			for c := range in {
+				if classed.Has(c) {
+					continue
+				}
+				select {
+				case out <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+}
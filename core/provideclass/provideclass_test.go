@@ -0,0 +1,158 @@
+package provideclass
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	dagtest "github.com/ipfs/boxo/ipld/merkledag/test"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// newTestDAG adds a root linking to two leaves, and returns their CIDs, the
+// root first.
+func newTestDAG(t *testing.T, ctx context.Context, dserv ipld.DAGService, seed string) []cid.Cid {
+	t.Helper()
+	a := merkledag.NodeWithData([]byte(seed + "a"))
+	b := merkledag.NodeWithData([]byte(seed + "b"))
+	root := merkledag.NodeWithData([]byte(seed))
+	if err := root.AddNodeLink("a", a); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.AddNodeLink("b", b); err != nil {
+		t.Fatal(err)
+	}
+	if err := dserv.AddMany(ctx, []ipld.Node{a, b, root}); err != nil {
+		t.Fatal(err)
+	}
+	return []cid.Cid{root.Cid(), a.Cid(), b.Cid()}
+}
+
+func TestReprovide(t *testing.T) {
+	ctx := context.Background()
+	dserv := dagtest.Mock()
+	hot := newTestDAG(t, ctx, dserv, "hot")
+	never := newTestDAG(t, ctx, dserv, "never")
+
+	var provided []cid.Cid
+	s := New(dssync.MutexWrap(datastore.NewMapDatastore()), merkledag.GetLinksWithDAG(dserv), func(c cid.Cid) error {
+		provided = append(provided, c)
+		return nil
+	}, false, clock.New())
+	defer s.Close()
+
+	if _, err := s.Set(ctx, hot[0], "lukewarm", true); err == nil {
+		t.Fatal("expected an error for an invalid class")
+	}
+	if _, err := s.Set(ctx, hot[0], Hot, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Set(ctx, never[0], Never, true); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	n, err := s.Reprovide(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(provided) != len(hot) {
+		t.Fatalf("expected the 3 blocks of the hot pin to be provided, got %d pins and %v", n, provided)
+	}
+
+	provided = nil
+	if n, err = s.Reprovide(ctx, now.Add(30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 || len(provided) != 0 {
+		t.Fatalf("expected nothing to be provided before an hour, got %v", provided)
+	}
+	if n, err = s.Reprovide(ctx, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected the hot pin to be provided after an hour, got %d pins", n)
+	}
+
+	// the same class keeps the time of the last reprovide
+	if _, err := s.Set(ctx, hot[0], Hot, true); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := s.Get(ctx, hot[0]); err != nil || !e.Provided.Equal(now.Add(time.Hour)) {
+		t.Fatalf("expected the last reprovide to be kept, got %v, %v", e, err)
+	}
+
+	if err := s.Clear(ctx, hot[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, hot[0]); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestReprovideOnlyRoots(t *testing.T) {
+	ctx := context.Background()
+	dserv := dagtest.Mock()
+	dag := newTestDAG(t, ctx, dserv, "warm")
+
+	var provided []cid.Cid
+	s := New(dssync.MutexWrap(datastore.NewMapDatastore()), merkledag.GetLinksWithDAG(dserv), func(c cid.Cid) error {
+		provided = append(provided, c)
+		return nil
+	}, true, clock.New())
+	defer s.Close()
+
+	if _, err := s.Set(ctx, dag[0], Warm, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Reprovide(ctx, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(provided) != 1 || !provided[0].Equals(dag[0]) {
+		t.Fatalf("expected only the root to be provided, got %v", provided)
+	}
+}
+
+func TestExclude(t *testing.T) {
+	ctx := context.Background()
+	dserv := dagtest.Mock()
+	classed := newTestDAG(t, ctx, dserv, "cold")
+	other := newTestDAG(t, ctx, dserv, "other")
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	s := New(ds, merkledag.GetLinksWithDAG(dserv), func(cid.Cid) error { return nil }, false, clock.New())
+	defer s.Close()
+	if _, err := s.Set(ctx, classed[0], Cold, true); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := Exclude(ds, merkledag.GetLinksWithDAG(dserv), func(context.Context) (<-chan cid.Cid, error) {
+		ch := make(chan cid.Cid, len(classed)+len(other))
+		for _, c := range append(classed, other...) {
+			ch <- c
+		}
+		close(ch)
+		return ch, nil
+	})
+	ch, err := keys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []cid.Cid
+	for c := range ch {
+		got = append(got, c)
+	}
+	if len(got) != len(other) {
+		t.Fatalf("expected only the blocks without class, got %v", got)
+	}
+	for i, c := range got {
+		if !c.Equals(other[i]) {
+			t.Fatalf("expected %s, got %s", other[i], c)
+		}
+	}
+}
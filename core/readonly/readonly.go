@@ -0,0 +1,144 @@
+// Package readonly implements the read-only mode of a node, in which the RPC
+// commands that change its content, pins, MFS, keys or config are rejected,
+// while reads, the gateway and bitswap keep being served. This is used to
+// expose replicas safely.
+package readonly
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// ErrReadOnly is returned for the changes rejected in read-only mode.
+var ErrReadOnly = errors.New("the node is in read-only mode")
+
+// Mode is the read-only mode of a node, which can be toggled at runtime.
+type Mode struct {
+	enabled atomic.Bool
+}
+
+// New returns a mode, enabled or not.
+func New(enabled bool) *Mode {
+	m := &Mode{}
+	m.enabled.Store(enabled)
+	return m
+}
+
+// Enabled returns whether the node is read-only.
+func (m *Mode) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// Set enables or disables the read-only mode.
+func (m *Mode) Set(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// Check returns ErrReadOnly when the node is read-only.
+func (m *Mode) Check() error {
+	if m.Enabled() {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// mutating are the paths of the commands that change the node. The commands
+// under object/patch are all mutating.
+var mutating = map[string]struct{}{
+	"add":                    {},
+	"block/provenance/purge": {},
+	"block/put":              {},
+	"block/rm":               {},
+	"bootstrap/add":          {},
+	"bootstrap/add/default":  {},
+	"bootstrap/rm":           {},
+	"bootstrap/rm/all":       {},
+	"cache/warm":             {},
+	"config/edit":            {},
+	"config/profile/apply":   {},
+	"config/replace":         {},
+	"dag/import":             {},
+	"dag/prune":              {},
+	"dag/put":                {},
+	"dht/put":                {},
+	"files/chcid":            {},
+	"files/chmod":            {},
+	"files/cp":               {},
+	"files/mkdir":            {},
+	"files/mv":               {},
+	"files/rm":               {},
+	"files/symlink":          {},
+	"files/sync":             {},
+	"files/touch":            {},
+	"files/ttl/clear":        {},
+	"files/ttl/set":          {},
+	"files/write":            {},
+	"key/derive":             {},
+	"key/gen":                {},
+	"key/import":             {},
+	"key/rename":             {},
+	"key/rm":                 {},
+	"key/rotate":             {},
+	"kv/put":                 {},
+	"kv/rm":                  {},
+	"name/publish":           {},
+	"object/new":             {},
+	"object/put":             {},
+	"pin/add":                {},
+	"pin/provide-class/set":  {},
+	"pin/queue/cancel":       {},
+	"pin/queue/priority":     {},
+	"pin/restore":            {},
+	"pin/rm":                 {},
+	"pin/update":             {},
+	"repo/gc":                {},
+	"repo/migrate":           {},
+	"routing/put":            {},
+	"standby/promote":        {},
+	"standby/sync":           {},
+	"swarm/filters/add":      {},
+	"swarm/filters/rm":       {},
+	"urlstore/add":           {},
+}
+
+// Mutating returns whether the command at path, called with args, changes
+// the node.
+func Mutating(path []string, args []string) bool {
+	p := strings.Join(path, "/")
+	if _, ok := mutating[p]; ok {
+		return true
+	}
+	switch {
+	case strings.HasPrefix(p, "object/patch/"):
+		return true
+	case p == "config":
+		// 'ipfs config <key> <value>' sets the value
+		return len(args) > 1
+	}
+	return false
+}
+
+// Guard returns a copy of the command tree of root whose mutating commands
+// fail with ErrReadOnly while m is enabled.
+func Guard(root *cmds.Command, m *Mode) *cmds.Command {
+	guarded := *root
+	if root.Run != nil {
+		run := root.Run
+		guarded.Run = func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+			if m.Enabled() && Mutating(req.Path, req.Arguments) {
+				return cmds.Errorf(cmds.ErrForbidden, "%s: 'ipfs %s' is not allowed", ErrReadOnly, strings.Join(req.Path, " "))
+			}
+			return run(req, res, env)
+		}
+	}
+	if root.Subcommands != nil {
+		guarded.Subcommands = make(map[string]*cmds.Command, len(root.Subcommands))
+		for name, sub := range root.Subcommands {
+			guarded.Subcommands[name] = Guard(sub, m)
+		}
+	}
+	return &guarded
+}
@@ -0,0 +1,71 @@
+package readonly
+
+import (
+	"context"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMutating(t *testing.T) {
+	for _, c := range []struct {
+		path     []string
+		args     []string
+		mutating bool
+	}{
+		{[]string{"add"}, nil, true},
+		{[]string{"cat"}, []string{"bafy"}, false},
+		{[]string{"pin", "rm"}, []string{"bafy"}, true},
+		{[]string{"pin", "ls"}, nil, false},
+		{[]string{"files", "write"}, []string{"/a"}, true},
+		{[]string{"files", "read"}, []string{"/a"}, false},
+		{[]string{"object", "patch", "add-link"}, nil, true},
+		{[]string{"config"}, []string{"Addresses.API"}, false},
+		{[]string{"config"}, []string{"Addresses.API", "/ip4/127.0.0.1/tcp/5001"}, true},
+		{[]string{"config", "show"}, nil, false},
+		{[]string{"config", "replace"}, nil, true},
+		{[]string{"readonly", "disable"}, nil, false},
+	} {
+		require.Equal(t, c.mutating, Mutating(c.path, c.args), "%v %v", c.path, c.args)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	var ran []string
+	run := func(name string) cmds.Function {
+		return func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+			ran = append(ran, name)
+			return nil
+		}
+	}
+	root := &cmds.Command{Subcommands: map[string]*cmds.Command{
+		"pin": {Subcommands: map[string]*cmds.Command{
+			"add": {Run: run("pin add")},
+			"ls":  {Run: run("pin ls")},
+		}},
+	}}
+
+	m := New(true)
+	guarded := Guard(root, m)
+	call := func(path ...string) error {
+		cmd, err := guarded.Get(path)
+		require.NoError(t, err)
+		return cmd.Run(&cmds.Request{Context: context.Background(), Path: path}, nil, nil)
+	}
+
+	err := call("pin", "add")
+	require.ErrorContains(t, err, ErrReadOnly.Error())
+	var cerr cmds.Error
+	require.ErrorAs(t, err, &cerr)
+	require.Equal(t, cmds.ErrForbidden, cerr.Code)
+	require.NoError(t, call("pin", "ls"))
+	require.Equal(t, []string{"pin ls"}, ran)
+
+	// toggled at runtime
+	m.Set(false)
+	require.NoError(t, call("pin", "add"))
+	require.Equal(t, []string{"pin ls", "pin add"}, ran)
+	require.ErrorIs(t, New(true).Check(), ErrReadOnly)
+	require.NoError(t, m.Check())
+}
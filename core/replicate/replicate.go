@@ -0,0 +1,490 @@
+// Package replicate pushes pinned DAGs to another node.
+//
+// The pushing node sends the pins one by one. The receiving node walks the
+// DAG of each pin breadth first and asks for the blocks it doesn't have, in
+// batches, then pins it. The blocks are kept as they are received, so a push
+// that is interrupted resumes where it stopped: the blocks already received
+// aren't asked for again. Before and after sending a pin, the pushing node
+// checks that the receiving node has it pinned.
+package replicate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ipfs/boxo/blockservice"
+	bstore "github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+var log = logging.Logger("core/replicate")
+
+// ProtocolID is the protocol of the replication of pins.
+const ProtocolID protocol.ID = "/kubo/replicate/1.0.0"
+
+const (
+	// MaxWant is the maximum number of blocks asked for at once.
+	MaxWant = 64
+
+	requestTimeout = time.Minute
+	// blocksTimeout bounds the wait for the blocks asked for, which may be
+	// sent slowly when the bandwidth of the push is capped.
+	blocksTimeout = 10 * time.Minute
+	// retryDelay is the delay before the first retry of an interrupted
+	// push, doubled for each retry.
+	retryDelay = time.Second
+)
+
+// Pin is a pin to replicate.
+type Pin struct {
+	Cid       cid.Cid
+	Recursive bool
+	Name      string `json:",omitempty"`
+}
+
+// Result is the outcome of the push of a pin.
+type Result struct {
+	Pin Pin
+	// Blocks and Bytes are the blocks sent, the blocks the receiving node
+	// already had aren't counted.
+	Blocks uint64
+	Bytes  uint64
+	// Skipped is true when the receiving node had the pin already.
+	Skipped bool
+	// Attempts is the number of times the pin was sent.
+	Attempts int
+}
+
+// Options are the options of a push.
+type Options struct {
+	// BytesPerSecond caps the bandwidth used to send the blocks, 0 for no
+	// cap.
+	BytesPerSecond uint64
+	// Retries is the number of times an interrupted push of a pin is
+	// resumed before giving up.
+	Retries int
+}
+
+const (
+	opPin    = "pin"
+	opVerify = "verify"
+	opBlocks = "blocks"
+)
+
+type block struct {
+	Cid  cid.Cid
+	Data []byte
+}
+
+// request is a message of the pushing node.
+type request struct {
+	Op     string
+	Pin    *Pin    `json:",omitempty"`
+	Blocks []block `json:",omitempty"`
+}
+
+// response is a message of the receiving node: blocks wanted, or the final
+// answer to a request.
+type response struct {
+	Error  string    `json:",omitempty"`
+	Want   []cid.Cid `json:",omitempty"`
+	Pinned bool      `json:",omitempty"`
+}
+
+// errRefused is a pin that the receiving node refused, as opposed to an
+// interrupted push.
+type errRefused struct {
+	msg string
+}
+
+func (e *errRefused) Error() string {
+	return e.msg
+}
+
+// Service receives the pins pushed by the peers allowed, and pushes pins to
+// peers.
+type Service struct {
+	host   host.Host
+	bs     bstore.GCBlockstore
+	dag    ipld.DAGService
+	pinner pin.Pinner
+	allow  func(peer.ID) bool
+}
+
+// New registers the replication protocol on h. Only the peers for which allow
+// returns true can push pins to the node.
+func New(h host.Host, bs bstore.GCBlockstore, pinner pin.Pinner, allow func(peer.ID) bool) *Service {
+	s := &Service{
+		host: h,
+		bs:   bs,
+		// the blocks are only read locally, they are received over the
+		// replication protocol
+		dag:    merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs))),
+		pinner: pinner,
+		allow:  allow,
+	}
+	h.SetStreamHandler(ProtocolID, s.handle)
+	return s
+}
+
+// Close unregisters the replication protocol.
+func (s *Service) Close() error {
+	s.host.RemoveStreamHandler(ProtocolID)
+	return nil
+}
+
+func (s *Service) handle(st network.Stream) {
+	defer st.Close()
+
+	remote := st.Conn().RemotePeer()
+	if !s.allow(remote) {
+		log.Debugf("refusing the pins pushed by %s", remote)
+		_ = st.Reset()
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := &conn{st: st, enc: json.NewEncoder(st), dec: json.NewDecoder(st)}
+	for {
+		_ = st.SetDeadline(time.Now().Add(requestTimeout))
+		var req request
+		if err := c.dec.Decode(&req); err != nil {
+			return // the peer is done
+		}
+		if req.Pin == nil || (req.Op != opPin && req.Op != opVerify) {
+			_ = c.enc.Encode(&response{Error: fmt.Sprintf("invalid %q request", req.Op)})
+			return
+		}
+
+		var resp response
+		var err error
+		if req.Op == opVerify {
+			resp.Pinned, err = s.isPinned(ctx, *req.Pin)
+		} else {
+			err = s.receive(ctx, c, *req.Pin)
+			resp.Pinned = err == nil
+		}
+		if err != nil {
+			log.Debugf("receiving %s from %s: %s", req.Pin.Cid, remote, err)
+			resp.Error = err.Error()
+		}
+		if err := c.enc.Encode(&resp); err != nil || resp.Error != "" {
+			return
+		}
+	}
+}
+
+func (s *Service) isPinned(ctx context.Context, p Pin) (bool, error) {
+	mode := pin.Direct
+	if p.Recursive {
+		mode = pin.Recursive
+	}
+	_, pinned, err := s.pinner.IsPinnedWithType(ctx, p.Cid, mode)
+	return pinned, err
+}
+
+// receive asks the pushing node for the blocks of the DAG of p that are
+// missing, then pins it.
+func (s *Service) receive(ctx context.Context, c *conn, p Pin) error {
+	// the blocks received aren't pinned until the whole DAG is
+	defer s.bs.PinLock(ctx).Unlock(ctx)
+
+	visited := cid.NewSet()
+	visited.Add(p.Cid)
+	frontier := []cid.Cid{p.Cid}
+	for len(frontier) > 0 {
+		batch := frontier
+		if len(batch) > MaxWant {
+			batch = batch[:MaxWant]
+		}
+		frontier = frontier[len(batch):]
+
+		if err := s.fetch(ctx, c, batch); err != nil {
+			return err
+		}
+		if !p.Recursive {
+			break
+		}
+		for _, k := range batch {
+			nd, err := s.dag.Get(ctx, k)
+			if err != nil {
+				return err
+			}
+			for _, l := range nd.Links() {
+				if visited.Visit(l.Cid) {
+					frontier = append(frontier, l.Cid)
+				}
+			}
+		}
+	}
+
+	root, err := s.dag.Get(ctx, p.Cid)
+	if err != nil {
+		return err
+	}
+	if err := s.pinner.Pin(ctx, root, p.Recursive, p.Name); err != nil {
+		return err
+	}
+	return s.pinner.Flush(ctx)
+}
+
+// fetch asks the pushing node for the blocks of batch that are missing, and
+// stores them.
+func (s *Service) fetch(ctx context.Context, c *conn, batch []cid.Cid) error {
+	var want []cid.Cid
+	for _, k := range batch {
+		has, err := s.bs.Has(ctx, k)
+		if err != nil {
+			return err
+		}
+		if !has {
+			want = append(want, k)
+		}
+	}
+	if len(want) == 0 {
+		return nil
+	}
+
+	_ = c.st.SetDeadline(time.Now().Add(blocksTimeout))
+	if err := c.enc.Encode(&response{Want: want}); err != nil {
+		return err
+	}
+	var req request
+	if err := c.dec.Decode(&req); err != nil {
+		return err
+	}
+	if req.Op != opBlocks {
+		return fmt.Errorf("expected blocks, got a %q request", req.Op)
+	}
+
+	wanted := cid.NewSet()
+	for _, k := range want {
+		wanted.Add(k)
+	}
+	blks := make([]blocks.Block, 0, len(req.Blocks))
+	for _, b := range req.Blocks {
+		if !wanted.Has(b.Cid) {
+			return fmt.Errorf("received %s, which wasn't asked for", b.Cid)
+		}
+		sum, err := b.Cid.Prefix().Sum(b.Data)
+		if err != nil || !sum.Equals(b.Cid) {
+			return fmt.Errorf("the data of the block %s doesn't match its hash", b.Cid)
+		}
+		blk, err := blocks.NewBlockWithCid(b.Data, b.Cid)
+		if err != nil {
+			return err
+		}
+		wanted.Remove(b.Cid)
+		blks = append(blks, blk)
+	}
+	if wanted.Len() > 0 {
+		return fmt.Errorf("the pushing node is missing %d blocks of the DAG", wanted.Len())
+	}
+	return s.bs.PutMany(ctx, blks)
+}
+
+// conn is a replication stream.
+type conn struct {
+	st  network.Stream
+	enc *json.Encoder
+	dec *json.Decoder
+}
+
+// Push sends the pins to p, which must allow the node to push pins. The
+// pins are sent one by one, and the results are reported as they complete.
+// A push interrupted by a network error is resumed up to opts.Retries times.
+func (s *Service) Push(ctx context.Context, p peer.ID, pins []Pin, opts Options, report func(Result)) error {
+	var c *conn
+	defer func() {
+		if c != nil {
+			_ = c.st.Close()
+		}
+	}()
+	limiter := &throttle{rate: opts.BytesPerSecond}
+
+	for _, pn := range pins {
+		res := Result{Pin: pn}
+		for retries := 0; ; retries++ {
+			var err error
+			if c == nil {
+				var st network.Stream
+				if st, err = s.host.NewStream(ctx, p, ProtocolID); err == nil {
+					c = &conn{st: st, enc: json.NewEncoder(&throttledWriter{ctx: ctx, w: st, t: limiter}), dec: json.NewDecoder(st)}
+				}
+			}
+			if err == nil {
+				if err = s.push(ctx, c, &res); err == nil {
+					break
+				}
+				_ = c.st.Reset()
+				c = nil
+			}
+
+			var refused *errRefused
+			if errors.As(err, &refused) || ctx.Err() != nil || retries >= opts.Retries {
+				return fmt.Errorf("pushing %s to %s: %w", pn.Cid, p, err)
+			}
+			delay := retryDelay << retries
+			log.Debugf("pushing %s to %s was interrupted, retrying in %s: %s", pn.Cid, p, delay, err)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if report != nil {
+			report(res)
+		}
+	}
+	return nil
+}
+
+// push sends a pin, unless the receiving node has it already, and checks it
+// is pinned once received.
+func (s *Service) push(ctx context.Context, c *conn, res *Result) error {
+	pinned, err := c.verify(res.Pin)
+	if err != nil {
+		return err
+	}
+	if pinned {
+		res.Skipped = res.Attempts == 0
+		return nil
+	}
+
+	res.Attempts++
+	_ = c.st.SetDeadline(time.Now().Add(requestTimeout))
+	if err := c.enc.Encode(&request{Op: opPin, Pin: &res.Pin}); err != nil {
+		return err
+	}
+	for {
+		_ = c.st.SetDeadline(time.Now().Add(requestTimeout))
+		var resp response
+		if err := c.dec.Decode(&resp); err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return &errRefused{msg: resp.Error}
+		}
+		if len(resp.Want) == 0 {
+			break
+		}
+		if len(resp.Want) > MaxWant {
+			return &errRefused{msg: fmt.Sprintf("%d blocks asked for at once, the maximum is %d", len(resp.Want), MaxWant)}
+		}
+
+		req := request{Op: opBlocks, Blocks: make([]block, 0, len(resp.Want))}
+		for _, k := range resp.Want {
+			blk, err := s.bs.Get(ctx, k)
+			if err != nil {
+				return &errRefused{msg: fmt.Sprintf("reading %s: %s", k, err)}
+			}
+			req.Blocks = append(req.Blocks, block{Cid: k, Data: blk.RawData()})
+			res.Blocks++
+			res.Bytes += uint64(len(blk.RawData()))
+		}
+		// sending the blocks is throttled, it isn't bounded by a deadline
+		_ = c.st.SetDeadline(time.Time{})
+		if err := c.enc.Encode(&req); err != nil {
+			return err
+		}
+	}
+
+	pinned, err = c.verify(res.Pin)
+	if err != nil {
+		return err
+	}
+	if !pinned {
+		return &errRefused{msg: "the pin is missing on the receiving node"}
+	}
+	return nil
+}
+
+func (c *conn) verify(p Pin) (bool, error) {
+	_ = c.st.SetDeadline(time.Now().Add(requestTimeout))
+	if err := c.enc.Encode(&request{Op: opVerify, Pin: &p}); err != nil {
+		return false, err
+	}
+	var resp response
+	if err := c.dec.Decode(&resp); err != nil {
+		return false, err
+	}
+	if resp.Error != "" {
+		return false, &errRefused{msg: resp.Error}
+	}
+	return resp.Pinned, nil
+}
+
+// throttle caps the rate of the writes of a push.
+type throttle struct {
+	rate  uint64
+	start time.Time
+	sent  uint64
+}
+
+// wait blocks until n more bytes can be sent.
+func (t *throttle) wait(ctx context.Context, n int) error {
+	if t.rate == 0 {
+		return nil
+	}
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+	t.sent += uint64(n)
+	due := t.start.Add(time.Duration(float64(t.sent) / float64(t.rate) * float64(time.Second)))
+	delay := time.Until(due)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttledWriter writes in chunks, at the rate of its throttle.
+type throttledWriter struct {
+	ctx context.Context
+	w   io.Writer
+	t   *throttle
+}
+
+func (tw *throttledWriter) Write(p []byte) (int, error) {
+	const chunk = 16 << 10
+	var written int
+	for len(p) > 0 {
+		n := len(p)
+		if n > chunk {
+			n = chunk
+		}
+		if err := tw.t.wait(tw.ctx, n); err != nil {
+			return written, err
+		}
+		m, err := tw.w.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,145 @@
+package replicate
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/blockservice"
+	bstore "github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/pinning/pinner/dspinner"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+type testNode struct {
+	s   *Service
+	bs  bstore.GCBlockstore
+	dag ipld.DAGService
+}
+
+func newTestNode(t *testing.T, mn mocknet.Mocknet, allow func(peer.ID) bool) *testNode {
+	ctx := context.Background()
+	h, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	bs := bstore.NewGCBlockstore(bstore.NewBlockstore(ds), bstore.NewGCLocker())
+	dag := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+	pinner, err := dspinner.New(ctx, ds, dag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(h, bs, pinner, allow)
+	t.Cleanup(func() { s.Close() })
+	return &testNode{s: s, bs: bs, dag: dag}
+}
+
+func TestPush(t *testing.T) {
+	ctx := context.Background()
+	mn := mocknet.New()
+	defer mn.Close()
+
+	src := newTestNode(t, mn, func(peer.ID) bool { return false })
+	dst := newTestNode(t, mn, func(peer.ID) bool { return true })
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a root with more children than asked for at once, one of which the
+	// receiving node already has
+	root := merkledag.NodeWithData([]byte("root"))
+	var shared ipld.Node
+	for i := 0; i < 2*MaxWant+10; i++ {
+		child := merkledag.NodeWithData([]byte(fmt.Sprint("child ", i)))
+		if err := src.dag.Add(ctx, child); err != nil {
+			t.Fatal(err)
+		}
+		if err := root.AddNodeLink(fmt.Sprint(i), child); err != nil {
+			t.Fatal(err)
+		}
+		shared = child
+	}
+	if err := src.dag.Add(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.dag.Add(ctx, shared); err != nil {
+		t.Fatal(err)
+	}
+	direct := merkledag.NodeWithData([]byte("direct"))
+	if err := src.dag.Add(ctx, direct); err != nil {
+		t.Fatal(err)
+	}
+
+	pins := []Pin{{Cid: root.Cid(), Recursive: true, Name: "dataset"}, {Cid: direct.Cid()}}
+	var results []Result
+	start := time.Now()
+	err := src.s.Push(ctx, dst.s.host.ID(), pins, Options{BytesPerSecond: 64 << 10}, func(r Result) {
+		results = append(results, r)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Blocks != uint64(2*MaxWant+10) || results[0].Skipped || results[0].Attempts != 1 {
+		t.Fatalf("unexpected result %+v", results[0])
+	}
+	if results[1].Blocks != 1 {
+		t.Fatalf("unexpected result %+v", results[1])
+	}
+	if total := results[0].Bytes + results[1].Bytes; time.Since(start) < time.Duration(total)*time.Second/(64<<10) {
+		t.Fatalf("%d bytes were sent faster than the bandwidth cap", total)
+	}
+
+	for _, p := range pins {
+		pinned, err := dst.s.isPinned(ctx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pinned {
+			t.Fatalf("expected %s to be pinned", p.Cid)
+		}
+	}
+
+	// pushing again skips the pins
+	results = nil
+	if err := src.s.Push(ctx, dst.s.host.ID(), pins, Options{}, func(r Result) {
+		results = append(results, r)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if !r.Skipped || r.Blocks != 0 {
+			t.Fatalf("expected %s to be skipped, got %+v", r.Pin.Cid, r)
+		}
+	}
+}
+
+func TestPushRefused(t *testing.T) {
+	ctx := context.Background()
+	mn := mocknet.New()
+	defer mn.Close()
+
+	src := newTestNode(t, mn, func(peer.ID) bool { return true })
+	dst := newTestNode(t, mn, func(peer.ID) bool { return false })
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	nd := merkledag.NodeWithData([]byte("data"))
+	if err := src.dag.Add(ctx, nd); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.s.Push(ctx, dst.s.host.ID(), []Pin{{Cid: nd.Cid(), Recursive: true}}, Options{}, nil); err == nil {
+		t.Fatal("expected the peer to refuse the pins")
+	}
+}
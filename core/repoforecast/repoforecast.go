@@ -0,0 +1,351 @@
+// Package repoforecast tracks the growth of the repo, and forecasts when it
+// reaches Datastore.StorageMax. Operators are warned when the forecast gets
+// close, through the logs, the subscribers of the alerts and an optional
+// webhook, before the garbage collection runs all the time or the disk fills
+// up.
+package repoforecast
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("repoforecast")
+
+// DatastoreKey is the key the samples are saved under, so that the growth
+// rate survives restarts.
+var DatastoreKey = datastore.NewKey("/local/repoforecast")
+
+// Level is the urgency of a forecast.
+type Level string
+
+const (
+	// LevelOK means the repo is not forecast to reach StorageMax within the
+	// warning period.
+	LevelOK Level = "ok"
+	// LevelWarning means the repo is forecast to reach StorageMax within the
+	// warning period.
+	LevelWarning Level = "warning"
+	// LevelCritical means the repo is forecast to reach StorageMax within
+	// the critical period, or has reached it.
+	LevelCritical Level = "critical"
+)
+
+// Sample is the size of the repo at a given time.
+type Sample struct {
+	Time time.Time
+	Size uint64
+}
+
+// Forecast is the growth of the repo, and when it reaches StorageMax.
+type Forecast struct {
+	Size       uint64
+	StorageMax uint64
+	// Rate is the growth of the repo in bytes per second over the window of
+	// the samples, negative when it shrinks.
+	Rate float64
+	// Full is when the repo is forecast to reach StorageMax, nil when it
+	// doesn't grow.
+	Full  *time.Time `json:",omitempty"`
+	Level Level
+	// Samples is the number of samples the forecast is made from.
+	Samples int
+	Updated time.Time
+}
+
+// Alert is sent when the level of the forecast changes.
+type Alert struct {
+	Level    Level
+	Previous Level
+	Forecast Forecast
+}
+
+// SizeFunc returns the size of the repo.
+type SizeFunc func(ctx context.Context) (uint64, error)
+
+// Options configure a Monitor.
+type Options struct {
+	StorageMax uint64
+	// Interval is the time between samples.
+	Interval time.Duration
+	// Window is the period the growth rate is computed over.
+	Window time.Duration
+	// Warning and Critical are how long before the repo reaches StorageMax
+	// the forecast turns to LevelWarning and LevelCritical.
+	Warning  time.Duration
+	Critical time.Duration
+	// Webhook is the URL the alerts are posted to as JSON, if set.
+	Webhook string
+}
+
+// Monitor samples the size of the repo and forecasts its growth.
+type Monitor struct {
+	ds     datastore.Datastore
+	size   SizeFunc
+	opts   Options
+	client *http.Client
+
+	lk       sync.Mutex
+	samples  []Sample
+	forecast Forecast
+	subs     map[chan Alert]struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New loads the samples saved in ds. The repo is sampled once Start is called,
+// until ctx is cancelled or Close is called.
+func New(ctx context.Context, ds datastore.Datastore, size SizeFunc, opts Options) (*Monitor, error) {
+	if opts.Interval <= 0 {
+		return nil, errors.New("the interval of the samples of the repo size must be positive")
+	}
+	m := &Monitor{
+		ds:     ds,
+		size:   size,
+		opts:   opts,
+		client: &http.Client{Timeout: 30 * time.Second},
+		subs:   make(map[chan Alert]struct{}),
+	}
+	m.ctx, m.cancel = context.WithCancel(ctx)
+
+	data, err := ds.Get(ctx, DatastoreKey)
+	switch {
+	case errors.Is(err, datastore.ErrNotFound):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &m.samples); err != nil {
+			log.Errorf("ignoring invalid samples of the repo size: %s", err)
+			m.samples = nil
+		}
+	}
+	m.forecast = m.compute(time.Now())
+	return m, nil
+}
+
+// Start samples the repo every Interval until the monitor is stopped.
+func (m *Monitor) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.opts.Interval)
+		defer ticker.Stop()
+		for {
+			if _, err := m.Sample(m.ctx); err != nil && m.ctx.Err() == nil {
+				log.Errorf("sampling the repo size: %s", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-m.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Close stops sampling the repo, and closes the subscriptions.
+func (m *Monitor) Close() error {
+	m.cancel()
+	m.wg.Wait()
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	for ch := range m.subs {
+		delete(m.subs, ch)
+		close(ch)
+	}
+	return nil
+}
+
+// Forecast returns the forecast made from the last sample.
+func (m *Monitor) Forecast() Forecast {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.forecast
+}
+
+// Samples returns the samples the forecast is made from, oldest first.
+func (m *Monitor) Samples() []Sample {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return append([]Sample(nil), m.samples...)
+}
+
+// Subscribe returns the alerts sent until ctx is done. Alerts are dropped for
+// subscribers that don't keep up.
+func (m *Monitor) Subscribe(ctx context.Context) <-chan Alert {
+	ch := make(chan Alert, 16)
+	m.lk.Lock()
+	m.subs[ch] = struct{}{}
+	m.lk.Unlock()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-m.ctx.Done():
+		}
+		m.lk.Lock()
+		defer m.lk.Unlock()
+		if _, ok := m.subs[ch]; ok {
+			delete(m.subs, ch)
+			close(ch)
+		}
+	}()
+	return ch
+}
+
+// Sample adds a sample of the size of the repo now, and updates the forecast.
+func (m *Monitor) Sample(ctx context.Context) (Forecast, error) {
+	size, err := m.size(ctx)
+	if err != nil {
+		return Forecast{}, err
+	}
+	return m.add(ctx, Sample{Time: time.Now(), Size: size})
+}
+
+func (m *Monitor) add(ctx context.Context, s Sample) (Forecast, error) {
+	m.lk.Lock()
+	m.samples = append(m.samples, s)
+	// only the samples of the window are kept, plus the one just before it
+	// so that the rate spans the whole window
+	cut := 0
+	for cut < len(m.samples)-1 && s.Time.Sub(m.samples[cut+1].Time) >= m.opts.Window {
+		cut++
+	}
+	m.samples = m.samples[cut:]
+	data, err := json.Marshal(m.samples)
+	if err != nil {
+		m.lk.Unlock()
+		return Forecast{}, err
+	}
+
+	previous := m.forecast.Level
+	m.forecast = m.compute(s.Time)
+	f := m.forecast
+	var alert *Alert
+	if f.Level != previous {
+		alert = &Alert{Level: f.Level, Previous: previous, Forecast: f}
+		for ch := range m.subs {
+			select {
+			case ch <- *alert:
+			default:
+			}
+		}
+	}
+	m.lk.Unlock()
+
+	if alert != nil {
+		m.notify(ctx, *alert)
+	}
+	return f, m.ds.Put(ctx, DatastoreKey, data)
+}
+
+// compute forecasts the growth of the repo from the samples, with the rate
+// of a least squares fit of the samples.
+func (m *Monitor) compute(now time.Time) Forecast {
+	f := Forecast{
+		StorageMax: m.opts.StorageMax,
+		Level:      LevelOK,
+		Samples:    len(m.samples),
+		Updated:    now,
+	}
+	if len(m.samples) == 0 {
+		return f
+	}
+	last := m.samples[len(m.samples)-1]
+	f.Size = last.Size
+
+	if len(m.samples) > 1 {
+		first := m.samples[0].Time
+		var sumT, sumS, sumTT, sumTS float64
+		for _, s := range m.samples {
+			t := s.Time.Sub(first).Seconds()
+			sz := float64(s.Size)
+			sumT += t
+			sumS += sz
+			sumTT += t * t
+			sumTS += t * sz
+		}
+		n := float64(len(m.samples))
+		if d := n*sumTT - sumT*sumT; d > 0 {
+			f.Rate = (n*sumTS - sumT*sumS) / d
+		}
+	}
+
+	if f.StorageMax == 0 {
+		return f
+	}
+	var remaining time.Duration
+	switch {
+	case f.Size >= f.StorageMax:
+		full := last.Time
+		f.Full = &full
+	case f.Rate > 0:
+		remaining = time.Duration(float64(f.StorageMax-f.Size) / f.Rate * float64(time.Second))
+		full := last.Time.Add(remaining)
+		f.Full = &full
+		remaining = full.Sub(now)
+	default:
+		return f
+	}
+	switch {
+	case remaining <= m.opts.Critical:
+		f.Level = LevelCritical
+	case remaining <= m.opts.Warning:
+		f.Level = LevelWarning
+	}
+	return f
+}
+
+// notify logs the alert, and posts it to the webhook.
+func (m *Monitor) notify(ctx context.Context, a Alert) {
+	msg := fmt.Sprintf("repo size forecast changed from %s to %s: %d of %d bytes", a.Previous, a.Level, a.Forecast.Size, a.Forecast.StorageMax)
+	if a.Forecast.Full != nil {
+		msg += fmt.Sprintf(", full at %s", a.Forecast.Full.Format(time.RFC3339))
+	}
+	switch a.Level {
+	case LevelOK:
+		log.Info(msg)
+	case LevelWarning:
+		log.Warn(msg)
+	default:
+		log.Error(msg)
+	}
+
+	if m.opts.Webhook == "" {
+		return
+	}
+	if err := m.post(ctx, a); err != nil {
+		log.Errorf("posting the repo size alert to the webhook: %s", err)
+	}
+}
+
+func (m *Monitor) post(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.opts.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package repoforecast
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForecast(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	alerts := make(chan Alert, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&a))
+		alerts <- a
+	}))
+	defer srv.Close()
+
+	opts := Options{
+		StorageMax: 1000,
+		Interval:   time.Hour,
+		Window:     24 * time.Hour,
+		Warning:    7 * 24 * time.Hour,
+		Critical:   24 * time.Hour,
+		Webhook:    srv.URL,
+	}
+	m, err := New(ctx, ds, nil, opts)
+	require.NoError(t, err)
+	defer m.Close()
+
+	start := time.Now().Add(-10 * time.Hour)
+	add := func(hours int, size uint64) Forecast {
+		f, err := m.add(ctx, Sample{Time: start.Add(time.Duration(hours) * time.Hour), Size: size})
+		require.NoError(t, err)
+		return f
+	}
+
+	// 1 byte per hour, full in 890 hours
+	add(0, 100)
+	f := add(10, 110)
+	require.InDelta(t, 1.0/3600, f.Rate, 1e-9)
+	require.NotNil(t, f.Full)
+	require.Equal(t, LevelOK, f.Level)
+
+	// 10 bytes per hour on average, full in 70 hours
+	f = add(20, 300)
+	require.Equal(t, LevelWarning, f.Level)
+	a := <-alerts
+	require.Equal(t, LevelWarning, a.Level)
+	require.Equal(t, LevelOK, a.Previous)
+
+	f = add(21, 1000)
+	require.Equal(t, LevelCritical, f.Level)
+	require.Equal(t, LevelCritical, (<-alerts).Level)
+
+	// the samples out of the window are dropped, and saved
+	f = add(50, 1000)
+	require.Equal(t, 2, f.Samples)
+	m2, err := New(ctx, ds, nil, opts)
+	require.NoError(t, err)
+	require.Len(t, m2.Samples(), 2)
+	require.Equal(t, uint64(1000), m2.Forecast().Size)
+}
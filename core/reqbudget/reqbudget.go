@@ -0,0 +1,225 @@
+// Package reqbudget bounds the memory and the goroutines used by a single RPC
+// request, so that an expensive command, such as a recursive 'ipfs refs' of a
+// huge DAG, fails instead of exhausting the daemon.
+//
+// The limits are configured by command path in API.RequestBudgets. Guard
+// gives each request of these commands a Budget, carried by its context, and
+// the commands charge what they use to it. A command that charges nothing is
+// not limited: only 'ipfs refs' charges its budget so far.
+package reqbudget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/config"
+)
+
+// ErrExceeded is matched by the errors returned when a request exceeds its
+// budget.
+var ErrExceeded = errors.New("request budget exceeded")
+
+// Resources of a Budget, as reported by ExceededError.
+const (
+	Memory     = "memory"
+	Goroutines = "goroutines"
+)
+
+// ExceededError is returned when a request exceeds the limit of one of the
+// resources of its budget.
+type ExceededError struct {
+	// Resource is Memory or Goroutines.
+	Resource string
+	Limit    int64
+}
+
+func (e *ExceededError) Error() string {
+	limit := fmt.Sprint(e.Limit)
+	if e.Resource == Memory {
+		limit = humanize.IBytes(uint64(e.Limit))
+	}
+	return fmt.Sprintf("%s: more than %s of %s", ErrExceeded, limit, e.Resource)
+}
+
+func (e *ExceededError) Is(target error) bool {
+	return target == ErrExceeded
+}
+
+// Limits are the per request limits of a command configured in
+// API.RequestBudgets. Zero limits are disabled.
+type Limits struct {
+	MaxMemory     int64
+	MaxGoroutines int64
+}
+
+func (l Limits) enabled() bool {
+	return l.MaxMemory > 0 || l.MaxGoroutines > 0
+}
+
+// ParseBudgets returns the limits configured in API.RequestBudgets, by
+// command path.
+func ParseBudgets(cfg map[string]*config.APIRequestBudget) (map[string]Limits, error) {
+	budgets := make(map[string]Limits, len(cfg))
+	for path, b := range cfg {
+		if b == nil {
+			continue
+		}
+		limits := Limits{
+			MaxGoroutines: b.MaxGoroutines.WithDefault(0),
+		}
+		if s := b.MaxMemory.WithDefault(""); s != "" {
+			n, err := humanize.ParseBytes(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid API.RequestBudgets[%q].MaxMemory: %w", path, err)
+			}
+			limits.MaxMemory = int64(n)
+		}
+		if limits.enabled() {
+			budgets[strings.Trim(path, "/")] = limits
+		}
+	}
+	return budgets, nil
+}
+
+// Budget tracks the memory and the goroutines used by a request. The methods
+// of a nil Budget never fail, so that the commands don't need to check
+// whether a budget is set.
+type Budget struct {
+	limits Limits
+
+	memory     atomic.Int64
+	goroutines atomic.Int64
+}
+
+// New returns a budget with the given limits.
+func New(limits Limits) *Budget {
+	return &Budget{limits: limits}
+}
+
+type budgetKey struct{}
+
+// ContextWithBudget returns a context carrying b.
+func ContextWithBudget(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// FromContext returns the budget carried by ctx, or nil.
+func FromContext(ctx context.Context) *Budget {
+	b, _ := ctx.Value(budgetKey{}).(*Budget)
+	return b
+}
+
+// Alloc charges n bytes of memory to the budget, or returns an ExceededError
+// when that would exceed MaxMemory.
+func (b *Budget) Alloc(n int64) error {
+	if b == nil || b.limits.MaxMemory <= 0 {
+		return nil
+	}
+	if b.memory.Add(n) > b.limits.MaxMemory {
+		b.memory.Add(-n)
+		return &ExceededError{Resource: Memory, Limit: b.limits.MaxMemory}
+	}
+	return nil
+}
+
+// Free returns n bytes of memory charged with Alloc to the budget.
+func (b *Budget) Free(n int64) {
+	if b == nil || b.limits.MaxMemory <= 0 {
+		return
+	}
+	b.memory.Add(-n)
+}
+
+// Acquire charges a goroutine to the budget, or returns an ExceededError when
+// that would exceed MaxGoroutines. Release must be called once the goroutine
+// ends.
+func (b *Budget) Acquire() error {
+	if b == nil || b.limits.MaxGoroutines <= 0 {
+		return nil
+	}
+	if b.goroutines.Add(1) > b.limits.MaxGoroutines {
+		b.goroutines.Add(-1)
+		return &ExceededError{Resource: Goroutines, Limit: b.limits.MaxGoroutines}
+	}
+	return nil
+}
+
+// Release returns a goroutine charged with Acquire to the budget.
+func (b *Budget) Release() {
+	if b == nil || b.limits.MaxGoroutines <= 0 {
+		return
+	}
+	b.goroutines.Add(-1)
+}
+
+// Go runs f in a new goroutine charged to the budget.
+func (b *Budget) Go(f func()) error {
+	if err := b.Acquire(); err != nil {
+		return err
+	}
+	go func() {
+		defer b.Release()
+		f()
+	}()
+	return nil
+}
+
+// Usage returns the memory and the goroutines currently charged to the
+// budget. Only the resources with a limit are tracked.
+func (b *Budget) Usage() (memory, goroutines int64) {
+	if b == nil {
+		return 0, 0
+	}
+	return b.memory.Load(), b.goroutines.Load()
+}
+
+// Guard returns a copy of the command tree of root whose commands with a
+// budget run with a new Budget of their limits in the context of each
+// request, and fail with a rate limited error when they exceed it. The budget
+// of a command is the one of its path in budgets, or else the one of its
+// closest parent, "" being the path of root. root itself is returned when
+// budgets is empty.
+func Guard(root *cmds.Command, budgets map[string]Limits) *cmds.Command {
+	if len(budgets) == 0 {
+		return root
+	}
+	return guard(root, nil, budgets)
+}
+
+func guard(c *cmds.Command, path []string, budgets map[string]Limits) *cmds.Command {
+	guarded := *c
+	if limits, ok := lookup(path, budgets); ok && c.Run != nil {
+		run := c.Run
+		guarded.Run = func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+			req.Context = ContextWithBudget(req.Context, New(limits))
+			err := run(req, res, env)
+			if errors.Is(err, ErrExceeded) {
+				return cmds.Errorf(cmds.ErrRateLimited, "%s", err)
+			}
+			return err
+		}
+	}
+	if c.Subcommands != nil {
+		guarded.Subcommands = make(map[string]*cmds.Command, len(c.Subcommands))
+		for name, sub := range c.Subcommands {
+			guarded.Subcommands[name] = guard(sub, append(path[:len(path):len(path)], name), budgets)
+		}
+	}
+	return &guarded
+}
+
+// lookup returns the limits of the command at path, or of its closest parent
+// with limits.
+func lookup(path []string, budgets map[string]Limits) (Limits, bool) {
+	for i := len(path); i >= 0; i-- {
+		if limits, ok := budgets[strings.Join(path[:i], "/")]; ok {
+			return limits, true
+		}
+	}
+	return Limits{}, false
+}
@@ -0,0 +1,110 @@
+package reqbudget
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudget(t *testing.T) {
+	b := New(Limits{MaxMemory: 100, MaxGoroutines: 2})
+
+	require.NoError(t, b.Alloc(60))
+	err := b.Alloc(60)
+	require.ErrorIs(t, err, ErrExceeded)
+	var exceeded *ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	require.Equal(t, Memory, exceeded.Resource)
+	b.Free(60)
+	require.NoError(t, b.Alloc(60))
+
+	require.NoError(t, b.Acquire())
+	require.NoError(t, b.Acquire())
+	require.ErrorIs(t, b.Acquire(), ErrExceeded)
+	b.Release()
+	done := make(chan struct{})
+	require.NoError(t, b.Go(func() { close(done) }))
+	<-done
+
+	mem, _ := b.Usage()
+	require.EqualValues(t, 60, mem)
+
+	// a nil budget has no limit
+	var none *Budget
+	require.NoError(t, none.Alloc(1<<40))
+	require.NoError(t, none.Acquire())
+	none.Release()
+	require.Nil(t, FromContext(context.Background()))
+}
+
+func TestParseBudgets(t *testing.T) {
+	budgets, err := ParseBudgets(map[string]*config.APIRequestBudget{
+		"refs": {
+			MaxMemory:     config.NewOptionalString("1MiB"),
+			MaxGoroutines: config.NewOptionalInteger(8),
+		},
+		"/dag/export/": {MaxGoroutines: config.NewOptionalInteger(2)},
+		"cat":          {},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]Limits{
+		"refs":       {MaxMemory: 1 << 20, MaxGoroutines: 8},
+		"dag/export": {MaxGoroutines: 2},
+	}, budgets)
+
+	_, err = ParseBudgets(map[string]*config.APIRequestBudget{"refs": {MaxMemory: config.NewOptionalString("lots")}})
+	require.Error(t, err)
+}
+
+func TestGuard(t *testing.T) {
+	charge := func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		return FromContext(req.Context).Alloc(2)
+	}
+	root := &cmds.Command{Subcommands: map[string]*cmds.Command{
+		"refs": {Run: charge, Subcommands: map[string]*cmds.Command{
+			"local": {Run: charge},
+		}},
+		"dag": {Subcommands: map[string]*cmds.Command{
+			"export": {Run: charge},
+		}},
+		"cat": {Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+			if FromContext(req.Context) != nil {
+				return errors.New("unexpected budget")
+			}
+			return nil
+		}},
+	}}
+	require.Same(t, root, Guard(root, nil))
+
+	run := func(guarded *cmds.Command, path ...string) error {
+		cmd, err := guarded.Get(path)
+		require.NoError(t, err)
+		return cmd.Run(&cmds.Request{Context: context.Background(), Path: path}, nil, nil)
+	}
+	exceeded := func(err error) {
+		t.Helper()
+		var cerr cmds.Error
+		require.ErrorAs(t, err, &cerr)
+		require.Equal(t, cmds.ErrRateLimited, cerr.Code)
+	}
+
+	guarded := Guard(root, map[string]Limits{
+		"refs":       {MaxMemory: 1},
+		"dag/export": {MaxMemory: 2},
+	})
+	exceeded(run(guarded, "refs"))
+	// subcommands inherit the budget of their parent
+	exceeded(run(guarded, "refs", "local"))
+	require.NoError(t, run(guarded, "dag", "export"))
+	// the commands without a budget run without one
+	require.NoError(t, run(guarded, "cat"))
+
+	// the budget of "" applies to every command
+	guarded = Guard(root, map[string]Limits{"": {MaxMemory: 1}})
+	exceeded(run(guarded, "dag", "export"))
+	require.Error(t, run(guarded, "cat"))
+}
@@ -0,0 +1,235 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// DefaultShutdownTimeout is how long Shutdown waits for in-flight operations
+// when neither ShutdownOptions.Timeout nor Internal.ShutdownTimeout is set.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// shutdownGracePeriod is how long Shutdown waits for operations to return
+// after their context was cancelled, unless the timeout is shorter.
+const shutdownGracePeriod = 5 * time.Second
+
+// ErrShuttingDown is returned when an operation is started on a node that is
+// shutting down.
+var ErrShuttingDown = errors.New("node is shutting down")
+
+// ShutdownOptions configures IpfsNode.Shutdown.
+type ShutdownOptions struct {
+	// Drain lets in-flight operations finish before the node is torn down.
+	// When false, they are cancelled right away.
+	Drain bool
+
+	// Timeout bounds the time spent draining. Operations still running
+	// afterwards are cancelled. Defaults to Internal.ShutdownTimeout, or
+	// DefaultShutdownTimeout.
+	Timeout time.Duration
+}
+
+// operations tracks the long running operations (API servers, adds, pins) of
+// a node, so that they can be drained on shutdown.
+type operations struct {
+	lk       sync.Mutex
+	draining chan struct{}
+	stopped  bool
+	cancels  map[uint64]context.CancelFunc
+	next     uint64
+	wg       sync.WaitGroup
+
+	shutdownOnce sync.Once
+	shutdownErr  error
+}
+
+// drainingCh must be called with the lock held.
+func (o *operations) drainingCh() chan struct{} {
+	if o.draining == nil {
+		o.draining = make(chan struct{})
+	}
+	return o.draining
+}
+
+func (o *operations) start(ctx context.Context) (context.Context, func(), error) {
+	o.lk.Lock()
+	defer o.lk.Unlock()
+
+	if o.stopped {
+		return nil, nil, ErrShuttingDown
+	}
+	if o.cancels == nil {
+		o.cancels = make(map[uint64]context.CancelFunc)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	id := o.next
+	o.next++
+	o.cancels[id] = cancel
+	o.wg.Add(1)
+
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			o.lk.Lock()
+			delete(o.cancels, id)
+			o.lk.Unlock()
+			cancel()
+			o.wg.Done()
+		})
+	}, nil
+}
+
+// stop refuses new operations and returns a channel closed once all running
+// operations are done.
+func (o *operations) stop() <-chan struct{} {
+	o.lk.Lock()
+	if !o.stopped {
+		o.stopped = true
+		close(o.drainingCh())
+	}
+	o.lk.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+func (o *operations) cancelAll() int {
+	o.lk.Lock()
+	defer o.lk.Unlock()
+	for _, cancel := range o.cancels {
+		cancel()
+	}
+	return len(o.cancels)
+}
+
+// StartOperation registers a long running operation with the node. The
+// returned context is cancelled when Shutdown stops waiting for the
+// operation, and done must be called once the operation finished. Once the
+// node is shutting down, ErrShuttingDown is returned.
+func (n *IpfsNode) StartOperation(ctx context.Context) (context.Context, func(), error) {
+	return n.ops.start(ctx)
+}
+
+// Draining returns a channel that is closed when the node starts shutting
+// down. Servers should stop accepting new requests at that point.
+func (n *IpfsNode) Draining() <-chan struct{} {
+	n.ops.lk.Lock()
+	defer n.ops.lk.Unlock()
+	return n.ops.drainingCh()
+}
+
+// Shutdown gracefully stops the node. It refuses new operations, waits for
+// the running ones when opts.Drain is set, flushes MFS and the pinner,
+// persists the current peers for the next bootstrap and finally closes the
+// node. Operations interrupted by the timeout keep what they wrote so far:
+// an incremental add or a pin can pick up from there after a restart.
+//
+// Shutdown stops waiting for operations as soon as ctx is done, which also
+// bounds the flush of the pinner and the saving of the peers. Calling Shutdown
+// more than once returns the result of the first call.
+func (n *IpfsNode) Shutdown(ctx context.Context, opts ShutdownOptions) error {
+	n.ops.shutdownOnce.Do(func() {
+		n.ops.shutdownErr = n.shutdown(ctx, opts)
+	})
+	return n.ops.shutdownErr
+}
+
+func (n *IpfsNode) shutdown(ctx context.Context, opts ShutdownOptions) error {
+	timeout := n.shutdownTimeout(opts)
+	grace := shutdownGracePeriod
+	if timeout < grace {
+		grace = timeout
+	}
+
+	done := n.ops.stop()
+	if opts.Drain {
+		t := time.NewTimer(timeout)
+		select {
+		case <-done:
+		case <-t.C:
+		case <-ctx.Done():
+		}
+		t.Stop()
+	}
+	if running := n.ops.cancelAll(); running > 0 {
+		log.Warnf("cancelling %d operations still running on shutdown", running)
+		t := time.NewTimer(grace)
+		select {
+		case <-done:
+		case <-t.C:
+			log.Warn("operations did not return after being cancelled")
+		case <-ctx.Done():
+		}
+		t.Stop()
+	}
+
+	// Persisting state must not be cut short by the draining deadline, only
+	// by ctx.
+	pctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var errs []error
+	if n.FilesRoot != nil {
+		if err := n.FilesRoot.Flush(); err != nil {
+			errs = append(errs, fmt.Errorf("flushing MFS: %w", err))
+		}
+	}
+	if n.Pinning != nil {
+		if err := n.Pinning.Flush(pctx); err != nil {
+			errs = append(errs, fmt.Errorf("flushing pins: %w", err))
+		}
+	}
+	if err := n.saveConnectedPeers(pctx); err != nil {
+		errs = append(errs, fmt.Errorf("saving peers: %w", err))
+	}
+
+	if err := n.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// shutdownTimeout returns the draining timeout of opts, falling back to the
+// configured one.
+func (n *IpfsNode) shutdownTimeout(opts ShutdownOptions) time.Duration {
+	if opts.Timeout > 0 {
+		return opts.Timeout
+	}
+	if n.Repo != nil {
+		if cfg, err := n.Repo.Config(); err == nil {
+			return cfg.Internal.ShutdownTimeout.WithDefault(DefaultShutdownTimeout)
+		}
+	}
+	return DefaultShutdownTimeout
+}
+
+// saveConnectedPeers stores the currently connected peers as backup bootstrap
+// peers, so that the next start does not depend on the configured bootstrap
+// peers only.
+func (n *IpfsNode) saveConnectedPeers(ctx context.Context) error {
+	if n.PeerHost == nil || n.Repo == nil {
+		return nil
+	}
+	var peers []peer.AddrInfo
+	for _, p := range n.PeerHost.Network().Peers() {
+		addrs := n.PeerHost.Peerstore().Addrs(p)
+		if len(addrs) == 0 {
+			continue
+		}
+		peers = append(peers, peer.AddrInfo{ID: p, Addrs: addrs})
+	}
+	if len(peers) == 0 {
+		return nil
+	}
+	return n.saveTempBootstrapPeers(ctx, peers)
+}
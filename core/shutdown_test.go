@@ -0,0 +1,102 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/repo"
+)
+
+func TestShutdownDrain(t *testing.T) {
+	var closed bool
+	n := &IpfsNode{stop: func() error { closed = true; return nil }}
+
+	_, done, err := n.StartOperation(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := make(chan error)
+	go func() {
+		res <- n.Shutdown(context.Background(), ShutdownOptions{Drain: true, Timeout: time.Minute})
+	}()
+
+	<-n.Draining()
+	if _, _, err := n.StartOperation(context.Background()); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("expected ErrShuttingDown, got %v", err)
+	}
+
+	select {
+	case <-res:
+		t.Fatal("shutdown returned before the operation finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	done()
+	if err := <-res; err != nil {
+		t.Fatal(err)
+	}
+	if !closed {
+		t.Fatal("node was not closed")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	n := &IpfsNode{stop: func() error { return nil }}
+
+	ctx, done, err := n.StartOperation(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		<-ctx.Done()
+		done()
+	}()
+
+	if err := n.Shutdown(context.Background(), ShutdownOptions{Drain: true, Timeout: 10 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("operation was not cancelled")
+	}
+}
+
+func TestShutdownConfiguredTimeout(t *testing.T) {
+	r := &repo.Mock{}
+	r.C.Internal.ShutdownTimeout = config.NewOptionalDuration(10 * time.Millisecond)
+	n := &IpfsNode{Repo: r, stop: func() error { return nil }}
+
+	// the operation ignores the cancellation
+	if _, _, err := n.StartOperation(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := n.Shutdown(context.Background(), ShutdownOptions{Drain: true}); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("shutdown took %s", d)
+	}
+}
+
+func TestShutdownContext(t *testing.T) {
+	n := &IpfsNode{stop: func() error { return nil }}
+
+	if _, _, err := n.StartOperation(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := n.Shutdown(ctx, ShutdownOptions{Drain: true, Timeout: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("shutdown took %s", d)
+	}
+}
@@ -0,0 +1,623 @@
+// Package standby pairs a standby node with a primary node: the standby
+// continuously mirrors the pinset, the MFS root and the keys of the primary,
+// and can be promoted to replace it, e.g. while the primary is maintained.
+//
+// The state of the primary is read over a libp2p stream, which is encrypted
+// and authenticated by the peer IDs of both nodes. The primary only answers
+// the standbys approved by its operator, and the standby only mirrors the
+// primary set by its own. The DAGs are then fetched like any other content.
+package standby
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/boxo/keystore"
+	"github.com/ipfs/boxo/mfs"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+var log = logging.Logger("core/standby")
+
+// ProtocolID is the protocol the standby reads the state of the primary with.
+const ProtocolID protocol.ID = "/kubo/standby/1.0.0"
+
+// FilesPinName is the name of the pin keeping the mirrored MFS root of the
+// primary on the standby, until it is promoted.
+const FilesPinName = "standby-files"
+
+const requestTimeout = time.Minute
+
+// stateKey is where the standby records what it mirrored, so that it can
+// remove what the primary removed.
+var stateKey = datastore.NewKey("/local/standby")
+
+// Pin is a pin of the primary.
+type Pin struct {
+	Cid       cid.Cid
+	Recursive bool
+	Name      string `json:",omitempty"`
+}
+
+// Key is a key of the keystore of the primary. The self key, the identity of
+// the primary, is not mirrored.
+type Key struct {
+	Name string
+	// Data is the private key, marshaled with crypto.MarshalPrivateKey.
+	Data []byte
+}
+
+// State is the state of the primary mirrored by the standby.
+type State struct {
+	Pins  []Pin
+	Files cid.Cid
+	Keys  []Key
+}
+
+// Status is the status of a node of a standby pair.
+type Status struct {
+	// Primary is the node mirrored, empty on primary nodes.
+	Primary peer.ID
+	// Standbys are the nodes allowed to mirror this node.
+	Standbys []peer.ID
+	// Promoted is set once the standby is promoted: it doesn't mirror its
+	// primary anymore.
+	Promoted bool
+
+	// LastSync is when the standby last mirrored the primary, and LastError
+	// the error of the attempts since, if any.
+	LastSync  time.Time
+	LastError string
+	// Pins, Files and Keys describe what was last mirrored.
+	Pins  int
+	Files cid.Cid
+	Keys  int
+}
+
+type request struct {
+	Op string
+}
+
+type response struct {
+	Error string `json:",omitempty"`
+	State *State `json:",omitempty"`
+}
+
+// mirrored is what the standby mirrored, recorded at stateKey.
+type mirrored struct {
+	Pins     []Pin
+	Files    cid.Cid
+	Keys     []string
+	Promoted bool
+	LastSync time.Time
+}
+
+// Service serves the state of the node to its standbys, and mirrors the
+// primary of the node, when it has one.
+type Service struct {
+	host     host.Host
+	dag      ipld.DAGService
+	bs       blockstore.GCLocker
+	pinner   pin.Pinner
+	keys     keystore.Keystore
+	files    *mfs.Root
+	ds       datastore.Datastore
+	primary  peer.ID
+	standbys []peer.ID
+	interval time.Duration
+
+	// syncLk serializes the mirrorings, lk guards the fields below
+	syncLk    sync.Mutex
+	lk        sync.Mutex
+	mirrored  mirrored
+	lastError string
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// Options are the settings of a Service.
+type Options struct {
+	// Primary is the node mirrored by this one, if any.
+	Primary peer.ID
+	// Standbys are the nodes allowed to mirror this one.
+	Standbys []peer.ID
+	// Interval is how often the primary is mirrored.
+	Interval time.Duration
+}
+
+// New registers the standby protocol on h, and loads what was mirrored
+// before. dag must fetch the blocks missing locally. Start starts mirroring
+// the primary.
+func New(ctx context.Context, h host.Host, dag ipld.DAGService, bs blockstore.GCLocker, pinner pin.Pinner, keys keystore.Keystore, files *mfs.Root, ds datastore.Datastore, opts Options) (*Service, error) {
+	s := &Service{
+		host:     h,
+		dag:      dag,
+		bs:       bs,
+		pinner:   pinner,
+		keys:     keys,
+		files:    files,
+		ds:       ds,
+		primary:  opts.Primary,
+		standbys: opts.Standbys,
+		interval: opts.Interval,
+	}
+	buf, err := ds.Get(ctx, stateKey)
+	switch err {
+	case nil:
+		if err := json.Unmarshal(buf, &s.mirrored); err != nil {
+			return nil, fmt.Errorf("reading the mirrored state: %w", err)
+		}
+	case datastore.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	if len(s.standbys) > 0 {
+		h.SetStreamHandler(ProtocolID, s.handle)
+	}
+	return s, nil
+}
+
+// Start starts mirroring the primary, unless the node is a primary, or was
+// promoted.
+func (s *Service) Start() {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.primary == "" || s.mirrored.Promoted || s.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.run(ctx, s.done)
+}
+
+// stop stops mirroring the primary.
+func (s *Service) stop() {
+	s.lk.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.lk.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Close stops mirroring the primary, and unregisters the standby protocol.
+func (s *Service) Close() error {
+	s.stop()
+	s.host.RemoveStreamHandler(ProtocolID)
+	return nil
+}
+
+func (s *Service) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	t := time.NewTimer(0)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+		if err := s.Sync(ctx); err != nil && ctx.Err() == nil {
+			log.Warnf("mirroring the primary %s: %s", s.primary, err)
+		}
+		t.Reset(s.interval)
+	}
+}
+
+// Status returns the status of the node.
+func (s *Service) Status() Status {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return Status{
+		Primary:   s.primary,
+		Standbys:  s.standbys,
+		Promoted:  s.mirrored.Promoted,
+		LastSync:  s.mirrored.LastSync,
+		LastError: s.lastError,
+		Pins:      len(s.mirrored.Pins),
+		Files:     s.mirrored.Files,
+		Keys:      len(s.mirrored.Keys),
+	}
+}
+
+func (s *Service) allowed(p peer.ID) bool {
+	for _, sb := range s.standbys {
+		if sb == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) handle(st network.Stream) {
+	defer st.Close()
+
+	remote := st.Conn().RemotePeer()
+	if !s.allowed(remote) {
+		log.Debugf("refusing to be mirrored by %s", remote)
+		_ = st.Reset()
+		return
+	}
+	_ = st.SetDeadline(time.Now().Add(requestTimeout))
+
+	var req request
+	if err := json.NewDecoder(st).Decode(&req); err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	var resp response
+	if req.Op != "state" {
+		resp.Error = fmt.Sprintf("unknown operation %q", req.Op)
+	} else if state, err := s.state(ctx); err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.State = state
+	}
+	if err := json.NewEncoder(st).Encode(&resp); err != nil {
+		log.Debugf("answering %s: %s", remote, err)
+	}
+}
+
+// state returns the state of the node, as mirrored by its standbys.
+func (s *Service) state(ctx context.Context) (*State, error) {
+	state := &State{}
+	for _, recursive := range []bool{true, false} {
+		keys := s.pinner.DirectKeys
+		if recursive {
+			keys = s.pinner.RecursiveKeys
+		}
+		for p := range keys(ctx, true) {
+			if p.Err != nil {
+				return nil, p.Err
+			}
+			if p.Pin.Name == FilesPinName {
+				// the MFS root mirrored from another primary
+				continue
+			}
+			state.Pins = append(state.Pins, Pin{Cid: p.Pin.Key, Recursive: recursive, Name: p.Pin.Name})
+		}
+	}
+
+	if s.files != nil {
+		nd, err := s.files.GetDirectory().GetNode()
+		if err != nil {
+			return nil, err
+		}
+		state.Files = nd.Cid()
+	}
+
+	names, err := s.keys.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		sk, err := s.keys.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		data, err := crypto.MarshalPrivateKey(sk)
+		if err != nil {
+			return nil, err
+		}
+		state.Keys = append(state.Keys, Key{Name: name, Data: data})
+	}
+	return state, nil
+}
+
+// fetchState reads the state of the primary.
+func (s *Service) fetchState(ctx context.Context) (*State, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	st, err := s.host.NewStream(ctx, s.primary, ProtocolID)
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = st.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(st).Encode(&request{Op: "state"}); err != nil {
+		_ = st.Reset()
+		return nil, err
+	}
+	_ = st.CloseWrite()
+	var resp response
+	if err := json.NewDecoder(st).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("reading the state of the primary, is this node in its Standby.Standbys? %w", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	if resp.State == nil {
+		return nil, errors.New("the primary sent no state")
+	}
+	return resp.State, nil
+}
+
+// Sync mirrors the primary now. The pins, the MFS root and the keys the
+// primary added are added to the node, and the ones it removed since it was
+// last mirrored are removed.
+func (s *Service) Sync(ctx context.Context) error {
+	if s.primary == "" {
+		return errors.New("the node has no primary (Standby.Primary)")
+	}
+	s.syncLk.Lock()
+	defer s.syncLk.Unlock()
+
+	err := s.sync(ctx)
+	s.lk.Lock()
+	if err != nil {
+		s.lastError = err.Error()
+	} else {
+		s.lastError = ""
+	}
+	s.lk.Unlock()
+	return err
+}
+
+func (s *Service) sync(ctx context.Context) error {
+	state, err := s.fetchState(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.lk.Lock()
+	prev := s.mirrored
+	s.lk.Unlock()
+	if prev.Promoted {
+		return errors.New("the node was promoted")
+	}
+
+	if err := s.mirrorPins(ctx, prev, state); err != nil {
+		return err
+	}
+	if err := s.mirrorKeys(prev, state); err != nil {
+		return err
+	}
+
+	next := mirrored{
+		Pins:     state.Pins,
+		Files:    state.Files,
+		LastSync: time.Now(),
+	}
+	for _, k := range state.Keys {
+		next.Keys = append(next.Keys, k.Name)
+	}
+	return s.record(ctx, next)
+}
+
+// record records what was mirrored.
+func (s *Service) record(ctx context.Context, m mirrored) error {
+	buf, err := json.Marshal(&m)
+	if err != nil {
+		return err
+	}
+	if err := s.ds.Put(ctx, stateKey, buf); err != nil {
+		return err
+	}
+	if err := s.ds.Sync(ctx, stateKey); err != nil {
+		return err
+	}
+	s.lk.Lock()
+	s.mirrored = m
+	s.lk.Unlock()
+	return nil
+}
+
+func (s *Service) isPinned(ctx context.Context, c cid.Cid, recursive bool) (bool, error) {
+	mode := pin.Direct
+	if recursive {
+		mode = pin.Recursive
+	}
+	_, pinned, err := s.pinner.IsPinnedWithType(ctx, c, mode)
+	return pinned, err
+}
+
+// pin pins c, fetching its DAG when recursive.
+func (s *Service) pin(ctx context.Context, c cid.Cid, recursive bool, name string) error {
+	pinned, err := s.isPinned(ctx, c, recursive)
+	if err != nil || pinned {
+		return err
+	}
+	nd, err := s.dag.Get(ctx, c)
+	if err != nil {
+		return err
+	}
+	return s.pinner.Pin(ctx, nd, recursive, name)
+}
+
+func (s *Service) unpin(ctx context.Context, c cid.Cid, recursive bool) error {
+	err := s.pinner.Unpin(ctx, c, recursive)
+	if err == pin.ErrNotPinned {
+		return nil
+	}
+	return err
+}
+
+// mirrorPins pins the pins and the MFS root of state, and unpins the pins and
+// the MFS root of prev which are gone.
+func (s *Service) mirrorPins(ctx context.Context, prev mirrored, state *State) error {
+	defer s.bs.PinLock(ctx).Unlock(ctx)
+
+	wanted := make(map[Pin]struct{}, len(state.Pins))
+	for _, p := range state.Pins {
+		wanted[Pin{Cid: p.Cid, Recursive: p.Recursive}] = struct{}{}
+		if err := s.pin(ctx, p.Cid, p.Recursive, p.Name); err != nil {
+			return fmt.Errorf("pinning %s: %w", p.Cid, err)
+		}
+	}
+	for _, p := range prev.Pins {
+		if _, ok := wanted[Pin{Cid: p.Cid, Recursive: p.Recursive}]; !ok {
+			if err := s.unpin(ctx, p.Cid, p.Recursive); err != nil {
+				return fmt.Errorf("unpinning %s: %w", p.Cid, err)
+			}
+		}
+	}
+
+	if state.Files.Defined() {
+		if err := s.pin(ctx, state.Files, true, FilesPinName); err != nil {
+			return fmt.Errorf("pinning the MFS root %s: %w", state.Files, err)
+		}
+	}
+	if prev.Files.Defined() && !prev.Files.Equals(state.Files) {
+		// unless the primary pinned it too
+		if _, ok := wanted[Pin{Cid: prev.Files, Recursive: true}]; !ok {
+			if err := s.unpin(ctx, prev.Files, true); err != nil {
+				return err
+			}
+		}
+	}
+	return s.pinner.Flush(ctx)
+}
+
+// mirrorKeys puts the keys of state in the keystore, replacing the keys of
+// the same name, and deletes the keys of prev which are gone.
+func (s *Service) mirrorKeys(prev mirrored, state *State) error {
+	wanted := make(map[string]struct{}, len(state.Keys))
+	for _, k := range state.Keys {
+		if k.Name == "self" {
+			continue
+		}
+		wanted[k.Name] = struct{}{}
+		sk, err := crypto.UnmarshalPrivateKey(k.Data)
+		if err != nil {
+			return fmt.Errorf("reading the key %q: %w", k.Name, err)
+		}
+		existing, err := s.keys.Get(k.Name)
+		switch err {
+		case nil:
+			if existing.Equals(sk) {
+				continue
+			}
+			if err := s.keys.Delete(k.Name); err != nil {
+				return err
+			}
+		case keystore.ErrNoSuchKey:
+		default:
+			return err
+		}
+		if err := s.keys.Put(k.Name, sk); err != nil {
+			return err
+		}
+	}
+	for _, name := range prev.Keys {
+		if _, ok := wanted[name]; ok {
+			continue
+		}
+		if err := s.keys.Delete(name); err != nil && err != keystore.ErrNoSuchKey {
+			return err
+		}
+	}
+	return nil
+}
+
+// Promote stops mirroring the primary, and replaces the MFS root of the node
+// with the mirrored one. The primary is mirrored one last time first: when
+// that fails, the node is only promoted when forced, with the state it last
+// mirrored.
+func (s *Service) Promote(ctx context.Context, force bool) error {
+	if s.primary == "" {
+		return errors.New("the node has no primary (Standby.Primary)")
+	}
+	if s.Status().Promoted {
+		return errors.New("the node was already promoted")
+	}
+	s.stop()
+	if err := s.Sync(ctx); err != nil {
+		if !force {
+			s.Start()
+			return fmt.Errorf("mirroring the primary one last time: %w", err)
+		}
+		log.Warnf("promoting without mirroring the primary one last time: %s", err)
+	}
+
+	s.syncLk.Lock()
+	defer s.syncLk.Unlock()
+	s.lk.Lock()
+	m := s.mirrored
+	s.lk.Unlock()
+
+	if m.Files.Defined() && s.files != nil {
+		if err := replaceFiles(ctx, s.files, s.dag, m.Files); err != nil {
+			return fmt.Errorf("replacing the MFS root: %w", err)
+		}
+		// the MFS root keeps it from now on
+		if err := func() error {
+			defer s.bs.PinLock(ctx).Unlock(ctx)
+			for _, p := range m.Pins {
+				if p.Recursive && p.Cid.Equals(m.Files) {
+					return nil
+				}
+			}
+			if err := s.unpin(ctx, m.Files, true); err != nil {
+				return err
+			}
+			return s.pinner.Flush(ctx)
+		}(); err != nil {
+			return err
+		}
+	}
+
+	m.Promoted = true
+	return s.record(ctx, m)
+}
+
+// replaceFiles replaces the entries of the MFS root with the entries of the
+// directory c.
+func replaceFiles(ctx context.Context, root *mfs.Root, dag ipld.DAGService, c cid.Cid) error {
+	nd, err := dag.Get(ctx, c)
+	if err != nil {
+		return err
+	}
+	dir, err := uio.NewDirectoryFromNode(dag, nd)
+	if err != nil {
+		return err
+	}
+	links, err := dir.Links(ctx)
+	if err != nil {
+		return err
+	}
+
+	rootDir := root.GetDirectory()
+	names, err := rootDir.ListNames(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := rootDir.Unlink(name); err != nil {
+			return err
+		}
+	}
+	for _, l := range links {
+		child, err := l.GetNode(ctx, dag)
+		if err != nil {
+			return err
+		}
+		if err := rootDir.AddChild(l.Name, child); err != nil {
+			return err
+		}
+	}
+	return rootDir.Flush()
+}
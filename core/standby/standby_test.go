@@ -0,0 +1,175 @@
+package standby
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/blockservice"
+	bstore "github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/keystore"
+	"github.com/ipfs/boxo/mfs"
+	"github.com/ipfs/boxo/pinning/pinner/dspinner"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+type testNode struct {
+	s     *Service
+	bs    bstore.GCBlockstore
+	keys  keystore.Keystore
+	files *mfs.Root
+}
+
+// newTestNode returns a node on h fetching the blocks it misses from the
+// blockstore from, if set.
+func newTestNode(t *testing.T, h host.Host, from bstore.Blockstore, opts Options) *testNode {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	bs := bstore.NewGCBlockstore(bstore.NewBlockstore(ds), bstore.NewGCLocker())
+	exch := offline.Exchange(bs)
+	if from != nil {
+		exch = offline.Exchange(from)
+	}
+	dag := merkledag.NewDAGService(blockservice.New(bs, exch))
+	pinner, err := dspinner.New(ctx, ds, dag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootNd := ft.EmptyDirNode()
+	if err := dag.Add(ctx, rootNd); err != nil {
+		t.Fatal(err)
+	}
+	files, err := mfs.NewRoot(ctx, dag, rootNd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := keystore.NewMemKeystore()
+	n := &testNode{bs: bs, keys: keys, files: files}
+	if n.s, err = New(ctx, h, dag, bs, pinner, keys, files, ds, opts); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { n.s.Close() })
+	return n
+}
+
+func TestStandby(t *testing.T) {
+	ctx := context.Background()
+	mn := mocknet.New()
+	defer mn.Close()
+
+	var hosts []host.Host
+	for i := 0; i < 3; i++ {
+		h, err := mn.GenPeer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hosts = append(hosts, h)
+	}
+	primary := newTestNode(t, hosts[0], nil, Options{Standbys: []peer.ID{hosts[1].ID()}})
+	standby := newTestNode(t, hosts[1], primary.bs, Options{Primary: hosts[0].ID(), Interval: time.Hour})
+	outsider := newTestNode(t, hosts[2], primary.bs, Options{Primary: hosts[0].ID(), Interval: time.Hour})
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	pdag := primary.s.dag
+	root := merkledag.NodeWithData([]byte("root"))
+	child := merkledag.NodeWithData([]byte("child"))
+	if err := pdag.Add(ctx, child); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.AddNodeLink("child", child); err != nil {
+		t.Fatal(err)
+	}
+	direct := merkledag.NodeWithData([]byte("direct"))
+	for _, nd := range []*merkledag.ProtoNode{root, direct} {
+		if err := pdag.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := primary.s.pinner.Pin(ctx, root, true, "dataset"); err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.s.pinner.Pin(ctx, direct, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	file := merkledag.NodeWithData(ft.FilePBData([]byte("file"), 4))
+	if err := pdag.Add(ctx, file); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.PutNode(primary.files, "/file", file); err != nil {
+		t.Fatal(err)
+	}
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.keys.Put("site", sk); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := outsider.s.Sync(ctx); err == nil {
+		t.Fatal("expected the primary to refuse to be mirrored by the outsider")
+	}
+
+	if err := standby.s.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []struct {
+		nd        *merkledag.ProtoNode
+		recursive bool
+	}{{root, true}, {direct, false}} {
+		if pinned, err := standby.s.isPinned(ctx, p.nd.Cid(), p.recursive); err != nil || !pinned {
+			t.Fatalf("expected %s to be pinned, got %t, %v", p.nd.Cid(), pinned, err)
+		}
+	}
+	if has, err := standby.bs.Has(ctx, child.Cid()); err != nil || !has {
+		t.Fatal("expected the DAG of the pin to be fetched")
+	}
+	if got, err := standby.keys.Get("site"); err != nil || !got.Equals(sk) {
+		t.Fatalf("expected the key to be mirrored, got %v", err)
+	}
+	st := standby.s.Status()
+	if st.Pins != 2 || st.Keys != 1 || !st.Files.Defined() || st.LastSync.IsZero() || st.LastError != "" {
+		t.Fatalf("unexpected status %+v", st)
+	}
+
+	// what the primary removes is removed
+	if err := primary.s.pinner.Unpin(ctx, direct.Cid(), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.keys.Delete("site"); err != nil {
+		t.Fatal(err)
+	}
+	if err := standby.s.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if pinned, _ := standby.s.isPinned(ctx, direct.Cid(), false); pinned {
+		t.Fatal("expected the pin removed by the primary to be removed")
+	}
+	if has, _ := standby.keys.Has("site"); has {
+		t.Fatal("expected the key removed by the primary to be removed")
+	}
+
+	if err := standby.s.Promote(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.Lookup(standby.files, "/file"); err != nil {
+		t.Fatalf("expected the MFS root of the primary: %s", err)
+	}
+	if !standby.s.Status().Promoted {
+		t.Fatal("expected the standby to be promoted")
+	}
+	if err := standby.s.Sync(ctx); err == nil {
+		t.Fatal("expected a promoted node not to mirror its primary")
+	}
+}
@@ -0,0 +1,8 @@
+//go:build nodht
+// +build nodht
+
+package subsystem
+
+func init() {
+	disabled[DHT] = "nodht"
+}
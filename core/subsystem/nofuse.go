@@ -0,0 +1,8 @@
+//go:build nofuse
+// +build nofuse
+
+package subsystem
+
+func init() {
+	disabled[FUSE] = "nofuse"
+}
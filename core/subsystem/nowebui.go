@@ -0,0 +1,8 @@
+//go:build nowebui
+// +build nowebui
+
+package subsystem
+
+func init() {
+	disabled[WebUI] = "nowebui"
+}
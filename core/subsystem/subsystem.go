@@ -0,0 +1,69 @@
+// Package subsystem tells which of the optional subsystems are compiled in
+// the binary. The build tags nofuse, nodht and nowebui compile them out of
+// the small footprint builds, and requesting one of them then fails with a
+// DisabledError.
+//
+// The tags exclude the files importing and constructing the subsystems, so
+// that their code isn't linked. The DHT server shares its code with the DHT
+// client, so nodht compiles out the whole DHT, and the node only uses the
+// HTTP routers. Graphsync, removed in Kubo 0.25, needs no tag.
+package subsystem
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// The optional subsystems.
+const (
+	FUSE  = "fuse"
+	DHT   = "dht"
+	WebUI = "webui"
+)
+
+// disabled maps the subsystems compiled out to the build tag which did.
+var disabled = map[string]string{}
+
+// ErrDisabled is matched by the errors returned for the subsystems compiled
+// out.
+var ErrDisabled = errors.New("subsystem not compiled in")
+
+// DisabledError is returned when Subsystem is requested, but was compiled
+// out with the build tag Tag.
+type DisabledError struct {
+	Subsystem string
+	Tag       string
+}
+
+func (e *DisabledError) Error() string {
+	return fmt.Sprintf("%s is not compiled in this binary (built with the %q tag)", e.Subsystem, e.Tag)
+}
+
+func (e *DisabledError) Is(target error) bool {
+	return target == ErrDisabled
+}
+
+// Enabled reports whether s is compiled in.
+func Enabled(s string) bool {
+	_, ok := disabled[s]
+	return !ok
+}
+
+// Check returns a DisabledError if s was compiled out.
+func Check(s string) error {
+	if tag, ok := disabled[s]; ok {
+		return &DisabledError{Subsystem: s, Tag: tag}
+	}
+	return nil
+}
+
+// Disabled returns the subsystems compiled out, sorted.
+func Disabled() []string {
+	out := make([]string, 0, len(disabled))
+	for s := range disabled {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
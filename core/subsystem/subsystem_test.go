@@ -0,0 +1,26 @@
+package subsystem
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	disabled["test"] = "notest"
+	defer delete(disabled, "test")
+
+	err := Check("test")
+	if !errors.Is(err, ErrDisabled) {
+		t.Fatalf("expected ErrDisabled, got %v", err)
+	}
+	var derr *DisabledError
+	if !errors.As(err, &derr) || derr.Tag != "notest" {
+		t.Fatalf("expected a DisabledError for the notest tag, got %v", err)
+	}
+	if Enabled("test") {
+		t.Error("expected test to be disabled")
+	}
+	if err := Check("other"); err != nil {
+		t.Errorf("expected other to be enabled, got %v", err)
+	}
+}
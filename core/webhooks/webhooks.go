@@ -0,0 +1,270 @@
+// Package webhooks posts the events of the node to HTTP endpoints, so that
+// small deployments can integrate with other services without watching the
+// RPC API.
+//
+// Events are posted as JSON, one request per event and endpoint, with the
+// name of the event in the X-Ipfs-Event header. Requests to endpoints with a
+// secret are signed with HMAC-SHA256 of the body, in the X-Ipfs-Signature
+// header as "sha256=<hex>".
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("webhooks")
+
+// Names of the events.
+const (
+	// EventPinCompleted is fired when a pin is added, by 'ipfs pin add' or
+	// the queue of background pins.
+	EventPinCompleted = "pin.completed"
+	// EventIPNSPublished is fired when an IPNS name is published.
+	EventIPNSPublished = "ipns.published"
+	// EventGCCompleted is fired when a garbage collection ends.
+	EventGCCompleted = "gc.completed"
+	// EventPeersLow and EventPeersHigh are fired when the number of
+	// connected peers falls below, or rises above, a threshold.
+	EventPeersLow  = "peers.low"
+	EventPeersHigh = "peers.high"
+)
+
+// Headers of the requests.
+const (
+	EventHeader     = "X-Ipfs-Event"
+	SignatureHeader = "X-Ipfs-Signature"
+)
+
+// queueSize is the number of events waiting to be posted, more are dropped.
+const queueSize = 256
+
+// Event is the body of the requests.
+type Event struct {
+	Event string
+	Time  time.Time
+	// Node is the peer ID of the node.
+	Node string
+	Data interface{} `json:",omitempty"`
+}
+
+// PinData is the data of EventPinCompleted.
+type PinData struct {
+	Cid       string
+	Recursive bool
+	Name      string `json:",omitempty"`
+}
+
+// IPNSData is the data of EventIPNSPublished.
+type IPNSData struct {
+	Name  string
+	Value string
+}
+
+// GCData is the data of EventGCCompleted.
+type GCData struct {
+	Removed  uint64
+	Errors   int
+	Duration time.Duration
+}
+
+// PeersData is the data of EventPeersLow and EventPeersHigh.
+type PeersData struct {
+	Peers     int
+	Threshold int
+}
+
+// Endpoint is a URL the events are posted to.
+type Endpoint struct {
+	URL string
+	// Events are the path.Match patterns of the names of the events posted,
+	// all of them when empty.
+	Events []string
+	Secret []byte
+}
+
+func (e Endpoint) matches(event string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, pattern := range e.Events {
+		if ok, _ := path.Match(pattern, event); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Options configure a Notifier.
+type Options struct {
+	// Node is the peer ID of the node, sent with the events.
+	Node      string
+	Endpoints []Endpoint
+	Timeout   time.Duration
+}
+
+// Notifier posts the events to the endpoints, in the background.
+type Notifier struct {
+	opts   Options
+	client *http.Client
+	queue  chan Event
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New checks the endpoints. Events are posted once Start is called.
+func New(opts Options) (*Notifier, error) {
+	for _, e := range opts.Endpoints {
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook URL %q: %w", e.URL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("invalid webhook URL %q: the scheme must be http or https", e.URL)
+		}
+		for _, pattern := range e.Events {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid webhook event pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	n := &Notifier{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		queue:  make(chan Event, queueSize),
+	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	return n, nil
+}
+
+// Start posts the events until Close.
+func (n *Notifier) Start() {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		for {
+			select {
+			case ev := <-n.queue:
+				n.post(ev)
+			case <-n.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Close stops posting the events, and watching the peers.
+func (n *Notifier) Close() error {
+	n.cancel()
+	n.wg.Wait()
+	return nil
+}
+
+// Notify queues an event. It never blocks: the event is dropped when the
+// queue is full. Notify does nothing on a nil Notifier.
+func (n *Notifier) Notify(event string, data interface{}) {
+	if n == nil {
+		return
+	}
+	ev := Event{Event: event, Time: time.Now(), Node: n.opts.Node, Data: data}
+	select {
+	case n.queue <- ev:
+	default:
+		log.Warnf("dropping the %s event, the webhooks don't keep up", event)
+	}
+}
+
+// WatchPeers counts the connected peers every interval until Close, and fires
+// EventPeersLow and EventPeersHigh when the count crosses low or high. A zero
+// threshold is ignored. No event is fired for a node that starts with few
+// peers, until it had at least low of them.
+func (n *Notifier) WatchPeers(count func() int, low, high int, interval time.Duration) {
+	if low <= 0 && high <= 0 {
+		return
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		below, above := true, false
+		for {
+			select {
+			case <-ticker.C:
+			case <-n.ctx.Done():
+				return
+			}
+			peers := count()
+			if low > 0 {
+				if peers < low && !below {
+					n.Notify(EventPeersLow, PeersData{Peers: peers, Threshold: low})
+				}
+				below = peers < low
+			}
+			if high > 0 {
+				if peers > high && !above {
+					n.Notify(EventPeersHigh, PeersData{Peers: peers, Threshold: high})
+				}
+				above = peers > high
+			}
+		}
+	}()
+}
+
+func (n *Notifier) post(ev Event) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		log.Errorf("encoding the %s event: %s", ev.Event, err)
+		return
+	}
+	for _, e := range n.opts.Endpoints {
+		if !e.matches(ev.Event) {
+			continue
+		}
+		if err := n.postTo(e, ev.Event, body); err != nil {
+			log.Errorf("posting the %s event to %s: %s", ev.Event, e.URL, err)
+		}
+	}
+}
+
+func (n *Notifier) postTo(e Endpoint, event string, body []byte) error {
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	if len(e.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(e.Secret, body))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the X-Ipfs-Signature of body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
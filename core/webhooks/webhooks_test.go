@@ -0,0 +1,65 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	event     string
+	signature string
+	body      []byte
+}
+
+func TestNotify(t *testing.T) {
+	reqs := make(chan request, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		reqs <- request{r.Header.Get(EventHeader), r.Header.Get(SignatureHeader), body}
+	}))
+	defer srv.Close()
+
+	secret := []byte("secret")
+	n, err := New(Options{
+		Node: "node",
+		Endpoints: []Endpoint{
+			{URL: srv.URL, Events: []string{"pin.*"}, Secret: secret},
+			{URL: srv.URL + "/gc", Events: []string{EventGCCompleted}},
+		},
+		Timeout: time.Second,
+	})
+	require.NoError(t, err)
+	n.Start()
+	defer n.Close()
+
+	n.Notify(EventIPNSPublished, IPNSData{Name: "name", Value: "/ipfs/cid"})
+	n.Notify(EventPinCompleted, PinData{Cid: "cid", Recursive: true})
+
+	r := <-reqs
+	require.Equal(t, EventPinCompleted, r.event)
+	require.Equal(t, Sign(secret, r.body), r.signature)
+	var ev Event
+	require.NoError(t, json.Unmarshal(r.body, &ev))
+	require.Equal(t, "node", ev.Node)
+	require.Equal(t, map[string]interface{}{"Cid": "cid", "Recursive": true}, ev.Data)
+
+	n.Notify(EventGCCompleted, GCData{Removed: 3})
+	r = <-reqs
+	require.Equal(t, EventGCCompleted, r.event)
+	require.Empty(t, r.signature)
+	require.Empty(t, reqs)
+}
+
+func TestNewInvalid(t *testing.T) {
+	_, err := New(Options{Endpoints: []Endpoint{{URL: "ftp://example.com"}}})
+	require.Error(t, err)
+	_, err = New(Options{Endpoints: []Endpoint{{URL: "http://example.com", Events: []string{"["}}}})
+	require.Error(t, err)
+}
@@ -4,11 +4,10 @@
 package node
 
 import (
-	"errors"
-
 	core "github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/subsystem"
 )
 
 func Mount(node *core.IpfsNode, fsdir, nsdir string) error {
-	return errors.New("not compiled in")
+	return subsystem.Check(subsystem.FUSE)
 }
@@ -6,9 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	bserv "github.com/ipfs/boxo/blockservice"
 	bstore "github.com/ipfs/boxo/blockstore"
+	dshelp "github.com/ipfs/boxo/datastore/dshelp"
 	offline "github.com/ipfs/boxo/exchange/offline"
 	dag "github.com/ipfs/boxo/ipld/merkledag"
 	pin "github.com/ipfs/boxo/pinning/pinner"
@@ -17,17 +20,74 @@ import (
 	dstore "github.com/ipfs/go-datastore"
 	ipld "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log"
+	"github.com/ipfs/kubo/repo"
 )
 
 var log = logging.Logger("gc")
 
 // Result represents an incremental output from a garbage collection
-// run.  It contains either an error, or the cid of a removed object.
+// run.  It contains either an error, the cid of a removed object, or the
+// progress of the sweep when Options.Progress is set.
 type Result struct {
 	KeyRemoved cid.Cid
 	Error      error
+	Shard      *ShardProgress
 }
 
+// ShardProgress reports a shard of the blockstore swept by a garbage
+// collection.
+type ShardProgress struct {
+	Name string
+	// Keys is the number of blocks in the shard, Removed the number of
+	// those removed.
+	Keys    int
+	Removed int
+	// Done is the number of shards swept so far, out of Total.
+	Done  int
+	Total int
+}
+
+// Options configures the sweep of a garbage collection.
+type Options struct {
+	// Shards lists the keys of the blockstore one shard at a time, in place
+	// of AllKeysChan. Its keys are those the blockstore stores the blocks
+	// under.
+	Shards repo.ShardedDatastore
+	// Concurrency is the number of shards listed and swept at the same
+	// time, or without Shards, the number of blocks deleted at the same
+	// time. It defaults to 1.
+	Concurrency int
+	// Progress adds a Result with a ShardProgress after each shard swept.
+	Progress bool
+	// Stats, when set, is filled in with the figures of the garbage
+	// collection before its output is closed.
+	Stats *Stats
+}
+
+// Reasons the blocks are marked for, as counted by Stats.Marked.
+const (
+	MarkedRecursive  = "recursive"
+	MarkedBestEffort = "best-effort"
+	MarkedDirect     = "direct"
+	MarkedInternal   = "internal"
+	MarkedOther      = "other"
+)
+
+// Stats are the figures of a garbage collection.
+type Stats struct {
+	// Marked is the number of blocks kept, by the first reason they were
+	// marked for: MarkedRecursive, MarkedBestEffort, MarkedDirect,
+	// MarkedInternal, or MarkedOther for the markers.
+	Marked map[string]int
+	// Removed is the number of blocks removed, and RemovedBytes their size.
+	Removed      int
+	RemovedBytes uint64
+}
+
+// Marker adds blocks to the marked set of a garbage collection. Markers run
+// while the blockstore is locked for the garbage collection.
+type Marker func(ctx context.Context, ng ipld.NodeGetter, marked *cid.Set) error
+
 // converts a set of CIDs with different codecs to a set of CIDs with the raw codec.
 func toRawCids(set *cid.Set) (*cid.Set, error) {
 	newSet := cid.NewSet()
@@ -44,10 +104,16 @@ func toRawCids(set *cid.Set) (*cid.Set, error) {
 // - bestEffortRoots, plus all of its descendants (recursively)
 // - all directly pinned blocks
 // - all blocks utilized internally by the pinner
+// - all blocks added by the markers
 //
 // The routine then iterates over every block in the blockstore and
 // deletes any block that is not found in the marked set.
-func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn pin.Pinner, bestEffortRoots []cid.Cid) <-chan Result {
+func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn pin.Pinner, bestEffortRoots []cid.Cid, markers ...Marker) <-chan Result {
+	return GCWithOptions(ctx, bs, dstor, pn, bestEffortRoots, Options{}, markers...)
+}
+
+// GCWithOptions is GC, with the sweep configured by opts.
+func GCWithOptions(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn pin.Pinner, bestEffortRoots []cid.Cid, opts Options, markers ...Marker) <-chan Result {
 	ctx, cancel := context.WithCancel(ctx)
 
 	unlocker := bs.GCLock(ctx)
@@ -62,7 +128,7 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 		defer close(output)
 		defer unlocker.Unlock(ctx)
 
-		gcs, err := ColoredSet(ctx, pn, ds, bestEffortRoots, output)
+		gcs, err := coloredSet(ctx, pn, ds, bestEffortRoots, output, opts.Stats)
 		if err != nil {
 			select {
 			case output <- Result{Error: err}:
@@ -70,6 +136,19 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 			}
 			return
 		}
+		marked := gcs.Len()
+		for _, mark := range markers {
+			if err := mark(ctx, ds, gcs); err != nil {
+				select {
+				case output <- Result{Error: err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+		if opts.Stats != nil && opts.Stats.Marked != nil {
+			opts.Stats.Marked[MarkedOther] = gcs.Len() - marked
+		}
 
 		// The blockstore reports raw blocks. We need to remove the codecs from the CIDs.
 		gcs, err = toRawCids(gcs)
@@ -81,7 +160,7 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 			return
 		}
 
-		keychan, err := bs.AllKeysChan(ctx)
+		errors, err := sweep(ctx, bs, gcs, opts, output)
 		if err != nil {
 			select {
 			case output <- Result{Error: err}:
@@ -89,42 +168,6 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 			}
 			return
 		}
-
-		errors := false
-		var removed uint64
-
-	loop:
-		for ctx.Err() == nil { // select may not notice that we're "done".
-			select {
-			case k, ok := <-keychan:
-				if !ok {
-					break loop
-				}
-				// NOTE: assumes that all CIDs returned by the keychan are _raw_ CIDv1 CIDs.
-				// This means we keep the block as long as we want it somewhere (CIDv1, CIDv0, Raw, other...).
-				if !gcs.Has(k) {
-					err := bs.DeleteBlock(ctx, k)
-					removed++
-					if err != nil {
-						errors = true
-						select {
-						case output <- Result{Error: &CannotDeleteBlockError{k, err}}:
-						case <-ctx.Done():
-							break loop
-						}
-						// continue as error is non-fatal
-						continue loop
-					}
-					select {
-					case output <- Result{KeyRemoved: k}:
-					case <-ctx.Done():
-						break loop
-					}
-				}
-			case <-ctx.Done():
-				break loop
-			}
-		}
 		if errors {
 			select {
 			case output <- Result{Error: ErrCannotDeleteSomeBlocks}:
@@ -151,6 +194,141 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 	return output
 }
 
+// sweep deletes the blocks of bs that aren't marked, and reports whether some
+// couldn't be deleted.
+func sweep(ctx context.Context, bs bstore.GCBlockstore, marked *cid.Set, opts Options, output chan<- Result) (bool, error) {
+	var failed atomic.Bool
+	send := func(res Result) bool {
+		select {
+		case output <- res:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	var removedCount, removedBytes atomic.Int64
+	if opts.Stats != nil {
+		defer func() {
+			opts.Stats.Removed = int(removedCount.Load())
+			opts.Stats.RemovedBytes = uint64(removedBytes.Load())
+		}()
+	}
+	// remove deletes k unless it is marked, and returns whether it was
+	// deleted, and false for ok once the garbage collection is cancelled.
+	remove := func(k cid.Cid) (removed, ok bool) {
+		// NOTE: assumes that all the keys are _raw_ CIDv1 CIDs.
+		// This means we keep the block as long as we want it somewhere (CIDv1, CIDv0, Raw, other...).
+		if marked.Has(k) {
+			return false, true
+		}
+		size := 0
+		if opts.Stats != nil {
+			// the size is only reported, a block of unknown size is removed
+			size, _ = bs.GetSize(ctx, k)
+		}
+		if err := bs.DeleteBlock(ctx, k); err != nil {
+			failed.Store(true)
+			// continue as error is non-fatal
+			return false, send(Result{Error: &CannotDeleteBlockError{k, err}})
+		}
+		removedCount.Add(1)
+		if size > 0 {
+			removedBytes.Add(int64(size))
+		}
+		return true, send(Result{KeyRemoved: k})
+	}
+
+	workers := opts.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+
+	if opts.Shards == nil {
+		keychan, err := bs.AllKeysChan(ctx)
+		if err != nil {
+			return false, err
+		}
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for ctx.Err() == nil { // select may not notice that we're "done".
+					select {
+					case k, ok := <-keychan:
+						if !ok {
+							return
+						}
+						if _, ok := remove(k); !ok {
+							return
+						}
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		return failed.Load(), nil
+	}
+
+	shards, err := opts.Shards.Shards(ctx)
+	if err != nil {
+		return false, err
+	}
+	shardchan := make(chan string)
+	var done atomic.Int64
+	sweepShard := func(name string) bool {
+		keys, err := opts.Shards.ShardKeys(ctx, name)
+		if err != nil {
+			failed.Store(true)
+			return send(Result{Error: fmt.Errorf("could not list shard %s: %w", name, err)})
+		}
+		progress := ShardProgress{Name: name, Keys: len(keys), Total: len(shards)}
+		for _, key := range keys {
+			if ctx.Err() != nil {
+				return false
+			}
+			mh, err := dshelp.BinaryFromDsKey(key)
+			if err != nil {
+				log.Warnf("error parsing key from binary: %s", err)
+				continue
+			}
+			removed, ok := remove(cid.NewCidV1(cid.Raw, mh))
+			if !ok {
+				return false
+			}
+			if removed {
+				progress.Removed++
+			}
+		}
+		progress.Done = int(done.Add(1))
+		return !opts.Progress || send(Result{Shard: &progress})
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range shardchan {
+				if !sweepShard(name) {
+					return
+				}
+			}
+		}()
+	}
+loop:
+	for _, name := range shards {
+		select {
+		case shardchan <- name:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(shardchan)
+	wg.Wait()
+	return failed.Load(), nil
+}
+
 // Descendants recursively finds all the descendants of the given roots and
 // adds them to the given cid.Set, using the provided dag.GetLinks function
 // to walk the tree.
@@ -199,6 +377,24 @@ func Descendants(ctx context.Context, getLinks dag.GetLinks, set *cid.Set, roots
 	}
 }
 
+// MarkBestEffort adds the given roots and their descendants to marked,
+// skipping the nodes that aren't stored locally.
+func MarkBestEffort(ctx context.Context, ng ipld.NodeGetter, roots []cid.Cid, marked *cid.Set) error {
+	getLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+		links, err := ipld.GetLinks(ctx, ng, c)
+		if ipld.IsNotFound(err) {
+			return nil, nil
+		}
+		return links, err
+	}
+	rootsChan := make(chan pin.StreamedPin, len(roots))
+	for _, root := range roots {
+		rootsChan <- pin.StreamedPin{Pin: pin.Pinned{Key: root}}
+	}
+	close(rootsChan)
+	return Descendants(ctx, getLinks, marked, rootsChan)
+}
+
 // toCidV1 converts any CIDv0s to CIDv1s.
 func toCidV1(c cid.Cid) cid.Cid {
 	if c.Version() == 0 {
@@ -210,6 +406,23 @@ func toCidV1(c cid.Cid) cid.Cid {
 // ColoredSet computes the set of nodes in the graph that are pinned by the
 // pins in the given pinner.
 func ColoredSet(ctx context.Context, pn pin.Pinner, ng ipld.NodeGetter, bestEffortRoots []cid.Cid, output chan<- Result) (*cid.Set, error) {
+	return coloredSet(ctx, pn, ng, bestEffortRoots, output, nil)
+}
+
+// coloredSet is ColoredSet, counting the blocks marked for each reason in
+// stats, if set.
+func coloredSet(ctx context.Context, pn pin.Pinner, ng ipld.NodeGetter, bestEffortRoots []cid.Cid, output chan<- Result, stats *Stats) (*cid.Set, error) {
+	marked := 0
+	count := func(reason string, set *cid.Set) {
+		if stats == nil {
+			return
+		}
+		if stats.Marked == nil {
+			stats.Marked = make(map[string]int)
+		}
+		stats.Marked[reason] = set.Len() - marked
+		marked = set.Len()
+	}
 	// KeySet currently implemented in memory, in the future, may be bloom filter or
 	// disk backed to conserve memory.
 	errors := false
@@ -237,6 +450,8 @@ func ColoredSet(ctx context.Context, pn pin.Pinner, ng ipld.NodeGetter, bestEffo
 		}
 	}
 
+	count(MarkedRecursive, gcs)
+
 	bestEffortGetLinks := func(ctx context.Context, cid cid.Cid) ([]*ipld.Link, error) {
 		links, err := ipld.GetLinks(ctx, ng, cid)
 		if err != nil && !ipld.IsNotFound(err) {
@@ -270,6 +485,8 @@ func ColoredSet(ctx context.Context, pn pin.Pinner, ng ipld.NodeGetter, bestEffo
 		}
 	}
 
+	count(MarkedBestEffort, gcs)
+
 	dkeys := pn.DirectKeys(ctx, false)
 	for k := range dkeys {
 		if k.Err != nil {
@@ -277,6 +494,7 @@ func ColoredSet(ctx context.Context, pn pin.Pinner, ng ipld.NodeGetter, bestEffo
 		}
 		gcs.Add(toCidV1(k.Pin.Key))
 	}
+	count(MarkedDirect, gcs)
 
 	ikeys := pn.InternalPins(ctx, false)
 	err = Descendants(ctx, getLinks, gcs, ikeys)
@@ -289,6 +507,8 @@ func ColoredSet(ctx context.Context, pn pin.Pinner, ng ipld.NodeGetter, bestEffo
 		}
 	}
 
+	count(MarkedInternal, gcs)
+
 	if errors {
 		return nil, ErrCannotFetchAllLinks
 	}
@@ -13,7 +13,9 @@ import (
 	"github.com/ipfs/boxo/pinning/pinner/dspinner"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
 	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
 	"github.com/multiformats/go-multihash"
 	"github.com/stretchr/testify/require"
 )
@@ -87,6 +89,159 @@ func TestGC(t *testing.T) {
 	require.ElementsMatch(t, expectedKept, kept)
 }
 
+// shards spreads the keys of the blockstore over shards like the flatfs
+// shard function next-to-last/2.
+type shards struct {
+	ds datastore.Datastore
+}
+
+func (s shards) keys(ctx context.Context) (map[string][]datastore.Key, error) {
+	res, err := s.ds.Query(ctx, query.Query{Prefix: blockstore.BlockPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string][]datastore.Key)
+	for _, e := range entries {
+		k := datastore.NewKey(e.Key).BaseNamespace()
+		shard := k[len(k)-3 : len(k)-1]
+		keys[shard] = append(keys[shard], datastore.NewKey(k))
+	}
+	return keys, nil
+}
+
+func (s shards) Shards(ctx context.Context) ([]string, error) {
+	keys, err := s.keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range keys {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (s shards) ShardKeys(ctx context.Context, shard string) ([]datastore.Key, error) {
+	keys, err := s.keys(ctx)
+	return keys[shard], err
+}
+
+func TestGCShards(t *testing.T) {
+	ctx := context.Background()
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	bs := blockstore.NewGCBlockstore(blockstore.NewBlockstore(ds), blockstore.NewGCLocker())
+	bserv := blockservice.New(bs, offline.Exchange(bs))
+	dserv := merkledag.NewDAGService(bserv)
+	pinner, err := dspinner.New(ctx, ds, dserv)
+	require.NoError(t, err)
+
+	daggen := mdutils.NewDAGGenerator()
+
+	var expectedKept []multihash.Multihash
+	var expectedDiscarded []multihash.Multihash
+	for i := 0; i < 5; i++ {
+		root, allCids, err := daggen.MakeDagNode(dserv.Add, 5, 2)
+		require.NoError(t, err)
+		err = pinner.PinWithMode(ctx, root, pin.Recursive, "")
+		require.NoError(t, err)
+		expectedKept = append(expectedKept, toMHs(allCids)...)
+
+		_, allCids, err = daggen.MakeDagNode(dserv.Add, 5, 2)
+		require.NoError(t, err)
+		expectedDiscarded = append(expectedDiscarded, toMHs(allCids)...)
+	}
+	err = pinner.Flush(ctx)
+	require.NoError(t, err)
+
+	total, err := shards{ds}.Shards(ctx)
+	require.NoError(t, err)
+
+	opts := Options{Shards: shards{ds}, Concurrency: 4, Progress: true}
+	ch := GCWithOptions(ctx, bs, ds, pinner, nil, opts)
+	var discarded []multihash.Multihash
+	swept := make(map[string]bool)
+	for res := range ch {
+		require.NoError(t, res.Error)
+		if res.Shard != nil {
+			require.False(t, swept[res.Shard.Name])
+			require.Equal(t, len(total), res.Shard.Total)
+			swept[res.Shard.Name] = true
+			continue
+		}
+		discarded = append(discarded, res.KeyRemoved.Hash())
+	}
+	require.Len(t, swept, len(total))
+
+	allKeys, err := bs.AllKeysChan(ctx)
+	require.NoError(t, err)
+	var kept []multihash.Multihash
+	for key := range allKeys {
+		kept = append(kept, key.Hash())
+	}
+
+	require.ElementsMatch(t, expectedDiscarded, discarded)
+	require.ElementsMatch(t, expectedKept, kept)
+}
+
+func TestGCStats(t *testing.T) {
+	ctx := context.Background()
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	bs := blockstore.NewGCBlockstore(blockstore.NewBlockstore(ds), blockstore.NewGCLocker())
+	bserv := blockservice.New(bs, offline.Exchange(bs))
+	dserv := merkledag.NewDAGService(bserv)
+	pinner, err := dspinner.New(ctx, ds, dserv)
+	require.NoError(t, err)
+
+	daggen := mdutils.NewDAGGenerator()
+
+	root, recursive, err := daggen.MakeDagNode(dserv.Add, 3, 2)
+	require.NoError(t, err)
+	require.NoError(t, pinner.PinWithMode(ctx, root, pin.Recursive, ""))
+	direct, _, err := daggen.MakeDagNode(dserv.Add, 0, 1)
+	require.NoError(t, err)
+	require.NoError(t, pinner.PinWithMode(ctx, direct, pin.Direct, ""))
+	require.NoError(t, pinner.Flush(ctx))
+	bestEffort, bestEffortCids, err := daggen.MakeDagNode(dserv.Add, 2, 2)
+	require.NoError(t, err)
+
+	// the root of the discarded DAG is kept by a marker
+	other, discarded, err := daggen.MakeDagNode(dserv.Add, 3, 2)
+	require.NoError(t, err)
+	var freed uint64
+	for _, c := range discarded {
+		if !c.Equals(other) {
+			size, err := bs.GetSize(ctx, c)
+			require.NoError(t, err)
+			freed += uint64(size)
+		}
+	}
+	mark := func(ctx context.Context, ng ipld.NodeGetter, marked *cid.Set) error {
+		marked.Add(other)
+		return nil
+	}
+
+	stats := &Stats{}
+	for res := range GCWithOptions(ctx, bs, ds, pinner, []cid.Cid{bestEffort}, Options{Stats: stats}, mark) {
+		require.NoError(t, res.Error)
+	}
+
+	require.Equal(t, map[string]int{
+		MarkedRecursive:  len(recursive),
+		MarkedBestEffort: len(bestEffortCids),
+		MarkedDirect:     1,
+		MarkedInternal:   0,
+		MarkedOther:      1,
+	}, stats.Marked)
+	require.Equal(t, len(discarded)-1, stats.Removed)
+	require.Equal(t, freed, stats.RemovedBytes)
+}
+
 func toMHs(cids []cid.Cid) []multihash.Multihash {
 	res := make([]multihash.Multihash, len(cids))
 	for i, c := range cids {
@@ -3,9 +3,11 @@ package p2p
 import (
 	"errors"
 	"sync"
+	"sync/atomic"
 
 	p2phost "github.com/libp2p/go-libp2p/core/host"
 	net "github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	ma "github.com/multiformats/go-multiaddr"
 )
@@ -16,12 +18,28 @@ type Listener interface {
 	ListenAddress() ma.Multiaddr
 	TargetAddress() ma.Multiaddr
 
+	// AllowedPeers returns the peers allowed to open streams to the
+	// listener, all of them when empty. Forwards to remote peers don't
+	// accept streams and return nil.
+	AllowedPeers() []peer.ID
+
+	// Counters returns the bytes forwarded by the streams of the listener.
+	Counters() *Counters
+
 	key() protocol.ID
 
 	// close closes the listener. Does not affect child streams
 	close()
 }
 
+// Counters count the bytes forwarded by a listener or a stream.
+type Counters struct {
+	// In counts the bytes received from the remote peers.
+	In atomic.Uint64
+	// Out counts the bytes sent to the remote peers.
+	Out atomic.Uint64
+}
+
 // Listeners manages a group of Listener implementations,
 // checking for conflicts and optionally dispatching connections.
 type Listeners struct {
@@ -23,6 +23,8 @@ type localListener struct {
 	peer  peer.ID
 
 	listener manet.Listener
+
+	counters Counters
 }
 
 // ForwardLocal creates new P2P stream to a remote listener.
@@ -91,11 +93,20 @@ func (l *localListener) setupStream(local manet.Conn) {
 		Remote: remote,
 
 		Registry: l.p2p.Streams,
+		listener: &l.counters,
 	}
 
 	l.p2p.Streams.Register(stream)
 }
 
+func (l *localListener) AllowedPeers() []peer.ID {
+	return nil
+}
+
+func (l *localListener) Counters() *Counters {
+	return &l.counters
+}
+
 func (l *localListener) close() {
 	l.listener.Close()
 }
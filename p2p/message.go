@@ -0,0 +1,178 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	net "github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// messageTimeout is how long a handled message stream may take, from the
+// message to the reply.
+const messageTimeout = time.Minute
+
+// Status of the replies to messages, sent before their payload.
+const (
+	replyOK    byte = 0
+	replyError byte = 1
+)
+
+// MessageHandler answers the messages received on a protocol. The returned
+// data is sent back as the reply, or the error message when it fails.
+type MessageHandler func(ctx context.Context, from peer.ID, data []byte) ([]byte, error)
+
+// messageHandler identifies a registration of HandleMessages.
+type messageHandler struct{}
+
+// RemoteError is the error returned by the handler of the remote peer.
+type RemoteError struct {
+	Peer    peer.ID
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("peer %s: %s", e.Peer, e.Message)
+}
+
+// SendMessage sends data to the peer p on the protocol proto, and returns the
+// reply of its handler, refusing replies over maxReply bytes.
+//
+// A message is a single stream: the length prefixed data, answered with a
+// status byte and the length prefixed reply.
+func (p2p *P2P) SendMessage(ctx context.Context, p peer.ID, proto protocol.ID, data []byte, maxReply int) ([]byte, error) {
+	s, err := p2p.peerHost.NewStream(ctx, p, proto)
+	if err != nil {
+		return nil, err
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		_ = s.SetDeadline(dl)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = s.Reset()
+		case <-done:
+		}
+	}()
+
+	werr := writeMessage(s, data)
+	if werr == nil {
+		werr = s.CloseWrite()
+	}
+
+	// the handler may have refused the message without reading it, its
+	// reply tells why
+	r := bufio.NewReader(s)
+	status, err := r.ReadByte()
+	if err != nil {
+		_ = s.Reset()
+		if werr != nil {
+			err = werr
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("sending message to %s: %w", p, err)
+	}
+	reply, err := readMessage(r, maxReply)
+	if err != nil {
+		_ = s.Reset()
+		return nil, fmt.Errorf("reading reply of %s: %w", p, err)
+	}
+	_ = s.Close()
+
+	if status == replyError {
+		return nil, &RemoteError{Peer: p, Message: string(reply)}
+	}
+	return reply, nil
+}
+
+// HandleMessages answers the messages received on the protocol proto with
+// handler, refusing the messages over maxSize bytes, until ctx is done.
+func (p2p *P2P) HandleMessages(ctx context.Context, proto protocol.ID, handler MessageHandler, maxSize int) error {
+	p2p.handlersLk.Lock()
+	defer p2p.handlersLk.Unlock()
+	if p2p.CheckProtoExists(proto) {
+		return fmt.Errorf("protocol %s is already handled", proto)
+	}
+
+	h := &messageHandler{}
+	p2p.handlers[proto] = h
+	p2p.peerHost.SetStreamHandler(proto, func(s net.Stream) {
+		p2p.handleMessage(ctx, s, handler, maxSize)
+	})
+	go func() {
+		<-ctx.Done()
+		p2p.handlersLk.Lock()
+		defer p2p.handlersLk.Unlock()
+		// unless the protocol was handled again since
+		if p2p.handlers[proto] == h {
+			delete(p2p.handlers, proto)
+			p2p.peerHost.RemoveStreamHandler(proto)
+		}
+	}()
+	return nil
+}
+
+func (p2p *P2P) handleMessage(ctx context.Context, s net.Stream, handler MessageHandler, maxSize int) {
+	ctx, cancel := context.WithTimeout(ctx, messageTimeout)
+	defer cancel()
+	_ = s.SetDeadline(time.Now().Add(messageTimeout))
+
+	from := s.Conn().RemotePeer()
+	data, err := readMessage(bufio.NewReader(s), maxSize)
+	var reply []byte
+	if err == nil {
+		reply, err = handler(ctx, from, data)
+	}
+
+	status := replyOK
+	if err != nil {
+		status, reply = replyError, []byte(err.Error())
+	}
+	if _, err := s.Write([]byte{status}); err != nil {
+		_ = s.Reset()
+		return
+	}
+	if err := writeMessage(s, reply); err != nil {
+		log.Debugf("replying to the message of %s on %s: %s", from, s.Protocol(), err)
+		_ = s.Reset()
+		return
+	}
+	_ = s.Close()
+}
+
+// writeMessage writes the length prefixed data.
+func writeMessage(w io.Writer, data []byte) error {
+	buf := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(data)), uint64(len(data)))
+	_, err := w.Write(append(buf, data...))
+	return err
+}
+
+// readMessage reads length prefixed data, of at most max bytes.
+func readMessage(r *bufio.Reader, max int) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if n > uint64(max) {
+		return nil, fmt.Errorf("message of %d bytes over the limit of %d bytes", n, max)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -1,6 +1,8 @@
 package p2p
 
 import (
+	"sync"
+
 	logging "github.com/ipfs/go-log"
 	p2phost "github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -19,6 +21,9 @@ type P2P struct {
 	identity  peer.ID
 	peerHost  p2phost.Host
 	peerstore pstore.Peerstore
+
+	handlersLk sync.Mutex
+	handlers   map[protocol.ID]*messageHandler
 }
 
 // New creates new P2P struct.
@@ -36,6 +41,8 @@ func New(identity peer.ID, peerHost p2phost.Host, peerstore pstore.Peerstore) *P
 			ConnManager: peerHost.ConnManager(),
 			conns:       map[peer.ID]int{},
 		},
+
+		handlers: map[protocol.ID]*messageHandler{},
 	}
 }
 
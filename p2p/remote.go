@@ -3,8 +3,10 @@ package p2p
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	net "github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
@@ -25,10 +27,16 @@ type remoteListener struct {
 	// reportRemote if set to true makes the handler send '<base58 remote peerid>\n'
 	// to target before any data is forwarded
 	reportRemote bool
+
+	// allowed are the peers allowed to open streams, all of them when nil
+	allowed map[peer.ID]struct{}
+
+	counters Counters
 }
 
-// ForwardRemote creates new p2p listener.
-func (p2p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, addr ma.Multiaddr, reportRemote bool) (Listener, error) {
+// ForwardRemote creates new p2p listener. Only the allowed peers may open
+// streams to it, or all of them when allowed is empty.
+func (p2p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, addr ma.Multiaddr, reportRemote bool, allowed []peer.ID) (Listener, error) {
 	listener := &remoteListener{
 		p2p: p2p,
 
@@ -37,6 +45,12 @@ func (p2p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, addr ma.Mu
 
 		reportRemote: reportRemote,
 	}
+	if len(allowed) > 0 {
+		listener.allowed = make(map[peer.ID]struct{}, len(allowed))
+		for _, p := range allowed {
+			listener.allowed[p] = struct{}{}
+		}
+	}
 
 	if err := p2p.ListenersP2P.Register(listener); err != nil {
 		return nil, err
@@ -46,14 +60,21 @@ func (p2p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, addr ma.Mu
 }
 
 func (l *remoteListener) handleStream(remote net.Stream) {
+	peer := remote.Conn().RemotePeer()
+	if l.allowed != nil {
+		if _, ok := l.allowed[peer]; !ok {
+			log.Debugf("refused stream of %s on %s", peer, l.proto)
+			_ = remote.Reset()
+			return
+		}
+	}
+
 	local, err := manet.Dial(l.addr)
 	if err != nil {
 		_ = remote.Reset()
 		return
 	}
 
-	peer := remote.Conn().RemotePeer()
-
 	if l.reportRemote {
 		if _, err := fmt.Fprintf(local, "%s\n", peer); err != nil {
 			_ = remote.Reset()
@@ -78,6 +99,7 @@ func (l *remoteListener) handleStream(remote net.Stream) {
 		Remote: remote,
 
 		Registry: l.p2p.Streams,
+		listener: &l.counters,
 	}
 
 	l.p2p.Streams.Register(stream)
@@ -99,6 +121,22 @@ func (l *remoteListener) TargetAddress() ma.Multiaddr {
 	return l.addr
 }
 
+func (l *remoteListener) AllowedPeers() []peer.ID {
+	if l.allowed == nil {
+		return nil
+	}
+	peers := make([]peer.ID, 0, len(l.allowed))
+	for p := range l.allowed {
+		peers = append(peers, p)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+	return peers
+}
+
+func (l *remoteListener) Counters() *Counters {
+	return &l.counters
+}
+
 func (l *remoteListener) close() {}
 
 func (l *remoteListener) key() protocol.ID {
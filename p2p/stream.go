@@ -3,6 +3,7 @@ package p2p
 import (
 	"io"
 	"sync"
+	"sync/atomic"
 
 	ifconnmgr "github.com/libp2p/go-libp2p/core/connmgr"
 	net "github.com/libp2p/go-libp2p/core/network"
@@ -28,6 +29,11 @@ type Stream struct {
 	Remote net.Stream
 
 	Registry *StreamRegistry
+
+	// Counters count the bytes forwarded by the stream.
+	Counters Counters
+	// listener are the counters of the listener of the stream.
+	listener *Counters
 }
 
 // close stream endpoints and deregister it.
@@ -42,7 +48,7 @@ func (s *Stream) reset() {
 
 func (s *Stream) startStreaming() {
 	go func() {
-		_, err := io.Copy(s.Local, s.Remote)
+		_, err := io.Copy(&countingWriter{w: s.Local, counts: []*atomic.Uint64{&s.Counters.In, s.listenerCounter(true)}}, s.Remote)
 		if err != nil {
 			s.reset()
 		} else {
@@ -51,7 +57,7 @@ func (s *Stream) startStreaming() {
 	}()
 
 	go func() {
-		_, err := io.Copy(s.Remote, s.Local)
+		_, err := io.Copy(&countingWriter{w: s.Remote, counts: []*atomic.Uint64{&s.Counters.Out, s.listenerCounter(false)}}, s.Local)
 		if err != nil {
 			s.reset()
 		} else {
@@ -60,6 +66,34 @@ func (s *Stream) startStreaming() {
 	}()
 }
 
+// listenerCounter returns the In or Out counter of the listener of the
+// stream.
+func (s *Stream) listenerCounter(in bool) *atomic.Uint64 {
+	if s.listener == nil {
+		return nil
+	}
+	if in {
+		return &s.listener.In
+	}
+	return &s.listener.Out
+}
+
+// countingWriter adds the bytes written to w to counts.
+type countingWriter struct {
+	w      io.Writer
+	counts []*atomic.Uint64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	for _, cnt := range c.counts {
+		if cnt != nil {
+			cnt.Add(uint64(n))
+		}
+	}
+	return n, err
+}
+
 // StreamRegistry is a collection of active incoming and outgoing proto app streams.
 type StreamRegistry struct {
 	sync.Mutex
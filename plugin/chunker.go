@@ -0,0 +1,16 @@
+package plugin
+
+import (
+	"github.com/ipfs/kubo/core/chunker"
+)
+
+// PluginChunker is an interface that can be implemented to add chunkers,
+// selected with the chunker specs of 'ipfs add --chunker' like the builtin
+// chunkers.
+type PluginChunker interface {
+	Plugin
+
+	// Chunkers returns the constructors of the chunkers, by name. The name
+	// "fastq" selects the chunker for the specs "fastq" and "fastq-[params]".
+	Chunkers() map[string]chunker.Constructor
+}
@@ -0,0 +1,14 @@
+package plugin
+
+import (
+	"github.com/ipfs/kubo/core/commands/cmdhooks"
+)
+
+// PluginCommandMiddleware is an interface that can be implemented to run a
+// middleware around every RPC command invocation, e.g. to write an audit log
+// or to deny some commands to some callers.
+type PluginCommandMiddleware interface {
+	Plugin
+
+	CommandMiddleware() (cmdhooks.Middleware, error)
+}
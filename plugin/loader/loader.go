@@ -13,6 +13,8 @@ import (
 	"github.com/ipld/go-ipld-prime/multicodec"
 
 	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/chunker"
+	"github.com/ipfs/kubo/core/commands/cmdhooks"
 	"github.com/ipfs/kubo/core/coreapi"
 	plugin "github.com/ipfs/kubo/plugin"
 	fsrepo "github.com/ipfs/kubo/repo/fsrepo"
@@ -278,6 +280,13 @@ func (loader *PluginLoader) Inject() error {
 				return err
 			}
 		}
+		if pl, ok := pl.(plugin.PluginChunker); ok {
+			err := injectChunkerPlugin(pl)
+			if err != nil {
+				loader.state = loaderFailed
+				return err
+			}
+		}
 		if pl, ok := pl.(plugin.PluginTracer); ok {
 			err := injectTracerPlugin(pl)
 			if err != nil {
@@ -299,6 +308,13 @@ func (loader *PluginLoader) Inject() error {
 				return err
 			}
 		}
+		if pl, ok := pl.(plugin.PluginCommandMiddleware); ok {
+			err := injectCommandMiddlewarePlugin(pl)
+			if err != nil {
+				loader.state = loaderFailed
+				return err
+			}
+		}
 	}
 
 	return loader.transition(loaderInjecting, loaderInjected)
@@ -375,6 +391,15 @@ func injectIPLDPlugin(pl plugin.PluginIPLD) error {
 	return pl.Register(multicodec.DefaultRegistry)
 }
 
+func injectChunkerPlugin(pl plugin.PluginChunker) error {
+	for name, c := range pl.Chunkers() {
+		if err := chunker.Register(name, c); err != nil {
+			return fmt.Errorf("plugin %s: %w", pl.Name(), err)
+		}
+	}
+	return nil
+}
+
 func injectTracerPlugin(pl plugin.PluginTracer) error {
 	log.Warn("Tracer plugins are deprecated, it's recommended to configure an OpenTelemetry collector instead.")
 	tracer, err := pl.InitTracer()
@@ -389,3 +414,12 @@ func injectFxPlugin(pl plugin.PluginFx) error {
 	core.RegisterFXOptionFunc(pl.Options)
 	return nil
 }
+
+func injectCommandMiddlewarePlugin(pl plugin.PluginCommandMiddleware) error {
+	m, err := pl.CommandMiddleware()
+	if err != nil {
+		return err
+	}
+	cmdhooks.Register(m)
+	return nil
+}
@@ -1,13 +1,17 @@
 package flatfs
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/ipfs/kubo/plugin"
 	"github.com/ipfs/kubo/repo"
 	"github.com/ipfs/kubo/repo/fsrepo"
 
+	ds "github.com/ipfs/go-datastore"
 	flatfs "github.com/ipfs/go-ds-flatfs"
 )
 
@@ -80,11 +84,71 @@ func (c *datastoreConfig) DiskSpec() fsrepo.DiskSpec {
 	}
 }
 
+// absPath returns the path of the datastore in the repo at path.
+func (c *datastoreConfig) absPath(path string) string {
+	if filepath.IsAbs(c.path) {
+		return c.path
+	}
+	return filepath.Join(path, c.path)
+}
+
 func (c *datastoreConfig) Create(path string) (repo.Datastore, error) {
-	p := c.path
-	if !filepath.IsAbs(p) {
-		p = filepath.Join(path, p)
+	return flatfs.CreateOrOpen(c.absPath(path), c.shardFun, c.syncField)
+}
+
+func (c *datastoreConfig) Sharded(path string, prefix ds.Key) repo.ShardedDatastore {
+	if !prefix.Equal(ds.NewKey("/")) {
+		return nil
+	}
+	return &shardLister{path: c.absPath(path)}
+}
+
+// extension is the extension of the files of flatfs holding values.
+const extension = ".data"
+
+// shardLister lists the keys of flatfs one shard directory at a time.
+type shardLister struct {
+	path string
+}
+
+var _ repo.ShardedDatastore = (*shardLister)(nil)
+
+func (d *shardLister) Shards(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, err
 	}
+	var shards []string
+	for _, e := range entries {
+		// temporary files and directories start with a dot
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			shards = append(shards, e.Name())
+		}
+	}
+	return shards, nil
+}
 
-	return flatfs.CreateOrOpen(p, c.shardFun, c.syncField)
+func (d *shardLister) ShardKeys(ctx context.Context, shard string) ([]ds.Key, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	dir, err := os.Open(filepath.Join(d.path, shard))
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+	// the names aren't sorted, unlike os.ReadDir, which is wasted on
+	// shards holding thousands of files
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]ds.Key, 0, len(names))
+	for _, name := range names {
+		if strings.HasPrefix(name, ".") || !strings.HasSuffix(name, extension) {
+			continue
+		}
+		keys = append(keys, ds.NewKey(strings.TrimSuffix(name, extension)))
+	}
+	return keys, nil
 }
@@ -30,6 +30,24 @@ type DatastoreConfig interface {
 	Create(path string) (repo.Datastore, error)
 }
 
+// ShardedConfig is implemented by the DatastoreConfigs of datastores that can
+// be sharded, or that wrap or mount such datastores.
+type ShardedConfig interface {
+	// Sharded lists the keys of the datastore created from this config in
+	// the repo at path, and mounted at prefix relative to this datastore,
+	// when it is sharded. It returns nil otherwise.
+	Sharded(path string, prefix ds.Key) repo.ShardedDatastore
+}
+
+// sharded returns the ShardedDatastore mounted at prefix in the datastore
+// created from c in the repo at path, if any.
+func sharded(c DatastoreConfig, path string, prefix ds.Key) repo.ShardedDatastore {
+	if sc, ok := c.(ShardedConfig); ok {
+		return sc.Sharded(path, prefix)
+	}
+	return nil
+}
+
 // DiskSpec is a minimal representation of the characteristic values of the
 // datastore. If two diskspecs are the same, the loader assumes that they refer
 // to exactly the same datastore. If they differ at all, it is assumed they are
@@ -161,6 +179,15 @@ func (c *mountDatastoreConfig) Create(path string) (repo.Datastore, error) {
 	return mount.New(mounts), nil
 }
 
+func (c *mountDatastoreConfig) Sharded(path string, prefix ds.Key) repo.ShardedDatastore {
+	for _, m := range c.mounts {
+		if m.prefix.Equal(prefix) {
+			return sharded(m.ds, path, ds.NewKey("/"))
+		}
+	}
+	return nil
+}
+
 type memDatastoreConfig struct {
 	cfg map[string]interface{}
 }
@@ -212,6 +239,10 @@ func (c *logDatastoreConfig) DiskSpec() DiskSpec {
 	return c.child.DiskSpec()
 }
 
+func (c *logDatastoreConfig) Sharded(path string, prefix ds.Key) repo.ShardedDatastore {
+	return sharded(c.child, path, prefix)
+}
+
 type measureDatastoreConfig struct {
 	child  DatastoreConfig
 	prefix string
@@ -238,6 +269,10 @@ func (c *measureDatastoreConfig) DiskSpec() DiskSpec {
 	return c.child.DiskSpec()
 }
 
+func (c *measureDatastoreConfig) Sharded(path string, prefix ds.Key) repo.ShardedDatastore {
+	return sharded(c.child, path, prefix)
+}
+
 func (c measureDatastoreConfig) Create(path string) (repo.Datastore, error) {
 	child, err := c.child.Create(path)
 	if err != nil {
@@ -107,13 +107,18 @@ type FSRepo struct {
 	// the same fsrepo path concurrently
 	lockfile              io.Closer
 	config                *config.Config
+	configLayers          config.Layers
 	userResourceOverrides rcmgr.PartialLimitConfig
 	ds                    repo.Datastore
+	dsc                   DatastoreConfig
 	keystore              keystore.Keystore
 	filemgr               *filestore.FileManager
 }
 
-var _ repo.Repo = (*FSRepo)(nil)
+var (
+	_ repo.Repo        = (*FSRepo)(nil)
+	_ repo.ShardedRepo = (*FSRepo)(nil)
+)
 
 // Open the FSRepo at path. Returns an error if the repo is not
 // initialized.
@@ -436,14 +441,69 @@ func (r *FSRepo) SetGatewayAddr(addr net.Addr) error {
 
 // openConfig returns an error if the config file is not present.
 func (r *FSRepo) openConfig() error {
-	conf, err := serialize.Load(r.configFilePath)
+	layers, err := r.readConfigLayers()
+	if err != nil {
+		return err
+	}
+	conf, err := mergeConfigLayers(layers)
 	if err != nil {
 		return err
 	}
 	r.config = conf
+	r.configLayers = layers
 	return nil
 }
 
+// readConfigLayers reads the config file, the override files in
+// config.OverridesDir and the environment overrides, from lowest to highest
+// precedence.
+func (r *FSRepo) readConfigLayers() (config.Layers, error) {
+	var base map[string]interface{}
+	if err := serialize.ReadConfigFile(r.configFilePath, &base); err != nil {
+		return nil, err
+	}
+	layers := config.Layers{{Source: config.SourceFile, Values: base}}
+
+	// Glob sorts the files, which sets the order they are applied in.
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(r.configFilePath), config.OverridesDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		var values map[string]interface{}
+		if err := serialize.ReadConfigFile(f, &values); err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		layers = append(layers, config.Layer{
+			Source: filepath.Join(config.OverridesDir, filepath.Base(f)),
+			Values: values,
+		})
+	}
+
+	env, err := config.EnvLayers(os.Environ())
+	if err != nil {
+		return nil, err
+	}
+	return append(layers, env...), nil
+}
+
+// mergeConfigLayers validates each layer and returns the effective config.
+// Unknown keys are only logged, so that a stale or misspelled option doesn't
+// prevent the repo from opening.
+func mergeConfigLayers(layers config.Layers) (*config.Config, error) {
+	for _, l := range layers {
+		unknown, err := config.SplitUnknownKeys(config.Validate(l.Values))
+		for _, e := range unknown {
+			log.Warnf("%s: ignoring config key %s", l.Source, e)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", l.Source, err)
+		}
+	}
+	merged, _ := layers.Merge()
+	return config.FromMap(merged)
+}
+
 // openUserResourceOverrides will remove all overrides if the file is not present.
 // It will error if the decoding fails.
 func (r *FSRepo) openUserResourceOverrides() error {
@@ -498,6 +558,7 @@ func (r *FSRepo) openDatastore() error {
 		return err
 	}
 	r.ds = d
+	r.dsc = dsc
 
 	// Wrap it with metrics gathering
 	prefix := "ipfs.fsrepo.datastore"
@@ -642,17 +703,23 @@ func (r *FSRepo) SetConfig(updated *config.Config) error {
 	if err != nil {
 		return err
 	}
+	// keep the values of the override layers out of the config file
+	r.configLayers.RemoveOverrides(m)
 	mergedMap := common.MapMergeDeep(mapconf, m)
 	if err := serialize.WriteConfigFile(r.configFilePath, mergedMap); err != nil {
 		return err
 	}
+	if len(r.configLayers) > 0 {
+		r.configLayers[0].Values = mergedMap
+	}
 	// Do not use `*r.config = ...`. This will modify the *shared* config
 	// returned by `r.Config`.
 	r.config = updated
 	return nil
 }
 
-// GetConfigKey retrieves only the value of a particular key.
+// GetConfigKey retrieves only the effective value of a particular key, see
+// ConfigKeySources.
 func (r *FSRepo) GetConfigKey(key string) (interface{}, error) {
 	packageLock.Lock()
 	defer packageLock.Unlock()
@@ -661,13 +728,32 @@ func (r *FSRepo) GetConfigKey(key string) (interface{}, error) {
 		return nil, errors.New("repo is closed")
 	}
 
-	var cfg map[string]interface{}
-	if err := serialize.ReadConfigFile(r.configFilePath, &cfg); err != nil {
+	layers, err := r.readConfigLayers()
+	if err != nil {
 		return nil, err
 	}
+	cfg, _ := layers.Merge()
 	return common.MapGetKV(cfg, key)
 }
 
+// ConfigKeySources returns the effective value of a key, merged from the
+// config file, the override files and the environment, and the sources it
+// was merged from, from lowest to highest precedence.
+func (r *FSRepo) ConfigKeySources(key string) (interface{}, []string, error) {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	if r.closed {
+		return nil, nil, errors.New("repo is closed")
+	}
+
+	layers, err := r.readConfigLayers()
+	if err != nil {
+		return nil, nil, err
+	}
+	return layers.Source(key)
+}
+
 // SetConfigKey writes the value of a particular key.
 func (r *FSRepo) SetConfigKey(key string, value interface{}) error {
 	packageLock.Lock()
@@ -701,13 +787,24 @@ func (r *FSRepo) SetConfigKey(key string, value interface{}) error {
 		return err
 	}
 
-	// This step doubles as to validate the map against the struct
-	// before serialization
-	conf, err := config.FromMap(mapconf)
+	// Reject typos in the key being set, and values of the wrong type, before
+	// anything is written. Unknown keys set earlier are left alone.
+	unknown, _ := config.SplitUnknownKeys(config.Validate(mapconf))
+	for _, e := range unknown {
+		if e.Path == key || strings.HasPrefix(e.Path, key+".") || strings.HasPrefix(e.Path, key+"[") {
+			return config.ValidationErrors{e}
+		}
+	}
+	layers := config.Layers{{Source: config.SourceFile, Values: mapconf}}
+	if len(r.configLayers) > 1 {
+		layers = append(layers, r.configLayers[1:]...)
+	}
+	conf, err := mergeConfigLayers(layers)
 	if err != nil {
 		return err
 	}
 	r.config = conf
+	r.configLayers = layers
 
 	if err := serialize.WriteConfigFile(r.configFilePath, mapconf); err != nil {
 		return err
@@ -725,6 +822,18 @@ func (r *FSRepo) Datastore() repo.Datastore {
 	return d
 }
 
+// ShardedDatastore returns the datastore mounted at prefix when it is sharded,
+// like flatfs, or nil.
+func (r *FSRepo) ShardedDatastore(prefix ds.Key) repo.ShardedDatastore {
+	packageLock.Lock()
+	dsc := r.dsc
+	packageLock.Unlock()
+	if dsc == nil {
+		return nil
+	}
+	return sharded(dsc, r.path, prefix)
+}
+
 // GetStorageUsage computes the storage space taken by the repo in bytes.
 func (r *FSRepo) GetStorageUsage(ctx context.Context) (uint64, error) {
 	return ds.DiskUsage(ctx, r.Datastore())
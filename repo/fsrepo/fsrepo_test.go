@@ -3,6 +3,7 @@ package fsrepo
 import (
 	"bytes"
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -11,6 +12,7 @@ import (
 
 	datastore "github.com/ipfs/go-datastore"
 	config "github.com/ipfs/kubo/config"
+	serialize "github.com/ipfs/kubo/config/serialize"
 )
 
 func TestInitIdempotence(t *testing.T) {
@@ -107,3 +109,22 @@ func TestOpenMoreThanOnceInSameProcess(t *testing.T) {
 	assert.Nil(r1.Close(), t)
 	assert.Nil(r2.Close(), t)
 }
+
+func TestUnknownConfigKey(t *testing.T) {
+	t.Parallel()
+	m, err := config.ToMap(&config.Config{Identity: config.Identity{PrivKey: "key"}})
+	assert.Nil(err, t)
+	m["Stale"] = true
+
+	_, err = mergeConfigLayers(config.Layers{{Source: config.SourceFile, Values: m}})
+	assert.Nil(err, t, "unknown keys should not prevent the config from loading")
+
+	r := &FSRepo{configFilePath: filepath.Join(t.TempDir(), "config")}
+	assert.Nil(serialize.WriteConfigFile(r.configFilePath, m), t)
+
+	err = r.SetConfigKey("Swarm.ConnMgr.HighWaterr", 10)
+	assert.True(errors.Is(err, config.ErrUnknownKey), t, "typo in the key being set should be rejected")
+	assert.Nil(r.SetConfigKey("Swarm.ConnMgr.HighWater", 10), t, "unrelated unknown keys should not prevent setting a key")
+	err = r.SetConfigKey("Swarm.ConnMgr.HighWater", "ten")
+	assert.True(errors.Is(err, config.ErrInvalidValue), t, "invalid values should be rejected")
+}
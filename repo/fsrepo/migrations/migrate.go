@@ -0,0 +1,252 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// Step is a migration of the repo between two consecutive versions, run in
+// process instead of by the fs-repo-migrations binary of these versions.
+type Step struct {
+	// From is the version the step migrates from, to From+1.
+	From int
+	// Apply migrates the repo in ipfsDir from From to From+1.
+	Apply func(ctx context.Context, ipfsDir string) error
+	// Revert migrates the repo in ipfsDir back from From+1 to From. It is nil
+	// for the steps that can't be reverted.
+	Revert func(ctx context.Context, ipfsDir string) error
+}
+
+var (
+	stepsLk sync.RWMutex
+	steps   = map[int]Step{}
+)
+
+// Register makes Migrate run s in process. The repo version is written by
+// Migrate after each step, not by the step.
+func Register(s Step) error {
+	if s.Apply == nil {
+		return fmt.Errorf("migration %s has no Apply function", migrationName(s.From, s.From+1))
+	}
+	stepsLk.Lock()
+	defer stepsLk.Unlock()
+	if _, ok := steps[s.From]; ok {
+		return fmt.Errorf("migration %s is already registered", migrationName(s.From, s.From+1))
+	}
+	steps[s.From] = s
+	return nil
+}
+
+// PlannedStep is a step of a migration run by Migrate.
+type PlannedStep struct {
+	// Name is the name of the fs-repo-migrations binary of the step, e.g.
+	// fs-repo-15-to-16.
+	Name     string
+	From, To int
+	// InProcess is true for the registered steps, and false for the steps run
+	// by an fs-repo-migrations binary.
+	InProcess bool
+	// Reversible is true when the step is undone on the failure of a later
+	// step of the migration.
+	Reversible bool
+
+	step Step
+	bin  string
+}
+
+// Progress is an event of a migration run by Migrate.
+type Progress struct {
+	Step PlannedStep
+	// Index is the position of Step in the migration, from 1 to Count.
+	Index, Count int
+	// Done is false when Step starts, and true when it succeeded.
+	Done bool
+	// Rollback is true when Step is undone after a later step failed.
+	Rollback bool
+}
+
+// MigrateOptions are the options of Migrate.
+type MigrateOptions struct {
+	// IpfsDir is the repo to migrate, the default location when empty.
+	IpfsDir string
+	// DryRun returns the steps of the migration without running them.
+	DryRun bool
+	// AllowDowngrade allows migrating to an older version.
+	AllowDowngrade bool
+	// Fetcher downloads the fs-repo-migrations binaries of the steps which
+	// are neither registered nor found in the PATH. When nil, these steps
+	// fail the migration before it starts.
+	Fetcher Fetcher
+	// Progress is called when each step starts and ends.
+	Progress func(Progress)
+	// Logger receives the log of the migration and the output of the
+	// fs-repo-migrations binaries. The log is discarded when nil.
+	Logger *log.Logger
+}
+
+// Migrate migrates the repo from version from, which must be its current
+// version, to version to. The registered steps run in process, and the others
+// run their fs-repo-migrations binary. When a step fails, the previous steps
+// are reverted, from the last one, up to the first step that isn't
+// reversible. Migrate returns the steps of the migration.
+func Migrate(ctx context.Context, from, to int, opts MigrateOptions) ([]PlannedStep, error) {
+	ipfsDir, err := CheckIpfsDir(opts.IpfsDir)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := RepoVersion(ipfsDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get repo version: %w", err)
+	}
+	if cur != from {
+		return nil, fmt.Errorf("repo is at version %d, not %d", cur, from)
+	}
+	if from == to {
+		return nil, nil
+	}
+	if from > to && !opts.AllowDowngrade {
+		return nil, fmt.Errorf("downgrade not allowed from %d to %d", from, to)
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	plan, err := planMigration(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		return plan, nil
+	}
+
+	var missing []string
+	for _, s := range plan {
+		if !s.InProcess && s.bin == "" {
+			missing = append(missing, s.Name)
+		}
+	}
+	if len(missing) != 0 {
+		if opts.Fetcher == nil {
+			return nil, fmt.Errorf("no fetcher to download the migrations %v", missing)
+		}
+		logger.Println("Need", len(missing), "migrations, downloading.")
+
+		tmpDir, err := os.MkdirTemp("", "migrations")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmpDir)
+
+		fetched, err := fetchMigrations(ctx, opts.Fetcher, missing, tmpDir, logger)
+		if err != nil {
+			logger.Print("Failed to download migrations.")
+			return nil, err
+		}
+		bins := make(map[string]string, len(missing))
+		for i, name := range missing {
+			bins[name] = fetched[i]
+		}
+		for i := range plan {
+			if bin, ok := bins[plan[i].Name]; ok {
+				plan[i].bin = bin
+			}
+		}
+	}
+
+	progress := func(i int, done, rollback bool) {
+		if opts.Progress != nil {
+			opts.Progress(Progress{Step: plan[i], Index: i + 1, Count: len(plan), Done: done, Rollback: rollback})
+		}
+	}
+	for i, s := range plan {
+		logger.Println("Running migration", s.Name, "...")
+		progress(i, false, false)
+		if err := runStep(ctx, s, ipfsDir, false, logger); err != nil {
+			err = fmt.Errorf("migration %s failed: %w", s.Name, err)
+			if rbErr := rollback(ctx, plan[:i], ipfsDir, logger, progress); rbErr != nil {
+				err = fmt.Errorf("%w, and the rollback failed: %v", err, rbErr)
+			}
+			return plan, err
+		}
+		progress(i, true, false)
+	}
+	logger.Printf("Success: fs-repo migrated to version %d.\n", to)
+
+	return plan, nil
+}
+
+// planMigration returns the steps migrating from version from to version to.
+func planMigration(ctx context.Context, from, to int) ([]PlannedStep, error) {
+	names, binPaths, err := findMigrations(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	stepsLk.RLock()
+	defer stepsLk.RUnlock()
+	plan := make([]PlannedStep, len(names))
+	for i, name := range names {
+		s := PlannedStep{Name: name, From: from + i, To: from + i + 1}
+		if from > to {
+			s.From, s.To = from-i, from-i-1
+		}
+		if from < to {
+			s.step, s.InProcess = steps[s.From]
+			s.Reversible = !s.InProcess || s.step.Revert != nil
+		} else {
+			// a downgrade reverts the step, and undoing it applies the step
+			st, ok := steps[s.To]
+			s.step, s.InProcess = st, ok && st.Revert != nil
+			s.Reversible = true
+		}
+		if !s.InProcess {
+			s.bin = binPaths[name]
+		}
+		plan[i] = s
+	}
+	return plan, nil
+}
+
+// rollback undoes the steps done, from the last one, up to the first step
+// which isn't reversible.
+func rollback(ctx context.Context, done []PlannedStep, ipfsDir string, logger *log.Logger, progress func(int, bool, bool)) error {
+	for i := len(done) - 1; i >= 0; i-- {
+		s := done[i]
+		if !s.Reversible {
+			logger.Printf("Migration %s is not reversible, the repo is left at version %d.", s.Name, s.To)
+			return nil
+		}
+		logger.Println("Rolling back migration", s.Name, "...")
+		progress(i, false, true)
+		if err := runStep(ctx, s, ipfsDir, true, logger); err != nil {
+			return fmt.Errorf("rolling back %s: %w", s.Name, err)
+		}
+		progress(i, true, true)
+	}
+	return nil
+}
+
+// runStep runs s, or undoes it when undo is true.
+func runStep(ctx context.Context, s PlannedStep, ipfsDir string, undo bool, logger *log.Logger) error {
+	from, to := s.From, s.To
+	if undo {
+		from, to = to, from
+	}
+	if !s.InProcess {
+		return runMigration(ctx, s.bin, ipfsDir, from > to, logger)
+	}
+
+	run := s.step.Apply
+	if from > to {
+		run = s.step.Revert
+	}
+	if err := run(ctx, ipfsDir); err != nil {
+		return err
+	}
+	return WriteRepoVersion(ipfsDir, to)
+}
@@ -0,0 +1,96 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	ipfsDir := t.TempDir()
+	if err := WriteRepoVersion(ipfsDir, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	var applied []int
+	noop := func(ctx context.Context, ipfsDir string) error { return nil }
+	for from := 1000; from < 1002; from++ {
+		from := from
+		err := Register(Step{
+			From: from,
+			Apply: func(ctx context.Context, ipfsDir string) error {
+				applied = append(applied, from)
+				return nil
+			},
+			Revert: func(ctx context.Context, ipfsDir string) error {
+				applied = append(applied, -from)
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Register(Step{From: 1000, Apply: noop}); err == nil {
+		t.Fatal("expected an error registering a step twice")
+	}
+	if err := Register(Step{From: 1002, Apply: func(ctx context.Context, ipfsDir string) error {
+		return errors.New("broken")
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := Migrate(ctx, 1000, 1003, MigrateOptions{IpfsDir: ipfsDir, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 3 || !plan[0].InProcess || !plan[1].Reversible || plan[2].Reversible || plan[2].Name != "fs-repo-1002-to-1003" {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	if len(applied) != 0 {
+		t.Fatal("the dry run applied steps")
+	}
+
+	if _, err := Migrate(ctx, 999, 1003, MigrateOptions{IpfsDir: ipfsDir}); err == nil {
+		t.Fatal("expected an error for the wrong current version")
+	}
+
+	var events int
+	_, err = Migrate(ctx, 1000, 1003, MigrateOptions{
+		IpfsDir:  ipfsDir,
+		Progress: func(Progress) { events++ },
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "migration fs-repo-1002-to-1003 failed") {
+		t.Fatalf("expected the last step to fail, got %v", err)
+	}
+	// the first two steps are rolled back
+	if fmt.Sprint(applied) != fmt.Sprint([]int{1000, 1001, -1001, -1000}) {
+		t.Fatalf("unexpected steps %v", applied)
+	}
+	if events != 2*2+1+2*2 {
+		t.Fatalf("unexpected number of progress events %d", events)
+	}
+	if ver, err := RepoVersion(ipfsDir); err != nil || ver != 1000 {
+		t.Fatalf("expected the repo back at version 1000, got %d, %v", ver, err)
+	}
+
+	applied = nil
+	if _, err := Migrate(ctx, 1000, 1002, MigrateOptions{IpfsDir: ipfsDir}); err != nil {
+		t.Fatal(err)
+	}
+	if ver, _ := RepoVersion(ipfsDir); ver != 1002 || len(applied) != 2 {
+		t.Fatalf("unexpected version %d after %v", ver, applied)
+	}
+	if _, err := Migrate(ctx, 1002, 1000, MigrateOptions{IpfsDir: ipfsDir}); err == nil {
+		t.Fatal("expected the downgrade to be refused")
+	}
+	if _, err := Migrate(ctx, 1002, 1000, MigrateOptions{IpfsDir: ipfsDir, AllowDowngrade: true}); err != nil {
+		t.Fatal(err)
+	}
+	if ver, _ := RepoVersion(ipfsDir); ver != 1000 {
+		t.Fatalf("unexpected version %d", ver)
+	}
+}
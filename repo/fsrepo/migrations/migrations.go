@@ -24,7 +24,8 @@ const (
 )
 
 // RunMigration finds, downloads, and runs the individual migrations needed to
-// migrate the repo from its current version to the target version.
+// migrate the repo from its current version to the target version. See
+// Migrate to run the migrations with more control.
 func RunMigration(ctx context.Context, fetcher Fetcher, targetVer int, ipfsDir string, allowDowngrade bool) error {
 	ipfsDir, err := CheckIpfsDir(ipfsDir)
 	if err != nil {
@@ -34,65 +35,19 @@ func RunMigration(ctx context.Context, fetcher Fetcher, targetVer int, ipfsDir s
 	if err != nil {
 		return fmt.Errorf("could not get repo version: %w", err)
 	}
-	if fromVer == targetVer {
-		// repo already at target version number
-		return nil
-	}
-	if fromVer > targetVer && !allowDowngrade {
-		return fmt.Errorf("downgrade not allowed from %d to %d", fromVer, targetVer)
-	}
 
 	logger := log.New(os.Stdout, "", 0)
-
-	logger.Print("Looking for suitable migration binaries.")
-
-	migrations, binPaths, err := findMigrations(ctx, fromVer, targetVer)
-	if err != nil {
-		return err
-	}
-
-	// Download migrations that were not found
-	if len(binPaths) < len(migrations) {
-		missing := make([]string, 0, len(migrations)-len(binPaths))
-		for _, mig := range migrations {
-			if _, ok := binPaths[mig]; !ok {
-				missing = append(missing, mig)
-			}
-		}
-
-		logger.Println("Need", len(missing), "migrations, downloading.")
-
-		tmpDir, err := os.MkdirTemp("", "migrations")
-		if err != nil {
-			return err
-		}
-		defer os.RemoveAll(tmpDir)
-
-		fetched, err := fetchMigrations(ctx, fetcher, missing, tmpDir, logger)
-		if err != nil {
-			logger.Print("Failed to download migrations.")
-			return err
-		}
-
-		for i := range missing {
-			binPaths[missing[i]] = fetched[i]
-		}
-	}
-
-	var revert bool
-	if fromVer > targetVer {
-		revert = true
-	}
-	for _, migration := range migrations {
-		logger.Println("Running migration", migration, "...")
-		err = runMigration(ctx, binPaths[migration], ipfsDir, revert, logger)
-		if err != nil {
-			return fmt.Errorf("migration %s failed: %w", migration, err)
-		}
+	if fromVer != targetVer && (fromVer < targetVer || allowDowngrade) {
+		logger.Print("Looking for suitable migration binaries.")
 	}
-	logger.Printf("Success: fs-repo migrated to version %d.\n", targetVer)
 
-	return nil
+	_, err = Migrate(ctx, fromVer, targetVer, MigrateOptions{
+		IpfsDir:        ipfsDir,
+		AllowDowngrade: allowDowngrade,
+		Fetcher:        fetcher,
+		Logger:         logger,
+	})
+	return err
 }
 
 func NeedMigration(target int) (bool, error) {
@@ -243,7 +198,7 @@ func runMigration(ctx context.Context, binPath, ipfsDir string, revert bool, log
 		logger.Println("  => Running:", binPath, pathArg, "-verbose=true")
 		cmd = exec.CommandContext(ctx, binPath, pathArg, "-verbose=true")
 	}
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = logger.Writer()
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/facebookgo/atomicfile"
+)
+
+func init() {
+	if err := Register(Step{From: 14, Apply: quicV1}); err != nil {
+		panic(err)
+	}
+}
+
+// quicAddrKeys are the keys of the Addresses config section holding
+// multiaddrs.
+var quicAddrKeys = []string{"Swarm", "Announce", "AppendAnnounce", "NoAnnounce"}
+
+// quicV1 is the fs-repo-14-to-15 migration: QUIC draft 29 is no longer
+// supported, so the /quic addresses of the config are replaced by /quic-v1
+// ones, dropping the duplicates. It isn't reversible, as the older versions
+// listen on both.
+func quicV1(ctx context.Context, ipfsDir string) error {
+	path := filepath.Join(ipfsDir, "config")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var cfg map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&cfg); err != nil {
+		return fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	addrs, _ := cfg["Addresses"].(map[string]interface{})
+	for _, key := range quicAddrKeys {
+		list, ok := addrs[key].([]interface{})
+		if !ok {
+			continue
+		}
+		seen := make(map[string]bool, len(list))
+		out := make([]interface{}, 0, len(list))
+		for _, v := range list {
+			s, ok := v.(string)
+			if !ok {
+				out = append(out, v)
+				continue
+			}
+			parts := strings.Split(s, "/")
+			for i, p := range parts {
+				if p == "quic" {
+					parts[i] = "quic-v1"
+				}
+			}
+			s = strings.Join(parts, "/")
+			if !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+		addrs[key] = out
+	}
+
+	data, err = json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := atomicfile.New(path, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestQuicV1(t *testing.T) {
+	ipfsDir := t.TempDir()
+	cfg := `{
+  "Addresses": {
+    "Swarm": ["/ip4/0.0.0.0/tcp/4001", "/ip4/0.0.0.0/udp/4001/quic", "/ip4/0.0.0.0/udp/4001/quic-v1"],
+    "Announce": ["/ip4/1.2.3.4/udp/4001/quic"],
+    "NoAnnounce": []
+  },
+  "Datastore": {"StorageMax": 10000000000}
+}`
+	if err := os.WriteFile(filepath.Join(ipfsDir, "config"), []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteRepoVersion(ipfsDir, 14); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := Migrate(context.Background(), 14, 15, MigrateOptions{IpfsDir: ipfsDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 1 || !plan[0].InProcess || plan[0].Reversible {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	if v, err := RepoVersion(ipfsDir); err != nil || v != 15 {
+		t.Fatalf("repo version %d, %v", v, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(ipfsDir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Addresses map[string][]string
+		Datastore map[string]json.Number
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		"Swarm":      {"/ip4/0.0.0.0/tcp/4001", "/ip4/0.0.0.0/udp/4001/quic-v1"},
+		"Announce":   {"/ip4/1.2.3.4/udp/4001/quic-v1"},
+		"NoAnnounce": {},
+	}
+	if !reflect.DeepEqual(out.Addresses, expected) {
+		t.Fatalf("unexpected addresses %v", out.Addresses)
+	}
+	if out.Datastore["StorageMax"] != "10000000000" {
+		t.Fatalf("unexpected datastore %v", out.Datastore)
+	}
+}
@@ -48,6 +48,10 @@ func (m *Mock) GetConfigKey(key string) (interface{}, error) {
 	return nil, errTODO
 }
 
+func (m *Mock) ConfigKeySources(key string) (interface{}, []string, error) {
+	return nil, nil, errTODO
+}
+
 func (m *Mock) Datastore() Datastore { return m.D }
 
 func (m *Mock) GetStorageUsage(_ context.Context) (uint64, error) { return 0, nil }
@@ -40,6 +40,10 @@ type Repo interface {
 	// GetConfigKey reads the value for the given key from the configuration in storage.
 	GetConfigKey(key string) (interface{}, error)
 
+	// ConfigKeySources reads the value for the given key like GetConfigKey,
+	// and returns the config layers it was merged from.
+	ConfigKeySources(key string) (interface{}, []string, error)
+
 	// Datastore returns a reference to the configured data storage backend.
 	Datastore() Datastore
 
@@ -69,3 +73,22 @@ type Repo interface {
 type Datastore interface {
 	ds.Batching // must be thread-safe
 }
+
+// ShardedDatastore is implemented by datastores that spread their keys over
+// shards that can be listed independently, like the directories of flatfs, so
+// that the keys can be enumerated concurrently.
+type ShardedDatastore interface {
+	// Shards returns the names of the shards.
+	Shards(ctx context.Context) ([]string, error)
+
+	// ShardKeys returns the keys stored in the given shard.
+	ShardKeys(ctx context.Context, shard string) ([]ds.Key, error)
+}
+
+// ShardedRepo is implemented by repos that can tell which part of their
+// datastore is sharded.
+type ShardedRepo interface {
+	// ShardedDatastore returns the datastore mounted at prefix when it is a
+	// ShardedDatastore, nil otherwise.
+	ShardedDatastore(prefix ds.Key) ShardedDatastore
+}
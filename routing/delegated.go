@@ -3,7 +3,6 @@ package routing
 import (
 	"context"
 	"encoding/base64"
-	"errors"
 	"fmt"
 	"net/http"
 
@@ -13,9 +12,6 @@ import (
 	logging "github.com/ipfs/go-log"
 	version "github.com/ipfs/kubo"
 	"github.com/ipfs/kubo/config"
-	dht "github.com/libp2p/go-libp2p-kad-dht"
-	"github.com/libp2p/go-libp2p-kad-dht/dual"
-	"github.com/libp2p/go-libp2p-kad-dht/fullrt"
 	record "github.com/libp2p/go-libp2p-record"
 	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
 	ic "github.com/libp2p/go-libp2p/core/crypto"
@@ -268,65 +264,3 @@ type ExtraDHTParams struct {
 	Datastore      datastore.Batching
 	Context        context.Context
 }
-
-func dhtRoutingFromConfig(conf config.Router, extra *ExtraDHTParams) (routing.Routing, error) {
-	params, ok := conf.Parameters.(*config.DHTRouterParams)
-	if !ok {
-		return nil, errors.New("incorrect params for DHT router")
-	}
-
-	if params.AcceleratedDHTClient {
-		return createFullRT(extra)
-	}
-
-	var mode dht.ModeOpt
-	switch params.Mode {
-	case config.DHTModeAuto:
-		mode = dht.ModeAuto
-	case config.DHTModeClient:
-		mode = dht.ModeClient
-	case config.DHTModeServer:
-		mode = dht.ModeServer
-	default:
-		return nil, fmt.Errorf("invalid DHT mode: %q", params.Mode)
-	}
-
-	return createDHT(extra, params.PublicIPNetwork, mode)
-}
-
-func createDHT(params *ExtraDHTParams, public bool, mode dht.ModeOpt) (routing.Routing, error) {
-	var opts []dht.Option
-
-	if public {
-		opts = append(opts, dht.QueryFilter(dht.PublicQueryFilter),
-			dht.RoutingTableFilter(dht.PublicRoutingTableFilter),
-			dht.RoutingTablePeerDiversityFilter(dht.NewRTPeerDiversityFilter(params.Host, 2, 3)))
-	} else {
-		opts = append(opts, dht.ProtocolExtension(dual.LanExtension),
-			dht.QueryFilter(dht.PrivateQueryFilter),
-			dht.RoutingTableFilter(dht.PrivateRoutingTableFilter))
-	}
-
-	opts = append(opts,
-		dht.Concurrency(10),
-		dht.Mode(mode),
-		dht.Datastore(params.Datastore),
-		dht.Validator(params.Validator),
-		dht.BootstrapPeers(params.BootstrapPeers...))
-
-	return dht.New(
-		params.Context, params.Host, opts...,
-	)
-}
-
-func createFullRT(params *ExtraDHTParams) (routing.Routing, error) {
-	return fullrt.NewFullRT(params.Host,
-		dht.DefaultPrefix,
-		fullrt.DHTOption(
-			dht.Validator(params.Validator),
-			dht.Datastore(params.Datastore),
-			dht.BootstrapPeers(params.BootstrapPeers...),
-			dht.BucketSize(20),
-		),
-	)
-}
@@ -0,0 +1,15 @@
+//go:build nodht
+// +build nodht
+
+package routing
+
+import (
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/subsystem"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+// dhtRoutingFromConfig fails, as the DHT is compiled out.
+func dhtRoutingFromConfig(_ config.Router, _ *ExtraDHTParams) (routing.Routing, error) {
+	return nil, subsystem.Check(subsystem.DHT)
+}
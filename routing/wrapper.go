@@ -5,6 +5,7 @@ import (
 
 	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
 	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/multiformats/go-multihash"
 )
 
 type ProvideManyRouter interface {
@@ -29,3 +30,29 @@ type httpRoutingWrapper struct {
 func (c *httpRoutingWrapper) Bootstrap(ctx context.Context) error {
 	return nil
 }
+
+// KeepProvideMany returns wrapper, a router wrapping inner, keeping the
+// ability of inner to provide many keys at once when it has it.
+func KeepProvideMany(wrapper, inner routing.Routing) routing.Routing {
+	pm, ok := inner.(routinghelpers.ProvideManyRouter)
+	if !ok {
+		return wrapper
+	}
+	return &provideManyWrapper{Routing: wrapper, pm: pm}
+}
+
+type provideManyWrapper struct {
+	routing.Routing
+	pm routinghelpers.ProvideManyRouter
+}
+
+func (r *provideManyWrapper) ProvideMany(ctx context.Context, keys []multihash.Multihash) error {
+	return r.pm.ProvideMany(ctx, keys)
+}
+
+func (r *provideManyWrapper) Ready() bool {
+	if rr, ok := r.pm.(routinghelpers.ReadyAbleRouter); ok {
+		return rr.Ready()
+	}
+	return true
+}
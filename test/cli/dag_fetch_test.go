@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/ipfs/kubo/test/cli/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tcpSwarmAddr returns the TCP swarm address of n, with its peer ID.
+func tcpSwarmAddr(t *testing.T, n *harness.Node) string {
+	for _, ma := range n.SwarmAddrsWithPeerIDs() {
+		s := ma.String()
+		if strings.Contains(s, "/tcp/") && !strings.Contains(s, "/ws") {
+			return s
+		}
+	}
+	t.Fatal("no TCP swarm address")
+	return ""
+}
+
+func TestDagFetch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fetches the DAG from the given peer", func(t *testing.T) {
+		t.Parallel()
+		nodes := harness.NewT(t).NewNodes(2).Init().StartDaemons()
+		node1, node2 := nodes[0], nodes[1]
+
+		data := testutils.RandomBytes(1024 * 1024)
+		cid := node2.IPFSAdd(bytes.NewReader(data), "--chunker=size-1024")
+
+		res := node1.IPFS("dag", "fetch", "--pin", cid, tcpSwarmAddr(t, node2))
+		assert.Contains(t, res.Stdout.String(), "fetched "+cid)
+
+		// the blocks are local, and pinned
+		res = node1.IPFS("cat", "--offline", cid)
+		assert.Equal(t, data, res.Stdout.Bytes())
+		res = node1.IPFS("pin", "ls", "--type=recursive", cid)
+		assert.Contains(t, res.Stdout.String(), cid)
+	})
+
+	t.Run("fails when the peer doesn't have the DAG", func(t *testing.T) {
+		t.Parallel()
+		nodes := harness.NewT(t).NewNodes(3).Init().StartDaemons()
+		node1, node2, node3 := nodes[0], nodes[1], nodes[2]
+
+		// only node3 has it, and is connected to node1, but is never asked
+		cid := node3.IPFSAddStr("only on node3")
+		node1.Connect(node3)
+
+		res := node1.RunIPFS("dag", "fetch", "--timeout=2s", cid, tcpSwarmAddr(t, node2))
+		assert.Equal(t, 1, res.ExitCode())
+		res = node1.RunIPFS("block", "stat", "--offline", cid)
+		assert.Equal(t, 1, res.ExitCode())
+	})
+
+	t.Run("fails when the peer is unreachable", func(t *testing.T) {
+		t.Parallel()
+		nodes := harness.NewT(t).NewNodes(2).Init().StartDaemons()
+		node1, node2 := nodes[0], nodes[1]
+
+		cid := node2.IPFSAddStr("unreachable")
+		addr := tcpSwarmAddr(t, node2)
+		node2.StopDaemon()
+
+		res := node1.RunIPFS("dag", "fetch", cid, addr)
+		assert.Equal(t, 1, res.ExitCode())
+		require.Contains(t, res.Stderr.String(), "failed to connect")
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"runtime"
 
+	"github.com/ipfs/kubo/core/subsystem"
 	"github.com/ipfs/kubo/repo/fsrepo"
 )
 
@@ -41,14 +42,17 @@ type VersionInfo struct {
 	Repo    string
 	System  string
 	Golang  string
+	// Disabled are the subsystems compiled out with build tags.
+	Disabled []string `json:",omitempty"`
 }
 
 func GetVersionInfo() *VersionInfo {
 	return &VersionInfo{
-		Version: CurrentVersionNumber,
-		Commit:  CurrentCommit,
-		Repo:    fmt.Sprint(fsrepo.RepoVersion),
-		System:  runtime.GOARCH + "/" + runtime.GOOS, // TODO: Precise version here
-		Golang:  runtime.Version(),
+		Version:  CurrentVersionNumber,
+		Commit:   CurrentCommit,
+		Repo:     fmt.Sprint(fsrepo.RepoVersion),
+		System:   runtime.GOARCH + "/" + runtime.GOOS, // TODO: Precise version here
+		Golang:   runtime.Version(),
+		Disabled: subsystem.Disabled(),
 	}
 }